@@ -48,6 +48,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/token_scopes"
@@ -76,6 +77,12 @@ type (
 		addr net.Addr
 	}
 
+	// A connection accepted through the broker, counted as active until it's closed
+	reversedConn struct {
+		net.Conn
+		closeOnce sync.Once
+	}
+
 	// Struct holding pending requests waiting on an origin callback
 	pendingReversals struct {
 		channel chan http.ResponseWriter
@@ -94,6 +101,17 @@ func (hj *hijackConn) Close() error {
 	return nil
 }
 
+func newReversedConn(conn net.Conn) *reversedConn {
+	metrics.PelicanBrokerReversedConnectionsActive.Inc()
+	metrics.PelicanBrokerReversedConnectionsTotal.Inc()
+	return &reversedConn{Conn: conn}
+}
+
+func (rc *reversedConn) Close() error {
+	rc.closeOnce.Do(metrics.PelicanBrokerReversedConnectionsActive.Dec)
+	return rc.Conn.Close()
+}
+
 // Returns a new 'one shot listener' from a given TCP connection
 func newOneShotListener(conn *net.TCPConn) net.Listener {
 	listener := &oneShotListener{addr: conn.LocalAddr()}
@@ -107,7 +125,7 @@ func (listener *oneShotListener) Accept() (conn net.Conn, err error) {
 		err = net.ErrClosed
 		return
 	}
-	conn = tcpConn
+	conn = newReversedConn(tcpConn)
 	return
 }
 
@@ -24,6 +24,10 @@ import (
 	"math/rand"
 	"sync"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/pelicanplatform/pelican/metrics"
 )
 
 type (
@@ -69,6 +73,9 @@ func getOriginQueue(prefix, origin string) chan reversalRequest {
 // Return a requestTimeout error if no origin retrieved the request before the context timed out.
 func handleRequest(ctx context.Context, origin string, req reversalRequest, timeout time.Duration) (err error) {
 	queue := getOriginQueue(req.Prefix, origin)
+	pending := metrics.PelicanBrokerPendingReversals.With(prometheus.Labels{"prefix": req.Prefix})
+	pending.Inc()
+	defer pending.Dec()
 	maxTime := timeout - 500*time.Millisecond - time.Duration(rand.Intn(500))*time.Millisecond
 	if maxTime <= 0 {
 		maxTime = time.Millisecond
@@ -86,6 +93,11 @@ func handleRequest(ctx context.Context, origin string, req reversalRequest, time
 		err = errRequestTimeout
 		break
 	}
+	result := metrics.BrokerReversalSucceeded
+	if err != nil {
+		result = metrics.BrokerReversalTimeout
+	}
+	metrics.PelicanBrokerReversalsTotal.With(prometheus.Labels{"prefix": req.Prefix, "result": string(result)}).Inc()
 	return
 }
 
@@ -98,6 +110,9 @@ func handleRetrieve(appCtx context.Context, ginCtx context.Context, prefix, orig
 	}
 	tick := time.NewTicker(maxTime)
 	defer tick.Stop()
+	waiting := metrics.PelicanBrokerWaitingServices.With(prometheus.Labels{"prefix": prefix})
+	waiting.Inc()
+	defer waiting.Dec()
 	select {
 	case req = <-getOriginQueue(prefix, origin):
 		break
@@ -0,0 +1,75 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package broker
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/metrics"
+)
+
+func TestReversalMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	labels := prometheus.Labels{"prefix": "/metrics-test"}
+	succeeded := testutil.ToFloat64(metrics.PelicanBrokerReversalsTotal.With(prometheus.Labels{"prefix": "/metrics-test", "result": "Succeeded"}))
+	timedOut := testutil.ToFloat64(metrics.PelicanBrokerReversalsTotal.With(prometheus.Labels{"prefix": "/metrics-test", "result": "Timeout"}))
+
+	// A request nobody retrieves times out
+	err := handleRequest(ctx, "origin.example.org", reversalRequest{Prefix: "/metrics-test"}, 500*time.Millisecond)
+	assert.ErrorIs(t, err, errRequestTimeout)
+	assert.Equal(t, timedOut+1, testutil.ToFloat64(metrics.PelicanBrokerReversalsTotal.With(prometheus.Labels{"prefix": "/metrics-test", "result": "Timeout"})))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.PelicanBrokerPendingReversals.With(labels)))
+
+	// The service waits at the broker until a request arrives
+	retrieved := make(chan reversalRequest)
+	go func() {
+		req, err := handleRetrieve(ctx, ctx, "/metrics-test", "origin.example.org", 10*time.Second)
+		assert.NoError(t, err)
+		retrieved <- req
+	}()
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(metrics.PelicanBrokerWaitingServices.With(labels)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, handleRequest(ctx, "origin.example.org", reversalRequest{Prefix: "/metrics-test", RequestId: "abc"}, 10*time.Second))
+	assert.Equal(t, "abc", (<-retrieved).RequestId)
+	assert.Equal(t, succeeded+1, testutil.ToFloat64(metrics.PelicanBrokerReversalsTotal.With(prometheus.Labels{"prefix": "/metrics-test", "result": "Succeeded"})))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.PelicanBrokerWaitingServices.With(labels)))
+}
+
+func TestReversedConnMetrics(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	active := testutil.ToFloat64(metrics.PelicanBrokerReversedConnectionsActive)
+
+	conn := newReversedConn(server)
+	assert.Equal(t, active+1, testutil.ToFloat64(metrics.PelicanBrokerReversedConnectionsActive))
+	require.NoError(t, conn.Close())
+	// Closing the connection again doesn't count it twice
+	_ = conn.Close()
+	assert.Equal(t, active, testutil.ToFloat64(metrics.PelicanBrokerReversedConnectionsActive))
+}
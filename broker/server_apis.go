@@ -106,6 +106,7 @@ func retrieveRequest(ctx context.Context, ginCtx *gin.Context) {
 	}
 	if !ok {
 		ginCtx.AbortWithStatusJSON(http.StatusUnauthorized, newBrokerRespFail("Authorization denied"))
+		return
 	}
 
 	req, err := handleRetrieve(ctx, ginCtx, originReq.Prefix, originReq.Origin, timeoutVal)
@@ -153,6 +154,7 @@ func reverseRequest(ctx context.Context, ginCtx *gin.Context) {
 	}
 	if !ok {
 		ginCtx.AbortWithStatusJSON(http.StatusUnauthorized, newBrokerRespFail("Authorization denied"))
+		return
 	}
 
 	reversalReq := reversalRequest{}
@@ -223,6 +225,7 @@ func handleCallback(ctx context.Context, ginCtx *gin.Context) {
 	}
 	if !ok {
 		ginCtx.AbortWithStatusJSON(http.StatusUnauthorized, newBrokerRespFail("Authorization denied"))
+		return
 	}
 
 	// Pass the response writer to the handler (or wait for
@@ -41,18 +41,34 @@ type (
 	}
 )
 
-func (server *CacheServer) CreateAdvertisement(name, originUrl, originWebUrl string) (*server_structs.OriginAdvertiseV2, error) {
+func (server *CacheServer) CreateAdvertisement(name, originUrl, originWebUrl string) (*server_structs.OriginAdvertiseV3, error) {
 	registryPrefix := server_structs.GetCacheNS(param.Xrootd_Sitename.GetString())
 	ad := server_structs.OriginAdvertiseV2{
-		Name:           name,
-		RegistryPrefix: registryPrefix,
-		DataURL:        originUrl,
-		WebURL:         originWebUrl,
-		Namespaces:     server.GetNamespaceAds(),
-		Version:        config.GetVersion(),
+		Name:              name,
+		RegistryPrefix:    registryPrefix,
+		DataURL:           originUrl,
+		WebURL:            originWebUrl,
+		Namespaces:        filterLimitedNamespaceAds(server.GetNamespaceAds()),
+		Version:           config.GetVersion(),
+		Region:            strings.ToLower(param.Cache_Region.GetString()),
+		OfflinePartitions: offlineDataLocations(),
+	}
+	if param.Cache_EnableLocalHttp.GetBool() {
+		ad.LocalHttpURL = getLocalHttpUrl()
+		ad.LocalHttpNetworks = param.Cache_LocalHttpNetworks.GetStringSlice()
 	}
 
-	return &ad, nil
+	adV3 := &server_structs.OriginAdvertiseV3{
+		OriginAdvertiseV2: ad,
+		// The checksums enabled by xrootd.chksum in xrootd-cache.cfg
+		ChecksumAlgorithms: []string{"md5", "adler32"},
+	}
+	adV3.Degraded, adV3.Capacity = diskDegradation()
+	if adV3.Capacity >= 1 {
+		adV3.Capacity = 0
+	}
+	adV3.ContentFilter = advertisedContent.Load()
+	return adV3, nil
 }
 
 func (server *CacheServer) SetPids(pids []int) {
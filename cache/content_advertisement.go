@@ -0,0 +1,110 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package cache
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// The rate of false positives of the filter of the cache's content
+const contentFilterFalsePositiveRate = 0.01
+
+// The filter of the cache's content included in its advertisement, if enabled
+var advertisedContent atomic.Pointer[server_structs.ContentFilter]
+
+// Build a Bloom filter of the most recently accessed objects in the cache
+func buildContentFilter(namespaceLocation string, maxObjects int) (*server_structs.ContentFilter, int, error) {
+	files, _, err := listCachedFiles(namespaceLocation)
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].lastAccess.After(files[j].lastAccess) })
+	objects := make([]string, 0, min(len(files), maxObjects))
+	for _, file := range files {
+		if len(objects) >= maxObjects {
+			break
+		}
+		rel, err := filepath.Rel(namespaceLocation, file.name)
+		if err != nil {
+			continue
+		}
+		objectPath := path.Clean("/" + filepath.ToSlash(rel))
+		// The self-test objects are of no use to clients
+		if strings.HasPrefix(objectPath, "/pelican/") {
+			continue
+		}
+		objects = append(objects, objectPath)
+	}
+	filter := server_structs.NewContentFilter(len(objects), contentFilterFalsePositiveRate)
+	for _, objectPath := range objects {
+		filter.Add(objectPath)
+	}
+	return filter, len(objects), nil
+}
+
+func updateContentFilter() {
+	start := time.Now()
+	filter, objects, err := buildContentFilter(param.Cache_NamespaceLocation.GetString(), param.Cache_ContentAdvertisementMaxObjects.GetInt())
+	if err != nil {
+		log.Warningln("Failed to list the content of the cache for the director:", err)
+		return
+	}
+	advertisedContent.Store(filter)
+	log.Debugf("Built the filter of the %d objects advertised to the director in %s", objects, time.Since(start).String())
+}
+
+// Periodically rebuild the filter of the cache's recent content advertised to the director,
+// if Cache.EnableContentAdvertisement is set
+func LaunchContentAdvertisement(ctx context.Context, egrp *errgroup.Group) {
+	if !param.Cache_EnableContentAdvertisement.GetBool() {
+		return
+	}
+	interval := param.Cache_ContentAdvertisementInterval.GetDuration()
+	if interval <= 0 {
+		interval = 5 * time.Minute
+		log.Error("Invalid config value: Cache.ContentAdvertisementInterval must be positive. Fallback to 5m.")
+	}
+	egrp.Go(func() error {
+		defer advertisedContent.Store(nil)
+		updateContentFilter()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				updateContentFilter()
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	log.Infoln("Advertising the content of the cache to the director")
+}
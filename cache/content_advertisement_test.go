@@ -0,0 +1,58 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildContentFilter(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for idx, name := range []string{"foo/new", "foo/old", "bar/oldest", "pelican/selftest/self-test-1.txt"} {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		require.NoError(t, os.WriteFile(file, []byte("data"), 0644))
+		require.NoError(t, os.WriteFile(file+".cinfo", []byte{}, 0644))
+		accessed := now.Add(-time.Duration(idx) * time.Hour)
+		require.NoError(t, os.Chtimes(file, accessed, accessed))
+		require.NoError(t, os.Chtimes(file+".cinfo", accessed, accessed))
+	}
+
+	filter, objects, err := buildContentFilter(dir, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, objects)
+	require.NoError(t, filter.Validate())
+	assert.True(t, filter.MayContain("/foo/new"))
+	assert.True(t, filter.MayContain("/foo/old"))
+	assert.True(t, filter.MayContain("/bar/oldest"))
+
+	// Only the most recently accessed objects are advertised
+	filter, objects, err = buildContentFilter(dir, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, objects)
+	assert.True(t, filter.MayContain("/foo/new"))
+	assert.True(t, filter.MayContain("/foo/old"))
+}
@@ -0,0 +1,190 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// The condition of the filesystem and disk behind a data partition
+	diskStatus struct {
+		// Why the disk is unfit to keep storing data, e.g., a failed SMART check;
+		// empty if it's fine
+		Problem    string
+		FreeBytes  uint64
+		TotalBytes uint64
+	}
+
+	// The filesystem a path lives on, from /proc/self/mountinfo
+	mountInfo struct {
+		MountPoint string
+		FsType     string
+		Source     string
+		ReadOnly   bool
+	}
+
+	freeSpaceSample struct {
+		at        time.Time
+		freeBytes uint64
+	}
+)
+
+const (
+	// How long a SMART result is reused before smartctl is run again
+	smartCheckInterval = 10 * time.Minute
+	// The period of free space samples the trend is computed from
+	freeSpaceTrendWindow = time.Hour
+)
+
+var errDiskStatusUnsupported = errors.New("disk status checks are not supported on this platform")
+
+var mountEscapes = strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+
+// Find the mount of /proc/self/mountinfo holding path, which must have its
+// symlinks resolved
+func findMount(mountinfo io.Reader, path string) (mountInfo, error) {
+	var found mountInfo
+	scanner := bufio.NewScanner(mountinfo)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt/parent rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for idx, field := range fields {
+			if field == "-" {
+				sep = idx
+				break
+			}
+		}
+		if sep < 6 || len(fields) < sep+3 {
+			continue
+		}
+		mountPoint := mountEscapes.Replace(fields[4])
+		if mountPoint != "/" && !pathInNamespace(path, mountPoint) {
+			continue
+		}
+		// Later mounts over the same point hide earlier ones
+		if len(mountPoint) < len(found.MountPoint) {
+			continue
+		}
+		found = mountInfo{
+			MountPoint: mountPoint,
+			FsType:     fields[sep+1],
+			Source:     mountEscapes.Replace(fields[sep+2]),
+		}
+		for _, option := range strings.Split(fields[5], ",") {
+			if option == "ro" {
+				found.ReadOnly = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return mountInfo{}, errors.Wrap(err, "failed to read the mount table")
+	}
+	if found.MountPoint == "" {
+		return mountInfo{}, errors.Errorf("no mount holds %s", path)
+	}
+	return found, nil
+}
+
+// Interpret the output of "smartctl --json -H", returning why the disk is
+// failing, if it is.  Disks that don't report their SMART status pass.
+func parseSmartStatus(output []byte) (string, error) {
+	var result struct {
+		SmartStatus *struct {
+			Passed bool `json:"passed"`
+		} `json:"smart_status"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return "", errors.Wrap(err, "failed to parse the output of smartctl")
+	}
+	if result.SmartStatus == nil || result.SmartStatus.Passed {
+		return "", nil
+	}
+	return "the disk failed its SMART health check", nil
+}
+
+// The name of a block device under /sys, e.g., dm-0 for /dev/mapper/vg-data
+func blockDeviceName(source string) string {
+	if resolved, err := filepath.EvalSymlinks(source); err == nil {
+		source = resolved
+	}
+	return filepath.Base(source)
+}
+
+func describeFsErrors(count int) string {
+	if count == 1 {
+		return "the filesystem recorded an error"
+	}
+	return fmt.Sprintf("the filesystem recorded %d errors", count)
+}
+
+// Keep the samples within freeSpaceTrendWindow of now
+func trimFreeSpaceSamples(samples []freeSpaceSample, now time.Time) []freeSpaceSample {
+	kept := samples[:0]
+	for _, sample := range samples {
+		if now.Sub(sample.at) < freeSpaceTrendWindow {
+			kept = append(kept, sample)
+		}
+	}
+	return kept
+}
+
+// Estimate from a least-squares fit of the samples how long it takes for the
+// free space to drop to minFree.  Returns false if it isn't dropping or there
+// are too few samples, spanning too short a period, to tell.
+func timeUntilFull(samples []freeSpaceSample, minFree uint64) (time.Duration, bool) {
+	if len(samples) < 3 || samples[len(samples)-1].at.Sub(samples[0].at) < freeSpaceTrendWindow/4 {
+		return 0, false
+	}
+	start := samples[0].at
+	var sumT, sumF, sumTT, sumTF float64
+	for _, sample := range samples {
+		t := sample.at.Sub(start).Seconds()
+		f := float64(sample.freeBytes)
+		sumT += t
+		sumF += f
+		sumTT += t * t
+		sumTF += t * f
+	}
+	n := float64(len(samples))
+	denominator := n*sumTT - sumT*sumT
+	if denominator == 0 {
+		return 0, false
+	}
+	// In bytes per second
+	slope := (n*sumTF - sumT*sumF) / denominator
+	if slope >= 0 {
+		return 0, false
+	}
+	last := samples[len(samples)-1]
+	if last.freeBytes <= minFree {
+		return 0, true
+	}
+	seconds := float64(last.freeBytes-minFree) / -slope
+	return time.Duration(seconds * float64(time.Second)), true
+}
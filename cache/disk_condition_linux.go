@@ -0,0 +1,124 @@
+//go:build linux
+
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package cache
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type smartResult struct {
+	problem string
+	at      time.Time
+}
+
+var (
+	smartResults      = map[string]smartResult{}
+	smartResultsMutex sync.Mutex
+)
+
+// Check a data partition for a read-only remount, filesystem errors, and a
+// failing disk, and measure its free space
+func checkDiskStatus(path string) (diskStatus, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return diskStatus{}, errors.Wrapf(err, "failed to get the filesystem stats of %s", path)
+	}
+	status := diskStatus{
+		FreeBytes:  stat.Bavail * uint64(stat.Bsize),
+		TotalBytes: stat.Blocks * uint64(stat.Bsize),
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return status, errors.Wrapf(err, "failed to resolve %s", path)
+	}
+	mountinfo, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return status, errors.Wrap(err, "failed to open the mount table")
+	}
+	defer mountinfo.Close()
+	mount, err := findMount(mountinfo, resolved)
+	if err != nil {
+		return status, err
+	}
+	// The kernel remounts a filesystem read-only when it finds it corrupted
+	if mount.ReadOnly || stat.Flags&syscall.MS_RDONLY != 0 {
+		status.Problem = "the filesystem is mounted read-only"
+		return status, nil
+	}
+	if !strings.HasPrefix(mount.Source, "/dev/") {
+		return status, nil
+	}
+	if mount.FsType == "ext4" {
+		if contents, err := os.ReadFile(filepath.Join("/sys/fs/ext4", blockDeviceName(mount.Source), "errors_count")); err == nil {
+			if count, err := strconv.Atoi(strings.TrimSpace(string(contents))); err == nil && count > 0 {
+				status.Problem = describeFsErrors(count)
+				return status, nil
+			}
+		}
+	}
+	if param.Cache_EnableDiskSmartCheck.GetBool() {
+		status.Problem = checkSmartStatus(mount.Source)
+	}
+	return status, nil
+}
+
+// Run smartctl against the device, reusing its result for smartCheckInterval.
+// Devices smartctl can't check, e.g., because it isn't installed, pass.
+func checkSmartStatus(device string) string {
+	smartResultsMutex.Lock()
+	defer smartResultsMutex.Unlock()
+	if result, ok := smartResults[device]; ok && time.Since(result.at) < smartCheckInterval {
+		return result.problem
+	}
+	result := smartResult{at: time.Now()}
+	defer func() { smartResults[device] = result }()
+
+	smartctl, err := exec.LookPath("smartctl")
+	if err != nil {
+		log.Debugln("Not checking the SMART status of the cache disks; smartctl is not installed:", err)
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	// smartctl exits with a bitmask of its findings, so only its output matters
+	output, _ := exec.CommandContext(ctx, smartctl, "--json", "-H", device).Output()
+	problem, err := parseSmartStatus(output)
+	if err != nil {
+		log.Debugf("Failed to check the SMART status of %s: %v", device, err)
+		return ""
+	}
+	result.problem = problem
+	return problem
+}
@@ -0,0 +1,92 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindMount(t *testing.T) {
+	mountinfo := `22 1 253:0 / / rw,relatime shared:1 - xfs /dev/mapper/root rw
+40 22 8:17 / /data rw,noatime shared:2 - ext4 /dev/sdb1 rw
+41 40 8:33 / /data/disk\0402 ro,noatime shared:3 - ext4 /dev/sdc1 rw,errors=remount-ro
+`
+	mount, err := findMount(strings.NewReader(mountinfo), "/data/disk1/cache")
+	require.NoError(t, err)
+	assert.Equal(t, mountInfo{MountPoint: "/data", FsType: "ext4", Source: "/dev/sdb1"}, mount)
+
+	mount, err = findMount(strings.NewReader(mountinfo), "/data/disk 2")
+	require.NoError(t, err)
+	assert.Equal(t, mountInfo{MountPoint: "/data/disk 2", FsType: "ext4", Source: "/dev/sdc1", ReadOnly: true}, mount)
+
+	mount, err = findMount(strings.NewReader(mountinfo), "/var/cache")
+	require.NoError(t, err)
+	assert.Equal(t, "/", mount.MountPoint)
+
+	_, err = findMount(strings.NewReader(""), "/var/cache")
+	assert.Error(t, err)
+}
+
+func TestParseSmartStatus(t *testing.T) {
+	problem, err := parseSmartStatus([]byte(`{"smart_status": {"passed": true}}`))
+	require.NoError(t, err)
+	assert.Empty(t, problem)
+
+	problem, err = parseSmartStatus([]byte(`{"smart_status": {"passed": false}}`))
+	require.NoError(t, err)
+	assert.Contains(t, problem, "SMART")
+
+	// Devices without SMART support pass
+	problem, err = parseSmartStatus([]byte(`{"smartctl": {"exit_status": 4}}`))
+	require.NoError(t, err)
+	assert.Empty(t, problem)
+
+	_, err = parseSmartStatus([]byte("smartctl: command failed"))
+	assert.Error(t, err)
+}
+
+func TestTimeUntilFull(t *testing.T) {
+	start := time.Now()
+	samples := []freeSpaceSample{}
+	for i := 0; i < 4; i++ {
+		samples = append(samples, freeSpaceSample{at: start.Add(time.Duration(i) * 10 * time.Minute), freeBytes: uint64(1000 - 100*i)})
+	}
+	// 100 bytes every 10 minutes leaves 600 bytes above the minimum for an hour
+	remaining, ok := timeUntilFull(samples, 100)
+	require.True(t, ok)
+	assert.InDelta(t, time.Hour.Seconds(), remaining.Seconds(), 1)
+
+	// Too short a period to tell
+	_, ok = timeUntilFull(samples[:2], 100)
+	assert.False(t, ok)
+
+	// Growing free space never runs out
+	for idx := range samples {
+		samples[idx].freeBytes = uint64(1000 + 100*idx)
+	}
+	_, ok = timeUntilFull(samples, 100)
+	assert.False(t, ok)
+
+	assert.Len(t, trimFreeSpaceSamples(samples, start.Add(time.Hour+5*time.Minute)), 3)
+}
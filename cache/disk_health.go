@@ -0,0 +1,636 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// A data partition taken out of service, as recorded in the offline
+	// partitions file
+	offlinePartition struct {
+		Path   string    `json:"path"`
+		Reason string    `json:"reason"`
+		Since  time.Time `json:"since"`
+		// Recently accessed objects evicted from the partition, to be fetched
+		// again once XRootD no longer places objects on it
+		PendingRefetch []string `json:"pendingRefetch,omitempty"`
+	}
+
+	partitionHealth struct {
+		path     string
+		failures []time.Time // Failed probes within the error window
+		offline  bool
+		// Whether XRootD was started without this partition, so objects
+		// fetched now can't land on it
+		excluded bool
+	}
+
+	diskHealthMonitor struct {
+		mutex      sync.Mutex
+		partitions []*partitionHealth
+		offline    map[string]*offlinePartition
+		stateFile  string
+		threshold  int
+		window     time.Duration
+		probe      func(string) error
+		status     func(string) (diskStatus, error)
+		// The free space of the partitions in service below which, or the time
+		// until it's reached below which, the cache is degraded; zero disables
+		// each check
+		minFreePercent int
+		fullHorizon    time.Duration
+		freeSamples    []freeSpaceSample
+		// Why the cache is degraded: the last partition in service is failing,
+		// or the cache is running out of space
+		failing  string
+		lowSpace string
+	}
+
+	evictedObject struct {
+		path       string
+		lastAccess time.Time
+	}
+)
+
+const (
+	offlinePartitionsFile = "offline-partitions.json"
+	diskProbeFile         = ".pelican-disk-probe"
+	diskProbeSize         = 4096
+)
+
+var (
+	diskHealthMutex  sync.RWMutex
+	activeDiskHealth *diskHealthMonitor
+)
+
+func getOfflinePartitionsFile() string {
+	return filepath.Join(param.Cache_StorageLocation.GetString(), offlinePartitionsFile)
+}
+
+func loadOfflinePartitions(stateFile string) (map[string]*offlinePartition, error) {
+	offline := make(map[string]*offlinePartition)
+	contents, err := os.ReadFile(stateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return offline, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read the offline partitions file")
+	}
+	var partitions []*offlinePartition
+	if err := json.Unmarshal(contents, &partitions); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the offline partitions file %s", stateFile)
+	}
+	for _, partition := range partitions {
+		offline[filepath.Clean(partition.Path)] = partition
+	}
+	return offline, nil
+}
+
+func saveOfflinePartitions(stateFile string, offline map[string]*offlinePartition) error {
+	partitions := make([]*offlinePartition, 0, len(offline))
+	for _, partition := range offline {
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].Path < partitions[j].Path })
+	contents, err := json.MarshalIndent(partitions, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile := stateFile + ".tmp"
+	if err := os.WriteFile(tmpFile, contents, 0644); err != nil {
+		return errors.Wrap(err, "failed to write the offline partitions file")
+	}
+	return errors.Wrap(os.Rename(tmpFile, stateFile), "failed to write the offline partitions file")
+}
+
+// Remove the partitions taken out of service for disk failures from the data
+// locations handed to XRootD. It's an error for every partition to be offline.
+func FilterOfflineDataLocations(dataLocations []string) ([]string, error) {
+	offline, err := loadOfflinePartitions(getOfflinePartitionsFile())
+	if err != nil {
+		return nil, err
+	}
+	filtered := make([]string, 0, len(dataLocations))
+	for _, location := range dataLocations {
+		if partition, ok := offline[filepath.Clean(location)]; ok {
+			log.Warningf("Data location %s has been offline since %s (%s); XRootD will not use it. Remove it from %s once the disk is replaced.",
+				location, partition.Since.Format(time.RFC3339), partition.Reason, getOfflinePartitionsFile())
+			continue
+		}
+		filtered = append(filtered, location)
+	}
+	if len(filtered) == 0 && len(dataLocations) > 0 {
+		return nil, errors.Errorf("every data location in %s is offline", param.Cache_DataLocations.GetName())
+	}
+	return filtered, nil
+}
+
+// Write, sync, and read back a probe file to check that a partition can
+// still store data
+func probePartition(dir string) error {
+	probe := make([]byte, diskProbeSize)
+	if _, err := rand.Read(probe); err != nil {
+		return err
+	}
+	probePath := filepath.Join(dir, diskProbeFile)
+	file, err := os.OpenFile(probePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the probe file")
+	}
+	defer os.Remove(probePath)
+	if _, err := file.Write(probe); err != nil {
+		file.Close()
+		return errors.Wrap(err, "failed to write the probe file")
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return errors.Wrap(err, "failed to sync the probe file")
+	}
+	if err := file.Close(); err != nil {
+		return errors.Wrap(err, "failed to write the probe file")
+	}
+	readBack, err := os.ReadFile(probePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the probe file")
+	}
+	if !bytes.Equal(probe, readBack) {
+		return errors.New("the probe file read back differs from what was written")
+	}
+	return nil
+}
+
+func newDiskHealthMonitor(dataLocations []string, stateFile string, threshold int, window time.Duration) (*diskHealthMonitor, error) {
+	offline, err := loadOfflinePartitions(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	monitor := &diskHealthMonitor{
+		offline:   offline,
+		stateFile: stateFile,
+		threshold: threshold,
+		window:    window,
+		probe:     probePartition,
+		status:    checkDiskStatus,
+	}
+	for _, location := range dataLocations {
+		location = filepath.Clean(location)
+		_, isOffline := offline[location]
+		monitor.partitions = append(monitor.partitions, &partitionHealth{path: location, offline: isOffline, excluded: isOffline})
+	}
+	return monitor, nil
+}
+
+// The data partitions currently out of service
+func (monitor *diskHealthMonitor) offlinePaths() []string {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	paths := []string{}
+	for _, partition := range monitor.partitions {
+		if partition.offline {
+			paths = append(paths, partition.path)
+		}
+	}
+	return paths
+}
+
+// Record the result of a probe, returning whether the partition has crossed
+// the error threshold
+func (monitor *diskHealthMonitor) record(partition *partitionHealth, probeErr error, now time.Time) bool {
+	kept := partition.failures[:0]
+	for _, failure := range partition.failures {
+		if now.Sub(failure) < monitor.window {
+			kept = append(kept, failure)
+		}
+	}
+	partition.failures = kept
+	if probeErr == nil {
+		return false
+	}
+	partition.failures = append(partition.failures, now)
+	metrics.PelicanCachePartitionIOErrors.WithLabelValues(partition.path).Inc()
+	log.Warningf("IO probe of cache data location %s failed (%d of %d allowed in %s): %v",
+		partition.path, len(partition.failures), monitor.threshold, monitor.window, probeErr)
+	return len(partition.failures) >= monitor.threshold
+}
+
+// Check every partition still in service and take offline those whose disk
+// is failing or that are over the IO error threshold. The last healthy
+// partition is kept in service, leaving the cache degraded instead.
+func (monitor *diskHealthMonitor) check(namespaceLocation string, refetchCount int, now time.Time) {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	monitor.failing = ""
+	var freeBytes, totalBytes uint64
+	for _, partition := range monitor.partitions {
+		if partition.offline {
+			continue
+		}
+		reason := ""
+		status, err := monitor.status(partition.path)
+		if err != nil && !errors.Is(err, errDiskStatusUnsupported) {
+			log.Warningf("Failed to check the disk of cache data location %s: %v", partition.path, err)
+		}
+		if status.Problem != "" {
+			reason = status.Problem
+		} else {
+			probeErr := monitor.probe(partition.path)
+			if !monitor.record(partition, probeErr, now) {
+				freeBytes += status.FreeBytes
+				totalBytes += status.TotalBytes
+				continue
+			}
+			reason = fmt.Sprintf("%d IO errors within %s; last error: %v", len(partition.failures), monitor.window, probeErr)
+		}
+		healthy := 0
+		for _, other := range monitor.partitions {
+			if !other.offline {
+				healthy++
+			}
+		}
+		if healthy <= 1 {
+			log.Errorf("Cache data location %s is failing (%s) but is the last one in service; it will not be taken offline", partition.path, reason)
+			monitor.failing = fmt.Sprintf("The last data location in service, %s, is failing: %s", partition.path, reason)
+			continue
+		}
+		monitor.takeOffline(partition, namespaceLocation, reason, refetchCount, now)
+	}
+	monitor.checkFreeSpace(freeBytes, totalBytes, now)
+	monitor.updateHealthStatus()
+}
+
+// Track the free space of the healthy partitions in service, degrading the
+// cache when it runs low or is dropping faster than purging keeps up with
+func (monitor *diskHealthMonitor) checkFreeSpace(freeBytes, totalBytes uint64, now time.Time) {
+	monitor.freeSamples = trimFreeSpaceSamples(monitor.freeSamples, now)
+	if totalBytes == 0 {
+		monitor.lowSpace = ""
+		return
+	}
+	monitor.freeSamples = append(monitor.freeSamples, freeSpaceSample{at: now, freeBytes: freeBytes})
+	minFree := totalBytes / 100 * uint64(monitor.minFreePercent)
+	wasLow := monitor.lowSpace != ""
+	monitor.lowSpace = ""
+	if monitor.minFreePercent > 0 && freeBytes < minFree {
+		monitor.lowSpace = fmt.Sprintf("The data locations in service are running out of space: %.1f%% free, below the minimum of %d%%",
+			float64(freeBytes)*100/float64(totalBytes), monitor.minFreePercent)
+	} else if monitor.fullHorizon > 0 {
+		if remaining, ok := timeUntilFull(monitor.freeSamples, minFree); ok && remaining < monitor.fullHorizon {
+			monitor.lowSpace = fmt.Sprintf("The data locations in service are filling up faster than they are purged; their free space is expected to drop below %d%% in %s",
+				monitor.minFreePercent, remaining.Round(time.Minute).String())
+		}
+	}
+	if monitor.lowSpace != "" && !wasLow {
+		log.Warningln(monitor.lowSpace)
+	} else if monitor.lowSpace == "" && wasLow {
+		log.Infoln("The data locations of the cache have enough free space again")
+	}
+}
+
+func (monitor *diskHealthMonitor) takeOffline(partition *partitionHealth, namespaceLocation, reason string, refetchCount int, now time.Time) {
+	partition.offline = true
+	log.Errorf("Taking cache data location %s offline: %s", partition.path, reason)
+	metrics.PelicanCachePartitionOffline.WithLabelValues(partition.path).Set(1)
+
+	state := &offlinePartition{Path: partition.path, Reason: reason, Since: now}
+	monitor.offline[partition.path] = state
+	// Persist the state first so the partition stays out of service even if
+	// the eviction below is interrupted
+	if err := saveOfflinePartitions(monitor.stateFile, monitor.offline); err != nil {
+		log.Errorf("Failed to record that %s is offline; it will be used again after a restart: %v", partition.path, err)
+	}
+	state.PendingRefetch = appendRefetch(state.PendingRefetch, evictPartitionObjects(namespaceLocation, partition.path), refetchCount)
+	if err := saveOfflinePartitions(monitor.stateFile, monitor.offline); err != nil {
+		log.Errorf("Failed to record the objects to fetch again from %s: %v", partition.path, err)
+	}
+}
+
+// Report the offline partitions and why the cache is degraded, if it is, in
+// the cache's health status
+func (monitor *diskHealthMonitor) updateHealthStatus() {
+	if monitor.failing != "" {
+		metrics.SetComponentHealthStatus(metrics.Cache_Disks, metrics.StatusCritical, monitor.failing)
+		return
+	}
+	var offline, pending []string
+	for _, partition := range monitor.partitions {
+		if !partition.offline {
+			continue
+		}
+		description := partition.path
+		if state := monitor.offline[partition.path]; state != nil && state.Reason != "" {
+			description += " (" + state.Reason + ")"
+		}
+		offline = append(offline, description)
+		if !partition.excluded {
+			pending = append(pending, partition.path)
+		}
+	}
+	msgs := []string{}
+	if monitor.lowSpace != "" {
+		msgs = append(msgs, monitor.lowSpace)
+	}
+	if len(offline) > 0 {
+		msgs = append(msgs, fmt.Sprintf("Data locations taken offline: %s", strings.Join(offline, ", ")))
+	}
+	if len(pending) > 0 {
+		msgs = append(msgs, fmt.Sprintf("Restart the cache so XRootD stops placing new objects on %s", strings.Join(pending, ", ")))
+	}
+	if len(msgs) == 0 {
+		metrics.SetComponentHealthStatus(metrics.Cache_Disks, metrics.StatusOK, "")
+		return
+	}
+	metrics.SetComponentHealthStatus(metrics.Cache_Disks, metrics.StatusWarning, strings.Join(msgs, ". "))
+}
+
+// Why the cache is degraded and should only get the clients no other cache
+// can take; empty if it isn't
+func (monitor *diskHealthMonitor) degradedReason() string {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	if monitor.failing != "" {
+		return monitor.failing
+	}
+	return monitor.lowSpace
+}
+
+// The fraction of the data partitions still in service
+func (monitor *diskHealthMonitor) capacity() float64 {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	if len(monitor.partitions) == 0 {
+		return 1
+	}
+	healthy := 0
+	for _, partition := range monitor.partitions {
+		if !partition.offline {
+			healthy++
+		}
+	}
+	return float64(healthy) / float64(len(monitor.partitions))
+}
+
+// Merge newly evicted objects into the list to fetch again, most recently
+// accessed first, keeping at most limit entries
+func appendRefetch(pending []string, evicted []evictedObject, limit int) []string {
+	seen := make(map[string]bool, len(pending))
+	for _, objectPath := range pending {
+		seen[objectPath] = true
+	}
+	for _, object := range evicted {
+		if len(pending) >= limit {
+			break
+		}
+		if !seen[object.path] {
+			seen[object.path] = true
+			pending = append(pending, object.path)
+		}
+	}
+	return pending
+}
+
+// Evict every object XRootD stored on a partition by removing its links from
+// the namespace directory, so the objects are fetched from the origin again
+// rather than read from a failing disk. Returns the evicted objects, most
+// recently accessed first.
+func evictPartitionObjects(namespaceLocation, partition string) []evictedObject {
+	evicted := []evictedObject{}
+	err := filepath.WalkDir(namespaceLocation, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.Type()&fs.ModeSymlink == 0 || strings.HasSuffix(name, ".cinfo") {
+			return nil
+		}
+		target, err := os.Readlink(name)
+		if err != nil {
+			return nil
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(name), target)
+		}
+		if !pathInNamespace(filepath.Clean(target), partition) {
+			return nil
+		}
+
+		object := evictedObject{path: "/" + filepath.ToSlash(strings.TrimPrefix(name, namespaceLocation+string(filepath.Separator)))}
+		// XRootD rewrites the cinfo file when it records an access
+		if cinfo, err := os.Stat(name + ".cinfo"); err == nil {
+			object.lastAccess = cinfo.ModTime()
+		}
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Warningf("Failed to evict %s from offline data location %s: %v", object.path, partition, err)
+			return nil
+		}
+		// The cinfo file lives on a meta partition and is linked like the data
+		if cinfoTarget, err := os.Readlink(name + ".cinfo"); err == nil {
+			if !filepath.IsAbs(cinfoTarget) {
+				cinfoTarget = filepath.Join(filepath.Dir(name), cinfoTarget)
+			}
+			_ = os.Remove(cinfoTarget)
+		}
+		if err := os.Remove(name + ".cinfo"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Warningf("Failed to remove the cinfo file of evicted object %s: %v", object.path, err)
+		}
+		// The failing disk may not let go of the data; it's unreachable either way
+		_ = os.Remove(target)
+		evicted = append(evicted, object)
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Failed to evict all objects from offline data location %s: %v", partition, err)
+	}
+	sort.SliceStable(evicted, func(i, j int) bool { return evicted[i].lastAccess.After(evicted[j].lastAccess) })
+	log.Infof("Evicted %d objects from offline data location %s", len(evicted), partition)
+	return evicted
+}
+
+// Fetch objects through the cache so XRootD stores them again, returning how
+// many were fetched. Objects the cache refuses without authorization are left
+// for their next client.
+func refetchObjects(ctx context.Context, client *http.Client, cacheUrl string, objects []string) int {
+	fetched := 0
+	for _, objectPath := range objects {
+		if ctx.Err() != nil {
+			break
+		}
+		objectUrl, err := url.JoinPath(cacheUrl, objectPath)
+		if err != nil {
+			continue
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectUrl, nil)
+		if err != nil {
+			continue
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Debugf("Failed to fetch %s again after its data location went offline: %v", objectPath, err)
+			continue
+		}
+		_, err = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if err == nil && resp.StatusCode == http.StatusOK {
+			fetched++
+		} else {
+			log.Debugf("Did not fetch %s again after its data location went offline: status %d", objectPath, resp.StatusCode)
+		}
+	}
+	return fetched
+}
+
+// Fetch the hot objects of partitions that XRootD was started without; those
+// of partitions taken offline since startup wait for the next restart so they
+// don't land on the failing disk again
+func (monitor *diskHealthMonitor) refetchPending(ctx context.Context, client *http.Client, cacheUrl string) {
+	monitor.mutex.Lock()
+	var objects []string
+	for _, partition := range monitor.partitions {
+		if state := monitor.offline[partition.path]; partition.excluded && state != nil && len(state.PendingRefetch) > 0 {
+			objects = append(objects, state.PendingRefetch...)
+			state.PendingRefetch = nil
+		}
+	}
+	if len(objects) > 0 {
+		if err := saveOfflinePartitions(monitor.stateFile, monitor.offline); err != nil {
+			log.Errorln("Failed to update the offline partitions file:", err)
+		}
+	}
+	monitor.mutex.Unlock()
+	if len(objects) == 0 {
+		return
+	}
+	fetched := refetchObjects(ctx, client, cacheUrl, objects)
+	log.Infof("Fetched %d of %d recently accessed objects from offline data locations onto healthy ones", fetched, len(objects))
+}
+
+func offlineDataLocations() []string {
+	diskHealthMutex.RLock()
+	monitor := activeDiskHealth
+	diskHealthMutex.RUnlock()
+	if monitor == nil {
+		return nil
+	}
+	return monitor.offlinePaths()
+}
+
+// Why the disks leave the cache degraded, and the fraction of its capacity
+// still in service
+func diskDegradation() (reason string, capacity float64) {
+	diskHealthMutex.RLock()
+	monitor := activeDiskHealth
+	diskHealthMutex.RUnlock()
+	if monitor == nil {
+		return "", 1
+	}
+	return monitor.degradedReason(), monitor.capacity()
+}
+
+// Watch the partitions in Cache.DataLocations for IO errors, failing disks,
+// and filesystem errors, and take those that keep failing out of service
+// instead of serving corrupted reads from them.  The cache is advertised as
+// degraded while its last partition is failing or it's running out of space.
+func LaunchDiskHealthMonitor(ctx context.Context, egrp *errgroup.Group) error {
+	if !param.Cache_EnableDiskHealthCheck.GetBool() {
+		return nil
+	}
+	interval := param.Cache_DiskHealthCheckInterval.GetDuration()
+	if interval <= 0 {
+		interval = time.Minute
+		log.Error("Invalid config value: Cache.DiskHealthCheckInterval must be positive. Fallback to 1m.")
+	}
+	threshold := param.Cache_DiskErrorThreshold.GetInt()
+	if threshold <= 0 {
+		threshold = 3
+		log.Error("Invalid config value: Cache.DiskErrorThreshold must be positive. Fallback to 3.")
+	}
+	monitor, err := newDiskHealthMonitor(param.Cache_DataLocations.GetStringSlice(), getOfflinePartitionsFile(),
+		threshold, param.Cache_DiskErrorWindow.GetDuration())
+	if err != nil {
+		return err
+	}
+
+	// Objects left on partitions that were offline at startup can't be read
+	// through XRootD anymore
+	namespaceLocation := param.Cache_NamespaceLocation.GetString()
+	refetchCount := param.Cache_DiskRefetchCount.GetInt()
+	for _, partition := range monitor.partitions {
+		if state := monitor.offline[partition.path]; partition.offline && state != nil {
+			metrics.PelicanCachePartitionOffline.WithLabelValues(partition.path).Set(1)
+			state.PendingRefetch = appendRefetch(state.PendingRefetch, evictPartitionObjects(namespaceLocation, partition.path), refetchCount)
+		}
+	}
+	if len(monitor.offline) > 0 {
+		if err := saveOfflinePartitions(monitor.stateFile, monitor.offline); err != nil {
+			log.Errorln("Failed to update the offline partitions file:", err)
+		}
+	}
+	monitor.minFreePercent = param.Cache_DiskMinFreePercentage.GetInt()
+	monitor.fullHorizon = param.Cache_DiskFullHorizon.GetDuration()
+	monitor.updateHealthStatus()
+
+	diskHealthMutex.Lock()
+	activeDiskHealth = monitor
+	diskHealthMutex.Unlock()
+
+	egrp.Go(func() error {
+		defer func() {
+			diskHealthMutex.Lock()
+			activeDiskHealth = nil
+			diskHealthMutex.Unlock()
+		}()
+		client := &http.Client{Transport: config.GetTransport()}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				monitor.check(namespaceLocation, refetchCount, now)
+				monitor.refetchPending(ctx, client, param.Cache_Url.GetString())
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,223 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Cache an object the way XRootD does: the data on a data partition, the
+// cinfo on a meta partition, and links to both in the namespace directory
+func writeCachedObject(t *testing.T, namespaceDir, dataDir, metaDir, objectPath string, lastAccess time.Time) {
+	name := filepath.Join(namespaceDir, filepath.FromSlash(objectPath))
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+	dataFile := filepath.Join(dataDir, filepath.Base(objectPath)+"%")
+	cinfoFile := filepath.Join(metaDir, filepath.Base(objectPath)+".cinfo%")
+	require.NoError(t, os.WriteFile(dataFile, []byte("data"), 0644))
+	require.NoError(t, os.WriteFile(cinfoFile, []byte("cinfo"), 0644))
+	require.NoError(t, os.Chtimes(cinfoFile, lastAccess, lastAccess))
+	require.NoError(t, os.Symlink(dataFile, name))
+	require.NoError(t, os.Symlink(cinfoFile, name+".cinfo"))
+}
+
+func TestProbePartition(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, probePartition(dir))
+	assert.NoFileExists(t, filepath.Join(dir, diskProbeFile))
+	assert.Error(t, probePartition(filepath.Join(dir, "missing")))
+}
+
+func TestEvictPartitionObjects(t *testing.T) {
+	root := t.TempDir()
+	namespaceDir := filepath.Join(root, "namespace")
+	badDisk := filepath.Join(root, "disk1")
+	goodDisk := filepath.Join(root, "disk2")
+	metaDir := filepath.Join(root, "meta")
+	for _, dir := range []string{namespaceDir, badDisk, goodDisk, metaDir} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+	now := time.Now()
+	writeCachedObject(t, namespaceDir, badDisk, metaDir, "/ns/cold", now.Add(-time.Hour))
+	writeCachedObject(t, namespaceDir, badDisk, metaDir, "/ns/sub/hot", now)
+	writeCachedObject(t, namespaceDir, goodDisk, metaDir, "/ns/healthy", now)
+
+	evicted := evictPartitionObjects(namespaceDir, badDisk)
+	require.Len(t, evicted, 2)
+	assert.Equal(t, "/ns/sub/hot", evicted[0].path)
+	assert.Equal(t, "/ns/cold", evicted[1].path)
+
+	assert.NoFileExists(t, filepath.Join(namespaceDir, "ns", "cold"))
+	assert.NoFileExists(t, filepath.Join(namespaceDir, "ns", "cold.cinfo"))
+	assert.NoFileExists(t, filepath.Join(metaDir, "cold.cinfo%"))
+	assert.NoFileExists(t, filepath.Join(badDisk, "cold%"))
+	assert.FileExists(t, filepath.Join(namespaceDir, "ns", "healthy"))
+	assert.FileExists(t, filepath.Join(metaDir, "healthy.cinfo%"))
+
+	assert.Equal(t, []string{"/ns/a", "/ns/sub/hot"}, appendRefetch([]string{"/ns/a"}, evicted, 2))
+}
+
+func TestDiskHealthMonitor(t *testing.T) {
+	root := t.TempDir()
+	namespaceDir := filepath.Join(root, "namespace")
+	disks := []string{filepath.Join(root, "disk1"), filepath.Join(root, "disk2")}
+	metaDir := filepath.Join(root, "meta")
+	for _, dir := range append([]string{namespaceDir, metaDir}, disks...) {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+	writeCachedObject(t, namespaceDir, disks[0], metaDir, "/ns/object", time.Now())
+	stateFile := filepath.Join(root, offlinePartitionsFile)
+
+	monitor, err := newDiskHealthMonitor(disks, stateFile, 2, time.Minute)
+	require.NoError(t, err)
+	monitor.probe = func(dir string) error {
+		if dir == disks[0] {
+			return errors.New("input/output error")
+		}
+		return nil
+	}
+
+	start := time.Now()
+	monitor.check(namespaceDir, 10, start)
+	assert.Empty(t, monitor.offlinePaths())
+	// The first failure has left the window by the time of the next probe
+	monitor.check(namespaceDir, 10, start.Add(2*time.Minute))
+	assert.Empty(t, monitor.offlinePaths())
+	monitor.check(namespaceDir, 10, start.Add(2*time.Minute+time.Second))
+	assert.Equal(t, []string{disks[0]}, monitor.offlinePaths())
+	assert.NoFileExists(t, filepath.Join(namespaceDir, "ns", "object"))
+
+	offline, err := loadOfflinePartitions(stateFile)
+	require.NoError(t, err)
+	require.Contains(t, offline, disks[0])
+	assert.Contains(t, offline[disks[0]].Reason, "input/output error")
+	assert.Equal(t, []string{"/ns/object"}, offline[disks[0]].PendingRefetch)
+
+	// The last healthy partition stays in service no matter how it fails
+	monitor.probe = func(string) error { return errors.New("input/output error") }
+	for i := 0; i < 3; i++ {
+		monitor.check(namespaceDir, 10, start.Add(time.Duration(3+i)*time.Minute))
+	}
+	assert.Equal(t, []string{disks[0]}, monitor.offlinePaths())
+
+	// The objects are fetched again only once XRootD runs without the partition
+	fetched := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = append(fetched, r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+	monitor.refetchPending(context.Background(), server.Client(), server.URL)
+	assert.Empty(t, fetched)
+
+	restarted, err := newDiskHealthMonitor(disks, stateFile, 2, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{disks[0]}, restarted.offlinePaths())
+	restarted.refetchPending(context.Background(), server.Client(), server.URL)
+	assert.Equal(t, []string{"/ns/object"}, fetched)
+	offline, err = loadOfflinePartitions(stateFile)
+	require.NoError(t, err)
+	assert.Empty(t, offline[disks[0]].PendingRefetch)
+}
+
+func TestFilterOfflineDataLocations(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	storageDir := t.TempDir()
+	viper.Set("Cache.StorageLocation", storageDir)
+	disks := []string{"/disk1/", "/disk2"}
+
+	filtered, err := FilterOfflineDataLocations(disks)
+	require.NoError(t, err)
+	assert.Equal(t, disks, filtered)
+
+	require.NoError(t, saveOfflinePartitions(filepath.Join(storageDir, offlinePartitionsFile),
+		map[string]*offlinePartition{"/disk1": {Path: "/disk1", Reason: "IO errors", Since: time.Now()}}))
+	filtered, err = FilterOfflineDataLocations(disks)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/disk2"}, filtered)
+
+	_, err = FilterOfflineDataLocations([]string{"/disk1"})
+	assert.ErrorContains(t, err, "every data location")
+}
+
+func TestDiskHealthDegradation(t *testing.T) {
+	root := t.TempDir()
+	namespaceDir := filepath.Join(root, "namespace")
+	disks := []string{filepath.Join(root, "disk1"), filepath.Join(root, "disk2")}
+	for _, dir := range append([]string{namespaceDir}, disks...) {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+
+	monitor, err := newDiskHealthMonitor(disks, filepath.Join(root, offlinePartitionsFile), 3, time.Minute)
+	require.NoError(t, err)
+	monitor.minFreePercent = 5
+	monitor.fullHorizon = time.Hour
+	monitor.probe = func(string) error { return nil }
+	freeBytes := uint64(500)
+	problems := map[string]string{}
+	monitor.status = func(dir string) (diskStatus, error) {
+		return diskStatus{Problem: problems[dir], FreeBytes: freeBytes, TotalBytes: 1000}, nil
+	}
+
+	start := time.Now()
+	monitor.check(namespaceDir, 10, start)
+	assert.Empty(t, monitor.degradedReason())
+	assert.Equal(t, 1.0, monitor.capacity())
+
+	// A failing disk is taken offline without waiting for IO errors
+	problems[disks[0]] = "the disk failed its SMART health check"
+	monitor.check(namespaceDir, 10, start.Add(time.Minute))
+	assert.Equal(t, []string{disks[0]}, monitor.offlinePaths())
+	assert.Equal(t, 0.5, monitor.capacity())
+	assert.Empty(t, monitor.degradedReason())
+
+	// The last one isn't, leaving the cache degraded
+	problems[disks[1]] = "the filesystem is mounted read-only"
+	monitor.check(namespaceDir, 10, start.Add(2*time.Minute))
+	assert.Equal(t, []string{disks[0]}, monitor.offlinePaths())
+	assert.Contains(t, monitor.degradedReason(), "read-only")
+	delete(problems, disks[1])
+	monitor.check(namespaceDir, 10, start.Add(3*time.Minute))
+	assert.Empty(t, monitor.degradedReason())
+
+	// Free space dropping 10 bytes a minute reaches the 50 byte minimum within the hour
+	for i := 0; i < 20; i++ {
+		freeBytes -= 10
+		monitor.check(namespaceDir, 10, start.Add(time.Duration(4+i)*time.Minute))
+	}
+	assert.Contains(t, monitor.degradedReason(), "filling up faster than they are purged")
+
+	// Free space below the minimum degrades the cache even when it's steady
+	freeBytes = 40
+	monitor.freeSamples = nil
+	monitor.check(namespaceDir, 10, start.Add(30*time.Minute))
+	assert.Contains(t, monitor.degradedReason(), "running out of space")
+	freeBytes = 800
+	monitor.check(namespaceDir, 10, start.Add(31*time.Minute))
+	assert.Empty(t, monitor.degradedReason())
+}
@@ -0,0 +1,206 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package cache
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/netip"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// Serves public objects over plain HTTP to clients on the configured local
+	// networks by proxying to the cache's XRootD server
+	localHttpHandler struct {
+		networks   []netip.Prefix
+		namespaces func() []server_structs.NamespaceAdV2
+		proxy      *httputil.ReverseProxy
+	}
+)
+
+// Parse Cache.LocalHttpNetworks into prefixes, failing if none are configured
+// so that enabling the listener never opens it to the world by accident
+func getLocalHttpNetworks() ([]netip.Prefix, error) {
+	networks := param.Cache_LocalHttpNetworks.GetStringSlice()
+	if len(networks) == 0 {
+		return nil, errors.New("Cache.EnableLocalHttp is set but Cache.LocalHttpNetworks is empty")
+	}
+	prefixes := make([]netip.Prefix, 0, len(networks))
+	for _, network := range networks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid network %q in Cache.LocalHttpNetworks", network)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// The URL the cache advertises for its plain HTTP listener
+func getLocalHttpUrl() string {
+	return (&url.URL{
+		Scheme: "http",
+		Host:   net.JoinHostPort(param.Server_Hostname.GetString(), strconv.Itoa(param.Cache_LocalHttpPort.GetInt())),
+	}).String()
+}
+
+func newLocalHttpHandler(networks []netip.Prefix, namespaces func() []server_structs.NamespaceAdV2, transport http.RoundTripper) *localHttpHandler {
+	return &localHttpHandler{
+		networks:   networks,
+		namespaces: namespaces,
+		proxy: &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				// Cache.Url is only final once XRootD has picked its port
+				target, err := url.Parse(param.Cache_Url.GetString())
+				if err != nil {
+					log.Errorf("Failed to parse Cache.Url %s for the local HTTP listener: %v", param.Cache_Url.GetString(), err)
+					return
+				}
+				pr.SetURL(target)
+				pr.SetXForwarded()
+			},
+			ModifyResponse: func(resp *http.Response) error {
+				resp.Body = metrics.TraceBody(resp.Request.Context(), "cache.localHttp.streamResponse", resp.Body)
+				return nil
+			},
+			Transport: metrics.NewTracingTransport("cache.localHttp.xrootd", transport),
+		},
+	}
+}
+
+func (h *localHttpHandler) clientAllowed(remoteAddr string) bool {
+	addrPort, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	for _, network := range h.networks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the most specific namespace containing objPath allows public reads
+func (h *localHttpHandler) isPublic(objPath string) bool {
+	var match *server_structs.NamespaceAdV2
+	for _, ns := range h.namespaces() {
+		ns := ns
+		prefix := path.Clean("/" + ns.Path)
+		if objPath != prefix && !strings.HasPrefix(objPath, strings.TrimSuffix(prefix, "/")+"/") {
+			continue
+		}
+		if match == nil || len(prefix) > len(path.Clean("/"+match.Path)) {
+			match = &ns
+		}
+	}
+	return match != nil && match.Caps.PublicReads
+}
+
+// Check that the object's namespace allows public reads, in a span of the request
+func (h *localHttpHandler) authorize(ctx context.Context, objPath string) bool {
+	_, span := metrics.StartSpan(ctx, "cache.localHttp.authorize", attribute.String("pelican.path", objPath))
+	defer span.End()
+	public := h.isPublic(objPath)
+	span.SetAttributes(attribute.Bool("pelican.authorized", public))
+	return public
+}
+
+func (h *localHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.clientAllowed(r.RemoteAddr) {
+		http.Error(w, "Plain HTTP access is only available to clients on the cache's local networks", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Only GET and HEAD are supported over plain HTTP", http.StatusMethodNotAllowed)
+		return
+	}
+	objPath := path.Clean("/" + r.URL.Path)
+	if !h.authorize(r.Context(), objPath) {
+		http.Error(w, "Plain HTTP access is only available for public namespaces", http.StatusForbidden)
+		return
+	}
+
+	// Credentials have no business crossing an unencrypted connection
+	r.Header.Del("Authorization")
+	query := r.URL.Query()
+	if query.Has("authz") || query.Has("access_token") {
+		query.Del("authz")
+		query.Del("access_token")
+		r.URL.RawQuery = query.Encode()
+	}
+	r.URL.Path = objPath
+	r.URL.RawPath = ""
+	h.proxy.ServeHTTP(w, r)
+}
+
+// Start the plain HTTP listener on Cache.LocalHttpPort if Cache.EnableLocalHttp is set
+func LaunchLocalHttpServer(ctx context.Context, egrp *errgroup.Group, cacheServer *CacheServer) error {
+	if !param.Cache_EnableLocalHttp.GetBool() {
+		return nil
+	}
+	networks, err := getLocalHttpNetworks()
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(param.Server_WebHost.GetString(), strconv.Itoa(param.Cache_LocalHttpPort.GetInt()))
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s for the local HTTP listener", addr)
+	}
+	server := &http.Server{
+		Handler:           metrics.TraceHandler("cache.localHttp", newLocalHttpHandler(networks, cacheServer.GetNamespaceAds, config.GetTransport())),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	log.Infof("Serving public namespaces over plain HTTP at %s to clients on %v", ln.Addr().String(), networks)
+
+	egrp.Go(func() error {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	})
+	egrp.Go(func() error {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return errors.Wrap(err, "local HTTP listener failed")
+		}
+		return nil
+	})
+	return nil
+}
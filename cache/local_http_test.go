@@ -0,0 +1,109 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func TestGetLocalHttpNetworks(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(server_utils.ResetTestState)
+
+	_, err := getLocalHttpNetworks()
+	assert.Error(t, err)
+
+	viper.Set("Cache.LocalHttpNetworks", []string{"10.1.2.3/8", "fd00::/8"})
+	networks, err := getLocalHttpNetworks()
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}, networks)
+
+	viper.Set("Cache.LocalHttpNetworks", []string{"10.0.0.0"})
+	_, err = getLocalHttpNetworks()
+	assert.Error(t, err)
+}
+
+func TestLocalHttpHandler(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(server_utils.ResetTestState)
+
+	var gotPath, gotAuth, gotQuery string
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		gotQuery = r.URL.RawQuery
+		_, _ = w.Write([]byte("object contents"))
+	}))
+	t.Cleanup(backend.Close)
+	viper.Set("Cache.Url", backend.URL)
+
+	namespaces := []server_structs.NamespaceAdV2{
+		{Path: "/public", Caps: server_structs.Capabilities{PublicReads: true}},
+		{Path: "/public/protected", Caps: server_structs.Capabilities{PublicReads: false}},
+		{Path: "/private", Caps: server_structs.Capabilities{PublicReads: false}},
+	}
+	handler := newLocalHttpHandler(
+		[]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		func() []server_structs.NamespaceAdV2 { return namespaces },
+		backend.Client().Transport,
+	)
+
+	serve := func(method, target, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Authorization", "Bearer secret")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("public-object-from-local-network", func(t *testing.T) {
+		resp := serve(http.MethodGet, "/public/foo/../bar.txt?authz=secret&other=1", "10.1.2.3:5000")
+		require.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "object contents", resp.Body.String())
+		assert.Equal(t, "/public/bar.txt", gotPath)
+		assert.Empty(t, gotAuth)
+		assert.Equal(t, "other=1", gotQuery)
+	})
+
+	t.Run("remote-network", func(t *testing.T) {
+		resp := serve(http.MethodGet, "/public/bar.txt", "192.168.1.1:5000")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("non-public-namespaces", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/private/bar.txt", "10.1.2.3:5000").Code)
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/public/protected/bar.txt", "10.1.2.3:5000").Code)
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/publicity/bar.txt", "10.1.2.3:5000").Code)
+	})
+
+	t.Run("writes-refused", func(t *testing.T) {
+		assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodPut, "/public/bar.txt", "10.1.2.3:5000").Code)
+	})
+}
@@ -0,0 +1,409 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package cache
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// A per-namespace ceiling as written in Cache.NamespaceLimits
+	NamespaceLimit struct {
+		Prefix       string `mapstructure:"Prefix"`
+		MaxDisk      string `mapstructure:"MaxDisk"`
+		MaxBandwidth string `mapstructure:"MaxBandwidth"`
+	}
+
+	// Accounting and enforcement state for one limited namespace
+	namespaceUsage struct {
+		prefix       string
+		maxDisk      int64 // Bytes; 0 means unlimited
+		maxBandwidth int64 // Bytes per second; 0 means unlimited
+		egressBytes  uint64
+		// Whether the namespace is left out of the cache's advertisement so
+		// the director sends new clients to other caches
+		withdrawn bool
+	}
+
+	limitedFile struct {
+		usage     *namespaceUsage
+		path      string
+		readBytes uint64
+		lastSeen  time.Time
+	}
+
+	namespaceLimiter struct {
+		mutex sync.Mutex
+		// Sorted longest prefix first so the first match is the most specific
+		namespaces []*namespaceUsage
+		openFiles  map[uint32]*limitedFile
+		lastPass   time.Time
+	}
+
+	cachedFile struct {
+		name       string
+		size       int64
+		lastAccess time.Time
+	}
+)
+
+const (
+	// Once over a ceiling, a namespace is brought back to this fraction of
+	// it so it doesn't bounce on and off the limit
+	namespaceLimitRestoreFraction = 0.8
+
+	// Open files not heard from in this long are assumed to have lost
+	// their close event
+	limitedFileIdleTimeout = time.Hour
+
+	namespaceLimitsHookName = "cache-namespace-limits"
+)
+
+var (
+	namespaceLimitsMutex  sync.RWMutex
+	activeNamespaceLimits *namespaceLimiter
+)
+
+// Parse a bandwidth such as "100MB" or "100MB/s" into bytes per second
+func parseBandwidth(value string) (int64, error) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "/s")
+	return units.ParseStrictBytes(value)
+}
+
+func newNamespaceLimiter(limits []NamespaceLimit) (*namespaceLimiter, error) {
+	limiter := &namespaceLimiter{
+		openFiles: make(map[uint32]*limitedFile),
+		lastPass:  time.Now(),
+	}
+	seen := make(map[string]bool)
+	for _, limit := range limits {
+		prefix := path.Clean("/" + strings.TrimSpace(limit.Prefix))
+		if limit.Prefix == "" || prefix == "/" {
+			return nil, errors.New("each entry in Cache.NamespaceLimits needs a namespace prefix other than /")
+		}
+		if seen[prefix] {
+			return nil, errors.Errorf("namespace %s appears more than once in Cache.NamespaceLimits", prefix)
+		}
+		seen[prefix] = true
+		usage := &namespaceUsage{prefix: prefix}
+		var err error
+		if limit.MaxDisk != "" {
+			if usage.maxDisk, err = units.ParseStrictBytes(limit.MaxDisk); err != nil || usage.maxDisk < 0 {
+				return nil, errors.Errorf("invalid MaxDisk %q for namespace %s in Cache.NamespaceLimits", limit.MaxDisk, prefix)
+			}
+		}
+		if limit.MaxBandwidth != "" {
+			if usage.maxBandwidth, err = parseBandwidth(limit.MaxBandwidth); err != nil || usage.maxBandwidth < 0 {
+				return nil, errors.Errorf("invalid MaxBandwidth %q for namespace %s in Cache.NamespaceLimits", limit.MaxBandwidth, prefix)
+			}
+		}
+		if usage.maxDisk == 0 && usage.maxBandwidth == 0 {
+			return nil, errors.Errorf("namespace %s in Cache.NamespaceLimits sets neither MaxDisk nor MaxBandwidth", prefix)
+		}
+		limiter.namespaces = append(limiter.namespaces, usage)
+	}
+	sort.Slice(limiter.namespaces, func(i, j int) bool {
+		return len(limiter.namespaces[i].prefix) > len(limiter.namespaces[j].prefix)
+	})
+	return limiter, nil
+}
+
+func pathInNamespace(objectPath, prefix string) bool {
+	return objectPath == prefix || strings.HasPrefix(objectPath, prefix+"/")
+}
+
+// Find the most specific limited namespace containing objectPath
+func (limiter *namespaceLimiter) lookup(objectPath string) *namespaceUsage {
+	for _, usage := range limiter.namespaces {
+		if pathInNamespace(objectPath, usage.prefix) {
+			return usage
+		}
+	}
+	return nil
+}
+
+// Account the bytes read by clients from the XRootD monitoring stream
+func (limiter *namespaceLimiter) record(event metrics.FileTraceEvent) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	switch event.Type {
+	case metrics.FileTraceOpen:
+		if event.Path == "" {
+			return
+		}
+		objectPath := path.Clean(event.Path)
+		usage := limiter.lookup(objectPath)
+		if usage == nil {
+			return
+		}
+		limiter.openFiles[event.FileId] = &limitedFile{usage: usage, path: objectPath, lastSeen: event.Time}
+	case metrics.FileTraceTransfer, metrics.FileTraceClose:
+		file := limiter.openFiles[event.FileId]
+		if file == nil {
+			return
+		}
+		file.lastSeen = event.Time
+		if readBytes := event.ReadBytes + event.ReadvBytes; readBytes > file.readBytes {
+			file.usage.egressBytes += readBytes - file.readBytes
+			file.readBytes = readBytes
+		}
+		if event.Type == metrics.FileTraceClose {
+			delete(limiter.openFiles, event.FileId)
+		}
+	}
+}
+
+func (limiter *namespaceLimiter) isOpen(objectPath string) bool {
+	for _, file := range limiter.openFiles {
+		if file.path == objectPath {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare each namespace's egress rate since the previous pass with its
+// ceiling.  A namespace over its ceiling is withdrawn from the advertisement
+// until its rate drops below namespaceLimitRestoreFraction of the ceiling;
+// transfers already in progress are not interrupted.
+func (limiter *namespaceLimiter) checkBandwidth(now time.Time) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	elapsed := now.Sub(limiter.lastPass).Seconds()
+	limiter.lastPass = now
+	for fileId, file := range limiter.openFiles {
+		if now.Sub(file.lastSeen) > limitedFileIdleTimeout {
+			delete(limiter.openFiles, fileId)
+		}
+	}
+	if elapsed <= 0 {
+		return
+	}
+	for _, usage := range limiter.namespaces {
+		rate := float64(usage.egressBytes) / elapsed
+		usage.egressBytes = 0
+		metrics.PelicanCacheNamespaceEgressRate.WithLabelValues(usage.prefix).Set(rate)
+		if usage.maxBandwidth == 0 {
+			continue
+		}
+		if !usage.withdrawn && rate > float64(usage.maxBandwidth) {
+			usage.withdrawn = true
+			log.Warningf("Namespace %s is serving %.0f bytes/s from this cache, above its limit of %d; withdrawing it from the cache's advertisement",
+				usage.prefix, rate, usage.maxBandwidth)
+		} else if usage.withdrawn && rate < float64(usage.maxBandwidth)*namespaceLimitRestoreFraction {
+			usage.withdrawn = false
+			log.Infof("Namespace %s egress from this cache is back under its limit; advertising it again", usage.prefix)
+		}
+		withdrawn := 0.0
+		if usage.withdrawn {
+			withdrawn = 1
+		}
+		metrics.PelicanCacheNamespaceWithdrawn.WithLabelValues(usage.prefix).Set(withdrawn)
+	}
+}
+
+// List the cached objects of a namespace with the space they use on disk
+func listCachedFiles(namespaceDir string) (files []cachedFile, total int64, err error) {
+	err = filepath.WalkDir(namespaceDir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasSuffix(name, ".cinfo") {
+			return nil
+		}
+		// Follow the link when the namespace directory points into Cache.DataLocations
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil
+		}
+		file := cachedFile{name: name, size: diskUsage(info), lastAccess: info.ModTime()}
+		// XRootD rewrites the cinfo file when it records an access
+		if cinfo, err := os.Stat(name + ".cinfo"); err == nil && cinfo.ModTime().After(file.lastAccess) {
+			file.lastAccess = cinfo.ModTime()
+		}
+		files = append(files, file)
+		total += file.size
+		return nil
+	})
+	return
+}
+
+// Evict the least recently accessed objects of each namespace over its disk
+// ceiling until it is back to namespaceLimitRestoreFraction of the ceiling.
+// Objects that are open are skipped.
+func (limiter *namespaceLimiter) checkDisk(namespaceLocation string) {
+	for _, usage := range limiter.namespaces {
+		files, total, err := listCachedFiles(filepath.Join(namespaceLocation, filepath.FromSlash(usage.prefix)))
+		if err != nil {
+			log.Warningf("Failed to measure the disk usage of namespace %s: %v", usage.prefix, err)
+			continue
+		}
+		// A more specific limited namespace is accounted on its own
+		for _, other := range limiter.namespaces {
+			if other == usage || !pathInNamespace(other.prefix, usage.prefix) {
+				continue
+			}
+			nestedDir := filepath.Join(namespaceLocation, filepath.FromSlash(other.prefix))
+			kept := files[:0]
+			for _, file := range files {
+				if pathInNamespace(file.name, nestedDir) {
+					total -= file.size
+				} else {
+					kept = append(kept, file)
+				}
+			}
+			files = kept
+		}
+		metrics.PelicanCacheNamespaceDiskUsage.WithLabelValues(usage.prefix).Set(float64(total))
+		if usage.maxDisk == 0 || total <= usage.maxDisk {
+			continue
+		}
+
+		target := int64(float64(usage.maxDisk) * namespaceLimitRestoreFraction)
+		log.Infof("Namespace %s uses %d bytes of cache, above its limit of %d; evicting objects down to %d bytes",
+			usage.prefix, total, usage.maxDisk, target)
+		sort.Slice(files, func(i, j int) bool { return files[i].lastAccess.Before(files[j].lastAccess) })
+		for _, file := range files {
+			if total <= target {
+				break
+			}
+			objectPath := "/" + filepath.ToSlash(strings.TrimPrefix(file.name, namespaceLocation+string(filepath.Separator)))
+			limiter.mutex.Lock()
+			open := limiter.isOpen(objectPath)
+			limiter.mutex.Unlock()
+			if open {
+				continue
+			}
+			if err := os.Remove(file.name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Warningf("Failed to evict %s from namespace %s: %v", objectPath, usage.prefix, err)
+				continue
+			}
+			if err := os.Remove(file.name + ".cinfo"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Warningf("Failed to remove the cinfo file of evicted object %s: %v", objectPath, err)
+			}
+			total -= file.size
+			metrics.PelicanCacheNamespaceEvictions.WithLabelValues(usage.prefix).Inc()
+			metrics.PelicanCacheNamespaceEvictedBytes.WithLabelValues(usage.prefix).Add(float64(file.size))
+		}
+		metrics.PelicanCacheNamespaceDiskUsage.WithLabelValues(usage.prefix).Set(float64(total))
+		if total > target {
+			log.Warningf("Namespace %s still uses %d bytes of cache after eviction; the remaining objects are in use", usage.prefix, total)
+		}
+	}
+}
+
+// Drop the namespaces withdrawn for exceeding their bandwidth ceiling from
+// the list the cache advertises
+func (limiter *namespaceLimiter) filterAds(nsAds []server_structs.NamespaceAdV2) []server_structs.NamespaceAdV2 {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	filtered := make([]server_structs.NamespaceAdV2, 0, len(nsAds))
+	for _, ad := range nsAds {
+		nsPath := path.Clean(ad.Path)
+		withdrawn := false
+		for _, usage := range limiter.namespaces {
+			if usage.withdrawn && pathInNamespace(nsPath, usage.prefix) {
+				withdrawn = true
+				break
+			}
+		}
+		if !withdrawn {
+			filtered = append(filtered, ad)
+		}
+	}
+	return filtered
+}
+
+func filterLimitedNamespaceAds(nsAds []server_structs.NamespaceAdV2) []server_structs.NamespaceAdV2 {
+	namespaceLimitsMutex.RLock()
+	limiter := activeNamespaceLimits
+	namespaceLimitsMutex.RUnlock()
+	if limiter == nil {
+		return nsAds
+	}
+	return limiter.filterAds(nsAds)
+}
+
+// Enforce the per-namespace disk and bandwidth ceilings from
+// Cache.NamespaceLimits so a single namespace can't monopolize a shared cache
+func LaunchNamespaceLimits(ctx context.Context, egrp *errgroup.Group) error {
+	var limits []NamespaceLimit
+	if err := param.Cache_NamespaceLimits.Unmarshal(&limits); err != nil {
+		return errors.Wrap(err, "failed to parse Cache.NamespaceLimits")
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+	limiter, err := newNamespaceLimiter(limits)
+	if err != nil {
+		return err
+	}
+	interval := param.Cache_NamespaceLimitsInterval.GetDuration()
+	if interval <= 0 {
+		interval = time.Minute
+		log.Error("Invalid config value: Cache.NamespaceLimitsInterval must be positive. Fallback to 1m.")
+	}
+
+	namespaceLimitsMutex.Lock()
+	activeNamespaceLimits = limiter
+	namespaceLimitsMutex.Unlock()
+	metrics.SetFileTraceHook(namespaceLimitsHookName, limiter.record)
+
+	egrp.Go(func() error {
+		defer func() {
+			metrics.SetFileTraceHook(namespaceLimitsHookName, nil)
+			namespaceLimitsMutex.Lock()
+			activeNamespaceLimits = nil
+			namespaceLimitsMutex.Unlock()
+		}()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				limiter.checkBandwidth(now)
+				limiter.checkDisk(param.Cache_NamespaceLocation.GetString())
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	log.Infof("Enforcing cache limits for %d namespace(s)", len(limiter.namespaces))
+	return nil
+}
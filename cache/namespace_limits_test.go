@@ -0,0 +1,135 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestNewNamespaceLimiter(t *testing.T) {
+	limiter, err := newNamespaceLimiter([]NamespaceLimit{
+		{Prefix: "/foo", MaxDisk: "1GB"},
+		{Prefix: "foo/bar/", MaxBandwidth: "10MB/s"},
+	})
+	require.NoError(t, err)
+	require.Len(t, limiter.namespaces, 2)
+	// Most specific prefix first
+	assert.Equal(t, "/foo/bar", limiter.namespaces[0].prefix)
+	assert.Equal(t, int64(10_000_000), limiter.namespaces[0].maxBandwidth)
+	assert.Equal(t, int64(1_000_000_000), limiter.namespaces[1].maxDisk)
+
+	assert.Equal(t, "/foo/bar", limiter.lookup("/foo/bar/baz").prefix)
+	assert.Equal(t, "/foo", limiter.lookup("/foo/barbaz").prefix)
+	assert.Nil(t, limiter.lookup("/other/foo"))
+
+	for _, limits := range [][]NamespaceLimit{
+		{{Prefix: "/", MaxDisk: "1GB"}},
+		{{Prefix: "/foo"}},
+		{{Prefix: "/foo", MaxDisk: "lots"}},
+		{{Prefix: "/foo", MaxBandwidth: "fast"}},
+		{{Prefix: "/foo", MaxDisk: "1GB"}, {Prefix: "/foo/", MaxBandwidth: "1MB"}},
+	} {
+		_, err := newNamespaceLimiter(limits)
+		assert.Error(t, err, "limits %+v should be rejected", limits)
+	}
+}
+
+func TestNamespaceBandwidthLimit(t *testing.T) {
+	limiter, err := newNamespaceLimiter([]NamespaceLimit{{Prefix: "/foo", MaxBandwidth: "1KB"}})
+	require.NoError(t, err)
+	start := time.Now()
+	limiter.lastPass = start
+
+	limiter.record(metrics.FileTraceEvent{Type: metrics.FileTraceOpen, FileId: 1, Path: "/foo/data", Time: start})
+	limiter.record(metrics.FileTraceEvent{Type: metrics.FileTraceOpen, FileId: 2, Path: "/bar/data", Time: start})
+	limiter.record(metrics.FileTraceEvent{Type: metrics.FileTraceTransfer, FileId: 1, ReadBytes: 6000, Time: start})
+	// Byte counts are cumulative per file
+	limiter.record(metrics.FileTraceEvent{Type: metrics.FileTraceClose, FileId: 1, ReadBytes: 8000, ReadvBytes: 2000, Time: start})
+	limiter.record(metrics.FileTraceEvent{Type: metrics.FileTraceClose, FileId: 2, ReadBytes: 1 << 30, Time: start})
+	assert.Equal(t, uint64(10000), limiter.namespaces[0].egressBytes)
+	assert.Empty(t, limiter.openFiles[1])
+
+	ads := []server_structs.NamespaceAdV2{{Path: "/foo"}, {Path: "/foo/sub"}, {Path: "/foobar"}, {Path: "/bar"}}
+
+	// 10000 bytes over 5 seconds exceeds 1KB/s
+	limiter.checkBandwidth(start.Add(5 * time.Second))
+	assert.True(t, limiter.namespaces[0].withdrawn)
+	assert.Equal(t, []server_structs.NamespaceAdV2{{Path: "/foobar"}, {Path: "/bar"}}, limiter.filterAds(ads))
+
+	// Just under the limit isn't enough to restore the namespace
+	limiter.namespaces[0].egressBytes = 900 * 10
+	limiter.checkBandwidth(start.Add(15 * time.Second))
+	assert.True(t, limiter.namespaces[0].withdrawn)
+
+	limiter.namespaces[0].egressBytes = 100 * 10
+	limiter.checkBandwidth(start.Add(25 * time.Second))
+	assert.False(t, limiter.namespaces[0].withdrawn)
+	assert.Equal(t, ads, limiter.filterAds(ads))
+}
+
+func TestNamespaceDiskLimit(t *testing.T) {
+	namespaceLocation := t.TempDir()
+	limiter, err := newNamespaceLimiter([]NamespaceLimit{
+		{Prefix: "/foo", MaxDisk: "1GB"},
+		{Prefix: "/foo/nested", MaxDisk: "1GB"},
+	})
+	require.NoError(t, err)
+
+	writeObject := func(objectPath string, age time.Duration) string {
+		name := filepath.Join(namespaceLocation, filepath.FromSlash(objectPath))
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		require.NoError(t, os.WriteFile(name, make([]byte, 64*1024), 0644))
+		require.NoError(t, os.WriteFile(name+".cinfo", []byte("cinfo"), 0644))
+		accessed := time.Now().Add(-age)
+		require.NoError(t, os.Chtimes(name, accessed, accessed))
+		require.NoError(t, os.Chtimes(name+".cinfo", accessed, accessed))
+		return name
+	}
+	oldest := writeObject("/foo/a", 3*time.Hour)
+	inUse := writeObject("/foo/b", 2*time.Hour)
+	newest := writeObject("/foo/c", time.Hour)
+	nested := writeObject("/foo/nested/d", 4*time.Hour)
+
+	_, total, err := listCachedFiles(filepath.Join(namespaceLocation, "foo"))
+	require.NoError(t, err)
+	objectSize := total / 4
+	require.NotZero(t, objectSize)
+
+	// Room for two of the three objects directly under /foo
+	limiter.namespaces[1].maxDisk = objectSize * 2
+	limiter.record(metrics.FileTraceEvent{Type: metrics.FileTraceOpen, FileId: 1, Path: "/foo/b", Time: time.Now()})
+	limiter.checkDisk(namespaceLocation)
+
+	// The least recently accessed object that isn't open goes first, and
+	// the nested namespace is accounted separately
+	assert.NoFileExists(t, oldest)
+	assert.NoFileExists(t, oldest+".cinfo")
+	assert.FileExists(t, inUse)
+	assert.NoFileExists(t, newest)
+	assert.FileExists(t, nested)
+}
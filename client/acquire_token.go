@@ -23,10 +23,8 @@ import (
 	"crypto/ecdsa"
 	"encoding/json"
 	"fmt"
-	"io/fs"
 	"net/http"
 	"net/url"
-	"os"
 	"path"
 	"path/filepath"
 	"strconv"
@@ -50,6 +48,8 @@ import (
 )
 
 type (
+	// Where the client found or obtained a token
+	TokenSource string
 
 	// A token contents and its expiration time
 	//
@@ -57,6 +57,7 @@ type (
 	tokenInfo struct {
 		Contents string
 		Expiry   time.Time
+		Source   TokenSource
 	}
 
 	// An object that can fetch an appropriate token for a given transfer.
@@ -81,9 +82,42 @@ type (
 		Name          string
 		CredLocations []string
 		Method        int
+		Source        TokenSource // Source of the token most recently returned by next()
+		platform      clientPlatform
 	}
 )
 
+// Token sources, in the order the client consults them.  The first token found that
+// is acceptable for the transfer is used; if none is acceptable, the first token found
+// is tried anyway.  The credential store, local keys and OIDC are only consulted when
+// token acquisition is enabled and no token was found in the environment.
+const (
+	TokenSourceOption          TokenSource = "option"            // A token or token file given on the command line or through the API
+	TokenSourceBearerToken     TokenSource = "BEARER_TOKEN"      // WLCG bearer token discovery: the token itself in the environment
+	TokenSourceBearerTokenFile TokenSource = "BEARER_TOKEN_FILE" // WLCG bearer token discovery: a file named in the environment
+	TokenSourceRuntimeDir      TokenSource = "XDG_RUNTIME_DIR"   // WLCG bearer token discovery: $XDG_RUNTIME_DIR/bt_u<uid>
+	TokenSourceTmp             TokenSource = "/tmp"              // WLCG bearer token discovery: /tmp/bt_u<uid>
+	TokenSourceTokenEnv        TokenSource = "TOKEN"             // Legacy: a file named by the TOKEN environment variable
+	TokenSourceCondorCreds     TokenSource = "condor-creds"      // HTCondor credentials in $_CONDOR_CREDS
+	TokenSourceCredentialStore TokenSource = "credential-store"  // Unexpired tokens cached in the encrypted credential store
+	TokenSourceLocalKey        TokenSource = "local-key"         // Tokens signed with a local issuer key
+	TokenSourceOIDC            TokenSource = "oidc"              // Tokens refreshed or obtained through the OAuth2 device flow
+)
+
+// The documented order in which token sources are consulted
+var TokenSourceOrder = []TokenSource{
+	TokenSourceOption,
+	TokenSourceBearerToken,
+	TokenSourceBearerTokenFile,
+	TokenSourceRuntimeDir,
+	TokenSourceTmp,
+	TokenSourceTokenEnv,
+	TokenSourceCondorCreds,
+	TokenSourceCredentialStore,
+	TokenSourceLocalKey,
+	TokenSourceOIDC,
+}
+
 func newTokenGenerator(dest *pelican_url.PelicanURL, dirResp *server_structs.DirectorResponse, isWrite bool, enableAcquire bool) *tokenGenerator {
 	return &tokenGenerator{
 		DirResp:       dirResp,
@@ -98,6 +132,7 @@ func newTokenContentIterator(loc string, name string) *tokenContentIterator {
 	return &tokenContentIterator{
 		Location: loc,
 		Name:     name,
+		platform: hostPlatform,
 	}
 }
 
@@ -119,15 +154,21 @@ func (tg *tokenGenerator) SetToken(contents string) {
 	info := tokenInfo{
 		Contents: contents,
 		Expiry:   time.Now().Add(100 * 365 * 24 * time.Hour), // 100 years should be enough for "forever"
+		Source:   TokenSourceOption,
 	}
 	tg.Token.Store(&info)
 }
 
 // Read a token from a file; ensure
 func getTokenFromFile(tokenLocation string) (string, error) {
+	return hostPlatform.getTokenFromFile(tokenLocation)
+}
+
+// Read a token, either bare or as the access token of a JSON object, from a file
+func (p clientPlatform) getTokenFromFile(tokenLocation string) (string, error) {
 	//Read in the JSON
 	log.Debug("Opening token file: " + tokenLocation)
-	tokenContents, err := os.ReadFile(tokenLocation)
+	tokenContents, err := p.readTokenFile(tokenLocation)
 	if err != nil {
 		log.Errorln("Error reading from token file:", err)
 		return "", err
@@ -138,7 +179,7 @@ func getTokenFromFile(tokenLocation string) (string, error) {
 		ExpiresIn int    `json:"expires_in"`
 	}
 
-	tokenStr := strings.TrimSpace(string(tokenContents))
+	tokenStr := string(tokenContents)
 	if len(tokenStr) > 0 && tokenStr[0] == '{' {
 		tokenParsed := tokenJson{}
 		if err := json.Unmarshal(tokenContents, &tokenParsed); err != nil {
@@ -163,7 +204,7 @@ func (tci *tokenContentIterator) discoverHTCondorTokenLocations(tokenName string
 		}
 	}
 
-	credsDir, isCondorCredsSet := os.LookupEnv("_CONDOR_CREDS")
+	credsDir, isCondorCredsSet := tci.platform.lookupEnv("_CONDOR_CREDS")
 	if !isCondorCredsSet {
 		credsDir = ".condor_creds"
 	}
@@ -171,7 +212,7 @@ func (tci *tokenContentIterator) discoverHTCondorTokenLocations(tokenName string
 	if len(tokenName) > 0 {
 		tokenLocation := filepath.Join(credsDir, tokenName+".use")
 		// Token was explicitly requested; warn if it doesn't exist.
-		if _, err := os.Stat(filepath.Join(credsDir, tokenName)); err != nil {
+		if _, err := tci.platform.fs.Stat(filepath.Join(credsDir, tokenName)); err != nil {
 			log.Warningln("Environment variable _CONDOR_CREDS is set, but the credential file is not readable:", err)
 		} else {
 			tokenLocations = append(tokenLocations, tokenLocation)
@@ -180,29 +221,24 @@ func (tci *tokenContentIterator) discoverHTCondorTokenLocations(tokenName string
 	} else {
 		tokenLocation := filepath.Join(credsDir, "scitokens.use")
 		// Just prefer the scitokens.use first by convention; do not warn if it is missing
-		if _, err := os.Stat(tokenLocation); err == nil {
+		if _, err := tci.platform.fs.Stat(tokenLocation); err == nil {
 			tokenLocations = append(tokenLocations, tokenLocation)
 		}
 	}
 
 	// Walk through all available credentials in the directory; scitokens.use was already
 	// put first, if available, above.
-	err := filepath.Walk(credsDir, func(path string, info fs.FileInfo, err error) error {
-		if path == credsDir {
-			return nil
-		} else if info.IsDir() {
-			return filepath.SkipDir
-		}
-		baseName := filepath.Base(path)
-		if baseName == "scitokens.use" {
-			return nil
+	entries, err := tci.platform.fs.ReadDir(credsDir)
+	for _, entry := range entries {
+		baseName := entry.Name()
+		if entry.IsDir() || baseName == "scitokens.use" {
+			continue
 		}
 		if len(baseName) > 0 && baseName[0] == '.' {
-			return nil
+			continue
 		}
-		tokenLocations = append(tokenLocations, path)
-		return nil
-	})
+		tokenLocations = append(tokenLocations, filepath.Join(credsDir, baseName))
+	}
 	if err != nil {
 		log.Warningln("Failure when iterating through directory to look through tokens:", err)
 	}
@@ -220,9 +256,10 @@ func (tci *tokenContentIterator) next() (string, bool) {
 		tci.Method += 1
 		if tci.Location != "" {
 			log.Debugln("Using API-specified token location", tci.Location)
-			if _, err := os.Stat(tci.Location); err != nil {
+			if _, err := tci.platform.fs.Stat(tci.Location); err != nil {
 				log.Warningln("Client was asked to read token from location", tci.Location, "but it is not readable:", err)
-			} else if jwtSerialized, err := getTokenFromFile(tci.Location); err == nil {
+			} else if jwtSerialized, err := tci.platform.getTokenFromFile(tci.Location); err == nil {
+				tci.Source = TokenSourceOption
 				return jwtSerialized, true
 			}
 		}
@@ -230,31 +267,35 @@ func (tci *tokenContentIterator) next() (string, bool) {
 	// WLCG Token Discovery
 	case 1:
 		tci.Method += 1
-		if bearerToken, isBearerTokenSet := os.LookupEnv("BEARER_TOKEN"); isBearerTokenSet {
+		if bearerToken, isBearerTokenSet := tci.platform.lookupEnv("BEARER_TOKEN"); isBearerTokenSet {
 			log.Debugln("Using token from BEARER_TOKEN environment variable")
+			tci.Source = TokenSourceBearerToken
 			return bearerToken, true
 		}
 		fallthrough
 	case 2:
 		tci.Method += 1
-		if bearerTokenFile, isBearerTokenFileSet := os.LookupEnv("BEARER_TOKEN_FILE"); isBearerTokenFileSet {
+		if bearerTokenFile, isBearerTokenFileSet := tci.platform.lookupEnv("BEARER_TOKEN_FILE"); isBearerTokenFileSet {
 			log.Debugln("Using token from BEARER_TOKEN_FILE environment variable")
-			if _, err := os.Stat(bearerTokenFile); err != nil {
+			if _, err := tci.platform.fs.Stat(bearerTokenFile); err != nil {
 				log.Warningln("Environment variable BEARER_TOKEN_FILE is set, but file being point to does not exist:", err)
-			} else if jwtSerialized, err := getTokenFromFile(bearerTokenFile); err == nil {
+			} else if jwtSerialized, err := tci.platform.getTokenFromFile(bearerTokenFile); err == nil {
+				tci.Source = TokenSourceBearerTokenFile
 				return jwtSerialized, true
 			}
 		}
 		fallthrough
 	case 3:
 		tci.Method += 1
-		if xdgRuntimeDir, xdgRuntimeDirSet := os.LookupEnv("XDG_RUNTIME_DIR"); xdgRuntimeDirSet {
-			// Get the uid
-			uid := os.Getuid()
+		// The uid-based locations don't exist on Windows
+		if uid, ok := tci.platform.tokenUid(); !ok {
+			log.Debugln("Skipping the XDG_RUNTIME_DIR and /tmp token locations as there is no user ID on", tci.platform.goos)
+		} else if xdgRuntimeDir, xdgRuntimeDirSet := tci.platform.lookupEnv("XDG_RUNTIME_DIR"); xdgRuntimeDirSet {
 			tmpTokenPath := filepath.Join(xdgRuntimeDir, "bt_u"+strconv.Itoa(uid))
-			if _, err := os.Stat(tmpTokenPath); err == nil {
+			if _, err := tci.platform.fs.Stat(tmpTokenPath); err == nil {
 				log.Debugln("Using token from XDG_RUNTIME_DIR")
-				if jwtSerialized, err := getTokenFromFile(tmpTokenPath); err == nil {
+				if jwtSerialized, err := tci.platform.getTokenFromFile(tmpTokenPath); err == nil {
+					tci.Source = TokenSourceRuntimeDir
 					return jwtSerialized, true
 				}
 			}
@@ -263,12 +304,14 @@ func (tci *tokenContentIterator) next() (string, bool) {
 	case 4:
 		tci.Method += 1
 		// Check for /tmp/bt_u<uid>
-		uid := os.Getuid()
-		tmpTokenPath := "/tmp/bt_u" + strconv.Itoa(uid)
-		if _, err := os.Stat(tmpTokenPath); err == nil {
-			log.Debugln("Using token from", tmpTokenPath)
-			if jwtSerialized, err := getTokenFromFile(tmpTokenPath); err == nil {
-				return jwtSerialized, true
+		if uid, ok := tci.platform.tokenUid(); ok {
+			tmpTokenPath := "/tmp/bt_u" + strconv.Itoa(uid)
+			if _, err := tci.platform.fs.Stat(tmpTokenPath); err == nil {
+				log.Debugln("Using token from", tmpTokenPath)
+				if jwtSerialized, err := tci.platform.getTokenFromFile(tmpTokenPath); err == nil {
+					tci.Source = TokenSourceTmp
+					return jwtSerialized, true
+				}
 			}
 		}
 		fallthrough
@@ -276,11 +319,12 @@ func (tci *tokenContentIterator) next() (string, bool) {
 		tci.Method += 1
 		// Backwards compatibility for getting token; TOKEN env var is not standardized
 		// but some of the oldest use cases may utilize them.
-		if tokenFile, isTokenSet := os.LookupEnv("TOKEN"); isTokenSet {
-			if _, err := os.Stat(tokenFile); err != nil {
+		if tokenFile, isTokenSet := tci.platform.lookupEnv("TOKEN"); isTokenSet {
+			if _, err := tci.platform.fs.Stat(tokenFile); err != nil {
 				log.Warningln("Environment variable TOKEN is set, but file being point to does not exist:", err)
-			} else if jwtSerialized, err := getTokenFromFile(tokenFile); err == nil {
+			} else if jwtSerialized, err := tci.platform.getTokenFromFile(tokenFile); err == nil {
 				log.Debugln("Using token from TOKEN environment variable")
+				tci.Source = TokenSourceTokenEnv
 				return jwtSerialized, true
 			}
 		}
@@ -298,7 +342,8 @@ func (tci *tokenContentIterator) next() (string, bool) {
 				log.Debugln("Out of token locations to search")
 				return "", false
 			}
-			if jwtSerialized, err := getTokenFromFile(tci.CredLocations[idx]); err == nil {
+			if jwtSerialized, err := tci.platform.getTokenFromFile(tci.CredLocations[idx]); err == nil {
+				tci.Source = TokenSourceCondorCreds
 				return jwtSerialized, true
 			}
 		}
@@ -343,10 +388,10 @@ func (tg *tokenGenerator) getToken() (token interface{}, err error) {
 			break
 		}
 		valid, expiry := tokenIsValid(contents)
-		info := tokenInfo{contents, expiry}
+		info := tokenInfo{contents, expiry, tg.Iterator.Source}
 		if valid && (tg.DirResp == nil || tokenIsAcceptable(contents, tg.Destination.Path, *tg.DirResp, opts)) {
 			tg.Token.Store(&info)
-			log.Debugln("Using token:", info.Contents)
+			log.Debugf("Using token from %s: %s", info.Source, info.Contents)
 			return contents, nil
 		} else if contents != "" {
 			potentialTokens = append(potentialTokens, info)
@@ -356,7 +401,7 @@ func (tg *tokenGenerator) getToken() (token interface{}, err error) {
 	// If _any_ potential token is found, even though it's not thought to be acceptable,
 	// return that instead of failing outright under the theory the user knows better.
 	if len(potentialTokens) > 0 {
-		log.Warningln("Using provided token even though it does not appear to be acceptable to perform transfer")
+		log.Warningf("Using provided token from %s even though it does not appear to be acceptable to perform transfer", potentialTokens[0].Source)
 		tg.Token.Store(&potentialTokens[0])
 		token = potentialTokens[0].Contents
 		err = nil
@@ -369,10 +414,12 @@ func (tg *tokenGenerator) getToken() (token interface{}, err error) {
 			opts.Operation = config.TokenSharedWrite
 		}
 		var contents string
-		contents, err = AcquireToken(tg.Destination.GetRawUrl(), *tg.DirResp, opts)
+		var source TokenSource
+		contents, source, err = acquireToken(tg.Destination.GetRawUrl(), *tg.DirResp, opts)
 		if err == nil && contents != "" {
+			log.Debugln("Acquired a token from", source)
 			valid, expiry := tokenIsValid(contents)
-			info := tokenInfo{contents, expiry}
+			info := tokenInfo{contents, expiry, source}
 			if !tokenIsAcceptable(contents, tg.Destination.Path, *tg.DirResp, opts) {
 				log.Warningln("Token was acquired from issuer but it does not appear valid for transfer; trying anyway")
 			} else if !valid {
@@ -504,12 +551,41 @@ func tokenIsValid(jwtSerialized string) (valid bool, expiry time.Time) {
 	return
 }
 
-func registerClient(dirResp server_structs.DirectorResponse) (*config.PrefixEntry, error) {
+// Determine the issuer to acquire a token from for the namespace in dirResp.
+//
+// The director's token generation header is preferred.  If it was not sent, fall back
+// to the issuers advertised for the namespace, on the assumption that they support
+// the OAuth2 device flow.
+func acquisitionIssuer(dirResp server_structs.DirectorResponse) (string, error) {
+	nsPrefix := dirResp.XPelNsHdr.Namespace
 	issuers := dirResp.XPelTokGenHdr.Issuers
-	if len(issuers) == 0 {
-		return nil, fmt.Errorf("no issuer information for prefix '%s' is provided", dirResp.XPelNsHdr.Namespace)
+	switch tokStrategy := dirResp.XPelTokGenHdr.Strategy; tokStrategy {
+	case server_structs.OAuthStrategy:
+	case server_structs.VaultStrategy:
+		return "", fmt.Errorf("vault credential generation strategy is not supported")
+	case "":
+		if len(issuers) == 0 {
+			issuers = dirResp.XPelAuthHdr.Issuers
+			if len(issuers) > 0 {
+				log.Debugf("No token generation information for prefix %s; using the namespace issuer %s", nsPrefix, issuers[0])
+			}
+		}
+	default:
+		return "", fmt.Errorf("unknown credential generation strategy (%s) for prefix %s",
+			tokStrategy, nsPrefix)
+	}
+
+	if len(issuers) == 0 || issuers[0] == nil {
+		return "", fmt.Errorf("no issuer information for prefix '%s' is provided", nsPrefix)
+	}
+	issuer := issuers[0].String()
+	if len(issuer) == 0 {
+		return "", fmt.Errorf("issuer URL for prefix %s is unknown", nsPrefix)
 	}
-	issuerUrl := issuers[0].String()
+	return issuer, nil
+}
+
+func registerClient(dirResp server_structs.DirectorResponse, issuerUrl string) (*config.PrefixEntry, error) {
 	issuer, err := config.GetIssuerMetadata(issuerUrl)
 	if err != nil {
 		return nil, err
@@ -542,42 +618,30 @@ func registerClient(dirResp server_structs.DirectorResponse) (*config.PrefixEntr
 // Given a URL and a director Response, attempt to acquire a valid
 // token for that URL.
 func AcquireToken(destination *url.URL, dirResp server_structs.DirectorResponse, opts config.TokenGenerationOpts) (string, error) {
+	token, _, err := acquireToken(destination, dirResp, opts)
+	return token, err
+}
+
+// Acquire a token for the URL, consulting the credential store, local issuer keys and
+// finally the namespace's OAuth2 issuer.  Returns the token and the source it came from.
+func acquireToken(destination *url.URL, dirResp server_structs.DirectorResponse, opts config.TokenGenerationOpts) (string, TokenSource, error) {
 
 	log.Debugln("Acquiring a token from configuration and OAuth2")
 
 	nsPrefix := dirResp.XPelNsHdr.Namespace
+	federation := federationKey(destination)
 
-	switch tokStrategy := dirResp.XPelTokGenHdr.Strategy; tokStrategy {
-	case server_structs.OAuthStrategy:
-	case server_structs.VaultStrategy:
-		return "", fmt.Errorf("vault credential generation strategy is not supported")
-	default:
-		return "", fmt.Errorf("unknown credential generation strategy (%s) for prefix %s",
-			tokStrategy, nsPrefix)
-	}
-
-	issuers := dirResp.XPelTokGenHdr.Issuers
-	if len(issuers) == 0 {
-		return "", fmt.Errorf("no issuer information for prefix '%s' is provided", nsPrefix)
-	}
-
-	issuer := issuers[0].String()
-	if len(issuer) == 0 {
-		return "", fmt.Errorf("issuer URL for prefix %s is unknown", nsPrefix)
+	issuer, err := acquisitionIssuer(dirResp)
+	if err != nil {
+		return "", "", err
 	}
 
 	osdfConfig, err := config.GetCredentialConfigContents()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	prefixIdx := -1
-	for idx, entry := range osdfConfig.OSDF.OauthClient {
-		if entry.Prefix == nsPrefix {
-			prefixIdx = idx
-			break
-		}
-	}
+	prefixIdx := findCredentialEntry(&osdfConfig, federation, nsPrefix)
 	var prefixEntry *config.PrefixEntry
 	newEntry := false
 	tryTokenGen := false
@@ -585,35 +649,41 @@ func AcquireToken(destination *url.URL, dirResp server_structs.DirectorResponse,
 		// We prefer to generate a token over registering a new client.
 		if token, err := generateToken(destination, dirResp, opts); err == nil && token != "" {
 			log.Debugln("Successfully generated a new token from a local key")
-			return token, nil
+			return token, TokenSourceLocalKey, nil
 		}
 		tryTokenGen = true
 
-		log.Infof("Prefix configuration for %s not in configuration file; will request new client", nsPrefix)
-		prefixEntry, err = registerClient(dirResp)
+		log.Infof("Prefix configuration for %s in federation %s not in configuration file; will request new client", nsPrefix, federation)
+		prefixEntry, err = registerClient(dirResp, issuer)
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
+		prefixEntry.Federation = federation
 		osdfConfig.OSDF.OauthClient = append(osdfConfig.OSDF.OauthClient, *prefixEntry)
 		prefixEntry = &osdfConfig.OSDF.OauthClient[len(osdfConfig.OSDF.OauthClient)-1]
 		newEntry = true
 	} else {
 		prefixEntry = &osdfConfig.OSDF.OauthClient[prefixIdx]
+		// Entries saved by older clients are claimed by the first federation to use them
+		if prefixEntry.Federation == "" && federation != "" {
+			prefixEntry.Federation = federation
+		}
 		if len(prefixEntry.ClientID) == 0 || len(prefixEntry.ClientSecret) == 0 {
 
 			// Similarly, here, generate a token before registering a new client.
 			if token, err := generateToken(destination, dirResp, opts); err == nil && token != "" {
 				log.Debugln("Successfully generated a new token from a local key")
-				return token, nil
+				return token, TokenSourceLocalKey, nil
 			}
 			tryTokenGen = true
 
 			log.Infof("Prefix configuration for %s missing OAuth2 client information", nsPrefix)
-			prefixEntry, err = registerClient(dirResp)
+			registered, err := registerClient(dirResp, issuer)
 			if err != nil {
-				return "", err
+				return "", "", err
 			}
-			osdfConfig.OSDF.OauthClient[prefixIdx] = *prefixEntry
+			registered.Federation = federation
+			*prefixEntry = *registered
 			newEntry = true
 		}
 	}
@@ -644,7 +714,7 @@ func AcquireToken(destination *url.URL, dirResp server_structs.DirectorResponse,
 	}
 	if len(acceptableUnexpiredToken) > 0 {
 		log.Debugln("Returning an unexpired token from cache")
-		return acceptableUnexpiredToken, nil
+		return acceptableUnexpiredToken, TokenSourceCredentialStore, nil
 	}
 
 	if acceptableToken != nil && len(acceptableToken.RefreshToken) > 0 {
@@ -679,7 +749,7 @@ func AcquireToken(destination *url.URL, dirResp server_structs.DirectorResponse,
 				if err = config.SaveConfigContents(&osdfConfig); err != nil {
 					log.Warningln("Failed to save new token to configuration file:", err)
 				}
-				return newToken.AccessToken, nil
+				return newToken.AccessToken, TokenSourceOIDC, nil
 			}
 		}
 	}
@@ -689,7 +759,7 @@ func AcquireToken(destination *url.URL, dirResp server_structs.DirectorResponse,
 	if !tryTokenGen {
 		if token, err := generateToken(destination, dirResp, opts); err == nil && token != "" {
 			log.Debugln("Successfully generated a new token from a local key")
-			return token, nil
+			return token, TokenSourceLocalKey, nil
 		}
 	}
 
@@ -698,20 +768,21 @@ func AcquireToken(destination *url.URL, dirResp server_structs.DirectorResponse,
 		// We use anonymously-registered clients; OA4MP can periodically garbage collect these to prevent DoS
 		// In this case, we register a new client and try to acquire again.
 		log.Infof("Identity provider does not know the client for %s; registering a new one", nsPrefix)
-		prefixEntry, err = registerClient(dirResp)
+		registered, err := registerClient(dirResp, issuer)
 		if err != nil {
-			return "", errors.Wrap(err, "re-registration error (identity provider does not recognize our client)")
+			return "", "", errors.Wrap(err, "re-registration error (identity provider does not recognize our client)")
 		}
-		osdfConfig.OSDF.OauthClient[prefixIdx] = *prefixEntry
+		registered.Federation = federation
+		*prefixEntry = *registered
 		if err = config.SaveConfigContents(&osdfConfig); err != nil {
 			log.Warningln("Failed to save new token to configuration file:", err)
 		}
 
 		if token, err = oauth2.AcquireToken(issuer, prefixEntry, dirResp, destination.Path, opts); err != nil {
-			return "", err
+			return "", "", err
 		}
 	} else if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	Tokens := &prefixEntry.Tokens
@@ -721,7 +792,7 @@ func AcquireToken(destination *url.URL, dirResp server_structs.DirectorResponse,
 		log.Warningln("Failed to save new token to configuration file:", err)
 	}
 
-	return token.AccessToken, nil
+	return token.AccessToken, TokenSourceOIDC, nil
 }
 
 // Given a URL and a known public key, determine whether the public key
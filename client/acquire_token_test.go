@@ -0,0 +1,70 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestAcquisitionIssuer(t *testing.T) {
+	tokGenIssuer, err := url.Parse("https://tokgen.example.com")
+	require.NoError(t, err)
+	nsIssuer, err := url.Parse("https://issuer.example.com")
+	require.NoError(t, err)
+
+	t.Run("prefer-token-generation-header", func(t *testing.T) {
+		dirResp := server_structs.DirectorResponse{
+			XPelNsHdr:     server_structs.XPelNs{Namespace: "/foo"},
+			XPelAuthHdr:   server_structs.XPelAuth{Issuers: []*url.URL{nsIssuer}},
+			XPelTokGenHdr: server_structs.XPelTokGen{Strategy: server_structs.OAuthStrategy, Issuers: []*url.URL{tokGenIssuer}},
+		}
+		issuer, err := acquisitionIssuer(dirResp)
+		require.NoError(t, err)
+		assert.Equal(t, "https://tokgen.example.com", issuer)
+	})
+
+	t.Run("fall-back-to-namespace-issuer", func(t *testing.T) {
+		dirResp := server_structs.DirectorResponse{
+			XPelNsHdr:   server_structs.XPelNs{Namespace: "/foo"},
+			XPelAuthHdr: server_structs.XPelAuth{Issuers: []*url.URL{nsIssuer}},
+		}
+		issuer, err := acquisitionIssuer(dirResp)
+		require.NoError(t, err)
+		assert.Equal(t, "https://issuer.example.com", issuer)
+	})
+
+	t.Run("no-issuer", func(t *testing.T) {
+		_, err := acquisitionIssuer(server_structs.DirectorResponse{XPelNsHdr: server_structs.XPelNs{Namespace: "/foo"}})
+		assert.ErrorContains(t, err, "no issuer information for prefix '/foo'")
+	})
+
+	t.Run("vault-unsupported", func(t *testing.T) {
+		dirResp := server_structs.DirectorResponse{
+			XPelTokGenHdr: server_structs.XPelTokGen{Strategy: server_structs.VaultStrategy, Issuers: []*url.URL{tokGenIssuer}},
+		}
+		_, err := acquisitionIssuer(dirResp)
+		assert.Error(t, err)
+	})
+}
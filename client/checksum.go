@@ -0,0 +1,329 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/error_codes"
+)
+
+type (
+	// The checksum algorithm used to verify transferred objects
+	ChecksumType int
+
+	// Error returned when the checksum of a transferred object does not match
+	// the checksum reported by the server
+	ChecksumMismatchError struct {
+		Path     string
+		Type     ChecksumType
+		Expected string
+		Actual   string
+	}
+)
+
+const (
+	ChecksumNone ChecksumType = iota
+	ChecksumSHA256
+	ChecksumMD5
+	ChecksumAdler32
+)
+
+// The checksum types tried, in order of preference, when none is specified
+var defaultChecksumTypes = []ChecksumType{ChecksumSHA256, ChecksumMD5, ChecksumAdler32}
+
+// Parse a checksum type as given on the command line; the empty string
+// (or "none") disables checksum verification
+func ParseChecksumType(name string) (ChecksumType, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "none":
+		return ChecksumNone, nil
+	case "sha256", "sha-256":
+		return ChecksumSHA256, nil
+	case "md5":
+		return ChecksumMD5, nil
+	case "adler32":
+		return ChecksumAdler32, nil
+	}
+	return ChecksumNone, errors.Errorf("unknown checksum type %q; supported types are sha256, md5, and adler32", name)
+}
+
+func (ct ChecksumType) String() string {
+	switch ct {
+	case ChecksumSHA256:
+		return "sha256"
+	case ChecksumMD5:
+		return "md5"
+	case ChecksumAdler32:
+		return "adler32"
+	}
+	return "none"
+}
+
+// The algorithm name used in the Digest and Want-Digest headers (RFC 3230)
+func (ct ChecksumType) digestName() string {
+	if ct == ChecksumSHA256 {
+		return "sha-256"
+	}
+	return ct.String()
+}
+
+func (ct ChecksumType) newHash() hash.Hash {
+	switch ct {
+	case ChecksumSHA256:
+		return sha256.New()
+	case ChecksumMD5:
+		return md5.New()
+	case ChecksumAdler32:
+		return adler32.New()
+	}
+	return nil
+}
+
+// Encode a checksum the way it appears in a Digest header: adler32 is
+// hex-encoded while the cryptographic digests are base64-encoded
+func (ct ChecksumType) encode(sum []byte) string {
+	if ct == ChecksumAdler32 {
+		return hex.EncodeToString(sum)
+	}
+	return base64.StdEncoding.EncodeToString(sum)
+}
+
+// Returns true if the value from a Digest header matches the computed checksum.
+// Servers are inconsistent about the encoding, so both hex and base64 are accepted.
+func (ct ChecksumType) matches(digestValue string, sum []byte) bool {
+	digestValue = strings.TrimSpace(digestValue)
+	if decoded, err := hex.DecodeString(digestValue); err == nil && len(decoded) == len(sum) {
+		return bytes.Equal(decoded, sum)
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(digestValue); err == nil {
+		return bytes.Equal(decoded, sum)
+	}
+	return false
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch for %s: server reported %s but the local copy has %s", e.Type.String(), e.Path, e.Expected, e.Actual)
+}
+
+func (e *ChecksumMismatchError) Is(target error) bool {
+	_, ok := target.(*ChecksumMismatchError)
+	return ok
+}
+
+// Compute the checksum of a local file
+func computeFileChecksum(localPath string, ct ChecksumType) ([]byte, error) {
+	hasher := ct.newHash()
+	if hasher == nil {
+		return nil, errors.Errorf("unsupported checksum type %s", ct.String())
+	}
+	fp, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	if _, err = io.Copy(hasher, fp); err != nil {
+		return nil, errors.Wrapf(err, "failed to compute the %s checksum of %s", ct.String(), localPath)
+	}
+	return hasher.Sum(nil), nil
+}
+
+// Query the server for the checksums of an object using a HEAD request with a
+// Want-Digest header.  Only the requested checksum types are returned; the map is
+// empty if the server reported none of them.
+func fetchRemoteChecksums(ctx context.Context, client *http.Client, objectUrl string, token string, project string, types []ChecksumType) (map[ChecksumType]string, error) {
+	wanted := make([]string, 0, len(types))
+	for _, ct := range types {
+		wanted = append(wanted, ct.digestName())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, objectUrl, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create checksum request")
+	}
+	req.Header.Set("Want-Digest", strings.Join(wanted, ", "))
+	req.Header.Set("User-Agent", getUserAgent(project))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query object checksum")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("server returned status %d when querying object checksum", resp.StatusCode)
+	}
+
+	digests := parseDigestHeader(resp.Header.Get("Digest"))
+	checksums := make(map[ChecksumType]string)
+	for _, ct := range types {
+		if value, ok := digests[ct.digestName()]; ok {
+			checksums[ct] = value
+		}
+	}
+	return checksums, nil
+}
+
+// Verify a local file against the checksum the server reports for the remote object.
+//
+// If ct is ChecksumNone, the first of the default checksum types reported by the server
+// is used.  Returns the checksum type that was verified; it is an error if the server
+// reports none of the requested checksums.
+func verifyChecksum(ctx context.Context, client *http.Client, objectUrl string, localPath string, ct ChecksumType, token string, project string) (ChecksumType, error) {
+	return verifyChecksumOf(ctx, client, objectUrl, localPath, ct, token, project, func(candidate ChecksumType) ([]byte, error) {
+		return computeFileChecksum(localPath, candidate)
+	})
+}
+
+// Verify data against the checksum the server reports for the remote object, as in
+// verifyChecksum; compute returns the checksum of the data for a given type and name
+// identifies the data in errors.
+func verifyChecksumOf(ctx context.Context, client *http.Client, objectUrl string, name string, ct ChecksumType, token string, project string, compute func(ChecksumType) ([]byte, error)) (ChecksumType, error) {
+	types := defaultChecksumTypes
+	if ct != ChecksumNone {
+		types = []ChecksumType{ct}
+	}
+	checksums, err := fetchRemoteChecksums(ctx, client, objectUrl, token, project, types)
+	if err != nil {
+		return ChecksumNone, err
+	}
+	for _, candidate := range types {
+		expected, ok := checksums[candidate]
+		if !ok {
+			continue
+		}
+		sum, err := compute(candidate)
+		if err != nil {
+			return candidate, err
+		}
+		if !candidate.matches(expected, sum) {
+			return candidate, error_codes.NewTransfer_ChecksumMismatchError(&ChecksumMismatchError{
+				Path:     name,
+				Type:     candidate,
+				Expected: expected,
+				Actual:   candidate.encode(sum),
+			})
+		}
+		log.Debugf("Verified %s checksum of %s: %s", candidate.String(), name, expected)
+		return candidate, nil
+	}
+	names := make([]string, 0, len(types))
+	for _, candidate := range types {
+		names = append(names, candidate.String())
+	}
+	return ChecksumNone, errors.Errorf("server did not report a %s checksum for the object; unable to verify %s", strings.Join(names, " or "), name)
+}
+
+// Verify a completed download against the checksum reported by the endpoint it came from
+func verifyDownloadChecksum(ctx context.Context, transfer transferAttemptDetails, remotePath string, localPath string, ct ChecksumType, token string, project string) error {
+	client, objectUrl := endpointChecksumClient(transfer, remotePath)
+	_, err := verifyChecksum(ctx, client, objectUrl, localPath, ct, token, project)
+	return err
+}
+
+// Verify an object downloaded to memory against the checksum reported by the endpoint
+// it came from.  As the data is at hand, any checksum type the endpoint reports can be
+// used when ct is ChecksumNone.
+func verifyMemoryChecksum(ctx context.Context, transfer transferAttemptDetails, remotePath string, ct ChecksumType, data []byte, token string, project string) error {
+	client, objectUrl := endpointChecksumClient(transfer, remotePath)
+	_, err := verifyChecksumOf(ctx, client, objectUrl, remotePath, ct, token, project, func(candidate ChecksumType) ([]byte, error) {
+		hasher := candidate.newHash()
+		if hasher == nil {
+			return nil, errors.Errorf("unsupported checksum type %s", candidate.String())
+		}
+		hasher.Write(data)
+		return hasher.Sum(nil), nil
+	})
+	return err
+}
+
+// Verify the data streamed from an endpoint against the checksum it reports for the object
+func verifyStreamChecksum(ctx context.Context, transfer transferAttemptDetails, remotePath string, ct ChecksumType, sum []byte, token string, project string) error {
+	client, objectUrl := endpointChecksumClient(transfer, remotePath)
+	return verifySum(ctx, client, objectUrl, remotePath, ct, sum, token, project)
+}
+
+// Build the HTTP client and URL for querying the checksum of an object from the
+// endpoint of a transfer attempt
+func endpointChecksumClient(transfer transferAttemptDetails, remotePath string) (*http.Client, string) {
+	transport := config.GetTransport().Clone()
+	transport.Proxy = transferProxy(transfer)
+	objectUrl := *transfer.Url
+	objectUrl.Path = remotePath
+	objectUrl.RawQuery = ""
+	if transfer.Url.Scheme == "unix" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			dialer := net.Dialer{}
+			return dialer.DialContext(ctx, "unix", transfer.UnixSocket)
+		}
+		objectUrl.Scheme = "http"
+		objectUrl.Host = "localhost"
+	}
+	return &http.Client{Transport: transport}, objectUrl.String()
+}
+
+// Verify an already-computed checksum of an object's data against the one the server
+// reports.  Used for streamed transfers, where there is no local file to checksum
+// afterward; name identifies the object in the mismatch error.
+func verifySum(ctx context.Context, client *http.Client, objectUrl string, name string, ct ChecksumType, sum []byte, token string, project string) error {
+	checksums, err := fetchRemoteChecksums(ctx, client, objectUrl, token, project, []ChecksumType{ct})
+	if err != nil {
+		return err
+	}
+	expected, ok := checksums[ct]
+	if !ok {
+		return errors.Errorf("server did not report a %s checksum for the object; unable to verify %s", ct.String(), name)
+	}
+	if !ct.matches(expected, sum) {
+		return error_codes.NewTransfer_ChecksumMismatchError(&ChecksumMismatchError{
+			Path:     name,
+			Type:     ct,
+			Expected: expected,
+			Actual:   ct.encode(sum),
+		})
+	}
+	log.Debugf("Verified %s checksum of %s: %s", ct.String(), name, expected)
+	return nil
+}
+
+// Verify a completed upload by comparing the checksum of the local file against
+// the one the server computed for the newly-written object
+func verifyUploadChecksum(ctx context.Context, dest *url.URL, localPath string, ct ChecksumType, token string, project string) error {
+	client := &http.Client{Transport: config.GetTransport()}
+	_, err := verifyChecksum(ctx, client, dest.String(), localPath, ct, token, project)
+	return err
+}
@@ -0,0 +1,120 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecksumType(t *testing.T) {
+	for name, expected := range map[string]ChecksumType{
+		"":        ChecksumNone,
+		"sha256":  ChecksumSHA256,
+		"SHA-256": ChecksumSHA256,
+		"md5":     ChecksumMD5,
+		"adler32": ChecksumAdler32,
+	} {
+		ct, err := ParseChecksumType(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, ct, name)
+	}
+	_, err := ParseChecksumType("crc64")
+	assert.Error(t, err)
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	contents := []byte("Hello, checksummed world!")
+	localPath := filepath.Join(t.TempDir(), "test.txt")
+	require.NoError(t, os.WriteFile(localPath, contents, 0644))
+	shaSum := sha256.Sum256(contents)
+	md5Sum := md5.Sum(contents)
+
+	// The server returns whatever Digest header the test sets, recording the Want-Digest it received
+	var digestHeader, wantDigest string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wantDigest = r.Header.Get("Want-Digest")
+		if digestHeader != "" {
+			w.Header().Set("Digest", digestHeader)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	objectUrl := server.URL + "/test.txt"
+	client := server.Client()
+	ctx := context.Background()
+
+	t.Run("sha256-match", func(t *testing.T) {
+		digestHeader = "sha-256=" + base64.StdEncoding.EncodeToString(shaSum[:])
+		verified, err := verifyChecksum(ctx, client, objectUrl, localPath, ChecksumSHA256, "", "")
+		require.NoError(t, err)
+		assert.Equal(t, ChecksumSHA256, verified)
+		assert.Equal(t, "sha-256", wantDigest)
+	})
+
+	t.Run("hex-encoded-md5", func(t *testing.T) {
+		digestHeader = "md5=" + hex.EncodeToString(md5Sum[:])
+		_, err := verifyChecksum(ctx, client, objectUrl, localPath, ChecksumMD5, "", "")
+		require.NoError(t, err)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		digestHeader = "sha-256=" + base64.StdEncoding.EncodeToString(md5Sum[:])
+		_, err := verifyChecksum(ctx, client, objectUrl, localPath, ChecksumSHA256, "", "")
+		require.Error(t, err)
+		assert.ErrorIs(t, err, &ChecksumMismatchError{})
+		assert.True(t, IsRetryable(err))
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		digestHeader = "md5=" + base64.StdEncoding.EncodeToString(md5Sum[:])
+		_, err := verifyChecksum(ctx, client, objectUrl, localPath, ChecksumAdler32, "", "")
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, &ChecksumMismatchError{})
+	})
+
+	t.Run("any-type", func(t *testing.T) {
+		digestHeader = "adler32=77100933,md5=" + base64.StdEncoding.EncodeToString(md5Sum[:])
+		verified, err := verifyChecksum(ctx, client, objectUrl, localPath, ChecksumNone, "", "")
+		require.NoError(t, err)
+		// MD5 is preferred over adler32 when SHA-256 is unavailable
+		assert.Equal(t, ChecksumMD5, verified)
+		assert.Equal(t, "sha-256, md5, adler32", wantDigest)
+	})
+
+	t.Run("download-attempt", func(t *testing.T) {
+		digestHeader = "sha-256=" + base64.StdEncoding.EncodeToString(shaSum[:])
+		serverUrl, err := url.Parse(server.URL)
+		require.NoError(t, err)
+		err = verifyDownloadChecksum(ctx, transferAttemptDetails{Url: serverUrl}, "/test.txt", localPath, ChecksumSHA256, "", "")
+		assert.NoError(t, err)
+	})
+}
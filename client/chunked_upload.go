@@ -0,0 +1,295 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/error_codes"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+const (
+	// How many times a chunk is sent before the upload fails
+	chunkedUploadAttempts = 3
+	// The smallest chunk size honored, to bound the number of requests
+	chunkedUploadMinChunkSize = 1024 * 1024
+)
+
+// Sends a large upload in chunks, in parallel, through the web API of an origin
+// that accepts chunked uploads.  The origin assembles the chunks and only publishes
+// the object once its checksum matches that of the local file.
+type chunkedUploader struct {
+	ctx       context.Context
+	client    *http.Client
+	apiUrl    *url.URL
+	object    string
+	token     string
+	project   string
+	uploadId  string
+	uploaded  atomic.Int64
+	transfer  *transferFile
+	size      int64
+	chunkSize int64
+}
+
+// The size of the local file if the upload should be sent in chunks: the director
+// reported that the origin accepts chunked uploads and the file is at least
+// Client.ChunkedUploadThreshold bytes
+func useChunkedUpload(transfer *transferFile) (int64, bool) {
+	if transfer.job == nil || transfer.job.dirResp.ChunkedUploadUrl == nil || transfer.packOption != "" || !transfer.job.compat.Allows(FeatureChunkedUpload) {
+		return 0, false
+	}
+	threshold := int64(param.Client_ChunkedUploadThreshold.GetInt())
+	if threshold <= 0 || isStreamPath(transfer.localPath) || IsObjectStorageURL(transfer.localPath) {
+		return 0, false
+	}
+	info, err := os.Stat(transfer.localPath)
+	if err != nil || !info.Mode().IsRegular() || info.Size() < threshold {
+		return 0, false
+	}
+	return info.Size(), true
+}
+
+// The URL of an origin API acting on the object, e.g. "chunked" or "publish"
+func (u *chunkedUploader) url(api string, query url.Values) string {
+	apiUrl := *u.apiUrl
+	apiUrl.Path = strings.TrimSuffix(apiUrl.Path, "/") + "/api/v1.0/origin/" + api + u.object
+	apiUrl.RawQuery = query.Encode()
+	return apiUrl.String()
+}
+
+// Send a request to the origin API, returning the response if it has the expected status
+func (u *chunkedUploader) do(ctx context.Context, method string, api string, query url.Values, body io.Reader, length int64, header http.Header, expected int) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.url(api, query), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = length
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if u.token != "" {
+		req.Header.Set("Authorization", "Bearer "+u.token)
+	}
+	req.Header.Set("User-Agent", getUserAgent(u.project))
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != expected {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &HttpErrResp{resp.StatusCode, fmt.Sprintf("%s of %s failed (HTTP status %d): %s", api, u.object, resp.StatusCode, strings.TrimSpace(string(msg)))}
+	}
+	return resp, nil
+}
+
+// Send one chunk of the file, retrying failures the origin may recover from
+func (u *chunkedUploader) sendChunk(ctx context.Context, file *os.File, offset int64, length int64) (err error) {
+	query := url.Values{"upload": {u.uploadId}, "offset": {strconv.FormatInt(offset, 10)}}
+	for attempt := 1; attempt <= chunkedUploadAttempts; attempt++ {
+		body := u.transfer.engine.limitReader(ctx, io.NopCloser(io.NewSectionReader(file, offset, length)))
+		var resp *http.Response
+		if resp, err = u.do(ctx, http.MethodPut, "chunked", query, body, length, nil, http.StatusOK); err == nil {
+			resp.Body.Close()
+			u.uploaded.Add(length)
+			if u.transfer.callback != nil {
+				u.transfer.callback(u.transfer.localPath, u.uploaded.Load(), u.size, false)
+			}
+			return nil
+		}
+		var httpErr *HttpErrResp
+		if ctx.Err() != nil || (errors.As(err, &httpErr) && httpErr.Code < 500) {
+			return err
+		}
+		log.Debugf("Failed to send the chunk at offset %d of %s (attempt %d of %d): %v", offset, u.object, attempt, chunkedUploadAttempts, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+	}
+	return err
+}
+
+// Upload the file in chunks, then have the origin assemble and publish it
+func (u *chunkedUploader) upload(concurrency int, ct ChecksumType) error {
+	// The checksum is computed while the chunks are sent
+	checksumDone := make(chan error, 1)
+	var localChecksum []byte
+	go func() {
+		var err error
+		localChecksum, err = computeFileChecksum(u.transfer.localPath, ct)
+		checksumDone <- err
+	}()
+	waitChecksum := func() error { return <-checksumDone }
+
+	resp, err := u.do(u.ctx, http.MethodPost, "chunked", url.Values{"size": {strconv.FormatInt(u.size, 10)}}, nil, 0, nil, http.StatusCreated)
+	if err != nil {
+		_ = waitChecksum()
+		return errors.Wrap(err, "failed to start the chunked upload")
+	}
+	started := struct {
+		Id string `json:"id"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&started)
+	resp.Body.Close()
+	if err != nil || started.Id == "" {
+		_ = waitChecksum()
+		return errors.New("the origin did not return the id of the chunked upload")
+	}
+	u.uploadId = started.Id
+	log.Debugf("Uploading %s in chunks of %d bytes as %s", u.object, u.chunkSize, u.uploadId)
+
+	file, err := os.Open(u.transfer.localPath)
+	if err != nil {
+		_ = waitChecksum()
+		u.discard("chunked", url.Values{"upload": {u.uploadId}})
+		return err
+	}
+	defer file.Close()
+	egrp, egrpCtx := errgroup.WithContext(u.ctx)
+	egrp.SetLimit(concurrency)
+	for offset := int64(0); offset < u.size; offset += u.chunkSize {
+		offset, length := offset, min(u.chunkSize, u.size-offset)
+		egrp.Go(func() error {
+			if egrpCtx.Err() != nil {
+				return nil
+			}
+			return u.sendChunk(egrpCtx, file, offset, length)
+		})
+	}
+	err = egrp.Wait()
+	checksumErr := waitChecksum()
+	if err != nil || checksumErr != nil {
+		u.discard("chunked", url.Values{"upload": {u.uploadId}})
+		if err != nil {
+			return err
+		}
+		return checksumErr
+	}
+
+	if resp, err = u.do(u.ctx, http.MethodPost, "assemble", url.Values{"upload": {u.uploadId}}, nil, 0, nil, http.StatusCreated); err != nil {
+		u.discard("chunked", url.Values{"upload": {u.uploadId}})
+		return errors.Wrap(err, "failed to assemble the chunked upload")
+	}
+	resp.Body.Close()
+	// Catch a corrupted upload before it replaces any existing object
+	remoteChecksum := parseDigestHeader(resp.Header.Get("Digest"))[strings.ToLower(ct.digestName())]
+	if remoteChecksum != "" && !ct.matches(remoteChecksum, localChecksum) {
+		u.discard("staging", nil)
+		return error_codes.NewTransfer_ChecksumMismatchError(&ChecksumMismatchError{
+			Path:     u.object,
+			Type:     ct,
+			Expected: remoteChecksum,
+			Actual:   ct.encode(localChecksum),
+		})
+	}
+
+	header := http.Header{"Digest": {ct.digestName() + "=" + ct.encode(localChecksum)}}
+	if resp, err = u.do(u.ctx, http.MethodPost, "publish", nil, nil, 0, header, http.StatusOK); err != nil {
+		return errors.Wrap(err, "failed to publish the chunked upload")
+	}
+	resp.Body.Close()
+	log.Debugf("Published the chunked upload %s of %s", u.uploadId, u.object)
+	return nil
+}
+
+// Abandon the chunked or staged upload on the origin; failures only leave it to expire
+func (u *chunkedUploader) discard(api string, query url.Values) {
+	// The transfer's context may be cancelled already
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if resp, err := u.do(ctx, http.MethodDelete, api, query, nil, 0, nil, http.StatusOK); err != nil {
+		log.Debugf("Failed to discard the upload of %s: %v", u.object, err)
+	} else {
+		resp.Body.Close()
+	}
+}
+
+// Upload an object in chunks sent in parallel to the origin's web API
+func uploadObjectChunked(transfer *transferFile, size int64) (transferResult TransferResults, err error) {
+	transferResult = newTransferResults(transfer.job)
+	transferResult.Scheme = transfer.remoteURL.Scheme
+	apiUrl := transfer.job.dirResp.ChunkedUploadUrl
+	attempt := TransferResult{Endpoint: apiUrl.Host, CacheAge: -1}
+
+	// Verify with the requested checksum, or SHA-256 if none was
+	ct := transfer.job.checksumType
+	if ct == ChecksumNone {
+		ct = ChecksumSHA256
+	}
+	uploader := &chunkedUploader{
+		ctx:       transfer.ctx,
+		client:    &http.Client{Transport: config.GetTransport()},
+		apiUrl:    apiUrl,
+		object:    transfer.remoteURL.Path,
+		project:   transfer.project,
+		transfer:  transfer,
+		size:      size,
+		chunkSize: max(int64(param.Client_UploadChunkSize.GetInt()), chunkedUploadMinChunkSize),
+	}
+	if transfer.token != nil {
+		uploader.token, _ = transfer.token.get()
+	}
+	if transfer.callback != nil {
+		transfer.callback(transfer.localPath, 0, size, false)
+		defer func() {
+			transfer.callback(transfer.localPath, uploader.uploaded.Load(), size, true)
+		}()
+	}
+
+	start := time.Now()
+	uploadErr := uploader.upload(max(param.Client_UploadConcurrency.GetInt(), 1), ct)
+	end := time.Now()
+	attempt.TransferFileBytes = uploader.uploaded.Load()
+	attempt.TransferEndTime = end
+	attempt.TransferTime = end.Sub(start)
+	transferResult.TransferStartTime = start
+	transferResult.TransferredBytes = uploader.uploaded.Load()
+	if uploadErr != nil {
+		log.Errorln("Chunked upload failed:", uploadErr)
+		xferErrors := NewTransferErrors()
+		xferErrors.AddPastError(newTransferAttemptError(apiUrl.Host, "", false, true, uploadErr), end)
+		transferResult.Error = xferErrors
+		attempt.Error = uploadErr
+	} else {
+		log.Debugf("Successful chunked upload of %d bytes", size)
+	}
+	transferResult.Attempts = append(transferResult.Attempts, attempt)
+	return transferResult, nil
+}
@@ -0,0 +1,153 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/test_utils"
+)
+
+// A fake origin web API accepting chunked uploads of the object /test/big.bin.  The first
+// attempt to send each chunk fails, and the assembled object is published as is, or
+// corrupted if requested.
+type fakeChunkedOrigin struct {
+	mutex     sync.Mutex
+	data      []byte
+	failed    map[string]bool
+	corrupt   bool
+	published []byte
+	discarded bool
+}
+
+func (origin *fakeChunkedOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin.mutex.Lock()
+	defer origin.mutex.Unlock()
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	digest := func() string {
+		sum := sha256.Sum256(origin.data)
+		return "sha-256=" + base64.StdEncoding.EncodeToString(sum[:])
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1.0/origin/chunked/test/big.bin":
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		origin.data = make([]byte, size)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": "upload-1"}`))
+	case r.Method == http.MethodPut && r.URL.Path == "/api/v1.0/origin/chunked/test/big.bin":
+		offset := r.URL.Query().Get("offset")
+		if !origin.failed[offset] {
+			origin.failed[offset] = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		start, _ := strconv.Atoi(offset)
+		body, _ := io.ReadAll(r.Body)
+		copy(origin.data[start:], body)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1.0/origin/assemble/test/big.bin":
+		if origin.corrupt {
+			origin.data[0] ^= 0xff
+		}
+		w.Header().Set("Digest", digest())
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1.0/origin/publish/test/big.bin":
+		if r.Header.Get("Digest") != digest() {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		origin.published = origin.data
+	case r.Method == http.MethodDelete:
+		origin.discarded = true
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestChunkedUpload(t *testing.T) {
+	test_utils.InitClient(t, map[string]any{
+		"Client.ChunkedUploadThreshold": 1024,
+		"Client.UploadChunkSize":        1,
+		"Client.UploadConcurrency":      2,
+	})
+	// Three chunks, the last one short
+	contents := []byte(strings.Repeat("0123456789", 250*1024))
+	localPath := filepath.Join(t.TempDir(), "big.bin")
+	require.NoError(t, os.WriteFile(localPath, contents, 0644))
+
+	upload := func(t *testing.T, origin *fakeChunkedOrigin) TransferResults {
+		server := httptest.NewServer(origin)
+		t.Cleanup(server.Close)
+		apiUrl, err := url.Parse(server.URL)
+		require.NoError(t, err)
+		token := newTokenGenerator(nil, nil, true, false)
+		token.SetToken("test-token")
+		transfer := &transferFile{
+			ctx: context.Background(),
+			job: &TransferJob{
+				dirResp: server_structs.DirectorResponse{ChunkedUploadUrl: apiUrl},
+			},
+			localPath: localPath,
+			remoteURL: &url.URL{Scheme: "pelican", Path: "/test/big.bin"},
+			token:     token,
+			upload:    true,
+		}
+		size, ok := useChunkedUpload(transfer)
+		require.True(t, ok)
+		require.Equal(t, int64(len(contents)), size)
+		results, err := uploadObject(transfer)
+		require.NoError(t, err)
+		return results
+	}
+
+	t.Run("success", func(t *testing.T) {
+		origin := &fakeChunkedOrigin{failed: map[string]bool{}}
+		results := upload(t, origin)
+		require.NoError(t, results.Error)
+		assert.Equal(t, int64(len(contents)), results.TransferredBytes)
+		assert.Equal(t, contents, origin.published)
+		assert.Len(t, origin.failed, 3, "each chunk should have been retried")
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		origin := &fakeChunkedOrigin{failed: map[string]bool{}, corrupt: true}
+		results := upload(t, origin)
+		require.Error(t, results.Error)
+		assert.Nil(t, origin.published)
+		assert.True(t, origin.discarded)
+	})
+}
@@ -0,0 +1,119 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// A client behavior that can be disabled to reproduce an older release
+	ClientFeature string
+
+	// The release whose client behavior is being reproduced.  The zero value
+	// enables every feature.
+	CompatLevel struct {
+		version *version.Version
+	}
+)
+
+const (
+	FeaturePacking              ClientFeature = "packing"
+	FeatureMultiSource          ClientFeature = "multi-source"
+	FeatureResume               ClientFeature = "resume"
+	FeatureChecksumVerification ClientFeature = "checksum-verification"
+	FeaturePreserveMtime        ClientFeature = "preserve-mtime"
+	FeatureThirdPartyCopy       ClientFeature = "third-party-copy"
+	FeatureChunkedUpload        ClientFeature = "chunked-upload"
+)
+
+var (
+	// The release that introduced each feature, in the order they are reported
+	clientFeatures = []struct {
+		feature    ClientFeature
+		introduced *version.Version
+	}{
+		{FeaturePacking, version.Must(version.NewVersion("7.5.0"))},
+		{FeatureMultiSource, version.Must(version.NewVersion("7.11.0"))},
+		{FeatureResume, version.Must(version.NewVersion("7.11.0"))},
+		{FeatureChecksumVerification, version.Must(version.NewVersion("7.11.0"))},
+		{FeaturePreserveMtime, version.Must(version.NewVersion("7.11.0"))},
+		{FeatureThirdPartyCopy, version.Must(version.NewVersion("7.11.0"))},
+		{FeatureChunkedUpload, version.Must(version.NewVersion("7.11.0"))},
+	}
+)
+
+// Parse a compatibility level such as "7.10" or "v7.10.2"; an empty string
+// selects the current behavior
+func ParseCompatLevel(level string) (CompatLevel, error) {
+	level = strings.TrimSpace(level)
+	if level == "" {
+		return CompatLevel{}, nil
+	}
+	ver, err := version.NewVersion(level)
+	if err != nil {
+		return CompatLevel{}, errors.Wrapf(err, "invalid compatibility level %q", level)
+	}
+	return CompatLevel{version: ver}, nil
+}
+
+// The compatibility level configured by Client.CompatibilityLevel
+func getCompatLevel() (CompatLevel, error) {
+	return ParseCompatLevel(param.Client_CompatibilityLevel.GetString())
+}
+
+func (level CompatLevel) IsSet() bool {
+	return level.version != nil
+}
+
+// The release being reproduced, or an empty string if none
+func (level CompatLevel) String() string {
+	if level.version == nil {
+		return ""
+	}
+	return level.version.String()
+}
+
+// Report whether a feature was available in the release being reproduced
+func (level CompatLevel) Allows(feature ClientFeature) bool {
+	if level.version == nil {
+		return true
+	}
+	for _, entry := range clientFeatures {
+		if entry.feature == feature {
+			return !level.version.LessThan(entry.introduced)
+		}
+	}
+	return true
+}
+
+// The features disabled at this compatibility level
+func (level CompatLevel) DisabledFeatures() (disabled []ClientFeature) {
+	for _, entry := range clientFeatures {
+		if !level.Allows(entry.feature) {
+			disabled = append(disabled, entry.feature)
+		}
+	}
+	return
+}
@@ -0,0 +1,65 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompatLevel(t *testing.T) {
+	t.Run("unset-allows-everything", func(t *testing.T) {
+		level, err := ParseCompatLevel("")
+		require.NoError(t, err)
+		assert.False(t, level.IsSet())
+		assert.Equal(t, "", level.String())
+		assert.True(t, level.Allows(FeatureMultiSource))
+		assert.True(t, level.Allows(FeaturePacking))
+		assert.Empty(t, level.DisabledFeatures())
+	})
+
+	t.Run("older-release-disables-newer-features", func(t *testing.T) {
+		level, err := ParseCompatLevel("v7.10")
+		require.NoError(t, err)
+		assert.True(t, level.IsSet())
+		assert.Equal(t, "7.10.0", level.String())
+		assert.True(t, level.Allows(FeaturePacking))
+		assert.False(t, level.Allows(FeatureMultiSource))
+		assert.Equal(t, []ClientFeature{FeatureMultiSource, FeatureResume, FeatureChecksumVerification,
+			FeaturePreserveMtime, FeatureThirdPartyCopy, FeatureChunkedUpload}, level.DisabledFeatures())
+
+		level, err = ParseCompatLevel("7.4.2")
+		require.NoError(t, err)
+		assert.False(t, level.Allows(FeaturePacking))
+	})
+
+	t.Run("release-with-feature-allows-it", func(t *testing.T) {
+		level, err := ParseCompatLevel("7.11.0")
+		require.NoError(t, err)
+		assert.Empty(t, level.DisabledFeatures())
+		assert.True(t, level.Allows(ClientFeature("unknown")))
+	})
+
+	t.Run("invalid-level", func(t *testing.T) {
+		_, err := ParseCompatLevel("not-a-version")
+		assert.Error(t, err)
+	})
+}
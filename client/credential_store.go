@@ -0,0 +1,165 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/pelican_url"
+)
+
+type (
+	// A summary of the credentials saved for one namespace in the
+	// encrypted credential store.  Token contents are never included.
+	StoredCredential struct {
+		Federation   string    `json:"federation"`
+		Prefix       string    `json:"prefix"`
+		ClientID     string    `json:"clientId"`
+		Tokens       int       `json:"tokens"`
+		ValidTokens  int       `json:"validTokens"`
+		Refreshable  bool      `json:"refreshable"`
+		LatestExpiry time.Time `json:"latestExpiry,omitempty"`
+	}
+)
+
+// The federation a URL belongs to, used to key entries in the credential store:
+// the discovery host of pelican:// URLs, the OSDF for osdf:// and stash:// URLs, and
+// otherwise the configured federation.
+func federationKey(destination *url.URL) string {
+	if destination != nil {
+		switch destination.Scheme {
+		case pelican_url.PelicanScheme:
+			if destination.Host != "" {
+				return destination.Host
+			}
+		case pelican_url.OsdfScheme, pelican_url.StashScheme:
+			return pelican_url.OsdfDiscoveryHost
+		}
+	}
+	if discoveryUrl, err := url.Parse(param.Federation_DiscoveryUrl.GetString()); err == nil && discoveryUrl.Host != "" {
+		return discoveryUrl.Host
+	}
+	return ""
+}
+
+// Find the credential store entry for a namespace in a federation, returning -1 if
+// there is none.  An entry for the federation is preferred over one saved by an older
+// client without a federation.
+func findCredentialEntry(osdfConfig *config.OSDFConfig, federation string, prefix string) int {
+	legacyIdx := -1
+	for idx, entry := range osdfConfig.OSDF.OauthClient {
+		if entry.Prefix != prefix {
+			continue
+		}
+		if entry.Federation == federation {
+			return idx
+		} else if entry.Federation == "" && legacyIdx < 0 {
+			legacyIdx = idx
+		}
+	}
+	return legacyIdx
+}
+
+// Report whether a credential store entry matches the given filters; empty filters match anything
+func credentialEntryMatches(entry config.PrefixEntry, federation string, prefix string) bool {
+	return (federation == "" || entry.Federation == federation) && (prefix == "" || entry.Prefix == prefix)
+}
+
+// Summarize the credentials saved in the encrypted credential store, sorted by
+// federation and prefix
+func ListStoredCredentials() ([]StoredCredential, error) {
+	osdfConfig, err := config.GetCredentialConfigContents()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the credential store")
+	}
+	return summarizeCredentials(osdfConfig, time.Now()), nil
+}
+
+func summarizeCredentials(osdfConfig config.OSDFConfig, now time.Time) []StoredCredential {
+	creds := make([]StoredCredential, 0, len(osdfConfig.OSDF.OauthClient))
+	for _, entry := range osdfConfig.OSDF.OauthClient {
+		cred := StoredCredential{
+			Federation: entry.Federation,
+			Prefix:     entry.Prefix,
+			ClientID:   entry.ClientID,
+			Tokens:     len(entry.Tokens),
+		}
+		for _, token := range entry.Tokens {
+			expiry := time.Unix(token.Expiration, 0)
+			if expiry.After(now) {
+				cred.ValidTokens++
+			}
+			if expiry.After(cred.LatestExpiry) {
+				cred.LatestExpiry = expiry
+			}
+			cred.Refreshable = cred.Refreshable || token.RefreshToken != ""
+		}
+		creds = append(creds, cred)
+	}
+	sort.SliceStable(creds, func(i, j int) bool {
+		if creds[i].Federation != creds[j].Federation {
+			return creds[i].Federation < creds[j].Federation
+		}
+		return creds[i].Prefix < creds[j].Prefix
+	})
+	return creds
+}
+
+// Remove the credentials saved for the matching federation and prefix from the
+// credential store; empty filters match anything.  If tokensOnly is set, the OAuth2
+// client registrations are kept and only the tokens are removed.
+//
+// Returns the number of namespaces whose credentials were removed.
+func ClearStoredCredentials(federation string, prefix string, tokensOnly bool) (int, error) {
+	osdfConfig, err := config.GetCredentialConfigContents()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read the credential store")
+	}
+	cleared := clearCredentials(&osdfConfig, federation, prefix, tokensOnly)
+	if cleared == 0 {
+		return 0, nil
+	}
+	if err = config.SaveConfigContents(&osdfConfig); err != nil {
+		return 0, errors.Wrap(err, "failed to save the credential store")
+	}
+	return cleared, nil
+}
+
+func clearCredentials(osdfConfig *config.OSDFConfig, federation string, prefix string, tokensOnly bool) (cleared int) {
+	kept := make([]config.PrefixEntry, 0, len(osdfConfig.OSDF.OauthClient))
+	for _, entry := range osdfConfig.OSDF.OauthClient {
+		if !credentialEntryMatches(entry, federation, prefix) {
+			kept = append(kept, entry)
+			continue
+		}
+		cleared++
+		if tokensOnly {
+			entry.Tokens = nil
+			kept = append(kept, entry)
+		}
+	}
+	osdfConfig.OSDF.OauthClient = kept
+	return
+}
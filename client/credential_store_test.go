@@ -0,0 +1,145 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func TestTokenSourcePrecedence(t *testing.T) {
+	dir := t.TempDir()
+	writeToken := func(name string, contents string) string {
+		location := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(location, []byte(contents), 0600))
+		return location
+	}
+	credsDir := filepath.Join(dir, "creds")
+	require.NoError(t, os.Mkdir(credsDir, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(credsDir, "scitokens.use"), []byte("condor"), 0600))
+
+	t.Setenv("BEARER_TOKEN", "bearer")
+	t.Setenv("BEARER_TOKEN_FILE", writeToken("bearer_file", "bearer-file"))
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	writeToken("bt_u"+strconv.Itoa(os.Getuid()), "runtime-dir")
+	t.Setenv("TOKEN", writeToken("token_env", "token-env"))
+	t.Setenv("_CONDOR_CREDS", credsDir)
+
+	iter := newTokenContentIterator(writeToken("option", "option"), "")
+	var sources []TokenSource
+	contents := map[TokenSource]string{}
+	for {
+		token, ok := iter.next()
+		if !ok {
+			break
+		}
+		sources = append(sources, iter.Source)
+		contents[iter.Source] = token
+	}
+
+	// Every source is visited in the documented order; /tmp/bt_u<uid> is
+	// outside of the test's control and may or may not be present.
+	sources = slices.DeleteFunc(sources, func(source TokenSource) bool { return source == TokenSourceTmp })
+	assert.Equal(t, []TokenSource{TokenSourceOption, TokenSourceBearerToken, TokenSourceBearerTokenFile,
+		TokenSourceRuntimeDir, TokenSourceTokenEnv, TokenSourceCondorCreds}, sources)
+	assert.True(t, slices.IsSortedFunc(sources, func(a, b TokenSource) int {
+		return slices.Index(TokenSourceOrder, a) - slices.Index(TokenSourceOrder, b)
+	}))
+	assert.Equal(t, "option", contents[TokenSourceOption])
+	assert.Equal(t, "bearer-file", contents[TokenSourceBearerTokenFile])
+	assert.Equal(t, "condor", contents[TokenSourceCondorCreds])
+}
+
+func TestFederationKey(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(server_utils.ResetTestState)
+
+	parse := func(raw string) *url.URL {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		return u
+	}
+	assert.Equal(t, "fed.example.com", federationKey(parse("pelican://fed.example.com/foo/bar")))
+	assert.Equal(t, "osg-htc.org", federationKey(parse("osdf:///foo/bar")))
+	assert.Equal(t, "", federationKey(parse("/foo/bar")))
+
+	viper.Set("Federation.DiscoveryUrl", "https://other.example.com")
+	assert.Equal(t, "other.example.com", federationKey(parse("/foo/bar")))
+}
+
+func TestCredentialStoreEntries(t *testing.T) {
+	now := time.Now()
+	osdfConfig := config.OSDFConfig{}
+	osdfConfig.OSDF.OauthClient = []config.PrefixEntry{
+		{Prefix: "/foo", ClientID: "legacy"},
+		{Federation: "b.example.com", Prefix: "/foo", ClientID: "b-foo", Tokens: []config.TokenEntry{
+			{AccessToken: "expired", Expiration: now.Add(-time.Hour).Unix()},
+			{AccessToken: "valid", RefreshToken: "refresh", Expiration: now.Add(time.Hour).Unix()},
+		}},
+		{Federation: "a.example.com", Prefix: "/bar", ClientID: "a-bar"},
+	}
+
+	t.Run("find-prefers-federation", func(t *testing.T) {
+		assert.Equal(t, 1, findCredentialEntry(&osdfConfig, "b.example.com", "/foo"))
+		// Entries without a federation match any federation
+		assert.Equal(t, 0, findCredentialEntry(&osdfConfig, "a.example.com", "/foo"))
+		assert.Equal(t, -1, findCredentialEntry(&osdfConfig, "b.example.com", "/bar"))
+	})
+
+	t.Run("summarize", func(t *testing.T) {
+		creds := summarizeCredentials(osdfConfig, now)
+		require.Len(t, creds, 3)
+		assert.Equal(t, "", creds[0].Federation)
+		assert.Equal(t, "a.example.com", creds[1].Federation)
+		assert.Equal(t, "b-foo", creds[2].ClientID)
+		assert.Equal(t, 2, creds[2].Tokens)
+		assert.Equal(t, 1, creds[2].ValidTokens)
+		assert.True(t, creds[2].Refreshable)
+		assert.Equal(t, now.Add(time.Hour).Unix(), creds[2].LatestExpiry.Unix())
+	})
+
+	t.Run("clear-tokens-only", func(t *testing.T) {
+		cfg := osdfConfig
+		cfg.OSDF.OauthClient = slices.Clone(osdfConfig.OSDF.OauthClient)
+		assert.Equal(t, 1, clearCredentials(&cfg, "b.example.com", "", true))
+		require.Len(t, cfg.OSDF.OauthClient, 3)
+		assert.Empty(t, cfg.OSDF.OauthClient[1].Tokens)
+		assert.Equal(t, "b-foo", cfg.OSDF.OauthClient[1].ClientID)
+	})
+
+	t.Run("clear-prefix", func(t *testing.T) {
+		cfg := osdfConfig
+		cfg.OSDF.OauthClient = slices.Clone(osdfConfig.OSDF.OauthClient)
+		assert.Equal(t, 2, clearCredentials(&cfg, "", "/foo", false))
+		require.Len(t, cfg.OSDF.OauthClient, 1)
+		assert.Equal(t, "/bar", cfg.OSDF.OauthClient[0].Prefix)
+	})
+}
@@ -0,0 +1,532 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, University of Nebraska-Lincoln
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// A transfer submitted to the client transfer daemon.  Local paths must be
+	// absolute as the daemon does not share the working directory of the
+	// submitter.
+	DaemonTransferRequest struct {
+		Operation     string   `json:"operation"` // One of "get" or "put"
+		RemoteUrl     string   `json:"remoteUrl"`
+		LocalPath     string   `json:"localPath"`
+		Recursive     bool     `json:"recursive,omitempty"`
+		TokenLocation string   `json:"tokenLocation,omitempty"`
+		Caches        []string `json:"caches,omitempty"`
+		Checksum      string   `json:"checksum,omitempty"`
+		ResumeJournal string   `json:"resumeJournal,omitempty"`
+		DisableResume bool     `json:"disableResume,omitempty"`
+		Decompress    bool     `json:"decompress,omitempty"`
+		SkipExisting  string   `json:"skipExisting,omitempty"`
+	}
+
+	// The progress of a single object of a daemon transfer
+	DaemonObjectProgress struct {
+		Path             string `json:"path"`
+		TransferredBytes int64  `json:"transferredBytes"`
+		TotalBytes       int64  `json:"totalBytes"`
+		Completed        bool   `json:"completed"`
+	}
+
+	// The outcome of a single object of a daemon transfer
+	DaemonObjectResult struct {
+		Source           string `json:"source"`
+		Destination      string `json:"destination"`
+		TransferredBytes int64  `json:"transferredBytes"`
+		Error            string `json:"error,omitempty"`
+		Retryable        bool   `json:"retryable,omitempty"`
+	}
+
+	// The state of a transfer known to the client transfer daemon
+	DaemonTransferStatus struct {
+		ID string `json:"id"`
+		DaemonTransferRequest
+		Status      string                 `json:"status"`
+		SubmittedAt time.Time              `json:"submittedAt"`
+		FinishedAt  *time.Time             `json:"finishedAt,omitempty"`
+		Progress    []DaemonObjectProgress `json:"progress,omitempty"`
+		Results     []DaemonObjectResult   `json:"results,omitempty"`
+		Error       string                 `json:"error,omitempty"`
+		Retryable   bool                   `json:"retryable,omitempty"`
+	}
+
+	daemonTransfer struct {
+		mutex    sync.Mutex
+		status   DaemonTransferStatus
+		progress map[string]*DaemonObjectProgress
+		cancel   context.CancelFunc
+	}
+
+	daemonRunFunc func(ctx context.Context, request DaemonTransferRequest, options ...TransferOption) ([]TransferResults, error)
+
+	// A long-running transfer engine shared by the client invocations on a
+	// machine, controlled through a REST API on a Unix socket
+	TransferDaemon struct {
+		ctx       context.Context
+		mutex     sync.Mutex
+		transfers map[string]*daemonTransfer
+		run       daemonRunFunc
+		accessKey string
+	}
+)
+
+const (
+	DaemonOperationGet = "get"
+	DaemonOperationPut = "put"
+
+	DaemonStatusRunning   = "running"
+	DaemonStatusSucceeded = "succeeded"
+	DaemonStatusFailed    = "failed"
+	DaemonStatusCancelled = "cancelled"
+
+	daemonApiPrefix = "/api/v1.0/client"
+
+	// How long finished transfers are remembered by the daemon
+	daemonTransferRetention = time.Hour
+
+	// Name of the file holding the access key for the daemon's TCP listener
+	daemonAccessKeyFile = "client-daemon.key"
+)
+
+func newTransferDaemon(ctx context.Context, run daemonRunFunc) *TransferDaemon {
+	return &TransferDaemon{
+		ctx:       ctx,
+		transfers: make(map[string]*daemonTransfer),
+		run:       run,
+	}
+}
+
+// Check a submitted transfer for problems before it is started
+func (request *DaemonTransferRequest) validate() error {
+	switch request.Operation {
+	case DaemonOperationGet, DaemonOperationPut:
+	default:
+		return errors.Errorf("unknown operation %q; must be %q or %q", request.Operation, DaemonOperationGet, DaemonOperationPut)
+	}
+	if request.RemoteUrl == "" {
+		return errors.New("the transfer is missing a remote URL")
+	}
+	if request.LocalPath == "" || isStreamPath(request.LocalPath) {
+		return errors.New("the transfer is missing a local path")
+	}
+	if !filepath.IsAbs(request.LocalPath) {
+		return errors.Errorf("local path %s is not absolute", request.LocalPath)
+	}
+	if request.TokenLocation != "" && !filepath.IsAbs(request.TokenLocation) {
+		return errors.Errorf("token location %s is not absolute", request.TokenLocation)
+	}
+	if request.ResumeJournal != "" && !filepath.IsAbs(request.ResumeJournal) {
+		return errors.Errorf("resume journal %s is not absolute", request.ResumeJournal)
+	}
+	if _, err := ParseChecksumType(request.Checksum); err != nil {
+		return err
+	}
+	if request.SkipExisting != "" {
+		if _, err := ParseSyncLevel(request.SkipExisting); err != nil {
+			return err
+		}
+	}
+	for _, cache := range request.Caches {
+		if _, err := url.Parse(cache); err != nil {
+			return errors.Wrapf(err, "invalid cache URL %s", cache)
+		}
+	}
+	return nil
+}
+
+// Build the transfer options corresponding to the request
+func (request *DaemonTransferRequest) options() (options []TransferOption) {
+	options = append(options, WithRecursive(request.Recursive))
+	if request.TokenLocation != "" {
+		options = append(options, WithTokenLocation(request.TokenLocation))
+	}
+	if len(request.Caches) > 0 {
+		caches := make([]*url.URL, 0, len(request.Caches))
+		for _, cache := range request.Caches {
+			if cacheUrl, err := url.Parse(cache); err == nil {
+				caches = append(caches, cacheUrl)
+			}
+		}
+		options = append(options, WithCaches(caches...))
+	}
+	if checksumType, err := ParseChecksumType(request.Checksum); err == nil && checksumType != ChecksumNone {
+		options = append(options, WithChecksum(checksumType))
+	}
+	if request.ResumeJournal != "" {
+		options = append(options, WithResumeJournal(request.ResumeJournal))
+	}
+	if request.Operation == DaemonOperationGet {
+		options = append(options, WithResume(!request.DisableResume), WithDecompress(request.Decompress))
+	} else if request.SkipExisting != "" {
+		if syncLevel, err := ParseSyncLevel(request.SkipExisting); err == nil {
+			options = append(options, WithSynchronize(syncLevel))
+		}
+	}
+	return
+}
+
+// Record the progress of one object of the transfer
+func (transfer *daemonTransfer) callback(path string, downloaded int64, totalSize int64, completed bool) {
+	transfer.mutex.Lock()
+	defer transfer.mutex.Unlock()
+	progress, ok := transfer.progress[path]
+	if !ok {
+		progress = &DaemonObjectProgress{Path: path}
+		transfer.progress[path] = progress
+	}
+	progress.TransferredBytes = downloaded
+	progress.TotalBytes = totalSize
+	progress.Completed = progress.Completed || completed
+}
+
+// Return a copy of the transfer's status, including the per-object progress
+func (transfer *daemonTransfer) snapshot() DaemonTransferStatus {
+	transfer.mutex.Lock()
+	defer transfer.mutex.Unlock()
+	status := transfer.status
+	status.Progress = make([]DaemonObjectProgress, 0, len(transfer.progress))
+	for _, progress := range transfer.progress {
+		status.Progress = append(status.Progress, *progress)
+	}
+	sort.Slice(status.Progress, func(i, j int) bool { return status.Progress[i].Path < status.Progress[j].Path })
+	status.Results = append([]DaemonObjectResult(nil), transfer.status.Results...)
+	return status
+}
+
+// Record the outcome of the transfer
+func (transfer *daemonTransfer) finish(ctx context.Context, results []TransferResults, err error) {
+	transfer.mutex.Lock()
+	defer transfer.mutex.Unlock()
+	now := time.Now()
+	transfer.status.FinishedAt = &now
+	for _, result := range results {
+		objResult := DaemonObjectResult{
+			Source:           result.Source,
+			Destination:      result.Destination,
+			TransferredBytes: result.TransferredBytes,
+		}
+		if result.Error != nil {
+			objResult.Error = result.Error.Error()
+			objResult.Retryable = ShouldRetry(result.Error)
+		}
+		transfer.status.Results = append(transfer.status.Results, objResult)
+	}
+	switch {
+	case ctx.Err() != nil:
+		transfer.status.Status = DaemonStatusCancelled
+		transfer.status.Error = "transfer was cancelled"
+	case err != nil:
+		transfer.status.Status = DaemonStatusFailed
+		transfer.status.Error = err.Error()
+		transfer.status.Retryable = ShouldRetry(err)
+	default:
+		transfer.status.Status = DaemonStatusSucceeded
+	}
+}
+
+// Start a transfer in the background and return its initial status
+func (td *TransferDaemon) submit(request DaemonTransferRequest) (status DaemonTransferStatus, err error) {
+	if err = request.validate(); err != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(td.ctx)
+	transfer := &daemonTransfer{
+		status: DaemonTransferStatus{
+			ID:                    uuid.NewString(),
+			DaemonTransferRequest: request,
+			Status:                DaemonStatusRunning,
+			SubmittedAt:           time.Now(),
+		},
+		progress: make(map[string]*DaemonObjectProgress),
+		cancel:   cancel,
+	}
+
+	td.mutex.Lock()
+	td.pruneLocked()
+	td.transfers[transfer.status.ID] = transfer
+	td.mutex.Unlock()
+
+	log.Infof("Starting daemon transfer %s: %s %s %s", transfer.status.ID, request.Operation, request.RemoteUrl, request.LocalPath)
+	go func() {
+		defer cancel()
+		options := append(request.options(), WithCallback(transfer.callback))
+		results, err := td.run(ctx, request, options...)
+		transfer.finish(ctx, results, err)
+		if err != nil {
+			log.Warningf("Daemon transfer %s failed: %v", transfer.status.ID, err)
+		} else {
+			log.Infof("Daemon transfer %s completed", transfer.status.ID)
+		}
+	}()
+	return transfer.snapshot(), nil
+}
+
+// Forget the transfers that finished more than daemonTransferRetention ago
+func (td *TransferDaemon) pruneLocked() {
+	cutoff := time.Now().Add(-daemonTransferRetention)
+	for id, transfer := range td.transfers {
+		transfer.mutex.Lock()
+		finishedAt := transfer.status.FinishedAt
+		transfer.mutex.Unlock()
+		if finishedAt != nil && finishedAt.Before(cutoff) {
+			delete(td.transfers, id)
+		}
+	}
+}
+
+func (td *TransferDaemon) get(id string) (*daemonTransfer, bool) {
+	td.mutex.Lock()
+	defer td.mutex.Unlock()
+	transfer, ok := td.transfers[id]
+	return transfer, ok
+}
+
+// Return the status of every known transfer, oldest first
+func (td *TransferDaemon) list() []DaemonTransferStatus {
+	td.mutex.Lock()
+	td.pruneLocked()
+	transfers := make([]*daemonTransfer, 0, len(td.transfers))
+	for _, transfer := range td.transfers {
+		transfers = append(transfers, transfer)
+	}
+	td.mutex.Unlock()
+
+	statuses := make([]DaemonTransferStatus, 0, len(transfers))
+	for _, transfer := range transfers {
+		status := transfer.snapshot()
+		// Listings only summarize each transfer
+		status.Progress = nil
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].SubmittedAt.Before(statuses[j].SubmittedAt) })
+	return statuses
+}
+
+func writeDaemonJSON(w http.ResponseWriter, code int, obj any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		log.Debugln("Failed to write daemon response:", err)
+	}
+}
+
+func writeDaemonError(w http.ResponseWriter, code int, msg string) {
+	writeDaemonJSON(w, code, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: msg})
+}
+
+// Serve the daemon's REST API:
+//
+//	GET    /api/v1.0/client/transfers      List the known transfers
+//	POST   /api/v1.0/client/transfers      Submit a DaemonTransferRequest
+//	GET    /api/v1.0/client/transfers/:id  Get the status of a transfer
+//	DELETE /api/v1.0/client/transfers/:id  Cancel a transfer
+func (td *TransferDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	if path == daemonApiPrefix+"/transfers" {
+		switch r.Method {
+		case http.MethodGet:
+			writeDaemonJSON(w, http.StatusOK, td.list())
+		case http.MethodPost:
+			var request DaemonTransferRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				writeDaemonError(w, http.StatusBadRequest, "Invalid transfer request: "+err.Error())
+				return
+			}
+			status, err := td.submit(request)
+			if err != nil {
+				writeDaemonError(w, http.StatusBadRequest, "Invalid transfer request: "+err.Error())
+				return
+			}
+			writeDaemonJSON(w, http.StatusCreated, status)
+		default:
+			writeDaemonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	id, found := strings.CutPrefix(path, daemonApiPrefix+"/transfers/")
+	if !found || id == "" || strings.Contains(id, "/") {
+		writeDaemonError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	transfer, ok := td.get(id)
+	if !ok {
+		writeDaemonError(w, http.StatusNotFound, fmt.Sprintf("No transfer with ID %s", id))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeDaemonJSON(w, http.StatusOK, transfer.snapshot())
+	case http.MethodDelete:
+		log.Infoln("Cancelling daemon transfer", id)
+		transfer.cancel()
+		writeDaemonJSON(w, http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK, Msg: "Transfer cancelled"})
+	default:
+		writeDaemonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// Require the daemon's access key on requests arriving over TCP, where any
+// local user may connect
+func (td *TransferDaemon) requireAccessKey(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+td.accessKey)) != 1 {
+			writeDaemonError(w, http.StatusUnauthorized, "Missing or invalid daemon access key")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Remove a socket left behind by a previous daemon, refusing to touch one
+// that is still being served
+func removeStaleSocket(socketName string) error {
+	if _, err := os.Lstat(socketName); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if conn, err := net.DialTimeout("unix", socketName, time.Second); err == nil {
+		conn.Close()
+		return errors.Errorf("another process is already listening on %s", socketName)
+	}
+	return errors.Wrap(os.Remove(socketName), "failed to remove stale daemon socket")
+}
+
+// Write a new random access key for the daemon's TCP listener next to the socket
+func writeDaemonAccessKey(socketName string) (string, error) {
+	keyBytes := make([]byte, 32)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return "", errors.Wrap(err, "failed to generate daemon access key")
+	}
+	accessKey := hex.EncodeToString(keyBytes)
+	keyFile := filepath.Join(filepath.Dir(socketName), daemonAccessKeyFile)
+	if err := os.WriteFile(keyFile, []byte(accessKey+"\n"), 0600); err != nil {
+		return "", errors.Wrap(err, "failed to write daemon access key")
+	}
+	return accessKey, nil
+}
+
+// Launch the client transfer daemon, serving its REST API on the
+// Client.DaemonSocket Unix socket (and, if Client.DaemonPort is set, on the
+// loopback interface) until ctx is cancelled.  All transfers run on a
+// single transfer engine, so they share connections, tokens, and the
+// Client.MaxRate bandwidth limit.
+func LaunchTransferDaemon(ctx context.Context, egrp *errgroup.Group) error {
+	te, err := NewTransferEngine(ctx)
+	if err != nil {
+		return err
+	}
+	td := newTransferDaemon(ctx, func(ctx context.Context, request DaemonTransferRequest, options ...TransferOption) ([]TransferResults, error) {
+		if request.Operation == DaemonOperationPut {
+			return te.Put(ctx, request.LocalPath, request.RemoteUrl, options...)
+		}
+		return te.Get(ctx, request.RemoteUrl, request.LocalPath, options...)
+	})
+
+	socketName := param.Client_DaemonSocket.GetString()
+	if socketName == "" {
+		return errors.New("Client.DaemonSocket is not set")
+	}
+	if err = os.MkdirAll(filepath.Dir(socketName), fs.FileMode(0700)); err != nil {
+		return errors.Wrap(err, "failed to create daemon socket directory")
+	}
+	if err = removeStaleSocket(socketName); err != nil {
+		return err
+	}
+	listener, err := listenDaemonSocket(socketName)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen on daemon socket")
+	}
+	if err = os.Chmod(socketName, 0600); err != nil {
+		listener.Close()
+		return errors.Wrap(err, "failed to restrict access to daemon socket")
+	}
+	servers := []*http.Server{{Handler: td}}
+	listeners := []net.Listener{listener}
+
+	if port := param.Client_DaemonPort.GetInt(); port != 0 {
+		if td.accessKey, err = writeDaemonAccessKey(socketName); err != nil {
+			listener.Close()
+			return err
+		}
+		tcpListener, err := net.Listen("tcp", net.JoinHostPort("localhost", fmt.Sprint(port)))
+		if err != nil {
+			listener.Close()
+			return errors.Wrap(err, "failed to listen on daemon port")
+		}
+		servers = append(servers, &http.Server{Handler: td.requireAccessKey(td)})
+		listeners = append(listeners, tcpListener)
+		log.Infoln("Client transfer daemon listening on", tcpListener.Addr())
+	}
+	log.Infoln("Client transfer daemon listening on", socketName)
+
+	for idx := range servers {
+		srv, ln := servers[idx], listeners[idx]
+		egrp.Go(func() error {
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		})
+	}
+	egrp.Go(func() error {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		for _, srv := range servers {
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				log.Warningln("Failed to shut down the client transfer daemon API:", err)
+			}
+		}
+		if err := te.Shutdown(); err != nil {
+			log.Warningln("Failure when shutting down the daemon's transfer engine:", err)
+		}
+		if err := os.Remove(socketName); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Warningln("Failed to remove daemon socket:", err)
+		}
+		return nil
+	})
+	return nil
+}
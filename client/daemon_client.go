@@ -0,0 +1,208 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, University of Nebraska-Lincoln
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// An error reported by the client transfer daemon for a transfer it ran
+	DaemonTransferError struct {
+		Msg       string
+		Retryable bool
+	}
+)
+
+// How often the status of a submitted transfer is polled
+var daemonPollInterval = 500 * time.Millisecond
+
+func (e *DaemonTransferError) Error() string {
+	return e.Msg
+}
+
+func (e *DaemonTransferError) Is(target error) bool {
+	_, ok := target.(*DaemonTransferError)
+	return ok
+}
+
+// Return an HTTP client whose connections go to the daemon socket
+func newDaemonHttpClient(socketName string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				dialer := net.Dialer{}
+				return dialer.DialContext(ctx, "unix", socketName)
+			},
+		},
+	}
+}
+
+// Send a request to the daemon's REST API, decoding the JSON response into
+// result.  Error responses are returned as errors.
+func daemonRequest(ctx context.Context, httpClient *http.Client, method string, path string, body any, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(bodyBytes)
+	}
+	// The host is ignored as connections go to the socket
+	req, err := http.NewRequestWithContext(ctx, method, "http://localhost"+daemonApiPrefix+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to contact the client transfer daemon")
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the response of the client transfer daemon")
+	}
+	if resp.StatusCode >= 300 {
+		apiResp := server_structs.SimpleApiResp{}
+		if err := json.Unmarshal(respBody, &apiResp); err == nil && apiResp.Msg != "" {
+			return errors.Errorf("client transfer daemon returned %d: %s", resp.StatusCode, apiResp.Msg)
+		}
+		return errors.Errorf("client transfer daemon returned %d", resp.StatusCode)
+	}
+	if result == nil {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(respBody, result), "invalid response from the client transfer daemon")
+}
+
+// Returns true if transfers should be submitted to a client transfer daemon,
+// which is the case when Client.UseDaemon is set and a daemon answers on
+// Client.DaemonSocket
+func DaemonAvailable(ctx context.Context) bool {
+	if !param.Client_UseDaemon.GetBool() {
+		return false
+	}
+	socketName := param.Client_DaemonSocket.GetString()
+	if socketName == "" {
+		return false
+	}
+	if _, err := os.Stat(socketName); err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	var statuses []DaemonTransferStatus
+	if err := daemonRequest(ctx, newDaemonHttpClient(socketName), http.MethodGet, "/transfers", nil, &statuses); err != nil {
+		log.Debugln("Not using the client transfer daemon:", err)
+		return false
+	}
+	return true
+}
+
+// Run a transfer on the client transfer daemon, blocking until it completes.
+//
+// The callback, if given, receives the progress of each object as in
+// WithCallback.  Cancelling ctx cancels the transfer on the daemon.  The
+// results and error follow those of DoGet and DoPut.
+func DoDaemonTransfer(ctx context.Context, request DaemonTransferRequest, callback TransferCallbackFunc) (transferResults []TransferResults, err error) {
+	httpClient := newDaemonHttpClient(param.Client_DaemonSocket.GetString())
+	// Submission isn't interrupted by ctx; otherwise the daemon could start
+	// a transfer whose ID we never learn and thus can't cancel
+	var status DaemonTransferStatus
+	submitCtx, cancelSubmit := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	err = daemonRequest(submitCtx, httpClient, http.MethodPost, "/transfers", request, &status)
+	cancelSubmit()
+	if err != nil {
+		return
+	}
+	log.Debugln("Submitted transfer", status.ID, "to the client transfer daemon")
+
+	reported := make(map[string]DaemonObjectProgress)
+	ticker := time.NewTicker(daemonPollInterval)
+	defer ticker.Stop()
+	for {
+		if callback != nil {
+			for _, progress := range status.Progress {
+				if last, ok := reported[progress.Path]; ok && last == progress {
+					continue
+				}
+				reported[progress.Path] = progress
+				callback(progress.Path, progress.TransferredBytes, progress.TotalBytes, progress.Completed)
+			}
+		}
+		if status.Status != DaemonStatusRunning {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			// Use a fresh context as ours is already done
+			cancelCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := daemonRequest(cancelCtx, httpClient, http.MethodDelete, "/transfers/"+status.ID, nil, nil); err != nil {
+				log.Warningln("Failed to cancel the transfer on the client transfer daemon:", err)
+			}
+			return transferResults, ctx.Err()
+		case <-ticker.C:
+		}
+		if err = daemonRequest(ctx, httpClient, http.MethodGet, "/transfers/"+status.ID, nil, &status); err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			return
+		}
+	}
+
+	for _, objResult := range status.Results {
+		result := TransferResults{
+			Source:           objResult.Source,
+			Destination:      objResult.Destination,
+			TransferredBytes: objResult.TransferredBytes,
+		}
+		if objResult.Error != "" {
+			result.Error = &DaemonTransferError{Msg: objResult.Error, Retryable: objResult.Retryable}
+		}
+		transferResults = append(transferResults, result)
+	}
+	switch status.Status {
+	case DaemonStatusSucceeded:
+		return transferResults, nil
+	case DaemonStatusCancelled:
+		return transferResults, errors.New("the transfer was cancelled on the client transfer daemon")
+	case DaemonStatusFailed:
+		return transferResults, &DaemonTransferError{Msg: status.Error, Retryable: status.Retryable}
+	}
+	return transferResults, errors.Errorf("unexpected transfer status %q from the client transfer daemon", status.Status)
+}
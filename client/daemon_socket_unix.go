@@ -0,0 +1,36 @@
+//go:build !windows
+// +build !windows
+
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"net"
+	"syscall"
+)
+
+// Listen on the daemon's Unix socket.  The socket is created under a restrictive
+// umask, so that other users can't connect to it before its permissions are
+// tightened, even if its directory is accessible to them.
+func listenDaemonSocket(socketName string) (*net.UnixListener, error) {
+	oldMask := syscall.Umask(0077)
+	defer syscall.Umask(oldMask)
+	return net.ListenUnix("unix", &net.UnixAddr{Name: socketName, Net: "unix"})
+}
@@ -0,0 +1,215 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, University of Nebraska-Lincoln
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Serve a transfer daemon with the given transfer function on a socket in a
+// temporary directory, returning the socket's location
+func startTestDaemon(t *testing.T, run daemonRunFunc) string {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	dir, err := os.MkdirTemp("", "daemon")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socketName := filepath.Join(dir, "client.sock")
+	listener, err := net.Listen("unix", socketName)
+	require.NoError(t, err)
+	srv := &http.Server{Handler: newTransferDaemon(ctx, run)}
+	go func() { _ = srv.Serve(listener) }()
+	t.Cleanup(func() { srv.Close() })
+
+	viper.Set("Client.DaemonSocket", socketName)
+	viper.Set("Client.UseDaemon", true)
+	t.Cleanup(viper.Reset)
+	return socketName
+}
+
+func TestDaemonTransferRequestValidate(t *testing.T) {
+	valid := DaemonTransferRequest{Operation: DaemonOperationGet, RemoteUrl: "pelican://fed.example.com/foo", LocalPath: "/tmp/foo"}
+	assert.NoError(t, valid.validate())
+
+	request := valid
+	request.Operation = "copy"
+	assert.ErrorContains(t, request.validate(), "unknown operation")
+
+	request = valid
+	request.LocalPath = "foo"
+	assert.ErrorContains(t, request.validate(), "not absolute")
+
+	request = valid
+	request.LocalPath = StreamPath
+	assert.ErrorContains(t, request.validate(), "missing a local path")
+
+	request = valid
+	request.Checksum = "crc99"
+	assert.Error(t, request.validate())
+
+	request = valid
+	request.Operation = DaemonOperationPut
+	request.SkipExisting = "size"
+	assert.NoError(t, request.validate())
+}
+
+func TestDaemonTransfer(t *testing.T) {
+	startTestDaemon(t, func(ctx context.Context, request DaemonTransferRequest, options ...TransferOption) ([]TransferResults, error) {
+		var callback TransferCallbackFunc
+		for _, option := range options {
+			if option.Ident() == (identTransferOptionCallback{}) {
+				callback = option.Value().(TransferCallbackFunc)
+			}
+		}
+		require.NotNil(t, callback)
+		callback(request.LocalPath, 5, 10, false)
+		callback(request.LocalPath, 10, 10, true)
+		if request.RemoteUrl == "pelican://fed.example.com/missing" {
+			err := errors.New("object not found")
+			return []TransferResults{{Source: request.RemoteUrl, Destination: request.LocalPath, Error: err}}, err
+		}
+		return []TransferResults{{Source: request.RemoteUrl, Destination: request.LocalPath, TransferredBytes: 10}}, nil
+	})
+	ctx := context.Background()
+	require.True(t, DaemonAvailable(ctx))
+
+	t.Run("success", func(t *testing.T) {
+		var progress []int64
+		results, err := DoDaemonTransfer(ctx, DaemonTransferRequest{
+			Operation: DaemonOperationGet,
+			RemoteUrl: "pelican://fed.example.com/foo",
+			LocalPath: "/tmp/foo",
+		}, func(path string, downloaded int64, totalSize int64, completed bool) {
+			assert.Equal(t, "/tmp/foo", path)
+			progress = append(progress, downloaded)
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, int64(10), results[0].TransferredBytes)
+		assert.Equal(t, "pelican://fed.example.com/foo", results[0].Source)
+		assert.Contains(t, progress, int64(10))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		results, err := DoDaemonTransfer(ctx, DaemonTransferRequest{
+			Operation: DaemonOperationGet,
+			RemoteUrl: "pelican://fed.example.com/missing",
+			LocalPath: "/tmp/missing",
+		}, nil)
+		require.Error(t, err)
+		var dte *DaemonTransferError
+		require.True(t, errors.As(err, &dte))
+		assert.False(t, ShouldRetry(err))
+		require.Len(t, results, 1)
+		assert.Error(t, results[0].Error)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := DoDaemonTransfer(ctx, DaemonTransferRequest{Operation: DaemonOperationGet, RemoteUrl: "pelican://fed.example.com/foo", LocalPath: "relative"}, nil)
+		assert.ErrorContains(t, err, "400")
+	})
+}
+
+func TestDaemonTransferCancel(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	socketName := startTestDaemon(t, func(ctx context.Context, request DaemonTransferRequest, options ...TransferOption) ([]TransferResults, error) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err := DoDaemonTransfer(ctx, DaemonTransferRequest{
+		Operation: DaemonOperationPut,
+		RemoteUrl: "pelican://fed.example.com/foo",
+		LocalPath: "/tmp/foo",
+	}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Transfer was not cancelled on the daemon")
+	}
+
+	var statuses []DaemonTransferStatus
+	require.Eventually(t, func() bool {
+		err := daemonRequest(context.Background(), newDaemonHttpClient(socketName), http.MethodGet, "/transfers", nil, &statuses)
+		return err == nil && len(statuses) == 1 && statuses[0].Status == DaemonStatusCancelled
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, DaemonOperationPut, statuses[0].Operation)
+}
+
+func TestDaemonAccessKey(t *testing.T) {
+	td := newTransferDaemon(context.Background(), nil)
+	td.accessKey = "secret"
+	handler := td.requireAccessKey(td)
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost/api/v1.0/client/transfers", nil)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	req.Header.Set("Authorization", "Bearer secreT")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	req.Header.Set("Authorization", "Bearer secret")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestListenDaemonSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix socket permissions are not enforced on Windows")
+	}
+	// Even in a directory other users may enter, they can't connect before the
+	// permissions of the socket are tightened
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0755))
+	socketName := filepath.Join(dir, "client.sock")
+	listener, err := listenDaemonSocket(socketName)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	info, err := os.Stat(socketName)
+	require.NoError(t, err)
+	assert.Zero(t, info.Mode().Perm()&0077, "socket permissions are %v", info.Mode().Perm())
+}
@@ -0,0 +1,380 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/token"
+)
+
+type (
+	// A file of a dataset, as listed in its signed manifest
+	DatasetFile struct {
+		// The path of the file relative to the dataset, with forward slashes
+		Path string `json:"path"`
+		Size int64  `json:"size"`
+		// The checksum of the file as sha256:<hex digest>
+		Checksum string `json:"checksum"`
+	}
+
+	// The list of the files of a dataset, signed by the issuer of the dataset's
+	// namespace so downloads of the dataset can be verified against it.  Signed
+	// manifests are JWTs whose claims carry the dataset and its files.
+	DatasetManifest struct {
+		// The federation URL of the collection holding the dataset, e.g. osdf:///ospool/ap20/data/run42
+		Dataset  string
+		Issuer   string
+		IssuedAt time.Time
+		// The ID of the key that signed the manifest, once it's verified
+		KeyID string
+		Files []DatasetFile
+	}
+
+	// The file-by-file outcome of downloading a dataset against its signed
+	// manifest, to be kept with the results of an analysis as a record of
+	// exactly which data it used
+	ProvenanceReport struct {
+		Dataset  string    `json:"dataset"`
+		Issuer   string    `json:"issuer"`
+		KeyID    string    `json:"key_id,omitempty"`
+		SignedAt time.Time `json:"signed_at"`
+		// The SHA-256 digest of the signed manifest, identifying the exact manifest used
+		ManifestDigest string           `json:"manifest_digest"`
+		VerifiedAt     time.Time        `json:"verified_at"`
+		ClientVersion  string           `json:"client_version"`
+		Verified       bool             `json:"verified"`
+		Files          []ProvenanceFile `json:"files"`
+	}
+
+	ProvenanceFile struct {
+		Path      string `json:"path"`
+		LocalPath string `json:"local_path"`
+		Size      int64  `json:"size"`
+		Checksum  string `json:"checksum"`
+		Verified  bool   `json:"verified"`
+		Error     string `json:"error,omitempty"`
+	}
+)
+
+const (
+	datasetClaim      = "pelican.dataset"
+	datasetFilesClaim = "pelican.files"
+)
+
+// Looks up the public keys of an issuer; replaced in tests
+var datasetIssuerKeys = func(issuer string) (jwk.Set, error) {
+	keys, err := token.GetJWKSFromIssUrl(issuer)
+	if err != nil {
+		return nil, err
+	}
+	return *keys, nil
+}
+
+// Check a file of a dataset manifest can't be written outside the download
+// directory and has a checksum strong enough to detect tampering
+func (file *DatasetFile) validate() error {
+	if file.Path == "" || strings.HasPrefix(file.Path, "/") || path.Clean(file.Path) != file.Path ||
+		file.Path == ".." || strings.HasPrefix(file.Path, "../") || strings.Contains(file.Path, "\\") {
+		return errors.Errorf("invalid path %q in the dataset manifest; paths must be relative to the dataset and not leave it", file.Path)
+	}
+	if file.Size < 0 {
+		return errors.Errorf("invalid size %d for %s in the dataset manifest", file.Size, file.Path)
+	}
+	ct, value, ok := parseManifestChecksum(file.Checksum)
+	if !ok || ct != ChecksumSHA256 {
+		return errors.Errorf("invalid checksum %q for %s in the dataset manifest; expected sha256:<hex digest>", file.Checksum, file.Path)
+	}
+	if decoded, err := hex.DecodeString(value); err != nil || len(decoded) != sha256.Size {
+		return errors.Errorf("invalid checksum %q for %s in the dataset manifest; expected sha256:<hex digest>", file.Checksum, file.Path)
+	}
+	return nil
+}
+
+// The federation URL of a file of the dataset
+func (manifest *DatasetManifest) fileUrl(file DatasetFile) (string, error) {
+	datasetUrl, err := url.Parse(manifest.Dataset)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid dataset URL in the manifest")
+	}
+	datasetUrl.Path = path.Join(datasetUrl.Path, file.Path)
+	return datasetUrl.String(), nil
+}
+
+// List the files under a local copy of a dataset, e.g. on an origin's storage,
+// with their sizes and checksums
+func NewDatasetManifest(dataset string, localDir string) (*DatasetManifest, error) {
+	if _, err := url.Parse(dataset); err != nil {
+		return nil, errors.Wrap(err, "invalid dataset URL")
+	}
+	manifest := &DatasetManifest{Dataset: dataset, Files: []DatasetFile{}}
+	err := filepath.WalkDir(localDir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(localDir, name)
+		if err != nil {
+			return err
+		}
+		sum, err := computeFileChecksum(name, ChecksumSHA256)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, DatasetFile{
+			Path:     filepath.ToSlash(relPath),
+			Size:     info.Size(),
+			Checksum: ChecksumSHA256.String() + ":" + hex.EncodeToString(sum),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the files of the dataset in %s", localDir)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Path < manifest.Files[j].Path })
+	return manifest, nil
+}
+
+// Sign a dataset manifest with the private key of the issuer of the dataset's namespace
+func SignDatasetManifest(manifest *DatasetManifest, key jwk.Key) ([]byte, error) {
+	if manifest.Issuer == "" {
+		return nil, errors.New("the dataset manifest has no issuer")
+	}
+	for idx := range manifest.Files {
+		if err := manifest.Files[idx].validate(); err != nil {
+			return nil, err
+		}
+	}
+	issuedAt := manifest.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = time.Now()
+	}
+	tok, err := jwt.NewBuilder().
+		Issuer(manifest.Issuer).
+		IssuedAt(issuedAt).
+		Claim(datasetClaim, manifest.Dataset).
+		Claim(datasetFilesClaim, manifest.Files).
+		Build()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build the dataset manifest")
+	}
+	if err = jwk.AssignKeyID(key); err != nil {
+		return nil, errors.Wrap(err, "failed to assign a kid to the signing key")
+	}
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, key))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign the dataset manifest")
+	}
+	return signed, nil
+}
+
+// Decode a signed dataset manifest, checking its signature against the keys
+func verifyDatasetManifest(signed []byte, keys jwk.Set) (*DatasetManifest, error) {
+	signed = bytes.TrimSpace(signed)
+	msg, err := jws.Parse(signed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode the dataset manifest")
+	}
+	if len(msg.Signatures()) == 0 {
+		return nil, errors.New("the dataset manifest is not signed")
+	}
+	tok, err := jwt.Parse(signed, jwt.WithKeySet(keys, jws.WithInferAlgorithmFromKey(true)))
+	if err != nil {
+		return nil, errors.Wrap(err, "the signature of the dataset manifest does not match the keys of its issuer")
+	}
+
+	manifest := &DatasetManifest{
+		Issuer:   tok.Issuer(),
+		IssuedAt: tok.IssuedAt(),
+		KeyID:    msg.Signatures()[0].ProtectedHeaders().KeyID(),
+	}
+	dataset, _ := tok.Get(datasetClaim)
+	if manifest.Dataset, _ = dataset.(string); manifest.Dataset == "" {
+		return nil, errors.Errorf("the dataset manifest has no %s claim", datasetClaim)
+	}
+	files, ok := tok.Get(datasetFilesClaim)
+	if !ok {
+		return nil, errors.Errorf("the dataset manifest has no %s claim", datasetFilesClaim)
+	}
+	// The claim is decoded generically; convert it through its JSON form
+	filesJSON, err := json.Marshal(files)
+	if err == nil {
+		err = json.Unmarshal(filesJSON, &manifest.Files)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s claim in the dataset manifest", datasetFilesClaim)
+	}
+	for idx := range manifest.Files {
+		if err := manifest.Files[idx].validate(); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// Check the issuer of a dataset manifest is one the director lists for the
+// dataset's namespace; anyone can sign a manifest with their own issuer
+func checkDatasetIssuer(issuer string, dirResp server_structs.DirectorResponse) error {
+	for _, nsIssuer := range dirResp.XPelAuthHdr.Issuers {
+		if nsIssuer != nil && strings.TrimSuffix(nsIssuer.String(), "/") == strings.TrimSuffix(issuer, "/") {
+			return nil
+		}
+	}
+	return errors.Errorf("the dataset manifest was signed by %s, which is not an issuer of namespace %s", issuer, dirResp.XPelNsHdr.Namespace)
+}
+
+// Decode a signed dataset manifest and verify it was signed by an issuer of
+// the namespace holding the dataset
+func VerifyDatasetManifest(ctx context.Context, signed []byte) (*DatasetManifest, error) {
+	unverified, err := jwt.ParseInsecure(bytes.TrimSpace(signed))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode the dataset manifest")
+	}
+	dataset, _ := unverified.Get(datasetClaim)
+	datasetUrl, _ := dataset.(string)
+	if datasetUrl == "" {
+		return nil, errors.Errorf("the dataset manifest has no %s claim", datasetClaim)
+	}
+	issuer := unverified.Issuer()
+	if issuer == "" {
+		return nil, errors.New("the dataset manifest has no issuer")
+	}
+
+	pUrl, err := ParseRemoteAsPUrl(ctx, datasetUrl)
+	if err != nil {
+		return nil, err
+	}
+	dirResp, err := GetDirectorInfoForPath(ctx, pUrl, http.MethodGet, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDatasetIssuer(issuer, dirResp); err != nil {
+		return nil, err
+	}
+	keys, err := datasetIssuerKeys(issuer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the public keys of issuer %s", issuer)
+	}
+	return verifyDatasetManifest(signed, keys)
+}
+
+// Record the outcome of each file of the dataset, checking the size of the
+// downloads whose checksums matched
+func newProvenanceReport(signed []byte, manifest *DatasetManifest, results []ManifestResult) *ProvenanceReport {
+	digest := sha256.Sum256(bytes.TrimSpace(signed))
+	report := &ProvenanceReport{
+		Dataset:        manifest.Dataset,
+		Issuer:         manifest.Issuer,
+		KeyID:          manifest.KeyID,
+		SignedAt:       manifest.IssuedAt,
+		ManifestDigest: ChecksumSHA256.String() + ":" + hex.EncodeToString(digest[:]),
+		VerifiedAt:     time.Now(),
+		ClientVersion:  config.GetVersion(),
+		Verified:       true,
+		Files:          make([]ProvenanceFile, 0, len(manifest.Files)),
+	}
+	for idx, file := range manifest.Files {
+		entry := ProvenanceFile{Path: file.Path, Size: file.Size, Checksum: file.Checksum}
+		var err error
+		if idx < len(results) && (results[idx].Err != nil || len(results[idx].Transfers) > 0) {
+			entry.LocalPath = results[idx].LocalPath
+			err = results[idx].Err
+		} else {
+			// The download was interrupted before the file's transfer finished
+			err = errors.New("the file was not downloaded")
+		}
+		if err == nil {
+			if info, statErr := os.Stat(entry.LocalPath); statErr != nil {
+				err = statErr
+			} else if info.Size() != file.Size {
+				err = errors.Errorf("size mismatch: the manifest lists %d bytes but the download has %d", file.Size, info.Size())
+			}
+		}
+		if err != nil {
+			entry.Error = err.Error()
+			report.Verified = false
+		} else {
+			entry.Verified = true
+		}
+		report.Files = append(report.Files, entry)
+	}
+	return report
+}
+
+// Download a dataset into destDir, verifying its signed manifest against the
+// keys of the issuer of the dataset's namespace and each file against the
+// manifest.  The report records the outcome of every file; it's returned along
+// with the error if only some of the files were verified.
+func DoDatasetGet(ctx context.Context, signed []byte, destDir string, options ...TransferOption) (report *ProvenanceReport, err error) {
+	manifest, err := VerifyDatasetManifest(ctx, signed)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Verified the manifest of dataset %s, signed by %s, listing %d files", manifest.Dataset, manifest.Issuer, len(manifest.Files))
+
+	entries := make([]ManifestEntry, 0, len(manifest.Files))
+	for idx, file := range manifest.Files {
+		source, err := manifest.fileUrl(file)
+		if err != nil {
+			return nil, err
+		}
+		ct, value, _ := parseManifestChecksum(file.Checksum)
+		entries = append(entries, ManifestEntry{
+			Source:       source,
+			Destination:  filepath.FromSlash(file.Path),
+			ChecksumType: ct,
+			Checksum:     value,
+			Line:         idx + 1,
+		})
+	}
+	results, err := DoManifestGet(ctx, entries, destDir, options...)
+	report = newProvenanceReport(signed, manifest, results)
+	if err == nil && !report.Verified {
+		err = errors.Errorf("the downloaded files of dataset %s do not match its manifest", manifest.Dataset)
+	}
+	return report, err
+}
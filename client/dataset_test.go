@@ -0,0 +1,146 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// Generate an issuer signing key and the public key set to verify with
+func newDatasetSigningKey(t *testing.T) (jwk.Key, jwk.Set) {
+	raw, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	key, err := jwk.FromRaw(raw)
+	require.NoError(t, err)
+	require.NoError(t, jwk.AssignKeyID(key))
+	pubKey, err := key.PublicKey()
+	require.NoError(t, err)
+	require.NoError(t, pubKey.Set(jwk.AlgorithmKey, jwa.ES256))
+	keys := jwk.NewSet()
+	require.NoError(t, keys.AddKey(pubKey))
+	return key, keys
+}
+
+func TestDatasetManifest(t *testing.T) {
+	dataDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDir, "run1"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "run1", "events.dat"), []byte("events"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "README"), []byte("readme"), 0644))
+
+	manifest, err := NewDatasetManifest("osdf:///ospool/data/run42", dataDir)
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 2)
+	readmeSum := sha256.Sum256([]byte("readme"))
+	assert.Equal(t, DatasetFile{Path: "README", Size: 6, Checksum: "sha256:" + hex.EncodeToString(readmeSum[:])}, manifest.Files[0])
+	assert.Equal(t, "run1/events.dat", manifest.Files[1].Path)
+	assert.Equal(t, int64(6), manifest.Files[1].Size)
+
+	key, keys := newDatasetSigningKey(t)
+	manifest.Issuer = "https://origin.example.org:8443"
+	manifest.IssuedAt = time.Now().Truncate(time.Second)
+	signed, err := SignDatasetManifest(manifest, key)
+	require.NoError(t, err)
+
+	verified, err := verifyDatasetManifest(signed, keys)
+	require.NoError(t, err)
+	assert.Equal(t, manifest.Dataset, verified.Dataset)
+	assert.Equal(t, manifest.Issuer, verified.Issuer)
+	assert.True(t, manifest.IssuedAt.Equal(verified.IssuedAt))
+	assert.Equal(t, manifest.Files, verified.Files)
+	assert.Equal(t, key.KeyID(), verified.KeyID)
+
+	fileUrl, err := verified.fileUrl(verified.Files[1])
+	require.NoError(t, err)
+	assert.Equal(t, "osdf:///ospool/data/run42/run1/events.dat", fileUrl)
+
+	// A manifest signed by another key is rejected
+	_, otherKeys := newDatasetSigningKey(t)
+	_, err = verifyDatasetManifest(signed, otherKeys)
+	assert.ErrorContains(t, err, "signature")
+
+	// As are files escaping the dataset or without a SHA-256 checksum
+	for _, file := range []DatasetFile{
+		{Path: "../escape", Checksum: manifest.Files[0].Checksum},
+		{Path: "/etc/passwd", Checksum: manifest.Files[0].Checksum},
+		{Path: "a/./b", Checksum: manifest.Files[0].Checksum},
+		{Path: "weak", Checksum: "md5:d41d8cd98f00b204e9800998ecf8427e"},
+	} {
+		_, err = SignDatasetManifest(&DatasetManifest{Dataset: manifest.Dataset, Issuer: manifest.Issuer, Files: []DatasetFile{file}}, key)
+		assert.Error(t, err, file.Path)
+	}
+}
+
+func TestCheckDatasetIssuer(t *testing.T) {
+	issuer, err := url.Parse("https://origin.example.org:8443/")
+	require.NoError(t, err)
+	dirResp := server_structs.DirectorResponse{
+		XPelAuthHdr: server_structs.XPelAuth{Issuers: []*url.URL{issuer}},
+		XPelNsHdr:   server_structs.XPelNs{Namespace: "/ospool/data"},
+	}
+	assert.NoError(t, checkDatasetIssuer("https://origin.example.org:8443", dirResp))
+	assert.ErrorContains(t, checkDatasetIssuer("https://attacker.example.org", dirResp), "not an issuer of namespace /ospool/data")
+}
+
+func TestProvenanceReport(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good")
+	short := filepath.Join(dir, "short")
+	require.NoError(t, os.WriteFile(good, []byte("good"), 0644))
+	require.NoError(t, os.WriteFile(short, []byte("sh"), 0644))
+	manifest := &DatasetManifest{
+		Dataset: "osdf:///ospool/data/run42",
+		Issuer:  "https://origin.example.org:8443",
+		Files: []DatasetFile{
+			{Path: "good", Size: 4, Checksum: "sha256:aa"},
+			{Path: "short", Size: 5, Checksum: "sha256:bb"},
+			{Path: "missing", Size: 1, Checksum: "sha256:cc"},
+		},
+	}
+	results := []ManifestResult{
+		{LocalPath: good, Transfers: []TransferResults{{}}},
+		{LocalPath: short, Transfers: []TransferResults{{}}},
+		// The download was interrupted before this file was transferred
+		{LocalPath: filepath.Join(dir, "missing")},
+	}
+
+	report := newProvenanceReport([]byte("signed"), manifest, results)
+	assert.False(t, report.Verified)
+	digest := sha256.Sum256([]byte("signed"))
+	assert.Equal(t, "sha256:"+hex.EncodeToString(digest[:]), report.ManifestDigest)
+	require.Len(t, report.Files, 3)
+	assert.True(t, report.Files[0].Verified)
+	assert.Contains(t, report.Files[1].Error, "size mismatch")
+	assert.Contains(t, report.Files[2].Error, "not downloaded")
+}
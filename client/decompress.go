@@ -0,0 +1,129 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Decompresses a download on the fly if the server reports the object is stored compressed.
+//
+// Whether the object is compressed is decided from the headers of the first response: a
+// gzip Content-Encoding, or a ".gz" object whose Content-Type is gzip.  Other objects are
+// written through unchanged.  The bytes written to the decompressor are those of the stored
+// object, so they can still be verified against the checksum the server reports.
+type decompressWriter struct {
+	w          io.Writer
+	objectPath string
+	decided    bool
+	pipe       *io.PipeWriter
+	done       chan error
+	closed     bool
+}
+
+func newDecompressWriter(w io.Writer, objectPath string) *decompressWriter {
+	return &decompressWriter{w: w, objectPath: objectPath}
+}
+
+// Whether the response carries an object stored with gzip compression
+func isGzipResponse(resp *http.Response, objectPath string) bool {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		return true
+	case "", "identity":
+	default:
+		return false
+	}
+	if !strings.HasSuffix(strings.ToLower(objectPath), ".gz") {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && (mediaType == "application/gzip" || mediaType == "application/x-gzip")
+}
+
+// Inspect the response of a download attempt.  The first response decides whether the
+// object is decompressed; later attempts continue the same stream.
+func (dw *decompressWriter) start(resp *http.Response) {
+	if dw.decided {
+		return
+	}
+	dw.decided = true
+	if encoding := resp.Header.Get("Content-Encoding"); !isGzipResponse(resp, dw.objectPath) {
+		if encoding != "" && !strings.EqualFold(encoding, "identity") {
+			log.Warningf("Not decompressing %s; the %s content encoding is not supported", dw.objectPath, encoding)
+		}
+		return
+	}
+	log.Debugln("Decompressing", dw.objectPath, "as it is downloaded")
+	pr, pw := io.Pipe()
+	dw.pipe = pw
+	dw.done = make(chan error, 1)
+	go func() {
+		gz, err := gzip.NewReader(pr)
+		if err == nil {
+			_, err = io.Copy(dw.w, gz)
+		}
+		if err != nil {
+			err = errors.Wrapf(err, "failed to decompress %s", dw.objectPath)
+		}
+		// Unblock the writer if decompression stopped early
+		pr.CloseWithError(err)
+		dw.done <- err
+	}()
+}
+
+// Whether the object is being decompressed
+func (dw *decompressWriter) decompressing() bool {
+	return dw.pipe != nil
+}
+
+func (dw *decompressWriter) Write(p []byte) (int, error) {
+	if dw.pipe == nil {
+		return dw.w.Write(p)
+	}
+	return dw.pipe.Write(p)
+}
+
+// Wait for the decompressed object to be written out.  Fails if the compressed object
+// was truncated or corrupt.
+func (dw *decompressWriter) Close() error {
+	if dw.pipe == nil || dw.closed {
+		return nil
+	}
+	dw.closed = true
+	dw.pipe.Close()
+	return <-dw.done
+}
+
+// Stop decompressing after a failed download
+func (dw *decompressWriter) abort() {
+	if dw.pipe == nil || dw.closed {
+		return
+	}
+	dw.closed = true
+	dw.pipe.CloseWithError(errors.New("the download of the object failed"))
+	<-dw.done
+}
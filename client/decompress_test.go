@@ -0,0 +1,179 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/test_utils"
+)
+
+func TestIsGzipResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		path        string
+		encoding    string
+		contentType string
+		expected    bool
+	}{
+		{"content-encoding", "/data.bin", "gzip", "application/octet-stream", true},
+		{"x-gzip-encoding", "/data.bin", "X-Gzip", "", true},
+		{"gz-suffix-with-type", "/data.csv.gz", "", "application/gzip", true},
+		{"gz-suffix-with-parameters", "/data.csv.gz", "identity", "application/x-gzip; charset=binary", true},
+		{"gz-suffix-without-type", "/data.csv.gz", "", "application/octet-stream", false},
+		{"gzip-type-without-suffix", "/data.csv", "", "application/gzip", false},
+		{"other-encoding", "/data.csv.gz", "br", "application/gzip", false},
+		{"plain", "/data.csv", "", "text/csv", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tc.encoding != "" {
+				resp.Header.Set("Content-Encoding", tc.encoding)
+			}
+			resp.Header.Set("Content-Type", tc.contentType)
+			assert.Equal(t, tc.expected, isGzipResponse(resp, tc.path))
+		})
+	}
+}
+
+func TestDecompressDownload(t *testing.T) {
+	ctx, _, _ := test_utils.TestContext(context.Background(), t)
+	test_utils.InitClient(t, map[string]any{})
+
+	contents := make([]byte, 256*1024)
+	_, err := rand.Read(contents[:1024])
+	require.NoError(t, err)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err = gz.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	// Serve the compressed object as stored, recording the headers of each request
+	var mutex sync.Mutex
+	var ranges, encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		encodings = append(encodings, r.Header.Get("Accept-Encoding"))
+		mutex.Unlock()
+		switch r.URL.Path {
+		case "/data.bin":
+			w.Header().Set("Content-Encoding", "gzip")
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(compressed.Bytes()))
+		case "/truncated.bin":
+			w.Header().Set("Content-Encoding", "gzip")
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(compressed.Bytes()[:compressed.Len()/2]))
+		default:
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(contents))
+		}
+	}))
+	defer server.Close()
+	reset := func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		ranges, encodings = nil, nil
+	}
+	attemptFor := func(objectPath string, stream *streamWriter) transferAttemptDetails {
+		serverUrl, err := url.Parse(server.URL)
+		require.NoError(t, err)
+		serverUrl.Path = objectPath
+		return transferAttemptDetails{Url: serverUrl, Stream: stream}
+	}
+
+	t.Run("decompresses-and-hashes-stored-object", func(t *testing.T) {
+		reset()
+		var output bytes.Buffer
+		decompressor := newDecompressWriter(&output, "/data.bin")
+		stream := newStreamWriter(decompressor, ChecksumMD5)
+		downloaded, _, _, _, _, err := downloadHTTP(ctx, nil, nil, attemptFor("/data.bin", stream), "-", -1, "", "")
+		require.NoError(t, err)
+		require.NoError(t, decompressor.Close())
+		assert.True(t, decompressor.decompressing())
+		assert.Equal(t, int64(compressed.Len()), downloaded)
+		assert.Equal(t, contents, output.Bytes())
+		expected := md5.Sum(compressed.Bytes())
+		assert.Equal(t, expected[:], stream.sum())
+		mutex.Lock()
+		require.NotEmpty(t, encodings)
+		assert.Equal(t, "gzip", encodings[0])
+		mutex.Unlock()
+	})
+
+	t.Run("continuation-fetches-full-object", func(t *testing.T) {
+		reset()
+		var output bytes.Buffer
+		decompressor := newDecompressWriter(&output, "/data.bin")
+		stream := newStreamWriter(decompressor, ChecksumNone)
+		// An earlier attempt delivered the start of the compressed object
+		resp := &http.Response{Header: http.Header{"Content-Encoding": []string{"gzip"}}}
+		decompressor.start(resp)
+		stream.startAttempt(false)
+		_, err := stream.Write(compressed.Bytes()[:1000])
+		require.NoError(t, err)
+
+		_, _, _, _, _, err = downloadHTTP(ctx, nil, nil, attemptFor("/data.bin", stream), "-", -1, "", "")
+		require.NoError(t, err)
+		require.NoError(t, decompressor.Close())
+		assert.Equal(t, contents, output.Bytes())
+		mutex.Lock()
+		assert.NotEmpty(t, ranges)
+		for _, rangeHeader := range ranges {
+			assert.Empty(t, rangeHeader)
+		}
+		mutex.Unlock()
+	})
+
+	t.Run("uncompressed-object-unchanged", func(t *testing.T) {
+		reset()
+		var output bytes.Buffer
+		decompressor := newDecompressWriter(&output, "/plain.bin")
+		stream := newStreamWriter(decompressor, ChecksumNone)
+		_, _, _, _, _, err := downloadHTTP(ctx, nil, nil, attemptFor("/plain.bin", stream), "-", -1, "", "")
+		require.NoError(t, err)
+		require.NoError(t, decompressor.Close())
+		assert.False(t, decompressor.decompressing())
+		assert.Equal(t, contents, output.Bytes())
+	})
+
+	t.Run("truncated-object-fails", func(t *testing.T) {
+		reset()
+		var output bytes.Buffer
+		decompressor := newDecompressWriter(&output, "/truncated.bin")
+		stream := newStreamWriter(decompressor, ChecksumNone)
+		_, _, _, _, _, err := downloadHTTP(ctx, nil, nil, attemptFor("/truncated.bin", stream), "-", -1, "", "")
+		require.NoError(t, err)
+		err = decompressor.Close()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to decompress /truncated.bin")
+	})
+}
@@ -22,6 +22,7 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"sort"
@@ -32,11 +33,15 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/pelican_url"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/utils"
 )
 
+// The maximum number of times a director query follows a delegation to a sub-director
+const maxDirectorDelegations = 2
+
 // Make a request to the director for a given verb/resource; return the
 // HTTP response object only if a 307 is returned.
 func queryDirector(ctx context.Context, verb string, pUrl *pelican_url.PelicanURL, token string) (resp *http.Response, err error) {
@@ -55,32 +60,53 @@ func queryDirector(ctx context.Context, verb string, pUrl *pelican_url.PelicanUR
 	tr := config.GetTransport()
 	client = &http.Client{
 		Transport: tr,
+		Timeout:   param.Client_DirectorTimeout.GetDuration(),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
 	}
 
-	req, err := http.NewRequestWithContext(ctx, verb, resourceUrl.String(), nil)
-	if err != nil {
-		log.Errorln("Failed to create an HTTP request:", err)
-		return nil, err
-	}
+	for hops := 0; ; hops++ {
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, verb, resourceUrl.String(), nil)
+		if err != nil {
+			log.Errorln("Failed to create an HTTP request:", err)
+			return nil, err
+		}
 
-	// Include the Client's version as a User-Agent header. The Director will decide
-	// if it supports the version, and provide an error message in the case that it
-	// cannot.
-	req.Header.Set("User-Agent", getUserAgent(""))
+		// Include the Client's version as a User-Agent header. The Director will decide
+		// if it supports the version, and provide an error message in the case that it
+		// cannot.
+		req.Header.Set("User-Agent", getUserAgent(""))
 
-	if token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
-	}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
 
-	// Perform the HTTP request
-	resp, err = client.Do(req)
+		// Perform the HTTP request
+		resp, err = client.Do(req)
 
-	if err != nil {
-		log.Errorln("Failed to get response from the director:", err)
-		return
+		if err != nil {
+			log.Errorln("Failed to get response from the director:", err)
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				err = &TimeoutError{Phase: TimeoutPhaseDirector, Timeout: client.Timeout, Err: err}
+			}
+			return
+		}
+
+		// The director may delegate the request to a regional sub-director; if so,
+		// repeat the query against the sub-director.
+		if resp.StatusCode != http.StatusTemporaryRedirect || resp.Header.Get(server_structs.SubDirectorHeader) == "" || hops >= maxDirectorDelegations {
+			break
+		}
+		location, locErr := resp.Location()
+		resp.Body.Close()
+		if locErr != nil {
+			return resp, errors.Wrap(locErr, "failed to parse the sub-director location from the director response")
+		}
+		log.Debugln("Director delegated the request to sub-director", resp.Header.Get(server_structs.SubDirectorHeader))
+		resourceUrl = location
 	}
 
 	defer resp.Body.Close()
@@ -236,10 +262,21 @@ func ParseDirectorInfo(dirResp *http.Response) (server_structs.DirectorResponse,
 		return server_structs.DirectorResponse{}, errors.Wrap(err, "failed to determine object servers from Director's response")
 	}
 
+	// Only sent for uploads to origins that accept chunked uploads
+	var chunkedUploadUrl *url.URL
+	if raw := dirResp.Header.Get(server_structs.ChunkedUploadHeader); raw != "" {
+		if parsed, err := url.Parse(raw); err == nil && parsed.Host != "" {
+			chunkedUploadUrl = parsed
+		} else {
+			log.Warningf("Ignoring the invalid %s header %q from the director", server_structs.ChunkedUploadHeader, raw)
+		}
+	}
+
 	return server_structs.DirectorResponse{
-		ObjectServers: sortedObjectServers,
-		XPelAuthHdr:   xPelAuth,
-		XPelNsHdr:     xPelNs,
-		XPelTokGenHdr: xPelTokGen,
+		ObjectServers:    sortedObjectServers,
+		XPelAuthHdr:      xPelAuth,
+		XPelNsHdr:        xPelNs,
+		XPelTokGenHdr:    xPelTokGen,
+		ChunkedUploadUrl: chunkedUploadUrl,
 	}, nil
 }
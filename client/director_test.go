@@ -59,6 +59,7 @@ func TestParseDirectorInfo(t *testing.T) {
 	directorHeaders["X-Pelican-Namespace"] = []string{"namespace=/foo/bar, require-token=True, collections-url=https://my-collections.com"}
 	directorHeaders["X-Pelican-Authorization"] = []string{"issuer=https://get-your-tokens.org, issuer=https://get-your-tokens2.org"}
 	directorHeaders["X-Pelican-Token-Generation"] = []string{"issuer=https://get-your-tokens.org, base-path=/foo/bar, max-scope-depth=2, strategy=OAuth2"}
+	directorHeaders[server_structs.ChunkedUploadHeader] = []string{"https://my-origin.edu:8444"}
 	directorBody := []byte(`{"key": "value"}`)
 
 	directorResponse := &http.Response{
@@ -83,6 +84,10 @@ func TestParseDirectorInfo(t *testing.T) {
 	assert.Equal(t, uint(2), parsed.XPelTokGenHdr.MaxScopeDepth)
 	assert.Equal(t, server_structs.OAuthStrategy, parsed.XPelTokGenHdr.Strategy)
 
+	if assert.NotNil(t, parsed.ChunkedUploadUrl) {
+		assert.Equal(t, "https://my-origin.edu:8444", parsed.ChunkedUploadUrl.String())
+	}
+
 	// Test the old version of parsing the issuer from the director to ensure backwards compatibility with a V1 client and a V2 director
 	var xPelicanAuthorization map[string]string
 	var issuer string
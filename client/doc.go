@@ -0,0 +1,45 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+// Package client implements the Pelican client: locating objects in a federation,
+// acquiring tokens, and transferring objects to and from origins and caches.
+//
+// Programs embedding Pelican transfers create a [TransferEngine] once and reuse it
+// for many transfers.  The client configuration must be initialized first (for
+// example, with [github.com/pelicanplatform/pelican/config.InitClient]):
+//
+//	engine, err := client.NewTransferEngine(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	defer engine.Shutdown()
+//
+//	results, err := engine.Get(ctx, "pelican://osg-htc.org/ospool/uc-shared/public/file.txt", "/tmp/file.txt",
+//		client.WithCallback(func(path string, downloaded int64, totalSize int64, completed bool) {
+//			fmt.Printf("%s: %d of %d bytes\n", path, downloaded, totalSize)
+//		}),
+//	)
+//
+// [TransferEngine.Get] and [TransferEngine.Put] block until the transfer completes
+// or the context is cancelled; transfers are customized with functional options such
+// as [WithToken], [WithRecursive], [WithCaches], and [WithChecksum].  Small objects,
+// such as configuration files, can be read straight into memory with
+// [TransferEngine.GetBytes], which always verifies their checksum.  Programs that
+// need to run many transfers concurrently can instead create a [TransferClient] with
+// [TransferEngine.NewClient] and submit jobs created by [TransferClient.NewTransferJob].
+package client
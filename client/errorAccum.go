@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -42,8 +43,32 @@ type (
 		start  time.Time
 		errors []error
 	}
+
+	// The broad category of a transfer error, which determines whether the
+	// transfer is worth retrying
+	ErrorClass int
+)
+
+const (
+	// The failure may not recur against another endpoint or on a later attempt
+	ErrorClassRetryable ErrorClass = iota
+	// Retrying will not help without changing the request
+	ErrorClassFatal
+	// The server refused the request's credentials; a retry needs a different token
+	ErrorClassAuth
 )
 
+func (class ErrorClass) String() string {
+	switch class {
+	case ErrorClassRetryable:
+		return "retryable"
+	case ErrorClassAuth:
+		return "auth"
+	default:
+		return "fatal"
+	}
+}
+
 func (te *TimestampedError) Error() string {
 	return te.err.Error()
 }
@@ -93,7 +118,27 @@ func (te *TransferErrors) Error() string {
 	for idx, err := range te.errors {
 		errors[idx] = err.Error()
 	}
-	return "transfer errors: [" + strings.Join(errors, ", ") + "]"
+	return "transfer errors: [" + strings.Join(errors, ", ") + "]" + te.endpointsSuffix()
+}
+
+// The endpoints the failed transfer attempts were made against, in the order they were tried
+func (te *TransferErrors) Endpoints() []string {
+	endpoints := make([]string, 0, len(te.errors))
+	for _, err := range te.errors {
+		var tae *TransferAttemptError
+		if errors.As(err, &tae) && tae.serviceHost != "" && !slices.Contains(endpoints, tae.serviceHost) {
+			endpoints = append(endpoints, tae.serviceHost)
+		}
+	}
+	return endpoints
+}
+
+// Summarize the endpoints attempted when there was more than one
+func (te *TransferErrors) endpointsSuffix() string {
+	if endpoints := te.Endpoints(); len(endpoints) > 1 {
+		return " (endpoints attempted: " + strings.Join(endpoints, ", ") + ")"
+	}
+	return ""
 }
 
 // Return a more refined, user-friendly error string
@@ -132,7 +177,65 @@ func (te *TransferErrors) UserError() string {
 		toReturn += errorsFormatted[idx]
 		first = false
 	}
-	return toReturn
+	return toReturn + te.endpointsSuffix()
+}
+
+// Report whether a server response status indicates a transient failure that
+// another endpoint, or a later attempt, may not repeat
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// The HTTP status code a server responded to a transfer with, or 0 if the
+// error isn't due to a server response
+func errorStatusCode(err error) int {
+	var busy *ServerBusyError
+	if errors.As(err, &busy) {
+		return busy.StatusCode
+	}
+	var sce *StatusCodeError
+	if errors.As(err, &sce) {
+		return int(*sce)
+	}
+	var gsce grab.StatusCodeError
+	if errors.As(err, &gsce) {
+		return int(gsce)
+	}
+	var hep *HttpErrResp
+	if errors.As(err, &hep) {
+		return hep.Code
+	}
+	return 0
+}
+
+// Classify a transfer error as retryable, fatal, or an authorization failure
+func ClassifyError(err error) ErrorClass {
+	if code := errorStatusCode(err); code == http.StatusUnauthorized || code == http.StatusForbidden {
+		return ErrorClassAuth
+	}
+	if ShouldRetry(err) {
+		return ErrorClassRetryable
+	}
+	return ErrorClassFatal
+}
+
+// Report whether a download that failed against one endpoint should be attempted
+// against the next.  Authorization failures and other client errors that the server
+// answered definitively would be repeated by every endpoint, so are not retried.
+func shouldFailover(err error) bool {
+	switch ClassifyError(err) {
+	case ErrorClassRetryable:
+		return true
+	case ErrorClassAuth:
+		return false
+	}
+	code := errorStatusCode(err)
+	return code < 400 || code >= 500
 }
 
 // IsRetryable will return true if the error is retryable
@@ -158,6 +261,10 @@ func IsRetryable(err error) bool {
 	if errors.Is(err, &allocateMemoryError{}) {
 		return true
 	}
+	// A corrupt copy may have come from a bad cache; a retry may use another
+	if errors.Is(err, &ChecksumMismatchError{}) {
+		return true
+	}
 	if errors.Is(err, &dirListingNotSupportedError{}) {
 		// false because we cannot automatically retry, the user must change the url to use a different origin/namespace
 		// that enables dirlistings or the admin must enable dirlistings on the origin/namespace
@@ -166,32 +273,23 @@ func IsRetryable(err error) bool {
 	if errors.Is(err, &HeaderTimeoutError{}) {
 		return true
 	}
+	if errors.Is(err, &TimeoutError{}) {
+		return true
+	}
+	// The daemon classified the error when it ran the transfer
+	var dte *DaemonTransferError
+	if errors.As(err, &dte) {
+		return dte.Retryable
+	}
 	var cse *ConnectionSetupError
 	if errors.As(err, &cse) {
 		if sce, ok := cse.Unwrap().(grab.StatusCodeError); ok {
-			switch int(sce) {
-			case http.StatusInternalServerError:
-			case http.StatusBadGateway:
-			case http.StatusServiceUnavailable:
-			case http.StatusGatewayTimeout:
-				return true
-			default:
-				return false
-			}
+			return isRetryableStatus(int(sce))
 		}
 		return true
 	}
-	var hep *HttpErrResp
-	if errors.As(err, &hep) {
-		switch int(hep.Code) {
-		case http.StatusInternalServerError:
-		case http.StatusBadGateway:
-		case http.StatusServiceUnavailable:
-		case http.StatusGatewayTimeout:
-			return true
-		default:
-			return false
-		}
+	if code := errorStatusCode(err); code != 0 {
+		return isRetryableStatus(code)
 	}
 
 	// If we have a timeout error, we are retryable
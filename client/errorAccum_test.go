@@ -20,6 +20,7 @@ package client
 
 import (
 	"errors"
+	"net/http"
 	"net/url"
 	"testing"
 
@@ -100,3 +101,46 @@ func TestErrorsRetryableTrue(t *testing.T) {
 	te.resetErrors()
 
 }
+
+func TestClassifyError(t *testing.T) {
+	attemptErr := func(code int) error {
+		sce := StatusCodeError(code)
+		return newTransferAttemptError("cache.example.com", "", false, false, &sce)
+	}
+
+	for _, code := range []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusTooManyRequests} {
+		assert.Equal(t, ErrorClassRetryable, ClassifyError(attemptErr(code)), "status %d", code)
+		assert.True(t, shouldFailover(attemptErr(code)), "status %d", code)
+	}
+	assert.Equal(t, ErrorClassRetryable, ClassifyError(&HttpErrResp{Code: http.StatusServiceUnavailable}))
+	assert.Equal(t, ErrorClassRetryable, ClassifyError(&timeoutError{msg: "timeout"}))
+
+	assert.Equal(t, ErrorClassAuth, ClassifyError(attemptErr(http.StatusForbidden)))
+	assert.Equal(t, ErrorClassAuth, ClassifyError(attemptErr(http.StatusUnauthorized)))
+	assert.False(t, shouldFailover(attemptErr(http.StatusForbidden)))
+
+	assert.Equal(t, ErrorClassFatal, ClassifyError(attemptErr(http.StatusNotFound)))
+	assert.False(t, shouldFailover(attemptErr(http.StatusNotFound)))
+	// Failures without a server response may be specific to the endpoint
+	assert.Equal(t, ErrorClassFatal, ClassifyError(errors.New("connection refused")))
+	assert.True(t, shouldFailover(errors.New("connection refused")))
+
+	assert.Equal(t, "retryable", ErrorClassRetryable.String())
+	assert.Equal(t, "fatal", ErrorClassFatal.String())
+	assert.Equal(t, "auth", ErrorClassAuth.String())
+}
+
+func TestTransferErrorsEndpoints(t *testing.T) {
+	sce := StatusCodeError(http.StatusServiceUnavailable)
+	te := NewTransferErrors()
+	te.AddError(newTransferAttemptError("cache1.example.com", "", false, false, &sce))
+	assert.Equal(t, []string{"cache1.example.com"}, te.Endpoints())
+	assert.NotContains(t, te.UserError(), "endpoints attempted")
+
+	te.AddError(newTransferAttemptError("cache2.example.com", "", false, false, &sce))
+	te.AddError(errors.New("not tied to an endpoint"))
+	assert.Equal(t, []string{"cache1.example.com", "cache2.example.com"}, te.Endpoints())
+	assert.Contains(t, te.UserError(), "(endpoints attempted: cache1.example.com, cache2.example.com)")
+	assert.Contains(t, te.Error(), "(endpoints attempted: cache1.example.com, cache2.example.com)")
+	assert.Equal(t, ErrorClassFatal, ClassifyError(te))
+}
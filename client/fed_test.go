@@ -32,6 +32,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -158,6 +159,46 @@ func TestGetAndPutAuth(t *testing.T) {
 		}
 	})
 
+	// This tests the library API for embedding transfers in other programs
+	t.Run("testTransferEnginePutAndGet", func(t *testing.T) {
+		engine, err := client.NewTransferEngine(fed.Ctx)
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, engine.Shutdown())
+		}()
+
+		for _, export := range fed.Exports {
+			uploadURL := fmt.Sprintf("pelican://%s%s/engine/%s", discoveryUrl.Host,
+				export.FederationPrefix, filepath.Base(tempFile.Name()))
+			putResults, err := engine.Put(fed.Ctx, tempFile.Name(), uploadURL, client.WithTokenLocation(tempToken.Name()))
+			require.NoError(t, err)
+			require.Len(t, putResults, 1)
+			assert.Equal(t, int64(17), putResults[0].TransferredBytes)
+
+			var finalSize atomic.Int64
+			callback := func(path string, downloaded int64, totalSize int64, completed bool) {
+				if completed {
+					finalSize.Store(downloaded)
+				}
+			}
+			destDir := t.TempDir()
+			getResults, err := engine.Get(fed.Ctx, uploadURL, destDir, client.WithTokenLocation(tempToken.Name()), client.WithCallback(callback))
+			require.NoError(t, err)
+			require.Len(t, getResults, 1)
+			assert.Equal(t, int64(17), getResults[0].TransferredBytes)
+			assert.Equal(t, int64(17), finalSize.Load())
+			contents, err := os.ReadFile(filepath.Join(destDir, filepath.Base(tempFile.Name())))
+			require.NoError(t, err)
+			assert.Equal(t, testFileContent, string(contents))
+
+			// A cancelled context aborts the transfer with the context's error
+			ctx, cancel := context.WithCancel(fed.Ctx)
+			cancel()
+			_, err = engine.Get(ctx, uploadURL, t.TempDir(), client.WithTokenLocation(tempToken.Name()))
+			assert.ErrorIs(t, err, context.Canceled)
+		}
+	})
+
 	t.Run("testPelicanObjectPutAndGetWithQueryAndDestDir", func(t *testing.T) {
 		oldPref, err := config.SetPreferredPrefix(config.PelicanPrefix)
 		defer func() {
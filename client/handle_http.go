@@ -22,6 +22,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
 	"net"
@@ -30,6 +31,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"slices"
@@ -132,19 +134,24 @@ type (
 		TransferredBytes  int64
 		TransferStartTime time.Time
 		Scheme            string
+		CompatLevel       string // The release whose client behavior was reproduced, if any
+		Source            string // Where the object was transferred from: a local path or a federation URL
+		Destination       string // Where the object was transferred to
 		Attempts          []TransferResult
 	}
 
 	TransferResult struct {
-		Number            int           // indicates which attempt this is
-		TransferFileBytes int64         // how much each attempt downloaded
-		TimeToFirstByte   time.Duration // how long it took to download the first byte
-		TransferEndTime   time.Time     // when the transfer ends
-		TransferTime      time.Duration // amount of time we were transferring per attempt (in seconds)
-		CacheAge          time.Duration // age of the data reported by the cache
-		Endpoint          string        // which origin did it use
-		ServerVersion     string        // version of the server
-		Error             error         // what error the attempt returned (if any)
+		Number            int              // indicates which attempt this is
+		TransferFileBytes int64            // how much each attempt downloaded
+		TimeToFirstByte   time.Duration    // how long it took to download the first byte
+		TransferEndTime   time.Time        // when the transfer ends
+		TransferTime      time.Duration    // amount of time we were transferring per attempt (in seconds)
+		CacheAge          time.Duration    // age of the data reported by the cache
+		Endpoint          string           // which origin did it use
+		ServerVersion     string           // version of the server
+		Validators        ObjectValidators // the ETag and Last-Modified of the object, as reported by the server
+		BusyWaitTime      time.Duration    // how long the attempt waited for a busy server to free a transfer slot
+		Error             error            // what error the attempt returned (if any)
 	}
 
 	clientTransferResults struct {
@@ -160,6 +167,10 @@ type (
 		// Proxy specifies if a proxy should be used
 		Proxy bool
 
+		// Discovery URL of the object's federation, which selects the proxy
+		// policy (see Client.ProxyOverrides)
+		Federation string
+
 		// If the Url scheme is unix, this is the path to connect to
 		UnixSocket string
 
@@ -171,6 +182,12 @@ type (
 
 		// Whether or not the cache has been queried
 		CacheQuery bool
+
+		// Whether a partial local copy of the object may be resumed with a ranged request
+		Resume bool
+
+		// If set, the download is written to this stream instead of a local file
+		Stream *streamWriter
 	}
 
 	// A structure representing a single file to transfer.
@@ -187,6 +204,12 @@ type (
 		attempts   []transferAttemptDetails
 		project    string
 		err        error
+		// Modification time of the remote object, if known from a collection listing;
+		// applied to the local copy once a download completes.
+		remoteModTime time.Time
+		// Directory of a recursive upload the file was queued from, tracked by the
+		// job's journal so that completed subtrees are skipped on resume
+		journalDir *journalDir
 	}
 
 	// A representation of a "transfer job".  The job
@@ -204,17 +227,25 @@ type (
 		lookupDone     atomic.Bool
 		lookupErr      error
 		activeXfer     atomic.Int64
+		failedXfer     atomic.Int64
 		totalXfer      int
 		localPath      string
 		upload         bool
 		recursive      bool
 		skipAcquire    bool
-		syncLevel      SyncLevel  // Policy for handling synchronization when the destination exists
-		prefObjServers []*url.URL // holds any client-requested caches/origins
+		syncLevel      SyncLevel     // Policy for handling synchronization when the destination exists
+		resume         bool          // Whether partial downloads may be resumed
+		checksumType   ChecksumType  // Checksum used to verify transferred objects, if any
+		decompress     bool          // Whether objects stored compressed are decompressed as they're downloaded
+		prefObjServers []*url.URL    // holds any client-requested caches/origins
+		memory         *memoryBuffer // If set, the object is downloaded into memory rather than to localPath
 		dirResp        server_structs.DirectorResponse
 		directorUrl    string
 		token          *tokenGenerator
+		deniedToken    *tokenGenerator // Token generator used if a server denies an anonymous read
 		project        string
+		journal        *transferJournal // Journal of completed objects for resuming recursive transfers
+		compat         CompatLevel      // Release whose client behavior is reproduced, if any
 	}
 
 	// A TransferJob associated with a client's request
@@ -254,6 +285,7 @@ type (
 		ewmaCtr         atomic.Int64
 		clientLock      sync.RWMutex
 		pelicanUrlCache *pelican_url.Cache
+		maxRate         *rate.Limiter // Limit on the combined rate of all transfers; nil if unlimited
 	}
 
 	TransferCallbackFunc = func(path string, downloaded int64, totalSize int64, completed bool)
@@ -265,10 +297,14 @@ type (
 		cancel         context.CancelFunc
 		callback       TransferCallbackFunc
 		engine         *TransferEngine
-		skipAcquire    bool      // Enable/disable the token acquisition logic.  Defaults to acquiring a token
-		syncLevel      SyncLevel // Policy for the client to synchronize data
-		tokenLocation  string    // Location of a token file to use for transfers
-		token          string    // Token that should be used for transfers
+		skipAcquire    bool         // Enable/disable the token acquisition logic.  Defaults to acquiring a token
+		syncLevel      SyncLevel    // Policy for the client to synchronize data
+		tokenLocation  string       // Location of a token file to use for transfers
+		token          string       // Token that should be used for transfers
+		journalPath    string       // Location of the journal used to resume recursive transfers
+		resume         bool         // Whether partial downloads may be resumed
+		checksumType   ChecksumType // Checksum used to verify transferred objects, if any
+		decompress     bool         // Whether objects stored compressed are decompressed as they're downloaded
 		work           chan *TransferJob
 		closed         bool
 		prefObjServers []*url.URL // holds any client-requested caches/origins
@@ -284,10 +320,19 @@ type (
 	identTransferOptionAcquireToken  struct{}
 	identTransferOptionToken         struct{}
 	identTransferOptionSynchronize   struct{}
+	identTransferOptionResumeJournal struct{}
+	identTransferOptionResume        struct{}
+	identTransferOptionChecksum      struct{}
+	identTransferOptionRecursive     struct{}
+	identTransferOptionListChecksums struct{}
+	identTransferOptionThirdParty    struct{}
+	identTransferOptionSyncDelete    struct{}
+	identTransferOptionDecompress    struct{}
 
 	transferDetailsOptions struct {
 		NeedsToken bool
 		PackOption string
+		Federation string
 	}
 )
 
@@ -297,9 +342,11 @@ const (
 	projectName classAd = "ProjectName"
 	jobId       classAd = "GlobalJobId"
 
-	SyncNone  = iota // When synchronizing, always re-transfer, regardless of existence at destination.
-	SyncExist        // Skip synchronization transfer if the destination exists
-	SyncSize         // Skip synchronization transfer if the destination exists and matches the current source size
+	SyncNone     = iota // When synchronizing, always re-transfer, regardless of existence at destination.
+	SyncExist           // Skip synchronization transfer if the destination exists
+	SyncSize            // Skip synchronization transfer if the destination exists and matches the current source size
+	SyncMtime           // Skip synchronization transfer if the destination matches the source size and is not older than the source
+	SyncChecksum        // Skip synchronization transfer if the destination matches the source size and checksum (see PlanSync)
 )
 
 // The progress container object creates several
@@ -404,15 +451,22 @@ func (e *SlowTransferError) Is(target error) bool {
 	return ok
 }
 
-// Determines whether or not we can interact with the site HTTP proxy
-func isProxyEnabled() bool {
-	if _, isSet := os.LookupEnv("http_proxy"); !isSet {
-		return false
-	}
+// Determines whether or not we can interact with a proxy for the URL scheme
+// when talking to the federation
+func isProxyEnabled(federation string, scheme string) bool {
 	if param.Client_DisableHttpProxy.GetBool() {
 		return false
 	}
-	return true
+	return config.FederationProxyConfigured(federation, scheme)
+}
+
+// Return the proxy function for a transport used by the transfer attempt
+func transferProxy(transfer transferAttemptDetails) func(*http.Request) (*url.URL, error) {
+	// Proxies make no sense when reading via a Unix socket
+	if !transfer.Proxy || transfer.Url.Scheme == "unix" {
+		return nil
+	}
+	return config.ProxyForFederation(transfer.Federation)
 }
 
 // Determine whether we are allowed to skip the proxy as a fallback
@@ -528,9 +582,10 @@ func hasPort(host string) bool {
 // Create a new transfer results object
 func newTransferResults(job *TransferJob) TransferResults {
 	return TransferResults{
-		job:      job,
-		jobId:    job.uuid,
-		Attempts: make([]TransferResult, 0),
+		job:         job,
+		jobId:       job.uuid,
+		CompatLevel: job.compat.String(),
+		Attempts:    make([]TransferResult, 0),
 	}
 }
 
@@ -546,6 +601,10 @@ func NewTransferEngine(ctx context.Context) (te *TransferEngine, err error) {
 	if !config.IsClientInitialized() {
 		return nil, errors.New("client has not been initialized, unable to create transfer engine")
 	}
+	maxRate, err := ParseMaxRate(param.Client_MaxRate.GetString())
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Client.MaxRate")
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
 	egrp, _ := errgroup.WithContext(ctx)
@@ -572,6 +631,7 @@ func NewTransferEngine(ctx context.Context) (te *TransferEngine, err error) {
 		ewmaTick:        time.NewTicker(ewmaInterval),
 		ewma:            ewma.NewMovingAverage(),
 		pelicanUrlCache: pelicanUrlCache,
+		maxRate:         newMaxRateLimiter(maxRate),
 	}
 	workerCount := param.Client_WorkerCount.GetInt()
 	if workerCount <= 0 {
@@ -634,6 +694,83 @@ func WithSynchronize(level SyncLevel) TransferOption {
 	return option.New(identTransferOptionSynchronize{}, level)
 }
 
+// Create an option to resume recursive transfers using a journal file
+//
+// As each object in a recursive transfer completes, it is recorded in the
+// journal.  If the transfer is interrupted, repeating it with the same journal
+// skips the objects that were already transferred.  The journal is removed
+// once the transfer completes without errors.
+func WithResumeJournal(location string) TransferOption {
+	return option.New(identTransferOptionResumeJournal{}, location)
+}
+
+// Create an option to resume interrupted downloads
+//
+// If enabled, the ETag (or Last-Modified time) of an object being downloaded is
+// recorded next to the destination until the download completes.  If a partial
+// copy of an object exists at the destination and the recorded validator still
+// matches the remote object, only the remainder of the object is downloaded;
+// otherwise, the download starts over.  Defaults to false.
+func WithResume(enable bool) TransferOption {
+	return option.New(identTransferOptionResume{}, enable)
+}
+
+// Create an option to verify transferred objects with the given checksum type
+//
+// Downloads are compared against the checksum reported by the server (via the
+// Want-Digest header); uploads send the checksum of the local file in a Digest
+// header and then verify the checksum the server computed for the new object.
+// The transfer fails if the checksums do not match or the server does not report
+// one.  Defaults to ChecksumNone, which disables verification.
+func WithChecksum(checksumType ChecksumType) TransferOption {
+	return option.New(identTransferOptionChecksum{}, checksumType)
+}
+
+// Create an option to delete extraneous objects when synchronizing
+//
+// Only used by PlanSync.  If enabled, objects present at the destination but
+// not at the source are removed from the destination.  Defaults to false.
+func WithSyncDelete(enable bool) TransferOption {
+	return option.New(identTransferOptionSyncDelete{}, enable)
+}
+
+// Create an option to transfer a collection and all of its contents
+//
+// This is equivalent to passing recursive=true to NewTransferJob or adding
+// the `recursive` query parameter to the remote URL.
+func WithRecursive(enable bool) TransferOption {
+	return option.New(identTransferOptionRecursive{}, enable)
+}
+
+// Create an option to include the checksums of each object in a listing
+//
+// Only used by DoList.  Checksums are retrieved with one HEAD request per object,
+// so this is considerably slower than a plain listing of a large collection.
+func WithListChecksums(enable bool) TransferOption {
+	return option.New(identTransferOptionListChecksums{}, enable)
+}
+
+// Create an option to control whether remote-to-remote copies may use HTTP-TPC
+//
+// When enabled (the default), DoCopy first asks the destination server to pull
+// the object directly from the source server as a third-party copy.  If that is
+// disabled or fails, the object is streamed through the client instead.
+func WithThirdPartyCopy(enable bool) TransferOption {
+	return option.New(identTransferOptionThirdParty{}, enable)
+}
+
+// Create an option to decompress objects stored compressed as they are downloaded
+//
+// An object is decompressed if the server sends it with a gzip Content-Encoding, or
+// if its name ends in ".gz" and its Content-Type is gzip; other objects are written
+// unchanged.  Checksums are verified against the compressed object.  Since ranges
+// of a compressed object can't be decompressed on their own, a partial local copy
+// is never resumed and a failed attempt fetches the whole object again from the
+// next endpoint.  Defaults to false.
+func WithDecompress(enable bool) TransferOption {
+	return option.New(identTransferOptionDecompress{}, enable)
+}
+
 // Create a new client to work with an engine
 func (te *TransferEngine) NewClient(options ...TransferOption) (client *TransferClient, err error) {
 	log.Debugln("Making new clients")
@@ -664,6 +801,14 @@ func (te *TransferEngine) NewClient(options ...TransferOption) (client *Transfer
 			client.token = option.Value().(string)
 		case identTransferOptionSynchronize{}:
 			client.syncLevel = option.Value().(SyncLevel)
+		case identTransferOptionResumeJournal{}:
+			client.journalPath = option.Value().(string)
+		case identTransferOptionResume{}:
+			client.resume = option.Value().(bool)
+		case identTransferOptionChecksum{}:
+			client.checksumType = option.Value().(ChecksumType)
+		case identTransferOptionDecompress{}:
+			client.decompress = option.Value().(bool)
 		}
 	}
 	func() {
@@ -709,6 +854,7 @@ func (te *TransferEngine) Close() {
 
 // If we've detected a job is done, clean up the active job state map
 func (te *TransferEngine) finishJob(activeJobs *map[uuid.UUID][]*TransferJob, job *TransferJob, id uuid.UUID) {
+	job.journal.close(job.lookupErr == nil && job.failedXfer.Load() == 0)
 	if len((*activeJobs)[id]) == 1 {
 		log.Debugln("Job", job.ID(), "is done for client", id.String(), "which has no active jobs remaining")
 		// Delete the job from the list of active jobs
@@ -915,6 +1061,7 @@ func (te *TransferEngine) runMux() error {
 			// If no transfers were created or we have an error, the job is no
 			// longer active
 			if job.job.lookupErr != nil || job.job.totalXfer == 0 {
+				job.job.journal.close(job.job.lookupErr == nil)
 				// Remove this job from the list of active jobs for the client.
 				activeJobs[job.uuid] = slices.DeleteFunc(activeJobs[job.uuid], func(oldJob *TransferJob) bool {
 					return oldJob.uuid == job.job.uuid
@@ -1009,11 +1156,14 @@ func (tc *TransferClient) NewTransferJob(ctx context.Context, remoteUrl *url.URL
 	tj = &TransferJob{
 		prefObjServers: tc.prefObjServers,
 		recursive:      recursive,
-		localPath:      localPath,
+		localPath:      absLocalPath(localPath),
 		remoteURL:      &copyUrl,
 		callback:       tc.callback,
 		skipAcquire:    tc.skipAcquire,
 		syncLevel:      tc.syncLevel,
+		resume:         tc.resume,
+		checksumType:   tc.checksumType,
+		decompress:     tc.decompress,
 		upload:         upload,
 		uuid:           id,
 		project:        project,
@@ -1039,6 +1189,7 @@ func (tc *TransferClient) NewTransferJob(ctx context.Context, remoteUrl *url.URL
 
 	tj.ctx, tj.cancel = mergeCancel(ctx, tc.ctx)
 
+	journalPath := tc.journalPath
 	for _, option := range options {
 		switch option.Ident() {
 		case identTransferOptionCaches{}:
@@ -1053,6 +1204,36 @@ func (tc *TransferClient) NewTransferJob(ctx context.Context, remoteUrl *url.URL
 			tj.token.SetToken(option.Value().(string))
 		case identTransferOptionSynchronize{}:
 			tj.syncLevel = option.Value().(SyncLevel)
+		case identTransferOptionResumeJournal{}:
+			journalPath = option.Value().(string)
+		case identTransferOptionResume{}:
+			tj.resume = option.Value().(bool)
+		case identTransferOptionChecksum{}:
+			tj.checksumType = option.Value().(ChecksumType)
+		case identTransferOptionDecompress{}:
+			tj.decompress = option.Value().(bool)
+		case identTransferOptionRecursive{}:
+			if option.Value().(bool) {
+				tj.recursive = true
+			}
+		}
+	}
+
+	if tj.compat, err = getCompatLevel(); err != nil {
+		return nil, err
+	}
+	if tj.compat.IsSet() {
+		log.Debugf("Reproducing the behavior of client version %s; disabled features: %v", tj.compat, tj.compat.DisabledFeatures())
+		if copyUrl.Query().Get("pack") != "" && !tj.compat.Allows(FeaturePacking) {
+			return nil, errors.Errorf("the pack option is not available at compatibility level %s", tj.compat)
+		}
+		if !tj.compat.Allows(FeatureResume) {
+			tj.resume = false
+			journalPath = ""
+		}
+		if tj.checksumType != ChecksumNone && !tj.compat.Allows(FeatureChecksumVerification) {
+			log.Warningf("Checksum verification is not available at compatibility level %s; objects will not be verified", tj.compat)
+			tj.checksumType = ChecksumNone
 		}
 	}
 
@@ -1089,6 +1270,9 @@ func (tc *TransferClient) NewTransferJob(ctx context.Context, remoteUrl *url.URL
 			tj.token.DirResp = &dirResp
 		}
 	} else {
+		// Reads from the namespace don't require a token, but keep the generator in
+		// case a server denies the anonymous request anyway
+		tj.deniedToken = tj.token
 		tj.token = nil
 	}
 
@@ -1101,6 +1285,12 @@ func (tc *TransferClient) NewTransferJob(ctx context.Context, remoteUrl *url.URL
 		}
 	}
 
+	if recursive && journalPath != "" {
+		if tj.journal, err = openTransferJournal(journalPath); err != nil {
+			return nil, err
+		}
+	}
+
 	log.Debugf("Created new transfer job, ID %s client %s, for URL %s", tj.uuid.String(), tc.id.String(), copyUrl.String())
 	return
 }
@@ -1227,29 +1417,46 @@ func generateTransferDetails(remoteOServer string, opts transferDetailsOptions)
 		if remoteUrl.Scheme != "unix" {
 			remoteUrl.Scheme = "https"
 		}
-		det := transferAttemptDetails{
+		if remoteUrl.Scheme == "unix" {
+			details = append(details, transferAttemptDetails{
+				Url:        remoteUrl,
+				UnixSocket: remoteUrl.Path,
+				PackOption: opts.PackOption,
+				Federation: opts.Federation,
+			})
+			return details
+		}
+		isProxyEnabled := isProxyEnabled(opts.Federation, remoteUrl.Scheme)
+		details = append(details, transferAttemptDetails{
 			Url:        remoteUrl,
-			Proxy:      false,
+			Proxy:      isProxyEnabled,
 			PackOption: opts.PackOption,
+			Federation: opts.Federation,
+		})
+		// Whether port is specified or not, fall back to a transfer without proxy
+		if isProxyEnabled && CanDisableProxy() {
+			details = append(details, transferAttemptDetails{
+				Url:        remoteUrl,
+				Proxy:      false,
+				PackOption: opts.PackOption,
+				Federation: opts.Federation,
+			})
 		}
-		if remoteUrl.Scheme == "unix" {
-			det.UnixSocket = remoteUrl.Path
-		}
-		// Whether port is specified or not, add a transfer without proxy
-		details = append(details, det)
 	} else if remoteUrl.Scheme == "" || remoteUrl.Scheme == "http" {
 		remoteUrl.Scheme = "http"
-		isProxyEnabled := isProxyEnabled()
+		isProxyEnabled := isProxyEnabled(opts.Federation, remoteUrl.Scheme)
 		details = append(details, transferAttemptDetails{
 			Url:        remoteUrl,
 			Proxy:      isProxyEnabled,
 			PackOption: opts.PackOption,
+			Federation: opts.Federation,
 		})
 		if isProxyEnabled && CanDisableProxy() {
 			details = append(details, transferAttemptDetails{
 				Url:        remoteUrl,
 				Proxy:      false,
 				PackOption: opts.PackOption,
+				Federation: opts.Federation,
 			})
 		}
 	} else {
@@ -1257,6 +1464,7 @@ func generateTransferDetails(remoteOServer string, opts transferDetailsOptions)
 			Url:        remoteUrl,
 			Proxy:      false,
 			PackOption: opts.PackOption,
+			Federation: opts.Federation,
 		}
 		if remoteUrl.Scheme == "unix" {
 			det.UnixSocket = remoteUrl.Path
@@ -1273,6 +1481,10 @@ func getObjectServersToTry(sortedObjectServers []string, job *TransferJob, oServ
 	oServersListed := 0
 	oServerList := make(map[string]bool)
 	oServers := make([]string, 0)
+	federation := ""
+	if job.remoteURL != nil {
+		federation = job.remoteURL.FedInfo.DiscoveryEndpoint
+	}
 
 	for _, oServer := range sortedObjectServers {
 		if oServersListed == oServersToTry {
@@ -1288,6 +1500,7 @@ func getObjectServersToTry(sortedObjectServers []string, job *TransferJob, oServ
 			td := transferDetailsOptions{
 				NeedsToken: job.dirResp.XPelNsHdr.RequireToken,
 				PackOption: packOption,
+				Federation: federation,
 			}
 			transfers = append(transfers, generateTransferDetails(oServer, td)...)
 		}
@@ -1322,9 +1535,12 @@ func (te *TransferEngine) createTransferFiles(job *clientTransferJob) (err error
 			err = errors.New("No origins found for upload")
 			return
 		}
+		federation := job.job.remoteURL.FedInfo.DiscoveryEndpoint
 		transfers = append(transfers, transferAttemptDetails{
 			Url:        job.job.dirResp.ObjectServers[0],
+			Proxy:      isProxyEnabled(federation, "https"),
 			PackOption: packOption,
+			Federation: federation,
 		})
 	} else {
 		var sortedServers []*url.URL
@@ -1355,7 +1571,7 @@ func (te *TransferEngine) createTransferFiles(job *clientTransferJob) (err error
 
 	if job.job.recursive {
 		if job.job.upload {
-			return te.walkDirUpload(job, transfers, te.files, job.job.localPath)
+			return te.walkDirUpload(job, transfers, te.files, job.job.localPath, nil)
 		} else {
 			return te.walkDirDownload(job, transfers, te.files, remoteUrl)
 		}
@@ -1405,6 +1621,9 @@ func runTransferWorker(ctx context.Context, workChan <-chan *clientTransferFile,
 				}
 			}
 			if file.file.ctx.Err() == context.Canceled {
+				if file.file.job != nil {
+					file.file.job.failedXfer.Add(1)
+				}
 				results <- &clientTransferResults{
 					id: file.uuid,
 					results: TransferResults{
@@ -1415,6 +1634,9 @@ func runTransferWorker(ctx context.Context, workChan <-chan *clientTransferFile,
 				break
 			}
 			if file.file.err != nil {
+				if file.file.job != nil {
+					file.file.job.failedXfer.Add(1)
+				}
 				results <- &clientTransferResults{
 					id: file.uuid,
 					results: TransferResults{
@@ -1430,6 +1652,14 @@ func runTransferWorker(ctx context.Context, workChan <-chan *clientTransferFile,
 				transferResults, err = uploadObject(file.file)
 			} else {
 				transferResults, err = downloadObject(file.file)
+				if err == nil && authorizeDeniedDownload(file.file, transferResults) {
+					deniedResults := transferResults
+					transferResults, err = downloadObject(file.file)
+					for idx := range transferResults.Attempts {
+						transferResults.Attempts[idx].Number += len(deniedResults.Attempts)
+					}
+					transferResults.Attempts = append(deniedResults.Attempts, transferResults.Attempts...)
+				}
 			}
 			transferResults.jobId = file.jobId
 			transferResults.Scheme = file.file.remoteURL.Scheme
@@ -1439,11 +1669,75 @@ func runTransferWorker(ctx context.Context, workChan <-chan *clientTransferFile,
 				transferResults.Scheme = file.file.remoteURL.Scheme
 				transferResults.Error = err
 			}
+			if file.file.upload {
+				transferResults.Source, transferResults.Destination = file.file.localPath, file.file.remoteURL.String()
+			} else {
+				transferResults.Source, transferResults.Destination = file.file.remoteURL.String(), file.file.localPath
+			}
+			if transferResults.Error != nil {
+				if file.file.job != nil {
+					file.file.job.failedXfer.Add(1)
+				}
+			} else {
+				finalizeTransferFile(file.file)
+			}
 			results <- &clientTransferResults{id: file.uuid, results: transferResults}
 		}
 	}
 }
 
+// Report whether an anonymous download was denied by every server it was attempted
+// against
+func downloadDenied(results TransferResults) bool {
+	if results.Error == nil || len(results.Attempts) == 0 {
+		return false
+	}
+	for _, attempt := range results.Attempts {
+		var sce *StatusCodeError
+		if !errors.As(attempt.Error, &sce) || (int(*sce) != http.StatusUnauthorized && int(*sce) != http.StatusForbidden) {
+			return false
+		}
+	}
+	return true
+}
+
+// If an anonymous download was denied, find or acquire a token (possibly through
+// the OAuth2 device flow) so the download can be retried with it.
+//
+// Returns true if the transfer now has a token and should be retried.
+func authorizeDeniedDownload(file *transferFile, results TransferResults) bool {
+	if file.token != nil || file.job == nil || file.job.deniedToken == nil || !downloadDenied(results) {
+		return false
+	}
+	log.Infoln("Anonymous download of", file.remoteURL.Path, "was denied; retrying with a token")
+	if _, err := file.job.deniedToken.get(); err != nil {
+		log.Warningln("Unable to find or acquire a token after the download was denied:", err)
+		return false
+	}
+	file.token = file.job.deniedToken
+	return true
+}
+
+// Once a file has been transferred successfully, preserve the remote modification
+// time on downloads and record the object in the job's resume journal (if any).
+func finalizeTransferFile(file *transferFile) {
+	if isStreamPath(file.localPath) || IsObjectStorageURL(file.localPath) {
+		return
+	}
+	if !file.upload && !file.remoteModTime.IsZero() && (file.job == nil || file.job.compat.Allows(FeaturePreserveMtime)) {
+		if err := os.Chtimes(file.localPath, file.remoteModTime, file.remoteModTime); err != nil {
+			log.Warningln("Failed to set the modification time of", file.localPath, ":", err)
+		}
+	}
+	if file.job != nil {
+		if err := file.job.journal.record(file.remoteURL.Path, file.localPath); err != nil {
+			log.Warningln("Failed to record", file.remoteURL.Path, "in the transfer journal:", err)
+		} else {
+			file.job.journal.completeInDir(file.journalDir)
+		}
+	}
+}
+
 // If there are multiple potential attempts, try to see if we can quickly eliminate some of them
 //
 // Attempts a HEAD against all the endpoints simultaneously.  Put any that don't respond within
@@ -1606,14 +1900,91 @@ func sortAttempts(ctx context.Context, path string, attempts []transferAttemptDe
 // create the destination directory).
 func downloadObject(transfer *transferFile) (transferResults TransferResults, err error) {
 	log.Debugln("Downloading object from", transfer.remoteURL, "to", transfer.localPath)
-	// Remove the source from the file path
-	directory := path.Dir(transfer.localPath)
 	var downloaded int64
-	if err = os.MkdirAll(directory, 0700); err != nil {
+	var stream *streamWriter
+	memory := transfer.job.memory
+	if memory != nil {
+		if transfer.packOption != "" {
+			err = errors.New("downloads with the pack option cannot be made to memory")
+			return
+		}
+		// The checksum is computed from the buffer once the download completes
+		stream = newStreamWriter(memory, ChecksumNone)
+	} else if isStreamPath(transfer.localPath) {
+		if transfer.packOption != "" {
+			err = errors.New("downloads with the pack option cannot be streamed to standard output")
+			return
+		}
+		stream = newStreamWriter(streamOutput, transfer.job.checksumType)
+	} else if IsObjectStorageURL(transfer.localPath) {
+		if transfer.packOption != "" {
+			err = errors.New("downloads with the pack option cannot be written to object storage")
+			return
+		}
+	} else if err = os.MkdirAll(filepath.Dir(transfer.localPath), 0700); err != nil {
 		return
 	}
 
 	size, attempts := sortAttempts(transfer.job.ctx, transfer.remoteURL.Path, transfer.attempts, transfer.token)
+	if memory != nil && size > memory.maxSize {
+		err = &ObjectTooLargeError{MaxSize: memory.maxSize}
+		return
+	}
+	if IsObjectStorageURL(transfer.localPath) {
+		// The object is streamed into a multipart upload, which is only completed once
+		// the download succeeds
+		var storageWriter *objectStorageWriter
+		if storageWriter, err = newObjectStorageWriter(transfer.ctx, transfer.localPath, size); err != nil {
+			return
+		}
+		defer func() {
+			if err != nil || transferResults.Error != nil {
+				storageWriter.abort(errors.New("the download of the object failed"))
+			} else if closeErr := storageWriter.Close(); closeErr != nil {
+				transferResults.Error = closeErr
+			}
+		}()
+		stream = newStreamWriter(storageWriter, transfer.job.checksumType)
+	}
+	// A decompressed object is written through a stream, even to a local file, since it
+	// can't be resumed or striped: a range of the compressed object can't be decompressed
+	// on its own.  The stream hashes the compressed bytes for checksum verification.
+	var decompressor *decompressWriter
+	if transfer.job.decompress && transfer.packOption == "" {
+		if memory != nil {
+			err = errors.New("downloads to memory cannot be decompressed")
+			return
+		}
+		if stream != nil {
+			decompressor = newDecompressWriter(stream.w, transfer.remoteURL.Path)
+			stream.w = decompressor
+		} else {
+			if transfer.job.resume && partialDownloadSize(transfer.localPath, size) > 0 {
+				log.Warningf("Not resuming the partial download of %s; the object may need to be decompressed, so all of it is fetched", transfer.localPath)
+			}
+			var fp *os.File
+			if fp, err = os.OpenFile(transfer.localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+				return
+			}
+			defer fp.Close()
+			decompressor = newDecompressWriter(fp, transfer.remoteURL.Path)
+			stream = newStreamWriter(decompressor, transfer.job.checksumType)
+			defer func() {
+				// A partly decompressed object can't be resumed later
+				if err != nil || transferResults.Error != nil {
+					removeCorruptDownload(transfer.localPath)
+				}
+			}()
+		}
+		defer decompressor.abort()
+	}
+	resume := transfer.job.resume && transfer.packOption == "" && stream == nil
+	// Downloads to memory are always verified
+	verifyChecksum := (transfer.job.checksumType != ChecksumNone || memory != nil) && transfer.packOption == ""
+	for idx := range attempts {
+		attempts[idx].Resume = resume
+		attempts[idx].Stream = stream
+	}
 
 	transferResults = newTransferResults(transfer.job)
 	xferErrors := NewTransferErrors()
@@ -1621,6 +1992,57 @@ func downloadObject(transfer *transferFile) (transferResults TransferResults, er
 	// transferStartTime is the start time of the last transfer attempt
 	// we create a var here and update it in the loop
 	var transferStartTime time.Time
+
+	// If several sources are available for a large object, try striping the download
+	// across them first; on failure, fall back to downloading from one source at a time.
+	// A striped download always starts over, so it is skipped if there is a partial
+	// download to resume.
+	if stream == nil && transfer.job.compat.Allows(FeatureMultiSource) && useStripedDownload(attempts, size, transfer.packOption) && !(resume && partialDownloadSize(transfer.localPath, size) > 0) {
+		fields := log.Fields{
+			"url": transfer.remoteURL.String(),
+			"job": transfer.job.ID(),
+		}
+		ctx := context.WithValue(transfer.ctx, logFields("fields"), fields)
+		transferStartTime = time.Now()
+		tokenContents := ""
+		if transfer.token != nil {
+			tokenContents, _ = transfer.token.get()
+		}
+		stripeDownloaded, sourceResults, stripeErr := downloadStriped(
+			ctx, transfer.engine, transfer.callback, attempts, transfer.remoteURL.Path, transfer.localPath, size, tokenContents, transfer.project,
+		)
+		endTime := time.Now()
+		for _, sourceResult := range sourceResults {
+			attempt := TransferResult{
+				Number:            len(transferResults.Attempts),
+				TransferFileBytes: sourceResult.Bytes,
+				TimeToFirstByte:   sourceResult.TimeToFirstByte,
+				TransferEndTime:   endTime,
+				TransferTime:      endTime.Sub(transferStartTime),
+				CacheAge:          -1,
+				Endpoint:          sourceResult.Endpoint,
+			}
+			if sourceResult.Error != nil {
+				attempt.Error = newTransferAttemptError(sourceResult.Endpoint, "", false, false, sourceResult.Error)
+			}
+			transferResults.Attempts = append(transferResults.Attempts, attempt)
+		}
+		if stripeErr == nil && verifyChecksum {
+			if stripeErr = verifyDownloadChecksum(ctx, attempts[0], transfer.remoteURL.Path, transfer.localPath, transfer.job.checksumType, tokenContents, transfer.project); stripeErr != nil {
+				removeCorruptDownload(transfer.localPath)
+			}
+		}
+		if stripeErr == nil {
+			transferResults.TransferStartTime = transferStartTime
+			transferResults.TransferredBytes = stripeDownloaded
+			return
+		}
+		log.WithFields(fields).Warningln("Striped download failed; falling back to single-source download:", stripeErr)
+		// The fallback downloads the object again from the start, so the bytes of the
+		// stripes are only reported in their attempts, not in the transferred bytes
+		xferErrors.AddPastError(stripeErr, endTime)
+	}
+
 	for idx, transferEndpoint := range attempts { // For each transfer attempt (usually 3), try to download via HTTP
 		var attempt TransferResult
 		attempt.CacheAge = -1
@@ -1640,13 +2062,71 @@ func downloadObject(transfer *transferFile) (transferResults TransferResults, er
 		}
 		ctx := context.WithValue(transfer.ctx, logFields("fields"), fields)
 		transferStartTime = time.Now() // Update start time for this attempt
-		tokenContents := ""
-		if transfer.token != nil {
-			tokenContents, _ = transfer.token.get()
+		var tokenContents string
+		var attemptDownloaded int64
+		var timeToFirstByte, cacheAge time.Duration
+		var serverVersion string
+		var validators ObjectValidators
+		var err error
+		// A busy server is asked again, for a bounded time, before failing over; its
+		// queue is likely shorter than the time it takes to warm up another cache
+		busyDeadline := transferStartTime.Add(param.Client_BusyRetryTimeout.GetDuration())
+		for busyRetries := 0; ; busyRetries++ {
+			tokenContents = ""
+			if transfer.token != nil {
+				tokenContents, _ = transfer.token.get()
+			}
+			attemptDownloaded, timeToFirstByte, cacheAge, serverVersion, validators, err = downloadHTTP(
+				ctx, transfer.engine, transfer.callback, transferEndpoint, transfer.localPath, size, tokenContents, transfer.project,
+			)
+			var busy *ServerBusyError
+			if !errors.As(err, &busy) {
+				break
+			}
+			remaining := time.Until(busyDeadline)
+			if remaining <= 0 || busy.RetryAfter > remaining {
+				log.WithFields(fields).Debugf("Giving up on busy server %s after waiting %s", attempt.Endpoint, attempt.BusyWaitTime.Round(time.Millisecond))
+				break
+			}
+			delay := busyRetryDelay(busy, busyRetries, remaining)
+			log.WithFields(fields).Infof("Server %s is busy; retrying in %s", attempt.Endpoint, delay.Round(time.Millisecond))
+			waitStart := time.Now()
+			waitErr := waitForBusyServer(ctx, delay)
+			attempt.BusyWaitTime += time.Since(waitStart)
+			if waitErr != nil {
+				err = waitErr
+				break
+			}
+		}
+		streamCorrupt := false
+		if err == nil && decompressor != nil {
+			// The decompressor can't pick up a corrupt stream from another endpoint
+			if err = decompressor.Close(); err != nil {
+				log.WithFields(fields).Errorln("Decompression failed:", err)
+				streamCorrupt = true
+			}
+		}
+		if err == nil && verifyChecksum && memory != nil {
+			// Nothing has been handed to the caller yet, so a corrupt copy is discarded
+			// and the next endpoint tried
+			if err = verifyMemoryChecksum(ctx, transferEndpoint, transfer.remoteURL.Path, transfer.job.checksumType, memory.Bytes(), tokenContents, transfer.project); err != nil {
+				log.WithFields(fields).Errorln("Checksum verification failed:", err)
+				stream.reset()
+			}
+		} else if err == nil && verifyChecksum && stream != nil {
+			// The corrupt data has already been written to the stream, so there is no
+			// point in trying another endpoint
+			if err = verifyStreamChecksum(ctx, transferEndpoint, transfer.remoteURL.Path, transfer.job.checksumType, stream.sum(), tokenContents, transfer.project); err != nil {
+				log.WithFields(fields).Errorln("Checksum verification failed:", err)
+				streamCorrupt = true
+			}
+		} else if err == nil && verifyChecksum {
+			// A corrupt copy is treated like a failed attempt so the next endpoint is tried
+			if err = verifyDownloadChecksum(ctx, transferEndpoint, transfer.remoteURL.Path, transfer.localPath, transfer.job.checksumType, tokenContents, transfer.project); err != nil {
+				log.WithFields(fields).Errorln("Checksum verification failed:", err)
+				removeCorruptDownload(transfer.localPath)
+			}
 		}
-		attemptDownloaded, timeToFirstByte, cacheAge, serverVersion, err := downloadHTTP(
-			ctx, transfer.engine, transfer.callback, transferEndpoint, transfer.localPath, size, tokenContents, transfer.project,
-		)
 		endTime := time.Now()
 		if cacheAge >= 0 {
 			attempt.CacheAge = cacheAge
@@ -1654,6 +2134,7 @@ func downloadObject(transfer *transferFile) (transferResults TransferResults, er
 		attempt.TransferEndTime = endTime
 		attempt.TransferTime = endTime.Sub(transferStartTime)
 		attempt.ServerVersion = serverVersion
+		attempt.Validators = validators
 		attempt.TransferFileBytes = attemptDownloaded
 		attempt.TimeToFirstByte = timeToFirstByte
 		downloaded += attemptDownloaded
@@ -1662,9 +2143,9 @@ func downloadObject(transfer *transferFile) (transferResults TransferResults, er
 			log.WithFields(fields).Debugln("Failed to download from", transferEndpoint.Url, ":", err)
 			var ope *net.OpError
 			var cse *ConnectionSetupError
-			proxyStr, _ := os.LookupEnv("http_proxy")
-			if !transferEndpoint.Proxy {
-				proxyStr = ""
+			proxyStr := ""
+			if transferEndpoint.Proxy {
+				proxyStr = config.ProxyDescription(transferEndpoint.Federation, transferEndpointUrl.Scheme)
 			}
 			serviceStr := attempt.Endpoint
 			if transferEndpointUrl.Scheme == "unix" {
@@ -1682,8 +2163,8 @@ func downloadObject(transfer *transferFile) (transferResults TransferResults, er
 					attempt.Error = newTransferAttemptError(serviceStr, proxyStr, false, false, sce)
 				} else if ue, ok := cse.Unwrap().(*url.Error); ok {
 					httpErr := ue.Unwrap()
-					if httpErr.Error() == "net/http: timeout awaiting response headers" {
-						attempt.Error = newTransferAttemptError(serviceStr, proxyStr, false, false, &HeaderTimeoutError{})
+					if timeoutErr := transferTimeoutError(httpErr); timeoutErr != nil {
+						attempt.Error = newTransferAttemptError(serviceStr, proxyStr, false, false, timeoutErr)
 					} else {
 						attempt.Error = newTransferAttemptError(serviceStr, proxyStr, false, false, httpErr)
 					}
@@ -1702,6 +2183,16 @@ func downloadObject(transfer *transferFile) (transferResults TransferResults, er
 			success = true
 			break
 		}
+		if idx < len(attempts)-1 {
+			if streamCorrupt || errors.Is(err, &ObjectTooLargeError{}) {
+				break
+			}
+			if !shouldFailover(attempt.Error) {
+				log.WithFields(fields).Debugf("Not trying the remaining endpoints as the %s error from %s would be repeated by them", ClassifyError(attempt.Error), attempt.Endpoint)
+				break
+			}
+			log.WithFields(fields).Infof("Download from %s failed (%s error); failing over to %s", attempt.Endpoint, ClassifyError(attempt.Error), attempts[idx+1].Url.Host)
+		}
 	}
 	transferResults.TransferStartTime = transferStartTime
 	transferResults.TransferredBytes = downloaded
@@ -1711,6 +2202,15 @@ func downloadObject(transfer *transferFile) (transferResults TransferResults, er
 	return
 }
 
+// Remove a download that failed checksum verification so that it is not mistaken
+// for a valid copy (or resumed) later
+func removeCorruptDownload(localPath string) {
+	if err := os.Remove(localPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warningln("Failed to remove download that failed checksum verification:", err)
+	}
+	removeResumeValidators(localPath)
+}
+
 func parseTransferStatus(status string) (int, string) {
 	parts := strings.SplitN(status, ": ", 2)
 	if len(parts) != 2 {
@@ -1728,7 +2228,7 @@ func parseTransferStatus(status string) (int, string) {
 // Perform the actual download of the file
 //
 // Returns the downloaded size, time to 1st byte downloaded, serverVersion and an error if there is one
-func downloadHTTP(ctx context.Context, te *TransferEngine, callback TransferCallbackFunc, transfer transferAttemptDetails, dest string, totalSize int64, token string, project string) (downloaded int64, timeToFirstByte time.Duration, cacheAge time.Duration, serverVersion string, err error) {
+func downloadHTTP(ctx context.Context, te *TransferEngine, callback TransferCallbackFunc, transfer transferAttemptDetails, dest string, totalSize int64, token string, project string) (downloaded int64, timeToFirstByte time.Duration, cacheAge time.Duration, serverVersion string, validators ObjectValidators, err error) {
 	fields, ok := ctx.Value(logFields("fields")).(log.Fields)
 	if !ok {
 		fields = log.Fields{}
@@ -1764,14 +2264,10 @@ func downloadHTTP(ctx context.Context, te *TransferEngine, callback TransferCall
 	// Create the client, request, and context
 	client := grab.NewClient()
 	client.UserAgent = getUserAgent(project)
-	transport := config.GetTransport()
-	if !transfer.Proxy {
-		transport.Proxy = nil
-	}
+	transport := newTransferTransport()
+	transport.Proxy = transferProxy(transfer)
 	transferUrl := *transfer.Url
 	if transfer.Url.Scheme == "unix" {
-		transport.Proxy = nil // Proxies make no sense when reading via a Unix socket
-		transport = transport.Clone()
 		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
 			dialer := net.Dialer{}
 			return dialer.DialContext(ctx, "unix", transfer.UnixSocket)
@@ -1783,7 +2279,7 @@ func downloadHTTP(ctx context.Context, te *TransferEngine, callback TransferCall
 	}
 	httpClient, ok := client.HTTPClient.(*http.Client)
 	if !ok {
-		return 0, 0, -1, "", errors.New("Internal error: implementation is not a http.Client type")
+		return 0, 0, -1, "", ObjectValidators{}, errors.New("Internal error: implementation is not a http.Client type")
 	}
 	httpClient.Transport = transport
 	headerTimeout := transport.ResponseHeaderTimeout
@@ -1799,28 +2295,102 @@ func downloadHTTP(ctx context.Context, te *TransferEngine, callback TransferCall
 	log.WithFields(fields).Debugln("Transfer URL String:", transferUrl.String())
 	var req *grab.Request
 	var unpacker *autoUnpacker
+	var resumeOffset int64
+	var resumeValidators ObjectValidators
+	if transfer.Resume && transfer.PackOption == "" {
+		resumeOffset, resumeValidators = partialDownloadOffset(ctx, httpClient, transferUrl.String(), dest, totalSize, token, project)
+	}
 	if transfer.PackOption != "" {
 		behavior, err := GetBehavior(transfer.PackOption)
 		if err != nil {
-			return 0, 0, -1, "", err
+			return 0, 0, -1, "", ObjectValidators{}, err
 		}
 		if dest == "." {
 			dest, err = os.Getwd()
 			if err != nil {
-				return 0, 0, -1, "", errors.Wrap(err, "Failed to get current directory for destination")
+				return 0, 0, -1, "", ObjectValidators{}, errors.Wrap(err, "Failed to get current directory for destination")
 			}
 		}
 		unpacker = newAutoUnpacker(dest, behavior)
 		if req, err = grab.NewRequestToWriter(unpacker, transferUrl.String()); err != nil {
-			return 0, 0, -1, "", errors.Wrap(err, "Failed to create new download request")
+			return 0, 0, -1, "", ObjectValidators{}, errors.Wrap(err, "Failed to create new download request")
+		}
+	} else if transfer.Stream != nil {
+		// Continue a stream that an earlier attempt left incomplete from where it stopped
+		stream := transfer.Stream
+		resumeOffset = stream.written
+		decompressor, _ := stream.w.(*decompressWriter)
+		if decompressor != nil && decompressor.decompressing() && resumeOffset > 0 {
+			// The server may not send the same compressed representation for a range, so
+			// fetch the whole object and skip the bytes the decompressor already has
+			log.WithFields(fields).Warningf("Fetching all of %s again to continue decompressing it after %d bytes", transferUrl.Path, resumeOffset)
+			resumeOffset = 0
+		}
+		if req, err = grab.NewRequestToWriter(stream, transferUrl.String()); err != nil {
+			return 0, 0, -1, "", ObjectValidators{}, errors.Wrap(err, "Failed to create new download request")
+		}
+		if resumeOffset > 0 {
+			req.HTTPRequest.Header.Set("Range", fmt.Sprintf("bytes=%d-", resumeOffset))
+		}
+		if decompressor != nil {
+			// Setting the header keeps the transport from decompressing the response itself,
+			// which would hide the encoding and break checksum verification
+			req.HTTPRequest.Header.Set("Accept-Encoding", "gzip")
+		}
+		req.BeforeCopy = func(resp *grab.Response) error {
+			stream.startAttempt(resp.HTTPResponse.StatusCode == http.StatusPartialContent)
+			if decompressor != nil {
+				decompressor.start(resp.HTTPResponse)
+			}
+			return nil
+		}
+	} else if resumeOffset > 0 {
+		var fp *os.File
+		if fp, err = os.OpenFile(dest, os.O_WRONLY, 0644); err != nil {
+			return 0, 0, -1, "", ObjectValidators{}, errors.Wrap(err, "Failed to open partial download for resuming")
+		}
+		defer fp.Close()
+		if _, err = fp.Seek(resumeOffset, io.SeekStart); err != nil {
+			return 0, 0, -1, "", ObjectValidators{}, errors.Wrap(err, "Failed to seek to the end of the partial download")
+		}
+		if req, err = grab.NewRequestToWriter(fp, transferUrl.String()); err != nil {
+			return 0, 0, -1, "", ObjectValidators{}, errors.Wrap(err, "Failed to create new download request")
+		}
+		req.HTTPRequest.Header.Set("Range", fmt.Sprintf("bytes=%d-", resumeOffset))
+		req.HTTPRequest.Header.Set("If-Range", resumeValidators.ifRange())
+		// If the server sends the full object instead of the remainder (e.g., the object
+		// changed since the partial download), start over from the beginning of the file.
+		req.BeforeCopy = func(resp *grab.Response) error {
+			current := validatorsFromHeader(resp.HTTPResponse.Header)
+			if resp.HTTPResponse.StatusCode == http.StatusPartialContent {
+				if !resumeValidators.sameObject(current) {
+					return errResumeMismatch
+				}
+				return nil
+			}
+			if _, err := fp.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			if err := fp.Truncate(0); err != nil {
+				return err
+			}
+			return recordResumeValidators(dest, current)
 		}
 	} else if req, err = grab.NewRequest(dest, transferUrl.String()); err != nil {
-		return 0, 0, -1, "", errors.Wrap(err, "Failed to create new download request")
+		return 0, 0, -1, "", ObjectValidators{}, errors.Wrap(err, "Failed to create new download request")
+	} else if transfer.Resume && transfer.PackOption == "" {
+		// Record what the download is a copy of, in case it is interrupted and resumed later
+		req.BeforeCopy = func(resp *grab.Response) error {
+			return recordResumeValidators(dest, validatorsFromHeader(resp.HTTPResponse.Header))
+		}
 	}
 
-	rateLimit := param.Client_MaximumDownloadSpeed.GetInt()
-	if rateLimit > 0 {
-		req.RateLimiter = rate.NewLimiter(rate.Limit(rateLimit), 64*1024)
+	limiters := te.rateLimiters()
+	if rateLimit := param.Client_MaximumDownloadSpeed.GetInt(); rateLimit > 0 {
+		limiters = append(limiters, rate.NewLimiter(rate.Limit(rateLimit), 64*1024))
+	}
+	if len(limiters) > 0 {
+		req.RateLimiter = limiters
 	}
 
 	if token != "" {
@@ -1859,13 +2429,18 @@ func downloadHTTP(ctx context.Context, te *TransferEngine, callback TransferCall
 			if errors.Is(err, grab.ErrBadLength) {
 				err = fmt.Errorf("local copy of file is larger than remote copy %w", grab.ErrBadLength)
 			} else if errors.As(err, &sce) {
-				log.WithFields(fields).Debugln("Creating a client status code error")
-				sce2 := StatusCodeError(sce)
-				err = &sce2
+				if busy := serverBusyFromResponse(resp.HTTPResponse); busy != nil {
+					err = busy
+				} else {
+					log.WithFields(fields).Debugln("Creating a client status code error")
+					sce2 := StatusCodeError(sce)
+					err = &sce2
+				}
 			} else if errors.As(err, &cam) && cam == syscall.ENOMEM {
 				// ENOMEM is error from os for unable to allocate memory
 				err = &allocateMemoryError{Err: err}
-			} else {
+			} else if !errors.Is(err, &ObjectTooLargeError{}) {
+				// An object outgrowing the caller's buffer is not a problem with the connection
 				err = &ConnectionSetupError{Err: err}
 			}
 			log.WithFields(fields).Errorln("Failed to download:", err)
@@ -1873,6 +2448,11 @@ func downloadHTTP(ctx context.Context, te *TransferEngine, callback TransferCall
 		}
 	}
 	serverVersion = resp.HTTPResponse.Header.Get("Server")
+	validators = validatorsFromHeader(resp.HTTPResponse.Header)
+	if resumeOffset > 0 && resp.HTTPResponse.StatusCode != http.StatusPartialContent {
+		log.WithFields(fields).Infoln("Server sent the full object instead of the remainder; restarting download of", dest)
+		resumeOffset = 0
+	}
 
 	if ageStr := resp.HTTPResponse.Header.Get("Age"); ageStr != "" {
 		if ageSec, err := strconv.Atoi(ageStr); err == nil {
@@ -1889,6 +2469,9 @@ func downloadHTTP(ctx context.Context, te *TransferEngine, callback TransferCall
 
 	// Size of the download
 	totalSize = resp.Size()
+	if resumeOffset > 0 && totalSize > 0 {
+		totalSize += resumeOffset
+	}
 	// Do a head request for content length if resp.Size is unknown
 	if totalSize <= 0 && !resp.IsComplete() {
 		headClient := &http.Client{Transport: transport}
@@ -1938,7 +2521,7 @@ Loop:
 		}
 		select {
 		case <-progressTicker.C:
-			downloaded = resp.BytesComplete()
+			downloaded = resumeOffset + resp.BytesComplete()
 			currentTime := time.Now()
 			if te != nil {
 				te.ewmaCtr.Add(int64(currentTime.Sub(lastUpdate)))
@@ -1950,7 +2533,7 @@ Loop:
 
 		case <-t.C:
 			// Check that progress is being made and that it is not too slow
-			downloaded = resp.BytesComplete()
+			downloaded = resumeOffset + resp.BytesComplete()
 			if downloaded == lastBytesComplete {
 				if noProgressStartTime.IsZero() {
 					noProgressStartTime = time.Now()
@@ -1966,7 +2549,7 @@ Loop:
 			} else {
 				noProgressStartTime = time.Time{}
 			}
-			lastBytesComplete = resp.BytesComplete()
+			lastBytesComplete = downloaded
 
 			// Check if we are downloading fast enough
 			limit := float64(downloadLimit)
@@ -2015,7 +2598,7 @@ Loop:
 			}
 
 		case <-resp.Done:
-			downloaded = resp.BytesComplete()
+			downloaded = resumeOffset + resp.BytesComplete()
 			break Loop
 		}
 	}
@@ -2028,6 +2611,10 @@ Loop:
 			err = &ConnectionSetupError{URL: resp.Request.URL().String()}
 			return
 		}
+		if errors.Is(err, errResumeMismatch) {
+			// The next attempt starts over
+			discardPartialDownload(dest)
+		}
 		log.WithFields(fields).Debugln("Got error from HTTP download", err)
 		return
 	} else {
@@ -2046,7 +2633,7 @@ Loop:
 	// prior attempt.
 	if resp.HTTPResponse.StatusCode != 200 && resp.HTTPResponse.StatusCode != 206 {
 		log.WithFields(fields).Debugln("Got failure status code:", resp.HTTPResponse.StatusCode)
-		return 0, 0, -1, serverVersion, &HttpErrResp{resp.HTTPResponse.StatusCode, fmt.Sprintf("Request failed (HTTP status %d): %s",
+		return 0, 0, -1, serverVersion, ObjectValidators{}, &HttpErrResp{resp.HTTPResponse.StatusCode, fmt.Sprintf("Request failed (HTTP status %d): %s",
 			resp.HTTPResponse.StatusCode, resp.Err().Error())}
 	}
 
@@ -2057,6 +2644,10 @@ Loop:
 		}
 	}
 
+	if transfer.PackOption == "" && transfer.Stream == nil {
+		// The download is complete, so there is nothing left to resume
+		removeResumeValidators(dest)
+	}
 	log.WithFields(fields).Debugln("HTTP Transfer was successful")
 	return
 }
@@ -2115,6 +2706,9 @@ func (pr *ProgressReader) Size() int64 {
 // Upload a single object to the origin
 func uploadObject(transfer *transferFile) (transferResult TransferResults, err error) {
 	log.Debugln("Uploading file to destination", transfer.remoteURL)
+	if size, ok := useChunkedUpload(transfer); ok {
+		return uploadObjectChunked(transfer, size)
+	}
 	xferErrors := NewTransferErrors()
 	transferResult.job = transfer.job
 
@@ -2128,19 +2722,42 @@ func uploadObject(transfer *transferFile) (transferResult TransferResults, err e
 	}
 
 	var attempt TransferResult
-	// Stat the file to get the size (for progress bar)
-	fileInfo, err := os.Stat(transfer.localPath)
 	transferResult.Scheme = transfer.remoteURL.Scheme
-	if err != nil {
-		log.Errorln("Error checking local file ", transfer.localPath, ":", err)
-		transferResult.Error = err
-		return transferResult, err
-	}
-
 	var ioreader io.ReadCloser
 	nonZeroSize := true
 	pack := transfer.packOption
-	if pack != "" {
+	// Data read from standard input is hashed as it is sent, since it cannot be
+	// read a second time to compute the checksum up front
+	// The same goes for objects read from object storage
+	stream := isStreamPath(transfer.localPath) || IsObjectStorageURL(transfer.localPath)
+	var streamHash hash.Hash
+	var fileInfo fs.FileInfo
+	if stream {
+		if pack != "" {
+			err = errors.New("uploads with the pack option cannot be streamed from standard input or object storage")
+			transferResult.Error = err
+			return transferResult, err
+		}
+		var input io.ReadCloser = io.NopCloser(streamInput)
+		if IsObjectStorageURL(transfer.localPath) {
+			var size int64
+			if input, size, err = openObjectStorageReader(transfer.ctx, transfer.localPath); err != nil {
+				transferResult.Error = err
+				return transferResult, err
+			}
+			sizer = &ConstantSizer{size: size}
+		}
+		ioreader = input
+		if transfer.job != nil && transfer.job.checksumType != ChecksumNone {
+			streamHash = transfer.job.checksumType.newHash()
+			ioreader = readCloser{io.TeeReader(input, streamHash), input}
+		}
+	} else if fileInfo, err = os.Stat(transfer.localPath); err != nil {
+		// Stat the file to get the size (for progress bar)
+		log.Errorln("Error checking local file ", transfer.localPath, ":", err)
+		transferResult.Error = err
+		return transferResult, err
+	} else if pack != "" {
 		if !fileInfo.IsDir() {
 			err = errors.Errorf("Upload with pack=%v only works when input (%v) is a directory", pack, transfer.localPath)
 			transferResult.Error = err
@@ -2176,10 +2793,25 @@ func uploadObject(transfer *transferFile) (transferResult TransferResults, err e
 		sizer = &ConstantSizer{size: fileInfo.Size()}
 		nonZeroSize = fileInfo.Size() > 0
 	}
+	ioreader = transfer.engine.limitReader(transfer.ctx, ioreader)
 	if transfer.callback != nil {
 		transfer.callback(transfer.localPath, 0, sizer.Size(), false)
 	}
 
+	// Compute the checksum up front so it can be sent to the server with the object
+	checksumType := ChecksumNone
+	var localChecksum []byte
+	if transfer.job != nil && pack == "" {
+		checksumType = transfer.job.checksumType
+	}
+	if checksumType != ChecksumNone && !stream {
+		if localChecksum, err = computeFileChecksum(transfer.localPath, checksumType); err != nil {
+			ioreader.Close()
+			transferResult.Error = err
+			return transferResult, err
+		}
+	}
+
 	// Parse the writeback host as a URL
 	writebackhostUrl := transfer.attempts[0].Url
 
@@ -2220,10 +2852,15 @@ func uploadObject(transfer *transferFile) (transferResult TransferResults, err e
 	if searchJobAd(jobId) != "" {
 		request.Header.Set("X-Pelican-JobId", searchJobAd(jobId))
 	}
+	if checksumType != ChecksumNone && !stream {
+		request.Header.Set("Digest", checksumType.digestName()+"="+checksumType.encode(localChecksum))
+	}
 	var lastKnownWritten int64
 	uploadStart := time.Now()
 
-	go runPut(request, responseChan, errorChan)
+	transport := newTransferTransport()
+	transport.Proxy = transferProxy(transfer.attempts[0])
+	go runPut(request, transport, responseChan, errorChan)
 	var lastError error = nil
 
 	tickerDuration := 100 * time.Millisecond
@@ -2254,8 +2891,9 @@ Loop:
 				// We have made progress!
 				lastKnownWritten = uploaded
 				lastProgress = time.Now()
-			} else if timeSinceLastProgress > stoppedTransferTimeout {
-
+			} else if timeSinceLastProgress > stoppedTransferTimeout && !stream {
+				// A stream may legitimately pause while its producer is busy, so
+				// only uploads of local files are checked for stalls
 				log.Errorln("No progress made in last", timeSinceLastProgress.Round(time.Millisecond).String(), "in upload")
 				lastError = &StoppedTransferError{
 					BytesTransferred: uploaded,
@@ -2284,8 +2922,8 @@ Loop:
 			var ue *url.Error
 			if errors.As(err, &ue) {
 				err = ue.Unwrap()
-				if err.Error() == "net/http: timeout awaiting response headers" {
-					err = &HeaderTimeoutError{}
+				if timeoutErr := transferTimeoutError(err); timeoutErr != nil {
+					err = timeoutErr
 				}
 			}
 			if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
@@ -2297,6 +2935,22 @@ Loop:
 		}
 	}
 
+	if lastError == nil && checksumType != ChecksumNone {
+		tokenContents := ""
+		if transfer.token != nil {
+			tokenContents, _ = transfer.token.get()
+		}
+		if stream {
+			client := &http.Client{Transport: config.GetTransport()}
+			lastError = verifySum(transfer.ctx, client, dest.String(), dest.Path, checksumType, streamHash.Sum(nil), tokenContents, transfer.project)
+		} else {
+			lastError = verifyUploadChecksum(transfer.ctx, dest, transfer.localPath, checksumType, tokenContents, transfer.project)
+		}
+		if lastError != nil {
+			log.Errorln("Checksum verification of upload failed:", lastError)
+		}
+	}
+
 	transferEndTime := time.Now()
 	uploaded = reader.BytesComplete()
 	transferResult.TransferredBytes = uploaded
@@ -2321,8 +2975,8 @@ Loop:
 //
 // This is executed in a separate goroutine to allow periodic progress callbacks
 // to be created within the main goroutine.
-func runPut(request *http.Request, responseChan chan<- *http.Response, errorChan chan<- error) {
-	var UploadClient = &http.Client{Transport: config.GetTransport()}
+func runPut(request *http.Request, transport *http.Transport, responseChan chan<- *http.Response, errorChan chan<- error) {
+	var UploadClient = &http.Client{Transport: transport}
 	client := UploadClient
 	dump, _ := httputil.DumpRequestOut(request, false)
 	log.Debugf("Dumping request: %s", dump)
@@ -2374,12 +3028,22 @@ func skipDownload(syncLevel SyncLevel, remoteInfo fs.FileInfo, localPath string)
 	switch syncLevel {
 	case SyncExist:
 		return true
-	case SyncSize:
+	case SyncSize, SyncChecksum:
+		// Checksums are only compared when planning a sync, which avoids a HEAD
+		// request per object while walking a collection
 		return localInfo.Size() == remoteInfo.Size()
+	case SyncMtime:
+		return localInfo.Size() == remoteInfo.Size() && !isOlder(localInfo.ModTime(), remoteInfo.ModTime())
 	}
 	return false
 }
 
+// Returns true if the destination's modification time is before the source's.  Times
+// are compared at a one-second granularity, as that is all remote listings provide.
+func isOlder(dest time.Time, source time.Time) bool {
+	return dest.Truncate(time.Second).Before(source.Truncate(time.Second))
+}
+
 // Depending on the synchronization policy, decide if the upload should be skipped
 func skipUpload(job *TransferJob, localPath string, remoteUrl *pelican_url.PelicanURL) bool {
 	if job.syncLevel == SyncNone {
@@ -2401,10 +3065,40 @@ func skipUpload(job *TransferJob, localPath string, remoteUrl *pelican_url.Pelic
 		return true
 	case SyncSize:
 		return localInfo.Size() == remoteInfo.Size
+	case SyncChecksum:
+		return localInfo.Size() == remoteInfo.Size && uploadChecksumsMatch(job, localPath, remoteUrl)
+	case SyncMtime:
+		return localInfo.Size() == remoteInfo.Size && !isOlder(remoteInfo.ModTime, localInfo.ModTime())
 	}
 	return false
 }
 
+// Compare a local file against the checksum the origin reports for the object it would
+// be uploaded to.  Returns false if the checksums can't be compared.
+func uploadChecksumsMatch(job *TransferJob, localPath string, remoteUrl *pelican_url.PelicanURL) bool {
+	collectionsUrl := job.dirResp.XPelNsHdr.CollectionsUrl
+	if collectionsUrl == nil {
+		return false
+	}
+	objectUrl := *collectionsUrl
+	objectUrl.Path = remoteUrl.Path
+	tokenContents := ""
+	if job.token != nil {
+		var err error
+		if tokenContents, err = job.token.get(); err != nil {
+			log.Debugln("Unable to get a token to compare the checksum of", remoteUrl.Path, ":", err)
+			return false
+		}
+	}
+	client := &http.Client{Transport: config.GetTransport()}
+	match, err := checksumsMatch(job.ctx, client, objectUrl.String(), localPath, tokenContents)
+	if err != nil {
+		log.Debugln("Unable to compare the checksum of", localPath, "with", remoteUrl.Path, ":", err)
+		return false
+	}
+	return match
+}
+
 // Walk a remote collection in a WebDAV server, emitting the files discovered
 func (te *TransferEngine) walkDirDownload(job *clientTransferJob, transfers []transferAttemptDetails, files chan *clientTransferFile, url *url.URL) error {
 	// Create the client to walk the filesystem
@@ -2441,6 +3135,8 @@ func (te *TransferEngine) walkDirDownloadHelper(job *clientTransferJob, transfer
 			if !info.IsDir() {
 				if skipDownload(job.job.syncLevel, info, job.job.localPath) {
 					log.Infoln("Skipping download of object", remotePath, "as it already exists at", job.job.localPath)
+				} else if job.job.journal.isComplete(remotePath, job.job.localPath, info.Size()) {
+					log.Infoln("Skipping download of object", remotePath, "as the transfer journal shows it was already downloaded")
 				} else {
 					job.job.activeXfer.Add(1)
 					select {
@@ -2450,16 +3146,17 @@ func (te *TransferEngine) walkDirDownloadHelper(job *clientTransferJob, transfer
 						uuid:  job.uuid,
 						jobId: job.job.uuid,
 						file: &transferFile{
-							ctx:        job.job.ctx,
-							callback:   job.job.callback,
-							job:        job.job,
-							engine:     te,
-							remoteURL:  &url.URL{Path: remotePath},
-							packOption: transfers[0].PackOption,
-							localPath:  job.job.localPath,
-							upload:     job.job.upload,
-							token:      job.job.token,
-							attempts:   transfers,
+							ctx:           job.job.ctx,
+							callback:      job.job.callback,
+							job:           job.job,
+							engine:        te,
+							remoteURL:     &url.URL{Path: remotePath},
+							packOption:    transfers[0].PackOption,
+							localPath:     job.job.localPath,
+							upload:        job.job.upload,
+							token:         job.job.token,
+							attempts:      transfers,
+							remoteModTime: info.ModTime(),
 						},
 					}:
 						job.job.totalXfer += 1
@@ -2479,8 +3176,10 @@ func (te *TransferEngine) walkDirDownloadHelper(job *clientTransferJob, transfer
 			if err != nil {
 				return err
 			}
-		} else if localPath := path.Join(job.job.localPath, localBase, info.Name()); skipDownload(job.job.syncLevel, info, localPath) {
+		} else if localPath := joinLocalPath(job.job.localPath, localBase, info.Name()); skipDownload(job.job.syncLevel, info, localPath) {
 			log.Infoln("Skipping download of object", newPath, "as it already exists at", localPath)
+		} else if job.job.journal.isComplete(newPath, localPath, info.Size()) {
+			log.Infoln("Skipping download of object", newPath, "as the transfer journal shows it was already downloaded")
 		} else {
 			job.job.activeXfer.Add(1)
 			select {
@@ -2490,16 +3189,17 @@ func (te *TransferEngine) walkDirDownloadHelper(job *clientTransferJob, transfer
 				uuid:  job.uuid,
 				jobId: job.job.uuid,
 				file: &transferFile{
-					ctx:        job.job.ctx,
-					callback:   job.job.callback,
-					job:        job.job,
-					engine:     te,
-					remoteURL:  &url.URL{Path: newPath},
-					packOption: transfers[0].PackOption,
-					localPath:  localPath,
-					upload:     job.job.upload,
-					token:      job.job.token,
-					attempts:   transfers,
+					ctx:           job.job.ctx,
+					callback:      job.job.callback,
+					job:           job.job,
+					engine:        te,
+					remoteURL:     &url.URL{Path: newPath},
+					packOption:    transfers[0].PackOption,
+					localPath:     localPath,
+					upload:        job.job.upload,
+					token:         job.job.token,
+					attempts:      transfers,
+					remoteModTime: info.ModTime(),
 				},
 			}:
 				job.job.totalXfer += 1
@@ -2509,12 +3209,26 @@ func (te *TransferEngine) walkDirDownloadHelper(job *clientTransferJob, transfer
 	return nil
 }
 
-// Helper function for walkDirUpload; not to be called directly
-func (te *TransferEngine) walkDirUpload(job *clientTransferJob, transfers []transferAttemptDetails, files chan *clientTransferFile, localPath string) error {
+// Walk a local directory, emitting the files to upload.  Subdirectories the job's
+// journal records as completely uploaded by an earlier run are skipped without being
+// walked; parent is the journal's record of the enclosing directory, if any.
+func (te *TransferEngine) walkDirUpload(job *clientTransferJob, transfers []transferAttemptDetails, files chan *clientTransferFile, localPath string, parent *journalDir) error {
 	if job.job.ctx.Err() != nil {
 		return job.job.ctx.Err()
 	}
 
+	// Take the modification time before listing the directory, so entries created
+	// during the walk cause it to be walked again if the upload is resumed
+	remoteDir := path.Join(job.job.remoteURL.Path, relativeObjectPath(job.job.localPath, localPath))
+	var dirModTime time.Time
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		if job.job.journal.isDirComplete(remoteDir, localPath) {
+			log.Infoln("Skipping upload of", localPath, "as the transfer journal shows its contents were already uploaded")
+			return nil
+		}
+		dirModTime = info.ModTime()
+	}
+
 	// Get our list of directory entries
 	infos, err := os.ReadDir(localPath)
 	if err != nil {
@@ -2524,8 +3238,10 @@ func (te *TransferEngine) walkDirUpload(job *clientTransferJob, transfers []tran
 		}
 		// If the path leads to a file and not a directory, create a job to upload the file and return
 		if !info.IsDir() {
-			if remotePath := path.Join(job.job.remoteURL.Path, strings.TrimPrefix(localPath, job.job.localPath)); skipUpload(job.job, localPath, job.job.remoteURL) {
+			if remotePath := path.Join(job.job.remoteURL.Path, relativeObjectPath(job.job.localPath, localPath)); skipUpload(job.job, localPath, job.job.remoteURL) {
 				log.Infoln("Skipping upload of object", remotePath, "as it already exists at the destination")
+			} else if job.job.journal.isComplete(remotePath, job.job.localPath, -1) {
+				log.Infoln("Skipping upload of object", remotePath, "as the transfer journal shows it was already uploaded")
 			} else if info.Mode().Type().IsRegular() {
 				job.job.activeXfer.Add(1)
 				select {
@@ -2556,24 +3272,28 @@ func (te *TransferEngine) walkDirUpload(job *clientTransferJob, transfers []tran
 		return errors.Wrap(err, "failed to upload local collection")
 	}
 
+	dir := job.job.journal.startDir(parent, remoteDir, localPath, dirModTime)
 	for _, info := range infos {
-		newPath := localPath + "/" + info.Name()
+		newPath := filepath.Join(localPath, info.Name())
 		remoteUrl, err := pelican_url.Parse(job.job.remoteURL.String(), nil, nil)
 		if err != nil {
 			return err
 		}
-		remoteUrl.Path = path.Join(remoteUrl.Path, strings.TrimPrefix(newPath, job.job.localPath))
+		remoteUrl.Path = path.Join(remoteUrl.Path, relativeObjectPath(job.job.localPath, newPath))
 
 		if info.IsDir() {
 			// Recursively call this function to create any nested dir's as well as list their files
-			err := te.walkDirUpload(job, transfers, files, newPath)
+			err := te.walkDirUpload(job, transfers, files, newPath, dir)
 			if err != nil {
 				return err
 			}
 		} else if skipUpload(job.job, newPath, remoteUrl) {
 			log.Infoln("Skipping upload of object", remoteUrl.Path, "as it already exists at the destination")
+		} else if job.job.journal.isComplete(remoteUrl.Path, newPath, -1) {
+			log.Infoln("Skipping upload of object", remoteUrl.Path, "as the transfer journal shows it was already uploaded")
 		} else if info.Type().IsRegular() {
 			job.job.activeXfer.Add(1)
+			job.job.journal.addPending(dir)
 			select {
 			case <-job.job.ctx.Done():
 				return job.job.ctx.Err()
@@ -2591,12 +3311,14 @@ func (te *TransferEngine) walkDirUpload(job *clientTransferJob, transfers []tran
 					upload:     job.job.upload,
 					token:      job.job.token,
 					attempts:   transfers,
+					journalDir: dir,
 				},
 			}:
 				job.job.totalXfer += 1
 			}
 		}
 	}
+	job.job.journal.finishWalk(dir)
 	return err
 }
 
@@ -2786,7 +3508,7 @@ func statHttp(dest *pelican_url.PelicanURL, dirResp server_structs.DirectorRespo
 		err  error
 	}
 	resultsChan := make(chan statResults)
-	transport := config.GetTransport()
+	federation := dest.FedInfo.DiscoveryEndpoint
 	auth := &bearerAuth{token: token}
 
 	for _, statUrl := range statHosts {
@@ -2797,15 +3519,19 @@ func statHttp(dest *pelican_url.PelicanURL, dirResp server_structs.DirectorRespo
 
 		go func(endpoint *url.URL) {
 			canDisableProxy := CanDisableProxy()
-			disableProxy := !isProxyEnabled()
+			disableProxy := !isProxyEnabled(federation, endpoint.Scheme)
 
 			var info FileInfo
 			for {
+				// Each request gets its own transport so disabling the proxy
+				// doesn't leak into the shared one
+				transport := config.GetTransport().Clone()
 				if disableProxy {
 					log.Debugln("Performing request (without proxy)", endpoint.String())
 					transport.Proxy = nil
 				} else {
 					log.Debugln("Performing request", endpoint.String())
+					transport.Proxy = config.ProxyForFederation(federation)
 				}
 				client.SetTransport(transport)
 
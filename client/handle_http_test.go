@@ -23,6 +23,8 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"io/fs"
 	"net"
 	"net/http"
@@ -82,7 +84,7 @@ func TestNewTransferDetails(t *testing.T) {
 
 	t.Run("ServerWithHTTPAndPort", func(t *testing.T) {
 		server := "http://cache.edu:8000"
-		transfers := generateTransferDetails(server, transferDetailsOptions{false, ""})
+		transfers := generateTransferDetails(server, transferDetailsOptions{false, "", ""})
 		assert.Equal(t, 2, len(transfers))
 		assert.Equal(t, "cache.edu:8000", transfers[0].Url.Host)
 		assert.Equal(t, "http", transfers[0].Url.Scheme)
@@ -94,7 +96,7 @@ func TestNewTransferDetails(t *testing.T) {
 
 	t.Run("ServerWithHTTPSAndPort", func(t *testing.T) {
 		server := "https://cache.edu:8443"
-		transfers := generateTransferDetails(server, transferDetailsOptions{true, ""})
+		transfers := generateTransferDetails(server, transferDetailsOptions{true, "", ""})
 		assert.Equal(t, 1, len(transfers))
 		assert.Equal(t, "cache.edu:8443", transfers[0].Url.Host)
 		assert.Equal(t, "https", transfers[0].Url.Scheme)
@@ -104,7 +106,7 @@ func TestNewTransferDetails(t *testing.T) {
 	t.Run("ServerWithHTTPAndNoPort", func(t *testing.T) {
 		server := "http://cache.edu"
 		// Case 3: cache without port with http
-		transfers := generateTransferDetails(server, transferDetailsOptions{false, ""})
+		transfers := generateTransferDetails(server, transferDetailsOptions{false, "", ""})
 		assert.Equal(t, 2, len(transfers))
 		assert.Equal(t, "cache.edu", transfers[0].Url.Host)
 		assert.Equal(t, "http", transfers[0].Url.Scheme)
@@ -117,7 +119,7 @@ func TestNewTransferDetails(t *testing.T) {
 	t.Run("ServerWithHTTPSAndNoPort", func(t *testing.T) {
 		// Case 4. cache without port with https
 		server := "https://cache.edu"
-		transfers := generateTransferDetails(server, transferDetailsOptions{true, ""})
+		transfers := generateTransferDetails(server, transferDetailsOptions{true, "", ""})
 		assert.Equal(t, 1, len(transfers))
 		assert.Equal(t, "cache.edu", transfers[0].Url.Host)
 		assert.Equal(t, "https", transfers[0].Url.Scheme)
@@ -142,7 +144,7 @@ func TestNewTransferDetailsEnv(t *testing.T) {
 
 	os.Unsetenv("http_proxy")
 
-	transfers = generateTransferDetails(testCache, transferDetailsOptions{true, ""})
+	transfers = generateTransferDetails(testCache, transferDetailsOptions{true, "", ""})
 	assert.Equal(t, 1, len(transfers))
 	assert.Equal(t, "https", transfers[0].Url.Scheme)
 	assert.Equal(t, false, transfers[0].Proxy)
@@ -152,6 +154,28 @@ func TestNewTransferDetailsEnv(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+// TestNewTransferDetailsHttpsProxy checks that authenticated transfers go
+// through HTTPS_PROXY and honor per-federation proxy overrides
+func TestNewTransferDetailsHttpsProxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://proxy.edu:3128")
+	test_utils.InitClient(t, map[string]any{
+		"Client.ProxyOverrides": []map[string]any{
+			{"Federation": "direct.edu", "Proxy": "none"},
+		},
+	})
+	t.Cleanup(server_utils.ResetTestState)
+
+	transfers := generateTransferDetails("https://cache.edu:8443", transferDetailsOptions{true, "", "https://fed.edu"})
+	require.Equal(t, 2, len(transfers))
+	assert.Equal(t, true, transfers[0].Proxy)
+	assert.Equal(t, "https://fed.edu", transfers[0].Federation)
+	assert.Equal(t, false, transfers[1].Proxy)
+
+	transfers = generateTransferDetails("https://cache.edu:8443", transferDetailsOptions{true, "", "https://direct.edu"})
+	require.Equal(t, 1, len(transfers))
+	assert.Equal(t, false, transfers[0].Proxy)
+}
+
 func TestSlowTransfers(t *testing.T) {
 	ctx, _, _ := test_utils.TestContext(context.Background(), t)
 
@@ -194,7 +218,7 @@ func TestSlowTransfers(t *testing.T) {
 		require.NoError(t, os.Unsetenv("http_proxy"))
 	})
 
-	transfers := generateTransferDetails(testCache, transferDetailsOptions{false, ""})
+	transfers := generateTransferDetails(testCache, transferDetailsOptions{false, "", ""})
 	assert.Equal(t, 2, len(transfers))
 	assert.Equal(t, svr.URL, transfers[0].Url.String())
 
@@ -202,7 +226,7 @@ func TestSlowTransfers(t *testing.T) {
 	var err error
 	// Do a quick timeout
 	go func() {
-		_, _, _, _, err = downloadHTTP(ctx, nil, nil, transfers[0], filepath.Join(t.TempDir(), "test.txt"), -1, "", "")
+		_, _, _, _, _, err = downloadHTTP(ctx, nil, nil, transfers[0], filepath.Join(t.TempDir(), "test.txt"), -1, "", "")
 		finishedChannel <- true
 	}()
 
@@ -276,7 +300,7 @@ func TestStoppedTransfer(t *testing.T) {
 	defer svr.Close()
 
 	testCache := svr.URL
-	transfers := generateTransferDetails(testCache, transferDetailsOptions{false, ""})
+	transfers := generateTransferDetails(testCache, transferDetailsOptions{false, "", ""})
 	assert.Equal(t, 2, len(transfers))
 	assert.Equal(t, svr.URL, transfers[0].Url.String())
 
@@ -284,7 +308,7 @@ func TestStoppedTransfer(t *testing.T) {
 	var err error
 
 	go func() {
-		_, _, _, _, err = downloadHTTP(ctx, nil, nil, transfers[0], filepath.Join(t.TempDir(), "test.txt"), -1, "", "")
+		_, _, _, _, _, err = downloadHTTP(ctx, nil, nil, transfers[0], filepath.Join(t.TempDir(), "test.txt"), -1, "", "")
 		finishedChannel <- true
 	}()
 
@@ -317,7 +341,7 @@ func TestConnectionError(t *testing.T) {
 	addr := l.Addr().String()
 	l.Close()
 
-	_, _, _, _, err = downloadHTTP(ctx, nil, nil, transferAttemptDetails{Url: &url.URL{Host: addr, Scheme: "http"}, Proxy: false}, filepath.Join(t.TempDir(), "test.txt"), -1, "", "")
+	_, _, _, _, _, err = downloadHTTP(ctx, nil, nil, transferAttemptDetails{Url: &url.URL{Host: addr, Scheme: "http"}, Proxy: false}, filepath.Join(t.TempDir(), "test.txt"), -1, "", "")
 
 	assert.IsType(t, &ConnectionSetupError{}, err)
 
@@ -348,12 +372,12 @@ func TestTrailerError(t *testing.T) {
 	})
 
 	testCache := svr.URL
-	transfers := generateTransferDetails(testCache, transferDetailsOptions{false, ""})
+	transfers := generateTransferDetails(testCache, transferDetailsOptions{false, "", ""})
 	assert.Equal(t, 2, len(transfers))
 	assert.Equal(t, svr.URL, transfers[0].Url.String())
 
 	// Call DownloadHTTP and check if the error is returned correctly
-	_, _, _, _, err := downloadHTTP(ctx, nil, nil, transfers[0], filepath.Join(t.TempDir(), "test.txt"), -1, "", "")
+	_, _, _, _, _, err := downloadHTTP(ctx, nil, nil, transfers[0], filepath.Join(t.TempDir(), "test.txt"), -1, "", "")
 
 	assert.NotNil(t, err)
 	assert.EqualError(t, err, "transfer error: Unable to read test.txt; input/output error")
@@ -376,7 +400,7 @@ func TestUploadZeroLengthFile(t *testing.T) {
 	request.Header.Set("Authorization", "Bearer test")
 	errorChan := make(chan error, 1)
 	responseChan := make(chan *http.Response)
-	go runPut(request, responseChan, errorChan)
+	go runPut(request, newTransferTransport(), responseChan, errorChan)
 	select {
 	case err := <-errorChan:
 		assert.NoError(t, err)
@@ -406,7 +430,7 @@ func TestFailedUpload(t *testing.T) {
 	request.Header.Set("Authorization", "Bearer test")
 	errorChan := make(chan error, 1)
 	responseChan := make(chan *http.Response)
-	go runPut(request, responseChan, errorChan)
+	go runPut(request, newTransferTransport(), responseChan, errorChan)
 	select {
 	case err := <-errorChan:
 		assert.Error(t, err)
@@ -508,7 +532,7 @@ func TestTimeoutHeaderSetForDownload(t *testing.T) {
 
 	serverURL, err := url.Parse(server.URL)
 	assert.NoError(t, err)
-	_, _, _, _, err = downloadHTTP(ctx, nil, nil, transferAttemptDetails{Url: serverURL, Proxy: false}, filepath.Join(t.TempDir(), "test.txt"), -1, "", "")
+	_, _, _, _, _, err = downloadHTTP(ctx, nil, nil, transferAttemptDetails{Url: serverURL, Proxy: false}, filepath.Join(t.TempDir(), "test.txt"), -1, "", "")
 	assert.NoError(t, err)
 	server_utils.ResetTestState()
 }
@@ -547,7 +571,7 @@ func TestJobIdHeaderSetForDownload(t *testing.T) {
 
 	serverURL, err := url.Parse(server.URL)
 	assert.NoError(t, err)
-	_, _, _, _, err = downloadHTTP(ctx, nil, nil, transferAttemptDetails{Url: serverURL, Proxy: false}, filepath.Join(t.TempDir(), "test.txt"), -1, "", "")
+	_, _, _, _, _, err = downloadHTTP(ctx, nil, nil, transferAttemptDetails{Url: serverURL, Proxy: false}, filepath.Join(t.TempDir(), "test.txt"), -1, "", "")
 	assert.NoError(t, err)
 	server_utils.ResetTestState()
 	os.Unsetenv("_CONDOR_JOB_AD")
@@ -582,7 +606,7 @@ func TestProjInUserAgent(t *testing.T) {
 
 	serverURL, err := url.Parse(server_test.server.URL)
 	assert.NoError(t, err)
-	_, _, _, _, err = downloadHTTP(ctx, nil, nil, transferAttemptDetails{Url: serverURL, Proxy: false}, filepath.Join(t.TempDir(), "test.txt"), -1, "", "test")
+	_, _, _, _, _, err = downloadHTTP(ctx, nil, nil, transferAttemptDetails{Url: serverURL, Proxy: false}, filepath.Join(t.TempDir(), "test.txt"), -1, "", "test")
 	assert.NoError(t, err)
 
 	// Test the user-agent header is what we expect it to be
@@ -770,6 +794,140 @@ func TestFailedConnectionSetupError(t *testing.T) {
 	assert.Error(t, err)
 }
 
+// Test that a download fails over to the next endpoint only for errors another endpoint may not repeat
+func TestDownloadFailover(t *testing.T) {
+	test_utils.InitClient(t, map[string]any{})
+
+	// Answer the single-byte probes made while sorting the endpoints, but fail the download itself
+	failingServer := func(code int) *url.URL {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Range") == "0-0" {
+				_, _ = w.Write([]byte("h"))
+				return
+			}
+			w.WriteHeader(code)
+		}))
+		t.Cleanup(svr.Close)
+		svrURL, err := url.Parse(svr.URL)
+		require.NoError(t, err)
+		return svrURL
+	}
+	goodSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	t.Cleanup(goodSvr.Close)
+	goodURL, err := url.Parse(goodSvr.URL)
+	require.NoError(t, err)
+
+	download := func(first *url.URL) TransferResults {
+		transfer := &transferFile{
+			ctx:       context.Background(),
+			job:       &TransferJob{ctx: context.Background()},
+			localPath: filepath.Join(t.TempDir(), "test.txt"),
+			remoteURL: &url.URL{Path: "/test.txt"},
+			attempts:  []transferAttemptDetails{{Url: first}, {Url: goodURL}},
+		}
+		transferResult, err := downloadObject(transfer)
+		require.NoError(t, err)
+		return transferResult
+	}
+
+	t.Run("unavailable", func(t *testing.T) {
+		transferResult := download(failingServer(http.StatusServiceUnavailable))
+		require.NoError(t, transferResult.Error)
+		require.Len(t, transferResult.Attempts, 2)
+		assert.Equal(t, ErrorClassRetryable, ClassifyError(transferResult.Attempts[0].Error))
+		assert.Equal(t, goodURL.Host, transferResult.Attempts[1].Endpoint)
+	})
+
+	t.Run("not-found", func(t *testing.T) {
+		badURL := failingServer(http.StatusNotFound)
+		transferResult := download(badURL)
+		require.Error(t, transferResult.Error)
+		assert.Len(t, transferResult.Attempts, 1)
+		assert.Equal(t, ErrorClassFatal, ClassifyError(transferResult.Error))
+		var te *TransferErrors
+		require.True(t, errors.As(transferResult.Error, &te))
+		assert.Equal(t, []string{badURL.Host}, te.Endpoints())
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		transferResult := download(failingServer(http.StatusForbidden))
+		require.Error(t, transferResult.Error)
+		assert.Len(t, transferResult.Attempts, 1)
+		assert.Equal(t, ErrorClassAuth, ClassifyError(transferResult.Error))
+	})
+}
+
+// Test that downloads to memory are verified, with corrupt copies discarded, and limited in size
+func TestDownloadToMemory(t *testing.T) {
+	test_utils.InitClient(t, map[string]any{})
+
+	object := []byte("calibration constants")
+	sum := sha256.Sum256(object)
+	// Every server reports the checksum of the real object, whatever it sends
+	server := func(contents []byte) *url.URL {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Want-Digest") != "" {
+				w.Header().Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum[:]))
+				return
+			}
+			_, _ = w.Write(contents)
+		}))
+		t.Cleanup(svr.Close)
+		svrURL, err := url.Parse(svr.URL)
+		require.NoError(t, err)
+		return svrURL
+	}
+	corruptURL := server([]byte("calibration CONSTANTS"))
+	goodURL := server(object)
+
+	download := func(maxSize int64, endpoints ...*url.URL) (*memoryBuffer, TransferResults, error) {
+		memory := &memoryBuffer{maxSize: maxSize}
+		attempts := []transferAttemptDetails{}
+		for _, endpoint := range endpoints {
+			attempts = append(attempts, transferAttemptDetails{Url: endpoint})
+		}
+		transfer := &transferFile{
+			ctx:       context.Background(),
+			job:       &TransferJob{ctx: context.Background(), memory: memory},
+			localPath: StreamPath,
+			remoteURL: &url.URL{Path: "/calibration.txt"},
+			attempts:  attempts,
+		}
+		transferResult, err := downloadObject(transfer)
+		return memory, transferResult, err
+	}
+
+	t.Run("corrupt-copy-discarded", func(t *testing.T) {
+		memory, transferResult, err := download(1024, corruptURL, goodURL)
+		require.NoError(t, err)
+		require.NoError(t, transferResult.Error)
+		require.Len(t, transferResult.Attempts, 2)
+		assert.ErrorIs(t, transferResult.Attempts[0].Error, &ChecksumMismatchError{})
+		assert.Equal(t, object, memory.Bytes())
+	})
+
+	t.Run("corrupt-everywhere", func(t *testing.T) {
+		_, transferResult, err := download(1024, corruptURL)
+		require.NoError(t, err)
+		assert.ErrorIs(t, transferResult.Error, &ChecksumMismatchError{})
+	})
+
+	t.Run("known-size-too-large", func(t *testing.T) {
+		// The object's size is learned while sorting the endpoints, so nothing is downloaded
+		_, _, err := download(10, goodURL, goodURL)
+		assert.ErrorIs(t, err, &ObjectTooLargeError{})
+	})
+
+	t.Run("too-large", func(t *testing.T) {
+		memory, transferResult, err := download(10, goodURL)
+		require.NoError(t, err)
+		assert.ErrorIs(t, transferResult.Error, &ObjectTooLargeError{})
+		assert.LessOrEqual(t, len(memory.Bytes()), 10)
+	})
+}
+
 // Test that head requests with downloads contain the download token if it exists
 func TestHeadRequestWithDownloadToken(t *testing.T) {
 	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -988,3 +1146,44 @@ func TestListHttp(t *testing.T) {
 		})
 	}
 }
+
+func TestDownloadDenied(t *testing.T) {
+	denied := func(code int) TransferResult {
+		sce := StatusCodeError(code)
+		return TransferResult{Error: newTransferAttemptError("cache.example.com", "", false, false, &sce)}
+	}
+
+	assert.False(t, downloadDenied(TransferResults{}))
+	assert.True(t, downloadDenied(TransferResults{
+		Error:    errors.New("failed"),
+		Attempts: []TransferResult{denied(http.StatusForbidden), denied(http.StatusUnauthorized)},
+	}))
+	// Any other failure means a token would not have helped
+	assert.False(t, downloadDenied(TransferResults{
+		Error:    errors.New("failed"),
+		Attempts: []TransferResult{denied(http.StatusForbidden), denied(http.StatusNotFound)},
+	}))
+	assert.False(t, downloadDenied(TransferResults{
+		Error:    errors.New("failed"),
+		Attempts: []TransferResult{denied(http.StatusForbidden), {Error: errors.New("connection refused")}},
+	}))
+
+	// Transfers that already have a token are not retried
+	file := &transferFile{
+		remoteURL: &url.URL{Path: "/foo/bar"},
+		token:     newTokenGenerator(nil, nil, false, false),
+		job:       &TransferJob{deniedToken: newTokenGenerator(nil, nil, false, false)},
+	}
+	results := TransferResults{Error: errors.New("failed"), Attempts: []TransferResult{denied(http.StatusForbidden)}}
+	assert.False(t, authorizeDeniedDownload(file, results))
+
+	// Otherwise, the token found by the job's generator is used for the retry
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("some-token"), 0600))
+	generator := newTokenGenerator(&pelican_url.PelicanURL{Path: "/foo/bar"}, nil, false, false)
+	generator.SetTokenLocation(tokenFile)
+	file.token = nil
+	file.job.deniedToken = generator
+	require.True(t, authorizeDeniedDownload(file, results))
+	assert.Equal(t, generator, file.token)
+}
@@ -0,0 +1,504 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pelicanplatform/pelican/utils"
+)
+
+type (
+	// A transfer workflow read from a job file, for example:
+	//
+	//	version: 1
+	//	tokens:
+	//	  analysis:
+	//	    file: /home/user/analysis.tok
+	//	defaults:
+	//	  token: analysis
+	//	  retries: 2
+	//	steps:
+	//	  - name: inputs
+	//	    action: sync
+	//	    source: osdf:///ospool/ap20/data/inputs
+	//	    destination: ./inputs
+	//	  - name: results
+	//	    action: put
+	//	    source: ./results
+	//	    destination: osdf:///ospool/ap20/data/results
+	//	    recursive: true
+	//	    dependsOn: [inputs]
+	//
+	// Steps run in the order given, except that a step always runs after the
+	// steps it depends on.  A step whose dependency failed is skipped.
+	Job struct {
+		Version int `yaml:"version"`
+		// Named token sources that steps refer to
+		Tokens map[string]JobTokenSource `yaml:"tokens"`
+		// Options applied to every step that doesn't set them itself
+		Defaults JobStepOptions `yaml:"defaults"`
+		// Skip every remaining step once one step fails
+		FailFast bool      `yaml:"failFast"`
+		Steps    []JobStep `yaml:"steps"`
+	}
+
+	// Where a job reads a token from; exactly one of the fields is set
+	JobTokenSource struct {
+		// A file containing the token
+		File string `yaml:"file"`
+		// An environment variable containing the token
+		Env string `yaml:"env"`
+	}
+
+	// The per-step options of a job; unset options fall back to the job's defaults
+	JobStepOptions struct {
+		// The name of one of the job's token sources
+		Token string `yaml:"token"`
+		// A comma-separated list of caches to use
+		Cache     string `yaml:"cache"`
+		Recursive *bool  `yaml:"recursive"`
+		// How sync steps detect changed objects, as in `object sync --compare`
+		Compare string `yaml:"compare"`
+		// Whether sync steps delete objects missing from the source
+		Delete *bool `yaml:"delete"`
+		// The checksum type used to verify transferred objects
+		Checksum string `yaml:"checksum"`
+		// How many times a step that fails with a retryable error is repeated
+		Retries *int `yaml:"retries"`
+	}
+
+	JobStep struct {
+		Name string `yaml:"name"`
+		// One of get, put, or sync
+		Action      JobAction `yaml:"action"`
+		Source      string    `yaml:"source"`
+		Destination string    `yaml:"destination"`
+		// The names of the steps that must succeed before this one runs
+		DependsOn      []string `yaml:"dependsOn"`
+		JobStepOptions `yaml:",inline"`
+	}
+
+	JobAction string
+
+	JobStepStatus string
+
+	// The outcome of one step of a job
+	JobStepResult struct {
+		Step   JobStep
+		Status JobStepStatus
+		// The number of times the step was run; 0 if it was skipped
+		Attempts  int
+		Transfers []TransferResults
+		// The destination objects removed by a sync step
+		Deleted  []string
+		Start    time.Time
+		Duration time.Duration
+		Err      error
+	}
+)
+
+const (
+	JobActionGet  JobAction = "get"
+	JobActionPut  JobAction = "put"
+	JobActionSync JobAction = "sync"
+
+	JobStepSucceeded JobStepStatus = "succeeded"
+	JobStepFailed    JobStepStatus = "failed"
+	JobStepSkipped   JobStepStatus = "skipped"
+
+	jobVersion = 1
+)
+
+// The delay before a failed step is retried, multiplied by the attempt number
+var jobRetryDelay = 5 * time.Second
+
+// Parse and validate a job file; see Job for the format
+func ParseJob(reader io.Reader) (job *Job, err error) {
+	decoder := yaml.NewDecoder(reader)
+	decoder.KnownFields(true)
+	job = &Job{}
+	if err = decoder.Decode(job); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("the job file is empty")
+		}
+		return nil, errors.Wrap(err, "failed to parse the job file")
+	}
+	if err = job.Validate(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Check that the job is well-formed: its steps have unique names, known actions,
+// and valid options, and their dependencies exist and form no cycle
+func (job *Job) Validate() error {
+	if job.Version != 0 && job.Version != jobVersion {
+		return errors.Errorf("unsupported job file version %d; the supported version is %d", job.Version, jobVersion)
+	}
+	if len(job.Steps) == 0 {
+		return errors.New("the job has no steps")
+	}
+	for name, source := range job.Tokens {
+		if (source.File == "") == (source.Env == "") {
+			return errors.Errorf("token %q must set exactly one of file or env", name)
+		}
+	}
+	if err := job.validateOptions("the job defaults", job.Defaults); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(job.Steps))
+	for idx := range job.Steps {
+		step := &job.Steps[idx]
+		if step.Name == "" {
+			return errors.Errorf("step %d of the job has no name", idx+1)
+		}
+		if names[step.Name] {
+			return errors.Errorf("the job has more than one step named %q", step.Name)
+		}
+		names[step.Name] = true
+		if err := step.validate(); err != nil {
+			return err
+		}
+		if err := job.validateOptions(fmt.Sprintf("step %q", step.Name), step.JobStepOptions); err != nil {
+			return err
+		}
+	}
+	for _, step := range job.Steps {
+		for _, dep := range step.DependsOn {
+			if !names[dep] {
+				return errors.Errorf("step %q depends on unknown step %q", step.Name, dep)
+			}
+		}
+	}
+	_, err := job.Order()
+	return err
+}
+
+func (step *JobStep) validate() error {
+	if step.Source == "" || step.Destination == "" {
+		return errors.Errorf("step %q must set both a source and a destination", step.Name)
+	}
+	srcLocal, destLocal := isLocalLocation(step.Source), isLocalLocation(step.Destination)
+	switch step.Action {
+	case JobActionGet:
+		if srcLocal || !destLocal {
+			return errors.Errorf("get step %q must copy a remote source to a local destination", step.Name)
+		}
+	case JobActionPut:
+		if !srcLocal || destLocal {
+			return errors.Errorf("put step %q must copy a local source to a remote destination", step.Name)
+		}
+	case JobActionSync:
+		if srcLocal == destLocal {
+			return errors.Errorf("sync step %q must have exactly one remote location", step.Name)
+		}
+	case "":
+		return errors.Errorf("step %q has no action", step.Name)
+	default:
+		return errors.Errorf("step %q has unknown action %q; supported actions are get, put, and sync", step.Name, step.Action)
+	}
+	if step.Action != JobActionSync && (step.Compare != "" || step.Delete != nil) {
+		return errors.Errorf("step %q sets compare or delete, which only apply to sync steps", step.Name)
+	}
+	return nil
+}
+
+func (job *Job) validateOptions(where string, options JobStepOptions) error {
+	if options.Token != "" {
+		if _, ok := job.Tokens[options.Token]; !ok {
+			return errors.Errorf("unknown token %q in %s", options.Token, where)
+		}
+	}
+	if _, err := ParseSyncLevel(options.Compare); err != nil {
+		return errors.Wrapf(err, "invalid comparison in %s", where)
+	}
+	if _, err := ParseChecksumType(options.Checksum); err != nil {
+		return errors.Wrapf(err, "invalid checksum in %s", where)
+	}
+	if options.Retries != nil && *options.Retries < 0 {
+		return errors.Errorf("negative number of retries in %s", where)
+	}
+	return nil
+}
+
+// The steps of the job in the order they run: the order of the job file, with
+// each step moved after the steps it depends on
+func (job *Job) Order() ([]JobStep, error) {
+	indices := make(map[string]int, len(job.Steps))
+	for idx, step := range job.Steps {
+		indices[step.Name] = idx
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(job.Steps))
+	order := make([]JobStep, 0, len(job.Steps))
+	var visit func(idx int, path []string) error
+	visit = func(idx int, path []string) error {
+		step := job.Steps[idx]
+		path = append(path, step.Name)
+		switch state[idx] {
+		case visiting:
+			return errors.Errorf("the job's step dependencies form a cycle: %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		state[idx] = visiting
+		for _, dep := range step.DependsOn {
+			depIdx, ok := indices[dep]
+			if !ok {
+				return errors.Errorf("step %q depends on unknown step %q", step.Name, dep)
+			}
+			if err := visit(depIdx, path); err != nil {
+				return err
+			}
+		}
+		state[idx] = visited
+		order = append(order, step)
+		return nil
+	}
+	for idx := range job.Steps {
+		if err := visit(idx, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// The step's options, with the unset ones taken from the job's defaults
+func (job *Job) stepOptions(step JobStep) JobStepOptions {
+	options := step.JobStepOptions
+	if options.Token == "" {
+		options.Token = job.Defaults.Token
+	}
+	if options.Cache == "" {
+		options.Cache = job.Defaults.Cache
+	}
+	if options.Recursive == nil {
+		options.Recursive = job.Defaults.Recursive
+	}
+	if options.Checksum == "" {
+		options.Checksum = job.Defaults.Checksum
+	}
+	if options.Retries == nil {
+		options.Retries = job.Defaults.Retries
+	}
+	if step.Action == JobActionSync {
+		if options.Compare == "" {
+			options.Compare = job.Defaults.Compare
+		}
+		if options.Delete == nil {
+			options.Delete = job.Defaults.Delete
+		}
+	}
+	return options
+}
+
+// The transfer options for a step
+func (job *Job) transferOptions(options JobStepOptions) ([]TransferOption, error) {
+	var transferOptions []TransferOption
+	if options.Token != "" {
+		source := job.Tokens[options.Token]
+		if source.File != "" {
+			transferOptions = append(transferOptions, WithTokenLocation(source.File))
+		} else {
+			token, ok := os.LookupEnv(source.Env)
+			if !ok || strings.TrimSpace(token) == "" {
+				return nil, errors.Errorf("the environment variable %s for token %q is not set", source.Env, options.Token)
+			}
+			transferOptions = append(transferOptions, WithToken(strings.TrimSpace(token)))
+		}
+	}
+	if options.Cache != "" {
+		caches, err := utils.GetPreferredCaches(options.Cache)
+		if err != nil {
+			return nil, err
+		}
+		transferOptions = append(transferOptions, WithCaches(caches...))
+	}
+	checksumType, err := ParseChecksumType(options.Checksum)
+	if err != nil {
+		return nil, err
+	}
+	if checksumType != ChecksumNone {
+		transferOptions = append(transferOptions, WithChecksum(checksumType))
+	}
+	if options.Compare != "" {
+		syncLevel, err := ParseSyncLevel(options.Compare)
+		if err != nil {
+			return nil, err
+		}
+		transferOptions = append(transferOptions, WithSynchronize(syncLevel))
+	}
+	if options.Delete != nil {
+		transferOptions = append(transferOptions, WithSyncDelete(*options.Delete))
+	}
+	return transferOptions, nil
+}
+
+// Run a single attempt of a step
+func runJobStep(ctx context.Context, step JobStep, recursive bool, options []TransferOption) (transfers []TransferResults, deleted []string, err error) {
+	switch step.Action {
+	case JobActionGet:
+		transfers, err = DoGet(ctx, step.Source, step.Destination, recursive, options...)
+	case JobActionPut:
+		transfers, err = DoPut(ctx, step.Source, step.Destination, recursive, options...)
+	case JobActionSync:
+		var plan *SyncPlan
+		if plan, err = PlanSync(ctx, step.Source, step.Destination, options...); err != nil {
+			return
+		}
+		if transfers, err = ExecuteSyncPlan(ctx, plan, options...); err != nil {
+			return
+		}
+		for _, entry := range plan.Entries {
+			if entry.Action == SyncActionDelete {
+				deleted = append(deleted, entry.Destination)
+			}
+		}
+	default:
+		err = errors.Errorf("unknown action %q", step.Action)
+	}
+	return
+}
+
+// Run the steps of a job, returning the outcome of each in the order they ran.
+// A failed step doesn't stop the steps that don't depend on it unless the job
+// sets FailFast.  The options are passed to every transfer, ahead of the ones
+// derived from the job; the error is set if any step failed or was skipped.
+func DoJob(ctx context.Context, job *Job, options ...TransferOption) (results []JobStepResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Debugln("Panic captured while attempting to run a job (DoJob):", r)
+			log.Debugln("Panic caused by the following", string(debug.Stack()))
+			ret := fmt.Sprintf("Unrecoverable error (panic) captured in DoJob: %v", r)
+			err = errors.New(ret)
+		}
+	}()
+
+	order, err := job.Order()
+	if err != nil {
+		return
+	}
+	results = make([]JobStepResult, 0, len(order))
+	statuses := make(map[string]JobStepStatus, len(order))
+	failed := false
+	var firstErr error
+	var firstFailed string
+	for _, step := range order {
+		result := JobStepResult{Step: step, Status: JobStepSkipped}
+		for _, dep := range step.DependsOn {
+			if statuses[dep] != JobStepSucceeded {
+				result.Err = errors.Errorf("step %q did not succeed", dep)
+				break
+			}
+		}
+		if result.Err == nil && failed && job.FailFast {
+			result.Err = errors.New("an earlier step failed")
+		}
+		if result.Err == nil && ctx.Err() != nil {
+			result.Err = ctx.Err()
+		}
+		if result.Err == nil {
+			runJobStepWithRetries(ctx, job, &result, options)
+		}
+		if result.Status != JobStepSucceeded {
+			if result.Status == JobStepFailed {
+				log.Errorf("Step %q of the job failed: %v", step.Name, result.Err)
+			} else {
+				log.Warningf("Skipping step %q of the job: %v", step.Name, result.Err)
+			}
+			if firstErr == nil {
+				firstErr = result.Err
+				firstFailed = step.Name
+			}
+			failed = true
+		}
+		statuses[step.Name] = result.Status
+		results = append(results, result)
+	}
+
+	if firstErr != nil {
+		incomplete := 0
+		for _, result := range results {
+			if result.Status != JobStepSucceeded {
+				incomplete++
+			}
+		}
+		err = errors.Wrapf(firstErr, "%d of the job's %d steps did not succeed; step %q", incomplete, len(results), firstFailed)
+	}
+	return
+}
+
+func runJobStepWithRetries(ctx context.Context, job *Job, result *JobStepResult, options []TransferOption) {
+	stepOptions := job.stepOptions(result.Step)
+	transferOptions, err := job.transferOptions(stepOptions)
+	result.Start = time.Now()
+	defer func() {
+		result.Duration = time.Since(result.Start)
+	}()
+	if err != nil {
+		result.Status = JobStepFailed
+		result.Err = err
+		return
+	}
+	transferOptions = append(append([]TransferOption{}, options...), transferOptions...)
+	recursive := stepOptions.Recursive != nil && *stepOptions.Recursive
+	retries := 0
+	if stepOptions.Retries != nil {
+		retries = *stepOptions.Retries
+	}
+
+	for {
+		result.Attempts++
+		log.Infof("Running step %q of the job: %s %s -> %s", result.Step.Name, result.Step.Action, result.Step.Source, result.Step.Destination)
+		var transfers []TransferResults
+		transfers, result.Deleted, result.Err = runJobStep(ctx, result.Step, recursive, transferOptions)
+		result.Transfers = append(result.Transfers, transfers...)
+		if result.Err == nil {
+			result.Status = JobStepSucceeded
+			return
+		}
+		result.Status = JobStepFailed
+		if result.Attempts > retries || !ShouldRetry(result.Err) {
+			return
+		}
+		delay := jobRetryDelay * time.Duration(result.Attempts)
+		log.Warningf("Step %q of the job failed with a retryable error; retrying in %s: %v", result.Step.Name, delay, result.Err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testJob = `
+version: 1
+tokens:
+  analysis:
+    file: /tmp/analysis.tok
+  ci:
+    env: PELICAN_TEST_JOB_TOKEN
+defaults:
+  token: analysis
+  retries: 2
+steps:
+  - name: results
+    action: put
+    source: ./results
+    destination: osdf:///ospool/ap20/results
+    recursive: true
+    dependsOn: [inputs]
+  - name: inputs
+    action: sync
+    source: osdf:///ospool/ap20/inputs
+    destination: ./inputs
+    compare: mtime
+    delete: true
+  - name: config
+    action: get
+    source: osdf:///ospool/ap20/config.yaml
+    destination: ./config.yaml
+    token: ci
+    retries: 0
+`
+
+func TestParseJob(t *testing.T) {
+	job, err := ParseJob(strings.NewReader(testJob))
+	require.NoError(t, err)
+	require.Len(t, job.Steps, 3)
+
+	order, err := job.Order()
+	require.NoError(t, err)
+	names := []string{}
+	for _, step := range order {
+		names = append(names, step.Name)
+	}
+	assert.Equal(t, []string{"inputs", "results", "config"}, names)
+
+	options := job.stepOptions(job.Steps[2])
+	assert.Equal(t, "ci", options.Token)
+	assert.Equal(t, 0, *options.Retries)
+	options = job.stepOptions(job.Steps[0])
+	assert.Equal(t, "analysis", options.Token)
+	assert.Equal(t, 2, *options.Retries)
+	assert.True(t, *options.Recursive)
+}
+
+func TestParseJobErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		job  string
+		err  string
+	}{
+		{"empty", ``, "empty"},
+		{"unknown-field", "steps:\n  - name: a\n    acton: get\n", "field acton not found"},
+		{"no-steps", "version: 1\n", "no steps"},
+		{"bad-version", "version: 2\nsteps: []\n", "unsupported job file version 2"},
+		{"duplicate", "steps:\n  - {name: a, action: get, source: 'osdf:///a', destination: a}\n  - {name: a, action: get, source: 'osdf:///b', destination: b}\n", `more than one step named "a"`},
+		{"bad-action", "steps:\n  - {name: a, action: copy, source: 'osdf:///a', destination: a}\n", `unknown action "copy"`},
+		{"wrong-direction", "steps:\n  - {name: a, action: put, source: 'osdf:///a', destination: a}\n", "local source to a remote destination"},
+		{"compare-on-get", "steps:\n  - {name: a, action: get, source: 'osdf:///a', destination: a, compare: size}\n", "only apply to sync steps"},
+		{"unknown-token", "steps:\n  - {name: a, action: get, source: 'osdf:///a', destination: a, token: missing}\n", `unknown token "missing"`},
+		{"bad-token", "tokens:\n  t: {file: a, env: B}\nsteps:\n  - {name: a, action: get, source: 'osdf:///a', destination: a}\n", "exactly one of file or env"},
+		{"unknown-dependency", "steps:\n  - {name: a, action: get, source: 'osdf:///a', destination: a, dependsOn: [b]}\n", `unknown step "b"`},
+		{"cycle", "steps:\n  - {name: a, action: get, source: 'osdf:///a', destination: a, dependsOn: [b]}\n  - {name: b, action: get, source: 'osdf:///b', destination: b, dependsOn: [a]}\n", "cycle: a -> b -> a"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseJob(strings.NewReader(test.job))
+			assert.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestDoJobSkipsDependents(t *testing.T) {
+	job, err := ParseJob(strings.NewReader(`
+tokens:
+  ci:
+    env: PELICAN_TEST_JOB_TOKEN
+steps:
+  - {name: a, action: get, source: 'osdf:///a', destination: a, token: ci}
+  - {name: b, action: get, source: 'osdf:///b', destination: b, dependsOn: [a]}
+  - {name: c, action: get, source: 'osdf:///c', destination: c, token: ci}
+`))
+	require.NoError(t, err)
+	t.Setenv("PELICAN_TEST_JOB_TOKEN", "")
+
+	results, err := DoJob(context.Background(), job)
+	assert.ErrorContains(t, err, `3 of the job's 3 steps did not succeed; step "a"`)
+	require.Len(t, results, 3)
+	assert.Equal(t, JobStepFailed, results[0].Status)
+	assert.ErrorContains(t, results[0].Err, "PELICAN_TEST_JOB_TOKEN")
+	assert.Equal(t, JobStepSkipped, results[1].Status)
+	assert.Equal(t, 0, results[1].Attempts)
+	// Steps that don't depend on the failed one still run
+	assert.Equal(t, JobStepFailed, results[2].Status)
+
+	job.FailFast = true
+	results, err = DoJob(context.Background(), job)
+	assert.Error(t, err)
+	assert.Equal(t, JobStepSkipped, results[2].Status)
+}
@@ -0,0 +1,124 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/studio-b12/gowebdav"
+
+	"github.com/pelicanplatform/pelican/pelican_url"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// Characters that make a path component a glob pattern, as understood by path.Match
+const globMetaChars = "*?["
+
+// Returns true if the remote path contains glob patterns
+func hasGlobMeta(remotePath string) bool {
+	return strings.ContainsAny(remotePath, globMetaChars)
+}
+
+// Split a remote path into the longest leading collection without any glob patterns
+// and the remaining path components, each of which is matched as a glob pattern.
+//
+// For example, "/foo/bar/*/data-?.txt" splits into "/foo/bar" and ["*", "data-?.txt"].
+func splitGlobPath(remotePath string) (base string, patterns []string, err error) {
+	components := strings.Split(strings.Trim(path.Clean(remotePath), "/"), "/")
+	idx := 0
+	for ; idx < len(components); idx++ {
+		if hasGlobMeta(components[idx]) {
+			break
+		}
+	}
+	base = "/" + path.Join(components[:idx]...)
+	patterns = components[idx:]
+	for _, pattern := range patterns {
+		if _, err = path.Match(pattern, ""); err != nil {
+			return "", nil, errors.Wrapf(err, "invalid glob pattern %q", pattern)
+		}
+	}
+	return
+}
+
+// List the objects and collections matching a remote path containing glob patterns.
+//
+// Each collection along the path is listed on the server and its entries are matched
+// against the corresponding pattern on the client; only collections are descended into
+// for patterns that are not the final path component.  Results are sorted by name.
+func globHttp(remoteUrl *pelican_url.PelicanURL, dirResp server_structs.DirectorResponse, token *tokenGenerator) (fileInfos []FileInfo, err error) {
+	if dirResp.XPelNsHdr.CollectionsUrl == nil {
+		return nil, errors.Errorf("Collections URL not found in director response. Are you sure there's an origin for prefix %s that supports listings?", dirResp.XPelNsHdr.Namespace)
+	}
+	base, patterns, err := splitGlobPath(remoteUrl.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	project := searchJobAd(projectName)
+	client := createWebDavClient(dirResp.XPelNsHdr.CollectionsUrl, token, project)
+
+	collections := []string{base}
+	for idx, pattern := range patterns {
+		last := idx == len(patterns)-1
+		matchedCollections := []string{}
+		for _, collection := range collections {
+			infos, err := client.ReadDir(collection)
+			if err != nil {
+				if gowebdav.IsErrNotFound(err) {
+					if idx > 0 {
+						// The collection disappeared after it was matched; nothing to list
+						continue
+					}
+					return nil, errors.Errorf("404: collection %s not found", collection)
+				} else if gowebdav.IsErrCode(err, http.StatusMethodNotAllowed) {
+					return nil, errors.Errorf("405: object listings are not supported by the discovered origin")
+				}
+				return nil, errors.Wrapf(err, "failed to read remote collection %s", collection)
+			}
+			for _, info := range infos {
+				if matched, _ := path.Match(pattern, info.Name()); !matched {
+					continue
+				}
+				name := path.Join(collection, info.Name())
+				if last {
+					fileInfos = append(fileInfos, FileInfo{
+						Name:         name,
+						Size:         info.Size(),
+						ModTime:      info.ModTime(),
+						IsCollection: info.IsDir(),
+					})
+				} else if info.IsDir() {
+					matchedCollections = append(matchedCollections, name)
+				}
+			}
+		}
+		collections = matchedCollections
+	}
+
+	if len(fileInfos) == 0 {
+		return nil, errors.Errorf("no objects or collections match %s", remoteUrl.Path)
+	}
+	sort.Slice(fileInfos, func(i, j int) bool { return fileInfos[i].Name < fileInfos[j].Name })
+	return fileInfos, nil
+}
@@ -0,0 +1,108 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+
+	"github.com/pelicanplatform/pelican/pelican_url"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/test_utils"
+)
+
+func TestSplitGlobPath(t *testing.T) {
+	base, patterns, err := splitGlobPath("/foo/bar/*/data-?.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "/foo/bar", base)
+	assert.Equal(t, []string{"*", "data-?.txt"}, patterns)
+
+	base, patterns, err = splitGlobPath("/*.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "/", base)
+	assert.Equal(t, []string{"*.txt"}, patterns)
+
+	base, patterns, err = splitGlobPath("/foo/bar/")
+	require.NoError(t, err)
+	assert.Equal(t, "/foo/bar", base)
+	assert.Empty(t, patterns)
+
+	_, _, err = splitGlobPath("/foo/[a-")
+	assert.Error(t, err)
+
+	assert.True(t, hasGlobMeta("/foo/*"))
+	assert.True(t, hasGlobMeta("/foo/[ab]"))
+	assert.False(t, hasGlobMeta("/foo/bar"))
+}
+
+func TestGlobHttp(t *testing.T) {
+	ctx, _, _ := test_utils.TestContext(context.Background(), t)
+	test_utils.InitClient(t, map[string]any{})
+
+	fs := webdav.NewMemFS()
+	for _, dir := range []string{"/data", "/data/run1", "/data/run2", "/data/other"} {
+		require.NoError(t, fs.Mkdir(ctx, dir, 0755))
+	}
+	for _, name := range []string{"/data/a.txt", "/data/b.txt", "/data/c.csv", "/data/run1/out.txt", "/data/run2/out.txt", "/data/run2/log.csv", "/data/other/out.txt"} {
+		f, err := fs.OpenFile(ctx, name, os.O_RDWR|os.O_CREATE, 0644)
+		require.NoError(t, err)
+		_, err = f.Write([]byte("contents of " + name))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+	server := httptest.NewServer(&webdav.Handler{FileSystem: fs, LockSystem: webdav.NewMemLS()})
+	defer server.Close()
+	serverUrl, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	dirResp := server_structs.DirectorResponse{XPelNsHdr: server_structs.XPelNs{Namespace: "/data", CollectionsUrl: serverUrl}}
+
+	glob := func(pattern string) ([]string, error) {
+		infos, err := globHttp(&pelican_url.PelicanURL{Scheme: "pelican", Host: "something.com", Path: pattern}, dirResp, nil)
+		names := []string{}
+		for _, info := range infos {
+			names = append(names, info.Name)
+		}
+		return names, err
+	}
+
+	names, err := glob("/data/*.txt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/data/a.txt", "/data/b.txt"}, names)
+
+	names, err = glob("/data/run?/*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/data/run1/out.txt", "/data/run2/log.csv", "/data/run2/out.txt"}, names)
+
+	names, err = glob("/data/*/out.txt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/data/other/out.txt", "/data/run1/out.txt", "/data/run2/out.txt"}, names)
+
+	_, err = glob("/data/*.json")
+	assert.ErrorContains(t, err, "no objects or collections match")
+
+	_, err = glob("/missing/*")
+	assert.ErrorContains(t, err, "404")
+}
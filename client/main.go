@@ -37,6 +37,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/pelican_url"
 	"github.com/pelicanplatform/pelican/server_structs"
 )
@@ -52,6 +53,8 @@ type FileInfo struct {
 	Size         int64
 	ModTime      time.Time
 	IsCollection bool
+	// Checksums of the object, keyed by the (lowercase) digest algorithm name
+	Checksums map[string]string `json:",omitempty"`
 }
 
 // Given a remote path, use the client's wisdom to parse it as a Pelican URL, including metadata discovery.
@@ -65,7 +68,7 @@ func ParseRemoteAsPUrl(ctx context.Context, rp string) (*pelican_url.PelicanURL,
 	}
 
 	// Set up options that get passed from Parse --> PopulateFedInfo and may be used when querying the Director
-	client := &http.Client{Transport: config.GetTransport()}
+	client := &http.Client{Transport: config.GetTransport(), Timeout: param.Client_DiscoveryTimeout.GetDuration()}
 	pOptions := []pelican_url.ParseOption{pelican_url.ShouldDiscover(true), pelican_url.ValidateQueryParams(true)}
 	dOptions := []pelican_url.DiscoveryOption{pelican_url.UseCached(true), pelican_url.WithContext(ctx), pelican_url.WithClient(client), pelican_url.WithUserAgent(getUserAgent(""))}
 
@@ -99,13 +102,56 @@ func ParseRemoteAsPUrl(ctx context.Context, rp string) (*pelican_url.PelicanURL,
 		pOptions,
 		dOptions,
 	)
-	if err != nil {
+	if errors.Is(err, pelican_url.MetadataTimeoutErr) {
+		return nil, &TimeoutError{Phase: TimeoutPhaseDiscovery, Timeout: client.Timeout, Err: err}
+	} else if err != nil {
 		return nil, err
 	}
 
 	return pUrl, nil
 }
 
+// Configure a token generator from the token-related transfer options
+func applyTokenOptions(token *tokenGenerator, options ...TransferOption) {
+	for _, option := range options {
+		switch option.Ident() {
+		case identTransferOptionTokenLocation{}:
+			token.SetTokenLocation(option.Value().(string))
+		case identTransferOptionAcquireToken{}:
+			token.EnableAcquire = option.Value().(bool)
+		case identTransferOptionToken{}:
+			token.SetToken(option.Value().(string))
+		}
+	}
+}
+
+// Resolve the remote URL, director response, and token needed to stat an object
+func prepareStat(ctx context.Context, destination string, options ...TransferOption) (pUrl *pelican_url.PelicanURL, dirResp server_structs.DirectorResponse, token *tokenGenerator, err error) {
+	pUrl, err = ParseRemoteAsPUrl(ctx, destination)
+	if err != nil {
+		return
+	}
+
+	dirResp, err = GetDirectorInfoForPath(ctx, pUrl, http.MethodGet, "")
+	if err != nil {
+		return
+	}
+
+	token = newTokenGenerator(pUrl, &dirResp, false, true)
+	applyTokenOptions(token, options...)
+
+	if dirResp.XPelNsHdr.RequireToken {
+		tokenContents, tokenErr := token.get()
+		if tokenErr != nil || tokenContents == "" {
+			err = errors.Wrap(tokenErr, "failed to get token for transfer")
+			return
+		}
+	} else {
+		token = nil
+	}
+	return
+}
+
 // Check the size of a remote file in an origin
 func DoStat(ctx context.Context, destination string, options ...TransferOption) (fileInfo *FileInfo, err error) {
 
@@ -119,11 +165,45 @@ func DoStat(ctx context.Context, destination string, options ...TransferOption)
 		}
 	}()
 
-	pUrl, err := ParseRemoteAsPUrl(ctx, destination)
+	te, err := NewTransferEngine(ctx)
 	if err != nil {
-		return
+		return nil, err
+	}
+
+	defer func() {
+		if err := te.Shutdown(); err != nil {
+			log.Errorln("Failure when shutting down transfer engine:", err)
+		}
+	}()
+
+	pUrl, dirResp, token, err := prepareStat(ctx, destination, options...)
+	if err != nil {
+		return nil, err
 	}
 
+	if statInfo, err := statHttp(pUrl, dirResp, token); err != nil {
+		return nil, errors.Wrap(err, "failed to do the stat")
+	} else {
+		return &statInfo, nil
+	}
+}
+
+// Stat a remote object, including its checksums, custom metadata, and which caches
+// in the federation currently hold a copy.
+//
+// Only the basic stat is required to succeed; failures to determine the checksums or
+// cache availability are logged and the corresponding fields are left empty.
+func DoStatDetailed(ctx context.Context, destination string, options ...TransferOption) (objectStat *ObjectStat, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Debugln("Panic captured while attempting to stat:", r)
+			log.Debugln("Panic caused by the following", string(debug.Stack()))
+			ret := fmt.Sprintf("Unrecoverable error (panic) while performing detailed stat: %v", r)
+			err = errors.New(ret)
+			return
+		}
+	}()
+
 	te, err := NewTransferEngine(ctx)
 	if err != nil {
 		return nil, err
@@ -135,37 +215,76 @@ func DoStat(ctx context.Context, destination string, options ...TransferOption)
 		}
 	}()
 
-	dirResp, err := GetDirectorInfoForPath(ctx, pUrl, http.MethodGet, "")
+	pUrl, dirResp, token, err := prepareStat(ctx, destination, options...)
 	if err != nil {
 		return nil, err
 	}
 
-	token := newTokenGenerator(pUrl, &dirResp, false, true)
-	for _, option := range options {
-		switch option.Ident() {
-		case identTransferOptionTokenLocation{}:
-			token.SetTokenLocation(option.Value().(string))
-		case identTransferOptionAcquireToken{}:
-			token.EnableAcquire = option.Value().(bool)
-		case identTransferOptionToken{}:
-			token.SetToken(option.Value().(string))
+	statInfo, err := statHttp(pUrl, dirResp, token)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to do the stat")
+	}
+	objectStat = &ObjectStat{FileInfo: statInfo}
+
+	if !statInfo.IsCollection {
+		if objectStat.Checksums, objectStat.Metadata, err = headObjectMetadata(ctx, pUrl, dirResp, token); err != nil {
+			log.Warningln("Unable to determine checksums for", pUrl.Path, ":", err)
+		}
+		if objectStat.Availability, err = queryObjectAvailability(ctx, pUrl, token); err != nil {
+			log.Warningln("Unable to determine cache availability for", pUrl.Path, ":", err)
 		}
 	}
+	return objectStat, nil
+}
 
-	if dirResp.XPelNsHdr.RequireToken {
-		tokenContents, err := token.get()
-		if err != nil || tokenContents == "" {
-			return nil, errors.Wrap(err, "failed to get token for transfer")
+// Verify a local file against the checksum the federation reports for a remote object.
+//
+// If checksumType is ChecksumNone, the first checksum type reported by the server (in
+// order of preference: sha256, md5, adler32) is used.  Returns the checksum type that
+// was verified; a mismatch results in a ChecksumMismatchError.
+func DoVerify(ctx context.Context, remoteObject string, localFile string, checksumType ChecksumType, options ...TransferOption) (verified ChecksumType, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Debugln("Panic captured while attempting to verify:", r)
+			log.Debugln("Panic caused by the following", string(debug.Stack()))
+			ret := fmt.Sprintf("Unrecoverable error (panic) while verifying checksum: %v", r)
+			err = errors.New(ret)
+			return
 		}
-	} else {
-		token = nil
+	}()
+
+	if info, statErr := os.Stat(localFile); statErr != nil {
+		return ChecksumNone, errors.Wrapf(statErr, "failed to stat local file %s", localFile)
+	} else if info.IsDir() {
+		return ChecksumNone, errors.Errorf("local path %s is a directory; only single objects can be verified", localFile)
 	}
 
-	if statInfo, err := statHttp(pUrl, dirResp, token); err != nil {
-		return nil, errors.Wrap(err, "failed to do the stat")
-	} else {
-		return &statInfo, nil
+	te, err := NewTransferEngine(ctx)
+	if err != nil {
+		return ChecksumNone, err
+	}
+
+	defer func() {
+		if err := te.Shutdown(); err != nil {
+			log.Errorln("Failure when shutting down transfer engine:", err)
+		}
+	}()
+
+	pUrl, dirResp, token, err := prepareStat(ctx, remoteObject, options...)
+	if err != nil {
+		return ChecksumNone, err
 	}
+	objectUrl, err := objectMetadataUrl(pUrl, dirResp)
+	if err != nil {
+		return ChecksumNone, err
+	}
+	tokenContents := ""
+	if token != nil {
+		tokenContents, _ = token.get()
+	}
+
+	client := &http.Client{Transport: config.GetTransport()}
+	return verifyChecksum(ctx, client, objectUrl.String(), localFile, checksumType, tokenContents, "")
 }
 
 func GetObjectServerHostnames(ctx context.Context, testFile string) (urls []string, err error) {
@@ -253,7 +372,10 @@ func generateSortedObjServers(dirResp server_structs.DirectorResponse, preferred
 
 }
 
-// Function for the object ls command, we get target information for our remote object and eventually print out the contents of the specified object
+// Function for the object ls command, we get target information for our remote object and eventually print out the contents of the specified object.
+//
+// If the remote path contains glob patterns (e.g., /prefix/dir/*.txt), the collections along the
+// path are listed on the server and their entries are filtered on the client with path.Match.
 func DoList(ctx context.Context, remoteObject string, options ...TransferOption) (fileInfos []FileInfo, err error) {
 	// First, create a handler for any panics that occur
 	defer func() {
@@ -280,13 +402,28 @@ func DoList(ctx context.Context, remoteObject string, options ...TransferOption)
 		}
 	}()
 
-	dirResp, err := GetDirectorInfoForPath(ctx, pUrl, http.MethodGet, "")
+	// For glob patterns, the director and token are looked up for the
+	// collection preceding the first pattern
+	lookupUrl := pUrl
+	isGlob := hasGlobMeta(pUrl.Path)
+	if isGlob {
+		base, _, err := splitGlobPath(pUrl.Path)
+		if err != nil {
+			return nil, err
+		}
+		baseUrl := *pUrl
+		baseUrl.Path = base
+		lookupUrl = &baseUrl
+	}
+
+	dirResp, err := GetDirectorInfoForPath(ctx, lookupUrl, http.MethodGet, "")
 	if err != nil {
 		return nil, err
 	}
 
 	// Get our token if needed
-	token := newTokenGenerator(pUrl, &dirResp, false, true)
+	token := newTokenGenerator(lookupUrl, &dirResp, false, true)
+	withChecksums := false
 	for _, option := range options {
 		switch option.Ident() {
 		case identTransferOptionTokenLocation{}:
@@ -295,6 +432,8 @@ func DoList(ctx context.Context, remoteObject string, options ...TransferOption)
 			token.EnableAcquire = option.Value().(bool)
 		case identTransferOptionToken{}:
 			token.SetToken(option.Value().(string))
+		case identTransferOptionListChecksums{}:
+			withChecksums = option.Value().(bool)
 		}
 	}
 
@@ -307,11 +446,28 @@ func DoList(ctx context.Context, remoteObject string, options ...TransferOption)
 		token = nil
 	}
 
-	fileInfos, err = listHttp(pUrl, dirResp, token)
+	if isGlob {
+		fileInfos, err = globHttp(pUrl, dirResp, token)
+	} else {
+		fileInfos, err = listHttp(pUrl, dirResp, token)
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to perform list request")
 	}
 
+	if withChecksums {
+		for idx := range fileInfos {
+			if fileInfos[idx].IsCollection {
+				continue
+			}
+			objectUrl := *pUrl
+			objectUrl.Path = fileInfos[idx].Name
+			if fileInfos[idx].Checksums, _, err = headObjectMetadata(ctx, &objectUrl, dirResp, token); err != nil {
+				log.Warningln("Unable to determine checksums for", fileInfos[idx].Name, ":", err)
+			}
+		}
+	}
+
 	return fileInfos, nil
 }
 
@@ -368,7 +524,7 @@ func DoDelete(ctx context.Context, remoteDestination string, recursive bool, opt
 /*
 	Start of transfer for pelican object put, gets information from the target destination before doing our HTTP PUT request
 
-localObject: the source file/directory you would like to upload
+localObject: the source file/directory you would like to upload, StreamPath to read from standard input, or an s3:// object to read from object storage
 remoteDestination: the end location of the upload
 recursive: a boolean indicating if the source is a directory or not
 */
@@ -391,6 +547,12 @@ func DoPut(ctx context.Context, localObject string, remoteDestination string, re
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to parse remote object: %s", remoteDestination)
 	}
+	if recursive && isStreamPath(localObject) {
+		return nil, errors.New("a recursive upload cannot read from standard input")
+	}
+	if recursive && IsObjectStorageURL(localObject) {
+		return nil, errors.New("a recursive upload cannot read from object storage")
+	}
 
 	te, err := NewTransferEngine(ctx)
 	if err != nil {
@@ -425,11 +587,46 @@ func DoPut(ctx context.Context, localObject string, remoteDestination string, re
 	return
 }
 
+// Determine the local path a download of the remote object should be written to.
+//
+// If the destination is an existing directory, the object is placed inside it (unless
+// the download is recursive or auto-unpacked); otherwise, the destination is made absolute.
+// StreamPath is returned unchanged, and object storage locations are resolved by
+// resolveObjectStorageDestination.
+func resolveLocalDestination(pUrl *pelican_url.PelicanURL, localDestination string, recursive bool) string {
+	if isStreamPath(localDestination) {
+		return localDestination
+	}
+	if IsObjectStorageURL(localDestination) {
+		return resolveObjectStorageDestination(pUrl.Path, localDestination)
+	}
+	// get absolute path
+	localDestPath, _ := filepath.Abs(localDestination)
+
+	//Check if path exists or if its in a folder
+	if destStat, err := os.Stat(localDestPath); os.IsNotExist(err) {
+		trailingChar := ""
+		if string(localDestination[len(localDestination)-1]) == string(filepath.Separator) {
+			trailingChar = string(filepath.Separator)
+		}
+		localDestination = localDestPath + trailingChar
+	} else if destStat.IsDir() && pUrl.Query().Get(pelican_url.QueryPack) == "" {
+		// If we have an auto-pack request, it's OK for the destination to be a directory
+		// Otherwise, get the base name of the source and append it to the destination dir.
+		// Note that we use the pUrl.Path, as this will have stripped any query params for us
+		remoteObjectFilename := path.Base(pUrl.Path)
+		if !recursive {
+			localDestination = filepath.Join(localDestPath, remoteObjectFilename)
+		}
+	}
+	return localDestination
+}
+
 /*
 	Start of transfer for pelican object get, gets information from the target source before doing our HTTP GET request
 
 remoteObject: the source file/directory you would like to upload
-localDestination: the end location of the upload
+localDestination: the end location of the upload, StreamPath to write to standard output, or an s3:// object to write to object storage
 recursive: a boolean indicating if the source is a directory or not
 */
 func DoGet(ctx context.Context, remoteObject string, localDestination string, recursive bool, options ...TransferOption) (transferResults []TransferResults, err error) {
@@ -451,26 +648,14 @@ func DoGet(ctx context.Context, remoteObject string, localDestination string, re
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to parse remote object: %s", remoteObject)
 	}
-
-	// get absolute path
-	localDestPath, _ := filepath.Abs(localDestination)
-
-	//Check if path exists or if its in a folder
-	if destStat, err := os.Stat(localDestPath); os.IsNotExist(err) {
-		trailingChar := ""
-		if string(localDestination[len(localDestination)-1]) == string(filepath.Separator) {
-			trailingChar = string(filepath.Separator)
-		}
-		localDestination = localDestPath + trailingChar
-	} else if destStat.IsDir() && pUrl.Query().Get(pelican_url.QueryPack) == "" {
-		// If we have an auto-pack request, it's OK for the destination to be a directory
-		// Otherwise, get the base name of the source and append it to the destination dir.
-		// Note that we use the pUrl.Path, as this will have stripped any query params for us
-		remoteObjectFilename := path.Base(pUrl.Path)
-		if !recursive {
-			localDestination = path.Join(localDestPath, remoteObjectFilename)
-		}
+	if recursive && isStreamPath(localDestination) {
+		return nil, errors.New("a recursive download cannot be written to standard output")
 	}
+	if recursive && IsObjectStorageURL(localDestination) {
+		return nil, errors.New("a recursive download cannot be written to object storage")
+	}
+
+	localDestination = resolveLocalDestination(pUrl, localDestination, recursive)
 
 	success := false
 
@@ -526,19 +711,20 @@ func DoGet(ctx context.Context, remoteObject string, localDestination string, re
 		//    failed download from local-cache: server returned 404 Not Found
 		// versus:
 		//    failed to download file: transfer error: failed download from local-cache: server returned 404 Not Found
+		// The results are still returned so callers can report on each object
 		var te *TransferErrors
 		if errors.As(err, &te) {
 			if len(te.Unwrap()) == 1 {
 				var tae *TransferAttemptError
 				if errors.As(te.Unwrap()[0], &tae) {
-					return nil, tae
+					return transferResults, tae
 				} else {
-					return nil, errors.Wrap(err, "failed to download file")
+					return transferResults, errors.Wrap(err, "failed to download file")
 				}
 			}
-			return nil, te
+			return transferResults, te
 		}
-		return nil, errors.Wrap(err, "failed to download file")
+		return transferResults, errors.Wrap(err, "failed to download file")
 	} else {
 		return transferResults, err
 	}
@@ -581,8 +767,11 @@ func DoCopy(ctx context.Context, sourceFile string, destination string, recursiv
 		log.Debugf("Detected a GET from %s to %s", parsedSrc.String(), parsedDest.Path)
 		localPath = parsedDest.Path
 		remotePath = parsedSrc.String()
+	} else if parsedDest.Scheme != "" && parsedDest.Scheme != "file" && parsedSrc.Scheme != "" && parsedSrc.Scheme != "file" {
+		log.Debugf("Detected a remote copy from %s to %s", parsedSrc.String(), parsedDest.String())
+		return DoRemoteCopy(ctx, sourceFile, destination, recursive, options...)
 	} else {
-		return nil, errors.New("unable to determine direction of transfer.  Both source and destination are either local or remote")
+		return nil, errors.New("unable to determine direction of transfer.  Both source and destination are local")
 	}
 
 	if isPut {
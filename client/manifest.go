@@ -0,0 +1,272 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	// An object listed in a pre-staging manifest.  Each non-empty line of a
+	// manifest that is not a comment (starting with '#') has the form
+	//
+	//	<source URL> [destination] [<checksum type>:<checksum>]
+	//
+	// for example:
+	//
+	//	osdf:///ospool/ap20/data/input.dat inputs/input.dat sha256:9f86d081884c7d65...
+	//
+	// Relative destinations are relative to the directory the manifest is downloaded
+	// into; without a destination, the object is downloaded into that directory
+	// under its own name.
+	ManifestEntry struct {
+		Source       string
+		Destination  string
+		ChecksumType ChecksumType
+		Checksum     string
+		Line         int
+	}
+
+	// The outcome of downloading a manifest entry
+	ManifestResult struct {
+		Entry ManifestEntry
+		// The local file the object was downloaded to
+		LocalPath string
+		// The results of the transfer; empty if it never started (e.g., the director lookup failed)
+		Transfers []TransferResults
+		// The checksum of the downloaded file, in the type given in the manifest
+		Checksum string
+		Err      error
+	}
+)
+
+// Parse a pre-staging manifest; see ManifestEntry for the format
+func ParseManifest(reader io.Reader) (entries []ManifestEntry, err error) {
+	scanner := bufio.NewScanner(reader)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 3 {
+			return nil, errors.Errorf("line %d of the manifest has %d fields; expected a source URL, optionally followed by a destination and a checksum", lineNum, len(fields))
+		}
+		entry := ManifestEntry{Source: fields[0], Line: lineNum}
+		for _, field := range fields[1:] {
+			if ct, value, ok := parseManifestChecksum(field); ok {
+				if entry.ChecksumType != ChecksumNone {
+					return nil, errors.Errorf("line %d of the manifest has more than one checksum", lineNum)
+				}
+				entry.ChecksumType = ct
+				entry.Checksum = value
+			} else if entry.Destination == "" && entry.ChecksumType == ChecksumNone {
+				entry.Destination = field
+			} else {
+				return nil, errors.Errorf("line %d of the manifest has an invalid checksum %q; expected <type>:<checksum> with type sha256, md5, or adler32", lineNum, field)
+			}
+		}
+		if _, err := url.Parse(entry.Source); err != nil {
+			return nil, errors.Wrapf(err, "line %d of the manifest has an invalid source URL", lineNum)
+		}
+		entries = append(entries, entry)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read the manifest")
+	}
+	return
+}
+
+// Parse a "<type>:<checksum>" manifest field
+func parseManifestChecksum(field string) (ct ChecksumType, value string, ok bool) {
+	name, value, found := strings.Cut(field, ":")
+	if !found || value == "" {
+		return
+	}
+	if ct, err := ParseChecksumType(name); err == nil && ct != ChecksumNone {
+		return ct, value, true
+	}
+	return
+}
+
+// The local file a manifest entry is downloaded to
+func (entry *ManifestEntry) localPath(destDir string) (string, error) {
+	if entry.Destination != "" {
+		if filepath.IsAbs(entry.Destination) {
+			return filepath.Clean(entry.Destination), nil
+		}
+		return filepath.Join(destDir, entry.Destination), nil
+	}
+	sourceUrl, err := url.Parse(entry.Source)
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(sourceUrl.Path)
+	if name == "/" || name == "." {
+		return "", errors.Errorf("cannot determine a file name for %s; give a destination in the manifest", entry.Source)
+	}
+	return filepath.Join(destDir, name), nil
+}
+
+// Compare the downloaded file with the checksum listed in the manifest, removing
+// the file if it does not match so that it is not mistaken for good input
+func (result *ManifestResult) verify() error {
+	sum, err := computeFileChecksum(result.LocalPath, result.Entry.ChecksumType)
+	if err != nil {
+		return err
+	}
+	result.Checksum = result.Entry.ChecksumType.String() + ":" + hex.EncodeToString(sum)
+	if !result.Entry.ChecksumType.matches(result.Entry.Checksum, sum) {
+		if err := os.Remove(result.LocalPath); err != nil {
+			log.Warningf("Failed to remove %s after its checksum did not match the manifest: %v", result.LocalPath, err)
+		}
+		return errors.Errorf("%s checksum mismatch for %s: the manifest lists %s but the download has %s", result.Entry.ChecksumType.String(), result.LocalPath, result.Entry.Checksum, hex.EncodeToString(sum))
+	}
+	return nil
+}
+
+// Download every entry of a pre-staging manifest into destDir.  The entries are
+// transferred concurrently by the transfer engine (bounded by Client.WorkerCount),
+// and a failure of one entry does not stop the others; the returned results are
+// in manifest order.  The error is set if any entry failed.
+func DoManifestGet(ctx context.Context, entries []ManifestEntry, destDir string, options ...TransferOption) (results []ManifestResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Debugln("Panic captured while attempting to perform transfer (DoManifestGet):", r)
+			log.Debugln("Panic caused by the following", string(debug.Stack()))
+			ret := fmt.Sprintf("Unrecoverable error (panic) captured in DoManifestGet: %v", r)
+			err = errors.New(ret)
+		}
+	}()
+
+	results = make([]ManifestResult, len(entries))
+	for idx := range entries {
+		results[idx].Entry = entries[idx]
+	}
+
+	te, err := NewTransferEngine(ctx)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err := te.Shutdown(); err != nil {
+			log.Errorln("Failure when shutting down transfer engine:", err)
+		}
+	}()
+	tc, err := te.NewClient(options...)
+	if err != nil {
+		return
+	}
+
+	jobs := make(map[string]int, len(entries))
+	transferJobs := make([]*TransferJob, len(entries))
+	for idx := range results {
+		result := &results[idx]
+		if result.LocalPath, result.Err = result.Entry.localPath(destDir); result.Err != nil {
+			continue
+		}
+		sourceUrl, err := url.Parse(result.Entry.Source)
+		if err != nil {
+			result.Err = errors.Wrapf(err, "failed to parse remote object: %s", result.Entry.Source)
+			continue
+		}
+		tj, err := tc.NewTransferJob(context.Background(), sourceUrl, result.LocalPath, false, false)
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		jobs[tj.ID()] = idx
+		transferJobs[idx] = tj
+	}
+
+	// Results must be consumed while jobs are submitted or the workers stall
+	submitErr := make(chan error, 1)
+	go func() {
+		defer tc.Close()
+		for _, tj := range transferJobs {
+			if tj == nil {
+				continue
+			}
+			if err := tc.Submit(tj); err != nil {
+				submitErr <- err
+				return
+			}
+		}
+		submitErr <- nil
+	}()
+	for transferResult := range tc.Results() {
+		if idx, ok := jobs[transferResult.ID()]; ok {
+			results[idx].Transfers = append(results[idx].Transfers, transferResult)
+		}
+	}
+	if err = <-submitErr; err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return
+	}
+
+	failed := 0
+	var firstErr error
+	for idx := range results {
+		result := &results[idx]
+		if result.Err == nil && transferJobs[idx] != nil {
+			if lookupErr := transferJobs[idx].lookupErr; lookupErr != nil {
+				result.Err = lookupErr
+			} else if len(result.Transfers) == 0 {
+				result.Err = errors.New("the transfer produced no result")
+			}
+			for _, transfer := range result.Transfers {
+				if result.Err == nil && transfer.Error != nil {
+					result.Err = transfer.Error
+				}
+			}
+			if result.Err == nil && result.Entry.ChecksumType != ChecksumNone {
+				result.Err = result.verify()
+			}
+		}
+		if result.Err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = result.Err
+			}
+			log.Errorf("Failed to download %s (line %d of the manifest): %v", result.Entry.Source, result.Entry.Line, result.Err)
+		}
+	}
+	if failed > 0 {
+		// Keep the first failure in the chain so callers can tell whether it is retryable
+		err = errors.Wrapf(firstErr, "%d of the %d objects in the manifest failed to download; the first failure was", failed, len(results))
+	}
+	return
+}
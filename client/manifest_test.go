@@ -0,0 +1,113 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseManifest(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		manifest := `# Inputs for the analysis
+osdf:///ospool/data/input.dat inputs/input.dat sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+
+pelican://example.com/data/other.dat
+  /data/third.dat   MD5:d41d8cd98f00b204e9800998ecf8427e
+`
+		entries, err := ParseManifest(strings.NewReader(manifest))
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+
+		assert.Equal(t, ManifestEntry{
+			Source:       "osdf:///ospool/data/input.dat",
+			Destination:  "inputs/input.dat",
+			ChecksumType: ChecksumSHA256,
+			Checksum:     "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+			Line:         2,
+		}, entries[0])
+		assert.Equal(t, ManifestEntry{Source: "pelican://example.com/data/other.dat", Line: 4}, entries[1])
+		// A lone checksum is not mistaken for a destination
+		assert.Equal(t, ManifestEntry{
+			Source:       "/data/third.dat",
+			ChecksumType: ChecksumMD5,
+			Checksum:     "d41d8cd98f00b204e9800998ecf8427e",
+			Line:         5,
+		}, entries[2])
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, manifest := range map[string]string{
+			"too-many-fields":     "/data/a.dat a.dat sha256:abcd extra",
+			"two-checksums":       "/data/a.dat md5:abcd sha256:abcd",
+			"bad-checksum-type":   "/data/a.dat a.dat crc64:abcd",
+			"two-destinations":    "/data/a.dat a.dat b.dat",
+			"destination-last":    "/data/a.dat md5:abcd a.dat",
+			"invalid-source-url":  "https://[::1",
+			"error-on-later-line": "/data/a.dat\n/data/b.dat b.dat c.dat",
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := ParseManifest(strings.NewReader(manifest))
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestManifestEntryLocalPath(t *testing.T) {
+	destDir := t.TempDir()
+	localPath, err := (&ManifestEntry{Source: "osdf:///ospool/data/input.dat"}).localPath(destDir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(destDir, "input.dat"), localPath)
+
+	localPath, err = (&ManifestEntry{Source: "osdf:///ospool/data/input.dat", Destination: "inputs/renamed.dat"}).localPath(destDir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(destDir, "inputs", "renamed.dat"), localPath)
+
+	absolute := filepath.Join(t.TempDir(), "elsewhere.dat")
+	localPath, err = (&ManifestEntry{Source: "osdf:///ospool/data/input.dat", Destination: absolute}).localPath(destDir)
+	require.NoError(t, err)
+	assert.Equal(t, absolute, localPath)
+}
+
+func TestManifestResultVerify(t *testing.T) {
+	localPath := filepath.Join(t.TempDir(), "input.dat")
+	contents := []byte("test")
+	sum := sha256.Sum256(contents)
+
+	require.NoError(t, os.WriteFile(localPath, contents, 0644))
+	result := ManifestResult{
+		Entry:     ManifestEntry{ChecksumType: ChecksumSHA256, Checksum: hex.EncodeToString(sum[:])},
+		LocalPath: localPath,
+	}
+	require.NoError(t, result.verify())
+	assert.Equal(t, "sha256:"+hex.EncodeToString(sum[:]), result.Checksum)
+
+	// A file that does not match is removed
+	result.Entry.Checksum = strings.Repeat("0", 64)
+	assert.ErrorContains(t, result.verify(), "checksum mismatch")
+	assert.NoFileExists(t, localPath)
+}
@@ -0,0 +1,187 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/pelican_url"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// Detailed information about a remote object, as returned by DoStatDetailed
+type ObjectStat struct {
+	FileInfo
+	// Additional metadata reported by the object server, such as the ETag,
+	// content type, and any custom X-Pelican-Meta-* headers
+	Metadata map[string]string `json:",omitempty"`
+	// The caches in the federation and whether they currently hold the object
+	Availability []server_structs.ObjectAvailability `json:",omitempty"`
+}
+
+const metadataHeaderPrefix = "X-Pelican-Meta-"
+
+// The digest algorithms requested from the object server, in order of preference
+var requestedDigests = "crc32c, md5, sha-256"
+
+// Parse an RFC 3230 Digest header (e.g., "md5=XXX,crc32c=YYY") into a map of
+// lowercase algorithm name to value.  Malformed entries are ignored.
+func parseDigestHeader(header string) map[string]string {
+	digests := make(map[string]string)
+	for _, entry := range strings.Split(header, ",") {
+		alg, value, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || alg == "" || value == "" {
+			continue
+		}
+		digests[strings.ToLower(alg)] = value
+	}
+	return digests
+}
+
+// Determine the URL at which to query an object's metadata.  The origin (via the
+// namespace's collections URL) is preferred as the authoritative source.
+func objectMetadataUrl(dest *pelican_url.PelicanURL, dirResp server_structs.DirectorResponse) (*url.URL, error) {
+	var endpoint *url.URL
+	if dirResp.XPelNsHdr.CollectionsUrl != nil {
+		endpoint = dirResp.XPelNsHdr.CollectionsUrl
+	} else if len(dirResp.ObjectServers) > 0 {
+		endpoint = dirResp.ObjectServers[0]
+	} else {
+		return nil, errors.New("no object servers available to query for metadata")
+	}
+
+	objectUrl := *(dest.GetRawUrl())
+	objectUrl.Host = endpoint.Host
+	objectUrl.Scheme = endpoint.Scheme
+	objectUrl.RawQuery = ""
+	return &objectUrl, nil
+}
+
+// Issue a HEAD request against the object server to retrieve checksums and other
+// metadata for the object.
+func headObjectMetadata(ctx context.Context, dest *pelican_url.PelicanURL, dirResp server_structs.DirectorResponse, token *tokenGenerator) (checksums map[string]string, metadata map[string]string, err error) {
+	objectUrl, err := objectMetadataUrl(dest, dirResp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, objectUrl.String(), nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create HEAD request")
+	}
+	req.Header.Set("Want-Digest", requestedDigests)
+	req.Header.Set("User-Agent", getUserAgent(""))
+	if token != nil {
+		if tokenContents, err := token.get(); err == nil && tokenContents != "" {
+			req.Header.Set("Authorization", "Bearer "+tokenContents)
+		}
+	}
+
+	client := &http.Client{Transport: config.GetTransport()}
+	log.Debugln("Querying object metadata at", objectUrl.String())
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to query object metadata")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, errors.Errorf("object server %s returned status %d for HEAD request", objectUrl.Host, resp.StatusCode)
+	}
+
+	checksums = parseDigestHeader(resp.Header.Get("Digest"))
+	if len(checksums) == 0 {
+		checksums = nil
+	}
+
+	metadata = make(map[string]string)
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		metadata["ETag"] = etag
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		metadata["Content-Type"] = contentType
+	}
+	for key, values := range resp.Header {
+		if name, found := strings.CutPrefix(key, metadataHeaderPrefix); found && name != "" && len(values) > 0 {
+			metadata[name] = strings.Join(values, ",")
+		}
+	}
+	if len(metadata) == 0 {
+		metadata = nil
+	}
+	return
+}
+
+// Ask the director which caches in the federation currently hold the object.
+//
+// Directors that predate the availability API return a 404; in that case no error
+// is returned and the availability is left empty.
+func queryObjectAvailability(ctx context.Context, dest *pelican_url.PelicanURL, token *tokenGenerator) (availability []server_structs.ObjectAvailability, err error) {
+	if dest.FedInfo.DirectorEndpoint == "" {
+		return nil, errors.New("no director endpoint found in the pelican URL metadata")
+	}
+	availUrl, err := url.Parse(dest.FedInfo.DirectorEndpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse director endpoint")
+	}
+	availUrl = availUrl.JoinPath("/api/v1.0/director/availability", dest.Path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, availUrl.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create availability request")
+	}
+	req.Header.Set("User-Agent", getUserAgent(""))
+	if token != nil {
+		if tokenContents, err := token.get(); err == nil && tokenContents != "" {
+			req.Header.Set("Authorization", "Bearer "+tokenContents)
+		}
+	}
+
+	client := &http.Client{Transport: config.GetTransport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query the director for object availability")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the director's availability response")
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		log.Debugln("Director does not support the availability API or the namespace is unknown:", string(body))
+		return nil, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("director returned status %d for availability query: %s", resp.StatusCode, string(body))
+	}
+
+	var availResp server_structs.ObjectAvailabilityResp
+	if err = json.Unmarshal(body, &availResp); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the director's availability response")
+	}
+	return availResp.Caches, nil
+}
@@ -0,0 +1,110 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/pelican_url"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestParseDigestHeader(t *testing.T) {
+	digests := parseDigestHeader("MD5=HUXZLQLMuI/KZ5KDcJPcOA==, crc32c=AAAAAA==,sha-256=,bogus")
+	assert.Equal(t, map[string]string{
+		"md5":    "HUXZLQLMuI/KZ5KDcJPcOA==",
+		"crc32c": "AAAAAA==",
+	}, digests)
+
+	assert.Empty(t, parseDigestHeader(""))
+}
+
+func TestHeadObjectMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		assert.Equal(t, "/foo/bar/test.txt", r.URL.Path)
+		assert.Equal(t, requestedDigests, r.Header.Get("Want-Digest"))
+		w.Header().Set("Digest", "md5=HUXZLQLMuI/KZ5KDcJPcOA==")
+		w.Header().Set("ETag", "\"1234\"")
+		w.Header().Set("X-Pelican-Meta-Experiment", "cms")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	serverUrl, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	pUrl := &pelican_url.PelicanURL{Scheme: "pelican", Host: "something.com", Path: "/foo/bar/test.txt"}
+	dirResp := server_structs.DirectorResponse{ObjectServers: []*url.URL{serverUrl}}
+
+	checksums, metadata, err := headObjectMetadata(context.Background(), pUrl, dirResp, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"md5": "HUXZLQLMuI/KZ5KDcJPcOA=="}, checksums)
+	assert.Equal(t, "\"1234\"", metadata["ETag"])
+	assert.Equal(t, "cms", metadata["Experiment"])
+}
+
+func TestQueryObjectAvailability(t *testing.T) {
+	t.Run("available", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v1.0/director/availability/foo/bar/test.txt", r.URL.Path)
+			resp := server_structs.ObjectAvailabilityResp{
+				Path: "/foo/bar/test.txt",
+				Caches: []server_structs.ObjectAvailability{
+					{Name: "cache1", URL: "https://cache1:8443", Available: true},
+					{Name: "cache2", URL: "https://cache2:8443"},
+				},
+			}
+			body, err := json.Marshal(resp)
+			require.NoError(t, err)
+			_, _ = w.Write(body)
+		}))
+		defer server.Close()
+
+		pUrl := &pelican_url.PelicanURL{
+			FedInfo: pelican_url.FederationDiscovery{DirectorEndpoint: server.URL},
+			Path:    "/foo/bar/test.txt",
+		}
+		availability, err := queryObjectAvailability(context.Background(), pUrl, nil)
+		require.NoError(t, err)
+		require.Len(t, availability, 2)
+		assert.True(t, availability[0].Available)
+		assert.False(t, availability[1].Available)
+	})
+
+	t.Run("old-director", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+
+		pUrl := &pelican_url.PelicanURL{
+			FedInfo: pelican_url.FederationDiscovery{DirectorEndpoint: server.URL},
+			Path:    "/foo/bar/test.txt",
+		}
+		availability, err := queryObjectAvailability(context.Background(), pUrl, nil)
+		require.NoError(t, err)
+		assert.Nil(t, availability)
+	})
+}
@@ -0,0 +1,223 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+const (
+	// The scheme of the object storage locations that may be given in place of a local path
+	objectStorageScheme = "s3"
+
+	// The bounds of the size of the parts of a multipart upload to S3
+	objectStorageMinPartSize = s3manager.MinUploadPartSize
+	objectStorageMaxParts    = s3manager.MaxUploadParts
+)
+
+// Streams a download into a multipart upload to object storage.  Data written to it is
+// passed on to the uploader through a pipe, so at most a few parts are held in memory;
+// the upload only completes when the writer is closed.
+type objectStorageWriter struct {
+	location string
+	pw       *io.PipeWriter
+	done     chan error
+}
+
+// Whether the local path of a transfer is an object storage location such as
+// s3://bucket/key rather than a path on the local filesystem
+func IsObjectStorageURL(localPath string) bool {
+	return strings.HasPrefix(localPath, objectStorageScheme+"://")
+}
+
+// Split an object storage location into its bucket and key
+func parseObjectStorageURL(location string) (bucket string, key string, err error) {
+	storageUrl, err := url.Parse(location)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "invalid object storage location %s", location)
+	}
+	if storageUrl.Scheme != objectStorageScheme {
+		return "", "", errors.Errorf("invalid object storage location %s; the scheme must be %s://", location, objectStorageScheme)
+	}
+	if storageUrl.Host == "" {
+		return "", "", errors.Errorf("invalid object storage location %s; the bucket is missing", location)
+	}
+	return storageUrl.Host, strings.TrimPrefix(storageUrl.Path, "/"), nil
+}
+
+// Determine the object storage location a download of the remote object should be written to.
+// If the location has no key or names a "directory", the object is placed inside it.
+func resolveObjectStorageDestination(remotePath string, location string) string {
+	if _, key, err := parseObjectStorageURL(location); err == nil && (key == "" || strings.HasSuffix(key, "/")) {
+		if !strings.HasSuffix(location, "/") {
+			location += "/"
+		}
+		return location + path.Base(remotePath)
+	}
+	return location
+}
+
+func readObjectStorageKeyfile(name string) (string, error) {
+	contents, err := os.ReadFile(name)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the S3 key file")
+	}
+	return strings.TrimSpace(string(contents)), nil
+}
+
+// Create an S3 client according to the Client.S3* parameters, falling back on the AWS
+// environment variables and shared configuration for the region and credentials
+func newObjectStorageClient() (*s3.S3, error) {
+	switch style := param.Client_S3UrlStyle.GetString(); style {
+	case "", "path", "virtual":
+	default:
+		return nil, errors.Errorf("invalid value %q for %s; must be \"path\" or \"virtual\"", style, param.Client_S3UrlStyle.GetName())
+	}
+	awsConfig := aws.NewConfig().
+		WithHTTPClient(&http.Client{Transport: config.GetTransport()}).
+		WithS3ForcePathStyle(param.Client_S3UrlStyle.GetString() != "virtual")
+	if serviceUrl := param.Client_S3ServiceUrl.GetString(); serviceUrl != "" {
+		awsConfig = awsConfig.WithEndpoint(serviceUrl)
+	}
+	if region := param.Client_S3Region.GetString(); region != "" {
+		awsConfig = awsConfig.WithRegion(region)
+	}
+
+	accessKeyfile := param.Client_S3AccessKeyfile.GetString()
+	secretKeyfile := param.Client_S3SecretKeyfile.GetString()
+	if (accessKeyfile == "") != (secretKeyfile == "") {
+		return nil, errors.Errorf("%s and %s must be set together", param.Client_S3AccessKeyfile.GetName(), param.Client_S3SecretKeyfile.GetName())
+	}
+	if accessKeyfile != "" {
+		accessKey, err := readObjectStorageKeyfile(accessKeyfile)
+		if err != nil {
+			return nil, err
+		}
+		secretKey, err := readObjectStorageKeyfile(secretKeyfile)
+		if err != nil {
+			return nil, err
+		}
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(accessKey, secretKey, ""))
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to configure the S3 client")
+	}
+	if aws.StringValue(sess.Config.Region) == "" {
+		sess.Config.Region = aws.String("us-east-1")
+	}
+	return s3.New(sess), nil
+}
+
+// Start a multipart upload of a download of the given size to the object storage location.
+// The parts are large enough for the whole object to fit in the maximum number of parts.
+func newObjectStorageWriter(ctx context.Context, location string, size int64) (*objectStorageWriter, error) {
+	bucket, key, err := parseObjectStorageURL(location)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, errors.Errorf("invalid object storage location %s; the key is missing", location)
+	}
+	svc, err := newObjectStorageClient()
+	if err != nil {
+		return nil, err
+	}
+	uploader := s3manager.NewUploaderWithClient(svc, func(u *s3manager.Uploader) {
+		u.PartSize = max(int64(objectStorageMinPartSize), (size+objectStorageMaxParts-1)/objectStorageMaxParts)
+	})
+
+	pr, pw := io.Pipe()
+	w := &objectStorageWriter{location: location, pw: pw, done: make(chan error, 1)}
+	go func() {
+		_, err := uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   pr,
+		})
+		// Unblock the download if the upload failed before reading all the data
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+func (w *objectStorageWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Complete the upload with the data written so far and wait for it to finish
+func (w *objectStorageWriter) Close() error {
+	w.pw.Close()
+	if err := <-w.done; err != nil {
+		return errors.Wrapf(err, "failed to upload the object to %s", w.location)
+	}
+	log.Debugln("Completed the upload of the object to", w.location)
+	return nil
+}
+
+// Abort the upload, discarding the parts uploaded so far
+func (w *objectStorageWriter) abort(cause error) {
+	w.pw.CloseWithError(cause)
+	<-w.done
+}
+
+// Open the object at the object storage location for an upload, returning its size
+func openObjectStorageReader(ctx context.Context, location string) (io.ReadCloser, int64, error) {
+	bucket, key, err := parseObjectStorageURL(location)
+	if err != nil {
+		return nil, 0, err
+	}
+	if key == "" {
+		return nil, 0, errors.Errorf("invalid object storage location %s; the key is missing", location)
+	}
+	svc, err := newObjectStorageClient()
+	if err != nil {
+		return nil, 0, err
+	}
+	output, err := svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "failed to read the object at %s", location)
+	}
+	return output.Body, aws.Int64Value(output.ContentLength), nil
+}
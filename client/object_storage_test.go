@@ -0,0 +1,139 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A minimal path-style S3 service holding objects in memory; it only supports
+// single-part uploads, which the uploader uses for objects smaller than a part
+func newFakeObjectStorage(t *testing.T) map[string][]byte {
+	objects := map[string][]byte{}
+	var mutex sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access-key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			objects[r.URL.Path] = body
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte("<Error><Code>NoSuchKey</Code></Error>"))
+				return
+			}
+			_, _ = w.Write(body)
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "access"), []byte("access-key\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret"), []byte("secret-key\n"), 0600))
+	t.Cleanup(viper.Reset)
+	viper.Set("Client.S3ServiceUrl", server.URL)
+	viper.Set("Client.S3Region", "us-east-1")
+	viper.Set("Client.S3UrlStyle", "path")
+	viper.Set("Client.S3AccessKeyfile", filepath.Join(dir, "access"))
+	viper.Set("Client.S3SecretKeyfile", filepath.Join(dir, "secret"))
+	return objects
+}
+
+func TestParseObjectStorageURL(t *testing.T) {
+	assert.True(t, IsObjectStorageURL("s3://bucket/key"))
+	assert.False(t, IsObjectStorageURL("/tmp/s3://bucket"))
+	assert.False(t, IsObjectStorageURL(StreamPath))
+
+	bucket, key, err := parseObjectStorageURL("s3://bucket/some/key")
+	require.NoError(t, err)
+	assert.Equal(t, "bucket", bucket)
+	assert.Equal(t, "some/key", key)
+
+	_, _, err = parseObjectStorageURL("s3:///key")
+	assert.Error(t, err)
+
+	assert.Equal(t, "s3://bucket/some/key", resolveObjectStorageDestination("/foo/bar", "s3://bucket/some/key"))
+	assert.Equal(t, "s3://bucket/some/bar", resolveObjectStorageDestination("/foo/bar", "s3://bucket/some/"))
+	assert.Equal(t, "s3://bucket/bar", resolveObjectStorageDestination("/foo/bar", "s3://bucket"))
+}
+
+func TestObjectStorageTransfer(t *testing.T) {
+	objects := newFakeObjectStorage(t)
+	ctx := context.Background()
+
+	t.Run("write", func(t *testing.T) {
+		w, err := newObjectStorageWriter(ctx, "s3://bucket/dir/object", 11)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("hello "))
+		require.NoError(t, err)
+		_, err = w.Write([]byte("world"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		assert.Equal(t, "hello world", string(objects["/bucket/dir/object"]))
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		w, err := newObjectStorageWriter(ctx, "s3://bucket/aborted", 11)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("hello "))
+		require.NoError(t, err)
+		w.abort(io.ErrUnexpectedEOF)
+		assert.NotContains(t, objects, "/bucket/aborted")
+	})
+
+	t.Run("read", func(t *testing.T) {
+		reader, size, err := openObjectStorageReader(ctx, "s3://bucket/dir/object")
+		require.NoError(t, err)
+		defer reader.Close()
+		assert.Equal(t, int64(11), size)
+		contents, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", string(contents))
+
+		_, _, err = openObjectStorageReader(ctx, "s3://bucket/missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("missing-key", func(t *testing.T) {
+		_, err := newObjectStorageWriter(ctx, "s3://bucket", 11)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,132 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, University of Nebraska-Lincoln
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+type (
+	// The filesystem operations used to locate and read credentials
+	credentialFS interface {
+		Stat(name string) (fs.FileInfo, error)
+		ReadFile(name string) ([]byte, error)
+		ReadDir(name string) ([]fs.DirEntry, error)
+	}
+
+	osCredentialFS struct{}
+
+	// The operating system facilities the client consults when locating
+	// credentials and local files.  Token discovery goes through a
+	// clientPlatform rather than the os package so tests can exercise the
+	// behavior of other platforms, such as Windows HTCondor workers, on any
+	// CI runner.
+	clientPlatform struct {
+		goos      string
+		fs        credentialFS
+		lookupEnv func(key string) (string, bool)
+		getuid    func() int
+	}
+)
+
+// The platform the client is running on
+var hostPlatform = clientPlatform{
+	goos:      runtime.GOOS,
+	fs:        osCredentialFS{},
+	lookupEnv: os.LookupEnv,
+	getuid:    os.Getuid,
+}
+
+func (osCredentialFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osCredentialFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (osCredentialFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (p clientPlatform) isWindows() bool {
+	return p.goos == "windows"
+}
+
+// Return the user ID used to name WLCG bearer token files (bt_u<uid>).
+// Windows has no numeric user IDs, so the uid-based discovery locations
+// don't apply there.
+func (p clientPlatform) tokenUid() (uid int, ok bool) {
+	if p.isWindows() {
+		return -1, false
+	}
+	uid = p.getuid()
+	return uid, uid >= 0
+}
+
+// Read a token file, stripping the byte order mark that Windows editors
+// may prepend and any surrounding whitespace (including CRLF line endings)
+func (p clientPlatform) readTokenFile(location string) ([]byte, error) {
+	contents, err := p.fs.ReadFile(location)
+	if err != nil {
+		return nil, err
+	}
+	contents = bytes.TrimPrefix(contents, []byte("\xef\xbb\xbf"))
+	return bytes.TrimSpace(contents), nil
+}
+
+// Make a local path absolute.  Besides not depending on the working
+// directory, absolute paths let the os package lift the 260-character
+// MAX_PATH limit of Windows (it only does so for absolute paths), which
+// deep job sandboxes on Windows HTCondor workers routinely exceed.
+func absLocalPath(localPath string) string {
+	if localPath == "" || isStreamPath(localPath) || IsObjectStorageURL(localPath) {
+		return localPath
+	}
+	if absPath, err := filepath.Abs(localPath); err == nil {
+		// Keep a trailing separator, which marks the destination as a directory
+		if strings.HasSuffix(localPath, string(filepath.Separator)) && !strings.HasSuffix(absPath, string(filepath.Separator)) {
+			absPath += string(filepath.Separator)
+		}
+		return absPath
+	}
+	return localPath
+}
+
+// Join a local path and an object path relative to it, converting the
+// object path's slashes to the local separator
+func joinLocalPath(localPath string, objectPath ...string) string {
+	elems := make([]string, 0, len(objectPath)+1)
+	elems = append(elems, localPath)
+	for _, elem := range objectPath {
+		elems = append(elems, filepath.FromSlash(elem))
+	}
+	return filepath.Join(elems...)
+}
+
+// Return the object path (with slashes) of a local file relative to the
+// local base directory of a transfer
+func relativeObjectPath(localBase string, localPath string) string {
+	return filepath.ToSlash(strings.TrimPrefix(localPath, localBase))
+}
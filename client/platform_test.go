@@ -0,0 +1,170 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, University of Nebraska-Lincoln
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	// An in-memory credentialFS whose files are keyed by their full path
+	fakeCredentialFS map[string]string
+
+	fakeFileInfo struct {
+		name  string
+		size  int64
+		isDir bool
+	}
+)
+
+func (fi fakeFileInfo) Name() string       { return fi.name }
+func (fi fakeFileInfo) Size() int64        { return fi.size }
+func (fi fakeFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fakeFileInfo) IsDir() bool        { return fi.isDir }
+func (fi fakeFileInfo) Sys() any           { return nil }
+func (fi fakeFileInfo) Mode() fs.FileMode {
+	if fi.isDir {
+		return fs.ModeDir | 0700
+	}
+	return 0600
+}
+
+func (fakeFS fakeCredentialFS) Stat(name string) (fs.FileInfo, error) {
+	if contents, ok := fakeFS[name]; ok {
+		return fakeFileInfo{name: filepath.Base(name), size: int64(len(contents))}, nil
+	}
+	for location := range fakeFS {
+		if filepath.Dir(location) == name {
+			return fakeFileInfo{name: filepath.Base(name), isDir: true}, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (fakeFS fakeCredentialFS) ReadFile(name string) ([]byte, error) {
+	if contents, ok := fakeFS[name]; ok {
+		return []byte(contents), nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (fakeFS fakeCredentialFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	for location, contents := range fakeFS {
+		if filepath.Dir(location) == name {
+			entries = append(entries, fs.FileInfoToDirEntry(fakeFileInfo{name: filepath.Base(location), size: int64(len(contents))}))
+		}
+	}
+	if entries == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func newFakePlatform(goos string, uid int, env map[string]string, files fakeCredentialFS) clientPlatform {
+	return clientPlatform{
+		goos: goos,
+		fs:   files,
+		lookupEnv: func(key string) (string, bool) {
+			val, ok := env[key]
+			return val, ok
+		},
+		getuid: func() int { return uid },
+	}
+}
+
+// Collect the sources and contents of every token the iterator finds
+func iterateTokens(iter *tokenContentIterator) (sources []TokenSource, tokens []string) {
+	for {
+		token, ok := iter.next()
+		if !ok {
+			return
+		}
+		sources = append(sources, iter.Source)
+		tokens = append(tokens, token)
+	}
+}
+
+func TestTokenDiscoveryWindows(t *testing.T) {
+	credsDir := filepath.Join("C:", "condor", "execute", "dir_1234", ".condor_creds")
+	runtimeDir := filepath.Join("C:", "Users", "worker", "AppData", "Local", "Temp")
+	files := fakeCredentialFS{
+		// Written by a Windows editor, with a byte order mark and CRLF
+		filepath.Join(credsDir, "scitokens.use"): "\xef\xbb\xbfcondor-default\r\n",
+		filepath.Join(credsDir, "ospool.use"):    "condor-ospool\r\n",
+		filepath.Join(credsDir, ".hidden"):       "hidden",
+		// No uid on Windows, so this must never be found
+		filepath.Join(runtimeDir, "bt_u-1"): "runtime-dir",
+	}
+	env := map[string]string{
+		"_CONDOR_CREDS":   credsDir,
+		"XDG_RUNTIME_DIR": runtimeDir,
+	}
+
+	iter := newTokenContentIterator("", "")
+	iter.platform = newFakePlatform("windows", -1, env, files)
+	sources, tokens := iterateTokens(iter)
+	assert.Equal(t, []TokenSource{TokenSourceCondorCreds, TokenSourceCondorCreds}, sources)
+	assert.Equal(t, []string{"condor-default", "condor-ospool"}, tokens)
+
+	// A named token resolves to its .use file
+	iter = newTokenContentIterator("", "ospool")
+	iter.platform = newFakePlatform("windows", -1, env, fakeCredentialFS{
+		filepath.Join(credsDir, "ospool"):     "",
+		filepath.Join(credsDir, "ospool.use"): "condor-ospool",
+	})
+	_, tokens = iterateTokens(iter)
+	require.NotEmpty(t, tokens)
+	assert.Equal(t, "condor-ospool", tokens[0])
+}
+
+func TestTokenDiscoveryUnixUid(t *testing.T) {
+	runtimeDir := filepath.Join("run", "user", "1000")
+	files := fakeCredentialFS{
+		filepath.Join(runtimeDir, "bt_u1000"): `{"access_token": "runtime-dir", "expires_in": 100}`,
+		"/tmp/bt_u1000":                       "tmp",
+	}
+	iter := newTokenContentIterator("", "")
+	iter.platform = newFakePlatform("linux", 1000, map[string]string{"XDG_RUNTIME_DIR": runtimeDir, "_CONDOR_CREDS": "nonexistent"}, files)
+	sources, tokens := iterateTokens(iter)
+	assert.Equal(t, []TokenSource{TokenSourceRuntimeDir, TokenSourceTmp}, sources)
+	assert.Equal(t, []string{"runtime-dir", "tmp"}, tokens)
+}
+
+func TestLocalPaths(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(wd, "foo"), absLocalPath("foo"))
+	assert.Equal(t, filepath.Join(wd, "foo")+string(filepath.Separator), absLocalPath("foo"+string(filepath.Separator)))
+	assert.Equal(t, StreamPath, absLocalPath(StreamPath))
+
+	base := filepath.Join(wd, "dest")
+	local := joinLocalPath(base, "/sub/dir", "file.txt")
+	assert.Equal(t, filepath.Join(base, "sub", "dir", "file.txt"), local)
+	assert.Equal(t, "/sub/dir/file.txt", relativeObjectPath(base, local))
+}
@@ -0,0 +1,55 @@
+//go:build linux
+// +build linux
+
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// Lower the CPU scheduling priority of the process to the given nice value
+// (0-19).  On Linux the nice value is a per-thread attribute, so it is applied
+// to every existing thread; threads started later inherit it.  Unless an I/O
+// priority was set explicitly, the kernel derives the I/O priority from it.
+func SetProcessPriority(nice int) error {
+	if err := checkNiceValue(nice); err != nil {
+		return err
+	}
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return errors.Wrap(err, "failed to list the threads of the process")
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		// The thread may have exited since the directory was read
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return errors.Wrapf(err, "failed to set the nice value to %d", nice)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// Lower the CPU scheduling priority of the process to the given nice value (0-19)
+func SetProcessPriority(nice int) error {
+	if err := checkNiceValue(nice); err != nil {
+		return err
+	}
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, nice); err != nil {
+		return errors.Wrapf(err, "failed to set the nice value to %d", nice)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+//go:build windows
+// +build windows
+
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// Nice values are not supported on Windows; the priority is left unchanged
+func SetProcessPriority(nice int) error {
+	if err := checkNiceValue(nice); err != nil {
+		return err
+	}
+	if nice > 0 {
+		log.Warningln("Lowering the process priority is not supported on Windows; ignoring the nice value")
+	}
+	return nil
+}
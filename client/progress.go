@@ -0,0 +1,187 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type (
+	// The progress of a single transfer of an operation
+	TransferProgress struct {
+		// The local path of the transfer, as passed to the TransferCallbackFunc
+		Path             string `json:"path"`
+		TransferredBytes int64  `json:"transferredBytes"`
+		// The size of the object; 0 until it's known
+		TotalBytes int64 `json:"totalBytes"`
+		// The recent rate of the transfer in bytes per second; the average rate once it's completed
+		Rate float64 `json:"rate"`
+		// The estimated time left; 0 if it's unknown or the transfer is completed
+		ETA       time.Duration `json:"eta"`
+		Completed bool          `json:"completed"`
+	}
+
+	// The combined progress of the transfers of a (possibly multi-file) operation
+	ProgressReport struct {
+		Files            int   `json:"files"`
+		CompletedFiles   int   `json:"completedFiles"`
+		TransferredBytes int64 `json:"transferredBytes"`
+		// The combined size of the objects whose size is known
+		TotalBytes int64 `json:"totalBytes"`
+		// The combined rate of the ongoing transfers in bytes per second
+		Rate float64 `json:"rate"`
+		// The estimated time left; 0 if it's unknown, e.g., while the size of an object is unknown
+		ETA time.Duration `json:"eta"`
+		// The progress of each transfer, in the order they started
+		Transfers []TransferProgress `json:"transfers"`
+	}
+
+	// Called with the progress of the whole operation each time one of its transfers progresses
+	ProgressFunc func(report ProgressReport)
+
+	// Tracks the progress of the transfers of an operation, computing their rates and
+	// estimated completion times.  Pass its Callback to WithCallback, e.g.,
+	//
+	//	tracker := client.NewProgressTracker(nil)
+	//	results, err := client.DoGet(ctx, source, dest, true, client.WithCallback(tracker.Callback))
+	//
+	// and poll Report, or give NewProgressTracker a function to be called on each update.
+	ProgressTracker struct {
+		mutex     sync.Mutex
+		transfers map[string]*trackedTransfer
+		order     []string
+		onUpdate  ProgressFunc
+		now       func() time.Time
+	}
+
+	trackedTransfer struct {
+		progress   TransferProgress
+		started    time.Time
+		lastUpdate time.Time
+	}
+)
+
+// The time over which the rate of a transfer is averaged
+const progressRateWindow = 5 * time.Second
+
+// Create a tracker calling onUpdate, if not nil, with the progress of the operation each
+// time one of its transfers progresses.  onUpdate is called from the transfer workers, so
+// it must be quick and safe for concurrent use.
+func NewProgressTracker(onUpdate ProgressFunc) *ProgressTracker {
+	return &ProgressTracker{
+		transfers: make(map[string]*trackedTransfer),
+		onUpdate:  onUpdate,
+		now:       time.Now,
+	}
+}
+
+// Record the progress of a transfer; a TransferCallbackFunc
+func (pt *ProgressTracker) Callback(path string, transferred int64, totalSize int64, completed bool) {
+	pt.mutex.Lock()
+	now := pt.now()
+	xfer := pt.transfers[path]
+	if xfer == nil {
+		xfer = &trackedTransfer{progress: TransferProgress{Path: path}, started: now, lastUpdate: now}
+		pt.transfers[path] = xfer
+		pt.order = append(pt.order, path)
+	}
+	xfer.update(now, transferred, totalSize, completed)
+	var report ProgressReport
+	if pt.onUpdate != nil {
+		report = pt.reportLocked()
+	}
+	pt.mutex.Unlock()
+
+	if pt.onUpdate != nil {
+		pt.onUpdate(report)
+	}
+}
+
+func (xfer *trackedTransfer) update(now time.Time, transferred int64, totalSize int64, completed bool) {
+	progress := &xfer.progress
+	if totalSize > 0 {
+		progress.TotalBytes = totalSize
+	}
+	elapsed := now.Sub(xfer.lastUpdate)
+	switch {
+	case transferred < progress.TransferredBytes:
+		// The transfer was restarted, e.g., by a retry
+		progress.Rate = 0
+		xfer.lastUpdate = now
+	case elapsed > 0:
+		// Weigh the rate since the last update by how much of the window it covers
+		rate := float64(transferred-progress.TransferredBytes) / elapsed.Seconds()
+		weight := 1 - math.Exp(-elapsed.Seconds()/progressRateWindow.Seconds())
+		if progress.TransferredBytes == 0 && progress.Rate == 0 {
+			weight = 1
+		}
+		progress.Rate += weight * (rate - progress.Rate)
+		xfer.lastUpdate = now
+	}
+	progress.TransferredBytes = transferred
+	progress.Completed = progress.Completed || completed
+
+	progress.ETA = 0
+	if progress.Completed {
+		if total := now.Sub(xfer.started); total > 0 {
+			progress.Rate = float64(progress.TransferredBytes) / total.Seconds()
+		}
+	} else if progress.TotalBytes > 0 && progress.Rate > 0 {
+		remaining := max(progress.TotalBytes-progress.TransferredBytes, 0)
+		progress.ETA = time.Duration(float64(remaining) / progress.Rate * float64(time.Second))
+	}
+}
+
+// Get the current progress of the operation
+func (pt *ProgressTracker) Report() ProgressReport {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	return pt.reportLocked()
+}
+
+func (pt *ProgressTracker) reportLocked() ProgressReport {
+	report := ProgressReport{
+		Files:     len(pt.order),
+		Transfers: make([]TransferProgress, 0, len(pt.order)),
+	}
+	sizesKnown := true
+	var remaining int64
+	for _, path := range pt.order {
+		progress := pt.transfers[path].progress
+		report.Transfers = append(report.Transfers, progress)
+		report.TransferredBytes += progress.TransferredBytes
+		report.TotalBytes += progress.TotalBytes
+		if progress.Completed {
+			report.CompletedFiles++
+			continue
+		}
+		report.Rate += progress.Rate
+		if progress.TotalBytes == 0 {
+			sizesKnown = false
+		} else {
+			remaining += max(progress.TotalBytes-progress.TransferredBytes, 0)
+		}
+	}
+	if sizesKnown && remaining > 0 && report.Rate > 0 {
+		report.ETA = time.Duration(float64(remaining) / report.Rate * float64(time.Second))
+	}
+	return report
+}
@@ -0,0 +1,80 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressTracker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	updates := 0
+	tracker := NewProgressTracker(func(report ProgressReport) { updates++ })
+	tracker.now = func() time.Time { return now }
+
+	tracker.Callback("/tmp/a", 0, 1000, false)
+	tracker.Callback("/tmp/b", 0, 0, false)
+	now = now.Add(time.Second)
+	tracker.Callback("/tmp/a", 100, 1000, false)
+	tracker.Callback("/tmp/b", 50, 0, false)
+	assert.Equal(t, 4, updates)
+
+	report := tracker.Report()
+	require.Len(t, report.Transfers, 2)
+	assert.Equal(t, "/tmp/a", report.Transfers[0].Path)
+	assert.InDelta(t, 100, report.Transfers[0].Rate, 0.01)
+	assert.Equal(t, 9*time.Second, report.Transfers[0].ETA.Round(time.Millisecond))
+	// The size of b is unknown, so neither are its ETA nor that of the operation
+	assert.Zero(t, report.Transfers[1].ETA)
+	assert.Zero(t, report.ETA)
+	assert.Equal(t, 2, report.Files)
+	assert.Equal(t, int64(150), report.TransferredBytes)
+	assert.Equal(t, int64(1000), report.TotalBytes)
+	assert.InDelta(t, 150, report.Rate, 0.01)
+
+	// The rate follows the recent progress
+	now = now.Add(time.Second)
+	tracker.Callback("/tmp/a", 500, 1000, false)
+	tracker.Callback("/tmp/b", 50, 100, false)
+	report = tracker.Report()
+	assert.Greater(t, report.Transfers[0].Rate, 100.0)
+	assert.Less(t, report.Transfers[0].Rate, 400.0)
+	assert.Less(t, report.Transfers[1].Rate, 50.0)
+	assert.Positive(t, report.ETA)
+
+	// Completed transfers report their average rate and no longer count in the combined rate
+	now = now.Add(2 * time.Second)
+	tracker.Callback("/tmp/a", 1000, 1000, true)
+	report = tracker.Report()
+	assert.True(t, report.Transfers[0].Completed)
+	assert.InDelta(t, 250, report.Transfers[0].Rate, 0.01)
+	assert.Zero(t, report.Transfers[0].ETA)
+	assert.Equal(t, 1, report.CompletedFiles)
+	assert.Equal(t, report.Transfers[1].Rate, report.Rate)
+
+	// A restarted transfer starts its rate over
+	tracker.Callback("/tmp/b", 0, 100, false)
+	report = tracker.Report()
+	assert.Zero(t, report.Transfers[1].Rate)
+	assert.Equal(t, int64(1000), report.TransferredBytes)
+}
@@ -0,0 +1,160 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+)
+
+// Reads arbitrary byte ranges of a remote object without downloading all of it,
+// for callers that need random access such as the FUSE mount.
+//
+// The director is consulted once, when the reader is created; each read is a ranged
+// GET against the object servers it returned.  A read that fails on one server is
+// retried on the next when the error is one another server may not repeat, and the
+// server that last succeeded is tried first for the following reads.
+type ObjectRangeReader struct {
+	objectPath string
+	servers    []*url.URL
+	token      *tokenGenerator
+	client     *http.Client
+
+	mutex   sync.Mutex
+	current int
+}
+
+// Create a reader for ranges of the remote object
+func NewObjectRangeReader(ctx context.Context, remoteObject string, options ...TransferOption) (reader *ObjectRangeReader, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Debugln("Panic captured while creating a range reader:", r)
+			log.Debugln("Panic caused by the following", string(debug.Stack()))
+			ret := fmt.Sprintf("Unrecoverable error (panic) while creating a range reader: %v", r)
+			err = errors.New(ret)
+		}
+	}()
+
+	pUrl, dirResp, token, err := prepareStat(ctx, remoteObject, options...)
+	if err != nil {
+		return
+	}
+	if len(dirResp.ObjectServers) == 0 {
+		return nil, errors.Errorf("the director returned no servers for %s", remoteObject)
+	}
+	reader = &ObjectRangeReader{
+		objectPath: pUrl.Path,
+		servers:    dirResp.ObjectServers,
+		token:      token,
+		client:     &http.Client{Transport: config.GetTransport()},
+	}
+	return
+}
+
+// Read len(p) bytes of the object starting at offset off.  As with io.ReaderAt,
+// fewer bytes are only returned at the end of the object, along with io.EOF.
+func (r *ObjectRangeReader) ReadRange(ctx context.Context, p []byte, off int64) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	r.mutex.Lock()
+	start := r.current
+	r.mutex.Unlock()
+
+	for attempt := 0; attempt < len(r.servers); attempt++ {
+		idx := (start + attempt) % len(r.servers)
+		n, err = r.readFrom(ctx, r.servers[idx], p, off)
+		if err == nil || errors.Is(err, io.EOF) {
+			if idx != start {
+				r.mutex.Lock()
+				r.current = idx
+				r.mutex.Unlock()
+			}
+			return
+		}
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if !shouldFailover(err) {
+			return 0, err
+		}
+		log.Debugf("Ranged read of %s from %s failed (%s error): %v", r.objectPath, r.servers[idx].Host, ClassifyError(err), err)
+	}
+	return 0, err
+}
+
+func (r *ObjectRangeReader) readFrom(ctx context.Context, server *url.URL, p []byte, off int64) (n int, err error) {
+	objectUrl := *server
+	objectUrl.Path = r.objectPath
+	objectUrl.RawQuery = ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectUrl.String(), nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create ranged read request")
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	req.Header.Set("User-Agent", getUserAgent(""))
+	if r.token != nil {
+		if tokenContents, err := r.token.get(); err == nil && tokenContents != "" {
+			req.Header.Set("Authorization", "Bearer "+tokenContents)
+		}
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// The server ignored the range and is sending the whole object
+		if _, err = io.CopyN(io.Discard, resp.Body, off); err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF
+	default:
+		sce := StatusCodeError(resp.StatusCode)
+		return 0, &sce
+	}
+
+	remaining := resp.ContentLength
+	if resp.StatusCode == http.StatusOK && remaining >= 0 {
+		remaining -= off
+	}
+	n, err = io.ReadFull(resp.Body, p)
+	// A short body is the end of the object unless the server sent less than it promised
+	if (errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)) && (remaining < 0 || int64(n) == remaining) {
+		err = io.EOF
+	}
+	return
+}
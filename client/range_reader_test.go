@@ -0,0 +1,102 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectRangeReader(t *testing.T) {
+	object := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	ranged := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "object", time.Time{}, bytes.NewReader(object))
+	}))
+	defer ranged.Close()
+	unranged := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(object)
+	}))
+	defer unranged.Close()
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	newReader := func(servers ...*httptest.Server) *ObjectRangeReader {
+		reader := &ObjectRangeReader{objectPath: "/test/object", client: http.DefaultClient}
+		for _, server := range servers {
+			serverUrl, err := url.Parse(server.URL)
+			require.NoError(t, err)
+			reader.servers = append(reader.servers, serverUrl)
+		}
+		return reader
+	}
+	ctx := context.Background()
+
+	t.Run("failover", func(t *testing.T) {
+		reader := newReader(unavailable, ranged)
+		buf := make([]byte, 5)
+		n, err := reader.ReadRange(ctx, buf, 10)
+		require.NoError(t, err)
+		assert.Equal(t, "abcde", string(buf[:n]))
+		// The server that succeeded is tried first from now on
+		assert.Equal(t, 1, reader.current)
+	})
+
+	t.Run("end-of-object", func(t *testing.T) {
+		reader := newReader(ranged)
+		buf := make([]byte, 10)
+		n, err := reader.ReadRange(ctx, buf, int64(len(object)-4))
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Equal(t, "wxyz", string(buf[:n]))
+
+		n, err = reader.ReadRange(ctx, buf, int64(len(object)+10))
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Zero(t, n)
+	})
+
+	t.Run("range-ignored", func(t *testing.T) {
+		reader := newReader(unranged)
+		buf := make([]byte, 3)
+		n, err := reader.ReadRange(ctx, buf, 20)
+		require.NoError(t, err)
+		assert.Equal(t, "klm", string(buf[:n]))
+	})
+
+	t.Run("no-failover-on-not-found", func(t *testing.T) {
+		reader := newReader(missing, ranged)
+		_, err := reader.ReadRange(ctx, make([]byte, 3), 0)
+		require.Error(t, err)
+		var sce *StatusCodeError
+		require.ErrorAs(t, err, &sce)
+		assert.Equal(t, StatusCodeError(http.StatusNotFound), *sce)
+	})
+}
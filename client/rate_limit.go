@@ -0,0 +1,125 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/units"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+type (
+	// A set of rate limiters that must all admit the bytes of a transfer,
+	// e.g., the engine-wide Client.MaxRate and a per-object limit
+	rateLimiters []*rate.Limiter
+
+	// Wraps a reader so it can't be consumed faster than the rate limiters allow
+	rateLimitedReader struct {
+		io.ReadCloser
+		ctx      context.Context
+		limiters rateLimiters
+	}
+)
+
+// The burst size of the engine-wide rate limiter; large enough to hold a full
+// read buffer so a transfer isn't woken up for every few kilobytes
+const maxRateBurst = 256 * 1024
+
+// Parse a transfer rate such as "50MB" or "50MB/s" into bytes per second.
+// An empty string or 0 means unlimited.
+func ParseMaxRate(value string) (int64, error) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "/s")
+	if value == "" {
+		return 0, nil
+	}
+	bytesPerSecond, err := units.ParseStrictBytes(value)
+	if err != nil || bytesPerSecond < 0 {
+		return 0, errors.Errorf("invalid transfer rate %q; expected a size per second such as 50MB", value)
+	}
+	return bytesPerSecond, nil
+}
+
+func newMaxRateLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	burst := maxRateBurst
+	if bytesPerSecond < int64(burst) {
+		burst = int(bytesPerSecond)
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), burst)
+}
+
+// WaitN blocks until every limiter admits n bytes.  Requests larger than a
+// limiter's burst are split so they can still be satisfied.
+func (limiters rateLimiters) WaitN(ctx context.Context, n int) error {
+	for n > 0 {
+		chunk := n
+		for _, limiter := range limiters {
+			if burst := limiter.Burst(); burst > 0 && chunk > burst {
+				chunk = burst
+			}
+		}
+		for _, limiter := range limiters {
+			if err := limiter.WaitN(ctx, chunk); err != nil {
+				return err
+			}
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+func (reader *rateLimitedReader) Read(p []byte) (n int, err error) {
+	n, err = reader.ReadCloser.Read(p)
+	if n > 0 {
+		if waitErr := reader.limiters.WaitN(reader.ctx, n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return
+}
+
+// The limiters shared by all the transfers of the engine
+func (te *TransferEngine) rateLimiters() rateLimiters {
+	if te == nil || te.maxRate == nil {
+		return nil
+	}
+	return rateLimiters{te.maxRate}
+}
+
+// Wrap reader in the engine's rate limiters, if any
+func (te *TransferEngine) limitReader(ctx context.Context, reader io.ReadCloser) io.ReadCloser {
+	limiters := te.rateLimiters()
+	if len(limiters) == 0 {
+		return reader
+	}
+	return &rateLimitedReader{ReadCloser: reader, ctx: ctx, limiters: limiters}
+}
+
+func checkNiceValue(nice int) error {
+	if nice < 0 || nice > 19 {
+		return errors.Errorf("invalid nice value %d; it must be between 0 and 19", nice)
+	}
+	return nil
+}
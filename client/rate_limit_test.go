@@ -0,0 +1,93 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestParseMaxRate(t *testing.T) {
+	for input, expected := range map[string]int64{
+		"":         0,
+		"0":        0,
+		"50MB":     50_000_000,
+		"50MB/s":   50_000_000,
+		" 1KiB/s ": 1024,
+	} {
+		actual, err := ParseMaxRate(input)
+		require.NoError(t, err, "input %q", input)
+		assert.Equal(t, expected, actual, "input %q", input)
+	}
+	for _, input := range []string{"fast", "-5MB", "10 per second"} {
+		_, err := ParseMaxRate(input)
+		assert.Error(t, err, "input %q", input)
+	}
+}
+
+func TestRateLimiters(t *testing.T) {
+	assert.Nil(t, newMaxRateLimiter(0))
+	assert.Equal(t, 1000, newMaxRateLimiter(1000).Burst())
+	assert.Equal(t, maxRateBurst, newMaxRateLimiter(1<<30).Burst())
+
+	// Requests larger than the burst must be split rather than rejected
+	limiters := rateLimiters{rate.NewLimiter(rate.Inf, 10), rate.NewLimiter(1e9, 100)}
+	require.NoError(t, limiters.WaitN(context.Background(), 1000))
+
+	// 40KB through a 100KB/s limiter with a 10KB burst takes at least 0.3s
+	data := make([]byte, 40*1000)
+	reader := &rateLimitedReader{
+		ReadCloser: io.NopCloser(bytes.NewReader(data)),
+		ctx:        context.Background(),
+		limiters:   rateLimiters{rate.NewLimiter(100*1000, 10*1000)},
+	}
+	start := time.Now()
+	read, err := io.Copy(io.Discard, reader)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), read)
+	assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+
+	// A cancelled transfer stops waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reader = &rateLimitedReader{
+		ReadCloser: io.NopCloser(bytes.NewReader(data)),
+		ctx:        ctx,
+		limiters:   rateLimiters{rate.NewLimiter(1, 1)},
+	}
+	_, err = io.Copy(io.Discard, reader)
+	assert.Error(t, err)
+
+	var te *TransferEngine
+	assert.Nil(t, te.rateLimiters())
+	te = &TransferEngine{maxRate: newMaxRateLimiter(1000)}
+	assert.Len(t, te.rateLimiters(), 1)
+}
+
+func TestSetProcessPriorityRange(t *testing.T) {
+	assert.Error(t, SetProcessPriority(-1))
+	assert.Error(t, SetProcessPriority(20))
+}
@@ -0,0 +1,294 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/pelican_url"
+)
+
+// Returned when the destination server doesn't support HTTP third-party copies
+var errTpcUnsupported = errors.New("the destination server does not support HTTP third-party copies")
+
+// Prefix of the performance marker line reporting the bytes copied so far
+const tpcBytesMarker = "Stripe Bytes Transferred:"
+
+// Copy an object (or, if recursive, a collection) between two federation URLs,
+// which may belong to different federations.
+//
+// Single objects are first copied with an HTTP third-party copy (HTTP-TPC), where the
+// destination server pulls the object directly from the source.  If the destination
+// does not support it, or the third-party copy fails, the data is streamed through the
+// client instead: the source is downloaded to a temporary directory and then uploaded
+// to the destination, with the same retry behavior as DoGet and DoPut.
+func DoRemoteCopy(ctx context.Context, source string, destination string, recursive bool, options ...TransferOption) (transferResults []TransferResults, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Debugln("Panic captured while attempting to perform remote copy:", r)
+			log.Debugln("Panic caused by the following", string(debug.Stack()))
+			ret := fmt.Sprintf("Unrecoverable error (panic) captured in DoRemoteCopy: %v", r)
+			err = errors.New(ret)
+		}
+	}()
+
+	tpc := true
+	var callback TransferCallbackFunc
+	for _, option := range options {
+		switch option.Ident() {
+		case identTransferOptionThirdParty{}:
+			tpc = option.Value().(bool)
+		case identTransferOptionCallback{}:
+			callback = option.Value().(TransferCallbackFunc)
+		case identTransferOptionRecursive{}:
+			recursive = recursive || option.Value().(bool)
+		}
+	}
+
+	compat, err := getCompatLevel()
+	if err != nil {
+		return nil, err
+	}
+	if tpc && !recursive && compat.Allows(FeatureThirdPartyCopy) {
+		result, tpcErr := thirdPartyCopy(ctx, source, destination, callback, options...)
+		if tpcErr == nil {
+			result.CompatLevel = compat.String()
+			return []TransferResults{result}, nil
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		} else if errors.Is(tpcErr, errTpcUnsupported) {
+			log.Infoln("Third-party copy unavailable; streaming the object through the client instead:", tpcErr)
+		} else {
+			log.Warningln("Third-party copy failed; streaming the object through the client instead:", tpcErr)
+		}
+	}
+	return streamRemoteCopy(ctx, source, destination, recursive, options...)
+}
+
+// Copy between two federation URLs by downloading the source to a temporary
+// directory and uploading it to the destination
+func streamRemoteCopy(ctx context.Context, source string, destination string, recursive bool, options ...TransferOption) (transferResults []TransferResults, err error) {
+	srcUrl, err := pelican_url.Parse(source, []pelican_url.ParseOption{pelican_url.ValidateQueryParams(true), pelican_url.AllowUnknownQueryParams(true)}, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse remote object: %s", source)
+	}
+
+	stageDir, err := os.MkdirTemp("", "pelican-copy-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create a staging directory for the copy")
+	}
+	defer func() {
+		if err := os.RemoveAll(stageDir); err != nil {
+			log.Warningln("Failed to remove the staging directory of the copy:", err)
+		}
+	}()
+
+	te, err := NewTransferEngine(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := te.Shutdown(); err != nil {
+			log.Errorln("Failure when shutting down transfer engine:", err)
+		}
+	}()
+
+	options = append(options, WithRecursive(recursive))
+	getResults, err := te.Get(ctx, source, stageDir, options...)
+	transferResults = append(transferResults, getResults...)
+	if err != nil {
+		return transferResults, errors.Wrapf(err, "failed to download %s", source)
+	}
+
+	localPath := stageDir
+	if !recursive {
+		localPath = filepath.Join(stageDir, path.Base(srcUrl.Path))
+	}
+	putResults, err := te.Put(ctx, localPath, destination, options...)
+	transferResults = append(transferResults, putResults...)
+	if err != nil {
+		return transferResults, errors.Wrapf(err, "failed to upload to %s", destination)
+	}
+	return transferResults, nil
+}
+
+// Ask the destination's origin to pull the source object directly from the source's origin
+func thirdPartyCopy(ctx context.Context, source string, destination string, callback TransferCallbackFunc, options ...TransferOption) (result TransferResults, err error) {
+	srcPUrl, srcDirResp, srcToken, err := prepareStat(ctx, source, options...)
+	if err != nil {
+		return
+	}
+	srcInfo, err := statHttp(srcPUrl, srcDirResp, srcToken)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to stat %s", source)
+		return
+	} else if srcInfo.IsCollection {
+		err = errors.Errorf("%s is a collection; only single objects can be copied without the recursive option", source)
+		return
+	}
+	srcObjUrl, err := objectMetadataUrl(srcPUrl, srcDirResp)
+	if err != nil {
+		return
+	}
+	srcTokenContents := ""
+	if srcToken != nil {
+		srcTokenContents, _ = srcToken.get()
+	}
+
+	dstPUrl, err := ParseRemoteAsPUrl(ctx, destination)
+	if err != nil {
+		return
+	}
+	dstDirResp, err := GetDirectorInfoForPath(ctx, dstPUrl, http.MethodPut, "")
+	if err != nil {
+		return
+	}
+	if len(dstDirResp.ObjectServers) == 0 {
+		err = errors.Errorf("no origins available to write %s", destination)
+		return
+	}
+	dstToken := newTokenGenerator(dstPUrl, &dstDirResp, true, true)
+	applyTokenOptions(dstToken, options...)
+	dstTokenContents, err := dstToken.get()
+	if err != nil || dstTokenContents == "" {
+		err = errors.Wrap(err, "failed to get token for transfer")
+		return
+	}
+	dstObjUrl := *dstDirResp.ObjectServers[0]
+	dstObjUrl.Path = dstPUrl.Path
+	dstObjUrl.RawQuery = ""
+
+	client := &http.Client{Transport: config.GetTransport()}
+	result, err = runThirdPartyCopy(ctx, client, srcObjUrl.String(), srcTokenContents, dstObjUrl.String(), dstTokenContents, srcInfo.Size, callback)
+	result.Scheme = dstPUrl.GetRawUrl().Scheme
+	result.Source, result.Destination = source, destination
+	return
+}
+
+// Issue an HTTP-TPC pull request to the destination and follow its performance
+// markers until the copy completes.  The progress of the copy is reported to the
+// callback (if any) using the destination URL as the path.
+func runThirdPartyCopy(ctx context.Context, client *http.Client, srcUrl string, srcToken string, dstUrl string, dstToken string, size int64, callback TransferCallbackFunc) (result TransferResults, err error) {
+	if result.jobId, err = uuid.NewV7(); err != nil {
+		err = errors.Wrap(err, "unable to create new UUID for the copy")
+		return
+	}
+	result.TransferStartTime = time.Now()
+	attempt := TransferResult{Number: 0, CacheAge: -1}
+
+	req, err := http.NewRequestWithContext(ctx, "COPY", dstUrl, nil)
+	if err != nil {
+		err = errors.Wrap(err, "failed to create third-party copy request")
+		return
+	}
+	attempt.Endpoint = req.URL.Host
+	req.Header.Set("Source", srcUrl)
+	req.Header.Set("Overwrite", "T")
+	req.Header.Set("Credential", "none")
+	req.Header.Set("User-Agent", getUserAgent(""))
+	if dstToken != "" {
+		req.Header.Set("Authorization", "Bearer "+dstToken)
+	}
+	if srcToken != "" {
+		req.Header.Set("TransferHeaderAuthorization", "Bearer "+srcToken)
+	}
+
+	log.Debugf("Requesting third-party copy of %s to %s", srcUrl, dstUrl)
+	resp, err := client.Do(req)
+	if err != nil {
+		err = errors.Wrap(err, "failed to request third-party copy")
+		return
+	}
+	defer resp.Body.Close()
+	attempt.ServerVersion = resp.Header.Get("Server")
+
+	var transferred int64
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusNoContent:
+		// The copy was performed synchronously
+		transferred = size
+	case http.StatusAccepted:
+		transferred, err = followPerfMarkers(resp.Body, func(copied int64) {
+			if callback != nil {
+				callback(dstUrl, copied, size, false)
+			}
+		})
+		if err == nil && transferred < size {
+			transferred = size
+		}
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		err = errors.Wrapf(errTpcUnsupported, "server %s returned status %d", req.URL.Host, resp.StatusCode)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		sce := StatusCodeError(resp.StatusCode)
+		err = errors.Wrapf(&sce, "third-party copy request to %s failed: %s", req.URL.Host, strings.TrimSpace(string(body)))
+	}
+
+	endTime := time.Now()
+	attempt.TransferEndTime = endTime
+	attempt.TransferTime = endTime.Sub(result.TransferStartTime)
+	attempt.TransferFileBytes = transferred
+	attempt.Error = err
+	result.Attempts = append(result.Attempts, attempt)
+	result.TransferredBytes = transferred
+	result.Error = err
+	if err == nil && callback != nil {
+		callback(dstUrl, transferred, size, true)
+	}
+	return
+}
+
+// Parse the performance markers streamed by a server performing a third-party
+// copy, returning the bytes copied once the server reports the outcome
+func followPerfMarkers(body io.Reader, progress func(int64)) (transferred int64, err error) {
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if value, found := strings.CutPrefix(line, tpcBytesMarker); found {
+			if copied, parseErr := strconv.ParseInt(strings.TrimSpace(value), 10, 64); parseErr == nil {
+				transferred = copied
+				progress(transferred)
+			}
+		} else if strings.HasPrefix(line, "success:") {
+			return transferred, nil
+		} else if msg, found := strings.CutPrefix(line, "failure:"); found {
+			return transferred, errors.Errorf("server reported the third-party copy failed: %s", strings.TrimSpace(msg))
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return transferred, errors.Wrap(err, "failed to read the progress of the third-party copy")
+	}
+	return transferred, errors.New("third-party copy ended without reporting its outcome")
+}
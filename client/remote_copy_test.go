@@ -0,0 +1,108 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFollowPerfMarkers(t *testing.T) {
+	markers := "Perf Marker\n\tTimestamp: 1700000000\n\tStripe Index: 0\n\tStripe Bytes Transferred: 512\n\tTotal Stripe Count: 1\nEnd\n" +
+		"Perf Marker\n\tStripe Bytes Transferred: 1024\nEnd\n"
+
+	t.Run("success", func(t *testing.T) {
+		progress := []int64{}
+		transferred, err := followPerfMarkers(strings.NewReader(markers+"success: Created\n"), func(copied int64) { progress = append(progress, copied) })
+		require.NoError(t, err)
+		assert.Equal(t, int64(1024), transferred)
+		assert.Equal(t, []int64{512, 1024}, progress)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		_, err := followPerfMarkers(strings.NewReader(markers+"failure: source returned 404\n"), func(int64) {})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "source returned 404")
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		_, err := followPerfMarkers(strings.NewReader(markers), func(int64) {})
+		assert.ErrorContains(t, err, "without reporting its outcome")
+	})
+}
+
+func TestRunThirdPartyCopy(t *testing.T) {
+	var lastReq *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastReq = r
+		switch r.URL.Path {
+		case "/unsupported":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("permission denied"))
+		default:
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = fmt.Fprint(w, "Perf Marker\n\tStripe Bytes Transferred: 100\nEnd\nsuccess: Created\n")
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		completed := false
+		callback := func(path string, copied int64, size int64, done bool) {
+			assert.Equal(t, srv.URL+"/dst", path)
+			assert.Equal(t, int64(200), size)
+			completed = completed || done
+		}
+		result, err := runThirdPartyCopy(ctx, srv.Client(), "https://origin-a/src", "src-token", srv.URL+"/dst", "dst-token", 200, callback)
+		require.NoError(t, err)
+		assert.Equal(t, int64(200), result.TransferredBytes)
+		require.Len(t, result.Attempts, 1)
+		assert.NoError(t, result.Attempts[0].Error)
+		assert.True(t, completed)
+
+		require.NotNil(t, lastReq)
+		assert.Equal(t, "COPY", lastReq.Method)
+		assert.Equal(t, "https://origin-a/src", lastReq.Header.Get("Source"))
+		assert.Equal(t, "Bearer dst-token", lastReq.Header.Get("Authorization"))
+		assert.Equal(t, "Bearer src-token", lastReq.Header.Get("TransferHeaderAuthorization"))
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := runThirdPartyCopy(ctx, srv.Client(), "https://origin-a/src", "", srv.URL+"/unsupported", "", 200, nil)
+		assert.ErrorIs(t, err, errTpcUnsupported)
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		result, err := runThirdPartyCopy(ctx, srv.Client(), "https://origin-a/src", "", srv.URL+"/forbidden", "", 200, nil)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, errTpcUnsupported)
+		assert.Contains(t, err.Error(), "permission denied")
+		assert.Equal(t, err, result.Error)
+	})
+}
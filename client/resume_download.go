@@ -0,0 +1,173 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// The suffix of the file, next to a partial download, that records the validators of
+// the object the partial download was fetched from
+const resumeValidatorsSuffix = ".pelican-resume"
+
+// Returned when a server ignores If-Range and sends the remainder of an object that
+// no longer matches the partial download
+var errResumeMismatch = errors.New("the remote object changed since the partial download; the partial download was discarded")
+
+// Returns the size of a partial local copy of an object, or 0 if there is
+// nothing that could be resumed
+func partialDownloadSize(dest string, totalSize int64) int64 {
+	if totalSize <= 0 {
+		return 0
+	}
+	info, err := os.Stat(dest)
+	if err != nil || !info.Mode().IsRegular() || info.Size() >= totalSize {
+		return 0
+	}
+	return info.Size()
+}
+
+// The value for the If-Range header of a request resuming a download of the object;
+// weak ETags cannot be used with If-Range, so the Last-Modified time is used instead.
+// Empty if the validators can't be used to resume a download.
+func (ov ObjectValidators) ifRange() string {
+	if ov.ETag != "" && !strings.HasPrefix(ov.ETag, "W/") {
+		return ov.ETag
+	}
+	return ov.LastModified
+}
+
+// Report whether a response with the validators is for the object the receiver was
+// recorded from, comparing the validator that is sent as If-Range
+func (ov ObjectValidators) sameObject(other ObjectValidators) bool {
+	if ov.ETag != "" && !strings.HasPrefix(ov.ETag, "W/") {
+		return ov.ETag == other.ETag
+	}
+	return ov.LastModified != "" && ov.LastModified == other.LastModified
+}
+
+// Record the validators of the object being downloaded to dest, so that the download
+// is only resumed if the object hasn't changed
+func recordResumeValidators(dest string, validators ObjectValidators) error {
+	if validators.ifRange() == "" {
+		removeResumeValidators(dest)
+		return nil
+	}
+	buf, err := json.Marshal(validators)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the validators of the download")
+	}
+	if err = os.WriteFile(dest+resumeValidatorsSuffix, buf, 0644); err != nil {
+		return errors.Wrap(err, "failed to record the validators of the download")
+	}
+	return nil
+}
+
+// Read the validators recorded for a partial download; they are empty if none were recorded
+func readResumeValidators(dest string) (validators ObjectValidators) {
+	buf, err := os.ReadFile(dest + resumeValidatorsSuffix)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(buf, &validators); err != nil {
+		log.Debugln("Ignoring the invalid validators recorded for the partial download", dest+":", err)
+		return ObjectValidators{}
+	}
+	return
+}
+
+// Remove the validators recorded for a download that no longer needs to be resumed
+func removeResumeValidators(dest string) {
+	if err := os.Remove(dest + resumeValidatorsSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warningln("Failed to remove the validators recorded for the partial download", dest+":", err)
+	}
+}
+
+// Remove a partial download that can't be resumed, along with its validators
+func discardPartialDownload(dest string) {
+	if err := os.Remove(dest); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warningln("Failed to remove the partial download", dest+":", err)
+	}
+	removeResumeValidators(dest)
+}
+
+// Determine whether a partial local copy of an object can be resumed.
+//
+// The partial copy is only resumed if the ETag (or, lacking a strong ETag, the
+// Last-Modified time) recorded when it was downloaded still matches the remote
+// object.  This is checked with a one-byte ranged request carrying the validator as
+// If-Range: a server sends the byte only if the object is unchanged.  Otherwise, the
+// offset is 0 and the download must start over.
+//
+// The returned validators should be sent as the If-Range header of the resumed request
+// so that the server sends the full object, rather than the remainder, if the object
+// changed after validation.
+func partialDownloadOffset(ctx context.Context, client *http.Client, transferUrl string, dest string, totalSize int64, token string, project string) (offset int64, validators ObjectValidators) {
+	partialSize := partialDownloadSize(dest, totalSize)
+	if partialSize == 0 {
+		return 0, ObjectValidators{}
+	}
+	fields, ok := ctx.Value(logFields("fields")).(log.Fields)
+	if !ok {
+		fields = log.Fields{}
+	}
+
+	recorded := readResumeValidators(dest)
+	if recorded.ifRange() == "" {
+		log.WithFields(fields).Infof("No validators were recorded for the partial local copy of %s; starting download over", dest)
+		return 0, ObjectValidators{}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, transferUrl, nil)
+	if err != nil {
+		return 0, ObjectValidators{}
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", partialSize, partialSize))
+	req.Header.Set("If-Range", recorded.ifRange())
+	req.Header.Set("User-Agent", getUserAgent(project))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.WithFields(fields).Debugln("Unable to validate partial download; starting over:", err)
+		return 0, ObjectValidators{}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		log.WithFields(fields).Infof("Partial local copy of %s does not match the remote object or the server does not support ranged requests (status %d); starting download over", dest, resp.StatusCode)
+		return 0, ObjectValidators{}
+	}
+	// A server that ignores If-Range sends the byte regardless
+	if !recorded.sameObject(validatorsFromHeader(resp.Header)) {
+		log.WithFields(fields).Infoln("Partial local copy of", dest, "does not match the remote object; starting download over")
+		return 0, ObjectValidators{}
+	}
+
+	log.WithFields(fields).Infof("Resuming download of %s at byte %d of %d", dest, partialSize, totalSize)
+	return partialSize, recorded
+}
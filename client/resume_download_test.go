@@ -0,0 +1,248 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/test_utils"
+)
+
+func TestResumeDownload(t *testing.T) {
+	ctx, _, _ := test_utils.TestContext(context.Background(), t)
+	test_utils.InitClient(t, map[string]any{})
+
+	contents := make([]byte, 3*64*1024+17)
+	_, err := rand.Read(contents)
+	require.NoError(t, err)
+	modTime := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	// Serve the object with Range and ETag support, recording the Range headers received
+	var mutex sync.Mutex
+	var ranges []string
+	record := func(r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		ranges = append(ranges, r.Header.Get("Range"))
+	}
+	newServer := func(handler http.HandlerFunc) *url.URL {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		serverUrl, err := url.Parse(server.URL)
+		require.NoError(t, err)
+		serverUrl.Path = "/test.txt"
+		return serverUrl
+	}
+	serverUrl := newServer(func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "test.txt", modTime, bytes.NewReader(contents))
+	})
+	attempt := transferAttemptDetails{Url: serverUrl, Resume: true}
+
+	reset := func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		ranges = nil
+	}
+	writePartial := func(t *testing.T, partial []byte, validators ObjectValidators) string {
+		dest := filepath.Join(t.TempDir(), "test.txt")
+		require.NoError(t, os.WriteFile(dest, partial, 0644))
+		if !validators.IsEmpty() {
+			require.NoError(t, recordResumeValidators(dest, validators))
+		}
+		return dest
+	}
+	checkDownload := func(t *testing.T, dest string) {
+		written, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		assert.Equal(t, contents, written)
+		assert.NoFileExists(t, dest+resumeValidatorsSuffix)
+	}
+
+	t.Run("resume-valid-partial", func(t *testing.T) {
+		reset()
+		partial := len(contents) / 2
+		dest := writePartial(t, contents[:partial], ObjectValidators{ETag: `"v1"`})
+
+		downloaded, _, _, _, _, err := downloadHTTP(ctx, nil, nil, attempt, dest, int64(len(contents)), "", "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(contents)), downloaded)
+
+		checkDownload(t, dest)
+		mutex.Lock()
+		assert.Equal(t, []string{"bytes=98312-98312", "bytes=98312-"}, ranges)
+		mutex.Unlock()
+	})
+
+	t.Run("resume-with-last-modified", func(t *testing.T) {
+		reset()
+		noEtagUrl := newServer(func(w http.ResponseWriter, r *http.Request) {
+			record(r)
+			w.Header().Set("ETag", `W/"weak"`)
+			http.ServeContent(w, r, "test.txt", modTime, bytes.NewReader(contents))
+		})
+		dest := writePartial(t, contents[:1000], ObjectValidators{ETag: `W/"weak"`, LastModified: modTime.Format(http.TimeFormat)})
+
+		downloaded, _, _, _, _, err := downloadHTTP(ctx, nil, nil, transferAttemptDetails{Url: noEtagUrl, Resume: true}, dest, int64(len(contents)), "", "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(contents)), downloaded)
+
+		checkDownload(t, dest)
+		mutex.Lock()
+		assert.Equal(t, []string{"bytes=1000-1000", "bytes=1000-"}, ranges)
+		mutex.Unlock()
+	})
+
+	t.Run("partial-without-validators-restarts", func(t *testing.T) {
+		reset()
+		dest := writePartial(t, contents[:1000], ObjectValidators{})
+
+		downloaded, _, _, _, _, err := downloadHTTP(ctx, nil, nil, attempt, dest, int64(len(contents)), "", "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(contents)), downloaded)
+
+		checkDownload(t, dest)
+		mutex.Lock()
+		assert.Equal(t, []string{""}, ranges)
+		mutex.Unlock()
+	})
+
+	t.Run("mismatched-partial-restarts", func(t *testing.T) {
+		reset()
+		// The partial copy is of an older version of the object, so the server ignores
+		// the range of the validation request
+		dest := writePartial(t, bytes.Repeat([]byte("x"), 100), ObjectValidators{ETag: `"v0"`})
+
+		downloaded, _, _, _, _, err := downloadHTTP(ctx, nil, nil, attempt, dest, int64(len(contents)), "", "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(contents)), downloaded)
+
+		checkDownload(t, dest)
+		mutex.Lock()
+		assert.Equal(t, []string{"bytes=100-100", ""}, ranges)
+		mutex.Unlock()
+	})
+
+	t.Run("resume-disabled", func(t *testing.T) {
+		reset()
+		dest := writePartial(t, contents[:1000], ObjectValidators{ETag: `"v1"`})
+
+		noResume := attempt
+		noResume.Resume = false
+		_, _, _, _, _, err := downloadHTTP(ctx, nil, nil, noResume, dest, int64(len(contents)), "", "")
+		require.NoError(t, err)
+
+		checkDownload(t, dest)
+		mutex.Lock()
+		assert.Equal(t, []string{""}, ranges)
+		mutex.Unlock()
+	})
+
+	t.Run("interrupted-download-is-resumed", func(t *testing.T) {
+		reset()
+		dest := filepath.Join(t.TempDir(), "test.txt")
+		// The connection is dropped after half of the object
+		interruptedUrl := newServer(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Length", strconv.Itoa(len(contents)))
+			_, _ = w.Write(contents[:len(contents)/2])
+			panic(http.ErrAbortHandler)
+		})
+
+		_, _, _, _, _, err := downloadHTTP(ctx, nil, nil, transferAttemptDetails{Url: interruptedUrl, Resume: true}, dest, int64(len(contents)), "", "")
+		require.Error(t, err)
+		assert.Equal(t, ObjectValidators{ETag: `"v1"`}, readResumeValidators(dest))
+		partial, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		require.NotEmpty(t, partial)
+		require.Less(t, len(partial), len(contents))
+
+		downloaded, _, _, _, _, err := downloadHTTP(ctx, nil, nil, attempt, dest, int64(len(contents)), "", "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(contents)), downloaded)
+
+		checkDownload(t, dest)
+		mutex.Lock()
+		assert.Equal(t, []string{fmt.Sprintf("bytes=%d-%d", len(partial), len(partial)), fmt.Sprintf("bytes=%d-", len(partial))}, ranges)
+		mutex.Unlock()
+	})
+
+	t.Run("object-changed-during-resume", func(t *testing.T) {
+		reset()
+		dest := writePartial(t, contents[:1000], ObjectValidators{ETag: `"v1"`})
+
+		// The validation request sees one ETag; the resumed request sees another, so the
+		// server ignores the range (per If-Range) and sends the full object.
+		var requests atomic.Int64
+		changingUrl := newServer(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) > 1 {
+				w.Header().Set("ETag", `"v2"`)
+			} else {
+				w.Header().Set("ETag", `"v1"`)
+			}
+			http.ServeContent(w, r, "test.txt", time.Time{}, bytes.NewReader(contents))
+		})
+
+		downloaded, _, _, _, _, err := downloadHTTP(ctx, nil, nil, transferAttemptDetails{Url: changingUrl, Resume: true}, dest, int64(len(contents)), "", "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(contents)), downloaded)
+
+		checkDownload(t, dest)
+	})
+
+	t.Run("server-ignoring-if-range", func(t *testing.T) {
+		reset()
+		dest := writePartial(t, contents[:1000], ObjectValidators{ETag: `"v1"`})
+
+		// As above, but the server sends the remainder of the changed object anyway
+		var requests atomic.Int64
+		ignoringUrl := newServer(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) > 1 {
+				w.Header().Set("ETag", `"v2"`)
+			} else {
+				w.Header().Set("ETag", `"v1"`)
+			}
+			r.Header.Del("If-Range")
+			http.ServeContent(w, r, "test.txt", time.Time{}, bytes.NewReader(contents))
+		})
+
+		_, _, _, _, _, err := downloadHTTP(ctx, nil, nil, transferAttemptDetails{Url: ignoringUrl, Resume: true}, dest, int64(len(contents)), "", "")
+		assert.ErrorIs(t, err, errResumeMismatch)
+		// The partial copy is discarded so that the next attempt starts over
+		assert.NoFileExists(t, dest)
+		assert.NoFileExists(t, dest+resumeValidatorsSuffix)
+	})
+}
@@ -0,0 +1,139 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+)
+
+// The validators of an object, as reported by the server it was downloaded from.
+// They allow a copy of the object to be revalidated with a conditional GET.
+type ObjectValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// Returns true if the server provided no validators for the object
+func (ov ObjectValidators) IsEmpty() bool {
+	return ov.ETag == "" && ov.LastModified == ""
+}
+
+// Extract the object validators from a server response.  Weak ETags are kept
+// as they remain valid for If-None-Match comparisons.
+func validatorsFromHeader(header http.Header) ObjectValidators {
+	return ObjectValidators{
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+	}
+}
+
+// Confirm whether a remote object still matches the validators recorded when a
+// copy of it was downloaded.
+//
+// A conditional GET is sent to the origin; if it responds with 304 (Not Modified),
+// modified is false and the object is not transferred.  Otherwise, modified is true
+// and the response body is discarded without being read.  The returned validators
+// are the ones currently reported by the server.
+func DoRevalidate(ctx context.Context, remoteObject string, validators ObjectValidators, options ...TransferOption) (modified bool, current ObjectValidators, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Debugln("Panic captured while attempting to revalidate:", r)
+			log.Debugln("Panic caused by the following", string(debug.Stack()))
+			ret := fmt.Sprintf("Unrecoverable error (panic) while revalidating object: %v", r)
+			err = errors.New(ret)
+			return
+		}
+	}()
+
+	if validators.IsEmpty() {
+		return true, validators, nil
+	}
+
+	pUrl, dirResp, token, err := prepareStat(ctx, remoteObject, options...)
+	if err != nil {
+		return
+	}
+	objectUrl, err := objectMetadataUrl(pUrl, dirResp)
+	if err != nil {
+		return
+	}
+
+	tokenContents := ""
+	if token != nil {
+		tokenContents, _ = token.get()
+	}
+
+	client := &http.Client{Transport: config.GetTransport()}
+	return conditionalGet(ctx, client, objectUrl.String(), validators, tokenContents)
+}
+
+// Issue a conditional GET for the object at objectUrl, returning whether it was
+// modified relative to the given validators
+func conditionalGet(ctx context.Context, client *http.Client, objectUrl string, validators ObjectValidators, token string) (modified bool, current ObjectValidators, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectUrl, nil)
+	if err != nil {
+		err = errors.Wrap(err, "failed to create conditional GET request")
+		return
+	}
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+	req.Header.Set("User-Agent", getUserAgent(""))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	log.Debugln("Revalidating object at", objectUrl)
+	resp, err := client.Do(req)
+	if err != nil {
+		err = errors.Wrap(err, "failed to revalidate object")
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		// A 304 response may omit validators that haven't changed
+		current = validatorsFromHeader(resp.Header)
+		if current.ETag == "" {
+			current.ETag = validators.ETag
+		}
+		if current.LastModified == "" {
+			current.LastModified = validators.LastModified
+		}
+		return false, current, nil
+	case http.StatusOK:
+		return true, validatorsFromHeader(resp.Header), nil
+	default:
+		sce := StatusCodeError(resp.StatusCode)
+		err = errors.Wrapf(&sce, "object server %s returned an unexpected status for conditional GET", req.URL.Host)
+		return
+	}
+}
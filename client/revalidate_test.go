@@ -0,0 +1,82 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalGet(t *testing.T) {
+	modTime := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	etag := `"v1"`
+	authHeaders := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "object", modTime, bytes.NewReader([]byte("object contents")))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := srv.Client()
+
+	t.Run("etag-unchanged", func(t *testing.T) {
+		modified, current, err := conditionalGet(ctx, client, srv.URL, ObjectValidators{ETag: `"v1"`}, "token")
+		require.NoError(t, err)
+		assert.False(t, modified)
+		assert.Equal(t, `"v1"`, current.ETag)
+		assert.Equal(t, "Bearer token", authHeaders[len(authHeaders)-1])
+	})
+
+	t.Run("last-modified-unchanged", func(t *testing.T) {
+		modified, _, err := conditionalGet(ctx, client, srv.URL, ObjectValidators{LastModified: modTime.Format(http.TimeFormat)}, "")
+		require.NoError(t, err)
+		assert.False(t, modified)
+	})
+
+	t.Run("etag-changed", func(t *testing.T) {
+		modified, current, err := conditionalGet(ctx, client, srv.URL, ObjectValidators{ETag: `"v0"`}, "")
+		require.NoError(t, err)
+		assert.True(t, modified)
+		assert.Equal(t, ObjectValidators{ETag: `"v1"`, LastModified: modTime.Format(http.TimeFormat)}, current)
+	})
+
+	t.Run("last-modified-changed", func(t *testing.T) {
+		modified, _, err := conditionalGet(ctx, client, srv.URL, ObjectValidators{LastModified: modTime.Add(-time.Hour).Format(http.TimeFormat)}, "")
+		require.NoError(t, err)
+		assert.True(t, modified)
+	})
+
+	t.Run("missing-object", func(t *testing.T) {
+		notFound := httptest.NewServer(http.NotFoundHandler())
+		defer notFound.Close()
+		_, _, err := conditionalGet(ctx, notFound.Client(), notFound.URL, ObjectValidators{ETag: `"v1"`}, "")
+		var sce *StatusCodeError
+		require.ErrorAs(t, err, &sce)
+		assert.Equal(t, http.StatusNotFound, int(*sce))
+	})
+}
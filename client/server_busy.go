@@ -0,0 +1,151 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServerBusyError is returned when a cache or origin declines a transfer because all
+// of its transfer slots are in use.  Unlike other failures, the same server is
+// expected to accept the transfer once a slot frees up.
+type ServerBusyError struct {
+	StatusCode int
+	// How long the server asked the client to wait; 0 if it didn't say
+	RetryAfter time.Duration
+}
+
+const (
+	// The first wait for a busy server that didn't say how long to wait; each
+	// subsequent wait doubles, up to busyRetryMaxDelay
+	busyRetryInitialDelay = time.Second
+	busyRetryMaxDelay     = 30 * time.Second
+)
+
+// Words in the reason phrase of a 503 response that mark it as a busy server, as
+// sent by XRootD-based servers relaying a "wait" response, rather than a server
+// that is down
+var busyReasonMarkers = []string{"busy", "wait", "slot", "overload"}
+
+func (e *ServerBusyError) Error() string {
+	msg := fmt.Sprintf("server is busy (HTTP status %d)", e.StatusCode)
+	if e.RetryAfter > 0 {
+		msg += "; asked to retry after " + e.RetryAfter.String()
+	}
+	return msg
+}
+
+func (e *ServerBusyError) Is(target error) bool {
+	_, ok := target.(*ServerBusyError)
+	return ok
+}
+
+// Returns the busy error for a server response that signals the server is out of
+// transfer slots, or nil if the response is some other failure.  A 429 always signals
+// a busy server; a 503 does if it carries a Retry-After header or a busy reason phrase.
+func serverBusyFromResponse(resp *http.Response) *ServerBusyError {
+	if resp == nil {
+		return nil
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	retryAfterHeader := resp.Header.Get("Retry-After")
+	if resp.StatusCode == http.StatusServiceUnavailable && retryAfterHeader == "" {
+		reason := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))))
+		busy := false
+		for _, marker := range busyReasonMarkers {
+			if strings.Contains(reason, marker) {
+				busy = true
+				break
+			}
+		}
+		if !busy {
+			return nil
+		}
+	}
+	return &ServerBusyError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(retryAfterHeader, time.Now()),
+	}
+}
+
+// Parse a Retry-After header, which is either a number of seconds or an HTTP date;
+// returns 0 if the header is missing, malformed, or in the past
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil && when.After(now) {
+		return when.Sub(now)
+	}
+	return 0
+}
+
+// How long to wait before asking a busy server for the object again, given the
+// number of busy responses it has already sent and the time left before the
+// client gives up on it.  The server's requested delay is honored when given;
+// otherwise the delay backs off exponentially.  Jitter keeps clients that were
+// turned away together from returning together.
+func busyRetryDelay(busy *ServerBusyError, retries int, remaining time.Duration) time.Duration {
+	delay := busy.RetryAfter
+	if delay <= 0 {
+		delay = busyRetryInitialDelay
+		for idx := 0; idx < retries && delay < busyRetryMaxDelay; idx++ {
+			delay *= 2
+		}
+		if delay > busyRetryMaxDelay {
+			delay = busyRetryMaxDelay
+		}
+		// Wait between half and all of the backoff
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	} else {
+		// Wait up to a quarter longer than the server asked
+		delay += time.Duration(rand.Int63n(int64(delay/4) + 1))
+	}
+	if delay > remaining {
+		delay = remaining
+	}
+	return delay
+}
+
+// Sleep for the delay unless the context is cancelled first
+func waitForBusyServer(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
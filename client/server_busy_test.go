@@ -0,0 +1,154 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/test_utils"
+)
+
+func TestServerBusyFromResponse(t *testing.T) {
+	response := func(code int, status string, retryAfter string) *http.Response {
+		resp := &http.Response{StatusCode: code, Status: status, Header: http.Header{}}
+		if retryAfter != "" {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+		return resp
+	}
+
+	busy := serverBusyFromResponse(response(http.StatusServiceUnavailable, "503 Service Unavailable", "5"))
+	require.NotNil(t, busy)
+	assert.Equal(t, 5*time.Second, busy.RetryAfter)
+	assert.True(t, IsRetryable(busy))
+	assert.Equal(t, http.StatusServiceUnavailable, errorStatusCode(busy))
+
+	busy = serverBusyFromResponse(response(http.StatusServiceUnavailable, "503 Server Busy", ""))
+	require.NotNil(t, busy)
+	assert.Zero(t, busy.RetryAfter)
+
+	busy = serverBusyFromResponse(response(http.StatusTooManyRequests, "429 Too Many Requests", ""))
+	require.NotNil(t, busy)
+
+	// A 503 without a busy marker is a server that is down, not one that is busy
+	assert.Nil(t, serverBusyFromResponse(response(http.StatusServiceUnavailable, "503 Service Unavailable", "")))
+	assert.Nil(t, serverBusyFromResponse(response(http.StatusInternalServerError, "500 Server Busy", "5")))
+	assert.Nil(t, serverBusyFromResponse(nil))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 30*time.Second, parseRetryAfter("30", now))
+	assert.Equal(t, 2*time.Minute, parseRetryAfter(now.Add(2*time.Minute).Format(http.TimeFormat), now))
+	assert.Zero(t, parseRetryAfter("", now))
+	assert.Zero(t, parseRetryAfter("-5", now))
+	assert.Zero(t, parseRetryAfter("soon", now))
+	assert.Zero(t, parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+}
+
+func TestBusyRetryDelay(t *testing.T) {
+	// The server's requested delay is honored, with up to a quarter added as jitter
+	for idx := 0; idx < 20; idx++ {
+		delay := busyRetryDelay(&ServerBusyError{RetryAfter: 4 * time.Second}, idx, time.Minute)
+		assert.GreaterOrEqual(t, delay, 4*time.Second)
+		assert.LessOrEqual(t, delay, 5*time.Second)
+	}
+
+	// Otherwise, the delay backs off exponentially up to the maximum
+	for retries, backoff := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		delay := busyRetryDelay(&ServerBusyError{}, retries, time.Minute)
+		assert.GreaterOrEqual(t, delay, backoff/2)
+		assert.LessOrEqual(t, delay, backoff)
+	}
+	assert.LessOrEqual(t, busyRetryDelay(&ServerBusyError{}, 20, time.Hour), busyRetryMaxDelay)
+
+	// The delay never runs past the time left
+	assert.Equal(t, time.Second, busyRetryDelay(&ServerBusyError{RetryAfter: 10 * time.Second}, 0, time.Second))
+}
+
+// Test that a download waits out a busy server, and fails over once it stops waiting
+func TestDownloadBusyServer(t *testing.T) {
+	// The server is busy for the first `busyResponses` requests for the object
+	busyServer := func(busyResponses int32) *url.URL {
+		var requests atomic.Int32
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Range") == "0-0" {
+				_, _ = w.Write([]byte("h"))
+				return
+			}
+			if requests.Add(1) <= busyResponses {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte("hello"))
+		}))
+		t.Cleanup(svr.Close)
+		svrURL, err := url.Parse(svr.URL)
+		require.NoError(t, err)
+		return svrURL
+	}
+	download := func(attempts ...*url.URL) TransferResults {
+		transfer := &transferFile{
+			ctx:       context.Background(),
+			job:       &TransferJob{ctx: context.Background()},
+			localPath: filepath.Join(t.TempDir(), "test.txt"),
+			remoteURL: &url.URL{Path: "/test.txt"},
+		}
+		for _, attempt := range attempts {
+			transfer.attempts = append(transfer.attempts, transferAttemptDetails{Url: attempt})
+		}
+		transferResult, err := downloadObject(transfer)
+		require.NoError(t, err)
+		return transferResult
+	}
+
+	t.Run("wait", func(t *testing.T) {
+		test_utils.InitClient(t, map[string]any{"Client.BusyRetryTimeout": "10s"})
+		svrURL := busyServer(1)
+		transferResult := download(svrURL)
+		require.NoError(t, transferResult.Error)
+		require.Len(t, transferResult.Attempts, 1)
+		assert.Equal(t, svrURL.Host, transferResult.Attempts[0].Endpoint)
+		assert.GreaterOrEqual(t, transferResult.Attempts[0].BusyWaitTime, time.Second)
+	})
+
+	t.Run("failover", func(t *testing.T) {
+		test_utils.InitClient(t, map[string]any{"Client.BusyRetryTimeout": "0s"})
+		busyURL := busyServer(100)
+		goodURL := busyServer(0)
+		transferResult := download(busyURL, goodURL)
+		require.NoError(t, transferResult.Error)
+		require.Len(t, transferResult.Attempts, 2)
+		var busy *ServerBusyError
+		assert.ErrorAs(t, transferResult.Attempts[0].Error, &busy)
+		assert.Zero(t, transferResult.Attempts[0].BusyWaitTime)
+		assert.Equal(t, goodURL.Host, transferResult.Attempts[1].Endpoint)
+	})
+}
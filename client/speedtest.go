@@ -0,0 +1,285 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, University of Nebraska-Lincoln
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/pelican_url"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// The options of a federation speed test
+	SpeedTestOptions struct {
+		// An existing object to download; ignored if UploadPrefix is set
+		Object string
+		// A writable collection to upload a synthetic object to before downloading it
+		UploadPrefix string
+		// The size of the synthetic object
+		Size int64
+		// The maximum number of caches and of origins to test
+		Endpoints int
+	}
+
+	// The result of one speed test transfer against one endpoint
+	SpeedTestResult struct {
+		Direction string `json:"direction"` // "upload" or "download"
+		Server    string `json:"server"`    // "cache" or "origin"
+		Endpoint  string `json:"endpoint"`
+		// For downloads, the time until the first byte of the response; for uploads, the
+		// time until the connection to the endpoint is ready
+		Latency    time.Duration `json:"latency"`
+		Duration   time.Duration `json:"duration"`
+		Bytes      int64         `json:"bytes"`
+		Throughput float64       `json:"throughputBytesPerSecond"`
+		Error      string        `json:"error,omitempty"`
+	}
+)
+
+const (
+	speedTestDirectionDownload = "download"
+	speedTestDirectionUpload   = "upload"
+)
+
+// Run a speed test against the caches and origins the director picks for an object.
+//
+// If opts.UploadPrefix is set, a synthetic object of opts.Size bytes is first uploaded
+// through the director's chosen origin, used for the downloads, and deleted at the end;
+// otherwise opts.Object is downloaded.  Up to opts.Endpoints caches and origins are each
+// tested in turn, in the director's order.  Failures against a single endpoint are
+// recorded in its result; an error is only returned if the director cannot be queried.
+func DoSpeedTest(ctx context.Context, opts SpeedTestOptions, options ...TransferOption) (results []SpeedTestResult, err error) {
+	if opts.Endpoints <= 0 {
+		opts.Endpoints = 1
+	}
+	httpClient := &http.Client{Transport: config.GetTransport()}
+
+	object := opts.Object
+	if opts.UploadPrefix != "" {
+		if opts.Size <= 0 {
+			return nil, errors.New("the size of the speed test object must be positive")
+		}
+		suffix := make([]byte, 8)
+		if _, err = rand.Read(suffix); err != nil {
+			return nil, errors.Wrap(err, "failed to generate the speed test object name")
+		}
+		object = path.Join(opts.UploadPrefix, "pelican-speedtest-"+hex.EncodeToString(suffix))
+
+		var uploadResult SpeedTestResult
+		if uploadResult, err = speedTestUpload(ctx, httpClient, object, opts.Size, options...); err != nil {
+			return nil, err
+		}
+		results = append(results, uploadResult)
+		if uploadResult.Error != "" {
+			return results, nil
+		}
+		defer func() {
+			if err := DoDelete(ctx, object, false, options...); err != nil {
+				log.Warningf("Failed to delete the speed test object %s: %v", object, err)
+			}
+		}()
+	} else if object == "" {
+		return nil, errors.New("either an object to download or a collection to upload to is required")
+	}
+
+	pUrl, err := ParseRemoteAsPUrl(ctx, object)
+	if err != nil {
+		return results, err
+	}
+	cacheResp, token, err := speedTestDirectorQuery(ctx, pUrl, options...)
+	if err != nil {
+		return results, errors.Wrap(err, "failed to find the caches for the speed test object")
+	}
+	for _, server := range cacheResp.ObjectServers[:min(len(cacheResp.ObjectServers), opts.Endpoints)] {
+		results = append(results, speedTestDownloadFrom(ctx, httpClient, server, "cache", token))
+	}
+
+	// Ask for the origins by requesting a direct read
+	originPUrl := *pUrl
+	query, _ := url.ParseQuery(originPUrl.RawQuery)
+	query.Set(pelican_url.QueryDirectRead, "")
+	originPUrl.RawQuery = query.Encode()
+	originResp, _, err := speedTestDirectorQuery(ctx, &originPUrl, options...)
+	if err != nil {
+		log.Warningln("Unable to find the origins for the speed test object; only caches were tested:", err)
+		return results, nil
+	}
+	for _, server := range originResp.ObjectServers[:min(len(originResp.ObjectServers), opts.Endpoints)] {
+		results = append(results, speedTestDownloadFrom(ctx, httpClient, server, "origin", token))
+	}
+	return results, nil
+}
+
+// Query the director for the servers of an object to read, along with the token to read it with
+func speedTestDirectorQuery(ctx context.Context, pUrl *pelican_url.PelicanURL, options ...TransferOption) (dirResp server_structs.DirectorResponse, token string, err error) {
+	dirResp, err = GetDirectorInfoForPath(ctx, pUrl, http.MethodGet, "")
+	if err != nil {
+		return
+	}
+	if dirResp.XPelNsHdr.RequireToken {
+		tokenGen := newTokenGenerator(pUrl, &dirResp, false, true)
+		applyTokenOptions(tokenGen, options...)
+		if token, err = tokenGen.get(); err != nil || token == "" {
+			err = errors.Wrap(err, "failed to get token for the speed test object")
+			return
+		}
+	}
+	if len(dirResp.ObjectServers) == 0 {
+		err = errors.New("the director returned no servers")
+	}
+	return
+}
+
+func newSpeedTestRequest(ctx context.Context, method string, server *url.URL, token string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, server.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", getUserAgent(""))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// Fill in the timings of a speed test transfer that started at start, if it started at all
+func (result *SpeedTestResult) finish(start, firstByte time.Time, err error) {
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if start.IsZero() {
+		return
+	}
+	result.Duration = time.Since(start)
+	if !firstByte.IsZero() {
+		result.Latency = firstByte.Sub(start)
+	}
+	if transferTime := result.Duration - result.Latency; transferTime > 0 && result.Bytes > 0 {
+		result.Throughput = float64(result.Bytes) / transferTime.Seconds()
+	}
+}
+
+// Download an object from one server, discarding its contents
+func speedTestDownloadFrom(ctx context.Context, httpClient *http.Client, server *url.URL, serverType string, token string) (result SpeedTestResult) {
+	result = SpeedTestResult{
+		Direction: speedTestDirectionDownload,
+		Server:    serverType,
+		Endpoint:  (&url.URL{Scheme: server.Scheme, Host: server.Host}).String(),
+	}
+	var start, firstByte time.Time
+	var err error
+	defer func() { result.finish(start, firstByte, err) }()
+
+	req, err := newSpeedTestRequest(ctx, http.MethodGet, server, token, nil)
+	if err != nil {
+		return
+	}
+	trace := &httptrace.ClientTrace{GotFirstResponseByte: func() { firstByte = time.Now() }}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	start = time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = errors.Errorf("server returned %s", resp.Status)
+		return
+	}
+	result.Bytes, err = io.Copy(io.Discard, resp.Body)
+	return
+}
+
+// Upload a synthetic object of the given size through the origin the director picks
+func speedTestUpload(ctx context.Context, httpClient *http.Client, object string, size int64, options ...TransferOption) (result SpeedTestResult, err error) {
+	pUrl, err := ParseRemoteAsPUrl(ctx, object)
+	if err != nil {
+		return
+	}
+	dirResp, err := GetDirectorInfoForPath(ctx, pUrl, http.MethodPut, "")
+	if err != nil {
+		err = errors.Wrap(err, "failed to find an origin to upload the speed test object to")
+		return
+	}
+	if len(dirResp.ObjectServers) == 0 {
+		err = errors.New("the director returned no origins to upload the speed test object to")
+		return
+	}
+	tokenGen := newTokenGenerator(pUrl, &dirResp, true, true)
+	applyTokenOptions(tokenGen, options...)
+	token, err := tokenGen.get()
+	if err != nil || token == "" {
+		err = errors.Wrap(err, "failed to get token to upload the speed test object")
+		return
+	}
+
+	server := dirResp.ObjectServers[0]
+	result = SpeedTestResult{
+		Direction: speedTestDirectionUpload,
+		Server:    "origin",
+		Endpoint:  (&url.URL{Scheme: server.Scheme, Host: server.Host}).String(),
+	}
+	var start, connected time.Time
+	var uploadErr error
+	defer func() { result.finish(start, connected, uploadErr) }()
+
+	// The contents only need to be incompressible, not unpredictable
+	body := io.LimitReader(mrand.New(mrand.NewSource(time.Now().UnixNano())), size)
+	req, uploadErr := newSpeedTestRequest(ctx, http.MethodPut, server, token, body)
+	if uploadErr != nil {
+		return
+	}
+	req.ContentLength = size
+	trace := &httptrace.ClientTrace{GotConn: func(httptrace.GotConnInfo) { connected = time.Now() }}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	start = time.Now()
+	resp, uploadErr := httpClient.Do(req)
+	if uploadErr != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		uploadErr = errors.Errorf("server returned %s", resp.Status)
+		return
+	}
+	result.Bytes = size
+	return
+}
+
+// Format a throughput in bytes per second for display
+func FormatThroughput(bytesPerSecond float64) string {
+	return fmt.Sprintf("%s/s", ByteCountSI(int64(bytesPerSecond)))
+}
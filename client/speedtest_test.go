@@ -0,0 +1,62 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, University of Nebraska-Lincoln
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpeedTestDownloadFrom(t *testing.T) {
+	contents := strings.Repeat("x", 100000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(contents))
+	}))
+	defer srv.Close()
+
+	server, err := url.Parse(srv.URL + "/test/object")
+	require.NoError(t, err)
+
+	result := speedTestDownloadFrom(context.Background(), srv.Client(), server, "cache", "tok")
+	assert.Empty(t, result.Error)
+	assert.Equal(t, "download", result.Direction)
+	assert.Equal(t, "cache", result.Server)
+	assert.Equal(t, srv.URL, result.Endpoint)
+	assert.Equal(t, int64(len(contents)), result.Bytes)
+	assert.Greater(t, result.Latency, time.Duration(0))
+	assert.GreaterOrEqual(t, result.Duration, result.Latency)
+	assert.Greater(t, result.Throughput, 0.0)
+
+	result = speedTestDownloadFrom(context.Background(), srv.Client(), server, "origin", "")
+	assert.Contains(t, result.Error, "403")
+	assert.Equal(t, int64(0), result.Bytes)
+	assert.Equal(t, 0.0, result.Throughput)
+}
@@ -0,0 +1,152 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// StreamPath is the local path that stands for standard output when given as the
+// destination of a download, or standard input when given as the source of an upload.
+const StreamPath = "-"
+
+var (
+	// The streams transfers to or from StreamPath use; replaced in tests
+	streamInput  io.Reader = os.Stdin
+	streamOutput io.Writer = os.Stdout
+)
+
+// Writes a download to a stream that, unlike a file, cannot be rewound.
+//
+// The writer tracks how many bytes have been passed through so that, when one endpoint
+// fails partway, the download can continue from the next endpoint with a range request.
+// If that endpoint sends the whole object instead, the bytes already written are dropped
+// rather than repeated.  The data is hashed as it is written, when a checksum type is
+// given, so the stream can be verified at the end.
+type streamWriter struct {
+	w       io.Writer
+	hash    hash.Hash
+	written int64
+	skip    int64
+}
+
+// Pairs a reader wrapping a stream, e.g. to hash it, with the Close of the stream
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Collects a download for TransferEngine.GetBytes in memory, refusing to grow
+// past the caller's limit
+type memoryBuffer struct {
+	buf     bytes.Buffer
+	maxSize int64
+}
+
+// Error returned when an object downloaded to memory is larger than allowed
+type ObjectTooLargeError struct {
+	MaxSize int64
+}
+
+func (e *ObjectTooLargeError) Error() string {
+	return fmt.Sprintf("object is larger than the limit of %d bytes", e.MaxSize)
+}
+
+func (e *ObjectTooLargeError) Is(target error) bool {
+	_, ok := target.(*ObjectTooLargeError)
+	return ok
+}
+
+func (mb *memoryBuffer) Write(p []byte) (int, error) {
+	if int64(mb.buf.Len())+int64(len(p)) > mb.maxSize {
+		return 0, &ObjectTooLargeError{MaxSize: mb.maxSize}
+	}
+	return mb.buf.Write(p)
+}
+
+func (mb *memoryBuffer) Bytes() []byte {
+	return mb.buf.Bytes()
+}
+
+func isStreamPath(localPath string) bool {
+	return localPath == StreamPath
+}
+
+func newStreamWriter(w io.Writer, ct ChecksumType) *streamWriter {
+	sw := &streamWriter{w: w}
+	if ct != ChecksumNone {
+		sw.hash = ct.newHash()
+	}
+	return sw
+}
+
+// Prepare for the response of a new attempt; partial indicates the server
+// honored the range request and is sending only the remainder of the object.
+func (sw *streamWriter) startAttempt(partial bool) {
+	if partial {
+		sw.skip = 0
+	} else {
+		sw.skip = sw.written
+	}
+}
+
+func (sw *streamWriter) Write(p []byte) (n int, err error) {
+	n = len(p)
+	if sw.skip > 0 {
+		drop := min(sw.skip, int64(len(p)))
+		sw.skip -= drop
+		p = p[drop:]
+	}
+	if len(p) == 0 {
+		return
+	}
+	written, err := sw.w.Write(p)
+	sw.written += int64(written)
+	if sw.hash != nil {
+		sw.hash.Write(p[:written])
+	}
+	if err != nil {
+		n -= len(p) - written
+	}
+	return
+}
+
+// Discard everything written so far; only possible for streams held in memory
+func (sw *streamWriter) reset() {
+	if mb, ok := sw.w.(*memoryBuffer); ok {
+		mb.buf.Reset()
+	}
+	sw.written = 0
+	sw.skip = 0
+	if sw.hash != nil {
+		sw.hash.Reset()
+	}
+}
+
+// The checksum of the data written so far, or nil if the stream is not hashed
+func (sw *streamWriter) sum() []byte {
+	if sw.hash == nil {
+		return nil
+	}
+	return sw.hash.Sum(nil)
+}
@@ -0,0 +1,104 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamWriter(t *testing.T) {
+	object := []byte("the quick brown fox jumps over the lazy dog")
+
+	t.Run("single-attempt", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		sw := newStreamWriter(buf, ChecksumSHA256)
+		sw.startAttempt(false)
+		n, err := sw.Write(object)
+		require.NoError(t, err)
+		assert.Equal(t, len(object), n)
+		assert.Equal(t, object, buf.Bytes())
+		sum := sha256.Sum256(object)
+		assert.Equal(t, sum[:], sw.sum())
+	})
+
+	t.Run("resumed-with-range", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		sw := newStreamWriter(buf, ChecksumSHA256)
+		sw.startAttempt(false)
+		_, err := sw.Write(object[:10])
+		require.NoError(t, err)
+
+		// The next endpoint honors the range request and sends only the remainder
+		sw.startAttempt(true)
+		_, err = sw.Write(object[10:])
+		require.NoError(t, err)
+		assert.Equal(t, object, buf.Bytes())
+		sum := sha256.Sum256(object)
+		assert.Equal(t, sum[:], sw.sum())
+	})
+
+	t.Run("resumed-with-full-object", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		sw := newStreamWriter(buf, ChecksumNone)
+		sw.startAttempt(false)
+		_, err := sw.Write(object[:10])
+		require.NoError(t, err)
+
+		// The next endpoint ignores the range request; the bytes already written
+		// are dropped, even when they straddle the writes
+		sw.startAttempt(false)
+		for _, chunk := range [][]byte{object[:4], object[4:15], object[15:]} {
+			n, err := sw.Write(chunk)
+			require.NoError(t, err)
+			assert.Equal(t, len(chunk), n)
+		}
+		assert.Equal(t, object, buf.Bytes())
+		assert.Equal(t, int64(len(object)), sw.written)
+		assert.Nil(t, sw.sum())
+	})
+}
+
+func TestMemoryBuffer(t *testing.T) {
+	object := []byte("the quick brown fox jumps over the lazy dog")
+	memory := &memoryBuffer{maxSize: int64(len(object))}
+	sw := newStreamWriter(memory, ChecksumNone)
+	sw.startAttempt(false)
+	_, err := sw.Write(object)
+	require.NoError(t, err)
+	assert.Equal(t, object, memory.Bytes())
+
+	// Nothing past the limit is kept
+	_, err = sw.Write([]byte("!"))
+	assert.ErrorIs(t, err, &ObjectTooLargeError{})
+	assert.Equal(t, object, memory.Bytes())
+
+	// A discarded copy starts over from the beginning
+	sw.reset()
+	assert.Empty(t, memory.Bytes())
+	sw.startAttempt(false)
+	_, err = sw.Write(object[:3])
+	require.NoError(t, err)
+	assert.Equal(t, object[:3], memory.Bytes())
+	assert.Equal(t, int64(3), sw.written)
+}
@@ -0,0 +1,464 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// A contiguous byte range of the object that is fetched from a single source
+	stripeBlock struct {
+		offset int64
+		length int64
+	}
+
+	// The state of a single source (cache or origin) participating in a striped download
+	stripeSource struct {
+		attempt   transferAttemptDetails
+		ctx       context.Context
+		cancel    context.CancelFunc
+		started   time.Time
+		bytes     atomic.Int64 // Bytes successfully written from this source
+		inflight  atomic.Int64 // Bytes of the current block received so far
+		retired   atomic.Bool
+		slow      atomic.Bool
+		blocks    int
+		err       error
+		firstByte time.Duration
+		limiters  rateLimiters
+	}
+
+	// The shared work queue for a striped download.  Blocks are pulled by
+	// each source as it becomes idle, so faster sources naturally service
+	// more of the object.
+	stripeQueue struct {
+		lock      sync.Mutex
+		pending   []stripeBlock
+		remaining int // Number of blocks not yet successfully written
+		done      chan struct{}
+	}
+
+	// Summary of a single source's contribution to a striped download
+	stripeSourceResult struct {
+		Endpoint        string
+		Bytes           int64
+		Blocks          int
+		TimeToFirstByte time.Duration
+		Error           error
+	}
+)
+
+const (
+	// A source is considered slow (and its work reassigned) if its throughput
+	// drops below this fraction of the fastest source's throughput.
+	stripeSlowSourceFraction = 0.25
+
+	// Do not judge a source as slow until it has been active for this long
+	stripeRampupTime = 2 * time.Second
+)
+
+func newStripeQueue(totalSize int64, stripeSize int64) *stripeQueue {
+	sq := &stripeQueue{done: make(chan struct{})}
+	for offset := int64(0); offset < totalSize; offset += stripeSize {
+		length := stripeSize
+		if offset+length > totalSize {
+			length = totalSize - offset
+		}
+		sq.pending = append(sq.pending, stripeBlock{offset: offset, length: length})
+	}
+	sq.remaining = len(sq.pending)
+	if sq.remaining == 0 {
+		close(sq.done)
+	}
+	return sq
+}
+
+// Pop the next block to transfer; ok is false if there's no pending work
+func (sq *stripeQueue) next() (block stripeBlock, ok bool) {
+	sq.lock.Lock()
+	defer sq.lock.Unlock()
+	if len(sq.pending) == 0 {
+		return
+	}
+	block = sq.pending[0]
+	sq.pending = sq.pending[1:]
+	ok = true
+	return
+}
+
+// Return a block to the front of the queue so another source can pick it up
+func (sq *stripeQueue) requeue(block stripeBlock) {
+	sq.lock.Lock()
+	defer sq.lock.Unlock()
+	sq.pending = append([]stripeBlock{block}, sq.pending...)
+}
+
+// Mark a block as successfully written
+func (sq *stripeQueue) complete() {
+	sq.lock.Lock()
+	defer sq.lock.Unlock()
+	sq.remaining--
+	if sq.remaining == 0 {
+		close(sq.done)
+	}
+}
+
+func (sq *stripeQueue) isDone() bool {
+	select {
+	case <-sq.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the throughput of the source in bytes per second
+func (ss *stripeSource) rate() float64 {
+	elapsed := time.Since(ss.started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(ss.bytes.Load()+ss.inflight.Load()) / elapsed
+}
+
+// Determine whether a striped, multi-source download should be attempted
+// for an object of the given size
+func useStripedDownload(attempts []transferAttemptDetails, totalSize int64, packOption string) bool {
+	if packOption != "" || totalSize <= 0 {
+		return false
+	}
+	if param.Client_MaxDownloadSources.GetInt() < 2 {
+		return false
+	}
+	stripeSize := int64(param.Client_StripeSize.GetInt())
+	if stripeSize <= 0 || totalSize < 2*stripeSize {
+		return false
+	}
+	return len(stripeCandidates(attempts)) >= 2
+}
+
+// Filter the list of attempts down to the ones eligible for striping.
+//
+// Local caches (accessed via unix sockets) are always the fastest option and
+// are not worth striping against; duplicate hosts (e.g., the proxy and non-proxy
+// variants of the same cache) are only used once.
+func stripeCandidates(attempts []transferAttemptDetails) (candidates []transferAttemptDetails) {
+	maxSources := param.Client_MaxDownloadSources.GetInt()
+	seen := make(map[string]bool)
+	for _, attempt := range attempts {
+		if len(candidates) >= maxSources {
+			break
+		}
+		if attempt.Url == nil || attempt.Url.Scheme == "unix" || seen[attempt.Url.Host] {
+			continue
+		}
+		seen[attempt.Url.Host] = true
+		candidates = append(candidates, attempt)
+	}
+	return
+}
+
+// Download an object by striping disjoint byte ranges across several sources.
+//
+// The object is divided into blocks of Client.StripeSize bytes; each source
+// repeatedly takes the next pending block, fetches it with a Range request, and
+// writes it at the corresponding offset in the destination file.  If a source
+// fails or falls far behind the fastest source, its in-progress block is returned
+// to the queue for the remaining sources to pick up.
+//
+// The download fails only if all sources have been retired with work remaining.
+func downloadStriped(ctx context.Context, te *TransferEngine, callback TransferCallbackFunc, attempts []transferAttemptDetails, remotePath string, dest string, totalSize int64, token string, project string) (downloaded int64, sourceResults []stripeSourceResult, err error) {
+	fields, ok := ctx.Value(logFields("fields")).(log.Fields)
+	if !ok {
+		fields = log.Fields{}
+	}
+	candidates := stripeCandidates(attempts)
+	stripeSize := int64(param.Client_StripeSize.GetInt())
+
+	fp, err := os.OpenFile(dest, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		err = errors.Wrap(err, "failed to open destination for striped download")
+		return
+	}
+	defer fp.Close()
+	if err = fp.Truncate(totalSize); err != nil {
+		err = errors.Wrap(err, "failed to allocate destination for striped download")
+		return
+	}
+
+	log.WithFields(fields).Debugf("Starting striped download of %s across %d sources using %s blocks", remotePath, len(candidates), ByteCountSI(stripeSize))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	queue := newStripeQueue(totalSize, stripeSize)
+	sources := make([]*stripeSource, len(candidates))
+	var wg sync.WaitGroup
+	for idx, attempt := range candidates {
+		source := &stripeSource{attempt: attempt, started: time.Now(), limiters: te.rateLimiters()}
+		source.ctx, source.cancel = context.WithCancel(ctx)
+		sources[idx] = source
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runStripeSource(source, queue, fp, remotePath, token, project)
+		}()
+	}
+	allRetired := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(allRetired)
+	}()
+
+	if callback != nil {
+		callback(dest, 0, totalSize, false)
+	}
+	progressTicker := time.NewTicker(100 * time.Millisecond)
+	defer progressTicker.Stop()
+	balanceTicker := time.NewTicker(500 * time.Millisecond)
+	defer balanceTicker.Stop()
+	lastUpdate := time.Now()
+
+	sumBytes := func() (total int64) {
+		for _, source := range sources {
+			total += source.bytes.Load()
+		}
+		return
+	}
+
+Loop:
+	for {
+		select {
+		case <-queue.done:
+			break Loop
+		case <-allRetired:
+			break Loop
+		case <-progressTicker.C:
+			if te != nil {
+				currentTime := time.Now()
+				te.ewmaCtr.Add(int64(currentTime.Sub(lastUpdate)))
+				lastUpdate = currentTime
+			}
+			if callback != nil {
+				callback(dest, sumBytes(), totalSize, false)
+			}
+		case <-balanceTicker.C:
+			rebalanceStripeSources(sources, fields)
+		}
+	}
+	cancel()
+	<-allRetired
+
+	downloaded = sumBytes()
+	for _, source := range sources {
+		sourceResults = append(sourceResults, stripeSourceResult{
+			Endpoint:        source.attempt.Url.Host,
+			Bytes:           source.bytes.Load(),
+			Blocks:          source.blocks,
+			TimeToFirstByte: source.firstByte,
+			Error:           source.err,
+		})
+	}
+	if callback != nil {
+		callback(dest, downloaded, totalSize, true)
+	}
+
+	if !queue.isDone() {
+		errs := NewTransferErrors()
+		for _, source := range sources {
+			if source.err != nil {
+				errs.AddError(newTransferAttemptError(source.attempt.Url.Host, "", false, false, source.err))
+			}
+		}
+		if ctxErr := ctx.Err(); len(errs.Unwrap()) == 0 && ctxErr != nil {
+			err = ctxErr
+		} else {
+			err = errs
+		}
+		return
+	}
+	log.WithFields(fields).Debugf("Striped download of %s finished; %s transferred", remotePath, ByteCountSI(downloaded))
+	return
+}
+
+// Retire any source whose throughput has fallen far behind the fastest source.
+//
+// The last active source is never retired; slow progress is preferable to none.
+func rebalanceStripeSources(sources []*stripeSource, fields log.Fields) {
+	var best float64
+	active := 0
+	for _, source := range sources {
+		if source.retired.Load() {
+			continue
+		}
+		active++
+		if rate := source.rate(); rate > best {
+			best = rate
+		}
+	}
+	if active < 2 || best <= 0 {
+		return
+	}
+	for _, source := range sources {
+		if active < 2 {
+			return
+		}
+		if source.retired.Load() || time.Since(source.started) < stripeRampupTime {
+			continue
+		}
+		if rate := source.rate(); rate < best*stripeSlowSourceFraction {
+			log.WithFields(fields).Debugf("Source %s is too slow (%s/s versus %s/s for the fastest source); reassigning its work",
+				source.attempt.Url.Host, ByteCountSI(int64(rate)), ByteCountSI(int64(best)))
+			source.slow.Store(true)
+			source.retired.Store(true)
+			source.cancel()
+			active--
+		}
+	}
+}
+
+// Worker loop for a single source; pulls blocks from the queue until there's
+// no more work or the source is retired.
+func runStripeSource(source *stripeSource, queue *stripeQueue, fp *os.File, remotePath string, token string, project string) {
+	defer source.retired.Store(true)
+	defer source.cancel()
+
+	transport := newTransferTransport()
+	transport.Proxy = transferProxy(source.attempt)
+	client := &http.Client{Transport: transport}
+	transferUrl := *source.attempt.Url
+	transferUrl.Path = remotePath
+
+	for {
+		if source.retired.Load() || source.ctx.Err() != nil {
+			return
+		}
+		block, ok := queue.next()
+		if !ok {
+			// No pending work; however, another source may still fail and requeue
+			// a block.  Wait briefly and check again unless everything is done.
+			select {
+			case <-queue.done:
+				return
+			case <-source.ctx.Done():
+				return
+			case <-time.After(100 * time.Millisecond):
+				continue
+			}
+		}
+		source.inflight.Store(0)
+		if err := fetchStripeBlock(source, client, transferUrl.String(), block, fp, token, project); err != nil {
+			source.inflight.Store(0)
+			queue.requeue(block)
+			if !source.slow.Load() && !errors.Is(err, context.Canceled) {
+				log.Debugf("Striped download from %s failed for bytes %d-%d: %v", source.attempt.Url.Host, block.offset, block.offset+block.length-1, err)
+				source.err = err
+			} else if source.slow.Load() {
+				source.err = errors.New("source was retired for being too slow")
+			}
+			return
+		}
+		source.inflight.Store(0)
+		source.bytes.Add(block.length)
+		source.blocks++
+		queue.complete()
+	}
+}
+
+// Fetch a single block from the source and write it into place
+func fetchStripeBlock(source *stripeSource, client *http.Client, transferUrl string, block stripeBlock, fp *os.File, token string, project string) error {
+	req, err := http.NewRequestWithContext(source.ctx, http.MethodGet, transferUrl, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", block.offset, block.offset+block.length-1))
+	req.Header.Set("User-Agent", getUserAgent(project))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if searchJobAd(jobId) != "" {
+		req.Header.Set("X-Pelican-JobId", searchJobAd(jobId))
+	}
+	requestStart := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		var ope *net.OpError
+		if errors.As(err, &ope) && ope.Op == "proxyconnect" {
+			return err
+		}
+		return &ConnectionSetupError{URL: transferUrl, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		// A 200 means the server ignored the Range header; we cannot use this
+		// source for striping as it would send the entire object for each block.
+		if resp.StatusCode == http.StatusOK {
+			return errors.Errorf("server %s does not support ranged requests", source.attempt.Url.Host)
+		}
+		sce := StatusCodeError(resp.StatusCode)
+		return &sce
+	}
+	if source.firstByte == 0 {
+		source.firstByte = time.Since(requestStart)
+	}
+
+	var body io.Reader = resp.Body
+	if len(source.limiters) > 0 {
+		body = &rateLimitedReader{ReadCloser: resp.Body, ctx: source.ctx, limiters: source.limiters}
+	}
+	writer := io.NewOffsetWriter(fp, block.offset)
+	buf := make([]byte, 128*1024)
+	var written int64
+	for written < block.length {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if int64(n) > block.length-written {
+				n = int(block.length - written)
+			}
+			if _, err := writer.Write(buf[:n]); err != nil {
+				return errors.Wrap(err, "failed to write block to destination")
+			}
+			written += int64(n)
+			source.inflight.Store(written)
+		}
+		if readErr == io.EOF {
+			break
+		} else if readErr != nil {
+			return readErr
+		}
+	}
+	if written != block.length {
+		return errors.Errorf("short read from %s: received %d of %d bytes", source.attempt.Url.Host, written, block.length)
+	}
+	return nil
+}
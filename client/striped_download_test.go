@@ -0,0 +1,179 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/test_utils"
+)
+
+func TestStripedDownload(t *testing.T) {
+	ctx, _, _ := test_utils.TestContext(context.Background(), t)
+	test_utils.InitClient(t, map[string]any{
+		"Client.MaxDownloadSources": 3,
+		"Client.StripeSize":         4096,
+	})
+
+	contents := make([]byte, 4096*10+123)
+	_, err := rand.Read(contents)
+	require.NoError(t, err)
+
+	// Returns a handler serving the object with Range support and counting the requests
+	serveObject := func(counter *atomic.Int64) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			counter.Add(1)
+			http.ServeContent(w, r, "test.txt", time.Now(), bytes.NewReader(contents))
+		}
+	}
+	newAttempt := func(svr *httptest.Server) transferAttemptDetails {
+		svrUrl, err := url.Parse(svr.URL)
+		require.NoError(t, err)
+		return transferAttemptDetails{Url: svrUrl}
+	}
+
+	t.Run("use-candidates", func(t *testing.T) {
+		attempts := []transferAttemptDetails{
+			{Url: &url.URL{Scheme: "unix", Path: "/tmp/sock"}},
+			{Url: &url.URL{Scheme: "https", Host: "cache1:8443"}, Proxy: true},
+			{Url: &url.URL{Scheme: "https", Host: "cache1:8443"}},
+			{Url: &url.URL{Scheme: "https", Host: "cache2:8443"}},
+		}
+		candidates := stripeCandidates(attempts)
+		require.Len(t, candidates, 2)
+		assert.Equal(t, "cache1:8443", candidates[0].Url.Host)
+		assert.Equal(t, "cache2:8443", candidates[1].Url.Host)
+
+		assert.True(t, useStripedDownload(attempts, 4096*2, ""))
+		assert.False(t, useStripedDownload(attempts, 4096*2-1, ""))
+		assert.False(t, useStripedDownload(attempts, 4096*2, "auto"))
+		assert.False(t, useStripedDownload(attempts[:3], 4096*2, ""))
+	})
+
+	t.Run("all-sources-healthy", func(t *testing.T) {
+		var count1, count2 atomic.Int64
+		svr1 := httptest.NewServer(serveObject(&count1))
+		defer svr1.Close()
+		svr2 := httptest.NewServer(serveObject(&count2))
+		defer svr2.Close()
+
+		dest := filepath.Join(t.TempDir(), "test.txt")
+		downloaded, results, err := downloadStriped(ctx, nil, nil, []transferAttemptDetails{newAttempt(svr1), newAttempt(svr2)}, "/test.txt", dest, int64(len(contents)), "", "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(contents)), downloaded)
+		require.Len(t, results, 2)
+		assert.Equal(t, int64(11), count1.Load()+count2.Load())
+
+		written, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		assert.Equal(t, contents, written)
+	})
+
+	t.Run("failed-source-is-rebalanced", func(t *testing.T) {
+		var count atomic.Int64
+		svr1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer svr1.Close()
+		svr2 := httptest.NewServer(serveObject(&count))
+		defer svr2.Close()
+
+		dest := filepath.Join(t.TempDir(), "test.txt")
+		downloaded, results, err := downloadStriped(ctx, nil, nil, []transferAttemptDetails{newAttempt(svr1), newAttempt(svr2)}, "/test.txt", dest, int64(len(contents)), "", "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(contents)), downloaded)
+		require.Len(t, results, 2)
+		assert.Error(t, results[0].Error)
+		assert.Equal(t, int64(0), results[0].Bytes)
+		assert.Equal(t, int64(len(contents)), results[1].Bytes)
+
+		written, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		assert.Equal(t, contents, written)
+	})
+
+	t.Run("fallback-counts-only-its-bytes", func(t *testing.T) {
+		// Every source fails one of the stripes, so the striped download fails part way
+		// through and the object is downloaded again from a single source
+		failingStripe := func(w http.ResponseWriter, r *http.Request) {
+			switch r.Header.Get("Range") {
+			case "bytes=20480-24575":
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			case "0-0":
+				// The size probe of sortAttempts reads the object size from Content-Length
+				w.Header().Set("Content-Length", strconv.Itoa(len(contents)))
+				_, _ = w.Write(contents)
+				return
+			}
+			http.ServeContent(w, r, "test.txt", time.Now(), bytes.NewReader(contents))
+		}
+		svr1 := httptest.NewServer(http.HandlerFunc(failingStripe))
+		defer svr1.Close()
+		svr2 := httptest.NewServer(http.HandlerFunc(failingStripe))
+		defer svr2.Close()
+
+		transfer := &transferFile{
+			ctx:       ctx,
+			job:       &TransferJob{ctx: ctx},
+			localPath: filepath.Join(t.TempDir(), "test.txt"),
+			remoteURL: &url.URL{Path: "/test.txt"},
+			attempts:  []transferAttemptDetails{newAttempt(svr1), newAttempt(svr2)},
+		}
+		transferResult, err := downloadObject(transfer)
+		require.NoError(t, err)
+		require.NoError(t, transferResult.Error)
+		assert.Equal(t, int64(len(contents)), transferResult.TransferredBytes)
+		stripeBytes := int64(0)
+		for _, attempt := range transferResult.Attempts[:2] {
+			stripeBytes += attempt.TransferFileBytes
+		}
+		assert.Greater(t, stripeBytes, int64(0))
+
+		written, err := os.ReadFile(transfer.localPath)
+		require.NoError(t, err)
+		assert.Equal(t, contents, written)
+	})
+
+	t.Run("no-range-support", func(t *testing.T) {
+		noRange := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(contents)
+		}))
+		defer noRange.Close()
+
+		dest := filepath.Join(t.TempDir(), "test.txt")
+		_, _, err := downloadStriped(ctx, nil, nil, []transferAttemptDetails{newAttempt(noRange), newAttempt(noRange)}, "/test.txt", dest, int64(len(contents)), "", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not support ranged requests")
+	})
+}
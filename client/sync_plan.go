@@ -0,0 +1,469 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/studio-b12/gowebdav"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/pelican_url"
+)
+
+type (
+	// The action taken for a single object when synchronizing
+	SyncAction string
+
+	// A single step of a synchronization plan
+	SyncPlanEntry struct {
+		Action      SyncAction
+		Source      string // Empty for deletions
+		Destination string
+		Size        int64
+		Reason      string
+	}
+
+	// The steps needed to make a destination match a source, as computed by PlanSync
+	SyncPlan struct {
+		Upload  bool
+		Entries []SyncPlanEntry
+		// The number of objects already up-to-date at the destination
+		Unchanged int
+	}
+
+	// The size and modification time of an object on one side of a sync
+	syncObject struct {
+		size    int64
+		modTime time.Time
+	}
+)
+
+const (
+	SyncActionTransfer SyncAction = "transfer"
+	SyncActionDelete   SyncAction = "delete"
+)
+
+// Parse a synchronization level as given on the command line
+func ParseSyncLevel(name string) (SyncLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "none":
+		return SyncNone, nil
+	case "exist", "exists":
+		return SyncExist, nil
+	case "", "size":
+		return SyncSize, nil
+	case "mtime":
+		return SyncMtime, nil
+	case "checksum":
+		return SyncChecksum, nil
+	}
+	return SyncNone, errors.Errorf("unknown comparison %q; supported comparisons are none, exist, size, mtime, and checksum", name)
+}
+
+// Returns true if the location is a local path rather than a remote URL
+func isLocalLocation(location string) bool {
+	parsed, err := url.Parse(location)
+	return err != nil || parsed.Scheme == "" || parsed.Scheme == "file"
+}
+
+// Compute the transfers (and, with WithSyncDelete, deletions) needed to make the
+// destination match the source.  Exactly one of the source and destination must be
+// a remote URL; the other is a local file or directory.
+//
+// Objects are compared according to the level set by WithSynchronize (SyncSize if
+// not given).  With SyncChecksum, objects whose sizes match are compared using a
+// checksum reported by the origin.  The plan can be inspected (e.g., for a dry run)
+// before being passed to ExecuteSyncPlan.
+func PlanSync(ctx context.Context, source string, destination string, options ...TransferOption) (plan *SyncPlan, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Debugln("Panic captured while attempting to plan a sync:", r)
+			log.Debugln("Panic caused by the following", string(debug.Stack()))
+			ret := fmt.Sprintf("Unrecoverable error (panic) captured in PlanSync: %v", r)
+			err = errors.New(ret)
+		}
+	}()
+
+	level := SyncLevel(SyncSize)
+	deleteExtra := false
+	for _, option := range options {
+		switch option.Ident() {
+		case identTransferOptionSynchronize{}:
+			level = option.Value().(SyncLevel)
+		case identTransferOptionSyncDelete{}:
+			deleteExtra = option.Value().(bool)
+		}
+	}
+
+	plan = &SyncPlan{}
+	var remote, local string
+	if isLocalLocation(source) && !isLocalLocation(destination) {
+		plan.Upload = true
+		remote, local = destination, source
+	} else if !isLocalLocation(source) && isLocalLocation(destination) {
+		remote, local = source, destination
+	} else {
+		return nil, errors.New("exactly one of the source and destination of a sync must be a remote URL")
+	}
+
+	pUrl, err := ParseRemoteAsPUrl(ctx, remote)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse remote path: %s", remote)
+	}
+	dirResp, err := GetDirectorInfoForPath(ctx, pUrl, http.MethodGet, "")
+	if err != nil {
+		return nil, err
+	}
+	if dirResp.XPelNsHdr.CollectionsUrl == nil {
+		return nil, errors.Errorf("Collections URL not found in director response. Are you sure there's an origin for prefix %s that supports listings?", dirResp.XPelNsHdr.Namespace)
+	}
+	token := newTokenGenerator(pUrl, &dirResp, plan.Upload, true)
+	applyTokenOptions(token, options...)
+	tokenContents := ""
+	if plan.Upload || dirResp.XPelNsHdr.RequireToken {
+		if tokenContents, err = token.get(); err != nil || tokenContents == "" {
+			return nil, errors.Wrap(err, "failed to get token for transfer")
+		}
+	} else {
+		token = nil
+	}
+
+	davClient := createWebDavClient(dirResp.XPelNsHdr.CollectionsUrl, token, searchJobAd(projectName))
+	remoteObjects, remoteIsCollection, err := listRemoteObjects(davClient, pUrl.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !plan.Upload {
+		local = resolveLocalDestination(pUrl, local, remoteIsCollection || remoteObjects == nil)
+	}
+	localObjects, localIsCollection, err := listLocalObjects(local)
+	if err != nil {
+		return nil, err
+	}
+	if remoteObjects != nil && localObjects != nil && remoteIsCollection != localIsCollection {
+		return nil, errors.Errorf("unable to sync %s with %s: one is an object and the other a collection", source, destination)
+	}
+
+	srcObjects, dstObjects := localObjects, remoteObjects
+	if !plan.Upload {
+		srcObjects, dstObjects = remoteObjects, localObjects
+	}
+	if srcObjects == nil {
+		// Guards against deleting the entire destination due to a mistyped source
+		return nil, errors.Errorf("source %s does not exist", source)
+	}
+	remoteLocation := func(rel string) string {
+		objectUrl := *pUrl.GetRawUrl()
+		objectUrl.Path = path.Join(objectUrl.Path, rel)
+		return objectUrl.String()
+	}
+	localLocation := func(rel string) string {
+		return filepath.Join(local, filepath.FromSlash(rel))
+	}
+	srcLocation, dstLocation := localLocation, remoteLocation
+	if !plan.Upload {
+		srcLocation, dstLocation = remoteLocation, localLocation
+	}
+
+	checksumClient := &http.Client{Transport: config.GetTransport()}
+	for _, rel := range sortedKeys(srcObjects) {
+		src := srcObjects[rel]
+		reason := ""
+		if dst, ok := dstObjects[rel]; !ok {
+			reason = "missing at destination"
+		} else {
+			reason = syncReason(level, src, dst, func() (bool, error) {
+				objectUrl := *dirResp.XPelNsHdr.CollectionsUrl
+				objectUrl.Path = path.Join(pUrl.Path, rel)
+				return checksumsMatch(ctx, checksumClient, objectUrl.String(), localLocation(rel), tokenContents)
+			})
+		}
+		if reason == "" {
+			plan.Unchanged++
+			continue
+		}
+		plan.Entries = append(plan.Entries, SyncPlanEntry{
+			Action:      SyncActionTransfer,
+			Source:      srcLocation(rel),
+			Destination: dstLocation(rel),
+			Size:        src.size,
+			Reason:      reason,
+		})
+	}
+	if deleteExtra {
+		for _, rel := range sortedKeys(dstObjects) {
+			if _, ok := srcObjects[rel]; ok {
+				continue
+			}
+			plan.Entries = append(plan.Entries, SyncPlanEntry{
+				Action:      SyncActionDelete,
+				Destination: dstLocation(rel),
+				Size:        dstObjects[rel].size,
+				Reason:      "not present at source",
+			})
+		}
+	}
+	return plan, nil
+}
+
+// Determine why an object present on both sides needs to be transferred; returns
+// the empty string if the destination is up-to-date
+func syncReason(level SyncLevel, src syncObject, dst syncObject, checksumsMatch func() (bool, error)) string {
+	switch level {
+	case SyncNone:
+		return "synchronization disabled"
+	case SyncExist:
+		return ""
+	}
+	if src.size != dst.size {
+		return fmt.Sprintf("size differs (%d bytes at source, %d at destination)", src.size, dst.size)
+	}
+	switch level {
+	case SyncMtime:
+		if isOlder(dst.modTime, src.modTime) {
+			return "source is newer"
+		}
+	case SyncChecksum:
+		if match, err := checksumsMatch(); err != nil {
+			return fmt.Sprintf("unable to compare checksums: %v", err)
+		} else if !match {
+			return "checksum differs"
+		}
+	}
+	return ""
+}
+
+// Compare a local file against the first of the default checksum types the server reports for the object
+func checksumsMatch(ctx context.Context, client *http.Client, objectUrl string, localPath string, token string) (bool, error) {
+	_, err := verifyChecksum(ctx, client, objectUrl, localPath, ChecksumNone, token, "")
+	if err == nil {
+		return true, nil
+	} else if errors.Is(err, &ChecksumMismatchError{}) {
+		return false, nil
+	}
+	return false, err
+}
+
+func sortedKeys(objects map[string]syncObject) []string {
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// List the objects under a remote collection, keyed by their path relative to it.
+// If the remote path is an object, it is returned under the empty path; if it
+// doesn't exist, the returned map is nil.
+func listRemoteObjects(client *gowebdav.Client, remotePath string) (objects map[string]syncObject, isCollection bool, err error) {
+	objects = make(map[string]syncObject)
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		infos, err := client.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			objectPath := path.Join(dir, info.Name())
+			if info.IsDir() {
+				if err := walk(objectPath); err != nil {
+					return err
+				}
+				continue
+			}
+			rel := strings.TrimPrefix(strings.TrimPrefix(objectPath, remotePath), "/")
+			objects[rel] = syncObject{size: info.Size(), modTime: info.ModTime()}
+		}
+		return nil
+	}
+
+	err = walk(remotePath)
+	if err == nil {
+		return objects, true, nil
+	} else if gowebdav.IsErrNotFound(err) {
+		return nil, false, nil
+	} else if gowebdav.IsErrCode(err, http.StatusInternalServerError) {
+		// Listing an object (rather than a collection) results in a 500
+		info, statErr := client.Stat(remotePath)
+		if statErr == nil && !info.IsDir() {
+			objects[""] = syncObject{size: info.Size(), modTime: info.ModTime()}
+			return objects, false, nil
+		}
+	} else if gowebdav.IsErrCode(err, http.StatusMethodNotAllowed) {
+		return nil, false, errors.New("405: object listings are not supported by the discovered origin")
+	}
+	return nil, false, errors.Wrap(err, "failed to read remote collection")
+}
+
+// List the files under a local directory, keyed by their slash-separated path
+// relative to it.  If the local path is a file, it is returned under the empty
+// path; if it doesn't exist, the returned map is nil.
+func listLocalObjects(localPath string) (objects map[string]syncObject, isCollection bool, err error) {
+	objects = make(map[string]syncObject)
+	info, err := os.Stat(localPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, errors.Wrap(err, "failed to stat local path")
+	} else if !info.IsDir() {
+		objects[""] = syncObject{size: info.Size(), modTime: info.ModTime()}
+		return objects, false, nil
+	}
+
+	err = filepath.WalkDir(localPath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, filePath)
+		if err != nil {
+			return err
+		}
+		objects[filepath.ToSlash(rel)] = syncObject{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to walk local directory")
+	}
+	return objects, true, nil
+}
+
+// Carry out a plan computed by PlanSync.  All transfers are performed first, then
+// any deletions; deletions are skipped if a transfer failed.
+func ExecuteSyncPlan(ctx context.Context, plan *SyncPlan, options ...TransferOption) (transferResults []TransferResults, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Debugln("Panic captured while attempting to execute a sync plan:", r)
+			log.Debugln("Panic caused by the following", string(debug.Stack()))
+			ret := fmt.Sprintf("Unrecoverable error (panic) captured in ExecuteSyncPlan: %v", r)
+			err = errors.New(ret)
+		}
+	}()
+
+	hasTransfers := false
+	for _, entry := range plan.Entries {
+		if entry.Action == SyncActionTransfer {
+			hasTransfers = true
+			break
+		}
+	}
+	if hasTransfers {
+		if transferResults, err = executeSyncTransfers(ctx, plan, options...); err != nil {
+			return
+		}
+	}
+
+	for _, entry := range plan.Entries {
+		if entry.Action != SyncActionDelete {
+			continue
+		}
+		log.Infoln("Deleting", entry.Destination, "as it is not present at the source")
+		if plan.Upload {
+			err = DoDelete(ctx, entry.Destination, false, options...)
+		} else {
+			err = os.Remove(entry.Destination)
+		}
+		if err != nil {
+			return transferResults, errors.Wrapf(err, "failed to delete %s", entry.Destination)
+		}
+	}
+	return
+}
+
+// Submit one transfer job per object in the plan to a single transfer client
+func executeSyncTransfers(ctx context.Context, plan *SyncPlan, options ...TransferOption) (transferResults []TransferResults, err error) {
+	te, err := NewTransferEngine(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := te.Shutdown(); err != nil {
+			log.Errorln("Failure when shutting down transfer engine:", err)
+		}
+	}()
+
+	// The plan already determined which objects need transferring
+	options = append(options, WithSynchronize(SyncNone))
+	tc, err := te.NewClient(options...)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*TransferJob, 0, len(plan.Entries))
+	for _, entry := range plan.Entries {
+		if entry.Action != SyncActionTransfer {
+			continue
+		}
+		remote, local := entry.Source, entry.Destination
+		if plan.Upload {
+			remote, local = entry.Destination, entry.Source
+		} else if err = os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+			tc.Close()
+			return nil, errors.Wrapf(err, "failed to create the directory for %s", local)
+		}
+		pUrl, parseErr := pelican_url.Parse(remote, []pelican_url.ParseOption{pelican_url.ValidateQueryParams(true), pelican_url.AllowUnknownQueryParams(true)}, nil)
+		if parseErr != nil {
+			tc.Close()
+			return nil, errors.Wrapf(parseErr, "failed to parse remote object: %s", remote)
+		}
+		tj, jobErr := tc.NewTransferJob(ctx, pUrl.GetRawUrl(), local, plan.Upload, false, options...)
+		if jobErr != nil {
+			tc.Close()
+			return nil, jobErr
+		}
+		if err = tc.Submit(tj); err != nil {
+			tc.Close()
+			return nil, err
+		}
+		jobs = append(jobs, tj)
+	}
+
+	transferResults, err = tc.Shutdown()
+	for _, tj := range jobs {
+		if err == nil && tj.lookupErr != nil {
+			err = tj.lookupErr
+		}
+	}
+	for _, result := range transferResults {
+		if err == nil && result.Error != nil {
+			err = result.Error
+		}
+	}
+	return
+}
@@ -0,0 +1,126 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/studio-b12/gowebdav"
+	"golang.org/x/net/webdav"
+)
+
+func TestParseSyncLevel(t *testing.T) {
+	for name, expected := range map[string]SyncLevel{"": SyncSize, "size": SyncSize, "MTIME": SyncMtime, "checksum": SyncChecksum, "exist": SyncExist, "none": SyncNone} {
+		level, err := ParseSyncLevel(name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, level, "unexpected level for %q", name)
+	}
+	_, err := ParseSyncLevel("bogus")
+	assert.Error(t, err)
+}
+
+func TestSyncReason(t *testing.T) {
+	now := time.Now()
+	src := syncObject{size: 10, modTime: now}
+	noChecksum := func() (bool, error) {
+		t.Fatal("checksums should not be compared")
+		return false, nil
+	}
+
+	assert.Equal(t, "", syncReason(SyncExist, src, syncObject{size: 5}, noChecksum))
+	assert.NotEqual(t, "", syncReason(SyncNone, src, src, noChecksum))
+	assert.Contains(t, syncReason(SyncSize, src, syncObject{size: 5, modTime: now}, noChecksum), "size differs")
+	assert.Equal(t, "", syncReason(SyncSize, src, syncObject{size: 10, modTime: now.Add(-time.Hour)}, noChecksum))
+
+	// Modification times are compared at one-second granularity
+	assert.Equal(t, "source is newer", syncReason(SyncMtime, src, syncObject{size: 10, modTime: now.Add(-time.Hour)}, noChecksum))
+	assert.Equal(t, "", syncReason(SyncMtime, src, syncObject{size: 10, modTime: now.Truncate(time.Second)}, noChecksum))
+
+	assert.Equal(t, "", syncReason(SyncChecksum, src, src, func() (bool, error) { return true, nil }))
+	assert.Equal(t, "checksum differs", syncReason(SyncChecksum, src, src, func() (bool, error) { return false, nil }))
+	assert.Contains(t, syncReason(SyncChecksum, src, src, func() (bool, error) { return false, os.ErrNotExist }), "unable to compare checksums")
+}
+
+func TestListSyncObjects(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("local", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("bb"), 0644))
+
+		objects, isCollection, err := listLocalObjects(dir)
+		require.NoError(t, err)
+		assert.True(t, isCollection)
+		require.Len(t, objects, 2)
+		assert.Equal(t, int64(1), objects["a.txt"].size)
+		assert.Equal(t, int64(2), objects["sub/b.txt"].size)
+
+		objects, isCollection, err = listLocalObjects(filepath.Join(dir, "a.txt"))
+		require.NoError(t, err)
+		assert.False(t, isCollection)
+		assert.Contains(t, objects, "")
+
+		objects, _, err = listLocalObjects(filepath.Join(dir, "missing"))
+		require.NoError(t, err)
+		assert.Nil(t, objects)
+	})
+
+	t.Run("remote", func(t *testing.T) {
+		fs := webdav.NewMemFS()
+		for _, dir := range []string{"/data", "/data/sub", "/data/empty"} {
+			require.NoError(t, fs.Mkdir(ctx, dir, 0755))
+		}
+		for _, name := range []string{"/data/a.txt", "/data/sub/b.txt"} {
+			f, err := fs.OpenFile(ctx, name, os.O_RDWR|os.O_CREATE, 0644)
+			require.NoError(t, err)
+			_, err = f.Write([]byte("contents of " + name))
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+		}
+		server := httptest.NewServer(&webdav.Handler{FileSystem: fs, LockSystem: webdav.NewMemLS()})
+		defer server.Close()
+		davClient := gowebdav.NewClient(server.URL, "", "")
+
+		objects, isCollection, err := listRemoteObjects(davClient, "/data")
+		require.NoError(t, err)
+		assert.True(t, isCollection)
+		require.Len(t, objects, 2)
+		assert.Equal(t, int64(len("contents of /data/a.txt")), objects["a.txt"].size)
+		assert.Contains(t, objects, "sub/b.txt")
+
+		objects, isCollection, err = listRemoteObjects(davClient, "/data/empty")
+		require.NoError(t, err)
+		assert.True(t, isCollection)
+		assert.NotNil(t, objects)
+		assert.Empty(t, objects)
+
+		objects, _, err = listRemoteObjects(davClient, "/missing")
+		require.NoError(t, err)
+		assert.Nil(t, objects)
+	})
+}
@@ -0,0 +1,154 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// The phase of a transfer in which a timeout was triggered
+	TimeoutPhase string
+
+	// TimeoutError is returned when one of the hard, per-phase timeouts of a
+	// transfer (discovery, director, connect, or first byte) expires
+	TimeoutError struct {
+		Phase   TimeoutPhase
+		Timeout time.Duration
+		Err     error
+	}
+
+	// Implemented by errors resulting from a timeout, reporting which timeout was triggered
+	timeoutPhaseError interface {
+		TimeoutPhase() TimeoutPhase
+	}
+)
+
+const (
+	TimeoutPhaseDiscovery TimeoutPhase = "discovery"
+	TimeoutPhaseDirector  TimeoutPhase = "director"
+	TimeoutPhaseConnect   TimeoutPhase = "connect"
+	TimeoutPhaseFirstByte TimeoutPhase = "first byte"
+	// The transfer stalled: it made no progress or fell below the minimum
+	// transfer rate.  Unlike the other phases, this is a soft timeout that
+	// never limits the total duration of a transfer.
+	TimeoutPhaseStall TimeoutPhase = "stall"
+)
+
+func (e *TimeoutError) Error() string {
+	msg := fmt.Sprintf("%s timeout", e.Phase)
+	if e.Timeout > 0 {
+		msg += " of " + e.Timeout.String()
+	}
+	msg += " exceeded"
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// A TimeoutError target without a phase matches timeouts in any phase
+func (e *TimeoutError) Is(target error) bool {
+	other, ok := target.(*TimeoutError)
+	return ok && (other.Phase == "" || other.Phase == e.Phase)
+}
+
+func (e *TimeoutError) TimeoutPhase() TimeoutPhase {
+	return e.Phase
+}
+
+func (e *HeaderTimeoutError) TimeoutPhase() TimeoutPhase {
+	return TimeoutPhaseFirstByte
+}
+
+func (e *StoppedTransferError) TimeoutPhase() TimeoutPhase {
+	return TimeoutPhaseStall
+}
+
+func (e *SlowTransferError) TimeoutPhase() TimeoutPhase {
+	return TimeoutPhaseStall
+}
+
+// Return the phase of the transfer whose timeout caused the error, if any
+func GetTimeoutPhase(err error) (TimeoutPhase, bool) {
+	var tpe timeoutPhaseError
+	if errors.As(err, &tpe) {
+		return tpe.TimeoutPhase(), true
+	}
+	return "", false
+}
+
+// The timeout for establishing a connection to an origin or cache
+func connectTimeout() time.Duration {
+	if param.Client_ConnectTimeout.IsSet() {
+		return compatToDuration(param.Client_ConnectTimeout.GetDuration(), "Client.ConnectTimeout")
+	}
+	return param.Transport_DialerTimeout.GetDuration()
+}
+
+// The timeout for an origin or cache to start responding to a transfer request
+func firstByteTimeout() time.Duration {
+	if param.Client_FirstByteTimeout.IsSet() {
+		return compatToDuration(param.Client_FirstByteTimeout.GetDuration(), "Client.FirstByteTimeout")
+	}
+	return param.Transport_ResponseHeaderTimeout.GetDuration()
+}
+
+// Create a transport for transfers to origins and caches, applying the
+// connect and first byte timeouts
+func newTransferTransport() *http.Transport {
+	transport := config.GetTransport().Clone()
+	timeout := connectTimeout()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: param.Transport_DialerKeepAlive.GetDuration(),
+	}).DialContext
+	transport.TLSHandshakeTimeout = timeout
+	transport.ResponseHeaderTimeout = firstByteTimeout()
+	return transport
+}
+
+// Convert a timeout encountered while setting up a transfer into a TimeoutError
+// reporting the phase that timed out; returns nil for any other error
+func transferTimeoutError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if strings.Contains(err.Error(), "timeout awaiting response headers") {
+		return &TimeoutError{Phase: TimeoutPhaseFirstByte, Timeout: firstByteTimeout(), Err: &HeaderTimeoutError{}}
+	}
+	var ope *net.OpError
+	if (errors.As(err, &ope) && ope.Op == "dial" && ope.Timeout()) || strings.Contains(err.Error(), "TLS handshake timeout") {
+		return &TimeoutError{Phase: TimeoutPhaseConnect, Timeout: connectTimeout(), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/test_utils"
+)
+
+func TestTimeoutPhases(t *testing.T) {
+	timeoutErr := &TimeoutError{Phase: TimeoutPhaseDirector, Timeout: 20 * time.Second, Err: errors.New("context deadline exceeded")}
+	assert.Equal(t, "director timeout of 20s exceeded: context deadline exceeded", timeoutErr.Error())
+	assert.ErrorIs(t, timeoutErr, &TimeoutError{})
+	assert.ErrorIs(t, timeoutErr, &TimeoutError{Phase: TimeoutPhaseDirector})
+	assert.NotErrorIs(t, timeoutErr, &TimeoutError{Phase: TimeoutPhaseDiscovery})
+	assert.True(t, IsRetryable(timeoutErr))
+
+	for _, tc := range []struct {
+		err   error
+		phase TimeoutPhase
+	}{
+		{newTransferAttemptError("cache", "", false, false, timeoutErr), TimeoutPhaseDirector},
+		{&TimeoutError{Phase: TimeoutPhaseFirstByte, Err: &HeaderTimeoutError{}}, TimeoutPhaseFirstByte},
+		{&HeaderTimeoutError{}, TimeoutPhaseFirstByte},
+		{&StoppedTransferError{}, TimeoutPhaseStall},
+		{&SlowTransferError{}, TimeoutPhaseStall},
+	} {
+		phase, ok := GetTimeoutPhase(tc.err)
+		assert.True(t, ok, tc.err.Error())
+		assert.Equal(t, tc.phase, phase, tc.err.Error())
+	}
+	_, ok := GetTimeoutPhase(&NetworkResetError{})
+	assert.False(t, ok)
+}
+
+func TestFirstByteTimeout(t *testing.T) {
+	test_utils.InitClient(t, map[string]any{
+		"Transport.ResponseHeaderTimeout": "10s",
+		"Client.FirstByteTimeout":         "500ms",
+	})
+	assert.Equal(t, 500*time.Millisecond, firstByteTimeout())
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer svr.CloseClientConnections()
+	defer svr.Close()
+	svrURL, err := url.Parse(svr.URL)
+	require.NoError(t, err)
+
+	transfer := &transferFile{
+		ctx:       context.Background(),
+		job:       &TransferJob{},
+		localPath: "/dev/null",
+		remoteURL: svrURL,
+		attempts:  []transferAttemptDetails{{Url: svrURL}},
+	}
+	transferResult, err := downloadObject(transfer)
+	require.NoError(t, err)
+	require.Error(t, transferResult.Error)
+
+	var timeoutErr *TimeoutError
+	require.ErrorAs(t, transferResult.Error, &timeoutErr)
+	assert.Equal(t, TimeoutPhaseFirstByte, timeoutErr.Phase)
+	assert.Equal(t, 500*time.Millisecond, timeoutErr.Timeout)
+	assert.ErrorIs(t, transferResult.Error, &HeaderTimeoutError{})
+	assert.True(t, IsRetryable(transferResult.Error))
+}
@@ -0,0 +1,130 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/pelican_url"
+)
+
+// Download the object at remoteUrl to dest, blocking until the transfer completes.
+//
+// If dest is an existing directory, the object is placed inside it.  Collections
+// may be downloaded with the WithRecursive option.  Cancelling ctx aborts the
+// transfer and returns the context's error.  The returned error is non-nil if
+// any object failed to transfer; the per-object results are returned regardless.
+func (te *TransferEngine) Get(ctx context.Context, remoteUrl string, dest string, options ...TransferOption) ([]TransferResults, error) {
+	return te.runTransfer(ctx, remoteUrl, dest, false, nil, options...)
+}
+
+// Download the object at remoteUrl into memory and return its contents, without
+// writing any file.  Intended for small objects, such as configuration files or
+// calibration constants, that a program reads at startup.
+//
+// The contents are always verified against a checksum reported by the server: the
+// type requested with WithChecksum, or else the first of the default types the
+// server reports.  A copy that fails verification is discarded and the next
+// endpoint is tried.  Objects larger than maxSize bytes are refused with an
+// ObjectTooLargeError, before any data is transferred if the object's size is known.
+func (te *TransferEngine) GetBytes(ctx context.Context, remoteUrl string, maxSize int64, options ...TransferOption) ([]byte, error) {
+	if maxSize <= 0 {
+		return nil, errors.New("the maximum size of an object downloaded to memory must be positive")
+	}
+	memory := &memoryBuffer{maxSize: maxSize}
+	if _, err := te.runTransfer(ctx, remoteUrl, StreamPath, false, memory, options...); err != nil {
+		return nil, err
+	}
+	return memory.Bytes(), nil
+}
+
+// Upload the local file (or, with the WithRecursive option, directory) at
+// localPath to remoteUrl, blocking until the transfer completes.
+//
+// Cancellation and the returned results behave as in Get.
+func (te *TransferEngine) Put(ctx context.Context, localPath string, remoteUrl string, options ...TransferOption) ([]TransferResults, error) {
+	return te.runTransfer(ctx, remoteUrl, localPath, true, nil, options...)
+}
+
+// Run a single transfer job on a dedicated client of the engine, cancelling
+// the client if ctx is cancelled before the job completes.  If memory is set,
+// the object is downloaded into it instead of to localPath.
+func (te *TransferEngine) runTransfer(ctx context.Context, remoteUrl string, localPath string, upload bool, memory *memoryBuffer, options ...TransferOption) (results []TransferResults, err error) {
+	pUrl, err := pelican_url.Parse(remoteUrl, []pelican_url.ParseOption{pelican_url.ValidateQueryParams(true), pelican_url.AllowUnknownQueryParams(true)}, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse remote object: %s", remoteUrl)
+	}
+	recursive := false
+	for _, option := range options {
+		if option.Ident() == (identTransferOptionRecursive{}) {
+			recursive = option.Value().(bool)
+		}
+	}
+	if !upload {
+		localPath = resolveLocalDestination(pUrl, localPath, recursive)
+	}
+
+	tc, err := te.NewClient(options...)
+	if err != nil {
+		return nil, err
+	}
+	defer tc.Cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			tc.Cancel()
+		case <-done:
+		}
+	}()
+
+	tj, err := tc.NewTransferJob(ctx, pUrl.GetRawUrl(), localPath, upload, recursive, options...)
+	if err != nil {
+		tc.Close()
+		return nil, err
+	}
+	if memory != nil {
+		if tj.recursive {
+			tc.Close()
+			return nil, errors.New("a collection cannot be downloaded to memory")
+		}
+		tj.memory = memory
+	}
+	if err = tc.Submit(tj); err != nil {
+		tc.Close()
+		return nil, err
+	}
+	results, err = tc.Shutdown()
+	if ctx.Err() != nil {
+		return results, ctx.Err()
+	} else if err != nil {
+		return results, err
+	} else if tj.lookupErr != nil {
+		return results, tj.lookupErr
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			return results, result.Error
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,273 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	// A record of a single object that was successfully transferred as part
+	// of a recursive transfer job
+	journalEntry struct {
+		Remote  string    `json:"remote"`
+		Local   string    `json:"local"`
+		Size    int64     `json:"size"`
+		ModTime time.Time `json:"mtime"`
+		// Set for a local directory whose entire contents were uploaded
+		Dir bool `json:"dir,omitempty"`
+	}
+
+	// A local directory being walked by a recursive upload.  Once every object
+	// under it has been uploaded, the directory is recorded in the journal so that
+	// a resumed upload can skip the whole subtree without walking it again.
+	journalDir struct {
+		remote  string
+		local   string
+		modTime time.Time
+		parent  *journalDir
+		// Objects and subdirectories that have not yet completed
+		pending int
+		// Set once every entry of the directory has been enumerated
+		walked bool
+	}
+
+	// An append-only journal of the objects completed by a recursive transfer.
+	//
+	// If a recursive transfer is interrupted, rerunning it with the same journal
+	// will skip any object whose local copy still matches the journal entry.
+	// A nil journal is valid and records nothing.
+	transferJournal struct {
+		path      string
+		mutex     sync.Mutex
+		file      *os.File
+		completed map[string]journalEntry
+	}
+)
+
+func journalKey(remote, local string) string {
+	return remote + "\x00" + local
+}
+
+// Open the journal at the given location, loading any entries left behind by
+// a previous, interrupted transfer.
+func openTransferJournal(location string) (journal *transferJournal, err error) {
+	journal = &transferJournal{
+		path:      location,
+		completed: make(map[string]journalEntry),
+	}
+
+	if existing, err := os.Open(location); err == nil {
+		scanner := bufio.NewScanner(existing)
+		for scanner.Scan() {
+			var entry journalEntry
+			// The last line may be truncated if the prior transfer was killed mid-write;
+			// simply ignore anything we cannot parse.
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				log.Debugln("Ignoring malformed line in transfer journal", location)
+				continue
+			}
+			journal.completed[journalKey(entry.Remote, entry.Local)] = entry
+		}
+		existing.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, errors.Wrapf(err, "failed to read transfer journal %s", location)
+		}
+		log.Infof("Resuming transfer using journal %s with %d completed objects", location, len(journal.completed))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.Wrapf(err, "failed to open transfer journal %s", location)
+	}
+
+	journal.file, err = os.OpenFile(location, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open transfer journal %s for writing", location)
+	}
+	return journal, nil
+}
+
+// Returns true if the journal records the object as transferred and the local
+// copy has not changed since.  If remoteSize is non-negative, it must also match
+// the recorded size.
+func (j *transferJournal) isComplete(remote, local string, remoteSize int64) bool {
+	if j == nil {
+		return false
+	}
+	j.mutex.Lock()
+	entry, ok := j.completed[journalKey(remote, local)]
+	j.mutex.Unlock()
+	if !ok {
+		return false
+	}
+	if remoteSize >= 0 && remoteSize != entry.Size {
+		return false
+	}
+	info, err := os.Stat(local)
+	if err != nil {
+		return false
+	}
+	return !entry.Dir && info.Size() == entry.Size && info.ModTime().Equal(entry.ModTime)
+}
+
+// Returns true if the journal records the entire contents of a local directory as
+// uploaded and the directory's modification time is unchanged since.
+//
+// Adding, removing or renaming an entry updates a directory's modification time, but
+// rewriting an existing file in place does not; such changes are not noticed within
+// a subtree the journal records as complete.
+func (j *transferJournal) isDirComplete(remote, local string) bool {
+	if j == nil {
+		return false
+	}
+	j.mutex.Lock()
+	entry, ok := j.completed[journalKey(remote, local)]
+	j.mutex.Unlock()
+	if !ok || !entry.Dir {
+		return false
+	}
+	info, err := os.Stat(local)
+	if err != nil {
+		return false
+	}
+	return info.IsDir() && info.ModTime().Equal(entry.ModTime)
+}
+
+// Record the successful transfer of an object.  The size and modification time
+// are taken from the local copy.
+func (j *transferJournal) record(remote, local string) error {
+	if j == nil {
+		return nil
+	}
+	info, err := os.Stat(local)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat %s for the transfer journal", local)
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.writeLocked(journalEntry{Remote: remote, Local: local, Size: info.Size(), ModTime: info.ModTime()})
+}
+
+func (j *transferJournal) writeLocked(entry journalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if j.file == nil {
+		return errors.New("transfer journal has been closed")
+	}
+	if _, err = j.file.Write(append(line, '\n')); err != nil {
+		return errors.Wrapf(err, "failed to write to transfer journal %s", j.path)
+	}
+	j.completed[journalKey(entry.Remote, entry.Local)] = entry
+	return nil
+}
+
+// Begin tracking a directory walked by a recursive upload.  The modification time
+// should be taken before the directory is listed, so that entries added during the
+// walk cause the directory to be walked again on resume.  Returns nil if the journal is
+// nil (journaling disabled).
+func (j *transferJournal) startDir(parent *journalDir, remote, local string, modTime time.Time) *journalDir {
+	if j == nil {
+		return nil
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if parent != nil {
+		parent.pending++
+	}
+	return &journalDir{remote: remote, local: local, modTime: modTime, parent: parent}
+}
+
+// Note an object queued for upload from a directory
+func (j *transferJournal) addPending(dir *journalDir) {
+	if j == nil || dir == nil {
+		return
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	dir.pending++
+}
+
+// Note that every entry of a directory has been enumerated
+func (j *transferJournal) finishWalk(dir *journalDir) {
+	if j == nil || dir == nil {
+		return
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	dir.walked = true
+	j.maybeCompleteDirLocked(dir)
+}
+
+// Note that an object queued from a directory was uploaded
+func (j *transferJournal) completeInDir(dir *journalDir) {
+	if j == nil || dir == nil {
+		return
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	dir.pending--
+	j.maybeCompleteDirLocked(dir)
+}
+
+// Record a directory, and any ancestors it completes, once nothing under it is outstanding
+func (j *transferJournal) maybeCompleteDirLocked(dir *journalDir) {
+	for dir != nil && dir.walked && dir.pending == 0 {
+		if err := j.writeLocked(journalEntry{Remote: dir.remote, Local: dir.local, ModTime: dir.modTime, Dir: true}); err != nil {
+			log.Warningln("Failed to record", dir.local, "in the transfer journal:", err)
+			return
+		}
+		log.Debugln("Transfer journal records the upload of", dir.local, "as complete")
+		if dir.parent == nil {
+			return
+		}
+		dir.parent.pending--
+		dir = dir.parent
+	}
+}
+
+// Close the journal.  If the transfer job completed without errors, the journal
+// is no longer needed and is removed.
+func (j *transferJournal) close(success bool) {
+	if j == nil {
+		return
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.file == nil {
+		return
+	}
+	if err := j.file.Close(); err != nil {
+		log.Warningln("Failed to close transfer journal", j.path, ":", err)
+	}
+	j.file = nil
+	if success {
+		if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warningln("Failed to remove completed transfer journal", j.path, ":", err)
+		}
+	} else {
+		log.Infoln("Transfer did not complete successfully; rerun with the journal", j.path, "to resume")
+	}
+}
@@ -0,0 +1,141 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferJournal(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "journal")
+	localA := filepath.Join(dir, "a.txt")
+	localB := filepath.Join(dir, "b.txt")
+	require.NoError(t, os.WriteFile(localA, []byte("hello"), 0644))
+	require.NoError(t, os.WriteFile(localB, []byte("world!"), 0644))
+
+	journal, err := openTransferJournal(journalPath)
+	require.NoError(t, err)
+	assert.False(t, journal.isComplete("/foo/a.txt", localA, 5))
+	require.NoError(t, journal.record("/foo/a.txt", localA))
+	assert.True(t, journal.isComplete("/foo/a.txt", localA, 5))
+	assert.True(t, journal.isComplete("/foo/a.txt", localA, -1))
+	// A remote object that changed size must be transferred again
+	assert.False(t, journal.isComplete("/foo/a.txt", localA, 6))
+	journal.close(false)
+
+	// Simulate a write interrupted partway through a line
+	f, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"remote":"/foo/b.txt","loc`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	journal, err = openTransferJournal(journalPath)
+	require.NoError(t, err)
+	assert.True(t, journal.isComplete("/foo/a.txt", localA, 5))
+	assert.False(t, journal.isComplete("/foo/b.txt", localB, 6))
+
+	// Modifying the local copy invalidates the entry
+	newTime := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(localA, newTime, newTime))
+	assert.False(t, journal.isComplete("/foo/a.txt", localA, 5))
+
+	// A successful transfer removes the journal
+	journal.close(true)
+	_, err = os.Stat(journalPath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// A nil journal never reports completion
+	var nilJournal *transferJournal
+	assert.False(t, nilJournal.isComplete("/foo/a.txt", localA, 5))
+	assert.NoError(t, nilJournal.record("/foo/a.txt", localA))
+	nilJournal.close(true)
+}
+
+func TestTransferJournalDirectories(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(t.TempDir(), "journal")
+	subdir := filepath.Join(dir, "sub")
+	require.NoError(t, os.MkdirAll(subdir, 0755))
+	for _, name := range []string{filepath.Join(dir, "a.txt"), filepath.Join(subdir, "b.txt"), filepath.Join(subdir, "c.txt")} {
+		require.NoError(t, os.WriteFile(name, []byte("hello"), 0644))
+	}
+	statTime := func(path string) time.Time {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		return info.ModTime()
+	}
+
+	journal, err := openTransferJournal(journalPath)
+	require.NoError(t, err)
+	root := journal.startDir(nil, "/foo", dir, statTime(dir))
+	journal.addPending(root)
+	sub := journal.startDir(root, "/foo/sub", subdir, statTime(subdir))
+	journal.addPending(sub)
+	journal.addPending(sub)
+	journal.finishWalk(sub)
+	journal.finishWalk(root)
+
+	// The subdirectory completes once both of its objects are uploaded
+	require.NoError(t, journal.record("/foo/sub/b.txt", filepath.Join(subdir, "b.txt")))
+	journal.completeInDir(sub)
+	assert.False(t, journal.isDirComplete("/foo/sub", subdir))
+	require.NoError(t, journal.record("/foo/sub/c.txt", filepath.Join(subdir, "c.txt")))
+	journal.completeInDir(sub)
+	assert.True(t, journal.isDirComplete("/foo/sub", subdir))
+	assert.False(t, journal.isDirComplete("/foo", dir))
+	// A directory entry is never mistaken for a completed object
+	assert.False(t, journal.isComplete("/foo/sub", subdir, -1))
+	journal.close(false)
+
+	// The completed subtree is remembered when the upload is resumed
+	journal, err = openTransferJournal(journalPath)
+	require.NoError(t, err)
+	assert.True(t, journal.isDirComplete("/foo/sub", subdir))
+	assert.False(t, journal.isDirComplete("/foo", dir))
+
+	// Adding a file changes the directory's modification time, so it is walked again
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.WriteFile(filepath.Join(subdir, "d.txt"), []byte("new"), 0644))
+	require.NoError(t, os.Chtimes(subdir, later, later))
+	assert.False(t, journal.isDirComplete("/foo/sub", subdir))
+
+	// A parent completes once its objects and subdirectories do
+	root = journal.startDir(nil, "/foo", dir, statTime(dir))
+	sub = journal.startDir(root, "/foo/sub", subdir, statTime(subdir))
+	journal.addPending(root)
+	journal.finishWalk(root)
+	journal.completeInDir(root)
+	assert.False(t, journal.isDirComplete("/foo", dir))
+	journal.finishWalk(sub)
+	assert.True(t, journal.isDirComplete("/foo/sub", subdir))
+	assert.True(t, journal.isDirComplete("/foo", dir))
+	journal.close(true)
+
+	var nilJournal *transferJournal
+	assert.Nil(t, nilJournal.startDir(nil, "/foo", dir, time.Now()))
+	assert.False(t, nilJournal.isDirComplete("/foo", dir))
+}
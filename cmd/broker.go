@@ -0,0 +1,53 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	brokerCmd = &cobra.Command{
+		Use:   "broker",
+		Short: "Interact with a Pelican connection broker",
+		Long: `Interact with a Pelican connection broker:
+
+		Origins and caches behind firewalls or NATs can't accept connections
+		from the rest of the federation.  Instead, they poll the connection
+		broker for requests to reach them.  When a cache wants to connect to
+		such a service, it asks the broker, which hands the request to the
+		polling service; the service then calls back to the cache and the
+		two reverse the direction of the resulting TCP connection.  Both
+		sides authenticate to the broker with tokens signed by the keys of
+		their namespaces at the registry.
+		`,
+	}
+
+	brokerServeCmd = &cobra.Command{
+		Use:          "serve",
+		Short:        "serve the connection broker",
+		RunE:         serveBroker,
+		SilenceUsage: true,
+	}
+)
+
+func init() {
+	brokerCmd.AddCommand(brokerServeCmd)
+	brokerServeCmd.Flags().AddFlag(portFlag)
+}
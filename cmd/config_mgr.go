@@ -19,10 +19,17 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 
@@ -36,12 +43,126 @@ var (
 	rootConfigCmd = &cobra.Command{
 		Use:   "credentials",
 		Short: "Interact with the credential configuration file",
+		Long: `Interact with the credential configuration file.
+
+The credential configuration file is an encrypted store of the OAuth2 clients and
+tokens the client has obtained, keyed by federation and namespace prefix.
+
+When a transfer needs a token, the client consults these sources in order, using the
+first token that is acceptable for the transfer:
+
+` + tokenSourceDescription(),
 		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
 			return config.InitClient()
 		},
 	}
+
+	credentialsListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the namespaces with saved credentials",
+		Long: `List the namespaces in each federation with saved credentials, along with the
+number of saved tokens and when the newest expires.  Token contents are not printed.`,
+		Args: cobra.NoArgs,
+		RunE: listCredentialsMain,
+	}
+
+	credentialsClearCmd = &cobra.Command{
+		Use:   "clear [prefix]",
+		Short: "Remove saved credentials",
+		Long: `Remove the saved OAuth2 client registration and tokens for a namespace prefix.
+Use the --federation flag to only remove credentials saved for one federation, or --all
+to remove every saved credential.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: clearCredentialsMain,
+	}
 )
 
+// Describe the token sources the client consults, in order
+func tokenSourceDescription() string {
+	descriptions := map[client.TokenSource]string{
+		client.TokenSourceOption:          "the --token flag",
+		client.TokenSourceBearerToken:     "the BEARER_TOKEN environment variable",
+		client.TokenSourceBearerTokenFile: "the file named by the BEARER_TOKEN_FILE environment variable",
+		client.TokenSourceRuntimeDir:      "$XDG_RUNTIME_DIR/bt_u<uid>",
+		client.TokenSourceTmp:             "/tmp/bt_u<uid>",
+		client.TokenSourceTokenEnv:        "the file named by the TOKEN environment variable",
+		client.TokenSourceCondorCreds:     "HTCondor credentials in $_CONDOR_CREDS",
+		client.TokenSourceCredentialStore: "unexpired tokens in the credential configuration file",
+		client.TokenSourceLocalKey:        "a token signed with a local issuer key",
+		client.TokenSourceOIDC:            "refreshing a saved token, or the OAuth2 device flow",
+	}
+	var sb strings.Builder
+	for idx, source := range client.TokenSourceOrder {
+		fmt.Fprintf(&sb, "  %2d. %s\n", idx+1, descriptions[source])
+	}
+	return sb.String()
+}
+
+func listCredentialsMain(cmd *cobra.Command, args []string) error {
+	creds, err := client.ListStoredCredentials()
+	if err != nil {
+		return err
+	}
+	if outputJSON {
+		jsonData, err := json.Marshal(creds)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the credentials to JSON format")
+		}
+		fmt.Println(string(jsonData))
+		return nil
+	}
+	if len(creds) == 0 {
+		fmt.Println("No saved credentials")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 3, ' ', 0)
+	fmt.Fprintln(w, "FEDERATION\tPREFIX\tTOKENS\tVALID\tREFRESHABLE\tEXPIRES")
+	for _, cred := range creds {
+		federation := cred.Federation
+		if federation == "" {
+			federation = "(any)"
+		}
+		expires := "-"
+		if !cred.LatestExpiry.IsZero() {
+			expires = cred.LatestExpiry.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintln(w, federation+"\t"+cred.Prefix+"\t"+strconv.Itoa(cred.Tokens)+"\t"+strconv.Itoa(cred.ValidTokens)+"\t"+
+			strconv.FormatBool(cred.Refreshable)+"\t"+expires)
+	}
+	return w.Flush()
+}
+
+func clearCredentialsMain(cmd *cobra.Command, args []string) error {
+	all, _ := cmd.Flags().GetBool("all")
+	tokensOnly, _ := cmd.Flags().GetBool("tokens-only")
+	prefix := ""
+	if len(args) > 0 {
+		prefix = args[0]
+	}
+	federation := ""
+	if cmd.Flags().Changed("federation") {
+		fedUrl, _ := cmd.Flags().GetString("federation")
+		parsed, err := url.Parse(fedUrl)
+		if err != nil {
+			return errors.Wrapf(err, "invalid federation %s", fedUrl)
+		}
+		federation = parsed.Host
+		if federation == "" {
+			federation = fedUrl
+		}
+	}
+	if prefix == "" && federation == "" && !all {
+		return errors.New("specify a prefix, a federation or --all")
+	}
+
+	cleared, err := client.ClearStoredCredentials(federation, prefix, tokensOnly)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed saved credentials for %d namespace(s)\n", cleared)
+	return nil
+}
+
 func printConfig() {
 	config, err := config.GetCredentialConfigContents()
 	if err != nil {
@@ -315,7 +436,12 @@ func init() {
 	}
 	addTokenSubcommands(tokenCmd)
 
+	credentialsClearCmd.Flags().Bool("all", false, "Remove the saved credentials of every namespace")
+	credentialsClearCmd.Flags().Bool("tokens-only", false, "Keep the OAuth2 client registrations and only remove the tokens")
+
 	rootConfigCmd.CompletionOptions.DisableDefaultCmd = true
 	rootConfigCmd.AddCommand(prefixCmd)
 	rootConfigCmd.AddCommand(tokenCmd)
+	rootConfigCmd.AddCommand(credentialsListCmd)
+	rootConfigCmd.AddCommand(credentialsClearCmd)
 }
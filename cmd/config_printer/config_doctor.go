@@ -0,0 +1,439 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config_printer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	doctorStatus string
+
+	// A port the server will listen on and the parameter that sets it
+	doctorPort struct {
+		name string
+		port int
+	}
+
+	// The outcome of a single preflight check, with a suggested fix for
+	// anything that isn't a pass
+	doctorResult struct {
+		Check   string
+		Status  doctorStatus
+		Message string
+		Fix     string
+	}
+)
+
+const (
+	doctorPass doctorStatus = "PASS"
+	doctorWarn doctorStatus = "WARN"
+	doctorFail doctorStatus = "FAIL"
+
+	// Warn about certificates that expire within this window
+	certExpiryWarning = 14 * 24 * time.Hour
+
+	// Clock skew beyond these limits breaks token validation
+	clockSkewWarning = 10 * time.Second
+	clockSkewFailure = time.Minute
+)
+
+var (
+	configDoctorCmd = &cobra.Command{
+		Use:   "doctor",
+		Short: "Check a server's configuration and host before starting it",
+		Long: `The 'doctor' command runs preflight checks for the given server modules and prints
+a pass, warning, or failure for each along with a suggested fix.  It checks the
+configuration against the parameter schema, the TLS certificate, the permissions of
+private keys, whether the hostname resolves and the ports are free, federation
+discovery, clock skew against the director, and the installed XRootD version.
+
+Nothing is generated or modified; the command exits with an error if any check fails.`,
+		Example: `# Check the host before starting an origin
+pelican config doctor -m origin`,
+		Args:         cobra.NoArgs,
+		RunE:         configDoctor,
+		SilenceUsage: true,
+	}
+
+	doctorModules []string
+
+	// The oldest XRootD release Pelican supports; kept in sync with the
+	// package dependencies in .goreleaser.yml
+	minimumXrootdVersion = [3]int{5, 7, 0}
+
+	xrootdVersionRegex = regexp.MustCompile(`v?(\d+)\.(\d+)\.(\d+)`)
+)
+
+func pass(check, message string) doctorResult {
+	return doctorResult{Check: check, Status: doctorPass, Message: message}
+}
+
+func warn(check, message, fix string) doctorResult {
+	return doctorResult{Check: check, Status: doctorWarn, Message: message, Fix: fix}
+}
+
+func fail(check, message, fix string) doctorResult {
+	return doctorResult{Check: check, Status: doctorFail, Message: message, Fix: fix}
+}
+
+// Report each problem found by the schema validation of the configuration
+func checkConfiguration(modules server_structs.ServerType) []doctorResult {
+	err := config.ValidateConfig(modules)
+	if err == nil {
+		return []doctorResult{pass("Configuration", "no problems found")}
+	}
+	var validationErr *config.ConfigValidationError
+	if !errors.As(err, &validationErr) {
+		return []doctorResult{fail("Configuration", err.Error(), "Correct the configuration file")}
+	}
+	results := []doctorResult{}
+	for _, problem := range validationErr.Problems {
+		results = append(results, fail("Configuration", problem.Key+" "+problem.Message,
+			fmt.Sprintf("Run `pelican config describe %s` for the expected value", problem.Key)))
+	}
+	return results
+}
+
+// Check the server's TLS certificate chain and key for expiry, a hostname
+// mismatch, and whether clients elsewhere will trust it
+func checkCertificate(certFile, keyFile, hostname string, now time.Time) doctorResult {
+	const check = "TLS certificate"
+	contents, err := os.ReadFile(certFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return warn(check, fmt.Sprintf("%s does not exist; a self-signed certificate will be generated at startup", certFile),
+			"Install a certificate from a CA trusted by your clients and set Server.TLSCertificateChain and Server.TLSKey")
+	} else if err != nil {
+		return fail(check, fmt.Sprintf("failed to read %s: %v", certFile, err), "Make sure the certificate is readable by the user running Pelican")
+	}
+	block, _ := pem.Decode(contents)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return fail(check, fmt.Sprintf("%s does not contain a PEM-encoded certificate", certFile),
+			"Set Server.TLSCertificateChain to a PEM file with the server certificate first, followed by any intermediates")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fail(check, fmt.Sprintf("failed to parse the certificate in %s: %v", certFile, err),
+			"Replace the certificate with a valid PEM-encoded X.509 certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for rest := contents; ; {
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if intermediate, err := x509.ParseCertificate(block.Bytes); err == nil && !intermediate.Equal(cert) {
+			intermediates.AddCert(intermediate)
+		}
+	}
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return fail(check, fmt.Sprintf("the certificate in %s can't be used with the key in %s: %v", certFile, keyFile, err),
+			"Set Server.TLSKey to the private key the certificate was issued for")
+	}
+	if now.Before(cert.NotBefore) {
+		return fail(check, fmt.Sprintf("the certificate is not valid until %s", cert.NotBefore.Format(time.RFC3339)),
+			"Check the system clock, or wait until the certificate becomes valid")
+	}
+	if now.After(cert.NotAfter) {
+		return fail(check, fmt.Sprintf("the certificate expired at %s", cert.NotAfter.Format(time.RFC3339)), "Renew the certificate")
+	}
+	if err := cert.VerifyHostname(hostname); err != nil {
+		return fail(check, fmt.Sprintf("the certificate is not valid for the server's hostname: %v", err),
+			"Set Server.Hostname to a name in the certificate, or reissue the certificate for "+hostname)
+	}
+	if cert.NotAfter.Sub(now) < certExpiryWarning {
+		return warn(check, fmt.Sprintf("the certificate expires soon, at %s", cert.NotAfter.Format(time.RFC3339)),
+			"Renew the certificate before it expires")
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{Intermediates: intermediates, CurrentTime: now}); err != nil {
+		return warn(check, fmt.Sprintf("the certificate is not trusted by the system's CA bundle, so clients on other hosts may reject it: %v", err),
+			"Install a certificate from a CA trusted by your clients, including any intermediates in Server.TLSCertificateChain")
+	}
+	return pass(check, fmt.Sprintf("valid for %s until %s", hostname, cert.NotAfter.Format(time.RFC3339)))
+}
+
+// Check that private keys and secrets are only readable by their owner
+func checkKeyPermissions(files []string) []doctorResult {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	results := []doctorResult{}
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if errors.Is(err, os.ErrNotExist) {
+			// Missing keys are generated with the right permissions at startup
+			continue
+		} else if err != nil {
+			results = append(results, fail("Key permissions", fmt.Sprintf("failed to stat %s: %v", file, err),
+				"Make sure the file is accessible to the user running Pelican"))
+			continue
+		}
+		if mode := info.Mode().Perm(); mode&0077 != 0 {
+			results = append(results, fail("Key permissions", fmt.Sprintf("%s is accessible to other users (mode %#o)", file, mode),
+				"Run `chmod 0600 "+file+"`"))
+		} else {
+			results = append(results, pass("Key permissions", file+" is only accessible to its owner"))
+		}
+	}
+	return results
+}
+
+// Check that the server's hostname resolves and each port it will listen on is free
+func checkPorts(host string, ports []doctorPort) []doctorResult {
+	results := []doctorResult{}
+	hostname := param.Server_Hostname.GetString()
+	if _, err := net.LookupHost(hostname); err != nil {
+		results = append(results, fail("Hostname", fmt.Sprintf("failed to resolve %s: %v", hostname, err),
+			"Set Server.Hostname to a name clients can resolve to this host"))
+	} else {
+		results = append(results, pass("Hostname", hostname+" resolves"))
+	}
+	for _, port := range ports {
+		if port.port == 0 {
+			// A random port is picked at startup
+			continue
+		}
+		addr := net.JoinHostPort(host, strconv.Itoa(port.port))
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			results = append(results, fail("Ports", fmt.Sprintf("%s %d is unavailable: %v", port.name, port.port, err),
+				fmt.Sprintf("Stop the process listening on %s or change %s", addr, port.name)))
+			continue
+		}
+		ln.Close()
+		results = append(results, pass("Ports", fmt.Sprintf("%s %d is available", port.name, port.port)))
+	}
+	return results
+}
+
+// Check that the federation's services can be discovered
+func checkFederation(ctx context.Context) doctorResult {
+	const check = "Federation discovery"
+	fedInfo, err := config.GetFederation(ctx)
+	if err != nil {
+		return fail(check, err.Error(), "Check that Federation.DiscoveryUrl is correct and reachable from this host")
+	}
+	if fedInfo.DirectorEndpoint == "" {
+		return fail(check, "no director was configured or discovered",
+			"Set Federation.DiscoveryUrl to your federation's discovery URL")
+	}
+	if fedInfo.RegistryEndpoint == "" {
+		return fail(check, "no registry was configured or discovered",
+			"Set Federation.DiscoveryUrl to your federation's discovery URL")
+	}
+	return pass(check, fmt.Sprintf("director at %s, registry at %s", fedInfo.DirectorEndpoint, fedInfo.RegistryEndpoint))
+}
+
+// Judge the difference between the local clock and a federation service's
+func evaluateClockSkew(skew time.Duration, server string) doctorResult {
+	const check = "Clock skew"
+	if skew < 0 {
+		skew = -skew
+	}
+	skew = skew.Round(time.Second)
+	fix := "Synchronize the system clock with NTP, e.g. by enabling chronyd"
+	if skew > clockSkewFailure {
+		return fail(check, fmt.Sprintf("the local clock is off from %s by %s; tokens will be rejected", server, skew), fix)
+	} else if skew > clockSkewWarning {
+		return warn(check, fmt.Sprintf("the local clock is off from %s by %s", server, skew), fix)
+	}
+	return pass(check, fmt.Sprintf("within %s of %s", clockSkewWarning, server))
+}
+
+// Compare the local clock against the Date header of a federation service
+func checkClockSkew(ctx context.Context, server string) doctorResult {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, server, nil)
+	if err != nil {
+		return fail("Clock skew", fmt.Sprintf("invalid URL %s: %v", server, err), "Check Federation.DiscoveryUrl")
+	}
+	client := &http.Client{Transport: config.GetTransport(), Timeout: 10 * time.Second}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return warn("Clock skew", fmt.Sprintf("failed to contact %s: %v", server, err),
+			"Check that the federation is reachable from this host")
+	}
+	resp.Body.Close()
+	// Assume the server stamped its response halfway through the round trip
+	local := start.Add(time.Since(start) / 2)
+	remote, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return warn("Clock skew", fmt.Sprintf("%s did not report its time", server), "Verify the system clock is synchronized with NTP")
+	}
+	return evaluateClockSkew(local.Sub(remote), server)
+}
+
+// Parse the version reported by `xrootd -v` or `cmsd -v`
+func parseXrootdVersion(output string) ([3]int, bool) {
+	var version [3]int
+	match := xrootdVersionRegex.FindStringSubmatch(output)
+	if match == nil {
+		return version, false
+	}
+	for idx := range version {
+		version[idx], _ = strconv.Atoi(match[idx+1])
+	}
+	return version, true
+}
+
+func formatXrootdVersion(version [3]int) string {
+	return fmt.Sprintf("%d.%d.%d", version[0], version[1], version[2])
+}
+
+// Check that an XRootD binary is installed and new enough
+func checkXrootdBinary(ctx context.Context, binary string) doctorResult {
+	check := "XRootD " + binary
+	fix := fmt.Sprintf("Install xrootd-server %s or newer", formatXrootdVersion(minimumXrootdVersion))
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return fail(check, binary+" was not found in PATH", fix)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, "-v").CombinedOutput()
+	version, ok := parseXrootdVersion(string(output))
+	if !ok {
+		message := fmt.Sprintf("unable to determine the version of %s", path)
+		if err != nil {
+			message += ": " + err.Error()
+		}
+		return warn(check, message, fix)
+	}
+	for idx := range version {
+		if version[idx] == minimumXrootdVersion[idx] {
+			continue
+		}
+		if version[idx] < minimumXrootdVersion[idx] {
+			return fail(check, fmt.Sprintf("%s is version %s, older than the minimum supported %s", path,
+				formatXrootdVersion(version), formatXrootdVersion(minimumXrootdVersion)), fix)
+		}
+		break
+	}
+	return pass(check, fmt.Sprintf("%s is version %s", path, formatXrootdVersion(version)))
+}
+
+func runDoctorChecks(ctx context.Context, modules server_structs.ServerType) []doctorResult {
+	results := checkConfiguration(modules)
+
+	results = append(results, checkCertificate(param.Server_TLSCertificateChain.GetString(), param.Server_TLSKey.GetString(),
+		param.Server_Hostname.GetString(), time.Now()))
+	results = append(results, checkKeyPermissions([]string{
+		param.Server_TLSKey.GetString(),
+		param.Server_TLSCAKey.GetString(),
+		param.IssuerKey.GetString(),
+		param.Server_SessionSecretFile.GetString(),
+		param.OIDC_ClientSecretFile.GetString(),
+	})...)
+
+	ports := []doctorPort{{param.Server_WebPort.GetName(), param.Server_WebPort.GetInt()}}
+	if modules.IsEnabled(server_structs.OriginType) {
+		ports = append(ports, doctorPort{param.Origin_Port.GetName(), param.Origin_Port.GetInt()})
+	}
+	if modules.IsEnabled(server_structs.CacheType) {
+		ports = append(ports, doctorPort{param.Cache_Port.GetName(), param.Cache_Port.GetInt()})
+		if param.Cache_EnableLocalHttp.GetBool() {
+			ports = append(ports, doctorPort{param.Cache_LocalHttpPort.GetName(), param.Cache_LocalHttpPort.GetInt()})
+		}
+	}
+	results = append(results, checkPorts(param.Server_WebHost.GetString(), ports)...)
+
+	if modules.IsEnabled(server_structs.OriginType) || modules.IsEnabled(server_structs.CacheType) {
+		fedResult := checkFederation(ctx)
+		results = append(results, fedResult)
+		if fedResult.Status == doctorPass {
+			fedInfo, _ := config.GetFederation(ctx)
+			results = append(results, checkClockSkew(ctx, fedInfo.DirectorEndpoint))
+		}
+
+		results = append(results, checkXrootdBinary(ctx, "xrootd"))
+		if modules.IsEnabled(server_structs.OriginType) && param.Origin_EnableCmsd.GetBool() {
+			results = append(results, checkXrootdBinary(ctx, "cmsd"))
+		}
+	}
+	return results
+}
+
+func printDoctorResults(results []doctorResult) (failed int) {
+	statusColors := map[doctorStatus]*color.Color{
+		doctorPass: color.New(color.FgGreen).Add(color.Bold),
+		doctorWarn: color.New(color.FgYellow).Add(color.Bold),
+		doctorFail: color.New(color.FgRed).Add(color.Bold),
+	}
+	counts := map[doctorStatus]int{}
+	for _, result := range results {
+		counts[result.Status]++
+		fmt.Printf("[%s] %s: %s\n", statusColors[result.Status].Sprint(result.Status), result.Check, result.Message)
+		if result.Fix != "" {
+			fmt.Printf("       Fix: %s\n", result.Fix)
+		}
+	}
+	fmt.Printf("\n%d passed, %d warnings, %d failed\n", counts[doctorPass], counts[doctorWarn], counts[doctorFail])
+	return counts[doctorFail]
+}
+
+func configDoctor(cmd *cobra.Command, args []string) error {
+	modules := server_structs.NewServerType()
+	for _, module := range doctorModules {
+		if !modules.SetString(module) {
+			return errors.Errorf("unsupported module: %s. Please use one of origin, cache, director, or registry", module)
+		}
+	}
+	if modules == 0 {
+		return errors.New("please specify the modules to check with --module, for example: pelican config doctor -m origin")
+	}
+	if err := config.SetServerDefaults(viper.GetViper()); err != nil {
+		return err
+	}
+
+	failed := printDoctorResults(runDoctorChecks(cmd.Context(), modules))
+	if failed > 0 {
+		return errors.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
+func init() {
+	configDoctorCmd.Flags().StringArrayVarP(&doctorModules, "module", "m", []string{},
+		"The modules that will be served, e.g. `-m origin`. Multiple modules can be specified at the same time.")
+	ConfigCmd.AddCommand(configDoctorCmd)
+}
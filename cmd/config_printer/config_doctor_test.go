@@ -0,0 +1,141 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config_printer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Write a self-signed certificate for hostname and its key to dir
+func writeTestCertificate(t *testing.T, dir, hostname string, notAfter time.Time) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hostname},
+		DNSNames:     []string{hostname},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "tls.crt")
+	keyFile = filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return
+}
+
+func TestCheckCertificate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeTestCertificate(t, dir, "origin.example.com", now.Add(90*24*time.Hour))
+
+	t.Run("missing", func(t *testing.T) {
+		result := checkCertificate(filepath.Join(dir, "missing.crt"), keyFile, "origin.example.com", now)
+		assert.Equal(t, doctorWarn, result.Status)
+		assert.Contains(t, result.Message, "will be generated")
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		result := checkCertificate(certFile, keyFile, "origin.example.com", now)
+		assert.Equal(t, doctorWarn, result.Status)
+		assert.Contains(t, result.Message, "not trusted")
+	})
+
+	t.Run("wrong-hostname", func(t *testing.T) {
+		result := checkCertificate(certFile, keyFile, "cache.example.com", now)
+		assert.Equal(t, doctorFail, result.Status)
+		assert.Contains(t, result.Fix, "Server.Hostname")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		result := checkCertificate(certFile, keyFile, "origin.example.com", now.Add(100*24*time.Hour))
+		assert.Equal(t, doctorFail, result.Status)
+		assert.Contains(t, result.Message, "expired")
+	})
+
+	t.Run("expiring-soon", func(t *testing.T) {
+		result := checkCertificate(certFile, keyFile, "origin.example.com", now.Add(80*24*time.Hour))
+		assert.Equal(t, doctorWarn, result.Status)
+		assert.Contains(t, result.Message, "expires soon")
+	})
+
+	t.Run("mismatched-key", func(t *testing.T) {
+		_, otherKey := writeTestCertificate(t, t.TempDir(), "origin.example.com", now.Add(time.Hour))
+		result := checkCertificate(certFile, otherKey, "origin.example.com", now)
+		assert.Equal(t, doctorFail, result.Status)
+		assert.Contains(t, result.Fix, "Server.TLSKey")
+	})
+}
+
+func TestCheckKeyPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("File modes are not checked on Windows")
+	}
+	dir := t.TempDir()
+	private := filepath.Join(dir, "private.key")
+	shared := filepath.Join(dir, "shared.key")
+	require.NoError(t, os.WriteFile(private, []byte("key"), 0600))
+	require.NoError(t, os.WriteFile(shared, []byte("key"), 0644))
+
+	results := checkKeyPermissions([]string{private, shared, filepath.Join(dir, "missing.key"), ""})
+	require.Len(t, results, 2)
+	assert.Equal(t, doctorPass, results[0].Status)
+	assert.Equal(t, doctorFail, results[1].Status)
+	assert.Equal(t, "Run `chmod 0600 "+shared+"`", results[1].Fix)
+}
+
+func TestEvaluateClockSkew(t *testing.T) {
+	assert.Equal(t, doctorPass, evaluateClockSkew(2*time.Second, "https://director").Status)
+	assert.Equal(t, doctorWarn, evaluateClockSkew(-30*time.Second, "https://director").Status)
+	result := evaluateClockSkew(5*time.Minute, "https://director")
+	assert.Equal(t, doctorFail, result.Status)
+	assert.Contains(t, result.Message, "5m0s")
+}
+
+func TestParseXrootdVersion(t *testing.T) {
+	version, ok := parseXrootdVersion("v5.7.1\n")
+	require.True(t, ok)
+	assert.Equal(t, [3]int{5, 7, 1}, version)
+
+	version, ok = parseXrootdVersion("xrootd version 5.6.9-rc1")
+	require.True(t, ok)
+	assert.Equal(t, [3]int{5, 6, 9}, version)
+
+	_, ok = parseXrootdVersion("command not found")
+	assert.False(t, ok)
+}
@@ -0,0 +1,173 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/director"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/registry"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+var (
+	registryMigrateCmd = newMigrateCmd("registry", param.Registry_DbLocation, registry.GetMigrations())
+	directorMigrateCmd = newMigrateCmd("director", param.Director_DbLocation, director.GetMigrations())
+)
+
+// Build the `migrate` subcommand managing the schema of a server's database
+func newMigrateCmd(server string, dbLocation param.StringParam, migrations embed.FS) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: fmt.Sprintf("Upgrade or roll back the schema of the %s database", server),
+		Long: fmt.Sprintf(`Upgrade or roll back the schema of the %s database.
+
+By default, every pending migration is applied; --to stops at the given
+version instead. --rollback undoes the most recent migration, or every
+migration after the version given with --to. The database is backed up
+next to itself before it is changed, and --dry-run shows what would be
+done without changing anything.
+
+Stop the %s before migrating its database.`, server, server),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return migrateDB(cmd, dbLocation, migrations)
+		},
+		SilenceUsage: true,
+	}
+	cmd.Flags().Bool("dry-run", false, "Show the migrations that would run without changing the database")
+	cmd.Flags().Bool("rollback", false, "Roll back the most recent migration, or those after the --to version")
+	cmd.Flags().Int64("to", 0, "The migration version to upgrade or roll back to")
+	return cmd
+}
+
+func printMigrations(out io.Writer, verb string, migrations []server_utils.MigrationStatus) {
+	for _, migration := range migrations {
+		fmt.Fprintf(out, "%s %d_%s\n", verb, migration.Version, migration.Name)
+	}
+}
+
+func migrateDB(cmd *cobra.Command, dbLocation param.StringParam, migrations embed.FS) error {
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.Wrap(err, "Failed to get value of the --dry-run flag")
+	}
+	rollback, err := cmd.Flags().GetBool("rollback")
+	if err != nil {
+		return errors.Wrap(err, "Failed to get value of the --rollback flag")
+	}
+	target, err := cmd.Flags().GetInt64("to")
+	if err != nil {
+		return errors.Wrap(err, "Failed to get value of the --to flag")
+	}
+	toSet := cmd.Flags().Changed("to")
+
+	if err := config.SetServerDefaults(viper.GetViper()); err != nil {
+		return err
+	}
+	gormDB, err := server_utils.InitSQLiteDB(dbLocation.GetString())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = server_utils.ShutdownDB(gormDB)
+	}()
+	sqldb, err := gormDB.DB()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get sql.DB from gorm DB")
+	}
+
+	statuses, err := server_utils.GetMigrationStatus(sqldb, migrations)
+	if err != nil {
+		return err
+	}
+	current := server_utils.CurrentMigrationVersion(statuses)
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Database %s is at migration version %d\n", dbLocation.GetString(), current)
+
+	if toSet {
+		found := target == 0
+		for _, status := range statuses {
+			if status.Version == target {
+				found = true
+			}
+		}
+		if !found {
+			return errors.Errorf("There is no migration with version %d", target)
+		}
+	}
+	if rollback {
+		if !toSet {
+			// Roll back only the most recent migration
+			target = 0
+			for _, status := range statuses {
+				if status.Applied && status.Version < current {
+					target = status.Version
+				}
+			}
+		}
+		if target > current {
+			return errors.Errorf("Can't roll back to version %d, which is newer than the current version %d", target, current)
+		}
+	} else if !toSet {
+		target = server_utils.LatestMigration
+	} else if target < current {
+		return errors.Errorf("Version %d is older than the current version %d; pass --rollback to roll back to it", target, current)
+	}
+
+	apply, undo := server_utils.PlanMigrations(statuses, target)
+	if rollback {
+		apply = nil
+	} else {
+		undo = nil
+	}
+	if len(apply) == 0 && len(undo) == 0 {
+		fmt.Fprintln(out, "Nothing to do")
+		return nil
+	}
+	if dryRun {
+		printMigrations(out, "Would apply", apply)
+		printMigrations(out, "Would roll back", undo)
+		return nil
+	}
+
+	backupFile, err := server_utils.BackupSQLiteDB(sqldb)
+	if err != nil {
+		return errors.Wrap(err, "Refusing to migrate the database without a backup")
+	}
+	fmt.Fprintf(out, "Backed up the database to %s\n", backupFile)
+	if err := server_utils.MigrateDBTo(sqldb, migrations, target); err != nil {
+		return errors.Wrapf(err, "Migration failed; restore the database from %s if it's unusable", backupFile)
+	}
+	printMigrations(out, "Applied", apply)
+	printMigrations(out, "Rolled back", undo)
+	return nil
+}
+
+func init() {
+	registryCmd.AddCommand(registryMigrateCmd)
+	directorCmd.AddCommand(directorMigrateCmd)
+}
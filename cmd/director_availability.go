@@ -0,0 +1,186 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/director"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/token"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+var (
+	directorAvailabilityCmd = &cobra.Command{
+		Use:   "availability-report <prefix>",
+		Short: "Report how many caches hold the objects of a namespace",
+		Long: `Report how many caches hold the objects of a namespace.
+
+The director samples objects under the prefix from the collection listing of an
+origin exporting it (or checks the objects given with --object) and asks each
+cache serving the namespace whether it holds them.  The report gives each
+object's replica count, the average replica count, how many of the objects each
+cache and each cache region holds, and the cold spots: the regions whose caches
+hold less than --cold-threshold of the objects.  It helps plan capacity and
+decide where to add caches.
+
+The command runs on the director's host, as it signs its request to the
+director's admin API with the director's issuer key.`,
+		Args:         cobra.ExactArgs(1),
+		RunE:         reportObjectAvailability,
+		SilenceUsage: true,
+	}
+)
+
+func init() {
+	flagSet := directorAvailabilityCmd.Flags()
+	flagSet.Int("samples", 20, "Number of objects to sample under the prefix")
+	flagSet.StringArray("object", nil, "Object to check instead of sampling the prefix; may be repeated")
+	flagSet.Float64("cold-threshold", 0.5, "Fraction of the objects below which a region is a cold spot")
+	flagSet.String("token", "", "Token file for reading the objects of a protected namespace")
+	directorCmd.AddCommand(directorAvailabilityCmd)
+}
+
+// Create a short-lived token the director's web UI accepts as an admin login
+func createAdminLoginToken() (string, error) {
+	tokenCfg := token.NewWLCGToken()
+	tokenCfg.Lifetime = 5 * time.Minute
+	tokenCfg.Issuer = param.Server_ExternalWebUrl.GetString()
+	tokenCfg.AddAudiences(param.Server_ExternalWebUrl.GetString())
+	tokenCfg.Subject = "admin"
+	tokenCfg.AddScopes(token_scopes.WebUi_Access)
+	return tokenCfg.CreateToken()
+}
+
+func reportObjectAvailability(cmd *cobra.Command, args []string) error {
+	samples, _ := cmd.Flags().GetInt("samples")
+	objects, _ := cmd.Flags().GetStringArray("object")
+	coldThreshold, _ := cmd.Flags().GetFloat64("cold-threshold")
+	tokenFile, _ := cmd.Flags().GetString("token")
+
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if err := config.InitServer(ctx, server_structs.DirectorType); err != nil {
+		return errors.Wrap(err, "failed to initialize the director's configuration")
+	}
+	loginToken, err := createAdminLoginToken()
+	if err != nil {
+		return errors.Wrap(err, "failed to create a token for the director's admin API")
+	}
+
+	query := url.Values{}
+	query.Set("prefix", args[0])
+	query.Set("samples", strconv.Itoa(samples))
+	query.Set("coldThreshold", strconv.FormatFloat(coldThreshold, 'f', -1, 64))
+	for _, object := range objects {
+		query.Add("object", object)
+	}
+	if tokenFile != "" {
+		contents, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to read the token file")
+		}
+		query.Set("authz", strings.TrimSpace(string(contents)))
+	}
+	report, err := fetchAvailabilityReport(ctx, param.Server_ExternalWebUrl.GetString(), loginToken, query)
+	if err != nil {
+		return err
+	}
+
+	if outputJSON {
+		jsonData, err := json.Marshal(report)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the availability report to JSON format")
+		}
+		fmt.Println(string(jsonData))
+		return nil
+	}
+	fmt.Printf("Namespace: %s (prefix %s)\n", report.Namespace, report.Prefix)
+	fmt.Printf("Objects: %d sampled, %d on no cache; %.2f replicas on average across %d caches\n",
+		report.Sampled, report.UncachedObjects, report.AverageReplicas, report.Caches)
+	if len(report.ColdSpots) > 0 {
+		fmt.Printf("Cold spots (below %.0f%% of the objects): %s\n", 100*report.ColdThreshold, strings.Join(report.ColdSpots, ", "))
+	}
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 3, ' ', 0)
+	fmt.Fprintln(w, "\nREGION\tCACHES\tOBJECTS\tCOVERAGE")
+	for _, region := range report.RegionCoverage {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f%%\n", region.Region, region.Caches, region.Objects, 100*region.Fraction)
+	}
+	fmt.Fprintln(w, "\nCACHE\tREGION\tOBJECTS\tCOVERAGE")
+	for _, cache := range report.CacheCoverage {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f%%\n", cache.Name, cache.Region, cache.Objects, 100*cache.Fraction)
+	}
+	fmt.Fprintln(w, "\nOBJECT\tREPLICAS\tCACHES")
+	for _, object := range report.Objects {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", object.Path, object.Replicas, strings.Join(object.Caches, ","))
+	}
+	return w.Flush()
+}
+
+func fetchAvailabilityReport(ctx context.Context, directorUrl, loginToken string, query url.Values) (*director.AvailabilityReport, error) {
+	reportUrl, err := url.JoinPath(directorUrl, "/api/v1.0/director_ui/availability_report")
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid director URL")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reportUrl+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.AddCookie(&http.Cookie{Name: "login", Value: loginToken})
+	client := &http.Client{Transport: config.GetTransport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to request the availability report from the director")
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the availability report")
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiResp := server_structs.SimpleApiResp{}
+		if json.Unmarshal(body, &apiResp) == nil && apiResp.Msg != "" {
+			return nil, errors.Errorf("the director failed to report the availability (status %d): %s", resp.StatusCode, apiResp.Msg)
+		}
+		return nil, errors.Errorf("the director failed to report the availability (status %d)", resp.StatusCode)
+	}
+	report := &director.AvailabilityReport{}
+	if err := json.Unmarshal(body, report); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the availability report")
+	}
+	return report, nil
+}
@@ -28,11 +28,11 @@ import (
 
 func serveDirector(cmd *cobra.Command, args []string) error {
 	modules := server_structs.DirectorType
-	if param.Origin_EnableBroker.GetBool() {
+	if param.Director_EnableBroker.GetBool() {
 		modules.Set(server_structs.BrokerType)
 	}
 
-	_, cancel, err := launchers.LaunchModules(cmd.Context(), server_structs.DirectorType)
+	_, cancel, err := launchers.LaunchModules(cmd.Context(), modules)
 	if err != nil {
 		cancel()
 	}
@@ -0,0 +1,169 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/pelicanplatform/pelican/director"
+)
+
+var (
+	directorSimulateCmd = &cobra.Command{
+		Use:   "simulate",
+		Short: "Simulate how the director would redirect a workload",
+		Long: `Simulate how the director would redirect a workload.
+
+The requests of a workload are replayed against the director's own filtering
+and sorting logic for a federation described by --servers, a YAML file listing
+each server's name, type (Cache or Origin), URL, latitude, longitude, IO load,
+and the namespace prefixes it serves:
+
+    servers:
+      - name: chicago-cache
+        type: Cache
+        latitude: 41.88
+        longitude: -87.63
+      - name: madison-origin
+        type: Origin
+        namespaces: ["/example"]
+
+A cache that lists no namespaces serves every namespace the origins export.
+
+The workload is either the director's log (--log), whose redirects are replayed
+from the clients that made them, or --synthetic requests for objects in the
+origins' namespaces from clients spread across the contiguous US.
+
+The report gives the rate at which the director's logic redirected the requests
+and how they were distributed across the servers.  With --remove, the workload is
+replayed again without the listed servers (comma-separated; the flag may be
+repeated for several scenarios), reporting how many requests moved to another
+server and how many could no longer be served.
+
+The configured Director.CacheSortMethod and Director.FilteredServers are used.`,
+		Args:         cobra.NoArgs,
+		RunE:         simulateDirector,
+		SilenceUsage: true,
+	}
+)
+
+func init() {
+	flagSet := directorSimulateCmd.Flags()
+	flagSet.String("servers", "", "YAML file describing the servers of the federation")
+	flagSet.String("log", "", "Director log whose redirects are replayed")
+	flagSet.Int("synthetic", 0, "Number of synthetic requests to replay instead of a log")
+	flagSet.StringArray("remove", nil, "Comma-separated servers to remove in an additional scenario")
+	directorCmd.AddCommand(directorSimulateCmd)
+}
+
+func simulateDirector(cmd *cobra.Command, args []string) error {
+	serversFile, _ := cmd.Flags().GetString("servers")
+	logFile, _ := cmd.Flags().GetString("log")
+	synthetic, _ := cmd.Flags().GetInt("synthetic")
+	removeFlags, _ := cmd.Flags().GetStringArray("remove")
+	if serversFile == "" {
+		return errors.New("the servers of the federation must be given with --servers")
+	}
+	if (logFile == "") == (synthetic <= 0) {
+		return errors.New("exactly one of --log or a positive number of --synthetic requests is required")
+	}
+
+	servers, err := director.LoadSimulatedServers(serversFile)
+	if err != nil {
+		return err
+	}
+	var requests []director.SimulatedRequest
+	if logFile != "" {
+		fp, err := os.Open(logFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to open the director log")
+		}
+		defer fp.Close()
+		if requests, err = director.ParseRedirectLog(fp); err != nil {
+			return err
+		}
+		if len(requests) == 0 {
+			return errors.Errorf("no redirects were found in the director log %s", logFile)
+		}
+	} else {
+		requests = director.SyntheticWorkload(servers, synthetic)
+	}
+
+	removals := make([][]string, 0, len(removeFlags))
+	for _, removeFlag := range removeFlags {
+		removed := []string{}
+		for _, name := range strings.Split(removeFlag, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				removed = append(removed, name)
+			}
+		}
+		if len(removed) > 0 {
+			removals = append(removals, removed)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	scenarios, err := director.Simulate(ctx, servers, requests, removals)
+	if err != nil {
+		return err
+	}
+
+	if outputJSON {
+		jsonData, err := json.Marshal(scenarios)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the simulation results to JSON format")
+		}
+		fmt.Println(string(jsonData))
+		return nil
+	}
+	for idx, scenario := range scenarios {
+		if idx > 0 {
+			fmt.Println()
+		}
+		if len(scenario.Removed) == 0 {
+			fmt.Println("Scenario: all servers present")
+		} else {
+			fmt.Println("Scenario: without", strings.Join(scenario.Removed, ", "))
+		}
+		fmt.Printf("Requests: %d (%d unserved", scenario.Requests, scenario.Unserved)
+		if idx > 0 {
+			fmt.Printf(", %d moved", scenario.Moved)
+		}
+		fmt.Printf("); %.0f redirects/s\n", scenario.RequestsPerSecond)
+		w := tabwriter.NewWriter(os.Stdout, 1, 2, 3, ' ', 0)
+		fmt.Fprintln(w, "SERVER\tTYPE\tREQUESTS\tSHARE")
+		for _, allocation := range scenario.Allocations {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%.1f%%\n", allocation.Server, allocation.Type, allocation.Requests, 100*allocation.Share)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
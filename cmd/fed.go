@@ -28,9 +28,19 @@ var (
 		Use:    "serve",
 		Hidden: true,
 		Short:  "Starts pelican with a list of enabled modules",
-		Long: `Starts pelican with a list of enabled modules [registry, director, cache, origin] to enable better
+		Long: `Starts pelican with a list of enabled modules [registry, director, broker, cache, origin] to enable better
 		 end-to-end and integration testing.
 
+		 The module 'all' runs a complete federation (director, registry, origin, and cache) in one process
+		 for integration tests and local development.  Its servers use 'localhost' and a self-signed CA, and
+		 unless exports are configured, the origin exports the directory fed-in-a-box/data in the config
+		 directory at /data.  Once started, it writes fed-in-a-box/client.yaml, which configures clients on the
+		 same host to use the federation and trust its CA.
+
+		 The client module runs on its own and starts the client transfer daemon, which serves a REST API on the
+		 Client.DaemonSocket Unix socket.  Invocations of 'pelican object get' and 'pelican object put' on the
+		 same machine submit their transfers to the daemon, sharing its connections, tokens, and bandwidth limit.
+
 		 If the director or namespace registry are enabled, then ensure there is a corresponding url in the
 		 pelican.yaml file.
 
@@ -40,7 +50,7 @@ var (
 )
 
 func init() {
-	serveCmd.Flags().StringSlice("module", []string{}, "Modules to be started.")
+	serveCmd.Flags().StringSlice("module", []string{}, "Modules to be started, or 'all' for a federation in one process.")
 	if err := viper.BindPFlag("Server.Modules", serveCmd.Flags().Lookup("module")); err != nil {
 		panic(err)
 	}
@@ -21,9 +21,19 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
 
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/launchers"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
@@ -35,16 +45,79 @@ func fedServeStart(cmd *cobra.Command, args []string) error {
 		return errors.New("No modules are enabled; pass the --module flag or set the Server.Modules parameter")
 	}
 	modules := server_structs.NewServerType()
+	clientDaemon := false
+	fedInABox := false
 	for _, module := range moduleSlice {
+		if strings.EqualFold(module, "client") {
+			clientDaemon = true
+			continue
+		}
+		if strings.EqualFold(module, "all") {
+			fedInABox = true
+			modules.Set(launchers.FedInABoxModules)
+			continue
+		}
 		if !modules.SetString(module) {
 			return errors.Errorf("Unknown module name: %s", module)
 		}
 	}
+	if clientDaemon {
+		if modules != server_structs.NewServerType() {
+			return errors.New("The client module cannot be combined with server modules")
+		}
+		return clientDaemonStart(cmd.Context())
+	}
+
+	if fedInABox {
+		if err := launchers.ConfigureFedInABox(); err != nil {
+			return err
+		}
+	}
 
 	_, cancel, err := launchers.LaunchModules(cmd.Context(), modules)
 	if err != nil {
 		cancel()
+		return err
+	}
+
+	if fedInABox {
+		clientConfig, err := launchers.WriteFedInABoxClientConfig()
+		if err != nil {
+			cancel()
+			return err
+		}
+		log.Infof("The federation is running at %s; use it from clients on this host with `pelican --config %s`",
+			param.Server_ExternalWebUrl.GetString(), clientConfig)
 	}
 
-	return err
+	return nil
+}
+
+// Run the client transfer daemon until the process is signalled
+func clientDaemonStart(ctx context.Context) error {
+	if err := config.InitClient(); err != nil {
+		return err
+	}
+	egrp, ok := ctx.Value(config.EgrpKey).(*errgroup.Group)
+	if !ok {
+		egrp = &errgroup.Group{}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	egrp.Go(func() error {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		select {
+		case sig := <-sigs:
+			log.Warningf("Received signal %v; will shutdown the client transfer daemon", sig)
+			cancel()
+			return launchers.ErrExitOnSignal
+		case <-ctx.Done():
+			return nil
+		}
+	})
+	if err := client.LaunchTransferDaemon(ctx, egrp); err != nil {
+		cancel()
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,128 @@
+/***************************************************************
+*
+* Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+*
+* Licensed under the Apache License, Version 2.0 (the "License"); you
+* may not use this file except in compliance with the License.  You may
+* obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+***************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/config"
+)
+
+var (
+	speedtestCmd = &cobra.Command{
+		Use:   "speedtest [object]",
+		Short: "Measure transfer speeds to the caches and origins the director picks",
+		Long: `Measure transfer speeds to the caches and origins the director picks.
+
+The object is downloaded, in turn, from each of the nearest caches and origins the
+director returns for it, and the latency and throughput of every endpoint is
+reported.  Latency is the time until the first byte of the response arrives.
+
+Instead of an existing object, --upload-to names a collection the user can write to.
+A synthetic object of --size bytes is then uploaded through the origin the director
+picks, downloaded as above, and deleted afterwards.  Note the first download of a
+fresh object through a cache includes the time the cache takes to fetch it from the
+origin.`,
+		Args:         cobra.MaximumNArgs(1),
+		RunE:         speedtestMain,
+		SilenceUsage: true,
+	}
+)
+
+func init() {
+	flagSet := speedtestCmd.Flags()
+	flagSet.StringP("token", "t", "", "Token file to use for the transfers")
+	flagSet.String("upload-to", "", "Collection to upload a synthetic test object to, instead of downloading an existing object")
+	flagSet.String("size", "64MB", "Size of the synthetic test object uploaded with --upload-to")
+	flagSet.Int("endpoints", 3, "Maximum number of caches, and of origins, to test")
+	federationCmd.AddCommand(speedtestCmd)
+}
+
+func speedtestMain(cmd *cobra.Command, args []string) error {
+	if err := config.InitClient(); err != nil {
+		return err
+	}
+
+	opts := client.SpeedTestOptions{}
+	opts.UploadPrefix, _ = cmd.Flags().GetString("upload-to")
+	if len(args) == 1 {
+		opts.Object = args[0]
+	}
+	if (opts.Object == "") == (opts.UploadPrefix == "") {
+		return errors.New("exactly one of an object to download or --upload-to is required")
+	}
+	sizeStr, _ := cmd.Flags().GetString("size")
+	size, err := units.ParseStrictBytes(sizeStr)
+	if err != nil || size <= 0 {
+		return errors.Errorf("invalid size %q; must be a positive size such as 64MB", sizeStr)
+	}
+	opts.Size = size
+	opts.Endpoints, _ = cmd.Flags().GetInt("endpoints")
+	if opts.Endpoints <= 0 {
+		return errors.Errorf("invalid number of endpoints %d; must be positive", opts.Endpoints)
+	}
+	tokenLocation, _ := cmd.Flags().GetString("token")
+
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	results, err := client.DoSpeedTest(ctx, opts, client.WithTokenLocation(tokenLocation))
+	if err != nil {
+		return err
+	}
+
+	if outputJSON {
+		jsonData, err := json.Marshal(results)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the speed test results to JSON format")
+		}
+		fmt.Println(string(jsonData))
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 1, 2, 3, ' ', 0)
+		fmt.Fprintln(w, "DIRECTION\tSERVER\tENDPOINT\tLATENCY\tTHROUGHPUT\tSIZE\tERROR")
+		for _, result := range results {
+			throughput := "-"
+			if result.Error == "" {
+				throughput = client.FormatThroughput(result.Throughput)
+			}
+			fmt.Fprintln(w, result.Direction+"\t"+result.Server+"\t"+result.Endpoint+"\t"+result.Latency.Round(time.Millisecond).String()+"\t"+
+				throughput+"\t"+client.ByteCountSI(result.Bytes)+"\t"+result.Error)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	for _, result := range results {
+		if result.Error == "" {
+			return nil
+		}
+	}
+	return errors.New("the speed test failed against every endpoint")
+}
@@ -0,0 +1,100 @@
+/***************************************************************
+*
+* Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+*
+* Licensed under the Apache License, Version 2.0 (the "License"); you
+* may not use this file except in compliance with the License.  You may
+* obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+***************************************************************/
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/fuse_mount"
+)
+
+var (
+	mountCmd = &cobra.Command{
+		Use:   "mount {federation-prefix} {mountpoint}",
+		Short: "Mount a namespace from a federation as a read-only filesystem",
+		Long: `Mount a namespace from a federation as a read-only filesystem.
+
+The collection at the federation prefix is made available under the mountpoint so
+applications can read federation data through ordinary POSIX paths.  Directory
+listings come from the origin's listing support and object contents are read in
+ranges from the caches the director redirects to, so only the parts of an object
+that are actually read are transferred.  Recently read data is kept in an in-memory
+page cache whose size is set by --cache-size.
+
+The command stays in the foreground until interrupted, at which point the filesystem
+is unmounted.  Requires FUSE support on the host; not available on Windows.`,
+		Args:         cobra.ExactArgs(2),
+		RunE:         mountMain,
+		SilenceUsage: true,
+	}
+)
+
+func init() {
+	flagSet := mountCmd.Flags()
+	flagSet.StringP("token", "t", "", "Token file to use for listings and reads")
+	flagSet.String("cache-size", "256MB", "Maximum size of the in-memory cache of object contents")
+	flagSet.Duration("list-ttl", time.Minute, "How long directory listings and file attributes are cached before being refreshed")
+	flagSet.Bool("allow-other", false, "Allow other users to access the mount (requires user_allow_other in /etc/fuse.conf)")
+	flagSet.Bool("fuse-debug", false, "Log every filesystem operation")
+}
+
+func mountMain(cmd *cobra.Command, args []string) error {
+	if err := config.InitClient(); err != nil {
+		return err
+	}
+
+	cacheSizeStr, _ := cmd.Flags().GetString("cache-size")
+	cacheSize, err := units.ParseStrictBytes(cacheSizeStr)
+	if err != nil || cacheSize <= 0 {
+		return errors.Errorf("invalid cache size %q; must be a positive size such as 256MB", cacheSizeStr)
+	}
+	listTTL, _ := cmd.Flags().GetDuration("list-ttl")
+	if listTTL < 0 {
+		return errors.Errorf("invalid listing TTL %s; must not be negative", listTTL)
+	}
+	tokenLocation, _ := cmd.Flags().GetString("token")
+	allowOther, _ := cmd.Flags().GetBool("allow-other")
+	fuseDebug, _ := cmd.Flags().GetBool("fuse-debug")
+
+	mountpoint := args[1]
+	if fi, err := os.Stat(mountpoint); err != nil {
+		return errors.Wrap(err, "invalid mountpoint")
+	} else if !fi.IsDir() {
+		return errors.Errorf("mountpoint %s is not a directory", mountpoint)
+	}
+
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	return fuse_mount.Mount(ctx, args[0], mountpoint, fuse_mount.Options{
+		CacheSize:       cacheSize,
+		ListTTL:         listTTL,
+		AllowOther:      allowOther,
+		Debug:           fuseDebug,
+		TransferOptions: []client.TransferOption{client.WithTokenLocation(tokenLocation)},
+	})
+}
@@ -19,7 +19,14 @@
 package main
 
 import (
+	"path/filepath"
+
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/param"
 )
 
 var (
@@ -28,3 +35,75 @@ var (
 		Short: "Interact with objects in the federation",
 	}
 )
+
+func init() {
+	objectCmd.PersistentFlags().String("compat", "", "Reproduce the client behavior of an older release (e.g., 7.10) by disabling newer features")
+	if err := viper.BindPFlag("Client.CompatibilityLevel", objectCmd.PersistentFlags().Lookup("compat")); err != nil {
+		panic(err)
+	}
+}
+
+// Add the flags controlling how hard a transfer may push the local machine
+func addTransferSchedulingFlags(flagSet *pflag.FlagSet, direction string) {
+	flagSet.Int("parallelism", 0, "Number of objects to transfer in parallel during a recursive "+direction+"; overrides Client.WorkerCount")
+	flagSet.Int("parallel", 0, "Number of objects to transfer in parallel during a recursive "+direction)
+	_ = flagSet.MarkDeprecated("parallel", "use --parallelism instead")
+	flagSet.String("max-rate", "", "Maximum combined transfer rate across all objects (e.g., 50MB/s); overrides Client.MaxRate")
+	flagSet.Int("nice", 0, "Run the transfer at a lower CPU and I/O priority (nice value 0-19); overrides Client.Nice")
+}
+
+// Apply the transfer scheduling flags on top of the client configuration
+// and lower the process priority if requested
+func applyTransferScheduling(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	parallelism, _ := flags.GetInt("parallelism")
+	if parallelism <= 0 {
+		parallelism, _ = flags.GetInt("parallel")
+	}
+	if parallelism > 0 {
+		viper.Set(param.Client_WorkerCount.GetName(), parallelism)
+	}
+	if flags.Changed("max-rate") {
+		maxRate, _ := flags.GetString("max-rate")
+		if _, err := client.ParseMaxRate(maxRate); err != nil {
+			return err
+		}
+		viper.Set(param.Client_MaxRate.GetName(), maxRate)
+	}
+	if flags.Changed("nice") {
+		nice, _ := flags.GetInt("nice")
+		viper.Set(param.Client_Nice.GetName(), nice)
+	}
+	if nice := param.Client_Nice.GetInt(); nice != 0 {
+		return client.SetProcessPriority(nice)
+	}
+	return nil
+}
+
+// Returns true if the command's transfers should be handed to the client
+// transfer daemon.  The scheduling flags and compatibility level configure
+// the local transfer engine, so commands using them run locally.
+func useTransferDaemon(cmd *cobra.Command) bool {
+	flags := cmd.Flags()
+	for _, name := range []string{"parallelism", "parallel", "max-rate", "nice"} {
+		if flags.Changed(name) {
+			return false
+		}
+	}
+	if param.Client_CompatibilityLevel.GetString() != "" {
+		return false
+	}
+	return client.DaemonAvailable(cmd.Context())
+}
+
+// Make a local path absolute for the client transfer daemon, which does not
+// share our working directory
+func daemonLocalPath(path string) string {
+	if path == "" {
+		return path
+	}
+	if absPath, err := filepath.Abs(path); err == nil {
+		return absPath
+	}
+	return path
+}
@@ -30,7 +30,6 @@ import (
 
 	"github.com/pelicanplatform/pelican/client"
 	"github.com/pelicanplatform/pelican/config"
-	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/utils"
 )
 
@@ -40,7 +39,13 @@ var (
 	copyCmd = &cobra.Command{
 		Use:   "copy {source ...} {destination}",
 		Short: "Copy a file to/from a Pelican federation",
-		Run:   copyMain,
+		Long: `Copy a file to/from a Pelican federation.
+
+If both the source and destination are federation URLs (which may belong to different
+federations), the object is copied between them.  The destination server is first asked
+to pull the object directly from the source with an HTTP third-party copy; if that is
+unsupported or fails, the object is streamed through the client instead.`,
+		Run: copyMain,
 	}
 )
 
@@ -54,6 +59,8 @@ func init() {
 	flagSet.StringP("cache", "c", "", "Cache to use")
 	flagSet.StringP("token", "t", "", "Token file to use for transfer")
 	flagSet.BoolP("recursive", "r", false, "Recursively copy a collection.  Forces methods to only be http to get the freshest collection contents")
+	flagSet.Bool("tpc", true, "For copies between two federation URLs, attempt a third-party copy between the servers before streaming through the client")
+	addJSONFlag(flagSet)
 	flagSet.StringP("cache-list-name", "n", "xroot", "(Deprecated) Cache list to use, currently either xroot or xroots; may be ignored")
 	flagSet.Lookup("cache-list-name").Hidden = true
 	// All the deprecated or hidden flags that are only relevant if we are in historical "stashcp mode"
@@ -73,6 +80,7 @@ func init() {
 	} else {
 		flagSet.String("caches", "", "A JSON file containing the list of caches")
 		flagSet.String("methods", "http", "Comma separated list of methods to try, in order")
+		addProgressFlags(flagSet)
 		objectCmd.AddCommand(copyCmd)
 	}
 }
@@ -89,8 +97,12 @@ func copyMain(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	asJSON, _ := cmd.Flags().GetBool("json")
 	err := config.InitClient()
 	if err != nil {
+		if asJSON {
+			newJSONResult("copy").finish(err)
+		}
 		log.Errorln(err)
 
 		if client.IsRetryable(err) {
@@ -111,11 +123,7 @@ func copyMain(cmd *cobra.Command, args []string) {
 
 	tokenLocation, _ := cmd.Flags().GetString("token")
 
-	// Check if the program was executed from a terminal and does not specify a log location
-	// https://rosettacode.org/wiki/Check_output_device_is_a_terminal#Go
-	if fileInfo, _ := os.Stdout.Stat(); (fileInfo.Mode()&os.ModeCharDevice) != 0 && param.Logging_LogLocation.GetString() == "" && !param.Logging_DisableProgressBars.GetBool() {
-		pb.launchDisplay(ctx)
-	}
+	pb.launchForCommand(ctx, cmd, asJSON)
 
 	if val, err := cmd.Flags().GetBool("namespaces"); err == nil && val {
 		// NOTE: The value returned by this no longer conforms to the old-style stashcp namespaces JSON.
@@ -153,7 +161,11 @@ func copyMain(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	if len(source) > 1 {
+	destIsLocal := true
+	if destUrl, err := url.Parse(dest); err == nil && destUrl.Scheme != "" && destUrl.Scheme != "file" {
+		destIsLocal = false
+	}
+	if len(source) > 1 && destIsLocal {
 		if destStat, err := os.Stat(dest); err != nil {
 			log.Errorln("Destination does not exist")
 			os.Exit(1)
@@ -164,17 +176,28 @@ func copyMain(cmd *cobra.Command, args []string) {
 	}
 
 	var result error
+	var transferResults []client.TransferResults
 	lastSrc := ""
 
 	for _, src := range source {
 		isRecursive, _ := cmd.Flags().GetBool("recursive")
-		_, result = client.DoCopy(ctx, src, dest, isRecursive, client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation), client.WithCaches(caches...))
+		tpc, _ := cmd.Flags().GetBool("tpc")
+		var srcResults []client.TransferResults
+		srcResults, result = client.DoCopy(ctx, src, dest, isRecursive, client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation), client.WithCaches(caches...), client.WithThirdPartyCopy(tpc))
+		transferResults = append(transferResults, srcResults...)
 		if result != nil {
 			lastSrc = src
 			break
 		}
 	}
 
+	if asJSON {
+		jsonResult := newJSONResult("copy")
+		jsonResult.addTransfers(transferResults)
+		jsonResult.finish(result)
+		return
+	}
+
 	// Exit with failure
 	if result != nil {
 		// Print the list of errors
@@ -0,0 +1,80 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/client"
+)
+
+// Read a signed dataset manifest from a file, or from stdin if the location is "-"
+func readSignedManifest(location string) ([]byte, error) {
+	var signed []byte
+	var err error
+	if location == client.StreamPath {
+		signed, err = io.ReadAll(os.Stdin)
+	} else {
+		signed, err = os.ReadFile(location)
+	}
+	return signed, errors.Wrap(err, "failed to read the signed manifest")
+}
+
+// Download the dataset of a signed manifest into destDir, writing the provenance
+// report (if requested) before exiting
+func datasetGetMain(ctx context.Context, manifestLocation string, reportLocation string, destDir string, asJSON bool, options ...client.TransferOption) {
+	signed, err := readSignedManifest(manifestLocation)
+	if err == nil {
+		if destStat, statErr := os.Stat(destDir); statErr != nil || !destStat.IsDir() {
+			err = errors.Errorf("destination %s is not a directory", destDir)
+		}
+	}
+	if err != nil {
+		if asJSON {
+			newJSONResult("get").finish(err)
+		}
+		log.Errorln(err)
+		os.Exit(1)
+	}
+
+	report, result := client.DoDatasetGet(ctx, signed, destDir, options...)
+	if reportLocation != "" && report != nil {
+		if err := writeJSONFile(reportLocation, report, "provenance report"); err != nil {
+			log.Errorln(err)
+			if result == nil {
+				result = err
+			}
+		}
+	}
+
+	if asJSON {
+		newJSONResult("get").finish(result)
+		return
+	}
+	if result != nil {
+		log.Errorln(result)
+		os.Exit(commandExitCode(result))
+	}
+	log.Infof("Verified all %d files of dataset %s", len(report.Files), report.Dataset)
+}
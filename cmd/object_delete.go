@@ -55,6 +55,7 @@ func init() {
 	flagSet := objectDeleteCmd.Flags()
 	flagSet.StringP("token", "t", "", "Token file to use for transfer")
 	flagSet.BoolP("recursive", "r", false, "Recursively delete a collection")
+	addJSONFlag(flagSet)
 
 	objectCmd.AddCommand(objectDeleteCmd)
 }
@@ -63,8 +64,12 @@ func init() {
 func deleteMain(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 
+	asJSON, _ := cmd.Flags().GetBool("json")
 	err := config.InitClient()
 	if err != nil {
+		if asJSON {
+			newJSONResult("delete").finish(err)
+		}
 		log.Errorln("Failed to initialize client:", err)
 
 		if client.IsRetryable(err) {
@@ -79,6 +84,15 @@ func deleteMain(cmd *cobra.Command, args []string) error {
 
 	err = client.DoDelete(ctx, remoteDestination, isRecursive, client.WithTokenLocation(tokenLocation))
 
+	if asJSON {
+		jsonResult := newJSONResult("delete")
+		if err == nil {
+			jsonResult.Deleted = []string{remoteDestination}
+		}
+		jsonResult.finish(err)
+		return nil
+	}
+
 	if err != nil {
 		log.Errorf("Failure deleting %s: %v", remoteDestination, err.Error())
 		os.Exit(1)
@@ -37,7 +37,34 @@ var (
 	getCmd = &cobra.Command{
 		Use:   "get {source ...} {destination}",
 		Short: "Get a file from a Pelican federation",
-		Run:   getMain,
+		Long: `Get a file from a Pelican federation.
+
+If the destination is "-", the objects are written to stdout, one after another, so
+they can be piped into another program.
+
+If the destination is an s3://bucket/key URL, each object is streamed straight into a
+multipart upload to S3 without being staged on the local disk; a destination ending in
+"/" receives the objects under their base names.  The S3 service and credentials are
+configured with the Client.S3* parameters or the usual AWS environment variables.
+
+With --from-manifest, the objects listed in the manifest are downloaded into the
+destination directory (the current directory if none is given), several at a time.
+Each line of the manifest has the form
+
+    <source URL> [destination] [<checksum type>:<checksum>]
+
+where relative destinations are relative to the destination directory and the
+checksum, if given, must match the downloaded file.  Blank lines and lines starting
+with '#' are ignored.  The outcome of every object can be written as JSON with
+--results-manifest for workflow managers to consume.
+
+With --signed-manifest, the dataset described by a manifest signed by the issuer of
+its namespace (see "pelican origin dataset-manifest") is downloaded into the
+destination directory.  The signature is checked against the issuer's public keys,
+and each file's size and SHA-256 checksum against the manifest; files that don't
+match are removed.  --provenance-report writes a JSON record of the manifest and
+the outcome of each file, to keep alongside the results of an analysis.`,
+		Run: getMain,
 	}
 )
 
@@ -46,17 +73,36 @@ func init() {
 	flagSet.StringP("cache", "c", "", "Cache to use")
 	flagSet.StringP("token", "t", "", "Token file to use for transfer")
 	flagSet.BoolP("recursive", "r", false, "Recursively download a collection.  Forces methods to only be http to get the freshest collection contents")
+	addTransferSchedulingFlags(flagSet, "download")
+	flagSet.Bool("resume", true, "Resume the interrupted download of an object if a partial copy exists at the destination and matches the remote object")
+	flagSet.String("checksum", "", "Verify each downloaded object with the given checksum type (sha256, md5, or adler32); the transfer fails if the checksums do not match")
+	flagSet.Bool("decompress", false, "Decompress objects stored with gzip compression as they are downloaded.  Checksums are verified against the compressed objects, and partial downloads are not resumed")
+	flagSet.String("resume-journal", "", "Journal file recording the progress of a recursive download.  Rerunning an interrupted transfer with the same journal skips objects that were already transferred")
 	flagSet.StringP("cache-list-name", "n", "xroot", "(Deprecated) Cache list to use, currently either xroot or xroots; may be ignored")
 	flagSet.Lookup("cache-list-name").Hidden = true
 	flagSet.String("caches", "", "A JSON file containing the list of caches")
+	flagSet.String("from-manifest", "", "Download the objects listed in a manifest file (\"-\" for stdin) instead of the sources on the command line")
+	flagSet.String("results-manifest", "", "With --from-manifest, write the outcome of each object to this file as JSON")
+	flagSet.String("signed-manifest", "", "Download and verify the dataset described by a signed dataset manifest file (\"-\" for stdin) instead of the sources on the command line")
+	flagSet.String("provenance-report", "", "With --signed-manifest, write the verification report of the dataset to this file as JSON")
+	getCmd.MarkFlagsMutuallyExclusive("from-manifest", "signed-manifest")
+	// The checksums of a manifest are those of the stored objects
+	getCmd.MarkFlagsMutuallyExclusive("decompress", "from-manifest")
+	getCmd.MarkFlagsMutuallyExclusive("decompress", "signed-manifest")
+	addJSONFlag(flagSet)
+	addProgressFlags(flagSet)
 	objectCmd.AddCommand(getCmd)
 }
 
 func getMain(cmd *cobra.Command, args []string) {
 	ctx := cmd.Context()
 
+	asJSON, _ := cmd.Flags().GetBool("json")
 	err := config.InitClient()
 	if err != nil {
+		if asJSON {
+			newJSONResult("get").finish(err)
+		}
 		log.Errorln(err)
 
 		if client.IsRetryable(err) {
@@ -68,18 +114,52 @@ func getMain(cmd *cobra.Command, args []string) {
 	}
 
 	tokenLocation, _ := cmd.Flags().GetString("token")
+	resumeJournal, _ := cmd.Flags().GetString("resume-journal")
+	checksumName, _ := cmd.Flags().GetString("checksum")
+	checksumType, err := client.ParseChecksumType(checksumName)
+	if err != nil {
+		log.Errorln(err)
+		os.Exit(1)
+	}
+	resume, _ := cmd.Flags().GetBool("resume")
+	decompress, _ := cmd.Flags().GetBool("decompress")
+	if err := applyTransferScheduling(cmd); err != nil {
+		log.Errorln(err)
+		os.Exit(1)
+	}
+
+	// A destination of "-" writes the objects to stdout, which must then carry nothing else
+	toStdout := len(args) > 1 && args[len(args)-1] == client.StreamPath
+	if toStdout && asJSON {
+		log.Errorln("The --json option cannot be used when writing objects to stdout")
+		os.Exit(1)
+	}
 
 	pb := newProgressBar()
 	defer pb.shutdown()
 
-	// Check if the program was executed from a terminal
-	// https://rosettacode.org/wiki/Check_output_device_is_a_terminal#Go
-	if fileInfo, _ := os.Stdout.Stat(); (fileInfo.Mode()&os.ModeCharDevice) != 0 && param.Logging_LogLocation.GetString() == "" && !param.Logging_DisableProgressBars.GetBool() {
-		pb.launchDisplay(ctx)
+	pb.launchForCommand(ctx, cmd, asJSON || toStdout)
+
+	manifestLocation, _ := cmd.Flags().GetString("from-manifest")
+	resultsLocation, _ := cmd.Flags().GetString("results-manifest")
+	if resultsLocation != "" && manifestLocation == "" {
+		log.Errorln("The --results-manifest option requires --from-manifest")
+		os.Exit(1)
+	}
+	signedManifestLocation, _ := cmd.Flags().GetString("signed-manifest")
+	reportLocation, _ := cmd.Flags().GetString("provenance-report")
+	if reportLocation != "" && signedManifestLocation == "" {
+		log.Errorln("The --provenance-report option requires --signed-manifest")
+		os.Exit(1)
 	}
 
 	log.Debugln("Len of source:", len(args))
-	if len(args) < 2 {
+	if manifestLocation != "" || signedManifestLocation != "" {
+		if len(args) > 1 || (len(args) == 1 && args[0] == client.StreamPath) {
+			log.Errorln("With --from-manifest or --signed-manifest, the only argument is the destination directory")
+			os.Exit(1)
+		}
+	} else if len(args) < 2 {
 		log.Errorln("No Source or Destination")
 		err = cmd.Help()
 		if err != nil {
@@ -87,11 +167,14 @@ func getMain(cmd *cobra.Command, args []string) {
 		}
 		os.Exit(1)
 	}
-	source := args[:len(args)-1]
-	dest := args[len(args)-1]
-
-	log.Debugln("Sources:", source)
-	log.Debugln("Destination:", dest)
+	var source []string
+	var dest string
+	if manifestLocation == "" && signedManifestLocation == "" {
+		source = args[:len(args)-1]
+		dest = args[len(args)-1]
+		log.Debugln("Sources:", source)
+		log.Debugln("Destination:", dest)
+	}
 
 	// Check for manually entered cache to use
 	var preferredCache string
@@ -107,7 +190,25 @@ func getMain(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	if len(source) > 1 {
+	if manifestLocation != "" {
+		destDir := "."
+		if len(args) == 1 {
+			destDir = args[0]
+		}
+		manifestGetMain(ctx, manifestLocation, resultsLocation, destDir, asJSON, client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation), client.WithCaches(caches...), client.WithChecksum(checksumType), client.WithResume(resume))
+		return
+	}
+	if signedManifestLocation != "" {
+		destDir := "."
+		if len(args) == 1 {
+			destDir = args[0]
+		}
+		datasetGetMain(ctx, signedManifestLocation, reportLocation, destDir, asJSON, client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation), client.WithCaches(caches...), client.WithResume(resume))
+		return
+	}
+
+	toObjectStorage := client.IsObjectStorageURL(dest)
+	if len(source) > 1 && !toStdout && !toObjectStorage {
 		if destStat, err := os.Stat(dest); err != nil {
 			log.Errorln("Destination does not exist")
 			os.Exit(1)
@@ -118,17 +219,52 @@ func getMain(cmd *cobra.Command, args []string) {
 	}
 
 	var result error
+	var transferResults []client.TransferResults
 	lastSrc := ""
 
+	// The daemon may not have the object storage credentials of the user
+	viaDaemon := !toStdout && !toObjectStorage && useTransferDaemon(cmd)
+	if viaDaemon {
+		log.Debugln("Submitting the transfers to the client transfer daemon at", param.Client_DaemonSocket.GetString())
+	}
+	cacheStrs := make([]string, 0, len(caches))
+	for _, cache := range caches {
+		cacheStrs = append(cacheStrs, cache.String())
+	}
+
 	for _, src := range source {
 		isRecursive, _ := cmd.Flags().GetBool("recursive")
-		_, result = client.DoGet(ctx, src, dest, isRecursive, client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation), client.WithCaches(caches...))
+		var srcResults []client.TransferResults
+		if viaDaemon {
+			srcResults, result = client.DoDaemonTransfer(ctx, client.DaemonTransferRequest{
+				Operation:     client.DaemonOperationGet,
+				RemoteUrl:     src,
+				LocalPath:     daemonLocalPath(dest),
+				Recursive:     isRecursive,
+				TokenLocation: daemonLocalPath(tokenLocation),
+				Caches:        cacheStrs,
+				Checksum:      checksumName,
+				ResumeJournal: daemonLocalPath(resumeJournal),
+				DisableResume: !resume,
+				Decompress:    decompress,
+			}, pb.callback)
+		} else {
+			srcResults, result = client.DoGet(ctx, src, dest, isRecursive, client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation), client.WithCaches(caches...), client.WithResumeJournal(resumeJournal), client.WithChecksum(checksumType), client.WithResume(resume), client.WithDecompress(decompress))
+		}
+		transferResults = append(transferResults, srcResults...)
 		if result != nil {
 			lastSrc = src
 			break
 		}
 	}
 
+	if asJSON {
+		jsonResult := newJSONResult("get")
+		jsonResult.addTransfers(transferResults)
+		jsonResult.finish(result)
+		return
+	}
+
 	// Exit with failure
 	if result != nil {
 		// Print the list of errors
@@ -0,0 +1,237 @@
+/***************************************************************
+*
+* Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+*
+* Licensed under the Apache License, Version 2.0 (the "License"); you
+* may not use this file except in compliance with the License.  You may
+* obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+***************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/error_codes"
+)
+
+type (
+	// The result of an object command, printed to stdout when --json is given.
+	// Fields may be added to this schema but are never renamed or removed
+	// without incrementing jsonResultVersion.
+	jsonCommandResult struct {
+		Version      int                 `json:"version"`
+		Command      string              `json:"command"`
+		Success      bool                `json:"success"`
+		Error        *jsonError          `json:"error,omitempty"`
+		Transfers    []jsonTransfer      `json:"transfers"`
+		Plan         []jsonSyncPlanEntry `json:"plan,omitempty"`
+		Deleted      []string            `json:"deleted,omitempty"`
+		SharingUrl   string              `json:"sharingUrl,omitempty"`
+		Verification *jsonVerification   `json:"verification,omitempty"`
+		Steps        []jsonJobStep       `json:"steps,omitempty"`
+	}
+
+	jsonError struct {
+		Message   string `json:"message"`
+		Retryable bool   `json:"retryable"`
+		// One of retryable, fatal, or auth
+		Class    string `json:"class"`
+		ExitCode int    `json:"exitCode"`
+		// The endpoints the failed transfer was attempted against
+		Endpoints []string `json:"endpoints,omitempty"`
+	}
+
+	// The outcome of transferring a single object
+	jsonTransfer struct {
+		Source          string        `json:"source"`
+		Destination     string        `json:"destination"`
+		Status          string        `json:"status"`
+		Bytes           int64         `json:"bytes"`
+		StartTime       time.Time     `json:"startTime"`
+		DurationSeconds float64       `json:"durationSeconds"`
+		Endpoints       []string      `json:"endpoints"`
+		Attempts        []jsonAttempt `json:"attempts"`
+		Error           string        `json:"error,omitempty"`
+	}
+
+	// A single attempt at a transfer against one endpoint
+	jsonAttempt struct {
+		Endpoint               string  `json:"endpoint"`
+		Bytes                  int64   `json:"bytes"`
+		DurationSeconds        float64 `json:"durationSeconds"`
+		TimeToFirstByteSeconds float64 `json:"timeToFirstByteSeconds"`
+		BusyWaitSeconds        float64 `json:"busyWaitSeconds,omitempty"`
+		ServerVersion          string  `json:"serverVersion,omitempty"`
+		Error                  string  `json:"error,omitempty"`
+	}
+
+	jsonSyncPlanEntry struct {
+		Action      string `json:"action"`
+		Source      string `json:"source,omitempty"`
+		Destination string `json:"destination"`
+		Size        int64  `json:"size"`
+		Reason      string `json:"reason"`
+	}
+
+	// The outcome of one step of a `pelican run` job; the step's transfers are
+	// also listed in the result's transfers
+	jsonJobStep struct {
+		Name            string   `json:"name"`
+		Action          string   `json:"action"`
+		Source          string   `json:"source"`
+		Destination     string   `json:"destination"`
+		Status          string   `json:"status"`
+		Attempts        int      `json:"attempts"`
+		Objects         int      `json:"objects"`
+		Bytes           int64    `json:"bytes"`
+		DurationSeconds float64  `json:"durationSeconds"`
+		Deleted         []string `json:"deleted,omitempty"`
+		Error           string   `json:"error,omitempty"`
+	}
+
+	jsonVerification struct {
+		Object       string `json:"object"`
+		LocalFile    string `json:"localFile"`
+		ChecksumType string `json:"checksumType,omitempty"`
+		Match        bool   `json:"match"`
+	}
+)
+
+const (
+	jsonResultVersion = 1
+
+	jsonStatusSuccess = "success"
+	jsonStatusFailure = "failure"
+)
+
+func addJSONFlag(flagSet *pflag.FlagSet) {
+	flagSet.Bool("json", false, "Print the results to stdout as JSON in a stable, machine-readable format")
+}
+
+func newJSONResult(command string) *jsonCommandResult {
+	return &jsonCommandResult{Version: jsonResultVersion, Command: command, Transfers: []jsonTransfer{}}
+}
+
+func newJSONTransfer(result client.TransferResults) jsonTransfer {
+	transfer := jsonTransfer{
+		Source:      result.Source,
+		Destination: result.Destination,
+		Status:      jsonStatusSuccess,
+		Bytes:       result.TransferredBytes,
+		StartTime:   result.TransferStartTime,
+		Endpoints:   []string{},
+		Attempts:    []jsonAttempt{},
+	}
+	if result.Error != nil {
+		transfer.Status = jsonStatusFailure
+		transfer.Error = result.Error.Error()
+	}
+	var endTime time.Time
+	for _, attempt := range result.Attempts {
+		jsonAttempt := jsonAttempt{
+			Endpoint:               attempt.Endpoint,
+			Bytes:                  attempt.TransferFileBytes,
+			DurationSeconds:        attempt.TransferTime.Seconds(),
+			TimeToFirstByteSeconds: attempt.TimeToFirstByte.Seconds(),
+			BusyWaitSeconds:        attempt.BusyWaitTime.Seconds(),
+			ServerVersion:          attempt.ServerVersion,
+		}
+		if attempt.Error != nil {
+			jsonAttempt.Error = attempt.Error.Error()
+		}
+		transfer.Attempts = append(transfer.Attempts, jsonAttempt)
+		transfer.Endpoints = append(transfer.Endpoints, attempt.Endpoint)
+		if attempt.TransferEndTime.After(endTime) {
+			endTime = attempt.TransferEndTime
+		}
+		if result.TransferStartTime.IsZero() {
+			transfer.DurationSeconds += attempt.TransferTime.Seconds()
+		}
+	}
+	if !result.TransferStartTime.IsZero() && endTime.After(result.TransferStartTime) {
+		transfer.DurationSeconds = endTime.Sub(result.TransferStartTime).Seconds()
+	}
+	return transfer
+}
+
+func (result *jsonCommandResult) addTransfers(results []client.TransferResults) {
+	for _, transferResult := range results {
+		result.Transfers = append(result.Transfers, newJSONTransfer(transferResult))
+	}
+}
+
+func (result *jsonCommandResult) addSyncPlan(plan *client.SyncPlan) {
+	for _, entry := range plan.Entries {
+		result.Plan = append(result.Plan, jsonSyncPlanEntry{
+			Action:      string(entry.Action),
+			Source:      entry.Source,
+			Destination: entry.Destination,
+			Size:        entry.Size,
+			Reason:      entry.Reason,
+		})
+	}
+}
+
+// The exit code of an object command that failed with err; 11 signals that
+// the failure is retryable
+func commandExitCode(err error) int {
+	var pe *error_codes.PelicanError
+	if errors.As(err, &pe) {
+		return pe.ExitCode()
+	}
+	if client.ShouldRetry(err) {
+		return 11
+	}
+	return 1
+}
+
+// Print the result to stdout and, if err is set, record it and exit with
+// the matching exit code
+func (result *jsonCommandResult) finish(err error) {
+	result.Success = err == nil
+	exitCode := 0
+	if err != nil {
+		message := err.Error()
+		var endpoints []string
+		var te *client.TransferErrors
+		if errors.As(err, &te) {
+			message = te.UserError()
+			endpoints = te.Endpoints()
+		}
+		exitCode = commandExitCode(err)
+		result.Error = &jsonError{
+			Message:   message,
+			Retryable: exitCode == 11,
+			Class:     client.ClassifyError(err).String(),
+			ExitCode:  exitCode,
+			Endpoints: endpoints,
+		}
+	}
+	data, marshalErr := json.Marshal(result)
+	if marshalErr != nil {
+		log.Errorln("Failed to marshal the command result to JSON:", marshalErr)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
@@ -0,0 +1,105 @@
+/***************************************************************
+*
+* Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+*
+* Licensed under the Apache License, Version 2.0 (the "License"); you
+* may not use this file except in compliance with the License.  You may
+* obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+***************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/error_codes"
+)
+
+func TestJSONTransferResult(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	result := client.TransferResults{
+		Source:            "pelican://example.com/foo/bar",
+		Destination:       "/tmp/bar",
+		TransferredBytes:  1024,
+		TransferStartTime: start,
+		Attempts: []client.TransferResult{
+			{
+				Number:          0,
+				Endpoint:        "cache-1.example.com:8443",
+				TransferTime:    time.Second,
+				TransferEndTime: start.Add(time.Second),
+				Error:           errors.New("connection reset"),
+			},
+			{
+				Number:            1,
+				Endpoint:          "cache-2.example.com:8443",
+				TransferFileBytes: 1024,
+				TimeToFirstByte:   250 * time.Millisecond,
+				TransferTime:      2 * time.Second,
+				TransferEndTime:   start.Add(3 * time.Second),
+				ServerVersion:     "7.11.0",
+			},
+		},
+	}
+
+	transfer := newJSONTransfer(result)
+	assert.Equal(t, jsonStatusSuccess, transfer.Status)
+	assert.Equal(t, int64(1024), transfer.Bytes)
+	assert.Equal(t, 3.0, transfer.DurationSeconds)
+	assert.Equal(t, []string{"cache-1.example.com:8443", "cache-2.example.com:8443"}, transfer.Endpoints)
+	require.Len(t, transfer.Attempts, 2)
+	assert.Equal(t, "connection reset", transfer.Attempts[0].Error)
+	assert.Equal(t, 0.25, transfer.Attempts[1].TimeToFirstByteSeconds)
+
+	result.Error = errors.New("transfer failed")
+	transfer = newJSONTransfer(result)
+	assert.Equal(t, jsonStatusFailure, transfer.Status)
+	assert.Equal(t, "transfer failed", transfer.Error)
+}
+
+func TestJSONResultSchema(t *testing.T) {
+	jsonResult := newJSONResult("get")
+	jsonResult.addTransfers([]client.TransferResults{{Source: "pelican://example.com/foo", Destination: "/tmp/foo"}})
+	data, err := json.Marshal(jsonResult)
+	require.NoError(t, err)
+
+	// Consumers rely on these key names; changing them requires a new schema version
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, float64(jsonResultVersion), decoded["version"])
+	assert.Equal(t, "get", decoded["command"])
+	assert.Contains(t, decoded, "success")
+	assert.NotContains(t, decoded, "error")
+	transfers := decoded["transfers"].([]interface{})
+	require.Len(t, transfers, 1)
+	transfer := transfers[0].(map[string]interface{})
+	for _, key := range []string{"source", "destination", "status", "bytes", "startTime", "durationSeconds", "endpoints", "attempts"} {
+		assert.Contains(t, transfer, key)
+	}
+
+	// Commands without transfers still emit an empty list
+	data, err = json.Marshal(newJSONResult("stat"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"transfers":[]`)
+}
+
+func TestCommandExitCode(t *testing.T) {
+	assert.Equal(t, 1, commandExitCode(errors.New("generic failure")))
+	assert.Equal(t, 11, commandExitCode(error_codes.NewTransfer_SlowTransferError(errors.New("too slow"))))
+}
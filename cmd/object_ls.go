@@ -24,6 +24,7 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/pkg/errors"
@@ -38,7 +39,17 @@ var (
 	lsCmd = &cobra.Command{
 		Use:   "ls {object}",
 		Short: "List objects in a namespace from a federation",
-		RunE:  listMain,
+		Long: `List objects in a namespace from a federation.
+
+The object may contain glob patterns (as understood by Go's path.Match) in any of
+its path components, in which case only the matching objects and collections are
+listed.  Quote the object so the shell does not expand the pattern, e.g.:
+
+    pelican object ls -l 'pelican://federation.example.org/prefix/dir/*.txt'
+
+With the long (-l) option, the size, modification time, and checksums (when the
+origin reports them) of each object are included.`,
+		RunE: listMain,
 	}
 )
 
@@ -55,8 +66,12 @@ func init() {
 
 func listMain(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
+	asJSON, _ := cmd.Flags().GetBool("json")
 	err := config.InitClient()
 	if err != nil {
+		if asJSON {
+			newJSONResult("ls").finish(err)
+		}
 		log.Errorln(err)
 
 		if client.IsRetryable(err) {
@@ -84,17 +99,20 @@ func listMain(cmd *cobra.Command, args []string) error {
 	long, _ := cmd.Flags().GetBool("long")
 	collectionOnly, _ := cmd.Flags().GetBool("collectionOnly")
 	objectOnly, _ := cmd.Flags().GetBool("objectonly")
-	asJSON, _ := cmd.Flags().GetBool("json")
 
 	if collectionOnly && objectOnly {
 		// If a user specifies collectionOnly and objectOnly, this means basic functionality (list both objects and directories) so just remove the flags
 		return errors.New("cannot specify both collectionOnly (-C) and object only (-O) flags, as they are mutually exclusive")
 	}
 
-	fileInfos, err := client.DoList(ctx, object, client.WithTokenLocation(tokenLocation))
+	fileInfos, err := client.DoList(ctx, object, client.WithTokenLocation(tokenLocation), client.WithListChecksums(long))
 
 	// Exit with failure
 	if err != nil {
+		// Listings print their own JSON on success; failures use the common schema
+		if asJSON {
+			newJSONResult("ls").finish(err)
+		}
 		// Print the list of errors
 		errMsg := err.Error()
 		var te *client.TransferErrors
@@ -137,7 +155,11 @@ func listMain(cmd *cobra.Command, args []string) error {
 		}
 		for _, info := range filteredInfos {
 			// If not json formats, just print out the information in a clean way
-			fmt.Fprintln(w, info.Name+"\t"+strconv.FormatInt(info.Size, 10)+"\t"+info.ModTime.Format("2006-01-02 15:04:05"))
+			checksums := []string{}
+			for _, alg := range sortedKeys(info.Checksums) {
+				checksums = append(checksums, alg+":"+info.Checksums[alg])
+			}
+			fmt.Fprintln(w, info.Name+"\t"+strconv.FormatInt(info.Size, 10)+"\t"+info.ModTime.Format("2006-01-02 15:04:05")+"\t"+strings.Join(checksums, ","))
 		}
 		w.Flush()
 	} else if asJSON {
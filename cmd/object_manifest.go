@@ -0,0 +1,165 @@
+/***************************************************************
+*
+* Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+*
+* Licensed under the Apache License, Version 2.0 (the "License"); you
+* may not use this file except in compliance with the License.  You may
+* obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+***************************************************************/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/client"
+)
+
+type (
+	// The results manifest written by `object get --results-manifest`, listing the
+	// outcome of every entry of the input manifest so that workflow managers can
+	// check which inputs were staged.  Like jsonCommandResult, fields may be added
+	// but are never renamed or removed without incrementing the version.
+	jsonManifestResults struct {
+		Version int                 `json:"version"`
+		Success bool                `json:"success"`
+		Entries []jsonManifestEntry `json:"entries"`
+	}
+
+	jsonManifestEntry struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+		Status      string `json:"status"`
+		Bytes       int64  `json:"bytes"`
+		// The verified checksum, as <type>:<hex value>, if the manifest listed one
+		Checksum string `json:"checksum,omitempty"`
+		Error    string `json:"error,omitempty"`
+		// The line of the input manifest the entry came from
+		Line int `json:"line"`
+	}
+)
+
+// Read a pre-staging manifest from a file, or from stdin if the location is "-"
+func readManifest(location string) ([]client.ManifestEntry, error) {
+	if location == client.StreamPath {
+		return client.ParseManifest(os.Stdin)
+	}
+	fp, err := os.Open(location)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the manifest")
+	}
+	defer fp.Close()
+	return client.ParseManifest(fp)
+}
+
+func newManifestResults(results []client.ManifestResult) *jsonManifestResults {
+	manifest := &jsonManifestResults{Version: jsonResultVersion, Success: true, Entries: make([]jsonManifestEntry, 0, len(results))}
+	for _, result := range results {
+		entry := jsonManifestEntry{
+			Source:      result.Entry.Source,
+			Destination: result.LocalPath,
+			Status:      jsonStatusSuccess,
+			Checksum:    result.Checksum,
+			Line:        result.Entry.Line,
+		}
+		for _, transfer := range result.Transfers {
+			entry.Bytes += transfer.TransferredBytes
+		}
+		if result.Err != nil {
+			entry.Status = jsonStatusFailure
+			entry.Error = result.Err.Error()
+			manifest.Success = false
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+	return manifest
+}
+
+// Write the results manifest, replacing any existing file atomically so a workflow
+// manager never sees a partial one
+func writeResultsManifest(location string, results []client.ManifestResult) error {
+	return writeJSONFile(location, newManifestResults(results), "results manifest")
+}
+
+// Write a value as indented JSON, replacing any existing file atomically
+func writeJSONFile(location string, value interface{}, what string) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal the %s", what)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(location), filepath.Base(location)+".tmp*")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the %s", what)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err = tmpFile.Write(append(data, '\n')); err != nil {
+		tmpFile.Close()
+		return errors.Wrapf(err, "failed to write the %s", what)
+	}
+	if err = tmpFile.Close(); err != nil {
+		return errors.Wrapf(err, "failed to write the %s", what)
+	}
+	return errors.Wrapf(os.Rename(tmpFile.Name(), location), "failed to write the %s", what)
+}
+
+// Download every object listed in a manifest into destDir, writing the outcome of
+// each to the results manifest (if requested) before exiting
+func manifestGetMain(ctx context.Context, manifestLocation string, resultsLocation string, destDir string, asJSON bool, options ...client.TransferOption) {
+	entries, err := readManifest(manifestLocation)
+	if err == nil && len(entries) == 0 {
+		err = errors.Errorf("the manifest %s lists no objects", manifestLocation)
+	}
+	if err != nil {
+		if asJSON {
+			newJSONResult("get").finish(err)
+		}
+		log.Errorln(err)
+		os.Exit(1)
+	}
+	if destStat, err := os.Stat(destDir); err != nil || !destStat.IsDir() {
+		err = errors.Errorf("destination %s is not a directory", destDir)
+		if asJSON {
+			newJSONResult("get").finish(err)
+		}
+		log.Errorln(err)
+		os.Exit(1)
+	}
+
+	results, result := client.DoManifestGet(ctx, entries, destDir, options...)
+	if resultsLocation != "" && len(results) > 0 {
+		if err := writeResultsManifest(resultsLocation, results); err != nil {
+			log.Errorln(err)
+			if result == nil {
+				result = err
+			}
+		}
+	}
+
+	if asJSON {
+		jsonResult := newJSONResult("get")
+		for _, manifestResult := range results {
+			jsonResult.addTransfers(manifestResult.Transfers)
+		}
+		jsonResult.finish(result)
+		return
+	}
+	if result != nil {
+		log.Errorln(result)
+		os.Exit(commandExitCode(result))
+	}
+}
@@ -20,6 +20,7 @@ package main
 
 import (
 	"os"
+	"slices"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -34,7 +35,15 @@ var (
 	putCmd = &cobra.Command{
 		Use:   "put {source ...} {destination}",
 		Short: "Send a file to a Pelican federation",
-		Run:   putMain,
+		Long: `Send a file to a Pelican federation.
+
+If the source is "-", the object is read from stdin and uploaded as it is read, so
+the output of another program can be piped into the federation.
+
+If the source is an s3://bucket/key URL, the object is streamed from S3 into the
+federation without being staged on the local disk.  The S3 service and credentials are
+configured with the Client.S3* parameters or the usual AWS environment variables.`,
+		Run: putMain,
 	}
 )
 
@@ -42,14 +51,24 @@ func init() {
 	flagSet := putCmd.Flags()
 	flagSet.StringP("token", "t", "", "Token file to use for transfer")
 	flagSet.BoolP("recursive", "r", false, "Recursively upload a collection.  Forces methods to only be http to get the freshest collection contents")
+	addTransferSchedulingFlags(flagSet, "upload")
+	addJSONFlag(flagSet)
+	addProgressFlags(flagSet)
+	flagSet.String("checksum", "", "Verify each uploaded object with the given checksum type (sha256, md5, or adler32); the transfer fails if the checksums do not match")
+	flagSet.String("resume-journal", "", "Journal file recording the progress of a recursive upload.  Rerunning an interrupted transfer with the same journal skips objects that were already transferred, and directories whose contents were all transferred and whose modification time is unchanged")
+	flagSet.String("skip-existing", "none", "Skip objects already present at the destination that match the local file, compared by one of exist, size, mtime, or checksum")
 	objectCmd.AddCommand(putCmd)
 }
 
 func putMain(cmd *cobra.Command, args []string) {
 	ctx := cmd.Context()
 
+	asJSON, _ := cmd.Flags().GetBool("json")
 	err := config.InitClient()
 	if err != nil {
+		if asJSON {
+			newJSONResult("put").finish(err)
+		}
 		log.Errorln(err)
 		if client.IsRetryable(err) {
 			log.Errorln("Errors are retryable")
@@ -61,15 +80,28 @@ func putMain(cmd *cobra.Command, args []string) {
 
 	// Set the progress bars to the command line option
 	tokenLocation, _ := cmd.Flags().GetString("token")
+	resumeJournal, _ := cmd.Flags().GetString("resume-journal")
+	checksumName, _ := cmd.Flags().GetString("checksum")
+	checksumType, err := client.ParseChecksumType(checksumName)
+	if err != nil {
+		log.Errorln(err)
+		os.Exit(1)
+	}
+	skipExisting, _ := cmd.Flags().GetString("skip-existing")
+	syncLevel, err := client.ParseSyncLevel(skipExisting)
+	if err != nil {
+		log.Errorln("Invalid --skip-existing value:", err)
+		os.Exit(1)
+	}
+	if err := applyTransferScheduling(cmd); err != nil {
+		log.Errorln(err)
+		os.Exit(1)
+	}
 
 	pb := newProgressBar()
 	defer pb.shutdown()
 
-	// Check if the program was executed from a terminal
-	// https://rosettacode.org/wiki/Check_output_device_is_a_terminal#Go
-	if fileInfo, _ := os.Stdout.Stat(); (fileInfo.Mode()&os.ModeCharDevice) != 0 && param.Logging_LogLocation.GetString() == "" && !param.Logging_DisableProgressBars.GetBool() {
-		pb.launchDisplay(ctx)
-	}
+	pb.launchForCommand(ctx, cmd, asJSON)
 
 	log.Debugln("Len of source:", len(args))
 	if len(args) < 2 {
@@ -87,17 +119,46 @@ func putMain(cmd *cobra.Command, args []string) {
 	log.Debugln("Destination:", dest)
 
 	var result error
+	var transferResults []client.TransferResults
 	lastSrc := ""
 
+	// The daemon may not have the object storage credentials of the user
+	viaDaemon := !slices.Contains(source, client.StreamPath) && !slices.ContainsFunc(source, client.IsObjectStorageURL) && useTransferDaemon(cmd)
+	if viaDaemon {
+		log.Debugln("Submitting the transfers to the client transfer daemon at", param.Client_DaemonSocket.GetString())
+	}
+
 	for _, src := range source {
 		isRecursive, _ := cmd.Flags().GetBool("recursive")
-		_, result = client.DoPut(ctx, src, dest, isRecursive, client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation))
+		var srcResults []client.TransferResults
+		if viaDaemon {
+			srcResults, result = client.DoDaemonTransfer(ctx, client.DaemonTransferRequest{
+				Operation:     client.DaemonOperationPut,
+				RemoteUrl:     dest,
+				LocalPath:     daemonLocalPath(src),
+				Recursive:     isRecursive,
+				TokenLocation: daemonLocalPath(tokenLocation),
+				Checksum:      checksumName,
+				ResumeJournal: daemonLocalPath(resumeJournal),
+				SkipExisting:  skipExisting,
+			}, pb.callback)
+		} else {
+			srcResults, result = client.DoPut(ctx, src, dest, isRecursive, client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation), client.WithResumeJournal(resumeJournal), client.WithChecksum(checksumType), client.WithSynchronize(syncLevel))
+		}
+		transferResults = append(transferResults, srcResults...)
 		if result != nil {
 			lastSrc = src
 			break
 		}
 	}
 
+	if asJSON {
+		jsonResult := newJSONResult("put")
+		jsonResult.addTransfers(transferResults)
+		jsonResult.finish(result)
+		return
+	}
+
 	// Exit with failure
 	if result != nil {
 		// Print the list of errors
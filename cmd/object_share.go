@@ -41,36 +41,50 @@ Note the sharing is based on prefixes; all object names matching the prefix will
 func init() {
 	flagSet := shareCmd.Flags()
 	flagSet.Bool("write", false, "Allow writes to the target prefix")
+	addJSONFlag(flagSet)
 	objectCmd.AddCommand(shareCmd)
 }
 
 func shareMain(cmd *cobra.Command, args []string) error {
+	sharingUrl, err := createSharingUrl(cmd, args)
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		jsonResult := newJSONResult("share")
+		jsonResult.SharingUrl = sharingUrl
+		jsonResult.finish(err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(sharingUrl)
+	return nil
+}
 
+func createSharingUrl(cmd *cobra.Command, args []string) (string, error) {
 	err := config.InitClient()
 	if err != nil {
-		return errors.Wrap(err, "Failed to initialize the client")
+		return "", errors.Wrap(err, "Failed to initialize the client")
 	}
 
 	isWrite, err := cmd.Flags().GetBool("write")
 	if err != nil {
-		return errors.Wrap(err, "Unable to get the value of the --write flag")
+		return "", errors.Wrap(err, "Unable to get the value of the --write flag")
 	}
 
 	if len(args) == 0 {
-		return errors.New("A URL must be specified to share")
+		return "", errors.New("A URL must be specified to share")
 	}
 
 	objectUrl, err := url.Parse(args[0])
 	if err != nil {
-		return errors.Wrapf(err, "Failed to parse '%v' as a URL", args[0])
+		return "", errors.Wrapf(err, "Failed to parse '%v' as a URL", args[0])
 	}
 
 	token, err := client.CreateSharingUrl(cmd.Context(), objectUrl, isWrite)
 	if err != nil {
-		return errors.Wrapf(err, "Failed to create a sharing URL for %v", objectUrl.String())
+		return "", errors.Wrapf(err, "Failed to create a sharing URL for %v", objectUrl.String())
 	}
 
 	objectUrl.RawQuery = "authz=" + token
-	fmt.Println(objectUrl.String())
-	return nil
+	return objectUrl.String(), nil
 }
@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -35,7 +36,12 @@ var (
 	statCmd = &cobra.Command{
 		Use:   "stat {object}",
 		Short: "Stat objects in a namespace from a federation",
-		Run:   statMain,
+		Long: `Stat objects in a namespace from a federation.
+
+In addition to the size and modification time, the output includes any checksums
+and custom metadata reported by the object server, as well as which caches in the
+federation currently hold a copy of the object.`,
+		Run: statMain,
 	}
 )
 
@@ -46,10 +52,24 @@ func init() {
 	objectCmd.AddCommand(statCmd)
 }
 
+// Return the keys of the map in sorted order, for stable output
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func statMain(cmd *cobra.Command, args []string) {
 	ctx := cmd.Context()
+	jsn, _ := cmd.Flags().GetBool("json")
 	err := config.InitClient()
 	if err != nil {
+		if jsn {
+			newJSONResult("stat").finish(err)
+		}
 		log.Errorln(err)
 
 		if client.IsRetryable(err) {
@@ -61,7 +81,6 @@ func statMain(cmd *cobra.Command, args []string) {
 	}
 
 	tokenLocation, _ := cmd.Flags().GetString("token")
-	jsn, _ := cmd.Flags().GetBool("json")
 
 	if len(args) < 1 {
 		log.Errorln("No object provided")
@@ -75,10 +94,14 @@ func statMain(cmd *cobra.Command, args []string) {
 
 	log.Debugln("Object:", object)
 
-	statInfo, err := client.DoStat(ctx, object, client.WithTokenLocation(tokenLocation))
+	statInfo, err := client.DoStatDetailed(ctx, object, client.WithTokenLocation(tokenLocation))
 
 	// Exit with failure
 	if err != nil {
+		// Stat prints its own JSON on success; failures use the common schema
+		if jsn {
+			newJSONResult("stat").finish(err)
+		}
 		// Print the list of errors
 		errMsg := err.Error()
 		var te *client.TransferErrors
@@ -108,6 +131,28 @@ func statMain(cmd *cobra.Command, args []string) {
 		fmt.Println("Size:", statInfo.Size)
 		fmt.Println("ModTime:", statInfo.ModTime)
 		fmt.Println("IsCollection:", statInfo.IsCollection)
+		if len(statInfo.Checksums) > 0 {
+			fmt.Println("Checksums:")
+			for _, alg := range sortedKeys(statInfo.Checksums) {
+				fmt.Printf("  %s: %s\n", alg, statInfo.Checksums[alg])
+			}
+		}
+		if len(statInfo.Metadata) > 0 {
+			fmt.Println("Metadata:")
+			for _, key := range sortedKeys(statInfo.Metadata) {
+				fmt.Printf("  %s: %s\n", key, statInfo.Metadata[key])
+			}
+		}
+		if len(statInfo.Availability) > 0 {
+			fmt.Println("Cache Availability:")
+			for _, cache := range statInfo.Availability {
+				status := "not cached"
+				if cache.Available {
+					status = "cached"
+				}
+				fmt.Printf("  %s (%s): %s\n", cache.Name, cache.URL, status)
+			}
+		}
 		return
 	}
 }
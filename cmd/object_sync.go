@@ -19,6 +19,7 @@
 package main
 
 import (
+	"fmt"
 	"net/url"
 	"os"
 	"strings"
@@ -30,7 +31,6 @@ import (
 	"github.com/pelicanplatform/pelican/client"
 	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/error_codes"
-	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/utils"
 )
 
@@ -38,7 +38,14 @@ var (
 	syncCmd = &cobra.Command{
 		Use:   "sync {source ...} {destination}",
 		Short: "Sync a directory to or from a Pelican federation",
-		Run:   syncMain,
+		Long: `Sync a directory to or from a Pelican federation.
+
+Only the objects that differ between the source and destination are transferred.  By
+default, objects are compared by size; use --compare to also compare modification
+times (mtime) or checksums reported by the origin (checksum).  With --delete, objects
+at the destination that are not present at the source are removed.  Use --dry-run to
+print the planned transfers and deletions without performing them.`,
+		Run: syncMain,
 	}
 )
 
@@ -46,6 +53,11 @@ func init() {
 	flagSet := syncCmd.Flags()
 	flagSet.StringP("cache", "c", "", "Cache to use")
 	flagSet.StringP("token", "t", "", "Token file to use for transfer")
+	flagSet.String("compare", "size", "How to detect changed objects: size, mtime (size and modification time), or checksum (size and checksum)")
+	flagSet.Bool("delete", false, "Delete objects at the destination that are not present at the source")
+	flagSet.Bool("dry-run", false, "Print the planned transfers and deletions without performing them")
+	addJSONFlag(flagSet)
+	addProgressFlags(flagSet)
 	objectCmd.AddCommand(syncCmd)
 }
 
@@ -84,8 +96,12 @@ func isPelicanUrl(input string) bool {
 func syncMain(cmd *cobra.Command, args []string) {
 	ctx := cmd.Context()
 
+	asJSON, _ := cmd.Flags().GetBool("json")
 	err := config.InitClient()
 	if err != nil {
+		if asJSON {
+			newJSONResult("sync").finish(err)
+		}
 		log.Errorln(err)
 
 		if client.IsRetryable(err) {
@@ -101,11 +117,7 @@ func syncMain(cmd *cobra.Command, args []string) {
 	pb := newProgressBar()
 	defer pb.shutdown()
 
-	// Check if the program was executed from a terminal
-	// https://rosettacode.org/wiki/Check_output_device_is_a_terminal#Go
-	if fileInfo, _ := os.Stdout.Stat(); (fileInfo.Mode()&os.ModeCharDevice) != 0 && param.Logging_LogLocation.GetString() == "" && !param.Logging_DisableProgressBars.GetBool() {
-		pb.launchDisplay(ctx)
-	}
+	pb.launchForCommand(ctx, cmd, asJSON)
 
 	if len(args) < 2 {
 		log.Errorln("No source or destination to sync")
@@ -168,35 +180,67 @@ func syncMain(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	compare, _ := cmd.Flags().GetString("compare")
+	syncLevel, err := client.ParseSyncLevel(compare)
+	if err != nil {
+		log.Errorln(err)
+		os.Exit(1)
+	}
+	deleteExtra, _ := cmd.Flags().GetBool("delete")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if deleteExtra && len(sources) > 1 {
+		log.Errorln("The --delete option may only be used with a single source")
+		os.Exit(1)
+	}
+	options := []client.TransferOption{
+		client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation),
+		client.WithCaches(caches...), client.WithSynchronize(syncLevel), client.WithSyncDelete(deleteExtra),
+	}
+
 	lastSrc := ""
+	jsonResult := newJSONResult("sync")
 
-	if doDownload {
-		for _, src := range sources {
-			if _, err = client.DoGet(ctx, src, dest, true,
-				client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation),
-				client.WithCaches(caches...), client.WithSynchronize(client.SyncSize)); err != nil {
-				lastSrc = src
-				break
-			}
-		}
-	} else {
-		for _, src := range sources {
+	for _, src := range sources {
+		if !doDownload {
 			if srcStat, err := os.Stat(src); err != nil {
 				log.Errorln("Source: " + src + " does not exist")
 				os.Exit(1)
 			} else if !srcStat.IsDir() && string(dest[len(dest)-1]) == `/` {
 				log.Warningln("Destination: " + dest + " ends with '/', but the source is a file. If the destination does not exist, it will be treated as an object, not a collection.")
 			}
+		}
 
-			if _, err = client.DoPut(ctx, src, dest, true,
-				client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation),
-				client.WithCaches(caches...), client.WithSynchronize(client.SyncSize)); err != nil {
-				lastSrc = src
-				break
+		var plan *client.SyncPlan
+		if plan, err = client.PlanSync(ctx, src, dest, options...); err != nil {
+			lastSrc = src
+			break
+		}
+		jsonResult.addSyncPlan(plan)
+		if dryRun {
+			if !asJSON {
+				printSyncPlan(plan)
+			}
+			continue
+		}
+		var transferResults []client.TransferResults
+		transferResults, err = client.ExecuteSyncPlan(ctx, plan, options...)
+		jsonResult.addTransfers(transferResults)
+		if err != nil {
+			lastSrc = src
+			break
+		}
+		for _, entry := range plan.Entries {
+			if entry.Action == client.SyncActionDelete {
+				jsonResult.Deleted = append(jsonResult.Deleted, entry.Destination)
 			}
 		}
 	}
 
+	if asJSON {
+		jsonResult.finish(err)
+		return
+	}
+
 	// Exit with failure
 	if err != nil {
 		// Print the list of errors
@@ -220,3 +264,21 @@ func syncMain(cmd *cobra.Command, args []string) {
 		}
 	}
 }
+
+// Print the transfers and deletions a sync would perform
+func printSyncPlan(plan *client.SyncPlan) {
+	transfers, deletions := 0, 0
+	var transferBytes int64
+	for _, entry := range plan.Entries {
+		switch entry.Action {
+		case client.SyncActionTransfer:
+			transfers++
+			transferBytes += entry.Size
+			fmt.Printf("transfer %s -> %s (%s)\n", entry.Source, entry.Destination, entry.Reason)
+		case client.SyncActionDelete:
+			deletions++
+			fmt.Printf("delete %s (%s)\n", entry.Destination, entry.Reason)
+		}
+	}
+	fmt.Printf("%d objects to transfer (%s), %d to delete, %d unchanged\n", transfers, client.ByteCountSI(transferBytes), deletions, plan.Unchanged)
+}
@@ -0,0 +1,112 @@
+/***************************************************************
+*
+* Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+*
+* Licensed under the Apache License, Version 2.0 (the "License"); you
+* may not use this file except in compliance with the License.  You may
+* obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+*
+***************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/error_codes"
+)
+
+var (
+	verifyCmd = &cobra.Command{
+		Use:   "verify {object} {local file}",
+		Short: "Verify a local file against the checksum of an object in a federation",
+		Long: `Verify a local file against the checksum of an object in a federation.
+
+The checksum of the local file is compared against the checksum reported by the
+object server.  If no checksum type is given, the first one the server reports
+is used, in order of preference: sha256, md5, adler32.  The command fails if the
+checksums do not match or the server does not report a checksum.`,
+		Args: cobra.ExactArgs(2),
+		Run:  verifyMain,
+	}
+)
+
+func init() {
+	flagSet := verifyCmd.Flags()
+	flagSet.StringP("token", "t", "", "Token file to use for the checksum query")
+	flagSet.String("checksum", "", "Checksum type to verify (sha256, md5, or adler32)")
+	addJSONFlag(flagSet)
+	objectCmd.AddCommand(verifyCmd)
+}
+
+func verifyMain(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+	asJSON, _ := cmd.Flags().GetBool("json")
+	err := config.InitClient()
+	if err != nil {
+		if asJSON {
+			newJSONResult("verify").finish(err)
+		}
+		log.Errorln(err)
+
+		if client.IsRetryable(err) {
+			log.Errorln("Errors are retryable")
+			os.Exit(11)
+		} else {
+			os.Exit(1)
+		}
+	}
+
+	tokenLocation, _ := cmd.Flags().GetString("token")
+	checksumName, _ := cmd.Flags().GetString("checksum")
+	checksumType, err := client.ParseChecksumType(checksumName)
+	if err != nil {
+		log.Errorln(err)
+		os.Exit(1)
+	}
+	object := args[0]
+	localFile := args[1]
+
+	log.Debugln("Object:", object)
+	log.Debugln("Local file:", localFile)
+
+	verified, err := client.DoVerify(ctx, object, localFile, checksumType, client.WithTokenLocation(tokenLocation))
+	if asJSON {
+		jsonResult := newJSONResult("verify")
+		jsonResult.Verification = &jsonVerification{Object: object, LocalFile: localFile, Match: err == nil}
+		if err == nil {
+			jsonResult.Verification.ChecksumType = verified.String()
+		}
+		jsonResult.finish(err)
+		return
+	}
+	if err != nil {
+		var pe *error_codes.PelicanError
+		if errors.As(err, &pe) {
+			log.Errorln("Failure verifying " + localFile + ": " + pe.Error())
+			os.Exit(pe.ExitCode())
+		}
+		log.Errorln("Failure verifying " + localFile + ": " + err.Error())
+		if client.ShouldRetry(err) {
+			log.Errorln("Errors are retryable")
+			os.Exit(11)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("%s: %s checksum matches %s\n", localFile, verified.String(), object)
+}
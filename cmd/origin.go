@@ -77,12 +77,15 @@ https://jwt.io/introduction.
 Additional profiles that expand on JWT are supported. They include scitokens2 and
 wlcg. For more information about these profiles, see https://scitokens.org/technical_docs/Claims
 and https://github.com/WLCG-AuthZ-WG/common-jwt-profile/blob/master/profile.md, respectively`,
-		RunE: cliTokenCreate,
+		PreRunE: checkTokenCreateFlags,
+		RunE:    cliTokenCreate,
 	}
 
 	originTokenVerifyCmd = &cobra.Command{
-		Use:   "verify",
+		Use:   "verify [token]",
 		Short: "Verify a Pelican origin token",
+		Long:  tokenVerifyLong,
+		Args:  cobra.MaximumNArgs(1),
 		RunE:  verifyToken,
 	}
 )
@@ -176,52 +179,9 @@ instead.
 	originCmd.AddCommand(originTokenCmd)
 	originTokenCmd.AddCommand(originTokenCreateCmd)
 	originTokenCmd.PersistentFlags().String("profile", "wlcg", "Passing a profile ensures the token adheres to the profile's requirements. Accepted values are scitokens2 and wlcg")
-	originTokenCreateCmd.Flags().Int("lifetime", 1200, "The lifetime of the token, in seconds.")
-	originTokenCreateCmd.Flags().StringSlice("audience", []string{}, "The token's intended audience.")
-	originTokenCreateCmd.Flags().String("subject", "", "The token's subject.")
-	originTokenCreateCmd.Flags().StringSlice("scope", []string{}, "Scopes for granting fine-grained permissions to the token.")
-	originTokenCreateCmd.Flags().StringSlice("claim", []string{}, "Additional token claims. A claim must be of the form <claim name>=<value>")
-	originTokenCreateCmd.Flags().String("issuer", "", "The URL of the token's issuer. If not provided, the tool will attempt to find one in the configuration file.")
-	if err := viper.BindPFlag("Server.IssuerUrl", originTokenCreateCmd.Flags().Lookup("issuer")); err != nil {
-		panic(err)
-	}
-	originTokenCreateCmd.Flags().String("private-key", "", "Filepath designating the location of the private key in PEM format to be used for signing, if different from the origin's default.")
-	if err := viper.BindPFlag("IssuerKey", originTokenCreateCmd.Flags().Lookup("private-key")); err != nil {
-		panic(err)
-	}
+	addTokenCreateFlags(originTokenCreateCmd)
 	originTokenCmd.AddCommand(originTokenVerifyCmd)
-
-	// A pre-run hook to enforce flags specific to each profile
-	originTokenCreateCmd.PreRun = func(cmd *cobra.Command, args []string) {
-		profile, _ := cmd.Flags().GetString("profile")
-		reqFlags := []string{}
-		reqSlices := []string{}
-		switch profile {
-		case "wlcg":
-			reqFlags = []string{"subject"}
-			reqSlices = []string{"audience"}
-		case "scitokens2":
-			reqSlices = []string{"audience", "scope"}
-		}
-
-		shouldCancel := false
-		for _, flag := range reqFlags {
-			if val, _ := cmd.Flags().GetString(flag); val == "" {
-				fmt.Printf("The --%s flag must be populated for the scitokens profile\n", flag)
-				shouldCancel = true
-			}
-		}
-		for _, flag := range reqSlices {
-			if slice, _ := cmd.Flags().GetStringSlice(flag); len(slice) == 0 {
-				fmt.Printf("The --%s flag must be populated for the scitokens profile\n", flag)
-				shouldCancel = true
-			}
-		}
-
-		if shouldCancel {
-			os.Exit(1)
-		}
-	}
+	addTokenVerifyFlags(originTokenVerifyCmd)
 
 	originCmd.AddCommand(originUiCmd)
 	originUiCmd.AddCommand(originUiResetCmd)
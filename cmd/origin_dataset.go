@@ -0,0 +1,86 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+var originDatasetManifestCmd = &cobra.Command{
+	Use:   "dataset-manifest {dataset URL} {local directory}",
+	Short: "Create a signed manifest of a dataset exported by the origin",
+	Long: `Create a manifest listing the path, size, and SHA-256 checksum of every file of a
+dataset, signed with the origin's issuer key:
+Usage: pelican origin dataset-manifest [FLAGS] {dataset URL} {local directory}
+E.g. pelican origin dataset-manifest -o run42.manifest osdf:///ospool/data/run42 /exports/data/run42
+
+The local directory is the dataset's copy on the origin's storage, and the dataset
+URL is where the federation serves it.  Clients download the dataset with
+"pelican object get --signed-manifest", which checks that the manifest was signed by
+an issuer of the dataset's namespace and that each file matches it.`,
+	Args: cobra.ExactArgs(2),
+	RunE: createDatasetManifest,
+}
+
+func init() {
+	originDatasetManifestCmd.Flags().StringP("output", "o", "", "Write the signed manifest to this file rather than stdout")
+	originCmd.AddCommand(originDatasetManifestCmd)
+}
+
+func createDatasetManifest(cmd *cobra.Command, args []string) error {
+	// The origin's configuration says where its issuer key lives
+	if err := config.InitServer(context.Background(), server_structs.OriginType); err != nil {
+		return errors.Wrap(err, "cannot sign the manifest, failed to initialize configuration")
+	}
+	manifest, err := client.NewDatasetManifest(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if manifest.Issuer, err = config.GetServerIssuerURL(); err != nil {
+		return errors.Wrap(err, "failed to determine the origin's issuer")
+	}
+	key, err := config.GetIssuerPrivateJWK()
+	if err != nil {
+		return errors.Wrap(err, "failed to load the origin's issuer key")
+	}
+	signed, err := client.SignDatasetManifest(manifest, key)
+	if err != nil {
+		return err
+	}
+
+	output, _ := cmd.Flags().GetString("output")
+	if output == "" {
+		fmt.Println(string(signed))
+		return nil
+	}
+	if err := os.WriteFile(output, append(signed, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "failed to write the signed manifest")
+	}
+	fmt.Fprintf(os.Stderr, "Signed the manifest of the %d files of %s\n", len(manifest.Files), manifest.Dataset)
+	return nil
+}
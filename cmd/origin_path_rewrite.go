@@ -0,0 +1,122 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+var (
+	originPathRewriteCmd = &cobra.Command{
+		Use:   "path-rewrite",
+		Short: "Inspect the origin's path rewrite rules",
+	}
+
+	originPathRewriteTestCmd = &cobra.Command{
+		Use:   "test [flags] path...",
+		Short: "Show how the origin's path rewrite rules rewrite federation paths",
+		Long: `Evaluate the origin's path rewrite rules (Origin.PathRewrites) against one or more
+federation paths without contacting any server, printing each rule that applies, the
+rewritten path, and where the object is found in the origin's exports.
+
+Candidate rules can be checked before they are deployed by passing a YAML file holding
+a list of rules in the same format as Origin.PathRewrites with --rules.
+
+The command fails if any of the paths is rewritten outside of the origin's exports.`,
+		Args:         cobra.MinimumNArgs(1),
+		RunE:         testPathRewrites,
+		SilenceUsage: true,
+	}
+)
+
+func loadPathRewriter(rulesFile string) (*server_utils.PathRewriter, error) {
+	if rulesFile == "" {
+		return server_utils.GetOriginPathRewriter()
+	}
+	contents, err := os.ReadFile(rulesFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the rules file")
+	}
+	var rules []server_utils.PathRewriteRule
+	if err := yaml.Unmarshal(contents, &rules); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the rules in %s", rulesFile)
+	}
+	return server_utils.NewPathRewriter(rules)
+}
+
+func testPathRewrites(cmd *cobra.Command, args []string) error {
+	rulesFile, _ := cmd.Flags().GetString("rules")
+	asJson, _ := cmd.Flags().GetBool("json")
+	rewriter, err := loadPathRewriter(rulesFile)
+	if err != nil {
+		return err
+	}
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		return errors.Wrap(err, "failed to load the origin's exports")
+	}
+
+	results := make([]server_utils.PathRewriteResult, 0, len(args))
+	failed := 0
+	for _, fedPath := range args {
+		result, err := rewriter.Resolve(fedPath, exports)
+		if err != nil {
+			failed++
+		}
+		results = append(results, result)
+		if asJson {
+			continue
+		}
+		fmt.Println(result.FederationPath)
+		for _, step := range result.Steps {
+			fmt.Printf("  rule %d (%s): %s -> %s\n", step.Rule, step.Match, step.Before, step.After)
+		}
+		if err != nil {
+			fmt.Printf("  => %s: not in any export\n", result.RewrittenPath)
+		} else {
+			fmt.Printf("  => %s (export %s, stored at %s)\n", result.RewrittenPath, result.Export.FederationPrefix, result.StoragePath)
+		}
+	}
+	if asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d paths are rewritten outside of the origin's exports", failed, len(args))
+	}
+	return nil
+}
+
+func init() {
+	originCmd.AddCommand(originPathRewriteCmd)
+	originPathRewriteCmd.AddCommand(originPathRewriteTestCmd)
+	originPathRewriteTestCmd.Flags().String("rules", "", "A YAML file with a list of rules to evaluate instead of Origin.PathRewrites")
+	originPathRewriteTestCmd.Flags().Bool("json", false, "Print the results as JSON")
+}
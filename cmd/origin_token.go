@@ -20,12 +20,17 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/param"
@@ -33,6 +38,78 @@ import (
 	"github.com/pelicanplatform/pelican/token"
 )
 
+const tokenVerifyLong = `Decode a JSON web token (JWT) and check that it is valid:
+Usage: pelican token verify [FLAGS] [token]
+E.g. pelican token verify --audience https://my-origin.org:8443 --scope storage.read:/foo/bar "$(cat my.tok)"
+
+The token is read from standard input if it isn't given as an argument or is "-".
+Its header and claims are printed along with any problems found with it, and the
+command fails if there are any.
+
+The token's signature is checked against the public keys of its issuer.  If an
+issuer is given with --issuer, the token must have been issued by it; otherwise, if
+a federation is configured, the token must have been issued by the federation
+(e.g. a token minted by the director or registry).  Pass --profile to also check
+the token has the claims required by the scitokens2 or wlcg profile.`
+
+// Add the flags shared by the token creation commands
+func addTokenCreateFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("lifetime", 1200, "The lifetime of the token, in seconds.")
+	cmd.Flags().StringSlice("audience", []string{}, "The token's intended audience.")
+	cmd.Flags().String("subject", "", "The token's subject.")
+	cmd.Flags().StringSlice("scope", []string{}, "Scopes for granting fine-grained permissions to the token.")
+	cmd.Flags().StringSlice("claim", []string{}, "Additional token claims. A claim must be of the form <claim name>=<value>")
+	cmd.Flags().String("issuer", "", "The URL of the token's issuer. If not provided, the tool will attempt to find one in the configuration file.")
+	cmd.Flags().String("private-key", "", "Filepath designating the location of the private key in PEM format to be used for signing, if different from the origin's default.")
+}
+
+// Add the flags of the token verification commands
+func addTokenVerifyFlags(cmd *cobra.Command) {
+	cmd.Flags().String("issuer", "", "The URL of the issuer the token must come from. Defaults to the federation's issuer, if a federation is configured.")
+	cmd.Flags().StringSlice("audience", []string{}, "The token must be intended for one of these audiences.")
+	cmd.Flags().StringSlice("scope", []string{}, "Scopes the token must grant, e.g. storage.read:/foo/bar.")
+	cmd.Flags().Duration("skew", time.Minute, "The clock skew to allow when checking the token's lifetime.")
+}
+
+// Bind the issuer flags to their configuration parameters and enforce the flags
+// each profile requires.  The flags are bound here rather than at startup so that
+// the commands sharing them don't override each other's bindings.
+func checkTokenCreateFlags(cmd *cobra.Command, args []string) error {
+	if err := viper.BindPFlag("Server.IssuerUrl", cmd.Flags().Lookup("issuer")); err != nil {
+		return err
+	}
+	if err := viper.BindPFlag("IssuerKey", cmd.Flags().Lookup("private-key")); err != nil {
+		return err
+	}
+
+	profile, _ := cmd.Flags().GetString("profile")
+	reqFlags := []string{}
+	reqSlices := []string{}
+	switch profile {
+	case string(token.TokenProfileWLCG):
+		reqFlags = []string{"subject"}
+		reqSlices = []string{"audience"}
+	case string(token.TokenProfileScitokens2):
+		reqSlices = []string{"audience", "scope"}
+	}
+
+	missing := []string{}
+	for _, flag := range reqFlags {
+		if val, _ := cmd.Flags().GetString(flag); val == "" {
+			missing = append(missing, "--"+flag)
+		}
+	}
+	for _, flag := range reqSlices {
+		if slice, _ := cmd.Flags().GetStringSlice(flag); len(slice) == 0 {
+			missing = append(missing, "--"+flag)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("the %s flags must be populated for the %s profile", strings.Join(missing, " and "), profile)
+	}
+	return nil
+}
+
 // Take an input slice and append its claim name
 func parseInputSlice(rawSlice *[]string, claimPrefix string) []string {
 	if len(*rawSlice) == 0 {
@@ -174,5 +251,88 @@ func cliTokenCreate(cmd *cobra.Command, args []string) error {
 }
 
 func verifyToken(cmd *cobra.Command, args []string) error {
-	return errors.New("Token verification not yet implemented")
+	var strToken string
+	if len(args) == 0 || args[0] == "-" {
+		contents, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return errors.Wrap(err, "failed to read the token from standard input")
+		}
+		strToken = string(contents)
+	} else {
+		strToken = args[0]
+	}
+	if strings.TrimSpace(strToken) == "" {
+		return errors.New("no token was given")
+	}
+
+	if err := config.InitClient(); err != nil {
+		return errors.Wrap(err, "failed to initialize the client configuration")
+	}
+
+	opts := token.TokenCheckOptions{}
+	opts.Issuer, _ = cmd.Flags().GetString("issuer")
+	opts.Audiences, _ = cmd.Flags().GetStringSlice("audience")
+	opts.Scopes, _ = cmd.Flags().GetStringSlice("scope")
+	opts.Skew, _ = cmd.Flags().GetDuration("skew")
+	// The profile defaults to wlcg for token creation; only check it when asked to
+	if cmd.Flags().Changed("profile") {
+		profile, _ := cmd.Flags().GetString("profile")
+		opts.Profile = token.TokenProfile(profile)
+		switch opts.Profile {
+		case token.TokenProfileWLCG, token.TokenProfileScitokens2, token.TokenProfileNone:
+		default:
+			return errors.Errorf("unsupported token profile: %s", profile)
+		}
+	}
+	if opts.Issuer == "" && param.Federation_DiscoveryUrl.GetString() != "" {
+		fedInfo, err := config.GetFederation(cmd.Context())
+		if err != nil {
+			return errors.Wrap(err, "failed to look up the federation's issuer")
+		}
+		opts.Issuer = fedInfo.DiscoveryEndpoint
+	}
+
+	result, err := token.CheckToken(cmd.Context(), strToken, opts)
+	if err != nil {
+		return err
+	}
+
+	if outputJSON {
+		bytes, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the token check result")
+		}
+		fmt.Println(string(bytes))
+	} else {
+		printTokenMap("Header", result.Header)
+		printTokenMap("Claims", result.Claims)
+		if result.Valid {
+			fmt.Println("The token is valid")
+		} else {
+			fmt.Println("Problems:")
+			for _, problem := range result.Problems {
+				fmt.Println("  - " + problem)
+			}
+		}
+	}
+	if !result.Valid {
+		return errors.Errorf("the token is invalid (%d problems found)", len(result.Problems))
+	}
+	return nil
+}
+
+func printTokenMap(title string, values map[string]interface{}) {
+	fmt.Println(title + ":")
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := values[key]
+		if ts, ok := value.(time.Time); ok {
+			value = ts.Format(time.RFC3339)
+		}
+		fmt.Printf("  %s: %v\n", key, value)
+	}
 }
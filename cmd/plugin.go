@@ -399,6 +399,14 @@ func runPluginWorker(ctx context.Context, upload bool, workChan <-chan PluginTra
 		}
 	}()
 
+	// Apply any transfer options set in the job ad
+	jobOpts, jobAdErr := readJobAdOptions()
+	if jobAdErr != nil {
+		log.Warningln("Ignoring the transfer options in the job ad:", jobAdErr)
+		jobOpts = pluginJobOptions{}
+	}
+	jobOpts.applyConfig()
+
 	// Check for local cache
 	var caches []*url.URL
 	if nearestCache, ok := os.LookupEnv("NEAREST_CACHE"); ok && nearestCache != "" {
@@ -412,6 +420,7 @@ func runPluginWorker(ctx context.Context, upload bool, workChan <-chan PluginTra
 			return
 		}
 	}
+	transferOptions := append([]client.TransferOption{client.WithAcquireToken(false), client.WithCaches(caches...)}, jobOpts.transferOptions()...)
 
 	tc, err := te.NewClient(client.WithAcquireToken(false))
 	if err != nil {
@@ -424,10 +433,22 @@ func runPluginWorker(ctx context.Context, upload bool, workChan <-chan PluginTra
 	}()
 	defer close(results)
 
-	jobMap := make(map[string]PluginTransfer)
-	var recursive bool
+	jobMap := make(map[string]*pluginJob)
 	var tj *client.TransferJob
 
+	// Create a transfer job, reading directly from the origin if the job prefers it
+	// and falling back to the caches if that's not possible
+	newTransferJob := func(job *pluginJob) (tj *client.TransferJob, err error) {
+		if job.directRead {
+			if tj, err = tc.NewTransferJob(context.Background(), job.transferUrl(), job.transfer.localFile, upload, job.recursive, transferOptions...); err == nil {
+				return
+			}
+			log.Warningln("Unable to read", job.transfer.url.String(), "directly from the origin; falling back to caches:", err)
+			job.directRead = false
+		}
+		return tc.NewTransferJob(context.Background(), job.transferUrl(), job.transfer.localFile, upload, job.recursive, transferOptions...)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -445,12 +466,6 @@ func runPluginWorker(ctx context.Context, upload bool, workChan <-chan PluginTra
 				return err
 			}
 
-			if transfer.url.Query().Has(pelican_url.QueryRecursive) {
-				recursive = true
-			} else {
-				recursive = false
-			}
-
 			if upload {
 				log.Debugln("Uploading:", transfer.localFile, "to", transfer.url)
 			} else {
@@ -458,13 +473,19 @@ func runPluginWorker(ctx context.Context, upload bool, workChan <-chan PluginTra
 				log.Debugln("Downloading:", transfer.url, "to", transfer.localFile)
 			}
 
-			urlCopy := *(pUrl.GetRawUrl())
-			tj, err = tc.NewTransferJob(context.Background(), &urlCopy, transfer.localFile, upload, recursive, client.WithAcquireToken(false), client.WithCaches(caches...))
+			job := &pluginJob{
+				transfer:   transfer,
+				rawUrl:     *(pUrl.GetRawUrl()),
+				recursive:  transfer.url.Query().Has(pelican_url.QueryRecursive),
+				directRead: jobOpts.preferOrigin(upload, transfer.url),
+			}
+			tj, err = newTransferJob(job)
 			if err != nil {
 				failTransfer(transfer.url.String(), transfer.localFile, results, upload, err)
 				return errors.Wrap(err, "Failed to create new transfer job")
 			}
-			jobMap[tj.ID()] = transfer
+			job.tries++
+			jobMap[tj.ID()] = job
 
 			if err = tc.Submit(tj); err != nil {
 				failTransfer(transfer.url.String(), transfer.localFile, results, upload, err)
@@ -482,66 +503,162 @@ func runPluginWorker(ctx context.Context, upload bool, workChan <-chan PluginTra
 				return
 			}
 			log.Debugln("Got result from transfer client")
-			resultAd := classads.NewClassAd()
-			// Set our DeveloperData:
-			developerData := make(map[string]interface{})
-			developerData["PelicanClientVersion"] = config.GetVersion()
-			developerData["Attempts"] = len(result.Attempts)
-			for _, attempt := range result.Attempts {
-				developerData[fmt.Sprintf("TransferFileBytes%d", attempt.Number)] = attempt.TransferFileBytes
-				developerData[fmt.Sprintf("TimeToFirstByte%d", attempt.Number)] = attempt.TimeToFirstByte.Round(time.Millisecond).Seconds()
-				developerData[fmt.Sprintf("Endpoint%d", attempt.Number)] = attempt.Endpoint
-				developerData[fmt.Sprintf("TransferEndTime%d", attempt.Number)] = attempt.TransferEndTime.Unix()
-				developerData[fmt.Sprintf("ServerVersion%d", attempt.Number)] = attempt.ServerVersion
-				developerData[fmt.Sprintf("TransferTime%d", attempt.Number)] = attempt.TransferTime.Round(time.Millisecond).Seconds()
-				if attempt.CacheAge >= 0 {
-					developerData[fmt.Sprintf("DataAge%d", attempt.Number)] = attempt.CacheAge.Round(time.Millisecond).Seconds()
-				}
-				if attempt.Error != nil {
-					developerData[fmt.Sprintf("TransferError%d", attempt.Number)] = attempt.Error.Error()
-				}
+			job := jobMap[result.ID()]
+			if job.startTime.IsZero() {
+				job.startTime = result.TransferStartTime
 			}
+			// Retries are done one at a time, outside of the client; since the client
+			// may already be closed, they can't be submitted to it.
+			if !job.recursive {
+				result = job.retry(ctx, te, upload, result, jobOpts.Retries, transferOptions)
+			}
+			results <- job.resultAd(result, upload)
+		}
+	}
+}
 
-			resultAd.Set("DeveloperData", developerData)
+// A transfer requested of the plugin, which may take several transfer jobs to complete
+type pluginJob struct {
+	transfer   PluginTransfer
+	rawUrl     url.URL
+	recursive  bool
+	directRead bool      // Whether the transfer is currently being attempted directly from the origin
+	tries      int       // The number of transfer jobs run for the transfer
+	retries    int       // The number of those jobs that were retries after a retryable error
+	startTime  time.Time // The start time of the first transfer job
+}
 
-			resultAd.Set("TransferStartTime", result.TransferStartTime.Unix())
-			resultAd.Set("TransferEndTime", time.Now().Unix())
-			hostname, _ := os.Hostname()
-			resultAd.Set("TransferLocalMachineName", hostname)
-			resultAd.Set("TransferProtocol", result.Scheme)
-			transfer := jobMap[result.ID()]
-			resultAd.Set("TransferUrl", transfer.url.String())
-			if upload {
-				resultAd.Set("TransferType", "upload")
-				resultAd.Set("TransferFileName", path.Base(transfer.localFile))
-			} else {
-				resultAd.Set("TransferType", "download")
-				resultAd.Set("TransferFileName", path.Base(transfer.url.String()))
-			}
-			if result.Error == nil {
-				resultAd.Set("TransferSuccess", true)
-				resultAd.Set("TransferFileBytes", result.Attempts[len(result.Attempts)-1].TransferFileBytes)
-				resultAd.Set("TransferTotalBytes", result.Attempts[len(result.Attempts)-1].TransferFileBytes)
-			} else {
-				resultAd.Set("TransferSuccess", false)
-				var te *client.TransferErrors
-				errMsgInternal := result.Error.Error()
-				if errors.As(result.Error, &te) {
-					errMsgInternal = te.UserError()
-				}
-				errMsg := writeTransferErrorMessage(errMsgInternal, transfer.url.String())
-				resultAd.Set("TransferError", errMsg)
-				resultAd.Set("TransferFileBytes", 0)
-				resultAd.Set("TransferTotalBytes", 0)
-				if client.ShouldRetry(result.Error) {
-					resultAd.Set("TransferRetryable", true)
-				} else {
-					resultAd.Set("TransferRetryable", false)
-				}
-			}
-			results <- resultAd
+// The URL to transfer for the job's next attempt
+func (job *pluginJob) transferUrl() *url.URL {
+	transferUrl := job.rawUrl
+	if job.directRead {
+		if transferUrl.RawQuery == "" {
+			transferUrl.RawQuery = pelican_url.QueryDirectRead
+		} else {
+			transferUrl.RawQuery += "&" + pelican_url.QueryDirectRead
 		}
 	}
+	return &transferUrl
+}
+
+// Retry a failed transfer, returning the result of the final attempt.
+//
+// A transfer that failed reading directly from the origin is repeated through the
+// caches; otherwise, a transfer failing with a retryable error is repeated up to
+// maxRetries times.
+func (job *pluginJob) retry(ctx context.Context, te *client.TransferEngine, upload bool, result client.TransferResults, maxRetries int, options []client.TransferOption) client.TransferResults {
+	for result.Error != nil && ctx.Err() == nil {
+		if job.directRead {
+			log.Warningln("Failed to read", job.transfer.url.String(), "directly from the origin; falling back to caches:", result.Error)
+			job.directRead = false
+		} else if job.retries < maxRetries && client.ShouldRetry(result.Error) {
+			job.retries++
+			log.Warningf("Retrying transfer of %s (retry %d of %d) after error: %v", job.transfer.url.String(), job.retries, maxRetries, result.Error)
+		} else {
+			break
+		}
+
+		job.tries++
+		var retryResults []client.TransferResults
+		var err error
+		if upload {
+			retryResults, err = te.Put(ctx, job.transfer.localFile, job.transferUrl().String(), options...)
+		} else {
+			retryResults, err = te.Get(ctx, job.transferUrl().String(), job.transfer.localFile, options...)
+		}
+		if len(retryResults) > 0 {
+			result = retryResults[0]
+		} else {
+			result = client.TransferResults{Error: err}
+		}
+	}
+	return result
+}
+
+// Create the result ad reporting the outcome of the job's transfer
+func (job *pluginJob) resultAd(result client.TransferResults, upload bool) *classads.ClassAd {
+	resultAd := classads.NewClassAd()
+	// Set our DeveloperData:
+	developerData := make(map[string]interface{})
+	developerData["PelicanClientVersion"] = config.GetVersion()
+	if result.CompatLevel != "" {
+		developerData["PelicanCompatLevel"] = result.CompatLevel
+	}
+	developerData["Attempts"] = len(result.Attempts)
+	for _, attempt := range result.Attempts {
+		developerData[fmt.Sprintf("TransferFileBytes%d", attempt.Number)] = attempt.TransferFileBytes
+		developerData[fmt.Sprintf("TimeToFirstByte%d", attempt.Number)] = attempt.TimeToFirstByte.Round(time.Millisecond).Seconds()
+		developerData[fmt.Sprintf("Endpoint%d", attempt.Number)] = attempt.Endpoint
+		developerData[fmt.Sprintf("TransferEndTime%d", attempt.Number)] = attempt.TransferEndTime.Unix()
+		developerData[fmt.Sprintf("ServerVersion%d", attempt.Number)] = attempt.ServerVersion
+		developerData[fmt.Sprintf("TransferTime%d", attempt.Number)] = attempt.TransferTime.Round(time.Millisecond).Seconds()
+		if attempt.BusyWaitTime > 0 {
+			developerData[fmt.Sprintf("BusyWaitTime%d", attempt.Number)] = attempt.BusyWaitTime.Round(time.Millisecond).Seconds()
+		}
+		if attempt.CacheAge >= 0 {
+			developerData[fmt.Sprintf("DataAge%d", attempt.Number)] = attempt.CacheAge.Round(time.Millisecond).Seconds()
+		}
+		if attempt.Error != nil {
+			developerData[fmt.Sprintf("TransferError%d", attempt.Number)] = attempt.Error.Error()
+		}
+	}
+
+	resultAd.Set("DeveloperData", developerData)
+
+	startTime := job.startTime
+	if startTime.IsZero() {
+		startTime = result.TransferStartTime
+	}
+	endTime := time.Now()
+	resultAd.Set("TransferStartTime", startTime.Unix())
+	resultAd.Set("TransferEndTime", endTime.Unix())
+	resultAd.Set("ConnectionTimeSeconds", endTime.Sub(startTime).Round(time.Millisecond).Seconds())
+	resultAd.Set("TransferTries", job.tries)
+	hostname, _ := os.Hostname()
+	resultAd.Set("TransferLocalMachineName", hostname)
+	resultAd.Set("TransferProtocol", result.Scheme)
+	resultAd.Set("TransferUrl", job.transfer.url.String())
+	if upload {
+		resultAd.Set("TransferType", "upload")
+		resultAd.Set("TransferFileName", filepath.Base(job.transfer.localFile))
+	} else {
+		resultAd.Set("TransferType", "download")
+		resultAd.Set("TransferFileName", path.Base(job.transfer.url.String()))
+	}
+	if len(result.Attempts) > 0 {
+		lastAttempt := result.Attempts[len(result.Attempts)-1]
+		resultAd.Set("TransferHostName", lastAttempt.Endpoint)
+		// The age of the data is only reported by caches; an age of zero means the
+		// cache had to fetch the object from the origin
+		if !upload && lastAttempt.CacheAge >= 0 {
+			resultAd.Set("TransferCacheHit", lastAttempt.CacheAge > 0)
+		}
+	}
+	if result.Error == nil {
+		resultAd.Set("TransferSuccess", true)
+		resultAd.Set("TransferFileBytes", result.Attempts[len(result.Attempts)-1].TransferFileBytes)
+		resultAd.Set("TransferTotalBytes", result.Attempts[len(result.Attempts)-1].TransferFileBytes)
+	} else {
+		resultAd.Set("TransferSuccess", false)
+		var te *client.TransferErrors
+		errMsgInternal := result.Error.Error()
+		if errors.As(result.Error, &te) {
+			errMsgInternal = te.UserError()
+		}
+		errMsg := writeTransferErrorMessage(errMsgInternal, job.transfer.url.String())
+		resultAd.Set("TransferError", errMsg)
+		resultAd.Set("TransferFileBytes", 0)
+		resultAd.Set("TransferTotalBytes", 0)
+		if client.ShouldRetry(result.Error) {
+			resultAd.Set("TransferRetryable", true)
+		} else {
+			resultAd.Set("TransferRetryable", false)
+		}
+		if phase, ok := client.GetTimeoutPhase(result.Error); ok {
+			resultAd.Set("TransferTimeoutType", string(phase))
+		}
+	}
+	return resultAd
 }
 
 // This function is to be called to populate the result ads for a failed transfer
@@ -551,7 +668,7 @@ func failTransfer(remoteUrl string, localFile string, results chan<- *classads.C
 	resultAd.Set("TransferUrl", remoteUrl)
 	if upload {
 		resultAd.Set("TransferType", "upload")
-		resultAd.Set("TransferFileName", path.Base(localFile))
+		resultAd.Set("TransferFileName", filepath.Base(localFile))
 	} else {
 		resultAd.Set("TransferType", "download")
 		resultAd.Set("TransferFileName", path.Base(remoteUrl))
@@ -563,6 +680,9 @@ func failTransfer(remoteUrl string, localFile string, results chan<- *classads.C
 	}
 	resultAd.Set("TransferSuccess", false)
 	resultAd.Set("TransferError", err.Error())
+	if phase, ok := client.GetTimeoutPhase(err); ok {
+		resultAd.Set("TransferTimeoutType", string(phase))
+	}
 
 	results <- resultAd
 }
@@ -578,7 +698,7 @@ func parseDestination(transfer PluginTransfer) (parsedDest string) {
 	// file which HTCondor prepares
 	isPack := transfer.url.Query().Get("pack") != ""
 	if isPack {
-		destPath = filepath.Dir(transfer.localFile)
+		destPath = filepath.Dir(destPath)
 	}
 
 	// Check if path exists or if its in a folder
@@ -587,7 +707,7 @@ func parseDestination(transfer PluginTransfer) (parsedDest string) {
 	} else if destStat.IsDir() && !isPack {
 		// If we are a directory, add the source filename to the destination dir
 		sourceFilename := path.Base(transfer.url.Path)
-		parsedDest = path.Join(destPath, sourceFilename)
+		parsedDest = filepath.Join(destPath, sourceFilename)
 		return parsedDest
 	}
 
@@ -0,0 +1,142 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, University of Nebraska-Lincoln
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/pelicanplatform/pelican/classads"
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/pelican_url"
+)
+
+// Transfer options that a job may set in its job ad, e.g., with
+//
+//	+PelicanCachePreference = "prefer-origin"
+//
+// in the submit file
+type pluginJobOptions struct {
+	TokenLocation    string
+	CachePreference  string
+	Retries          int
+	MinimumBandwidth int
+}
+
+const (
+	// Path of a token file to use for the job's transfers
+	jobAdTokenLocation = "PelicanTokenLocation"
+	// Either "prefer-cache" (the default) or "prefer-origin"
+	jobAdCachePreference = "PelicanCachePreference"
+	// Number of times a transfer failing with a retryable error is retried
+	jobAdRetries = "PelicanTransferRetries"
+	// Minimum download speed, in bytes per second; overrides Client.MinimumDownloadSpeed
+	jobAdMinimumBandwidth = "PelicanMinimumBandwidth"
+
+	cachePreferenceCache  = "prefer-cache"
+	cachePreferenceOrigin = "prefer-origin"
+)
+
+// Read the job's transfer options from the HTCondor job ad, if there is one
+func readJobAdOptions() (opts pluginJobOptions, err error) {
+	filename, isPresent := os.LookupEnv("_CONDOR_JOB_AD")
+	if !isPresent {
+		filename = ".job.ad"
+	}
+	jobAdFile, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return opts, nil
+	} else if err != nil {
+		return opts, errors.Wrap(err, "failed to open the job ad")
+	}
+	defer jobAdFile.Close()
+	return parseJobAdOptions(jobAdFile)
+}
+
+// Parse the job's transfer options from a job ad in the "long" (one attribute per line) format
+func parseJobAdOptions(reader io.Reader) (opts pluginJobOptions, err error) {
+	contents, err := io.ReadAll(reader)
+	if err != nil {
+		return opts, errors.Wrap(err, "failed to read the job ad")
+	}
+	jobAd, err := classads.ParseShadowClassAd(string(contents))
+	if err != nil {
+		return opts, errors.Wrap(err, "failed to parse the job ad")
+	}
+
+	if value, _ := jobAd.Get(jobAdTokenLocation); value != nil {
+		if opts.TokenLocation, _ = value.(string); opts.TokenLocation == "" {
+			return opts, errors.Errorf("%s must be a string", jobAdTokenLocation)
+		}
+	}
+	if value, _ := jobAd.Get(jobAdCachePreference); value != nil {
+		preference, _ := value.(string)
+		if preference != cachePreferenceCache && preference != cachePreferenceOrigin {
+			return opts, errors.Errorf("%s must be either %q or %q", jobAdCachePreference, cachePreferenceCache, cachePreferenceOrigin)
+		}
+		opts.CachePreference = preference
+	}
+	if value, _ := jobAd.Get(jobAdRetries); value != nil {
+		retries, ok := value.(int)
+		if !ok || retries < 0 {
+			return opts, errors.Errorf("%s must be a non-negative integer", jobAdRetries)
+		}
+		opts.Retries = retries
+	}
+	if value, _ := jobAd.Get(jobAdMinimumBandwidth); value != nil {
+		bandwidth, ok := value.(int)
+		if !ok || bandwidth < 0 {
+			return opts, errors.Errorf("%s must be a non-negative integer (bytes per second)", jobAdMinimumBandwidth)
+		}
+		opts.MinimumBandwidth = bandwidth
+	}
+	return opts, nil
+}
+
+// Apply the options that are process-wide settings.  Since the plugin is
+// invoked once per job, these only affect the current job's transfers.
+func (opts pluginJobOptions) applyConfig() {
+	if opts.MinimumBandwidth > 0 {
+		log.Debugln("Job ad sets the minimum download speed to", opts.MinimumBandwidth, "bytes per second")
+		viper.Set(param.Client_MinimumDownloadSpeed.GetName(), opts.MinimumBandwidth)
+	}
+}
+
+// The transfer options for each of the job's transfers
+func (opts pluginJobOptions) transferOptions() []client.TransferOption {
+	if opts.TokenLocation == "" {
+		return nil
+	}
+	return []client.TransferOption{client.WithTokenLocation(opts.TokenLocation)}
+}
+
+// Whether a download should first be attempted directly from the origin
+func (opts pluginJobOptions) preferOrigin(upload bool, transferUrl *url.URL) bool {
+	if upload || opts.CachePreference != cachePreferenceOrigin {
+		return false
+	}
+	// An explicit directread in the URL already reads from the origin
+	return !transferUrl.Query().Has(pelican_url.QueryDirectRead)
+}
@@ -111,7 +111,7 @@ func stagePluginMain(cmd *cobra.Command, args []string) {
 	// Check if the program was executed from a terminal
 	// https://rosettacode.org/wiki/Check_output_device_is_a_terminal#Go
 	if fileInfo, _ := os.Stdout.Stat(); (fileInfo.Mode() & os.ModeCharDevice) != 0 {
-		pb.launchDisplay(ctx)
+		pb.launchDisplay(ctx, os.Stdout)
 	}
 
 	isHook := param.StagePlugin_Hook.GetBool()
@@ -705,6 +705,98 @@ func TestPluginCorrectStartAndEndTime(t *testing.T) {
 	}
 }
 
+func TestParseJobAdOptions(t *testing.T) {
+	jobAd := `MyType = "Job"
+PelicanTokenLocation = "/srv/tokens/job.tkn"
+PelicanCachePreference = "prefer-origin"
+PelicanTransferRetries = 2
+PelicanMinimumBandwidth = 1024
+`
+	opts, err := parseJobAdOptions(strings.NewReader(jobAd))
+	require.NoError(t, err)
+	assert.Equal(t, pluginJobOptions{
+		TokenLocation:    "/srv/tokens/job.tkn",
+		CachePreference:  cachePreferenceOrigin,
+		Retries:          2,
+		MinimumBandwidth: 1024,
+	}, opts)
+	assert.Len(t, opts.transferOptions(), 1)
+
+	downloadUrl, err := url.Parse("pelican://example.com/test/file.txt")
+	require.NoError(t, err)
+	assert.True(t, opts.preferOrigin(false, downloadUrl))
+	assert.False(t, opts.preferOrigin(true, downloadUrl))
+	directUrl, err := url.Parse("pelican://example.com/test/file.txt?directread")
+	require.NoError(t, err)
+	assert.False(t, opts.preferOrigin(false, directUrl))
+
+	job := &pluginJob{rawUrl: *downloadUrl, directRead: true}
+	assert.Equal(t, "pelican://example.com/test/file.txt?directread", job.transferUrl().String())
+	job.rawUrl.RawQuery = "pack=auto"
+	assert.Equal(t, "pelican://example.com/test/file.txt?pack=auto&directread", job.transferUrl().String())
+	job.directRead = false
+	assert.Equal(t, "pelican://example.com/test/file.txt?pack=auto", job.transferUrl().String())
+
+	t.Run("no-options", func(t *testing.T) {
+		opts, err := parseJobAdOptions(strings.NewReader("MyType = \"Job\"\n"))
+		require.NoError(t, err)
+		assert.Equal(t, pluginJobOptions{}, opts)
+		assert.Empty(t, opts.transferOptions())
+	})
+
+	t.Run("invalid-options", func(t *testing.T) {
+		_, err := parseJobAdOptions(strings.NewReader("PelicanCachePreference = \"prefer-nothing\"\n"))
+		assert.Error(t, err)
+		_, err = parseJobAdOptions(strings.NewReader("PelicanTransferRetries = -1\n"))
+		assert.Error(t, err)
+		_, err = parseJobAdOptions(strings.NewReader("PelicanMinimumBandwidth = \"fast\"\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestPluginJobResultAd(t *testing.T) {
+	transferUrl, err := url.Parse("pelican://example.com/test/file.txt")
+	require.NoError(t, err)
+	startTime := time.Now().Add(-10 * time.Second)
+	job := &pluginJob{transfer: PluginTransfer{url: transferUrl, localFile: "/tmp/file.txt"}, tries: 2, startTime: startTime}
+
+	resultAd := job.resultAd(client.TransferResults{
+		TransferStartTime: time.Now(),
+		Attempts: []client.TransferResult{
+			{Number: 0, TransferFileBytes: 17, Endpoint: "cache.example.com:8443", CacheAge: 30 * time.Second},
+		},
+	}, false)
+	value, err := resultAd.Get("TransferSuccess")
+	require.NoError(t, err)
+	assert.Equal(t, true, value)
+	value, err = resultAd.Get("TransferTries")
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+	value, err = resultAd.Get("TransferStartTime")
+	require.NoError(t, err)
+	assert.Equal(t, startTime.Unix(), value)
+	value, err = resultAd.Get("TransferHostName")
+	require.NoError(t, err)
+	assert.Equal(t, "cache.example.com:8443", value)
+	value, err = resultAd.Get("TransferCacheHit")
+	require.NoError(t, err)
+	assert.Equal(t, true, value)
+	value, err = resultAd.Get("ConnectionTimeSeconds")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, value.(float64), float64(10))
+
+	// Downloads directly from an origin don't report whether there was a cache hit
+	resultAd = job.resultAd(client.TransferResults{
+		Error:    &client.SlowTransferError{},
+		Attempts: []client.TransferResult{{Number: 0, Endpoint: "origin.example.com:8443", CacheAge: -1}},
+	}, false)
+	value, _ = resultAd.Get("TransferCacheHit")
+	assert.Nil(t, value)
+	value, err = resultAd.Get("TransferTimeoutType")
+	require.NoError(t, err)
+	assert.Equal(t, "stall", value)
+}
+
 // Test the functionality of the failTransfer function, ensuring the proper classads are being set and returned
 func TestFailTransfer(t *testing.T) {
 	// Test when we call failTransfer with an upload
@@ -834,6 +926,10 @@ func TestFailTransfer(t *testing.T) {
 		transferErrorStr, ok := transferError.(string)
 		require.True(t, ok)
 		assert.Equal(t, "cancelled transfer, too slow; detected speed=0 B/s, total transferred=0 B, total transfer time=0s, cache miss", transferErrorStr)
+
+		// Check TransferTimeoutType set
+		transferTimeoutType, _ := result.Get("TransferTimeoutType")
+		assert.Equal(t, "stall", transferTimeoutType)
 	})
 }
 
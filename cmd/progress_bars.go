@@ -20,52 +20,92 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
-	"sync"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/vbauerster/mpb/v8"
 	"github.com/vbauerster/mpb/v8/decor"
 	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/param"
 )
 
 type (
-	progressStatus struct {
-		xfer      int64 // Number of bytes transferred
-		size      int64 // Total size of object to move
-		completed bool  // Whether the object is complete
-	}
+	// How the progress of the transfers is shown, set by --progress
+	progressMode string
 
 	progressBar struct {
-		progressStatus
-		bar *mpb.Bar
+		size int64
+		bar  *mpb.Bar
 	}
 
 	progressBars struct {
-		lock   sync.RWMutex
-		done   chan bool
-		status map[string]progressStatus
-		egrp   *errgroup.Group
+		tracker *client.ProgressTracker
+		done    chan bool
+		egrp    *errgroup.Group
 	}
+
+	// A line of the progress printed to stderr with --progress=json.  Fields may be added
+	// but are never renamed or removed.
+	jsonProgress struct {
+		Files            int                    `json:"files"`
+		CompletedFiles   int                    `json:"completedFiles"`
+		TransferredBytes int64                  `json:"transferredBytes"`
+		TotalBytes       int64                  `json:"totalBytes"`
+		BytesPerSecond   float64                `json:"bytesPerSecond"`
+		EtaSeconds       float64                `json:"etaSeconds,omitempty"`
+		Transfers        []jsonTransferProgress `json:"transfers"`
+	}
+
+	jsonTransferProgress struct {
+		Path             string  `json:"path"`
+		TransferredBytes int64   `json:"transferredBytes"`
+		TotalBytes       int64   `json:"totalBytes"`
+		BytesPerSecond   float64 `json:"bytesPerSecond"`
+		EtaSeconds       float64 `json:"etaSeconds,omitempty"`
+		Completed        bool    `json:"completed"`
+	}
+)
+
+const (
+	// Progress bars when stdout is a terminal not carrying the results
+	progressAuto progressMode = "auto"
+	// Progress bars, on stderr if stdout isn't a terminal
+	progressShowBars progressMode = "bars"
+	// A JSON line of the progress on stderr at each refresh
+	progressJSON progressMode = "json"
+	progressNone progressMode = "none"
+
+	progressRefresh = 500 * time.Millisecond
 )
 
 func newProgressBar() *progressBars {
 	return &progressBars{
-		done:   make(chan bool),
-		status: make(map[string]progressStatus),
+		tracker: client.NewProgressTracker(nil),
+		done:    make(chan bool),
 	}
 }
 
+// Add the flags selecting how the progress of the transfers is shown
+func addProgressFlags(flagSet *pflag.FlagSet) {
+	flagSet.BoolP("quiet", "q", false, "Don't show the progress of the transfers")
+	flagSet.String("progress", string(progressAuto), "How to show the progress of the transfers: auto (progress bars if run from a terminal), bars, json (a line per update on stderr), or none")
+}
+
 func (pb *progressBars) callback(path string, xfer int64, size int64, completed bool) {
-	pb.lock.Lock()
-	defer pb.lock.Unlock()
-	stat := pb.status[path]
-	stat.completed = completed
-	stat.size = size
-	stat.xfer = xfer
-	pb.status[path] = stat
+	pb.tracker.Callback(path, xfer, size, completed)
 }
 
 func (pb *progressBars) shutdown() {
@@ -74,11 +114,108 @@ func (pb *progressBars) shutdown() {
 		if err := pb.egrp.Wait(); err != nil {
 			log.Debugln("Failure to shut down progress bar:", err)
 		}
+		pb.egrp = nil
+	}
+}
+
+// Show the progress of the transfers of a command, exiting if --progress is invalid.  Progress
+// bars are drawn by default if the program was executed from a terminal, stdout doesn't carry
+// the results (e.g., as JSON), and no log location is specified.
+// https://rosettacode.org/wiki/Check_output_device_is_a_terminal#Go
+func (pb *progressBars) launchForCommand(ctx context.Context, cmd *cobra.Command, resultsOnStdout bool) {
+	fileInfo, _ := os.Stdout.Stat()
+	interactive := !resultsOnStdout && (fileInfo.Mode()&os.ModeCharDevice) != 0 && param.Logging_LogLocation.GetString() == "" && !param.Logging_DisableProgressBars.GetBool()
+	if err := pb.launch(ctx, cmd, interactive); err != nil {
+		log.Errorln(err)
+		os.Exit(1)
 	}
 }
 
-func (pb *progressBars) launchDisplay(ctx context.Context) {
-	progressCtr := mpb.NewWithContext(ctx)
+// Show the progress of the transfers as selected by --quiet and --progress.  In the default
+// auto mode, progress bars are only drawn if interactive is set, i.e., stdout is a terminal
+// not carrying the results.
+func (pb *progressBars) launch(ctx context.Context, cmd *cobra.Command, interactive bool) error {
+	mode := progressAuto
+	if flag := cmd.Flags().Lookup("progress"); flag != nil && flag.Value.Type() == "string" {
+		mode = progressMode(strings.ToLower(flag.Value.String()))
+	}
+	if quiet, _ := cmd.Flags().GetBool("quiet"); quiet {
+		mode = progressNone
+	}
+	switch mode {
+	case progressAuto:
+		if interactive {
+			pb.launchDisplay(ctx, os.Stdout)
+		}
+	case progressShowBars:
+		if fileInfo, _ := os.Stdout.Stat(); interactive || (fileInfo.Mode()&os.ModeCharDevice) != 0 {
+			pb.launchDisplay(ctx, os.Stdout)
+		} else {
+			pb.launchDisplay(ctx, os.Stderr)
+		}
+	case progressJSON:
+		pb.launchJSON(ctx, os.Stderr)
+	case progressNone:
+	default:
+		return errors.Errorf("invalid value %q for --progress; must be one of auto, bars, json, or none", mode)
+	}
+	return nil
+}
+
+func newJSONProgress(report client.ProgressReport) jsonProgress {
+	progress := jsonProgress{
+		Files:            report.Files,
+		CompletedFiles:   report.CompletedFiles,
+		TransferredBytes: report.TransferredBytes,
+		TotalBytes:       report.TotalBytes,
+		BytesPerSecond:   report.Rate,
+		EtaSeconds:       report.ETA.Seconds(),
+		Transfers:        make([]jsonTransferProgress, 0, len(report.Transfers)),
+	}
+	for _, transfer := range report.Transfers {
+		progress.Transfers = append(progress.Transfers, jsonTransferProgress{
+			Path:             transfer.Path,
+			TransferredBytes: transfer.TransferredBytes,
+			TotalBytes:       transfer.TotalBytes,
+			BytesPerSecond:   transfer.Rate,
+			EtaSeconds:       transfer.ETA.Seconds(),
+			Completed:        transfer.Completed,
+		})
+	}
+	return progress
+}
+
+// Print the progress as a JSON line to the writer at each refresh, and once more when the
+// transfers are done
+func (pb *progressBars) launchJSON(ctx context.Context, w io.Writer) {
+	pb.egrp, _ = errgroup.WithContext(ctx)
+	encoder := json.NewEncoder(w)
+	write := func() {
+		if err := encoder.Encode(newJSONProgress(pb.tracker.Report())); err != nil {
+			log.Debugln("Failed to write the progress:", err)
+		}
+	}
+	pb.egrp.Go(func() error {
+		ticker := time.NewTicker(progressRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-pb.done:
+				write()
+				return nil
+			case <-ticker.C:
+				write()
+			}
+		}
+	})
+}
+
+// Draw a progress bar for each ongoing transfer to the writer and, for operations with
+// several files, one for the whole operation
+func (pb *progressBars) launchDisplay(ctx context.Context, w io.Writer) {
+	progressCtr := mpb.NewWithContext(ctx, mpb.WithOutput(w))
 	pb.egrp, _ = errgroup.WithContext(ctx)
 	log.Debugln("Launch progress bars display")
 
@@ -88,10 +225,13 @@ func (pb *progressBars) launchDisplay(ctx context.Context) {
 			progressCtr.Wait()
 		}()
 
-		tickDuration := 500 * time.Millisecond
-		ticker := time.NewTicker(tickDuration)
+		ticker := time.NewTicker(progressRefresh)
 		defer ticker.Stop()
 		pbMap := make(map[string]*progressBar)
+		var totalBar *mpb.Bar
+		var totalSize int64
+		// The decorators of the total bar are rendered by another goroutine
+		var latest atomic.Pointer[client.ProgressReport]
 		for {
 			select {
 			case <-ctx.Done():
@@ -101,54 +241,110 @@ func (pb *progressBars) launchDisplay(ctx context.Context) {
 					// The deferred `progressCtr.Wait()` above will handle final cleanup.
 					pbMap[path].bar.Abort(true)
 				}
+				if totalBar != nil {
+					totalBar.Abort(true)
+				}
 				return nil
 			case <-ticker.C:
-				func() {
-					pb.lock.RLock()
-					defer pb.lock.RUnlock()
-					for path := range pbMap {
-						pbMap[path].xfer = -1
-					}
-					for path := range pb.status {
-						if pbMap[path] == nil {
-							pbMap[path] = &progressBar{
-								bar: progressCtr.AddBar(0,
-									mpb.PrependDecorators(
-										decor.Name(filepath.Base(path), decor.WCSyncSpaceR),
-										decor.CountersKibiByte("% .2f / % .2f"),
-									),
-									mpb.AppendDecorators(
-										decor.OnComplete(decor.EwmaETA(decor.ET_STYLE_GO, 15), ""),
-										decor.OnComplete(decor.Name(" ] "), ""),
-										decor.OnComplete(decor.EwmaSpeed(decor.SizeB1024(0), "% .2f", 15), "Done!"),
-									),
-									mpb.BarRemoveOnComplete(),
-								),
-							}
-						}
-						oldStatus := pbMap[path].progressStatus
-						newStatus := pb.status[path]
-						if oldStatus.size == 0 && newStatus.size > 0 {
-							pbMap[path].bar.SetTotal(newStatus.size, false)
-							pbMap[path].bar.EnableTriggerComplete()
+				report := pb.tracker.Report()
+				latest.Store(&report)
+				seen := make(map[string]bool, len(report.Transfers))
+				for _, transfer := range report.Transfers {
+					seen[transfer.Path] = true
+					if transfer.Completed {
+						if bar := pbMap[transfer.Path]; bar != nil {
+							bar.complete(transfer.TransferredBytes)
+							delete(pbMap, transfer.Path)
 						}
-						pbMap[path].bar.EwmaSetCurrent(newStatus.xfer, tickDuration)
-						pbMap[path].progressStatus = newStatus
+						continue
 					}
-					toDelete := make([]string, 0)
-					for path := range pbMap {
-						if pbMap[path].xfer == -1 {
-							toDelete = append(toDelete, path)
-						}
+					if pbMap[transfer.Path] == nil {
+						pbMap[transfer.Path] = newFileProgressBar(progressCtr, filepath.Base(transfer.Path))
 					}
-					for _, path := range toDelete {
-						bar := pbMap[path].bar
-						bar.Abort(true)
+					pbMap[transfer.Path].update(transfer.TransferredBytes, transfer.TotalBytes)
+				}
+				// Drop the bars of the transfers the tracker no longer knows
+				for path, bar := range pbMap {
+					if !seen[path] {
+						bar.bar.Abort(true)
 						delete(pbMap, path)
 					}
-				}()
+				}
+
+				if report.Files > 1 {
+					if totalBar == nil {
+						totalBar = newTotalProgressBar(progressCtr, &latest)
+					}
+					// More objects may be found, so the total bar is never completed
+					if report.TotalBytes != totalSize {
+						totalSize = report.TotalBytes
+						totalBar.SetTotal(totalSize, false)
+					}
+					totalBar.SetCurrent(report.TransferredBytes)
+				}
 			}
 		}
-
 	})
 }
+
+func newFileProgressBar(progressCtr *mpb.Progress, name string) *progressBar {
+	return &progressBar{
+		bar: progressCtr.AddBar(0,
+			mpb.PrependDecorators(
+				decor.Name(name, decor.WCSyncSpaceR),
+				decor.CountersKibiByte("% .2f / % .2f"),
+			),
+			mpb.AppendDecorators(
+				decor.OnComplete(decor.EwmaETA(decor.ET_STYLE_GO, 15), ""),
+				decor.OnComplete(decor.Name(" ] "), ""),
+				decor.OnComplete(decor.EwmaSpeed(decor.SizeB1024(0), "% .2f", 15), "Done!"),
+			),
+			mpb.BarRemoveOnComplete(),
+		),
+	}
+}
+
+// A bar kept below the others with the combined progress of the operation
+func newTotalProgressBar(progressCtr *mpb.Progress, latest *atomic.Pointer[client.ProgressReport]) *mpb.Bar {
+	return progressCtr.AddBar(0,
+		mpb.BarPriority(math.MaxInt),
+		mpb.PrependDecorators(
+			decor.Any(func(decor.Statistics) string {
+				report := latest.Load()
+				return fmt.Sprintf("Total (%d/%d files)", report.CompletedFiles, report.Files)
+			}, decor.WCSyncSpaceR),
+			decor.CountersKibiByte("% .2f / % .2f"),
+		),
+		mpb.AppendDecorators(
+			decor.Any(func(decor.Statistics) string {
+				if eta := latest.Load().ETA; eta > 0 {
+					return eta.Round(time.Second).String()
+				}
+				return ""
+			}),
+			decor.Name(" ] "),
+			decor.Any(func(decor.Statistics) string {
+				return fmt.Sprintf("% .2f/s", decor.SizeB1024(int64(latest.Load().Rate)))
+			}),
+		),
+	)
+}
+
+// Complete the bar of a finished transfer
+func (bar *progressBar) complete(transferred int64) {
+	if bar.size != transferred {
+		bar.bar.SetTotal(transferred, true)
+		return
+	}
+	bar.bar.EwmaSetCurrent(transferred, progressRefresh)
+}
+
+// Move the bar to the bytes transferred, setting its total once the size is known
+func (bar *progressBar) update(transferred int64, size int64) {
+	if bar.size == 0 && size > 0 {
+		bar.size = size
+		bar.bar.SetTotal(size, false)
+		bar.bar.EnableTriggerComplete()
+	}
+	bar.bar.EwmaSetCurrent(transferred, progressRefresh)
+}
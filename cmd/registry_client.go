@@ -48,6 +48,7 @@ import (
 var withIdentity bool
 var prefix string
 var pubkeyPath string
+var recoveryCode string
 
 func getRegistryEndpoint(ctx context.Context) (string, error) {
 	fedInfo, err := config.GetFederation(ctx)
@@ -68,6 +69,23 @@ func getRegistryEndpoint(ctx context.Context) (string, error) {
 	return registryEndpointURL.String(), nil
 }
 
+// Load the private key of the namespace: the issuer key on disk, or the key held by the
+// external key management service at IssuerKeyURI
+func loadNamespaceKey() (jwk.Key, error) {
+	if param.IssuerKeyURI.GetString() != "" {
+		return config.GetIssuerPrivateJWK()
+	}
+	privateKeyRaw, err := config.LoadPrivateKey(param.IssuerKey.GetString(), false)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := jwk.FromRaw(privateKeyRaw)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create JWK private key")
+	}
+	return privateKey, nil
+}
+
 func registerANamespace(cmd *cobra.Command, args []string) {
 	err := config.InitClient()
 	if err != nil {
@@ -109,14 +127,9 @@ func registerANamespace(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	privateKeyRaw, err := config.LoadPrivateKey(param.IssuerKey.GetString(), false)
+	privateKey, err := loadNamespaceKey()
 	if err != nil {
-		log.Error("Failed to load private key", err)
-		os.Exit(1)
-	}
-	privateKey, err := jwk.FromRaw(privateKeyRaw)
-	if err != nil {
-		log.Error("Failed to create JWK private key", err)
+		log.Error("Failed to load private key: ", err)
 		os.Exit(1)
 	}
 
@@ -161,6 +174,40 @@ func deleteANamespace(cmd *cobra.Command, args []string) {
 	}
 }
 
+func recoverANamespace(cmd *cobra.Command, args []string) {
+	err := config.InitClient()
+	if err != nil {
+		log.Errorln("Failed to initialize the client: ", err)
+		os.Exit(1)
+	}
+
+	namespaceEndpoint, err := getRegistryEndpoint(cmd.Context())
+	if err != nil {
+		log.Errorln("Failed to get RegistryUrl from config: ", err)
+		os.Exit(1)
+	}
+
+	recoveryEndpointURL, err := url.JoinPath(namespaceEndpoint, "api", "v1.0", "registry", "recover")
+	if err != nil {
+		log.Errorf("Failed to construction recovery endpoint URL: %v", err)
+	}
+	if prefix == "" || recoveryCode == "" {
+		log.Error("Error: prefix and recovery code are required")
+		os.Exit(1)
+	}
+
+	privateKey, err := loadNamespaceKey()
+	if err != nil {
+		log.Error("Failed to load private key: ", err)
+		os.Exit(1)
+	}
+
+	if err = registry.NamespaceRecover(privateKey, recoveryEndpointURL, prefix, recoveryCode); err != nil {
+		log.Errorf("Failed to recover prefix %s: %v", prefix, err)
+		os.Exit(1)
+	}
+}
+
 func listAllNamespaces(cmd *cobra.Command, args []string) {
 	err := config.InitClient()
 	if err != nil {
@@ -231,6 +278,15 @@ var deleteCmd = &cobra.Command{
 	Run:   deleteANamespace,
 }
 
+var recoverCmd = &cobra.Command{
+	Use:   "recover",
+	Short: "Replace the public key of a namespace using a recovery code",
+	Long: `Replace the registered public key of a namespace with the public key of the
+current issuer key (or the key given by --privkey), using one of the recovery codes
+returned when the namespace was registered. Each recovery code can only be used once.`,
+	Run: recoverANamespace,
+}
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all namespaces",
@@ -250,6 +306,8 @@ func init() {
 	//getCmd.Flags().StringVar(&prefix, "prefix", "", "prefix for get namespace")
 	//getCmd.Flags().BoolVar(&jwks, "jwks", false, "Get the jwks of the namespace")
 	deleteCmd.Flags().StringVar(&prefix, "prefix", "", "prefix for delete namespace")
+	recoverCmd.Flags().StringVar(&prefix, "prefix", "", "prefix of the namespace to recover")
+	recoverCmd.Flags().StringVar(&recoveryCode, "code", "", "recovery code for the namespace")
 
 	namespaceCmd.PersistentFlags().String("namespace-url", "", "Endpoint for the namespace registry")
 	// Don't override Federation.RegistryUrl if the flag value is empty
@@ -271,6 +329,7 @@ func init() {
 	namespaceCmd.AddCommand(registerCmd)
 	namespaceCmd.AddCommand(deleteCmd)
 	namespaceCmd.AddCommand(listCmd)
+	namespaceCmd.AddCommand(recoverCmd)
 	// Commenting until we use -- JH
 	//namespaceCmd.AddCommand(getCmd)
 }
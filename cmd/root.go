@@ -126,9 +126,12 @@ func restartProgram() error {
 func init() {
 	cobra.OnInitialize(config.InitConfig)
 	rootCmd.AddCommand(objectCmd)
+	rootCmd.AddCommand(mountCmd)
+	rootCmd.AddCommand(federationCmd)
 	objectCmd.CompletionOptions.DisableDefaultCmd = true
 	rootCmd.AddCommand(directorCmd)
 	rootCmd.AddCommand(registryCmd)
+	rootCmd.AddCommand(brokerCmd)
 	rootCmd.AddCommand(originCmd)
 	rootCmd.AddCommand(cacheCmd)
 	rootCmd.AddCommand(namespaceCmd)
@@ -136,6 +139,7 @@ func init() {
 	rootCmd.AddCommand(rootPluginCmd)
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(generateCmd)
+	rootCmd.AddCommand(rootTokenCmd)
 	rootCmd.AddCommand(config_printer.ConfigCmd)
 	preferredPrefix := config.GetPreferredPrefix()
 	rootCmd.Use = strings.ToLower(preferredPrefix.String())
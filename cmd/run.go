@@ -0,0 +1,193 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/config"
+)
+
+var (
+	runCmd = &cobra.Command{
+		Use:   "run {job file}",
+		Short: "Run a transfer job described in a YAML file",
+		Long: `Run a transfer job described in a YAML file.
+
+A job lists get, put, and sync steps, the tokens they use, and per-step options such
+as caches, checksums, and retries.  Steps run in the order listed, except that a step
+always runs after the steps named in its dependsOn list; a step whose dependency fails
+is skipped, while independent steps still run unless the job sets failFast.  Once all
+steps have finished, a report of every step is printed.  For example:
+
+  version: 1
+  tokens:
+    analysis:
+      file: /home/user/analysis.tok
+  defaults:
+    token: analysis
+    retries: 2
+  steps:
+    - name: inputs
+      action: sync
+      source: osdf:///ospool/ap20/data/inputs
+      destination: ./inputs
+    - name: results
+      action: put
+      source: ./results
+      destination: osdf:///ospool/ap20/data/results
+      recursive: true
+      dependsOn: [inputs]
+
+Use "-" to read the job from stdin and --dry-run to check the job and print the
+order its steps would run in.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		Run:          runMain,
+	}
+)
+
+func init() {
+	flagSet := runCmd.Flags()
+	flagSet.Bool("dry-run", false, "Validate the job and print its steps in the order they would run")
+	addJSONFlag(flagSet)
+	addProgressFlags(flagSet)
+	rootCmd.AddCommand(runCmd)
+}
+
+// Read a job from a file, or from stdin if the location is "-"
+func readJob(location string) (*client.Job, error) {
+	if location == client.StreamPath {
+		return client.ParseJob(os.Stdin)
+	}
+	fp, err := os.Open(location)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the job file")
+	}
+	defer fp.Close()
+	return client.ParseJob(fp)
+}
+
+func newJSONJobStep(result client.JobStepResult) jsonJobStep {
+	step := jsonJobStep{
+		Name:            result.Step.Name,
+		Action:          string(result.Step.Action),
+		Source:          result.Step.Source,
+		Destination:     result.Step.Destination,
+		Status:          string(result.Status),
+		Attempts:        result.Attempts,
+		Objects:         len(result.Transfers),
+		DurationSeconds: result.Duration.Seconds(),
+		Deleted:         result.Deleted,
+	}
+	for _, transfer := range result.Transfers {
+		step.Bytes += transfer.TransferredBytes
+	}
+	if result.Err != nil {
+		step.Error = result.Err.Error()
+	}
+	return step
+}
+
+// Print a table with the outcome of every step of a job
+func printJobReport(results []client.JobStepResult) {
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 3, ' ', 0)
+	fmt.Fprintln(w, "STEP\tACTION\tSTATUS\tATTEMPTS\tOBJECTS\tSIZE\tTIME\tERROR")
+	for _, result := range results {
+		step := newJSONJobStep(result)
+		fmt.Fprintln(w, step.Name+"\t"+step.Action+"\t"+step.Status+"\t"+strconv.Itoa(step.Attempts)+"\t"+strconv.Itoa(step.Objects)+"\t"+
+			client.ByteCountSI(step.Bytes)+"\t"+result.Duration.Round(time.Millisecond).String()+"\t"+step.Error)
+	}
+	if err := w.Flush(); err != nil {
+		log.Errorln("Failed to print the job report:", err)
+	}
+}
+
+func runMain(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+
+	asJSON, _ := cmd.Flags().GetBool("json")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	job, err := readJob(args[0])
+	if err == nil && !dryRun {
+		err = config.InitClient()
+	}
+	if err != nil {
+		if asJSON {
+			newJSONResult("run").finish(err)
+		}
+		log.Errorln(err)
+		os.Exit(commandExitCode(err))
+	}
+
+	if dryRun {
+		// The job was validated when it was parsed, so its order is known
+		order, _ := job.Order()
+		if asJSON {
+			jsonResult := newJSONResult("run")
+			for _, step := range order {
+				jsonResult.Steps = append(jsonResult.Steps, jsonJobStep{Name: step.Name, Action: string(step.Action), Source: step.Source, Destination: step.Destination, Status: "pending"})
+			}
+			jsonResult.finish(nil)
+			return
+		}
+		for idx, step := range order {
+			dependsOn := ""
+			if len(step.DependsOn) > 0 {
+				dependsOn = " (after " + strings.Join(step.DependsOn, ", ") + ")"
+			}
+			fmt.Printf("%d. %s: %s %s -> %s%s\n", idx+1, step.Name, step.Action, step.Source, step.Destination, dependsOn)
+		}
+		return
+	}
+
+	pb := newProgressBar()
+	pb.launchForCommand(ctx, cmd, asJSON)
+
+	results, err := client.DoJob(ctx, job, client.WithCallback(pb.callback))
+	// Stop the progress bars before printing the report
+	pb.shutdown()
+
+	if asJSON {
+		jsonResult := newJSONResult("run")
+		for _, result := range results {
+			jsonResult.Steps = append(jsonResult.Steps, newJSONJobStep(result))
+			jsonResult.addTransfers(result.Transfers)
+			jsonResult.Deleted = append(jsonResult.Deleted, result.Deleted...)
+		}
+		jsonResult.finish(err)
+		return
+	}
+
+	printJobReport(results)
+	if err != nil {
+		log.Errorln(err)
+		os.Exit(commandExitCode(err))
+	}
+}
@@ -0,0 +1,65 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	rootTokenCmd = &cobra.Command{
+		Use:   "token",
+		Short: "Create and verify tokens",
+		Long: `Create tokens signed by the local issuer key and decode and verify tokens
+issued by a federation's services.`,
+	}
+
+	tokenCreateCmd = &cobra.Command{
+		Use:   "create [claims]",
+		Short: "Create a token signed by the local issuer key",
+		Long: `Create a JSON web token (JWT) signed by the server's issuer key:
+Usage: pelican token create [FLAGS] claims
+E.g. pelican token create --profile wlcg --subject alice --audience https://my-origin.org:8443 --scope storage.read:/foo --lifetime 600
+
+The key in IssuerKey is used unless another is given with --private-key, and the
+issuer defaults to the server's issuer URL.  Additional claims may be given as
+arguments or with --claim, in the form <claim name>=<value>.
+
+The scitokens2 profile requires --audience and --scope, while the wlcg profile
+requires --audience and --subject.`,
+		PreRunE: checkTokenCreateFlags,
+		RunE:    cliTokenCreate,
+	}
+
+	tokenVerifyCmd = &cobra.Command{
+		Use:   "verify [token]",
+		Short: "Decode a token and check it against its issuer",
+		Long:  tokenVerifyLong,
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  verifyToken,
+	}
+)
+
+func init() {
+	rootTokenCmd.PersistentFlags().String("profile", "wlcg", "The token profile to adhere to. Accepted values are scitokens2, wlcg, and none")
+	rootTokenCmd.AddCommand(tokenCreateCmd)
+	addTokenCreateFlags(tokenCreateCmd)
+	rootTokenCmd.AddCommand(tokenVerifyCmd)
+	addTokenVerifyFlags(tokenVerifyCmd)
+}
@@ -68,6 +68,9 @@ type (
 	}
 
 	PrefixEntry struct {
+		// Discovery host of the federation the prefix belongs to.  Entries saved
+		// by older clients have no federation and match the prefix in any federation.
+		Federation string `yaml:"federation,omitempty"`
 		// OSDF namespace prefix
 		Prefix       string       `yaml:"prefix"`
 		ClientID     string       `yaml:"client_id"`
@@ -410,7 +413,7 @@ func discoverFederationImpl(ctx context.Context) (fedInfo pelican_url.Federation
 
 		// We can't really know the service here, so set to generic Pelican
 		ua := "pelican/" + GetVersion()
-		metadata, err = pelican_url.DiscoverFederation(ctx, httpClient, ua, federationUrl)
+		metadata, err = discoverFederationWithCache(ctx, httpClient, ua, federationUrl)
 		if err != nil {
 			err = errors.Wrapf(err, "invalid federation value (%s)", federationStr)
 			return
@@ -523,7 +526,7 @@ func setupTransport() {
 
 	//Set up the transport
 	transport = &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
+		Proxy: ProxyForFederation(param.Federation_DiscoveryUrl.GetString()),
 		DialContext: (&net.Dialer{
 			Timeout:   transportDialerTimeout,
 			KeepAlive: transportKeepAlive,
@@ -690,8 +693,8 @@ func setupTranslation() error {
 // their configuration across multiple directories/files.
 //
 // Config merging is handled by viper. For more information, see https://pkg.go.dev/github.com/spf13/viper#MergeConfig
-func handleContinuedCfg() error {
-	cfgDirs := viper.GetStringSlice("ConfigLocations")
+func handleContinuedCfg(v *viper.Viper) error {
+	cfgDirs := v.GetStringSlice("ConfigLocations")
 	if len(cfgDirs) == 0 {
 		return nil
 	}
@@ -730,7 +733,7 @@ func handleContinuedCfg() error {
 			defer fHandle.Close()
 
 			reader := io.Reader(fHandle)
-			err = viper.MergeConfig(reader)
+			err = v.MergeConfig(reader)
 			if err != nil {
 				return errors.Wrapf(err, "failed to merge extra configuration file %s", filepath.Join(cfgDir, file))
 			}
@@ -743,6 +746,88 @@ func handleContinuedCfg() error {
 	return nil
 }
 
+// List the YAML files directly inside dir in lexicographical order, or none if dir doesn't exist
+func listConfigFragments(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to list configuration fragments in %s", dir)
+	}
+	fragments := []string{}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		fragments = append(fragments, filepath.Join(dir, entry.Name()))
+	}
+	return fragments, nil
+}
+
+func mergeConfigFile(v *viper.Viper, path string) error {
+	fHandle, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open configuration file %s", path)
+	}
+	defer fHandle.Close()
+	if err := v.MergeConfig(fHandle); err != nil {
+		return errors.Wrapf(err, "failed to merge configuration file %s", path)
+	}
+	return nil
+}
+
+// Merge the YAML fragments in ConfigDir/pelican.d on top of the main config file, in lexicographical order.
+// This lets deployment tools drop in a file per concern rather than templating one large pelican.yaml.
+func handleConfigFragments(v *viper.Viper) error {
+	fragmentDir := filepath.Join(v.GetString("ConfigDir"), "pelican.d")
+	fragments, err := listConfigFragments(fragmentDir)
+	if err != nil {
+		return err
+	}
+	for _, fragment := range fragments {
+		if err := mergeConfigFile(v, fragment); err != nil {
+			return err
+		}
+	}
+	if len(fragments) > 0 {
+		log.Infof("Merged configuration fragments from %s: %s", fragmentDir, strings.Join(fragments, ", "))
+	}
+	return nil
+}
+
+// Merge the site and then the instance overlays, named by the ConfigSite and ConfigInstance keys, on top of
+// the base configuration. Overlays live in ConfigDir/sites/<name>.yaml and ConfigDir/instances/<name>.yaml;
+// each overlay is read after the layers below it, so a site overlay may select the instance.
+func handleConfigOverlays(v *viper.Viper) error {
+	configDir := v.GetString("ConfigDir")
+	for _, overlay := range []struct {
+		key string
+		dir string
+	}{
+		{"ConfigSite", "sites"},
+		{"ConfigInstance", "instances"},
+	} {
+		name := v.GetString(overlay.key)
+		if name == "" {
+			continue
+		}
+		if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return errors.Errorf("invalid %s %q: the overlay name may not contain a path separator", overlay.key, name)
+		}
+		overlayFile := filepath.Join(configDir, overlay.dir, name+".yaml")
+		if _, err := os.Stat(overlayFile); err != nil {
+			return errors.Wrapf(err, "the configuration overlay for %s %q could not be loaded", overlay.key, name)
+		}
+		if err := mergeConfigFile(v, overlayFile); err != nil {
+			return err
+		}
+		log.Infof("Merged configuration overlay %s", overlayFile)
+	}
+	return nil
+}
+
 // Read config file from web UI changes, and call viper.Set() to explicitly override the value
 // so that env wouldn't take precedence
 func setWebConfigOverride(v *viper.Viper, configPath string) error {
@@ -837,11 +922,19 @@ func InitConfig() {
 			cobra.CheckErr(err)
 		}
 	}
+	// The configuration is layered as: pelican.yaml, the fragments in pelican.d, the directories listed in
+	// ConfigLocations, then the site and instance overlays
+	if err := handleConfigFragments(viper.GetViper()); err != nil {
+		cobra.CheckErr(err)
+	}
 	// Handle any extra yaml configurations specified in the ConfigLocations key
-	err := handleContinuedCfg()
+	err := handleContinuedCfg(viper.GetViper())
 	if err != nil {
 		cobra.CheckErr(err)
 	}
+	if err := handleConfigOverlays(viper.GetViper()); err != nil {
+		cobra.CheckErr(err)
+	}
 	logLocation := param.Logging_LogLocation.GetString()
 	if logLocation != "" {
 		dir := filepath.Dir(logLocation)
@@ -875,10 +968,15 @@ func InitConfig() {
 	// Warn users about deprecated config keys they're using and try to map them to any new equivalent we've defined.
 	handleDeprecatedConfig()
 
+	// Replace file:, env:, and vault: references in secret-valued parameters with the secrets themselves
+	if err := resolveSecretParams(context.Background(), viper.GetViper(), getSecretsDir()); err != nil {
+		cobra.CheckErr(err)
+	}
+
 	// Spit out a warning if the user has passed config keys that are not recognized
 	// This should work against both config files and appropriately-prefixed env vars
 	if unknownKeys := validateConfigKeys(); len(unknownKeys) > 0 {
-		log.Warningln("Unknown configuration keys found: ", strings.Join(unknownKeys, ", "))
+		log.Warningln("Unknown configuration keys found: ", describeUnknownKeys(unknownKeys))
 	}
 
 	onceValidate.Do(func() {
@@ -1024,6 +1122,7 @@ func SetServerDefaults(v *viper.Viper) error {
 	v.SetDefault(param.Server_SessionSecretFile.GetName(), filepath.Join(configDir, "session-secret"))
 	v.SetDefault(param.Xrootd_RobotsTxtFile.GetName(), filepath.Join(configDir, "robots.txt"))
 	v.SetDefault(param.Xrootd_ScitokensConfig.GetName(), filepath.Join(configDir, "xrootd", "scitokens.cfg"))
+	v.SetDefault(param.Xrootd_ConfigExtras.GetName(), filepath.Join(configDir, "xrootd", "config.d"))
 	v.SetDefault(param.Xrootd_Authfile.GetName(), filepath.Join(configDir, "xrootd", "authfile"))
 	v.SetDefault(param.Xrootd_MacaroonsKeyFile.GetName(), filepath.Join(configDir, "macaroons-secret"))
 	v.SetDefault(param.IssuerKey.GetName(), filepath.Join(configDir, "issuer.jwk"))
@@ -1039,6 +1138,7 @@ func SetServerDefaults(v *viper.Viper) error {
 	// Set up the default S3 URL style to be path-style here as opposed to in the defaults.yaml becase
 	// we want to be able to check if this is user-provided (which we can't do for defaults.yaml)
 	v.SetDefault(param.Origin_S3UrlStyle.GetName(), "path")
+	setDiscoveryCacheDefault(v, configDir)
 
 	if IsRootExecution() {
 		v.SetDefault(param.Origin_RunLocation.GetName(), filepath.Join("/run", "pelican", "xrootd", "origin"))
@@ -1057,9 +1157,13 @@ func SetServerDefaults(v *viper.Viper) error {
 		v.SetDefault(param.LocalCache_RunLocation.GetName(), filepath.Join("/run", "pelican", "localcache"))
 		v.SetDefault(param.Origin_Multiuser.GetName(), true)
 		v.SetDefault(param.Origin_DbLocation.GetName(), "/var/lib/pelican/origin.sqlite")
+		v.SetDefault(param.Origin_VersionsLocation.GetName(), "/var/lib/pelican/origin-versions")
+		v.SetDefault(param.Origin_UploadScanLocation.GetName(), "/var/lib/pelican/origin-upload-scan")
+		v.SetDefault(param.Origin_UploadStagingLocation.GetName(), "/var/lib/pelican/origin-upload-staging")
 		v.SetDefault(param.Director_GeoIPLocation.GetName(), "/var/cache/pelican/maxmind/GeoLite2-City.mmdb")
 		v.SetDefault(param.Registry_DbLocation.GetName(), "/var/lib/pelican/registry.sqlite")
 		v.SetDefault(param.Director_DbLocation.GetName(), "/var/lib/pelican/director.sqlite")
+		v.SetDefault(param.Server_UIAccountDbLocation.GetName(), "/var/lib/pelican/server-ui-accounts.sqlite")
 		// The lotman db will actually take this path and create the lot at /path/.lot/lotman_cpp.sqlite
 		v.SetDefault(param.Lotman_DbLocation.GetName(), "/var/lib/pelican")
 		v.SetDefault(param.Monitoring_DataLocation.GetName(), "/var/lib/pelican/monitoring/data")
@@ -1068,9 +1172,13 @@ func SetServerDefaults(v *viper.Viper) error {
 		v.SetDefault(param.Origin_GlobusConfigLocation.GetName(), filepath.Join("/run", "pelican", "xrootd", "origin", "globus"))
 	} else {
 		v.SetDefault(param.Origin_DbLocation.GetName(), filepath.Join(configDir, "origin.sqlite"))
+		v.SetDefault(param.Origin_VersionsLocation.GetName(), filepath.Join(configDir, "origin-versions"))
+		v.SetDefault(param.Origin_UploadScanLocation.GetName(), filepath.Join(configDir, "origin-upload-scan"))
+		v.SetDefault(param.Origin_UploadStagingLocation.GetName(), filepath.Join(configDir, "origin-upload-staging"))
 		v.SetDefault(param.Director_GeoIPLocation.GetName(), filepath.Join(configDir, "maxmind", "GeoLite2-City.mmdb"))
 		v.SetDefault(param.Registry_DbLocation.GetName(), filepath.Join(configDir, "ns-registry.sqlite"))
 		v.SetDefault(param.Director_DbLocation.GetName(), filepath.Join(configDir, "director.sqlite"))
+		v.SetDefault(param.Server_UIAccountDbLocation.GetName(), filepath.Join(configDir, "server-ui-accounts.sqlite"))
 		// Lotdb will live at <configDir>/.lot/lotman_cpp.sqlite
 		v.SetDefault(param.Lotman_DbLocation.GetName(), configDir)
 		v.SetDefault(param.Monitoring_DataLocation.GetName(), filepath.Join(configDir, "monitoring/data"))
@@ -1125,14 +1233,17 @@ func SetServerDefaults(v *viper.Viper) error {
 		return errors.Wrapf(err, "Failure when setting up OS-specific configuration")
 	}
 
-	hostname, err := os.Hostname()
-	if err != nil {
-		return err
+	// Keep a default set earlier, such as the loopback hostname of `pelican serve --module all`
+	if !v.IsSet(param.Server_Hostname.GetName()) {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		v.SetDefault(param.Server_Hostname.GetName(), hostname)
 	}
-	v.SetDefault(param.Server_Hostname.GetName(), hostname)
 	// For the rest of the function, use the hostname provided by the admin if
 	// they have overridden the defaults.
-	hostname = v.GetString("Server.Hostname")
+	hostname := v.GetString("Server.Hostname")
 	// We default to the value of Server.Hostname, which defaults to os.Hostname but can be overwritten
 	v.SetDefault(param.Xrootd_Sitename.GetName(), hostname)
 
@@ -1256,6 +1367,10 @@ func InitServer(ctx context.Context, currentServers server_structs.ServerType) e
 		cobra.CheckErr(errors.Wrapf(err, "failed to override configuration based on changes from web UI"))
 	}
 
+	if err := ValidateConfig(currentServers); err != nil {
+		return err
+	}
+
 	if !IsRootExecution() {
 		var runtimeDir string
 		if userRuntimeDir := os.Getenv("XDG_RUNTIME_DIR"); userRuntimeDir != "" {
@@ -1288,9 +1403,6 @@ func InitServer(ctx context.Context, currentServers server_structs.ServerType) e
 		switch ost {
 		case "https":
 			httpSvcUrl := param.Origin_HttpServiceUrl.GetString()
-			if httpSvcUrl == "" {
-				return errors.New("Origin.HTTPServiceUrl may not be empty when the origin is configured with an https backend")
-			}
 			_, err := url.Parse(httpSvcUrl)
 			if err != nil {
 				return errors.Wrap(err, "unable to parse Origin.HTTPServiceUrl as a URL")
@@ -1322,18 +1434,12 @@ func InitServer(ctx context.Context, currentServers server_structs.ServerType) e
 			}
 		case "xroot":
 			xrootSvcUrl := param.Origin_XRootServiceUrl.GetString()
-			if xrootSvcUrl == "" {
-				return errors.New("Origin.XRootServiceUrl may not be empty when the origin is configured with an xroot backend")
-			}
 			_, err := url.Parse(xrootSvcUrl)
 			if err != nil {
 				return errors.Wrap(err, "unable to parse Origin.XrootServiceUrl as a URL")
 			}
 		case "s3":
 			s3SvcUrl := param.Origin_S3ServiceUrl.GetString()
-			if s3SvcUrl == "" {
-				return errors.New("Origin.S3ServiceUrl may not be empty when the origin is configured with an s3 backend")
-			}
 			_, err := url.Parse(s3SvcUrl)
 			if err != nil {
 				return errors.Wrap(err, "unable to parse Origin.S3ServiceUrl as a URL")
@@ -1488,8 +1594,17 @@ func SetClientDefaults(v *viper.Viper) error {
 	if upperPrefix == OsdfPrefix || upperPrefix == StashPrefix {
 		v.SetDefault("Federation.TopologyNamespaceURL", "https://topology.opensciencegrid.org/osdf/namespaces")
 	}
+	setDiscoveryCacheDefault(v, configDir)
 	// Set our default worker count
 	v.SetDefault(param.Client_WorkerCount.GetName(), 5)
+	// The transfer daemon's socket lives with the user's runtime files
+	if IsRootExecution() {
+		v.SetDefault(param.Client_DaemonSocket.GetName(), filepath.Join("/run", "pelican", "client.sock"))
+	} else if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		v.SetDefault(param.Client_DaemonSocket.GetName(), filepath.Join(runtimeDir, "pelican", "client.sock"))
+	} else {
+		v.SetDefault(param.Client_DaemonSocket.GetName(), filepath.Join(configDir, "client.sock"))
+	}
 	v.SetDefault(param.Server_TLSCACertificateFile.GetName(), filepath.Join(configDir, "certificates", "tlsca.pem"))
 
 	var downloadLimit int64 = 1024 * 100
@@ -1631,6 +1746,7 @@ func ResetConfig() {
 	// Clear cached transport object
 	onceTransport = sync.Once{}
 	transport = nil
+	resetProxyOverrides()
 
 	// Reset federation metadata
 	fedDiscoveryOnce = &sync.Once{}
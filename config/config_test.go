@@ -296,7 +296,7 @@ func TestExtraCfg(t *testing.T) {
 	})
 
 	t.Run("test-no-continue", func(t *testing.T) {
-		err := handleContinuedCfg()
+		err := handleContinuedCfg(viper.GetViper())
 		assert.NoError(t, err)
 	})
 
@@ -307,7 +307,7 @@ func TestExtraCfg(t *testing.T) {
 
 		// If there are no files in a directory pointed to by ConfigLocations, we should not error
 		// We should also do no config merging
-		err := handleContinuedCfg()
+		err := handleContinuedCfg(viper.GetViper())
 		assert.NoError(t, err)
 		// Check the other value in our original config to make sure we didn't simply overwrite
 		assert.Equal(t, "bar", viper.GetString("OtherVal"))
@@ -323,7 +323,7 @@ func TestExtraCfg(t *testing.T) {
 		err := os.WriteFile(continueFile, []byte("TestVal: foo"), 0644)
 		require.NoError(t, err)
 
-		err = handleContinuedCfg()
+		err = handleContinuedCfg(viper.GetViper())
 		assert.NoError(t, err)
 		assert.Equal(t, "foo", viper.GetString("TestVal"))
 		// Check the other value in our original config to make sure we didn't simply overwrite
@@ -343,7 +343,7 @@ func TestExtraCfg(t *testing.T) {
 		}
 
 		// Because we've configured ConfigLocations: [dir1, dir2], we should expect the value from dir1 to be overwritten by the value from dir2
-		err := handleContinuedCfg()
+		err := handleContinuedCfg(viper.GetViper())
 		assert.NoError(t, err)
 		assert.Equal(t, "foo-1", viper.GetString("TestVal"))
 		// Any previously-undefined keys should still be picked up (ie they're new and not a "patch")
@@ -372,7 +372,7 @@ func TestExtraCfg(t *testing.T) {
 			counter += 1
 		}
 
-		err := handleContinuedCfg()
+		err := handleContinuedCfg(viper.GetViper())
 		assert.NoError(t, err)
 		assert.Equal(t, "foo-1-1", viper.GetString("TestVal"))
 		// Check the other value in our original config to make sure we didn't simply overwrite the entire config with our new values
@@ -388,12 +388,77 @@ func TestExtraCfg(t *testing.T) {
 		err := os.WriteFile(continueFile, []byte("TestVal: foo"), 0644)
 		require.NoError(t, err)
 
-		err = handleContinuedCfg()
+		err = handleContinuedCfg(viper.GetViper())
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "does not exist")
 	})
 }
 
+// Test that pelican.d fragments and the site/instance overlays are merged in order
+func TestConfigLayers(t *testing.T) {
+	ResetConfig()
+	t.Cleanup(func() {
+		ResetConfig()
+	})
+
+	writeFile := func(t *testing.T, path string, contents string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+
+	t.Run("no-fragments-or-overlays", func(t *testing.T) {
+		ResetConfig()
+		viper.Set("ConfigDir", t.TempDir())
+		assert.NoError(t, handleConfigFragments(viper.GetViper()))
+		assert.NoError(t, handleConfigOverlays(viper.GetViper()))
+	})
+
+	t.Run("fragments-in-lexicographical-order", func(t *testing.T) {
+		ResetConfig()
+		configDir := t.TempDir()
+		viper.SetConfigType("yaml")
+		viper.Set("ConfigDir", configDir)
+		writeFile(t, filepath.Join(configDir, "pelican.d", "10-server.yaml"), "Server:\n  WebPort: 1000\n  Hostname: one.example.org")
+		writeFile(t, filepath.Join(configDir, "pelican.d", "20-server.yml"), "Server:\n  WebPort: 2000")
+		writeFile(t, filepath.Join(configDir, "pelican.d", "30-ignored.txt"), "Server:\n  WebPort: 3000")
+		writeFile(t, filepath.Join(configDir, "pelican.d", "nested", "40-ignored.yaml"), "Server:\n  WebPort: 4000")
+
+		require.NoError(t, handleConfigFragments(viper.GetViper()))
+		assert.Equal(t, 2000, viper.GetInt("Server.WebPort"))
+		// Nested keys from earlier fragments survive
+		assert.Equal(t, "one.example.org", viper.GetString("Server.Hostname"))
+	})
+
+	t.Run("site-then-instance-overlay", func(t *testing.T) {
+		ResetConfig()
+		configDir := t.TempDir()
+		viper.SetConfigType("yaml")
+		viper.Set("ConfigDir", configDir)
+		writeFile(t, filepath.Join(configDir, "pelican.d", "base.yaml"), "ConfigSite: chtc\nServer:\n  WebPort: 1000\n  Hostname: base.example.org")
+		writeFile(t, filepath.Join(configDir, "sites", "chtc.yaml"), "ConfigInstance: cache-1\nServer:\n  WebPort: 2000\n  Hostname: site.example.org")
+		writeFile(t, filepath.Join(configDir, "instances", "cache-1.yaml"), "Server:\n  Hostname: cache-1.example.org")
+
+		require.NoError(t, handleConfigFragments(viper.GetViper()))
+		require.NoError(t, handleConfigOverlays(viper.GetViper()))
+		assert.Equal(t, 2000, viper.GetInt("Server.WebPort"))
+		assert.Equal(t, "cache-1.example.org", viper.GetString("Server.Hostname"))
+	})
+
+	t.Run("missing-overlay", func(t *testing.T) {
+		ResetConfig()
+		viper.Set("ConfigDir", t.TempDir())
+		viper.Set("ConfigSite", "nowhere")
+		assert.Error(t, handleConfigOverlays(viper.GetViper()))
+	})
+
+	t.Run("overlay-name-with-path", func(t *testing.T) {
+		ResetConfig()
+		viper.Set("ConfigDir", t.TempDir())
+		viper.Set("ConfigInstance", "../secrets")
+		assert.Error(t, handleConfigOverlays(viper.GetViper()))
+	})
+}
+
 func TestDeprecateLogMessage(t *testing.T) {
 	tmpPathPattern := "TestOrigin*"
 	tmpPath, err := os.MkdirTemp("", tmpPathPattern)
@@ -19,15 +19,85 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 
 	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
 )
 
+type (
+	// A single problem found while validating the configuration
+	ConfigProblem struct {
+		Key     string
+		Message string
+	}
+
+	// Every problem found while validating the configuration, so that an
+	// admin can fix them all in one pass instead of one restart at a time
+	ConfigValidationError struct {
+		Problems []ConfigProblem
+	}
+
+	// A parameter that must be set when one of the modules is enabled and
+	// the condition, if any, holds
+	requiredParam struct {
+		name      string
+		modules   server_structs.ServerType
+		condition func() bool
+		reason    string
+	}
+)
+
+var (
+	requiredParams = []requiredParam{
+		{
+			name:      param.Origin_HttpServiceUrl.GetName(),
+			modules:   server_structs.OriginType,
+			condition: func() bool { return param.Origin_StorageType.GetString() == "https" },
+			reason:    "the origin is configured with an https backend",
+		},
+		{
+			name:      param.Origin_XRootServiceUrl.GetName(),
+			modules:   server_structs.OriginType,
+			condition: func() bool { return param.Origin_StorageType.GetString() == "xroot" },
+			reason:    "the origin is configured with an xroot backend",
+		},
+		{
+			name:      param.Origin_S3ServiceUrl.GetName(),
+			modules:   server_structs.OriginType,
+			condition: func() bool { return param.Origin_StorageType.GetString() == "s3" },
+			reason:    "the origin is configured with an s3 backend",
+		},
+		{
+			name:      param.Cache_LocalHttpNetworks.GetName(),
+			modules:   server_structs.CacheType,
+			condition: param.Cache_EnableLocalHttp.GetBool,
+			reason:    "Cache.EnableLocalHttp is set",
+		},
+	}
+)
+
+func (e *ConfigValidationError) Error() string {
+	var sb strings.Builder
+	if len(e.Problems) == 1 {
+		sb.WriteString("found 1 problem in the configuration:")
+	} else {
+		fmt.Fprintf(&sb, "found %d problems in the configuration:", len(e.Problems))
+	}
+	for _, problem := range e.Problems {
+		fmt.Fprintf(&sb, "\n  - %s: %s", problem.Key, problem.Message)
+	}
+	return sb.String()
+}
+
 // findFieldByTag searches for a field in a struct by the value of a tag. This is used to
 // check our Config struct against viper keys so we can warn the users if they feed the Pelican things
 // it doesn't know how to eat.
@@ -42,10 +112,9 @@ func findFieldByTag(t reflect.Type, tagKey, tagValue string) (reflect.StructFiel
 	return reflect.StructField{}, false
 }
 
-// validateConfigKeys checks keys in the Viper config against fields in the Config struct
-func validateConfigKeys() []string {
-	possibleCfg := param.Config{}
-	unknownKeys := []string{}
+// configuredKeys returns the lowercase keys of every value Viper knows about,
+// including values that would only be discovered through environment variables
+func configuredKeys() []string {
 	// Get all currently-configured keys from Viper. This is a collection of default
 	// configurations (both set internally and in defaults.yaml) and user-provided config.
 	keys := viper.AllKeys()
@@ -64,6 +133,14 @@ func validateConfigKeys() []string {
 			keys = append(keys, key)
 		}
 	}
+	return keys
+}
+
+// validateConfigKeys checks keys in the Viper config against fields in the Config struct
+func validateConfigKeys() []string {
+	possibleCfg := param.Config{}
+	unknownKeys := []string{}
+	keys := configuredKeys()
 
 	// Convert the config struct to a map
 	configValue := reflect.ValueOf(possibleCfg)
@@ -100,3 +177,218 @@ func validateConfigKeys() []string {
 
 	return unknownKeys
 }
+
+// knownConfigKeys lists the names of every parameter in the Config struct,
+// e.g. "Origin.FederationPrefix"
+func knownConfigKeys(t reflect.Type, prefix string) []string {
+	names := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := prefix + field.Tag.Get("yaml")
+		if field.Type.Kind() == reflect.Struct {
+			names = append(names, knownConfigKeys(field.Type, name+".")...)
+		} else {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// editDistance computes the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// suggestConfigKey returns the known parameter closest to an unknown key, or
+// an empty string if nothing is close enough to be a plausible typo
+func suggestConfigKey(key string) string {
+	best := ""
+	bestDistance := 0
+	for _, name := range knownConfigKeys(reflect.TypeOf(param.Config{}), "") {
+		distance := editDistance(strings.ToLower(key), strings.ToLower(name))
+		if best == "" || distance < bestDistance {
+			best = name
+			bestDistance = distance
+		}
+	}
+	if bestDistance > 2 && bestDistance > len(key)/5 {
+		return ""
+	}
+	return best
+}
+
+// describeUnknownKeys formats unknown keys for the user, pointing out the
+// parameter each one was most likely meant to be
+func describeUnknownKeys(unknownKeys []string) string {
+	descriptions := make([]string, 0, len(unknownKeys))
+	for _, key := range unknownKeys {
+		if suggestion := suggestConfigKey(key); suggestion != "" {
+			descriptions = append(descriptions, fmt.Sprintf("%s (did you mean %s?)", key, suggestion))
+		} else {
+			descriptions = append(descriptions, key)
+		}
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// Whether the user set the key in a config file or through the environment,
+// as opposed to it holding a default
+func isUserSet(key string) bool {
+	return viper.InConfig(key) || envOverridesKey(strings.ToLower(key))
+}
+
+// Describe the value a field of the Config struct expects and check the
+// configured value against it
+func checkConfigType(fieldType reflect.Type, value interface{}) (string, error) {
+	var err error
+	switch {
+	case fieldType == reflect.TypeOf(time.Duration(0)):
+		_, err = cast.ToDurationE(value)
+		return "a duration such as 30s or 5m", err
+	case fieldType.Kind() == reflect.Bool:
+		_, err = cast.ToBoolE(value)
+		return "a boolean", err
+	case fieldType.Kind() == reflect.Int:
+		_, err = cast.ToIntE(value)
+		return "an integer", err
+	case fieldType.Kind() == reflect.String:
+		_, err = cast.ToStringE(value)
+		return "a string", err
+	case fieldType.Kind() == reflect.Slice:
+		_, err = cast.ToStringSliceE(value)
+		return "a list of strings", err
+	case fieldType.Kind() == reflect.Struct:
+		_, err = cast.ToStringMapE(value)
+		return "a section of nested parameters", err
+	}
+	// Object parameters are validated by the modules that consume them
+	return "", nil
+}
+
+// validateConfigTypes checks every configured value against the type of its
+// parameter in the Config struct
+func validateConfigTypes() []ConfigProblem {
+	problems := []ConfigProblem{}
+	checked := map[string]bool{}
+	configType := reflect.TypeOf(param.Config{})
+	for _, key := range configuredKeys() {
+		currentType := configType
+		fieldKey := []string{}
+		var fieldType reflect.Type
+		for _, part := range strings.Split(key, ".") {
+			field, present := findFieldByTag(currentType, "mapstructure", part)
+			if !present {
+				// Unknown keys are reported by validateConfigKeys
+				fieldType = nil
+				break
+			}
+			fieldKey = append(fieldKey, part)
+			fieldType = field.Type
+			if field.Type.Kind() != reflect.Struct {
+				break
+			}
+			currentType = field.Type
+		}
+		name := strings.Join(fieldKey, ".")
+		if fieldType == nil || checked[name] {
+			continue
+		}
+		checked[name] = true
+
+		value := viper.Get(name)
+		if value == nil {
+			continue
+		}
+		if expected, err := checkConfigType(fieldType, value); err != nil {
+			problems = append(problems, ConfigProblem{
+				Key:     canonicalConfigKey(name),
+				Message: fmt.Sprintf("expected %s but got %#v", expected, value),
+			})
+		}
+	}
+	return problems
+}
+
+// validateDeprecatedConflicts finds deprecated parameters set alongside their
+// replacements. The replacement silently wins, so the two may only be set at
+// the same time if they agree.
+func validateDeprecatedConflicts() []ConfigProblem {
+	problems := []ConfigProblem{}
+	for deprecated, replacements := range param.GetDeprecated() {
+		if !isUserSet(deprecated) {
+			continue
+		}
+		for _, replacement := range replacements {
+			if replacement == "none" || !isUserSet(replacement) {
+				continue
+			}
+			if reflect.DeepEqual(viper.Get(deprecated), viper.Get(replacement)) {
+				continue
+			}
+			problems = append(problems, ConfigProblem{
+				Key: deprecated,
+				Message: fmt.Sprintf("is deprecated and conflicts with %s, which is set to a different value; "+
+					"remove %s and keep only %s", replacement, deprecated, replacement),
+			})
+		}
+	}
+	return problems
+}
+
+// validateRequiredParams finds parameters that must be set for the enabled modules
+func validateRequiredParams(servers server_structs.ServerType) []ConfigProblem {
+	problems := []ConfigProblem{}
+	for _, required := range requiredParams {
+		if servers&required.modules == 0 {
+			continue
+		}
+		if required.condition != nil && !required.condition() {
+			continue
+		}
+		if value := viper.Get(required.name); value != nil {
+			if values, err := cast.ToStringSliceE(value); err == nil && len(values) > 0 && strings.Join(values, "") != "" {
+				continue
+			}
+		}
+		problems = append(problems, ConfigProblem{
+			Key:     required.name,
+			Message: fmt.Sprintf("is required because %s", required.reason),
+		})
+	}
+	return problems
+}
+
+// ValidateConfig checks the configuration against the parameter schema for the
+// enabled modules: values of the wrong type, deprecated parameters that conflict
+// with their replacements, and required parameters that are missing. Every problem
+// found is returned in a single ConfigValidationError. Unknown keys are only
+// warned about during InitConfig so that newer configs keep working with older
+// Pelican releases.
+func ValidateConfig(servers server_structs.ServerType) error {
+	problems := validateConfigTypes()
+	problems = append(problems, validateDeprecatedConflicts()...)
+	problems = append(problems, validateRequiredParams(servers)...)
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return strings.ToLower(problems[i].Key) < strings.ToLower(problems[j].Key)
+	})
+	return &ConfigValidationError{Problems: problems}
+}
@@ -20,6 +20,7 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -27,6 +28,8 @@ import (
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
 )
 
 // Test that Pelican notifies users about unrecognized configuration keys.
@@ -82,3 +85,75 @@ func TestBadConfigKeys(t *testing.T) {
 		assert.Contains(t, hook.LastEntry().Message, "origin.bad.key")
 	})
 }
+
+func TestSuggestConfigKey(t *testing.T) {
+	assert.Equal(t, "Origin.FederationPrefix", suggestConfigKey("origin.federationprefx"))
+	assert.Equal(t, "Server.WebPort", suggestConfigKey("server.webprot"))
+	assert.Empty(t, suggestConfigKey("origin.bad.key"))
+	assert.Equal(t, "server.webprot (did you mean Server.WebPort?), origin.bad.key",
+		describeUnknownKeys([]string{"server.webprot", "origin.bad.key"}))
+}
+
+func TestValidateConfig(t *testing.T) {
+	t.Cleanup(func() { ResetConfig() })
+
+	readConfig := func(t *testing.T, lines ...string) {
+		ResetConfig()
+		viper.SetConfigType("yaml")
+		require.NoError(t, viper.ReadConfig(strings.NewReader(strings.Join(lines, "\n"))))
+	}
+	problemKeys := func(err error) []string {
+		var validationErr *ConfigValidationError
+		if !assert.ErrorAs(t, err, &validationErr) {
+			return nil
+		}
+		keys := []string{}
+		for _, problem := range validationErr.Problems {
+			keys = append(keys, problem.Key)
+		}
+		return keys
+	}
+
+	t.Run("valid-config", func(t *testing.T) {
+		readConfig(t, "Server:", "  WebPort: 8444", "Cache:", "  SelfTestInterval: 15s", "  DataLocations: [/a, /b]")
+		assert.NoError(t, ValidateConfig(server_structs.CacheType))
+	})
+
+	t.Run("type-mismatches", func(t *testing.T) {
+		readConfig(t, "Server:", "  WebPort: eighty", "  EnableUI: maybe", "Cache:", "  SelfTestInterval: soon", "Logging: debug")
+		err := ValidateConfig(server_structs.CacheType)
+		assert.Equal(t, []string{"Cache.SelfTestInterval", "Logging", "Server.EnableUI", "Server.WebPort"}, problemKeys(err))
+		assert.Contains(t, err.Error(), "found 4 problems in the configuration")
+		assert.Contains(t, err.Error(), "Server.WebPort: expected an integer")
+	})
+
+	t.Run("type-mismatch-from-env", func(t *testing.T) {
+		ResetConfig()
+		t.Setenv("PELICAN_SERVER_WEBPORT", "eighty")
+		viper.SetEnvPrefix("pelican")
+		viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+		viper.AutomaticEnv()
+		assert.Equal(t, []string{"Server.WebPort"}, problemKeys(ValidateConfig(server_structs.CacheType)))
+	})
+
+	t.Run("deprecated-conflicts", func(t *testing.T) {
+		readConfig(t, "Origin:", "  EnableWrite: true", "  EnableWrites: true")
+		assert.NoError(t, ValidateConfig(server_structs.OriginType))
+
+		readConfig(t, "Origin:", "  EnableWrite: true", "  EnableWrites: false")
+		err := ValidateConfig(server_structs.OriginType)
+		assert.Equal(t, []string{"Origin.EnableWrite"}, problemKeys(err))
+		assert.Contains(t, err.Error(), "conflicts with Origin.EnableWrites")
+	})
+
+	t.Run("required-per-module", func(t *testing.T) {
+		readConfig(t, "Origin:", "  StorageType: s3", "Cache:", "  EnableLocalHttp: true")
+		assert.Equal(t, []string{"Origin.S3ServiceUrl"}, problemKeys(ValidateConfig(server_structs.OriginType)))
+		assert.Equal(t, []string{"Cache.LocalHttpNetworks"}, problemKeys(ValidateConfig(server_structs.CacheType)))
+		assert.Equal(t, []string{"Cache.LocalHttpNetworks", "Origin.S3ServiceUrl"},
+			problemKeys(ValidateConfig(server_structs.OriginType|server_structs.CacheType)))
+
+		readConfig(t, "Origin:", "  StorageType: s3", "  S3ServiceUrl: https://s3.example.com")
+		assert.NoError(t, ValidateConfig(server_structs.OriginType))
+	})
+}
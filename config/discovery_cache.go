@@ -0,0 +1,139 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/pelican_url"
+)
+
+type (
+	// The on-disk copy of a federation's discovery metadata
+	cachedDiscovery struct {
+		DiscoveryUrl string                          `json:"discovery_url"`
+		Fetched      time.Time                       `json:"fetched"`
+		Metadata     pelican_url.FederationDiscovery `json:"metadata"`
+	}
+)
+
+func setDiscoveryCacheDefault(v *viper.Viper, configDir string) {
+	if IsRootExecution() {
+		v.SetDefault(param.Federation_DiscoveryCacheLocation.GetName(), filepath.Join("/var", "cache", "pelican", "federation-discovery"))
+	} else {
+		v.SetDefault(param.Federation_DiscoveryCacheLocation.GetName(), filepath.Join(configDir, "federation-discovery"))
+	}
+}
+
+// The file caching the discovery metadata of the federation at discoveryUrl
+func discoveryCacheFile(cacheDir string, discoveryUrl *url.URL) string {
+	return filepath.Join(cacheDir, strings.ReplaceAll(strings.ToLower(discoveryUrl.Host), ":", "_")+".json")
+}
+
+// Read the cached discovery metadata for discoveryUrl, returning it with the
+// time it was fetched. Copies older than ttl are not used.
+func readCachedDiscovery(cacheDir string, discoveryUrl *url.URL, ttl time.Duration, now time.Time) (pelican_url.FederationDiscovery, time.Time, error) {
+	contents, err := os.ReadFile(discoveryCacheFile(cacheDir, discoveryUrl))
+	if err != nil {
+		return pelican_url.FederationDiscovery{}, time.Time{}, errors.Wrap(err, "no cached federation metadata")
+	}
+	var cached cachedDiscovery
+	if err := json.Unmarshal(contents, &cached); err != nil {
+		return pelican_url.FederationDiscovery{}, time.Time{}, errors.Wrap(err, "failed to parse the cached federation metadata")
+	}
+	if cached.DiscoveryUrl != discoveryUrl.String() {
+		return pelican_url.FederationDiscovery{}, time.Time{}, errors.Errorf("the cached federation metadata is for %s", cached.DiscoveryUrl)
+	}
+	if age := now.Sub(cached.Fetched); age > ttl {
+		return pelican_url.FederationDiscovery{}, time.Time{}, errors.Errorf("the cached federation metadata expired %s ago", (age - ttl).Round(time.Second))
+	}
+	return cached.Metadata, cached.Fetched, nil
+}
+
+func writeCachedDiscovery(cacheDir string, discoveryUrl *url.URL, metadata pelican_url.FederationDiscovery, now time.Time) error {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create the federation metadata cache directory")
+	}
+	contents, err := json.MarshalIndent(cachedDiscovery{DiscoveryUrl: discoveryUrl.String(), Fetched: now, Metadata: metadata}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(cacheDir, ".discovery-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to write the federation metadata cache")
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(contents); err != nil {
+		tmpFile.Close()
+		return errors.Wrap(err, "failed to write the federation metadata cache")
+	}
+	if err := tmpFile.Close(); err != nil {
+		return errors.Wrap(err, "failed to write the federation metadata cache")
+	}
+	if err := os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return errors.Wrap(err, "failed to write the federation metadata cache")
+	}
+	return errors.Wrap(os.Rename(tmpFile.Name(), discoveryCacheFile(cacheDir, discoveryUrl)), "failed to write the federation metadata cache")
+}
+
+// Discover the federation's services, caching the result on disk. If the
+// discovery host can't be reached, an unexpired cached copy is used instead so
+// a transient network or DNS failure doesn't stop clients and servers from
+// starting; errors returned by a reachable host are never masked.
+func discoverFederationWithCache(ctx context.Context, httpClient *http.Client, ua string, discoveryUrl *url.URL) (pelican_url.FederationDiscovery, error) {
+	cacheDir := param.Federation_DiscoveryCacheLocation.GetString()
+	ttl := param.Federation_DiscoveryCacheTTL.GetDuration()
+	// DiscoverFederation sets the path of the URL it's handed
+	cacheKey := *discoveryUrl
+	metadata, err := pelican_url.DiscoverFederation(ctx, httpClient, ua, discoveryUrl)
+	if cacheDir == "" || ttl <= 0 {
+		return metadata, err
+	}
+	now := time.Now()
+	if err == nil {
+		if cacheErr := writeCachedDiscovery(cacheDir, &cacheKey, metadata, now); cacheErr != nil {
+			log.Debugln("Failed to cache the federation metadata:", cacheErr)
+		}
+		return metadata, nil
+	}
+
+	var metadataErr *pelican_url.MetadataErr
+	if !errors.As(err, &metadataErr) {
+		return metadata, err
+	}
+	cached, fetched, cacheErr := readCachedDiscovery(cacheDir, &cacheKey, ttl, now)
+	if cacheErr != nil {
+		log.Debugf("Unable to fall back to cached federation metadata for %s: %v", cacheKey.String(), cacheErr)
+		return metadata, err
+	}
+	log.Warningf("Federation discovery at %s failed (%v); using the metadata cached %s ago", cacheKey.String(), err, now.Sub(fetched).Round(time.Second))
+	return cached, nil
+}
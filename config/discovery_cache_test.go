@@ -0,0 +1,95 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/pelican_url"
+)
+
+func TestDiscoverFederationWithCache(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set("Federation.DiscoveryCacheLocation", t.TempDir())
+	viper.Set("Federation.DiscoveryCacheTTL", time.Hour)
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != pelican_url.PelicanDiscoveryPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"director_endpoint": "https://director.example.com", "namespace_registration_endpoint": "https://registry.example.com"}`))
+	}))
+	discoveryUrl, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	discover := func() (pelican_url.FederationDiscovery, error) {
+		fedUrl := *discoveryUrl
+		return discoverFederationWithCache(context.Background(), server.Client(), "pelican-test", &fedUrl)
+	}
+
+	metadata, err := discover()
+	require.NoError(t, err)
+	assert.Equal(t, "https://director.example.com", metadata.DirectorEndpoint)
+
+	// Errors from a reachable discovery host aren't masked by the cache
+	status = http.StatusInternalServerError
+	_, err = discover()
+	assert.ErrorContains(t, err, "HTTP status 500")
+
+	// An unreachable discovery host falls back to the cached copy
+	server.Close()
+	metadata, err = discover()
+	require.NoError(t, err)
+	assert.Equal(t, "https://registry.example.com", metadata.RegistryEndpoint)
+
+	viper.Set("Federation.DiscoveryCacheTTL", 0)
+	_, err = discover()
+	assert.Error(t, err)
+}
+
+func TestReadCachedDiscovery(t *testing.T) {
+	cacheDir := t.TempDir()
+	discoveryUrl := &url.URL{Scheme: "https", Host: "Fed.example.com:8443"}
+	now := time.Now()
+	metadata := pelican_url.FederationDiscovery{DirectorEndpoint: "https://director.example.com"}
+	require.NoError(t, writeCachedDiscovery(cacheDir, discoveryUrl, metadata, now.Add(-2*time.Hour)))
+
+	cached, fetched, err := readCachedDiscovery(cacheDir, discoveryUrl, 3*time.Hour, now)
+	require.NoError(t, err)
+	assert.Equal(t, metadata, cached)
+	assert.True(t, fetched.Equal(now.Add(-2*time.Hour)))
+
+	_, _, err = readCachedDiscovery(cacheDir, discoveryUrl, time.Hour, now)
+	assert.ErrorContains(t, err, "expired")
+
+	// A different scheme for the same host must not reuse the copy
+	_, _, err = readCachedDiscovery(cacheDir, &url.URL{Scheme: "http", Host: "Fed.example.com:8443"}, 3*time.Hour, now)
+	assert.Error(t, err)
+}
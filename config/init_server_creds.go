@@ -506,6 +506,11 @@ func GenerateCert() error {
 // Helper function to load the issuer/server's private key to sign tokens it issues.
 // Only intended to be called internally
 func loadIssuerPrivateJWK(issuerKeyFile string) (jwk.Key, error) {
+	// A key held by an external key management service replaces the key on disk
+	if keyURI := param.IssuerKeyURI.GetString(); keyURI != "" {
+		return loadIssuerExternalJWK(keyURI)
+	}
+
 	// Check to see if we already had an IssuerKey or generate one
 	if err := GeneratePrivateKey(issuerKeyFile, elliptic.P256(), false); err != nil {
 		return nil, errors.Wrap(err, "Failed to generate new private key")
@@ -0,0 +1,169 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	// Opens a signing key held by an external key management service or HSM, given the URI
+	// of the key.  The returned signer must keep the private key in the service and delegate
+	// each signature to it.
+	KeyCustodyProvider func(ctx context.Context, keyURI *url.URL) (crypto.Signer, error)
+
+	// A private JWK whose key is held by a key custody provider.  It's the public JWK of the
+	// key, so the kid and the JWKS are derived as for a key on disk, plus the signer of the
+	// provider; jwx signs through the crypto.Signer interface when it's given one.
+	externalJWK struct {
+		jwk.Key
+		signer crypto.Signer
+	}
+)
+
+var (
+	keyCustodyProviders      = map[string]KeyCustodyProvider{}
+	keyCustodyProvidersMutex sync.RWMutex
+
+	// How long to wait for the key custody service to answer a request
+	keyCustodyTimeout = 30 * time.Second
+)
+
+func init() {
+	RegisterKeyCustodyProvider("awskms", openAWSKMSKey)
+	RegisterKeyCustodyProvider("gcpkms", openGCPKMSKey)
+	RegisterKeyCustodyProvider("pkcs11", openPKCS11Key)
+}
+
+// Register the provider opening the keys whose URIs have the scheme, e.g. "pkcs11",
+// replacing any previous provider of the scheme
+func RegisterKeyCustodyProvider(scheme string, provider KeyCustodyProvider) {
+	keyCustodyProvidersMutex.Lock()
+	defer keyCustodyProvidersMutex.Unlock()
+	keyCustodyProviders[strings.ToLower(scheme)] = provider
+}
+
+// Open the signing key at the URI with the key custody provider of its scheme
+func OpenExternalKey(ctx context.Context, keyURI string) (crypto.Signer, error) {
+	parsed, err := url.Parse(keyURI)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the key URI %s", keyURI)
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	keyCustodyProvidersMutex.RLock()
+	provider, ok := keyCustodyProviders[scheme]
+	schemes := make([]string, 0, len(keyCustodyProviders))
+	for name := range keyCustodyProviders {
+		schemes = append(schemes, name)
+	}
+	keyCustodyProvidersMutex.RUnlock()
+	if !ok {
+		sort.Strings(schemes)
+		return nil, errors.Errorf("no key custody provider for the scheme %q of the key URI %s; supported schemes are %s",
+			scheme, keyURI, strings.Join(schemes, ", "))
+	}
+	signer, err := provider(ctx, parsed)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open the key %s", keyURI)
+	}
+	return signer, nil
+}
+
+// Wrap the signer of a key custody provider into a private JWK signing tokens.  Pelican
+// signs with ES256, so the key must be an ECDSA key on the P-256 curve.
+func NewExternalJWK(signer crypto.Signer) (jwk.Key, error) {
+	publicKey, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok || publicKey.Curve != elliptic.P256() {
+		return nil, errors.Errorf("the key must be an ECDSA P-256 key to sign with ES256; got a %T", signer.Public())
+	}
+	key, err := jwk.FromRaw(publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to convert the public key to a JWK")
+	}
+	if err = key.Set(jwk.AlgorithmKey, jwa.ES256); err != nil {
+		return nil, errors.Wrap(err, "failed to add alg specification to key header")
+	}
+	if err = jwk.AssignKeyID(key); err != nil {
+		return nil, errors.Wrap(err, "failed to assign key ID to the key")
+	}
+	return &externalJWK{Key: key, signer: signer}, nil
+}
+
+func (key *externalJWK) Public() crypto.PublicKey {
+	return key.signer.Public()
+}
+
+func (key *externalJWK) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return key.signer.Sign(rand, digest, opts)
+}
+
+// Whether the private key is held by a key custody provider rather than on disk
+func IsExternalJWK(key jwk.Key) bool {
+	_, ok := key.(*externalJWK)
+	return ok
+}
+
+// Get the signer of a private JWK: the key custody provider holding the key, or the raw
+// private key itself
+func GetJWKSigner(key jwk.Key) (crypto.Signer, error) {
+	if external, ok := key.(*externalJWK); ok {
+		return external.signer, nil
+	}
+	var rawKey interface{}
+	if err := key.Raw(&rawKey); err != nil {
+		return nil, errors.Wrap(err, "failed to get the raw private key")
+	}
+	signer, ok := rawKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("a %T can't sign", rawKey)
+	}
+	return signer, nil
+}
+
+// Load the issuer's private JWK from the key custody provider of IssuerKeyURI
+func loadIssuerExternalJWK(keyURI string) (jwk.Key, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyCustodyTimeout)
+	defer cancel()
+	signer, err := OpenExternalKey(ctx, keyURI)
+	if err != nil {
+		return nil, err
+	}
+	key, err := NewExternalJWK(signer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to use the key %s as the issuer key", keyURI)
+	}
+	log.Infof("Signing with the issuer key %s, whose kid is %s", keyURI, key.KeyID())
+
+	issuerPrivateJWK.Store(&key)
+	return key, nil
+}
@@ -0,0 +1,249 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+type (
+	// A key held by AWS KMS
+	awsKMSSigner struct {
+		client    *kms.KMS
+		keyID     string
+		publicKey crypto.PublicKey
+	}
+
+	// A key version held by Google Cloud KMS
+	gcpKMSSigner struct {
+		client    *http.Client
+		name      string
+		publicKey crypto.PublicKey
+	}
+
+	gcpKMSPublicKey struct {
+		Pem       string `json:"pem"`
+		Algorithm string `json:"algorithm"`
+	}
+
+	gcpKMSError struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+)
+
+var (
+	gcpKMSEndpoint = "https://cloudkms.googleapis.com/v1/"
+
+	// Get the credentials for Google Cloud KMS; replaced in tests
+	gcpKMSTokenSource = func(ctx context.Context) (oauth2.TokenSource, error) {
+		return google.DefaultTokenSource(ctx, "https://www.googleapis.com/auth/cloudkms")
+	}
+)
+
+// Open an AWS KMS key at a URI of the form awskms:///<key>?region=<region>&endpoint=<url>,
+// where the key is a key ID, key ARN, alias name or alias ARN.  The credentials, and the
+// region unless given by the URI or the ARN, come from the usual AWS configuration.
+func openAWSKMSKey(ctx context.Context, keyURI *url.URL) (crypto.Signer, error) {
+	keyID := strings.TrimPrefix(keyURI.Path, "/")
+	if keyID == "" {
+		return nil, errors.New("the URI of an AWS KMS key must name the key, e.g. awskms:///alias/pelican-issuer")
+	}
+	awsConfig := aws.NewConfig()
+	region := keyURI.Query().Get("region")
+	if keyARN, err := arn.Parse(keyID); err == nil && region == "" {
+		region = keyARN.Region
+	}
+	if region != "" {
+		awsConfig = awsConfig.WithRegion(region)
+	}
+	if endpoint := keyURI.Query().Get("endpoint"); endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(endpoint)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the AWS session")
+	}
+	client := kms.New(sess)
+
+	output, err := client.GetPublicKeyWithContext(ctx, &kms.GetPublicKeyInput{KeyId: aws.String(keyID)})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the public key from AWS KMS")
+	}
+	if usage := aws.StringValue(output.KeyUsage); usage != kms.KeyUsageTypeSignVerify {
+		return nil, errors.Errorf("the key usage is %s rather than %s", usage, kms.KeyUsageTypeSignVerify)
+	}
+	if spec := aws.StringValue(output.KeySpec); spec != kms.KeySpecEccNistP256 {
+		return nil, errors.Errorf("the key spec is %s rather than %s", spec, kms.KeySpecEccNistP256)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(output.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the public key from AWS KMS")
+	}
+	return &awsKMSSigner{client: client, keyID: keyID, publicKey: publicKey}, nil
+}
+
+func (signer *awsKMSSigner) Public() crypto.PublicKey {
+	return signer.publicKey
+}
+
+// Sign the digest with the key in AWS KMS.  Like ecdsa.PrivateKey, the signature is
+// ASN.1-encoded.
+func (signer *awsKMSSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, errors.Errorf("AWS KMS keys sign SHA-256 digests, not %s", opts.HashFunc().String())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), keyCustodyTimeout)
+	defer cancel()
+	output, err := signer.client.SignWithContext(ctx, &kms.SignInput{
+		KeyId:            aws.String(signer.keyID),
+		Message:          digest,
+		MessageType:      aws.String(kms.MessageTypeDigest),
+		SigningAlgorithm: aws.String(kms.SigningAlgorithmSpecEcdsaSha256),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign with AWS KMS")
+	}
+	return output.Signature, nil
+}
+
+// Open a Google Cloud KMS key version at a URI of the form
+// gcpkms://projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>/cryptoKeyVersions/<version>,
+// authenticating with the Application Default Credentials
+func openGCPKMSKey(ctx context.Context, keyURI *url.URL) (crypto.Signer, error) {
+	name := strings.Trim(keyURI.Host+keyURI.Path, "/")
+	if !strings.HasPrefix(name, "projects/") || !strings.Contains(name, "/cryptoKeyVersions/") {
+		return nil, errors.New("the URI of a Google Cloud KMS key must name a key version, e.g. " +
+			"gcpkms://projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>/cryptoKeyVersions/<version>")
+	}
+	tokenSource, err := gcpKMSTokenSource(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find the Google Cloud credentials")
+	}
+	signer := &gcpKMSSigner{
+		client: &http.Client{
+			Transport: &oauth2.Transport{Source: tokenSource, Base: http.DefaultTransport},
+			Timeout:   keyCustodyTimeout,
+		},
+		name: name,
+	}
+
+	var response gcpKMSPublicKey
+	if err = signer.call(ctx, http.MethodGet, name+"/publicKey", nil, &response); err != nil {
+		return nil, errors.Wrap(err, "failed to get the public key from Google Cloud KMS")
+	}
+	if response.Algorithm != "EC_SIGN_P256_SHA256" {
+		return nil, errors.Errorf("the key algorithm is %s rather than EC_SIGN_P256_SHA256", response.Algorithm)
+	}
+	block, _ := pem.Decode([]byte(response.Pem))
+	if block == nil {
+		return nil, errors.New("the public key from Google Cloud KMS is not PEM-encoded")
+	}
+	if signer.publicKey, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the public key from Google Cloud KMS")
+	}
+	return signer, nil
+}
+
+// Make a request to the Google Cloud KMS REST API, decoding its JSON response
+func (signer *gcpKMSSigner) call(ctx context.Context, method string, resource string, body interface{}, response interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, gcpKMSEndpoint+resource, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := signer.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	contents, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiError gcpKMSError
+		if json.Unmarshal(contents, &apiError) == nil && apiError.Error.Message != "" {
+			return errors.Errorf("Google Cloud KMS responded with %d: %s", resp.StatusCode, apiError.Error.Message)
+		}
+		return errors.Errorf("Google Cloud KMS responded with %d", resp.StatusCode)
+	}
+	return json.Unmarshal(contents, response)
+}
+
+func (signer *gcpKMSSigner) Public() crypto.PublicKey {
+	return signer.publicKey
+}
+
+// Sign the digest with the key version in Google Cloud KMS.  Like ecdsa.PrivateKey, the
+// signature is ASN.1-encoded.
+func (signer *gcpKMSSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, errors.Errorf("Google Cloud KMS P-256 keys sign SHA-256 digests, not %s", opts.HashFunc().String())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), keyCustodyTimeout)
+	defer cancel()
+	request := map[string]interface{}{
+		"digest": map[string]string{"sha256": base64.StdEncoding.EncodeToString(digest)},
+	}
+	var response struct {
+		Signature string `json:"signature"`
+	}
+	if err := signer.call(ctx, http.MethodPost, fmt.Sprintf("%s:asymmetricSign", signer.name), request, &response); err != nil {
+		return nil, errors.Wrap(err, "failed to sign with Google Cloud KMS")
+	}
+	signature, err := base64.StdEncoding.DecodeString(response.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode the signature from Google Cloud KMS")
+	}
+	return signature, nil
+}
@@ -0,0 +1,156 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"math/big"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// The key named by a PKCS#11 URI (RFC 7512), e.g.
+	// pkcs11:token=pelican;object=issuer?module-path=/usr/lib64/pkcs11/libsofthsm2.so&pin-source=file:/etc/pelican/hsm-pin
+	pkcs11KeyURI struct {
+		modulePath string
+		// The token holding the key, by label, serial number or slot
+		token   string
+		serial  string
+		slotID  uint
+		hasSlot bool
+		// The key, by label (CKA_LABEL) or ID (CKA_ID)
+		object string
+		id     []byte
+		// The user PIN of the token, if it needs a login
+		pin string
+	}
+
+	pkcs11ECDSASignature struct {
+		R, S *big.Int
+	}
+)
+
+var oidNamedCurveP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+
+// Parse a PKCS#11 URI of a private key.  The module is given by the module-path query
+// attribute, and the PIN by pin-value or by pin-source, the path of a file holding it.
+func parsePKCS11URI(keyURI *url.URL) (*pkcs11KeyURI, error) {
+	parsed := &pkcs11KeyURI{}
+	pathAttrs := keyURI.Opaque
+	if pathAttrs == "" {
+		pathAttrs = strings.TrimPrefix(keyURI.Path, "/")
+	}
+	for _, attr := range strings.Split(pathAttrs, ";") {
+		if attr == "" {
+			continue
+		}
+		name, rawValue, _ := strings.Cut(attr, "=")
+		value, err := url.PathUnescape(rawValue)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value of the PKCS#11 URI attribute %s", name)
+		}
+		switch name {
+		case "token":
+			parsed.token = value
+		case "serial":
+			parsed.serial = value
+		case "slot-id":
+			slotID, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid slot-id %q", value)
+			}
+			parsed.slotID = uint(slotID)
+			parsed.hasSlot = true
+		case "object":
+			parsed.object = value
+		case "id":
+			parsed.id = []byte(value)
+		case "type":
+			if value != "private" {
+				return nil, errors.Errorf("the PKCS#11 URI must name a private key, not an object of type %s", value)
+			}
+		case "manufacturer", "model", "library-manufacturer", "library-description", "library-version", "slot-manufacturer", "slot-description":
+			// These only describe the token or module further; the token is picked by the attributes above
+		default:
+			return nil, errors.Errorf("unsupported PKCS#11 URI attribute %s", name)
+		}
+	}
+	if parsed.object == "" && len(parsed.id) == 0 {
+		return nil, errors.New("the PKCS#11 URI must name the key with the object or id attribute")
+	}
+
+	query := keyURI.Query()
+	parsed.modulePath = query.Get("module-path")
+	if parsed.modulePath == "" {
+		return nil, errors.New("the PKCS#11 URI must give the path of the PKCS#11 module with the module-path attribute")
+	}
+	if pinSource := query.Get("pin-source"); pinSource != "" {
+		contents, err := os.ReadFile(strings.TrimPrefix(pinSource, "file:"))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the PIN of the PKCS#11 token")
+		}
+		parsed.pin = strings.TrimSpace(string(contents))
+	} else {
+		parsed.pin = query.Get("pin-value")
+	}
+	return parsed, nil
+}
+
+// Decode the public key of a PKCS#11 EC key from its CKA_EC_PARAMS and CKA_EC_POINT
+// attributes, which must be a P-256 key
+func pkcs11ECPublicKey(params []byte, point []byte) (*ecdsa.PublicKey, error) {
+	var curve asn1.ObjectIdentifier
+	if rest, err := asn1.Unmarshal(params, &curve); err != nil || len(rest) > 0 || !curve.Equal(oidNamedCurveP256) {
+		return nil, errors.New("the key must be an ECDSA key on the P-256 curve")
+	}
+	// The point is a DER octet string, though some modules give the raw point
+	var encoded []byte
+	if rest, err := asn1.Unmarshal(point, &encoded); err != nil || len(rest) > 0 {
+		encoded = point
+	}
+	if _, err := ecdh.P256().NewPublicKey(encoded); err != nil {
+		return nil, errors.Wrap(err, "invalid public key of the PKCS#11 key")
+	}
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(encoded[1:33]),
+		Y:     new(big.Int).SetBytes(encoded[33:65]),
+	}, nil
+}
+
+// Convert the signature of CKM_ECDSA, the concatenation of r and s, to the ASN.1 encoding
+// ecdsa.PrivateKey gives
+func pkcs11ECDSASignatureToASN1(raw []byte) ([]byte, error) {
+	if len(raw) == 0 || len(raw)%2 != 0 {
+		return nil, errors.Errorf("invalid ECDSA signature of %d bytes from the PKCS#11 module", len(raw))
+	}
+	half := len(raw) / 2
+	return asn1.Marshal(pkcs11ECDSASignature{
+		R: new(big.Int).SetBytes(raw[:half]),
+		S: new(big.Int).SetBytes(raw[half:]),
+	})
+}
@@ -0,0 +1,38 @@
+//go:build !(pkcs11 && (linux || darwin) && (amd64 || arm64))
+
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"crypto"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// PKCS#11 modules are loaded with purego, which needs a build with the pkcs11 tag on
+// Linux or macOS on amd64 or arm64
+func openPKCS11Key(_ context.Context, keyURI *url.URL) (crypto.Signer, error) {
+	if _, err := parsePKCS11URI(keyURI); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("this build of Pelican does not support PKCS#11 keys; use a build with the pkcs11 tag, such as pelican-server")
+}
@@ -0,0 +1,473 @@
+//go:build pkcs11 && (linux || darwin) && (amd64 || arm64)
+
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"net/url"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+	"github.com/pkg/errors"
+)
+
+type (
+	// The functions of a PKCS#11 module Pelican calls, from its CK_FUNCTION_LIST.  Handles,
+	// flags and lengths are CK_ULONGs, which are the size of a uint on the supported platforms.
+	pkcs11Functions struct {
+		Initialize        func(args *pkcs11InitializeArgs) uint
+		GetSlotList       func(tokenPresent uint8, slots *uint, count *uint) uint
+		GetTokenInfo      func(slot uint, info *pkcs11TokenInfo) uint
+		OpenSession       func(slot uint, flags uint, application unsafe.Pointer, notify unsafe.Pointer, session *uint) uint
+		CloseSession      func(session uint) uint
+		Login             func(session uint, userType uint, pin *byte, pinLen uint) uint
+		GetAttributeValue func(session uint, object uint, template *pkcs11Attribute, count uint) uint
+		FindObjectsInit   func(session uint, template *pkcs11Attribute, count uint) uint
+		FindObjects       func(session uint, objects *uint, maxObjects uint, count *uint) uint
+		FindObjectsFinal  func(session uint) uint
+		SignInit          func(session uint, mechanism *pkcs11Mechanism, key uint) uint
+		Sign              func(session uint, data *byte, dataLen uint, signature *byte, signatureLen *uint) uint
+	}
+
+	// The start of CK_FUNCTION_LIST: the version, then the functions in the order of the
+	// specification up to C_Sign
+	pkcs11FunctionList struct {
+		version   [2]byte
+		functions [44]uintptr
+	}
+
+	// CK_C_INITIALIZE_ARGS
+	pkcs11InitializeArgs struct {
+		createMutex  uintptr
+		destroyMutex uintptr
+		lockMutex    uintptr
+		unlockMutex  uintptr
+		flags        uint
+		reserved     uintptr
+	}
+
+	// CK_TOKEN_INFO
+	pkcs11TokenInfo struct {
+		label           [32]byte
+		manufacturerID  [32]byte
+		model           [16]byte
+		serialNumber    [16]byte
+		flags           uint
+		counters        [10]uint
+		hardwareVersion [2]byte
+		firmwareVersion [2]byte
+		utcTime         [16]byte
+	}
+
+	// CK_ATTRIBUTE
+	pkcs11Attribute struct {
+		attrType uint
+		value    unsafe.Pointer
+		valueLen uint
+	}
+
+	// CK_MECHANISM
+	pkcs11Mechanism struct {
+		mechanism    uint
+		parameter    unsafe.Pointer
+		parameterLen uint
+	}
+
+	pkcs11Module struct {
+		path string
+		pkcs11Functions
+	}
+
+	// A private key in a PKCS#11 token.  A session handles one operation at a time, so
+	// signatures are serialized; the session is reopened if the token drops it.
+	pkcs11Signer struct {
+		module    *pkcs11Module
+		key       *pkcs11KeyURI
+		slot      uint
+		publicKey *ecdsa.PublicKey
+
+		mutex   sync.Mutex
+		session uint
+		object  uint
+	}
+
+	pkcs11Error uint
+)
+
+const (
+	ckrOK                              = 0x000
+	ckrDeviceRemoved                   = 0x032
+	ckrSessionClosed                   = 0x0B0
+	ckrSessionHandleInvalid            = 0x0B3
+	ckrTokenNotPresent                 = 0x0E0
+	ckrUserAlreadyLoggedIn             = 0x100
+	ckrBufferTooSmall                  = 0x150
+	ckrCryptokiAlreadyInitialized      = 0x191
+	ckfOSLockingOK                     = 0x002
+	ckfSerialSession                   = 0x004
+	ckuUser                            = 1
+	ckaClass                           = 0x000
+	ckaLabel                           = 0x003
+	ckaKeyType                         = 0x100
+	ckaID                              = 0x102
+	ckaECParams                        = 0x180
+	ckaECPoint                         = 0x181
+	ckoPublicKey                       = 2
+	ckoPrivateKey                      = 3
+	ckkEC                              = 3
+	ckmECDSA                           = 0x1041
+	ckUnavailableInformation      uint = ^uint(0)
+)
+
+var (
+	pkcs11Modules      = map[string]*pkcs11Module{}
+	pkcs11ModulesMutex sync.Mutex
+)
+
+func (rv pkcs11Error) Error() string {
+	names := map[pkcs11Error]string{
+		0x005: "CKR_GENERAL_ERROR",
+		0x007: "CKR_ARGUMENTS_BAD",
+		0x030: "CKR_DEVICE_ERROR",
+		0x032: "CKR_DEVICE_REMOVED",
+		0x0A0: "CKR_PIN_INCORRECT",
+		0x0A4: "CKR_PIN_LOCKED",
+		0x0B0: "CKR_SESSION_CLOSED",
+		0x0B3: "CKR_SESSION_HANDLE_INVALID",
+		0x0E0: "CKR_TOKEN_NOT_PRESENT",
+		0x101: "CKR_USER_NOT_LOGGED_IN",
+		0x150: "CKR_BUFFER_TOO_SMALL",
+		0x190: "CKR_CRYPTOKI_NOT_INITIALIZED",
+	}
+	if name, ok := names[rv]; ok {
+		return name
+	}
+	return fmt.Sprintf("CKR_0x%08X", uint(rv))
+}
+
+func pkcs11Call(function string, rv uint) error {
+	if rv == ckrOK {
+		return nil
+	}
+	return errors.Wrapf(pkcs11Error(rv), "%s failed", function)
+}
+
+// Whether the error means the session is gone and may be reopened
+func pkcs11SessionLost(err error) bool {
+	var rv pkcs11Error
+	if !errors.As(err, &rv) {
+		return false
+	}
+	return rv == ckrSessionClosed || rv == ckrSessionHandleInvalid || rv == ckrDeviceRemoved || rv == ckrTokenNotPresent
+}
+
+// Load and initialize the PKCS#11 module at the path, once per process
+func loadPKCS11Module(path string) (*pkcs11Module, error) {
+	pkcs11ModulesMutex.Lock()
+	defer pkcs11ModulesMutex.Unlock()
+	if module, ok := pkcs11Modules[path]; ok {
+		return module, nil
+	}
+
+	lib, err := purego.Dlopen(path, purego.RTLD_NOW|purego.RTLD_LOCAL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load the PKCS#11 module %s", path)
+	}
+	getFunctionListSym, err := purego.Dlsym(lib, "C_GetFunctionList")
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s is not a PKCS#11 module", path)
+	}
+	var getFunctionList func(list **pkcs11FunctionList) uint
+	purego.RegisterFunc(&getFunctionList, getFunctionListSym)
+	var list *pkcs11FunctionList
+	if err = pkcs11Call("C_GetFunctionList", getFunctionList(&list)); err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return nil, errors.Errorf("the PKCS#11 module %s has no function list", path)
+	}
+
+	module := &pkcs11Module{path: path}
+	for index, fn := range map[int]interface{}{
+		0:  &module.Initialize,
+		4:  &module.GetSlotList,
+		6:  &module.GetTokenInfo,
+		12: &module.OpenSession,
+		13: &module.CloseSession,
+		18: &module.Login,
+		24: &module.GetAttributeValue,
+		26: &module.FindObjectsInit,
+		27: &module.FindObjects,
+		28: &module.FindObjectsFinal,
+		42: &module.SignInit,
+		43: &module.Sign,
+	} {
+		if list.functions[index] == 0 {
+			return nil, errors.Errorf("the PKCS#11 module %s lacks function %d of the specification", path, index)
+		}
+		purego.RegisterFunc(fn, list.functions[index])
+	}
+
+	// Go calls the module from many threads, so it must do its own locking
+	args := &pkcs11InitializeArgs{flags: ckfOSLockingOK}
+	if rv := module.Initialize(args); rv != ckrOK && rv != ckrCryptokiAlreadyInitialized {
+		return nil, errors.Wrapf(pkcs11Call("C_Initialize", rv), "failed to initialize the PKCS#11 module %s", path)
+	}
+	pkcs11Modules[path] = module
+	return module, nil
+}
+
+// Find the slot of the token named by the URI
+func (module *pkcs11Module) findSlot(key *pkcs11KeyURI) (uint, error) {
+	var count uint
+	if err := pkcs11Call("C_GetSlotList", module.GetSlotList(1, nil, &count)); err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, errors.Errorf("the PKCS#11 module %s has no tokens", module.path)
+	}
+	slots := make([]uint, count)
+	if err := pkcs11Call("C_GetSlotList", module.GetSlotList(1, &slots[0], &count)); err != nil {
+		return 0, err
+	}
+	slots = slots[:count]
+
+	var matches []uint
+	for _, slot := range slots {
+		if key.hasSlot && slot != key.slotID {
+			continue
+		}
+		if key.token != "" || key.serial != "" {
+			info := &pkcs11TokenInfo{}
+			if err := pkcs11Call("C_GetTokenInfo", module.GetTokenInfo(slot, info)); err != nil {
+				return 0, err
+			}
+			if key.token != "" && string(bytes.TrimRight(info.label[:], " \x00")) != key.token {
+				continue
+			}
+			if key.serial != "" && string(bytes.TrimRight(info.serialNumber[:], " \x00")) != key.serial {
+				continue
+			}
+		}
+		matches = append(matches, slot)
+	}
+	switch len(matches) {
+	case 0:
+		return 0, errors.Errorf("no token of the PKCS#11 module %s matches the key URI", module.path)
+	case 1:
+		return matches[0], nil
+	default:
+		return 0, errors.Errorf("%d tokens of the PKCS#11 module %s match the key URI; name the token with the token, serial or slot-id attribute",
+			len(matches), module.path)
+	}
+}
+
+// Build the template of the key's objects of the given class
+func (key *pkcs11KeyURI) template(class uint, keyType uint, pinner *runtime.Pinner) []pkcs11Attribute {
+	ulongAttr := func(attrType uint, value uint) pkcs11Attribute {
+		stored := new(uint)
+		*stored = value
+		pinner.Pin(stored)
+		return pkcs11Attribute{attrType: attrType, value: unsafe.Pointer(stored), valueLen: uint(unsafe.Sizeof(value))}
+	}
+	bytesAttr := func(attrType uint, value []byte) pkcs11Attribute {
+		pinner.Pin(&value[0])
+		return pkcs11Attribute{attrType: attrType, value: unsafe.Pointer(&value[0]), valueLen: uint(len(value))}
+	}
+	template := []pkcs11Attribute{ulongAttr(ckaClass, class), ulongAttr(ckaKeyType, keyType)}
+	if key.object != "" {
+		template = append(template, bytesAttr(ckaLabel, []byte(key.object)))
+	}
+	if len(key.id) > 0 {
+		template = append(template, bytesAttr(ckaID, key.id))
+	}
+	return template
+}
+
+// Find the one object of the class named by the URI
+func (signer *pkcs11Signer) findObject(session uint, class uint) (uint, error) {
+	var pinner runtime.Pinner
+	defer pinner.Unpin()
+	template := signer.key.template(class, ckkEC, &pinner)
+	module := signer.module
+	if err := pkcs11Call("C_FindObjectsInit", module.FindObjectsInit(session, &template[0], uint(len(template)))); err != nil {
+		return 0, err
+	}
+	objects := make([]uint, 2)
+	var count uint
+	err := pkcs11Call("C_FindObjects", module.FindObjects(session, &objects[0], uint(len(objects)), &count))
+	if finalErr := pkcs11Call("C_FindObjectsFinal", module.FindObjectsFinal(session)); err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	switch count {
+	case 0:
+		return 0, errors.New("no EC key in the PKCS#11 token matches the key URI")
+	case 1:
+		return objects[0], nil
+	default:
+		return 0, errors.New("several EC keys in the PKCS#11 token match the key URI; name the key with both the object and id attributes")
+	}
+}
+
+// Get the value of an attribute of an object
+func (signer *pkcs11Signer) attribute(session uint, object uint, attrType uint) ([]byte, error) {
+	attr := pkcs11Attribute{attrType: attrType}
+	if err := pkcs11Call("C_GetAttributeValue", signer.module.GetAttributeValue(session, object, &attr, 1)); err != nil {
+		return nil, err
+	}
+	if attr.valueLen == ckUnavailableInformation || attr.valueLen == 0 {
+		return nil, errors.Errorf("the PKCS#11 key has no attribute 0x%X", attrType)
+	}
+	value := make([]byte, attr.valueLen)
+	var pinner runtime.Pinner
+	defer pinner.Unpin()
+	pinner.Pin(&value[0])
+	attr.value = unsafe.Pointer(&value[0])
+	if err := pkcs11Call("C_GetAttributeValue", signer.module.GetAttributeValue(session, object, &attr, 1)); err != nil {
+		return nil, err
+	}
+	return value[:attr.valueLen], nil
+}
+
+// Open a session with the token, logging in if there's a PIN, and find the private key
+func (signer *pkcs11Signer) openSession() error {
+	module := signer.module
+	var session uint
+	if err := pkcs11Call("C_OpenSession", module.OpenSession(signer.slot, ckfSerialSession, nil, nil, &session)); err != nil {
+		return err
+	}
+	if signer.key.pin != "" {
+		pin := []byte(signer.key.pin)
+		if rv := module.Login(session, ckuUser, &pin[0], uint(len(pin))); rv != ckrOK && rv != ckrUserAlreadyLoggedIn {
+			module.CloseSession(session)
+			return errors.Wrap(pkcs11Call("C_Login", rv), "failed to log in to the PKCS#11 token")
+		}
+	}
+	object, err := signer.findObject(session, ckoPrivateKey)
+	if err != nil {
+		module.CloseSession(session)
+		return err
+	}
+	signer.session = session
+	signer.object = object
+	return nil
+}
+
+func (signer *pkcs11Signer) closeSession() {
+	if signer.session != 0 {
+		signer.module.CloseSession(signer.session)
+		signer.session = 0
+	}
+}
+
+// Open a private key in a PKCS#11 token at a URI of the form
+// pkcs11:token=<label>;object=<label>?module-path=<path>&pin-source=<file>.  The PKCS#11
+// module is loaded into the process; the private key stays in the token.
+func openPKCS11Key(_ context.Context, keyURI *url.URL) (crypto.Signer, error) {
+	key, err := parsePKCS11URI(keyURI)
+	if err != nil {
+		return nil, err
+	}
+	module, err := loadPKCS11Module(key.modulePath)
+	if err != nil {
+		return nil, err
+	}
+	slot, err := module.findSlot(key)
+	if err != nil {
+		return nil, err
+	}
+	signer := &pkcs11Signer{module: module, key: key, slot: slot}
+	if err = signer.openSession(); err != nil {
+		return nil, err
+	}
+	publicObject, err := signer.findObject(signer.session, ckoPublicKey)
+	if err != nil {
+		signer.closeSession()
+		return nil, errors.Wrap(err, "failed to find the public key of the PKCS#11 key")
+	}
+	params, err := signer.attribute(signer.session, publicObject, ckaECParams)
+	if err == nil {
+		var point []byte
+		if point, err = signer.attribute(signer.session, publicObject, ckaECPoint); err == nil {
+			signer.publicKey, err = pkcs11ECPublicKey(params, point)
+		}
+	}
+	if err != nil {
+		signer.closeSession()
+		return nil, errors.Wrap(err, "failed to get the public key of the PKCS#11 key")
+	}
+	return signer, nil
+}
+
+func (signer *pkcs11Signer) Public() crypto.PublicKey {
+	return signer.publicKey
+}
+
+func (signer *pkcs11Signer) sign(digest []byte) ([]byte, error) {
+	mechanism := &pkcs11Mechanism{mechanism: ckmECDSA}
+	if err := pkcs11Call("C_SignInit", signer.module.SignInit(signer.session, mechanism, signer.object)); err != nil {
+		return nil, err
+	}
+	// The signature of a P-256 key is 64 bytes; leave room for larger keys
+	signature := make([]byte, 132)
+	signatureLen := uint(len(signature))
+	if err := pkcs11Call("C_Sign", signer.module.Sign(signer.session, &digest[0], uint(len(digest)), &signature[0], &signatureLen)); err != nil {
+		return nil, err
+	}
+	return signature[:signatureLen], nil
+}
+
+// Sign the digest with the key in the PKCS#11 token.  Like ecdsa.PrivateKey, the
+// signature is ASN.1-encoded.
+func (signer *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 || len(digest) != crypto.SHA256.Size() {
+		return nil, errors.Errorf("PKCS#11 P-256 keys sign SHA-256 digests, not %s", opts.HashFunc().String())
+	}
+	signer.mutex.Lock()
+	defer signer.mutex.Unlock()
+	if signer.session == 0 {
+		if err := signer.openSession(); err != nil {
+			return nil, errors.Wrap(err, "failed to reopen the session with the PKCS#11 token")
+		}
+	}
+	raw, err := signer.sign(digest)
+	if pkcs11SessionLost(err) {
+		signer.session = 0
+		if err = signer.openSession(); err != nil {
+			return nil, errors.Wrap(err, "failed to reopen the session with the PKCS#11 token")
+		}
+		raw, err = signer.sign(digest)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign with the PKCS#11 token")
+	}
+	return pkcs11ECDSASignatureToASN1(raw)
+}
@@ -0,0 +1,325 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+// A key custody provider keeping its keys in memory, counting the signatures
+type memorySigner struct {
+	key        crypto.Signer
+	signatures atomic.Int32
+}
+
+func (signer *memorySigner) Public() crypto.PublicKey {
+	return signer.key.Public()
+}
+
+func (signer *memorySigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	signer.signatures.Add(1)
+	return signer.key.Sign(rand, digest, opts)
+}
+
+func TestExternalIssuerKey(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		ResetIssuerJWKPtr()
+	})
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer := &memorySigner{key: privateKey}
+	RegisterKeyCustodyProvider("memory", func(ctx context.Context, keyURI *url.URL) (crypto.Signer, error) {
+		assert.Equal(t, "issuer", keyURI.Host)
+		return signer, nil
+	})
+
+	issuerKeyFile := filepath.Join(t.TempDir(), "issuer.jwk")
+	viper.Set(param.IssuerKey.GetName(), issuerKeyFile)
+	viper.Set(param.IssuerKeyURI.GetName(), "memory://issuer")
+	ResetIssuerJWKPtr()
+
+	key, err := GetIssuerPrivateJWK()
+	require.NoError(t, err)
+	assert.True(t, IsExternalJWK(key))
+	assert.NotEmpty(t, key.KeyID())
+	// The key stays in the service; nothing is generated on disk
+	_, err = os.Stat(issuerKeyFile)
+	assert.True(t, os.IsNotExist(err))
+
+	keySigner, err := GetJWKSigner(key)
+	require.NoError(t, err)
+	assert.Equal(t, signer, keySigner)
+
+	// Tokens are signed by the provider and verify against the public JWKS
+	tok, err := jwt.NewBuilder().Issuer("https://issuer.example.com").Expiration(time.Now().Add(time.Minute)).Build()
+	require.NoError(t, err)
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, key))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), signer.signatures.Load())
+
+	jwks, err := GetIssuerPublicJWKS()
+	require.NoError(t, err)
+	assert.Equal(t, 1, jwks.Len())
+	parsed, err := jwt.Parse(signed, jwt.WithKeySet(jwks))
+	require.NoError(t, err)
+	assert.Equal(t, "https://issuer.example.com", parsed.Issuer())
+}
+
+func TestOpenExternalKey(t *testing.T) {
+	_, err := OpenExternalKey(context.Background(), "vault://keys/issuer")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `no key custody provider for the scheme "vault"`)
+	assert.Contains(t, err.Error(), "awskms, gcpkms")
+
+	_, err = OpenExternalKey(context.Background(), "awskms:///")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must name the key")
+
+	// Tokens are signed with ES256
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = NewExternalJWK(rsaKey)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ECDSA P-256")
+
+	// Raw private keys sign for themselves
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	key, err := NewExternalJWK(privateKey)
+	require.NoError(t, err)
+	publicKey, err := key.PublicKey()
+	require.NoError(t, err)
+	_, err = GetJWKSigner(publicKey)
+	require.Error(t, err)
+}
+
+// Sign the digest and check the signature against the public key of the signer
+func checkExternalSignature(t *testing.T, signer crypto.Signer) {
+	digest := sha256.Sum256([]byte("pelican"))
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	publicKey, ok := signer.Public().(*ecdsa.PublicKey)
+	require.True(t, ok)
+	assert.True(t, ecdsa.VerifyASN1(publicKey, digest[:], signature))
+
+	_, err = signer.Sign(rand.Reader, digest[:], crypto.SHA384)
+	assert.Error(t, err)
+}
+
+func TestAWSKMSKey(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicDer, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+
+	// A fake of the AWS KMS JSON API
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			KeyId            string
+			Message          []byte
+			MessageType      string
+			SigningAlgorithm string
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, "alias/pelican", request.KeyId)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"KeyId":     "arn:aws:kms:us-east-1:123456789012:key/1234",
+				"KeySpec":   "ECC_NIST_P256",
+				"KeyUsage":  "SIGN_VERIFY",
+				"PublicKey": publicDer,
+			})
+		case "TrentService.Sign":
+			assert.Equal(t, "DIGEST", request.MessageType)
+			assert.Equal(t, "ECDSA_SHA_256", request.SigningAlgorithm)
+			signature, err := ecdsa.SignASN1(rand.Reader, privateKey, request.Message)
+			require.NoError(t, err)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"Signature": signature})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	signer, err := OpenExternalKey(context.Background(), "awskms:///alias/pelican?region=us-east-1&endpoint="+url.QueryEscape(server.URL))
+	require.NoError(t, err)
+	checkExternalSignature(t, signer)
+}
+
+func TestGCPKMSKey(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicDer, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	keyName := "projects/pelican/locations/global/keyRings/ring/cryptoKeys/issuer/cryptoKeyVersions/1"
+
+	// A fake of the Google Cloud KMS REST API
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/"+keyName+"/publicKey":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"pem":       string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer})),
+				"algorithm": "EC_SIGN_P256_SHA256",
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/"+keyName+":asymmetricSign":
+			var request struct {
+				Digest struct {
+					Sha256 []byte `json:"sha256"`
+				} `json:"digest"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			signature, err := ecdsa.SignASN1(rand.Reader, privateKey, request.Digest.Sha256)
+			require.NoError(t, err)
+			_ = json.NewEncoder(w).Encode(map[string]string{"signature": base64.StdEncoding.EncodeToString(signature)})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": {"message": "key not found"}}`))
+		}
+	}))
+	defer server.Close()
+
+	oldEndpoint, oldTokenSource := gcpKMSEndpoint, gcpKMSTokenSource
+	t.Cleanup(func() {
+		gcpKMSEndpoint, gcpKMSTokenSource = oldEndpoint, oldTokenSource
+	})
+	gcpKMSEndpoint = server.URL + "/v1/"
+	gcpKMSTokenSource = func(ctx context.Context) (oauth2.TokenSource, error) {
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "test-token"}), nil
+	}
+
+	signer, err := OpenExternalKey(context.Background(), "gcpkms://"+keyName)
+	require.NoError(t, err)
+	checkExternalSignature(t, signer)
+
+	_, err = OpenExternalKey(context.Background(), "gcpkms://"+strings.Replace(keyName, "issuer", "missing", 1))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "key not found")
+
+	_, err = OpenExternalKey(context.Background(), "gcpkms://projects/pelican/locations/global")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must name a key version")
+}
+
+func TestPKCS11Key(t *testing.T) {
+	pinFile := filepath.Join(t.TempDir(), "pin")
+	require.NoError(t, os.WriteFile(pinFile, []byte("1234\n"), 0600))
+
+	t.Run("parse-uri", func(t *testing.T) {
+		keyURI, err := url.Parse("pkcs11:token=Pelican%20HSM;slot-id=7;object=issuer;id=%01%02;type=private?module-path=/usr/lib64/pkcs11/libsofthsm2.so&pin-source=file:" + pinFile)
+		require.NoError(t, err)
+		key, err := parsePKCS11URI(keyURI)
+		require.NoError(t, err)
+		assert.Equal(t, "/usr/lib64/pkcs11/libsofthsm2.so", key.modulePath)
+		assert.Equal(t, "Pelican HSM", key.token)
+		assert.True(t, key.hasSlot)
+		assert.Equal(t, uint(7), key.slotID)
+		assert.Equal(t, "issuer", key.object)
+		assert.Equal(t, []byte{1, 2}, key.id)
+		assert.Equal(t, "1234", key.pin)
+
+		for uri, message := range map[string]string{
+			"pkcs11:token=pelican?module-path=/lib/p11.so":                         "object or id",
+			"pkcs11:object=issuer":                                                 "module-path",
+			"pkcs11:object=issuer;type=cert?module-path=/lib/p11.so":               "private key",
+			"pkcs11:object=issuer;slot-id=first?module-path=/lib/p11.so":           "invalid slot-id",
+			"pkcs11:object=issuer;color=blue?module-path=/lib/p11.so":              "unsupported PKCS#11 URI attribute",
+			"pkcs11:object=issuer?module-path=/lib/p11.so&pin-source=/nonexistent": "PIN",
+		} {
+			keyURI, err := url.Parse(uri)
+			require.NoError(t, err)
+			_, err = parsePKCS11URI(keyURI)
+			require.Error(t, err, uri)
+			assert.Contains(t, err.Error(), message, uri)
+		}
+	})
+
+	t.Run("public-key", func(t *testing.T) {
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		ecdhKey, err := privateKey.PublicKey.ECDH()
+		require.NoError(t, err)
+		params, err := asn1.Marshal(oidNamedCurveP256)
+		require.NoError(t, err)
+		point, err := asn1.Marshal(ecdhKey.Bytes())
+		require.NoError(t, err)
+
+		// The point may be given as a DER octet string or as is
+		for _, encoded := range [][]byte{point, ecdhKey.Bytes()} {
+			publicKey, err := pkcs11ECPublicKey(params, encoded)
+			require.NoError(t, err)
+			assert.True(t, privateKey.PublicKey.Equal(publicKey))
+		}
+
+		p384Params, err := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 34})
+		require.NoError(t, err)
+		_, err = pkcs11ECPublicKey(p384Params, point)
+		assert.ErrorContains(t, err, "P-256")
+		_, err = pkcs11ECPublicKey(params, []byte{4, 1, 2, 3})
+		assert.Error(t, err)
+
+		// CKM_ECDSA signatures are converted to the encoding of ecdsa.PrivateKey
+		digest := sha256.Sum256([]byte("pelican"))
+		r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest[:])
+		require.NoError(t, err)
+		raw := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		signature, err := pkcs11ECDSASignatureToASN1(raw)
+		require.NoError(t, err)
+		assert.True(t, ecdsa.VerifyASN1(&privateKey.PublicKey, digest[:], signature))
+		_, err = pkcs11ECDSASignatureToASN1(raw[:63])
+		assert.Error(t, err)
+	})
+}
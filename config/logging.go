@@ -22,11 +22,16 @@ import (
 	"io"
 	"os"
 	"regexp"
+	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/go-kit/log/term"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/writer"
+
+	"github.com/pelicanplatform/pelican/param"
 )
 
 type (
@@ -58,12 +63,41 @@ type (
 		regex    *regexp.Regexp
 		template string
 	}
+
+	// The log levels in effect for the server: a default level plus
+	// per-component overrides keyed on the "component" field of a log entry
+	moduleLogLevels struct {
+		mutex        sync.RWMutex
+		defaultLevel log.Level
+		overrides    map[string]log.Level
+	}
+)
+
+// Standard field names for structured log entries.  Using the same names across
+// all modules allows log aggregators to index and filter on them consistently.
+const (
+	LogFieldComponent = "component"
+	LogFieldRequestId = "request_id"
+	LogFieldNamespace = "namespace"
+	LogFieldServer    = "server"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
 )
 
 var (
 	globalFilters      RegexpFilterHook
 	addedGlobalFilters bool
 
+	logLevels = moduleLogLevels{
+		defaultLevel: log.InfoLevel,
+		overrides:    map[string]log.Level{},
+	}
+	// Fields added to every log entry written by the server, such as the server name
+	logStaticFields atomic.Pointer[log.Fields]
+
 	globalTransform *regexpTransformHook = &regexpTransformHook{
 		hook: &writer.Hook{
 			Writer:    os.Stderr,
@@ -104,6 +138,16 @@ func (fh *RegexpFilterHook) Fire(entry *log.Entry) (err error) {
 
 // Process a single log entry, updating it as necessary
 func (rt *regexpTransformHook) Fire(entry *log.Entry) (err error) {
+	if !logLevels.enabled(entry) {
+		return nil
+	}
+	if fields := logStaticFields.Load(); fields != nil {
+		for key, value := range *fields {
+			if _, ok := entry.Data[key]; !ok {
+				entry.Data[key] = value
+			}
+		}
+	}
 	for _, replace := range rt.replacements {
 		entry.Message = replace.regex.ReplaceAllString(entry.Message, replace.template)
 	}
@@ -118,13 +162,17 @@ func initFilterLogging() {
 	filters := make([]*RegexpFilter, 0)
 	globalFilters.filters.Store(&filters)
 
-	configLevel := log.GetLevel()
-	log.SetLevel(log.DebugLevel)
-	hookLevel := make([]log.Level, 0)
-	for _, lvl := range log.AllLevels {
-		if lvl <= configLevel {
-			hookLevel = append(hookLevel, lvl)
-		}
+	// The hook passes through every level; the per-module filtering happens in
+	// the hook itself so levels can be changed at runtime.  After the first call,
+	// the global level no longer reflects the configured one, which SetLogging tracks.
+	if !addedGlobalFilters {
+		logLevels.setDefault(log.GetLevel())
+	}
+	if err := loadModuleLogLevels(); err != nil {
+		log.Errorln("Ignoring invalid Logging.Modules configuration:", err)
+	}
+	if hostname := param.Server_Hostname.GetString(); hostname != "" {
+		logStaticFields.Store(&log.Fields{LogFieldServer: hostname})
 	}
 
 	// Unit tests may initialize the server multiple times; avoid configuring
@@ -134,10 +182,11 @@ func initFilterLogging() {
 		addedGlobalFilters = true
 		// Set the writer to what logrus has
 		globalTransform.hook.Writer = log.StandardLogger().Out
-		globalTransform.hook.LogLevels = hookLevel
+		globalTransform.hook.LogLevels = log.AllLevels
 		log.SetOutput(io.Discard)
 		log.AddHook(globalTransform)
 	}
+	logLevels.updateGlobalLevel()
 }
 
 func AddFilter(newFilter *RegexpFilter) {
@@ -171,6 +220,112 @@ func SetLogging(logLevel log.Level) {
 	// and won't format logs with color. Here we bypass logrus check by forcing the color
 	// and provide our check. Note that when calling SetLogging, io.Out hasn't been changed yet.
 	textFormatter.ForceColors = term.IsTerminal(log.StandardLogger().Out)
-	log.SetFormatter(&textFormatter)
+	if strings.EqualFold(param.Logging_Format.GetString(), LogFormatJSON) {
+		log.SetFormatter(&log.JSONFormatter{})
+	} else {
+		log.SetFormatter(&textFormatter)
+	}
 	log.SetLevel(logLevel)
+	// Once the filter hook is installed, the global logger level only bounds the most
+	// verbose module; the configured level is enforced by the hook.
+	if addedGlobalFilters {
+		logLevels.setDefault(logLevel)
+	}
+}
+
+// Return a logger whose entries are tagged with the given component.  The component
+// determines which per-module level override (Logging.Modules) applies to the entries.
+func ModuleLogger(component string) *log.Entry {
+	return log.WithField(LogFieldComponent, strings.ToLower(component))
+}
+
+// Set the log level for entries tagged with the given component, overriding
+// Logging.Level.  Takes effect immediately.
+func SetModuleLogLevel(component string, level log.Level) {
+	logLevels.mutex.Lock()
+	logLevels.overrides[strings.ToLower(component)] = level
+	logLevels.mutex.Unlock()
+	logLevels.updateGlobalLevel()
+}
+
+// Remove the log level override for the given component so it falls
+// back to Logging.Level
+func ResetModuleLogLevel(component string) {
+	logLevels.mutex.Lock()
+	delete(logLevels.overrides, strings.ToLower(component))
+	logLevels.mutex.Unlock()
+	logLevels.updateGlobalLevel()
+}
+
+// Return the default log level and a copy of the current per-module overrides
+func GetModuleLogLevels() (defaultLevel log.Level, overrides map[string]log.Level) {
+	logLevels.mutex.RLock()
+	defer logLevels.mutex.RUnlock()
+	overrides = make(map[string]log.Level, len(logLevels.overrides))
+	for component, level := range logLevels.overrides {
+		overrides[component] = level
+	}
+	return logLevels.defaultLevel, overrides
+}
+
+// Populate the per-module overrides from the Logging.Modules parameter
+func loadModuleLogLevels() error {
+	modules := map[string]string{}
+	if err := param.Logging_Modules.Unmarshal(&modules); err != nil {
+		return errors.Wrap(err, "failed to parse Logging.Modules")
+	}
+	overrides := make(map[string]log.Level, len(modules))
+	for component, levelStr := range modules {
+		level, err := log.ParseLevel(levelStr)
+		if err != nil {
+			return errors.Wrapf(err, "invalid log level for module %s", component)
+		}
+		overrides[strings.ToLower(component)] = level
+	}
+	logLevels.mutex.Lock()
+	logLevels.overrides = overrides
+	logLevels.mutex.Unlock()
+	logLevels.updateGlobalLevel()
+	return nil
+}
+
+func (ml *moduleLogLevels) setDefault(level log.Level) {
+	ml.mutex.Lock()
+	ml.defaultLevel = level
+	ml.mutex.Unlock()
+	ml.updateGlobalLevel()
+}
+
+// Returns true if the entry should be written given the level of its component
+func (ml *moduleLogLevels) enabled(entry *log.Entry) bool {
+	ml.mutex.RLock()
+	defer ml.mutex.RUnlock()
+	level := ml.defaultLevel
+	if component, ok := entry.Data[LogFieldComponent].(string); ok {
+		if override, ok := ml.overrides[component]; ok {
+			level = override
+		}
+	}
+	return entry.Level <= level
+}
+
+// The global logger discards entries more verbose than its level before any hook
+// sees them.  Keep it at least at debug (the regexp filters may want to see debug
+// messages) and raise it to trace if any module is configured for trace.
+func (ml *moduleLogLevels) updateGlobalLevel() {
+	if !addedGlobalFilters {
+		return
+	}
+	ml.mutex.RLock()
+	globalLevel := log.DebugLevel
+	if ml.defaultLevel > globalLevel {
+		globalLevel = ml.defaultLevel
+	}
+	for _, level := range ml.overrides {
+		if level > globalLevel {
+			globalLevel = level
+		}
+	}
+	ml.mutex.RUnlock()
+	log.SetLevel(globalLevel)
 }
@@ -20,12 +20,15 @@ package config
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/writer"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLoggingFilter(t *testing.T) {
@@ -34,6 +37,9 @@ func TestLoggingFilter(t *testing.T) {
 	entry := log.NewEntry(logger)
 	// Actual log message observed; note this token is expired and hence useless
 	entry.Message = `240229 14:13:55 18544 XrdPfc_Cache: info Attach() pelican://u221@itb-osdf-director-origins.dev.osgdev.chtc.io:443//ospool/ap20/data/dvp2/singularity_repos/iebe-music_dev.sif?&authz=Bearer%20eyJ0eXAiOiJKV1QiLCJhbGciOiJFUzI1NiIsImtpZCI6IjhiNjkifQ.eyJzdWIiOiJkdnAyIiwic2NvcGUiOiJyZWFkOi9kYXRhL2R2cDIgd3JpdGU6L2RhdGEvZHZwMiIsInZlciI6InNjaXRva2VuczoyLjAiLCJhdWQiOlsiQU5ZIl0sImlzcyI6Imh0dHBzOi8vYXAyMC51Yy5vc2ctaHRjLm9yZzoxMDk0L29zcG9vbC9hcDIwIiwiZXhwIjoxNzA5MjM4MTk3LCJpYXQiOjE3MDkyMzY5OTcsIm5iZiI6MTcwOTIzNjk5NywianRpIjoiNGNhNGM0NmItZDBiNy00YTFhLTk4NmYtYzk0Mjc1MzAzNDc3In0.ImFc2WiTLJDjavsjDQWgVJhASAkmV-XE2LbJkogv_kjxdF0sazTKPPRqaLmQ7_Tab-1nDYixfHT58CmFLHeebQ`
+	// The static fields set up by other tests don't belong in this output
+	staticFields := logStaticFields.Swap(nil)
+	t.Cleanup(func() { logStaticFields.Store(staticFields) })
 	transform := globalTransform
 	result := &bytes.Buffer{}
 	transform.hook = &writer.Hook{Writer: result}
@@ -41,3 +47,69 @@ func TestLoggingFilter(t *testing.T) {
 	fmt.Println(result.String())
 	assert.Equal(t, `time="0001-01-01T00:00:00Z" level=panic msg="240229 14:13:55 18544 XrdPfc_Cache: info Attach() pelican://u221@itb-osdf-director-origins.dev.osgdev.chtc.io:443//ospool/ap20/data/dvp2/singularity_repos/iebe-music_dev.sif?&authz=Bearer%20eyJ0eXAiOiJKV1QiLCJhbGciOiJFUzI1NiIsImtpZCI6IjhiNjkifQ.eyJzdWIiOiJkdnAyIiwic2NvcGUiOiJyZWFkOi9kYXRhL2R2cDIgd3JpdGU6L2RhdGEvZHZwMiIsInZlciI6InNjaXRva2VuczoyLjAiLCJhdWQiOlsiQU5ZIl0sImlzcyI6Imh0dHBzOi8vYXAyMC51Yy5vc2ctaHRjLm9yZzoxMDk0L29zcG9vbC9hcDIwIiwiZXhwIjoxNzA5MjM4MTk3LCJpYXQiOjE3MDkyMzY5OTcsIm5iZiI6MTcwOTIzNjk5NywianRpIjoiNGNhNGM0NmItZDBiNy00YTFhLTk4NmYtYzk0Mjc1MzAzNDc3In0.REDACTED"`+"\n", result.String())
 }
+
+func TestModuleLogLevels(t *testing.T) {
+	ResetConfig()
+	defaultLevel, overrides := GetModuleLogLevels()
+	t.Cleanup(func() {
+		ResetConfig()
+		logLevels.mutex.Lock()
+		logLevels.overrides = overrides
+		logLevels.mutex.Unlock()
+		logLevels.setDefault(defaultLevel)
+	})
+	logLevels.setDefault(log.InfoLevel)
+
+	newEntry := func(component string, level log.Level) *log.Entry {
+		entry := log.NewEntry(log.New())
+		if component != "" {
+			entry = entry.WithField(LogFieldComponent, component)
+		}
+		entry.Level = level
+		return entry
+	}
+
+	viper.Set("Logging.Modules", map[string]string{"Director": "debug", "registry": "error"})
+	require.NoError(t, loadModuleLogLevels())
+	assert.True(t, logLevels.enabled(newEntry("director", log.DebugLevel)))
+	assert.False(t, logLevels.enabled(newEntry("director", log.TraceLevel)))
+	assert.False(t, logLevels.enabled(newEntry("registry", log.WarnLevel)))
+	assert.True(t, logLevels.enabled(newEntry("origin", log.InfoLevel)))
+	assert.False(t, logLevels.enabled(newEntry("", log.DebugLevel)))
+
+	// Overrides can be changed at runtime
+	SetModuleLogLevel("Registry", log.TraceLevel)
+	assert.True(t, logLevels.enabled(newEntry("registry", log.TraceLevel)))
+	ResetModuleLogLevel("director")
+	assert.False(t, logLevels.enabled(newEntry("director", log.DebugLevel)))
+
+	current, modules := GetModuleLogLevels()
+	assert.Equal(t, log.InfoLevel, current)
+	assert.Equal(t, map[string]log.Level{"registry": log.TraceLevel}, modules)
+
+	viper.Set("Logging.Modules", map[string]string{"director": "loud"})
+	assert.Error(t, loadModuleLogLevels())
+}
+
+func TestJSONLogging(t *testing.T) {
+	logger := log.New()
+	logger.SetFormatter(&log.JSONFormatter{})
+	result := &bytes.Buffer{}
+	hook := &regexpTransformHook{hook: &writer.Hook{Writer: result}}
+
+	logStaticFields.Store(&log.Fields{LogFieldServer: "origin.example.org"})
+	t.Cleanup(func() { logStaticFields.Store(nil) })
+
+	entry := log.NewEntry(logger).WithFields(log.Fields{LogFieldComponent: "origin", LogFieldNamespace: "/foo"})
+	entry.Level = log.ErrorLevel
+	entry.Message = "Failed to advertise"
+	require.NoError(t, hook.Fire(entry))
+
+	fields := map[string]string{}
+	require.NoError(t, json.Unmarshal(result.Bytes(), &fields))
+	assert.Equal(t, "origin", fields[LogFieldComponent])
+	assert.Equal(t, "/foo", fields[LogFieldNamespace])
+	assert.Equal(t, "origin.example.org", fields[LogFieldServer])
+	assert.Equal(t, "error", fields["level"])
+	assert.Equal(t, "Failed to advertise", fields["msg"])
+}
@@ -0,0 +1,223 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// A proxy policy for the client's connections to a federation, as
+	// configured by Client.ProxyOverrides
+	ProxyOverride struct {
+		Federation string `mapstructure:"Federation"`
+		Proxy      string `mapstructure:"Proxy"`
+	}
+
+	proxyPolicy struct {
+		// If set, the policy is invalid and any request using it fails
+		err error
+		// Never use a proxy
+		none bool
+		// The forced proxy; if nil (and none is unset), the environment is used
+		proxyUrl *url.URL
+	}
+)
+
+const (
+	proxyEnvironment = "environment"
+	proxyNone        = "none"
+	proxyAnyFed      = "*"
+)
+
+var (
+	proxyOverridesOnce sync.Once
+	proxyOverrides     map[string]proxyPolicy
+	proxyOverridesErr  error
+)
+
+// The hostname identifying a federation in Client.ProxyOverrides; the
+// federation may be given either as a discovery URL or as a bare hostname
+func proxyFederationKey(federation string) string {
+	federation = strings.TrimSpace(federation)
+	if federation == proxyAnyFed {
+		return federation
+	}
+	if !strings.Contains(federation, "://") {
+		federation = "https://" + federation
+	}
+	fedUrl, err := url.Parse(federation)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(fedUrl.Hostname())
+}
+
+// Parse the proxy value of a Client.ProxyOverrides entry
+func parseProxyPolicy(proxy string) (policy proxyPolicy, err error) {
+	proxy = strings.TrimSpace(proxy)
+	switch strings.ToLower(proxy) {
+	case "", proxyEnvironment:
+		return
+	case proxyNone:
+		policy.none = true
+		return
+	}
+	proxyUrl, err := url.Parse(proxy)
+	if err != nil {
+		err = errors.Wrapf(err, "invalid proxy URL %q", proxy)
+		return
+	}
+	switch proxyUrl.Scheme {
+	case "http", "https", "socks5":
+	case "socks5h":
+		// The SOCKS5 dialer of the HTTP transport always leaves name
+		// resolution to the proxy, which is what socks5h asks for
+		proxyUrl.Scheme = "socks5"
+	default:
+		err = errors.Errorf("unsupported scheme in proxy URL %q; must be one of http, https, socks5, or socks5h", proxy)
+		return
+	}
+	if proxyUrl.Host == "" {
+		err = errors.Errorf("proxy URL %q is missing a host", proxy)
+		return
+	}
+	policy.proxyUrl = proxyUrl
+	return
+}
+
+func loadProxyOverrides() {
+	var overrides []ProxyOverride
+	if err := param.Client_ProxyOverrides.Unmarshal(&overrides); err != nil {
+		proxyOverridesErr = errors.Wrap(err, "failed to parse Client.ProxyOverrides")
+		return
+	}
+	proxyOverrides = make(map[string]proxyPolicy, len(overrides))
+	for _, override := range overrides {
+		key := proxyFederationKey(override.Federation)
+		if key == "" {
+			proxyOverridesErr = errors.Errorf("invalid federation %q in Client.ProxyOverrides", override.Federation)
+			return
+		}
+		policy, err := parseProxyPolicy(override.Proxy)
+		if err != nil {
+			// Keep the error with the federation's policy so requests to it
+			// fail rather than silently going around a required proxy
+			policy.err = errors.Wrapf(err, "invalid proxy for federation %s in Client.ProxyOverrides", override.Federation)
+		}
+		proxyOverrides[key] = policy
+	}
+}
+
+// Return the proxy policy for the federation at the given discovery URL
+func getProxyPolicy(federation string) proxyPolicy {
+	proxyOverridesOnce.Do(loadProxyOverrides)
+	if proxyOverridesErr != nil {
+		return proxyPolicy{err: proxyOverridesErr}
+	}
+	if policy, ok := proxyOverrides[proxyFederationKey(federation)]; ok {
+		return policy
+	}
+	return proxyOverrides[proxyAnyFed]
+}
+
+// Return the proxy function for an HTTP transport talking to the federation
+// at the given discovery URL (or hostname).  Absent a Client.ProxyOverrides
+// entry for the federation, the proxy is taken from the HTTP_PROXY, HTTPS_PROXY,
+// and NO_PROXY environment variables.
+func ProxyForFederation(federation string) func(*http.Request) (*url.URL, error) {
+	policy := getProxyPolicy(federation)
+	switch {
+	case policy.err != nil:
+		err := policy.err
+		return func(*http.Request) (*url.URL, error) { return nil, err }
+	case policy.none:
+		return nil
+	case policy.proxyUrl != nil:
+		return http.ProxyURL(policy.proxyUrl)
+	default:
+		return http.ProxyFromEnvironment
+	}
+}
+
+// Return the proxy set in the environment for URLs of the given scheme,
+// following the same precedence as the HTTP transport
+func environmentProxy(scheme string) string {
+	envVars := []string{"HTTP_PROXY", "http_proxy"}
+	if scheme == "https" {
+		envVars = []string{"HTTPS_PROXY", "https_proxy"}
+	}
+	for _, envVar := range envVars {
+		if val := os.Getenv(envVar); val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+// Returns true if connections with the given URL scheme to the federation at
+// the given discovery URL may go through a proxy, either because one is forced
+// by Client.ProxyOverrides or because one is set in the environment
+func FederationProxyConfigured(federation string, scheme string) bool {
+	policy := getProxyPolicy(federation)
+	switch {
+	case policy.err != nil:
+		// Surface the configuration error on the proxied attempt
+		return true
+	case policy.none:
+		return false
+	case policy.proxyUrl != nil:
+		return true
+	}
+	return environmentProxy(scheme) != ""
+}
+
+// Return a description of the proxy used for connections with the given URL
+// scheme to the federation (for error messages), or the empty string if there
+// is none; credentials are redacted
+func ProxyDescription(federation string, scheme string) string {
+	policy := getProxyPolicy(federation)
+	if policy.none || policy.err != nil {
+		return ""
+	} else if policy.proxyUrl != nil {
+		return policy.proxyUrl.Redacted()
+	}
+	envProxy := environmentProxy(scheme)
+	if envProxy == "" {
+		return ""
+	}
+	if proxyUrl, err := url.Parse(envProxy); err == nil && proxyUrl.Host != "" {
+		return proxyUrl.Redacted()
+	}
+	return envProxy
+}
+
+func resetProxyOverrides() {
+	proxyOverridesOnce = sync.Once{}
+	proxyOverrides = nil
+	proxyOverridesErr = nil
+}
@@ -0,0 +1,447 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// A hook run after new values of reload-safe parameters have been set.  It should
+	// validate and apply them; if it returns an error, the previous values are restored.
+	ReloadHook func() error
+
+	// The outcome of re-reading the configuration files
+	ConfigReloadResult struct {
+		// The changed parameters that were applied to the running server
+		Applied []string `json:"applied"`
+		// Parameters changed since the server started that only take effect after a restart
+		RequiresRestart []string `json:"requiresRestart"`
+		// Changed parameters whose new value was rejected, with the reason
+		Failed map[string]string `json:"failed,omitempty"`
+		// Changed parameters that have no effect because an environment variable overrides them
+		Ignored    []string  `json:"ignored,omitempty"`
+		ReloadedAt time.Time `json:"reloadedAt"`
+	}
+
+	reloadable struct {
+		key  string
+		hook ReloadHook
+	}
+
+	configReloadState struct {
+		mutex sync.Mutex
+		// The values read from the configuration files when the server started
+		startup map[string]any
+		// The values read from the configuration files as of the last reload
+		current    map[string]any
+		lastResult *ConfigReloadResult
+	}
+)
+
+var (
+	reloadables      = map[string]reloadable{}
+	reloadablesMutex sync.RWMutex
+
+	reloadState configReloadState
+)
+
+func init() {
+	RegisterReloadable(applyLogLevel, param.Logging_Level.GetName(), param.Debug.GetName())
+	RegisterReloadable(loadModuleLogLevels, param.Logging_Modules.GetName())
+}
+
+// Mark parameters as safe to change while the server runs.  When a reload changes
+// one of the keys, its new value is set and then the hook, if any, is called.
+func RegisterReloadable(hook ReloadHook, keys ...string) {
+	reloadablesMutex.Lock()
+	defer reloadablesMutex.Unlock()
+	for _, key := range keys {
+		reloadables[strings.ToLower(key)] = reloadable{key: key, hook: hook}
+	}
+}
+
+// Find the reload-safe parameter a (lowercase, possibly nested) viper key belongs to
+func findReloadable(key string) (reloadable, bool) {
+	reloadablesMutex.RLock()
+	defer reloadablesMutex.RUnlock()
+	for {
+		if r, ok := reloadables[key]; ok {
+			return r, true
+		}
+		idx := strings.LastIndex(key, ".")
+		if idx < 0 {
+			return reloadable{}, false
+		}
+		key = key[:idx]
+	}
+}
+
+// Whether the parameter can be changed while the server runs
+func IsReloadable(key string) bool {
+	_, ok := findReloadable(strings.ToLower(key))
+	return ok
+}
+
+func applyLogLevel() error {
+	if param.Debug.GetBool() {
+		SetLogging(log.DebugLevel)
+		return nil
+	}
+	level, err := log.ParseLevel(param.Logging_Level.GetString())
+	if err != nil {
+		return err
+	}
+	SetLogging(level)
+	return nil
+}
+
+// Map a lowercase viper key to the name of the parameter it belongs to, e.g.
+// "logging.modules.director" to "Logging.Modules"
+func canonicalConfigKey(key string) string {
+	currentType := reflect.TypeOf(param.Config{})
+	names := []string{}
+	for _, part := range strings.Split(key, ".") {
+		field, present := findFieldByTag(currentType, "mapstructure", part)
+		if !present {
+			return key
+		}
+		names = append(names, field.Tag.Get("yaml"))
+		if field.Type.Kind() != reflect.Struct {
+			break
+		}
+		currentType = field.Type
+	}
+	return strings.Join(names, ".")
+}
+
+// Whether an environment variable overrides the key or one of its parents
+func envOverridesKey(key string) bool {
+	prefixes := []string{PelicanPrefix.String()}
+	if prefix := GetPreferredPrefix(); prefix != PelicanPrefix {
+		prefixes = append(prefixes, prefix.String())
+	}
+	for {
+		envSuffix := strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		for _, prefix := range prefixes {
+			if _, ok := os.LookupEnv(prefix + "_" + envSuffix); ok {
+				return true
+			}
+		}
+		idx := strings.LastIndex(key, ".")
+		if idx < 0 {
+			return false
+		}
+		key = key[:idx]
+	}
+}
+
+// Read the configuration files into a new viper instance, layered the same way as InitConfig and InitServer do
+func loadConfigFiles() (*viper.Viper, error) {
+	v := viper.New()
+	SetBaseDefaultsInConfig(v)
+	v.SetConfigType("yaml")
+	v.SetConfigName("pelican")
+	v.Set("ConfigDir", viper.GetString("ConfigDir"))
+	if configFile := viper.GetString("config"); configFile != "" {
+		v.SetConfigFile(configFile)
+	} else {
+		v.AddConfigPath(viper.GetString("ConfigDir"))
+	}
+	if err := v.MergeInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return nil, err
+		}
+	}
+	if err := handleConfigFragments(v); err != nil {
+		return nil, err
+	}
+	if err := handleContinuedCfg(v); err != nil {
+		return nil, err
+	}
+	if err := handleConfigOverlays(v); err != nil {
+		return nil, err
+	}
+	if webConfigPath := param.Server_WebConfigFile.GetString(); webConfigPath != "" {
+		if err := setWebConfigOverride(v, webConfigPath); err != nil {
+			return nil, errors.Wrap(err, "failed to read the web UI configuration")
+		}
+	}
+	return v, nil
+}
+
+func configSettings(v *viper.Viper) map[string]any {
+	settings := make(map[string]any)
+	for _, key := range v.AllKeys() {
+		settings[key] = v.Get(key)
+	}
+	return settings
+}
+
+// The keys whose values differ between the two settings
+func changedConfigKeys(before, after map[string]any) []string {
+	changed := []string{}
+	for key, value := range after {
+		if old, ok := before[key]; !ok || !reflect.DeepEqual(old, value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func appendUnique(list []string, item string) []string {
+	for _, existing := range list {
+		if existing == item {
+			return list
+		}
+	}
+	return append(list, item)
+}
+
+// Record the configuration the server started with so later reloads can tell what changed
+func initConfigReload() error {
+	v, err := loadConfigFiles()
+	if err != nil {
+		return err
+	}
+	settings := configSettings(v)
+	reloadState.mutex.Lock()
+	defer reloadState.mutex.Unlock()
+	reloadState.startup = settings
+	reloadState.current = settings
+	reloadState.lastResult = nil
+	return nil
+}
+
+// Re-read the configuration files and apply the changes to reload-safe parameters.
+// Other changes are reported as requiring a restart.
+func ReloadConfig() (*ConfigReloadResult, error) {
+	reloadState.mutex.Lock()
+	defer reloadState.mutex.Unlock()
+	if reloadState.current == nil {
+		return nil, errors.New("configuration reloading has not been initialized")
+	}
+
+	v, err := loadConfigFiles()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to re-read the configuration; no changes were applied")
+	}
+	newSettings := configSettings(v)
+
+	result := &ConfigReloadResult{
+		Applied:         []string{},
+		RequiresRestart: []string{},
+		Failed:          map[string]string{},
+		ReloadedAt:      time.Now(),
+	}
+
+	// Group the changed keys by the reload-safe parameter they belong to
+	toApply := []reloadable{}
+	for _, key := range changedConfigKeys(reloadState.current, newSettings) {
+		if envOverridesKey(key) {
+			result.Ignored = appendUnique(result.Ignored, canonicalConfigKey(key))
+			continue
+		}
+		if r, ok := findReloadable(key); ok {
+			found := false
+			for _, existing := range toApply {
+				found = found || existing.key == r.key
+			}
+			if !found {
+				toApply = append(toApply, r)
+			}
+		}
+	}
+
+	for _, r := range toApply {
+		oldValue := viper.Get(r.key)
+		newValue := v.Get(r.key)
+		if newValue == nil && oldValue != nil {
+			// The key was removed and has no default; fall back to the type's zero value
+			newValue = reflect.Zero(reflect.TypeOf(oldValue)).Interface()
+		}
+		viper.Set(r.key, newValue)
+		if r.hook != nil {
+			if err := r.hook(); err != nil {
+				viper.Set(r.key, oldValue)
+				if revertErr := r.hook(); revertErr != nil {
+					log.Errorf("Failed to restore the previous value of %s: %v", r.key, revertErr)
+				}
+				result.Failed[r.key] = err.Error()
+				// Keep the old file values so the next reload tries again
+				prefix := strings.ToLower(r.key)
+				for key := range newSettings {
+					if key == prefix || strings.HasPrefix(key, prefix+".") {
+						delete(newSettings, key)
+					}
+				}
+				for key, value := range reloadState.current {
+					if key == prefix || strings.HasPrefix(key, prefix+".") {
+						newSettings[key] = value
+					}
+				}
+				continue
+			}
+		}
+		result.Applied = append(result.Applied, r.key)
+	}
+
+	for _, key := range changedConfigKeys(reloadState.startup, newSettings) {
+		if _, ok := findReloadable(key); ok || envOverridesKey(key) {
+			continue
+		}
+		result.RequiresRestart = appendUnique(result.RequiresRestart, canonicalConfigKey(key))
+	}
+
+	reloadState.current = newSettings
+	reloadState.lastResult = result
+
+	if len(result.Applied) > 0 {
+		log.Infof("Applied configuration changes to: %s", strings.Join(result.Applied, ", "))
+	}
+	for key, reason := range result.Failed {
+		log.Errorf("Rejected the new value of %s: %s", key, reason)
+	}
+	if len(result.Ignored) > 0 {
+		log.Warningf("Configuration changes to %s have no effect as they are overridden by environment variables", strings.Join(result.Ignored, ", "))
+	}
+	if len(result.RequiresRestart) > 0 {
+		log.Warningf("Configuration changes to %s require a restart to take effect", strings.Join(result.RequiresRestart, ", "))
+	}
+	return result, nil
+}
+
+// Return the result of the last reload, or nil if the configuration hasn't been reloaded
+func GetLastConfigReload() *ConfigReloadResult {
+	reloadState.mutex.Lock()
+	defer reloadState.mutex.Unlock()
+	return reloadState.lastResult
+}
+
+// A summary of the modification times and sizes of the configuration files and the
+// directories holding them, which changes whenever a file is edited, added or removed
+func configFilesFingerprint() string {
+	configDir := viper.GetString("ConfigDir")
+	paths := []string{
+		viper.ConfigFileUsed(),
+		filepath.Join(configDir, "pelican.yaml"),
+		filepath.Join(configDir, "pelican.d"),
+		filepath.Join(configDir, "sites"),
+		filepath.Join(configDir, "instances"),
+		param.Server_WebConfigFile.GetString(),
+	}
+	if fragments, err := listConfigFragments(filepath.Join(configDir, "pelican.d")); err == nil {
+		paths = append(paths, fragments...)
+	}
+	for _, overlay := range []struct{ key, dir string }{{"ConfigSite", "sites"}, {"ConfigInstance", "instances"}} {
+		if name := viper.GetString(overlay.key); name != "" {
+			paths = append(paths, filepath.Join(configDir, overlay.dir, name+".yaml"))
+		}
+	}
+	for _, dir := range viper.GetStringSlice("ConfigLocations") {
+		paths = append(paths, dir)
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+	}
+
+	var fingerprint strings.Builder
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&fingerprint, "%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size())
+		} else {
+			fmt.Fprintf(&fingerprint, "%s:missing;", path)
+		}
+	}
+	return fingerprint.String()
+}
+
+// Watch for changes to the configuration files, polling every Server.ConfigWatchInterval,
+// and for SIGHUP, reloading the configuration whenever either occurs
+func LaunchConfigWatcher(ctx context.Context, egrp *errgroup.Group) error {
+	if err := initConfigReload(); err != nil {
+		return errors.Wrap(err, "failed to read the configuration for live reloading")
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	interval := param.Server_ConfigWatchInterval.GetDuration()
+
+	egrp.Go(func() error {
+		defer signal.Stop(sighup)
+		// A nil channel never fires, leaving only SIGHUP and the API to trigger reloads
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		fingerprint := configFilesFingerprint()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-sighup:
+				log.Info("Received SIGHUP; reloading the configuration")
+			case <-tick:
+				newFingerprint := configFilesFingerprint()
+				if newFingerprint == fingerprint {
+					continue
+				}
+				log.Info("The configuration files changed; reloading the configuration")
+			}
+			fingerprint = configFilesFingerprint()
+			if _, err := ReloadConfig(); err != nil {
+				log.Errorln("Failed to reload the configuration:", err)
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,120 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalConfigKey(t *testing.T) {
+	assert.Equal(t, "Logging.Level", canonicalConfigKey("logging.level"))
+	assert.Equal(t, "Logging.Modules", canonicalConfigKey("logging.modules.director"))
+	assert.Equal(t, "not.a.param", canonicalConfigKey("not.a.param"))
+}
+
+func TestReloadConfig(t *testing.T) {
+	ResetConfig()
+	oldLevel := log.GetLevel()
+	t.Cleanup(func() {
+		ResetConfig()
+		log.SetLevel(oldLevel)
+		reloadablesMutex.Lock()
+		delete(reloadables, "cache.selftestinterval")
+		reloadablesMutex.Unlock()
+		reloadState = configReloadState{}
+	})
+
+	_, err := ReloadConfig()
+	assert.Error(t, err, "Reloading should fail before it's initialized")
+
+	configDir := t.TempDir()
+	configFile := filepath.Join(configDir, "pelican.yaml")
+	writeConfig := func(lines ...string) {
+		require.NoError(t, os.WriteFile(configFile, []byte(strings.Join(lines, "\n")), 0644))
+	}
+	viper.Set("ConfigDir", configDir)
+	writeConfig("Logging:", "  Level: info", "Server:", "  WebPort: 1234", "Cache:", "  SelfTestInterval: 15s")
+	viper.SetConfigType("yaml")
+	viper.SetConfigFile(configFile)
+	require.NoError(t, viper.MergeInConfig())
+
+	var hookErr error
+	hookCalls := 0
+	RegisterReloadable(func() error {
+		hookCalls++
+		return hookErr
+	}, "Cache.SelfTestInterval")
+	require.NoError(t, initConfigReload())
+
+	t.Run("apply-and-report-restart", func(t *testing.T) {
+		writeConfig("Logging:", "  Level: debug", "Server:", "  WebPort: 5678", "Cache:", "  SelfTestInterval: 30s")
+		result, err := ReloadConfig()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Cache.SelfTestInterval", "Logging.Level"}, result.Applied)
+		assert.Equal(t, []string{"Server.WebPort"}, result.RequiresRestart)
+		assert.Empty(t, result.Failed)
+		assert.Equal(t, 1, hookCalls)
+		assert.Equal(t, "debug", viper.GetString("Logging.Level"))
+		assert.Equal(t, "30s", viper.GetString("Cache.SelfTestInterval"))
+		assert.Equal(t, result, GetLastConfigReload())
+	})
+
+	t.Run("rejected-value-is-reverted", func(t *testing.T) {
+		hookErr = errors.New("bad interval")
+		writeConfig("Logging:", "  Level: debug", "Server:", "  WebPort: 5678", "Cache:", "  SelfTestInterval: 1ms")
+		result, err := ReloadConfig()
+		require.NoError(t, err)
+		assert.Empty(t, result.Applied)
+		assert.Equal(t, map[string]string{"Cache.SelfTestInterval": "bad interval"}, result.Failed)
+		assert.Equal(t, "30s", viper.GetString("Cache.SelfTestInterval"))
+
+		// The rejected change is tried again on the next reload
+		hookErr = nil
+		result, err = ReloadConfig()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Cache.SelfTestInterval"}, result.Applied)
+		assert.Equal(t, "1ms", viper.GetString("Cache.SelfTestInterval"))
+	})
+
+	t.Run("environment-overrides", func(t *testing.T) {
+		t.Setenv("PELICAN_LOGGING_LEVEL", "debug")
+		writeConfig("Logging:", "  Level: warn", "Server:", "  WebPort: 1234", "Cache:", "  SelfTestInterval: 1ms")
+		result, err := ReloadConfig()
+		require.NoError(t, err)
+		assert.Empty(t, result.Applied)
+		assert.Equal(t, []string{"Logging.Level"}, result.Ignored)
+		// Reverting a change clears the need for a restart
+		assert.Empty(t, result.RequiresRestart)
+	})
+
+	t.Run("invalid-yaml", func(t *testing.T) {
+		writeConfig("Logging: [")
+		_, err := ReloadConfig()
+		assert.Error(t, err)
+	})
+}
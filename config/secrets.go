@@ -0,0 +1,274 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+const (
+	secretFilePrefix  = "file:"
+	secretEnvPrefix   = "env:"
+	secretVaultPrefix = "vault:"
+)
+
+var (
+	// Parameters whose values are themselves secrets
+	secretValueParams = []param.StringParam{
+		param.OIDC_ClientID,
+		param.Plugin_Token,
+		param.Shoveler_StompPassword,
+	}
+
+	// Parameters naming a file that holds a secret. A reference in one of these
+	// is written to a private file and the parameter is pointed at that file.
+	secretFileParams = []param.StringParam{
+		param.IssuerKey,
+		param.OIDC_ClientIDFile,
+		param.OIDC_ClientSecretFile,
+		param.Origin_GlobusClientIDFile,
+		param.Origin_GlobusClientSecretFile,
+		param.Origin_S3AccessKeyfile,
+		param.Origin_S3SecretKeyfile,
+		param.Server_SessionSecretFile,
+		param.Server_TLSCAKey,
+		param.Server_TLSKey,
+		param.Shoveler_StompCertKey,
+	}
+)
+
+// Whether a parameter value references a secret stored elsewhere
+func isSecretReference(value string) bool {
+	return strings.HasPrefix(value, secretFilePrefix) || strings.HasPrefix(value, secretEnvPrefix) ||
+		strings.HasPrefix(value, secretVaultPrefix)
+}
+
+// Resolve a `file:/path`, `env:VAR`, or `vault:path#field` reference to the secret it names
+func resolveSecretReference(ctx context.Context, ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, secretFilePrefix):
+		contents, err := os.ReadFile(strings.TrimPrefix(ref, secretFilePrefix))
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read secret file")
+		}
+		return strings.TrimSpace(string(contents)), nil
+	case strings.HasPrefix(ref, secretEnvPrefix):
+		name := strings.TrimPrefix(ref, secretEnvPrefix)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", errors.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, secretVaultPrefix):
+		return readVaultSecret(ctx, strings.TrimPrefix(ref, secretVaultPrefix))
+	}
+	return ref, nil
+}
+
+// Build an HTTP client for Vault, honoring the VAULT_CACERT convention of the Vault CLI
+func vaultClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile := os.Getenv("VAULT_CACERT"); caFile != "" {
+		caPem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read VAULT_CACERT")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, errors.Errorf("no certificates found in VAULT_CACERT file %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}, nil
+}
+
+// Read a field of a secret from HashiCorp Vault. The reference is the API path of the
+// secret, e.g. `secret/data/pelican#client_secret` for the client_secret field of a KV
+// version 2 secret; the field may be omitted if the secret has a single field. Vault is
+// located and authenticated with the VAULT_ADDR and VAULT_TOKEN environment variables
+// (falling back to ~/.vault-token), plus VAULT_NAMESPACE if set.
+func readVaultSecret(ctx context.Context, ref string) (string, error) {
+	secretPath, field, _ := strings.Cut(ref, "#")
+	vaultAddr := os.Getenv("VAULT_ADDR")
+	if vaultAddr == "" {
+		return "", errors.New("VAULT_ADDR must be set to read secrets from Vault")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			if contents, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				token = strings.TrimSpace(string(contents))
+			}
+		}
+	}
+	if token == "" {
+		return "", errors.New("VAULT_TOKEN must be set to read secrets from Vault")
+	}
+
+	secretUrl, err := url.JoinPath(vaultAddr, "v1", strings.TrimPrefix(secretPath, "/"))
+	if err != nil {
+		return "", errors.Wrap(err, "invalid VAULT_ADDR")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, secretUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	client, err := vaultClient()
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to contact Vault")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("Vault returned status %d for secret %s", resp.StatusCode, secretPath)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", errors.Wrap(err, "failed to parse the response from Vault")
+	}
+	data := secret.Data
+	// KV version 2 nests the fields of the secret alongside its metadata
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = inner
+		}
+	}
+	if field == "" {
+		if len(data) != 1 {
+			return "", errors.Errorf("secret %s has %d fields; select one with %s#<field>", secretPath, len(data), secretPath)
+		}
+		for name := range data {
+			field = name
+		}
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", errors.Errorf("secret %s has no field %q", secretPath, field)
+	}
+	valueStr, ok := value.(string)
+	if !ok {
+		return "", errors.Errorf("field %q of secret %s is not a string", field, secretPath)
+	}
+	return valueStr, nil
+}
+
+// The private directory that resolved file-valued secrets are written to
+func getSecretsDir() string {
+	if IsRootExecution() {
+		return filepath.Join("/run", "pelican", "secrets")
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "pelican", "secrets")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("pelican-secrets-%d", os.Getuid()))
+}
+
+// Write a resolved secret to a file only its owner can read
+func writeSecretFile(dir, name, secret string) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", errors.Wrap(err, "failed to create the secrets directory")
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return "", errors.Wrap(err, "failed to restrict the secrets directory")
+	}
+	secretPath := filepath.Join(dir, strings.ToLower(name))
+	// Write to a new file so the permissions are never those of a stale copy
+	tmpFile, err := os.CreateTemp(dir, ".secret-*")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create the secret file")
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.WriteString(secret + "\n"); err != nil {
+		tmpFile.Close()
+		return "", errors.Wrap(err, "failed to write the secret file")
+	}
+	if err := tmpFile.Close(); err != nil {
+		return "", errors.Wrap(err, "failed to write the secret file")
+	}
+	if err := os.Rename(tmpFile.Name(), secretPath); err != nil {
+		return "", errors.Wrap(err, "failed to write the secret file")
+	}
+	return secretPath, nil
+}
+
+// Replace references to secrets in secret-valued parameters with the secrets they
+// name, so secrets never have to live in the configuration itself
+func resolveSecretParams(ctx context.Context, v *viper.Viper, secretsDir string) error {
+	for _, secretParam := range secretValueParams {
+		ref := v.GetString(secretParam.GetName())
+		if !isSecretReference(ref) {
+			continue
+		}
+		secret, err := resolveSecretReference(ctx, ref)
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve the secret referenced by %s", secretParam.GetName())
+		}
+		v.Set(secretParam.GetName(), secret)
+		log.Debugf("Resolved the secret referenced by %s", secretParam.GetName())
+	}
+
+	for _, secretParam := range secretFileParams {
+		ref := v.GetString(secretParam.GetName())
+		if !isSecretReference(ref) {
+			continue
+		}
+		// The parameter already names a file, so there's nothing to resolve
+		if strings.HasPrefix(ref, secretFilePrefix) {
+			v.Set(secretParam.GetName(), strings.TrimPrefix(ref, secretFilePrefix))
+			continue
+		}
+		secret, err := resolveSecretReference(ctx, ref)
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve the secret referenced by %s", secretParam.GetName())
+		}
+		secretPath, err := writeSecretFile(secretsDir, secretParam.GetName(), secret)
+		if err != nil {
+			return errors.Wrapf(err, "failed to store the secret referenced by %s", secretParam.GetName())
+		}
+		v.Set(secretParam.GetName(), secretPath)
+		log.Debugf("Resolved the secret referenced by %s into %s", secretParam.GetName(), secretPath)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSecretReference(t *testing.T) {
+	ctx := context.Background()
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("from-file\n"), 0600))
+	t.Setenv("PELICAN_TEST_SECRET", "from-env")
+
+	secret, err := resolveSecretReference(ctx, "file:"+secretFile)
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", secret)
+
+	secret, err = resolveSecretReference(ctx, "env:PELICAN_TEST_SECRET")
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", secret)
+
+	secret, err = resolveSecretReference(ctx, "literal")
+	require.NoError(t, err)
+	assert.Equal(t, "literal", secret)
+
+	_, err = resolveSecretReference(ctx, "env:PELICAN_TEST_MISSING_SECRET")
+	assert.Error(t, err)
+	_, err = resolveSecretReference(ctx, "file:"+filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestReadVaultSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/pelican":
+			_, _ = w.Write([]byte(`{"data": {"data": {"client_secret": "kv2-secret", "other": "x"}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/pelican":
+			_, _ = w.Write([]byte(`{"data": {"password": "kv1-secret"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "test-token")
+	ctx := context.Background()
+
+	secret, err := resolveSecretReference(ctx, "vault:secret/data/pelican#client_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "kv2-secret", secret)
+
+	secret, err = resolveSecretReference(ctx, "vault:kv/pelican")
+	require.NoError(t, err)
+	assert.Equal(t, "kv1-secret", secret)
+
+	_, err = resolveSecretReference(ctx, "vault:secret/data/pelican")
+	assert.ErrorContains(t, err, "select one")
+	_, err = resolveSecretReference(ctx, "vault:secret/data/pelican#missing")
+	assert.ErrorContains(t, err, "no field")
+	_, err = resolveSecretReference(ctx, "vault:secret/data/missing#field")
+	assert.ErrorContains(t, err, "status 404")
+
+	t.Setenv("VAULT_TOKEN", "wrong-token")
+	_, err = resolveSecretReference(ctx, "vault:kv/pelican")
+	assert.ErrorContains(t, err, "status 403")
+}
+
+func TestResolveSecretParams(t *testing.T) {
+	t.Setenv("PELICAN_TEST_CLIENT_ID", "my-client")
+	t.Setenv("PELICAN_TEST_S3_SECRET", "s3-secret")
+	secretsDir := filepath.Join(t.TempDir(), "secrets")
+
+	v := viper.New()
+	v.Set("OIDC.ClientID", "env:PELICAN_TEST_CLIENT_ID")
+	v.Set("Origin.S3SecretKeyfile", "env:PELICAN_TEST_S3_SECRET")
+	v.Set("Server.TLSKey", "file:/etc/pelican/tls.key")
+	v.Set("IssuerKey", "/etc/pelican/issuer.jwk")
+	require.NoError(t, resolveSecretParams(context.Background(), v, secretsDir))
+
+	assert.Equal(t, "my-client", v.GetString("OIDC.ClientID"))
+	assert.Equal(t, "/etc/pelican/tls.key", v.GetString("Server.TLSKey"))
+	assert.Equal(t, "/etc/pelican/issuer.jwk", v.GetString("IssuerKey"))
+
+	secretPath := v.GetString("Origin.S3SecretKeyfile")
+	assert.Equal(t, filepath.Join(secretsDir, "origin.s3secretkeyfile"), secretPath)
+	contents, err := os.ReadFile(secretPath)
+	require.NoError(t, err)
+	assert.Equal(t, "s3-secret\n", string(contents))
+	info, err := os.Stat(secretPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(secretsDir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	v.Set("OIDC.ClientID", "env:PELICAN_TEST_MISSING_SECRET")
+	assert.ErrorContains(t, resolveSecretParams(context.Background(), v, secretsDir), "OIDC.ClientID")
+}
@@ -37,6 +37,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/metrics"
 )
 
@@ -63,7 +64,7 @@ func checkPIDExists(pid int) bool {
 }
 
 func ForwardCommandToLogger(ctx context.Context, daemonName string, cmdStdout io.ReadCloser, cmdStderr io.ReadCloser) {
-	cmd_logger := log.WithFields(log.Fields{"daemon": daemonName})
+	cmd_logger := config.ModuleLogger(daemonName).WithFields(log.Fields{"daemon": daemonName})
 	stdout_scanner := bufio.NewScanner(cmdStdout)
 	stdout_lines := make(chan string, 10)
 
@@ -109,6 +110,11 @@ func (launcher DaemonLauncher) Launch(ctx context.Context) (context.Context, int
 	if cmd.Err != nil {
 		return ctx, -1, cmd.Err
 	}
+	// Give the daemon a chance to shut down cleanly when the context is cancelled
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = 10 * time.Second
 	cmdStdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return ctx, -1, err
@@ -197,6 +203,7 @@ func LaunchDaemons(ctx context.Context, launchers []Launcher, egrp *errgroup.Gro
 	cases[len(daemons)+1].Dir = reflect.SelectRecv
 
 	egrp.Go(func() error {
+		defer signal.Stop(sigs)
 		for {
 			timer := time.NewTimer(time.Second)
 			cases[len(daemons)+1].Chan = reflect.ValueOf(timer.C)
@@ -237,7 +244,8 @@ func LaunchDaemons(ctx context.Context, launchers []Launcher, egrp *errgroup.Gro
 				if waitResult := context.Cause(daemons[chosen].ctx); waitResult != nil {
 					if !daemons[chosen].expiry.IsZero() {
 						return nil
-					} else if errors.Is(waitResult, context.Canceled) {
+					} else if errors.Is(waitResult, context.Canceled) || ctx.Err() != nil {
+						// The daemons were stopped on purpose by cancelling the context
 						return nil
 					}
 					metricName := strings.SplitN(launchers[chosen].Name(), ".", 2)[0]
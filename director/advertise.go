@@ -21,6 +21,8 @@ package director
 import (
 	"context"
 	"encoding/xml"
+	"fmt"
+	"maps"
 	"net/http"
 	"net/url"
 	"strings"
@@ -30,6 +32,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/server_utils"
@@ -48,6 +51,15 @@ func consolidateDupServerAd(newAd, existingAd server_structs.ServerAd) server_st
 	consolidatedAd.Caps.Reads = existingAd.Caps.Reads || newAd.Caps.Reads
 	consolidatedAd.Caps.Writes = existingAd.Caps.Writes || newAd.Caps.Writes
 	consolidatedAd.Caps.Listings = existingAd.Caps.Listings || newAd.Caps.Listings
+	// Likewise take the union of the experimental capabilities, keeping the existing values
+	if len(newAd.Caps.Extensions) > 0 {
+		consolidatedAd.Caps.Extensions = maps.Clone(existingAd.Caps.Extensions)
+		for name, value := range newAd.Caps.Extensions {
+			if _, ok := consolidatedAd.Caps.Extensions[name]; !ok {
+				consolidatedAd.Caps.SetExtension(name, value)
+			}
+		}
+	}
 
 	return consolidatedAd
 }
@@ -110,10 +122,29 @@ func parseServerAdFromTopology(server server_structs.TopoServer, serverType serv
 		}
 	}
 
+	serverAd.ResourceGroup = server.ResourceGroup
+	serverAd.Contacts = server.Contacts
+
 	// We will leave serverAd.WebURL as empty when fetched from topology
 	return serverAd
 }
 
+// The details of a server's current downtime in the OSG Topology
+type topologyDowntime struct {
+	// Whether the downtime is planned maintenance rather than a failure
+	Scheduled     bool      `json:"scheduled"`
+	Severity      string    `json:"severity,omitempty"`
+	Description   string    `json:"description,omitempty"`
+	ResourceGroup string    `json:"resourceGroup,omitempty"`
+	Services      []string  `json:"services,omitempty"`
+	StartTime     time.Time `json:"startTime"`
+	EndTime       time.Time `json:"endTime"`
+}
+
+// The current topology downtimes, keyed by the resource name of the server.
+// Guarded by filteredServersMutex, as it's updated along with filteredServers.
+var topologyDowntimes = map[string]topologyDowntime{}
+
 // Use the topology downtime endpoint to create the list of downed servers. Servers are tracked using their
 // resource name, NOT their FQDN.
 func updateDowntimeFromTopology(ctx context.Context) error {
@@ -139,10 +170,11 @@ func updateDowntimeFromTopology(ctx context.Context) error {
 	defer filteredServersMutex.Unlock()
 	// Remove existing filteredSevers that are fetched from the topology first
 	for key, val := range filteredServers {
-		if val == topoFiltered {
+		if val == topoFiltered || val == topoUnscheduledFiltered {
 			delete(filteredServers, key)
 		}
 	}
+	topologyDowntimes = map[string]topologyDowntime{}
 
 	const timeLayout = "Jan 2, 2006 15:04 PM MST" // see https://pkg.go.dev/time#pkg-constants
 	for _, downtime := range downtimeInfo.CurrentDowntimes.Downtimes {
@@ -159,8 +191,37 @@ func updateDowntimeFromTopology(ctx context.Context) error {
 		}
 
 		currentTime := time.Now()
-		if parsedStartDT.Before(currentTime) && parsedEndDT.After(currentTime) {
+		if !parsedStartDT.Before(currentTime) || !parsedEndDT.After(currentTime) {
+			continue
+		}
+		if !downtime.AffectsDataServices() {
+			log.Debugf("Ignoring the downtime of %s because it doesn't cover the resource's cache or origin", downtime.ResourceName)
+			continue
+		}
+
+		// Scheduled maintenance filters the server entirely, so it takes precedence
+		// over a failure reported at the same time
+		if existing, ok := topologyDowntimes[downtime.ResourceName]; ok && existing.Scheduled {
+			continue
+		}
+		services := make([]string, 0, len(downtime.Services.Service))
+		for _, service := range downtime.Services.Service {
+			services = append(services, service.Name)
+		}
+		topologyDowntimes[downtime.ResourceName] = topologyDowntime{
+			Scheduled:     downtime.IsScheduled(),
+			Severity:      downtime.Severity,
+			Description:   downtime.Description,
+			ResourceGroup: downtime.ResourceGroup.GroupName,
+			Services:      services,
+			StartTime:     parsedStartDT,
+			EndTime:       parsedEndDT,
+		}
+		if downtime.IsScheduled() {
 			filteredServers[downtime.ResourceName] = topoFiltered
+		} else if _, exists := filteredServers[downtime.ResourceName]; !exists {
+			// Don't let a failure lift a filter the admin put on the server
+			filteredServers[downtime.ResourceName] = topoUnscheduledFiltered
 		}
 	}
 
@@ -168,14 +229,29 @@ func updateDowntimeFromTopology(ctx context.Context) error {
 	return nil
 }
 
+// Record the outcome of fetching a resource (namespaces or downtime) from topology
+func recordTopologyFetch(resource string, start time.Time, err error) {
+	metrics.PelicanDirectorTopologyFetchDuration.WithLabelValues(resource).Set(time.Since(start).Seconds())
+	if err != nil {
+		metrics.PelicanDirectorTopologyFetches.WithLabelValues(resource, string(metrics.MetricFailed)).Inc()
+		return
+	}
+	metrics.PelicanDirectorTopologyFetches.WithLabelValues(resource, string(metrics.MetricSucceeded)).Inc()
+	metrics.PelicanDirectorTopologyLastSync.WithLabelValues(resource).Set(float64(time.Now().Unix()))
+}
+
 // Populate internal cache with origin/cache ads
 func AdvertiseOSDF(ctx context.Context) error {
+	start := time.Now()
 	namespaces, err := server_utils.GetTopologyJSON(ctx)
+	recordTopologyFetch("namespaces", start, err)
 	if err != nil {
 		return errors.Wrapf(err, "Failed to get topology JSON")
 	}
 
+	start = time.Now()
 	err = updateDowntimeFromTopology(ctx)
+	recordTopologyFetch("downtime", start, err)
 	if err != nil {
 		// Don't treat this as a fatal error, but log it in a loud way.
 		log.Errorf("Unable to generate downtime list for servers from topology: %v", err)
@@ -184,6 +260,7 @@ func AdvertiseOSDF(ctx context.Context) error {
 	cacheAdMap := make(map[string]*server_structs.Advertisement)  // key is serverAd.URL.String()
 	originAdMap := make(map[string]*server_structs.Advertisement) // key is serverAd.URL.String()
 	tGen := server_structs.TokenGen{}
+	issues := newTopologyIssueCollector()
 	for _, ns := range namespaces.Namespaces {
 		requireToken := ns.UseTokenOnRead
 
@@ -240,6 +317,9 @@ func AdvertiseOSDF(ctx context.Context) error {
 			Listings:    listings,
 			DirectReads: true, // Topology namespaces should probably always have this turned on
 		}
+		for name, value := range ns.ExperimentalCapabilities {
+			caps.SetExtension(name, fmt.Sprint(value))
+		}
 		nsAd := server_structs.NamespaceAdV2{
 			Path:         ns.Path,
 			Caps:         caps,
@@ -257,6 +337,7 @@ func AdvertiseOSDF(ctx context.Context) error {
 		// will have the same set of capabilities as the namespace itself. Pelican has teased apart origins
 		// and namespaces, so this isn't true outside this limited context.
 		for _, origin := range ns.Origins {
+			issues.add(origin, server_structs.OriginType, ns.Path)
 			originAd := parseServerAdFromTopology(origin, server_structs.OriginType, caps)
 			if existingAd, ok := originAdMap[originAd.URL.String()]; ok {
 				existingAd.NamespaceAds = append(existingAd.NamespaceAds, nsAd)
@@ -269,6 +350,7 @@ func AdvertiseOSDF(ctx context.Context) error {
 		}
 
 		for _, cache := range ns.Caches {
+			issues.add(cache, server_structs.CacheType, ns.Path)
 			cacheAd := parseServerAdFromTopology(cache, server_structs.CacheType, server_structs.Capabilities{})
 			if existingAd, ok := cacheAdMap[cacheAd.URL.String()]; ok {
 				existingAd.NamespaceAds = append(existingAd.NamespaceAds, nsAd)
@@ -289,6 +371,8 @@ func AdvertiseOSDF(ctx context.Context) error {
 		recordAd(ctx, ad.ServerAd, &ad.NamespaceAds)
 	}
 
+	launchTopologyIssueChecks(ctx, issues)
+
 	return nil
 }
 
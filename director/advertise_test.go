@@ -25,16 +25,19 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
 	"text/template"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	logrustest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/server_utils"
 )
@@ -68,6 +71,13 @@ func TestConsolidateDupServerAd(t *testing.T) {
 		newAd = server_structs.ServerAd{Caps: server_structs.Capabilities{Reads: true, Writes: true, DirectReads: true, Listings: true}}
 		get = consolidateDupServerAd(newAd, existingAd)
 		assert.EqualValues(t, server_structs.Capabilities{Reads: true, Writes: true, Listings: true, DirectReads: true}, get.Caps)
+
+		// Experimental capabilities are merged, keeping the existing values
+		existingAd = server_structs.ServerAd{Caps: server_structs.Capabilities{Extensions: map[string]string{"tpc": "v1"}}}
+		newAd = server_structs.ServerAd{Caps: server_structs.Capabilities{Extensions: map[string]string{"tpc": "v2", "zip-extraction": "true"}}}
+		get = consolidateDupServerAd(newAd, existingAd)
+		assert.Equal(t, map[string]string{"tpc": "v1", "zip-extraction": "true"}, get.Caps.Extensions)
+		assert.Equal(t, map[string]string{"tpc": "v1"}, existingAd.Caps.Extensions)
 	})
 
 	t.Run("take-existing-one-for-non-cap-fields", func(t *testing.T) {
@@ -238,6 +248,13 @@ func TestAdvertiseOSDF(t *testing.T) {
 		assert.True(t, nsAd.Caps.Listings)
 		assert.False(t, nsAd.Caps.PublicReads)
 		assert.True(t, nsAd.Caps.Listings)
+		assert.True(t, nsAd.Caps.HasExtension("tpc"))
+		assert.True(t, oAds[0].Caps.HasExtension("tpc"))
+		assert.Equal(t, "MY_RESOURCE_GROUP", oAds[0].ResourceGroup)
+		assert.Equal(t, []server_structs.TopoContact{{Name: "Origin Admin", Email: "origin-admin@example.com", Type: "Administrative Contact"}}, oAds[0].Contacts)
+		zipExtraction, ok := nsAd.Caps.Extension("zip-extraction")
+		assert.True(t, ok)
+		assert.Equal(t, "v1", zipExtraction)
 
 		nsAd, oAds, cAds = getAdsForPath("/my/server/2/path/to/file")
 		assert.Equal(t, "/my/server/2", nsAd.Path)
@@ -297,6 +314,61 @@ func TestAdvertiseOSDF(t *testing.T) {
 		assert.Equal(t, server_structs.CacheType.String(), foundAd.Type)
 		assert.Len(t, foundAd.NamespaceAds, 2)
 	})
+
+	t.Run("topology-fetches-are-recorded", func(t *testing.T) {
+		server_utils.ResetTestState()
+		serverAds.DeleteAll()
+		defer func() {
+			server_utils.ResetTestState()
+			serverAds.DeleteAll()
+		}()
+
+		fetches := func(resource string, status metrics.MetricSimpleStatus) float64 {
+			return testutil.ToFloat64(metrics.PelicanDirectorTopologyFetches.WithLabelValues(resource, string(status)))
+		}
+		lastSync := func(resource string) float64 {
+			return testutil.ToFloat64(metrics.PelicanDirectorTopologyLastSync.WithLabelValues(resource))
+		}
+
+		var topoDown atomic.Bool
+		topoServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if topoDown.Load() {
+				_, _ = w.Write([]byte("not the namespaces JSON"))
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+			multiExportsTopoJSONHandler(w, r)
+		}))
+		defer topoServer.Close()
+		downtimeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("not the downtime XML"))
+		}))
+		defer downtimeServer.Close()
+		viper.Set("Federation.TopologyNamespaceUrl", topoServer.URL)
+		viper.Set("Federation.TopologyDowntimeUrl", downtimeServer.URL)
+
+		// The namespaces are fetched, but the downtime isn't
+		nsSucceeded := fetches("namespaces", metrics.MetricSucceeded)
+		nsFailed := fetches("namespaces", metrics.MetricFailed)
+		dtFailed := fetches("downtime", metrics.MetricFailed)
+		dtLastSync := lastSync("downtime")
+		start := time.Now()
+		require.NoError(t, AdvertiseOSDF(context.Background()))
+		assert.Equal(t, nsSucceeded+1, fetches("namespaces", metrics.MetricSucceeded))
+		assert.Equal(t, nsFailed, fetches("namespaces", metrics.MetricFailed))
+		assert.GreaterOrEqual(t, testutil.ToFloat64(metrics.PelicanDirectorTopologyFetchDuration.WithLabelValues("namespaces")), 0.02)
+		assert.GreaterOrEqual(t, lastSync("namespaces"), float64(start.Unix()))
+		assert.Equal(t, dtFailed+1, fetches("downtime", metrics.MetricFailed))
+		assert.Equal(t, dtLastSync, lastSync("downtime"))
+
+		// A failed fetch of the namespaces leaves the time of the last sync alone
+		nsLastSync := lastSync("namespaces")
+		topoDown.Store(true)
+		require.Error(t, AdvertiseOSDF(context.Background()))
+		assert.Equal(t, nsSucceeded+1, fetches("namespaces", metrics.MetricSucceeded))
+		assert.Equal(t, nsFailed+1, fetches("namespaces", metrics.MetricFailed))
+		assert.Equal(t, nsLastSync, lastSync("namespaces"))
+	})
 }
 
 func mockTopoDowntimeXMLHandler(w http.ResponseWriter, r *http.Request) {
@@ -308,6 +380,31 @@ func mockTopoDowntimeXMLHandler(w http.ResponseWriter, r *http.Request) {
 				ResourceFQDN: "dtn-pas.bois.nrp.internet2.edu",
 				StartTime:    time.Now().Add(-24 * time.Hour).Format("Jan 2, 2006 03:04 PM MST"),
 				EndTime:      time.Now().Add(24 * time.Hour).Format("Jan 2, 2006 03:04 PM MST"),
+				Class:        "UNSCHEDULED",
+				Severity:     "Outage",
+				Services:     server_structs.TopoServices{Service: []server_structs.TopoService{{ID: 156, Name: "XRootD cache server", Description: "Internet2 Boise Cache"}}},
+				Description:  "HW issues",
+			},
+			{
+				// Planned maintenance of the resource's cache. Should be filtered
+				ResourceName: "CHICAGO_INTERNET2_OSDF_CACHE",
+				ResourceFQDN: "dtn-pas.chic.nrp.internet2.edu",
+				StartTime:    time.Now().Add(-1 * time.Hour).Format("Jan 2, 2006 03:04 PM MST"),
+				EndTime:      time.Now().Add(2 * time.Hour).Format("Jan 2, 2006 03:04 PM MST"),
+				Class:        "SCHEDULED",
+				Severity:     "Outage",
+				Services:     server_structs.TopoServices{Service: []server_structs.TopoService{{ID: 156, Name: "XRootD cache server"}}},
+				Description:  "OS upgrade",
+			},
+			{
+				// Only the resource's Squid is down. Should NOT be filtered
+				ResourceName: "KANSAS_INTERNET2_OSDF_CACHE",
+				ResourceFQDN: "dtn-pas.kans.nrp.internet2.edu",
+				StartTime:    time.Now().Add(-1 * time.Hour).Format("Jan 2, 2006 03:04 PM MST"),
+				EndTime:      time.Now().Add(2 * time.Hour).Format("Jan 2, 2006 03:04 PM MST"),
+				Class:        "UNSCHEDULED",
+				Severity:     "Outage",
+				Services:     server_structs.TopoServices{Service: []server_structs.TopoService{{ID: 138, Name: "Squid"}}},
 			},
 			{
 				// start time is after current time. Should NOT be filtered
@@ -375,8 +472,23 @@ func TestUpdateDowntimeFromTopology(t *testing.T) {
 	logOutput := logBuffer.String()
 	assert.Contains(t, logOutput, "Could not put FOOBAR into downtime because its start time")
 
-	assert.True(t, filteredServers["BOISE_INTERNET2_OSDF_CACHE"] == topoFiltered)
-	_, keyExists := filteredServers["DENVER_INTERNET2_OSDF_CACHE"]
+	assert.Equal(t, topoUnscheduledFiltered, filteredServers["BOISE_INTERNET2_OSDF_CACHE"])
+	downtime, ok := getTopologyDowntime("BOISE_INTERNET2_OSDF_CACHE")
+	require.True(t, ok)
+	assert.False(t, downtime.Scheduled)
+	assert.Equal(t, "Outage", downtime.Severity)
+	assert.Equal(t, "HW issues", downtime.Description)
+	assert.Equal(t, "I2BoiseInfrastructure", downtime.ResourceGroup)
+	assert.Equal(t, []string{"XRootD cache server"}, downtime.Services)
+
+	assert.Equal(t, topoFiltered, filteredServers["CHICAGO_INTERNET2_OSDF_CACHE"])
+	downtime, ok = getTopologyDowntime("CHICAGO_INTERNET2_OSDF_CACHE")
+	require.True(t, ok)
+	assert.True(t, downtime.Scheduled)
+
+	_, keyExists := filteredServers["KANSAS_INTERNET2_OSDF_CACHE"]
+	assert.False(t, keyExists, "KANSAS_INTERNET2_OSDF_CACHE only had its Squid in downtime")
+	_, keyExists = filteredServers["DENVER_INTERNET2_OSDF_CACHE"]
 	assert.False(t, keyExists, "DENVER_INTERNET2_OSDF_CACHE should not be in filteredServers")
 	_, keyExists = filteredServers["HOW_MUCH_CASH_COULD_A_STASHCACHE_STASH"]
 	assert.False(t, keyExists, "HOW_MUCH_CASH_COULD_A_STASHCACHE_STASH should not be in filteredServers")
@@ -0,0 +1,351 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/studio-b12/gowebdav"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// The caches holding one of the sampled objects
+	ObjectReplicas struct {
+		Path     string   `json:"path"`
+		Replicas int      `json:"replicas"`
+		Caches   []string `json:"caches"`
+	}
+
+	// How many of the sampled objects a cache holds
+	CacheCoverage struct {
+		Name     string  `json:"name"`
+		Region   string  `json:"region"`
+		Objects  int     `json:"objects"`
+		Fraction float64 `json:"fraction"`
+	}
+
+	// How many of the sampled objects are held by at least one cache of a region
+	RegionCoverage struct {
+		Region   string  `json:"region"`
+		Caches   int     `json:"caches"`
+		Objects  int     `json:"objects"`
+		Fraction float64 `json:"fraction"`
+	}
+
+	// The availability of a sample of a namespace's objects across the caches of the
+	// federation, for capacity planning
+	AvailabilityReport struct {
+		Prefix    string `json:"prefix"`
+		Namespace string `json:"namespace"`
+		Sampled   int    `json:"sampled"`
+		// The number of caches serving the namespace
+		Caches          int     `json:"caches"`
+		AverageReplicas float64 `json:"averageReplicas"`
+		// The number of sampled objects no cache holds
+		UncachedObjects int              `json:"uncachedObjects"`
+		ColdThreshold   float64          `json:"coldThreshold"`
+		Objects         []ObjectReplicas `json:"objects"`
+		CacheCoverage   []CacheCoverage  `json:"cacheCoverage"`
+		RegionCoverage  []RegionCoverage `json:"regionCoverage"`
+		// The regions whose caches hold less than ColdThreshold of the sampled objects
+		ColdSpots []string `json:"coldSpots"`
+	}
+
+	availabilityReportRequest struct {
+		Prefix  string `form:"prefix" binding:"required"`
+		Samples int    `form:"samples"`
+		// Objects to check instead of sampling the namespace
+		Objects       []string `form:"object"`
+		ColdThreshold *float64 `form:"coldThreshold"`
+	}
+)
+
+const (
+	defaultAvailabilitySamples = 20
+	maxAvailabilitySamples     = 1000
+	defaultColdThreshold       = 0.5
+
+	// The limits of the walk of a namespace looking for objects to sample
+	maxSampledDirectories = 200
+	maxSampledFiles       = 100000
+
+	// How many objects are checked against the caches at once
+	availabilityStatConcurrency = 10
+
+	// The region of caches that don't advertise one
+	unknownRegion = "unknown"
+)
+
+// Sample up to n objects under the prefix by walking the collection listing of an origin
+// breadth-first.  The walk stops after maxSampledDirectories directories or
+// maxSampledFiles files, so the sample of a large namespace comes from its top levels.
+func sampleNamespaceObjects(ctx context.Context, collectionsUrl url.URL, prefix, token string, n int) ([]string, error) {
+	client := gowebdav.NewClient(collectionsUrl.String(), "", "")
+	client.SetTransport(config.GetTransport())
+	if token != "" {
+		client.SetHeader("Authorization", "Bearer "+token)
+	}
+
+	sample := make([]string, 0, n)
+	seen := 0
+	dirs := []string{prefix}
+	for visited := 0; len(dirs) > 0 && visited < maxSampledDirectories && seen < maxSampledFiles; visited++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		dir := dirs[0]
+		dirs = dirs[1:]
+		infos, err := client.ReadDir(dir)
+		if err != nil {
+			// The prefix itself must be listable; a subdirectory failing only shrinks the sample
+			if visited == 0 {
+				return nil, errors.Wrapf(err, "failed to list %s at %s", dir, collectionsUrl.String())
+			}
+			log.Debugf("Skipping %s in the availability sample: %v", dir, err)
+			continue
+		}
+		for _, info := range infos {
+			objPath := path.Join(dir, info.Name())
+			if info.IsDir() {
+				dirs = append(dirs, objPath)
+				continue
+			}
+			// Reservoir sampling keeps each file seen with the same probability
+			seen++
+			if len(sample) < n {
+				sample = append(sample, objPath)
+			} else if idx := rand.Intn(seen); idx < n {
+				sample[idx] = objPath
+			}
+		}
+	}
+	sort.Strings(sample)
+	return sample, nil
+}
+
+// Check which of the caches serving the namespace hold each of the objects and summarize
+// the replicas by cache and region
+func buildAvailabilityReport(ctx context.Context, stat *ObjectStat, prefix string, namespaceAd server_structs.NamespaceAdV2, cacheAds []server_structs.ServerAd, objects []string, token string, coldThreshold float64) AvailabilityReport {
+	report := AvailabilityReport{
+		Prefix:         prefix,
+		Namespace:      namespaceAd.Path,
+		Sampled:        len(objects),
+		Caches:         len(cacheAds),
+		ColdThreshold:  coldThreshold,
+		Objects:        make([]ObjectReplicas, len(objects)),
+		CacheCoverage:  make([]CacheCoverage, 0, len(cacheAds)),
+		RegionCoverage: []RegionCoverage{},
+		ColdSpots:      []string{},
+	}
+
+	// Which of the cache ads hold each object
+	held := make([][]bool, len(objects))
+	egrp, egrpCtx := errgroup.WithContext(ctx)
+	egrp.SetLimit(availabilityStatConcurrency)
+	for idx, objPath := range objects {
+		held[idx] = make([]bool, len(cacheAds))
+		report.Objects[idx] = ObjectReplicas{Path: objPath, Caches: []string{}}
+		if len(cacheAds) == 0 {
+			continue
+		}
+		idx, objPath := idx, objPath
+		egrp.Go(func() error {
+			qr := stat.Query(egrpCtx, objPath, server_structs.CacheType, 1, len(cacheAds),
+				withCacheAds(cacheAds), withAuth(!namespaceAd.Caps.PublicReads), WithToken(token))
+			if qr.Status == queryFailed && qr.ErrorType != queryInsufficientResErr {
+				log.Debugf("Failed to query the caches for %s in the availability report: %s", objPath, qr.String())
+				return nil
+			}
+			for _, obj := range qr.Objects {
+				for cIdx, cAd := range cacheAds {
+					if cAd.URL.Host == obj.URL.Host || cAd.AuthURL.Host == obj.URL.Host {
+						held[idx][cIdx] = true
+					}
+				}
+			}
+			return nil
+		})
+	}
+	_ = egrp.Wait()
+
+	cacheObjects := make([]int, len(cacheAds))
+	regions := make(map[string]*RegionCoverage)
+	cacheRegions := make([]string, len(cacheAds))
+	for cIdx, cAd := range cacheAds {
+		region := strings.ToLower(cAd.Region)
+		if region == "" {
+			region = unknownRegion
+		}
+		cacheRegions[cIdx] = region
+		if regions[region] == nil {
+			regions[region] = &RegionCoverage{Region: region}
+		}
+		regions[region].Caches++
+	}
+
+	totalReplicas := 0
+	for idx := range objects {
+		inRegion := make(map[string]bool)
+		for cIdx, cAd := range cacheAds {
+			if !held[idx][cIdx] {
+				continue
+			}
+			report.Objects[idx].Caches = append(report.Objects[idx].Caches, cAd.Name)
+			cacheObjects[cIdx]++
+			inRegion[cacheRegions[cIdx]] = true
+		}
+		sort.Strings(report.Objects[idx].Caches)
+		report.Objects[idx].Replicas = len(report.Objects[idx].Caches)
+		totalReplicas += report.Objects[idx].Replicas
+		if report.Objects[idx].Replicas == 0 {
+			report.UncachedObjects++
+		}
+		for region := range inRegion {
+			regions[region].Objects++
+		}
+	}
+
+	fraction := func(count int) float64 {
+		if len(objects) == 0 {
+			return 0
+		}
+		return float64(count) / float64(len(objects))
+	}
+	report.AverageReplicas = fraction(totalReplicas)
+	for cIdx, cAd := range cacheAds {
+		report.CacheCoverage = append(report.CacheCoverage, CacheCoverage{
+			Name:     cAd.Name,
+			Region:   cacheRegions[cIdx],
+			Objects:  cacheObjects[cIdx],
+			Fraction: fraction(cacheObjects[cIdx]),
+		})
+	}
+	sort.Slice(report.CacheCoverage, func(i, j int) bool {
+		if report.CacheCoverage[i].Objects != report.CacheCoverage[j].Objects {
+			return report.CacheCoverage[i].Objects > report.CacheCoverage[j].Objects
+		}
+		return report.CacheCoverage[i].Name < report.CacheCoverage[j].Name
+	})
+	for _, regionCoverage := range regions {
+		regionCoverage.Fraction = fraction(regionCoverage.Objects)
+		report.RegionCoverage = append(report.RegionCoverage, *regionCoverage)
+		if len(objects) > 0 && regionCoverage.Fraction < coldThreshold {
+			report.ColdSpots = append(report.ColdSpots, regionCoverage.Region)
+		}
+	}
+	sort.Slice(report.RegionCoverage, func(i, j int) bool {
+		return report.RegionCoverage[i].Region < report.RegionCoverage[j].Region
+	})
+	sort.Strings(report.ColdSpots)
+	return report
+}
+
+// Report how many caches hold a sample of the objects under a namespace prefix.  The
+// objects are sampled from the collection listing of an origin exporting the namespace
+// unless they're given with the "object" query parameter.  The "authz" query parameter
+// is passed on to the origin and the caches for protected namespaces.
+func availabilityReportHandler(ginCtx *gin.Context) {
+	req := availabilityReportRequest{}
+	if err := ginCtx.ShouldBindQuery(&req); err != nil {
+		ginCtx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+	if req.Samples <= 0 {
+		req.Samples = defaultAvailabilitySamples
+	}
+	if req.Samples > maxAvailabilitySamples {
+		req.Samples = maxAvailabilitySamples
+	}
+	coldThreshold := defaultColdThreshold
+	if req.ColdThreshold != nil {
+		if *req.ColdThreshold < 0 || *req.ColdThreshold > 1 {
+			ginCtx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "The cold threshold must be between 0 and 1",
+			})
+			return
+		}
+		coldThreshold = *req.ColdThreshold
+	}
+	prefix := path.Clean("/" + req.Prefix)
+	token := getRequestParameters(ginCtx.Request).Get("authz")
+
+	namespaceAd, originAds, cacheAds := getAdsForPath(prefix)
+	if namespaceAd.Path == "" {
+		ginCtx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "No namespace found for the prefix " + prefix,
+		})
+		return
+	}
+
+	objects := make([]string, 0, len(req.Objects))
+	for _, object := range req.Objects {
+		objects = append(objects, path.Clean("/"+object))
+	}
+	if len(objects) == 0 {
+		var collectionsUrl *url.URL
+		for idx := range originAds {
+			if !namespaceAd.Caps.Listings || !originAds[idx].Caps.Listings {
+				continue
+			}
+			collectionsUrl = &originAds[idx].URL
+			if !namespaceAd.Caps.PublicReads && originAds[idx].AuthURL != (url.URL{}) {
+				collectionsUrl = &originAds[idx].AuthURL
+			}
+			break
+		}
+		if collectionsUrl == nil {
+			ginCtx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    fmt.Sprintf("No origin of the namespace %s allows listings to sample objects from; list the objects to check instead", namespaceAd.Path),
+			})
+			return
+		}
+		sampled, err := sampleNamespaceObjects(ginCtx, *collectionsUrl, prefix, token, req.Samples)
+		if err != nil {
+			log.Warningf("Failed to sample the objects under %s: %v", prefix, err)
+			ginCtx.JSON(http.StatusBadGateway, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    fmt.Sprintf("Failed to sample the objects under %s: %v", prefix, err),
+			})
+			return
+		}
+		objects = sampled
+	}
+
+	ginCtx.JSON(http.StatusOK, buildAvailabilityReport(ginCtx, NewObjectStat(), prefix, namespaceAd, cacheAds, objects, token, coldThreshold))
+}
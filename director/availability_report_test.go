@@ -0,0 +1,131 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/utils"
+)
+
+func TestSampleNamespaceObjects(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"data/a.txt", "data/b.txt", "data/sub/c.txt", "data/sub/deeper/d.txt", "other/e.txt"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("content"), 0644))
+	}
+	server := httptest.NewServer(&webdav.Handler{FileSystem: webdav.Dir(dir), LockSystem: webdav.NewMemLS()})
+	t.Cleanup(server.Close)
+	serverUrl, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	objects, err := sampleNamespaceObjects(context.Background(), *serverUrl, "/data", "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/data/a.txt", "/data/b.txt", "/data/sub/c.txt", "/data/sub/deeper/d.txt"}, objects)
+
+	objects, err = sampleNamespaceObjects(context.Background(), *serverUrl, "/data", "", 2)
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	for _, object := range objects {
+		assert.True(t, strings.HasPrefix(object, "/data/"), object)
+	}
+
+	_, err = sampleNamespaceObjects(context.Background(), *serverUrl, "/missing", "", 2)
+	assert.Error(t, err)
+}
+
+func TestBuildAvailabilityReport(t *testing.T) {
+	cacheAds := []server_structs.ServerAd{
+		{Name: "chicago", URL: url.URL{Scheme: "https", Host: "chicago.example.com"}, Region: "US-Central"},
+		{Name: "madison", URL: url.URL{Scheme: "https", Host: "madison.example.com"}, Region: "us-central"},
+		{Name: "amsterdam", URL: url.URL{Scheme: "https", Host: "amsterdam.example.com"}, Region: "eu"},
+		{Name: "unplaced", URL: url.URL{Scheme: "https", Host: "unplaced.example.com"}},
+	}
+	statUtilsMutex.Lock()
+	for _, cAd := range cacheAds {
+		ctx, cancel := context.WithCancel(context.Background())
+		statUtils[cAd.URL.String()] = serverStatUtil{
+			Context:     ctx,
+			Cancel:      cancel,
+			Errgroup:    &utils.Group{},
+			ResultCache: ttlcache.New[string, *objectMetadata](),
+		}
+	}
+	statUtilsMutex.Unlock()
+	t.Cleanup(func() {
+		statUtilsMutex.Lock()
+		defer statUtilsMutex.Unlock()
+		for _, cAd := range cacheAds {
+			statUtils[cAd.URL.String()].Cancel()
+			delete(statUtils, cAd.URL.String())
+		}
+	})
+
+	// Which caches hold each object
+	holders := map[string][]string{
+		"/data/hot.txt":  {"chicago.example.com", "madison.example.com", "amsterdam.example.com"},
+		"/data/warm.txt": {"chicago.example.com"},
+		"/data/cold.txt": {},
+	}
+	stat := NewObjectStat()
+	stat.ReqHandler = func(ctx context.Context, objectName string, dataUrl url.URL, digest bool, token string, timeout time.Duration) (*objectMetadata, error) {
+		for _, host := range holders[objectName] {
+			if host == dataUrl.Host {
+				return &objectMetadata{URL: *dataUrl.JoinPath(objectName)}, nil
+			}
+		}
+		return nil, &headReqNotFoundErr{fmt.Sprintf("%s not found", objectName)}
+	}
+
+	report := buildAvailabilityReport(context.Background(), stat, "/data", server_structs.NamespaceAdV2{Path: "/data", Caps: server_structs.Capabilities{PublicReads: true}},
+		cacheAds, []string{"/data/cold.txt", "/data/hot.txt", "/data/warm.txt"}, "", 0.5)
+
+	assert.Equal(t, "/data", report.Namespace)
+	assert.Equal(t, 3, report.Sampled)
+	assert.Equal(t, 4, report.Caches)
+	assert.InDelta(t, 4.0/3, report.AverageReplicas, 1e-9)
+	assert.Equal(t, 1, report.UncachedObjects)
+	assert.Equal(t, []ObjectReplicas{
+		{Path: "/data/cold.txt", Replicas: 0, Caches: []string{}},
+		{Path: "/data/hot.txt", Replicas: 3, Caches: []string{"amsterdam", "chicago", "madison"}},
+		{Path: "/data/warm.txt", Replicas: 1, Caches: []string{"chicago"}},
+	}, report.Objects)
+	assert.Equal(t, CacheCoverage{Name: "chicago", Region: "us-central", Objects: 2, Fraction: 2.0 / 3}, report.CacheCoverage[0])
+	assert.Equal(t, CacheCoverage{Name: "unplaced", Region: unknownRegion, Objects: 0, Fraction: 0}, report.CacheCoverage[3])
+	assert.Equal(t, []RegionCoverage{
+		{Region: "eu", Caches: 1, Objects: 1, Fraction: 1.0 / 3},
+		{Region: unknownRegion, Caches: 1, Objects: 0, Fraction: 0},
+		{Region: "us-central", Caches: 2, Objects: 2, Fraction: 2.0 / 3},
+	}, report.RegionCoverage)
+	assert.Equal(t, []string{"eu", unknownRegion}, report.ColdSpots)
+}
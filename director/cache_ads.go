@@ -44,8 +44,11 @@ type filterType string
 const (
 	permFiltered filterType = "permFiltered"     // Read from Director.FilteredServers
 	tempFiltered filterType = "tempFiltered"     // Filtered by web UI, e.g. the server is put in downtime via the director website
-	topoFiltered filterType = "topologyFiltered" // Filtered by Topology, e.g. the server is put in downtime via the OSDF Topology change
+	topoFiltered filterType = "topologyFiltered" // Filtered by Topology for a scheduled downtime, i.e. the server is put in maintenance via the OSDF Topology
 	tempAllowed  filterType = "tempAllowed"      // Read from Director.FilteredServers but mutated by web UI
+	// In an unscheduled Topology downtime, i.e. a failure rather than planned maintenance. The server may
+	// be back before Topology is updated, so it's not filtered but kept as a last resort
+	topoUnscheduledFiltered filterType = "topologyUnscheduledFiltered"
 )
 
 var (
@@ -65,6 +68,8 @@ func (f filterType) String() string {
 		return "Temporarily disabled via the admin website"
 	case topoFiltered:
 		return "Disabled via the Topology policy"
+	case topoUnscheduledFiltered:
+		return "Deprioritized via the Topology policy for an unscheduled downtime"
 	case tempAllowed:
 		return "Temporarily enabled via the admin website"
 	case "": // Here is to simplify the empty value at the UI side
@@ -25,11 +25,14 @@ import (
 	"time"
 
 	"github.com/jellydator/ttlcache/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/server_utils"
 )
@@ -370,6 +373,40 @@ func TestServerAdsCacheEviction(t *testing.T) {
 			require.False(t, true, "Cache didn't evict expired item")
 		}
 	})
+
+	t.Run("expirations-are-counted", func(t *testing.T) {
+		shutdownCtx, shutdownCancel := context.WithCancel(context.Background())
+		egrp, ctx := errgroup.WithContext(shutdownCtx)
+		LaunchTTLCache(ctx, egrp)
+		defer func() {
+			shutdownCancel()
+			err := egrp.Wait()
+			assert.NoError(t, err)
+		}()
+
+		// A server of its own, so evictions from the other tests aren't counted
+		expiringAd := server_structs.ServerAd{Name: "expiring", Type: server_structs.OriginType.String(), URL: url.URL{Host: "expiring.server.org"}}
+		expirations := metrics.PelicanDirectorServerAdExpirationsTotal.With(prometheus.Labels{
+			"server_name":   expiringAd.Name,
+			"server_type":   expiringAd.Type,
+			"from_topology": "false",
+		})
+		before := testutil.ToFloat64(expirations)
+
+		// Deleting an ad is not an expiration
+		serverAds.DeleteAll()
+		serverAds.Set(expiringAd.URL.String(), &server_structs.Advertisement{ServerAd: expiringAd}, ttlcache.DefaultTTL)
+		serverAds.Delete(expiringAd.URL.String())
+
+		serverAds.Set(expiringAd.URL.String(), &server_structs.Advertisement{ServerAd: expiringAd}, time.Second)
+		// The eviction callbacks run on their own goroutines
+		require.Eventually(t, func() bool {
+			return testutil.ToFloat64(expirations) == before+1
+		}, 5*time.Second, 50*time.Millisecond)
+		assert.False(t, serverAds.Has(expiringAd.URL.String()))
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, before+1, testutil.ToFloat64(expirations))
+	})
 }
 
 func TestRecordAd(t *testing.T) {
@@ -0,0 +1,470 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// A rule refusing redirects, pushed through the admin API during a security
+	// incident.  A request is denied if it matches every field the rule sets.
+	DenyRule struct {
+		ID string `gorm:"primaryKey" json:"id"`
+		// The issuer of the request's token
+		Issuer string `json:"issuer,omitempty"`
+		// The namespace prefix the requested object is under
+		Prefix string `json:"prefix,omitempty"`
+		// The network the client connects from
+		CIDR      string    `gorm:"column:cidr" json:"cidr,omitempty"`
+		Reason    string    `json:"reason"`
+		CreatedBy string    `json:"createdBy"`
+		CreatedAt time.Time `json:"createdAt"`
+		ExpiresAt time.Time `json:"expiresAt"`
+
+		network netip.Prefix
+	}
+
+	// A record of a deny rule being created, removed, or expiring
+	DenyRuleAudit struct {
+		ID        uint           `gorm:"primaryKey" json:"id"`
+		RuleID    string         `json:"ruleId"`
+		Action    denyRuleAction `json:"action"`
+		Actor     string         `json:"actor,omitempty"`
+		Detail    string         `json:"detail,omitempty"`
+		CreatedAt time.Time      `json:"createdAt"`
+	}
+
+	denyRuleAction string
+
+	createDenyRuleReq struct {
+		Issuer string `json:"issuer"`
+		Prefix string `json:"prefix"`
+		// An address or network, e.g. 192.0.2.0/24
+		CIDR   string `json:"cidr"`
+		Reason string `json:"reason"`
+		// How long the rule stays in effect, e.g. "2h"; defaults to Director.DenyRuleDefaultLifetime
+		Lifetime string `json:"lifetime"`
+	}
+)
+
+const (
+	denyRuleCreated denyRuleAction = "created"
+	denyRuleRemoved denyRuleAction = "removed"
+	denyRuleExpired denyRuleAction = "expired"
+
+	// The number of audit records kept when the director has no database
+	maxDenyRuleAudits = 1000
+)
+
+var (
+	denyRules      = map[string]*DenyRule{}
+	denyRulesMutex sync.RWMutex
+	// The number of rules in denyRules, so redirects skip the lock when there are none
+	denyRuleCount atomic.Int32
+
+	// The audit records, when they aren't stored in the director database
+	denyRuleAudits      []DenyRuleAudit
+	denyRuleAuditSeq    uint
+	denyRuleAuditsMutex sync.Mutex
+)
+
+// Check the rule's fields, normalizing them and parsing its network
+func (rule *DenyRule) validate() error {
+	if rule.Issuer == "" && rule.Prefix == "" && rule.CIDR == "" {
+		return errors.New("a deny rule must set at least one of issuer, prefix, or cidr")
+	}
+	if rule.Issuer != "" {
+		issuerUrl, err := url.Parse(rule.Issuer)
+		if err != nil || issuerUrl.Scheme == "" || issuerUrl.Host == "" {
+			return errors.Errorf("invalid issuer %q; expected a URL", rule.Issuer)
+		}
+		rule.Issuer = strings.TrimSuffix(rule.Issuer, "/")
+	}
+	if rule.Prefix != "" {
+		if !strings.HasPrefix(rule.Prefix, "/") {
+			return errors.Errorf("invalid prefix %q; expected an absolute path", rule.Prefix)
+		}
+		rule.Prefix = path.Clean(rule.Prefix)
+	}
+	if rule.CIDR != "" {
+		network, err := netip.ParsePrefix(rule.CIDR)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(rule.CIDR)
+			if addrErr != nil {
+				return errors.Errorf("invalid cidr %q; expected an IP address or network", rule.CIDR)
+			}
+			network = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		rule.network = network.Masked()
+		rule.CIDR = rule.network.String()
+	}
+	if strings.TrimSpace(rule.Reason) == "" {
+		return errors.New("a deny rule must give a reason")
+	}
+	return nil
+}
+
+// Whether the rule denies a request for reqPath from clientIP; issuer returns the
+// issuer of the request's token and is only called if the rule needs it
+func (rule *DenyRule) matches(reqPath string, clientIP netip.Addr, issuer func() string) bool {
+	if rule.Prefix != "" && rule.Prefix != "/" && reqPath != rule.Prefix && !strings.HasPrefix(reqPath, rule.Prefix+"/") {
+		return false
+	}
+	if rule.CIDR != "" && (!clientIP.IsValid() || !rule.network.Contains(clientIP.Unmap())) {
+		return false
+	}
+	if rule.Issuer != "" && issuer() != rule.Issuer {
+		return false
+	}
+	return true
+}
+
+// The issuer of a token, without verifying the token; the director only needs
+// it to match deny rules, and the server the client is redirected to verifies it
+func tokenIssuer(token string) string {
+	if token == "" {
+		return ""
+	}
+	tok, err := jwt.Parse([]byte(token), jwt.WithVerify(false), jwt.WithValidate(false))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(tok.Issuer(), "/")
+}
+
+// Find an unexpired deny rule matching the request
+func matchDenyRule(reqPath string, clientIP netip.Addr, token string, now time.Time) *DenyRule {
+	if denyRuleCount.Load() == 0 {
+		return nil
+	}
+	issuer := ""
+	parsedIssuer := false
+	getIssuer := func() string {
+		if !parsedIssuer {
+			issuer = tokenIssuer(token)
+			parsedIssuer = true
+		}
+		return issuer
+	}
+
+	denyRulesMutex.RLock()
+	defer denyRulesMutex.RUnlock()
+	for _, rule := range denyRules {
+		if now.Before(rule.ExpiresAt) && rule.matches(reqPath, clientIP, getIssuer) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Refuse the redirect if a deny rule matches it, returning true if the request was denied
+func denyRedirect(ginCtx *gin.Context, reqPath string, clientIP netip.Addr, reqParams url.Values) bool {
+	_, span := metrics.StartSpan(ginCtx.Request.Context(), "director.denyRedirect")
+	defer span.End()
+	rule := matchDenyRule(reqPath, clientIP, reqParams.Get("authz"), time.Now())
+	if rule == nil {
+		return false
+	}
+	span.SetAttributes(attribute.String("pelican.deny_rule", rule.ID))
+	metrics.PelicanDirectorDeniedRedirectsTotal.WithLabelValues(rule.ID).Inc()
+	log.Infof("Deny rule %s refused a redirect for %s from %s", rule.ID, reqPath, clientIP.String())
+	ginCtx.JSON(http.StatusForbidden, server_structs.SimpleApiResp{
+		Status: server_structs.RespFailed,
+		Msg:    fmt.Sprintf("The request was denied by the federation's administrators (rule %s)", rule.ID),
+	})
+	return true
+}
+
+// Record an action on a deny rule in the director database or, without one, in memory
+func auditDenyRule(rule *DenyRule, action denyRuleAction, actor string, now time.Time) {
+	detail := fmt.Sprintf("issuer=%q prefix=%q cidr=%q expires=%s reason=%q", rule.Issuer, rule.Prefix, rule.CIDR, rule.ExpiresAt.UTC().Format(time.RFC3339), rule.Reason)
+	if actor != "" {
+		log.Warningf("Deny rule %s %s by %s: %s", rule.ID, action, actor, detail)
+	} else {
+		log.Warningf("Deny rule %s %s: %s", rule.ID, action, detail)
+	}
+	record := DenyRuleAudit{RuleID: rule.ID, Action: action, Actor: actor, Detail: detail, CreatedAt: now}
+	if db != nil {
+		if err := db.Create(&record).Error; err != nil {
+			log.Errorf("Failed to record the audit entry for deny rule %s in the director database: %v", rule.ID, err)
+		}
+		return
+	}
+	denyRuleAuditsMutex.Lock()
+	defer denyRuleAuditsMutex.Unlock()
+	denyRuleAuditSeq++
+	record.ID = denyRuleAuditSeq
+	denyRuleAudits = append(denyRuleAudits, record)
+	if len(denyRuleAudits) > maxDenyRuleAudits {
+		denyRuleAudits = denyRuleAudits[len(denyRuleAudits)-maxDenyRuleAudits:]
+	}
+}
+
+// The most recent audit records, newest first
+func getDenyRuleAudits(limit int) ([]DenyRuleAudit, error) {
+	if db != nil {
+		records := []DenyRuleAudit{}
+		if err := db.Order("id desc").Limit(limit).Find(&records).Error; err != nil {
+			return nil, errors.Wrap(err, "failed to read the deny rule audit records")
+		}
+		return records, nil
+	}
+	denyRuleAuditsMutex.Lock()
+	defer denyRuleAuditsMutex.Unlock()
+	records := make([]DenyRuleAudit, 0, min(limit, len(denyRuleAudits)))
+	for idx := len(denyRuleAudits) - 1; idx >= 0 && len(records) < limit; idx-- {
+		records = append(records, denyRuleAudits[idx])
+	}
+	return records, nil
+}
+
+// Put a rule into effect, persisting it so it survives a director restart
+func addDenyRule(rule *DenyRule, actor string, now time.Time) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+	if rule.ID == "" {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return errors.Wrap(err, "unable to create an ID for the deny rule")
+		}
+		rule.ID = id.String()
+	}
+	rule.CreatedBy = actor
+	rule.CreatedAt = now
+	if db != nil {
+		if err := db.Create(rule).Error; err != nil {
+			return errors.Wrap(err, "failed to save the deny rule in the director database")
+		}
+	}
+	denyRulesMutex.Lock()
+	denyRules[rule.ID] = rule
+	denyRuleCount.Store(int32(len(denyRules)))
+	denyRulesMutex.Unlock()
+	auditDenyRule(rule, denyRuleCreated, actor, now)
+	return nil
+}
+
+// Take a rule out of effect; returns false if there's no such rule
+func removeDenyRule(id string, action denyRuleAction, actor string, now time.Time) (bool, error) {
+	denyRulesMutex.Lock()
+	rule, ok := denyRules[id]
+	if ok {
+		delete(denyRules, id)
+		denyRuleCount.Store(int32(len(denyRules)))
+	}
+	denyRulesMutex.Unlock()
+	if !ok {
+		return false, nil
+	}
+	auditDenyRule(rule, action, actor, now)
+	if db != nil {
+		if err := db.Delete(&DenyRule{}, "id = ?", id).Error; err != nil {
+			return true, errors.Wrapf(err, "failed to delete deny rule %s from the director database", id)
+		}
+	}
+	return true, nil
+}
+
+// Remove the rules that have expired
+func expireDenyRules(now time.Time) {
+	denyRulesMutex.RLock()
+	expired := []string{}
+	for id, rule := range denyRules {
+		if !now.Before(rule.ExpiresAt) {
+			expired = append(expired, id)
+		}
+	}
+	denyRulesMutex.RUnlock()
+	for _, id := range expired {
+		if _, err := removeDenyRule(id, denyRuleExpired, "", now); err != nil {
+			log.Errorln("Failed to expire deny rule:", err)
+		}
+	}
+}
+
+// Load the deny rules saved in the director database, so rules pushed during an
+// incident stay in effect across director restarts
+func ConfigDenyRules() error {
+	if db == nil {
+		return nil
+	}
+	rules := []*DenyRule{}
+	if err := db.Find(&rules).Error; err != nil {
+		return errors.Wrap(err, "failed to load the deny rules from the director database")
+	}
+	denyRulesMutex.Lock()
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			log.Errorf("Ignoring invalid deny rule %s from the director database: %v", rule.ID, err)
+			continue
+		}
+		denyRules[rule.ID] = rule
+	}
+	denyRuleCount.Store(int32(len(denyRules)))
+	denyRulesMutex.Unlock()
+	if len(rules) > 0 {
+		log.Infof("Loaded %d deny rules from the director database", len(rules))
+	}
+	expireDenyRules(time.Now())
+	return nil
+}
+
+// Periodically remove the deny rules that have expired; expired rules never
+// match, so this only keeps the rule list and audit trail current
+func LaunchDenyRuleExpiry(ctx context.Context, egrp *errgroup.Group) {
+	egrp.Go(func() error {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case now := <-ticker.C:
+				expireDenyRules(now)
+			}
+		}
+	})
+}
+
+func listDenyRulesHandler(ctx *gin.Context) {
+	now := time.Now()
+	denyRulesMutex.RLock()
+	rules := make([]DenyRule, 0, len(denyRules))
+	for _, rule := range denyRules {
+		if now.Before(rule.ExpiresAt) {
+			rules = append(rules, *rule)
+		}
+	}
+	denyRulesMutex.RUnlock()
+	sort.Slice(rules, func(i, j int) bool { return rules[i].CreatedAt.Before(rules[j].CreatedAt) })
+	ctx.JSON(http.StatusOK, rules)
+}
+
+func createDenyRuleHandler(ctx *gin.Context) {
+	req := createDenyRuleReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid deny rule: " + err.Error(),
+		})
+		return
+	}
+	lifetime := param.Director_DenyRuleDefaultLifetime.GetDuration()
+	if req.Lifetime != "" {
+		var err error
+		if lifetime, err = time.ParseDuration(req.Lifetime); err != nil || lifetime <= 0 {
+			ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    fmt.Sprintf("Invalid deny rule lifetime %q", req.Lifetime),
+			})
+			return
+		}
+	}
+	if maxLifetime := param.Director_DenyRuleMaxLifetime.GetDuration(); maxLifetime > 0 && lifetime > maxLifetime {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("The deny rule lifetime %s exceeds the maximum of %s (Director.DenyRuleMaxLifetime)", lifetime, maxLifetime),
+		})
+		return
+	}
+
+	now := time.Now()
+	rule := &DenyRule{
+		Issuer:    req.Issuer,
+		Prefix:    req.Prefix,
+		CIDR:      req.CIDR,
+		Reason:    req.Reason,
+		ExpiresAt: now.Add(lifetime),
+	}
+	if err := rule.validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid deny rule: " + err.Error(),
+		})
+		return
+	}
+	if err := addDenyRule(rule, ctx.GetString("User"), now); err != nil {
+		log.Errorln(err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to create the deny rule: " + err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, rule)
+}
+
+func deleteDenyRuleHandler(ctx *gin.Context) {
+	id := ctx.Param("id")
+	found, err := removeDenyRule(id, denyRuleRemoved, ctx.GetString("User"), time.Now())
+	if !found {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("No deny rule with ID %s", id),
+		})
+		return
+	}
+	if err != nil {
+		// The rule is out of effect, but it will be loaded again if the director restarts
+		log.Errorln(err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "The deny rule was removed but could not be deleted from the director database",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK, Msg: "Deny rule removed"})
+}
+
+func listDenyRuleAuditsHandler(ctx *gin.Context) {
+	records, err := getDenyRuleAudits(maxDenyRuleAudits)
+	if err != nil {
+		log.Errorln(err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to read the deny rule audit records",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, records)
+}
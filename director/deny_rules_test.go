@@ -0,0 +1,203 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func resetDenyRules() {
+	denyRulesMutex.Lock()
+	denyRules = map[string]*DenyRule{}
+	denyRuleCount.Store(0)
+	denyRulesMutex.Unlock()
+	denyRuleAuditsMutex.Lock()
+	denyRuleAudits = nil
+	denyRuleAuditsMutex.Unlock()
+}
+
+func TestDenyRuleValidate(t *testing.T) {
+	rule := DenyRule{Prefix: "/foo/bar/", CIDR: "192.0.2.7/24", Issuer: "https://issuer.example.com/", Reason: "incident"}
+	require.NoError(t, rule.validate())
+	assert.Equal(t, "/foo/bar", rule.Prefix)
+	assert.Equal(t, "192.0.2.0/24", rule.CIDR)
+	assert.Equal(t, "https://issuer.example.com", rule.Issuer)
+
+	rule = DenyRule{CIDR: "2001:db8::1", Reason: "incident"}
+	require.NoError(t, rule.validate())
+	assert.Equal(t, "2001:db8::1/128", rule.CIDR)
+
+	assert.ErrorContains(t, (&DenyRule{Reason: "incident"}).validate(), "at least one of")
+	assert.ErrorContains(t, (&DenyRule{Prefix: "/foo"}).validate(), "reason")
+	assert.ErrorContains(t, (&DenyRule{Prefix: "foo", Reason: "incident"}).validate(), "absolute path")
+	assert.ErrorContains(t, (&DenyRule{CIDR: "not-a-network", Reason: "incident"}).validate(), "invalid cidr")
+	assert.ErrorContains(t, (&DenyRule{Issuer: "issuer", Reason: "incident"}).validate(), "invalid issuer")
+}
+
+func TestMatchDenyRule(t *testing.T) {
+	resetDenyRules()
+	t.Cleanup(func() { resetDenyRules() })
+	db = nil
+
+	now := time.Now()
+	clientIP := netip.MustParseAddr("192.0.2.10")
+	assert.Nil(t, matchDenyRule("/foo/bar", clientIP, "", now))
+
+	tok, err := jwt.NewBuilder().Issuer("https://issuer.example.com").Build()
+	require.NoError(t, err)
+	signed, err := jwt.Sign(tok, jwt.WithInsecureNoSignature())
+	require.NoError(t, err)
+	token := string(signed)
+
+	prefixRule := &DenyRule{Prefix: "/foo", Reason: "compromised namespace", ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, addDenyRule(prefixRule, "admin", now))
+	assert.Equal(t, prefixRule, matchDenyRule("/foo/bar", clientIP, "", now))
+	assert.Equal(t, prefixRule, matchDenyRule("/foo", clientIP, "", now))
+	assert.Nil(t, matchDenyRule("/foobar", clientIP, "", now))
+
+	// Every field the rule sets must match
+	issuerRule := &DenyRule{Issuer: "https://issuer.example.com/", CIDR: "192.0.2.0/24", Reason: "stolen tokens", ExpiresAt: now.Add(time.Minute)}
+	require.NoError(t, addDenyRule(issuerRule, "admin", now))
+	assert.Equal(t, issuerRule, matchDenyRule("/bar", clientIP, token, now))
+	assert.Nil(t, matchDenyRule("/bar", clientIP, "", now))
+	assert.Nil(t, matchDenyRule("/bar", netip.MustParseAddr("198.51.100.1"), token, now))
+	assert.Equal(t, issuerRule, matchDenyRule("/bar", netip.MustParseAddr("::ffff:192.0.2.10"), token, now))
+
+	// Expired rules never match and are removed with an audit record
+	later := now.Add(2 * time.Minute)
+	assert.Nil(t, matchDenyRule("/bar", clientIP, token, later))
+	expireDenyRules(later)
+	assert.Equal(t, int32(1), denyRuleCount.Load())
+
+	found, err := removeDenyRule(prefixRule.ID, denyRuleRemoved, "admin", later)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Nil(t, matchDenyRule("/foo/bar", clientIP, "", later))
+
+	audits, err := getDenyRuleAudits(10)
+	require.NoError(t, err)
+	require.Len(t, audits, 4)
+	assert.Equal(t, denyRuleRemoved, audits[0].Action)
+	assert.Equal(t, "admin", audits[0].Actor)
+	assert.Equal(t, denyRuleExpired, audits[1].Action)
+	assert.Equal(t, issuerRule.ID, audits[1].RuleID)
+	assert.Equal(t, denyRuleCreated, audits[3].Action)
+}
+
+func TestDenyRulesPersist(t *testing.T) {
+	server_utils.ResetTestState()
+	resetDenyRules()
+	SetupMockDirectorDB(t)
+	require.NoError(t, db.AutoMigrate(&DenyRule{}, &DenyRuleAudit{}))
+	t.Cleanup(func() {
+		TeardownMockDirectorDB(t)
+		db = nil
+		resetDenyRules()
+	})
+
+	now := time.Now()
+	rule := &DenyRule{CIDR: "198.51.100.0/24", Reason: "scanning", ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, addDenyRule(rule, "admin", now))
+	expired := &DenyRule{Prefix: "/old", Reason: "old incident", ExpiresAt: now.Add(time.Millisecond)}
+	require.NoError(t, addDenyRule(expired, "admin", now))
+	time.Sleep(10 * time.Millisecond)
+
+	// A restarted director picks the rules back up, dropping the expired one
+	resetDenyRules()
+	require.NoError(t, ConfigDenyRules())
+	assert.Equal(t, int32(1), denyRuleCount.Load())
+	matched := matchDenyRule("/foo", netip.MustParseAddr("198.51.100.20"), "", time.Now())
+	require.NotNil(t, matched)
+	assert.Equal(t, rule.ID, matched.ID)
+
+	audits, err := getDenyRuleAudits(10)
+	require.NoError(t, err)
+	require.Len(t, audits, 3)
+	assert.Equal(t, denyRuleExpired, audits[0].Action)
+	assert.Equal(t, expired.ID, audits[0].RuleID)
+}
+
+func TestDenyRuleHandlers(t *testing.T) {
+	server_utils.ResetTestState()
+	resetDenyRules()
+	db = nil
+	viper.Set("Director.DenyRuleDefaultLifetime", 24*time.Hour)
+	viper.Set("Director.DenyRuleMaxLifetime", 168*time.Hour)
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		resetDenyRules()
+	})
+
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) { ctx.Set("User", "admin") })
+	router.GET("/deny_rules", listDenyRulesHandler)
+	router.POST("/deny_rules", createDenyRuleHandler)
+	router.DELETE("/deny_rules/:id", deleteDenyRuleHandler)
+	router.GET("/object/*any", func(ctx *gin.Context) {
+		if !denyRedirect(ctx, ctx.Param("any"), netip.MustParseAddr("192.0.2.1"), getRequestParameters(ctx.Request)) {
+			ctx.Status(http.StatusTemporaryRedirect)
+		}
+	})
+	do := func(method, target string, body any) *httptest.ResponseRecorder {
+		var reqBody bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+		}
+		req := httptest.NewRequest(method, target, &reqBody)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/deny_rules", createDenyRuleReq{Prefix: "/foo"}).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/deny_rules", createDenyRuleReq{Prefix: "/foo", Reason: "incident", Lifetime: "1000h"}).Code)
+
+	w := do(http.MethodPost, "/deny_rules", createDenyRuleReq{Prefix: "/foo", Reason: "incident", Lifetime: "1h"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	created := DenyRule{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "admin", created.CreatedBy)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), created.ExpiresAt, time.Minute)
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/object/foo/bar", nil).Code)
+	assert.Equal(t, http.StatusTemporaryRedirect, do(http.MethodGet, "/object/other", nil).Code)
+
+	rules := []DenyRule{}
+	require.NoError(t, json.Unmarshal(do(http.MethodGet, "/deny_rules", nil).Body.Bytes(), &rules))
+	require.Len(t, rules, 1)
+	assert.Equal(t, created.ID, rules[0].ID)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/deny_rules/"+created.ID, nil).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/deny_rules/"+created.ID, nil).Code)
+	assert.Equal(t, http.StatusTemporaryRedirect, do(http.MethodGet, "/object/foo/bar", nil).Code)
+}
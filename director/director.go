@@ -22,11 +22,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"path"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -35,6 +37,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/pelicanplatform/pelican/config"
@@ -65,6 +68,9 @@ type (
 		ErrGrpContext context.Context
 		Cancel        context.CancelFunc
 		Status        HealthTestStatus
+		// When the last test cycle finished, and why it failed if it did
+		LastTest  time.Time
+		LastError string
 	}
 	// Utility struct to keep track of the `stat` call the director made to the origin/cache servers
 	serverStatUtil struct {
@@ -133,6 +139,27 @@ func getRedirectURL(reqPath string, ad server_structs.ServerAd, requiresAuth boo
 	return
 }
 
+// Swap the redirect URL of a public object for the cache's plain HTTP endpoint if
+// the client is on one of the networks the cache serves over plain HTTP
+func getLocalHttpRedirectURL(redirectURL url.URL, ad server_structs.ServerAd, clientIP netip.Addr) url.URL {
+	if ad.LocalHttpURL.Host == "" || !clientIP.IsValid() {
+		return redirectURL
+	}
+	clientIP = clientIP.Unmap()
+	for _, network := range ad.LocalHttpNetworks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			continue
+		}
+		if prefix.Contains(clientIP) {
+			redirectURL.Scheme = "http"
+			redirectURL.Host = ad.LocalHttpURL.Host
+			return redirectURL
+		}
+	}
+	return redirectURL
+}
+
 // Calculate the depth attribute of Link header given the path to the file
 // and the prefix of the namespace that can serve the file
 //
@@ -332,6 +359,8 @@ func checkRedirectQuery(query url.Values) error {
 }
 
 func redirectToCache(ginCtx *gin.Context) {
+	span := startRedirectSpan(ginCtx, "director.redirectToCache")
+	defer func() { metrics.EndRequestSpan(span, ginCtx.Writer.Status()) }()
 	reqVer, service, _ := extractVersionAndService(ginCtx)
 	// Flag to indicate if the request was redirected to a cache
 	// For metric collection purposes
@@ -366,9 +395,13 @@ func redirectToCache(ginCtx *gin.Context) {
 
 	reqPath := path.Clean("/" + ginCtx.Request.URL.Path)
 	reqPath = strings.TrimPrefix(reqPath, "/api/v1.0/director/object")
+	reqPath = applyNamespaceAlias(ginCtx, reqPath)
 	ipAddr := utils.ClientIPAddr(ginCtx)
 
 	reqParams := getRequestParameters(ginCtx.Request)
+	if denyRedirect(ginCtx, reqPath, ipAddr, reqParams) {
+		return
+	}
 
 	disableStat := !param.Director_CheckCachePresence.GetBool()
 
@@ -376,7 +409,7 @@ func redirectToCache(ginCtx *gin.Context) {
 	// If either disableStat or skipstat is set, then skip the stat query
 	skipStat := ginCtx.Request.URL.Query().Has("skipstat") || disableStat
 
-	namespaceAd, originAds, cacheAds := getAdsForPath(reqPath)
+	namespaceAd, originAds, cacheAds := getAdsForPathTraced(ginCtx.Request.Context(), reqPath)
 	// if GetAdsForPath doesn't find any ads because the prefix doesn't exist, we should
 	// report the lack of path first -- this is most important for the user because it tells them
 	// they're trying to get an object that simply doesn't exist
@@ -387,6 +420,10 @@ func redirectToCache(ginCtx *gin.Context) {
 		})
 		return
 	}
+	defer func() { recordRedirectSLO(namespaceAd.Path, ginCtx.Writer.Status()) }()
+	if redirectToArchiveMember(ginCtx, reqPath, ipAddr, namespaceAd, originAds, reqParams) {
+		return
+	}
 	// if err != nil, depth == 0, which is the default value for depth
 	// so we can use it as the value for the header even with err
 	depth, err := getLinkDepth(reqPath, namespaceAd.Path)
@@ -421,7 +458,7 @@ func redirectToCache(ginCtx *gin.Context) {
 		// TODO: come back and re-evaluate if we need this many responses and potential origin/cache
 		// server performance issue out of this
 		maxRes := len(cacheAds) + len(originAds)
-		qr := q.Query(context.Background(), reqPath, st, 1, maxRes,
+		qr := q.Query(context.WithoutCancel(ginCtx.Request.Context()), reqPath, st, 1, maxRes,
 			withOriginAds(originAds), withCacheAds(cacheAds), WithToken(reqParams.Get("authz")))
 		log.Debugf("Stat result for %s: %s", reqPath, qr.String())
 
@@ -465,6 +502,9 @@ func redirectToCache(ginCtx *gin.Context) {
 				}
 			}
 		}
+		// Caches whose stat timed out, or was cut off once enough servers had the object,
+		// may still be known to have it from their advertised content
+		applyContentHints(reqPath, cacheAds, cachesAvailabilityMap)
 	}
 
 	// If the namespace prefix DOES exist, then it makes sense to say we couldn't find a valid cache.
@@ -491,9 +531,10 @@ func redirectToCache(ginCtx *gin.Context) {
 		redirectedToCache = false
 	}
 
-	ctx := context.Background()
+	ctx := ginCtx.Request.Context()
 	project := utils.ExtractProjectFromUserAgent(ginCtx.Request.Header.Values("User-Agent"))
 	ctx = context.WithValue(ctx, ProjectContextKey{}, project)
+	ctx = withSortExperiment(ctx, ipAddr)
 	cacheAds, err = sortServerAds(ctx, ipAddr, cacheAds, cachesAvailabilityMap)
 	if err != nil {
 		log.Error("Error determining server ordering for cacheAds: ", err)
@@ -505,29 +546,30 @@ func redirectToCache(ginCtx *gin.Context) {
 	}
 
 	// "adaptive" sorting method takes care of availability factor
-	if param.Director_CacheSortMethod.GetString() == string(server_structs.AdaptiveType) {
+	if getSortMethod(ctx) == server_structs.AdaptiveType {
 		// Re-sort by availability, where caches having the object have higher priority
 		sortServerAdsByAvailability(cacheAds, cachesAvailabilityMap)
+		sortLastResortServersLast(cacheAds)
 	}
 
 	redirectURL := getRedirectURL(reqPath, cacheAds[0], !namespaceAd.Caps.PublicReads)
+	if namespaceAd.Caps.PublicReads {
+		redirectURL = getLocalHttpRedirectURL(redirectURL, cacheAds[0], ipAddr)
+	}
 
-	linkHeader := ""
-	first := true
 	cachesToSend := serverResLimit
 	if numCAds := len(cacheAds); numCAds < serverResLimit {
 		cachesToSend = numCAds
 	}
+	servers := make([]server_structs.RedirectServer, 0, cachesToSend)
 	for idx, ad := range cacheAds[:cachesToSend] {
-		if first {
-			first = false
-		} else {
-			linkHeader += ", "
-		}
 		redirectURL := getRedirectURL(reqPath, ad, !namespaceAd.Caps.PublicReads)
-		linkHeader += fmt.Sprintf(`<%s>; rel="duplicate"; pri=%d; depth=%d`, redirectURL.String(), idx+1, depth)
+		if namespaceAd.Caps.PublicReads {
+			redirectURL = getLocalHttpRedirectURL(redirectURL, ad, ipAddr)
+		}
+		servers = append(servers, server_structs.RedirectServer{URL: redirectURL.String(), Priority: idx + 1, Depth: depth})
 	}
-	ginCtx.Writer.Header()["Link"] = []string{linkHeader}
+	setLinkHeader(ginCtx, servers)
 
 	// Generate headers needed for token generation/verification
 	generateXAuthHeader(ginCtx, namespaceAd)
@@ -550,10 +592,81 @@ func redirectToCache(ginCtx *gin.Context) {
 	// duplicate link metadata above.  This is purposeful: the Link header might get too long if we repeat
 	// the token 20 times for 20 caches.  This means a "normal HTTP client" will correctly redirect but
 	// anything parsing the `Link` header for metalinks will need logic for redirecting appropriately.
-	ginCtx.Redirect(307, getFinalRedirectURL(redirectURL, reqParams))
+	recordSortExperimentRedirect(ctx, ipAddr, reqPath)
+	issueRedirect(ginCtx, namespaceAd.Path, cacheAds[0], getFinalRedirectURL(redirectURL, reqParams))
+}
+
+// Handle the servers only reachable through the connection broker.  Caches can reach them
+// through the broker, but prefer the servers they can connect to directly; other clients
+// can't use the broker, so they don't get them at all.  The order of the servers is kept
+// otherwise.
+func filterBrokerOnlyAds(ads []server_structs.ServerAd, fromCache bool) []server_structs.ServerAd {
+	direct := make([]server_structs.ServerAd, 0, len(ads))
+	brokerOnly := []server_structs.ServerAd{}
+	for _, ad := range ads {
+		if ad.BrokerOnly {
+			brokerOnly = append(brokerOnly, ad)
+		} else {
+			direct = append(direct, ad)
+		}
+	}
+	if !fromCache {
+		return direct
+	}
+	return append(direct, brokerOnly...)
+}
+
+// Redirect a request for a member of an archive, e.g., /ns/data.zip?member=images/1.png,
+// to the web API of an origin that can extract it, rather than to a server that would send
+// the whole archive.  Returns whether the request was handled.
+func redirectToArchiveMember(ginCtx *gin.Context, reqPath string, ipAddr netip.Addr, namespaceAd server_structs.NamespaceAdV2, originAds []server_structs.ServerAd, reqParams url.Values) bool {
+	member := ginCtx.Query("member")
+	if member == "" {
+		return false
+	}
+	if ginCtx.Request.Method != http.MethodGet && ginCtx.Request.Method != http.MethodHead {
+		ginCtx.JSON(http.StatusMethodNotAllowed, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Members of archives can only be read",
+		})
+		return true
+	}
+	candidates := []server_structs.ServerAd{}
+	for _, ad := range originAds {
+		if ad.ArchiveMembers && ad.WebURL.String() != "" && ad.BrokerURL.String() == "" && !ad.BrokerOnly {
+			candidates = append(candidates, ad)
+		}
+	}
+	if len(candidates) == 0 {
+		ginCtx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "No origins on specified endpoint can serve the members of archives",
+		})
+		return true
+	}
+	candidates, err := sortServerAds(ginCtx.Request.Context(), ipAddr, candidates, nil)
+	if err != nil {
+		log.Errorf("Failed to sort the origins serving the members of archives: %v", err)
+		ginCtx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to determine origin ordering",
+		})
+		return true
+	}
+
+	generateXAuthHeader(ginCtx, namespaceAd)
+	generateXTokenGenHeader(ginCtx, namespaceAd)
+	ad := candidates[0]
+	redirectURL := ad.WebURL
+	redirectURL.Path = "/api/v1.0/origin/archive" + reqPath
+	redirectURL.RawQuery = url.Values{"member": []string{member}}.Encode()
+	issueRedirect(ginCtx, namespaceAd.Path, ad, getFinalRedirectURL(redirectURL, reqParams))
+	return true
 }
 
 func redirectToOrigin(ginCtx *gin.Context) {
+	span := startRedirectSpan(ginCtx, "director.redirectToOrigin")
+	defer func() { metrics.EndRequestSpan(span, ginCtx.Writer.Status()) }()
 	reqVer, service, _ := extractVersionAndService(ginCtx)
 	defer collectDirectorRedirectionMetric(ginCtx, "origin")
 	err := versionCompatCheck(reqVer, service)
@@ -585,10 +698,14 @@ func redirectToOrigin(ginCtx *gin.Context) {
 		ginCtx.Redirect(http.StatusTemporaryRedirect, param.Server_ExternalWebUrl.GetString()+"/api/v1.0/director/healthTest"+reqPath)
 		return
 	}
+	reqPath = applyNamespaceAlias(ginCtx, reqPath)
 
 	ipAddr := utils.ClientIPAddr(ginCtx)
 
 	reqParams := getRequestParameters(ginCtx.Request)
+	if denyRedirect(ginCtx, reqPath, ipAddr, reqParams) {
+		return
+	}
 
 	// Skip the stat check for object availability if either disableStat or skipstat is set
 	skipStat := reqParams.Has(pelican_url.QuerySkipStat) || !param.Director_CheckOriginPresence.GetBool()
@@ -597,7 +714,7 @@ func redirectToOrigin(ginCtx *gin.Context) {
 	// AND prefercached query parameter is set
 	includeCaches := param.Director_CachesPullFromCaches.GetBool() && reqParams.Has(pelican_url.QueryPreferCached)
 
-	namespaceAd, originAds, cacheAds := getAdsForPath(reqPath)
+	namespaceAd, originAds, cacheAds := getAdsForPathTraced(ginCtx.Request.Context(), reqPath)
 	// if GetAdsForPath doesn't find any ads because the prefix doesn't exist, we should
 	// report the lack of path first -- this is most important for the user because it tells them
 	// they're trying to get an object that simply doesn't exist
@@ -608,6 +725,10 @@ func redirectToOrigin(ginCtx *gin.Context) {
 		})
 		return
 	}
+	defer func() { recordRedirectSLO(namespaceAd.Path, ginCtx.Writer.Status()) }()
+	if redirectToArchiveMember(ginCtx, reqPath, ipAddr, namespaceAd, originAds, reqParams) {
+		return
+	}
 
 	// If the namespace requires a token yet there's no token available, skip the stat.
 	if (!namespaceAd.Caps.PublicReads && reqParams.Get("authz") == "") || (param.Director_AssumePresenceAtSingleOrigin.GetBool() && len(originAds) == 1) {
@@ -623,7 +744,7 @@ func redirectToOrigin(ginCtx *gin.Context) {
 	} else {
 		// Query Origins and check if the object exists
 		q = NewObjectStat()
-		qr := q.Query(context.Background(), reqPath, server_structs.OriginType, 1, 3,
+		qr := q.Query(context.WithoutCancel(ginCtx.Request.Context()), reqPath, server_structs.OriginType, 1, 3,
 			withOriginAds(originAds), WithToken(reqParams.Get("authz")), withAuth(!namespaceAd.Caps.PublicReads))
 		log.Debugf("Stat result for %s: %s", reqPath, qr.String())
 
@@ -675,7 +796,7 @@ func redirectToOrigin(ginCtx *gin.Context) {
 		if q == nil {
 			q = NewObjectStat()
 		}
-		qr := q.Query(context.Background(), reqPath, server_structs.CacheType, 1, 3,
+		qr := q.Query(context.WithoutCancel(ginCtx.Request.Context()), reqPath, server_structs.CacheType, 1, 3,
 			withCacheAds(cacheAds), WithToken(reqParams.Get("authz")))
 		log.Debugf("CachesPullFromCaches is enabled. Stat result for %s among caches: %s", reqPath, qr.String())
 
@@ -728,9 +849,10 @@ func redirectToOrigin(ginCtx *gin.Context) {
 		log.Errorf("Failed to get depth attribute for the redirecting request to %q, with best match namespace prefix %q", reqPath, namespaceAd.Path)
 	}
 
-	ctx := context.Background()
+	ctx := ginCtx.Request.Context()
 	project := utils.ExtractProjectFromUserAgent(ginCtx.Request.Header.Values("User-Agent"))
 	ctx = context.WithValue(ctx, ProjectContextKey{}, project)
+	ctx = withSortExperiment(ctx, ipAddr)
 
 	availableAds, err = sortServerAds(ctx, ipAddr, availableAds, nil)
 	if err != nil {
@@ -741,23 +863,28 @@ func redirectToOrigin(ginCtx *gin.Context) {
 		})
 		return
 	}
+	_, filterSpan := metrics.StartSpan(ctx, "director.filterBrokerOnlyAds", attribute.Int("pelican.servers", len(availableAds)))
+	availableAds = filterBrokerOnlyAds(availableAds, service == "cache")
+	filterSpan.SetAttributes(attribute.Int("pelican.servers.remaining", len(availableAds)))
+	filterSpan.End()
+	if len(availableAds) == 0 {
+		ginCtx.JSON(http.StatusServiceUnavailable, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "The origins hosting the object are only reachable through the connection broker, which only caches can use; access the object through a cache instead",
+		})
+		return
+	}
 
-	linkHeader := ""
-	first := true
 	serversToSend := serverResLimit
 	if numCAds := len(availableAds); numCAds < serverResLimit {
 		serversToSend = numCAds
 	}
+	servers := make([]server_structs.RedirectServer, 0, serversToSend)
 	for idx, ad := range availableAds[:serversToSend] {
-		if first {
-			first = false
-		} else {
-			linkHeader += ", "
-		}
 		redirectURL := getRedirectURL(reqPath, ad, !namespaceAd.Caps.PublicReads)
-		linkHeader += fmt.Sprintf(`<%s>; rel="duplicate"; pri=%d; depth=%d`, redirectURL.String(), idx+1, depth)
+		servers = append(servers, server_structs.RedirectServer{URL: redirectURL.String(), Priority: idx + 1, Depth: depth})
 	}
-	ginCtx.Writer.Header()["Link"] = []string{linkHeader}
+	setLinkHeader(ginCtx, servers)
 
 	var colUrl string
 	// If the namespace or the origin does not allow directory listings, then we should not advertise a collections-url.
@@ -782,7 +909,7 @@ func redirectToOrigin(ginCtx *gin.Context) {
 				if brokerUrl := availableAds[idx].BrokerURL; brokerUrl.String() != "" {
 					ginCtx.Header("X-Pelican-Broker", brokerUrl.String())
 				}
-				ginCtx.Redirect(http.StatusTemporaryRedirect, getFinalRedirectURL(redirectURL, reqParams))
+				issueRedirect(ginCtx, namespaceAd.Path, availableAds[idx], getFinalRedirectURL(redirectURL, reqParams))
 				return
 			}
 		}
@@ -804,7 +931,7 @@ func redirectToOrigin(ginCtx *gin.Context) {
 				if brokerUrl := availableAds[idx].BrokerURL; brokerUrl.String() != "" {
 					ginCtx.Header("X-Pelican-Broker", brokerUrl.String())
 				}
-				ginCtx.Redirect(http.StatusTemporaryRedirect, getFinalRedirectURL(redirectURL, reqParams))
+				issueRedirect(ginCtx, namespaceAd.Path, availableAds[idx], getFinalRedirectURL(redirectURL, reqParams))
 				return
 			}
 		}
@@ -827,7 +954,11 @@ func redirectToOrigin(ginCtx *gin.Context) {
 				if brokerUrl := availableAds[idx].BrokerURL; brokerUrl.String() != "" {
 					ginCtx.Header("X-Pelican-Broker", brokerUrl.String())
 				}
-				ginCtx.Redirect(http.StatusTemporaryRedirect, getFinalRedirectURL(redirectURL, reqParams))
+				// Large uploads may instead be sent in chunks through the origin's web API
+				if ginCtx.Request.Method == http.MethodPut && ad.ChunkedUploads && ad.WebURL.String() != "" && ad.BrokerURL.String() == "" {
+					ginCtx.Header(server_structs.ChunkedUploadHeader, ad.WebURL.String())
+				}
+				issueRedirect(ginCtx, namespaceAd.Path, availableAds[idx], getFinalRedirectURL(redirectURL, reqParams))
 				return
 			}
 		}
@@ -851,7 +982,8 @@ func redirectToOrigin(ginCtx *gin.Context) {
 
 		// See note in RedirectToCache as to why we only add the authz query parameter to this URL,
 		// not those in the `Link`.
-		ginCtx.Redirect(http.StatusTemporaryRedirect, getFinalRedirectURL(redirectURL, reqParams))
+		recordSortExperimentRedirect(ctx, ipAddr, reqPath)
+		issueRedirect(ginCtx, namespaceAd.Path, availableAds[0], getFinalRedirectURL(redirectURL, reqParams))
 	}
 }
 
@@ -979,24 +1111,19 @@ func registerServeAd(engineCtx context.Context, ctx *gin.Context, sType server_s
 		return
 	}
 
-	ad := server_structs.OriginAdvertiseV1{}
-	adV2 := server_structs.OriginAdvertiseV2{}
-	err = ctx.ShouldBindBodyWith(&ad, binding.JSON)
+	// Let the server know which versions of the advertisement we accept so it can
+	// send the newest one we both understand
+	ctx.Header(server_structs.AdVersionsHeader, server_structs.SupportedAdVersions())
+
+	adV3, err := bindServerAd(ctx, sType)
 	if err != nil {
-		// Failed binding to a V1 type, so should now check to see if it's a V2 type
-		adV2 = server_structs.OriginAdvertiseV2{}
-		err = ctx.ShouldBindBodyWith(&adV2, binding.JSON)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
-				Status: server_structs.RespFailed,
-				Msg:    fmt.Sprintf("Invalid %s registration", sType),
-			})
-			return
-		}
-	} else {
-		// If the OriginAdvertisement is a V1 type, convert to a V2 type
-		adV2 = server_structs.ConvertOriginAdV1ToV2(ad)
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+		return
 	}
+	adV2 := &adV3.OriginAdvertiseV2
 
 	// Set to ctx for metrics handler downstream
 	ctx.Set("serverName", adV2.Name)
@@ -1013,50 +1140,20 @@ func registerServeAd(engineCtx context.Context, ctx *gin.Context, sType server_s
 	namespacePaths = strings.TrimSpace(namespacePaths)
 	ctx.Set("namespacePaths", namespacePaths)
 
-	adUrl, err := url.Parse(adV2.DataURL)
+	sAd, err := serverAdFromAdvertisement(&adV3, sType, reqVer)
 	if err != nil {
-		log.Warningf("Failed to parse %s URL %v: %v\n", sType, adV2.DataURL, err)
-		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
-			Status: server_structs.RespFailed,
-			Msg:    fmt.Sprintf("Invalid %s registration. %s.URL %s is not a valid URL", sType, sType, adV2.DataURL), // Origin.URL / Cache.URL
-		})
-		return
-	}
-
-	adWebUrl, err := url.Parse(adV2.WebURL)
-	if err != nil && adV2.WebURL != "" { // We allow empty WebURL string for backward compatibility
-		log.Warningf("Failed to parse server Web URL %v: %v\n", adV2.WebURL, err)
+		log.Warningf("Rejected the %s advertisement of %s: %v", sType, adV2.Name, err)
 		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
 			Status: server_structs.RespFailed,
-			Msg:    fmt.Sprintf("Invalid %s registration. Server.ExternalWebUrl %s is not a valid URL", sType, adV2.WebURL),
+			Msg:    fmt.Sprintf("Invalid %s registration. %v", sType, err),
 		})
 		return
 	}
 
-	brokerUrl, err := url.Parse(adV2.BrokerURL)
-	if err != nil {
-		log.Warningf("Failed to parse broker URL %s: %s", adV2.BrokerURL, err)
-		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
-			Status: server_structs.RespFailed,
-			Msg:    fmt.Sprintf("Invalid %s registration. BrokerURL %s is not a valid URL", sType, adV2.BrokerURL),
-		})
-	}
-
 	// Verify server registration
 	token := strings.TrimPrefix(tokens[0], "Bearer ")
 
-	registryPrefix := adV2.RegistryPrefix
-	verifyServer := true
-	if registryPrefix == "" {
-		if sType == server_structs.OriginType {
-			// For origins < 7.9.0, they are not registered, and we skip the verification
-			verifyServer = false
-		} else {
-			// For caches <= 7.8.1, they don't have RegistryPrefix
-			// so we fall back to Name
-			registryPrefix = server_structs.GetCacheNS(adV2.Name)
-		}
-	}
+	registryPrefix, verifyServer := advertisementRegistryPrefix(sType, adV2)
 
 	approvalErrMsg := "You may find more information on " + param.Server_ExternalWebUrl.GetString()
 	// Prepare the admin approval error message
@@ -1073,7 +1170,7 @@ func registerServeAd(engineCtx context.Context, ctx *gin.Context, sType server_s
 		if err != nil {
 			if err == adminApprovalErr {
 				log.Warningf("Failed to verify token. %s %q was not approved", sType.String(), adV2.Name)
-				ctx.JSON(http.StatusForbidden, gin.H{"approval_error": true, "error": fmt.Sprintf("%s %q was not approved by an administrator. %s", sType.String(), ad.Name, approvalErrMsg)})
+				ctx.JSON(http.StatusForbidden, gin.H{"approval_error": true, "error": fmt.Sprintf("%s %q was not approved by an administrator. %s", sType.String(), adV2.Name, approvalErrMsg)})
 				return
 			} else {
 				log.Warningln("Failed to verify token:", err)
@@ -1123,6 +1220,97 @@ func registerServeAd(engineCtx context.Context, ctx *gin.Context, sType server_s
 				})
 				return
 			}
+			// The registry may require capabilities of the namespace's origins
+			caps := effectiveNamespaceCaps(adV2.Caps, namespace.Caps, sType)
+			if conflicts := namespacePolicyConflicts(getNamespacePolicy(namespace.Path), sAd, caps); len(conflicts) > 0 {
+				log.Warningf("Rejected the advertisement of %s %s, which conflicts with the registry policy of the namespace %s: %s",
+					sType, adV2.Name, namespace.Path, strings.Join(conflicts, "; "))
+				ctx.JSON(http.StatusForbidden, server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    fmt.Sprintf("The advertisement conflicts with the registry policy of the namespace %s: %s", namespace.Path, strings.Join(conflicts, "; ")),
+				})
+				return
+			}
+		}
+	}
+
+	if sAd.DisableDirectorTest && !adV2.DisableDirectorTest {
+		log.Warningf("%s server %s with storage type %s enabled director test. This is not supported.", sType, adV2.Name, string(sAd.StorageType))
+	}
+
+	if sType == server_structs.CacheType && verifyServer {
+		recordKnownCache(utils.ClientIPAddr(ctx))
+	}
+	recordAd(engineCtx, sAd, &adV2.Namespaces)
+	forwardAdToSubDirectors(engineCtx, ctx, sType)
+
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK, Msg: "Successful registration"})
+}
+
+// Bind the body of a request to the advertisement it carries, whichever version
+// of the advertisement the server sent.  Older advertisements are converted to
+// the newest version.
+func bindServerAd(ctx *gin.Context, sType server_structs.ServerType) (adV3 server_structs.OriginAdvertiseV3, err error) {
+	// V3 and later ads carry their version; older ones are told apart by their fields
+	adVersion := struct {
+		AdVersion int `json:"ad-version"`
+	}{}
+	if err = ctx.ShouldBindBodyWith(&adVersion, binding.JSON); err != nil {
+		return adV3, errors.Errorf("Invalid %s registration", sType)
+	}
+	if adVersion.AdVersion > server_structs.LatestAdVersion {
+		log.Debugf("Rejected a version %d %s advertisement; the newest supported version is %d", adVersion.AdVersion, sType, server_structs.LatestAdVersion)
+		return adV3, errors.Errorf("Unsupported %s advertisement version %d; the director accepts versions %s", sType, adVersion.AdVersion, server_structs.SupportedAdVersions())
+	}
+
+	ad := server_structs.OriginAdvertiseV1{}
+	if adVersion.AdVersion >= server_structs.AdVersionV3 {
+		err = ctx.ShouldBindBodyWith(&adV3, binding.JSON)
+	} else if err = ctx.ShouldBindBodyWith(&ad, binding.JSON); err != nil {
+		// Failed binding to a V1 type, so should now check to see if it's a V2 type
+		adV3.AdVersion = server_structs.AdVersionV2
+		err = ctx.ShouldBindBodyWith(&adV3.OriginAdvertiseV2, binding.JSON)
+	} else {
+		// If the OriginAdvertisement is a V1 type, convert to a V2 type
+		adV3.AdVersion = server_structs.AdVersionV1
+		adV3.OriginAdvertiseV2 = server_structs.ConvertOriginAdV1ToV2(ad)
+	}
+	if err != nil {
+		return adV3, errors.Errorf("Invalid %s registration", sType)
+	}
+	return adV3, nil
+}
+
+// Convert an advertisement into the server ad the director records, checking the
+// URLs it carries.  reqVer is the server version from the request's user agent,
+// used when the advertisement doesn't carry its own.
+func serverAdFromAdvertisement(adV3 *server_structs.OriginAdvertiseV3, sType server_structs.ServerType, reqVer *version.Version) (server_structs.ServerAd, error) {
+	adV2 := &adV3.OriginAdvertiseV2
+	adUrl, err := url.Parse(adV2.DataURL)
+	if err != nil {
+		return server_structs.ServerAd{}, errors.Errorf("%s.URL %s is not a valid URL", sType, adV2.DataURL) // Origin.URL / Cache.URL
+	}
+
+	adWebUrl, err := url.Parse(adV2.WebURL)
+	if err != nil && adV2.WebURL != "" { // We allow empty WebURL string for backward compatibility
+		return server_structs.ServerAd{}, errors.Errorf("Server.ExternalWebUrl %s is not a valid URL", adV2.WebURL)
+	}
+
+	brokerUrl, err := url.Parse(adV2.BrokerURL)
+	if err != nil {
+		return server_structs.ServerAd{}, errors.Errorf("BrokerURL %s is not a valid URL", adV2.BrokerURL)
+	}
+
+	localHttpUrl := &url.URL{}
+	if adV2.LocalHttpURL != "" {
+		localHttpUrl, err = url.Parse(adV2.LocalHttpURL)
+		if err != nil || localHttpUrl.Scheme != "http" || localHttpUrl.Host == "" {
+			return server_structs.ServerAd{}, errors.Errorf("Local HTTP URL %s is not a valid http URL", adV2.LocalHttpURL)
+		}
+		for _, network := range adV2.LocalHttpNetworks {
+			if _, err := netip.ParsePrefix(network); err != nil {
+				return server_structs.ServerAd{}, errors.Errorf("Local HTTP network %s is not a valid CIDR", network)
+			}
 		}
 	}
 
@@ -1132,45 +1320,79 @@ func registerServeAd(engineCtx context.Context, ctx *gin.Context, sType server_s
 		st = server_structs.OriginStoragePosix
 	}
 	// Disable director test if the server isn't POSIX
-	if st != server_structs.OriginStoragePosix && !adV2.DisableDirectorTest {
-		log.Warningf("%s server %s with storage type %s enabled director test. This is not supported.", sType, adV2.Name, string(st))
-		adV2.DisableDirectorTest = true
-	}
+	disableDirectorTest := adV2.DisableDirectorTest || st != server_structs.OriginStoragePosix
 
 	// if we didn't receive a version from the ad but we were able to extract the request version from the user agent,
 	// then we can fallback to the request version
 	// otherwise, we set the version to unknown because our sources of truth are not available
-	if adV2.Version == "" && reqVer != nil {
-		adV2.Version = reqVer.String()
-	} else if adV2.Version != "" && reqVer != nil {
-		parsedAdVersion, err := version.NewVersion(adV2.Version)
+	serverVersion := adV2.Version
+	if serverVersion == "" && reqVer != nil {
+		serverVersion = reqVer.String()
+	} else if serverVersion != "" && reqVer != nil {
+		parsedAdVersion, err := version.NewVersion(serverVersion)
 		if err != nil {
 			// ad version was not a valid version, so we fallback to the request version
-			adV2.Version = reqVer.String()
+			serverVersion = reqVer.String()
 		} else if !parsedAdVersion.Equal(reqVer) {
 			// if the reqVer doesn't match the adV2.version, we should use the adV2.version
-			adV2.Version = parsedAdVersion.String()
+			serverVersion = parsedAdVersion.String()
 		}
-	} else if adV2.Version == "" {
-		adV2.Version = "unknown"
+	} else if serverVersion == "" {
+		serverVersion = "unknown"
 	}
 
 	sAd := server_structs.ServerAd{
 		Name:                adV2.Name,
 		StorageType:         st,
-		DisableDirectorTest: adV2.DisableDirectorTest,
+		DisableDirectorTest: disableDirectorTest,
 		URL:                 *adUrl,
 		WebURL:              *adWebUrl,
 		BrokerURL:           *brokerUrl,
+		BrokerOnly:          brokerUrl.String() != "",
 		Type:                sType.String(),
 		Caps:                adV2.Caps,
 		IOLoad:              0.0, // Explicitly set to 0. The sort algorithm takes 0.0 as unknown load
-		Version:             adV2.Version,
+		Version:             serverVersion,
+		Region:              strings.ToLower(adV2.Region),
+		LocalHttpURL:        *localHttpUrl,
+		LocalHttpNetworks:   adV2.LocalHttpNetworks,
+		OfflinePartitions:   adV2.OfflinePartitions,
+		AdVersion:           adV3.AdVersion,
+		ChecksumAlgorithms:  adV3.ChecksumAlgorithms,
+		Draining:            adV3.Draining,
+		Degraded:            adV3.Degraded,
+		Capacity:            adV3.Capacity,
+		ChunkedUploads:      adV3.ChunkedUploads,
+		ArchiveMembers:      adV3.ArchiveMembers,
+	}
+	if adV3.Load != nil {
+		sAd.ActiveIO = adV3.Load.ActiveIO
+	}
+	if adV3.ContentFilter != nil {
+		// A bad filter only costs the server its content hints
+		if err := adV3.ContentFilter.Validate(); err != nil {
+			log.Warningf("Ignoring the content filter advertised by %s: %v", adV2.Name, err)
+		} else {
+			sAd.ContentFilter = adV3.ContentFilter
+		}
 	}
+	return sAd, nil
+}
 
-	recordAd(engineCtx, sAd, &adV2.Namespaces)
-
-	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK, Msg: "Successful registration"})
+// The registry prefix whose registration authorizes the server to advertise, and
+// whether the director verifies it at all
+func advertisementRegistryPrefix(sType server_structs.ServerType, adV2 *server_structs.OriginAdvertiseV2) (registryPrefix string, verify bool) {
+	registryPrefix = adV2.RegistryPrefix
+	if registryPrefix == "" {
+		if sType == server_structs.OriginType {
+			// For origins < 7.9.0, they are not registered, and we skip the verification
+			return "", false
+		}
+		// For caches <= 7.8.1, they don't have RegistryPrefix
+		// so we fall back to Name
+		registryPrefix = server_structs.GetCacheNS(adV2.Name)
+	}
+	return registryPrefix, true
 }
 
 func serverAdMetricMiddleware(ctx *gin.Context) {
@@ -1375,6 +1597,11 @@ func collectClientVersionMetric(reqVer *version.Version, service string) {
 	metrics.PelicanDirectorClientVersionTotal.With(prometheus.Labels{"version": shortendVersion, "service": service}).Inc()
 }
 
+// Count a redirect for the namespace to the server the client was sent to
+func recordNamespaceRedirect(nsPath string, ad server_structs.ServerAd) {
+	metrics.PelicanDirectorNamespaceRedirectsTotal.WithLabelValues(nsPath, ad.Name, ad.Type).Inc()
+}
+
 func collectDirectorRedirectionMetric(ctx *gin.Context, destination string) {
 	labels := prometheus.Labels{
 		"destination": destination,
@@ -1403,6 +1630,61 @@ func collectDirectorRedirectionMetric(ctx *gin.Context, destination string) {
 	metrics.PelicanDirectorRedirectionsTotal.With(labels).Inc()
 }
 
+// Report which caches currently hold a copy of an object.
+//
+// Unlike the redirect endpoints, every cache serving the object's namespace is
+// queried (subject to the stat result cache) and the per-cache result is returned,
+// allowing clients to answer "where is this object" without downloading it.
+func queryObjectAvailability(ginCtx *gin.Context) {
+	reqPath := path.Clean("/" + ginCtx.Param("path"))
+	reqPath = applyNamespaceAlias(ginCtx, reqPath)
+	reqParams := getRequestParameters(ginCtx.Request)
+
+	namespaceAd, _, cacheAds := getAdsForPath(reqPath)
+	if namespaceAd.Path == "" {
+		ginCtx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "No namespace found for path. Either it doesn't exist, or the Director is experiencing problems",
+		})
+		return
+	}
+
+	resp := server_structs.ObjectAvailabilityResp{
+		Path:   reqPath,
+		Caches: make([]server_structs.ObjectAvailability, 0, len(cacheAds)),
+	}
+	for _, cAd := range cacheAds {
+		resp.Caches = append(resp.Caches, server_structs.ObjectAvailability{
+			Name: cAd.Name,
+			URL:  cAd.URL.String(),
+		})
+	}
+	if len(cacheAds) == 0 {
+		ginCtx.JSON(http.StatusOK, resp)
+		return
+	}
+
+	qr := NewObjectStat().Query(ginCtx, reqPath, server_structs.CacheType, 1, len(cacheAds),
+		withCacheAds(cacheAds), withAuth(!namespaceAd.Caps.PublicReads), WithToken(reqParams.Get("authz")))
+	log.Debugf("Availability result for %s: %s", reqPath, qr.String())
+	if qr.Status == queryFailed && qr.ErrorType != queryInsufficientResErr {
+		ginCtx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Failed to query caches with error %s: %s", string(qr.ErrorType), qr.Msg),
+		})
+		return
+	}
+	for _, obj := range qr.Objects {
+		for idx, cAd := range cacheAds {
+			if cAd.URL.Host == obj.URL.Host || cAd.AuthURL.Host == obj.URL.Host {
+				resp.Caches[idx].Available = true
+				resp.Caches[idx].Checksum = obj.Checksum
+			}
+		}
+	}
+	ginCtx.JSON(http.StatusOK, resp)
+}
+
 func RegisterDirectorAPI(ctx context.Context, router *gin.RouterGroup) {
 	directorAPIV1 := router.Group("/api/v1.0/director")
 	{
@@ -1415,11 +1697,14 @@ func RegisterDirectorAPI(ctx context.Context, router *gin.RouterGroup) {
 		directorAPIV1.DELETE("/origin/*any", redirectToOrigin)
 		directorAPIV1.POST("/registerOrigin", serverAdMetricMiddleware, func(gctx *gin.Context) { registerServeAd(ctx, gctx, server_structs.OriginType) })
 		directorAPIV1.POST("/registerCache", serverAdMetricMiddleware, func(gctx *gin.Context) { registerServeAd(ctx, gctx, server_structs.CacheType) })
+		directorAPIV1.POST("/validateAd", func(gctx *gin.Context) { validateServerAd(ctx, gctx) })
 		directorAPIV1.GET("/listNamespaces", listNamespacesV1)
 		directorAPIV1.GET("/namespaces/prefix/*path", getPrefixByPath)
 		directorAPIV1.GET("/healthTest/*path", getHealthTestFile)
 		directorAPIV1.HEAD("/healthTest/*path", getHealthTestFile)
 		directorAPIV1.GET("/listX509ClientPrefixes", listX509ClientPrefixes)
+		directorAPIV1.GET("/availability/*path", queryObjectAvailability)
+		directorAPIV1.GET("/nearestCache", nearestCacheHandler)
 		directorAPIV1.Any("/origin", func(gctx *gin.Context) { // Need to do this for PROPFIND since gin does not support it
 			if gctx.Request.Method == "PROPFIND" {
 				redirectToOrigin(gctx)
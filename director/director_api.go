@@ -25,10 +25,12 @@ import (
 	"time"
 
 	"github.com/jellydator/ttlcache/v3"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
@@ -80,6 +82,9 @@ func checkFilter(serverName string) (bool, filterType) {
 			return true, tempFiltered
 		case topoFiltered:
 			return true, topoFiltered
+		case topoUnscheduledFiltered:
+			// Kept as a last resort rather than filtered
+			return false, topoUnscheduledFiltered
 		case tempAllowed:
 			return false, tempAllowed
 		default:
@@ -89,6 +94,14 @@ func checkFilter(serverName string) (bool, filterType) {
 	}
 }
 
+// The server's current downtime in the OSG Topology, if any
+func getTopologyDowntime(serverName string) (topologyDowntime, bool) {
+	filteredServersMutex.RLock()
+	defer filteredServersMutex.RUnlock()
+	downtime, ok := topologyDowntimes[serverName]
+	return downtime, ok
+}
+
 // Configure TTL caches to enable cache eviction and other additional cache events handling logic
 //
 // The `ctx` is the context for listening to server shutdown event in order to cleanup internal cache eviction goroutine
@@ -193,6 +206,17 @@ func LaunchMapMetrics(ctx context.Context, egrp *errgroup.Group) {
 				metrics.PelicanDirectorMapItemsTotal.WithLabelValues("filteredServers").Set(float64(len(filteredServers)))
 				metrics.PelicanDirectorMapItemsTotal.WithLabelValues("healthTestUtils").Set(float64(len(healthTestUtils)))
 				metrics.PelicanDirectorMapItemsTotal.WithLabelValues("originStatUtils").Set(float64(len(statUtils)))
+
+				// Filtered servers, by filter type
+				filterCounts := map[filterType]int{permFiltered: 0, tempFiltered: 0, tempAllowed: 0, topoFiltered: 0, topoUnscheduledFiltered: 0}
+				filteredServersMutex.RLock()
+				for _, ft := range filteredServers {
+					filterCounts[ft]++
+				}
+				filteredServersMutex.RUnlock()
+				for ft, count := range filterCounts {
+					metrics.PelicanDirectorFilteredServers.WithLabelValues(string(ft)).Set(float64(count))
+				}
 			}
 		}
 	})
@@ -215,11 +239,16 @@ func hookServerAdsCache() {
 
 	serverAds.OnEviction(func(ctx context.Context, er ttlcache.EvictionReason, ad *ttlcache.Item[string, *server_structs.Advertisement]) {
 		serverAd := ad.Value()
-		metrics.PelicanDirectorServerCount.With(prometheus.Labels{
+		labels := prometheus.Labels{
 			"server_name":   serverAd.Name,
 			"server_type":   string(serverAd.Type),
 			"from_topology": strconv.FormatBool(serverAd.FromTopology),
-		}).Dec()
+		}
+		metrics.PelicanDirectorServerCount.With(labels).Dec()
+		// Unlike deletions, expirations mean the server stopped advertising
+		if er == ttlcache.EvictionReasonExpired {
+			metrics.PelicanDirectorServerAdExpirationsTotal.With(labels).Inc()
+		}
 	})
 }
 
@@ -249,6 +278,40 @@ func ConfigFilterdServers() {
 	}
 }
 
+// Re-read Director.FilteredServers after a configuration reload, dropping the
+// servers that are no longer listed
+func reloadFilteredServers() error {
+	listed := map[string]bool{}
+	for _, sn := range param.Director_FilteredServers.GetStringSlice() {
+		listed[sn] = true
+	}
+	filteredServersMutex.Lock()
+	for sn, ft := range filteredServers {
+		if (ft == permFiltered || ft == tempAllowed) && !listed[sn] {
+			delete(filteredServers, sn)
+		}
+	}
+	filteredServersMutex.Unlock()
+	ConfigFilterdServers()
+	return nil
+}
+
+// Mark the director parameters that can be changed without a restart
+func RegisterReloadables() {
+	config.RegisterReloadable(func() error {
+		switch s := server_structs.SortType(param.Director_CacheSortMethod.GetString()); s {
+		case server_structs.DistanceType, server_structs.DistanceAndLoadType, server_structs.RandomType, server_structs.AdaptiveType:
+			return nil
+		default:
+			return errors.Errorf("invalid Director.CacheSortMethod %q", s)
+		}
+	}, param.Director_CacheSortMethod.GetName())
+	config.RegisterReloadable(reloadFilteredServers, param.Director_FilteredServers.GetName())
+	config.RegisterReloadable(ConfigSortExperiment, param.Director_SortExperiment.GetName())
+	config.RegisterReloadable(ConfigRedirectStyles, param.Director_RedirectStyles.GetName())
+	registerRateLimitReloadables()
+}
+
 // Start a goroutine to query director's Prometheus endpoint for origin/cache server I/O stats
 // and save the value to the corresponding serverAd
 func LaunchServerIOQuery(ctx context.Context, egrp *errgroup.Group) {
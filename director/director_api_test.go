@@ -230,6 +230,20 @@ func TestCheckFilter(t *testing.T) {
 			filtered:     false,
 			ft:           tempAllowed,
 		},
+		{
+			name:         "topo-scheduled-return-true",
+			serverToTest: "mock",
+			mapItems:     map[string]filterType{"mock": topoFiltered},
+			filtered:     true,
+			ft:           topoFiltered,
+		},
+		{
+			name:         "topo-unscheduled-return-false",
+			serverToTest: "mock",
+			mapItems:     map[string]filterType{"mock": topoUnscheduledFiltered},
+			filtered:     false,
+			ft:           topoUnscheduledFiltered,
+		},
 	}
 
 	for _, tc := range testCases {
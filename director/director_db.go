@@ -18,14 +18,17 @@
 package director
 
 import (
+	"context"
 	"embed"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_utils"
 )
@@ -44,6 +47,12 @@ var db *gorm.DB
 //go:embed migrations/*.sql
 var embedMigrations embed.FS
 
+// The schema migrations for the Director database, for tools that manage the
+// database outside of a running server
+func GetMigrations() embed.FS {
+	return embedMigrations
+}
+
 // Initialize the Director's sqlite database, which is used to persist information about server downtimes
 func InitializeDB() error {
 	dbPath := param.Director_DbLocation.GetString()
@@ -68,6 +77,11 @@ func shutdownDirectorDB() error {
 	return server_utils.ShutdownDB(db)
 }
 
+// Periodically check the database works, reporting the result as the director-database component
+func LaunchDBHealthCheck(ctx context.Context, egrp *errgroup.Group) {
+	server_utils.LaunchDBHealthCheck(ctx, egrp, db, metrics.Director_Database)
+}
+
 // Create a new db entry representing the downtime info of a server
 func createServerDowntime(serverName string, filterType filterType) error {
 	id, err := uuid.NewV7()
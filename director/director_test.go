@@ -30,6 +30,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"net/url"
 	"path/filepath"
 	"testing"
@@ -40,11 +41,13 @@ import (
 	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/server_utils"
 	"github.com/pelicanplatform/pelican/test_utils"
@@ -343,6 +346,70 @@ func TestDirectorRegistration(t *testing.T) {
 		assert.Equal(t, getAd.Name, ad.Name)
 		require.Len(t, getAd.NamespaceAds, 1)
 		assert.Equal(t, getAd.NamespaceAds[0].Path, "/foo/bar")
+		assert.Equal(t, server_structs.AdVersionV2, getAd.AdVersion)
+		// An origin advertising a broker is only reachable through it
+		assert.True(t, getAd.BrokerOnly)
+		assert.Equal(t, server_structs.SupportedAdVersions(), w.Result().Header.Get(server_structs.AdVersionsHeader))
+		teardown()
+	})
+
+	t.Run("valid-token-V3", func(t *testing.T) {
+		c, r, w := setupContext()
+		pKey, token, _ := generateToken()
+		publicKey, err := jwk.PublicKeyOf(pKey)
+		assert.NoError(t, err, "Error creating public key from private key")
+
+		setupJwksCache(t, "/foo/bar", publicKey)
+
+		isurl := url.URL{}
+		isurl.Path = ts.URL
+
+		ad := server_structs.OriginAdvertiseV3{
+			OriginAdvertiseV2: server_structs.OriginAdvertiseV2{
+				DataURL: "https://or-url.org",
+				Name:    "test",
+				Namespaces: []server_structs.NamespaceAdV2{{
+					Path:   "/foo/bar",
+					Issuer: []server_structs.TokenIssuer{{IssuerUrl: isurl}},
+				}},
+			},
+			Load:               &server_structs.AdvertisedLoad{ActiveIO: 4},
+			ChecksumAlgorithms: []string{"md5", "adler32"},
+		}
+		versionedAd, err := ad.ForVersion(server_structs.AdVersionV3)
+		require.NoError(t, err)
+		jsonad, err := json.Marshal(versionedAd)
+		assert.NoError(t, err, "Error marshalling OriginAdvertise")
+
+		setupRequest(c, r, jsonad, token, server_structs.OriginType)
+
+		r.ServeHTTP(w, c.Request)
+
+		assert.Equal(t, 200, w.Result().StatusCode, "Expected status code of 200")
+
+		get := serverAds.Get("https://or-url.org")
+		require.NotNil(t, get, "Coudln't find server in the director cache.")
+		getAd := get.Value()
+		assert.Equal(t, "test", getAd.Name)
+		require.Len(t, getAd.NamespaceAds, 1)
+		assert.Equal(t, server_structs.AdVersionV3, getAd.AdVersion)
+		assert.Equal(t, int64(4), getAd.ActiveIO)
+		assert.Equal(t, []string{"md5", "adler32"}, getAd.ChecksumAlgorithms)
+		teardown()
+	})
+
+	t.Run("unsupported-ad-version", func(t *testing.T) {
+		c, r, w := setupContext()
+		_, token, _ := generateToken()
+
+		jsonad := []byte(`{"ad-version": 99, "name": "test", "data-url": "https://or-url.org"}`)
+		setupRequest(c, r, jsonad, token, server_structs.OriginType)
+
+		r.ServeHTTP(w, c.Request)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		assert.Equal(t, server_structs.SupportedAdVersions(), w.Result().Header.Get(server_structs.AdVersionsHeader))
+		assert.Nil(t, serverAds.Get("https://or-url.org"))
 		teardown()
 	})
 
@@ -567,6 +634,7 @@ func TestDirectorRegistration(t *testing.T) {
 		// Check to see that the code exits with status code 200 after given it a good token
 		require.Equal(t, 200, w.Result().StatusCode, "Expected status code of 200")
 
+		// Only caches can reach an origin behind the broker
 		c, r, w = setupContext()
 		token = generateReadToken(pKey, "/foo/bar", isurl.String())
 		// Since we didn't set up any real server for the test
@@ -575,6 +643,15 @@ func TestDirectorRegistration(t *testing.T) {
 
 		r.ServeHTTP(w, c.Request)
 
+		assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+		assert.Empty(t, w.Result().Header.Get("X-Pelican-Broker"))
+
+		c, r, w = setupContext()
+		setupRedirect(c, r, "/foo/bar/baz?skipstat", token)
+		c.Request.Header.Set("User-Agent", "pelican-cache/7.3.0")
+
+		r.ServeHTTP(w, c.Request)
+
 		assert.Equal(t, http.StatusTemporaryRedirect, w.Result().StatusCode)
 		if w.Result().StatusCode != http.StatusTemporaryRedirect {
 			body, err := io.ReadAll(w.Result().Body)
@@ -1725,6 +1802,39 @@ func TestRedirects(t *testing.T) {
 		assert.NotContains(t, c.Writer.Header().Get("X-Pelican-Namespace"), "collections-url")
 	})
 
+	t.Run("redirects-are-counted-by-namespace", func(t *testing.T) {
+		server_utils.ResetTestState()
+		t.Cleanup(func() {
+			server_utils.ResetTestState()
+		})
+
+		viper.Set("Director.CacheSortMethod", "random")
+
+		// The namespace has a single origin and a single cache, so the server each
+		// request is redirected to is known
+		nsAd, originAds, cacheAds := getAdsForPath("/my/server/2")
+		require.Len(t, originAds, 1)
+		require.Len(t, cacheAds, 1)
+		cacheRedirects := metrics.PelicanDirectorNamespaceRedirectsTotal.WithLabelValues(nsAd.Path, cacheAds[0].Name, cacheAds[0].Type)
+		originRedirects := metrics.PelicanDirectorNamespaceRedirectsTotal.WithLabelValues(nsAd.Path, originAds[0].Name, originAds[0].Type)
+		cacheBefore := testutil.ToFloat64(cacheRedirects)
+		originBefore := testutil.ToFloat64(originRedirects)
+
+		for _, handler := range []gin.HandlerFunc{redirectToCache, redirectToCache, redirectToOrigin} {
+			req, _ := http.NewRequest("GET", "/my/server/2/foo", nil)
+			req.Header.Add("User-Agent", "pelican-client/7.6.1")
+			req.Header.Add("X-Real-Ip", "128.104.153.60")
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = req
+			handler(c)
+			require.Equal(t, http.StatusTemporaryRedirect, c.Writer.Status())
+		}
+
+		assert.Equal(t, cacheBefore+2, testutil.ToFloat64(cacheRedirects))
+		assert.Equal(t, originBefore+1, testutil.ToFloat64(originRedirects))
+	})
+
 	t.Run("object-endpoint-returns-all-headers", func(t *testing.T) {
 		server_utils.ResetTestState()
 		t.Cleanup(func() {
@@ -1771,6 +1881,58 @@ func TestRedirects(t *testing.T) {
 		assert.NotEmpty(t, c.Writer.Header().Get("X-Pelican-Namespace"))
 	})
 }
+func TestRedirectToArchiveMember(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(server_utils.ResetTestState)
+	viper.Set("Director.CacheSortMethod", "random")
+
+	namespaceAd := server_structs.NamespaceAdV2{
+		Caps: server_structs.Capabilities{PublicReads: true, Reads: true},
+		Path: "/ns",
+	}
+	plainOrigin := server_structs.ServerAd{Name: "plain", Type: server_structs.OriginType.String()}
+	plainOrigin.URL = url.URL{Scheme: "https", Host: "plain.example.org:1094"}
+	plainOrigin.WebURL = url.URL{Scheme: "https", Host: "plain.example.org:8443"}
+	archiveOrigin := plainOrigin
+	archiveOrigin.Name = "archive"
+	archiveOrigin.URL = url.URL{Scheme: "https", Host: "archive.example.org:1094"}
+	archiveOrigin.WebURL = url.URL{Scheme: "https", Host: "archive.example.org:8443"}
+	archiveOrigin.ArchiveMembers = true
+
+	redirect := func(method, target string, originAds []server_structs.ServerAd) (*httptest.ResponseRecorder, bool) {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer sometoken")
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+		handled := redirectToArchiveMember(c, "/ns/data.zip", netip.MustParseAddr("128.104.153.60"), namespaceAd, originAds, getRequestParameters(req))
+		return w, handled
+	}
+
+	// Requests for whole objects are left to the usual redirects
+	w, handled := redirect(http.MethodGet, "/ns/data.zip", []server_structs.ServerAd{plainOrigin, archiveOrigin})
+	assert.False(t, handled)
+	assert.Empty(t, w.Header().Get("Location"))
+
+	w, handled = redirect(http.MethodGet, "/ns/data.zip?member=images/1.png", []server_structs.ServerAd{plainOrigin, archiveOrigin})
+	require.True(t, handled)
+	require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "archive.example.org:8443", location.Host)
+	assert.Equal(t, "/api/v1.0/origin/archive/ns/data.zip", location.Path)
+	assert.Equal(t, "images/1.png", location.Query().Get("member"))
+	assert.Equal(t, "sometoken", location.Query().Get("authz"))
+
+	w, handled = redirect(http.MethodGet, "/ns/data.zip?member=images/1.png", []server_structs.ServerAd{plainOrigin})
+	require.True(t, handled)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w, handled = redirect(http.MethodPut, "/ns/data.zip?member=images/1.png", []server_structs.ServerAd{archiveOrigin})
+	require.True(t, handled)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
 func TestHeaderGenFuncs(t *testing.T) {
 	issUrl := url.URL{
 		Scheme: "https",
@@ -2389,6 +2551,54 @@ func TestGetRedirectUrl(t *testing.T) {
 	})
 }
 
+func TestGetLocalHttpRedirectURL(t *testing.T) {
+	ad := server_structs.ServerAd{
+		URL:               url.URL{Host: "cache.example.org:8443"},
+		LocalHttpURL:      url.URL{Scheme: "http", Host: "cache.example.org:8000"},
+		LocalHttpNetworks: []string{"10.0.0.0/8", "fd00::/8"},
+	}
+	redirectURL := getRedirectURL("/some/path", ad, false)
+
+	t.Run("client-on-local-network", func(t *testing.T) {
+		get := getLocalHttpRedirectURL(redirectURL, ad, netip.MustParseAddr("10.1.2.3"))
+		assert.Equal(t, "http://cache.example.org:8000/some/path", get.String())
+
+		get = getLocalHttpRedirectURL(redirectURL, ad, netip.MustParseAddr("::ffff:10.1.2.3"))
+		assert.Equal(t, "http://cache.example.org:8000/some/path", get.String())
+
+		get = getLocalHttpRedirectURL(redirectURL, ad, netip.MustParseAddr("fd00::1"))
+		assert.Equal(t, "http://cache.example.org:8000/some/path", get.String())
+	})
+
+	t.Run("client-on-other-network", func(t *testing.T) {
+		get := getLocalHttpRedirectURL(redirectURL, ad, netip.MustParseAddr("192.168.1.1"))
+		assert.Equal(t, "https://cache.example.org:8443/some/path", get.String())
+
+		get = getLocalHttpRedirectURL(redirectURL, ad, netip.Addr{})
+		assert.Equal(t, "https://cache.example.org:8443/some/path", get.String())
+	})
+
+	t.Run("cache-without-local-http", func(t *testing.T) {
+		noLocal := server_structs.ServerAd{URL: url.URL{Host: "cache.example.org:8443"}}
+		get := getLocalHttpRedirectURL(redirectURL, noLocal, netip.MustParseAddr("10.1.2.3"))
+		assert.Equal(t, "https://cache.example.org:8443/some/path", get.String())
+	})
+}
+
+func TestFilterBrokerOnlyAds(t *testing.T) {
+	brokered := server_structs.ServerAd{Name: "brokered", BrokerOnly: true}
+	direct1 := server_structs.ServerAd{Name: "direct1"}
+	direct2 := server_structs.ServerAd{Name: "direct2"}
+	ads := []server_structs.ServerAd{brokered, direct1, direct2}
+
+	// Caches fall back on the brokered origin after the others
+	assert.Equal(t, []server_structs.ServerAd{direct1, direct2, brokered}, filterBrokerOnlyAds(ads, true))
+	// Other clients can't use it at all
+	assert.Equal(t, []server_structs.ServerAd{direct1, direct2}, filterBrokerOnlyAds(ads, false))
+	assert.Empty(t, filterBrokerOnlyAds([]server_structs.ServerAd{brokered}, false))
+	assert.Equal(t, []server_structs.ServerAd{brokered}, filterBrokerOnlyAds([]server_structs.ServerAd{brokered}, true))
+}
+
 func TestGetFinalRedirectURL(t *testing.T) {
 	t.Run("url-without-params", func(t *testing.T) {
 		base := url.URL{Scheme: "https", Host: "example.org:8444"}
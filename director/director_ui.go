@@ -19,9 +19,11 @@
 package director
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"path"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -38,6 +40,13 @@ type (
 		ServerType string `form:"server_type"` // "cache" or "origin"
 	}
 
+	// Query parameters for filtering the namespace listing and selecting the returned fields.
+	// Both accept repeated parameters as well as comma-separated values.
+	listNamespacesRequest struct {
+		Capabilities []string `form:"capability"` // Capabilities every returned namespace must have
+		Fields       []string `form:"fields"`     // JSON fields to include in each returned namespace
+	}
+
 	// A response struct for a server Ad that provides a minimal view into the servers data
 	listServerResponse struct {
 		Name                string                           `json:"name"`
@@ -48,6 +57,7 @@ type (
 		// accessing protected objects and URL for public objects.
 		AuthURL           string                      `json:"authUrl"`
 		BrokerURL         string                      `json:"brokerUrl"`
+		BrokerOnly        bool                        `json:"brokerOnly"`
 		URL               string                      `json:"url"`    // This is server's XRootD URL for file transfer
 		WebURL            string                      `json:"webUrl"` // This is server's Web interface and API
 		Type              string                      `json:"type"`
@@ -72,6 +82,7 @@ type (
 		// accessing protected objects and URL for public objects.
 		AuthURL      string                      `json:"authUrl"`
 		BrokerURL    string                      `json:"brokerUrl"`
+		BrokerOnly   bool                        `json:"brokerOnly"`
 		URL          string                      `json:"url"`    // This is server's XRootD URL for file transfer
 		WebURL       string                      `json:"webUrl"` // This is server's Web interface and API
 		Type         string                      `json:"type"`
@@ -84,6 +95,11 @@ type (
 		HealthStatus HealthTestStatus            `json:"healthStatus"`
 		IOLoad       float64                     `json:"ioLoad"`
 		Namespaces   []NamespaceAdV2Response     `json:"namespaces"`
+		// The OSG resource group and contacts of servers from topology
+		ResourceGroup string                       `json:"resourceGroup,omitempty"`
+		Contacts      []server_structs.TopoContact `json:"contacts,omitempty"`
+		// The server's current downtime in the OSG Topology, if any
+		TopologyDowntime *topologyDowntime `json:"topologyDowntime,omitempty"`
 	}
 
 	// TokenIssuerResponse creates a response struct for TokenIssuer
@@ -234,6 +250,7 @@ func advertisementToServerResponse(ad *server_structs.Advertisement) serverRespo
 		StorageType:         ad.StorageType,
 		DisableDirectorTest: ad.DisableDirectorTest,
 		BrokerURL:           ad.BrokerURL.String(),
+		BrokerOnly:          ad.BrokerOnly,
 		AuthURL:             ad.AuthURL.String(),
 		URL:                 ad.URL.String(),
 		WebURL:              ad.WebURL.String(),
@@ -246,6 +263,11 @@ func advertisementToServerResponse(ad *server_structs.Advertisement) serverRespo
 		FromTopology:        ad.FromTopology,
 		HealthStatus:        healthStatus,
 		IOLoad:              ad.GetIOLoad(),
+		ResourceGroup:       ad.ResourceGroup,
+		Contacts:            ad.Contacts,
+	}
+	if downtime, ok := getTopologyDowntime(ad.Name); ok {
+		res.TopologyDowntime = &downtime
 	}
 	for _, ns := range ad.NamespaceAds {
 		nsRes := namespaceAdV2ToResponse(&ns)
@@ -261,6 +283,7 @@ func serverResponseToListServerResponse(res serverResponse) listServerResponse {
 		StorageType:         res.StorageType,
 		DisableDirectorTest: res.DisableDirectorTest,
 		BrokerURL:           res.BrokerURL,
+		BrokerOnly:          res.BrokerOnly,
 		AuthURL:             res.AuthURL,
 		URL:                 res.URL,
 		WebURL:              res.WebURL,
@@ -366,9 +389,126 @@ func listNamespaceResponses() []NamespaceAdV2MappedResponse {
 	return utils.MapToSlice(namespaceMap)
 }
 
-// Get list of all namespaces
+// Map of the capability names accepted by the namespace listing to a check of that capability.
+// Both the JSON names of server_structs.Capabilities and friendlier aliases are accepted;
+// experimental capabilities are given as experimental.<name>.
+var namespaceCapabilityFilters = map[string]func(server_structs.Capabilities) bool{
+	"publicread":   func(c server_structs.Capabilities) bool { return c.PublicReads },
+	"public":       func(c server_structs.Capabilities) bool { return c.PublicReads },
+	"read":         func(c server_structs.Capabilities) bool { return c.Reads },
+	"write":        func(c server_structs.Capabilities) bool { return c.Writes },
+	"writable":     func(c server_structs.Capabilities) bool { return c.Writes },
+	"listing":      func(c server_structs.Capabilities) bool { return c.Listings },
+	"fallbackread": func(c server_structs.Capabilities) bool { return c.DirectReads },
+	"directread":   func(c server_structs.Capabilities) bool { return c.DirectReads },
+}
+
+// The JSON fields of NamespaceAdV2MappedResponse that may be selected in the namespace listing
+var namespaceResponseFields = []string{"path", "capabilities", "tokenGeneration", "tokenIssuer", "fromTopology", "origins", "caches"}
+
+// Split repeated and comma-separated query values into a list of non-empty values
+func splitQueryValues(values []string) []string {
+	result := []string{}
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// Filter the namespaces to those having every one of the requested capabilities
+func filterNamespacesByCapabilities(namespaces []NamespaceAdV2MappedResponse, capabilities []string) ([]NamespaceAdV2MappedResponse, error) {
+	checks := make([]func(server_structs.Capabilities) bool, 0, len(capabilities))
+	for _, capability := range capabilities {
+		check, ok := namespaceCapabilityFilters[strings.ToLower(capability)]
+		if !ok {
+			name, isExtension := strings.CutPrefix(strings.ToLower(capability), "experimental.")
+			if !isExtension || name == "" {
+				return nil, fmt.Errorf("unknown capability %q", capability)
+			}
+			check = func(c server_structs.Capabilities) bool { return c.HasExtension(name) }
+		}
+		checks = append(checks, check)
+	}
+	filtered := make([]NamespaceAdV2MappedResponse, 0, len(namespaces))
+	for _, ns := range namespaces {
+		matches := true
+		for _, check := range checks {
+			if !check(ns.Caps) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			filtered = append(filtered, ns)
+		}
+	}
+	return filtered, nil
+}
+
+// Reduce each namespace to the selected JSON fields
+func selectNamespaceFields(namespaces []NamespaceAdV2MappedResponse, fields []string) ([]map[string]interface{}, error) {
+	for _, field := range fields {
+		if !slices.Contains(namespaceResponseFields, field) {
+			return nil, fmt.Errorf("unknown field %q; valid fields are %s", field, strings.Join(namespaceResponseFields, ", "))
+		}
+	}
+	selected := make([]map[string]interface{}, 0, len(namespaces))
+	for _, ns := range namespaces {
+		nsJson, err := json.Marshal(ns)
+		if err != nil {
+			return nil, err
+		}
+		nsMap := make(map[string]interface{})
+		if err := json.Unmarshal(nsJson, &nsMap); err != nil {
+			return nil, err
+		}
+		res := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			res[field] = nsMap[field]
+		}
+		selected = append(selected, res)
+	}
+	return selected, nil
+}
+
+// Get list of all namespaces, optionally filtered by capabilities and reduced to selected fields
 func listNamespacesHandler(ctx *gin.Context) {
-	ctx.JSON(http.StatusOK, listNamespaceResponses())
+	queryParams := listNamespacesRequest{}
+	if ctx.ShouldBindQuery(&queryParams) != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid query parameters",
+		})
+		return
+	}
+
+	namespaces, err := filterNamespacesByCapabilities(listNamespaceResponses(), splitQueryValues(queryParams.Capabilities))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid capability filter: " + err.Error(),
+		})
+		return
+	}
+
+	fields := splitQueryValues(queryParams.Fields)
+	if len(fields) == 0 {
+		ctx.JSON(http.StatusOK, namespaces)
+		return
+	}
+	selected, err := selectNamespaceFields(namespaces, fields)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid field selection: " + err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, selected)
 }
 
 // Issue a stat query to origins for an object and return which origins serve the object
@@ -595,6 +735,16 @@ func RegisterDirectorWebAPI(router *gin.RouterGroup) {
 		directorWebAPI.GET("/servers/origins/stat/*path", web_ui.AuthHandler, queryOrigins)
 		directorWebAPI.HEAD("/servers/origins/stat/*path", web_ui.AuthHandler, queryOrigins)
 		directorWebAPI.GET("/namespaces", listNamespacesHandler)
+		directorWebAPI.GET("/replicas", listReplicasHandler)
+		directorWebAPI.GET("/availability_report", web_ui.AuthHandler, web_ui.AdminAuthHandler, availabilityReportHandler)
+		directorWebAPI.GET("/slos", listSLOsHandler)
+		directorWebAPI.GET("/sort_experiment", getSortExperimentHandler)
+		directorWebAPI.GET("/topology/issues", listTopologyIssuesHandler)
+		directorWebAPI.GET("/health", federationHealthHandler)
 		directorWebAPI.GET("/contact", handleDirectorContact)
+		directorWebAPI.GET("/deny_rules", web_ui.AuthHandler, web_ui.AdminAuthHandler, listDenyRulesHandler)
+		directorWebAPI.POST("/deny_rules", web_ui.AuthHandler, web_ui.AdminAuthHandler, createDenyRuleHandler)
+		directorWebAPI.DELETE("/deny_rules/:id", web_ui.AuthHandler, web_ui.AdminAuthHandler, deleteDenyRuleHandler)
+		directorWebAPI.GET("/deny_rules/audit", web_ui.AuthHandler, web_ui.AdminAuthHandler, listDenyRuleAuditsHandler)
 	}
 }
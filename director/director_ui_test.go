@@ -406,4 +406,62 @@ func TestGetNamespaces(t *testing.T) {
 			assert.Contains(t, got, ns, "Response data does not match expected")
 		}
 	})
+
+	t.Run("filter-and-select-fields", func(t *testing.T) {
+		serverAds.DeleteAll()
+		mockNamespaces := mockNamespaceAds(3, "origin1")
+		mockNamespaces[0].Caps = server_structs.Capabilities{PublicReads: true, Reads: true, Writes: true}
+		mockNamespaces[1].Caps = server_structs.Capabilities{Reads: true, Writes: true, Extensions: map[string]string{"tpc": "true", "zip-extraction": "false"}}
+		mockNamespaces[2].Caps = server_structs.Capabilities{PublicReads: true, Extensions: map[string]string{"tpc": "v2"}}
+		serverAds.Set(mockOriginServerAd.URL.String(),
+			&server_structs.Advertisement{
+				ServerAd:     mockOriginServerAd,
+				NamespaceAds: mockNamespaces,
+			}, ttlcache.DefaultTTL)
+
+		getPaths := func(query string) []string {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/namespaces?"+query, nil)
+			router.ServeHTTP(w, req)
+			require.Equal(t, 200, w.Code)
+			var got []NamespaceAdV2MappedResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			paths := []string{}
+			for _, ns := range got {
+				paths = append(paths, ns.Path)
+			}
+			return paths
+		}
+
+		assert.ElementsMatch(t, []string{mockNamespaces[0].Path, mockNamespaces[1].Path}, getPaths("capability=writable"))
+		assert.ElementsMatch(t, []string{mockNamespaces[0].Path, mockNamespaces[2].Path}, getPaths("capability=PublicRead"))
+		assert.ElementsMatch(t, []string{mockNamespaces[0].Path}, getPaths("capability=write&capability=public"))
+		assert.ElementsMatch(t, []string{mockNamespaces[0].Path}, getPaths("capability=write,public"))
+		assert.Empty(t, getPaths("capability=listing"))
+		assert.ElementsMatch(t, []string{mockNamespaces[1].Path, mockNamespaces[2].Path}, getPaths("capability=experimental.tpc"))
+		assert.ElementsMatch(t, []string{mockNamespaces[1].Path}, getPaths("capability=Experimental.TPC,write"))
+		assert.Empty(t, getPaths("capability=experimental.zip-extraction"))
+
+		// Only the selected fields are returned
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/namespaces?capability=public&fields=path,origins", nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, 200, w.Code)
+		var got []map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		require.Len(t, got, 2)
+		for _, ns := range got {
+			assert.Len(t, ns, 2)
+			assert.Contains(t, ns, "path")
+			assert.Equal(t, []interface{}{mockOriginServerAd.Name}, ns["origins"])
+		}
+
+		// Unknown capabilities and fields are rejected
+		for _, query := range []string{"capability=teleport", "capability=experimental.", "fields=path,secret"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/namespaces?"+query, nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, 400, w.Code, query)
+		}
+	})
 }
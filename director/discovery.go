@@ -19,15 +19,23 @@
 package director
 
 import (
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/pelican_url"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/server_utils"
@@ -37,6 +45,24 @@ const (
 	oidcDiscoveryPath       string = "/.well-known/openid-configuration"
 	federationDiscoveryPath string = "/.well-known/pelican-configuration"
 	directorJWKSPath        string = "/.well-known/issuer.jwks"
+
+	discoveryJWTMIME string = "application/jwt"
+)
+
+var (
+	// Fields of the discovery document, and of its signed form, that
+	// Director.DiscoveryExtraFields may not override
+	reservedDiscoveryFields = map[string]bool{
+		"discovery_endpoint":              true,
+		"director_endpoint":               true,
+		"namespace_registration_endpoint": true,
+		"jwks_uri":                        true,
+		"broker_endpoint":                 true,
+		"iss":                             true,
+		"iat":                             true,
+		"nbf":                             true,
+		"exp":                             true,
+	}
 )
 
 // Director hosts a discovery endpoint at federationDiscoveryPath to provide URLs to various
@@ -50,6 +76,7 @@ func federationDiscoveryHandler(ctx *gin.Context) {
 				Status: server_structs.RespFailed,
 				Msg:    "Bad server configuration: Federation discovery could not resolve",
 			})
+		return
 	}
 	directorUrlStr := fedInfo.DirectorEndpoint
 	if directorUrlStr == "" {
@@ -118,19 +145,120 @@ func federationDiscoveryHandler(ctx *gin.Context) {
 		JwksUri:          jwksUri,
 		BrokerEndpoint:   brokerUrl,
 	}
-
-	jsonData, err := json.MarshalIndent(rs, "", "  ")
+	document, err := discoveryDocument(rs)
 	if err != nil {
+		log.Error("Bad server configuration: ", err)
 		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
 			Status: server_structs.RespFailed,
-			Msg:    "Failed to marshal federation's discovery response",
+			Msg:    "Bad server configuration: invalid Director.DiscoveryExtraFields",
 		})
 		return
 	}
-	// Append a new line to the JSON data
-	jsonData = append(jsonData, '\n')
-	ctx.Header("Content-Disposition", "attachment; filename=pelican-configuration.json")
-	ctx.Data(http.StatusOK, "application/json", jsonData)
+
+	var data []byte
+	contentType := "application/json"
+	if ctx.NegotiateFormat(gin.MIMEJSON, discoveryJWTMIME) == discoveryJWTMIME {
+		contentType = discoveryJWTMIME
+		signed, err := signDiscoveryDocument(document, directorUrl.String(), time.Now())
+		if err != nil {
+			log.Error("Failed to sign the federation's discovery response: ", err)
+			ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Failed to sign federation's discovery response",
+			})
+			return
+		}
+		data = signed
+	} else {
+		jsonData, err := json.MarshalIndent(document, "", "  ")
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Failed to marshal federation's discovery response",
+			})
+			return
+		}
+		// Append a new line to the JSON data
+		data = append(jsonData, '\n')
+		ctx.Header("Content-Disposition", "attachment; filename=pelican-configuration.json")
+	}
+
+	ctx.Header("Vary", "Accept")
+	ctx.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(param.Director_DiscoveryMaxAge.GetDuration().Seconds())))
+	// The signed document changes with every issue time, so only the JSON can be revalidated
+	if contentType == "application/json" {
+		etag := fmt.Sprintf("\"%x\"", sha256.Sum256(data))
+		ctx.Header("ETag", etag)
+		if match := ctx.GetHeader("If-None-Match"); match != "" && (match == "*" || strings.Contains(match, etag)) {
+			ctx.Status(http.StatusNotModified)
+			return
+		}
+	}
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+// Parse Director.DiscoveryExtraFields, rejecting any field that would shadow one the
+// director generates
+func getDiscoveryExtraFields() (map[string]interface{}, error) {
+	extraFields := map[string]interface{}{}
+	if err := param.Director_DiscoveryExtraFields.Unmarshal(&extraFields); err != nil {
+		return nil, errors.Wrap(err, "failed to parse Director.DiscoveryExtraFields")
+	}
+	for key := range extraFields {
+		if reservedDiscoveryFields[key] {
+			return nil, errors.Errorf("Director.DiscoveryExtraFields may not set the reserved field %q", key)
+		}
+	}
+	return extraFields, nil
+}
+
+// Check Director.DiscoveryExtraFields at startup so a bad field fails the director's
+// launch instead of every discovery request
+func ConfigDiscoveryDocument() error {
+	_, err := getDiscoveryExtraFields()
+	return err
+}
+
+// Combine the generated discovery information with the federation's custom fields
+func discoveryDocument(fedInfo pelican_url.FederationDiscovery) (map[string]interface{}, error) {
+	document, err := getDiscoveryExtraFields()
+	if err != nil {
+		return nil, err
+	}
+	generated, err := json.Marshal(fedInfo)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(generated, &document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// Sign the discovery document as a JWT with the director's issuer key so clients can
+// verify it against the federation's jwks_uri and know when to stop trusting it
+func signDiscoveryDocument(document map[string]interface{}, issuer string, now time.Time) ([]byte, error) {
+	key, err := config.GetIssuerPrivateJWK()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the director's private key")
+	}
+	if err := jwk.AssignKeyID(key); err != nil {
+		return nil, errors.Wrap(err, "failed to assign kid to the discovery document")
+	}
+
+	builder := jwt.NewBuilder().
+		Issuer(issuer).
+		IssuedAt(now).
+		NotBefore(now).
+		Expiration(now.Add(param.Director_DiscoveryValidity.GetDuration()))
+	for field, value := range document {
+		builder.Claim(field, value)
+	}
+	tok, err := builder.Build()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build the discovery document")
+	}
+	return jwt.Sign(tok, jwt.WithKey(jwa.ES256, key))
 }
 
 func RegisterDirectorOIDCAPI(router *gin.RouterGroup) {
@@ -23,9 +23,12 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -162,6 +165,81 @@ func TestFederationDiscoveryHandler(t *testing.T) {
 	}
 }
 
+func TestFederationDiscoveryDocument(t *testing.T) {
+	router := gin.Default()
+	router.GET("/test", federationDiscoveryHandler)
+	t.Cleanup(server_utils.ResetTestState)
+
+	setup := func(t *testing.T, extraFields map[string]interface{}) {
+		server_utils.ResetTestState()
+		viper.Set("ConfigDir", t.TempDir())
+		viper.Set("IssuerKey", filepath.Join(t.TempDir(), "issuer.jwk"))
+		viper.Set("Federation.DirectorUrl", mockDirUrlWoPort)
+		viper.Set("Federation.RegistryUrl", mockRegUrlWoPort)
+		viper.Set("Director.DiscoveryExtraFields", extraFields)
+		viper.Set("Director.DiscoveryMaxAge", "10m")
+		viper.Set("Director.DiscoveryValidity", "1h")
+		config.InitConfig()
+		require.NoError(t, config.InitClient())
+	}
+	get := func(headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test", nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("extra-fields-and-caching", func(t *testing.T) {
+		setup(t, map[string]interface{}{"federation_name": "Example Federation"})
+
+		w := get(nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "public, max-age=600", w.Header().Get("Cache-Control"))
+		document := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &document))
+		assert.Equal(t, "Example Federation", document["federation_name"])
+		assert.Equal(t, mockDirUrlWoPort, document["director_endpoint"])
+
+		etag := w.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+		assert.Equal(t, http.StatusNotModified, get(map[string]string{"If-None-Match": etag}).Code)
+		assert.Equal(t, http.StatusOK, get(map[string]string{"If-None-Match": `"stale"`}).Code)
+	})
+
+	t.Run("reserved-extra-field", func(t *testing.T) {
+		setup(t, map[string]interface{}{"director_endpoint": "https://elsewhere.example.com"})
+		assert.Error(t, ConfigDiscoveryDocument())
+		assert.Equal(t, http.StatusInternalServerError, get(nil).Code)
+	})
+
+	t.Run("signed", func(t *testing.T) {
+		setup(t, map[string]interface{}{"federation_name": "Example Federation"})
+		_, err := config.GetIssuerPublicJWKS()
+		require.NoError(t, err)
+
+		w := get(map[string]string{"Accept": "application/jwt"})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/jwt", w.Header().Get("Content-Type"))
+		assert.Empty(t, w.Header().Get("ETag"))
+
+		jwks, err := config.GetIssuerPublicJWKS()
+		require.NoError(t, err)
+		tok, err := jwt.Parse(w.Body.Bytes(), jwt.WithKeySet(jwks))
+		require.NoError(t, err)
+		assert.Equal(t, mockDirUrlWoPort, tok.Issuer())
+		assert.WithinDuration(t, time.Now().Add(time.Hour), tok.Expiration(), time.Minute)
+		name, ok := tok.Get("federation_name")
+		require.True(t, ok)
+		assert.Equal(t, "Example Federation", name)
+		director, ok := tok.Get("director_endpoint")
+		require.True(t, ok)
+		assert.Equal(t, mockDirUrlWoPort, director)
+	})
+}
+
 func TestOidcDiscoveryHandler(t *testing.T) {
 	router := gin.Default()
 	server_utils.RegisterOIDCAPI(router.Group("/test"), true)
@@ -0,0 +1,295 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// The overall health of a server as shown on the federation dashboard
+	serverHealthState string
+
+	// The health of one origin or cache known to the director
+	ServerHealth struct {
+		Name         string            `json:"name"`
+		Type         string            `json:"type"`
+		URL          string            `json:"url"`
+		WebURL       string            `json:"webUrl"`
+		Version      string            `json:"version"`
+		FromTopology bool              `json:"fromTopology"`
+		State        serverHealthState `json:"state"`
+		// When the director last received the server's advertisement, and when the
+		// advertisement expires unless the server advertises again
+		LastAdvertised *time.Time `json:"lastAdvertised,omitempty"`
+		AdExpiresAt    *time.Time `json:"adExpiresAt,omitempty"`
+		// The result of the director's file transfer tests against the server
+		HealthStatus    HealthTestStatus `json:"healthStatus"`
+		LastHealthTest  *time.Time       `json:"lastHealthTest,omitempty"`
+		HealthTestError string           `json:"healthTestError,omitempty"`
+		Filtered        bool             `json:"filtered"`
+		FilteredType    string           `json:"filteredType"`
+		// The server's current downtime in the OSG Topology, if any
+		TopologyDowntime *topologyDowntime `json:"topologyDowntime,omitempty"`
+	}
+
+	// Counts of the servers in a dashboard payload
+	FederationHealthSummary struct {
+		Total   int                       `json:"total"`
+		Origins int                       `json:"origins"`
+		Caches  int                       `json:"caches"`
+		States  map[serverHealthState]int `json:"states"`
+		// The number of servers running each version
+		Versions map[string]int `json:"versions"`
+	}
+
+	FederationHealth struct {
+		Summary     FederationHealthSummary `json:"summary"`
+		Servers     []ServerHealth          `json:"servers"`
+		GeneratedAt time.Time               `json:"generatedAt"`
+	}
+
+	federationHealthRequest struct {
+		ServerType string   `form:"server_type"` // "cache" or "origin"
+		States     []string `form:"state"`       // Only return servers in one of these states
+		Name       string   `form:"name"`        // Only return servers whose name contains this, ignoring case
+		Version    string   `form:"version"`     // Only return servers running this version
+		Sort       string   `form:"sort"`        // The field to sort the servers by; defaults to the name
+		Order      string   `form:"order"`       // "asc" (the default) or "desc"
+	}
+)
+
+const (
+	serverHealthy   serverHealthState = "healthy"
+	serverUnhealthy serverHealthState = "unhealthy"
+	// The server is filtered or in a Topology downtime
+	serverInDowntime serverHealthState = "downtime"
+	// The director doesn't test the server, or no test has finished yet
+	serverHealthUnknown serverHealthState = "unknown"
+)
+
+var serverHealthStates = []serverHealthState{serverHealthy, serverUnhealthy, serverInDowntime, serverHealthUnknown}
+
+// The ways servers can be sorted in the dashboard payload
+var serverHealthSorts = map[string]func(a, b ServerHealth) int{
+	"name":    func(a, b ServerHealth) int { return strings.Compare(a.Name, b.Name) },
+	"type":    func(a, b ServerHealth) int { return strings.Compare(a.Type, b.Type) },
+	"state":   func(a, b ServerHealth) int { return strings.Compare(string(a.State), string(b.State)) },
+	"version": func(a, b ServerHealth) int { return strings.Compare(a.Version, b.Version) },
+	// Servers that never advertised (i.e. those from topology) sort first
+	"lastAdvertised": func(a, b ServerHealth) int {
+		return timePtrCompare(a.LastAdvertised, b.LastAdvertised)
+	},
+	"lastHealthTest": func(a, b ServerHealth) int {
+		return timePtrCompare(a.LastHealthTest, b.LastHealthTest)
+	},
+}
+
+func timePtrCompare(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return a.Compare(*b)
+}
+
+// Gather the health of every origin and cache the director knows about
+func collectServerHealth() []ServerHealth {
+	result := []ServerHealth{}
+	healthTestUtilsMutex.RLock()
+	defer healthTestUtilsMutex.RUnlock()
+	for _, item := range serverAds.Items() {
+		ad := item.Value()
+		if ad == nil || (ad.Type != server_structs.OriginType.String() && ad.Type != server_structs.CacheType.String()) {
+			continue
+		}
+		health := ServerHealth{
+			Name:         ad.Name,
+			Type:         ad.Type,
+			URL:          ad.URL.String(),
+			WebURL:       ad.WebURL.String(),
+			Version:      ad.Version,
+			FromTopology: ad.FromTopology,
+			HealthStatus: HealthStatusUnknown,
+		}
+		if !ad.FromTopology && item.TTL() > 0 {
+			expiresAt := item.ExpiresAt()
+			lastAdvertised := expiresAt.Add(-item.TTL())
+			health.AdExpiresAt = &expiresAt
+			health.LastAdvertised = &lastAdvertised
+		}
+		if util, ok := healthTestUtils[ad.URL.String()]; ok {
+			health.HealthStatus = util.Status
+			if !util.LastTest.IsZero() {
+				lastTest := util.LastTest
+				health.LastHealthTest = &lastTest
+			}
+			health.HealthTestError = util.LastError
+		} else if ad.DisableDirectorTest {
+			health.HealthStatus = HealthStatusDisabled
+		}
+		filtered, ft := checkFilter(ad.Name)
+		health.Filtered = filtered
+		health.FilteredType = ft.String()
+		if downtime, ok := getTopologyDowntime(ad.Name); ok {
+			health.TopologyDowntime = &downtime
+		}
+
+		switch {
+		case filtered || ft == topoUnscheduledFiltered:
+			health.State = serverInDowntime
+		case health.HealthStatus == HealthStatusOK:
+			health.State = serverHealthy
+		case health.HealthStatus == HealthStatusError:
+			health.State = serverUnhealthy
+		default:
+			health.State = serverHealthUnknown
+		}
+		result = append(result, health)
+	}
+	return result
+}
+
+// Filter and sort the servers' health and summarize the servers that remain
+func buildFederationHealth(servers []ServerHealth, queryParams federationHealthRequest) (FederationHealth, error) {
+	serverType := server_structs.ServerType(0)
+	if queryParams.ServerType != "" && (!serverType.SetString(queryParams.ServerType) ||
+		(serverType != server_structs.OriginType && serverType != server_structs.CacheType)) {
+		return FederationHealth{}, fmt.Errorf("invalid server type %q; it must be either 'cache' or 'origin'", queryParams.ServerType)
+	}
+	states := map[serverHealthState]bool{}
+	for _, state := range splitQueryValues(queryParams.States) {
+		if !slices.Contains(serverHealthStates, serverHealthState(state)) {
+			return FederationHealth{}, fmt.Errorf("unknown state %q; valid states are %s", state, joinHealthStates())
+		}
+		states[serverHealthState(state)] = true
+	}
+	sortBy := queryParams.Sort
+	if sortBy == "" {
+		sortBy = "name"
+	}
+	compare, ok := serverHealthSorts[sortBy]
+	if !ok {
+		return FederationHealth{}, fmt.Errorf("unknown sort field %q", queryParams.Sort)
+	}
+	descending := false
+	switch strings.ToLower(queryParams.Order) {
+	case "", "asc":
+	case "desc":
+		descending = true
+	default:
+		return FederationHealth{}, fmt.Errorf("invalid sort order %q; it must be either 'asc' or 'desc'", queryParams.Order)
+	}
+
+	result := FederationHealth{
+		Summary: FederationHealthSummary{
+			States:   map[serverHealthState]int{},
+			Versions: map[string]int{},
+		},
+		Servers:     []ServerHealth{},
+		GeneratedAt: time.Now(),
+	}
+	for _, state := range serverHealthStates {
+		result.Summary.States[state] = 0
+	}
+	for _, server := range servers {
+		if queryParams.ServerType != "" && server.Type != serverType.String() {
+			continue
+		}
+		if len(states) > 0 && !states[server.State] {
+			continue
+		}
+		if queryParams.Name != "" && !strings.Contains(strings.ToLower(server.Name), strings.ToLower(queryParams.Name)) {
+			continue
+		}
+		if queryParams.Version != "" && server.Version != queryParams.Version {
+			continue
+		}
+		result.Servers = append(result.Servers, server)
+
+		result.Summary.Total++
+		if server.Type == server_structs.OriginType.String() {
+			result.Summary.Origins++
+		} else {
+			result.Summary.Caches++
+		}
+		result.Summary.States[server.State]++
+		version := server.Version
+		if version == "" {
+			version = "unknown"
+		}
+		result.Summary.Versions[version]++
+	}
+	slices.SortStableFunc(result.Servers, func(a, b ServerHealth) int {
+		order := compare(a, b)
+		if order == 0 {
+			order = strings.Compare(a.Name, b.Name)
+		}
+		if order == 0 {
+			order = strings.Compare(a.URL, b.URL)
+		}
+		if descending {
+			return -order
+		}
+		return order
+	})
+	return result, nil
+}
+
+func joinHealthStates() string {
+	names := make([]string, 0, len(serverHealthStates))
+	for _, state := range serverHealthStates {
+		names = append(names, string(state))
+	}
+	return strings.Join(names, ", ")
+}
+
+// Report the health of the federation's origins and caches in a single payload
+// for the dashboard
+func federationHealthHandler(ctx *gin.Context) {
+	queryParams := federationHealthRequest{}
+	if ctx.ShouldBindQuery(&queryParams) != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid query parameters",
+		})
+		return
+	}
+	result, err := buildFederationHealth(collectServerHealth(), queryParams)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
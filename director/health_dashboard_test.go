@@ -0,0 +1,162 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestCollectServerHealth(t *testing.T) {
+	serverAds.DeleteAll()
+	t.Cleanup(func() {
+		serverAds.DeleteAll()
+		healthTestUtilsMutex.Lock()
+		healthTestUtils = make(map[string]*healthTestUtil)
+		healthTestUtilsMutex.Unlock()
+		filteredServersMutex.Lock()
+		filteredServers = map[string]filterType{}
+		filteredServersMutex.Unlock()
+	})
+
+	newAd := func(name string, sType server_structs.ServerType, fromTopology bool) *server_structs.Advertisement {
+		return &server_structs.Advertisement{
+			ServerAd: server_structs.ServerAd{
+				Name:         name,
+				Type:         sType.String(),
+				URL:          url.URL{Scheme: "https", Host: name + ".org:8443"},
+				Version:      "7.10.0",
+				FromTopology: fromTopology,
+			},
+		}
+	}
+	healthyOrigin := newAd("healthy-origin", server_structs.OriginType, false)
+	brokenCache := newAd("broken-cache", server_structs.CacheType, false)
+	downCache := newAd("down-cache", server_structs.CacheType, false)
+	topoCache := newAd("topo-cache", server_structs.CacheType, true)
+	for _, ad := range []*server_structs.Advertisement{healthyOrigin, brokenCache, downCache} {
+		serverAds.Set(ad.URL.String(), ad, 15*time.Minute)
+	}
+	serverAds.Set(topoCache.URL.String(), topoCache, ttlcache.DefaultTTL)
+
+	lastTest := time.Now().Add(-time.Minute)
+	healthTestUtilsMutex.Lock()
+	healthTestUtils[healthyOrigin.URL.String()] = &healthTestUtil{Status: HealthStatusOK, LastTest: lastTest}
+	healthTestUtils[brokenCache.URL.String()] = &healthTestUtil{Status: HealthStatusError, LastTest: lastTest, LastError: "connection refused"}
+	healthTestUtils[downCache.URL.String()] = &healthTestUtil{Status: HealthStatusOK, LastTest: lastTest}
+	healthTestUtilsMutex.Unlock()
+	filteredServersMutex.Lock()
+	filteredServers[downCache.Name] = tempFiltered
+	filteredServersMutex.Unlock()
+
+	health := map[string]ServerHealth{}
+	for _, server := range collectServerHealth() {
+		health[server.Name] = server
+	}
+	require.Len(t, health, 4)
+
+	assert.Equal(t, serverHealthy, health["healthy-origin"].State)
+	require.NotNil(t, health["healthy-origin"].LastAdvertised)
+	assert.WithinDuration(t, time.Now(), *health["healthy-origin"].LastAdvertised, time.Minute)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), *health["healthy-origin"].AdExpiresAt, time.Minute)
+	require.NotNil(t, health["healthy-origin"].LastHealthTest)
+	assert.True(t, lastTest.Equal(*health["healthy-origin"].LastHealthTest))
+
+	assert.Equal(t, serverUnhealthy, health["broken-cache"].State)
+	assert.Equal(t, "connection refused", health["broken-cache"].HealthTestError)
+
+	assert.Equal(t, serverInDowntime, health["down-cache"].State)
+	assert.True(t, health["down-cache"].Filtered)
+
+	assert.Equal(t, serverHealthUnknown, health["topo-cache"].State)
+	assert.Nil(t, health["topo-cache"].LastAdvertised)
+}
+
+func TestBuildFederationHealth(t *testing.T) {
+	older := time.Now().Add(-time.Hour)
+	newer := time.Now()
+	servers := []ServerHealth{
+		{Name: "b-cache", Type: "Cache", Version: "7.10.0", State: serverHealthy, LastAdvertised: &newer},
+		{Name: "a-origin", Type: "Origin", Version: "7.10.0", State: serverUnhealthy, LastAdvertised: &older},
+		{Name: "c-cache", Type: "Cache", Version: "7.9.0", State: serverInDowntime},
+		{Name: "d-cache", Type: "Cache", State: serverHealthUnknown},
+	}
+
+	t.Run("all-servers", func(t *testing.T) {
+		result, err := buildFederationHealth(servers, federationHealthRequest{})
+		require.NoError(t, err)
+		require.Len(t, result.Servers, 4)
+		assert.Equal(t, "a-origin", result.Servers[0].Name)
+		assert.Equal(t, "d-cache", result.Servers[3].Name)
+		assert.Equal(t, 4, result.Summary.Total)
+		assert.Equal(t, 1, result.Summary.Origins)
+		assert.Equal(t, 3, result.Summary.Caches)
+		assert.Equal(t, map[serverHealthState]int{serverHealthy: 1, serverUnhealthy: 1, serverInDowntime: 1, serverHealthUnknown: 1}, result.Summary.States)
+		assert.Equal(t, map[string]int{"7.10.0": 2, "7.9.0": 1, "unknown": 1}, result.Summary.Versions)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		result, err := buildFederationHealth(servers, federationHealthRequest{ServerType: "cache", States: []string{"healthy,downtime"}})
+		require.NoError(t, err)
+		require.Len(t, result.Servers, 2)
+		assert.Equal(t, "b-cache", result.Servers[0].Name)
+		assert.Equal(t, "c-cache", result.Servers[1].Name)
+		assert.Equal(t, 2, result.Summary.Total)
+		assert.Zero(t, result.Summary.States[serverUnhealthy])
+
+		result, err = buildFederationHealth(servers, federationHealthRequest{Name: "ORIGIN"})
+		require.NoError(t, err)
+		require.Len(t, result.Servers, 1)
+		assert.Equal(t, "a-origin", result.Servers[0].Name)
+
+		result, err = buildFederationHealth(servers, federationHealthRequest{Version: "7.9.0"})
+		require.NoError(t, err)
+		require.Len(t, result.Servers, 1)
+		assert.Equal(t, "c-cache", result.Servers[0].Name)
+	})
+
+	t.Run("sorting", func(t *testing.T) {
+		result, err := buildFederationHealth(servers, federationHealthRequest{Sort: "lastAdvertised", Order: "desc"})
+		require.NoError(t, err)
+		names := []string{}
+		for _, server := range result.Servers {
+			names = append(names, server.Name)
+		}
+		assert.Equal(t, []string{"b-cache", "a-origin", "d-cache", "c-cache"}, names)
+	})
+
+	t.Run("invalid-query", func(t *testing.T) {
+		for _, queryParams := range []federationHealthRequest{
+			{ServerType: "registry"},
+			{States: []string{"sleeping"}},
+			{Sort: "color"},
+			{Order: "sideways"},
+		} {
+			_, err := buildFederationHealth(servers, queryParams)
+			assert.Error(t, err, "query %+v should be rejected", queryParams)
+		}
+	})
+}
@@ -20,11 +20,16 @@ package director
 
 import (
 	"archive/tar"
+	"bufio"
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
@@ -37,107 +42,309 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 )
 
 const (
-	maxMindURL string = "https://download.maxmind.com/app/geoip_download?edition_id=GeoLite2-City&license_key=%s&suffix=tar.gz"
+	// How long to wait before retrying a failed refresh
+	geoIPRetryInterval = time.Hour
+	// How long a replaced reader is kept open so in-flight lookups can finish
+	geoIPCloseDelay = time.Minute
+	// Suffix of the copy of the previous database kept as a fallback
+	geoIPPrevSuffix = ".prev"
 )
 
 var (
 	maxMindReader atomic.Pointer[geoip2.Reader]
-)
 
-func downloadDB(localFile string) error {
-	err := os.MkdirAll(filepath.Dir(localFile), 0755)
-	if err != nil {
-		return err
-	}
+	// Overridden by the unit tests
+	maxMindURL string = "https://download.maxmind.com/app/geoip_download?edition_id=GeoLite2-City&license_key=%s&suffix=%s"
+)
 
-	var licenseKey string
+// Read the MaxMind license key from Director.MaxMindKeyFile or, failing that,
+// the MAXMINDKEY environment variable
+func getMaxMindLicenseKey() (string, error) {
 	keyFile := param.Director_MaxMindKeyFile.GetString()
 	keyFromEnv := viper.GetString("MAXMINDKEY")
 	if keyFile != "" {
 		contents, err := os.ReadFile(keyFile)
 		if err != nil {
-			return err
+			return "", err
 		}
-		licenseKey = strings.TrimSpace(string(contents))
+		return strings.TrimSpace(string(contents)), nil
 	} else if keyFromEnv != "" {
-		licenseKey = keyFromEnv
-	} else {
-		return errors.New("A MaxMind key file must be specified in the config (Director.MaxMindKeyFile), in the environment (PELICAN_DIRECTOR_MAXMINDKEYFILE), or the key must be provided via the environment variable PELICAN_MAXMINDKEY)")
+		return keyFromEnv, nil
 	}
+	return "", errors.New("A MaxMind key file must be specified in the config (Director.MaxMindKeyFile), in the environment (PELICAN_DIRECTOR_MAXMINDKEYFILE), or the key must be provided via the environment variable PELICAN_MAXMINDKEY)")
+}
 
-	url := fmt.Sprintf(maxMindURL, licenseKey)
-	localDir := filepath.Dir(localFile)
-	fileHandle, err := os.CreateTemp(localDir, filepath.Base(localFile)+".tmp")
+func maxMindGet(ctx context.Context, licenseKey, suffix string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(maxMindURL, licenseKey, suffix), nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer fileHandle.Close()
-	resp, err := http.Get(url)
+	client := http.Client{Transport: config.GetTransport()}
+	resp, err := client.Do(req)
 	if err != nil {
-		os.Remove(fileHandle.Name())
-		return err
+		// The URL contains the license key; don't leak it into the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, errors.Wrapf(err, "failed to download GeoIP database %s", suffix)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("failed to download GeoIP database %s: server returned %s", suffix, resp.Status)
+	}
+	return resp, nil
+}
+
+// Fetch the published SHA-256 checksum of the database archive.  MaxMind
+// serves it in the sha256sum format: "<hex digest>  <file name>"
+func fetchDBChecksum(ctx context.Context, licenseKey string) (string, error) {
+	resp, err := maxMindGet(ctx, licenseKey, "tar.gz.sha256")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return parseDBChecksum(io.LimitReader(resp.Body, 4096))
+}
+
+func parseDBChecksum(reader io.Reader) (string, error) {
+	scanner := bufio.NewScanner(reader)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", errors.Wrap(err, "failed to read GeoIP database checksum")
+		}
+		return "", errors.New("GeoIP database checksum is empty")
+	}
+	fields := strings.Fields(scanner.Text())
+	if len(fields) == 0 {
+		return "", errors.New("GeoIP database checksum is empty")
+	}
+	sum := strings.ToLower(fields[0])
+	if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != sha256.Size {
+		return "", errors.Errorf("GeoIP database checksum %q is not a valid SHA-256 digest", fields[0])
+	}
+	return sum, nil
+}
+
+// Download the database archive, extract the database into a temporary file
+// next to localFile and verify the archive against the published checksum.
+// Returns the name of the temporary file; the caller is responsible for
+// installing or removing it.
+func fetchDB(ctx context.Context, licenseKey, localFile string) (tmpName string, err error) {
+	expected, err := fetchDBChecksum(ctx, licenseKey)
+	if err != nil {
+		return
+	}
+
+	resp, err := maxMindGet(ctx, licenseKey, "tar.gz")
+	if err != nil {
+		return
 	}
 	defer resp.Body.Close()
 
-	gz, err := gzip.NewReader(resp.Body)
+	fileHandle, err := os.CreateTemp(filepath.Dir(localFile), filepath.Base(localFile)+".tmp")
 	if err != nil {
-		return err
+		return
+	}
+	defer func() {
+		fileHandle.Close()
+		if err != nil {
+			os.Remove(fileHandle.Name())
+		}
+	}()
+
+	hasher := sha256.New()
+	body := io.TeeReader(resp.Body, hasher)
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		err = errors.Wrap(err, "downloaded GeoIP database is not a gzip archive")
+		return
 	}
 	tr := tar.NewReader(gz)
 	foundDB := false
 	for {
-		hdr, err := tr.Next()
+		var hdr *tar.Header
+		hdr, err = tr.Next()
 		if err == io.EOF {
+			err = nil
 			break
 		} else if err != nil {
-			return err
+			err = errors.Wrap(err, "failed to read downloaded GeoIP database archive")
+			return
 		}
-		baseName := path.Base(hdr.Name)
-		if baseName != "GeoLite2-City.mmdb" {
+		if path.Base(hdr.Name) != "GeoLite2-City.mmdb" {
 			continue
 		}
 		if _, err = io.Copy(fileHandle, tr); err != nil {
-			os.Remove(fileHandle.Name())
-			return err
+			return
 		}
 		foundDB = true
 		break
 	}
+	// The checksum covers the whole archive, not just the bytes we extracted
+	if _, err = io.Copy(io.Discard, body); err != nil {
+		return
+	}
+	if actual := hex.EncodeToString(hasher.Sum(nil)); actual != expected {
+		err = errors.Errorf("GeoIP database checksum mismatch: expected %s, got %s", expected, actual)
+		return
+	}
 	if !foundDB {
-		return errors.New("GeoIP database not found in downloaded resource")
+		err = errors.New("GeoIP database not found in downloaded resource")
+		return
+	}
+	if err = fileHandle.Sync(); err != nil {
+		return
+	}
+	tmpName = fileHandle.Name()
+	return
+}
+
+// Open a database file and check that it is a usable GeoIP city database
+func openGeoIPDB(file string) (*geoip2.Reader, error) {
+	reader, err := geoip2.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	meta := reader.Metadata()
+	if !strings.Contains(meta.DatabaseType, "City") {
+		reader.Close()
+		return nil, errors.Errorf("GeoIP database %s has type %q; a City database is required", file, meta.DatabaseType)
+	}
+	if _, err = reader.City(net.ParseIP("8.8.8.8")); err != nil {
+		reader.Close()
+		return nil, errors.Wrapf(err, "GeoIP database %s failed a test lookup", file)
+	}
+	return reader, nil
+}
+
+// The age of the database according to its build timestamp
+func geoIPDBAge(reader *geoip2.Reader) time.Duration {
+	return time.Since(time.Unix(int64(reader.Metadata().BuildEpoch), 0))
+}
+
+// Download a fresh database, verify it and atomically move it into place at
+// localFile.  The database being replaced is kept as a fallback next to it.
+// The database on disk is left untouched if any step fails.
+func downloadDB(ctx context.Context, localFile string) (*geoip2.Reader, error) {
+	if err := os.MkdirAll(filepath.Dir(localFile), 0755); err != nil {
+		return nil, err
+	}
+	licenseKey, err := getMaxMindLicenseKey()
+	if err != nil {
+		return nil, err
+	}
+	tmpName, err := fetchDB(ctx, licenseKey, localFile)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := openGeoIPDB(tmpName)
+	if err != nil {
+		os.Remove(tmpName)
+		return nil, errors.Wrap(err, "downloaded GeoIP database failed validation")
+	}
+	// mmdb files are memory-mapped, so the rename doesn't disturb the open reader
+	prevFile := localFile + geoIPPrevSuffix
+	if _, err := os.Stat(localFile); err == nil {
+		os.Remove(prevFile)
+		if err := os.Link(localFile, prevFile); err != nil {
+			log.Debugln("Unable to keep a copy of the previous GeoIP database:", err)
+		}
+	}
+	if err = os.Rename(tmpName, localFile); err != nil {
+		reader.Close()
+		os.Remove(tmpName)
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Load the database from disk, falling back to the copy of the previous
+// database if the current one is missing or corrupt
+func loadGeoIPDB(localFile string) (*geoip2.Reader, error) {
+	reader, err := openGeoIPDB(localFile)
+	if err == nil {
+		return reader, nil
+	}
+	prevReader, prevErr := openGeoIPDB(localFile + geoIPPrevSuffix)
+	if prevErr != nil {
+		return nil, err
 	}
-	if err = os.Rename(fileHandle.Name(), localFile); err != nil {
+	log.Warningf("GeoIP database %s is unusable (%v); falling back to the previous database", localFile, err)
+	return prevReader, nil
+}
+
+// Make reader the active database.  The replaced reader is closed after a
+// delay to let in-flight lookups complete.
+func storeGeoIPReader(reader *geoip2.Reader) {
+	if old := maxMindReader.Swap(reader); old != nil && old != reader {
+		time.AfterFunc(geoIPCloseDelay, func() { old.Close() })
+	}
+	updateGeoIPAge()
+}
+
+// Publish the age of the active database and warn if it is stale
+func updateGeoIPAge() {
+	reader := maxMindReader.Load()
+	if reader == nil {
+		return
+	}
+	age := geoIPDBAge(reader)
+	metrics.PelicanDirectorGeoIPDBAge.Set(age.Seconds())
+	if maxAge := param.Director_GeoIPMaxAge.GetDuration(); maxAge > 0 && age > maxAge {
+		log.Warningf("GeoIP database is %s old, which exceeds Director.GeoIPMaxAge of %s; it will be used until a refresh succeeds",
+			age.Round(time.Hour), maxAge)
+	}
+}
+
+func refreshGeoIPDB(ctx context.Context) error {
+	reader, err := downloadDB(ctx, param.Director_GeoIPLocation.GetString())
+	if err != nil {
+		metrics.PelicanDirectorGeoIPDBRefreshes.WithLabelValues(string(metrics.MetricFailed)).Inc()
 		return err
 	}
+	metrics.PelicanDirectorGeoIPDBRefreshes.WithLabelValues(string(metrics.MetricSucceeded)).Inc()
+	metrics.PelicanDirectorGeoIPDBLastRefresh.SetToCurrentTime()
+	storeGeoIPReader(reader)
+	log.Infoln("Refreshed GeoIP database; build date", time.Unix(int64(reader.Metadata().BuildEpoch), 0).UTC().Format(time.RFC3339))
 	return nil
 }
 
-func periodicMaxMindReload(ctx context.Context) {
+func periodicMaxMindReload(ctx context.Context, next time.Duration) {
 	// The MaxMindDB updates Tuesday/Thursday. While a free API key
 	// does get 1000 downloads a month, we might still want to change
 	// this eventually to guarantee we only update on those days...
-
-	// Update once every other day
-	ticker := time.NewTicker(48 * time.Hour)
-	defer ticker.Stop()
+	interval := param.Director_GeoIPRefreshInterval.GetDuration()
+	if interval <= 0 {
+		log.Warningln("Director.GeoIPRefreshInterval must be positive; automatic GeoIP database refresh is disabled")
+		return
+	}
+	timer := time.NewTimer(next)
+	defer timer.Stop()
+	ageTicker := time.NewTicker(time.Hour)
+	defer ageTicker.Stop()
 	for {
 		select {
-		case <-ticker.C:
-			localFile := param.Director_GeoIPLocation.GetString()
-			if err := downloadDB(localFile); err != nil {
-				log.Warningln("Failed to download GeoIP database:", err)
+		case <-timer.C:
+			if _, err := getMaxMindLicenseKey(); err != nil {
+				log.Debugln("Skipping GeoIP database refresh:", err)
+				timer.Reset(interval)
+				continue
+			}
+			if err := refreshGeoIPDB(ctx); err != nil {
+				log.Warningln("Failed to refresh GeoIP database; keeping the current database:", err)
+				timer.Reset(min(geoIPRetryInterval, interval))
 			} else {
-				localReader, err := geoip2.Open(localFile)
-				if err != nil {
-					log.Warningln("Failed to re-open GeoIP database:", err)
-				} else {
-					maxMindReader.Store(localReader)
-				}
+				timer.Reset(interval)
 			}
+		case <-ageTicker.C:
+			updateGeoIPAge()
 		case <-ctx.Done():
 			return
 		}
@@ -145,21 +352,27 @@ func periodicMaxMindReload(ctx context.Context) {
 }
 
 func InitializeGeoIPDB(ctx context.Context) {
-	go periodicMaxMindReload(ctx)
 	localFile := param.Director_GeoIPLocation.GetString()
-	localReader, err := geoip2.Open(localFile)
+	next := param.Director_GeoIPRefreshInterval.GetDuration()
+	reader, err := loadGeoIPDB(localFile)
 	if err != nil {
-		log.Warningln("Local GeoIP database file not present; will attempt a download.", err)
-		err = downloadDB(localFile)
-		if err != nil {
-			log.Errorln("Failed to download GeoIP database!  Will not be available:", err)
-			return
+		log.Warningln("Local GeoIP database file not present or unusable; will attempt a download.", err)
+	} else {
+		storeGeoIPReader(reader)
+		if maxAge := param.Director_GeoIPMaxAge.GetDuration(); maxAge > 0 && geoIPDBAge(reader) > maxAge {
+			log.Warningln("Local GeoIP database is stale; will attempt a download")
+			err = errors.New("stale database")
 		}
-		localReader, err = geoip2.Open(localFile)
-		if err != nil {
-			log.Errorln("Failed to reopen GeoIP database!  Will not be available:", err)
-			return
+	}
+	if err != nil {
+		if err = refreshGeoIPDB(ctx); err != nil {
+			if maxMindReader.Load() == nil {
+				log.Errorln("Failed to download GeoIP database!  Will not be available until a refresh succeeds:", err)
+			} else {
+				log.Errorln("Failed to download GeoIP database; continuing with the stale local copy:", err)
+			}
+			next = geoIPRetryInterval
 		}
 	}
-	maxMindReader.Store(localReader)
+	go periodicMaxMindReload(ctx, next)
 }
@@ -0,0 +1,179 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeGeoIPArchive(t *testing.T, name string, contents []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "GeoLite2-City_20240101/" + name, Mode: 0644, Size: int64(len(contents))}))
+	_, err := tw.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// Serve archive at suffix=tar.gz and checksum at suffix=tar.gz.sha256
+func serveGeoIPArchive(t *testing.T, archive []byte, checksum string, status int) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("license_key") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		switch r.URL.Query().Get("suffix") {
+		case "tar.gz":
+			_, _ = w.Write(archive)
+		case "tar.gz.sha256":
+			_, _ = w.Write([]byte(checksum + "  GeoLite2-City_20240101.tar.gz\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	oldURL := maxMindURL
+	maxMindURL = srv.URL + "/app/geoip_download?edition_id=GeoLite2-City&license_key=%s&suffix=%s"
+	t.Cleanup(func() { maxMindURL = oldURL })
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestParseDBChecksum(t *testing.T) {
+	valid := strings.Repeat("ab", sha256.Size)
+	sum, err := parseDBChecksum(strings.NewReader(strings.ToUpper(valid) + "  GeoLite2-City.tar.gz\n"))
+	require.NoError(t, err)
+	assert.Equal(t, valid, sum)
+
+	for _, input := range []string{"", "\n", "not-a-digest  file.tar.gz", strings.Repeat("ab", 16)} {
+		_, err = parseDBChecksum(strings.NewReader(input))
+		assert.Error(t, err, "input %q should be rejected", input)
+	}
+}
+
+func TestFetchDB(t *testing.T) {
+	dbContents := []byte("fake mmdb contents")
+
+	t.Run("valid-archive", func(t *testing.T) {
+		archive := makeGeoIPArchive(t, "GeoLite2-City.mmdb", dbContents)
+		serveGeoIPArchive(t, archive, sha256Hex(archive), http.StatusOK)
+		localFile := filepath.Join(t.TempDir(), "GeoLite2-City.mmdb")
+
+		tmpName, err := fetchDB(context.Background(), "test-key", localFile)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Dir(localFile), filepath.Dir(tmpName))
+		contents, err := os.ReadFile(tmpName)
+		require.NoError(t, err)
+		assert.Equal(t, dbContents, contents)
+	})
+
+	t.Run("checksum-mismatch", func(t *testing.T) {
+		archive := makeGeoIPArchive(t, "GeoLite2-City.mmdb", dbContents)
+		serveGeoIPArchive(t, archive, sha256Hex([]byte("something else")), http.StatusOK)
+		dir := t.TempDir()
+
+		_, err := fetchDB(context.Background(), "test-key", filepath.Join(dir, "GeoLite2-City.mmdb"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "checksum mismatch")
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "temporary file should be removed")
+	})
+
+	t.Run("missing-db", func(t *testing.T) {
+		archive := makeGeoIPArchive(t, "README.txt", dbContents)
+		serveGeoIPArchive(t, archive, sha256Hex(archive), http.StatusOK)
+
+		_, err := fetchDB(context.Background(), "test-key", filepath.Join(t.TempDir(), "GeoLite2-City.mmdb"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+
+	t.Run("bad-license-key", func(t *testing.T) {
+		archive := makeGeoIPArchive(t, "GeoLite2-City.mmdb", dbContents)
+		serveGeoIPArchive(t, archive, sha256Hex(archive), http.StatusOK)
+
+		_, err := fetchDB(context.Background(), "wrong-key", filepath.Join(t.TempDir(), "GeoLite2-City.mmdb"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "401")
+		assert.NotContains(t, err.Error(), "wrong-key")
+	})
+}
+
+func TestDownloadDBKeepsExistingOnFailure(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("MAXMINDKEY", "test-key")
+
+	// A checksum-valid archive whose database is corrupt must not replace
+	// the existing file
+	archive := makeGeoIPArchive(t, "GeoLite2-City.mmdb", []byte("corrupt database"))
+	serveGeoIPArchive(t, archive, sha256Hex(archive), http.StatusOK)
+
+	dir := t.TempDir()
+	localFile := filepath.Join(dir, "GeoLite2-City.mmdb")
+	require.NoError(t, os.WriteFile(localFile, []byte("existing database"), 0644))
+
+	_, err := downloadDB(context.Background(), localFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed validation")
+
+	contents, err := os.ReadFile(localFile)
+	require.NoError(t, err)
+	assert.Equal(t, "existing database", string(contents))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, fmt.Sprintf("unexpected files left behind: %v", entries))
+}
+
+func TestLoadGeoIPDBMissing(t *testing.T) {
+	dir := t.TempDir()
+	localFile := filepath.Join(dir, "GeoLite2-City.mmdb")
+	require.NoError(t, os.WriteFile(localFile, []byte("corrupt"), 0644))
+	require.NoError(t, os.WriteFile(localFile+geoIPPrevSuffix, []byte("also corrupt"), 0644))
+
+	_, err := loadGeoIPDB(localFile)
+	assert.Error(t, err)
+}
@@ -184,6 +184,7 @@ func LaunchPeriodicDirectorTest(ctx context.Context, serverAd server_structs.Ser
 			} else if serverAd.Type == server_structs.CacheType.String() {
 				err = runCacheTest(ctx, serverAd.URL)
 			}
+			recordTransferSLO(serverAd.URL.String(), ok && err == nil)
 
 			// Successfully run a test, no error
 			if ok && err == nil {
@@ -193,6 +194,8 @@ func LaunchPeriodicDirectorTest(ctx context.Context, serverAd server_structs.Ser
 					defer healthTestUtilsMutex.Unlock()
 					if existingUtil, ok := healthTestUtils[serverAd.URL.String()]; ok {
 						existingUtil.Status = HealthStatusOK
+						existingUtil.LastTest = time.Now()
+						existingUtil.LastError = ""
 					} else {
 						log.Debugln("HealthTestUtil missing for ", serverAd.Type, " server: ", serverUrl, " Failed to update internal status")
 					}
@@ -272,6 +275,12 @@ func LaunchPeriodicDirectorTest(ctx context.Context, serverAd server_structs.Ser
 					defer healthTestUtilsMutex.Unlock()
 					if existingUtil, ok := healthTestUtils[serverAd.URL.String()]; ok {
 						existingUtil.Status = HealthStatusError
+						existingUtil.LastTest = time.Now()
+						if err != nil {
+							existingUtil.LastError = err.Error()
+						} else {
+							existingUtil.LastError = "the file transfer test failed"
+						}
 					} else {
 						log.Debugln("HealthTestUtil missing for", serverAd.Type, " server: ", serverUrl, " Failed to update internal status")
 					}
@@ -0,0 +1,127 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// A renamed namespace, as read from Director.NamespaceAliases
+	NamespaceAliasConfig struct {
+		Alias     string `mapstructure:"Alias"`
+		Namespace string `mapstructure:"Namespace"`
+	}
+
+	namespaceAlias struct {
+		alias     string
+		namespace string
+	}
+)
+
+var (
+	// Sorted by decreasing length of the alias, so the most specific one matches first
+	namespaceAliases      []namespaceAlias
+	namespaceAliasesMutex sync.RWMutex
+)
+
+// Populate the namespace aliases from the Director.NamespaceAliases parameter
+func ConfigNamespaceAliases() error {
+	var configs []NamespaceAliasConfig
+	if err := param.Director_NamespaceAliases.Unmarshal(&configs); err != nil {
+		return errors.Wrap(err, "failed to parse Director.NamespaceAliases")
+	}
+
+	aliases := make([]namespaceAlias, 0, len(configs))
+	for _, cfg := range configs {
+		if cfg.Alias == "" || cfg.Namespace == "" {
+			return errors.New("each entry in Director.NamespaceAliases needs both an Alias and a Namespace")
+		}
+		alias := path.Clean("/" + cfg.Alias)
+		ns := path.Clean("/" + cfg.Namespace)
+		if alias == "/" || ns == "/" {
+			return errors.New("the root namespace can't be aliased or be an alias target in Director.NamespaceAliases")
+		}
+		aliases = append(aliases, namespaceAlias{alias: alias, namespace: ns})
+	}
+	// An alias resolving to another alias would make the target of a path depend
+	// on the order in which they're applied
+	for _, a := range aliases {
+		for _, b := range aliases {
+			if a.alias == b.alias && a.namespace != b.namespace {
+				return errors.Errorf("the alias %s is given more than one namespace in Director.NamespaceAliases", a.alias)
+			}
+			if pathInPrefix(a.namespace, b.alias) {
+				return errors.Errorf("the alias %s of Director.NamespaceAliases resolves to %s, which is itself under the alias %s", a.alias, a.namespace, b.alias)
+			}
+		}
+	}
+	sort.SliceStable(aliases, func(i, j int) bool { return len(aliases[i].alias) > len(aliases[j].alias) })
+	for _, a := range aliases {
+		log.Infof("Requests for the namespace alias %s will be served from %s", a.alias, a.namespace)
+	}
+
+	namespaceAliasesMutex.Lock()
+	defer namespaceAliasesMutex.Unlock()
+	namespaceAliases = aliases
+	return nil
+}
+
+// Whether the object path is the prefix or under it
+func pathInPrefix(objectPath, prefix string) bool {
+	return objectPath == prefix || strings.HasPrefix(objectPath, prefix+"/")
+}
+
+// Rewrite an object path under a renamed namespace to its new location.  Returns
+// whether an alias matched and which one.
+func resolveNamespaceAlias(objectPath string) (resolved string, alias namespaceAlias, ok bool) {
+	namespaceAliasesMutex.RLock()
+	defer namespaceAliasesMutex.RUnlock()
+	for _, a := range namespaceAliases {
+		if pathInPrefix(objectPath, a.alias) {
+			return a.namespace + strings.TrimPrefix(objectPath, a.alias), a, true
+		}
+	}
+	return objectPath, namespaceAlias{}, false
+}
+
+// Serve a request for an object under a renamed namespace from its new location,
+// telling the client the old path is deprecated
+func applyNamespaceAlias(ginCtx *gin.Context, objectPath string) string {
+	resolved, alias, ok := resolveNamespaceAlias(objectPath)
+	if !ok {
+		return objectPath
+	}
+	log.Debugf("Rewriting the request for %s under the namespace alias %s to %s", objectPath, alias.alias, resolved)
+	ginCtx.Header("Deprecation", "true")
+	ginCtx.Header(server_structs.NamespaceAliasHeader, "alias="+alias.alias+", namespace="+alias.namespace)
+	metrics.PelicanDirectorNamespaceAliasRequestsTotal.WithLabelValues(alias.alias).Inc()
+	return resolved
+}
@@ -0,0 +1,108 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func setupNamespaceAliases(t *testing.T, aliasConfig []map[string]any) error {
+	server_utils.ResetTestState()
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		namespaceAliasesMutex.Lock()
+		namespaceAliases = nil
+		namespaceAliasesMutex.Unlock()
+	})
+	viper.Set("Director.NamespaceAliases", aliasConfig)
+	return ConfigNamespaceAliases()
+}
+
+func TestConfigNamespaceAliases(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config []map[string]any
+		errMsg string
+	}{
+		{name: "valid", config: []map[string]any{{"Alias": "/old/prefix/", "Namespace": "/new/prefix"}, {"Alias": "/old", "Namespace": "/other"}}},
+		{name: "missing-namespace", config: []map[string]any{{"Alias": "/old"}}, errMsg: "both an Alias and a Namespace"},
+		{name: "root", config: []map[string]any{{"Alias": "/", "Namespace": "/new"}}, errMsg: "root namespace"},
+		{name: "conflicting", config: []map[string]any{{"Alias": "/old", "Namespace": "/new"}, {"Alias": "/old/", "Namespace": "/other"}}, errMsg: "more than one namespace"},
+		{name: "chained", config: []map[string]any{{"Alias": "/a", "Namespace": "/b/data"}, {"Alias": "/b", "Namespace": "/c"}}, errMsg: "itself under the alias /b"},
+		{name: "self", config: []map[string]any{{"Alias": "/a", "Namespace": "/a"}}, errMsg: "itself under the alias /a"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := setupNamespaceAliases(t, tc.config)
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errMsg)
+			}
+		})
+	}
+}
+
+func TestResolveNamespaceAlias(t *testing.T) {
+	require.NoError(t, setupNamespaceAliases(t, []map[string]any{
+		{"Alias": "/old", "Namespace": "/other"},
+		{"Alias": "/old/prefix", "Namespace": "/new/prefix"},
+	}))
+
+	resolve := func(objectPath string) string {
+		resolved, _, _ := resolveNamespaceAlias(objectPath)
+		return resolved
+	}
+	// The longest alias wins
+	assert.Equal(t, "/new/prefix/dir/file", resolve("/old/prefix/dir/file"))
+	assert.Equal(t, "/new/prefix", resolve("/old/prefix"))
+	assert.Equal(t, "/other/prefixes/file", resolve("/old/prefixes/file"))
+	assert.Equal(t, "/oldies/file", resolve("/oldies/file"))
+
+	router := gin.New()
+	router.GET("/object/*path", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, applyNamespaceAlias(ctx, ctx.Param("path")))
+	})
+
+	t.Run("aliased", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/object/old/prefix/file", nil))
+		assert.Equal(t, "/new/prefix/file", w.Body.String())
+		assert.Equal(t, "true", w.Header().Get("Deprecation"))
+		assert.Equal(t, "alias=/old/prefix, namespace=/new/prefix", w.Header().Get(server_structs.NamespaceAliasHeader))
+	})
+
+	t.Run("not-aliased", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/object/new/prefix/file", nil))
+		assert.Equal(t, "/new/prefix/file", w.Body.String())
+		assert.Empty(t, w.Header().Get("Deprecation"))
+		assert.Empty(t, w.Header().Get(server_structs.NamespaceAliasHeader))
+	})
+}
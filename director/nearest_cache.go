@@ -0,0 +1,294 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/utils"
+)
+
+type (
+	// The nearest cache to a client, for tools that can't follow the director's redirects
+	nearestCacheResponse struct {
+		Name     string `json:"name"`
+		Hostname string `json:"hostname"`
+		// The hostname as the target of a CNAME record, i.e., fully qualified
+		CName string `json:"cname"`
+		URL   string `json:"url"`
+		// How long the answer may be cached, in seconds
+		TTL int `json:"ttl"`
+		// The hostnames of the next nearest caches, in order
+		Alternates []string `json:"alternates,omitempty"`
+	}
+
+	// Answers DNS queries for one hostname with a CNAME record to the nearest cache
+	dnsResponder struct {
+		ctx      context.Context
+		hostname string // Fully qualified and lowercase
+		ttl      uint32
+	}
+)
+
+const (
+	// The alternates listed by the nearest cache API
+	nearestCacheAlternates = 3
+)
+
+// Get the caches serving the path, or all the caches if the path is empty, nearest to the
+// client first.  The second return value is false if no namespace matches the path.
+func getNearestCaches(ctx context.Context, clientAddr netip.Addr, reqPath string) ([]server_structs.ServerAd, bool, error) {
+	var cacheAds []server_structs.ServerAd
+	if reqPath != "" {
+		var namespaceAd server_structs.NamespaceAdV2
+		namespaceAd, _, cacheAds = getAdsForPath(reqPath)
+		if namespaceAd.Path == "" {
+			return nil, false, nil
+		}
+	} else {
+		for _, ad := range listAdvertisement([]server_structs.ServerType{server_structs.CacheType}) {
+			if filtered, _ := checkFilter(ad.Name); filtered {
+				continue
+			}
+			cacheAds = append(cacheAds, ad.ServerAd)
+		}
+	}
+	if len(cacheAds) == 0 {
+		return nil, true, nil
+	}
+	sorted, err := sortServerAds(ctx, clientAddr, cacheAds, nil)
+	return sorted, true, err
+}
+
+// Report the cache nearest to the client, or to the address in the "ip" query parameter
+// for resolvers asking on behalf of a client.  With the "path" query parameter, only the
+// caches serving the namespace of the path are considered.
+func nearestCacheHandler(ginCtx *gin.Context) {
+	clientAddr := utils.ClientIPAddr(ginCtx)
+	if ipStr := ginCtx.Query("ip"); ipStr != "" {
+		addr, err := netip.ParseAddr(ipStr)
+		if err != nil {
+			ginCtx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Invalid IP address " + ipStr,
+			})
+			return
+		}
+		clientAddr = addr
+	}
+	reqPath := ginCtx.Query("path")
+	if reqPath != "" {
+		reqPath = path.Clean("/" + reqPath)
+	}
+
+	cacheAds, found, err := getNearestCaches(ginCtx.Request.Context(), clientAddr, reqPath)
+	if !found {
+		ginCtx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "No namespace found for path " + reqPath,
+		})
+		return
+	} else if err != nil {
+		log.Errorln("Failed to sort the caches for the nearest cache API:", err)
+		ginCtx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to find the nearest cache",
+		})
+		return
+	} else if len(cacheAds) == 0 {
+		ginCtx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "No cache is available",
+		})
+		return
+	}
+
+	nearest := cacheAds[0]
+	resp := nearestCacheResponse{
+		Name:     nearest.Name,
+		Hostname: nearest.URL.Hostname(),
+		CName:    dns.Fqdn(nearest.URL.Hostname()),
+		URL:      nearest.URL.String(),
+		TTL:      int(param.Director_DNSTTL.GetDuration().Seconds()),
+	}
+	for _, ad := range cacheAds[1:min(len(cacheAds), nearestCacheAlternates+1)] {
+		resp.Alternates = append(resp.Alternates, ad.URL.Hostname())
+	}
+	ginCtx.JSON(http.StatusOK, resp)
+}
+
+func newDNSResponder(ctx context.Context, hostname string, ttl time.Duration) *dnsResponder {
+	return &dnsResponder{
+		ctx:      ctx,
+		hostname: strings.ToLower(dns.Fqdn(hostname)),
+		ttl:      uint32(ttl.Seconds()),
+	}
+}
+
+// The address of the client a query is made for: the EDNS Client Subnet the resolver
+// sent, if any, or else the address of the resolver itself
+func dnsClientAddr(w dns.ResponseWriter, r *dns.Msg) (netip.Addr, *dns.EDNS0_SUBNET) {
+	if opt := r.IsEdns0(); opt != nil {
+		for _, option := range opt.Option {
+			if subnet, ok := option.(*dns.EDNS0_SUBNET); ok {
+				if addr, ok := netip.AddrFromSlice(subnet.Address); ok {
+					return addr.Unmap(), subnet
+				}
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(w.RemoteAddr().String())
+	if err != nil {
+		return netip.Addr{}, nil
+	}
+	addr, _ := netip.ParseAddr(host)
+	return addr.Unmap(), nil
+}
+
+// The records answering a query of the type for the cache's hostname: a CNAME record or,
+// if the cache is only known by its IP address, an A or AAAA record
+func (d *dnsResponder) answer(qtype uint16, cacheHost string) []dns.RR {
+	header := dns.RR_Header{Name: d.hostname, Class: dns.ClassINET, Ttl: d.ttl}
+	addr, err := netip.ParseAddr(cacheHost)
+	if err != nil {
+		header.Rrtype = dns.TypeCNAME
+		return []dns.RR{&dns.CNAME{Hdr: header, Target: dns.Fqdn(cacheHost)}}
+	}
+	if addr.Is4() && (qtype == dns.TypeA || qtype == dns.TypeANY) {
+		header.Rrtype = dns.TypeA
+		return []dns.RR{&dns.A{Hdr: header, A: addr.AsSlice()}}
+	} else if addr.Is6() && (qtype == dns.TypeAAAA || qtype == dns.TypeANY) {
+		header.Rrtype = dns.TypeAAAA
+		return []dns.RR{&dns.AAAA{Hdr: header, AAAA: addr.AsSlice()}}
+	}
+	return nil
+}
+
+func (d *dnsResponder) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	defer func() {
+		metrics.PelicanDirectorDNSQueriesTotal.WithLabelValues(dns.RcodeToString[m.Rcode]).Inc()
+		if err := w.WriteMsg(m); err != nil {
+			log.Debugln("Failed to answer a DNS query:", err)
+		}
+	}()
+
+	if r.Opcode != dns.OpcodeQuery || len(r.Question) != 1 {
+		m.Rcode = dns.RcodeFormatError
+		return
+	}
+	question := r.Question[0]
+	name := strings.ToLower(question.Name)
+	if question.Qclass != dns.ClassINET || !dns.IsSubDomain(d.hostname, name) {
+		m.Authoritative = false
+		m.Rcode = dns.RcodeRefused
+		return
+	} else if name != d.hostname {
+		m.Rcode = dns.RcodeNameError
+		return
+	}
+
+	clientAddr, subnet := dnsClientAddr(w, r)
+	cacheAds, _, err := getNearestCaches(d.ctx, clientAddr, "")
+	if err != nil || len(cacheAds) == 0 {
+		if err != nil {
+			log.Errorln("Failed to sort the caches for a DNS query:", err)
+		}
+		m.Rcode = dns.RcodeServerFailure
+		return
+	}
+	m.Answer = d.answer(question.Qtype, cacheAds[0].URL.Hostname())
+	if subnet != nil {
+		// The answer holds for the whole subnet the resolver sent
+		scope := *subnet
+		scope.SourceScope = subnet.SourceNetmask
+		m.SetEdns0(dns.DefaultMsgSize, false)
+		m.IsEdns0().Option = append(m.IsEdns0().Option, &scope)
+	}
+	log.Debugf("Answering the DNS query for %s from %s with %s", name, clientAddr.String(), cacheAds[0].URL.Hostname())
+}
+
+// Launch the DNS responder for Director.DNSHostname if Director.EnableDNS is set
+func LaunchDNSResponder(ctx context.Context, egrp *errgroup.Group) error {
+	if !param.Director_EnableDNS.GetBool() {
+		return nil
+	}
+	hostname := param.Director_DNSHostname.GetString()
+	if hostname == "" {
+		return errors.Errorf("%s must be set when %s is enabled", param.Director_DNSHostname.GetName(), param.Director_EnableDNS.GetName())
+	}
+	if _, ok := dns.IsDomainName(hostname); !ok {
+		return errors.Errorf("invalid hostname %q for %s", hostname, param.Director_DNSHostname.GetName())
+	}
+	responder := newDNSResponder(ctx, hostname, param.Director_DNSTTL.GetDuration())
+	addr := net.JoinHostPort("", strconv.Itoa(param.Director_DNSPort.GetInt()))
+
+	servers := []*dns.Server{}
+	for _, network := range []string{"udp", "tcp"} {
+		server := &dns.Server{Addr: addr, Net: network, Handler: responder}
+		started := make(chan error, 1)
+		server.NotifyStartedFunc = func() { started <- nil }
+		go func() {
+			if err := server.ListenAndServe(); err != nil {
+				select {
+				case started <- err:
+				default:
+					log.Errorf("The director's DNS responder on %s/%s stopped: %v", addr, server.Net, err)
+				}
+			}
+		}()
+		if err := <-started; err != nil {
+			for _, running := range servers {
+				_ = running.Shutdown()
+			}
+			return errors.Wrapf(err, "failed to start the director's DNS responder on %s/%s", addr, network)
+		}
+		servers = append(servers, server)
+	}
+	log.Infof("Answering DNS queries for %s on port %d with the nearest cache", responder.hostname, param.Director_DNSPort.GetInt())
+
+	egrp.Go(func() error {
+		<-ctx.Done()
+		for _, server := range servers {
+			if err := server.Shutdown(); err != nil {
+				log.Warningln("Failed to shut down the director's DNS responder:", err)
+			}
+		}
+		return nil
+	})
+	return nil
+}
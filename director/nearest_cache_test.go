@@ -0,0 +1,215 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/miekg/dns"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+// Two caches in Madison and San Diego, with clients near each of them
+func setupNearestCacheAds(t *testing.T) {
+	server_utils.ResetTestState()
+	geoNetOverrides = nil
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		geoNetOverrides = nil
+		serverAds.DeleteAll()
+	})
+	viper.Set("Director.CacheSortMethod", "distance")
+	viper.Set("Director.DNSTTL", "30s")
+	viper.Set("GeoIPOverrides", []map[string]interface{}{
+		{"IP": "10.1.0.0/16", "Coordinate": map[string]float64{"lat": 43.07, "long": -89.4}},
+		{"IP": "10.2.0.0/16", "Coordinate": map[string]float64{"lat": 32.72, "long": -117.16}},
+	})
+
+	serverAds.DeleteAll()
+	for _, cache := range []struct {
+		name      string
+		host      string
+		lat, long float64
+		prefix    string
+	}{
+		{"MADISON_CACHE", "cache.madison.example.org:8443", 43.07, -89.4, "/madison"},
+		{"SAN_DIEGO_CACHE", "cache.sandiego.example.org:8443", 32.72, -117.16, "/sandiego"},
+	} {
+		cacheUrl := url.URL{Scheme: "https", Host: cache.host}
+		serverAds.Set(cacheUrl.String(), &server_structs.Advertisement{
+			ServerAd: server_structs.ServerAd{
+				Name:      cache.name,
+				URL:       cacheUrl,
+				Type:      server_structs.CacheType.String(),
+				Latitude:  cache.lat,
+				Longitude: cache.long,
+			},
+			NamespaceAds: []server_structs.NamespaceAdV2{{Path: cache.prefix}},
+		}, ttlcache.DefaultTTL)
+	}
+}
+
+func TestNearestCacheHandler(t *testing.T) {
+	setupNearestCacheAds(t)
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/nearestCache", nearestCacheHandler)
+
+	get := func(query string) (int, nearestCacheResponse) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/nearestCache?"+query, nil)
+		engine.ServeHTTP(w, req)
+		resp := nearestCacheResponse{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return w.Code, resp
+	}
+
+	t.Run("nearest-to-client", func(t *testing.T) {
+		code, resp := get("ip=10.2.3.4")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "SAN_DIEGO_CACHE", resp.Name)
+		assert.Equal(t, "cache.sandiego.example.org", resp.Hostname)
+		assert.Equal(t, "cache.sandiego.example.org.", resp.CName)
+		assert.Equal(t, "https://cache.sandiego.example.org:8443", resp.URL)
+		assert.Equal(t, 30, resp.TTL)
+		assert.Equal(t, []string{"cache.madison.example.org"}, resp.Alternates)
+
+		_, resp = get("ip=10.1.3.4")
+		assert.Equal(t, "MADISON_CACHE", resp.Name)
+	})
+
+	t.Run("caches-of-namespace", func(t *testing.T) {
+		code, resp := get("ip=10.2.3.4&path=/madison/data.txt")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "MADISON_CACHE", resp.Name)
+		assert.Empty(t, resp.Alternates)
+
+		code, _ = get("path=/unknown/data.txt")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("invalid-ip", func(t *testing.T) {
+		code, _ := get("ip=not-an-ip")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestDNSResponder(t *testing.T) {
+	setupNearestCacheAds(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn:        conn,
+		Handler:           newDNSResponder(ctx, "Nearest-Cache.Example.org", 30*time.Second),
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	<-started
+
+	query := func(name string, qtype uint16, clientSubnet string) *dns.Msg {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(name), qtype)
+		if clientSubnet != "" {
+			_, subnet, err := net.ParseCIDR(clientSubnet)
+			require.NoError(t, err)
+			ones, _ := subnet.Mask.Size()
+			msg.SetEdns0(dns.DefaultMsgSize, false)
+			msg.IsEdns0().Option = append(msg.IsEdns0().Option, &dns.EDNS0_SUBNET{
+				Code:          dns.EDNS0SUBNET,
+				Family:        1,
+				SourceNetmask: uint8(ones),
+				Address:       subnet.IP.To4(),
+			})
+		}
+		resp, err := dns.Exchange(msg, conn.LocalAddr().String())
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("cname-to-nearest-cache", func(t *testing.T) {
+		resp := query("nearest-cache.example.org", dns.TypeA, "10.2.3.0/24")
+		require.Equal(t, dns.RcodeSuccess, resp.Rcode)
+		assert.True(t, resp.Authoritative)
+		require.Len(t, resp.Answer, 1)
+		cname, ok := resp.Answer[0].(*dns.CNAME)
+		require.True(t, ok)
+		assert.Equal(t, "cache.sandiego.example.org.", cname.Target)
+		assert.EqualValues(t, 30, cname.Hdr.Ttl)
+
+		// The answer is scoped to the subnet of the client
+		require.NotNil(t, resp.IsEdns0())
+		require.Len(t, resp.IsEdns0().Option, 1)
+		subnet, ok := resp.IsEdns0().Option[0].(*dns.EDNS0_SUBNET)
+		require.True(t, ok)
+		assert.EqualValues(t, 24, subnet.SourceScope)
+
+		resp = query("NEAREST-CACHE.example.org", dns.TypeAAAA, "10.1.3.0/24")
+		require.Len(t, resp.Answer, 1)
+		assert.Equal(t, "cache.madison.example.org.", resp.Answer[0].(*dns.CNAME).Target)
+	})
+
+	t.Run("resolver-address-without-subnet", func(t *testing.T) {
+		resp := query("nearest-cache.example.org", dns.TypeCNAME, "")
+		require.Equal(t, dns.RcodeSuccess, resp.Rcode)
+		require.Len(t, resp.Answer, 1)
+		assert.IsType(t, &dns.CNAME{}, resp.Answer[0])
+	})
+
+	t.Run("other-names", func(t *testing.T) {
+		assert.Equal(t, dns.RcodeNameError, query("sub.nearest-cache.example.org", dns.TypeA, "").Rcode)
+		assert.Equal(t, dns.RcodeRefused, query("www.example.org", dns.TypeA, "").Rcode)
+	})
+
+	t.Run("no-caches", func(t *testing.T) {
+		serverAds.DeleteAll()
+		assert.Equal(t, dns.RcodeServerFailure, query("nearest-cache.example.org", dns.TypeA, "").Rcode)
+	})
+}
+
+func TestDNSResponderAnswer(t *testing.T) {
+	responder := newDNSResponder(context.Background(), "nearest-cache.example.org", time.Minute)
+	answer := responder.answer(dns.TypeA, "192.0.2.10")
+	require.Len(t, answer, 1)
+	assert.Equal(t, "192.0.2.10", answer[0].(*dns.A).A.String())
+	assert.Empty(t, responder.answer(dns.TypeAAAA, "192.0.2.10"))
+	answer = responder.answer(dns.TypeANY, "2001:db8::10")
+	require.Len(t, answer, 1)
+	assert.Equal(t, "2001:db8::10", answer[0].(*dns.AAAA).AAAA.String())
+}
@@ -26,6 +26,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -49,59 +50,89 @@ var (
 	// TTL cache is thread-safe
 	namespaceKeys = ttlcache.New(ttlcache.WithTTL[string, jwk.Set](15 * time.Minute))
 
+	// namespacePolicies caches the policies the registry sent with the approval status of
+	// each namespace, keyed by the namespace prefix
+	namespacePolicies = ttlcache.New(ttlcache.WithTTL[string, server_structs.NamespacePolicy](15 * time.Minute))
+
 	adminApprovalErr error
 )
 
-func checkNamespaceStatus(prefix string, registryWebUrlStr string) (bool, error) {
+func checkNamespaceStatus(prefix string, registryWebUrlStr string) (server_structs.CheckNamespaceStatusRes, error) {
+	resBody := server_structs.CheckNamespaceStatusRes{}
 	registryUrl, err := url.Parse(registryWebUrlStr)
 	if err != nil {
-		return false, err
+		return resBody, err
 	}
 	reqUrl := registryUrl.JoinPath("/api/v1.0/registry/checkNamespaceStatus")
 
 	reqBody := server_structs.CheckNamespaceStatusReq{Prefix: prefix}
 	reqByte, err := json.Marshal(reqBody)
 	if err != nil {
-		return false, err
+		return resBody, err
 	}
 	client := http.Client{Transport: config.GetTransport()}
 	req, err := http.NewRequest(http.MethodPost, reqUrl.String(), bytes.NewBuffer(reqByte))
 	req.Header.Add("Content-Type", "application/json")
 	if err != nil {
-		return false, err
+		return resBody, err
 	}
 
 	res, err := client.Do(req)
 	if err != nil {
-		return false, err
+		return resBody, err
 	}
 
 	if res.StatusCode != 200 {
 		if res.StatusCode == 404 {
 			// This is when we hit a legacy OSDF registry (or Pelican registry <= 7.4.0) which doesn't have such endpoint
 			log.Warningf("Request %q hit 404, either it's an OSDF registry or Pelican registry <= 7.4.0. Fallback to return true for approval status check", reqUrl.String())
-			return true, nil
+			resBody.Approved = true
+			return resBody, nil
 		} else {
 			body, err := io.ReadAll(res.Body)
 			if err != nil {
-				return false, errors.New(fmt.Sprintf("Registry returns error when checkNamespaceStatus %d and can't get the response body %v", res.StatusCode, err))
+				return resBody, errors.New(fmt.Sprintf("Registry returns error when checkNamespaceStatus %d and can't get the response body %v", res.StatusCode, err))
 			} else {
-				return false, errors.New(fmt.Sprintf("Registry returns error when checkNamespaceStatus %d with body %s", res.StatusCode, string(body)))
+				return resBody, errors.New(fmt.Sprintf("Registry returns error when checkNamespaceStatus %d with body %s", res.StatusCode, string(body)))
 			}
 		}
 	}
 
-	resBody := server_structs.CheckNamespaceStatusRes{}
 	bodyByte, err := io.ReadAll(res.Body)
 	if err != nil {
-		return false, err
+		return resBody, err
 	}
 
 	if err := json.Unmarshal(bodyByte, &resBody); err != nil {
-		return false, err
+		return resBody, err
 	}
 
-	return resBody.Approved, nil
+	return resBody, nil
+}
+
+// Get the policy the registry last sent for the namespace; namespaces whose
+// registration wasn't checked recently have no policy
+func getNamespacePolicy(prefix string) server_structs.NamespacePolicy {
+	if item := namespacePolicies.Get(prefix); item != nil && !item.IsExpired() {
+		return item.Value()
+	}
+	return server_structs.NamespacePolicy{}
+}
+
+// Check the effective capabilities an origin advertises for a namespace against the
+// namespace's policy, describing each conflict
+func namespacePolicyConflicts(policy server_structs.NamespacePolicy, sAd server_structs.ServerAd, caps server_structs.Capabilities) (conflicts []string) {
+	if policy.DisallowDirectReads && caps.DirectReads {
+		conflicts = append(conflicts, "the namespace must not be read directly from origins, but the origin allows direct reads")
+	}
+	if policy.DisallowPublicReads && caps.PublicReads {
+		conflicts = append(conflicts, "the namespace must not be readable without a token, but the origin allows public reads")
+	}
+	if len(policy.WriteOrigins) > 0 && caps.Writes &&
+		!slices.Contains(policy.WriteOrigins, sAd.Name) && !slices.Contains(policy.WriteOrigins, sAd.URL.Hostname()) {
+		conflicts = append(conflicts, fmt.Sprintf("only the origins %s may accept writes to the namespace, but the origin allows writes", strings.Join(policy.WriteOrigins, ", ")))
+	}
+	return
 }
 
 // Given a token and a location in the namespace to advertise in,
@@ -124,11 +155,16 @@ func verifyAdvertiseToken(ctx context.Context, token, namespace string) (bool, e
 	}
 	regUrlStr := fedInfo.RegistryEndpoint
 
-	approved, err := checkNamespaceStatus(namespace, regUrlStr)
+	status, err := checkNamespaceStatus(namespace, regUrlStr)
 	if err != nil {
 		return false, errors.Wrap(err, "failed to check namespace approval status")
 	}
-	if !approved {
+	policy := server_structs.NamespacePolicy{}
+	if status.Policy != nil {
+		policy = *status.Policy
+	}
+	namespacePolicies.Set(namespace, policy, ttlcache.DefaultTTL)
+	if !status.Approved {
 		adminApprovalErr = errors.New(namespace + " has not been approved by an administrator")
 		return false, adminApprovalErr
 	}
@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"path/filepath"
 	"testing"
 	"time"
@@ -186,3 +187,19 @@ func TestNamespaceKeysCacheEviction(t *testing.T) {
 		}
 	})
 }
+
+func TestNamespacePolicyConflicts(t *testing.T) {
+	sAd := server_structs.ServerAd{Name: "my-origin", URL: url.URL{Scheme: "https", Host: "origin.example.com:8443"}}
+	caps := server_structs.Capabilities{Reads: true, Writes: true, DirectReads: true}
+
+	assert.Empty(t, namespacePolicyConflicts(server_structs.NamespacePolicy{}, sAd, caps))
+	assert.Len(t, namespacePolicyConflicts(server_structs.NamespacePolicy{DisallowDirectReads: true}, sAd, caps), 1)
+	assert.Empty(t, namespacePolicyConflicts(server_structs.NamespacePolicy{DisallowPublicReads: true}, sAd, caps))
+
+	// Write origins are matched by name or hostname, and only matter to origins accepting writes
+	assert.Empty(t, namespacePolicyConflicts(server_structs.NamespacePolicy{WriteOrigins: []string{"my-origin"}}, sAd, caps))
+	assert.Empty(t, namespacePolicyConflicts(server_structs.NamespacePolicy{WriteOrigins: []string{"origin.example.com"}}, sAd, caps))
+	assert.Len(t, namespacePolicyConflicts(server_structs.NamespacePolicy{WriteOrigins: []string{"other-origin"}}, sAd, caps), 1)
+	caps.Writes = false
+	assert.Empty(t, namespacePolicyConflicts(server_structs.NamespacePolicy{WriteOrigins: []string{"other-origin"}}, sAd, caps))
+}
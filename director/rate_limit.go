@@ -0,0 +1,292 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/utils"
+)
+
+type (
+	// Token buckets limiting the requests of each client, keyed by its IP address or token
+	rateLimiter struct {
+		limit   rate.Limit
+		burst   int
+		buckets *ttlcache.Cache[string, *rate.Limiter]
+	}
+
+	// The rate limits of the director, replaced as a whole when the configuration is reloaded
+	rateLimits struct {
+		ip        *rateLimiter
+		token     *rateLimiter
+		allowlist []netip.Prefix
+	}
+)
+
+var (
+	currentRateLimits      *rateLimits
+	currentRateLimitsMutex sync.RWMutex
+
+	// The addresses caches advertised from with a verified token, exempt from the rate limits
+	knownCacheAddrs = ttlcache.New[netip.Addr, struct{}]()
+
+	// How long the bucket of an idle client is kept.  Buckets refill long before that, so
+	// dropping one doesn't let the client go over its limit.
+	rateLimitBucketTTL = 10 * time.Minute
+)
+
+func newRateLimiter(limit int, burst int) *rateLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		limit:   rate.Limit(limit),
+		burst:   burst,
+		buckets: ttlcache.New(ttlcache.WithTTL[string, *rate.Limiter](rateLimitBucketTTL)),
+	}
+}
+
+// Take a request of the client out of its bucket, returning how long the client should
+// wait before retrying if the bucket is empty
+func (rl *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	item, _ := rl.buckets.GetOrSet(key, rate.NewLimiter(rl.limit, rl.burst))
+	reservation := item.Value().ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		// Don't hold a slot for a request that's refused
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Parse Director.RateLimitAllowlist, resolving the hostnames in it
+func parseRateLimitAllowlist(ctx context.Context, entries []string) ([]netip.Prefix, error) {
+	allowlist := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			allowlist = append(allowlist, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			allowlist = append(allowlist, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		addrs, err := net.DefaultResolver.LookupNetIP(lookupCtx, "ip", entry)
+		cancel()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve %q in %s", entry, param.Director_RateLimitAllowlist.GetName())
+		}
+		for _, addr := range addrs {
+			allowlist = append(allowlist, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return allowlist, nil
+}
+
+// Configure the rate limits of the director's redirect and API endpoints from the
+// Director.RateLimit* parameters.  The buckets of the clients start full again.
+func ConfigRateLimits() error {
+	for _, limit := range []param.IntParam{param.Director_RateLimitPerIP, param.Director_RateLimitPerIPBurst,
+		param.Director_RateLimitPerToken, param.Director_RateLimitPerTokenBurst} {
+		if limit.GetInt() < 0 {
+			return errors.Errorf("invalid value %d for %s; must not be negative", limit.GetInt(), limit.GetName())
+		}
+	}
+	allowlist, err := parseRateLimitAllowlist(context.Background(), param.Director_RateLimitAllowlist.GetStringSlice())
+	if err != nil {
+		return err
+	}
+	limits := &rateLimits{
+		ip:        newRateLimiter(param.Director_RateLimitPerIP.GetInt(), param.Director_RateLimitPerIPBurst.GetInt()),
+		token:     newRateLimiter(param.Director_RateLimitPerToken.GetInt(), param.Director_RateLimitPerTokenBurst.GetInt()),
+		allowlist: allowlist,
+	}
+	currentRateLimitsMutex.Lock()
+	currentRateLimits = limits
+	currentRateLimitsMutex.Unlock()
+	if limits.ip != nil || limits.token != nil {
+		log.Infof("Limiting requests to the director to %d a second per IP address and %d a second per token (0 is unlimited)",
+			param.Director_RateLimitPerIP.GetInt(), param.Director_RateLimitPerToken.GetInt())
+	}
+	return nil
+}
+
+func getRateLimits() *rateLimits {
+	currentRateLimitsMutex.RLock()
+	defer currentRateLimitsMutex.RUnlock()
+	return currentRateLimits
+}
+
+// Remove the buckets of idle clients and the addresses of caches that stopped advertising
+func LaunchRateLimitCleanup(ctx context.Context, egrp *errgroup.Group) {
+	egrp.Go(func() error {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				knownCacheAddrs.DeleteExpired()
+				if limits := getRateLimits(); limits != nil {
+					for _, limiter := range []*rateLimiter{limits.ip, limits.token} {
+						if limiter != nil {
+							limiter.buckets.DeleteExpired()
+						}
+					}
+				}
+			}
+		}
+	})
+}
+
+// Exempt the address a cache advertised from with a verified token from the rate limits
+// until its advertisement expires
+func recordKnownCache(addr netip.Addr) {
+	if addr.IsValid() {
+		knownCacheAddrs.Set(addr.Unmap(), struct{}{}, param.Director_AdvertisementTTL.GetDuration())
+	}
+}
+
+func (limits *rateLimits) exempt(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	if addr.IsLoopback() {
+		return true
+	}
+	for _, prefix := range limits.allowlist {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	if param.Director_RateLimitExemptCaches.GetBool() {
+		if item := knownCacheAddrs.Get(addr, ttlcache.WithDisableTouchOnHit[netip.Addr, struct{}]()); item != nil && !item.IsExpired() {
+			return true
+		}
+	}
+	return false
+}
+
+// The token a request carries, if any
+func getRequestToken(req *http.Request) string {
+	if authz := req.Header.Get("Authorization"); strings.HasPrefix(authz, "Bearer ") {
+		return strings.TrimPrefix(authz, "Bearer ")
+	}
+	query := req.URL.Query()
+	if tok := query.Get("authz"); tok != "" {
+		return strings.TrimPrefix(tok, "Bearer ")
+	}
+	return query.Get("access_token")
+}
+
+// Whether the rate limits apply to the path: the redirects, including those of the
+// shortcut paths, and the director's API, but not its web UI
+func rateLimitedPath(reqPath string) bool {
+	if strings.HasPrefix(reqPath, "/api/v1.0/director/") || strings.HasPrefix(reqPath, "/api/v2.0/director/") {
+		return true
+	}
+	return !strings.HasPrefix(reqPath, "/api/") && !strings.HasPrefix(reqPath, "/view") && !strings.HasPrefix(reqPath, "/.well-known/")
+}
+
+// A middleware refusing requests beyond the rate limits of the client's IP address or
+// token with a 429 response
+func RateLimitMiddleware(ginCtx *gin.Context) {
+	limits := getRateLimits()
+	if limits == nil || (limits.ip == nil && limits.token == nil) || !rateLimitedPath(ginCtx.Request.URL.Path) {
+		ginCtx.Next()
+		return
+	}
+	clientAddr := utils.ClientIPAddr(ginCtx)
+	if limits.exempt(clientAddr) {
+		ginCtx.Next()
+		return
+	}
+
+	now := time.Now()
+	kind := ""
+	var retryAfter time.Duration
+	if limits.ip != nil && clientAddr.IsValid() {
+		if ok, delay := limits.ip.allow(clientAddr.Unmap().String(), now); !ok {
+			kind, retryAfter = "ip", delay
+		}
+	}
+	if tok := getRequestToken(ginCtx.Request); kind == "" && limits.token != nil && tok != "" {
+		// Don't keep the tokens themselves around
+		digest := sha256.Sum256([]byte(tok))
+		if ok, delay := limits.token.allow(hex.EncodeToString(digest[:]), now); !ok {
+			kind, retryAfter = "token", delay
+		}
+	}
+	if kind == "" {
+		ginCtx.Next()
+		return
+	}
+
+	retrySeconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+	metrics.PelicanDirectorRateLimitedTotal.WithLabelValues(kind).Inc()
+	log.Debugf("Rate limited a request from %s for %s: over the %s limit", clientAddr.String(), ginCtx.Request.URL.Path, kind)
+	ginCtx.Header("Retry-After", retrySeconds)
+	ginCtx.AbortWithStatusJSON(http.StatusTooManyRequests, server_structs.SimpleApiResp{
+		Status: server_structs.RespFailed,
+		Msg:    "Too many requests to the director; retry in " + retrySeconds + " seconds",
+	})
+}
+
+// Let the rate limits be changed without a restart
+func registerRateLimitReloadables() {
+	config.RegisterReloadable(ConfigRateLimits,
+		param.Director_RateLimitPerIP.GetName(),
+		param.Director_RateLimitPerIPBurst.GetName(),
+		param.Director_RateLimitPerToken.GetName(),
+		param.Director_RateLimitPerTokenBurst.GetName(),
+		param.Director_RateLimitAllowlist.GetName(),
+	)
+}
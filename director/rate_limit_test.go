@@ -0,0 +1,147 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRateLimitTest(t *testing.T, settings map[string]any) *gin.Engine {
+	viper.Reset()
+	t.Cleanup(func() {
+		viper.Reset()
+		currentRateLimitsMutex.Lock()
+		currentRateLimits = nil
+		currentRateLimitsMutex.Unlock()
+		knownCacheAddrs.DeleteAll()
+	})
+	viper.Set("Director.RateLimitPerIPBurst", 100)
+	viper.Set("Director.RateLimitPerTokenBurst", 100)
+	viper.Set("Director.RateLimitExemptCaches", true)
+	viper.Set("Director.AdvertisementTTL", "15m")
+	for key, value := range settings {
+		viper.Set(key, value)
+	}
+	require.NoError(t, ConfigRateLimits())
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RateLimitMiddleware)
+	engine.NoRoute(func(ctx *gin.Context) { ctx.Status(http.StatusTemporaryRedirect) })
+	return engine
+}
+
+func rateLimitRequest(engine *gin.Engine, target string, remoteAddr string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.RemoteAddr = remoteAddr
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	t.Run("per-ip-limit-with-burst", func(t *testing.T) {
+		engine := setupRateLimitTest(t, map[string]any{
+			"Director.RateLimitPerIP":      1,
+			"Director.RateLimitPerIPBurst": 3,
+		})
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusTemporaryRedirect, rateLimitRequest(engine, "/api/v1.0/director/object/foo", "192.0.2.1:1234", "").Code)
+		}
+		resp := rateLimitRequest(engine, "/foo/bar", "192.0.2.1:1234", "")
+		assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+		assert.Equal(t, "1", resp.Header().Get("Retry-After"))
+		assert.Contains(t, resp.Body.String(), "Too many requests")
+
+		// Other clients and the web UI are unaffected
+		assert.Equal(t, http.StatusTemporaryRedirect, rateLimitRequest(engine, "/foo/bar", "192.0.2.2:1234", "").Code)
+		assert.Equal(t, http.StatusTemporaryRedirect, rateLimitRequest(engine, "/view/director/index.html", "192.0.2.1:1234", "").Code)
+	})
+
+	t.Run("per-token-limit-across-addresses", func(t *testing.T) {
+		engine := setupRateLimitTest(t, map[string]any{
+			"Director.RateLimitPerToken":      1,
+			"Director.RateLimitPerTokenBurst": 2,
+		})
+		assert.Equal(t, http.StatusTemporaryRedirect, rateLimitRequest(engine, "/foo/bar", "192.0.2.1:1234", "abc").Code)
+		assert.Equal(t, http.StatusTemporaryRedirect, rateLimitRequest(engine, "/foo/bar?authz=abc", "192.0.2.2:1234", "").Code)
+		assert.Equal(t, http.StatusTooManyRequests, rateLimitRequest(engine, "/foo/bar", "192.0.2.3:1234", "abc").Code)
+		// Requests with another token, or without any, are unaffected
+		assert.Equal(t, http.StatusTemporaryRedirect, rateLimitRequest(engine, "/foo/bar", "192.0.2.3:1234", "def").Code)
+		assert.Equal(t, http.StatusTemporaryRedirect, rateLimitRequest(engine, "/foo/bar", "192.0.2.3:1234", "").Code)
+	})
+
+	t.Run("exempt-clients", func(t *testing.T) {
+		engine := setupRateLimitTest(t, map[string]any{
+			"Director.RateLimitPerIP":      1,
+			"Director.RateLimitPerIPBurst": 1,
+			"Director.RateLimitAllowlist":  []string{"198.51.100.0/24", "2001:db8::1"},
+		})
+		recordKnownCache(netip.MustParseAddr("203.0.113.7"))
+		for _, addr := range []string{"198.51.100.20:1234", "[2001:db8::1]:1234", "127.0.0.1:1234", "203.0.113.7:1234"} {
+			for i := 0; i < 3; i++ {
+				assert.Equal(t, http.StatusTemporaryRedirect, rateLimitRequest(engine, "/foo/bar", addr, "").Code, addr)
+			}
+		}
+
+		// Known caches are only exempt with Director.RateLimitExemptCaches
+		viper.Set("Director.RateLimitExemptCaches", false)
+		assert.Equal(t, http.StatusTemporaryRedirect, rateLimitRequest(engine, "/foo/bar", "203.0.113.7:1234", "").Code)
+		assert.Equal(t, http.StatusTooManyRequests, rateLimitRequest(engine, "/foo/bar", "203.0.113.7:1234", "").Code)
+	})
+
+	t.Run("disabled-by-default", func(t *testing.T) {
+		engine := setupRateLimitTest(t, nil)
+		for i := 0; i < 200; i++ {
+			require.Equal(t, http.StatusTemporaryRedirect, rateLimitRequest(engine, "/foo/bar", "192.0.2.1:1234", "abc").Code)
+		}
+	})
+
+	t.Run("negative-limit-rejected", func(t *testing.T) {
+		setupRateLimitTest(t, nil)
+		viper.Set("Director.RateLimitPerToken", -1)
+		err := ConfigRateLimits()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Director.RateLimitPerToken")
+	})
+}
+
+func TestParseRateLimitAllowlist(t *testing.T) {
+	allowlist, err := parseRateLimitAllowlist(context.Background(), []string{"10.0.0.1/8", "192.0.2.1", "::ffff:192.0.2.2", "localhost"})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(allowlist), 4)
+	assert.Equal(t, netip.MustParsePrefix("10.0.0.0/8"), allowlist[0])
+	assert.Equal(t, netip.MustParsePrefix("192.0.2.1/32"), allowlist[1])
+	assert.Equal(t, netip.MustParsePrefix("192.0.2.2/32"), allowlist[2])
+	limits := &rateLimits{allowlist: allowlist}
+	assert.True(t, limits.exempt(netip.MustParseAddr("10.20.30.40")))
+	assert.False(t, limits.exempt(netip.MustParseAddr("192.0.2.3")))
+}
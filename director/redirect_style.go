@@ -0,0 +1,158 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// The way the director sends a client to the server of an object
+	redirectStyle string
+
+	// A redirect style for the clients with a User-Agent, as read from Director.RedirectStyles
+	RedirectStyleConfig struct {
+		UserAgent string `mapstructure:"UserAgent"`
+		Style     string `mapstructure:"Style"`
+	}
+
+	userAgentRedirectStyle struct {
+		userAgent string
+		style     redirectStyle
+	}
+)
+
+const (
+	// A 307 redirect, which clients follow with the same method and body
+	redirectStyleTemporary redirectStyle = "temporary"
+	// A 302 redirect, for clients that mishandle 307; only used for GET and HEAD requests,
+	// as clients may follow it with a GET
+	redirectStyleFound redirectStyle = "found"
+	// A 300 response pointing at the preferred server, leaving the choice among the
+	// servers of the Link header to clients implementing their own failover
+	redirectStyleLinks redirectStyle = "links"
+	// A 200 response with the servers in a JSON body
+	redirectStyleJSON redirectStyle = "json"
+
+	// The context key of the servers listed in the Link header of a redirect
+	redirectServersKey = "pelican.redirectServers"
+)
+
+var (
+	userAgentRedirectStyles      []userAgentRedirectStyle
+	userAgentRedirectStylesMutex sync.RWMutex
+)
+
+// Populate the redirect styles of the clients from the Director.RedirectStyles parameter
+func ConfigRedirectStyles() error {
+	var configs []RedirectStyleConfig
+	if err := param.Director_RedirectStyles.Unmarshal(&configs); err != nil {
+		return errors.Wrap(err, "failed to parse Director.RedirectStyles")
+	}
+
+	styles := make([]userAgentRedirectStyle, 0, len(configs))
+	for _, cfg := range configs {
+		if cfg.UserAgent == "" {
+			return errors.New("an entry in Director.RedirectStyles is missing its UserAgent")
+		}
+		style := redirectStyle(strings.ToLower(cfg.Style))
+		switch style {
+		case redirectStyleTemporary, redirectStyleFound, redirectStyleLinks, redirectStyleJSON:
+		default:
+			return errors.Errorf("invalid Style %q for UserAgent %q in Director.RedirectStyles. Must be one of '%s', '%s', '%s', or '%s'",
+				cfg.Style, cfg.UserAgent, redirectStyleTemporary, redirectStyleFound, redirectStyleLinks, redirectStyleJSON)
+		}
+		styles = append(styles, userAgentRedirectStyle{userAgent: strings.ToLower(cfg.UserAgent), style: style})
+	}
+
+	userAgentRedirectStylesMutex.Lock()
+	defer userAgentRedirectStylesMutex.Unlock()
+	userAgentRedirectStyles = styles
+	return nil
+}
+
+// Negotiate the redirect style of the request.  Clients preferring JSON over HTML in their
+// Accept header get the JSON body; otherwise, the first entry of Director.RedirectStyles
+// matching the User-Agent picks the style, and the others get a 307 redirect.
+func negotiateRedirectStyle(ginCtx *gin.Context) redirectStyle {
+	if ginCtx.GetHeader("Accept") != "" && ginCtx.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		return redirectStyleJSON
+	}
+
+	style := redirectStyleTemporary
+	userAgent := strings.ToLower(ginCtx.Request.UserAgent())
+	userAgentRedirectStylesMutex.RLock()
+	for _, uaStyle := range userAgentRedirectStyles {
+		if strings.Contains(userAgent, uaStyle.userAgent) {
+			style = uaStyle.style
+			break
+		}
+	}
+	userAgentRedirectStylesMutex.RUnlock()
+
+	// Following a 302 may turn an upload into a GET
+	if style == redirectStyleFound && ginCtx.Request.Method != http.MethodGet && ginCtx.Request.Method != http.MethodHead {
+		style = redirectStyleTemporary
+	}
+	return style
+}
+
+// Set the Link header listing the servers for the client to fail over to
+func setLinkHeader(ginCtx *gin.Context, servers []server_structs.RedirectServer) {
+	links := make([]string, 0, len(servers))
+	for _, server := range servers {
+		links = append(links, fmt.Sprintf(`<%s>; rel="duplicate"; pri=%d; depth=%d`, server.URL, server.Priority, server.Depth))
+	}
+	ginCtx.Writer.Header()["Link"] = []string{strings.Join(links, ", ")}
+	ginCtx.Set(redirectServersKey, servers)
+}
+
+// Send the client to the server in the negotiated redirect style
+func writeRedirect(ginCtx *gin.Context, nsPath string, location string) redirectStyle {
+	style := negotiateRedirectStyle(ginCtx)
+	ginCtx.Writer.Header().Add("Vary", "Accept, User-Agent")
+	switch style {
+	case redirectStyleJSON:
+		servers := []server_structs.RedirectServer{}
+		if value, ok := ginCtx.Get(redirectServersKey); ok {
+			servers = value.([]server_structs.RedirectServer)
+		}
+		ginCtx.JSON(http.StatusOK, server_structs.RedirectResponse{
+			Location:  location,
+			Namespace: nsPath,
+			Servers:   servers,
+		})
+	case redirectStyleLinks:
+		ginCtx.Redirect(http.StatusMultipleChoices, location)
+	case redirectStyleFound:
+		ginCtx.Redirect(http.StatusFound, location)
+	default:
+		ginCtx.Redirect(http.StatusTemporaryRedirect, location)
+	}
+	return style
+}
@@ -0,0 +1,121 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func TestConfigRedirectStyles(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		require.NoError(t, ConfigRedirectStyles())
+	})
+
+	viper.Set("Director.RedirectStyles", []map[string]any{{"UserAgent": "curl", "Style": "Found"}})
+	require.NoError(t, ConfigRedirectStyles())
+	assert.Equal(t, []userAgentRedirectStyle{{userAgent: "curl", style: redirectStyleFound}}, userAgentRedirectStyles)
+
+	viper.Set("Director.RedirectStyles", []map[string]any{{"UserAgent": "curl", "Style": "permanent"}})
+	assert.Error(t, ConfigRedirectStyles())
+	viper.Set("Director.RedirectStyles", []map[string]any{{"Style": "json"}})
+	assert.Error(t, ConfigRedirectStyles())
+}
+
+func TestWriteRedirect(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		require.NoError(t, ConfigRedirectStyles())
+	})
+	viper.Set("Director.RedirectStyles", []map[string]any{
+		{"UserAgent": "legacy-downloader", "Style": "found"},
+		{"UserAgent": "failover-client", "Style": "links"},
+	})
+	require.NoError(t, ConfigRedirectStyles())
+
+	servers := []server_structs.RedirectServer{
+		{URL: "https://cache1.example.com/foo/bar", Priority: 1, Depth: 1},
+		{URL: "https://cache2.example.com/foo/bar", Priority: 2, Depth: 1},
+	}
+	location := "https://cache1.example.com/foo/bar?authz=token"
+	r := gin.New()
+	r.Any("/foo/bar", func(ginCtx *gin.Context) {
+		setLinkHeader(ginCtx, servers)
+		writeRedirect(ginCtx, "/foo", location)
+	})
+	request := func(method string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/foo/bar", nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("temporary-by-default", func(t *testing.T) {
+		w := request(http.MethodGet, map[string]string{"User-Agent": "curl/8.0", "Accept": "*/*"})
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+		assert.Equal(t, location, w.Header().Get("Location"))
+		assert.Equal(t, `<https://cache1.example.com/foo/bar>; rel="duplicate"; pri=1; depth=1, <https://cache2.example.com/foo/bar>; rel="duplicate"; pri=2; depth=1`, w.Header().Get("Link"))
+	})
+
+	t.Run("browsers-get-redirects", func(t *testing.T) {
+		w := request(http.MethodGet, map[string]string{"User-Agent": "Mozilla/5.0", "Accept": "text/html,application/xhtml+xml,application/json;q=0.9,*/*;q=0.8"})
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	})
+
+	t.Run("found-by-user-agent", func(t *testing.T) {
+		w := request(http.MethodGet, map[string]string{"User-Agent": "Legacy-Downloader/1.2"})
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, location, w.Header().Get("Location"))
+		// Uploads keep their method
+		w = request(http.MethodPut, map[string]string{"User-Agent": "Legacy-Downloader/1.2"})
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	})
+
+	t.Run("links-by-user-agent", func(t *testing.T) {
+		w := request(http.MethodGet, map[string]string{"User-Agent": "failover-client/2.0"})
+		assert.Equal(t, http.StatusMultipleChoices, w.Code)
+		assert.Equal(t, location, w.Header().Get("Location"))
+		assert.Contains(t, w.Header().Get("Link"), "cache2.example.com")
+	})
+
+	t.Run("json-by-accept", func(t *testing.T) {
+		w := request(http.MethodGet, map[string]string{"User-Agent": "Legacy-Downloader/1.2", "Accept": "application/json"})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+		resp := server_structs.RedirectResponse{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, server_structs.RedirectResponse{Location: location, Namespace: "/foo", Servers: servers}, resp)
+	})
+}
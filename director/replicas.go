@@ -0,0 +1,199 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// The replica coverage of a namespace within one cache region
+	RegionReplicas struct {
+		Region    string   `json:"region"`
+		Target    int      `json:"target"`
+		Available int      `json:"available"` // The number of caches in the region able to serve the namespace
+		Placed    []string `json:"placed"`    // The caches chosen to hold the namespace's replicas
+		Missing   int      `json:"missing"`   // How many replicas short of the target the region is
+	}
+
+	// The replica coverage of a namespace that declares replica targets
+	NamespaceReplicas struct {
+		Namespace string           `json:"namespace"`
+		Regions   []RegionReplicas `json:"regions"`
+		Satisfied bool             `json:"satisfied"`
+	}
+
+	listReplicasRequest struct {
+		Unsatisfied bool `form:"unsatisfied"` // Only return namespaces short of a target
+	}
+)
+
+// The region name in replica targets that matches caches in any region
+const anyRegion = "*"
+
+// How often the replica coverage metrics are recomputed
+const replicaMetricsInterval = time.Minute
+
+// Collect the replica targets declared by the origins for each namespace.  If
+// several origins export the same namespace, the largest target for a region wins.
+func collectReplicaTargets(ads []*server_structs.Advertisement) map[string]map[string]int {
+	targets := make(map[string]map[string]int)
+	for _, ad := range ads {
+		if ad.Type != server_structs.OriginType.String() {
+			continue
+		}
+		for _, ns := range ad.NamespaceAds {
+			for region, count := range ns.ReplicaTargets {
+				if count <= 0 {
+					continue
+				}
+				if targets[ns.Path] == nil {
+					targets[ns.Path] = make(map[string]int)
+				}
+				region = strings.ToLower(region)
+				targets[ns.Path][region] = max(targets[ns.Path][region], count)
+			}
+		}
+	}
+	return targets
+}
+
+// Compute the replica coverage of every namespace with replica targets.
+//
+// A cache can hold a replica of a namespace if it is not filtered and advertises the
+// namespace.  Within each region, the least loaded of those caches (by name, to break
+// ties) are placed up to the target.  The placement is what a replication service
+// should prefetch the namespace's data to.
+func computeReplicaCoverage() []NamespaceReplicas {
+	ads := make([]*server_structs.Advertisement, 0, serverAds.Len())
+	for _, item := range serverAds.Items() {
+		ads = append(ads, item.Value())
+	}
+	targets := collectReplicaTargets(ads)
+
+	var caches []server_structs.ServerAd
+	cacheNamespaces := make(map[string][]server_structs.NamespaceAdV2)
+	for _, ad := range ads {
+		if ad.Type != server_structs.CacheType.String() {
+			continue
+		}
+		// A cache in an unscheduled downtime can't be counted on to hold a replica
+		if filtered, ft := checkFilter(ad.Name); filtered || ft == topoUnscheduledFiltered {
+			continue
+		}
+		caches = append(caches, ad.ServerAd)
+		cacheNamespaces[ad.URL.String()] = ad.NamespaceAds
+	}
+	sort.Slice(caches, func(i, j int) bool {
+		if caches[i].IOLoad != caches[j].IOLoad {
+			return caches[i].IOLoad < caches[j].IOLoad
+		}
+		return caches[i].Name < caches[j].Name
+	})
+
+	result := make([]NamespaceReplicas, 0, len(targets))
+	for nsPath, regionTargets := range targets {
+		nsReplicas := NamespaceReplicas{Namespace: nsPath, Satisfied: true}
+		for region, target := range regionTargets {
+			regionReplicas := RegionReplicas{Region: region, Target: target, Placed: []string{}}
+			for _, cache := range caches {
+				if region != anyRegion && cache.Region != region {
+					continue
+				}
+				if matchesPrefix(strings.TrimSuffix(nsPath, "/")+"/", cacheNamespaces[cache.URL.String()]) == nil {
+					continue
+				}
+				regionReplicas.Available++
+				if len(regionReplicas.Placed) < target {
+					regionReplicas.Placed = append(regionReplicas.Placed, cache.Name)
+				}
+			}
+			regionReplicas.Missing = target - len(regionReplicas.Placed)
+			if regionReplicas.Missing > 0 {
+				nsReplicas.Satisfied = false
+			}
+			nsReplicas.Regions = append(nsReplicas.Regions, regionReplicas)
+		}
+		sort.Slice(nsReplicas.Regions, func(i, j int) bool {
+			return nsReplicas.Regions[i].Region < nsReplicas.Regions[j].Region
+		})
+		result = append(result, nsReplicas)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Namespace < result[j].Namespace
+	})
+	return result
+}
+
+// Report the replica coverage of the namespaces with replica targets
+func listReplicasHandler(ctx *gin.Context) {
+	queryParams := listReplicasRequest{}
+	if ctx.ShouldBindQuery(&queryParams) != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid query parameters",
+		})
+		return
+	}
+	coverage := computeReplicaCoverage()
+	if queryParams.Unsatisfied {
+		unsatisfied := make([]NamespaceReplicas, 0, len(coverage))
+		for _, ns := range coverage {
+			if !ns.Satisfied {
+				unsatisfied = append(unsatisfied, ns)
+			}
+		}
+		coverage = unsatisfied
+	}
+	ctx.JSON(http.StatusOK, coverage)
+}
+
+// Periodically export the replica coverage of the namespaces as metrics, so that
+// availability targets can be alerted on
+func LaunchReplicaMetrics(ctx context.Context, egrp *errgroup.Group) {
+	egrp.Go(func() error {
+		ticker := time.NewTicker(replicaMetricsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				metrics.PelicanDirectorNamespaceReplicaTarget.Reset()
+				metrics.PelicanDirectorNamespaceReplicas.Reset()
+				for _, ns := range computeReplicaCoverage() {
+					for _, region := range ns.Regions {
+						metrics.PelicanDirectorNamespaceReplicaTarget.WithLabelValues(ns.Namespace, region.Region).Set(float64(region.Target))
+						metrics.PelicanDirectorNamespaceReplicas.WithLabelValues(ns.Namespace, region.Region).Set(float64(len(region.Placed)))
+					}
+				}
+			}
+		}
+	})
+}
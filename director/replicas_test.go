@@ -0,0 +1,109 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestReplicaCoverage(t *testing.T) {
+	serverAds.DeleteAll()
+	t.Cleanup(func() {
+		serverAds.DeleteAll()
+		filteredServersMutex.Lock()
+		delete(filteredServers, "cache-filtered")
+		filteredServersMutex.Unlock()
+	})
+
+	addAd := func(name string, sType server_structs.ServerType, region string, ioLoad float64, nsAds []server_structs.NamespaceAdV2) {
+		adUrl := url.URL{Scheme: "https", Host: name + ".example.com"}
+		serverAds.Set(adUrl.String(), &server_structs.Advertisement{
+			ServerAd: server_structs.ServerAd{
+				Name:   name,
+				URL:    adUrl,
+				Type:   sType.String(),
+				Region: region,
+				IOLoad: ioLoad,
+			},
+			NamespaceAds: nsAds,
+		}, ttlcache.DefaultTTL)
+	}
+
+	addAd("origin", server_structs.OriginType, "", 0, []server_structs.NamespaceAdV2{
+		{Path: "/data", ReplicaTargets: map[string]int{"US-East": 2, "eu": 1}},
+		{Path: "/other"},
+	})
+	dataNs := []server_structs.NamespaceAdV2{{Path: "/data"}}
+	addAd("cache-busy", server_structs.CacheType, "us-east", 5, dataNs)
+	addAd("cache-idle", server_structs.CacheType, "us-east", 1, dataNs)
+	addAd("cache-also-idle", server_structs.CacheType, "us-east", 1, dataNs)
+	addAd("cache-other-ns", server_structs.CacheType, "eu", 0, []server_structs.NamespaceAdV2{{Path: "/other"}})
+	addAd("cache-filtered", server_structs.CacheType, "eu", 0, dataNs)
+	filteredServersMutex.Lock()
+	filteredServers["cache-filtered"] = tempFiltered
+	filteredServersMutex.Unlock()
+
+	coverage := computeReplicaCoverage()
+	require.Len(t, coverage, 1)
+	assert.Equal(t, "/data", coverage[0].Namespace)
+	assert.False(t, coverage[0].Satisfied)
+	assert.Equal(t, []RegionReplicas{
+		{Region: "eu", Target: 1, Available: 0, Placed: []string{}, Missing: 1},
+		{Region: "us-east", Target: 2, Available: 3, Placed: []string{"cache-also-idle", "cache-idle"}, Missing: 0},
+	}, coverage[0].Regions)
+
+	t.Run("any-region", func(t *testing.T) {
+		addAd("origin", server_structs.OriginType, "", 0, []server_structs.NamespaceAdV2{
+			{Path: "/data", ReplicaTargets: map[string]int{"*": 3}},
+		})
+		coverage := computeReplicaCoverage()
+		require.Len(t, coverage, 1)
+		assert.True(t, coverage[0].Satisfied)
+		assert.Equal(t, []string{"cache-also-idle", "cache-idle", "cache-busy"}, coverage[0].Regions[0].Placed)
+	})
+
+	t.Run("unsatisfied-filter", func(t *testing.T) {
+		addAd("origin-2", server_structs.OriginType, "", 0, []server_structs.NamespaceAdV2{
+			{Path: "/other", ReplicaTargets: map[string]int{"us-east": 1}},
+		})
+		router := gin.New()
+		router.GET("/replicas", listReplicasHandler)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/replicas?unsatisfied=true", nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var got []NamespaceReplicas
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		require.Len(t, got, 1)
+		assert.Equal(t, "/other", got[0].Namespace)
+		assert.Equal(t, 1, got[0].Regions[0].Missing)
+	})
+}
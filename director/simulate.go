@@ -0,0 +1,423 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// A server in the federation whose redirects are simulated
+	SimulatedServer struct {
+		Name      string  `yaml:"name"`
+		Type      string  `yaml:"type"` // Cache or Origin
+		URL       string  `yaml:"url"`
+		Latitude  float64 `yaml:"latitude"`
+		Longitude float64 `yaml:"longitude"`
+		IOLoad    float64 `yaml:"ioLoad"`
+		// The namespace prefixes the server serves.  A cache that lists none serves
+		// every namespace exported by the origins.
+		Namespaces []string `yaml:"namespaces"`
+	}
+
+	// A client request replayed against the director's redirect logic
+	SimulatedRequest struct {
+		Path string
+		// Whether the client asked for an origin rather than a cache
+		ToOrigin bool
+		// The client's address, used to locate the client unless Location is set
+		ClientIP netip.Addr
+		Location *Coordinate
+	}
+
+	// The requests redirected to one server in a simulation
+	SimulatedAllocation struct {
+		Server   string  `json:"server"`
+		Type     string  `json:"type"`
+		Requests int     `json:"requests"`
+		Share    float64 `json:"share"` // The fraction of the served requests
+	}
+
+	// The outcome of replaying a workload with some servers removed from the federation
+	SimulationScenario struct {
+		Removed  []string `json:"removed"`
+		Requests int      `json:"requests"`
+		// Requests for which no server was found
+		Unserved int `json:"unserved"`
+		// Requests redirected to a different server than with all servers present;
+		// requests that could no longer be served are counted as unserved instead
+		Moved int `json:"moved"`
+		// The time the director's logic took to redirect the requests
+		DurationSeconds   float64               `json:"durationSeconds"`
+		RequestsPerSecond float64               `json:"requestsPerSecond"`
+		Allocations       []SimulatedAllocation `json:"allocations"`
+	}
+)
+
+const (
+	directorObjectPrefix = "/api/v1.0/director/object"
+	directorOriginPrefix = "/api/v1.0/director/origin"
+)
+
+// A key=value field of a log line in logrus' text format
+var logFieldRegex = regexp.MustCompile(`(\w+)=("(?:[^"\\]|\\.)*"|\S*)`)
+
+// Load the servers of a simulated federation from a YAML (or JSON) file listing
+// them under the `servers` key
+func LoadSimulatedServers(file string) ([]SimulatedServer, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the server inventory")
+	}
+	inventory := struct {
+		Servers []SimulatedServer `yaml:"servers"`
+	}{}
+	if err = yaml.Unmarshal(contents, &inventory); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the server inventory %s", file)
+	}
+	if len(inventory.Servers) == 0 {
+		return nil, errors.Errorf("the server inventory %s lists no servers", file)
+	}
+	names := make(map[string]bool, len(inventory.Servers))
+	for idx := range inventory.Servers {
+		server := &inventory.Servers[idx]
+		if server.Name == "" {
+			return nil, errors.Errorf("server %d of the inventory has no name", idx+1)
+		}
+		if names[server.Name] {
+			return nil, errors.Errorf("the inventory lists server %s more than once", server.Name)
+		}
+		names[server.Name] = true
+		var sType server_structs.ServerType
+		if !sType.SetString(server.Type) || (sType != server_structs.CacheType && sType != server_structs.OriginType) {
+			return nil, errors.Errorf("server %s has type %q; it must be a Cache or an Origin", server.Name, server.Type)
+		}
+		server.Type = sType.String()
+		if server.URL == "" {
+			server.URL = "https://" + server.Name
+		}
+		if _, err := url.Parse(server.URL); err != nil {
+			return nil, errors.Wrapf(err, "server %s has an invalid URL", server.Name)
+		}
+		if sType == server_structs.OriginType && len(server.Namespaces) == 0 {
+			return nil, errors.Errorf("origin %s exports no namespaces", server.Name)
+		}
+	}
+	return inventory.Servers, nil
+}
+
+// Parse the requests the director redirected out of its log.  Both the text and the
+// JSON log formats are understood; lines other than successful object and origin
+// redirects are skipped.
+func ParseRedirectLog(reader io.Reader) ([]SimulatedRequest, error) {
+	requests := []SimulatedRequest{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := parseLogFields(scanner.Text())
+		if fields["msg"] != "Served Request" {
+			continue
+		}
+		if status, err := strconv.Atoi(fields["status"]); err != nil || status < 300 || status >= 400 {
+			continue
+		}
+		request := SimulatedRequest{}
+		resource := fields["resource"]
+		if objPath, ok := strings.CutPrefix(resource, directorObjectPrefix+"/"); ok {
+			request.Path = "/" + objPath
+		} else if objPath, ok := strings.CutPrefix(resource, directorOriginPrefix+"/"); ok {
+			request.Path = "/" + objPath
+			request.ToOrigin = true
+		} else {
+			continue
+		}
+		// Unparseable addresses are left invalid, which places the client randomly
+		request.ClientIP, _ = netip.ParseAddr(fields["client"])
+		requests = append(requests, request)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read the redirect log")
+	}
+	return requests, nil
+}
+
+// Split a log line into its fields
+func parseLogFields(line string) map[string]string {
+	fields := map[string]string{}
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") {
+		jsonFields := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &jsonFields); err == nil {
+			for key, val := range jsonFields {
+				fields[key] = fmt.Sprint(val)
+			}
+		}
+		return fields
+	}
+	for _, match := range logFieldRegex.FindAllStringSubmatch(line, -1) {
+		val := match[2]
+		if unquoted, err := strconv.Unquote(val); err == nil {
+			val = unquoted
+		}
+		fields[match[1]] = val
+	}
+	return fields
+}
+
+// Generate a workload of count cache requests for objects under the namespaces the
+// origins export, from clients spread across the contiguous US
+func SyntheticWorkload(servers []SimulatedServer, count int) []SimulatedRequest {
+	namespaces := []string{}
+	for _, server := range servers {
+		if server.Type == server_structs.OriginType.String() {
+			namespaces = append(namespaces, server.Namespaces...)
+		}
+	}
+	if len(namespaces) == 0 {
+		return nil
+	}
+	requests := make([]SimulatedRequest, count)
+	for idx := range requests {
+		location := Coordinate{}
+		location.Lat, location.Long = assignRandBoundedCoord(usLatMin, usLatMax, usLongMin, usLongMax)
+		namespace := namespaces[rand.Intn(len(namespaces))]
+		requests[idx] = SimulatedRequest{
+			Path:     path.Join(namespace, fmt.Sprintf("synthetic-object-%d", idx)),
+			Location: &location,
+		}
+	}
+	return requests
+}
+
+// Replay the requests against the director's filtering and sorting logic, first with
+// all the servers present, then once for each set of removed servers.
+//
+// The configured Director.CacheSortMethod and Director.FilteredServers are used.  As the director's object
+// availability queries aren't simulated, the adaptive method sorts as if no server
+// reported whether it has the object.
+//
+// Simulate replaces the director's in-memory server advertisements, so it must not
+// be run inside a director that is serving clients.
+func Simulate(ctx context.Context, servers []SimulatedServer, requests []SimulatedRequest, removals [][]string) ([]SimulationScenario, error) {
+	known := map[string]string{}
+	for _, server := range servers {
+		known[server.Name] = server.Type
+	}
+	for _, removed := range removals {
+		for _, name := range removed {
+			if _, ok := known[name]; !ok {
+				return nil, errors.Errorf("cannot remove server %s as it isn't in the inventory", name)
+			}
+		}
+	}
+
+	if err := loadSimulatedAds(servers); err != nil {
+		return nil, err
+	}
+	filteredServersMutex.Lock()
+	savedFilters := maps.Clone(filteredServers)
+	filteredServersMutex.Unlock()
+	baseFilters := maps.Clone(savedFilters)
+	for _, name := range param.Director_FilteredServers.GetStringSlice() {
+		if _, ok := baseFilters[name]; !ok {
+			baseFilters[name] = permFiltered
+		}
+	}
+	defer func() {
+		serverAds.DeleteAll()
+		filteredServersMutex.Lock()
+		filteredServers = savedFilters
+		filteredServersMutex.Unlock()
+	}()
+
+	locations := locateSimulatedClients(requests)
+
+	scenarios := make([]SimulationScenario, 0, len(removals)+1)
+	var baseline []string
+	for _, removed := range append([][]string{nil}, removals...) {
+		filteredServersMutex.Lock()
+		filteredServers = maps.Clone(baseFilters)
+		for _, name := range removed {
+			filteredServers[name] = tempFiltered
+		}
+		filteredServersMutex.Unlock()
+
+		scenario, destinations, err := runSimulation(ctx, requests, locations, known)
+		if err != nil {
+			return nil, err
+		}
+		scenario.Removed = removed
+		if scenario.Removed == nil {
+			scenario.Removed = []string{}
+			baseline = destinations
+		} else {
+			for idx, destination := range destinations {
+				if destination != "" && destination != baseline[idx] {
+					scenario.Moved++
+				}
+			}
+		}
+		scenarios = append(scenarios, scenario)
+	}
+	return scenarios, nil
+}
+
+// Replace the director's server advertisements with those of the simulated servers
+func loadSimulatedAds(servers []SimulatedServer) error {
+	originNamespaces := []string{}
+	for _, server := range servers {
+		if server.Type == server_structs.OriginType.String() {
+			originNamespaces = append(originNamespaces, server.Namespaces...)
+		}
+	}
+	serverAds.DeleteAll()
+	for _, server := range servers {
+		serverUrl, err := url.Parse(server.URL)
+		if err != nil {
+			return errors.Wrapf(err, "server %s has an invalid URL", server.Name)
+		}
+		namespaces := server.Namespaces
+		if len(namespaces) == 0 {
+			namespaces = originNamespaces
+		}
+		ad := &server_structs.Advertisement{
+			ServerAd: server_structs.ServerAd{
+				Name:      server.Name,
+				Type:      server.Type,
+				URL:       *serverUrl,
+				AuthURL:   *serverUrl,
+				Latitude:  server.Latitude,
+				Longitude: server.Longitude,
+				IOLoad:    server.IOLoad,
+			},
+		}
+		for _, namespace := range namespaces {
+			ad.NamespaceAds = append(ad.NamespaceAds, server_structs.NamespaceAdV2{Path: namespace})
+		}
+		serverAds.Set(serverUrl.String(), ad, ttlcache.NoTTL)
+	}
+	return nil
+}
+
+// Locate the clients of the requests up front, so that the time taken to resolve
+// their addresses isn't counted against the director's sorting
+func locateSimulatedClients(requests []SimulatedRequest) []Coordinate {
+	needGeoIP := slices.ContainsFunc(requests, func(request SimulatedRequest) bool {
+		return request.Location == nil && request.ClientIP.IsValid()
+	})
+	if needGeoIP && maxMindReader.Load() == nil {
+		if reader, err := loadGeoIPDB(param.Director_GeoIPLocation.GetString()); err != nil {
+			log.Warningln("No GeoIP database is available; clients that aren't covered by Director.GeoIPOverrides will be placed randomly:", err)
+		} else {
+			storeGeoIPReader(reader)
+		}
+	}
+
+	resolved := map[netip.Addr]Coordinate{}
+	locations := make([]Coordinate, len(requests))
+	for idx, request := range requests {
+		if request.Location != nil {
+			locations[idx] = *request.Location
+			continue
+		}
+		location, ok := resolved[request.ClientIP]
+		if !ok {
+			location, _ = getClientLatLong(request.ClientIP)
+			resolved[request.ClientIP] = location
+		}
+		locations[idx] = location
+	}
+	return locations
+}
+
+// Replay the requests once, returning the name of the server each was sent to
+// (empty if none was found)
+func runSimulation(ctx context.Context, requests []SimulatedRequest, locations []Coordinate, serverTypes map[string]string) (scenario SimulationScenario, destinations []string, err error) {
+	counts := map[string]int{}
+	destinations = make([]string, len(requests))
+	start := time.Now()
+	for idx, request := range requests {
+		if idx%1000 == 0 && ctx.Err() != nil {
+			err = ctx.Err()
+			return
+		}
+		namespaceAd, originAds, cacheAds := getAdsForPath(request.Path)
+		candidates := cacheAds
+		if request.ToOrigin {
+			candidates = originAds
+		}
+		if namespaceAd.Path == "" || len(candidates) == 0 {
+			scenario.Unserved++
+			continue
+		}
+		var sorted []server_structs.ServerAd
+		if sorted, err = sortServerAdsForCoordinate(locations[idx], candidates, nil, server_structs.SortType(param.Director_CacheSortMethod.GetString())); err != nil {
+			return
+		}
+		destinations[idx] = sorted[0].Name
+		counts[sorted[0].Name]++
+	}
+	duration := time.Since(start)
+	scenario.Requests = len(requests)
+	scenario.DurationSeconds = duration.Seconds()
+	if duration > 0 {
+		scenario.RequestsPerSecond = float64(len(requests)) / duration.Seconds()
+	}
+
+	served := len(requests) - scenario.Unserved
+	scenario.Allocations = []SimulatedAllocation{}
+	for name, count := range counts {
+		scenario.Allocations = append(scenario.Allocations, SimulatedAllocation{
+			Server:   name,
+			Type:     serverTypes[name],
+			Requests: count,
+			Share:    float64(count) / float64(served),
+		})
+	}
+	slices.SortFunc(scenario.Allocations, func(a, b SimulatedAllocation) int {
+		if a.Requests != b.Requests {
+			return b.Requests - a.Requests
+		}
+		return strings.Compare(a.Server, b.Server)
+	})
+	return
+}
@@ -0,0 +1,161 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+const simulatedInventory = `
+servers:
+  - name: chicago-cache
+    type: cache
+    latitude: 41.88
+    longitude: -87.63
+  - name: denver-cache
+    type: Cache
+    latitude: 39.74
+    longitude: -104.99
+  - name: madison-origin
+    type: Origin
+    latitude: 43.07
+    longitude: -89.40
+    namespaces: ["/example"]
+`
+
+func TestLoadSimulatedServers(t *testing.T) {
+	dir := t.TempDir()
+	inventoryFile := filepath.Join(dir, "servers.yaml")
+	require.NoError(t, os.WriteFile(inventoryFile, []byte(simulatedInventory), 0644))
+
+	servers, err := LoadSimulatedServers(inventoryFile)
+	require.NoError(t, err)
+	require.Len(t, servers, 3)
+	assert.Equal(t, "Cache", servers[0].Type)
+	assert.Equal(t, "https://chicago-cache", servers[0].URL)
+	assert.Equal(t, []string{"/example"}, servers[2].Namespaces)
+
+	for name, inventory := range map[string]string{
+		"bad-type":     "servers:\n  - name: foo\n    type: registry\n",
+		"no-name":      "servers:\n  - type: cache\n",
+		"duplicate":    "servers:\n  - name: foo\n    type: cache\n  - name: foo\n    type: cache\n",
+		"no-namespace": "servers:\n  - name: foo\n    type: origin\n",
+		"empty":        "servers: []\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			badFile := filepath.Join(dir, name+".yaml")
+			require.NoError(t, os.WriteFile(badFile, []byte(inventory), 0644))
+			_, err := LoadSimulatedServers(badFile)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseRedirectLog(t *testing.T) {
+	logLines := strings.Join([]string{
+		`time="2024-06-01T12:00:00Z" level=info msg="Served Request" client=128.104.153.60 daemon=gin method=GET resource=/api/v1.0/director/object/example/foo status=307 time=1.2ms`,
+		`time="2024-06-01T12:00:01Z" level=info msg="Served Request" client=10.0.0.1 daemon=gin method=GET resource=/api/v1.0/director/origin/example/bar status=307 time=1.1ms`,
+		// Not a redirect
+		`time="2024-06-01T12:00:02Z" level=info msg="Served Request" client=10.0.0.1 daemon=gin method=GET resource=/api/v1.0/director/object/example/missing status=404 time=1.1ms`,
+		`time="2024-06-01T12:00:03Z" level=info msg="Served Request" client=10.0.0.1 daemon=gin method=GET resource=/api/v1.0/director_ui/servers status=200 time=1.1ms`,
+		`time="2024-06-01T12:00:04Z" level=debug msg="Checking for a downtime filter applied to server foo"`,
+		`{"client":"10.0.0.2","level":"info","method":"GET","msg":"Served Request","resource":"/api/v1.0/director/object/example/baz","status":307}`,
+	}, "\n")
+
+	requests, err := ParseRedirectLog(strings.NewReader(logLines))
+	require.NoError(t, err)
+	require.Len(t, requests, 3)
+	assert.Equal(t, "/example/foo", requests[0].Path)
+	assert.False(t, requests[0].ToOrigin)
+	assert.Equal(t, "128.104.153.60", requests[0].ClientIP.String())
+	assert.Equal(t, "/example/bar", requests[1].Path)
+	assert.True(t, requests[1].ToOrigin)
+	assert.Equal(t, "/example/baz", requests[2].Path)
+	assert.Equal(t, "10.0.0.2", requests[2].ClientIP.String())
+}
+
+func TestSimulate(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(server_utils.ResetTestState)
+	viper.Set("Director.CacheSortMethod", "distance")
+
+	inventoryFile := filepath.Join(t.TempDir(), "servers.yaml")
+	require.NoError(t, os.WriteFile(inventoryFile, []byte(simulatedInventory), 0644))
+	servers, err := LoadSimulatedServers(inventoryFile)
+	require.NoError(t, err)
+
+	// Three clients near Chicago and one near Denver, plus a request outside any namespace
+	requests := []SimulatedRequest{
+		{Path: "/example/a", Location: &Coordinate{Lat: 41.9, Long: -87.7}},
+		{Path: "/example/b", Location: &Coordinate{Lat: 42.0, Long: -88.0}},
+		{Path: "/example/c", Location: &Coordinate{Lat: 41.5, Long: -87.5}},
+		{Path: "/example/d", Location: &Coordinate{Lat: 39.7, Long: -105.0}},
+		{Path: "/example/e", ToOrigin: true, Location: &Coordinate{Lat: 39.7, Long: -105.0}},
+		{Path: "/elsewhere/f", Location: &Coordinate{Lat: 39.7, Long: -105.0}},
+	}
+	filteredServersMutex.RLock()
+	filtersBefore := maps.Clone(filteredServers)
+	filteredServersMutex.RUnlock()
+	scenarios, err := Simulate(context.Background(), servers, requests,
+		[][]string{{"chicago-cache"}, {"chicago-cache", "denver-cache"}})
+	require.NoError(t, err)
+	require.Len(t, scenarios, 3)
+
+	baseline := scenarios[0]
+	assert.Empty(t, baseline.Removed)
+	assert.Equal(t, 6, baseline.Requests)
+	assert.Equal(t, 1, baseline.Unserved)
+	assert.Equal(t, []SimulatedAllocation{
+		{Server: "chicago-cache", Type: "Cache", Requests: 3, Share: 0.6},
+		{Server: "denver-cache", Type: "Cache", Requests: 1, Share: 0.2},
+		{Server: "madison-origin", Type: "Origin", Requests: 1, Share: 0.2},
+	}, baseline.Allocations)
+
+	withoutChicago := scenarios[1]
+	assert.Equal(t, []string{"chicago-cache"}, withoutChicago.Removed)
+	assert.Equal(t, 3, withoutChicago.Moved)
+	assert.Equal(t, 1, withoutChicago.Unserved)
+	require.Len(t, withoutChicago.Allocations, 2)
+	assert.Equal(t, SimulatedAllocation{Server: "denver-cache", Type: "Cache", Requests: 4, Share: 0.8}, withoutChicago.Allocations[0])
+
+	// Without any caches, only the origin request can be served
+	withoutCaches := scenarios[2]
+	assert.Equal(t, 5, withoutCaches.Unserved)
+	assert.Zero(t, withoutCaches.Moved)
+
+	// The director's state is left as it was
+	assert.Zero(t, serverAds.Len())
+	filteredServersMutex.RLock()
+	assert.Equal(t, filtersBefore, filteredServers)
+	filteredServersMutex.RUnlock()
+
+	_, err = Simulate(context.Background(), servers, requests, [][]string{{"no-such-cache"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,357 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// The service level objectives of a namespace, as read from Director.NamespaceSLOs
+	NamespaceSLOConfig struct {
+		Namespace            string        `mapstructure:"Namespace"`
+		RedirectAvailability float64       `mapstructure:"RedirectAvailability"`
+		TransferSuccessRate  float64       `mapstructure:"TransferSuccessRate"`
+		Window               time.Duration `mapstructure:"Window"`
+	}
+
+	sloObjective string
+
+	// A bucket of good and bad events in a rolling window
+	sloBucket struct {
+		start time.Time
+		good  uint64
+		bad   uint64
+	}
+
+	// Good and bad events over a rolling window, kept as a ring of fixed-width buckets
+	sloWindow struct {
+		window  time.Duration
+		width   time.Duration
+		buckets []sloBucket
+	}
+
+	// One objective tracked for a namespace
+	namespaceSLO struct {
+		namespace string
+		objective sloObjective
+		target    float64
+		events    *sloWindow
+	}
+
+	// The status of one objective of a namespace over its rolling window
+	SLOStatus struct {
+		Namespace string  `json:"namespace"`
+		Objective string  `json:"objective"`
+		Target    float64 `json:"target"`
+		Window    string  `json:"window"`
+		Good      uint64  `json:"good"`
+		Total     uint64  `json:"total"`
+		// The measured ratio of good events; 1 if there were no events in the window
+		SLI float64 `json:"sli"`
+		// The number of bad events the target allows over the window's events
+		ErrorBudget float64 `json:"errorBudget"`
+		// The fraction of the error budget left; negative once the budget is overspent
+		ErrorBudgetRemaining float64 `json:"errorBudgetRemaining"`
+		Met                  bool    `json:"met"`
+	}
+
+	listSLOsRequest struct {
+		Namespace string `form:"namespace"` // Only return the objectives of this namespace
+		Violated  bool   `form:"violated"`  // Only return objectives that are not met
+	}
+)
+
+const (
+	sloRedirectAvailability sloObjective = "redirect_availability"
+	sloTransferSuccess      sloObjective = "transfer_success"
+)
+
+// The rolling window used when an SLO does not specify one
+const defaultSLOWindow = 30 * 24 * time.Hour
+
+// The number of buckets each rolling window is divided into
+const sloWindowBuckets = 60
+
+// How often the SLO metrics are recomputed
+const sloMetricsInterval = time.Minute
+
+var (
+	namespaceSLOs      []*namespaceSLO
+	namespaceSLOsMutex sync.RWMutex
+)
+
+func newSLOWindow(window time.Duration) *sloWindow {
+	width := window / sloWindowBuckets
+	if width <= 0 {
+		width = time.Second
+	}
+	return &sloWindow{
+		window:  window,
+		width:   width,
+		buckets: make([]sloBucket, sloWindowBuckets),
+	}
+}
+
+func (w *sloWindow) record(now time.Time, good bool) {
+	start := now.Truncate(w.width)
+	bucket := &w.buckets[(start.UnixNano()/int64(w.width))%int64(len(w.buckets))]
+	if !bucket.start.Equal(start) {
+		*bucket = sloBucket{start: start}
+	}
+	if good {
+		bucket.good++
+	} else {
+		bucket.bad++
+	}
+}
+
+// Sum the events in the buckets that overlap the window ending at now
+func (w *sloWindow) totals(now time.Time) (good, bad uint64) {
+	cutoff := now.Add(-w.window)
+	for _, bucket := range w.buckets {
+		if bucket.start.IsZero() || !bucket.start.Add(w.width).After(cutoff) || bucket.start.After(now) {
+			continue
+		}
+		good += bucket.good
+		bad += bucket.bad
+	}
+	return
+}
+
+// Populate the namespace SLOs from the Director.NamespaceSLOs parameter.
+//
+// Every (re)configuration starts the rolling windows empty.
+func ConfigNamespaceSLOs() error {
+	var configs []NamespaceSLOConfig
+	if err := param.Director_NamespaceSLOs.Unmarshal(&configs); err != nil {
+		return errors.Wrap(err, "failed to parse Director.NamespaceSLOs")
+	}
+
+	slos := make([]*namespaceSLO, 0, 2*len(configs))
+	for _, cfg := range configs {
+		ns := path.Clean("/" + cfg.Namespace)
+		if cfg.Namespace == "" {
+			return errors.New("an entry in Director.NamespaceSLOs is missing its Namespace")
+		}
+		window := cfg.Window
+		if window == 0 {
+			window = defaultSLOWindow
+		} else if window < 0 {
+			return errors.Errorf("invalid window %s for namespace %s in Director.NamespaceSLOs", window, ns)
+		}
+		objectives := map[sloObjective]float64{
+			sloRedirectAvailability: cfg.RedirectAvailability,
+			sloTransferSuccess:      cfg.TransferSuccessRate,
+		}
+		for _, objective := range []sloObjective{sloRedirectAvailability, sloTransferSuccess} {
+			target := objectives[objective]
+			if target == 0 {
+				continue
+			}
+			if target < 0 || target >= 1 {
+				return errors.Errorf("invalid %s target %v for namespace %s in Director.NamespaceSLOs; targets are ratios between 0 and 1, such as 0.999", objective, target, ns)
+			}
+			slos = append(slos, &namespaceSLO{
+				namespace: ns,
+				objective: objective,
+				target:    target,
+				events:    newSLOWindow(window),
+			})
+		}
+		log.Infof("Configured SLOs for namespace %s over a %s window: redirect availability %v, transfer success rate %v",
+			ns, window, cfg.RedirectAvailability, cfg.TransferSuccessRate)
+	}
+
+	namespaceSLOsMutex.Lock()
+	defer namespaceSLOsMutex.Unlock()
+	namespaceSLOs = slos
+	return nil
+}
+
+// Whether the path is the prefix itself or lies underneath it
+func pathUnderPrefix(p, prefix string) bool {
+	return prefix == "/" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// Record the outcome of a redirect for an object in the namespace.
+//
+// Only server-side failures spend the error budget: client errors (4xx), such as a
+// missing object or a missing token, are not counted against the namespace.
+func recordRedirectSLO(nsPath string, statusCode int) {
+	if statusCode >= 400 && statusCode < 500 {
+		return
+	}
+	good := statusCode < 400
+	nsPath = path.Clean("/" + nsPath)
+	now := time.Now()
+
+	namespaceSLOsMutex.Lock()
+	defer namespaceSLOsMutex.Unlock()
+	// Only the most specific SLO covering the namespace is charged
+	var best *namespaceSLO
+	for _, slo := range namespaceSLOs {
+		if slo.objective != sloRedirectAvailability || !pathUnderPrefix(nsPath, slo.namespace) {
+			continue
+		}
+		if best == nil || len(slo.namespace) > len(best.namespace) {
+			best = slo
+		}
+	}
+	if best == nil {
+		return
+	}
+	best.events.record(now, good)
+	metrics.PelicanDirectorNamespaceSLOEventsTotal.WithLabelValues(best.namespace, string(best.objective), sloOutcome(good)).Inc()
+}
+
+// Record the outcome of a director file transfer test against a server.  The outcome
+// counts toward every namespace SLO the server serves data for.
+func recordTransferSLO(serverUrl string, good bool) {
+	item := serverAds.Get(serverUrl)
+	if item == nil {
+		return
+	}
+	ad := item.Value()
+	now := time.Now()
+
+	namespaceSLOsMutex.Lock()
+	defer namespaceSLOsMutex.Unlock()
+	for _, slo := range namespaceSLOs {
+		if slo.objective != sloTransferSuccess {
+			continue
+		}
+		for _, ns := range ad.NamespaceAds {
+			nsPath := path.Clean("/" + ns.Path)
+			if pathUnderPrefix(nsPath, slo.namespace) || pathUnderPrefix(slo.namespace, nsPath) {
+				slo.events.record(now, good)
+				metrics.PelicanDirectorNamespaceSLOEventsTotal.WithLabelValues(slo.namespace, string(slo.objective), sloOutcome(good)).Inc()
+				break
+			}
+		}
+	}
+}
+
+func sloOutcome(good bool) string {
+	if good {
+		return "good"
+	}
+	return "bad"
+}
+
+func (slo *namespaceSLO) status(now time.Time) SLOStatus {
+	good, bad := slo.events.totals(now)
+	status := SLOStatus{
+		Namespace:            slo.namespace,
+		Objective:            string(slo.objective),
+		Target:               slo.target,
+		Window:               slo.events.window.String(),
+		Good:                 good,
+		Total:                good + bad,
+		SLI:                  1,
+		ErrorBudgetRemaining: 1,
+	}
+	if status.Total > 0 {
+		status.SLI = float64(good) / float64(status.Total)
+		status.ErrorBudget = (1 - slo.target) * float64(status.Total)
+		status.ErrorBudgetRemaining = 1 - float64(bad)/status.ErrorBudget
+	}
+	status.Met = status.SLI >= slo.target
+	return status
+}
+
+// Compute the status of every configured namespace SLO
+func computeSLOStatus() []SLOStatus {
+	now := time.Now()
+	namespaceSLOsMutex.RLock()
+	defer namespaceSLOsMutex.RUnlock()
+	result := make([]SLOStatus, 0, len(namespaceSLOs))
+	for _, slo := range namespaceSLOs {
+		result = append(result, slo.status(now))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Objective < result[j].Objective
+	})
+	return result
+}
+
+// Report the SLO status and error budgets of the namespaces
+func listSLOsHandler(ctx *gin.Context) {
+	queryParams := listSLOsRequest{}
+	if ctx.ShouldBindQuery(&queryParams) != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid query parameters",
+		})
+		return
+	}
+	statuses := computeSLOStatus()
+	filtered := make([]SLOStatus, 0, len(statuses))
+	for _, status := range statuses {
+		if queryParams.Namespace != "" && status.Namespace != path.Clean("/"+queryParams.Namespace) {
+			continue
+		}
+		if queryParams.Violated && status.Met {
+			continue
+		}
+		filtered = append(filtered, status)
+	}
+	ctx.JSON(http.StatusOK, filtered)
+}
+
+// Periodically export the SLO status of the namespaces as metrics for federation reporting
+func LaunchSLOMetrics(ctx context.Context, egrp *errgroup.Group) {
+	egrp.Go(func() error {
+		ticker := time.NewTicker(sloMetricsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				metrics.PelicanDirectorNamespaceSLOTarget.Reset()
+				metrics.PelicanDirectorNamespaceSLI.Reset()
+				metrics.PelicanDirectorNamespaceErrorBudgetRemaining.Reset()
+				for _, status := range computeSLOStatus() {
+					metrics.PelicanDirectorNamespaceSLOTarget.WithLabelValues(status.Namespace, status.Objective).Set(status.Target)
+					metrics.PelicanDirectorNamespaceSLI.WithLabelValues(status.Namespace, status.Objective).Set(status.SLI)
+					metrics.PelicanDirectorNamespaceErrorBudgetRemaining.WithLabelValues(status.Namespace, status.Objective).Set(status.ErrorBudgetRemaining)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,116 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func setupNamespaceSLOs(t *testing.T, sloConfig []map[string]any) {
+	server_utils.ResetTestState()
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		namespaceSLOsMutex.Lock()
+		namespaceSLOs = nil
+		namespaceSLOsMutex.Unlock()
+	})
+	viper.Set("Director.NamespaceSLOs", sloConfig)
+	require.NoError(t, ConfigNamespaceSLOs())
+}
+
+func TestSLOWindow(t *testing.T) {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	w := newSLOWindow(time.Hour)
+	require.Equal(t, time.Minute, w.width)
+
+	w.record(start, true)
+	w.record(start.Add(10*time.Second), false)
+	w.record(start.Add(30*time.Minute), true)
+	good, bad := w.totals(start.Add(30 * time.Minute))
+	assert.Equal(t, uint64(2), good)
+	assert.Equal(t, uint64(1), bad)
+
+	// The first bucket leaves the window an hour later
+	good, bad = w.totals(start.Add(61 * time.Minute))
+	assert.Equal(t, uint64(1), good)
+	assert.Equal(t, uint64(0), bad)
+
+	// Events an hour apart land in the same slot of the ring, which is reset
+	w.record(start.Add(time.Hour), false)
+	good, bad = w.totals(start.Add(time.Hour))
+	assert.Equal(t, uint64(1), good)
+	assert.Equal(t, uint64(1), bad)
+}
+
+func TestNamespaceSLOs(t *testing.T) {
+	setupNamespaceSLOs(t, []map[string]any{
+		{"Namespace": "/ospool", "RedirectAvailability": 0.9, "TransferSuccessRate": 0.5},
+		{"Namespace": "/ospool/data/", "RedirectAvailability": 0.99, "Window": "24h"},
+	})
+
+	for i := 0; i < 9; i++ {
+		recordRedirectSLO("/ospool/data/project", 307)
+	}
+	recordRedirectSLO("/ospool/data", 500)
+	// Client errors do not count against the namespace
+	recordRedirectSLO("/ospool/data", 404)
+	recordRedirectSLO("/ospool/other", 307)
+	recordRedirectSLO("/ospoolish", 500)
+
+	statuses := computeSLOStatus()
+	require.Len(t, statuses, 3)
+
+	assert.Equal(t, "/ospool", statuses[0].Namespace)
+	assert.Equal(t, string(sloRedirectAvailability), statuses[0].Objective)
+	assert.Equal(t, uint64(1), statuses[0].Total)
+	assert.True(t, statuses[0].Met)
+
+	// No transfer tests have run yet, so the budget is untouched
+	assert.Equal(t, string(sloTransferSuccess), statuses[1].Objective)
+	assert.Equal(t, uint64(0), statuses[1].Total)
+	assert.Equal(t, 1.0, statuses[1].SLI)
+	assert.Equal(t, 1.0, statuses[1].ErrorBudgetRemaining)
+
+	dataStatus := statuses[2]
+	assert.Equal(t, "/ospool/data", dataStatus.Namespace)
+	assert.Equal(t, "24h0m0s", dataStatus.Window)
+	assert.Equal(t, uint64(9), dataStatus.Good)
+	assert.Equal(t, uint64(10), dataStatus.Total)
+	assert.InDelta(t, 0.9, dataStatus.SLI, 1e-9)
+	assert.InDelta(t, 0.1, dataStatus.ErrorBudget, 1e-9)
+	assert.InDelta(t, -9.0, dataStatus.ErrorBudgetRemaining, 1e-9)
+	assert.False(t, dataStatus.Met)
+
+	t.Run("invalid-target", func(t *testing.T) {
+		viper.Set("Director.NamespaceSLOs", []map[string]any{{"Namespace": "/foo", "RedirectAvailability": 99.9}})
+		assert.Error(t, ConfigNamespaceSLOs())
+	})
+
+	t.Run("missing-namespace", func(t *testing.T) {
+		viper.Set("Director.NamespaceSLOs", []map[string]any{{"RedirectAvailability": 0.99}})
+		assert.Error(t, ConfigNamespaceSLOs())
+	})
+}
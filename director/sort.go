@@ -33,6 +33,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
@@ -291,7 +292,8 @@ func invertWeightIfNeeded(isRand bool, weight float64) float64 {
 	return weight
 }
 
-// The all-in-one method to sort serverAds based on the Director.CacheSortMethod configuration parameter
+// The all-in-one method to sort serverAds based on the Director.CacheSortMethod configuration parameter,
+// or on the sort method of the client's arm of the Director.SortExperiment
 //   - distance: sort serverAds by the distance between the geolocation of the servers and the client
 //   - distanceAndLoad: sort serverAds by the distance with gated halving factor (see details in the adaptive method)
 //     and the server IO load
@@ -302,10 +304,15 @@ func invertWeightIfNeeded(isRand bool, weight float64) float64 {
 // the client IP, any distance-related steps are skipped. If the sort method is "distance", then
 // the serverAds are randomly sorted.
 func sortServerAds(ctx context.Context, clientAddr netip.Addr, ads []server_structs.ServerAd, availabilityMap map[string]bool) ([]server_structs.ServerAd, error) {
-	// Each entry in weights will map a priority to an index in the original ads slice.
-	// A larger weight is a higher priority.
-	weights := make(SwapMaps, len(ads))
-	sortMethod := param.Director_CacheSortMethod.GetString()
+	sortMethod := getSortMethod(ctx)
+	_, span := metrics.StartSpan(ctx, "director.sortServerAds",
+		attribute.String("pelican.sort_method", string(sortMethod)),
+		attribute.Int("pelican.servers", len(ads)),
+	)
+	defer span.End()
+	if assignment, ok := ctx.Value(SortExperimentContextKey{}).(sortExperimentAssignment); ok {
+		span.SetAttributes(attribute.String("pelican.sort_experiment", assignment.experiment), attribute.String("pelican.sort_experiment.arm", string(assignment.arm)))
+	}
 	// This will handle the case where the client address is invalid or the lat/long is not resolvable.
 	clientCoord, err := getClientLatLong(clientAddr)
 	if err != nil {
@@ -318,10 +325,18 @@ func sortServerAds(ctx context.Context, clientAddr netip.Addr, ads []server_stru
 		}
 		log.Warningf("Error while getting the client IP address: %v", err)
 	}
+	return sortServerAdsForCoordinate(clientCoord, ads, availabilityMap, sortMethod)
+}
+
+// Sort the serverAds for a client at the given coordinate with the sort method; see sortServerAds
+func sortServerAdsForCoordinate(clientCoord Coordinate, ads []server_structs.ServerAd, availabilityMap map[string]bool, sortMethod server_structs.SortType) ([]server_structs.ServerAd, error) {
+	// Each entry in weights will map a priority to an index in the original ads slice.
+	// A larger weight is a higher priority.
+	weights := make(SwapMaps, len(ads))
 
 	// For each ad, we apply the configured sort method to determine a priority weight.
 	for idx, ad := range ads {
-		switch server_structs.SortType(sortMethod) {
+		switch sortMethod {
 		case server_structs.DistanceType:
 			// If either client or ad coordinates are null, the underlying distanceWeight function will return a random weight
 			weight, isRand := distanceWeight(clientCoord.Lat, clientCoord.Long, ad.Latitude, ad.Longitude, false)
@@ -349,6 +364,7 @@ func sortServerAds(ctx context.Context, clientAddr netip.Addr, ads []server_stru
 			// Load weight
 			lWeighted := gatedHalvingMultiplier(ad.IOLoad, loadHalvingThreshold, loadHalvingFactor)
 			weight *= invertWeightIfNeeded(isRand, lWeighted)
+			weight *= invertWeightIfNeeded(isRand, capacityMultiplier(ad))
 			weights[idx] = SwapMap{weight, idx}
 		case server_structs.AdaptiveType:
 			weight := 1.0
@@ -375,22 +391,49 @@ func sortServerAds(ctx context.Context, clientAddr netip.Addr, ads []server_stru
 			// Load weight
 			lWeighted := gatedHalvingMultiplier(ad.IOLoad, loadHalvingThreshold, loadHalvingFactor)
 			weight *= invertWeightIfNeeded(isRand, lWeighted)
+			weight *= invertWeightIfNeeded(isRand, capacityMultiplier(ad))
 
 			weights[idx] = SwapMap{weight, idx}
 		case server_structs.RandomType:
 			weights[idx] = SwapMap{rand.Float64(), idx}
 		default:
 			// Never say never, but this should never get hit because we validate the value on startup.
-			return nil, errors.Errorf("Invalid sort method '%s' set in Director.CacheSortMethod.", param.Director_CacheSortMethod.GetString())
+			return nil, errors.Errorf("Invalid sort method '%s' set in Director.CacheSortMethod.", sortMethod)
 		}
 	}
 
-	if sortMethod == string(server_structs.AdaptiveType) {
-		candidates, _ := stochasticSort(weights, serverResLimit)
+	if sortMethod == server_structs.AdaptiveType {
+		// Last-resort servers must not take the place of a healthy one in the result,
+		// so if there are any, every server is ranked before the result is cut down
+		var candidates []int
+		if hasLastResortServers(ads) {
+			// stochasticSort can only pick the servers with a positive weight; the
+			// others follow in their original order
+			positive := SwapMaps{}
+			for _, weight := range weights {
+				if weight.Weight > 0 {
+					positive = append(positive, weight)
+				}
+			}
+			if len(positive) > 0 {
+				candidates, _ = stochasticSort(positive, len(positive))
+			}
+			for _, weight := range weights {
+				if !slices.Contains(candidates, weight.Index) {
+					candidates = append(candidates, weight.Index)
+				}
+			}
+		} else {
+			candidates, _ = stochasticSort(weights, serverResLimit)
+		}
 		resultAds := []server_structs.ServerAd{}
-		for _, cidx := range candidates[:serverResLimit] {
+		for _, cidx := range candidates {
 			resultAds = append(resultAds, ads[cidx])
 		}
+		sortLastResortServersLast(resultAds)
+		if len(resultAds) > serverResLimit {
+			resultAds = resultAds[:serverResLimit]
+		}
 		return resultAds, nil
 	} else {
 		// Larger weight = higher priority, so we reverse the sort (which would otherwise default to ascending)
@@ -399,10 +442,58 @@ func sortServerAds(ctx context.Context, clientAddr netip.Addr, ads []server_stru
 		for idx, weight := range weights {
 			resultAds[idx] = ads[weight.Index]
 		}
+		sortLastResortServersLast(resultAds)
 		return resultAds, nil
 	}
 }
 
+// Whether the server is in an unscheduled topology downtime, draining before a restart, or
+// degraded, in which case it's only redirected to after all the other servers
+func isLastResortServer(ad server_structs.ServerAd) bool {
+	return ad.Draining || ad.Degraded != "" || filteredServers[ad.Name] == topoUnscheduledFiltered
+}
+
+// Scale the weight of a server by the fraction of its capacity it has left
+func capacityMultiplier(ad server_structs.ServerAd) float64 {
+	if ad.Capacity > 0 && ad.Capacity < 1 {
+		return ad.Capacity
+	}
+	return 1.0
+}
+
+// Mark the caches whose advertised content includes the object as having it.  The filters
+// only cover the content a cache served recently, so caches they leave out are not assumed
+// to lack the object.
+func applyContentHints(objectPath string, ads []server_structs.ServerAd, availabilityMap map[string]bool) {
+	for _, ad := range ads {
+		if ad.ContentFilter != nil && ad.ContentFilter.MayContain(objectPath) {
+			availabilityMap[ad.URL.String()] = true
+		}
+	}
+}
+
+func hasLastResortServers(ads []server_structs.ServerAd) bool {
+	filteredServersMutex.RLock()
+	defer filteredServersMutex.RUnlock()
+	return slices.ContainsFunc(ads, isLastResortServer)
+}
+
+// Stable-sort the given serverAds in-place, moving the servers in an unscheduled
+// topology downtime or draining to the end of the list
+func sortLastResortServersLast(ads []server_structs.ServerAd) {
+	filteredServersMutex.RLock()
+	defer filteredServersMutex.RUnlock()
+	slices.SortStableFunc(ads, func(a, b server_structs.ServerAd) int {
+		aLast, bLast := isLastResortServer(a), isLastResortServer(b)
+		if aLast && !bLast {
+			return 1
+		} else if !aLast && bLast {
+			return -1
+		}
+		return 0
+	})
+}
+
 // Sort a list of ServerAds with the following rule:
 //   - if a ServerAds has FromTopology = true, then it will be moved to the end of the list
 //   - if two ServerAds has the SAME FromTopology value (both true or false), then break tie them by name
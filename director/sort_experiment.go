@@ -0,0 +1,312 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// The A/B experiment of a sorting algorithm, as read from Director.SortExperiment
+	SortExperimentConfig struct {
+		Name        string        `mapstructure:"Name"`
+		SortMethod  string        `mapstructure:"SortMethod"`
+		Percentage  float64       `mapstructure:"Percentage"`
+		RetryWindow time.Duration `mapstructure:"RetryWindow"`
+	}
+
+	sortExperimentArm string
+
+	// The arm of the sort experiment a redirect was assigned to
+	sortExperimentAssignment struct {
+		experiment string
+		arm        sortExperimentArm
+		method     server_structs.SortType
+	}
+
+	sortExperimentArmCounts struct {
+		redirects uint64
+		succeeded uint64
+		failed    uint64
+	}
+
+	// The comparative results of one arm of the sort experiment
+	SortExperimentArmStatus struct {
+		Arm                string  `json:"arm"`
+		SortMethod         string  `json:"sort_method"`
+		Redirects          uint64  `json:"redirects"`
+		TransfersSucceeded uint64  `json:"transfers_succeeded"`
+		TransfersFailed    uint64  `json:"transfers_failed"`
+		SuccessRate        float64 `json:"success_rate"` // Of the transfers whose result is known
+	}
+
+	SortExperimentStatus struct {
+		Enabled     bool                      `json:"enabled"`
+		Name        string                    `json:"name,omitempty"`
+		Percentage  float64                   `json:"percentage,omitempty"`
+		RetryWindow string                    `json:"retry_window,omitempty"`
+		Arms        []SortExperimentArmStatus `json:"arms"`
+	}
+
+	// Context key for the sort experiment assignment of a redirect
+	SortExperimentContextKey struct{}
+)
+
+const (
+	sortExperimentControl   sortExperimentArm = "control"
+	sortExperimentTreatment sortExperimentArm = "treatment"
+
+	// The retry window used when the experiment does not specify one
+	defaultSortExperimentRetryWindow = 10 * time.Minute
+
+	// The most redirects whose transfer result is awaited at once; the results of the
+	// redirects beyond that aren't counted
+	sortExperimentMaxPending = 100000
+)
+
+var (
+	// The running experiment; nil if there's none
+	sortExperiment       *SortExperimentConfig
+	sortExperimentCounts map[sortExperimentArm]*sortExperimentArmCounts
+	sortExperimentMutex  sync.RWMutex
+
+	// The redirects whose transfer result is not known yet, keyed by client address and object
+	pendingExperimentRedirects = ttlcache.New[string, sortExperimentAssignment](
+		ttlcache.WithDisableTouchOnHit[string, sortExperimentAssignment](),
+		ttlcache.WithCapacity[string, sortExperimentAssignment](sortExperimentMaxPending),
+	)
+)
+
+// Configure the sort experiment from the Director.SortExperiment parameter.
+//
+// Every (re)configuration starts the results of the experiment from scratch.
+func ConfigSortExperiment() error {
+	var cfg SortExperimentConfig
+	if err := param.Director_SortExperiment.Unmarshal(&cfg); err != nil {
+		return errors.Wrap(err, "failed to parse Director.SortExperiment")
+	}
+
+	var experiment *SortExperimentConfig
+	if cfg.SortMethod != "" {
+		switch server_structs.SortType(cfg.SortMethod) {
+		case server_structs.DistanceType, server_structs.DistanceAndLoadType, server_structs.RandomType, server_structs.AdaptiveType:
+		default:
+			return errors.Errorf("invalid SortMethod %q in Director.SortExperiment. Must be one of '%s', '%s', '%s', or '%s'",
+				cfg.SortMethod, server_structs.DistanceType, server_structs.DistanceAndLoadType, server_structs.RandomType, server_structs.AdaptiveType)
+		}
+		if cfg.Percentage < 0 || cfg.Percentage > 100 {
+			return errors.Errorf("invalid Percentage %v in Director.SortExperiment; must be between 0 and 100", cfg.Percentage)
+		}
+		if cfg.RetryWindow == 0 {
+			cfg.RetryWindow = defaultSortExperimentRetryWindow
+		} else if cfg.RetryWindow < 0 {
+			return errors.Errorf("invalid RetryWindow %s in Director.SortExperiment", cfg.RetryWindow)
+		}
+		if cfg.Name == "" {
+			cfg.Name = cfg.SortMethod
+		}
+		experiment = &cfg
+		log.Infof("Sorting %v%% of the redirects with the %q method in sort experiment %q", cfg.Percentage, cfg.SortMethod, cfg.Name)
+	}
+
+	sortExperimentMutex.Lock()
+	sortExperiment = experiment
+	sortExperimentCounts = map[sortExperimentArm]*sortExperimentArmCounts{
+		sortExperimentControl:   {},
+		sortExperimentTreatment: {},
+	}
+	sortExperimentMutex.Unlock()
+	// The results of the previous experiment no longer count
+	pendingExperimentRedirects.DeleteAll()
+	return nil
+}
+
+// Assign the client to an arm of the sort experiment.  The assignment only depends on the
+// client address, so a client keeps seeing the same arm, and its retries are attributed
+// to the arm that sorted its failed transfer.
+func assignSortExperimentArm(clientAddr netip.Addr) (assignment sortExperimentAssignment, ok bool) {
+	sortExperimentMutex.RLock()
+	experiment := sortExperiment
+	sortExperimentMutex.RUnlock()
+	if experiment == nil {
+		return
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(experiment.Name + "/" + clientAddr.String()))
+	assignment = sortExperimentAssignment{
+		experiment: experiment.Name,
+		arm:        sortExperimentControl,
+		method:     server_structs.SortType(param.Director_CacheSortMethod.GetString()),
+	}
+	if float64(hash.Sum32()%10000) < experiment.Percentage*100 {
+		assignment.arm = sortExperimentTreatment
+		assignment.method = server_structs.SortType(experiment.SortMethod)
+	}
+	return assignment, true
+}
+
+// Add the sort experiment assignment of the client, if an experiment is running, to the context
+func withSortExperiment(ctx context.Context, clientAddr netip.Addr) context.Context {
+	if assignment, ok := assignSortExperimentArm(clientAddr); ok {
+		return context.WithValue(ctx, SortExperimentContextKey{}, assignment)
+	}
+	return ctx
+}
+
+// Get the sort method for the request: the one of its sort experiment arm, or Director.CacheSortMethod
+func getSortMethod(ctx context.Context) server_structs.SortType {
+	if assignment, ok := ctx.Value(SortExperimentContextKey{}).(sortExperimentAssignment); ok {
+		return assignment.method
+	}
+	return server_structs.SortType(param.Director_CacheSortMethod.GetString())
+}
+
+func (assignment sortExperimentAssignment) count(update func(counts *sortExperimentArmCounts)) bool {
+	sortExperimentMutex.Lock()
+	defer sortExperimentMutex.Unlock()
+	if sortExperiment == nil || sortExperiment.Name != assignment.experiment {
+		return false
+	}
+	update(sortExperimentCounts[assignment.arm])
+	return true
+}
+
+// Record that the transfer of a redirect in the sort experiment succeeded or failed
+func concludeSortExperimentRedirect(assignment sortExperimentAssignment, succeeded bool) {
+	counted := assignment.count(func(counts *sortExperimentArmCounts) {
+		if succeeded {
+			counts.succeeded++
+		} else {
+			counts.failed++
+		}
+	})
+	if !counted {
+		return
+	}
+	result := "Succeeded"
+	if !succeeded {
+		result = "Failed"
+	}
+	metrics.PelicanDirectorSortExperimentTransfersTotal.WithLabelValues(assignment.experiment, string(assignment.arm), string(assignment.method), result).Inc()
+}
+
+// Record a redirect sorted as part of the sort experiment.
+//
+// The director doesn't see the transfers themselves, so their result is inferred from
+// the clients: a client asking for the same object again within the retry window of the
+// experiment is taken to have failed the transfer, otherwise the transfer is taken to
+// have succeeded once the window passes.
+func recordSortExperimentRedirect(ctx context.Context, clientAddr netip.Addr, objectPath string) {
+	assignment, ok := ctx.Value(SortExperimentContextKey{}).(sortExperimentAssignment)
+	if !ok {
+		return
+	}
+	var retryWindow time.Duration
+	counted := assignment.count(func(counts *sortExperimentArmCounts) {
+		counts.redirects++
+		retryWindow = sortExperiment.RetryWindow
+	})
+	if !counted {
+		return
+	}
+	metrics.PelicanDirectorSortExperimentRedirectsTotal.WithLabelValues(assignment.experiment, string(assignment.arm), string(assignment.method)).Inc()
+	log.WithFields(log.Fields{
+		"experiment":     assignment.experiment,
+		"experiment_arm": assignment.arm,
+		"sort_method":    assignment.method,
+	}).Debugf("Redirecting %s for %s as part of the sort experiment", objectPath, clientAddr.String())
+
+	key := clientAddr.String() + " " + objectPath
+	if previous := pendingExperimentRedirects.Get(key); previous != nil {
+		pendingExperimentRedirects.Delete(key)
+		concludeSortExperimentRedirect(previous.Value(), false)
+	}
+	pendingExperimentRedirects.Set(key, assignment, retryWindow)
+}
+
+// Compute the comparative results of the arms of the sort experiment
+func computeSortExperimentStatus() SortExperimentStatus {
+	sortExperimentMutex.RLock()
+	defer sortExperimentMutex.RUnlock()
+	status := SortExperimentStatus{Arms: []SortExperimentArmStatus{}}
+	if sortExperiment == nil {
+		return status
+	}
+	status.Enabled = true
+	status.Name = sortExperiment.Name
+	status.Percentage = sortExperiment.Percentage
+	status.RetryWindow = sortExperiment.RetryWindow.String()
+	methods := map[sortExperimentArm]string{
+		sortExperimentControl:   param.Director_CacheSortMethod.GetString(),
+		sortExperimentTreatment: sortExperiment.SortMethod,
+	}
+	for _, arm := range []sortExperimentArm{sortExperimentControl, sortExperimentTreatment} {
+		counts := sortExperimentCounts[arm]
+		armStatus := SortExperimentArmStatus{
+			Arm:                string(arm),
+			SortMethod:         methods[arm],
+			Redirects:          counts.redirects,
+			TransfersSucceeded: counts.succeeded,
+			TransfersFailed:    counts.failed,
+		}
+		if concluded := counts.succeeded + counts.failed; concluded > 0 {
+			armStatus.SuccessRate = float64(counts.succeeded) / float64(concluded)
+		}
+		status.Arms = append(status.Arms, armStatus)
+	}
+	return status
+}
+
+// Report the comparative results of the arms of the sort experiment
+func getSortExperimentHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, computeSortExperimentStatus())
+}
+
+// Conclude the transfers of the sort experiment redirects whose retry window passed
+func LaunchSortExperiment(ctx context.Context, egrp *errgroup.Group) {
+	removeHook := pendingExperimentRedirects.OnEviction(func(_ context.Context, reason ttlcache.EvictionReason, item *ttlcache.Item[string, sortExperimentAssignment]) {
+		if reason == ttlcache.EvictionReasonExpired {
+			concludeSortExperimentRedirect(item.Value(), true)
+		}
+	})
+	go pendingExperimentRedirects.Start()
+
+	egrp.Go(func() error {
+		<-ctx.Done()
+		removeHook()
+		pendingExperimentRedirects.Stop()
+		pendingExperimentRedirects.DeleteAll()
+		return nil
+	})
+}
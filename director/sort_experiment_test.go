@@ -0,0 +1,157 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func setupSortExperiment(t *testing.T, experimentConfig map[string]any) {
+	server_utils.ResetTestState()
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		require.NoError(t, ConfigSortExperiment())
+	})
+	viper.Set("Director.CacheSortMethod", "distance")
+	viper.Set("Director.SortExperiment", experimentConfig)
+	require.NoError(t, ConfigSortExperiment())
+}
+
+func TestConfigSortExperiment(t *testing.T) {
+	t.Run("disabled-without-sort-method", func(t *testing.T) {
+		setupSortExperiment(t, map[string]any{"Percentage": 10})
+		_, ok := assignSortExperimentArm(netip.MustParseAddr("192.0.2.1"))
+		assert.False(t, ok)
+		assert.False(t, computeSortExperimentStatus().Enabled)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		setupSortExperiment(t, map[string]any{"SortMethod": "adaptive", "Percentage": 10})
+		status := computeSortExperimentStatus()
+		assert.True(t, status.Enabled)
+		assert.Equal(t, "adaptive", status.Name)
+		assert.Equal(t, defaultSortExperimentRetryWindow.String(), status.RetryWindow)
+	})
+
+	t.Run("rejects-invalid-config", func(t *testing.T) {
+		server_utils.ResetTestState()
+		t.Cleanup(server_utils.ResetTestState)
+		viper.Set("Director.SortExperiment", map[string]any{"SortMethod": "fastest"})
+		assert.Error(t, ConfigSortExperiment())
+		viper.Set("Director.SortExperiment", map[string]any{"SortMethod": "adaptive", "Percentage": 150})
+		assert.Error(t, ConfigSortExperiment())
+		viper.Set("Director.SortExperiment", map[string]any{"SortMethod": "adaptive", "RetryWindow": "-1m"})
+		assert.Error(t, ConfigSortExperiment())
+	})
+}
+
+func TestAssignSortExperimentArm(t *testing.T) {
+	setupSortExperiment(t, map[string]any{"SortMethod": "adaptive", "Percentage": 25})
+
+	treatment := 0
+	for i := 0; i < 4000; i++ {
+		addr := netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)})
+		assignment, ok := assignSortExperimentArm(addr)
+		require.True(t, ok)
+		if assignment.arm == sortExperimentTreatment {
+			treatment++
+			assert.Equal(t, server_structs.AdaptiveType, assignment.method)
+		} else {
+			assert.Equal(t, server_structs.DistanceType, assignment.method)
+		}
+		// A client always sees the same arm
+		again, _ := assignSortExperimentArm(addr)
+		assert.Equal(t, assignment, again)
+	}
+	assert.InDelta(t, 1000, treatment, 150)
+
+	// The sort method follows the arm of the request
+	addr := netip.MustParseAddr("192.0.2.1")
+	assignment, _ := assignSortExperimentArm(addr)
+	assert.Equal(t, assignment.method, getSortMethod(withSortExperiment(context.Background(), addr)))
+	assert.Equal(t, server_structs.DistanceType, getSortMethod(context.Background()))
+
+	setupSortExperiment(t, map[string]any{"SortMethod": "adaptive", "Percentage": 100})
+	assignment, _ = assignSortExperimentArm(addr)
+	assert.Equal(t, sortExperimentTreatment, assignment.arm)
+}
+
+func TestSortExperimentResults(t *testing.T) {
+	setupSortExperiment(t, map[string]any{"Name": "rollout", "SortMethod": "adaptive", "Percentage": 50, "RetryWindow": "200ms"})
+	ctx, cancel := context.WithCancel(context.Background())
+	egrp, ctx := errgroup.WithContext(ctx)
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, egrp.Wait())
+	})
+	LaunchSortExperiment(ctx, egrp)
+
+	// Find a client of each arm
+	clients := map[sortExperimentArm]netip.Addr{}
+	for i := 1; len(clients) < 2; i++ {
+		addr := netip.AddrFrom4([4]byte{192, 0, 2, byte(i)})
+		assignment, _ := assignSortExperimentArm(addr)
+		if _, ok := clients[assignment.arm]; !ok {
+			clients[assignment.arm] = addr
+		}
+	}
+
+	// The control client retries its object, failing the first transfer
+	control := clients[sortExperimentControl]
+	recordSortExperimentRedirect(withSortExperiment(context.Background(), control), control, "/foo/bar")
+	recordSortExperimentRedirect(withSortExperiment(context.Background(), control), control, "/foo/bar")
+	treatment := clients[sortExperimentTreatment]
+	recordSortExperimentRedirect(withSortExperiment(context.Background(), treatment), treatment, "/foo/bar")
+	// Requests outside the experiment aren't counted
+	recordSortExperimentRedirect(context.Background(), control, "/foo/baz")
+
+	// The transfers without a retry succeed once the window passes
+	require.Eventually(t, func() bool {
+		status := computeSortExperimentStatus()
+		return status.Arms[0].TransfersSucceeded == 1 && status.Arms[1].TransfersSucceeded == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	r := gin.New()
+	r.GET("/sort_experiment", getSortExperimentHandler)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sort_experiment", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	status := SortExperimentStatus{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, "rollout", status.Name)
+	assert.Equal(t, []SortExperimentArmStatus{
+		{Arm: "control", SortMethod: "distance", Redirects: 2, TransfersSucceeded: 1, TransfersFailed: 1, SuccessRate: 0.5},
+		{Arm: "treatment", SortMethod: "adaptive", Redirects: 1, TransfersSucceeded: 1, SuccessRate: 1},
+	}, status.Arms)
+}
@@ -349,6 +349,83 @@ func TestSortServerAds(t *testing.T) {
 		assert.EqualValues(t, expected, sorted)
 	})
 
+	t.Run("test-unscheduled-downtime-sorted-last", func(t *testing.T) {
+		viper.Set("Director.CacheSortMethod", "distance")
+		filteredServersMutex.Lock()
+		filteredServers[madisonServer.Name] = topoUnscheduledFiltered
+		filteredServersMutex.Unlock()
+		t.Cleanup(func() {
+			filteredServersMutex.Lock()
+			delete(filteredServers, madisonServer.Name)
+			filteredServersMutex.Unlock()
+		})
+
+		// The closest server is in an unscheduled downtime, so it's only a last resort
+		expected := []server_structs.ServerAd{sdscServer, bigBenServer, kremlinServer,
+			daejeonServer, mcMurdoServer, nullIslandServer, madisonServer}
+		ctx := context.Background()
+		ctx = context.WithValue(ctx, ProjectContextKey{}, "pelican-client/1.0.0 project/test")
+		sorted, err := sortServerAds(ctx, clientIP, randAds, nil)
+		require.NoError(t, err)
+		assert.EqualValues(t, expected, sorted)
+
+		// With more servers than are returned, the healthy ones fill the result
+		viper.Set("Director.CacheSortMethod", "adaptive")
+		sorted, err = sortServerAds(ctx, clientIP, randAds, nil)
+		require.NoError(t, err)
+		assert.Len(t, sorted, serverResLimit)
+		assert.NotContains(t, sorted, madisonServer)
+	})
+
+	t.Run("test-draining-sorted-last", func(t *testing.T) {
+		viper.Set("Director.CacheSortMethod", "distance")
+		drainingMadison := madisonServer
+		drainingMadison.Draining = true
+		ads := []server_structs.ServerAd{sdscServer, drainingMadison, bigBenServer}
+
+		// The closest server is draining for a restart, so it's only a last resort
+		expected := []server_structs.ServerAd{sdscServer, bigBenServer, drainingMadison}
+		ctx := context.Background()
+		ctx = context.WithValue(ctx, ProjectContextKey{}, "pelican-client/1.0.0 project/test")
+		sorted, err := sortServerAds(ctx, clientIP, ads, nil)
+		require.NoError(t, err)
+		assert.EqualValues(t, expected, sorted)
+	})
+
+	t.Run("test-degraded-sorted-last", func(t *testing.T) {
+		viper.Set("Director.CacheSortMethod", "distanceAndLoad")
+		degradedMadison := madisonServer
+		degradedMadison.Degraded = "The data locations in service are running out of space"
+		ads := []server_structs.ServerAd{sdscServer, degradedMadison, bigBenServer}
+
+		ctx := context.Background()
+		ctx = context.WithValue(ctx, ProjectContextKey{}, "pelican-client/1.0.0 project/test")
+		sorted, err := sortServerAds(ctx, clientIP, ads, nil)
+		require.NoError(t, err)
+		assert.EqualValues(t, []server_structs.ServerAd{sdscServer, bigBenServer, degradedMadison}, sorted)
+
+		// The weight of a server with half its capacity left is halved
+		halfMadison := madisonServer
+		halfMadison.Capacity = 0.5
+		assert.Equal(t, 0.5, capacityMultiplier(halfMadison))
+		assert.Equal(t, 1.0, capacityMultiplier(madisonServer))
+	})
+
+	t.Run("test-content-hints", func(t *testing.T) {
+		hinted := madisonServer
+		hinted.ContentFilter = server_structs.NewContentFilter(10, 0.01)
+		hinted.ContentFilter.Add("/foo/bar.txt")
+		ads := []server_structs.ServerAd{sdscServer, hinted, bigBenServer}
+
+		availabilityMap := map[string]bool{bigBenServer.URL.String(): true}
+		applyContentHints("/foo/bar.txt", ads, availabilityMap)
+		assert.Equal(t, map[string]bool{bigBenServer.URL.String(): true, hinted.URL.String(): true}, availabilityMap)
+
+		availabilityMap = map[string]bool{}
+		applyContentHints("/foo/other.txt", ads, availabilityMap)
+		assert.Empty(t, availabilityMap)
+	})
+
 	t.Run("test-distanceAndLoad-sort-distance-only", func(t *testing.T) {
 		// Should return the same ordering as the distance test
 		viper.Set("Director.CacheSortMethod", "distanceAndLoad")
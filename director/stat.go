@@ -32,6 +32,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/metrics"
@@ -196,9 +197,11 @@ func (stat *ObjectStat) sendHeadReq(ctx context.Context, objectName string, data
 		// Request checksum
 		req.Header.Set("Want-Digest", "crc32c")
 	}
+	span := metrics.StartClientSpan(ctx, "director.sendHeadReq", req)
 
 	res, err := client.Do(req)
 	if err != nil {
+		metrics.EndSpan(span, err)
 		urlErr, ok := err.(*url.Error)
 		if !ok {
 			return nil, errors.Wrap(err, "unknown request error")
@@ -212,6 +215,7 @@ func (stat *ObjectStat) sendHeadReq(ctx context.Context, objectName string, data
 			return nil, errors.Wrap(err, "unknown request error")
 		}
 	}
+	metrics.EndRequestSpan(span, res.StatusCode)
 	if res.StatusCode == 404 {
 		return nil, &headReqNotFoundErr{"file not found on the server " + dataUrl.String()}
 	} else if res.StatusCode == 403 {
@@ -286,6 +290,17 @@ func getStatUtils(ads []server_structs.ServerAd) map[string]*serverStatUtil {
 //
 // Returns the object metadata with available urls, a message indicating the stat result, and error if any.
 func (stat *ObjectStat) queryServersForObject(ctx context.Context, objectName string, sType server_structs.ServerType, minimum, maximum int, options ...queryOption) (qResult queryResult) {
+	ctx, span := metrics.StartSpan(ctx, "director.queryServersForObject",
+		attribute.String("pelican.path", objectName),
+		attribute.String("pelican.server_types", sType.String()),
+	)
+	defer func() {
+		span.SetAttributes(
+			attribute.String("pelican.stat.status", string(qResult.Status)),
+			attribute.Int("pelican.stat.objects", len(qResult.Objects)),
+		)
+		span.End()
+	}()
 	cfg := queryConfig{}
 	for _, option := range options {
 		option(&cfg)
@@ -0,0 +1,250 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// The configuration of a regional sub-director, as read from Director.SubDirectors
+	SubDirectorConfig struct {
+		Url        string   `mapstructure:"Url"`
+		Networks   []string `mapstructure:"Networks"`
+		Namespaces []string `mapstructure:"Namespaces"`
+	}
+
+	// A parsed sub-director that client requests may be delegated to
+	subDirector struct {
+		url        *url.URL
+		networks   []netip.Prefix
+		namespaces []string
+	}
+)
+
+var (
+	subDirectors      []subDirector
+	subDirectorsMutex sync.RWMutex
+)
+
+// Timeout for forwarding a single server advertisement to a sub-director
+const subDirectorAdTimeout = 10 * time.Second
+
+// Populate the list of sub-directors from the Director.SubDirectors parameter.
+//
+// Entries with an invalid URL are an error; invalid networks are logged and skipped.
+func ConfigSubDirectors() error {
+	var configs []SubDirectorConfig
+	if err := param.Director_SubDirectors.Unmarshal(&configs); err != nil {
+		return errors.Wrap(err, "failed to parse Director.SubDirectors")
+	}
+
+	parsed := make([]subDirector, 0, len(configs))
+	for _, cfg := range configs {
+		subUrl, err := url.Parse(cfg.Url)
+		if err != nil || subUrl.Scheme == "" || subUrl.Host == "" {
+			return errors.Errorf("invalid URL %q for sub-director in Director.SubDirectors", cfg.Url)
+		}
+		subUrl.Path = strings.TrimSuffix(subUrl.Path, "/")
+		sd := subDirector{url: subUrl}
+		for _, network := range cfg.Networks {
+			if prefix, err := netip.ParsePrefix(network); err == nil {
+				sd.networks = append(sd.networks, prefix.Masked())
+			} else if addr, err := netip.ParseAddr(network); err == nil {
+				sd.networks = append(sd.networks, netip.PrefixFrom(addr, addr.BitLen()))
+			} else {
+				log.Warningf("Ignoring invalid network %q for sub-director %s", network, subUrl.String())
+			}
+		}
+		for _, ns := range cfg.Namespaces {
+			if ns = path.Clean("/" + ns); ns != "/" {
+				sd.namespaces = append(sd.namespaces, ns)
+			}
+		}
+		if len(sd.networks) == 0 && len(sd.namespaces) == 0 {
+			log.Warningf("Sub-director %s has no networks or namespaces configured; it will only receive server advertisements", subUrl.String())
+		}
+		log.Infof("Configured sub-director %s for networks %v and namespaces %v", subUrl.String(), sd.networks, sd.namespaces)
+		parsed = append(parsed, sd)
+	}
+
+	subDirectorsMutex.Lock()
+	defer subDirectorsMutex.Unlock()
+	subDirectors = parsed
+	return nil
+}
+
+// Find the sub-director, if any, that requests for the object path from the
+// client address should be delegated to.  Namespace delegations take precedence
+// over network delegations; among namespaces the longest matching prefix wins.
+func matchSubDirector(objectPath string, clientAddr netip.Addr) *subDirector {
+	subDirectorsMutex.RLock()
+	defer subDirectorsMutex.RUnlock()
+
+	var best *subDirector
+	bestLen := 0
+	for idx := range subDirectors {
+		for _, ns := range subDirectors[idx].namespaces {
+			if (objectPath == ns || strings.HasPrefix(objectPath, ns+"/")) && len(ns) > bestLen {
+				best = &subDirectors[idx]
+				bestLen = len(ns)
+			}
+		}
+	}
+	if best != nil || !clientAddr.IsValid() {
+		return best
+	}
+
+	clientAddr = clientAddr.Unmap()
+	for idx := range subDirectors {
+		for _, network := range subDirectors[idx].networks {
+			if network.Contains(clientAddr) {
+				return &subDirectors[idx]
+			}
+		}
+	}
+	return nil
+}
+
+// Determine the object path of a client request the same way ShortcutMiddleware
+// does, returning false for requests that are not object requests
+func delegatedObjectPath(reqPath string) (string, bool) {
+	if strings.HasPrefix(reqPath, "/.well-known/") {
+		return "", false
+	}
+	for _, prefix := range []string{"/api/v1.0/director/object", "/api/v1.0/director/origin"} {
+		if strings.HasPrefix(reqPath, prefix+"/") {
+			return path.Clean(strings.TrimPrefix(reqPath, prefix)), true
+		}
+	}
+	if strings.HasPrefix(reqPath, "/api/") {
+		return "", false
+	}
+	return path.Clean("/" + reqPath), true
+}
+
+// Middleware redirecting object requests to the regional sub-director responsible for
+// the client's network or the requested namespace.  The original path and query are
+// preserved, so the sub-director handles the request exactly as this director would have.
+func SubDirectorMiddleware() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		objectPath, ok := delegatedObjectPath(ginCtx.Request.URL.Path)
+		if !ok {
+			ginCtx.Next()
+			return
+		}
+		clientAddr, _ := netip.ParseAddr(ginCtx.ClientIP())
+		// Delegate renamed namespaces by their new prefix
+		objectPath, _, _ = resolveNamespaceAlias(objectPath)
+		sd := matchSubDirector(objectPath, clientAddr)
+		if sd == nil {
+			ginCtx.Next()
+			return
+		}
+
+		redirectUrl := *sd.url
+		redirectUrl.Path = sd.url.Path + ginCtx.Request.URL.Path
+		redirectUrl.RawQuery = ginCtx.Request.URL.RawQuery
+		log.Debugf("Delegating request for %s from client %s to sub-director %s", objectPath, clientAddr.String(), sd.url.String())
+		ginCtx.Header(server_structs.SubDirectorHeader, sd.url.String())
+		ginCtx.Redirect(http.StatusTemporaryRedirect, redirectUrl.String())
+		ginCtx.Abort()
+	}
+}
+
+// Forward a server advertisement received by this director to each configured
+// sub-director so that every tier sees the same set of origins and caches.
+//
+// The advertisement keeps its original token, which the sub-director verifies
+// against the registry exactly as if the server had advertised to it directly.
+// Advertisements that were themselves forwarded are not forwarded again.
+func forwardAdToSubDirectors(engineCtx context.Context, ginCtx *gin.Context, sType server_structs.ServerType) {
+	if ginCtx.GetHeader(server_structs.ForwardedAdHeader) != "" {
+		return
+	}
+	subDirectorsMutex.RLock()
+	targets := make([]*url.URL, 0, len(subDirectors))
+	for _, sd := range subDirectors {
+		targets = append(targets, sd.url)
+	}
+	subDirectorsMutex.RUnlock()
+	if len(targets) == 0 {
+		return
+	}
+
+	rawBody, ok := ginCtx.Get(gin.BodyBytesKey)
+	if !ok {
+		log.Warningln("Unable to forward", sType, "advertisement to sub-directors: request body is unavailable")
+		return
+	}
+	body, ok := rawBody.([]byte)
+	if !ok {
+		return
+	}
+	authHeader := ginCtx.GetHeader("Authorization")
+	userAgent := ginCtx.GetHeader("User-Agent")
+	endpoint := "/api/v1.0/director/registerOrigin"
+	if sType == server_structs.CacheType {
+		endpoint = "/api/v1.0/director/registerCache"
+	}
+
+	client := http.Client{Transport: config.GetTransport()}
+	for _, target := range targets {
+		go func(target *url.URL) {
+			ctx, cancel := context.WithTimeout(engineCtx, subDirectorAdTimeout)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.JoinPath(endpoint).String(), bytes.NewReader(body))
+			if err != nil {
+				log.Warningln("Failed to create advertisement request for sub-director", target.String(), ":", err)
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", authHeader)
+			req.Header.Set("User-Agent", userAgent)
+			req.Header.Set(server_structs.ForwardedAdHeader, param.Server_ExternalWebUrl.GetString())
+			resp, err := client.Do(req)
+			if err != nil {
+				log.Warningf("Failed to forward %s advertisement to sub-director %s: %v", sType, target.String(), err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				respBody, _ := io.ReadAll(resp.Body)
+				log.Warningf("Sub-director %s rejected forwarded %s advertisement with status %d: %s", target.String(), sType, resp.StatusCode, string(respBody))
+			}
+		}(target)
+	}
+}
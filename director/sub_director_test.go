@@ -0,0 +1,169 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func setupSubDirectors(t *testing.T, subDirectorConfig []map[string]any) {
+	server_utils.ResetTestState()
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		subDirectorsMutex.Lock()
+		subDirectors = nil
+		subDirectorsMutex.Unlock()
+	})
+	viper.Set("Director.SubDirectors", subDirectorConfig)
+	require.NoError(t, ConfigSubDirectors())
+}
+
+func TestMatchSubDirector(t *testing.T) {
+	setupSubDirectors(t, []map[string]any{
+		{"Url": "https://eu-director.example.org", "Networks": []string{"192.0.2.0/24", "2001:db8::/32", "not-a-network"}},
+		{"Url": "https://hep-director.example.org", "Namespaces": []string{"/hep"}},
+		{"Url": "https://cms-director.example.org", "Namespaces": []string{"/hep/cms/"}},
+	})
+
+	match := func(objectPath, addr string) string {
+		clientAddr, _ := netip.ParseAddr(addr)
+		if sd := matchSubDirector(objectPath, clientAddr); sd != nil {
+			return sd.url.Host
+		}
+		return ""
+	}
+
+	assert.Equal(t, "eu-director.example.org", match("/foo/bar", "192.0.2.17"))
+	assert.Equal(t, "eu-director.example.org", match("/foo/bar", "::ffff:192.0.2.17"))
+	assert.Equal(t, "eu-director.example.org", match("/foo/bar", "2001:db8::1"))
+	assert.Equal(t, "", match("/foo/bar", "198.51.100.1"))
+	assert.Equal(t, "hep-director.example.org", match("/hep/atlas/file", "198.51.100.1"))
+	assert.Equal(t, "", match("/hepatitis/file", "198.51.100.1"))
+	// Namespace delegations take precedence over networks, and the longest prefix wins
+	assert.Equal(t, "hep-director.example.org", match("/hep", "192.0.2.17"))
+	assert.Equal(t, "cms-director.example.org", match("/hep/cms/file", "192.0.2.17"))
+
+	t.Run("invalid-url", func(t *testing.T) {
+		viper.Set("Director.SubDirectors", []map[string]any{{"Url": "eu-director"}})
+		assert.Error(t, ConfigSubDirectors())
+	})
+}
+
+func TestSubDirectorMiddleware(t *testing.T) {
+	setupSubDirectors(t, []map[string]any{
+		{"Url": "https://eu-director.example.org/", "Networks": []string{"192.0.2.0/24"}},
+	})
+
+	r := gin.New()
+	r.Use(SubDirectorMiddleware())
+	r.NoRoute(func(ctx *gin.Context) { ctx.String(http.StatusOK, "local") })
+
+	doRequest := func(method, target, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("delegated-shortcut", func(t *testing.T) {
+		w := doRequest(http.MethodGet, "/foo/bar?directread", "192.0.2.10:1234")
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+		assert.Equal(t, "https://eu-director.example.org/foo/bar?directread", w.Header().Get("Location"))
+		assert.Equal(t, "https://eu-director.example.org", w.Header().Get(server_structs.SubDirectorHeader))
+	})
+
+	t.Run("delegated-api", func(t *testing.T) {
+		w := doRequest(http.MethodPut, "/api/v1.0/director/origin/foo/bar", "192.0.2.10:1234")
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+		assert.Equal(t, "https://eu-director.example.org/api/v1.0/director/origin/foo/bar", w.Header().Get("Location"))
+	})
+
+	t.Run("other-network", func(t *testing.T) {
+		w := doRequest(http.MethodGet, "/foo/bar", "198.51.100.1:1234")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "local", w.Body.String())
+	})
+
+	t.Run("non-object-requests", func(t *testing.T) {
+		for _, target := range []string{"/api/v1.0/director/registerCache", "/.well-known/openid-configuration", "/api/v1.0/health"} {
+			w := doRequest(http.MethodGet, target, "192.0.2.10:1234")
+			assert.Equal(t, http.StatusOK, w.Code, target)
+		}
+	})
+}
+
+func TestForwardAdToSubDirectors(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	subDirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer subDirector.Close()
+
+	setupSubDirectors(t, []map[string]any{{"Url": subDirector.URL}})
+	viper.Set("Server.ExternalWebUrl", "https://top-director.example.org")
+
+	newContext := func(forwarded bool) *gin.Context {
+		ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ginCtx.Request = httptest.NewRequest(http.MethodPost, "/api/v1.0/director/registerCache", nil)
+		ginCtx.Request.Header.Set("Authorization", "Bearer abc")
+		if forwarded {
+			ginCtx.Request.Header.Set(server_structs.ForwardedAdHeader, "https://top-director.example.org")
+		}
+		ginCtx.Set(gin.BodyBytesKey, []byte(`{"name":"test-cache"}`))
+		return ginCtx
+	}
+
+	forwardAdToSubDirectors(context.Background(), newContext(false), server_structs.CacheType)
+	select {
+	case req := <-received:
+		assert.Equal(t, "/api/v1.0/director/registerCache", req.URL.Path)
+		assert.Equal(t, "Bearer abc", req.Header.Get("Authorization"))
+		assert.Equal(t, "https://top-director.example.org", req.Header.Get(server_structs.ForwardedAdHeader))
+		assert.Equal(t, `{"name":"test-cache"}`, <-bodies)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the forwarded advertisement")
+	}
+
+	// Advertisements that were already forwarded are not forwarded again
+	forwardAdToSubDirectors(context.Background(), newContext(true), server_structs.CacheType)
+	select {
+	case <-received:
+		t.Fatal("Forwarded advertisement was forwarded a second time")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
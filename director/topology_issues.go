@@ -0,0 +1,463 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	TopologyIssueKind string
+
+	// A single problem found with a server entry in the topology
+	TopologyIssue struct {
+		Kind      TopologyIssueKind `json:"kind"`
+		Endpoint  string            `json:"endpoint"`
+		Detail    string            `json:"detail"`
+		FirstSeen time.Time         `json:"firstSeen"`
+	}
+
+	// The problems found with one topology resource, aggregated across all
+	// the namespaces that list it
+	TopologyIssueReport struct {
+		Resource     string          `json:"resource"`
+		ServerType   string          `json:"serverType"`
+		Endpoint     string          `json:"endpoint"`
+		AuthEndpoint string          `json:"authEndpoint"`
+		Namespaces   []string        `json:"namespaces"`
+		Issues       []TopologyIssue `json:"issues"`
+		CheckedAt    time.Time       `json:"checkedAt"`
+	}
+
+	// Collects the topology server entries seen by AdvertiseOSDF, keyed by
+	// server type and resource name
+	topologyIssueCollector struct {
+		reports map[string]*TopologyIssueReport
+	}
+
+	topologyIssueWebhookPayload struct {
+		Director string                `json:"director"`
+		Reports  []TopologyIssueReport `json:"reports"`
+	}
+
+	listTopologyIssuesRequest struct {
+		Resource   string `form:"resource"`
+		ServerType string `form:"server_type"`
+		Kind       string `form:"kind"`
+	}
+)
+
+const (
+	TopologyIssueInvalidUrl         TopologyIssueKind = "invalid_url"
+	TopologyIssueDNSLookup          TopologyIssueKind = "dns_lookup_failed"
+	TopologyIssueSelfSignedCert     TopologyIssueKind = "self_signed_certificate"
+	TopologyIssueInvalidCertificate TopologyIssueKind = "invalid_certificate"
+	TopologyIssuePortMismatch       TopologyIssueKind = "port_mismatch"
+)
+
+// The timeout of each DNS lookup and TLS handshake made against a topology server
+const topologyProbeTimeout = 5 * time.Second
+
+// The number of topology servers probed at once
+const topologyProbeConcurrency = 8
+
+var (
+	topologyIssueReports      map[string]*TopologyIssueReport
+	topologyIssueReportsMutex sync.RWMutex
+
+	// Set while a round of probes is in flight so that a slow round is never
+	// stacked on top of by the next topology reload
+	topologyIssueCheckRunning atomic.Bool
+
+	// Overridable in tests
+	topologyLookupHost = net.DefaultResolver.LookupHost
+)
+
+func newTopologyIssueCollector() *topologyIssueCollector {
+	return &topologyIssueCollector{reports: make(map[string]*TopologyIssueReport)}
+}
+
+// Parse an endpoint from topology the same way parseServerAdFromTopology does,
+// but return the error rather than papering over it
+func parseTopologyEndpoint(endpoint string, scheme string) (*url.URL, error) {
+	if endpoint == "" {
+		return nil, errors.New("endpoint is empty")
+	}
+	if !strings.HasPrefix(endpoint, "http") {
+		endpoint = scheme + "://" + endpoint
+	}
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpointUrl.Hostname() == "" {
+		return nil, errors.New("endpoint has no hostname")
+	}
+	return endpointUrl, nil
+}
+
+func (report *TopologyIssueReport) addIssue(kind TopologyIssueKind, endpoint string, detail string) {
+	for _, issue := range report.Issues {
+		if issue.Kind == kind && issue.Endpoint == endpoint {
+			return
+		}
+	}
+	report.Issues = append(report.Issues, TopologyIssue{Kind: kind, Endpoint: endpoint, Detail: detail})
+}
+
+// Record a server entry listed by a topology namespace, running the checks
+// that need nothing but the entry itself
+func (c *topologyIssueCollector) add(server server_structs.TopoServer, serverType server_structs.ServerType, namespace string) {
+	key := serverType.String() + "/" + server.Resource
+	report, ok := c.reports[key]
+	if !ok {
+		report = &TopologyIssueReport{
+			Resource:     server.Resource,
+			ServerType:   serverType.String(),
+			Endpoint:     server.Endpoint,
+			AuthEndpoint: server.AuthEndpoint,
+			Issues:       []TopologyIssue{},
+		}
+		c.reports[key] = report
+
+		if _, err := parseTopologyEndpoint(server.Endpoint, "http"); err != nil {
+			report.addIssue(TopologyIssueInvalidUrl, server.Endpoint, fmt.Sprintf("Invalid unauthenticated endpoint: %v", err))
+		}
+		if server.AuthEndpoint != "" {
+			if _, err := parseTopologyEndpoint(server.AuthEndpoint, "https"); err != nil {
+				report.addIssue(TopologyIssueInvalidUrl, server.AuthEndpoint, fmt.Sprintf("Invalid authenticated endpoint: %v", err))
+			}
+		}
+	} else {
+		// The same resource listed under another namespace should point at the same place
+		checkTopologyPortsMatch(report, report.Endpoint, server.Endpoint, "http")
+		checkTopologyPortsMatch(report, report.AuthEndpoint, server.AuthEndpoint, "https")
+	}
+	report.Namespaces = append(report.Namespaces, namespace)
+}
+
+func checkTopologyPortsMatch(report *TopologyIssueReport, existing string, other string, scheme string) {
+	if existing == "" || other == "" || existing == other {
+		return
+	}
+	existingUrl, err := parseTopologyEndpoint(existing, scheme)
+	if err != nil {
+		return
+	}
+	otherUrl, err := parseTopologyEndpoint(other, scheme)
+	if err != nil {
+		return
+	}
+	if existingUrl.Hostname() == otherUrl.Hostname() && existingUrl.Port() != otherUrl.Port() {
+		report.addIssue(TopologyIssuePortMismatch, other,
+			fmt.Sprintf("Resource is listed with port %q in one namespace and %q in another", existingUrl.Port(), otherUrl.Port()))
+	}
+}
+
+func (c *topologyIssueCollector) list() []*TopologyIssueReport {
+	reports := make([]*TopologyIssueReport, 0, len(c.reports))
+	for _, report := range c.reports {
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+func isSelfSignedCert(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	return cert.CheckSignatureFrom(cert) == nil
+}
+
+// Check the certificate served by an authenticated endpoint against the
+// director's trust roots, and that the port speaks TLS at all
+func probeTopologyTLS(ctx context.Context, report *TopologyIssueReport, endpointUrl *url.URL) {
+	port := endpointUrl.Port()
+	if port == "" {
+		port = "443"
+	}
+	var roots *x509.CertPool
+	if tr := config.GetTransport(); tr != nil && tr.TLSClientConfig != nil {
+		roots = tr.TLSClientConfig.RootCAs
+	}
+	// Verification is done by hand below so the failure can be classified
+	dialer := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+	dialCtx, cancel := context.WithTimeout(ctx, topologyProbeTimeout)
+	defer cancel()
+	conn, err := dialer.DialContext(dialCtx, "tcp", net.JoinHostPort(endpointUrl.Hostname(), port))
+	if err != nil {
+		var recordErr tls.RecordHeaderError
+		if errors.As(err, &recordErr) {
+			report.addIssue(TopologyIssuePortMismatch, report.AuthEndpoint,
+				fmt.Sprintf("Authenticated endpoint port %s does not speak TLS", port))
+		}
+		// Servers that are down are the business of downtime and health tests, not this report
+		log.Debugf("Failed to probe TLS for topology resource %s at %s: %v", report.Resource, endpointUrl.Host, err)
+		return
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		report.addIssue(TopologyIssueInvalidCertificate, report.AuthEndpoint, "Server presented no certificate")
+		return
+	}
+	if isSelfSignedCert(certs[0]) {
+		report.addIssue(TopologyIssueSelfSignedCert, report.AuthEndpoint,
+			fmt.Sprintf("Server presented a self-signed certificate for %q", certs[0].Subject.String()))
+		return
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       endpointUrl.Hostname(),
+	})
+	if err != nil {
+		report.addIssue(TopologyIssueInvalidCertificate, report.AuthEndpoint, err.Error())
+	}
+}
+
+// Run the checks against a topology resource that need the network
+func probeTopologyResource(ctx context.Context, report *TopologyIssueReport) {
+	resolved := map[string]bool{}
+	for _, endpoint := range []struct {
+		raw    string
+		scheme string
+	}{{report.Endpoint, "http"}, {report.AuthEndpoint, "https"}} {
+		endpointUrl, err := parseTopologyEndpoint(endpoint.raw, endpoint.scheme)
+		if err != nil {
+			continue
+		}
+		host := endpointUrl.Hostname()
+		if _, ok := resolved[host]; ok || net.ParseIP(host) != nil {
+			resolved[host] = true
+			continue
+		}
+		lookupCtx, cancel := context.WithTimeout(ctx, topologyProbeTimeout)
+		_, err = topologyLookupHost(lookupCtx, host)
+		cancel()
+		resolved[host] = err == nil
+		if err != nil {
+			report.addIssue(TopologyIssueDNSLookup, endpoint.raw, fmt.Sprintf("Failed to resolve %s: %v", host, err))
+		}
+	}
+
+	if report.AuthEndpoint == "" {
+		return
+	}
+	authUrl, err := parseTopologyEndpoint(report.AuthEndpoint, "https")
+	if err != nil || !resolved[authUrl.Hostname()] {
+		return
+	}
+	probeTopologyTLS(ctx, report, authUrl)
+}
+
+// Probe the topology resources, then replace the stored reports with the
+// results and notify the configured webhook of any issue not seen before
+func analyzeTopologyIssues(ctx context.Context, reports []*TopologyIssueReport) {
+	egrp := errgroup.Group{}
+	egrp.SetLimit(topologyProbeConcurrency)
+	for _, report := range reports {
+		report := report
+		egrp.Go(func() error {
+			probeTopologyResource(ctx, report)
+			return nil
+		})
+	}
+	_ = egrp.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	newReports := make(map[string]*TopologyIssueReport, len(reports))
+	newIssues := []TopologyIssueReport{}
+	kindCounts := map[TopologyIssueKind]int{}
+
+	topologyIssueReportsMutex.Lock()
+	for _, report := range reports {
+		key := report.ServerType + "/" + report.Resource
+		previous := topologyIssueReports[key]
+		report.CheckedAt = now
+		fresh := []TopologyIssue{}
+		for idx := range report.Issues {
+			issue := &report.Issues[idx]
+			issue.FirstSeen = now
+			found := false
+			if previous != nil {
+				for _, old := range previous.Issues {
+					if old.Kind == issue.Kind && old.Endpoint == issue.Endpoint {
+						issue.FirstSeen = old.FirstSeen
+						found = true
+						break
+					}
+				}
+			}
+			if !found {
+				fresh = append(fresh, *issue)
+			}
+			kindCounts[issue.Kind]++
+		}
+		newReports[key] = report
+		if len(fresh) > 0 {
+			freshReport := *report
+			freshReport.Issues = fresh
+			newIssues = append(newIssues, freshReport)
+		}
+	}
+	topologyIssueReports = newReports
+	topologyIssueReportsMutex.Unlock()
+
+	metrics.PelicanDirectorTopologyIssues.Reset()
+	for kind, count := range kindCounts {
+		metrics.PelicanDirectorTopologyIssues.WithLabelValues(string(kind)).Set(float64(count))
+	}
+
+	for _, report := range newIssues {
+		for _, issue := range report.Issues {
+			log.Warningf("Topology %s resource %s has an issue (%s) with endpoint %s: %s",
+				strings.ToLower(report.ServerType), report.Resource, issue.Kind, issue.Endpoint, issue.Detail)
+		}
+	}
+	if len(newIssues) > 0 && param.Director_TopologyIssueWebhook.GetString() != "" {
+		if err := notifyTopologyIssueWebhook(ctx, newIssues); err != nil {
+			log.Warningf("Failed to notify the topology issue webhook: %v", err)
+		}
+	}
+}
+
+// POST newly found topology issues to the configured webhook
+func notifyTopologyIssueWebhook(ctx context.Context, reports []TopologyIssueReport) error {
+	webhookUrl := param.Director_TopologyIssueWebhook.GetString()
+	body, err := json.Marshal(topologyIssueWebhookPayload{
+		Director: param.Server_ExternalWebUrl.GetString(),
+		Reports:  reports,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal topology issue reports")
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, webhookUrl, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request to %s", webhookUrl)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pelican-director/"+config.GetVersion())
+	client := http.Client{Transport: config.GetTransport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to send request to %s", webhookUrl)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("webhook %s responded with status %d", webhookUrl, resp.StatusCode)
+	}
+	return nil
+}
+
+// Kick off the issue analysis of the servers AdvertiseOSDF found in topology.
+// The probes run in the background since a full round can take a while.
+func launchTopologyIssueChecks(ctx context.Context, collector *topologyIssueCollector) {
+	if !param.Director_EnableTopologyIssueChecks.GetBool() {
+		return
+	}
+	if !topologyIssueCheckRunning.CompareAndSwap(false, true) {
+		log.Debug("Skipping topology issue checks as the previous round is still running")
+		return
+	}
+	reports := collector.list()
+	go func() {
+		defer topologyIssueCheckRunning.Store(false)
+		analyzeTopologyIssues(ctx, reports)
+	}()
+}
+
+func listTopologyIssues(queryParams listTopologyIssuesRequest) []TopologyIssueReport {
+	topologyIssueReportsMutex.RLock()
+	defer topologyIssueReportsMutex.RUnlock()
+
+	result := []TopologyIssueReport{}
+	for _, report := range topologyIssueReports {
+		if queryParams.Resource != "" && report.Resource != queryParams.Resource {
+			continue
+		}
+		if queryParams.ServerType != "" && !strings.EqualFold(report.ServerType, queryParams.ServerType) {
+			continue
+		}
+		filtered := *report
+		filtered.Issues = []TopologyIssue{}
+		for _, issue := range report.Issues {
+			if queryParams.Kind == "" || string(issue.Kind) == queryParams.Kind {
+				filtered.Issues = append(filtered.Issues, issue)
+			}
+		}
+		if len(filtered.Issues) == 0 {
+			continue
+		}
+		filtered.Namespaces = append([]string{}, report.Namespaces...)
+		result = append(result, filtered)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Resource == result[j].Resource {
+			return result[i].ServerType < result[j].ServerType
+		}
+		return result[i].Resource < result[j].Resource
+	})
+	return result
+}
+
+func listTopologyIssuesHandler(ctx *gin.Context) {
+	queryParams := listTopologyIssuesRequest{}
+	if ctx.ShouldBindQuery(&queryParams) != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid query parameters",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, listTopologyIssues(queryParams))
+}
@@ -0,0 +1,175 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func setupTopologyIssueTest(t *testing.T) {
+	// Let any round started by the AdvertiseOSDF tests finish so it does not clobber the reports
+	require.Eventually(t, func() bool { return !topologyIssueCheckRunning.Load() }, 30*time.Second, 10*time.Millisecond)
+	server_utils.ResetTestState()
+	oldLookup := topologyLookupHost
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		topologyLookupHost = oldLookup
+		topologyIssueReportsMutex.Lock()
+		topologyIssueReports = nil
+		topologyIssueReportsMutex.Unlock()
+	})
+	topologyLookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if strings.HasSuffix(host, ".invalid") {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return []string{"192.0.2.1"}, nil
+	}
+}
+
+func issueKinds(report *TopologyIssueReport) []TopologyIssueKind {
+	kinds := []TopologyIssueKind{}
+	for _, issue := range report.Issues {
+		kinds = append(kinds, issue.Kind)
+	}
+	return kinds
+}
+
+func TestTopologyIssueCollector(t *testing.T) {
+	collector := newTopologyIssueCollector()
+	collector.add(server_structs.TopoServer{Resource: "GOOD", Endpoint: "good.example.org:8000", AuthEndpoint: "good.example.org:8443"}, server_structs.CacheType, "/a")
+	collector.add(server_structs.TopoServer{Resource: "GOOD", Endpoint: "good.example.org:8000", AuthEndpoint: "good.example.org:8443"}, server_structs.CacheType, "/b")
+	collector.add(server_structs.TopoServer{Resource: "BAD_URL", Endpoint: "bad.example.org:port", AuthEndpoint: ":8443"}, server_structs.OriginType, "/a")
+	collector.add(server_structs.TopoServer{Resource: "PORTS", Endpoint: "ports.example.org:1094", AuthEndpoint: "ports.example.org:1095"}, server_structs.OriginType, "/a")
+	collector.add(server_structs.TopoServer{Resource: "PORTS", Endpoint: "ports.example.org:1094", AuthEndpoint: "ports.example.org:1096"}, server_structs.OriginType, "/b")
+
+	good := collector.reports["Cache/GOOD"]
+	require.NotNil(t, good)
+	assert.Empty(t, good.Issues)
+	assert.Equal(t, []string{"/a", "/b"}, good.Namespaces)
+
+	badUrl := collector.reports["Origin/BAD_URL"]
+	require.NotNil(t, badUrl)
+	assert.Equal(t, []TopologyIssueKind{TopologyIssueInvalidUrl, TopologyIssueInvalidUrl}, issueKinds(badUrl))
+
+	ports := collector.reports["Origin/PORTS"]
+	require.NotNil(t, ports)
+	require.Len(t, ports.Issues, 1)
+	assert.Equal(t, TopologyIssuePortMismatch, ports.Issues[0].Kind)
+	assert.Equal(t, "ports.example.org:1096", ports.Issues[0].Endpoint)
+}
+
+func TestProbeTopologyResource(t *testing.T) {
+	setupTopologyIssueTest(t)
+
+	selfSigned := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(selfSigned.Close)
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(plain.Close)
+
+	t.Run("self-signed", func(t *testing.T) {
+		host := strings.TrimPrefix(selfSigned.URL, "https://")
+		report := &TopologyIssueReport{Resource: "SELF", Endpoint: host, AuthEndpoint: host}
+		probeTopologyResource(context.Background(), report)
+		assert.Equal(t, []TopologyIssueKind{TopologyIssueSelfSignedCert}, issueKinds(report))
+	})
+
+	t.Run("auth-port-without-tls", func(t *testing.T) {
+		host := strings.TrimPrefix(plain.URL, "http://")
+		report := &TopologyIssueReport{Resource: "PLAIN", Endpoint: host, AuthEndpoint: host}
+		probeTopologyResource(context.Background(), report)
+		assert.Equal(t, []TopologyIssueKind{TopologyIssuePortMismatch}, issueKinds(report))
+	})
+
+	t.Run("dead-dns", func(t *testing.T) {
+		report := &TopologyIssueReport{Resource: "DEAD", Endpoint: "dead.invalid:8000", AuthEndpoint: "dead.invalid:8443"}
+		probeTopologyResource(context.Background(), report)
+		// The host is only looked up once, and the TLS probe is skipped as it does not resolve
+		assert.Equal(t, []TopologyIssueKind{TopologyIssueDNSLookup}, issueKinds(report))
+	})
+}
+
+func TestAnalyzeTopologyIssues(t *testing.T) {
+	setupTopologyIssueTest(t)
+
+	var mutex sync.Mutex
+	payloads := []topologyIssueWebhookPayload{}
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := topologyIssueWebhookPayload{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		mutex.Lock()
+		payloads = append(payloads, payload)
+		mutex.Unlock()
+	}))
+	t.Cleanup(webhook.Close)
+	viper.Set("Director.TopologyIssueWebhook", webhook.URL)
+
+	collect := func(servers ...server_structs.TopoServer) []*TopologyIssueReport {
+		collector := newTopologyIssueCollector()
+		for _, server := range servers {
+			collector.add(server, server_structs.OriginType, "/foo")
+		}
+		return collector.list()
+	}
+	dead := server_structs.TopoServer{Resource: "DEAD", Endpoint: "dead.invalid:1094"}
+	good := server_structs.TopoServer{Resource: "GOOD", Endpoint: "192.0.2.1:1094"}
+
+	analyzeTopologyIssues(context.Background(), collect(dead, good))
+	issues := listTopologyIssues(listTopologyIssuesRequest{})
+	require.Len(t, issues, 1)
+	assert.Equal(t, "DEAD", issues[0].Resource)
+	require.Len(t, issues[0].Issues, 1)
+	firstSeen := issues[0].Issues[0].FirstSeen
+
+	mutex.Lock()
+	require.Len(t, payloads, 1)
+	assert.Equal(t, "DEAD", payloads[0].Reports[0].Resource)
+	mutex.Unlock()
+
+	// Issues already reported keep their first sighting and are not sent again
+	analyzeTopologyIssues(context.Background(), collect(dead, good))
+	issues = listTopologyIssues(listTopologyIssuesRequest{})
+	require.Len(t, issues, 1)
+	assert.Equal(t, firstSeen, issues[0].Issues[0].FirstSeen)
+	mutex.Lock()
+	assert.Len(t, payloads, 1)
+	mutex.Unlock()
+
+	assert.Empty(t, listTopologyIssues(listTopologyIssuesRequest{Kind: string(TopologyIssueSelfSignedCert)}))
+	assert.Empty(t, listTopologyIssues(listTopologyIssuesRequest{ServerType: "cache"}))
+	assert.Len(t, listTopologyIssues(listTopologyIssuesRequest{Resource: "DEAD", ServerType: "origin"}), 1)
+
+	// A fixed entry drops out of the report
+	analyzeTopologyIssues(context.Background(), collect(good))
+	assert.Empty(t, listTopologyIssues(listTopologyIssuesRequest{}))
+}
@@ -0,0 +1,67 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// Trace a redirect request in a server span, which continues the trace of the client.  The
+// spans of the steps of the redirect are started from the context of the request.
+func startRedirectSpan(ginCtx *gin.Context, name string) trace.Span {
+	req, span := metrics.StartRequestSpan(ginCtx.Request, name)
+	ginCtx.Request = req
+	return span
+}
+
+// Look up the namespace of the path and the servers serving it in a span
+func getAdsForPathTraced(ctx context.Context, reqPath string) (server_structs.NamespaceAdV2, []server_structs.ServerAd, []server_structs.ServerAd) {
+	_, span := metrics.StartSpan(ctx, "director.getAdsForPath", attribute.String("pelican.path", reqPath))
+	defer span.End()
+	namespaceAd, originAds, cacheAds := getAdsForPath(reqPath)
+	span.SetAttributes(
+		attribute.String("pelican.namespace", namespaceAd.Path),
+		attribute.Int("pelican.origins", len(originAds)),
+		attribute.Int("pelican.caches", len(cacheAds)),
+	)
+	return namespaceAd, originAds, cacheAds
+}
+
+// Redirect the client to the server, recording the redirect in the metrics and the trace
+// of the request.  The caller sets the other headers of the response beforehand.
+func issueRedirect(ginCtx *gin.Context, nsPath string, ad server_structs.ServerAd, location string) {
+	_, span := metrics.StartSpan(ginCtx.Request.Context(), "director.issueRedirect",
+		attribute.String("pelican.namespace", nsPath),
+		attribute.String("pelican.server.name", ad.Name),
+		attribute.String("pelican.server.type", ad.Type),
+		attribute.String("pelican.server.url", ad.URL.String()),
+		attribute.Bool("pelican.server.broker_only", ad.BrokerOnly),
+	)
+	defer span.End()
+	recordNamespaceRedirect(nsPath, ad)
+	style := writeRedirect(ginCtx, nsPath, location)
+	span.SetAttributes(attribute.String("pelican.redirect_style", string(style)))
+}
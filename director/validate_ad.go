@@ -0,0 +1,291 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// What the director would do with one namespace of an advertisement
+	NamespaceValidation struct {
+		Path     string `json:"path"`
+		Accepted bool   `json:"accepted"`
+		// The capabilities clients get for the namespace at this server, which
+		// need both the namespace and the server to allow an operation
+		Caps    server_structs.Capabilities `json:"capabilities"`
+		Issuers []string                    `json:"issuers"`
+		// Why the namespace would be rejected
+		Errors []string `json:"errors"`
+		// Problems that wouldn't stop the director from accepting the namespace
+		Warnings []string `json:"warnings"`
+	}
+
+	// What the director would do with an advertisement, were it registered
+	AdValidation struct {
+		Name      string `json:"name"`
+		Type      string `json:"type"`
+		AdVersion int    `json:"adVersion"`
+		Accepted  bool   `json:"accepted"`
+		// Whether a token was given and the registry vouched for the server with it
+		TokenVerified bool                        `json:"tokenVerified"`
+		Caps          server_structs.Capabilities `json:"capabilities"`
+		Namespaces    []NamespaceValidation       `json:"namespaces"`
+		// Registered servers and namespaces the advertisement collides with
+		Conflicts []string `json:"conflicts"`
+		// Why the advertisement would be rejected
+		Errors []string `json:"errors"`
+		// Problems that wouldn't stop the director from accepting the advertisement
+		Warnings []string `json:"warnings"`
+	}
+
+	validateAdRequest struct {
+		ServerType string `form:"server_type" binding:"required"` // "cache" or "origin"
+	}
+)
+
+// Report the effective capabilities of a namespace at a server.  Writes, listings,
+// and direct reads are only redirected to servers that allow them as well.
+func effectiveNamespaceCaps(serverCaps, nsCaps server_structs.Capabilities, sType server_structs.ServerType) server_structs.Capabilities {
+	caps := nsCaps
+	if sType == server_structs.OriginType {
+		caps.Writes = caps.Writes && serverCaps.Writes
+		caps.Listings = caps.Listings && serverCaps.Listings
+		caps.DirectReads = caps.DirectReads && serverCaps.DirectReads
+	}
+	return caps
+}
+
+// Check the advertisement against the servers and namespaces the director already
+// knows about
+func findAdConflicts(sAd server_structs.ServerAd, namespaces []server_structs.NamespaceAdV2) (conflicts []string) {
+	// Servers are keyed by their URL, ignoring the http/https difference between
+	// topology and Pelican servers
+	hostKey := func(u string) string {
+		return strings.TrimPrefix(strings.TrimPrefix(u, "https://"), "http://")
+	}
+	nsPaths := make(map[string]server_structs.NamespaceAdV2, len(namespaces))
+	for _, ns := range namespaces {
+		nsPaths[ns.Path] = ns
+	}
+
+	for _, item := range serverAds.Items() {
+		existing := item.Value()
+		if existing == nil {
+			continue
+		}
+		sameURL := hostKey(existing.URL.String()) == hostKey(sAd.URL.String())
+		if sameURL && existing.Name != sAd.Name {
+			source := "registered"
+			if existing.FromTopology {
+				source = "Topology"
+			}
+			conflicts = append(conflicts, fmt.Sprintf("The URL %s belongs to the %s %s %q, whose advertisement this would replace", sAd.URL.String(), source, existing.Type, existing.Name))
+		} else if !sameURL && existing.Name == sAd.Name && !existing.FromTopology {
+			conflicts = append(conflicts, fmt.Sprintf("The %s %q at %s already uses the name; filters and downtimes apply to servers by name", existing.Type, existing.Name, existing.URL.String()))
+		}
+		if sameURL || existing.Type != server_structs.OriginType.String() || sAd.Type != server_structs.OriginType.String() {
+			continue
+		}
+		for _, existingNs := range existing.NamespaceAds {
+			ns, ok := nsPaths[existingNs.Path]
+			if !ok {
+				continue
+			}
+			if ns.Caps.PublicReads != existingNs.Caps.PublicReads {
+				conflicts = append(conflicts, fmt.Sprintf("The namespace %s is exported by origin %q with public reads set to %t", ns.Path, existing.Name, existingNs.Caps.PublicReads))
+			}
+			if !sameIssuers(ns.Issuer, existingNs.Issuer) {
+				conflicts = append(conflicts, fmt.Sprintf("The namespace %s is exported by origin %q with different token issuers", ns.Path, existing.Name))
+			}
+		}
+	}
+	sort.Strings(conflicts)
+	return
+}
+
+func issuerStrings(issuers []server_structs.TokenIssuer) []string {
+	result := make([]string, 0, len(issuers))
+	for _, issuer := range issuers {
+		result = append(result, issuer.IssuerUrl.String())
+	}
+	return result
+}
+
+func sameIssuers(a, b []server_structs.TokenIssuer) bool {
+	aStrs, bStrs := issuerStrings(a), issuerStrings(b)
+	sort.Strings(aStrs)
+	sort.Strings(bStrs)
+	return strings.Join(aStrs, " ") == strings.Join(bStrs, " ")
+}
+
+// Check the token issuers of a namespace, which clients are sent to for tokens
+// to access it.  The director doesn't reject a namespace for these, but clients
+// of the namespace would fail to get tokens.
+func checkNamespaceIssuers(ns server_structs.NamespaceAdV2) (problems []string) {
+	if !ns.Caps.PublicReads && len(ns.Issuer) == 0 {
+		problems = append(problems, "The namespace requires a token to read but advertises no token issuer")
+	}
+	for _, issuer := range ns.Issuer {
+		issuerUrl := issuer.IssuerUrl
+		if issuerUrl.Host == "" {
+			problems = append(problems, fmt.Sprintf("The token issuer %q is not an absolute URL", issuerUrl.String()))
+		} else if issuerUrl.Scheme != "https" {
+			problems = append(problems, fmt.Sprintf("The token issuer %s does not use https", issuerUrl.String()))
+		}
+		for _, basePath := range issuer.BasePaths {
+			if !strings.HasPrefix(basePath, "/") {
+				problems = append(problems, fmt.Sprintf("The base path %q of token issuer %s is not an absolute path", basePath, issuerUrl.String()))
+			}
+		}
+	}
+	return
+}
+
+// Report what the director would do with an advertisement without registering it,
+// to help debug the deployment of a new origin or cache.  The token in the
+// Authorization header, if any, is verified as it would be for a registration.
+func validateServerAd(engineCtx context.Context, ctx *gin.Context) {
+	queryParams := validateAdRequest{}
+	sType := server_structs.ServerType(0)
+	if ctx.ShouldBindQuery(&queryParams) != nil || !sType.SetString(queryParams.ServerType) ||
+		(sType != server_structs.OriginType && sType != server_structs.CacheType) {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "The server_type query parameter must be either 'cache' or 'origin'",
+		})
+		return
+	}
+
+	ctx.Header(server_structs.AdVersionsHeader, server_structs.SupportedAdVersions())
+	adV3, err := bindServerAd(ctx, sType)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+		return
+	}
+	adV2 := &adV3.OriginAdvertiseV2
+
+	result := AdValidation{
+		Name:       adV2.Name,
+		Type:       sType.String(),
+		AdVersion:  adV3.AdVersion,
+		Namespaces: []NamespaceValidation{},
+		Conflicts:  []string{},
+		Errors:     []string{},
+		Warnings:   []string{},
+	}
+	if adV2.Name == "" {
+		result.Warnings = append(result.Warnings, "The advertisement has no server name")
+	}
+
+	reqVer, service, _ := extractVersionAndService(ctx)
+	if err := versionCompatCheck(reqVer, service); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("Incompatible versions detected: %v", err))
+	}
+
+	sAd, err := serverAdFromAdvertisement(&adV3, sType, reqVer)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	} else {
+		result.Caps = sAd.Caps
+		if sAd.DisableDirectorTest && !adV2.DisableDirectorTest {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Director tests are not supported for storage type %s and would be disabled", sAd.StorageType))
+		}
+		result.Conflicts = append(result.Conflicts, findAdConflicts(sAd, adV2.Namespaces)...)
+	}
+	if filtered, ft := checkFilter(adV2.Name); filtered {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("The director would not redirect clients to the server: %s", ft))
+	} else if ft == topoUnscheduledFiltered {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("The director would only redirect clients to the server as a last resort: %s", ft))
+	}
+
+	// Verify the token the way a registration would, recording every failure
+	// rather than stopping at the first
+	token := ""
+	if authz := ctx.GetHeader("Authorization"); authz != "" {
+		token = strings.TrimPrefix(authz, "Bearer ")
+	}
+	registryPrefix, verifyServer := advertisementRegistryPrefix(sType, adV2)
+	if token == "" {
+		result.Errors = append(result.Errors, "Bearer token not present in the 'Authorization' header; the server's and namespaces' registrations were not verified")
+	} else if verifyServer {
+		if ok, err := verifyAdvertiseToken(engineCtx, token, registryPrefix); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Failed to verify the token for the registration %s: %v", registryPrefix, err))
+		} else if !ok {
+			result.Errors = append(result.Errors, fmt.Sprintf("The token for the registration %s is missing the required scope", registryPrefix))
+		} else {
+			result.TokenVerified = true
+		}
+	} else {
+		result.Warnings = append(result.Warnings, "The advertisement has no registry prefix, so the server's registration is not verified")
+		result.TokenVerified = true
+	}
+
+	seenPaths := make(map[string]bool, len(adV2.Namespaces))
+	for _, ns := range adV2.Namespaces {
+		nsResult := NamespaceValidation{
+			Path:     ns.Path,
+			Caps:     effectiveNamespaceCaps(adV2.Caps, ns.Caps, sType),
+			Issuers:  issuerStrings(ns.Issuer),
+			Errors:   []string{},
+			Warnings: []string{},
+		}
+		if !strings.HasPrefix(ns.Path, "/") {
+			nsResult.Warnings = append(nsResult.Warnings, "The namespace path is not an absolute path")
+		}
+		if seenPaths[ns.Path] {
+			nsResult.Warnings = append(nsResult.Warnings, "The namespace is advertised more than once")
+		}
+		seenPaths[ns.Path] = true
+		nsResult.Warnings = append(nsResult.Warnings, checkNamespaceIssuers(ns)...)
+
+		// Only origins have their namespace registrations verified
+		if sType == server_structs.OriginType && token != "" {
+			if ok, err := verifyAdvertiseToken(engineCtx, token, ns.Path); err != nil {
+				nsResult.Errors = append(nsResult.Errors, fmt.Sprintf("Failed to verify the token for the namespace: %v", err))
+			} else if !ok {
+				nsResult.Errors = append(nsResult.Errors, "The token for the namespace is missing the required scope")
+			} else {
+				for _, conflict := range namespacePolicyConflicts(getNamespacePolicy(ns.Path), sAd, nsResult.Caps) {
+					nsResult.Errors = append(nsResult.Errors, "The advertisement conflicts with the registry policy: "+conflict)
+				}
+			}
+		}
+		nsResult.Accepted = len(nsResult.Errors) == 0 && len(result.Errors) == 0 && result.TokenVerified
+		result.Namespaces = append(result.Namespaces, nsResult)
+	}
+
+	// A namespace the registry rejects fails the whole registration
+	result.Accepted = len(result.Errors) == 0 && result.TokenVerified
+	for _, nsResult := range result.Namespaces {
+		result.Accepted = result.Accepted && nsResult.Accepted
+	}
+	ctx.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,248 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/test_utils"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+func TestValidateServerAd(t *testing.T) {
+	ctx, cancel, egrp := test_utils.TestContext(context.Background(), t)
+	defer func() { require.NoError(t, egrp.Wait()) }()
+	defer cancel()
+
+	server_utils.ResetTestState()
+	t.Cleanup(func() {
+		serverAds.DeleteAll()
+		namespaceKeys.DeleteAll()
+		namespacePolicies.DeleteAll()
+		server_utils.ResetTestState()
+	})
+
+	// Mock registry that approves every namespace but /foo/unapproved and places a
+	// policy on /foo/restricted
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.URL.Path != "/api/v1.0/registry/checkNamespaceStatus" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reqJson := server_structs.CheckNamespaceStatusReq{}
+		if err := json.NewDecoder(req.Body).Decode(&reqJson); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		res := server_structs.CheckNamespaceStatusRes{Approved: reqJson.Prefix != "/foo/unapproved"}
+		if reqJson.Prefix == "/foo/restricted" {
+			res.Policy = &server_structs.NamespacePolicy{DisallowPublicReads: true, WriteOrigins: []string{"writer-origin"}}
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	}))
+	defer ts.Close()
+	viper.Set("Federation.RegistryUrl", ts.URL)
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pKey, err := jwk.FromRaw(privateKey)
+	require.NoError(t, err)
+	require.NoError(t, jwk.AssignKeyID(pKey))
+	require.NoError(t, pKey.Set(jwk.AlgorithmKey, jwa.ES256))
+	tok, err := jwt.NewBuilder().
+		Issuer(ts.URL).
+		Claim("scope", token_scopes.Pelican_Advertise.String()).
+		Subject("origin").
+		Build()
+	require.NoError(t, err)
+	signedTok, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, pKey))
+	require.NoError(t, err)
+	signed := string(signedTok)
+	publicKey, err := jwk.PublicKeyOf(pKey)
+	require.NoError(t, err)
+	for _, ns := range []string{"/origins/example-origin", "/foo/bar", "/foo/unapproved", "/foo/restricted"} {
+		jwks := jwk.NewSet()
+		require.NoError(t, jwks.AddKey(publicKey))
+		namespaceKeys.Set(ts.URL+"/api/v1.0/registry"+ns+"/.well-known/issuer.jwks", jwks, ttlcache.DefaultTTL)
+	}
+
+	issuerUrl, err := url.Parse("https://example-origin.org:8443")
+	require.NoError(t, err)
+	newAd := func(namespaces ...string) server_structs.OriginAdvertiseV3 {
+		ad := server_structs.OriginAdvertiseV3{AdVersion: server_structs.AdVersionV3}
+		ad.Name = "example-origin"
+		ad.RegistryPrefix = "/origins/example-origin"
+		ad.DataURL = "https://example-origin.org:8443"
+		ad.Caps = server_structs.Capabilities{Reads: true, Writes: false, Listings: true}
+		for _, ns := range namespaces {
+			ad.Namespaces = append(ad.Namespaces, server_structs.NamespaceAdV2{
+				Path:   ns,
+				Caps:   server_structs.Capabilities{Reads: true, Writes: true, Listings: true},
+				Issuer: []server_structs.TokenIssuer{{IssuerUrl: *issuerUrl}},
+			})
+		}
+		return ad
+	}
+	validate := func(serverType string, ad server_structs.OriginAdvertiseV3, token string) (int, AdValidation) {
+		body, err := json.Marshal(ad)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		_, r := gin.CreateTestContext(w)
+		r.POST("/validateAd", func(gctx *gin.Context) { validateServerAd(ctx, gctx) })
+		req, err := http.NewRequest(http.MethodPost, "/validateAd?server_type="+serverType, bytes.NewBuffer(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "pelican-origin/7.0.0")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		result := AdValidation{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		}
+		return w.Code, result
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		code, result := validate("origin", newAd("/foo/bar"), signed)
+		require.Equal(t, http.StatusOK, code)
+		assert.True(t, result.Accepted, "errors: %v", result.Errors)
+		assert.True(t, result.TokenVerified)
+		assert.Equal(t, "Origin", result.Type)
+		assert.Equal(t, server_structs.AdVersionV3, result.AdVersion)
+		assert.Empty(t, result.Errors)
+		assert.Empty(t, result.Conflicts)
+		require.Len(t, result.Namespaces, 1)
+		ns := result.Namespaces[0]
+		assert.True(t, ns.Accepted)
+		assert.Equal(t, []string{"https://example-origin.org:8443"}, ns.Issuers)
+		// The origin doesn't allow writes, so neither does its namespace
+		assert.True(t, ns.Caps.Reads)
+		assert.True(t, ns.Caps.Listings)
+		assert.False(t, ns.Caps.Writes)
+
+		// Nothing was registered
+		assert.Zero(t, serverAds.Len())
+	})
+
+	t.Run("unapproved-namespace", func(t *testing.T) {
+		code, result := validate("origin", newAd("/foo/bar", "/foo/unapproved"), signed)
+		require.Equal(t, http.StatusOK, code)
+		assert.False(t, result.Accepted)
+		assert.True(t, result.TokenVerified)
+		require.Len(t, result.Namespaces, 2)
+		assert.True(t, result.Namespaces[0].Accepted)
+		assert.False(t, result.Namespaces[1].Accepted)
+		assert.NotEmpty(t, result.Namespaces[1].Errors)
+	})
+
+	t.Run("policy-conflicts", func(t *testing.T) {
+		ad := newAd("/foo/restricted")
+		code, result := validate("origin", ad, signed)
+		require.Equal(t, http.StatusOK, code)
+		assert.True(t, result.Accepted)
+
+		ad.Caps.Writes = true
+		ad.Namespaces[0].Caps.PublicReads = true
+		code, result = validate("origin", ad, signed)
+		require.Equal(t, http.StatusOK, code)
+		assert.False(t, result.Accepted)
+		require.Len(t, result.Namespaces, 1)
+		require.Len(t, result.Namespaces[0].Errors, 2)
+		assert.Contains(t, result.Namespaces[0].Errors[0], "public reads")
+		assert.Contains(t, result.Namespaces[0].Errors[1], "writer-origin")
+	})
+
+	t.Run("no-token", func(t *testing.T) {
+		code, result := validate("origin", newAd("/foo/bar"), "")
+		require.Equal(t, http.StatusOK, code)
+		assert.False(t, result.Accepted)
+		assert.False(t, result.TokenVerified)
+		assert.NotEmpty(t, result.Errors)
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		otherAd := server_structs.Advertisement{
+			ServerAd: server_structs.ServerAd{
+				Name: "other-origin",
+				URL:  url.URL{Scheme: "https", Host: "other-origin.org:8443"},
+				Type: server_structs.OriginType.String(),
+			},
+			NamespaceAds: []server_structs.NamespaceAdV2{{Path: "/foo/bar", Caps: server_structs.Capabilities{PublicReads: true}}},
+		}
+		serverAds.Set(otherAd.URL.String(), &otherAd, ttlcache.DefaultTTL)
+		sameURLAd := server_structs.Advertisement{
+			ServerAd: server_structs.ServerAd{
+				Name:         "renamed-origin",
+				URL:          url.URL{Scheme: "http", Host: "example-origin.org:8443"},
+				Type:         server_structs.OriginType.String(),
+				FromTopology: true,
+			},
+		}
+		serverAds.Set(sameURLAd.URL.String(), &sameURLAd, ttlcache.DefaultTTL)
+		defer serverAds.DeleteAll()
+
+		code, result := validate("origin", newAd("/foo/bar"), signed)
+		require.Equal(t, http.StatusOK, code)
+		// Conflicts don't stop the director from accepting the advertisement
+		assert.True(t, result.Accepted)
+		require.Len(t, result.Conflicts, 3)
+		assert.Contains(t, result.Conflicts[0], "renamed-origin")
+		assert.Contains(t, result.Conflicts[1], "different token issuers")
+		assert.Contains(t, result.Conflicts[2], "public reads")
+	})
+
+	t.Run("invalid-url", func(t *testing.T) {
+		ad := newAd("/foo/bar")
+		ad.DataURL = "https://example-origin.org:bad port"
+		code, result := validate("origin", ad, signed)
+		require.Equal(t, http.StatusOK, code)
+		assert.False(t, result.Accepted)
+		require.NotEmpty(t, result.Errors)
+		assert.Contains(t, result.Errors[0], "is not a valid URL")
+	})
+
+	t.Run("bad-server-type", func(t *testing.T) {
+		code, _ := validate("registry", newAd("/foo/bar"), signed)
+		assert.Equal(t, http.StatusBadRequest, code)
+		code, _ = validate("", newAd("/foo/bar"), signed)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
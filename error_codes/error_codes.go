@@ -171,6 +171,16 @@ func NewTransfer_SlowTransferError(err error) *PelicanError {
 	}
 }
 
+func NewTransfer_ChecksumMismatchError(err error) *PelicanError {
+	return &PelicanError{
+		errorType: "Transfer.ChecksumMismatch",
+		exitCode:  11,
+		code:      6003,
+		retryable: true,
+		err:       err,
+	}
+}
+
 // function that maps the error to the exit code
 func (e *PelicanError) ExitCode() int {
 	return e.exitCode
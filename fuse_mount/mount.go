@@ -0,0 +1,41 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+// Package fuse_mount exposes a federation namespace as a read-only POSIX filesystem,
+// so applications that only know how to open files can read federation data.
+package fuse_mount
+
+import (
+	"time"
+
+	"github.com/pelicanplatform/pelican/client"
+)
+
+// Options controlling a mount
+type Options struct {
+	// Maximum size, in bytes, of the in-memory cache of object contents
+	CacheSize int64
+	// How long directory listings and attributes are trusted before being refetched
+	ListTTL time.Duration
+	// Allow users other than the one running the mount to access it
+	AllowOther bool
+	// Log every FUSE operation
+	Debug bool
+	// Options passed to the client for every listing, stat, and read (e.g., the token)
+	TransferOptions []client.TransferOption
+}
@@ -0,0 +1,270 @@
+//go:build !windows
+
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package fuse_mount
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/client"
+)
+
+type (
+	mountFS struct {
+		remote remoteFS
+		cache  *pageCache
+		root   client.FileInfo
+	}
+
+	dirNode struct {
+		fs.Inode
+		mfs  *mountFS
+		path string
+	}
+
+	fileNode struct {
+		fs.Inode
+		mfs  *mountFS
+		path string
+
+		mutex    sync.Mutex
+		lastSize int64
+		lastMod  time.Time
+	}
+)
+
+var (
+	_ = (fs.NodeLookuper)((*dirNode)(nil))
+	_ = (fs.NodeReaddirer)((*dirNode)(nil))
+	_ = (fs.NodeGetattrer)((*dirNode)(nil))
+	_ = (fs.NodeOpener)((*fileNode)(nil))
+	_ = (fs.NodeReader)((*fileNode)(nil))
+	_ = (fs.NodeGetattrer)((*fileNode)(nil))
+)
+
+// Mount the federation namespace `prefix` read-only at `mountpoint` and serve it
+// until the context is cancelled or the filesystem is unmounted externally.
+func Mount(ctx context.Context, prefix, mountpoint string, opts Options) error {
+	remote := newFederationFS(prefix, opts.ListTTL, opts.TransferOptions...)
+	root, err := remote.stat(ctx, "")
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat %s", prefix)
+	}
+	if !root.IsCollection {
+		return errors.Errorf("%s is an object, not a collection; only collections can be mounted", prefix)
+	}
+	mfs := &mountFS{
+		remote: remote,
+		cache:  newPageCache(opts.CacheSize, defaultPageSize),
+		root:   root,
+	}
+
+	ttl := opts.ListTTL
+	server, err := fs.Mount(mountpoint, &dirNode{mfs: mfs}, &fs.Options{
+		MountOptions: fuse.MountOptions{
+			Options:    []string{"ro"},
+			FsName:     prefix,
+			Name:       "pelican",
+			AllowOther: opts.AllowOther,
+			Debug:      opts.Debug,
+		},
+		EntryTimeout:    &ttl,
+		AttrTimeout:     &ttl,
+		NegativeTimeout: &ttl,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to mount %s at %s", prefix, mountpoint)
+	}
+	log.Infof("Mounted %s at %s", prefix, mountpoint)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			log.Infof("Unmounting %s", mountpoint)
+			if err := server.Unmount(); err != nil {
+				log.Errorf("Failed to unmount %s (is it still in use?): %v", mountpoint, err)
+			}
+		case <-done:
+		}
+	}()
+	server.Wait()
+	close(done)
+	return nil
+}
+
+// Translate an error from the federation into the errno reported to the application
+func toErrno(err error) syscall.Errno {
+	var sce *client.StatusCodeError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return syscall.EINTR
+	case errors.As(err, &sce) && (int(*sce) == http.StatusNotFound):
+		return syscall.ENOENT
+	case errors.As(err, &sce) && (int(*sce) == http.StatusUnauthorized || int(*sce) == http.StatusForbidden):
+		return syscall.EACCES
+	case client.ClassifyError(err) == client.ErrorClassAuth:
+		return syscall.EACCES
+	}
+	return syscall.EIO
+}
+
+// Return the attributes of the entry at relPath, from the (cached) listing of its parent
+func (m *mountFS) info(ctx context.Context, relPath string) (client.FileInfo, syscall.Errno) {
+	if relPath == "" {
+		return m.root, 0
+	}
+	parent := path.Dir(relPath)
+	if parent == "." {
+		parent = ""
+	}
+	entries, err := m.remote.list(ctx, parent)
+	if err != nil {
+		log.Warningf("Failed to list %s: %v", parent, err)
+		return client.FileInfo{}, toErrno(err)
+	}
+	name := path.Base(relPath)
+	for _, entry := range entries {
+		if entry.Name == name {
+			return entry, 0
+		}
+	}
+	return client.FileInfo{}, syscall.ENOENT
+}
+
+func (m *mountFS) fillAttr(info client.FileInfo, out *fuse.Attr) {
+	if info.IsCollection {
+		out.Mode = fuse.S_IFDIR | 0555
+		out.Nlink = 2
+	} else {
+		out.Mode = fuse.S_IFREG | 0444
+		out.Nlink = 1
+		out.Size = uint64(info.Size)
+		out.Blocks = (out.Size + 511) / 512
+	}
+	out.Blksize = uint32(m.cache.pageSize)
+	if !info.ModTime.IsZero() {
+		out.SetTimes(nil, &info.ModTime, &info.ModTime)
+	}
+}
+
+func childPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "/" + name
+}
+
+func (d *dirNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	relPath := childPath(d.path, name)
+	info, errno := d.mfs.info(ctx, relPath)
+	if errno != 0 {
+		return nil, errno
+	}
+	d.mfs.fillAttr(info, &out.Attr)
+	if info.IsCollection {
+		return d.NewInode(ctx, &dirNode{mfs: d.mfs, path: relPath}, fs.StableAttr{Mode: fuse.S_IFDIR}), 0
+	}
+	return d.NewInode(ctx, &fileNode{mfs: d.mfs, path: relPath}, fs.StableAttr{Mode: fuse.S_IFREG}), 0
+}
+
+func (d *dirNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries, err := d.mfs.remote.list(ctx, d.path)
+	if err != nil {
+		log.Warningf("Failed to list %s: %v", d.path, err)
+		return nil, toErrno(err)
+	}
+	dirEntries := make([]fuse.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		mode := uint32(fuse.S_IFREG)
+		if entry.IsCollection {
+			mode = fuse.S_IFDIR
+		}
+		dirEntries = append(dirEntries, fuse.DirEntry{Name: entry.Name, Mode: mode})
+	}
+	return fs.NewListDirStream(dirEntries), 0
+}
+
+func (d *dirNode) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	info, errno := d.mfs.info(ctx, d.path)
+	if errno != 0 {
+		return errno
+	}
+	d.mfs.fillAttr(info, &out.Attr)
+	return 0
+}
+
+func (f *fileNode) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	info, errno := f.mfs.info(ctx, f.path)
+	if errno != 0 {
+		return errno
+	}
+	f.mfs.fillAttr(info, &out.Attr)
+	return 0
+}
+
+func (f *fileNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR|syscall.O_TRUNC|syscall.O_APPEND) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+	info, errno := f.mfs.info(ctx, f.path)
+	if errno != 0 {
+		return nil, 0, errno
+	}
+
+	// Cached pages (ours and the kernel's) are only kept while the object is unchanged
+	f.mutex.Lock()
+	changed := f.lastSize != info.Size || !f.lastMod.Equal(info.ModTime)
+	f.lastSize = info.Size
+	f.lastMod = info.ModTime
+	f.mutex.Unlock()
+	if changed {
+		f.mfs.cache.invalidate(f.path)
+		f.mfs.remote.forget(f.path)
+		return nil, 0, 0
+	}
+	return nil, fuse.FOPEN_KEEP_CACHE, 0
+}
+
+func (f *fileNode) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	info, errno := f.mfs.info(ctx, f.path)
+	if errno != 0 {
+		return nil, errno
+	}
+	n, err := f.mfs.cache.read(ctx, f.path, info.Size, dest, off, func(ctx context.Context, p []byte, off int64) (int, error) {
+		return f.mfs.remote.readRange(ctx, f.path, p, off)
+	})
+	if err != nil && err != io.EOF {
+		log.Warningf("Failed to read %s at offset %d: %v", f.path, off, err)
+		return nil, toErrno(err)
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
@@ -0,0 +1,158 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package fuse_mount
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"strconv"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const defaultPageSize = 1024 * 1024
+
+type (
+	// Reads len(p) bytes of an object starting at off, with io.ReaderAt semantics
+	fetchFunc func(ctx context.Context, p []byte, off int64) (int, error)
+
+	pageKey struct {
+		path  string
+		index int64
+	}
+
+	page struct {
+		key  pageKey
+		data []byte
+	}
+
+	// An in-memory LRU of fixed-size pages of remote objects.  Reads through the
+	// mount are served from here and only missing pages are fetched from the federation,
+	// so small sequential reads from an application turn into page-sized ranged GETs.
+	pageCache struct {
+		pageSize int64
+		maxPages int
+
+		mutex sync.Mutex
+		pages map[pageKey]*list.Element
+		lru   *list.List
+
+		fetches singleflight.Group
+	}
+)
+
+// Create a page cache holding at most `size` bytes, in pages of `pageSize` bytes.
+// The cache always holds at least one page.
+func newPageCache(size, pageSize int64) *pageCache {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	return &pageCache{
+		pageSize: pageSize,
+		maxPages: int(max(size/pageSize, 1)),
+		pages:    make(map[pageKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func (c *pageCache) get(key pageKey) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.pages[key]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*page).data, true
+	}
+	return nil, false
+}
+
+func (c *pageCache) put(key pageKey, data []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.pages[key]; ok {
+		elem.Value.(*page).data = data
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.pages[key] = c.lru.PushFront(&page{key: key, data: data})
+	for c.lru.Len() > c.maxPages {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.pages, oldest.Value.(*page).key)
+	}
+}
+
+// Drop all the cached pages of an object, e.g., after it changed remotely
+func (c *pageCache) invalidate(path string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, elem := range c.pages {
+		if key.path == path {
+			c.lru.Remove(elem)
+			delete(c.pages, key)
+		}
+	}
+}
+
+// Return the page of the object, fetching it if it is not cached.  Concurrent
+// requests for the same missing page share a single fetch.
+func (c *pageCache) page(ctx context.Context, key pageKey, size int64, fetch fetchFunc) ([]byte, error) {
+	if data, ok := c.get(key); ok {
+		return data, nil
+	}
+	result, err, _ := c.fetches.Do(key.path+"\x00"+strconv.FormatInt(key.index, 10), func() (interface{}, error) {
+		start := key.index * c.pageSize
+		data := make([]byte, min(c.pageSize, size-start))
+		n, err := fetch(ctx, data, start)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		data = data[:n]
+		c.put(key, data)
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]byte), nil
+}
+
+// Read len(p) bytes of the object at `path`, whose size is `size`, starting at off.
+// Returns io.EOF along with any bytes read when the read reaches the end of the object.
+func (c *pageCache) read(ctx context.Context, path string, size int64, p []byte, off int64, fetch fetchFunc) (n int, err error) {
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= size {
+			return n, io.EOF
+		}
+		key := pageKey{path: path, index: pos / c.pageSize}
+		data, err := c.page(ctx, key, size, fetch)
+		if err != nil {
+			return n, err
+		}
+		pageOff := pos - key.index*c.pageSize
+		if pageOff >= int64(len(data)) {
+			// The object is shorter than it was when listed
+			return n, io.EOF
+		}
+		n += copy(p[n:], data[pageOff:])
+	}
+	return n, nil
+}
@@ -0,0 +1,125 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package fuse_mount
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageCache(t *testing.T) {
+	object := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	size := int64(len(object))
+	var fetches atomic.Int32
+	fetch := func(ctx context.Context, p []byte, off int64) (int, error) {
+		fetches.Add(1)
+		n := copy(p, object[off:])
+		if n < len(p) {
+			return n, io.EOF
+		}
+		return n, nil
+	}
+	ctx := context.Background()
+
+	t.Run("read-across-pages", func(t *testing.T) {
+		fetches.Store(0)
+		cache := newPageCache(1024, 8)
+		buf := make([]byte, 10)
+		n, err := cache.read(ctx, "/obj", size, buf, 6, fetch)
+		require.NoError(t, err)
+		assert.Equal(t, "6789abcdef", string(buf[:n]))
+		assert.Equal(t, int32(2), fetches.Load())
+
+		// The same pages are served from the cache
+		n, err = cache.read(ctx, "/obj", size, buf, 8, fetch)
+		require.NoError(t, err)
+		assert.Equal(t, "89abcdefgh", string(buf[:n]))
+		assert.Equal(t, int32(3), fetches.Load())
+	})
+
+	t.Run("end-of-object", func(t *testing.T) {
+		cache := newPageCache(1024, 8)
+		buf := make([]byte, 10)
+		n, err := cache.read(ctx, "/obj", size, buf, size-3, fetch)
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Equal(t, "xyz", string(buf[:n]))
+
+		n, err = cache.read(ctx, "/obj", size, buf, size+5, fetch)
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Zero(t, n)
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		fetches.Store(0)
+		cache := newPageCache(16, 8)
+		buf := make([]byte, 8)
+		for _, off := range []int64{0, 8, 16, 0} {
+			_, err := cache.read(ctx, "/obj", size, buf, off, fetch)
+			require.NoError(t, err)
+		}
+		// Only two pages fit, so the first was evicted by the third and fetched again
+		assert.Equal(t, int32(4), fetches.Load())
+		assert.Equal(t, 2, cache.lru.Len())
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		fetches.Store(0)
+		cache := newPageCache(1024, 8)
+		buf := make([]byte, 8)
+		_, err := cache.read(ctx, "/obj", size, buf, 0, fetch)
+		require.NoError(t, err)
+		_, err = cache.read(ctx, "/other", size, buf, 0, fetch)
+		require.NoError(t, err)
+		cache.invalidate("/obj")
+		assert.Equal(t, 1, cache.lru.Len())
+		_, err = cache.read(ctx, "/obj", size, buf, 0, fetch)
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), fetches.Load())
+	})
+
+	t.Run("concurrent-reads", func(t *testing.T) {
+		fetches.Store(0)
+		release := make(chan struct{})
+		slowFetch := func(ctx context.Context, p []byte, off int64) (int, error) {
+			<-release
+			return fetch(ctx, p, off)
+		}
+		cache := newPageCache(1024, 8)
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				buf := make([]byte, 4)
+				n, err := cache.read(ctx, "/obj", size, buf, 2, slowFetch)
+				assert.NoError(t, err)
+				assert.Equal(t, "2345", string(buf[:n]))
+			}()
+		}
+		close(release)
+		wg.Wait()
+		assert.Equal(t, 1, cache.lru.Len())
+	})
+}
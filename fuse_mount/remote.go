@@ -0,0 +1,137 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package fuse_mount
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pelicanplatform/pelican/client"
+)
+
+type (
+	// The federation operations the mount needs; paths are relative to the mounted
+	// prefix, with "" being the prefix itself.
+	remoteFS interface {
+		list(ctx context.Context, path string) ([]client.FileInfo, error)
+		stat(ctx context.Context, path string) (client.FileInfo, error)
+		readRange(ctx context.Context, path string, p []byte, off int64) (int, error)
+		forget(path string)
+	}
+
+	cachedListing struct {
+		entries []client.FileInfo
+		expiry  time.Time
+	}
+
+	// A remoteFS backed by the director: listings and stats go through the client's
+	// list and stat functions and object contents are read in ranges from the servers
+	// the director redirects to.  Listings are cached for `listTTL` to avoid a round
+	// trip to the director for every lookup the kernel makes.
+	federationFS struct {
+		prefix  string
+		options []client.TransferOption
+		listTTL time.Duration
+
+		mutex    sync.Mutex
+		listings map[string]cachedListing
+		readers  map[string]*client.ObjectRangeReader
+	}
+)
+
+func newFederationFS(prefix string, listTTL time.Duration, options ...client.TransferOption) *federationFS {
+	return &federationFS{
+		prefix:   strings.TrimSuffix(prefix, "/"),
+		options:  options,
+		listTTL:  listTTL,
+		listings: make(map[string]cachedListing),
+		readers:  make(map[string]*client.ObjectRangeReader),
+	}
+}
+
+// Convert a path relative to the mount into the remote object name
+func (f *federationFS) remotePath(relPath string) string {
+	if relPath == "" {
+		return f.prefix
+	}
+	return f.prefix + "/" + relPath
+}
+
+func (f *federationFS) list(ctx context.Context, relPath string) ([]client.FileInfo, error) {
+	f.mutex.Lock()
+	listing, ok := f.listings[relPath]
+	f.mutex.Unlock()
+	if ok && time.Now().Before(listing.expiry) {
+		return listing.entries, nil
+	}
+
+	infos, err := client.DoList(ctx, f.remotePath(relPath), f.options...)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]client.FileInfo, 0, len(infos))
+	for _, info := range infos {
+		info.Name = path.Base(info.Name)
+		if info.Name == "" || info.Name == "." || info.Name == "/" {
+			continue
+		}
+		entries = append(entries, info)
+	}
+
+	f.mutex.Lock()
+	f.listings[relPath] = cachedListing{entries: entries, expiry: time.Now().Add(f.listTTL)}
+	f.mutex.Unlock()
+	return entries, nil
+}
+
+func (f *federationFS) stat(ctx context.Context, relPath string) (client.FileInfo, error) {
+	info, err := client.DoStat(ctx, f.remotePath(relPath), f.options...)
+	if err != nil {
+		return client.FileInfo{}, err
+	}
+	info.Name = path.Base(relPath)
+	return *info, nil
+}
+
+// Read a range of the object, reusing the director response from the object's previous reads
+func (f *federationFS) readRange(ctx context.Context, relPath string, p []byte, off int64) (int, error) {
+	f.mutex.Lock()
+	reader, ok := f.readers[relPath]
+	f.mutex.Unlock()
+	if !ok {
+		var err error
+		if reader, err = client.NewObjectRangeReader(ctx, f.remotePath(relPath), f.options...); err != nil {
+			return 0, err
+		}
+		f.mutex.Lock()
+		f.readers[relPath] = reader
+		f.mutex.Unlock()
+	}
+	return reader.ReadRange(ctx, p, off)
+}
+
+// Forget the cached director response for an object, e.g., once it changed remotely
+func (f *federationFS) forget(relPath string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.readers, relPath)
+}
@@ -31,6 +31,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -44,6 +45,7 @@ import (
 	"github.com/pelicanplatform/pelican/token"
 	"github.com/pelicanplatform/pelican/token_scopes"
 	"github.com/pelicanplatform/pelican/utils"
+	"github.com/pelicanplatform/pelican/xrootd"
 )
 
 type directorResponse struct {
@@ -51,6 +53,12 @@ type directorResponse struct {
 	ApprovalError bool   `json:"approval_error"`
 }
 
+var (
+	// The advertisement version to send to each director, keyed by its URL
+	directorAdVersions      = map[string]int{}
+	directorAdVersionsMutex sync.Mutex
+)
+
 func doAdvertise(ctx context.Context, servers []server_structs.XRootDServer) {
 	log.Debugf("About to advertise %d XRootD servers", len(servers))
 	err := Advertise(ctx, servers)
@@ -182,11 +190,10 @@ func advertiseInternal(ctx context.Context, server server_structs.XRootDServer)
 	if err != nil {
 		return err
 	}
-
-	body, err := json.Marshal(*ad)
-	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("failed to generate JSON description of %s", server.GetServerType()))
+	if activeIO, ok := metrics.GetActiveIO(); ok {
+		ad.Load = &server_structs.AdvertisedLoad{ActiveIO: activeIO}
 	}
+	ad.Draining = xrootd.IsXrootdDraining(server.GetServerType())
 
 	fedInfo, err := config.GetFederation(ctx)
 	if err != nil {
@@ -220,14 +227,80 @@ func advertiseInternal(ctx context.Context, server server_structs.XRootDServer)
 		return errors.Wrap(err, "failed to create director advertisement token")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, directorUrl.String(), bytes.NewBuffer(body))
+	userAgent := "pelican-" + strings.ToLower(server.GetServerType().String()) + "/" + config.GetVersion()
+
+	// Send the newest version of the ad the director is known to accept.  If the
+	// director rejects it and lists the versions it does accept, retry once with
+	// the best of those.
+	adVersion := getDirectorAdVersion(directorUrlStr)
+	var resp *http.Response
+	var body []byte
+	for attempt := 0; attempt < 2; attempt++ {
+		resp, body, err = postAdvertisement(ctx, directorUrl.String(), tok, userAgent, ad, adVersion)
+		if err != nil {
+			return err
+		}
+		supportedVersions := resp.Header.Get(server_structs.AdVersionsHeader)
+		negotiated := server_structs.NegotiateAdVersion(supportedVersions)
+		setDirectorAdVersion(directorUrlStr, negotiated)
+		if resp.StatusCode <= 299 || supportedVersions == "" || negotiated == adVersion {
+			break
+		}
+		log.Infof("The director does not accept version %d advertisements; retrying with version %d", adVersion, negotiated)
+		adVersion = negotiated
+	}
+	if resp.StatusCode > 299 {
+		var respErr directorResponse
+		if unmarshalErr := json.Unmarshal(body, &respErr); unmarshalErr != nil { // Error creating json
+			return errors.Wrapf(unmarshalErr, "could not decode the director's response, which responded %v from director advertisement: %s", resp.StatusCode, string(body))
+		}
+		if respErr.ApprovalError {
+			// Removed the "Please contact admin..." section since the director now provides contact information
+			return fmt.Errorf("the director rejected the server advertisement: %s", respErr.Error)
+		}
+		return errors.Errorf("error during director advertisement: %v", respErr.Error)
+	}
+
+	return nil
+}
+
+// The advertisement version last negotiated with the director
+func getDirectorAdVersion(directorUrl string) int {
+	directorAdVersionsMutex.Lock()
+	defer directorAdVersionsMutex.Unlock()
+	if version, ok := directorAdVersions[directorUrl]; ok {
+		return version
+	}
+	return server_structs.LatestAdVersion
+}
+
+func setDirectorAdVersion(directorUrl string, version int) {
+	directorAdVersionsMutex.Lock()
+	defer directorAdVersionsMutex.Unlock()
+	if directorAdVersions[directorUrl] != version {
+		log.Debugf("Advertising to the director at %s with version %d advertisements", directorUrl, version)
+	}
+	directorAdVersions[directorUrl] = version
+}
+
+// POST the given version of the advertisement to the director, returning the
+// response and its body
+func postAdvertisement(ctx context.Context, directorUrl, tok, userAgent string, ad *server_structs.OriginAdvertiseV3, adVersion int) (*http.Response, []byte, error) {
+	versionedAd, err := ad.ForVersion(adVersion)
 	if err != nil {
-		return errors.Wrap(err, "failed to create a POST request for director advertisement")
+		return nil, nil, err
+	}
+	reqBody, err := json.Marshal(versionedAd)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate the JSON advertisement")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, directorUrl, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create a POST request for director advertisement")
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+tok)
-	userAgent := "pelican-" + strings.ToLower(server.GetServerType().String()) + "/" + config.GetVersion()
 	req.Header.Set("User-Agent", userAgent)
 
 	// We should switch this over to use the common transport, but for that to happen
@@ -237,25 +310,13 @@ func advertiseInternal(ctx context.Context, server server_structs.XRootDServer)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return errors.Wrap(err, "failed to start the request for director advertisement")
+		return nil, nil, errors.Wrap(err, "failed to start the request for director advertisement")
 	}
 	defer resp.Body.Close()
 
-	body, err = io.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return errors.Wrap(err, "failed to read the response body for director advertisement")
-	}
-	if resp.StatusCode > 299 {
-		var respErr directorResponse
-		if unmarshalErr := json.Unmarshal(body, &respErr); unmarshalErr != nil { // Error creating json
-			return errors.Wrapf(unmarshalErr, "could not decode the director's response, which responded %v from director advertisement: %s", resp.StatusCode, string(body))
-		}
-		if respErr.ApprovalError {
-			// Removed the "Please contact admin..." section since the director now provides contact information
-			return fmt.Errorf("the director rejected the server advertisement: %s", respErr.Error)
-		}
-		return errors.Errorf("error during director advertisement: %v", respErr.Error)
+		return nil, nil, errors.Wrap(err, "failed to read the response body for director advertisement")
 	}
-
-	return nil
+	return resp, body, nil
 }
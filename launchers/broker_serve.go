@@ -0,0 +1,38 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package launchers
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/broker"
+)
+
+// Serve the connection broker, which origins and caches behind firewalls poll for
+// requests to reverse connections to them
+func BrokerServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group) {
+	log.Info("Starting the connection broker...")
+	rootGroup := engine.Group("/")
+	broker.RegisterBroker(ctx, rootGroup)
+	broker.LaunchNamespaceKeyMaintenance(ctx, egrp)
+}
@@ -45,6 +45,9 @@ import (
 )
 
 func CacheServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group, modules server_structs.ServerType) (server_structs.XRootDServer, error) {
+	metrics.AddLivenessComponents(server_structs.CacheType.String(), metrics.OriginCache_XRootD)
+	metrics.AddReadinessComponents(server_structs.CacheType.String(), metrics.OriginCache_Federation)
+
 	err := xrootd.SetUpMonitoring(ctx, egrp)
 	if err != nil {
 		return nil, err
@@ -99,6 +102,16 @@ func CacheServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group, m
 
 	cache.LaunchDirectorTestFileCleanup(ctx)
 
+	if err := cache.LaunchNamespaceLimits(ctx, egrp); err != nil {
+		return nil, err
+	}
+
+	if err := cache.LaunchDiskHealthMonitor(ctx, egrp); err != nil {
+		return nil, err
+	}
+
+	cache.LaunchContentAdvertisement(ctx, egrp)
+
 	if param.Cache_SelfTest.GetBool() {
 		err = cache.InitSelfTestDir()
 		if err != nil {
@@ -134,6 +147,10 @@ func CacheServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group, m
 		return nil, err
 	}
 	cacheServer.SetPids(pids)
+
+	if err := cache.LaunchLocalHttpServer(ctx, egrp, cacheServer); err != nil {
+		return nil, err
+	}
 	return cacheServer, nil
 }
 
@@ -32,6 +32,7 @@ import (
 	"github.com/pelicanplatform/pelican/director"
 	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
 )
 
 func DirectorServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group) error {
@@ -42,16 +43,65 @@ func DirectorServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group
 	if err := director.InitializeDB(); err != nil {
 		return errors.Wrap(err, "failed to initialize director sqlite database")
 	}
+	metrics.AddLivenessComponents(server_structs.DirectorType.String(), metrics.Director_Database)
+	director.LaunchDBHealthCheck(ctx, egrp)
 	director.ConfigFilterdServers()
 
+	if err := director.ConfigDenyRules(); err != nil {
+		return err
+	}
+
+	if err := director.ConfigRateLimits(); err != nil {
+		return err
+	}
+
+	if err := director.ConfigSubDirectors(); err != nil {
+		return err
+	}
+
+	if err := director.ConfigNamespaceSLOs(); err != nil {
+		return err
+	}
+
+	if err := director.ConfigRedirectStyles(); err != nil {
+		return err
+	}
+
+	if err := director.ConfigSortExperiment(); err != nil {
+		return err
+	}
+
+	if err := director.ConfigNamespaceAliases(); err != nil {
+		return err
+	}
+
+	if err := director.ConfigDiscoveryDocument(); err != nil {
+		return err
+	}
+
 	director.LaunchTTLCache(ctx, egrp)
 
 	director.LaunchMapMetrics(ctx, egrp)
 
+	director.LaunchReplicaMetrics(ctx, egrp)
+
+	director.LaunchSLOMetrics(ctx, egrp)
+
+	director.LaunchSortExperiment(ctx, egrp)
+
+	director.LaunchDenyRuleExpiry(ctx, egrp)
+
+	director.LaunchRateLimitCleanup(ctx, egrp)
+
 	director.ConfigFilterdServers()
+	director.RegisterReloadables()
 
 	director.LaunchServerIOQuery(ctx, egrp)
 
+	if err := director.LaunchDNSResponder(ctx, egrp); err != nil {
+		return err
+	}
+
 	if config.GetPreferredPrefix() == config.OsdfPrefix {
 		metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusWarning, "Start requesting from topology, status unknown")
 		log.Info("Generating/advertising server ads from OSG topology service...")
@@ -81,6 +131,8 @@ func DirectorServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group
 	rootGroup := engine.Group("/")
 	director.RegisterDirectorOIDCAPI(rootGroup)
 	director.RegisterDirectorWebAPI(rootGroup)
+	engine.Use(director.RateLimitMiddleware)
+	engine.Use(director.SubDirectorMiddleware())
 	engine.Use(director.ShortcutMiddleware(defaultResponse))
 	director.RegisterDirectorAPI(ctx, rootGroup)
 
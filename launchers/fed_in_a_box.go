@@ -0,0 +1,94 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package launchers
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// The modules of a federation-in-a-box, which `pelican serve --module all` runs in one process
+const FedInABoxModules = server_structs.DirectorType | server_structs.RegistryType | server_structs.OriginType | server_structs.CacheType
+
+// The directory, under the config directory, holding the origin's data and the client
+// configuration of a federation-in-a-box
+func fedInABoxDir() string {
+	return filepath.Join(viper.GetString("ConfigDir"), "fed-in-a-box")
+}
+
+// Configure a federation-in-a-box before its modules are launched.  Its servers use the
+// loopback hostname, so the self-signed certificates Pelican generates match the URLs the
+// servers and local clients contact each other at, and the director and registry
+// default to this server.  Unless exports are configured, the origin exports a directory
+// under the config directory at /data.  Explicitly configured values take precedence.
+func ConfigureFedInABox() error {
+	viper.SetDefault(param.Server_Hostname.GetName(), "localhost")
+	if param.Origin_Exports.IsSet() || param.Origin_StoragePrefix.IsSet() || param.Origin_FederationPrefix.IsSet() {
+		return nil
+	}
+	dataDir := filepath.Join(fedInABoxDir(), "data")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create the directory for the origin's data")
+	}
+	if config.IsRootExecution() {
+		uinfo, err := config.GetDaemonUserInfo()
+		if err != nil {
+			return err
+		}
+		if err = os.Chown(dataDir, uinfo.Uid, uinfo.Gid); err != nil {
+			return errors.Wrap(err, "failed to give the daemon user ownership of the origin's data directory")
+		}
+	}
+	viper.SetDefault(param.Origin_StoragePrefix.GetName(), dataDir)
+	viper.SetDefault(param.Origin_FederationPrefix.GetName(), "/data")
+	return nil
+}
+
+// Write a configuration file that points clients at the running federation-in-a-box and
+// trusts its self-signed CA, returning the file's location
+func WriteFedInABoxClientConfig() (string, error) {
+	clientConfig := map[string]any{
+		"Federation": map[string]any{
+			"DiscoveryUrl": param.Server_ExternalWebUrl.GetString(),
+		},
+		"Server": map[string]any{
+			"TLSCACertificateFile": param.Server_TLSCACertificateFile.GetString(),
+		},
+	}
+	contents, err := yaml.Marshal(clientConfig)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate the client configuration")
+	}
+	location := filepath.Join(fedInABoxDir(), "client.yaml")
+	if err = os.MkdirAll(filepath.Dir(location), 0755); err != nil {
+		return "", errors.Wrap(err, "failed to create the directory for the client configuration")
+	}
+	if err = os.WriteFile(location, contents, 0644); err != nil {
+		return "", errors.Wrap(err, "failed to write the client configuration")
+	}
+	return location, nil
+}
@@ -0,0 +1,86 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package launchers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+func TestConfigureFedInABox(t *testing.T) {
+	setup := func(t *testing.T) string {
+		config.ResetConfig()
+		t.Cleanup(config.ResetConfig)
+		configDir := t.TempDir()
+		viper.Set("ConfigDir", configDir)
+		return configDir
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		configDir := setup(t)
+		require.NoError(t, ConfigureFedInABox())
+		assert.Equal(t, "localhost", param.Server_Hostname.GetString())
+		assert.Equal(t, "/data", param.Origin_FederationPrefix.GetString())
+		assert.Equal(t, filepath.Join(configDir, "fed-in-a-box", "data"), param.Origin_StoragePrefix.GetString())
+		assert.DirExists(t, param.Origin_StoragePrefix.GetString())
+	})
+
+	t.Run("configured-values-win", func(t *testing.T) {
+		configDir := setup(t)
+		viper.Set("Server.Hostname", "fed.example.org")
+		viper.Set("Origin.StoragePrefix", "/mnt/data")
+		viper.Set("Origin.FederationPrefix", "/example")
+		require.NoError(t, ConfigureFedInABox())
+		assert.Equal(t, "fed.example.org", param.Server_Hostname.GetString())
+		assert.Equal(t, "/example", param.Origin_FederationPrefix.GetString())
+		assert.NoDirExists(t, filepath.Join(configDir, "fed-in-a-box", "data"))
+	})
+
+	t.Run("client-config", func(t *testing.T) {
+		configDir := setup(t)
+		viper.Set("Server.ExternalWebUrl", "https://localhost:8444")
+		viper.Set("Server.TLSCACertificateFile", "/etc/pelican/certificates/tlsca.pem")
+		location, err := WriteFedInABoxClientConfig()
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(configDir, "fed-in-a-box", "client.yaml"), location)
+
+		contents, err := os.ReadFile(location)
+		require.NoError(t, err)
+		clientConfig := struct {
+			Federation struct {
+				DiscoveryUrl string `yaml:"DiscoveryUrl"`
+			} `yaml:"Federation"`
+			Server struct {
+				TLSCACertificateFile string `yaml:"TLSCACertificateFile"`
+			} `yaml:"Server"`
+		}{}
+		require.NoError(t, yaml.Unmarshal(contents, &clientConfig))
+		assert.Equal(t, "https://localhost:8444", clientConfig.Federation.DiscoveryUrl)
+		assert.Equal(t, "/etc/pelican/certificates/tlsca.pem", clientConfig.Server.TLSCACertificateFile)
+	})
+}
@@ -34,15 +34,16 @@ import (
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 
-	"github.com/pelicanplatform/pelican/broker"
 	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/launcher_utils"
 	"github.com/pelicanplatform/pelican/local_cache"
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/origin"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/server_utils"
 	"github.com/pelicanplatform/pelican/web_ui"
+	"github.com/pelicanplatform/pelican/xrootd"
 )
 
 var (
@@ -60,6 +61,16 @@ func LaunchModules(ctx context.Context, modules server_structs.ServerType) (serv
 
 	config.LogPelicanVersion()
 
+	if err = metrics.ValidateProbeStrictness(); err != nil {
+		return
+	}
+	if err = metrics.InitTracing(ctx, egrp); err != nil {
+		return
+	}
+	metrics.SetServerStarted(false)
+	metrics.AddLivenessComponents(metrics.ProbeModuleServer, metrics.Server_WebEngine)
+	launchSystemdNotify(ctx, egrp)
+
 	egrp.Go(func() error {
 		_ = config.RestartFlag
 		log.Debug("Will shutdown process on signal")
@@ -97,6 +108,10 @@ func LaunchModules(ctx context.Context, modules server_structs.ServerType) (serv
 		}
 	}
 
+	if err = config.LaunchConfigWatcher(ctx, egrp); err != nil {
+		return
+	}
+
 	// Set up necessary APIs to support Web UI, including auth and metrics
 	if err = web_ui.ConfigureServerWebAPI(ctx, engine, egrp); err != nil {
 		return
@@ -122,9 +137,7 @@ func LaunchModules(ctx context.Context, modules server_structs.ServerType) (serv
 	}
 
 	if modules.IsEnabled(server_structs.BrokerType) {
-		rootGroup := engine.Group("/")
-		broker.RegisterBroker(ctx, rootGroup)
-		broker.LaunchNamespaceKeyMaintenance(ctx, egrp)
+		BrokerServe(ctx, engine, egrp)
 	}
 
 	if modules.IsEnabled(server_structs.DirectorType) {
@@ -153,15 +166,15 @@ func LaunchModules(ctx context.Context, modules server_structs.ServerType) (serv
 	config.UpdateConfigFromListener(ln)
 
 	servers = make([]server_structs.XRootDServer, 0)
+	var originServer server_structs.XRootDServer
 
 	if modules.IsEnabled(server_structs.OriginType) {
 
-		var server server_structs.XRootDServer
-		server, err = OriginServe(ctx, engine, egrp, modules)
+		originServer, err = OriginServe(ctx, engine, egrp, modules)
 		if err != nil {
 			return
 		}
-		servers = append(servers, server)
+		servers = append(servers, originServer)
 
 		var originExports []server_utils.OriginExport
 		originExports, err = server_utils.GetOriginExports()
@@ -197,10 +210,12 @@ func LaunchModules(ctx context.Context, modules server_structs.ServerType) (serv
 	}
 
 	log.Info("Starting web engine...")
+	metrics.SetComponentHealthStatus(metrics.Server_WebEngine, metrics.StatusWarning, "Web engine is starting")
 	lnReference = nil
 	egrp.Go(func() error {
 		if err := web_ui.RunEngineRoutineWithListener(ctx, engine, egrp, true, ln); err != nil {
 			log.Errorln("Failure when running the web engine:", err)
+			metrics.SetComponentHealthStatus(metrics.Server_WebEngine, metrics.StatusCritical, "Web engine failed: "+err.Error())
 			return err
 		}
 		log.Info("Web engine has shutdown")
@@ -213,6 +228,7 @@ func LaunchModules(ctx context.Context, modules server_structs.ServerType) (serv
 		log.Errorln("Web engine check failed: ", err)
 		return
 	}
+	metrics.SetComponentHealthStatus(metrics.Server_WebEngine, metrics.StatusOK, "")
 	if param.Origin_EnableIssuer.GetBool() {
 		oa4mpHealthCheckUrl := param.Server_ExternalWebUrl.GetString() + "/api/v1.0/issuer/.well-known/openid-configuration"
 		if err = server_utils.WaitUntilWorking(ctx, "GET", oa4mpHealthCheckUrl, "Issuer", http.StatusOK, true); err != nil {
@@ -330,6 +346,27 @@ func LaunchModules(ctx context.Context, modules server_structs.ServerType) (serv
 		if err = launcher_utils.LaunchPeriodicAdvertise(ctx, egrp, servers); err != nil {
 			return
 		}
+		// Let the director know when XRootD drains for a restart
+		xrootd.SetRestartAdvertiser(func(ctx context.Context) error {
+			return launcher_utils.Advertise(ctx, servers)
+		})
+	}
+
+	if originServer != nil {
+		// Embargoed exports become public (or stop being public) without waiting for
+		// the next authorization refresh or advertisement
+		err = origin.LaunchEmbargoMaintenance(ctx, egrp, func() error {
+			if err := xrootd.EmitAuthfile(originServer); err != nil {
+				return err
+			}
+			if err := xrootd.EmitScitokensConfig(originServer); err != nil {
+				return err
+			}
+			return launcher_utils.Advertise(ctx, servers)
+		})
+		if err != nil {
+			return
+		}
 	}
 
 	if modules.IsEnabled(server_structs.LocalCacheType) {
@@ -354,5 +391,6 @@ func LaunchModules(ctx context.Context, modules server_structs.ServerType) (serv
 		egrp.Go(func() error { return web_ui.InitServerWebLogin(ctx) })
 	}
 
+	metrics.SetServerStarted(true)
 	return
 }
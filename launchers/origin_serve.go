@@ -24,7 +24,10 @@ import (
 	"context"
 	_ "embed"
 	"net/url"
+	"reflect"
+	"slices"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -33,6 +36,8 @@ import (
 	"github.com/spf13/viper"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/daemon"
 	"github.com/pelicanplatform/pelican/launcher_utils"
 	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/oa4mp"
@@ -46,6 +51,12 @@ import (
 func OriginServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group, modules server_structs.ServerType) (server_structs.XRootDServer, error) {
 	metrics.SetComponentHealthStatus(metrics.OriginCache_XRootD, metrics.StatusWarning, "XRootD is initializing")
 	metrics.SetComponentHealthStatus(metrics.OriginCache_CMSD, metrics.StatusWarning, "CMSD is initializting")
+	module := server_structs.OriginType.String()
+	metrics.AddLivenessComponents(module, metrics.OriginCache_XRootD, metrics.Origin_Database)
+	metrics.AddReadinessComponents(module, metrics.OriginCache_Federation)
+	if param.Origin_EnableCmsd.GetBool() {
+		metrics.AddLivenessComponents(module, metrics.OriginCache_CMSD)
+	}
 
 	err := xrootd.SetUpMonitoring(ctx, egrp)
 	if err != nil {
@@ -61,6 +72,7 @@ func OriginServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group,
 	if err := origin.InitializeDB(); err != nil {
 		return nil, errors.Wrap(err, "failed to initialize origin sqlite database")
 	}
+	origin.LaunchDBHealthCheck(ctx, egrp)
 
 	origin.ConfigOriginTTLCache(ctx, egrp)
 
@@ -69,6 +81,11 @@ func OriginServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group,
 		return nil, errors.Wrap(err, "failed to initialize origin exports")
 	}
 
+	// Refuse to start with rewrite rules that do not compile rather than fail on each request
+	if _, err := server_utils.GetOriginPathRewriter(); err != nil {
+		return nil, err
+	}
+
 	if param.Origin_StorageType.GetString() == string(server_structs.OriginStorageGlobus) {
 		if err := origin.InitGlobusBackend(originExports); err != nil {
 			return nil, errors.Wrap(err, "failed to initialize Globus backend")
@@ -102,6 +119,30 @@ func OriginServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group,
 		return nil, err
 	}
 
+	if err = origin.LaunchObjectVersioning(ctx, egrp); err != nil {
+		return nil, err
+	}
+
+	if err = origin.LaunchUploadScanning(ctx, egrp); err != nil {
+		return nil, err
+	}
+
+	if err = origin.LaunchExportScan(ctx, egrp); err != nil {
+		return nil, err
+	}
+
+	if err = origin.LaunchUploadStaging(ctx, egrp); err != nil {
+		return nil, err
+	}
+
+	if err = origin.ConfigArchiveMembers(); err != nil {
+		return nil, err
+	}
+
+	if err = origin.LaunchWriteNotifications(ctx, egrp); err != nil {
+		return nil, err
+	}
+
 	if param.Origin_SelfTest.GetBool() {
 		egrp.Go(func() error { return origin.PeriodicSelfTest(ctx) })
 	}
@@ -112,14 +153,6 @@ func OriginServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group,
 		return nil, err
 	}
 
-	if param.Origin_EnableIssuer.GetBool() {
-		oa4mp_launcher, err := oa4mp.ConfigureOA4MP()
-		if err != nil {
-			return nil, err
-		}
-		launchers = append(launchers, oa4mp_launcher)
-	}
-
 	portStartCallback := func(port int) {
 		viper.Set("Origin.Port", port)
 		if originUrl, err := url.Parse(param.Origin_Url.GetString()); err == nil {
@@ -130,12 +163,29 @@ func OriginServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group,
 		log.Infoln("Origin startup complete on port", port)
 	}
 
-	pids, err := xrootd.LaunchDaemons(ctx, launchers, egrp, portStartCallback)
+	pids, err := xrootd.LaunchRestartableDaemons(ctx, egrp, originServer, launchers, portStartCallback)
 	if err != nil {
 		return nil, err
 	}
+
+	if param.Origin_EnableIssuer.GetBool() {
+		oa4mp_launcher, err := oa4mp.ConfigureOA4MP()
+		if err != nil {
+			return nil, err
+		}
+		oa4mpPids, err := daemon.LaunchDaemons(ctx, []daemon.Launcher{oa4mp_launcher}, egrp)
+		if err != nil {
+			return nil, err
+		}
+		pids = append(pids, oa4mpPids...)
+	}
 	originServer.SetPids(pids)
 
+	// The S3 gateway forwards requests to Origin.Url, which is only final once XRootD has started
+	if err = origin.LaunchS3Gateway(ctx, egrp); err != nil {
+		return nil, err
+	}
+
 	// LaunchOriginDaemons may edit the viper config; these launched goroutines are purposely
 	// delayed until after the viper config is done.
 	xrootd.LaunchXrootdMaintenance(ctx, originServer, 2*time.Minute)
@@ -172,5 +222,50 @@ func OriginServeFinish(ctx context.Context, egrp *errgroup.Group) error {
 		return origin.ShutdownOriginDB()
 	})
 
+	registerOriginExportsReload(ctx, egrp, originExports)
+
 	return nil
 }
+
+// Restart XRootD when a configuration reload changes the origin's exports, registering
+// any new namespaces.  The previous exports stay in effect if the new ones are invalid.
+func registerOriginExportsReload(ctx context.Context, egrp *errgroup.Group, originExports []server_utils.OriginExport) {
+	var mutex sync.Mutex
+	running := originExports
+	config.RegisterReloadable(func() error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		server_utils.ResetOriginExports()
+		newExports, err := server_utils.GetOriginExports()
+		if err != nil {
+			return err
+		}
+		if reflect.DeepEqual(newExports, running) {
+			return nil
+		}
+		for _, export := range newExports {
+			isNew := !slices.ContainsFunc(running, func(old server_utils.OriginExport) bool {
+				return old.FederationPrefix == export.FederationPrefix
+			})
+			if isNew {
+				if err := launcher_utils.RegisterNamespaceWithRetry(ctx, egrp, export.FederationPrefix); err != nil {
+					return err
+				}
+			}
+		}
+		if err := xrootd.RestartXrootd(server_structs.OriginType, "the origin exports changed"); err != nil {
+			return err
+		}
+		running = newExports
+		return nil
+	},
+		param.Origin_Exports.GetName(),
+		param.Origin_StoragePrefix.GetName(),
+		param.Origin_FederationPrefix.GetName(),
+		param.Origin_EnableReads.GetName(),
+		param.Origin_EnableWrites.GetName(),
+		param.Origin_EnableListings.GetName(),
+		param.Origin_EnableDirectReads.GetName(),
+		param.Origin_EnablePublicReads.GetName(),
+	)
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/registry"
+	"github.com/pelicanplatform/pelican/server_structs"
 )
 
 func RegistryServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group) error {
@@ -40,6 +41,9 @@ func RegistryServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group
 	if err != nil {
 		return errors.Wrap(err, "Unable to initialize the namespace registry database")
 	}
+	metrics.AddLivenessComponents(server_structs.RegistryType.String(), metrics.Registry_Database)
+	registry.LaunchDBHealthCheck(ctx, egrp)
+	registry.InitIssuerMetadataCache(ctx, egrp)
 
 	if param.Server_EnableUI.GetBool() {
 		registry.InitOptionsCache(ctx, egrp)
@@ -48,6 +52,14 @@ func RegistryServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group
 			return err
 		}
 
+		if err = registry.InitIdentityProviders(); err != nil {
+			return err
+		}
+
+		if err = registry.InitRegistrationVerification(); err != nil {
+			return err
+		}
+
 		if err := registry.InitInstConfig(ctx, egrp); err != nil {
 			return err
 		}
@@ -0,0 +1,88 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package launchers
+
+import (
+	"context"
+	"os"
+	"time"
+
+	sddaemon "github.com/coreos/go-systemd/v22/daemon"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+)
+
+// How often to check whether the server became ready
+var systemdReadinessInterval = time.Second
+
+func sdNotify(state string) {
+	if _, err := sddaemon.SdNotify(false, state); err != nil {
+		log.Warningln("Failed to notify systemd:", err)
+	}
+}
+
+// When Pelican runs as a systemd service of Type=notify, tell systemd once the server is
+// ready for traffic, keep it updated on what startup is waiting for, and, if the service
+// has a WatchdogSec, keep the watchdog fed.
+func launchSystemdNotify(ctx context.Context, egrp *errgroup.Group) {
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return
+	}
+	watchdogInterval, err := sddaemon.SdWatchdogEnabled(false)
+	if err != nil {
+		log.Warningln("Invalid systemd watchdog configuration:", err)
+	}
+
+	egrp.Go(func() error {
+		// A nil channel never fires, leaving the watchdog unfed when it's disabled
+		var watchdog <-chan time.Time
+		if watchdogInterval > 0 {
+			// Notify at half the interval, as systemd recommends
+			watchdogTicker := time.NewTicker(watchdogInterval / 2)
+			defer watchdogTicker.Stop()
+			watchdog = watchdogTicker.C
+		}
+		readinessTicker := time.NewTicker(systemdReadinessInterval)
+		defer readinessTicker.Stop()
+		ready := false
+		lastStatus := ""
+		for {
+			select {
+			case <-ctx.Done():
+				sdNotify(sddaemon.SdNotifyStopping)
+				return nil
+			case <-watchdog:
+				sdNotify(sddaemon.SdNotifyWatchdog)
+			case <-readinessTicker.C:
+				readiness := metrics.GetProbe(metrics.ProbeReadiness)
+				status := readiness.Summary()
+				if readiness.Pass && !ready {
+					ready = true
+					log.Infoln("Notifying systemd that the server is ready")
+					sdNotify(sddaemon.SdNotifyReady + "\nSTATUS=" + status)
+				} else if status != lastStatus {
+					sdNotify("STATUS=" + status)
+				}
+				lastStatus = status
+			}
+		}
+	})
+}
@@ -0,0 +1,91 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package launchers
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+)
+
+func TestLaunchSystemdNotify(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", socketPath)
+	t.Setenv("WATCHDOG_USEC", "200000")
+
+	oldInterval := systemdReadinessInterval
+	systemdReadinessInterval = 10 * time.Millisecond
+	metrics.ResetProbes()
+	t.Cleanup(func() {
+		systemdReadinessInterval = oldInterval
+		metrics.ResetProbes()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	egrp := &errgroup.Group{}
+	launchSystemdNotify(ctx, egrp)
+
+	messages := make(chan string, 100)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				close(messages)
+				return
+			}
+			messages <- string(buf[:n])
+		}
+	}()
+	waitFor := func(prefix string) string {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case msg := <-messages:
+				if strings.HasPrefix(msg, prefix) {
+					return msg
+				}
+			case <-timeout:
+				require.Fail(t, "timed out waiting for the systemd notification "+prefix)
+				return ""
+			}
+		}
+	}
+
+	assert.Equal(t, "STATUS=Waiting for startup", waitFor("STATUS="))
+	waitFor("WATCHDOG=1")
+	metrics.SetServerStarted(true)
+	assert.Equal(t, "READY=1\nSTATUS=Ready", waitFor("READY=1"))
+
+	cancel()
+	require.NoError(t, egrp.Wait())
+	waitFor("STOPPING=1")
+}
@@ -38,6 +38,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/token"
@@ -98,13 +99,15 @@ func (lc *LocalCache) LaunchListener(ctx context.Context, egrp *errgroup.Group)
 				w.Header().Set("Content-Length", strconv.FormatUint(size, 10))
 			}
 		} else {
-			ctx = context.Background()
+			// The download may be shared with other clients, so it's not cancelled with the
+			// request; it's still part of the request's trace
+			reqCtx := context.WithoutCancel(r.Context())
 			if headerTimeout > 0 {
 				var cancelReqFunc context.CancelFunc
-				ctx, cancelReqFunc = context.WithTimeout(ctx, headerTimeout)
+				reqCtx, cancelReqFunc = context.WithTimeout(reqCtx, headerTimeout)
 				defer cancelReqFunc()
 			}
-			reader, err = lc.Get(ctx, path, bearerToken)
+			reader, err = lc.Get(reqCtx, path, bearerToken)
 		}
 		if errors.Is(err, authorizationDenied) {
 			w.WriteHeader(http.StatusForbidden)
@@ -135,6 +138,8 @@ func (lc *LocalCache) LaunchListener(ctx context.Context, egrp *errgroup.Group)
 		if r.Method == "HEAD" {
 			return
 		}
+		reader = metrics.TraceBody(r.Context(), "localcache.streamResponse", reader)
+		defer reader.Close()
 		if _, err = io.Copy(w, reader); err != nil && sendTrailer {
 			// TODO: Enumerate more error values
 			w.Header().Set("X-Transfer-Status", fmt.Sprintf("%d: %s", 500, err))
@@ -143,7 +148,7 @@ func (lc *LocalCache) LaunchListener(ctx context.Context, egrp *errgroup.Group)
 		}
 	}
 	srv := http.Server{
-		Handler: http.HandlerFunc(handler),
+		Handler: metrics.TraceHandler("localcache", http.HandlerFunc(handler)),
 	}
 	egrp.Go(func() error {
 		return srv.Serve(listener)
@@ -20,6 +20,8 @@ package local_cache
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 	"path"
 	"slices"
@@ -32,8 +34,10 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/token"
 	"github.com/pelicanplatform/pelican/token_scopes"
@@ -44,7 +48,13 @@ type (
 		ns         atomic.Pointer[[]server_structs.NamespaceAdV2]
 		issuers    atomic.Pointer[map[string]bool]
 		issuerKeys *ttlcache.Cache[string, authConfigItem]
-		tokenAuthz *ttlcache.Cache[string, acls]
+		// The authorization of verified tokens, keyed by the hash of the token
+		tokenAuthz         *ttlcache.Cache[string, tokenAuthzItem]
+		tokenAuthzLoads    singleflight.Group
+		tokenAuthzLifetime time.Duration
+		revoked            atomic.Pointer[revocations]
+		// The last revocation list fetched from each source
+		revocationLists atomic.Pointer[map[revocationListSource]*revocationList]
 	}
 
 	authConfigItem struct {
@@ -53,6 +63,15 @@ type (
 	}
 
 	acls []token_scopes.ResourceScope
+
+	// The ACLs of a verified token and the claims needed to check if it was revoked
+	tokenAuthzItem struct {
+		acls     acls
+		issuer   string
+		jti      string
+		subject  string
+		issuedAt time.Time
+	}
 )
 
 func newAuthConfig(ctx context.Context, egrp *errgroup.Group) (ac *authConfig) {
@@ -90,9 +109,15 @@ func newAuthConfig(ctx context.Context, egrp *errgroup.Group) (ac *authConfig) {
 		ttlcache.WithLoader[string, authConfigItem](ttlcache.NewSuppressedLoader[string, authConfigItem](loader, nil)),
 	)
 
-	ac.tokenAuthz = ttlcache.New[string, acls](
-		ttlcache.WithTTL[string, acls](5*time.Minute),
-		ttlcache.WithLoader[string, acls](ttlcache.LoaderFunc[string, acls](ac.loader)),
+	ac.tokenAuthzLifetime = param.LocalCache_TokenAuthzCacheLifetime.GetDuration()
+	if ac.tokenAuthzLifetime <= 0 {
+		log.Warningf("Invalid LocalCache.TokenAuthzCacheLifetime %s; using 5m", ac.tokenAuthzLifetime.String())
+		ac.tokenAuthzLifetime = 5 * time.Minute
+	}
+	ac.tokenAuthz = ttlcache.New[string, tokenAuthzItem](
+		ttlcache.WithTTL[string, tokenAuthzItem](ac.tokenAuthzLifetime),
+		// Using the token must not keep its authorization around past the token's expiration
+		ttlcache.WithDisableTouchOnHit[string, tokenAuthzItem](),
 	)
 
 	egrp.Go(func() error {
@@ -103,6 +128,9 @@ func newAuthConfig(ctx context.Context, egrp *errgroup.Group) (ac *authConfig) {
 		ac.tokenAuthz.Start()
 		return nil
 	})
+	egrp.Go(func() error {
+		return ac.periodicUpdateRevocations(ctx)
+	})
 	egrp.Go(func() error {
 		<-ctx.Done()
 		ac.issuerKeys.Stop()
@@ -127,17 +155,38 @@ func (ac *authConfig) updateConfig(nsAds []server_structs.NamespaceAdV2) error {
 	return nil
 }
 
-func (ac *authConfig) getResourceScopes(token string) (scopes []token_scopes.ResourceScope, issuer string, err error) {
+// Determine whether the namespace containing the resource is writable; objects
+// in such namespaces may change after they have been cached.
+func (ac *authConfig) isMutable(resource string) bool {
+	namespaces := ac.ns.Load()
+	if namespaces == nil {
+		return false
+	}
+	var best *server_structs.NamespaceAdV2
+	for idx := range *namespaces {
+		conf := &(*namespaces)[idx]
+		nsScope := token_scopes.NewResourceScope(token_scopes.Storage_Read, conf.Path)
+		if !nsScope.Contains(token_scopes.NewResourceScope(token_scopes.Storage_Read, resource)) {
+			continue
+		}
+		if best == nil || len(conf.Path) > len(best.Path) {
+			best = conf
+		}
+	}
+	return best != nil && best.Caps.Writes
+}
+
+func (ac *authConfig) getResourceScopes(token string) (scopes []token_scopes.ResourceScope, tok jwt.Token, err error) {
 	if token == "" {
 		return
 	}
 
-	tok, err := jwt.Parse([]byte(token), jwt.WithVerify(false))
+	tok, err = jwt.Parse([]byte(token), jwt.WithVerify(false))
 	if err != nil {
 		err = errors.Wrap(err, "failed to parse incoming JWT when authorizing request")
 		return
 	}
-	issuer = tok.Issuer()
+	issuer := tok.Issuer()
 
 	issuers := ac.issuers.Load()
 	if !(*issuers)[issuer] {
@@ -168,6 +217,7 @@ func (ac *authConfig) getResourceScopes(token string) (scopes []token_scopes.Res
 	}
 	tok, err = jwt.Parse([]byte(token), jwt.WithKeySet(item.set))
 	if err != nil {
+		tok = nil
 		return
 	}
 
@@ -212,15 +262,19 @@ func calcResourceScopes(rs token_scopes.ResourceScope, basePaths []string, restr
 //
 // If the token verification fails then an error will be returned; no authorization
 // should be given.
-func (ac *authConfig) getAcls(token string) (newAcls acls, err error) {
+func (ac *authConfig) getAcls(token string) (newAcls acls, tok jwt.Token, err error) {
 	namespaces := ac.ns.Load()
 	if namespaces == nil {
 		return
 	}
-	resources, issuer, err := ac.getResourceScopes(token)
+	resources, tok, err := ac.getResourceScopes(token)
 	if err != nil {
 		return
 	}
+	issuer := ""
+	if tok != nil {
+		issuer = tok.Issuer()
+	}
 
 	newAcls = make(acls, 0)
 	for _, conf := range *namespaces {
@@ -246,23 +300,57 @@ func (ac *authConfig) getAcls(token string) (newAcls acls, err error) {
 	return
 }
 
-func (ac *authConfig) loader(cache *ttlcache.Cache[string, acls], token string) *ttlcache.Item[string, acls] {
-	acls, err := ac.getAcls(token)
-	if err != nil {
-		// If the token is not a valid one signed by a known issuer, do not keep it in memory (avoids a DoS)
-		log.Warningln("Rejecting invalid token:", err)
-		return nil
-	}
+// Get the authorization of the token, verifying it if it isn't cached.
+//
+// Tokens are cached by their hash, until they expire or the authorization
+// cache lifetime passes, whichever is first.
+func (ac *authConfig) getTokenAuthz(token string) *ttlcache.Item[string, tokenAuthzItem] {
+	tokenHash := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(tokenHash[:])
+	loader := ttlcache.LoaderFunc[string, tokenAuthzItem](
+		func(cache *ttlcache.Cache[string, tokenAuthzItem], key string) *ttlcache.Item[string, tokenAuthzItem] {
+			acls, tok, err := ac.getAcls(token)
+			if err != nil {
+				// If the token is not a valid one signed by a known issuer, do not keep it in memory (avoids a DoS)
+				log.Warningln("Rejecting invalid token:", err)
+				return nil
+			}
 
-	item := cache.Set(token, acls, ttlcache.DefaultTTL)
-	return item
+			authzItem := tokenAuthzItem{acls: acls}
+			ttl := ttlcache.DefaultTTL
+			if tok != nil {
+				authzItem.issuer = tok.Issuer()
+				authzItem.jti = tok.JwtID()
+				authzItem.subject = tok.Subject()
+				authzItem.issuedAt = tok.IssuedAt()
+				if expiry := tok.Expiration(); !expiry.IsZero() {
+					untilExpiry := time.Until(expiry)
+					if untilExpiry <= 0 {
+						return nil
+					}
+					if untilExpiry < ac.tokenAuthzLifetime {
+						ttl = untilExpiry
+					}
+				}
+			}
+			return cache.Set(key, authzItem, ttl)
+		},
+	)
+	return ac.tokenAuthz.Get(key, ttlcache.WithLoader[string, tokenAuthzItem](ttlcache.NewSuppressedLoader[string, tokenAuthzItem](loader, &ac.tokenAuthzLoads)))
 }
 
 func (ac *authConfig) authorize(action token_scopes.TokenScope, resource, token string) bool {
-	aclsItem := ac.tokenAuthz.Get(token)
-	if aclsItem == nil {
+	authzItem := ac.getTokenAuthz(token)
+	if authzItem == nil {
 		return false
 	}
+	authz := authzItem.Value()
+	if revoked := ac.revoked.Load(); revoked != nil && authz.issuer != "" {
+		if revoked.isRevoked(authz.issuer, authz.jti, authz.subject, authz.issuedAt) {
+			log.Warningf("Rejecting token for subject %s revoked by its issuer %s", authz.subject, authz.issuer)
+			return false
+		}
+	}
 	rsScope := token_scopes.NewResourceScope(action, resource)
-	return slices.ContainsFunc(aclsItem.Value(), func(rs token_scopes.ResourceScope) bool { return rs.Contains(rsScope) })
+	return slices.ContainsFunc(authz.acls, func(rs token_scopes.ResourceScope) bool { return rs.Contains(rsScope) })
 }
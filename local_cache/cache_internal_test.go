@@ -21,10 +21,16 @@
 package local_cache
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/token_scopes"
 )
 
@@ -81,3 +87,41 @@ func TestCalcResources(t *testing.T) {
 		assert.Equal(t, test.result, result)
 	}
 }
+
+func TestIsMutable(t *testing.T) {
+	ac := &authConfig{}
+	assert.False(t, ac.isMutable("/foo/bar"))
+
+	nsAds := []server_structs.NamespaceAdV2{
+		{Path: "/foo", Caps: server_structs.Capabilities{PublicReads: true}},
+		{Path: "/foo/writable", Caps: server_structs.Capabilities{Reads: true, Writes: true}},
+		{Path: "/bar", Caps: server_structs.Capabilities{Reads: true, Writes: true}},
+	}
+	ac.ns.Store(&nsAds)
+
+	assert.False(t, ac.isMutable("/foo/bar"))
+	assert.False(t, ac.isMutable("/foo/writable2/obj"))
+	assert.True(t, ac.isMutable("/foo/writable/obj"))
+	assert.True(t, ac.isMutable("/bar/obj"))
+	assert.False(t, ac.isMutable("/baz/obj"))
+}
+
+func TestDoneFile(t *testing.T) {
+	localPath := filepath.Join(t.TempDir(), "obj")
+
+	// DONE files without validators are treated as having none
+	require.NoError(t, os.WriteFile(localPath+".DONE", nil, 0600))
+	validators, _, err := readDoneFile(localPath)
+	require.NoError(t, err)
+	assert.True(t, validators.IsEmpty())
+
+	expected := client.ObjectValidators{ETag: `"abc"`, LastModified: "Tue, 01 Oct 2024 12:00:00 GMT"}
+	require.NoError(t, writeDoneFile(localPath, expected))
+	validators, validated, err := readDoneFile(localPath)
+	require.NoError(t, err)
+	assert.Equal(t, expected, validators)
+	assert.WithinDuration(t, time.Now(), validated, time.Minute)
+
+	_, _, err = readDoneFile(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
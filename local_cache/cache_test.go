@@ -32,10 +32,12 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -45,6 +47,7 @@ import (
 	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/fed_test_utils"
 	local_cache "github.com/pelicanplatform/pelican/local_cache"
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_utils"
 	"github.com/pelicanplatform/pelican/test_utils"
@@ -88,6 +91,53 @@ func TestFedPublicGet(t *testing.T) {
 	assert.Equal(t, "Hello, World!", string(byteBuff))
 }
 
+// Simultaneous misses of the same object are served by a single fetch from the federation
+func TestCoalescedMisses(t *testing.T) {
+	server_utils.ResetTestState()
+	test_utils.InitClient(t, map[string]interface{}{
+		"Client.MaximumDownloadSpeed": 10 * 1024 * 1024,
+	})
+	ft := fed_test_utils.NewFedTest(t, pubOriginCfg)
+
+	fp, err := os.OpenFile(filepath.Join(ft.Exports[0].StoragePrefix, "coalesced.txt"), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	size := test_utils.WriteBigBuffer(t, fp, 20)
+
+	lc, err := local_cache.NewLocalCache(ft.Ctx, ft.Egrp)
+	require.NoError(t, err)
+
+	fetches := testutil.ToFloat64(metrics.PelicanLocalCacheFetchesTotal)
+	coalesced := testutil.ToFloat64(metrics.PelicanLocalCacheCoalescedRequestsTotal)
+	const clients = 10
+	sizes := make([]int64, clients)
+	errs := make([]error, clients)
+	start := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for idx := 0; idx < clients; idx++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			<-start
+			reader, err := lc.Get(context.Background(), "/test/coalesced.txt", "")
+			if err != nil {
+				errs[idx] = err
+				return
+			}
+			defer reader.Close()
+			sizes[idx], errs[idx] = io.Copy(io.Discard, reader)
+		}(idx)
+	}
+	close(start)
+	wg.Wait()
+
+	for idx := 0; idx < clients; idx++ {
+		require.NoError(t, errs[idx])
+		assert.Equal(t, int64(size), sizes[idx])
+	}
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.PelicanLocalCacheFetchesTotal)-fetches)
+	assert.Equal(t, float64(clients-1), testutil.ToFloat64(metrics.PelicanLocalCacheCoalescedRequestsTotal)-coalesced)
+}
+
 // Test the local cache library on an authenticated GET.
 func TestFedAuthGet(t *testing.T) {
 	server_utils.ResetTestState()
@@ -38,10 +38,12 @@ import (
 	"github.com/lestrrat-go/option"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/pelicanplatform/pelican/client"
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/token_scopes"
@@ -118,6 +120,8 @@ type (
 		openErr error
 		status  chan *downloadStatus
 		buf     []byte
+		// Whether the reader asked the cache for the object yet
+		requested bool
 	}
 
 	req struct {
@@ -136,6 +140,9 @@ type (
 		request req
 		size    int64
 		results chan *downloadStatus
+		// Whether this is the first request of the reader, rather than a request for more
+		// of an object it's already waiting on
+		initial bool
 	}
 
 	LocalCacheOption                 = option.Interface
@@ -368,6 +375,7 @@ func (sc *LocalCache) runMux() error {
 	defer ticker.Stop()
 	clientClosed := false
 	for {
+		metrics.PelicanLocalCacheActiveFetches.Set(float64(len(activeJobs)))
 		lenResults := len(tmpResults)
 		lenCancel := len(cancelRequest)
 		lenChan := lenResults + lenCancel
@@ -450,10 +458,13 @@ func (sc *LocalCache) runMux() error {
 				tmpResults = append(tmpResults, result{ds: ad.status, path: reqPath, channel: waiter.notify})
 			}
 			if results.Error == nil {
-				if fp, err := os.OpenFile(filepath.Join(sc.basePath, reqPath)+".DONE", os.O_CREATE|os.O_WRONLY, os.FileMode(0600)); err != nil {
+				// Record the object's validators in the DONE file so a later hit can be revalidated upstream
+				var validators client.ObjectValidators
+				if len(results.Attempts) > 0 {
+					validators = results.Attempts[len(results.Attempts)-1].Validators
+				}
+				if err := writeDoneFile(filepath.Join(sc.basePath, reqPath), validators); err != nil {
 					log.Debugln("Unable to save a DONE file for cache path", reqPath)
-				} else {
-					fp.Close()
 				}
 				sc.lruHit(lruEntry{lastUse: time.Now(), path: reqPath, size: results.TransferredBytes})
 			}
@@ -496,8 +507,12 @@ func (sc *LocalCache) runMux() error {
 			// New request
 			req := recv.Interface().(availSizeReq)
 
-			// See if we can add the request to the waiter list
+			// Simultaneous misses of the same object share a single fetch; the waiters
+			// read the object from disk as it's downloaded
 			if ds := activeJobs[req.request.path]; ds != nil {
+				if req.initial {
+					metrics.PelicanLocalCacheCoalescedRequestsTotal.Inc()
+				}
 				heap.Push(&ds.waiterList, waiterInfo{
 					size:   req.size,
 					notify: req.results,
@@ -521,6 +536,7 @@ func (sc *LocalCache) runMux() error {
 						ds:      ds,
 					})
 					sc.lruHit(lruEntry{lastUse: time.Now(), path: req.request.path, size: fi.Size()})
+					continue
 				}
 			}
 
@@ -555,7 +571,9 @@ func (sc *LocalCache) runMux() error {
 					channel: req.results,
 					ds:      ds,
 				})
+				continue
 			}
+			metrics.PelicanLocalCacheFetchesTotal.Inc()
 			activeJobs[req.request.path] = ad
 			jobPath[tj.ID()] = req.request.path
 			func() {
@@ -654,6 +672,83 @@ func (lc *LocalCache) purge() (err error) {
 	return
 }
 
+// Create the sentinel DONE file for a completed download, recording the validators
+// reported by the upstream server
+func writeDoneFile(localPath string, validators client.ObjectValidators) error {
+	contents, err := json.Marshal(validators)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(localPath+".DONE", contents, os.FileMode(0600))
+}
+
+// Read the validators recorded in the DONE file of a cached object, along with the
+// time the object was last confirmed to be fresh.
+func readDoneFile(localPath string) (validators client.ObjectValidators, validated time.Time, err error) {
+	fi, err := os.Stat(localPath + ".DONE")
+	if err != nil {
+		return
+	}
+	validated = fi.ModTime()
+	contents, err := os.ReadFile(localPath + ".DONE")
+	if err != nil || len(contents) == 0 {
+		return
+	}
+	err = json.Unmarshal(contents, &validators)
+	return
+}
+
+// Confirm that a cached object is still fresh, returning false if the object has
+// changed upstream and the cached copy was discarded.
+//
+// Only objects in mutable namespaces are revalidated, at most once every
+// LocalCache.RevalidateInterval.  If revalidation fails (e.g., the origin is unreachable),
+// the cached copy continues to be served.
+func (lc *LocalCache) revalidate(ctx context.Context, objectPath, token string) bool {
+	interval := param.LocalCache_RevalidateInterval.GetDuration()
+	if interval <= 0 || !lc.ac.isMutable(objectPath) {
+		return true
+	}
+	localPath := filepath.Join(lc.basePath, path.Clean(objectPath))
+	validators, validated, err := readDoneFile(localPath)
+	if err != nil {
+		log.Debugf("Unable to read the validators of cached object %s: %v", objectPath, err)
+		return true
+	}
+	if time.Since(validated) < interval {
+		return true
+	}
+	if validators.IsEmpty() {
+		log.Debugln("No validators were recorded for cached object", objectPath, "; unable to revalidate it")
+		return true
+	}
+
+	dUrl := *lc.directorURL
+	dUrl.Path = objectPath
+	dUrl.Scheme = "pelican"
+	modified, current, err := client.DoRevalidate(ctx, dUrl.String(), validators, client.WithToken(token), client.WithAcquireToken(false))
+	if err != nil {
+		log.Warningf("Failed to revalidate cached object %s; serving the cached copy: %v", objectPath, err)
+		return true
+	}
+	if !modified {
+		// Rewriting the DONE file resets the time the object was last validated
+		if err = writeDoneFile(localPath, current); err != nil {
+			log.Debugln("Unable to update the DONE file for cache path", objectPath)
+		}
+		return true
+	}
+
+	log.Debugln("Cached object", objectPath, "has changed upstream; discarding the cached copy")
+	if err = os.Remove(localPath + ".DONE"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warningln("Failed to remove DONE file of stale object:", err)
+	}
+	if err = os.Remove(localPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warningln("Failed to remove stale object:", err)
+	}
+	return false
+}
+
 // Given a URL, return a reader from the disk cache
 //
 // If there is no sentinal $NAME.DONE file, then returns nil
@@ -683,20 +778,31 @@ func (sc *LocalCache) newCacheReader(ctx context.Context, path, token string) (r
 }
 
 // Get path from the cache
-func (sc *LocalCache) Get(ctx context.Context, path, token string) (io.ReadCloser, error) {
-	if !sc.ac.authorize(token_scopes.Storage_Read, path, token) {
+func (sc *LocalCache) Get(ctx context.Context, path, token string) (reader io.ReadCloser, err error) {
+	_, authSpan := metrics.StartSpan(ctx, "localcache.authorize")
+	authorized := sc.ac.authorize(token_scopes.Storage_Read, path, token)
+	authSpan.SetAttributes(attribute.Bool("pelican.authorized", authorized))
+	authSpan.End()
+	if !authorized {
 		return nil, authorizationDenied
 	}
 
+	ctx, span := metrics.StartSpan(ctx, "localcache.fetch", attribute.String("pelican.path", path))
+	defer func() { metrics.EndSpan(span, err) }()
 	if fp := sc.getFromDisk(path); fp != nil {
-		finfo, err := fp.Stat()
-		if err != nil {
-			log.Warningf("Able to open %s in cache but unable to stat it: %v", path, err)
+		if sc.revalidate(ctx, path, token) {
+			finfo, err := fp.Stat()
+			if err != nil {
+				log.Warningf("Able to open %s in cache but unable to stat it: %v", path, err)
+			}
+			span.SetAttributes(attribute.Bool("pelican.cache_hit", true))
+			sc.hitChan <- lruEntry{lastUse: time.Now(), path: path, size: finfo.Size()}
+			return fp, nil
 		}
-		sc.hitChan <- lruEntry{lastUse: time.Now(), path: path, size: finfo.Size()}
-		return fp, nil
+		fp.Close()
 	}
 
+	span.SetAttributes(attribute.Bool("pelican.cache_hit", false))
 	return sc.newCacheReader(ctx, path, token)
 
 }
@@ -707,11 +813,14 @@ func (lc *LocalCache) Stat(path, token string) (uint64, error) {
 	}
 
 	if fp := lc.getFromDisk(path); fp != nil {
-		finfo, err := fp.Stat()
-		if err != nil {
-			return 0, errors.New("Failed to determine cached file size for object")
+		defer fp.Close()
+		if lc.revalidate(context.Background(), path, token) {
+			finfo, err := fp.Stat()
+			if err != nil {
+				return 0, errors.New("Failed to determine cached file size for object")
+			}
+			return uint64(finfo.Size()), nil
 		}
-		return uint64(finfo.Size()), nil
 	}
 
 	dUrl := *lc.directorURL
@@ -857,6 +966,7 @@ func (cr *cacheReader) readRaw(ctx context.Context, p []byte) (n int, err error)
 				request: req,
 				size:    neededSize,
 				results: cr.status,
+				initial: !cr.requested,
 			}
 			select {
 			case <-cr.sc.ctx.Done():
@@ -864,6 +974,7 @@ func (cr *cacheReader) readRaw(ctx context.Context, p []byte) (n int, err error)
 				return
 			case cr.sc.sizeReq <- sizeReq:
 			}
+			cr.requested = true
 		}
 		select {
 		case <-cr.sc.ctx.Done():
@@ -0,0 +1,199 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package local_cache
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+// The largest token revocation list the local cache reads
+const maxRevocationListSize = 16 * 1024 * 1024
+
+type (
+	// An entry of LocalCache.TokenRevocationLists: the URL of an issuer's revocation list
+	revocationListSource struct {
+		Issuer string `mapstructure:"issuer"`
+		Url    string `mapstructure:"url"`
+	}
+
+	// A token revocation list as published by an issuer
+	revocationList struct {
+		Issuer          string           `json:"issuer"`
+		RevokedTokens   []string         `json:"revoked_tokens"`
+		RevokedSubjects map[string]int64 `json:"revoked_subjects"`
+	}
+
+	// The revoked tokens of one issuer, indexed for lookup on each request
+	issuerRevocations struct {
+		tokens   map[string]bool
+		subjects map[string]time.Time
+	}
+
+	// The revoked tokens of all issuers, keyed by issuer
+	revocations map[string]*issuerRevocations
+)
+
+// Whether the token with the given claims has been revoked by its issuer
+func (r revocations) isRevoked(issuer, jti, subject string, issuedAt time.Time) bool {
+	revoked := r[issuer]
+	if revoked == nil {
+		return false
+	}
+	if jti != "" && revoked.tokens[jti] {
+		return true
+	}
+	if revokedBefore, ok := revoked.subjects[subject]; ok && subject != "" {
+		// A token without an issue time can't be shown to predate the revocation
+		return issuedAt.IsZero() || !issuedAt.After(revokedBefore)
+	}
+	return false
+}
+
+func (r revocations) add(list *revocationList) {
+	revoked := r[list.Issuer]
+	if revoked == nil {
+		revoked = &issuerRevocations{tokens: make(map[string]bool), subjects: make(map[string]time.Time)}
+		r[list.Issuer] = revoked
+	}
+	for _, jti := range list.RevokedTokens {
+		revoked.tokens[jti] = true
+	}
+	for subject, unixTime := range list.RevokedSubjects {
+		revokedBefore := time.Unix(unixTime, 0)
+		if existing, ok := revoked.subjects[subject]; !ok || revokedBefore.After(existing) {
+			revoked.subjects[subject] = revokedBefore
+		}
+	}
+}
+
+// Fetch the revocation list of an issuer.  The list only ever revokes the tokens of the
+// issuer it's configured for, whatever issuer the document itself claims.
+func fetchRevocationList(ctx context.Context, source revocationListSource) (list *revocationList, err error) {
+	listUrl := source.Url
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, listUrl, nil)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create the request for the token revocation list %s", listUrl)
+		return
+	}
+	client := &http.Client{Transport: config.GetTransport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to fetch the token revocation list %s", listUrl)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = errors.Errorf("failed to fetch the token revocation list %s: HTTP %d", listUrl, resp.StatusCode)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRevocationListSize+1))
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read the token revocation list %s", listUrl)
+		return
+	}
+	if len(body) > maxRevocationListSize {
+		err = errors.Errorf("the token revocation list %s is larger than %d bytes", listUrl, maxRevocationListSize)
+		return
+	}
+	list = &revocationList{}
+	if err = json.Unmarshal(body, list); err != nil {
+		err = errors.Wrapf(err, "failed to parse the token revocation list %s", listUrl)
+		return
+	}
+	if list.Issuer != "" && list.Issuer != source.Issuer {
+		err = errors.Errorf("the token revocation list %s is for the issuer %s rather than %s", listUrl, list.Issuer, source.Issuer)
+		list = nil
+		return
+	}
+	list.Issuer = source.Issuer
+	return
+}
+
+// Fetch the revocation lists in LocalCache.TokenRevocationLists.  A list that
+// can't be fetched keeps the revocations from its last successful fetch.
+func (ac *authConfig) updateRevocations(ctx context.Context, sources []revocationListSource) {
+	lastLists := ac.revocationLists.Load()
+	lists := make(map[revocationListSource]*revocationList, len(sources))
+	for _, source := range sources {
+		list, err := fetchRevocationList(ctx, source)
+		if err != nil {
+			log.Warningln("Failed to update the token revocations:", err)
+			if lastLists == nil || (*lastLists)[source] == nil {
+				continue
+			}
+			list = (*lastLists)[source]
+		}
+		lists[source] = list
+	}
+
+	revoked := make(revocations)
+	for _, list := range lists {
+		revoked.add(list)
+	}
+	ac.revocationLists.Store(&lists)
+	ac.revoked.Store(&revoked)
+}
+
+// Periodically fetch the token revocation lists until the context is cancelled
+func (ac *authConfig) periodicUpdateRevocations(ctx context.Context) error {
+	configured := []revocationListSource{}
+	if err := param.LocalCache_TokenRevocationLists.Unmarshal(&configured); err != nil {
+		log.Errorln("Ignoring the token revocation lists; failed to read LocalCache.TokenRevocationLists:", err)
+		return nil
+	}
+	sources := make([]revocationListSource, 0, len(configured))
+	for _, source := range configured {
+		if source.Issuer == "" || source.Url == "" {
+			log.Warningf("Ignoring the entry of LocalCache.TokenRevocationLists with issuer %q and URL %q; both must be set", source.Issuer, source.Url)
+			continue
+		}
+		sources = append(sources, source)
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	interval := param.LocalCache_TokenRevocationListRefreshInterval.GetDuration()
+	if interval <= 0 {
+		log.Warningf("Invalid LocalCache.TokenRevocationListRefreshInterval %s; using 1m", interval.String())
+		interval = time.Minute
+	}
+	ac.updateRevocations(ctx, sources)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			ac.updateRevocations(ctx, sources)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package local_cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateRevocations(t *testing.T) {
+	revokedAt := time.Now().Truncate(time.Second)
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/issuer1":
+			_, _ = w.Write([]byte(`{"issuer": "https://issuer1.example.org", "revoked_tokens": ["token-1"], "revoked_subjects": {"mallory": ` +
+				strconv.FormatInt(revokedAt.Unix(), 10) + `}}`))
+		case "/no-issuer":
+			_, _ = w.Write([]byte(`{"revoked_tokens": ["token-2"]}`))
+		case "/impostor":
+			// A list claiming to be another issuer's can't revoke that issuer's tokens
+			_, _ = w.Write([]byte(`{"issuer": "https://issuer1.example.org", "revoked_tokens": ["token-3"]}`))
+		case "/oversized":
+			_, _ = w.Write([]byte(`{"revoked_tokens": ["token-4"], "padding": "` + strings.Repeat("x", maxRevocationListSize) + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ac := &authConfig{}
+	issuer1 := revocationListSource{Issuer: "https://issuer1.example.org", Url: srv.URL + "/issuer1"}
+	ac.updateRevocations(context.Background(), []revocationListSource{
+		issuer1,
+		{Issuer: "https://issuer2.example.org", Url: srv.URL + "/no-issuer"},
+		{Issuer: "https://issuer3.example.org", Url: srv.URL + "/impostor"},
+		{Issuer: "https://issuer1.example.org", Url: srv.URL + "/missing"},
+		{Issuer: "https://issuer4.example.org", Url: srv.URL + "/oversized"},
+	})
+	revoked := ac.revoked.Load()
+	require.NotNil(t, revoked)
+
+	check := func(revoked *revocations) {
+		assert.True(t, revoked.isRevoked("https://issuer1.example.org", "token-1", "alice", time.Now()))
+		// The same token ID from another issuer is a different token
+		assert.False(t, revoked.isRevoked("https://issuer2.example.org", "token-1", "alice", time.Now()))
+		assert.False(t, revoked.isRevoked("https://issuer1.example.org", "token-2", "alice", time.Now()))
+		// Lists without an issuer are bound to the issuer they're configured for
+		assert.True(t, revoked.isRevoked("https://issuer2.example.org", "token-2", "alice", time.Now()))
+		assert.False(t, revoked.isRevoked("https://issuer1.example.org", "token-3", "alice", time.Now()))
+		assert.False(t, revoked.isRevoked("https://issuer3.example.org", "token-3", "alice", time.Now()))
+		// Lists too large to read are refused
+		assert.False(t, revoked.isRevoked("https://issuer4.example.org", "token-4", "alice", time.Now()))
+
+		// Only the subject's tokens issued before the revocation are revoked
+		assert.True(t, revoked.isRevoked("https://issuer1.example.org", "", "mallory", revokedAt.Add(-time.Hour)))
+		assert.True(t, revoked.isRevoked("https://issuer1.example.org", "", "mallory", time.Time{}))
+		assert.False(t, revoked.isRevoked("https://issuer1.example.org", "", "mallory", revokedAt.Add(time.Second)))
+	}
+	check(revoked)
+
+	// The last revocation lists remain in effect if they can't be fetched
+	fail.Store(true)
+	ac.updateRevocations(context.Background(), []revocationListSource{issuer1, {Issuer: "https://issuer2.example.org", Url: srv.URL + "/no-issuer"}})
+	check(ac.revoked.Load())
+}
@@ -0,0 +1,58 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type BrokerReversalResult string
+
+const (
+	BrokerReversalSucceeded BrokerReversalResult = "Succeeded"
+	BrokerReversalTimeout   BrokerReversalResult = "Timeout"
+)
+
+var (
+	PelicanBrokerWaitingServices = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_broker_waiting_services",
+		Help: "The number of polls from services behind firewalls waiting at the broker for a connection reversal request",
+	}, []string{"prefix"})
+
+	PelicanBrokerPendingReversals = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_broker_pending_reversals",
+		Help: "The number of connection reversal requests waiting at the broker for the service to poll",
+	}, []string{"prefix"})
+
+	PelicanBrokerReversalsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_broker_reversals_total",
+		Help: "The total number of connection reversal requests the broker handled, by result: Succeeded|Timeout",
+	}, []string{"prefix", "result"})
+
+	PelicanBrokerReversedConnectionsActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pelican_broker_reversed_connections_active",
+		Help: "The number of open connections a service behind a firewall accepted through the broker",
+	})
+
+	PelicanBrokerReversedConnectionsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pelican_broker_reversed_connections_total",
+		Help: "The total number of connections a service behind a firewall accepted through the broker",
+	})
+)
@@ -0,0 +1,76 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	PelicanCacheNamespaceDiskUsage = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_cache_namespace_disk_usage_bytes",
+		Help: "The cache disk space used by each namespace with a limit in Cache.NamespaceLimits",
+	}, []string{"namespace"})
+
+	PelicanCacheNamespaceEgressRate = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_cache_namespace_egress_bytes_per_second",
+		Help: "The rate at which the cache served data for each namespace with a limit in Cache.NamespaceLimits, averaged over Cache.NamespaceLimitsInterval",
+	}, []string{"namespace"})
+
+	PelicanCacheNamespaceWithdrawn = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_cache_namespace_withdrawn",
+		Help: "Whether a namespace is left out of the cache's advertisement for exceeding its bandwidth limit (1) or not (0)",
+	}, []string{"namespace"})
+
+	PelicanCacheNamespaceEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_cache_namespace_evictions_total",
+		Help: "The total number of objects evicted from the cache for exceeding their namespace's disk limit",
+	}, []string{"namespace"})
+
+	PelicanCacheNamespaceEvictedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_cache_namespace_evicted_bytes_total",
+		Help: "The total disk space freed by evicting objects for exceeding their namespace's disk limit",
+	}, []string{"namespace"})
+
+	PelicanCachePartitionIOErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_cache_partition_io_errors_total",
+		Help: "The total number of failed IO probes of each partition in Cache.DataLocations",
+	}, []string{"partition"})
+
+	PelicanCachePartitionOffline = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_cache_partition_offline",
+		Help: "Whether a partition in Cache.DataLocations was taken out of service for disk failures (1) or not (0)",
+	}, []string{"partition"})
+
+	PelicanLocalCacheFetchesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pelican_local_cache_fetches_total",
+		Help: "The total number of objects the local cache started fetching from the federation on a miss",
+	})
+
+	PelicanLocalCacheCoalescedRequestsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pelican_local_cache_coalesced_requests_total",
+		Help: "The total number of requests missing the local cache that joined an ongoing fetch of the same object instead of starting another",
+	})
+
+	PelicanLocalCacheActiveFetches = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pelican_local_cache_active_fetches",
+		Help: "The number of objects the local cache is fetching from the federation",
+	})
+)
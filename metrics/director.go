@@ -82,11 +82,21 @@ var (
 		Help: "The total stat queries the director issues. The status can be Succeeded, Cancelled, Timeout, Forbidden, or UnknownErr",
 	}, []string{"server_name", "server_url", "server_type", "result", "cached_result"}) // result: see enums for DirectorStatResult
 
+	PelicanDirectorFilteredServers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_director_filtered_servers",
+		Help: "The number of servers the director filters or deprioritizes, by the type of filter",
+	}, []string{"filter_type"}) // filter_type: permFiltered, tempFiltered, tempAllowed, topologyFiltered, topologyUnscheduledFiltered
+
 	PelicanDirectorServerCount = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "pelican_director_server_count",
 		Help: "The number of servers currently recognized by the Director, delineated by pelican/non-pelican and origin/cache",
 	}, []string{"server_name", "server_type", "from_topology"})
 
+	PelicanDirectorServerAdExpirationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_server_ad_expirations_total",
+		Help: "The total number of server advertisements that expired from the director's cache because the server stopped advertising",
+	}, []string{"server_name", "server_type", "from_topology"})
+
 	PelicanDirectorClientVersionTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "pelican_director_client_version_total",
 		Help: "The total number of requests from client versions.",
@@ -97,8 +107,108 @@ var (
 		Help: "The total number of redirections the director issued.",
 	}, []string{"destination", "status_code", "version", "network"})
 
+	PelicanDirectorNamespaceRedirectsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_namespace_redirects_total",
+		Help: "The total number of redirects the director issued, by namespace and the server the client was redirected to",
+	}, []string{"namespace", "server_name", "server_type"})
+
 	PelicanDirectorGeoIPErrors = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "pelican_director_geoip_errors",
 		Help: "The total number of errors encountered trying to resolve coordinates using the GeoIP MaxMind database",
 	}, []string{"network", "source", "proj"})
+
+	PelicanDirectorGeoIPDBAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pelican_director_geoip_db_age_seconds",
+		Help: "The age of the GeoIP MaxMind database in use, based on its build date",
+	})
+
+	PelicanDirectorGeoIPDBLastRefresh = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pelican_director_geoip_db_last_refresh_timestamp_seconds",
+		Help: "The Unix timestamp of the last successful download of the GeoIP MaxMind database",
+	})
+
+	PelicanDirectorGeoIPDBRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_geoip_db_refreshes_total",
+		Help: "The total number of attempts to download the GeoIP MaxMind database, by status: Succeeded|Failed",
+	}, []string{"status"})
+
+	PelicanDirectorNamespaceReplicaTarget = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_director_namespace_replica_target",
+		Help: "The number of cache replicas a namespace requests in a cache region",
+	}, []string{"namespace", "region"})
+
+	PelicanDirectorNamespaceReplicas = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_director_namespace_replicas",
+		Help: "The number of caches placed to hold replicas of a namespace in a cache region, up to the namespace's target",
+	}, []string{"namespace", "region"})
+
+	PelicanDirectorNamespaceSLOEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_namespace_slo_events_total",
+		Help: "The total number of events counted toward a namespace SLO, by objective (redirect_availability|transfer_success) and outcome (good|bad)",
+	}, []string{"namespace", "objective", "outcome"})
+
+	PelicanDirectorNamespaceSLOTarget = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_director_namespace_slo_target",
+		Help: "The target ratio of good events of a namespace SLO",
+	}, []string{"namespace", "objective"})
+
+	PelicanDirectorNamespaceSLI = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_director_namespace_sli",
+		Help: "The measured ratio of good events of a namespace SLO over its rolling window",
+	}, []string{"namespace", "objective"})
+
+	PelicanDirectorNamespaceErrorBudgetRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_director_namespace_error_budget_remaining",
+		Help: "The fraction of a namespace SLO's error budget left over its rolling window; negative once overspent",
+	}, []string{"namespace", "objective"})
+
+	PelicanDirectorTopologyFetches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_topology_fetches_total",
+		Help: "The total number of attempts to fetch data from topology, by resource (namespaces|downtime) and status: Succeeded|Failed",
+	}, []string{"resource", "status"})
+
+	PelicanDirectorTopologyFetchDuration = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_director_topology_fetch_duration_seconds",
+		Help: "How long the last attempt to fetch data from topology took, by resource (namespaces|downtime)",
+	}, []string{"resource"})
+
+	PelicanDirectorTopologyLastSync = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_director_topology_last_sync_timestamp_seconds",
+		Help: "The Unix timestamp of the last successful fetch of data from topology, by resource (namespaces|downtime)",
+	}, []string{"resource"})
+
+	PelicanDirectorTopologyIssues = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_director_topology_issues",
+		Help: "The number of issues found with the servers listed in topology, by kind",
+	}, []string{"kind"})
+
+	PelicanDirectorDeniedRedirectsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_denied_redirects_total",
+		Help: "The number of redirects refused by an incident response deny rule",
+	}, []string{"rule_id"})
+
+	PelicanDirectorRateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_rate_limited_total",
+		Help: "The number of requests refused for exceeding the director's rate limits, by limit: ip|token",
+	}, []string{"limit"})
+
+	PelicanDirectorNamespaceAliasRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_namespace_alias_requests_total",
+		Help: "The number of requests for objects under a deprecated namespace alias, by alias",
+	}, []string{"alias"})
+
+	PelicanDirectorSortExperimentRedirectsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_sort_experiment_redirects_total",
+		Help: "The number of redirects sorted by each arm of the sort experiment, by arm: control|treatment",
+	}, []string{"experiment", "arm", "sort_method"})
+
+	PelicanDirectorSortExperimentTransfersTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_sort_experiment_transfers_total",
+		Help: "The number of transfers redirected by each arm of the sort experiment, by the result inferred from client retries: Succeeded|Failed",
+	}, []string{"experiment", "arm", "sort_method", "result"})
+
+	PelicanDirectorDNSQueriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_dns_queries_total",
+		Help: "The number of queries the director's DNS responder answered, by response code: NOERROR|NXDOMAIN|REFUSED|SERVFAIL|FORMERR",
+	}, []string{"rcode"})
 )
@@ -74,7 +74,12 @@ const (
 	OriginCache_Director      HealthStatusComponent = "director"   // File transfer tests with director
 	OriginCache_Registry      HealthStatusComponent = "registry"   // Register namespace at the registry
 	DirectorRegistry_Topology HealthStatusComponent = "topology"   // Fetch data from OSDF topology
+	Cache_Disks               HealthStatusComponent = "disks"      // IO health of the cache's data partitions
+	Origin_Database           HealthStatusComponent = "origin-database"
+	Director_Database         HealthStatusComponent = "director-database"
+	Registry_Database         HealthStatusComponent = "registry-database"
 	Server_WebUI              HealthStatusComponent = "web-ui"
+	Server_WebEngine          HealthStatusComponent = "web-engine" // The HTTP server of the web UI and APIs
 )
 
 var (
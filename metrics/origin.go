@@ -0,0 +1,51 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	PelicanOriginUploadScans = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_origin_upload_scans_total",
+		Help: "The total number of uploaded objects scanned by the origin, by the outcome of the scan",
+	}, []string{"result"})
+
+	PelicanOriginUploadScansPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pelican_origin_upload_scans_pending",
+		Help: "The number of uploaded objects withheld from the namespace while waiting for or undergoing a scan",
+	})
+
+	PelicanOriginStagedUploads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_origin_staged_uploads_total",
+		Help: "The total number of uploads staged through the origin's web API, by outcome: staged|published|mismatch|abandoned|expired",
+	}, []string{"result"})
+
+	PelicanOriginExportScanObjects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_origin_export_scan_objects_total",
+		Help: "The total number of objects checked by the origin's export scan, by result: unchanged|scanned|failed",
+	}, []string{"result"})
+
+	PelicanOriginExportScanReadBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pelican_origin_export_scan_read_bytes_total",
+		Help: "The total number of bytes the origin's export scan read to compute checksums",
+	})
+)
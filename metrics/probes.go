@@ -0,0 +1,256 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// The probes orchestrators such as Kubernetes use to manage the server
+	ProbeType string
+
+	// How strict a probe is about the health of the components it checks
+	ProbeStrictness string
+
+	ProbeComponentStatus struct {
+		Status string `json:"status"`
+		Pass   bool   `json:"pass"`
+	}
+
+	// The result of a probe, for orchestrators and systemd
+	ProbeStatus struct {
+		Probe      ProbeType       `json:"probe"`
+		Pass       bool            `json:"pass"`
+		Strictness ProbeStrictness `json:"strictness,omitempty"`
+		// Whether all the enabled modules have been launched
+		Started bool `json:"started"`
+		// The status of the components the probe checks, keyed by the module depending on them
+		Modules map[string]map[HealthStatusComponent]ProbeComponentStatus `json:"modules"`
+	}
+)
+
+const (
+	// Passes once the server has started; orchestrators hold off the other probes until then
+	ProbeStartup ProbeType = "startup"
+	// Fails when the server is broken beyond recovery and should be restarted
+	ProbeLiveness ProbeType = "liveness"
+	// Fails while the server can't take traffic
+	ProbeReadiness ProbeType = "readiness"
+
+	ProbeStrict   ProbeStrictness = "strict"
+	ProbeTolerant ProbeStrictness = "tolerant"
+
+	// The module of the components common to all servers, such as the web engine
+	ProbeModuleServer = "server"
+)
+
+var (
+	// Components checked by the probes, keyed by module.  The value is whether the
+	// liveness probe checks the component too; the readiness probe checks them all.
+	probeComponents      = map[string]map[HealthStatusComponent]bool{}
+	probeComponentsMutex sync.RWMutex
+
+	serverStarted atomic.Bool
+)
+
+func addProbeComponents(module string, liveness bool, components ...HealthStatusComponent) {
+	probeComponentsMutex.Lock()
+	defer probeComponentsMutex.Unlock()
+	module = strings.ToLower(module)
+	if probeComponents[module] == nil {
+		probeComponents[module] = map[HealthStatusComponent]bool{}
+	}
+	for _, component := range components {
+		probeComponents[module][component] = probeComponents[module][component] || liveness
+	}
+}
+
+// Require the components of the module to be healthy for the server to be ready.  Modules
+// add the components they depend on when they're launched.
+func AddReadinessComponents(module string, components ...HealthStatusComponent) {
+	addProbeComponents(module, false, components...)
+}
+
+// Require the components of the module to be healthy for the server to be both ready and
+// alive.  Only add components whose failure a restart of the server could fix; e.g. losing
+// the director must not restart every origin and cache of the federation.
+func AddLivenessComponents(module string, components ...HealthStatusComponent) {
+	addProbeComponents(module, true, components...)
+}
+
+// Record that all the enabled modules have been launched
+func SetServerStarted(started bool) {
+	serverStarted.Store(started)
+}
+
+// Get the strictness of the probe from Server.LivenessProbeStrictness or
+// Server.ReadinessProbeStrictness
+func GetProbeStrictness(probe ProbeType) (ProbeStrictness, error) {
+	var strictness string
+	var name string
+	switch probe {
+	case ProbeLiveness:
+		strictness, name = param.Server_LivenessProbeStrictness.GetString(), param.Server_LivenessProbeStrictness.GetName()
+	case ProbeReadiness:
+		strictness, name = param.Server_ReadinessProbeStrictness.GetString(), param.Server_ReadinessProbeStrictness.GetName()
+	default:
+		return "", nil
+	}
+	switch ProbeStrictness(strings.ToLower(strictness)) {
+	case ProbeStrict:
+		return ProbeStrict, nil
+	case ProbeTolerant:
+		return ProbeTolerant, nil
+	}
+	return "", errors.Errorf("invalid value %q for %s; must be %q or %q", strictness, name, ProbeStrict, ProbeTolerant)
+}
+
+// Check Server.LivenessProbeStrictness and Server.ReadinessProbeStrictness
+func ValidateProbeStrictness() error {
+	for _, probe := range []ProbeType{ProbeLiveness, ProbeReadiness} {
+		if _, err := GetProbeStrictness(probe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getComponentStatusEnum(component HealthStatusComponent) HealthStatusEnum {
+	value, ok := healthStatus.Load(component)
+	if !ok {
+		return StatusUnknown
+	}
+	status, ok := value.(componentStatusInternal)
+	if !ok {
+		return StatusUnknown
+	}
+	return status.Status
+}
+
+// Whether a component with the status passes the probe
+func componentPasses(probe ProbeType, strictness ProbeStrictness, status HealthStatusEnum) bool {
+	switch probe {
+	case ProbeStartup:
+		// Each component must have reported its status and not be broken
+		return status == StatusOK || status == StatusWarning
+	case ProbeLiveness:
+		// A component that hasn't reported its status yet may still be starting
+		if status == StatusWarning {
+			return strictness != ProbeStrict
+		}
+		return status != StatusCritical
+	default:
+		if status == StatusWarning {
+			return strictness == ProbeTolerant
+		}
+		return status == StatusOK
+	}
+}
+
+// Run a probe against the current health of the components.
+//
+//   - The startup probe passes once the server has started and the components checked by
+//     the liveness probe have reported a status that isn't critical.
+//   - The liveness probe fails when a component it checks is critical or, if it's strict,
+//     reports a warning.
+//   - The readiness probe passes once the server has started and each component is ok or,
+//     if it's tolerant, reports a warning.  A component that hasn't reported its status yet
+//     makes the server unready.
+func GetProbe(probe ProbeType) ProbeStatus {
+	strictness, err := GetProbeStrictness(probe)
+	if err != nil {
+		// Invalid values are rejected at startup; fall back on the defaults in tests
+		strictness = ProbeStrict
+		if probe == ProbeLiveness {
+			strictness = ProbeTolerant
+		}
+	}
+	status := ProbeStatus{
+		Probe:      probe,
+		Strictness: strictness,
+		Started:    serverStarted.Load(),
+		Modules:    map[string]map[HealthStatusComponent]ProbeComponentStatus{},
+	}
+	status.Pass = status.Started || probe == ProbeLiveness
+
+	probeComponentsMutex.RLock()
+	defer probeComponentsMutex.RUnlock()
+	for module, components := range probeComponents {
+		for component, liveness := range components {
+			if probe != ProbeReadiness && !liveness {
+				continue
+			}
+			componentStatus := getComponentStatusEnum(component)
+			pass := componentPasses(probe, strictness, componentStatus)
+			if status.Modules[module] == nil {
+				status.Modules[module] = map[HealthStatusComponent]ProbeComponentStatus{}
+			}
+			status.Modules[module][component] = ProbeComponentStatus{Status: componentStatus.String(), Pass: pass}
+			status.Pass = status.Pass && pass
+		}
+	}
+	return status
+}
+
+// A one-line description of what the probe is waiting for
+func (status ProbeStatus) Summary() string {
+	if status.Pass {
+		switch status.Probe {
+		case ProbeStartup:
+			return "Started"
+		case ProbeLiveness:
+			return "Alive"
+		default:
+			return "Ready"
+		}
+	}
+	pending := []string{}
+	if !status.Started && status.Probe != ProbeLiveness {
+		pending = append(pending, "startup")
+	}
+	failing := map[string]bool{}
+	for _, components := range status.Modules {
+		for component, componentStatus := range components {
+			if !componentStatus.Pass {
+				failing[component.String()+" ("+componentStatus.Status+")"] = true
+			}
+		}
+	}
+	for component := range failing {
+		pending = append(pending, component)
+	}
+	sort.Strings(pending)
+	return "Waiting for " + strings.Join(pending, ", ")
+}
+
+// Forget the components checked by the probes; for tests
+func ResetProbes() {
+	probeComponentsMutex.Lock()
+	defer probeComponentsMutex.Unlock()
+	probeComponents = map[string]map[HealthStatusComponent]bool{}
+	serverStarted.Store(false)
+}
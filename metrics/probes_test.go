@@ -0,0 +1,109 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetProbe(t *testing.T) {
+	ResetProbes()
+	t.Cleanup(ResetProbes)
+	t.Cleanup(func() {
+		DeleteComponentHealthStatus(OriginCache_XRootD)
+		DeleteComponentHealthStatus(OriginCache_Federation)
+		DeleteComponentHealthStatus(Server_WebEngine)
+		viper.Reset()
+	})
+	viper.Set("Server.LivenessProbeStrictness", "tolerant")
+	viper.Set("Server.ReadinessProbeStrictness", "strict")
+
+	// A server without requirements is ready once it's started
+	assert.False(t, GetProbe(ProbeReadiness).Pass)
+	assert.False(t, GetProbe(ProbeStartup).Pass)
+	assert.True(t, GetProbe(ProbeLiveness).Pass)
+	assert.Equal(t, "Waiting for startup", GetProbe(ProbeReadiness).Summary())
+	SetServerStarted(true)
+	assert.True(t, GetProbe(ProbeReadiness).Pass)
+	assert.Equal(t, "Ready", GetProbe(ProbeReadiness).Summary())
+
+	AddLivenessComponents(ProbeModuleServer, Server_WebEngine)
+	AddLivenessComponents("Origin", OriginCache_XRootD)
+	AddReadinessComponents("Origin", OriginCache_XRootD, OriginCache_Federation)
+	SetComponentHealthStatus(OriginCache_XRootD, StatusWarning, "XRootD is initializing")
+
+	readiness := GetProbe(ProbeReadiness)
+	assert.False(t, readiness.Pass)
+	assert.Equal(t, ProbeStrict, readiness.Strictness)
+	assert.Equal(t, map[string]map[HealthStatusComponent]ProbeComponentStatus{
+		"server": {Server_WebEngine: {Status: "unknown"}},
+		"origin": {
+			OriginCache_XRootD:     {Status: "warning"},
+			OriginCache_Federation: {Status: "unknown"},
+		},
+	}, readiness.Modules)
+	assert.Equal(t, "Waiting for federation (unknown), web-engine (unknown), xrootd (warning)", readiness.Summary())
+
+	// The liveness and startup probes don't check the advertisement to the director
+	liveness := GetProbe(ProbeLiveness)
+	assert.True(t, liveness.Pass)
+	assert.NotContains(t, liveness.Modules["origin"], OriginCache_Federation)
+	assert.False(t, GetProbe(ProbeStartup).Pass)
+
+	SetComponentHealthStatus(Server_WebEngine, StatusOK, "")
+	assert.True(t, GetProbe(ProbeStartup).Pass)
+	SetComponentHealthStatus(OriginCache_XRootD, StatusOK, "")
+	SetComponentHealthStatus(OriginCache_Federation, StatusOK, "")
+	assert.True(t, GetProbe(ProbeReadiness).Pass)
+
+	// Losing the director makes the server unready, but not dead
+	SetComponentHealthStatus(OriginCache_Federation, StatusCritical, "XRootD server failed to advertise to the director")
+	assert.False(t, GetProbe(ProbeReadiness).Pass)
+	assert.True(t, GetProbe(ProbeLiveness).Pass)
+
+	// A tolerant readiness probe accepts warnings; a strict liveness probe doesn't
+	SetComponentHealthStatus(OriginCache_Federation, StatusOK, "")
+	SetComponentHealthStatus(OriginCache_XRootD, StatusWarning, "XRootD is draining for a restart")
+	assert.False(t, GetProbe(ProbeReadiness).Pass)
+	viper.Set("Server.ReadinessProbeStrictness", "tolerant")
+	assert.True(t, GetProbe(ProbeReadiness).Pass)
+	assert.True(t, GetProbe(ProbeLiveness).Pass)
+	viper.Set("Server.LivenessProbeStrictness", "strict")
+	assert.False(t, GetProbe(ProbeLiveness).Pass)
+
+	SetComponentHealthStatus(OriginCache_XRootD, StatusCritical, "XRootD exited")
+	viper.Set("Server.LivenessProbeStrictness", "tolerant")
+	assert.False(t, GetProbe(ProbeLiveness).Pass)
+}
+
+func TestValidateProbeStrictness(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set("Server.LivenessProbeStrictness", "Tolerant")
+	viper.Set("Server.ReadinessProbeStrictness", "strict")
+	require.NoError(t, ValidateProbeStrictness())
+
+	viper.Set("Server.ReadinessProbeStrictness", "lenient")
+	err := ValidateProbeStrictness()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Server.ReadinessProbeStrictness")
+}
@@ -0,0 +1,274 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+// The name of the tracer of Pelican's spans
+const tracerName = "github.com/pelicanplatform/pelican"
+
+// Set up the export of OpenTelemetry spans according to Tracing.Exporter.  Until this is
+// called, or if tracing is disabled, spans are no-ops, but the W3C trace context of
+// incoming requests is still passed on.
+func InitTracing(ctx context.Context, egrp *errgroup.Group) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporterName := strings.ToLower(param.Tracing_Exporter.GetString())
+	switch exporterName {
+	case "", "none":
+		return nil
+	case "otlp":
+	default:
+		return errors.Errorf("invalid value %q for %s; must be \"none\" or \"otlp\"", param.Tracing_Exporter.GetString(), param.Tracing_Exporter.GetName())
+	}
+
+	percentage := param.Tracing_SamplingPercentage.GetInt()
+	if percentage < 0 || percentage > 100 {
+		return errors.Errorf("invalid value %d for %s; must be between 0 and 100", percentage, param.Tracing_SamplingPercentage.GetName())
+	}
+
+	options := []otlptracehttp.Option{}
+	if endpoint := param.Tracing_Endpoint.GetString(); endpoint != "" {
+		options = append(options, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	if tlsConfig := config.GetTransport().TLSClientConfig; tlsConfig != nil {
+		options = append(options, otlptracehttp.WithTLSClientConfig(tlsConfig.Clone()))
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the OTLP trace exporter")
+	}
+
+	// Name the service after the modules of the server, e.g. pelican-origin
+	serviceName := "pelican"
+	if servers := config.GetEnabledServerString(true); len(servers) > 0 {
+		serviceName += "-" + strings.Join(servers, "-")
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(config.GetVersion()),
+	))
+	if err != nil {
+		return errors.Wrap(err, "failed to describe the traced service")
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Follow the client's sampling decision for requests that are part of its trace
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(float64(percentage)/100))),
+	)
+	otel.SetTracerProvider(provider)
+	log.Infof("Exporting the traces of %s over OTLP", serviceName)
+
+	egrp.Go(func() error {
+		<-ctx.Done()
+		// Flush the pending spans
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(shutdownCtx); err != nil {
+			log.Warningln("Failed to flush the traces on shutdown:", err)
+		}
+		return nil
+	})
+	return nil
+}
+
+// Start a span as part of the trace in the context
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Record the error in the span, if any, and end it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Start a server span for an incoming request, continuing the trace of the client if the
+// request carries the W3C trace context.  The returned request carries the span.
+func StartRequestSpan(req *http.Request, name string) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLPath(req.URL.Path),
+			semconv.UserAgentOriginal(req.UserAgent()),
+		),
+	)
+	return req.WithContext(ctx), span
+}
+
+// Record the response to the request of a span started by StartRequestSpan or
+// StartClientSpan and end it
+func EndRequestSpan(span trace.Span, status int) {
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// Start a client span for an outgoing request and add its W3C trace context to the headers
+// of the request, so that the server continues the trace
+func StartClientSpan(ctx context.Context, name string, req *http.Request) trace.Span {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.Redacted()),
+		),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return span
+}
+
+type (
+	// Records the status and size of a response for the span of its request
+	tracedResponseWriter struct {
+		http.ResponseWriter
+		status int
+		size   int64
+	}
+
+	// Traces the requests of a transport in client spans
+	tracingTransport struct {
+		name string
+		base http.RoundTripper
+	}
+
+	// Traces the streaming of a response body in a span ending when the body is closed
+	tracedBody struct {
+		io.ReadCloser
+		span  trace.Span
+		size  int64
+		err   error
+		ended bool
+	}
+)
+
+func (w *tracedResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *tracedResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Let http.ResponseController reach the flushing and deadline methods of the connection
+func (w *tracedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Trace each request to the handler in a server span, recording the status and size of
+// the response.  The span continues the trace of the client, if any.
+func TraceHandler(name string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, span := StartRequestSpan(r, name)
+		tw := &tracedResponseWriter{ResponseWriter: w}
+		defer func() {
+			status := tw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttributes(semconv.HTTPResponseBodySize(int(tw.size)))
+			EndRequestSpan(span, status)
+		}()
+		handler.ServeHTTP(tw, r)
+	})
+}
+
+// Wrap a transport such that each request is traced in a client span passing the trace
+// context on to the server.  The span ends once the response headers arrive, measuring the
+// latency of the backend; trace the streaming of the body with TraceBody.
+func NewTracingTransport(name string, base http.RoundTripper) http.RoundTripper {
+	return &tracingTransport{name: name, base: base}
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A transport must not modify the request it's given
+	req = req.Clone(req.Context())
+	span := StartClientSpan(req.Context(), t.name, req)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		EndSpan(span, err)
+		return nil, err
+	}
+	EndRequestSpan(span, resp.StatusCode)
+	return resp, nil
+}
+
+// Trace the streaming of a response body in a span that ends when the body is closed,
+// recording the bytes read and the error interrupting the stream, if any
+func TraceBody(ctx context.Context, name string, body io.ReadCloser) io.ReadCloser {
+	_, span := StartSpan(ctx, name)
+	return &tracedBody{ReadCloser: body, span: span}
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	if !b.ended {
+		b.ended = true
+		b.span.SetAttributes(attribute.Int64("pelican.bytes", b.size))
+		EndSpan(b.span, b.err)
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestInitTracingValidation(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	egrp := &errgroup.Group{}
+
+	viper.Set("Tracing.Exporter", "none")
+	require.NoError(t, InitTracing(context.Background(), egrp))
+
+	viper.Set("Tracing.Exporter", "jaeger")
+	err := InitTracing(context.Background(), egrp)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Tracing.Exporter")
+
+	viper.Set("Tracing.Exporter", "otlp")
+	viper.Set("Tracing.SamplingPercentage", 101)
+	err = InitTracing(context.Background(), egrp)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Tracing.SamplingPercentage")
+}
+
+// Record the spans of the test in memory
+func setupSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Cleanup(viper.Reset)
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	oldProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(oldProvider) })
+	viper.Set("Tracing.Exporter", "none")
+	require.NoError(t, InitTracing(context.Background(), &errgroup.Group{}))
+	return recorder
+}
+
+func TestTracePropagation(t *testing.T) {
+	recorder := setupSpanRecorder(t)
+
+	// The client's trace continues at the server...
+	clientTraceId := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/api/v1.0/director/object/foo/bar", nil)
+	req.Header.Set("traceparent", "00-"+clientTraceId+"-00f067aa0ba902b7-01")
+	req, requestSpan := StartRequestSpan(req, "director.redirectToCache")
+	assert.Equal(t, clientTraceId, requestSpan.SpanContext().TraceID().String())
+
+	// ...through the steps of the request...
+	ctx, stepSpan := StartSpan(req.Context(), "director.queryServersForObject")
+
+	// ...and on to the servers it queries
+	outgoing := httptest.NewRequest(http.MethodHead, "https://origin.example.org/foo/bar", nil)
+	clientSpan := StartClientSpan(ctx, "director.sendHeadReq", outgoing)
+	traceparent := outgoing.Header.Get("traceparent")
+	assert.Equal(t, "00-"+clientTraceId+"-"+clientSpan.SpanContext().SpanID().String()+"-01", traceparent)
+
+	EndRequestSpan(clientSpan, http.StatusNotFound)
+	EndSpan(stepSpan, nil)
+	EndRequestSpan(requestSpan, http.StatusTemporaryRedirect)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "director.sendHeadReq", spans[0].Name())
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+	assert.Equal(t, stepSpan.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, requestSpan.SpanContext().SpanID(), spans[1].Parent().SpanID())
+	assert.Equal(t, "director.redirectToCache", spans[2].Name())
+	assert.Equal(t, trace.SpanKindServer, spans[2].SpanKind())
+	assert.True(t, spans[2].Parent().IsRemote())
+	for _, span := range spans {
+		assert.Equal(t, clientTraceId, span.SpanContext().TraceID().String())
+	}
+}
+
+func TestTraceObjectRequest(t *testing.T) {
+	recorder := setupSpanRecorder(t)
+
+	// A backend that sees the trace context of the request
+	var backendTraceparent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendTraceparent = r.Header.Get("traceparent")
+		_, _ = io.WriteString(w, "Hello, world!")
+	}))
+	t.Cleanup(backend.Close)
+	client := &http.Client{Transport: NewTracingTransport("test.backend", http.DefaultTransport)}
+
+	handler := TraceHandler("test.object", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, backend.URL+"/foo", nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		assert.Empty(t, req.Header.Get("traceparent"), "the transport must not modify the request")
+		body := TraceBody(r.Context(), "test.stream", resp.Body)
+		defer body.Close()
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = io.Copy(w, body)
+	}))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "Hello, world!", rec.Body.String())
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	}
+	require.Len(t, spans, 3)
+	server := spans["test.object"]
+	require.NotNil(t, server)
+	assert.Contains(t, server.Attributes(), attribute.Int("http.response.status_code", http.StatusPartialContent))
+	assert.Contains(t, server.Attributes(), attribute.Int("http.response.body.size", 13))
+
+	backendSpan := spans["test.backend"]
+	require.NotNil(t, backendSpan)
+	assert.Equal(t, server.SpanContext().SpanID(), backendSpan.Parent().SpanID())
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+backendSpan.SpanContext().SpanID().String()+"-01", backendTraceparent)
+
+	stream := spans["test.stream"]
+	require.NotNil(t, stream)
+	assert.Contains(t, stream.Attributes(), attribute.Int64("pelican.bytes", 13))
+	assert.Equal(t, codes.Unset, stream.Status().Code)
+}
@@ -31,6 +31,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/jellydator/ttlcache/v3"
@@ -231,8 +232,21 @@ type (
 		Wq   int `xml:"wq"`
 	}
 
+	// The requests handled by the xrootd protocol, by kind
+	SummaryXrootdOps struct {
+		Open    int `xml:"open"`
+		Read    int `xml:"rd"`
+		Preread int `xml:"pr"`
+		Readv   int `xml:"rv"`
+		Write   int `xml:"wr"`
+		Writev  int `xml:"wv"`
+		Sync    int `xml:"sync"`
+		Misc    int `xml:"misc"`
+	}
+
 	SummaryStat struct {
 		Id      SummaryStatType    `xml:"id,attr"`
+		Num     int                `xml:"num"` // Current connections for Link Summary Data
 		Total   int                `xml:"tot"`
 		In      int                `xml:"in"`
 		Out     int                `xml:"out"`
@@ -241,6 +255,9 @@ type (
 		Paths   SummaryPath        `xml:"paths"` // For Oss Summary Data
 		Store   SummaryCacheStore  `xml:"store"`
 		Memory  SummaryCacheMemory `xml:"mem"`
+		Ops     SummaryXrootdOps   `xml:"ops"` // For Xrootd Summary Data
+		Err     int                `xml:"err"` // Requests that ended in an error, for Xrootd Summary Data
+		Dly     int                `xml:"dly"` // Requests the client was told to retry later, for Xrootd Summary Data
 	}
 
 	SummaryStatistics struct {
@@ -267,10 +284,11 @@ const (
 
 // Summary data types
 const (
-	LinkStat  SummaryStatType = "link"  // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653739
-	SchedStat SummaryStatType = "sched" // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653745
-	OssStat   SummaryStatType = "oss"   // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653741
-	CacheStat SummaryStatType = "cache" // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653733
+	LinkStat  SummaryStatType = "link"   // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653739
+	SchedStat SummaryStatType = "sched"  // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653745
+	OssStat   SummaryStatType = "oss"    // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653741
+	CacheStat SummaryStatType = "cache"  // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653733
+	ProtStat  SummaryStatType = "xrootd" // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm (see "Protocol xrootd")
 )
 
 var (
@@ -294,6 +312,11 @@ var (
 		Help: "Bytes of transfers",
 	}, []string{"path", "ap", "dn", "role", "org", "proj", "type", "network"})
 
+	TransferCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_transfer_count",
+		Help: "Number of files closed after a transfer",
+	}, []string{"path", "ap", "dn", "role", "org", "proj", "status", "network"}) // status: complete/forced
+
 	Threads = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "xrootd_sched_thread_count",
 		Help: "Number of scheduler threads",
@@ -304,6 +327,26 @@ var (
 		Help: "Aggregate number of server connections",
 	})
 
+	ActiveConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "xrootd_server_connections_active",
+		Help: "Number of current server connections",
+	})
+
+	ServerRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_server_requests_total",
+		Help: "Number of requests handled by the server",
+	}, []string{"type"}) // type: open/read/preread/readv/write/writev/sync/misc
+
+	ServerRequestErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "xrootd_server_request_errors_total",
+		Help: "Number of requests that ended in an error",
+	})
+
+	ServerRequestDelays = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "xrootd_server_request_delays_total",
+		Help: "Number of requests the server told the client to retry later",
+	})
+
 	BytesXfer = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "xrootd_server_bytes",
 		Help: "Number of bytes read into the server",
@@ -336,8 +379,10 @@ var (
 
 	lastStats SummaryStat
 
-	lastTotalIO  int     // The last total IO value
-	lastWaitTime float64 // The last IO wait time
+	lastTotalIO      int          // The last total IO value
+	lastWaitTime     float64      // The last IO wait time
+	lastActiveIO     atomic.Int64 // The last number of ongoing storage operations
+	haveLastActiveIO atomic.Bool  // Whether the throttle plugin has reported lastActiveIO
 
 	// Maps the connection identifier with a user record
 	sessions = ttlcache.New[UserId, UserRecord](ttlcache.WithTTL[UserId, UserRecord](24 * time.Hour))
@@ -584,6 +629,12 @@ func NullTermToString(nullTermBytes []byte) (str string) {
 	return string(nullTermBytes[0:idx])
 }
 
+// The number of ongoing storage operations last reported by XRootD; ok is false
+// if it hasn't been reported (e.g., the throttle plugin isn't enabled)
+func GetActiveIO() (activeIO int64, ok bool) {
+	return lastActiveIO.Load(), haveLastActiveIO.Load()
+}
+
 func HandlePacket(packet []byte) error {
 	// XML '<' character indicates a summary packet
 	if len(packet) > 0 && packet[0] == '<' {
@@ -616,9 +667,17 @@ func HandlePacket(packet []byte) error {
 			return errors.Wrapf(err, "Failed to parse XRootD monitoring packet")
 		}
 		path := computePrefix(rest, monitorPaths)
-		if useridItem := userids.Get(xrdUserId); useridItem != nil {
+		useridItem := userids.Get(xrdUserId)
+		if useridItem != nil {
 			transfers.Set(fileid, FileRecord{UserId: useridItem.Value(), Path: path}, ttlcache.DefaultTTL)
 		}
+		if fileTracingEnabled() {
+			event := FileTraceEvent{Type: FileTraceOpen, FileId: dictid, Path: rest}
+			if useridItem != nil {
+				event.User = traceUser(useridItem.Value())
+			}
+			emitFileTrace(event)
+		}
 	case 'f':
 		log.Debug("HandlePacket: Received a f-stream packet")
 		// sizeof(XrdXrootdMonHeader) + sizeof(XrdXrootdMonFileTOD)
@@ -643,6 +702,27 @@ func HandlePacket(packet []byte) error {
 				fileId := FileId{Id: fileHdr.FileId}
 				xferRecord := transfers.Get(fileId)
 				transfers.Delete(fileId)
+				if fileTracingEnabled() {
+					event := FileTraceEvent{
+						Type:       FileTraceClose,
+						FileId:     fileHdr.FileId,
+						ReadBytes:  binary.BigEndian.Uint64(packet[offset+8 : offset+16]),
+						ReadvBytes: binary.BigEndian.Uint64(packet[offset+16 : offset+24]),
+						WriteBytes: binary.BigEndian.Uint64(packet[offset+24 : offset+32]),
+					}
+					if fileHdr.RecFlag&0x02 == 0x02 { // XrdXrootdMonFileHdr::hasOPS
+						opsOffset := offset + 8 + 24
+						event.ReadOps = binary.BigEndian.Uint32(packet[opsOffset : opsOffset+4])
+						event.ReadvOps = binary.BigEndian.Uint32(packet[opsOffset+4 : opsOffset+8])
+						event.WriteOps = binary.BigEndian.Uint32(packet[opsOffset+8 : opsOffset+12])
+						event.ReadMin = int32(binary.BigEndian.Uint32(packet[opsOffset+24 : opsOffset+28]))
+						event.ReadMax = int32(binary.BigEndian.Uint32(packet[opsOffset+28 : opsOffset+32]))
+					}
+					if xferRecord != nil {
+						event.User = traceUser(xferRecord.Value().UserId)
+					}
+					emitFileTrace(event)
+				}
 				labels := prometheus.Labels{
 					"path":    "/",
 					"ap":      "",
@@ -707,6 +787,19 @@ func HandlePacket(packet []byte) error {
 						packet[offset+opsOffset+8:offset+opsOffset+12]) -
 						oldWriteOps)))
 				}
+				// Files closed because the client went away without closing them
+				// mark transfers that didn't finish
+				countLabels := prometheus.Labels{"status": "complete"}
+				for key, value := range labels {
+					if key != "type" {
+						countLabels[key] = value
+					}
+				}
+				if fileHdr.RecFlag&0x01 == 0x01 { // XrdXrootdMonFileHdr::forced
+					countLabels["status"] = "forced"
+				}
+				TransferCount.With(countLabels).Inc()
+
 				xfrOffset := uint32(8) // sizeof(XrdXrootdMonFileHdr)
 				labels["type"] = "read"
 				counter := TransferBytes.With(labels)
@@ -727,10 +820,11 @@ func HandlePacket(packet []byte) error {
 				log.Debug("MonPacket: Received a f-stream file-open packet")
 				fileid := FileId{Id: fileHdr.FileId}
 				path := ""
+				lfn := ""
 				userId := UserId{}
 				if fileHdr.RecFlag&0x01 == 0x01 { // hasLFN
 					lfnSize := uint32(fileHdr.RecSize - 20)
-					lfn := NullTermToString(packet[offset+20 : offset+lfnSize+20])
+					lfn = NullTermToString(packet[offset+20 : offset+lfnSize+20])
 					// path has been difined
 					path = computePrefix(lfn, monitorPaths)
 					log.Debugf("MonPacket: User LFN %v matches prefix %v",
@@ -740,6 +834,15 @@ func HandlePacket(packet []byte) error {
 				}
 				transfers.Set(fileid, FileRecord{UserId: userId, Path: path},
 					ttlcache.DefaultTTL)
+				if fileTracingEnabled() {
+					emitFileTrace(FileTraceEvent{
+						Type:     FileTraceOpen,
+						FileId:   fileHdr.FileId,
+						Path:     lfn,
+						FileSize: int64(binary.BigEndian.Uint64(packet[offset+8 : offset+16])),
+						User:     traceUser(userId),
+					})
+				}
 			case isTime: // XrdXrootdMonFileHdr::isTime
 				log.Debug("MonPacket: Received a f-stream time packet")
 			case isXfr: // XrdXrootdMonFileHdr::isXfr
@@ -814,6 +917,19 @@ func HandlePacket(packet []byte) error {
 				record.ReadvBytes = readvBytes
 				record.WriteBytes = writeBytes
 				transfers.Set(fileid, record, ttlcache.DefaultTTL)
+				if fileTracingEnabled() {
+					event := FileTraceEvent{
+						Type:       FileTraceTransfer,
+						FileId:     fileHdr.FileId,
+						ReadBytes:  readBytes,
+						ReadvBytes: readvBytes,
+						WriteBytes: writeBytes,
+					}
+					if item != nil {
+						event.User = traceUser(record.UserId)
+					}
+					emitFileTrace(event)
+				}
 
 			case isDisc: // XrdXrootdMonFileHdr::isDisc
 				log.Debug("MonPacket: Received a f-stream disconnect packet")
@@ -894,6 +1010,8 @@ func HandlePacket(packet []byte) error {
 
 				ServerTotalIO.Add(float64(totalIOInc))
 				ServerActiveIO.Set(float64(throttleGS.IOActive))
+				lastActiveIO.Store(int64(throttleGS.IOActive))
+				haveLastActiveIO.Store(true)
 				ServerIOWaitTime.Add(waitTimeInc)
 			}
 		}
@@ -1095,6 +1213,15 @@ func HandlePacket(packet []byte) error {
 </statistics>
 */
 
+// The increase of a summary count since the last summary; the counts restart
+// from zero when the service does
+func summaryIncrease(current, last int) float64 {
+	if current < last {
+		return float64(current)
+	}
+	return float64(current - last)
+}
+
 func HandleSummaryPacket(packet []byte) error {
 	summaryStats := SummaryStatistics{}
 	// The cache summary data has a typo where the <hit> tag contains a trailing bracet
@@ -1147,6 +1274,31 @@ func HandleSummaryPacket(packet []byte) error {
 			}
 			BytesXfer.With(prometheus.Labels{"direction": "tx"}).Add(incBy)
 			lastStats.Out = stat.Out
+
+			ActiveConnections.Set(float64(stat.Num))
+		case ProtStat:
+			// When stats tag has id="xrootd", the counts are since start-up of the service
+			ops := []struct {
+				name        string
+				count, last *int
+			}{
+				{"open", &stat.Ops.Open, &lastStats.Ops.Open},
+				{"read", &stat.Ops.Read, &lastStats.Ops.Read},
+				{"preread", &stat.Ops.Preread, &lastStats.Ops.Preread},
+				{"readv", &stat.Ops.Readv, &lastStats.Ops.Readv},
+				{"write", &stat.Ops.Write, &lastStats.Ops.Write},
+				{"writev", &stat.Ops.Writev, &lastStats.Ops.Writev},
+				{"sync", &stat.Ops.Sync, &lastStats.Ops.Sync},
+				{"misc", &stat.Ops.Misc, &lastStats.Ops.Misc},
+			}
+			for _, op := range ops {
+				ServerRequests.With(prometheus.Labels{"type": op.name}).Add(summaryIncrease(*op.count, *op.last))
+				*op.last = *op.count
+			}
+			ServerRequestErrors.Add(summaryIncrease(stat.Err, lastStats.Err))
+			lastStats.Err = stat.Err
+			ServerRequestDelays.Add(summaryIncrease(stat.Dly, lastStats.Dly))
+			lastStats.Dly = stat.Dly
 		case SchedStat:
 			Threads.With(prometheus.Labels{"state": "idle"}).Set(float64(stat.Idle))
 			Threads.With(prometheus.Labels{"state": "running"}).Set(float64(stat.Threads -
@@ -400,6 +400,43 @@ func TestHandlePacket(t *testing.T) {
 		}
 	})
 
+	t.Run("record-correct-requests-from-summary-packet", func(t *testing.T) {
+		mockProtSummary := func(ops SummaryXrootdOps, errs, delays int) []byte {
+			summary := SummaryStatistics{
+				Version: "0.0",
+				Program: "xrootd",
+				Stats: []SummaryStat{
+					{Id: "link", Num: 4},
+					{Id: "xrootd", Ops: ops, Err: errs, Dly: delays},
+				},
+			}
+			summaryBytes, err := xml.Marshal(summary)
+			require.NoError(t, err, "Error Marshal Summary packet")
+			return summaryBytes
+		}
+
+		ServerRequests.Reset()
+		lastStats.Ops = SummaryXrootdOps{}
+		lastStats.Err = 0
+		lastStats.Dly = 0
+
+		require.NoError(t, HandlePacket(mockProtSummary(SummaryXrootdOps{Open: 5, Read: 20}, 1, 0)))
+		require.NoError(t, HandlePacket(mockProtSummary(SummaryXrootdOps{Open: 8, Read: 50, Write: 2}, 3, 1)))
+
+		assert.Equal(t, 4.0, testutil.ToFloat64(ActiveConnections))
+		assert.Equal(t, 8.0, testutil.ToFloat64(ServerRequests.WithLabelValues("open")))
+		assert.Equal(t, 50.0, testutil.ToFloat64(ServerRequests.WithLabelValues("read")))
+		assert.Equal(t, 2.0, testutil.ToFloat64(ServerRequests.WithLabelValues("write")))
+		errorsBefore := testutil.ToFloat64(ServerRequestErrors)
+		delaysBefore := testutil.ToFloat64(ServerRequestDelays)
+
+		// After a restart of the service, the counts start over
+		require.NoError(t, HandlePacket(mockProtSummary(SummaryXrootdOps{Open: 1}, 1, 0)))
+		assert.Equal(t, 9.0, testutil.ToFloat64(ServerRequests.WithLabelValues("open")))
+		assert.Equal(t, errorsBefore+1, testutil.ToFloat64(ServerRequestErrors))
+		assert.Equal(t, delaysBefore, testutil.ToFloat64(ServerRequestDelays))
+	})
+
 	t.Run("auth-packet-u-should-register-correct-info", func(t *testing.T) {
 		mockUserRecord := UserRecord{
 			AuthenticationProtocol: "https",
@@ -651,6 +688,7 @@ func TestHandlePacket(t *testing.T) {
 		TransferReadvSegs.Reset()
 		TransferOps.Reset()
 		TransferBytes.Reset()
+		TransferCount.Reset()
 
 		openPacket, err := mockFileOpenPacket(0, mockFileID, mockUserID, mockSID, "/full/path/to/file.txt")
 		require.NoError(t, err, "Error generating mock file open packet")
@@ -718,6 +756,50 @@ func TestHandlePacket(t *testing.T) {
 		if err := testutil.CollectAndCompare(TransferBytes, expectedTransferBytesReader, "xrootd_transfer_bytes"); err != nil {
 			require.NoError(t, err, "Collected metric is different from expected")
 		}
+
+		expectedTransferCount := `
+		# HELP xrootd_transfer_count Number of files closed after a transfer
+		# TYPE xrootd_transfer_count counter
+		xrootd_transfer_count{ap="",dn="",network="",org="",path="/",proj="",role="",status="complete"} 1
+		`
+		if err := testutil.CollectAndCompare(TransferCount, strings.NewReader(expectedTransferCount), "xrootd_transfer_count"); err != nil {
+			require.NoError(t, err, "Collected metric is different from expected")
+		}
+	})
+
+	t.Run("f-stream-events-are-delivered-to-trace-hook", func(t *testing.T) {
+		var events []FileTraceEvent
+		SetFileTraceHook("test", func(event FileTraceEvent) {
+			events = append(events, event)
+		})
+		t.Cleanup(func() { SetFileTraceHook("test", nil) })
+
+		openPacket, err := mockFileOpenPacket(0, mockFileID, mockUserID, mockSID, "/full/path/to/file.txt")
+		require.NoError(t, err, "Error generating mock file open packet")
+		xftPacket, err := mockFileXfrPacket(1, mockFileID, mockSID, mockRead, mockReadV, mockWrite)
+		require.NoError(t, err, "Error generating mock file transfer packet")
+		clsPacket, err := mockFileClosePacket(2, mockFileID, mockSID, mockStatOps(120, 10, 30, 1000), mockRead, mockReadV, mockWrite)
+		require.NoError(t, err, "Error generating mock file close packet")
+
+		transfers.DeleteAll()
+		sessions.DeleteAll()
+		require.NoError(t, HandlePacket(openPacket))
+		require.NoError(t, HandlePacket(xftPacket))
+		require.NoError(t, HandlePacket(clsPacket))
+
+		require.Len(t, events, 3)
+		assert.Equal(t, FileTraceOpen, events[0].Type)
+		assert.Equal(t, mockFileID, events[0].FileId)
+		// The trace gets the full path rather than the aggregated prefix
+		assert.Equal(t, "/full/path/to/file.txt", events[0].Path)
+		assert.Equal(t, int64(10000), events[0].FileSize)
+		assert.Equal(t, FileTraceTransfer, events[1].Type)
+		assert.Equal(t, uint64(mockRead), events[1].ReadBytes)
+		assert.Equal(t, FileTraceClose, events[2].Type)
+		assert.Equal(t, uint64(mockReadV), events[2].ReadvBytes)
+		assert.Equal(t, uint64(mockWrite), events[2].WriteBytes)
+		assert.Equal(t, uint32(120), events[2].ReadOps)
+		assert.Equal(t, uint32(30), events[2].WriteOps)
 	})
 
 	// The token packet should update the user's session.
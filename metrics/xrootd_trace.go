@@ -0,0 +1,107 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	FileTraceEventType string
+
+	// A per-file event decoded from the XRootD detailed monitoring stream,
+	// delivered to the file trace hooks before the path is aggregated
+	FileTraceEvent struct {
+		Type       FileTraceEventType `json:"type"`
+		Time       time.Time          `json:"time"`
+		FileId     uint32             `json:"fileId"`
+		Path       string             `json:"path,omitempty"`
+		FileSize   int64              `json:"fileSize,omitempty"`
+		User       *UserRecord        `json:"user,omitempty"`
+		ReadBytes  uint64             `json:"readBytes"`
+		ReadvBytes uint64             `json:"readvBytes"`
+		WriteBytes uint64             `json:"writeBytes"`
+		ReadOps    uint32             `json:"readOps"`
+		ReadvOps   uint32             `json:"readvOps"`
+		WriteOps   uint32             `json:"writeOps"`
+		ReadMin    int32              `json:"readMin,omitempty"`
+		ReadMax    int32              `json:"readMax,omitempty"`
+	}
+)
+
+const (
+	FileTraceOpen     FileTraceEventType = "open"
+	FileTraceTransfer FileTraceEventType = "transfer"
+	FileTraceClose    FileTraceEventType = "close"
+)
+
+var (
+	fileTraceHooks     atomic.Pointer[map[string]func(FileTraceEvent)]
+	fileTraceHookMutex sync.Mutex
+)
+
+// Register a function, identified by name, to receive every per-file
+// monitoring event; pass a nil hook to remove it.  Hooks are called
+// synchronously from the packet handler and must not block.
+func SetFileTraceHook(name string, hook func(FileTraceEvent)) {
+	fileTraceHookMutex.Lock()
+	defer fileTraceHookMutex.Unlock()
+	hooks := make(map[string]func(FileTraceEvent))
+	if current := fileTraceHooks.Load(); current != nil {
+		for key, value := range *current {
+			hooks[key] = value
+		}
+	}
+	if hook == nil {
+		delete(hooks, name)
+	} else {
+		hooks[name] = hook
+	}
+	if len(hooks) == 0 {
+		fileTraceHooks.Store(nil)
+		return
+	}
+	fileTraceHooks.Store(&hooks)
+}
+
+func fileTracingEnabled() bool {
+	return fileTraceHooks.Load() != nil
+}
+
+func emitFileTrace(event FileTraceEvent) {
+	hooks := fileTraceHooks.Load()
+	if hooks == nil {
+		return
+	}
+	event.Time = time.Now()
+	for _, hook := range *hooks {
+		hook(event)
+	}
+}
+
+// Look up the login record of a user for a trace event
+func traceUser(userId UserId) *UserRecord {
+	if item := sessions.Get(userId); item != nil {
+		record := item.Value()
+		return &record
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package oa4mp
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+type (
+	// A rule in the Issuer.GroupScopeMappingFile, granting the members of a group
+	// access to a set of path prefixes
+	groupScopeRule struct {
+		Group  string   `yaml:"group"`
+		Read   []string `yaml:"read"`
+		Create []string `yaml:"create"`
+		Write  []string `yaml:"write"`
+	}
+
+	// The storage scopes issued to the members of a group
+	groupScopeMapping struct {
+		Group  string
+		Scopes []string
+	}
+)
+
+// Read the group-to-scope rules from a file, merging the rules for the same group
+func loadGroupScopeMappings(filename string) ([]groupScopeMapping, error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the group scope mapping file")
+	}
+	rules := []groupScopeRule{}
+	if err := yaml.Unmarshal(contents, &rules); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the group scope mapping file %s", filename)
+	}
+	mappings, err := groupScopeRulesToMappings(rules)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid group scope mapping file %s", filename)
+	}
+	return mappings, nil
+}
+
+func groupScopeRulesToMappings(rules []groupScopeRule) ([]groupScopeMapping, error) {
+	mappings := []groupScopeMapping{}
+	mappingIdx := map[string]int{}
+	for idx, rule := range rules {
+		// The group and scopes are written into the issuer's QDL policy as quoted strings
+		if rule.Group == "" {
+			return nil, errors.Errorf("rule %d has no group", idx+1)
+		}
+		if strings.ContainsAny(rule.Group, "'\\") {
+			return nil, errors.Errorf("the group %q of rule %d may not contain quotes or backslashes", rule.Group, idx+1)
+		}
+		scopes := []string{}
+		for _, access := range []struct {
+			scope    token_scopes.TokenScope
+			prefixes []string
+		}{
+			{token_scopes.Storage_Read, rule.Read},
+			{token_scopes.Storage_Create, rule.Create},
+			{token_scopes.Storage_Modify, rule.Write},
+		} {
+			for _, prefix := range access.prefixes {
+				if !strings.HasPrefix(prefix, "/") {
+					return nil, errors.Errorf("the prefix %q for group %s must be an absolute path", prefix, rule.Group)
+				}
+				if strings.ContainsAny(prefix, " \t\n'\\") {
+					return nil, errors.Errorf("the prefix %q for group %s may not contain whitespace, quotes, or backslashes", prefix, rule.Group)
+				}
+				scopes = append(scopes, access.scope.String()+":"+path.Clean(prefix))
+			}
+		}
+		if len(scopes) == 0 {
+			return nil, errors.Errorf("the rule for group %s grants no read, create, or write prefixes", rule.Group)
+		}
+
+		if existing, ok := mappingIdx[rule.Group]; ok {
+			mappings[existing].Scopes = append(mappings[existing].Scopes, scopes...)
+		} else {
+			mappingIdx[rule.Group] = len(mappings)
+			mappings = append(mappings, groupScopeMapping{Group: rule.Group, Scopes: scopes})
+		}
+	}
+	return mappings, nil
+}
@@ -0,0 +1,71 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package oa4mp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadGroupScopeMappings(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), "group-scopes.yaml")
+	require.NoError(t, os.WriteFile(rulesFile, []byte(`
+- group: cms-users
+  read: ["/cms/store"]
+- group: cms-production
+  read: ["/cms/store/"]
+  write: ["/cms/store/mc", "/cms/store/data"]
+- group: cms-users
+  create: ["/cms/store/user"]
+`), 0644))
+
+	mappings, err := loadGroupScopeMappings(rulesFile)
+	require.NoError(t, err)
+	assert.Equal(t, []groupScopeMapping{
+		{Group: "cms-users", Scopes: []string{"storage.read:/cms/store", "storage.create:/cms/store/user"}},
+		{Group: "cms-production", Scopes: []string{"storage.read:/cms/store", "storage.modify:/cms/store/mc", "storage.modify:/cms/store/data"}},
+	}, mappings)
+
+	t.Run("policy", func(t *testing.T) {
+		policy := strings.Builder{}
+		templ := template.Must(template.New("policies.qdl").Parse(policiesQdlTmpl))
+		require.NoError(t, templ.Execute(&policy, oa4mpConfig{GroupScopeMappings: mappings}))
+		assert.Contains(t, policy.String(), "if [has_value('cms-production', group_list.)] then")
+		assert.Contains(t, policy.String(), "scopes := scopes \\/ {'storage.read:/cms/store', 'storage.create:/cms/store/user'};")
+	})
+
+	t.Run("invalid-rules", func(t *testing.T) {
+		for name, rules := range map[string][]groupScopeRule{
+			"no-group":        {{Read: []string{"/foo"}}},
+			"quoted-group":    {{Group: "o'brien", Read: []string{"/foo"}}},
+			"relative-prefix": {{Group: "foo", Read: []string{"foo"}}},
+			"spaced-prefix":   {{Group: "foo", Write: []string{"/foo bar"}}},
+			"no-prefixes":     {{Group: "foo"}},
+		} {
+			_, err := groupScopeRulesToMappings(rules)
+			assert.Error(t, err, name)
+		}
+	})
+}
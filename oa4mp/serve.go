@@ -60,6 +60,7 @@ type (
 		GroupRequirements       []string
 		GroupAuthzTemplates     []authzTemplate
 		UserAuthzTemplates      []authzTemplate
+		GroupScopeMappings      []groupScopeMapping
 	}
 
 	oidcAuthenticationRequirements struct {
@@ -230,11 +231,28 @@ func ConfigureOA4MP() (launcher daemon.Launcher, err error) {
 		}
 	}
 
+	groupScopeMappings := []groupScopeMapping{}
+	if mappingFile := param.Issuer_GroupScopeMappingFile.GetString(); mappingFile != "" {
+		if groupSource == "none" || groupSource == "" {
+			err = errors.New("Issuer.GroupSource must be set to use Issuer.GroupScopeMappingFile")
+			return
+		}
+		if groupScopeMappings, err = loadGroupScopeMappings(mappingFile); err != nil {
+			return
+		}
+		log.Debugf("Loaded scope mappings for %d groups from %s", len(groupScopeMappings), mappingFile)
+	}
+
 	key, err := config.GetIssuerPrivateJWK()
 	if err != nil {
 		err = errors.Wrap(err, "Failed to load the private issuer key for running issuer")
 		return
 	}
+	// OA4MP signs its tokens itself, reading the private key from disk
+	if config.IsExternalJWK(key) {
+		err = errors.Errorf("the OA4MP issuer needs the private issuer key on disk; it can't use the key in an external key management service at %s", param.IssuerKeyURI.GetName())
+		return
+	}
 	if err = key.Set("use", "sig"); err != nil {
 		err = errors.Wrap(err, "Failed to configure private issuer key")
 		return
@@ -274,6 +292,7 @@ func ConfigureOA4MP() (launcher daemon.Launcher, err error) {
 		GroupRequirements:       groupReqs,
 		GroupAuthzTemplates:     groupAuthzTemplates,
 		UserAuthzTemplates:      userAuthzTemplates,
+		GroupScopeMappings:      groupScopeMappings,
 	}
 
 	varQdlScitokensPath := filepath.Join(param.Issuer_ScitokensServerLocation.GetString(), "var",
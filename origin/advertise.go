@@ -22,6 +22,7 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -58,7 +59,7 @@ func (server *OriginServer) GetPids() (pids []int) {
 	return
 }
 
-func (server *OriginServer) CreateAdvertisement(name, originUrlStr, originWebUrl string) (*server_structs.OriginAdvertiseV2, error) {
+func (server *OriginServer) CreateAdvertisement(name, originUrlStr, originWebUrl string) (*server_structs.OriginAdvertiseV3, error) {
 	isGlobusBackend := param.Origin_StorageType.GetString() == string(server_structs.OriginStorageGlobus)
 	// Here we instantiate the namespaceAd slice, but we still need to define the namespace
 	issuerUrlStr, err := config.GetServerIssuerURL()
@@ -89,6 +90,9 @@ func (server *OriginServer) CreateAdvertisement(name, originUrlStr, originWebUrl
 		return nil, err
 	}
 
+	// Embargoed exports are public only during their embargo windows
+	now := time.Now()
+	publicReads := param.Origin_EnablePublicReads.GetBool()
 	for _, export := range originExports {
 		if isGlobusBackend {
 			// Do not include the export if it's an inactive Globus collection
@@ -97,15 +101,20 @@ func (server *OriginServer) CreateAdvertisement(name, originUrlStr, originWebUrl
 				continue
 			}
 		}
+		exportCaps := export.CapabilitiesAt(now)
+		if export.Embargo != nil && exportCaps.PublicReads {
+			publicReads = true
+		}
 		// PublicReads implies reads
-		reads := export.Capabilities.PublicReads || export.Capabilities.Reads
+		reads := exportCaps.PublicReads || exportCaps.Reads
 		nsAds = append(nsAds, server_structs.NamespaceAdV2{
 			Caps: server_structs.Capabilities{
-				PublicReads: export.Capabilities.PublicReads,
+				PublicReads: exportCaps.PublicReads,
 				Reads:       reads,
-				Writes:      export.Capabilities.Writes,
-				Listings:    export.Capabilities.Listings,
-				DirectReads: export.Capabilities.DirectReads,
+				Writes:      exportCaps.Writes,
+				Listings:    exportCaps.Listings,
+				DirectReads: exportCaps.DirectReads,
+				Extensions:  exportCaps.Extensions,
 			},
 			Path: export.FederationPrefix,
 			Generation: []server_structs.TokenGen{{
@@ -117,29 +126,34 @@ func (server *OriginServer) CreateAdvertisement(name, originUrlStr, originWebUrl
 				BasePaths: []string{export.FederationPrefix},
 				IssuerUrl: *issuerUrl,
 			}},
+			ReplicaTargets: export.ReplicaTargets,
 		})
 		prefixes = append(prefixes, export.FederationPrefix)
 	}
 
 	// PublicReads implies reads
-	reads := param.Origin_EnableReads.GetBool() || param.Origin_EnablePublicReads.GetBool()
+	reads := param.Origin_EnableReads.GetBool() || publicReads
 	extUrlStr := param.Server_ExternalWebUrl.GetString()
 	extUrl, _ := url.Parse(extUrlStr)
 	// Only use hostname:port
 	registryPrefix := server_structs.GetOriginNs(extUrl.Host)
+	caps := server_structs.Capabilities{
+		PublicReads: publicReads,
+		Reads:       reads,
+		Writes:      param.Origin_EnableWrites.GetBool(),
+		DirectReads: param.Origin_EnableDirectReads.GetBool(),
+		Listings:    param.Origin_EnableListings.GetBool(),
+	}
+	if err := server_utils.SetExperimentalCapabilities(&caps); err != nil {
+		return nil, err
+	}
 	ad := server_structs.OriginAdvertiseV2{
 		Name:           name,
 		RegistryPrefix: registryPrefix,
 		DataURL:        originUrlStr,
 		WebURL:         originWebUrl,
 		Namespaces:     nsAds,
-		Caps: server_structs.Capabilities{
-			PublicReads: param.Origin_EnablePublicReads.GetBool(),
-			Reads:       reads,
-			Writes:      param.Origin_EnableWrites.GetBool(),
-			DirectReads: param.Origin_EnableDirectReads.GetBool(),
-			Listings:    param.Origin_EnableListings.GetBool(),
-		},
+		Caps:           caps,
 		Issuer: []server_structs.TokenIssuer{{
 			BasePaths: prefixes,
 			IssuerUrl: *issuerUrl,
@@ -178,7 +192,13 @@ func (server *OriginServer) CreateAdvertisement(name, originUrlStr, originWebUrl
 	} else {
 		log.Warningf("Multiple prefixes are not yet supported with the broker. Skipping broker configuration")
 	}
-	return &ad, nil
+	return &server_structs.OriginAdvertiseV3{
+		OriginAdvertiseV2: ad,
+		// The checksums enabled by xrootd.chksum in xrootd-origin.cfg
+		ChecksumAlgorithms: []string{"md5", "adler32", "crc32"},
+		ChunkedUploads:     activeUploadStaging() != nil,
+		ArchiveMembers:     archiveMembersEnabled.Load(),
+	}, nil
 }
 
 // Return a list of paths where the origin's issuer is authoritative.
@@ -191,8 +211,10 @@ func (server *OriginServer) GetAuthorizedPrefixes() ([]string, error) {
 		return nil, err
 	}
 
+	now := time.Now()
 	for _, export := range originExports {
-		if (export.Capabilities.Reads && !export.Capabilities.PublicReads) || export.Capabilities.Writes {
+		caps := export.CapabilitiesAt(now)
+		if (caps.Reads && !caps.PublicReads) || caps.Writes {
 			prefixes = append(prefixes, export.FederationPrefix)
 		}
 	}
@@ -0,0 +1,406 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+type (
+	archiveFormat string
+
+	// A file inside an archive
+	ArchiveMember struct {
+		Name    string    `json:"name"`
+		Size    int64     `json:"size"`
+		ModTime time.Time `json:"modTime"`
+	}
+
+	// The bytes of an archive member.  Members stored without compression can be read at
+	// any offset, letting clients request ranges of them.
+	archiveMemberContent struct {
+		ArchiveMember
+		reader   io.Reader
+		seeker   io.ReadSeeker
+		closeFns []func() error
+	}
+)
+
+const (
+	archiveZip   archiveFormat = "zip"
+	archiveTar   archiveFormat = "tar"
+	archiveTarGz archiveFormat = "tar.gz"
+)
+
+var (
+	archiveMembersEnabled atomic.Bool
+
+	errArchiveNotFound       = errors.New("archive not found")
+	errArchiveMemberNotFound = errors.New("archive member not found")
+	errArchiveUnsupported    = errors.New("not a zip or tar archive")
+)
+
+// Check that archive member extraction can be enabled for the origin's storage
+func ConfigArchiveMembers() error {
+	if !param.Origin_EnableArchiveMembers.GetBool() {
+		archiveMembersEnabled.Store(false)
+		return nil
+	}
+	if param.Origin_StorageType.GetString() != string(server_structs.OriginStoragePosix) {
+		return errors.Errorf("Origin.EnableArchiveMembers is only supported for the %s storage type", server_structs.OriginStoragePosix)
+	}
+	if param.Origin_Multiuser.GetBool() {
+		return errors.New("Origin.EnableArchiveMembers is not supported with Origin.Multiuser")
+	}
+	archiveMembersEnabled.Store(true)
+	log.Infoln("Archive member extraction enabled; members of zip and tar archives are served through the web API")
+	return nil
+}
+
+// Normalize the name of an archive member, e.g., "./images/1.png" to "images/1.png"
+func normalizeMemberName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// Detect the format of an archive from its first bytes
+func detectArchiveFormat(file io.ReaderAt) (archiveFormat, error) {
+	header := make([]byte, 512)
+	n, err := file.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	header = header[:n]
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")) || bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return archiveZip, nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return archiveTarGz, nil
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return archiveTar, nil
+	}
+	return "", errArchiveUnsupported
+}
+
+// Read the members of the archive, calling visit on each regular file until it returns
+// true.  For tar archives, the reader is positioned at the start of the member when
+// visit is called.
+func walkTarMembers(reader io.Reader, visit func(hdr *tar.Header, tr *tar.Reader) (bool, error)) error {
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(errArchiveUnsupported, err.Error())
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeGNUSparse {
+			continue
+		}
+		if done, err := visit(hdr, tr); err != nil || done {
+			return err
+		}
+	}
+}
+
+// Whether the bytes of the tar member are stored as a sparse map rather than contiguously
+func isSparseTarMember(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// List the regular files of the archive
+func listArchiveMembers(file *os.File, size int64, format archiveFormat) ([]ArchiveMember, error) {
+	members := []ArchiveMember{}
+	switch format {
+	case archiveZip:
+		zr, err := zip.NewReader(file, size)
+		if err != nil {
+			return nil, errors.Wrap(errArchiveUnsupported, err.Error())
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			members = append(members, ArchiveMember{Name: normalizeMemberName(f.Name), Size: int64(f.UncompressedSize64), ModTime: f.Modified})
+		}
+		return members, nil
+	case archiveTarGz:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, errors.Wrap(errArchiveUnsupported, err.Error())
+		}
+		defer gz.Close()
+		return members, walkTarMembers(gz, func(hdr *tar.Header, _ *tar.Reader) (bool, error) {
+			members = append(members, ArchiveMember{Name: normalizeMemberName(hdr.Name), Size: hdr.Size, ModTime: hdr.ModTime})
+			return false, nil
+		})
+	default:
+		return members, walkTarMembers(file, func(hdr *tar.Header, _ *tar.Reader) (bool, error) {
+			members = append(members, ArchiveMember{Name: normalizeMemberName(hdr.Name), Size: hdr.Size, ModTime: hdr.ModTime})
+			return false, nil
+		})
+	}
+}
+
+// Find a member of the archive.  The members of zip archives are located through the
+// central directory at the end of the archive; tar archives are scanned from the start,
+// seeking over the other members unless the archive is compressed.
+func openArchiveMember(file *os.File, size int64, format archiveFormat, name string) (*archiveMemberContent, error) {
+	name = normalizeMemberName(name)
+	switch format {
+	case archiveZip:
+		zr, err := zip.NewReader(file, size)
+		if err != nil {
+			return nil, errors.Wrap(errArchiveUnsupported, err.Error())
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() || normalizeMemberName(f.Name) != name {
+				continue
+			}
+			content := &archiveMemberContent{ArchiveMember: ArchiveMember{Name: name, Size: int64(f.UncompressedSize64), ModTime: f.Modified}}
+			if f.Method == zip.Store {
+				offset, err := f.DataOffset()
+				if err != nil {
+					return nil, errors.Wrap(errArchiveUnsupported, err.Error())
+				}
+				content.seeker = io.NewSectionReader(file, offset, int64(f.CompressedSize64))
+				return content, nil
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, errors.Wrap(errArchiveUnsupported, err.Error())
+			}
+			content.reader = rc
+			content.closeFns = append(content.closeFns, rc.Close)
+			return content, nil
+		}
+	case archiveTarGz:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, errors.Wrap(errArchiveUnsupported, err.Error())
+		}
+		var content *archiveMemberContent
+		err = walkTarMembers(gz, func(hdr *tar.Header, tr *tar.Reader) (bool, error) {
+			if normalizeMemberName(hdr.Name) != name {
+				return false, nil
+			}
+			content = &archiveMemberContent{ArchiveMember: ArchiveMember{Name: name, Size: hdr.Size, ModTime: hdr.ModTime}, reader: tr}
+			content.closeFns = append(content.closeFns, gz.Close)
+			return true, nil
+		})
+		if content == nil {
+			gz.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if content != nil {
+			return content, nil
+		}
+	default:
+		var content *archiveMemberContent
+		err := walkTarMembers(file, func(hdr *tar.Header, tr *tar.Reader) (bool, error) {
+			if normalizeMemberName(hdr.Name) != name {
+				return false, nil
+			}
+			content = &archiveMemberContent{ArchiveMember: ArchiveMember{Name: name, Size: hdr.Size, ModTime: hdr.ModTime}, reader: tr}
+			// The tar reader doesn't buffer, so the member starts at the current offset
+			// of the file unless it's sparse
+			if !isSparseTarMember(hdr) {
+				offset, err := file.Seek(0, io.SeekCurrent)
+				if err != nil {
+					return false, err
+				}
+				content.seeker = io.NewSectionReader(file, offset, hdr.Size)
+			}
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if content != nil {
+			return content, nil
+		}
+	}
+	return nil, errors.Wrapf(errArchiveMemberNotFound, "%s is not a member of the archive", name)
+}
+
+func (content *archiveMemberContent) Close() error {
+	var result error
+	for _, closeFn := range content.closeFns {
+		if err := closeFn(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// Find the readable export of the object, checking that the request may read it
+func authorizeArchiveRead(ctx *gin.Context, objectPath string) error {
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		prefix := path.Clean(export.FederationPrefix)
+		if objectPath != prefix && !strings.HasPrefix(objectPath, prefix+"/") {
+			continue
+		}
+		caps := export.CapabilitiesAt(time.Now())
+		if caps.PublicReads {
+			return nil
+		}
+		if !caps.Reads {
+			continue
+		}
+		tok, err := verifyOriginToken(ctx)
+		if err != nil {
+			return err
+		}
+		if exportScopeAllows(tok, objectPath, prefix, []token_scopes.TokenScope{token_scopes.Storage_Read}) {
+			return nil
+		}
+		return errors.Wrapf(errStagedUploadForbidden, "the token does not allow reading %s", objectPath)
+	}
+	return errors.Wrapf(errArchiveNotFound, "%s is not in a readable export of this origin", objectPath)
+}
+
+func abortArchiveMemberError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errArchiveNotFound), errors.Is(err, errArchiveMemberNotFound):
+		ctx.AbortWithStatusJSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+	case errors.Is(err, errArchiveUnsupported):
+		ctx.AbortWithStatusJSON(http.StatusUnsupportedMediaType, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+	default:
+		abortStagedUploadError(ctx, err)
+	}
+}
+
+// Serve a member of an archive, given by the member query parameter, or list the members
+// of the archive if it's not given
+func handleArchiveMember(ctx *gin.Context) {
+	if !archiveMembersEnabled.Load() {
+		ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Archive member extraction is not enabled",
+		})
+		return
+	}
+	objectPath := path.Clean("/" + ctx.Param("path"))
+	if err := authorizeArchiveRead(ctx, objectPath); err != nil {
+		abortArchiveMemberError(ctx, err)
+		return
+	}
+
+	file, err := os.Open(objectStoragePath(objectPath))
+	if errors.Is(err, os.ErrNotExist) {
+		abortArchiveMemberError(ctx, errors.Wrapf(errArchiveNotFound, "%s does not exist", objectPath))
+		return
+	} else if err != nil {
+		abortArchiveMemberError(ctx, err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		abortArchiveMemberError(ctx, err)
+		return
+	}
+	if info.IsDir() {
+		abortArchiveMemberError(ctx, errors.Wrapf(errArchiveUnsupported, "%s is a directory", objectPath))
+		return
+	}
+	format, err := detectArchiveFormat(file)
+	if err != nil {
+		abortArchiveMemberError(ctx, errors.Wrap(err, objectPath))
+		return
+	}
+
+	member := ctx.Query("member")
+	if member == "" {
+		members, err := listArchiveMembers(file, info.Size(), format)
+		if err != nil {
+			abortArchiveMemberError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, members)
+		return
+	}
+
+	content, err := openArchiveMember(file, info.Size(), format, member)
+	if err != nil {
+		abortArchiveMemberError(ctx, err)
+		return
+	}
+	defer content.Close()
+	if content.seeker != nil {
+		http.ServeContent(ctx.Writer, ctx.Request, path.Base(content.Name), content.ModTime, content.seeker)
+		return
+	}
+	contentType := mime.TypeByExtension(path.Ext(content.Name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Length", strconv.FormatInt(content.Size, 10))
+	if !content.ModTime.IsZero() {
+		ctx.Header("Last-Modified", content.ModTime.UTC().Format(http.TimeFormat))
+	}
+	ctx.Status(http.StatusOK)
+	if ctx.Request.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(ctx.Writer, content.reader); err != nil {
+		log.Warningf("Failed to serve member %s of archive %s: %v", content.Name, objectPath, err)
+	}
+}
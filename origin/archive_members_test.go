@@ -0,0 +1,188 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func writeTestTar(t *testing.T, members map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, name := range []string{"README", "images/1.png", "images/2.png"} {
+		contents, ok := members[name]
+		if !ok {
+			continue
+		}
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0644, Size: int64(len(contents)), ModTime: time.Unix(1700000000, 0), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func TestArchiveMembers(t *testing.T) {
+	_, storageDir, newToken := setupUploadStaging(t)
+	viper.Set("Origin.EnableReads", true)
+	archiveMembersEnabled.Store(true)
+	t.Cleanup(func() { archiveMembersEnabled.Store(false) })
+	router := gin.New()
+	router.GET("/archive/*path", handleArchiveMember)
+	router.HEAD("/archive/*path", handleArchiveMember)
+	readToken := newToken("storage.read:/")
+	do := func(method, target, tok string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if tok != "" {
+			req.Header.Set("Authorization", "Bearer "+tok)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	members := map[string]string{
+		"README":       "an archive for testing",
+		"images/1.png": "the first image",
+		"images/2.png": "the second image, which is a bit longer",
+	}
+
+	// Zip archives with both stored and compressed members
+	zipBuf := &bytes.Buffer{}
+	zw := zip.NewWriter(zipBuf)
+	for name, method := range map[string]uint16{"README": zip.Deflate, "images/1.png": zip.Store, "images/2.png": zip.Deflate} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: time.Unix(1700000000, 0)})
+		require.NoError(t, err)
+		_, err = w.Write([]byte(members[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(storageDir, "data.zip"), zipBuf.Bytes(), 0644))
+
+	tarBytes := writeTestTar(t, members)
+	require.NoError(t, os.WriteFile(filepath.Join(storageDir, "data.tar"), tarBytes, 0644))
+	gzBuf := &bytes.Buffer{}
+	gw := gzip.NewWriter(gzBuf)
+	_, err := gw.Write(tarBytes)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(storageDir, "data.tar.gz"), gzBuf.Bytes(), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(storageDir, "notes.txt"), []byte("not an archive"), 0644))
+
+	t.Run("unauthorized", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/archive/test/data.zip?member=README", "", nil).Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/archive/test/data.zip?member=README", newToken("storage.read:/other"), nil).Code)
+	})
+
+	for _, archive := range []string{"data.zip", "data.tar", "data.tar.gz"} {
+		t.Run(archive, func(t *testing.T) {
+			for name, contents := range members {
+				w := do(http.MethodGet, "/archive/test/"+archive+"?member="+name, readToken, nil)
+				require.Equal(t, http.StatusOK, w.Code, name)
+				assert.Equal(t, contents, w.Body.String())
+			}
+			// Names are normalized
+			w := do(http.MethodGet, "/archive/test/"+archive+"?member=/images/../README", readToken, nil)
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, members["README"], w.Body.String())
+
+			w = do(http.MethodGet, "/archive/test/"+archive, readToken, nil)
+			require.Equal(t, http.StatusOK, w.Code)
+			listing := []ArchiveMember{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listing))
+			assert.Len(t, listing, 3)
+			for _, member := range listing {
+				assert.Equal(t, int64(len(members[member.Name])), member.Size, member.Name)
+			}
+
+			assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/archive/test/"+archive+"?member=images/3.png", readToken, nil).Code)
+		})
+	}
+
+	t.Run("ranges", func(t *testing.T) {
+		// Members stored without compression can be read in part
+		for _, archive := range []string{"data.zip", "data.tar"} {
+			w := do(http.MethodGet, "/archive/test/"+archive+"?member=images/1.png", readToken, map[string]string{"Range": "bytes=4-8"})
+			require.Equal(t, http.StatusPartialContent, w.Code, archive)
+			assert.Equal(t, "first", w.Body.String())
+		}
+		w := do(http.MethodHead, "/archive/test/data.tar.gz?member=README", readToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "22", w.Header().Get("Content-Length"))
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("embargo", func(t *testing.T) {
+		// A public export under embargo needs a token until the embargo lifts
+		setEmbargo := func(publicAfter time.Time) {
+			server_utils.ResetOriginExports()
+			viper.Set("Origin.ExportVolumes", []string{})
+			viper.Set("Origin.Exports", []map[string]any{{
+				"StoragePrefix":    storageDir,
+				"FederationPrefix": "/test",
+				"Capabilities":     []any{"PublicReads", "Reads"},
+				"Embargo":          map[string]any{"PublicAfter": publicAfter},
+			}})
+		}
+		t.Cleanup(func() {
+			viper.Set("Origin.Exports", nil)
+			viper.Set("Origin.ExportVolumes", []string{storageDir + ":/test"})
+			server_utils.ResetOriginExports()
+		})
+
+		setEmbargo(time.Now().Add(time.Hour))
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/archive/test/data.zip?member=README", "", nil).Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/archive/test/data.zip?member=README", readToken, nil).Code)
+
+		setEmbargo(time.Now().Add(-time.Hour))
+		w := do(http.MethodGet, "/archive/test/data.zip?member=README", "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, members["README"], w.Body.String())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/archive/test/missing.zip?member=README", readToken, nil).Code)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/archive/elsewhere/data.zip?member=README", readToken, nil).Code)
+		assert.Equal(t, http.StatusUnsupportedMediaType, do(http.MethodGet, "/archive/test/notes.txt?member=README", readToken, nil).Code)
+
+		archiveMembersEnabled.Store(false)
+		defer archiveMembersEnabled.Store(true)
+		assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/archive/test/data.zip?member=README", readToken, nil).Code)
+	})
+}
@@ -0,0 +1,309 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+type (
+	// An upload sent to the origin's web API in chunks, possibly in parallel.  Once all
+	// its chunks are received, it's assembled into a staged upload that is published
+	// like any other.
+	ChunkedUpload struct {
+		Id        string    `json:"id"`
+		Path      string    `json:"path"`
+		Size      int64     `json:"size"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+
+	chunkedUpload struct {
+		ChunkedUpload
+		// The length of the chunks received so far, keyed by their offset
+		received map[int64]int64
+		// The number of chunks being written
+		writing    int
+		assembling bool
+	}
+)
+
+// Whether the chunks received so far cover the whole object
+func (upload *chunkedUpload) complete() bool {
+	offsets := make([]int64, 0, len(upload.received))
+	for offset := range upload.received {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	var covered int64
+	for _, offset := range offsets {
+		if offset > covered {
+			return false
+		}
+		covered = max(covered, offset+upload.received[offset])
+	}
+	return covered >= upload.Size
+}
+
+// Look up the chunked upload of the object, holding the mutex
+func (staging *uploadStaging) lookupChunked(id string, objectPath string) (*chunkedUpload, error) {
+	upload, ok := staging.chunked[id]
+	if !ok || upload.Path != objectPath {
+		return nil, errors.Wrapf(errStagedUploadNotFound, "no chunked upload %s of %s is in progress", id, objectPath)
+	}
+	return upload, nil
+}
+
+// Start a chunked upload of an object of the given size
+func (staging *uploadStaging) startChunked(objectPath string, size int64) (ChunkedUpload, error) {
+	if size < 0 {
+		return ChunkedUpload{}, errors.Wrap(errStagedUploadBadRequest, "the size of the object must not be negative")
+	}
+	id := uuid.NewString()
+	partialPath := staging.filePath(id) + uploadStagingPartialExt
+	file, err := os.OpenFile(partialPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return ChunkedUpload{}, err
+	}
+	err = file.Truncate(size)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(partialPath)
+		return ChunkedUpload{}, errors.Wrapf(err, "failed to start the chunked upload of %s", objectPath)
+	}
+
+	upload := &chunkedUpload{
+		ChunkedUpload: ChunkedUpload{
+			Id:        id,
+			Path:      objectPath,
+			Size:      size,
+			ExpiresAt: time.Now().UTC().Add(staging.lifetime),
+		},
+		received: make(map[int64]int64),
+	}
+	staging.mutex.Lock()
+	if staging.chunked == nil {
+		staging.chunked = make(map[string]*chunkedUpload)
+	}
+	staging.chunked[id] = upload
+	staging.mutex.Unlock()
+	log.Debugf("Started the chunked upload %s of %s (%d bytes)", id, objectPath, size)
+	return upload.ChunkedUpload, nil
+}
+
+// Write a chunk of a chunked upload at the offset.  A chunk may be sent again, e.g.,
+// after a failed attempt, as long as the upload isn't being assembled.
+func (staging *uploadStaging) writeChunk(id string, objectPath string, offset int64, length int64, body io.Reader) error {
+	staging.mutex.Lock()
+	upload, err := staging.lookupChunked(id, objectPath)
+	if err == nil && upload.assembling {
+		err = errors.Wrapf(errStagedUploadIncomplete, "the chunked upload %s is being assembled", id)
+	} else if err == nil && (offset < 0 || length < 0 || offset+length > upload.Size) {
+		err = errors.Wrapf(errStagedUploadBadRequest, "the chunk of %d bytes at offset %d is outside the object of %d bytes", length, offset, upload.Size)
+	}
+	if err != nil {
+		staging.mutex.Unlock()
+		return err
+	}
+	upload.writing++
+	staging.mutex.Unlock()
+
+	file, err := os.OpenFile(staging.filePath(id)+uploadStagingPartialExt, os.O_WRONLY, 0)
+	if err == nil {
+		var written int64
+		written, err = io.CopyN(io.NewOffsetWriter(file, offset), body, length)
+		if err == io.EOF {
+			err = errors.Wrapf(errStagedUploadBadRequest, "the chunk ended after %d of %d bytes", written, length)
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	staging.mutex.Lock()
+	defer staging.mutex.Unlock()
+	upload.writing--
+	if err != nil {
+		return errors.Wrapf(err, "failed to write the chunk at offset %d of %s", offset, objectPath)
+	}
+	upload.received[offset] = max(upload.received[offset], length)
+	return nil
+}
+
+// Assemble a chunked upload whose chunks were all received into the staged upload of
+// the object, computing its checksums
+func (staging *uploadStaging) assembleChunked(id string, objectPath string) (StagedUpload, error) {
+	staging.mutex.Lock()
+	upload, err := staging.lookupChunked(id, objectPath)
+	if err == nil && (upload.assembling || upload.writing > 0) {
+		err = errors.Wrapf(errStagedUploadIncomplete, "chunks of the upload %s are still being written", id)
+	} else if err == nil && !upload.complete() {
+		err = errors.Wrapf(errStagedUploadIncomplete, "the chunks of the upload %s do not cover the object yet", id)
+	}
+	if err != nil {
+		staging.mutex.Unlock()
+		return StagedUpload{}, err
+	}
+	upload.assembling = true
+	staging.mutex.Unlock()
+
+	hashers := make(map[string]hash.Hash, len(stagedUploadDigests))
+	writers := make([]io.Writer, 0, len(stagedUploadDigests))
+	for name, newHash := range stagedUploadDigests {
+		hashers[name] = newHash()
+		writers = append(writers, hashers[name])
+	}
+	file, err := os.Open(staging.filePath(id) + uploadStagingPartialExt)
+	if err == nil {
+		_, err = io.Copy(io.MultiWriter(writers...), file)
+		if err == nil {
+			err = file.Sync()
+		}
+		file.Close()
+	}
+
+	staging.mutex.Lock()
+	delete(staging.chunked, id)
+	staging.mutex.Unlock()
+	if err != nil {
+		os.Remove(staging.filePath(id) + uploadStagingPartialExt)
+		return StagedUpload{}, errors.Wrapf(err, "failed to assemble the chunked upload of %s", objectPath)
+	}
+	return staging.register(id, objectPath, upload.Size, hashers)
+}
+
+// Abandon a chunked upload, deleting the chunks received so far
+func (staging *uploadStaging) discardChunked(id string, objectPath string) error {
+	staging.mutex.Lock()
+	upload, err := staging.lookupChunked(id, objectPath)
+	if err == nil && upload.assembling {
+		err = errors.Wrapf(errStagedUploadIncomplete, "the chunked upload %s is being assembled", id)
+	}
+	if err != nil {
+		staging.mutex.Unlock()
+		return err
+	}
+	delete(staging.chunked, id)
+	staging.mutex.Unlock()
+	// Chunks still being written fail once the file is gone
+	os.Remove(staging.filePath(id) + uploadStagingPartialExt)
+	return nil
+}
+
+// Delete the chunked uploads that were not assembled within Origin.UploadStagingLifetime
+func (staging *uploadStaging) expireChunked(now time.Time) {
+	expired := []ChunkedUpload{}
+	staging.mutex.Lock()
+	for id, upload := range staging.chunked {
+		if now.After(upload.ExpiresAt) && !upload.assembling {
+			expired = append(expired, upload.ChunkedUpload)
+			delete(staging.chunked, id)
+		}
+	}
+	staging.mutex.Unlock()
+	for _, upload := range expired {
+		os.Remove(staging.filePath(upload.Id) + uploadStagingPartialExt)
+		log.Infof("Deleted the chunked upload %s of %s; it was never assembled", upload.Id, upload.Path)
+	}
+}
+
+// Parse a non-negative integer query parameter of a chunked upload request
+func chunkedUploadQueryInt(ctx *gin.Context, name string) (int64, error) {
+	value, err := strconv.ParseInt(ctx.Query(name), 10, 64)
+	if err != nil || value < 0 {
+		return 0, errors.Wrapf(errStagedUploadBadRequest, "the %s query parameter must be a non-negative integer", name)
+	}
+	return value, nil
+}
+
+func handleStartChunkedUpload(ctx *gin.Context) {
+	staging, objectPath := stagedUploadRequest(ctx, token_scopes.Storage_Create, token_scopes.Storage_Modify)
+	if staging == nil {
+		return
+	}
+	size, err := chunkedUploadQueryInt(ctx, "size")
+	if err == nil {
+		var upload ChunkedUpload
+		if upload, err = staging.startChunked(objectPath, size); err == nil {
+			ctx.JSON(http.StatusCreated, upload)
+			return
+		}
+	}
+	abortStagedUploadError(ctx, err)
+}
+
+func handleUploadChunk(ctx *gin.Context) {
+	staging, objectPath := stagedUploadRequest(ctx, token_scopes.Storage_Create, token_scopes.Storage_Modify)
+	if staging == nil {
+		return
+	}
+	offset, err := chunkedUploadQueryInt(ctx, "offset")
+	if err == nil && ctx.Request.ContentLength < 0 {
+		err = errors.Wrap(errStagedUploadBadRequest, "the length of the chunk must be given in the Content-Length header")
+	}
+	if err == nil {
+		err = staging.writeChunk(ctx.Query("upload"), objectPath, offset, ctx.Request.ContentLength, ctx.Request.Body)
+	}
+	if err != nil {
+		abortStagedUploadError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK})
+}
+
+func handleDiscardChunkedUpload(ctx *gin.Context) {
+	staging, objectPath := stagedUploadRequest(ctx, token_scopes.Storage_Create, token_scopes.Storage_Modify)
+	if staging == nil {
+		return
+	}
+	if err := staging.discardChunked(ctx.Query("upload"), objectPath); err != nil {
+		abortStagedUploadError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK})
+}
+
+func handleAssembleChunkedUpload(ctx *gin.Context) {
+	staging, objectPath := stagedUploadRequest(ctx, token_scopes.Storage_Create, token_scopes.Storage_Modify)
+	if staging == nil {
+		return
+	}
+	record, err := staging.assembleChunked(ctx.Query("upload"), objectPath)
+	if err != nil {
+		abortStagedUploadError(ctx, err)
+		return
+	}
+	ctx.Header("Digest", record.digestHeader())
+	ctx.JSON(http.StatusCreated, record)
+}
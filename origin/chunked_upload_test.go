@@ -0,0 +1,110 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkedUploadAPI(t *testing.T) {
+	staging, storageDir, newToken := setupUploadStaging(t)
+	router := gin.New()
+	router.POST("/chunked/*path", handleStartChunkedUpload)
+	router.PUT("/chunked/*path", handleUploadChunk)
+	router.DELETE("/chunked/*path", handleDiscardChunkedUpload)
+	router.POST("/assemble/*path", handleAssembleChunkedUpload)
+	router.POST("/publish/*path", handlePublishUpload)
+	createToken := newToken("storage.create:/dir")
+	do := func(method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+createToken)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	start := func(size string) string {
+		w := do(http.MethodPost, "/chunked/test/dir/big.bin?size="+size, "", nil)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		upload := ChunkedUpload{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &upload))
+		assert.Equal(t, "/test/dir/big.bin", upload.Path)
+		return upload.Id
+	}
+
+	t.Run("assemble-and-publish", func(t *testing.T) {
+		id := start("11")
+		// Chunks may arrive in any order, and be sent again
+		assert.Equal(t, http.StatusOK, do(http.MethodPut, "/chunked/test/dir/big.bin?upload="+id+"&offset=6", "world", nil).Code)
+		assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/assemble/test/dir/big.bin?upload="+id, "", nil).Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodPut, "/chunked/test/dir/big.bin?upload="+id+"&offset=0", "hello ", nil).Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodPut, "/chunked/test/dir/big.bin?upload="+id+"&offset=0", "hello ", nil).Code)
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/chunked/test/dir/big.bin?upload="+id+"&offset=8", "world", nil).Code)
+
+		w := do(http.MethodPost, "/assemble/test/dir/big.bin?upload="+id, "", nil)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		sum := sha256.Sum256([]byte("hello world"))
+		digest := "sha-256=" + base64.StdEncoding.EncodeToString(sum[:])
+		assert.Contains(t, w.Header().Get("Digest"), digest)
+		assert.Empty(t, staging.chunked)
+		_, err := os.Stat(filepath.Join(storageDir, "dir", "big.bin"))
+		assert.ErrorIs(t, err, os.ErrNotExist, "an assembled upload must not be in the namespace until published")
+
+		require.Equal(t, http.StatusOK, do(http.MethodPost, "/publish/test/dir/big.bin", "", map[string]string{"Digest": digest}).Code)
+		contents, err := os.ReadFile(filepath.Join(storageDir, "dir", "big.bin"))
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", string(contents))
+	})
+
+	t.Run("wrong-upload", func(t *testing.T) {
+		id := start("5")
+		assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/chunked/test/dir/other.bin?upload="+id+"&offset=0", "hello", nil).Code)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/chunked/test/dir/big.bin?upload=unknown&offset=0", "hello", nil).Code)
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/chunked/test/dir/big.bin?upload="+id+"&offset=-1", "hello", nil).Code)
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/chunked/test/dir/big.bin", "", nil).Code)
+
+		require.Equal(t, http.StatusOK, do(http.MethodDelete, "/chunked/test/dir/big.bin?upload="+id, "", nil).Code)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/chunked/test/dir/big.bin?upload="+id+"&offset=0", "hello", nil).Code)
+		_, err := os.Stat(staging.filePath(id) + uploadStagingPartialExt)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		id := start("5")
+		staging.expire(time.Now().Add(2 * time.Hour))
+		assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/assemble/test/dir/big.bin?upload="+id, "", nil).Code)
+		_, err := os.Stat(staging.filePath(id) + uploadStagingPartialExt)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
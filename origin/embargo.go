@@ -0,0 +1,71 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+// Call onBoundary each time an export's embargo starts or ends, so the origin's
+// authorization and advertisement follow the embargo without anyone restarting
+// the origin at release time.  Nothing is launched if no export is embargoed.
+func LaunchEmbargoMaintenance(ctx context.Context, egrp *errgroup.Group, onBoundary func() error) error {
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, export := range exports {
+		if export.Embargo != nil {
+			log.Infof("Export %s is embargoed; it is currently public: %t", export.FederationPrefix, export.CapabilitiesAt(now).PublicReads)
+		}
+	}
+	if _, ok := server_utils.NextEmbargoBoundary(exports, now); !ok {
+		return nil
+	}
+
+	egrp.Go(func() error {
+		for {
+			next, ok := server_utils.NextEmbargoBoundary(exports, time.Now())
+			if !ok {
+				log.Debugln("No export embargoes remain to start or end; stopping the embargo maintenance")
+				return nil
+			}
+			log.Debugln("Next export embargo boundary is at", next.Format(time.RFC3339))
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-timer.C:
+			}
+			log.Infoln("An export embargo started or ended; updating the origin's authorization and advertisement")
+			if err := onBoundary(); err != nil {
+				log.Errorln("Failed to update the origin for the export embargo:", err)
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,517 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"hash"
+	"hash/adler32"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+type (
+	// The size and checksums the export scan recorded for an object
+	ExportScanEntry struct {
+		Path    string    `gorm:"primaryKey" json:"path"` // The path of the object in the federation
+		Size    int64     `gorm:"not null" json:"size"`
+		ModTime time.Time `gorm:"not null" json:"modTime"`
+		MD5     string    `gorm:"column:md5;not null;default:''" json:"md5"`
+		Adler32 string    `gorm:"column:adler32;not null;default:''" json:"adler32"`
+		CRC32   string    `gorm:"column:crc32;not null;default:''" json:"crc32"`
+		// The last pass that found the object; objects not found by a complete pass are forgotten
+		Pass      int64     `gorm:"not null;index" json:"-"`
+		ScannedAt time.Time `gorm:"not null" json:"scannedAt"`
+	}
+
+	// A pass of the export scan over every export.  The counters of an interrupted pass
+	// are saved periodically, so they're close to the truth when the pass resumes.
+	ExportScanPass struct {
+		ID          int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+		StartedAt   time.Time  `gorm:"not null" json:"startedAt"`
+		CompletedAt *time.Time `json:"completedAt,omitempty"`
+		Objects     int64      `gorm:"not null;default:0" json:"objects"`   // The objects checked so far
+		Bytes       int64      `gorm:"not null;default:0" json:"bytes"`     // The size of the objects checked so far
+		ReadBytes   int64      `gorm:"not null;default:0" json:"readBytes"` // The bytes read to compute checksums
+		Errors      int64      `gorm:"not null;default:0" json:"errors"`
+	}
+
+	exportScanStatus struct {
+		// The pass in progress or, between passes, the last one
+		CurrentPass *ExportScanPass `json:"currentPass,omitempty"`
+		// The last complete pass
+		LastCompletedPass *ExportScanPass `json:"lastCompletedPass,omitempty"`
+		NextPassAt        *time.Time      `json:"nextPassAt,omitempty"`
+		// The objects recorded in the database, and their total size
+		Objects int64 `json:"objects"`
+		Bytes   int64 `json:"bytes"`
+	}
+
+	// Checks the objects of the exports with a bounded pool of workers, throttled by
+	// rate limits on the bytes and the objects it reads
+	exportScanner struct {
+		workers     int
+		byteLimiter *rate.Limiter
+		fileLimiter *rate.Limiter
+		// The counters of the pass in progress
+		objects   atomic.Int64
+		bytes     atomic.Int64
+		readBytes atomic.Int64
+		errors    atomic.Int64
+	}
+
+	// An object found by the walk of an export
+	exportScanJob struct {
+		fedPath  string
+		filePath string
+		info     fs.FileInfo
+	}
+
+	// A reader that can't be consumed faster than a rate limiter allows
+	throttledReader struct {
+		io.Reader
+		ctx     context.Context
+		limiter *rate.Limiter
+	}
+)
+
+const (
+	// How often the counters of the pass in progress are saved
+	exportScanSaveInterval = 10 * time.Second
+	// The burst of the byte rate limiter, large enough to hold a read buffer
+	exportScanRateBurst  = 256 * 1024
+	exportScanBufferSize = 128 * 1024
+)
+
+// When the next pass of the export scan starts, if it's waiting for one
+var exportScanNextAt atomic.Pointer[time.Time]
+
+func (ExportScanEntry) TableName() string {
+	return "export_scan_entries"
+}
+
+func (ExportScanPass) TableName() string {
+	return "export_scan_passes"
+}
+
+// Parse a read rate such as "100MB" or "100MB/s" into bytes per second.
+// An empty string or 0 means unlimited.
+func parseExportScanRate(value string) (int64, error) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "/s")
+	if value == "" {
+		return 0, nil
+	}
+	bytesPerSecond, err := units.ParseStrictBytes(value)
+	if err != nil || bytesPerSecond < 0 {
+		return 0, errors.Errorf("invalid value %q for %s; expected a size per second such as 100MB", value, param.Origin_ExportScanMaxRate.GetName())
+	}
+	return bytesPerSecond, nil
+}
+
+func newExportScanner(workers int, bytesPerSecond int64, filesPerSecond int) *exportScanner {
+	scanner := &exportScanner{workers: max(workers, 1)}
+	if bytesPerSecond > 0 {
+		scanner.byteLimiter = rate.NewLimiter(rate.Limit(bytesPerSecond), int(min(bytesPerSecond, exportScanRateBurst)))
+	}
+	if filesPerSecond > 0 {
+		scanner.fileLimiter = rate.NewLimiter(rate.Limit(filesPerSecond), 1)
+	}
+	return scanner
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+// Get the pass to run: the interrupted pass if there's one, or a new one
+func startExportScanPass() (*ExportScanPass, error) {
+	pass := &ExportScanPass{}
+	err := db.Order("id DESC").First(pass).Error
+	if err == nil && pass.CompletedAt == nil {
+		return pass, nil
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(err, "failed to get the last pass of the export scan")
+	}
+	pass = &ExportScanPass{StartedAt: time.Now()}
+	if err := db.Create(pass).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to record a new pass of the export scan")
+	}
+	return pass, nil
+}
+
+// Get the last pass of the export scan, and the last complete one
+func getExportScanPasses() (last *ExportScanPass, lastCompleted *ExportScanPass, err error) {
+	last = &ExportScanPass{}
+	if err = db.Order("id DESC").First(last).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if last.CompletedAt != nil {
+		return last, last, nil
+	}
+	lastCompleted = &ExportScanPass{}
+	if err = db.Where("completed_at IS NOT NULL").Order("id DESC").First(lastCompleted).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return last, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return last, lastCompleted, nil
+}
+
+func (s *exportScanner) savePass(pass *ExportScanPass) error {
+	pass.Objects = s.objects.Load()
+	pass.Bytes = s.bytes.Load()
+	pass.ReadBytes = s.readBytes.Load()
+	pass.Errors = s.errors.Load()
+	return db.Save(pass).Error
+}
+
+// Compute the checksums of the file, reading it no faster than the scanner allows
+func (s *exportScanner) checksumFile(ctx context.Context, filePath string) (entry ExportScanEntry, err error) {
+	fp, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	md5Hash, adler32Hash, crc32Hash := md5.New(), adler32.New(), crc32.NewIEEE()
+	var reader io.Reader = fp
+	if s.byteLimiter != nil {
+		reader = &throttledReader{Reader: fp, ctx: ctx, limiter: s.byteLimiter}
+	}
+	n, err := io.CopyBuffer(io.MultiWriter(md5Hash, adler32Hash, crc32Hash), reader, make([]byte, exportScanBufferSize))
+	s.readBytes.Add(n)
+	metrics.PelicanOriginExportScanReadBytes.Add(float64(n))
+	if err != nil {
+		return
+	}
+	sum := func(h hash.Hash) string { return hex.EncodeToString(h.Sum(nil)) }
+	entry.MD5, entry.Adler32, entry.CRC32 = sum(md5Hash), sum(adler32Hash), sum(crc32Hash)
+	return
+}
+
+// Record the object, reading it only if it changed since it was last scanned
+func (s *exportScanner) scanObject(ctx context.Context, passId int64, job exportScanJob) error {
+	// Find rather than First, which logs an error for each new object
+	existing := ExportScanEntry{}
+	result := db.Where("path = ?", job.fedPath).Limit(1).Find(&existing)
+	if result.Error != nil {
+		return result.Error
+	}
+	found := result.RowsAffected > 0
+	if found && existing.Pass == passId {
+		// Checked before the pass was interrupted
+		return nil
+	}
+
+	if found && existing.Size == job.info.Size() && existing.ModTime.Equal(job.info.ModTime()) {
+		if err := db.Model(&existing).Update("pass", passId).Error; err != nil {
+			return err
+		}
+		metrics.PelicanOriginExportScanObjects.WithLabelValues("unchanged").Inc()
+	} else {
+		entry, err := s.checksumFile(ctx, job.filePath)
+		if err != nil {
+			return err
+		}
+		// An object modified while it was read is checked again by the next pass
+		info, err := os.Stat(job.filePath)
+		if err != nil {
+			return err
+		}
+		if info.Size() != job.info.Size() || !info.ModTime().Equal(job.info.ModTime()) {
+			log.Debugf("Skipping %s in the export scan; it changed while it was read", job.fedPath)
+			return nil
+		}
+		entry.Path = job.fedPath
+		entry.Size = info.Size()
+		entry.ModTime = info.ModTime()
+		entry.Pass = passId
+		entry.ScannedAt = time.Now()
+		if err := db.Save(&entry).Error; err != nil {
+			return err
+		}
+		metrics.PelicanOriginExportScanObjects.WithLabelValues("scanned").Inc()
+	}
+	s.objects.Add(1)
+	s.bytes.Add(job.info.Size())
+	return nil
+}
+
+// Walk the exports, sending their objects to the workers
+func (s *exportScanner) walkExports(ctx context.Context, exports []server_utils.OriginExport, jobs chan<- exportScanJob) error {
+	for _, export := range exports {
+		root := filepath.Clean(export.StoragePrefix)
+		err := filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err != nil {
+				log.Warningf("Failed to walk %s in the export scan: %v", filePath, err)
+				s.errors.Add(1)
+				if d != nil && d.IsDir() && filePath != root {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			// Symbolic links and special files aren't objects of the namespace
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				// Removed since the directory was listed
+				return nil
+			}
+			rel, err := filepath.Rel(root, filePath)
+			if err != nil {
+				return nil
+			}
+			if s.fileLimiter != nil {
+				if err := s.fileLimiter.Wait(ctx); err != nil {
+					return err
+				}
+			}
+			job := exportScanJob{
+				fedPath:  path.Join(export.FederationPrefix, filepath.ToSlash(rel)),
+				filePath: filePath,
+				info:     info,
+			}
+			select {
+			case jobs <- job:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run a pass of the export scan, resuming the interrupted pass if there's one.  The pass
+// is only completed, and the objects it didn't find forgotten, if it wasn't cancelled.
+func (s *exportScanner) runPass(ctx context.Context, exports []server_utils.OriginExport) (*ExportScanPass, error) {
+	pass, err := startExportScanPass()
+	if err != nil {
+		return nil, err
+	}
+	s.objects.Store(pass.Objects)
+	s.bytes.Store(pass.Bytes)
+	s.readBytes.Store(pass.ReadBytes)
+	s.errors.Store(pass.Errors)
+	log.Infof("Starting pass %d of the export scan with %d workers", pass.ID, s.workers)
+
+	egrp, egrpCtx := errgroup.WithContext(ctx)
+	jobs := make(chan exportScanJob, s.workers)
+	egrp.Go(func() error {
+		defer close(jobs)
+		return s.walkExports(egrpCtx, exports, jobs)
+	})
+	for i := 0; i < s.workers; i++ {
+		egrp.Go(func() error {
+			for job := range jobs {
+				if err := s.scanObject(egrpCtx, pass.ID, job); err != nil {
+					if egrpCtx.Err() != nil {
+						return egrpCtx.Err()
+					}
+					log.Warningf("Failed to scan %s: %v", job.filePath, err)
+					s.errors.Add(1)
+					metrics.PelicanOriginExportScanObjects.WithLabelValues("failed").Inc()
+				}
+			}
+			return nil
+		})
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(exportScanSaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				progress := *pass
+				if err := s.savePass(&progress); err != nil {
+					log.Warningln("Failed to save the progress of the export scan:", err)
+				}
+			}
+		}
+	}()
+	err = egrp.Wait()
+	close(done)
+
+	if err != nil {
+		if saveErr := s.savePass(pass); saveErr != nil {
+			log.Warningln("Failed to save the progress of the export scan:", saveErr)
+		}
+		return pass, err
+	}
+	if err := db.Where("pass < ?", pass.ID).Delete(&ExportScanEntry{}).Error; err != nil {
+		return pass, errors.Wrap(err, "failed to forget the objects removed from the exports")
+	}
+	completedAt := time.Now()
+	pass.CompletedAt = &completedAt
+	if err := s.savePass(pass); err != nil {
+		return pass, errors.Wrap(err, "failed to record the completion of the export scan")
+	}
+	log.Infof("Completed pass %d of the export scan: %d objects (%d bytes), %d bytes read, %d errors",
+		pass.ID, pass.Objects, pass.Bytes, pass.ReadBytes, pass.Errors)
+	return pass, nil
+}
+
+// Launch the background scan of the exports if Origin.EnableExportScan is set
+func LaunchExportScan(ctx context.Context, egrp *errgroup.Group) error {
+	if !param.Origin_EnableExportScan.GetBool() {
+		return nil
+	}
+	if param.Origin_StorageType.GetString() != string(server_structs.OriginStoragePosix) {
+		return errors.Errorf("%s is only supported for the %s storage type", param.Origin_EnableExportScan.GetName(), server_structs.OriginStoragePosix)
+	}
+	bytesPerSecond, err := parseExportScanRate(param.Origin_ExportScanMaxRate.GetString())
+	if err != nil {
+		return err
+	}
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		return err
+	}
+	scanner := newExportScanner(param.Origin_ExportScanWorkers.GetInt(), bytesPerSecond, param.Origin_ExportScanMaxFileRate.GetInt())
+
+	egrp.Go(func() error {
+		for {
+			// Resume an interrupted pass at once; otherwise wait out the interval
+			wait := time.Duration(0)
+			if last, _, err := getExportScanPasses(); err != nil {
+				log.Warningln("Failed to get the last pass of the export scan:", err)
+			} else if last != nil && last.CompletedAt != nil {
+				wait = time.Until(last.CompletedAt.Add(param.Origin_ExportScanInterval.GetDuration()))
+			}
+			nextAt := time.Now().Add(max(wait, 0))
+			exportScanNextAt.Store(&nextAt)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(wait):
+			}
+			exportScanNextAt.Store(nil)
+
+			if _, err := scanner.runPass(ctx, exports); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				log.Errorln("The export scan failed:", err)
+				// Don't hammer a failing database or filesystem
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(time.Minute):
+				}
+			}
+		}
+	})
+	log.Infof("Scanning the exports in the background with %d workers", scanner.workers)
+	return nil
+}
+
+// Report the progress of the export scan
+func handleGetExportScanStatus(ctx *gin.Context) {
+	status := exportScanStatus{NextPassAt: exportScanNextAt.Load()}
+	var err error
+	if status.CurrentPass, status.LastCompletedPass, err = getExportScanPasses(); err == nil {
+		totals := struct {
+			Objects int64
+			Bytes   int64
+		}{}
+		err = db.Model(&ExportScanEntry{}).Select("COUNT(*) AS objects, COALESCE(SUM(size), 0) AS bytes").Scan(&totals).Error
+		status.Objects, status.Bytes = totals.Objects, totals.Bytes
+	}
+	if err != nil {
+		log.Errorln("Failed to get the status of the export scan:", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to get the status of the export scan",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, status)
+}
+
+// Get the size and checksums the export scan recorded for the object at the "path" query parameter
+func handleGetExportScanEntry(ctx *gin.Context) {
+	objectPath := ctx.Query("path")
+	if objectPath == "" {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "The path query parameter is required",
+		})
+		return
+	}
+	entry := ExportScanEntry{}
+	if err := db.First(&entry, "path = ?", path.Clean("/"+objectPath)).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "The object has not been scanned",
+		})
+		return
+	} else if err != nil {
+		log.Errorf("Failed to get the export scan entry of %s: %v", objectPath, err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to get the export scan entry",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, entry)
+}
@@ -0,0 +1,163 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func setupExportScanDB(t *testing.T) {
+	mockDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	// Each connection to an in-memory database gets its own database
+	sqlDB, err := mockDB.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, mockDB.AutoMigrate(&ExportScanEntry{}, &ExportScanPass{}))
+	db = mockDB
+	t.Cleanup(func() {
+		db = nil
+		sqlDB.Close()
+	})
+}
+
+func getExportScanEntry(t *testing.T, fedPath string) ExportScanEntry {
+	entry := ExportScanEntry{}
+	require.NoError(t, db.First(&entry, "path = ?", fedPath).Error)
+	return entry
+}
+
+func TestParseExportScanRate(t *testing.T) {
+	for value, expected := range map[string]int64{"": 0, "0": 0, "100MB/s": 100 * 1000 * 1000, "1KiB": 1024} {
+		rate, err := parseExportScanRate(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, rate, value)
+	}
+	_, err := parseExportScanRate("fast")
+	assert.Error(t, err)
+}
+
+func TestExportScan(t *testing.T) {
+	setupExportScanDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(storage, "dir", "subdir"), 0755))
+	files := map[string]string{
+		"hello.txt":            "Hello, World!",
+		"dir/data.bin":         "some data",
+		"dir/subdir/empty.txt": "",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(storage, name), []byte(content), 0644))
+	}
+	require.NoError(t, os.Symlink(filepath.Join(storage, "hello.txt"), filepath.Join(storage, "link.txt")))
+	exports := []server_utils.OriginExport{{StoragePrefix: storage, FederationPrefix: "/test"}}
+	scanner := newExportScanner(2, 1024*1024, 0)
+
+	t.Run("first-pass", func(t *testing.T) {
+		pass, err := scanner.runPass(ctx, exports)
+		require.NoError(t, err)
+		require.NotNil(t, pass.CompletedAt)
+		assert.EqualValues(t, 3, pass.Objects)
+		assert.EqualValues(t, len("Hello, World!")+len("some data"), pass.Bytes)
+		assert.Equal(t, pass.Bytes, pass.ReadBytes)
+		assert.Zero(t, pass.Errors)
+
+		entry := getExportScanEntry(t, "/test/hello.txt")
+		assert.EqualValues(t, 13, entry.Size)
+		assert.Equal(t, "65a8e27d8879283831b664bd8b7f0ad4", entry.MD5)
+		assert.Equal(t, "1f9e046a", entry.Adler32)
+		assert.Equal(t, "ec4ac3d0", entry.CRC32)
+		assert.Equal(t, pass.ID, entry.Pass)
+		entry = getExportScanEntry(t, "/test/dir/subdir/empty.txt")
+		assert.Equal(t, "00000001", entry.Adler32)
+
+		// Symbolic links aren't followed
+		var count int64
+		require.NoError(t, db.Model(&ExportScanEntry{}).Count(&count).Error)
+		assert.EqualValues(t, 3, count)
+	})
+
+	t.Run("unchanged-files-not-read", func(t *testing.T) {
+		pass, err := scanner.runPass(ctx, exports)
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, pass.Objects)
+		assert.Zero(t, pass.ReadBytes)
+		assert.Equal(t, pass.ID, getExportScanEntry(t, "/test/hello.txt").Pass)
+	})
+
+	t.Run("changed-and-removed-files", func(t *testing.T) {
+		dataPath := filepath.Join(storage, "dir", "data.bin")
+		require.NoError(t, os.WriteFile(dataPath, []byte("other data!"), 0644))
+		require.NoError(t, os.Chtimes(dataPath, time.Now(), time.Now().Add(time.Minute)))
+		require.NoError(t, os.Remove(filepath.Join(storage, "hello.txt")))
+
+		pass, err := scanner.runPass(ctx, exports)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, pass.Objects)
+		assert.EqualValues(t, len("other data!"), pass.ReadBytes)
+		assert.EqualValues(t, len("other data!"), getExportScanEntry(t, "/test/dir/data.bin").Size)
+		err = db.First(&ExportScanEntry{}, "path = ?", "/test/hello.txt").Error
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("resume-interrupted-pass", func(t *testing.T) {
+		// An interrupted pass that already checked one of the objects
+		interrupted := ExportScanPass{StartedAt: time.Now(), Objects: 1, Bytes: 11}
+		require.NoError(t, db.Create(&interrupted).Error)
+		require.NoError(t, db.Model(&ExportScanEntry{}).Where("path = ?", "/test/dir/data.bin").Update("pass", interrupted.ID).Error)
+
+		pass, err := scanner.runPass(ctx, exports)
+		require.NoError(t, err)
+		assert.Equal(t, interrupted.ID, pass.ID)
+		assert.NotNil(t, pass.CompletedAt)
+		assert.EqualValues(t, 2, pass.Objects)
+		assert.EqualValues(t, 11, pass.Bytes)
+		assert.Zero(t, pass.ReadBytes)
+	})
+
+	t.Run("cancelled-pass-left-incomplete", func(t *testing.T) {
+		cancelledCtx, cancelPass := context.WithCancel(ctx)
+		cancelPass()
+		pass, err := scanner.runPass(cancelledCtx, exports)
+		require.Error(t, err)
+		assert.Nil(t, pass.CompletedAt)
+		last, lastCompleted, err := getExportScanPasses()
+		require.NoError(t, err)
+		assert.Equal(t, pass.ID, last.ID)
+		assert.Less(t, lastCompleted.ID, pass.ID)
+		// The objects the pass didn't reach are kept
+		var count int64
+		require.NoError(t, db.Model(&ExportScanEntry{}).Count(&count).Error)
+		assert.EqualValues(t, 2, count)
+	})
+}
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE export_scan_entries (
+    path TEXT PRIMARY KEY,
+    size INTEGER NOT NULL,
+    mod_time DATETIME NOT NULL,
+    md5 TEXT NOT NULL DEFAULT '',
+    adler32 TEXT NOT NULL DEFAULT '',
+    crc32 TEXT NOT NULL DEFAULT '',
+    pass INTEGER NOT NULL,
+    scanned_at DATETIME NOT NULL
+);
+CREATE INDEX idx_export_scan_entries_pass ON export_scan_entries(pass);
+
+CREATE TABLE export_scan_passes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    started_at DATETIME NOT NULL,
+    completed_at DATETIME,
+    objects INTEGER NOT NULL DEFAULT 0,
+    bytes INTEGER NOT NULL DEFAULT 0,
+    read_bytes INTEGER NOT NULL DEFAULT 0,
+    errors INTEGER NOT NULL DEFAULT 0
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS export_scan_passes;
+DROP TABLE IF EXISTS export_scan_entries;
+-- +goose StatementEnd
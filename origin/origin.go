@@ -26,6 +26,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_utils"
 )
 
@@ -52,5 +53,24 @@ func RegisterOriginAPI(router *gin.Engine, ctx context.Context, egrp *errgroup.G
 	{
 		group.POST("/directorTest", func(ctx *gin.Context) { server_utils.HandleDirectorTestResponse(ctx, notificationChan) })
 	}
+
+	// Staged uploads are authorized by the same tokens as uploads to XRootD
+	if param.Origin_EnableUploadStaging.GetBool() {
+		group.PUT("/staging/*path", handleStageUpload)
+		group.DELETE("/staging/*path", handleDiscardUpload)
+		group.POST("/publish/*path", handlePublishUpload)
+		// Large uploads may be sent in chunks, in parallel, and assembled into a staged upload
+		group.POST("/chunked/*path", handleStartChunkedUpload)
+		group.PUT("/chunked/*path", handleUploadChunk)
+		group.DELETE("/chunked/*path", handleDiscardChunkedUpload)
+		group.POST("/assemble/*path", handleAssembleChunkedUpload)
+	}
+
+	// Members of archives are served by the origin's web API, which extracts them from the
+	// archives in the exports; XRootD isn't involved
+	if param.Origin_EnableArchiveMembers.GetBool() {
+		group.GET("/archive/*path", handleArchiveMember)
+		group.HEAD("/archive/*path", handleArchiveMember)
+	}
 	return nil
 }
@@ -0,0 +1,331 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/web_ui"
+)
+
+type (
+	// The origin-wide capabilities, from the Origin.Enable* parameters
+	originCapabilities struct {
+		Reads       bool `json:"reads"`
+		PublicReads bool `json:"publicReads"`
+		Writes      bool `json:"writes"`
+		Listings    bool `json:"listings"`
+		DirectReads bool `json:"directReads"`
+	}
+
+	// The subset of the origin's configuration that can be edited through the web API
+	originConfigRes struct {
+		Sitename     string                      `json:"sitename"`
+		StorageType  string                      `json:"storageType"`
+		Capabilities originCapabilities          `json:"capabilities"`
+		Exports      []server_utils.OriginExport `json:"exports"`
+		// False when the exports are given on the command line, which overrides the configuration files
+		ExportsEditable bool `json:"exportsEditable"`
+		// Whether a saved change is waiting for the server to restart
+		RestartPending bool `json:"restartPending"`
+	}
+
+	// A change to the origin's configuration; fields left out are unchanged
+	originConfigUpdate struct {
+		Sitename     *string                      `json:"sitename"`
+		Capabilities *originCapabilities          `json:"capabilities"`
+		Exports      *[]server_utils.OriginExport `json:"exports"`
+	}
+
+	originConfigUpdateQuery struct {
+		// Only validate the change
+		DryRun bool `form:"dry_run"`
+		// Restart the server to apply a saved change; defaults to true
+		Restart *bool `form:"restart"`
+	}
+
+	originConfigUpdateRes struct {
+		// The parameters the change modifies
+		Changed    []string `json:"changed"`
+		Saved      bool     `json:"saved"`
+		Restarting bool     `json:"restarting"`
+	}
+)
+
+var (
+	restartPending atomic.Bool
+
+	// Gracefully stop the origin's XRootD daemons and restart the server with the new
+	// configuration.  Overridable in tests.
+	restartOrigin = func() {
+		config.RestartFlag <- true
+	}
+
+	// Apply reload-safe changes, such as to the exports, without restarting the server.
+	// Overridable in tests.
+	reloadOriginConfig = config.ReloadConfig
+)
+
+func currentOriginCapabilities() originCapabilities {
+	return originCapabilities{
+		Reads:       param.Origin_EnableReads.GetBool(),
+		PublicReads: param.Origin_EnablePublicReads.GetBool(),
+		Writes:      param.Origin_EnableWrites.GetBool(),
+		Listings:    param.Origin_EnableListings.GetBool(),
+		DirectReads: param.Origin_EnableDirectReads.GetBool(),
+	}
+}
+
+// Normalize an export the way the configuration files are read, so that unchanged
+// exports compare equal to the running ones
+func normalizeExport(export server_utils.OriginExport) server_utils.OriginExport {
+	if export.Capabilities.PublicReads {
+		export.Capabilities.Reads = true
+	}
+	if len(export.Capabilities.Extensions) == 0 {
+		export.Capabilities.Extensions = nil
+	}
+	if len(export.ReplicaTargets) == 0 {
+		export.ReplicaTargets = nil
+	}
+	if strings.HasSuffix(export.StoragePrefix, "/") && export.StoragePrefix != "/" {
+		export.StoragePrefix = strings.TrimSuffix(export.StoragePrefix, "/")
+	}
+	return export
+}
+
+// Convert an export to the form it takes in the Origin.Exports block of the configuration
+func exportToConfig(export server_utils.OriginExport) map[string]interface{} {
+	result := map[string]interface{}{
+		"FederationPrefix": export.FederationPrefix,
+		"StoragePrefix":    export.StoragePrefix,
+		"Capabilities":     server_utils.CapsToStringList(export.Capabilities),
+	}
+	optional := map[string]string{
+		"SentinelLocation":     export.SentinelLocation,
+		"S3Bucket":             export.S3Bucket,
+		"S3AccessKeyfile":      export.S3AccessKeyfile,
+		"S3SecretKeyfile":      export.S3SecretKeyfile,
+		"GlobusCollectionID":   export.GlobusCollectionID,
+		"GlobusCollectionName": export.GlobusCollectionName,
+	}
+	for key, value := range optional {
+		if value != "" {
+			result[key] = value
+		}
+	}
+	if len(export.ReplicaTargets) > 0 {
+		result["ReplicaTargets"] = export.ReplicaTargets
+	}
+	if export.Embargo != nil {
+		embargo := map[string]interface{}{}
+		if !export.Embargo.PublicAfter.IsZero() {
+			embargo["PublicAfter"] = export.Embargo.PublicAfter.Format(time.RFC3339)
+		}
+		if !export.Embargo.PublicUntil.IsZero() {
+			embargo["PublicUntil"] = export.Embargo.PublicUntil.Format(time.RFC3339)
+		}
+		result["Embargo"] = embargo
+	}
+	return result
+}
+
+// Validate a change to the origin's configuration and return the configuration
+// values it sets, keyed by parameter name, along with the parameters it changes
+func validateOriginConfigUpdate(update originConfigUpdate) (values map[string]interface{}, changed []string, err error) {
+	values = map[string]interface{}{}
+	changed = []string{}
+
+	if update.Sitename != nil {
+		sitename := strings.TrimSpace(*update.Sitename)
+		if sitename == "" {
+			return nil, nil, errors.New("the sitename cannot be empty")
+		}
+		if strings.ContainsAny(sitename, " \t\n") {
+			return nil, nil, errors.Errorf("the sitename %q cannot contain whitespace", sitename)
+		}
+		values[param.Xrootd_Sitename.GetName()] = sitename
+		if sitename != param.Xrootd_Sitename.GetString() {
+			changed = append(changed, param.Xrootd_Sitename.GetName())
+		}
+	}
+
+	if update.Capabilities != nil {
+		caps := *update.Capabilities
+		if caps.PublicReads {
+			caps.Reads = true
+		}
+		current := currentOriginCapabilities()
+		for _, capParam := range []struct {
+			param    param.BoolParam
+			value    bool
+			previous bool
+		}{
+			{param.Origin_EnableReads, caps.Reads, current.Reads},
+			{param.Origin_EnablePublicReads, caps.PublicReads, current.PublicReads},
+			{param.Origin_EnableWrites, caps.Writes, current.Writes},
+			{param.Origin_EnableListings, caps.Listings, current.Listings},
+			{param.Origin_EnableDirectReads, caps.DirectReads, current.DirectReads},
+		} {
+			values[capParam.param.GetName()] = capParam.value
+			if capParam.value != capParam.previous {
+				changed = append(changed, capParam.param.GetName())
+			}
+		}
+	}
+
+	if update.Exports != nil {
+		if len(param.Origin_ExportVolumes.GetStringSlice()) > 0 {
+			return nil, nil, errors.New("the exports are given on the command line, which overrides Origin.Exports")
+		}
+		storageType, err := server_structs.ParseOriginStorageType(param.Origin_StorageType.GetString())
+		if err != nil {
+			return nil, nil, err
+		}
+		exports := make([]server_utils.OriginExport, 0, len(*update.Exports))
+		for _, export := range *update.Exports {
+			exports = append(exports, normalizeExport(export))
+		}
+		if err := server_utils.ValidateOriginExports(storageType, exports); err != nil {
+			return nil, nil, err
+		}
+		exportConfigs := make([]interface{}, 0, len(exports))
+		for _, export := range exports {
+			exportConfigs = append(exportConfigs, exportToConfig(export))
+		}
+		values[param.Origin_Exports.GetName()] = exportConfigs
+
+		currentExports, err := server_utils.GetOriginExports()
+		if err != nil || len(currentExports) != len(exports) {
+			changed = append(changed, param.Origin_Exports.GetName())
+		} else {
+			for idx := range exports {
+				if !reflect.DeepEqual(normalizeExport(currentExports[idx]), exports[idx]) {
+					changed = append(changed, param.Origin_Exports.GetName())
+					break
+				}
+			}
+		}
+	}
+	return values, changed, nil
+}
+
+// Convert parameter names like Origin.Exports to the nested map the config file holds
+func nestConfigValues(values map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for key, value := range values {
+		parts := strings.Split(key, ".")
+		current := result
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := current[part].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				current[part] = next
+			}
+			current = next
+		}
+		current[parts[len(parts)-1]] = value
+	}
+	return result
+}
+
+func handleGetOriginConfig(ctx *gin.Context) {
+	res := originConfigRes{
+		Sitename:        param.Xrootd_Sitename.GetString(),
+		StorageType:     param.Origin_StorageType.GetString(),
+		Capabilities:    currentOriginCapabilities(),
+		Exports:         []server_utils.OriginExport{},
+		ExportsEditable: len(param.Origin_ExportVolumes.GetStringSlice()) == 0,
+		RestartPending:  restartPending.Load(),
+	}
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		log.Errorf("Failed to get the origin exports: %v", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: "Server encountered error when getting the origin exports: " + err.Error()})
+		return
+	}
+	res.Exports = append(res.Exports, exports...)
+	ctx.JSON(http.StatusOK, res)
+}
+
+// Validate and save a change to the origin's sitename, capabilities, or exports to the
+// web-based config file, then apply it.  Changes that can be reloaded only restart XRootD;
+// others restart the server.
+func handleUpdateOriginConfig(ctx *gin.Context) {
+	query := originConfigUpdateQuery{}
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: "Invalid query parameters: " + err.Error()})
+		return
+	}
+	update := originConfigUpdate{}
+	if err := ctx.ShouldBindJSON(&update); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: "Invalid request body: " + err.Error()})
+		return
+	}
+	values, changed, err := validateOriginConfigUpdate(update)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: "Invalid origin configuration: " + err.Error()})
+		return
+	}
+
+	res := originConfigUpdateRes{Changed: changed}
+	if query.DryRun || len(changed) == 0 {
+		ctx.JSON(http.StatusOK, res)
+		return
+	}
+	if err := web_ui.WriteWebConfig(nestConfigValues(values)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: err.Error()})
+		return
+	}
+	res.Saved = true
+	restartPending.Store(true)
+	log.Infof("Origin configuration of %s changed by %s", strings.Join(changed, ", "), ctx.GetString("User"))
+
+	res.Restarting = query.Restart == nil || *query.Restart
+	ctx.JSON(http.StatusOK, res)
+	if !res.Restarting {
+		return
+	}
+	if slices.IndexFunc(changed, func(key string) bool { return !config.IsReloadable(key) }) < 0 {
+		result, err := reloadOriginConfig()
+		if err == nil && len(result.Failed) == 0 {
+			log.Info("Reloaded the origin configuration to apply the change")
+			restartPending.Store(false)
+			return
+		} else if err == nil {
+			err = errors.Errorf("the new values of %d parameters were rejected", len(result.Failed))
+		}
+		log.Warningln("Failed to reload the origin configuration:", err)
+	}
+	log.Info("Restarting the origin to apply the configuration change")
+	restartOrigin()
+}
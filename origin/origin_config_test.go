@@ -0,0 +1,203 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func TestUpdateOriginConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	restarts := 0
+	oldRestart := restartOrigin
+	restartOrigin = func() { restarts++ }
+	t.Cleanup(func() {
+		restartOrigin = oldRestart
+		restartPending.Store(false)
+		server_utils.ResetTestState()
+	})
+
+	setup := func(t *testing.T) string {
+		server_utils.ResetTestState()
+		restartPending.Store(false)
+		restarts = 0
+		webConfigFile := filepath.Join(t.TempDir(), "web-config.yaml")
+		require.NoError(t, os.WriteFile(webConfigFile, []byte("Logging:\n  Level: debug\n"), 0644))
+		viper.Set("Server.WebConfigFile", webConfigFile)
+		viper.Set("Origin.StorageType", "posix")
+		viper.Set("Xrootd.Sitename", "old-origin")
+		viper.Set("Origin.Exports", []map[string]interface{}{
+			{"FederationPrefix": "/foo", "StoragePrefix": "/mnt/foo", "Capabilities": []interface{}{"PublicReads", "Listings"}},
+		})
+		return webConfigFile
+	}
+	patch := func(t *testing.T, query string, body string) (int, originConfigUpdateRes) {
+		w := httptest.NewRecorder()
+		_, r := gin.CreateTestContext(w)
+		r.PATCH("/config", handleUpdateOriginConfig)
+		req, err := http.NewRequest(http.MethodPatch, "/config"+query, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		res := originConfigUpdateRes{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		}
+		return w.Code, res
+	}
+	readWebConfig := func(t *testing.T, file string) *viper.Viper {
+		v := viper.New()
+		v.SetConfigFile(file)
+		require.NoError(t, v.ReadInConfig())
+		return v
+	}
+
+	t.Run("save-and-restart", func(t *testing.T) {
+		webConfigFile := setup(t)
+		code, res := patch(t, "", `{
+			"sitename": "new-origin",
+			"exports": [
+				{"federationPrefix": "/foo", "storagePrefix": "/mnt/foo", "capabilities": {"PublicRead": true, "Listing": true}},
+				{"federationPrefix": "/bar", "storagePrefix": "/mnt/bar/", "capabilities": {"Read": true, "Write": true}}
+			]
+		}`)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"Xrootd.Sitename", "Origin.Exports"}, res.Changed)
+		assert.True(t, res.Saved)
+		assert.True(t, res.Restarting)
+		assert.Equal(t, 1, restarts)
+		assert.True(t, restartPending.Load())
+
+		v := readWebConfig(t, webConfigFile)
+		assert.Equal(t, "debug", v.GetString("Logging.Level"))
+		assert.Equal(t, "new-origin", v.GetString("Xrootd.Sitename"))
+
+		// The saved exports read back the same way the origin reads Origin.Exports
+		server_utils.ResetTestState()
+		viper.Set("Origin.StorageType", "posix")
+		viper.Set("Origin.Exports", v.Get("Origin.Exports"))
+		exports, err := server_utils.GetOriginExports()
+		require.NoError(t, err)
+		require.Len(t, exports, 2)
+		assert.Equal(t, "/bar", exports[1].FederationPrefix)
+		assert.Equal(t, "/mnt/bar", exports[1].StoragePrefix)
+		assert.Equal(t, server_structs.Capabilities{Reads: true, Writes: true}, exports[1].Capabilities)
+		assert.Equal(t, server_structs.Capabilities{PublicReads: true, Reads: true, Listings: true}, exports[0].Capabilities)
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		setup(t)
+		code, res := patch(t, "", `{
+			"sitename": "old-origin",
+			"exports": [{"federationPrefix": "/foo", "storagePrefix": "/mnt/foo", "capabilities": {"PublicRead": true, "Listing": true}}]
+		}`)
+		require.Equal(t, http.StatusOK, code)
+		assert.Empty(t, res.Changed)
+		assert.False(t, res.Saved)
+		assert.Zero(t, restarts)
+	})
+
+	t.Run("dry-run", func(t *testing.T) {
+		webConfigFile := setup(t)
+		code, res := patch(t, "?dry_run=true", `{"capabilities": {"reads": true, "writes": true}}`)
+		require.Equal(t, http.StatusOK, code)
+		assert.Contains(t, res.Changed, "Origin.EnableWrites")
+		assert.False(t, res.Saved)
+		assert.Zero(t, restarts)
+		assert.False(t, readWebConfig(t, webConfigFile).IsSet("Origin.EnableWrites"))
+	})
+
+	t.Run("save-without-restart", func(t *testing.T) {
+		webConfigFile := setup(t)
+		code, res := patch(t, "?restart=false", `{"capabilities": {"publicReads": true}}`)
+		require.Equal(t, http.StatusOK, code)
+		assert.True(t, res.Saved)
+		assert.False(t, res.Restarting)
+		assert.Zero(t, restarts)
+		assert.True(t, restartPending.Load())
+		v := readWebConfig(t, webConfigFile)
+		assert.True(t, v.GetBool("Origin.EnablePublicReads"))
+		assert.True(t, v.GetBool("Origin.EnableReads"))
+	})
+
+	t.Run("reload-instead-of-restart", func(t *testing.T) {
+		setup(t)
+		reloads := 0
+		oldReload := reloadOriginConfig
+		reloadOriginConfig = func() (*config.ConfigReloadResult, error) {
+			reloads++
+			return &config.ConfigReloadResult{}, nil
+		}
+		t.Cleanup(func() { reloadOriginConfig = oldReload })
+		config.RegisterReloadable(nil, "Origin.Exports", "Origin.EnableReads", "Origin.EnablePublicReads")
+
+		code, res := patch(t, "", `{"capabilities": {"publicReads": true}}`)
+		require.Equal(t, http.StatusOK, code)
+		assert.True(t, res.Restarting)
+		assert.Equal(t, 1, reloads)
+		assert.Zero(t, restarts)
+		assert.False(t, restartPending.Load())
+
+		// The sitename can't be reloaded, so the server restarts
+		code, _ = patch(t, "", `{"sitename": "new-origin"}`)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 1, reloads)
+		assert.Equal(t, 1, restarts)
+	})
+
+	t.Run("invalid-changes", func(t *testing.T) {
+		for name, body := range map[string]string{
+			"empty-sitename":     `{"sitename": " "}`,
+			"no-exports":         `{"exports": []}`,
+			"relative-prefix":    `{"exports": [{"federationPrefix": "foo", "storagePrefix": "/mnt/foo"}]}`,
+			"reserved-prefix":    `{"exports": [{"federationPrefix": "/caches/foo", "storagePrefix": "/mnt/foo"}]}`,
+			"duplicate-prefixes": `{"exports": [{"federationPrefix": "/foo", "storagePrefix": "/mnt/a"}, {"federationPrefix": "/foo", "storagePrefix": "/mnt/b"}]}`,
+			"bad-json":           `{"sitename": 1}`,
+		} {
+			t.Run(name, func(t *testing.T) {
+				setup(t)
+				code, _ := patch(t, "", body)
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Zero(t, restarts)
+			})
+		}
+	})
+
+	t.Run("exports-from-command-line", func(t *testing.T) {
+		setup(t)
+		viper.Set("Origin.ExportVolumes", []string{"/mnt/foo:/foo"})
+		code, _ := patch(t, "", `{"exports": [{"federationPrefix": "/bar", "storagePrefix": "/mnt/bar"}]}`)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
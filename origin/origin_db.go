@@ -19,13 +19,16 @@
 package origin
 
 import (
+	"context"
 	"embed"
 	"time"
 
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 	"gorm.io/gorm"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_utils"
 )
@@ -82,6 +85,11 @@ func ShutdownOriginDB() error {
 	return server_utils.ShutdownDB(db)
 }
 
+// Periodically check the database works, reporting the result as the origin-database component
+func LaunchDBHealthCheck(ctx context.Context, egrp *errgroup.Group) {
+	server_utils.LaunchDBHealthCheck(ctx, egrp, db, metrics.Origin_Database)
+}
+
 func collectionExistsByUUID(uuid string) (bool, error) {
 	var count int64
 	err := db.Model(&GlobusCollection{}).Where("uuid = ?", uuid).Count(&count).Error
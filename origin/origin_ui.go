@@ -165,6 +165,52 @@ func RegisterOriginWebAPI(engine *gin.Engine) error {
 	originWebAPI := engine.Group("/api/v1.0/origin_ui")
 	{
 		originWebAPI.GET("/exports", web_ui.AuthHandler, web_ui.AdminAuthHandler, handleExports)
+		originWebAPI.GET("/config", web_ui.AuthHandler, web_ui.AdminAuthHandler, handleGetOriginConfig)
+		originWebAPI.PATCH("/config", web_ui.AuthHandler, web_ui.AdminAuthHandler, handleUpdateOriginConfig)
+	}
+
+	if param.Origin_EnableVersioning.GetBool() {
+		versionsAPI := originWebAPI.Group("/versions", web_ui.AuthHandler, web_ui.AdminAuthHandler)
+		{
+			versionsAPI.GET("", handleListVersions)
+			versionsAPI.POST("/restore", handleRestoreVersion)
+		}
+	}
+
+	if param.Origin_EnableUploadScan.GetBool() {
+		scansAPI := originWebAPI.Group("/scans", web_ui.AuthHandler, web_ui.AdminAuthHandler)
+		{
+			scansAPI.GET("", handleListUploadScans)
+			scansAPI.GET("/:id", handleGetUploadScan)
+			scansAPI.POST("/:id/release", handleReleaseUploadScan)
+			scansAPI.DELETE("/:id", handleDeleteUploadScan)
+		}
+	}
+
+	if param.Origin_EnableExportScan.GetBool() {
+		exportScanAPI := originWebAPI.Group("/export_scan", web_ui.AuthHandler, web_ui.AdminAuthHandler)
+		{
+			exportScanAPI.GET("", handleGetExportScanStatus)
+			exportScanAPI.GET("/object", handleGetExportScanEntry)
+		}
+	}
+
+	if param.Origin_EnableS3Gateway.GetBool() {
+		s3KeysAPI := originWebAPI.Group("/s3/keys", web_ui.AuthHandler, web_ui.AdminAuthHandler)
+		{
+			s3KeysAPI.GET("", handleListS3AccessKeys)
+			s3KeysAPI.POST("", handleCreateS3AccessKey)
+			s3KeysAPI.DELETE("/:id", handleDeleteS3AccessKey)
+		}
+	}
+
+	tracingAPI := originWebAPI.Group("/tracing", web_ui.AuthHandler, web_ui.AdminAuthHandler)
+	{
+		tracingAPI.GET("", handleListReadTraces)
+		tracingAPI.POST("", handleStartReadTrace)
+		tracingAPI.GET("/:id", handleGetReadTrace)
+		tracingAPI.POST("/:id/stop", handleStopReadTrace)
+		tracingAPI.DELETE("/:id", handleDeleteReadTrace)
 	}
 
 	// Globus backend specific. Config other origin routes above this line
@@ -0,0 +1,387 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// A request to trace reads of the paths matching Pattern for Duration.
+	// Pattern is a path.Match glob; a pattern ending in "/" matches every
+	// object under that prefix.
+	startReadTraceReq struct {
+		Pattern  string `json:"pattern" binding:"required"`
+		Duration string `json:"duration"`
+	}
+
+	// Status of a read-path tracing session
+	ReadTraceSummary struct {
+		Id         string    `json:"id"`
+		Pattern    string    `json:"pattern"`
+		CreatedBy  string    `json:"createdBy"`
+		StartedAt  time.Time `json:"startedAt"`
+		ExpiresAt  time.Time `json:"expiresAt"`
+		Active     bool      `json:"active"`
+		EventCount int       `json:"eventCount"`
+		Dropped    int       `json:"dropped"`
+	}
+
+	// A traced file access, assembled from the open, transfer and close events
+	// sharing a file ID
+	ReadTraceFile struct {
+		FileId     uint32                     `json:"fileId"`
+		Path       string                     `json:"path"`
+		User       *metrics.UserRecord        `json:"user,omitempty"`
+		FileSize   int64                      `json:"fileSize"`
+		OpenedAt   time.Time                  `json:"openedAt"`
+		ClosedAt   *time.Time                 `json:"closedAt,omitempty"`
+		Seconds    float64                    `json:"seconds"`
+		ReadBytes  uint64                     `json:"readBytes"`
+		ReadvBytes uint64                     `json:"readvBytes"`
+		WriteBytes uint64                     `json:"writeBytes"`
+		ReadOps    uint32                     `json:"readOps"`
+		ReadvOps   uint32                     `json:"readvOps"`
+		WriteOps   uint32                     `json:"writeOps"`
+		LastEvent  metrics.FileTraceEventType `json:"lastEvent"`
+	}
+
+	// Everything a tracing session recorded
+	ReadTraceBundle struct {
+		ReadTraceSummary
+		Files  []ReadTraceFile          `json:"files"`
+		Events []metrics.FileTraceEvent `json:"events"`
+	}
+
+	readTraceSession struct {
+		summary ReadTraceSummary
+		events  []metrics.FileTraceEvent
+		// Open files matching the pattern, keyed on their XRootD file ID
+		openFiles map[uint32]string
+	}
+)
+
+const (
+	defaultReadTraceDuration = 10 * time.Minute
+	maxReadTraceDuration     = time.Hour
+	// How long the results of a finished session remain retrievable
+	readTraceRetention = 24 * time.Hour
+	// Caps on the memory a runaway pattern can use
+	maxReadTraceEvents   = 100000
+	maxReadTraceSessions = 10
+
+	readTraceHookName = "origin-read-trace"
+)
+
+var (
+	errReadTraceNotFound = errors.New("tracing session not found")
+
+	readTraceMutex    sync.Mutex
+	readTraceSessions = make(map[string]*readTraceSession)
+)
+
+// Report whether an object path is covered by a tracing pattern
+func matchReadTracePattern(pattern string, objectPath string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(objectPath, pattern)
+	}
+	matched, err := path.Match(pattern, objectPath)
+	return err == nil && matched
+}
+
+// Remove sessions whose results have outlived the retention period and
+// detach from the monitoring stream once no session is collecting.
+// Must be called with readTraceMutex held.
+func pruneReadTraceSessionsLocked(now time.Time) {
+	active := false
+	for id, session := range readTraceSessions {
+		if now.After(session.summary.ExpiresAt.Add(readTraceRetention)) {
+			delete(readTraceSessions, id)
+			continue
+		}
+		if session.summary.Active && now.After(session.summary.ExpiresAt) {
+			session.summary.Active = false
+		}
+		active = active || session.summary.Active
+	}
+	if !active {
+		metrics.SetFileTraceHook(readTraceHookName, nil)
+	}
+}
+
+// Start a tracing session for reads of objects matching pattern
+func startReadTrace(pattern string, duration time.Duration, user string) (ReadTraceSummary, error) {
+	pattern = strings.TrimSpace(pattern)
+	if !strings.HasPrefix(pattern, "/") {
+		return ReadTraceSummary{}, errors.New("the pattern must be an absolute object path")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return ReadTraceSummary{}, errors.Wrap(err, "invalid pattern")
+	}
+	if duration <= 0 {
+		duration = defaultReadTraceDuration
+	} else if duration > maxReadTraceDuration {
+		return ReadTraceSummary{}, errors.Errorf("the duration may not exceed %s", maxReadTraceDuration)
+	}
+
+	readTraceMutex.Lock()
+	defer readTraceMutex.Unlock()
+	now := time.Now()
+	pruneReadTraceSessionsLocked(now)
+	if len(readTraceSessions) >= maxReadTraceSessions {
+		return ReadTraceSummary{}, errors.Errorf("at most %d tracing sessions may be kept; delete a finished session first", maxReadTraceSessions)
+	}
+
+	session := &readTraceSession{
+		summary: ReadTraceSummary{
+			Id:        uuid.NewString(),
+			Pattern:   pattern,
+			CreatedBy: user,
+			StartedAt: now,
+			ExpiresAt: now.Add(duration),
+			Active:    true,
+		},
+		openFiles: make(map[uint32]string),
+	}
+	readTraceSessions[session.summary.Id] = session
+	metrics.SetFileTraceHook(readTraceHookName, recordReadTraceEvent)
+	log.Infof("Read-path tracing session %s for %s started by %s; it ends at %s",
+		session.summary.Id, pattern, user, session.summary.ExpiresAt.Format(time.RFC3339))
+	return session.summary, nil
+}
+
+// Receive a per-file event from the XRootD monitoring stream
+func recordReadTraceEvent(event metrics.FileTraceEvent) {
+	readTraceMutex.Lock()
+	defer readTraceMutex.Unlock()
+	for _, session := range readTraceSessions {
+		if !session.summary.Active || event.Time.After(session.summary.ExpiresAt) {
+			continue
+		}
+		session.record(event)
+	}
+}
+
+func (session *readTraceSession) record(event metrics.FileTraceEvent) {
+	switch event.Type {
+	case metrics.FileTraceOpen:
+		if !matchReadTracePattern(session.summary.Pattern, event.Path) {
+			// The file ID may have been reused by a file we no longer trace
+			delete(session.openFiles, event.FileId)
+			return
+		}
+		session.openFiles[event.FileId] = event.Path
+	default:
+		objectPath, ok := session.openFiles[event.FileId]
+		if !ok {
+			return
+		}
+		event.Path = objectPath
+		if event.Type == metrics.FileTraceClose {
+			delete(session.openFiles, event.FileId)
+		}
+	}
+	if len(session.events) >= maxReadTraceEvents {
+		session.summary.Dropped++
+		return
+	}
+	session.events = append(session.events, event)
+	session.summary.EventCount = len(session.events)
+}
+
+// Assemble the per-file view of a session's events
+func (session *readTraceSession) bundle() ReadTraceBundle {
+	bundle := ReadTraceBundle{
+		ReadTraceSummary: session.summary,
+		Files:            []ReadTraceFile{},
+		Events:           make([]metrics.FileTraceEvent, len(session.events)),
+	}
+	copy(bundle.Events, session.events)
+
+	// File IDs are reused by XRootD, so an access ends at its close event
+	current := make(map[uint32]int)
+	for _, event := range session.events {
+		idx, ok := current[event.FileId]
+		if event.Type == metrics.FileTraceOpen || !ok {
+			bundle.Files = append(bundle.Files, ReadTraceFile{
+				FileId:   event.FileId,
+				Path:     event.Path,
+				FileSize: event.FileSize,
+				OpenedAt: event.Time,
+			})
+			idx = len(bundle.Files) - 1
+			current[event.FileId] = idx
+		}
+		file := &bundle.Files[idx]
+		if event.User != nil {
+			file.User = event.User
+		}
+		file.LastEvent = event.Type
+		if event.Type != metrics.FileTraceOpen {
+			file.ReadBytes = event.ReadBytes
+			file.ReadvBytes = event.ReadvBytes
+			file.WriteBytes = event.WriteBytes
+		}
+		if event.Type == metrics.FileTraceClose {
+			closedAt := event.Time
+			file.ClosedAt = &closedAt
+			file.ReadOps = event.ReadOps
+			file.ReadvOps = event.ReadvOps
+			file.WriteOps = event.WriteOps
+			delete(current, event.FileId)
+		}
+		file.Seconds = event.Time.Sub(file.OpenedAt).Seconds()
+	}
+	return bundle
+}
+
+func listReadTraces() []ReadTraceSummary {
+	readTraceMutex.Lock()
+	defer readTraceMutex.Unlock()
+	pruneReadTraceSessionsLocked(time.Now())
+	summaries := make([]ReadTraceSummary, 0, len(readTraceSessions))
+	for _, session := range readTraceSessions {
+		summaries = append(summaries, session.summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].StartedAt.After(summaries[j].StartedAt)
+	})
+	return summaries
+}
+
+func getReadTrace(id string) (ReadTraceBundle, error) {
+	readTraceMutex.Lock()
+	defer readTraceMutex.Unlock()
+	pruneReadTraceSessionsLocked(time.Now())
+	session, ok := readTraceSessions[id]
+	if !ok {
+		return ReadTraceBundle{}, errReadTraceNotFound
+	}
+	return session.bundle(), nil
+}
+
+// Stop a session early, keeping its results
+func stopReadTrace(id string) (ReadTraceSummary, error) {
+	readTraceMutex.Lock()
+	defer readTraceMutex.Unlock()
+	session, ok := readTraceSessions[id]
+	if !ok {
+		return ReadTraceSummary{}, errReadTraceNotFound
+	}
+	now := time.Now()
+	if session.summary.Active {
+		session.summary.Active = false
+		session.summary.ExpiresAt = now
+	}
+	pruneReadTraceSessionsLocked(now)
+	return session.summary, nil
+}
+
+// Stop a session and discard its results
+func deleteReadTrace(id string) error {
+	readTraceMutex.Lock()
+	defer readTraceMutex.Unlock()
+	if _, ok := readTraceSessions[id]; !ok {
+		return errReadTraceNotFound
+	}
+	delete(readTraceSessions, id)
+	pruneReadTraceSessionsLocked(time.Now())
+	return nil
+}
+
+func handleStartReadTrace(ctx *gin.Context) {
+	req := startReadTraceReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid request data format: " + err.Error(),
+		})
+		return
+	}
+	var duration time.Duration
+	if req.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(req.Duration); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Invalid duration: " + err.Error(),
+			})
+			return
+		}
+	}
+	summary, err := startReadTrace(req.Pattern, duration, ctx.GetString("User"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, summary)
+}
+
+func handleListReadTraces(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, listReadTraces())
+}
+
+func abortReadTraceNotFound(ctx *gin.Context) {
+	ctx.AbortWithStatusJSON(http.StatusNotFound, server_structs.SimpleApiResp{
+		Status: server_structs.RespFailed,
+		Msg:    errReadTraceNotFound.Error(),
+	})
+}
+
+func handleGetReadTrace(ctx *gin.Context) {
+	bundle, err := getReadTrace(ctx.Param("id"))
+	if err != nil {
+		abortReadTraceNotFound(ctx)
+		return
+	}
+	ctx.JSON(http.StatusOK, bundle)
+}
+
+func handleStopReadTrace(ctx *gin.Context) {
+	summary, err := stopReadTrace(ctx.Param("id"))
+	if err != nil {
+		abortReadTraceNotFound(ctx)
+		return
+	}
+	ctx.JSON(http.StatusOK, summary)
+}
+
+func handleDeleteReadTrace(ctx *gin.Context) {
+	if err := deleteReadTrace(ctx.Param("id")); err != nil {
+		abortReadTraceNotFound(ctx)
+		return
+	}
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK})
+}
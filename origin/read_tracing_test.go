@@ -0,0 +1,104 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/metrics"
+)
+
+func TestMatchReadTracePattern(t *testing.T) {
+	assert.True(t, matchReadTracePattern("/data/run1/*.root", "/data/run1/a.root"))
+	assert.False(t, matchReadTracePattern("/data/run1/*.root", "/data/run1/sub/a.root"))
+	assert.True(t, matchReadTracePattern("/data/run1/", "/data/run1/sub/a.root"))
+	assert.False(t, matchReadTracePattern("/data/run1/", "/data/run10/a.root"))
+	assert.True(t, matchReadTracePattern("/data/file", "/data/file"))
+}
+
+func TestReadTraceSessions(t *testing.T) {
+	t.Cleanup(func() {
+		readTraceMutex.Lock()
+		readTraceSessions = make(map[string]*readTraceSession)
+		readTraceMutex.Unlock()
+		metrics.SetFileTraceHook(readTraceHookName, nil)
+	})
+
+	_, err := startReadTrace("relative/*", time.Minute, "admin")
+	assert.Error(t, err)
+	_, err = startReadTrace("/data/[", time.Minute, "admin")
+	assert.Error(t, err)
+	_, err = startReadTrace("/data/*", 2*maxReadTraceDuration, "admin")
+	assert.Error(t, err)
+
+	summary, err := startReadTrace("/data/*", 0, "admin")
+	require.NoError(t, err)
+	assert.True(t, summary.Active)
+	assert.Equal(t, "admin", summary.CreatedBy)
+	assert.WithinDuration(t, summary.StartedAt.Add(defaultReadTraceDuration), summary.ExpiresAt, time.Second)
+
+	user := &metrics.UserRecord{AuthenticationProtocol: "ztn", DN: "subject"}
+	start := time.Now()
+	events := []metrics.FileTraceEvent{
+		{Type: metrics.FileTraceOpen, Time: start, FileId: 1, Path: "/data/a", FileSize: 100, User: user},
+		{Type: metrics.FileTraceOpen, Time: start, FileId: 2, Path: "/other/b"},
+		{Type: metrics.FileTraceTransfer, Time: start.Add(time.Second), FileId: 1, ReadBytes: 50},
+		{Type: metrics.FileTraceTransfer, Time: start.Add(time.Second), FileId: 2, ReadBytes: 10},
+		{Type: metrics.FileTraceClose, Time: start.Add(2 * time.Second), FileId: 1, ReadBytes: 100, ReadOps: 4},
+		// A later transfer on a closed file ID is not part of the traced access
+		{Type: metrics.FileTraceTransfer, Time: start.Add(3 * time.Second), FileId: 1, ReadBytes: 10},
+	}
+	for _, event := range events {
+		recordReadTraceEvent(event)
+	}
+
+	bundle, err := getReadTrace(summary.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 3, bundle.EventCount)
+	require.Len(t, bundle.Events, 3)
+	assert.Equal(t, "/data/a", bundle.Events[1].Path)
+	require.Len(t, bundle.Files, 1)
+	file := bundle.Files[0]
+	assert.Equal(t, "/data/a", file.Path)
+	assert.Equal(t, user, file.User)
+	assert.Equal(t, int64(100), file.FileSize)
+	assert.Equal(t, uint64(100), file.ReadBytes)
+	assert.Equal(t, uint32(4), file.ReadOps)
+	require.NotNil(t, file.ClosedAt)
+	assert.Equal(t, 2.0, file.Seconds)
+	assert.Equal(t, metrics.FileTraceClose, file.LastEvent)
+
+	stopped, err := stopReadTrace(summary.Id)
+	require.NoError(t, err)
+	assert.False(t, stopped.Active)
+	recordReadTraceEvent(metrics.FileTraceEvent{Type: metrics.FileTraceOpen, Time: time.Now().Add(time.Second), FileId: 3, Path: "/data/c"})
+	bundle, err = getReadTrace(summary.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 3, bundle.EventCount)
+
+	require.Len(t, listReadTraces(), 1)
+	require.NoError(t, deleteReadTrace(summary.Id))
+	assert.Empty(t, listReadTraces())
+	_, err = getReadTrace(summary.Id)
+	assert.ErrorIs(t, err, errReadTraceNotFound)
+}
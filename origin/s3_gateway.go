@@ -0,0 +1,770 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/token"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+type (
+	// An S3-protocol front end to the origin's exports.  Each export is a bucket;
+	// requests are authorized with access keys issued by the origin and forwarded
+	// to the origin's own XRootD server with a short-lived token for the request.
+	s3Gateway struct {
+		buckets   map[string]server_utils.OriginExport
+		exports   []server_utils.OriginExport
+		rewriter  *server_utils.PathRewriter
+		originUrl *url.URL
+		client    *http.Client
+		now       func() time.Time
+	}
+
+	// An object or common prefix in a bucket listing
+	s3ListEntry struct {
+		key      string
+		isPrefix bool
+		size     int64
+		modified time.Time
+	}
+
+	s3ErrorResp struct {
+		XMLName   xml.Name `xml:"Error"`
+		Code      string   `xml:"Code"`
+		Message   string   `xml:"Message"`
+		Resource  string   `xml:"Resource"`
+		RequestId string   `xml:"RequestId"`
+	}
+
+	s3Bucket struct {
+		Name         string `xml:"Name"`
+		CreationDate string `xml:"CreationDate"`
+	}
+
+	s3ListBucketsResp struct {
+		XMLName xml.Name   `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+		OwnerId string     `xml:"Owner>ID"`
+		Buckets []s3Bucket `xml:"Buckets>Bucket"`
+	}
+
+	s3Object struct {
+		Key          string `xml:"Key"`
+		LastModified string `xml:"LastModified"`
+		Size         int64  `xml:"Size"`
+		StorageClass string `xml:"StorageClass"`
+	}
+
+	s3CommonPrefix struct {
+		Prefix string `xml:"Prefix"`
+	}
+
+	// The response to both versions of ListObjects; the fields of the other version are omitted
+	s3ListObjectsResp struct {
+		XMLName               xml.Name         `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+		Name                  string           `xml:"Name"`
+		Prefix                string           `xml:"Prefix"`
+		Delimiter             string           `xml:"Delimiter,omitempty"`
+		EncodingType          string           `xml:"EncodingType,omitempty"`
+		MaxKeys               int              `xml:"MaxKeys"`
+		IsTruncated           bool             `xml:"IsTruncated"`
+		Marker                *string          `xml:"Marker"`
+		NextMarker            string           `xml:"NextMarker,omitempty"`
+		KeyCount              *int             `xml:"KeyCount"`
+		ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+		NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+		StartAfter            string           `xml:"StartAfter,omitempty"`
+		Contents              []s3Object       `xml:"Contents"`
+		CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+	}
+
+	s3LocationResp struct {
+		XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+	}
+
+	// The subset of a WebDAV multistatus response used to list collections
+	davMultistatus struct {
+		Responses []struct {
+			Href     string `xml:"href"`
+			Propstat []struct {
+				Prop struct {
+					ContentLength string `xml:"getcontentlength"`
+					LastModified  string `xml:"getlastmodified"`
+					ResourceType  struct {
+						Collection *struct{} `xml:"collection"`
+					} `xml:"resourcetype"`
+				} `xml:"prop"`
+			} `xml:"propstat"`
+		} `xml:"response"`
+	}
+
+	// Checks the digests of an uploaded body as it is read, failing the read at the
+	// end of the body if a digest does not match what the client declared
+	s3DigestReader struct {
+		reader       io.Reader
+		md5          hash.Hash
+		sha256       hash.Hash
+		expectMD5    string // Base64, from the Content-MD5 header
+		expectSHA256 string // Hex, from the X-Amz-Content-Sha256 header
+		mismatch     bool
+	}
+)
+
+const (
+	s3MaxKeys         = 1000
+	s3TimeFormat      = "2006-01-02T15:04:05.000Z"
+	s3TokenLifetime   = 5 * time.Minute
+	s3PropfindRequest = `<?xml version="1.0" encoding="utf-8"?><D:propfind xmlns:D="DAV:"><D:prop><D:getcontentlength/><D:getlastmodified/><D:resourcetype/></D:prop></D:propfind>`
+)
+
+var errS3BadDigest = newS3Error(http.StatusBadRequest, "BadDigest", "the uploaded content does not match the digest the client declared")
+
+// The bucket name of an export: its federation prefix without the leading slash and
+// with the remaining slashes replaced by dots, e.g. /demo/project becomes demo.project
+func s3BucketName(federationPrefix string) string {
+	return strings.ReplaceAll(strings.Trim(path.Clean(federationPrefix), "/"), "/", ".")
+}
+
+func newS3Gateway(exports []server_utils.OriginExport, rewriter *server_utils.PathRewriter, originUrl *url.URL) *s3Gateway {
+	gw := &s3Gateway{
+		buckets:   make(map[string]server_utils.OriginExport, len(exports)),
+		exports:   exports,
+		rewriter:  rewriter,
+		originUrl: originUrl,
+		client:    &http.Client{Transport: metrics.NewTracingTransport("origin.s3Gateway.xrootd", config.GetTransport())},
+		now:       time.Now,
+	}
+	for _, export := range exports {
+		name := s3BucketName(export.FederationPrefix)
+		if name == "" {
+			log.Warningf("The export %s cannot be served by the S3 gateway as a bucket", export.FederationPrefix)
+			continue
+		}
+		if existing, ok := gw.buckets[name]; ok {
+			log.Warningf("The exports %s and %s map to the same S3 bucket %s; only the first is served by the S3 gateway",
+				existing.FederationPrefix, export.FederationPrefix, name)
+			continue
+		}
+		gw.buckets[name] = export
+	}
+	return gw
+}
+
+// Whether p is the prefix itself or lies underneath it
+func s3PathUnder(p, prefix string) bool {
+	return prefix == "/" || p == prefix || strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// The federation path of a key in the bucket for fedPrefix. Keys are joined and cleaned,
+// so a key containing ".." segments that would escape the bucket is refused
+func s3KeyPath(fedPrefix, key string) (string, error) {
+	fedPath := path.Join(fedPrefix, key)
+	if !s3PathUnder(fedPath, fedPrefix) {
+		return "", errS3AccessDenied("the key resolves outside of the bucket")
+	}
+	return fedPath, nil
+}
+
+// The export with the longest federation prefix containing fedPath, falling back to
+// the given export if none does
+func (gw *s3Gateway) exportFor(fedPath string, fallback server_utils.OriginExport) server_utils.OriginExport {
+	found := fallback
+	longest := -1
+	for _, export := range gw.exports {
+		fedPrefix := path.Clean(export.FederationPrefix)
+		if s3PathUnder(fedPath, fedPrefix) && len(fedPrefix) > longest {
+			found = export
+			longest = len(fedPrefix)
+		}
+	}
+	return found
+}
+
+func (gw *s3Gateway) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var s3Err *s3Error
+	if !errors.As(err, &s3Err) {
+		log.Warningf("S3 gateway request %s %s failed: %v", r.Method, r.URL.Path, err)
+		s3Err = newS3Error(http.StatusInternalServerError, "InternalError", "the origin failed to process the request")
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(s3Err.status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_ = xml.NewEncoder(w).Encode(s3ErrorResp{
+		Code:      s3Err.code,
+		Message:   s3Err.message,
+		Resource:  r.URL.Path,
+		RequestId: w.Header().Get("X-Amz-Request-Id"),
+	})
+}
+
+func (gw *s3Gateway) writeXML(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(body); err != nil {
+		log.Warningln("Failed to write S3 gateway response:", err)
+	}
+}
+
+func (gw *s3Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Amz-Request-Id", uuid.NewString())
+	w.Header().Set("Server", "Pelican")
+
+	var accessKey *S3AccessKey
+	_, authSpan := metrics.StartSpan(r.Context(), "origin.s3Gateway.authenticate")
+	_, err := verifySigV4(r, gw.now(), func(accessKeyId string) (string, error) {
+		authSpan.SetAttributes(attribute.String("pelican.s3.access_key", accessKeyId))
+		key, err := getS3AccessKey(accessKeyId)
+		if err != nil {
+			return "", err
+		}
+		accessKey = key
+		return key.Secret, nil
+	})
+	metrics.EndSpan(authSpan, err)
+	if err != nil {
+		gw.writeError(w, r, err)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" {
+		if r.Method != http.MethodGet {
+			gw.writeError(w, r, newS3Error(http.StatusMethodNotAllowed, "MethodNotAllowed", "only listing buckets is supported on the service"))
+			return
+		}
+		gw.listBuckets(w, accessKey)
+		return
+	}
+	export, ok := gw.buckets[bucket]
+	if !ok {
+		gw.writeError(w, r, newS3Error(http.StatusNotFound, "NoSuchBucket", "the bucket does not exist"))
+		return
+	}
+	fedPrefix := path.Clean(export.FederationPrefix)
+	if !s3PathUnder(fedPrefix, accessKey.Prefix) && !s3PathUnder(accessKey.Prefix, fedPrefix) {
+		gw.writeError(w, r, errS3AccessDenied("the access key is not valid for this bucket"))
+		return
+	}
+
+	query := r.URL.Query()
+	if key == "" {
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && query.Has("location"):
+			gw.writeXML(w, s3LocationResp{})
+		case r.Method == http.MethodGet:
+			err = gw.listObjects(w, r, bucket, export, accessKey)
+		default:
+			err = newS3Error(http.StatusNotImplemented, "NotImplemented", "the S3 gateway only supports listing buckets")
+		}
+	} else {
+		fedPath, keyErr := s3KeyPath(fedPrefix, key)
+		if keyErr != nil {
+			err = keyErr
+		} else if !s3PathUnder(fedPath, accessKey.Prefix) {
+			err = errS3AccessDenied("the access key is not valid for this object")
+		} else if fedPath, export, err = gw.rewritePath(fedPath, export); err == nil {
+			// Capabilities come from the export actually holding the object, which may be
+			// nested inside the bucket's export
+			export = gw.exportFor(fedPath, export)
+			switch {
+			case r.Method == http.MethodGet || r.Method == http.MethodHead:
+				err = gw.getObject(w, r, fedPath, export, accessKey)
+			case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") == "" && !query.Has("uploadId"):
+				err = gw.putObject(w, r, fedPath, export, accessKey)
+			default:
+				err = newS3Error(http.StatusNotImplemented, "NotImplemented",
+					"the S3 gateway only supports GetObject, HeadObject, and single-part PutObject on objects")
+			}
+		}
+	}
+	if err != nil {
+		gw.writeError(w, r, err)
+	}
+}
+
+func (gw *s3Gateway) listBuckets(w http.ResponseWriter, accessKey *S3AccessKey) {
+	resp := s3ListBucketsResp{OwnerId: accessKey.AccessKeyId}
+	created := accessKey.CreatedAt.UTC().Format(s3TimeFormat)
+	for name, export := range gw.buckets {
+		fedPrefix := path.Clean(export.FederationPrefix)
+		if s3PathUnder(fedPrefix, accessKey.Prefix) || s3PathUnder(accessKey.Prefix, fedPrefix) {
+			resp.Buckets = append(resp.Buckets, s3Bucket{Name: name, CreationDate: created})
+		}
+	}
+	sort.Slice(resp.Buckets, func(i, j int) bool { return resp.Buckets[i].Name < resp.Buckets[j].Name })
+	gw.writeXML(w, resp)
+}
+
+// Apply Origin.PathRewrites to the federation path of an object, returning the path
+// and export that requests for the object are sent to
+func (gw *s3Gateway) rewritePath(fedPath string, export server_utils.OriginExport) (string, server_utils.OriginExport, error) {
+	if !gw.rewriter.Enabled() {
+		return fedPath, export, nil
+	}
+	result, err := gw.rewriter.Resolve(fedPath, gw.exports)
+	if err != nil {
+		log.Debugln("S3 gateway refused a request:", err)
+		return "", export, errS3AccessDenied("the object's path is rewritten outside of the origin's exports")
+	}
+	if len(result.Steps) > 0 {
+		log.Debugf("S3 gateway rewrote %s to %s", fedPath, result.RewrittenPath)
+	}
+	return result.RewrittenPath, *result.Export, nil
+}
+
+// Create a token for the origin's XRootD server allowing the access key's request on the path
+func (gw *s3Gateway) xrootdToken(fedPath string, write bool, accessKey *S3AccessKey) (string, error) {
+	issuerUrl, err := config.GetServerIssuerURL()
+	if err != nil {
+		return "", err
+	}
+	tokenCfg := token.NewWLCGToken()
+	tokenCfg.Lifetime = s3TokenLifetime
+	tokenCfg.Issuer = issuerUrl
+	tokenCfg.Subject = "s3:" + accessKey.AccessKeyId
+	tokenCfg.AddAudiences(config.GetServerAudience())
+	if write {
+		tokenCfg.AddResourceScopes(token_scopes.NewResourceScope(token_scopes.Storage_Modify, fedPath))
+	} else {
+		tokenCfg.AddResourceScopes(token_scopes.NewResourceScope(token_scopes.Storage_Read, fedPath))
+	}
+	return tokenCfg.CreateToken()
+}
+
+// Build a request to the origin's XRootD server for the path
+func (gw *s3Gateway) xrootdRequest(ctx context.Context, method string, fedPath string, body io.Reader, export server_utils.OriginExport, accessKey *S3AccessKey) (*http.Request, error) {
+	target := *gw.originUrl
+	target.Path = fedPath
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	write := method == http.MethodPut
+	// Public exports do not trust the origin's issuer for reads
+	if write || !export.CapabilitiesAt(time.Now()).PublicReads {
+		tok, err := gw.xrootdToken(fedPath, write, accessKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create a token for the request")
+		}
+		req.Header.Set("Authorization", "Bearer "+tok)
+	}
+	return req, nil
+}
+
+// Translate an error status from XRootD into an S3 error
+func s3ErrorFromStatus(status int, notFoundCode string) *s3Error {
+	switch status {
+	case http.StatusNotFound:
+		return newS3Error(http.StatusNotFound, notFoundCode, "the object does not exist")
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errS3AccessDenied("the origin denied access to the object")
+	case http.StatusRequestedRangeNotSatisfiable:
+		return newS3Error(status, "InvalidRange", "the requested range is not satisfiable")
+	default:
+		return newS3Error(http.StatusBadGateway, "InternalError", fmt.Sprintf("the origin's storage returned status %d", status))
+	}
+}
+
+// Stat a path through WebDAV, listing its children if depth is 1
+func (gw *s3Gateway) propfind(ctx context.Context, fedPath string, depth int, export server_utils.OriginExport, accessKey *S3AccessKey) ([]s3ListEntry, error) {
+	req, err := gw.xrootdRequest(ctx, "PROPFIND", fedPath, strings.NewReader(s3PropfindRequest), export, accessKey)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", strconv.Itoa(depth))
+	req.Header.Set("Content-Type", "application/xml")
+	resp, err := gw.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query the origin's storage")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, s3ErrorFromStatus(resp.StatusCode, "NoSuchKey")
+	}
+	return parseDavMultistatus(resp.Body)
+}
+
+// Parse a WebDAV multistatus response into entries keyed by their paths
+func parseDavMultistatus(body io.Reader) ([]s3ListEntry, error) {
+	multistatus := davMultistatus{}
+	if err := xml.NewDecoder(body).Decode(&multistatus); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the origin's listing")
+	}
+	entries := make([]s3ListEntry, 0, len(multistatus.Responses))
+	for _, resp := range multistatus.Responses {
+		href, err := url.PathUnescape(resp.Href)
+		if err != nil {
+			continue
+		}
+		if hrefUrl, err := url.Parse(href); err == nil && hrefUrl.Host != "" {
+			href = hrefUrl.Path
+		}
+		entry := s3ListEntry{key: path.Clean("/" + href)}
+		for _, propstat := range resp.Propstat {
+			prop := propstat.Prop
+			if prop.ResourceType.Collection != nil {
+				entry.isPrefix = true
+			}
+			if size, err := strconv.ParseInt(prop.ContentLength, 10, 64); err == nil {
+				entry.size = size
+			}
+			if modified, err := http.ParseTime(prop.LastModified); err == nil {
+				entry.modified = modified
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (gw *s3Gateway) getObject(w http.ResponseWriter, r *http.Request, fedPath string, export server_utils.OriginExport, accessKey *S3AccessKey) error {
+	if r.Method == http.MethodHead {
+		entries, err := gw.propfind(r.Context(), fedPath, 0, export, accessKey)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 || entries[0].isPrefix {
+			return newS3Error(http.StatusNotFound, "NoSuchKey", "the object does not exist")
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(entries[0].size, 10))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Accept-Ranges", "bytes")
+		if !entries[0].modified.IsZero() {
+			w.Header().Set("Last-Modified", entries[0].modified.UTC().Format(http.TimeFormat))
+		}
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	req, err := gw.xrootdRequest(r.Context(), http.MethodGet, fedPath, nil, export, accessKey)
+	if err != nil {
+		return err
+	}
+	for _, header := range []string{"Range", "If-Modified-Since", "If-Unmodified-Since", "If-Match", "If-None-Match"} {
+		if value := r.Header.Get(header); value != "" {
+			req.Header.Set(header, value)
+		}
+	}
+	resp, err := gw.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to read from the origin's storage")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusNotModified {
+		return s3ErrorFromStatus(resp.StatusCode, "NoSuchKey")
+	}
+	for _, header := range []string{"Content-Length", "Content-Range", "Last-Modified", "ETag", "Accept-Ranges"} {
+		if value := resp.Header.Get(header); value != "" {
+			w.Header().Set(header, value)
+		}
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(resp.StatusCode)
+	body := metrics.TraceBody(r.Context(), "origin.s3Gateway.streamResponse", resp.Body)
+	defer body.Close()
+	if _, err := io.Copy(w, body); err != nil {
+		log.Debugf("S3 gateway failed to send %s to the client: %v", fedPath, err)
+	}
+	return nil
+}
+
+func (d *s3DigestReader) Read(p []byte) (int, error) {
+	n, err := d.reader.Read(p)
+	d.md5.Write(p[:n])
+	d.sha256.Write(p[:n])
+	if err == io.EOF {
+		if (d.expectMD5 != "" && base64.StdEncoding.EncodeToString(d.md5.Sum(nil)) != d.expectMD5) ||
+			(d.expectSHA256 != "" && hex.EncodeToString(d.sha256.Sum(nil)) != strings.ToLower(d.expectSHA256)) {
+			d.mismatch = true
+			return n, errS3BadDigest
+		}
+	}
+	return n, err
+}
+
+func (gw *s3Gateway) putObject(w http.ResponseWriter, r *http.Request, fedPath string, export server_utils.OriginExport, accessKey *S3AccessKey) error {
+	if !accessKey.Writable || !export.CapabilitiesAt(time.Now()).Writes {
+		return errS3AccessDenied("the access key does not allow writes to this bucket")
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if strings.HasPrefix(payloadHash, "STREAMING-") {
+		return newS3Error(http.StatusNotImplemented, "NotImplemented", "chunked payload signing is not supported; sign the payload or send it unsigned")
+	}
+	if r.ContentLength < 0 {
+		return newS3Error(http.StatusLengthRequired, "MissingContentLength", "the Content-Length header is required")
+	}
+	body := &s3DigestReader{
+		reader:    r.Body,
+		md5:       md5.New(),
+		sha256:    sha256.New(),
+		expectMD5: r.Header.Get("Content-MD5"),
+	}
+	if payloadHash != sigV4UnsignedPayload {
+		body.expectSHA256 = payloadHash
+	}
+
+	req, err := gw.xrootdRequest(r.Context(), http.MethodPut, fedPath, body, export, accessKey)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = r.ContentLength
+	resp, err := gw.client.Do(req)
+	if err != nil {
+		// The transport does not necessarily preserve the error from reading the body
+		if body.mismatch {
+			return errS3BadDigest
+		}
+		return errors.Wrap(err, "failed to write to the origin's storage")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return s3ErrorFromStatus(resp.StatusCode, "NoSuchKey")
+	}
+	// Like S3, the ETag of a single-part upload is the MD5 of its content
+	w.Header().Set("ETag", `"`+hex.EncodeToString(body.md5.Sum(nil))+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// List the entries under a directory of the export, recursing into subdirectories if
+// recursive.  Keys are relative to the export.
+func (gw *s3Gateway) listDirectory(ctx context.Context, dir string, recursive bool, export server_utils.OriginExport, accessKey *S3AccessKey) ([]s3ListEntry, error) {
+	fedPrefix := path.Clean(export.FederationPrefix)
+	var result []s3ListEntry
+	pending := []string{dir}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		entries, err := gw.propfind(ctx, current, 1, export, accessKey)
+		if err != nil {
+			var s3Err *s3Error
+			// Listing a prefix that does not exist returns nothing, as in S3
+			if errors.As(err, &s3Err) && s3Err.code == "NoSuchKey" {
+				continue
+			}
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.key == current || !s3PathUnder(entry.key, current) {
+				continue
+			}
+			if entry.isPrefix && recursive {
+				pending = append(pending, entry.key)
+				continue
+			}
+			entry.key = strings.TrimPrefix(strings.TrimPrefix(entry.key, fedPrefix), "/")
+			if entry.isPrefix {
+				entry.key += "/"
+			}
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// Select one page of a listing: the entries with the prefix, sorted by key, after
+// startAfter, and at most maxKeys of them.  Returns whether the listing was
+// truncated and the last key of the page.
+func paginateS3Listing(entries []s3ListEntry, prefix string, startAfter string, maxKeys int) (page []s3ListEntry, truncated bool, lastKey string) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.key, prefix) || entry.key <= startAfter {
+			continue
+		}
+		if len(page) == maxKeys {
+			truncated = true
+			break
+		}
+		page = append(page, entry)
+		lastKey = entry.key
+	}
+	return
+}
+
+func (gw *s3Gateway) listObjects(w http.ResponseWriter, r *http.Request, bucket string, export server_utils.OriginExport, accessKey *S3AccessKey) error {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	if delimiter != "" && delimiter != "/" {
+		return newS3Error(http.StatusBadRequest, "InvalidArgument", "the only supported delimiter is /")
+	}
+	maxKeys := s3MaxKeys
+	if value := query.Get("max-keys"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return newS3Error(http.StatusBadRequest, "InvalidArgument", "max-keys must be a non-negative integer")
+		}
+		maxKeys = min(parsed, s3MaxKeys)
+	}
+	encodingType := query.Get("encoding-type")
+	encode := func(s string) string {
+		if encodingType == "url" {
+			return sigV4Escape(s, false)
+		}
+		return s
+	}
+
+	resp := s3ListObjectsResp{
+		Name:         bucket,
+		Prefix:       encode(prefix),
+		Delimiter:    encode(delimiter),
+		EncodingType: encodingType,
+		MaxKeys:      maxKeys,
+	}
+	startAfter := ""
+	isV2 := query.Get("list-type") == "2"
+	if isV2 {
+		resp.StartAfter = encode(query.Get("start-after"))
+		startAfter = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				return newS3Error(http.StatusBadRequest, "InvalidArgument", "the continuation token is invalid")
+			}
+			resp.ContinuationToken = token
+			startAfter = max(startAfter, string(decoded))
+		}
+	} else {
+		marker := query.Get("marker")
+		resp.Marker = &marker
+		startAfter = marker
+	}
+
+	fedPrefix := path.Clean(export.FederationPrefix)
+	dir := fedPrefix
+	if idx := strings.LastIndex(prefix, "/"); idx >= 0 {
+		var err error
+		if dir, err = s3KeyPath(fedPrefix, prefix[:idx]); err != nil {
+			return err
+		}
+	}
+	if !s3PathUnder(dir, accessKey.Prefix) {
+		return errS3AccessDenied("the access key is not valid for listing this prefix")
+	}
+	entries, err := gw.listDirectory(r.Context(), dir, delimiter == "", export, accessKey)
+	if err != nil {
+		return err
+	}
+
+	page, truncated, lastKey := paginateS3Listing(entries, prefix, startAfter, maxKeys)
+	resp.IsTruncated = truncated
+	for _, entry := range page {
+		if entry.isPrefix {
+			resp.CommonPrefixes = append(resp.CommonPrefixes, s3CommonPrefix{Prefix: encode(entry.key)})
+			continue
+		}
+		resp.Contents = append(resp.Contents, s3Object{
+			Key:          encode(entry.key),
+			LastModified: entry.modified.UTC().Format(s3TimeFormat),
+			Size:         entry.size,
+			StorageClass: "STANDARD",
+		})
+	}
+	if isV2 {
+		keyCount := len(page)
+		resp.KeyCount = &keyCount
+		if truncated {
+			resp.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(lastKey))
+		}
+	} else if truncated {
+		resp.NextMarker = encode(lastKey)
+	}
+	gw.writeXML(w, resp)
+	return nil
+}
+
+// Start the S3 gateway on Origin.S3GatewayPort if Origin.EnableS3Gateway is set
+func LaunchS3Gateway(ctx context.Context, egrp *errgroup.Group) error {
+	if !param.Origin_EnableS3Gateway.GetBool() {
+		return nil
+	}
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		return err
+	}
+	rewriter, err := server_utils.GetOriginPathRewriter()
+	if err != nil {
+		return err
+	}
+	originUrl, err := url.Parse(param.Origin_Url.GetString())
+	if err != nil {
+		return errors.Wrap(err, "failed to parse Origin.Url for the S3 gateway")
+	}
+	cert, err := tls.LoadX509KeyPair(param.Server_TLSCertificateChain.GetString(), param.Server_TLSKey.GetString())
+	if err != nil {
+		return errors.Wrap(err, "failed to load the TLS certificate for the S3 gateway")
+	}
+
+	addr := net.JoinHostPort(param.Server_WebHost.GetString(), strconv.Itoa(param.Origin_S3GatewayPort.GetInt()))
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s for the S3 gateway", addr)
+	}
+	server := &http.Server{
+		Handler:   metrics.TraceHandler("origin.s3Gateway", newS3Gateway(exports, rewriter, originUrl)),
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+	log.Infoln("Starting the S3 gateway at", ln.Addr().String())
+
+	egrp.Go(func() error {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	})
+	egrp.Go(func() error {
+		if err := server.ServeTLS(ln, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return errors.Wrap(err, "S3 gateway failed")
+		}
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,232 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+type (
+	// An access key for the S3 gateway, scoped to a path under the origin's exports
+	S3AccessKey struct {
+		AccessKeyId string `gorm:"primaryKey" json:"accessKeyId"`
+		// The secret, encrypted with the server's key; SigV4 needs the plaintext to verify signatures
+		Secret      string     `gorm:"not null" json:"-"`
+		Prefix      string     `gorm:"not null" json:"prefix"`
+		Writable    bool       `gorm:"not null;default:false" json:"writable"`
+		Description string     `gorm:"not null;default:''" json:"description"`
+		CreatedBy   string     `gorm:"not null;default:''" json:"createdBy"`
+		ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+		CreatedAt   time.Time  `json:"createdAt"`
+	}
+
+	createS3AccessKeyReq struct {
+		Prefix      string `json:"prefix" binding:"required"`
+		Writable    bool   `json:"writable"`
+		Description string `json:"description"`
+		Lifetime    string `json:"lifetime"` // A duration such as "720h"; keys without one do not expire
+	}
+
+	// A newly created access key, the only time its secret is returned
+	createS3AccessKeyResp struct {
+		S3AccessKey
+		SecretAccessKey string `json:"secretAccessKey"`
+	}
+)
+
+// The prefix of the access key ids issued by the origin, to tell them apart from AWS keys
+const s3AccessKeyIdPrefix = "PEL"
+
+var errS3AccessKeyNotFound = errors.New("access key not found")
+
+func (S3AccessKey) TableName() string {
+	return "s3_access_keys"
+}
+
+// Find the export that contains the federation path
+func exportForPath(exports []server_utils.OriginExport, fedPath string) *server_utils.OriginExport {
+	for idx := range exports {
+		prefix := path.Clean(exports[idx].FederationPrefix)
+		if fedPath == prefix || strings.HasPrefix(fedPath, strings.TrimSuffix(prefix, "/")+"/") {
+			return &exports[idx]
+		}
+	}
+	return nil
+}
+
+// Issue a new access key for the prefix, which must be within one of the origin's exports
+func createS3AccessKey(prefix string, writable bool, description string, lifetime time.Duration, createdBy string) (*createS3AccessKeyResp, error) {
+	prefix = path.Clean("/" + prefix)
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		return nil, err
+	}
+	export := exportForPath(exports, prefix)
+	if export == nil {
+		return nil, errors.Errorf("prefix %s is not within any of the origin's exports", prefix)
+	}
+	if writable && !export.Capabilities.Writes {
+		return nil, errors.Errorf("the export %s containing prefix %s does not allow writes", export.FederationPrefix, prefix)
+	}
+	if lifetime < 0 {
+		return nil, errors.New("the lifetime of an access key must not be negative")
+	}
+
+	idBytes := make([]byte, 10)
+	secretBytes := make([]byte, 30)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, errors.Wrap(err, "failed to generate the access key id")
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return nil, errors.Wrap(err, "failed to generate the secret access key")
+	}
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	encrypted, err := config.EncryptString(secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt the secret access key")
+	}
+
+	key := S3AccessKey{
+		AccessKeyId: s3AccessKeyIdPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(idBytes)[:17],
+		Secret:      encrypted,
+		Prefix:      prefix,
+		Writable:    writable,
+		Description: description,
+		CreatedBy:   createdBy,
+	}
+	if lifetime > 0 {
+		expiresAt := time.Now().Add(lifetime)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := db.Create(&key).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to store the access key")
+	}
+	return &createS3AccessKeyResp{S3AccessKey: key, SecretAccessKey: secret}, nil
+}
+
+// Look up an unexpired access key, with its secret decrypted
+func getS3AccessKey(accessKeyId string) (*S3AccessKey, error) {
+	key := S3AccessKey{}
+	if err := db.First(&key, "access_key_id = ?", accessKeyId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errS3AccessKeyNotFound
+		}
+		return nil, err
+	}
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now()) {
+		return nil, errS3AccessKeyNotFound
+	}
+	secret, err := config.DecryptString(key.Secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt the secret access key")
+	}
+	key.Secret = secret
+	return &key, nil
+}
+
+func listS3AccessKeys() ([]S3AccessKey, error) {
+	keys := []S3AccessKey{}
+	if err := db.Order("created_at").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func deleteS3AccessKey(accessKeyId string) error {
+	result := db.Delete(&S3AccessKey{}, "access_key_id = ?", accessKeyId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errS3AccessKeyNotFound
+	}
+	return nil
+}
+
+func handleListS3AccessKeys(ctx *gin.Context) {
+	keys, err := listS3AccessKeys()
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to list the access keys: " + err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, keys)
+}
+
+func handleCreateS3AccessKey(ctx *gin.Context) {
+	req := createS3AccessKeyReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid request data format: " + err.Error(),
+		})
+		return
+	}
+	var lifetime time.Duration
+	if req.Lifetime != "" {
+		var err error
+		if lifetime, err = time.ParseDuration(req.Lifetime); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Invalid lifetime: " + err.Error(),
+			})
+			return
+		}
+	}
+	key, err := createS3AccessKey(req.Prefix, req.Writable, req.Description, lifetime, ctx.GetString("User"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, key)
+}
+
+func handleDeleteS3AccessKey(ctx *gin.Context) {
+	if err := deleteS3AccessKey(ctx.Param("id")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errS3AccessKeyNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.AbortWithStatusJSON(status, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK})
+}
@@ -0,0 +1,231 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// The parsed Authorization header of an AWS Signature Version 4 request
+	sigV4Auth struct {
+		accessKeyId   string
+		date          string // The date of the credential scope, YYYYMMDD
+		region        string
+		service       string
+		signedHeaders []string
+		signature     string
+	}
+
+	// An error to report to an S3 client, with the S3 error code
+	s3Error struct {
+		status  int
+		code    string
+		message string
+	}
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	// The payload hash of requests that do not sign their body
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// How far the request time may be from the server's clock
+	sigV4MaxSkew = 15 * time.Minute
+)
+
+func (e *s3Error) Error() string {
+	return e.code + ": " + e.message
+}
+
+func newS3Error(status int, code, message string) *s3Error {
+	return &s3Error{status: status, code: code, message: message}
+}
+
+func errS3AccessDenied(message string) *s3Error {
+	return newS3Error(http.StatusForbidden, "AccessDenied", message)
+}
+
+// Parse an Authorization header of the form
+//
+//	AWS4-HMAC-SHA256 Credential=AKID/20130524/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-date, Signature=abc...
+func parseSigV4Auth(header string) (auth sigV4Auth, err error) {
+	rest, found := strings.CutPrefix(header, sigV4Algorithm+" ")
+	if !found {
+		err = errors.New("only AWS Signature Version 4 (" + sigV4Algorithm + ") is supported")
+		return
+	}
+	for _, field := range strings.Split(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			err = errors.Errorf("malformed authorization field %q", field)
+			return
+		}
+		switch key {
+		case "Credential":
+			parts := strings.Split(value, "/")
+			if len(parts) != 5 || parts[4] != "aws4_request" {
+				err = errors.Errorf("malformed credential %q", value)
+				return
+			}
+			auth.accessKeyId, auth.date, auth.region, auth.service = parts[0], parts[1], parts[2], parts[3]
+		case "SignedHeaders":
+			auth.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			auth.signature = value
+		}
+	}
+	if auth.accessKeyId == "" || len(auth.signedHeaders) == 0 || auth.signature == "" {
+		err = errors.New("the authorization header is missing the credential, signed headers, or signature")
+	}
+	return
+}
+
+// Percent-encode a string as AWS canonical requests require: everything but the
+// unreserved characters is encoded, and "/" is kept only if encodeSlash is false
+func sigV4Escape(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' || (b == '/' && !encodeSlash) {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+// Build the canonical request that the client signed
+func sigV4CanonicalRequest(req *http.Request, signedHeaders []string, payloadHash string) string {
+	canonicalUri := sigV4Escape(req.URL.Path, false)
+	if canonicalUri == "" {
+		canonicalUri = "/"
+	}
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	queryParts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			queryParts = append(queryParts, sigV4Escape(key, true)+"="+sigV4Escape(value, true))
+		}
+	}
+
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		var value string
+		if name == "host" {
+			value = req.Host
+		} else {
+			value = strings.Join(req.Header.Values(name), ",")
+		}
+		headers.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		canonicalUri,
+		strings.Join(queryParts, "&"),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Compute the signature of a canonical request with a secret key
+func sigV4Signature(secret string, auth sigV4Auth, amzDate string, canonicalRequest string) string {
+	scope := strings.Join([]string{auth.date, auth.region, auth.service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secret), auth.date)
+	key = hmacSHA256(key, auth.region)
+	key = hmacSHA256(key, auth.service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// Verify the signature of an S3 request, looking up the secret of the access key the
+// request claims.  Returns the access key id on success.
+func verifySigV4(req *http.Request, now time.Time, lookupSecret func(accessKeyId string) (string, error)) (string, error) {
+	header := req.Header.Get("Authorization")
+	if header == "" {
+		return "", errS3AccessDenied("Anonymous access is not supported; sign requests with an access key issued by the origin")
+	}
+	auth, err := parseSigV4Auth(header)
+	if err != nil {
+		return "", newS3Error(http.StatusBadRequest, "AuthorizationHeaderMalformed", err.Error())
+	}
+	if auth.service != "s3" {
+		return "", newS3Error(http.StatusBadRequest, "AuthorizationHeaderMalformed", "the credential scope must be for the s3 service")
+	}
+	if !sort.StringsAreSorted(auth.signedHeaders) || !slices.Contains(auth.signedHeaders, "host") {
+		return "", newS3Error(http.StatusBadRequest, "AuthorizationHeaderMalformed", "the signed headers must be sorted and include host")
+	}
+
+	amzDate := req.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		return "", errS3AccessDenied("the request is missing the X-Amz-Date header")
+	}
+	reqTime, err := time.Parse(sigV4TimeFormat, amzDate)
+	if err != nil || !strings.HasPrefix(amzDate, auth.date) {
+		return "", errS3AccessDenied("the X-Amz-Date header is malformed or does not match the credential scope")
+	}
+	if skew := now.Sub(reqTime); skew > sigV4MaxSkew || skew < -sigV4MaxSkew {
+		return "", newS3Error(http.StatusForbidden, "RequestTimeTooSkewed", "the difference between the request time and the server's time is too large")
+	}
+
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return "", errS3AccessDenied("the request is missing the X-Amz-Content-Sha256 header")
+	}
+
+	secret, err := lookupSecret(auth.accessKeyId)
+	if err != nil {
+		return "", newS3Error(http.StatusForbidden, "InvalidAccessKeyId", "the access key does not exist or has expired")
+	}
+	expected := sigV4Signature(secret, auth, amzDate, sigV4CanonicalRequest(req, auth.signedHeaders, payloadHash))
+	if !hmac.Equal([]byte(expected), []byte(auth.signature)) {
+		return "", newS3Error(http.StatusForbidden, "SignatureDoesNotMatch", "the request signature does not match the signature computed with the access key")
+	}
+	return auth.accessKeyId, nil
+}
@@ -0,0 +1,753 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	UploadScanStatus string
+
+	// The scan of an object uploaded to the origin
+	UploadScan struct {
+		Id         string           `json:"id"`
+		Path       string           `json:"path"`
+		Size       int64            `json:"size"`
+		Status     UploadScanStatus `json:"status"`
+		Reason     string           `json:"reason,omitempty"`
+		UploadedAt time.Time        `json:"uploadedAt"`
+		ScannedAt  *time.Time       `json:"scannedAt,omitempty"`
+	}
+
+	// A scanner decides whether an uploaded file may enter the namespace.  It returns
+	// a non-empty reason if the file must be quarantined, or an error if the file
+	// could not be scanned.
+	uploadScanner interface {
+		scan(ctx context.Context, filePath string) (reason string, err error)
+	}
+
+	// Scans files by running a command, following the exit status convention of clamscan
+	execUploadScanner struct {
+		command []string
+	}
+
+	// Scans files with an ICAP (RFC 3507) RESPMOD service
+	icapUploadScanner struct {
+		serviceUrl *url.URL
+	}
+
+	uploadScans struct {
+		scanner uploadScanner
+		root    string
+		timeout time.Duration
+		queue   chan string
+
+		// Serializes moving objects in and out of the namespace
+		mutex   sync.Mutex
+		records map[string]*UploadScan
+	}
+)
+
+const (
+	// Withheld from the namespace, waiting for a scan
+	UploadScanPending UploadScanStatus = "pending"
+	// Withheld from the namespace while being scanned
+	UploadScanScanning UploadScanStatus = "scanning"
+	// Passed the scan and placed in the namespace
+	UploadScanClean UploadScanStatus = "clean"
+	// Failed the scan and quarantined
+	UploadScanQuarantined UploadScanStatus = "quarantined"
+	// Could not be scanned and quarantined
+	UploadScanFailed UploadScanStatus = "failed"
+	// Placed in the namespace from quarantine by an administrator
+	UploadScanReleased UploadScanStatus = "released"
+	// Discarded because the object was written again before the scan completed
+	UploadScanSuperseded UploadScanStatus = "superseded"
+)
+
+const (
+	uploadScanStagingDir    = "staging"
+	uploadScanQuarantineDir = "quarantine"
+	uploadScanRecordExt     = ".json"
+
+	uploadScanWorkers   = 4
+	uploadScanQueueSize = 1024
+	// How long the results of scans that placed an object in the namespace remain listed
+	uploadScanRetention = 24 * time.Hour
+	// Cap on the scanner output recorded as a quarantine reason
+	maxUploadScanReason = 1024
+	defaultICAPPort     = "1344"
+	uploadScanWaitDelay = 10 * time.Second
+)
+
+var (
+	errUploadScanNotFound = errors.New("upload scan not found")
+	errUploadScanConflict = errors.New("upload scan conflict")
+
+	activeScans atomic.Pointer[uploadScans]
+)
+
+// The upload scanner, or nil if upload scanning is disabled
+func activeUploadScans() *uploadScans {
+	return activeScans.Load()
+}
+
+func (status UploadScanStatus) inQuarantine() bool {
+	return status == UploadScanQuarantined || status == UploadScanFailed
+}
+
+func (status UploadScanStatus) withheld() bool {
+	return status == UploadScanPending || status == UploadScanScanning
+}
+
+func truncateScanReason(reason string) string {
+	reason = strings.TrimSpace(reason)
+	if len(reason) > maxUploadScanReason {
+		reason = reason[:maxUploadScanReason] + "..."
+	}
+	return reason
+}
+
+func (scanner *execUploadScanner) scan(ctx context.Context, filePath string) (string, error) {
+	args := append(slices.Clone(scanner.command[1:]), filePath)
+	cmd := exec.CommandContext(ctx, scanner.command[0], args...)
+	// Don't wait on processes the scanner left holding its output open once it is killed
+	cmd.WaitDelay = uploadScanWaitDelay
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return "", errors.Wrapf(ctx.Err(), "%s did not complete", scanner.command[0])
+	}
+	if err == nil {
+		return "", nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		if reason := truncateScanReason(string(output)); reason != "" {
+			return reason, nil
+		}
+		return "rejected by " + scanner.command[0], nil
+	}
+	return "", errors.Wrapf(err, "%s failed: %s", scanner.command[0], truncateScanReason(string(output)))
+}
+
+// Write the body of an ICAP request using the chunked transfer coding
+func writeICAPChunks(writer *bufio.Writer, reader io.Reader) error {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			if _, werr := fmt.Fprintf(writer, "%x\r\n", n); werr != nil {
+				return werr
+			}
+			if _, werr := writer.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			if _, werr := writer.WriteString("\r\n"); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	if _, err := writer.WriteString("0\r\n\r\n"); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func (scanner *icapUploadScanner) scan(ctx context.Context, filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	address := scanner.serviceUrl.Host
+	if scanner.serviceUrl.Port() == "" {
+		address = net.JoinHostPort(scanner.serviceUrl.Hostname(), defaultICAPPort)
+	}
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to connect to the ICAP service")
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	// The object is presented to the service as the body of an HTTP response
+	resHdr := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\nContent-Length: %d\r\n\r\n", info.Size())
+	writer := bufio.NewWriter(conn)
+	_, err = fmt.Fprintf(writer, "RESPMOD %s ICAP/1.0\r\nHost: %s\r\nAllow: 204\r\nEncapsulated: res-hdr=0, res-body=%d\r\n\r\n%s",
+		scanner.serviceUrl.String(), scanner.serviceUrl.Host, len(resHdr), resHdr)
+	if err == nil {
+		err = writeICAPChunks(writer, file)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to send the object to the ICAP service")
+	}
+
+	reader := textproto.NewReader(bufio.NewReader(conn))
+	statusLine, err := reader.ReadLine()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the ICAP service response")
+	}
+	fields := strings.Fields(statusLine)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "ICAP/") {
+		return "", errors.Errorf("invalid ICAP status line %q", statusLine)
+	}
+	code, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "", errors.Errorf("invalid ICAP status line %q", statusLine)
+	}
+	headers, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the ICAP service response headers")
+	}
+
+	switch code {
+	case http.StatusNoContent:
+		return "", nil
+	case http.StatusOK:
+		for _, name := range []string{"X-Infection-Found", "X-Violations-Found", "X-Virus-ID"} {
+			if value := headers.Get(name); value != "" {
+				return truncateScanReason(name + ": " + value), nil
+			}
+		}
+		// The service replaced the object, typically with a notice that it was blocked
+		return "object modified by the ICAP service", nil
+	default:
+		return "", errors.Errorf("ICAP service responded with %q", statusLine)
+	}
+}
+
+// Move a file, copying it if the destination is on another filesystem
+func moveFile(src string, dest string) error {
+	err := os.Rename(src, dest)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err = copyFileAtomic(src, dest, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err = preserveOwnership(info, dest); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+func (scans *uploadScans) filePath(dir string, id string) string {
+	return filepath.Join(scans.root, dir, id)
+}
+
+func (scans *uploadScans) recordPath(dir string, id string) string {
+	return filepath.Join(scans.root, dir, id+uploadScanRecordExt)
+}
+
+func (scans *uploadScans) writeRecord(dir string, record UploadScan) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(scans.recordPath(dir, record.Id), data, 0640)
+}
+
+// Load the scans recorded in a directory; uploads withheld from the namespace
+// when the origin stopped are returned as pending
+func (scans *uploadScans) readRecords(dir string) ([]UploadScan, error) {
+	entries, err := os.ReadDir(filepath.Join(scans.root, dir))
+	if err != nil {
+		return nil, err
+	}
+	records := make([]UploadScan, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), uploadScanRecordExt) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(scans.root, dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		record := UploadScan{}
+		if err = json.Unmarshal(data, &record); err != nil {
+			log.Warningln("Ignoring invalid upload scan record", entry.Name(), ":", err)
+			continue
+		}
+		if _, err = os.Stat(scans.filePath(dir, record.Id)); err != nil {
+			log.Warningln("Ignoring upload scan record", entry.Name(), "without a matching object:", err)
+			continue
+		}
+		if dir == uploadScanStagingDir {
+			record.Status = UploadScanPending
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Drop the results of scans that are finished and no longer need attention
+func (scans *uploadScans) pruneLocked(now time.Time) {
+	for id, record := range scans.records {
+		if record.Status.withheld() || record.Status.inQuarantine() || record.ScannedAt == nil {
+			continue
+		}
+		if now.Sub(*record.ScannedAt) > uploadScanRetention {
+			delete(scans.records, id)
+		}
+	}
+}
+
+// Withhold a newly written object from the namespace and queue it for a scan.
+// Blocks while the queue is full.
+func (scans *uploadScans) submit(objectPath string) error {
+	scans.mutex.Lock()
+	storagePath := objectStoragePath(objectPath)
+	info, err := os.Stat(storagePath)
+	if err != nil {
+		scans.mutex.Unlock()
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		scans.mutex.Unlock()
+		return errors.Errorf("%s is not a regular file", objectPath)
+	}
+	record := UploadScan{
+		Id:         uuid.NewString(),
+		Path:       objectPath,
+		Size:       info.Size(),
+		Status:     UploadScanPending,
+		UploadedAt: time.Now().UTC(),
+	}
+	// Record the upload before moving it so that it is scanned after a restart
+	if err = scans.writeRecord(uploadScanStagingDir, record); err != nil {
+		scans.mutex.Unlock()
+		return err
+	}
+	if err = moveFile(storagePath, scans.filePath(uploadScanStagingDir, record.Id)); err != nil {
+		os.Remove(scans.recordPath(uploadScanStagingDir, record.Id))
+		scans.mutex.Unlock()
+		return errors.Wrapf(err, "failed to withhold %s from the namespace", objectPath)
+	}
+	scans.records[record.Id] = &record
+	scans.mutex.Unlock()
+
+	metrics.PelicanOriginUploadScansPending.Inc()
+	log.Debugf("Withheld %s from the namespace for scan %s", objectPath, record.Id)
+	scans.queue <- record.Id
+	return nil
+}
+
+// Move a withheld or quarantined file into the namespace.  Returns false, leaving the
+// namespace untouched, if the object was written again after the upload was received.
+func placeScannedObject(srcPath string, record *UploadScan) (bool, error) {
+	storagePath := objectStoragePath(record.Path)
+	if existing, err := os.Stat(storagePath); err == nil {
+		if existing.ModTime().After(record.UploadedAt) {
+			return false, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	} else if err = os.MkdirAll(filepath.Dir(storagePath), 0755); err != nil {
+		return false, err
+	}
+	return true, moveFile(srcPath, storagePath)
+}
+
+// Update a scan's status, returning a copy of the updated record
+func (scans *uploadScans) setStatusLocked(record *UploadScan, status UploadScanStatus, reason string) UploadScan {
+	now := time.Now().UTC()
+	record.Status = status
+	record.Reason = reason
+	if !status.withheld() {
+		record.ScannedAt = &now
+	}
+	return *record
+}
+
+// Move a withheld object to quarantine
+func (scans *uploadScans) quarantineLocked(record *UploadScan, status UploadScanStatus, reason string) {
+	updated := scans.setStatusLocked(record, status, reason)
+	metrics.PelicanOriginUploadScans.WithLabelValues(string(status)).Inc()
+	if err := moveFile(scans.filePath(uploadScanStagingDir, record.Id), scans.filePath(uploadScanQuarantineDir, record.Id)); err != nil {
+		// The object stays withheld in the staging directory and is scanned again after a restart
+		log.Errorf("Failed to move %s (scan %s) to quarantine: %v", record.Path, record.Id, err)
+		return
+	}
+	if err := scans.writeRecord(uploadScanQuarantineDir, updated); err != nil {
+		log.Errorf("Failed to record the quarantine of %s (scan %s): %v", record.Path, record.Id, err)
+	}
+	os.Remove(scans.recordPath(uploadScanStagingDir, record.Id))
+	log.Warningf("Quarantined %s (scan %s): %s", record.Path, record.Id, reason)
+}
+
+// Act on the outcome of scanning a withheld object
+func (scans *uploadScans) complete(id string, reason string, scanErr error) {
+	scans.mutex.Lock()
+	defer scans.mutex.Unlock()
+	record, ok := scans.records[id]
+	if !ok {
+		return
+	}
+	defer metrics.PelicanOriginUploadScansPending.Dec()
+
+	if scanErr != nil {
+		scans.quarantineLocked(record, UploadScanFailed, "scan failed: "+scanErr.Error())
+		return
+	} else if reason != "" {
+		scans.quarantineLocked(record, UploadScanQuarantined, reason)
+		return
+	}
+
+	stagedPath := scans.filePath(uploadScanStagingDir, id)
+	placed, err := placeScannedObject(stagedPath, record)
+	if err != nil {
+		scans.quarantineLocked(record, UploadScanFailed, "failed to place the object in the namespace: "+err.Error())
+		return
+	}
+	status := UploadScanClean
+	if !placed {
+		status = UploadScanSuperseded
+		reason = "the object was written again before the scan completed"
+		os.Remove(stagedPath)
+	}
+	os.Remove(scans.recordPath(uploadScanStagingDir, id))
+	scans.setStatusLocked(record, status, reason)
+	scans.pruneLocked(time.Now())
+	metrics.PelicanOriginUploadScans.WithLabelValues(string(status)).Inc()
+	log.Debugf("Scan %s of %s finished: %s", id, record.Path, status)
+
+	if placed && param.Origin_EnableVersioning.GetBool() {
+		if _, err := snapshotObject(record.Path); err != nil {
+			log.Errorln("Failed to retain a version of", record.Path, ":", err)
+		}
+	}
+}
+
+func (scans *uploadScans) process(ctx context.Context, id string) {
+	scans.mutex.Lock()
+	record, ok := scans.records[id]
+	if ok {
+		scans.setStatusLocked(record, UploadScanScanning, "")
+	}
+	scans.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	scanCtx, cancel := context.WithTimeout(ctx, scans.timeout)
+	reason, err := scans.scanner.scan(scanCtx, scans.filePath(uploadScanStagingDir, id))
+	cancel()
+	if ctx.Err() != nil {
+		// Shutting down; the upload remains withheld and is scanned after a restart
+		return
+	}
+	scans.complete(id, reason, err)
+}
+
+func (scans *uploadScans) list(status UploadScanStatus, pathPrefix string) []UploadScan {
+	scans.mutex.Lock()
+	defer scans.mutex.Unlock()
+	result := make([]UploadScan, 0, len(scans.records))
+	for _, record := range scans.records {
+		if status != "" && record.Status != status {
+			continue
+		}
+		if pathPrefix != "" && record.Path != pathPrefix && !strings.HasPrefix(record.Path, strings.TrimSuffix(pathPrefix, "/")+"/") {
+			continue
+		}
+		result = append(result, *record)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UploadedAt.After(result[j].UploadedAt) })
+	return result
+}
+
+func (scans *uploadScans) get(id string) (UploadScan, error) {
+	scans.mutex.Lock()
+	defer scans.mutex.Unlock()
+	record, ok := scans.records[id]
+	if !ok {
+		return UploadScan{}, errors.Wrapf(errUploadScanNotFound, "no upload scan with ID %s", id)
+	}
+	return *record, nil
+}
+
+// Look up a quarantined object for an administrator's action
+func (scans *uploadScans) quarantinedLocked(id string) (*UploadScan, error) {
+	record, ok := scans.records[id]
+	if !ok {
+		return nil, errors.Wrapf(errUploadScanNotFound, "no upload scan with ID %s", id)
+	}
+	if !record.Status.inQuarantine() {
+		return nil, errors.Wrapf(errUploadScanConflict, "the object of scan %s is not quarantined (status %s)", id, record.Status)
+	}
+	return record, nil
+}
+
+// Place a quarantined object in the namespace on an administrator's request
+func (scans *uploadScans) release(id string, user string) (UploadScan, error) {
+	scans.mutex.Lock()
+	defer scans.mutex.Unlock()
+	record, err := scans.quarantinedLocked(id)
+	if err != nil {
+		return UploadScan{}, err
+	}
+	placed, err := placeScannedObject(scans.filePath(uploadScanQuarantineDir, id), record)
+	if err != nil {
+		return UploadScan{}, errors.Wrapf(err, "failed to release %s from quarantine", record.Path)
+	} else if !placed {
+		return UploadScan{}, errors.Wrapf(errUploadScanConflict, "%s was written again after the quarantined upload", record.Path)
+	}
+	os.Remove(scans.recordPath(uploadScanQuarantineDir, id))
+	updated := scans.setStatusLocked(record, UploadScanReleased, fmt.Sprintf("released by %s; previously %s", user, record.Reason))
+	log.Infof("Quarantined upload of %s (scan %s) released by %s", record.Path, id, user)
+	if param.Origin_EnableVersioning.GetBool() {
+		if _, err := snapshotObject(record.Path); err != nil {
+			log.Errorln("Failed to retain a version of", record.Path, ":", err)
+		}
+	}
+	return updated, nil
+}
+
+// Delete a quarantined object on an administrator's request
+func (scans *uploadScans) discard(id string, user string) error {
+	scans.mutex.Lock()
+	defer scans.mutex.Unlock()
+	record, err := scans.quarantinedLocked(id)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(scans.filePath(uploadScanQuarantineDir, id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	os.Remove(scans.recordPath(uploadScanQuarantineDir, id))
+	delete(scans.records, id)
+	log.Infof("Quarantined upload of %s (scan %s) deleted by %s", record.Path, id, user)
+	return nil
+}
+
+// Construct the scanner configured by Origin.UploadScanCommand or Origin.UploadScanICAPUrl
+func newUploadScanner() (uploadScanner, error) {
+	if command := param.Origin_UploadScanCommand.GetStringSlice(); len(command) > 0 {
+		return &execUploadScanner{command: command}, nil
+	}
+	icapUrl := param.Origin_UploadScanICAPUrl.GetString()
+	if icapUrl == "" {
+		return nil, errors.New("Origin.EnableUploadScan is set but neither Origin.UploadScanCommand nor Origin.UploadScanICAPUrl is configured")
+	}
+	serviceUrl, err := url.Parse(icapUrl)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Origin.UploadScanICAPUrl")
+	}
+	if serviceUrl.Scheme != "icap" || serviceUrl.Host == "" {
+		return nil, errors.Errorf("invalid Origin.UploadScanICAPUrl %q: expected a URL of the form icap://host[:port]/service", icapUrl)
+	}
+	return &icapUploadScanner{serviceUrl: serviceUrl}, nil
+}
+
+// Set up scanning of uploaded objects, if enabled.  Uploads withheld from the namespace
+// when the origin last stopped are scanned again.
+func LaunchUploadScanning(ctx context.Context, egrp *errgroup.Group) error {
+	if !param.Origin_EnableUploadScan.GetBool() {
+		return nil
+	}
+	if param.Origin_StorageType.GetString() != string(server_structs.OriginStoragePosix) {
+		return errors.Errorf("Origin.EnableUploadScan is only supported for the %s storage type", server_structs.OriginStoragePosix)
+	}
+	scanner, err := newUploadScanner()
+	if err != nil {
+		return err
+	}
+	scans := &uploadScans{
+		scanner: scanner,
+		root:    param.Origin_UploadScanLocation.GetString(),
+		timeout: param.Origin_UploadScanTimeout.GetDuration(),
+		queue:   make(chan string, uploadScanQueueSize),
+		records: make(map[string]*UploadScan),
+	}
+	for _, dir := range []string{uploadScanStagingDir, uploadScanQuarantineDir} {
+		if err := os.MkdirAll(filepath.Join(scans.root, dir), 0750); err != nil {
+			return errors.Wrap(err, "failed to create the upload scan directory")
+		}
+	}
+
+	quarantined, err := scans.readRecords(uploadScanQuarantineDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to load the quarantined uploads")
+	}
+	pending, err := scans.readRecords(uploadScanStagingDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to load the uploads awaiting a scan")
+	}
+	for idx := range quarantined {
+		scans.records[quarantined[idx].Id] = &quarantined[idx]
+	}
+	for idx := range pending {
+		scans.records[pending[idx].Id] = &pending[idx]
+	}
+	metrics.PelicanOriginUploadScansPending.Set(float64(len(pending)))
+
+	for idx := 0; idx < uploadScanWorkers; idx++ {
+		egrp.Go(func() error {
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case id := <-scans.queue:
+					scans.process(ctx, id)
+				}
+			}
+		})
+	}
+	if len(pending) > 0 {
+		log.Infof("Resuming scans of %d uploads withheld from the namespace", len(pending))
+		egrp.Go(func() error {
+			for _, record := range pending {
+				select {
+				case <-ctx.Done():
+					return nil
+				case scans.queue <- record.Id:
+				}
+			}
+			return nil
+		})
+	}
+
+	activeScans.Store(scans)
+	log.Infoln("Upload scanning enabled; uploads are withheld from the namespace in", scans.root, "until scanned")
+	return nil
+}
+
+func abortUploadScanError(ctx *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, errUploadScanNotFound) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, errUploadScanConflict) {
+		status = http.StatusConflict
+	} else {
+		log.Errorln("Upload scan request failed:", err)
+	}
+	ctx.AbortWithStatusJSON(status, server_structs.SimpleApiResp{
+		Status: server_structs.RespFailed,
+		Msg:    err.Error(),
+	})
+}
+
+// The upload scanner, aborting the request if scanning has not started
+func uploadScansOrAbort(ctx *gin.Context) *uploadScans {
+	scans := activeUploadScans()
+	if scans == nil {
+		ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Upload scanning is not running",
+		})
+	}
+	return scans
+}
+
+func handleListUploadScans(ctx *gin.Context) {
+	scans := uploadScansOrAbort(ctx)
+	if scans == nil {
+		return
+	}
+	pathPrefix := ctx.Query("path")
+	if pathPrefix != "" {
+		pathPrefix = path.Clean("/" + pathPrefix)
+	}
+	ctx.JSON(http.StatusOK, scans.list(UploadScanStatus(ctx.Query("status")), pathPrefix))
+}
+
+func handleGetUploadScan(ctx *gin.Context) {
+	scans := uploadScansOrAbort(ctx)
+	if scans == nil {
+		return
+	}
+	record, err := scans.get(ctx.Param("id"))
+	if err != nil {
+		abortUploadScanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, record)
+}
+
+func handleReleaseUploadScan(ctx *gin.Context) {
+	scans := uploadScansOrAbort(ctx)
+	if scans == nil {
+		return
+	}
+	record, err := scans.release(ctx.Param("id"), ctx.GetString("User"))
+	if err != nil {
+		abortUploadScanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, record)
+}
+
+func handleDeleteUploadScan(ctx *gin.Context) {
+	scans := uploadScansOrAbort(ctx)
+	if scans == nil {
+		return
+	}
+	if err := scans.discard(ctx.Param("id"), ctx.GetString("User")); err != nil {
+		abortUploadScanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK})
+}
@@ -0,0 +1,299 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeUploadScanner func(filePath string) (string, error)
+
+func (scanner fakeUploadScanner) scan(ctx context.Context, filePath string) (string, error) {
+	return scanner(filePath)
+}
+
+// Rejects files containing "virus"
+func newFakeUploadScans(t *testing.T) *uploadScans {
+	scans := &uploadScans{
+		scanner: fakeUploadScanner(func(filePath string) (string, error) {
+			contents, err := os.ReadFile(filePath)
+			if err != nil {
+				return "", err
+			}
+			if strings.Contains(string(contents), "virus") {
+				return "virus found", nil
+			}
+			return "", nil
+		}),
+		root:    t.TempDir(),
+		timeout: time.Minute,
+		queue:   make(chan string, 10),
+		records: make(map[string]*UploadScan),
+	}
+	for _, dir := range []string{uploadScanStagingDir, uploadScanQuarantineDir} {
+		require.NoError(t, os.MkdirAll(filepath.Join(scans.root, dir), 0750))
+	}
+	return scans
+}
+
+// Submit an object for scanning and run the scan, returning its result
+func scanUpload(t *testing.T, scans *uploadScans, objectPath string) UploadScan {
+	require.NoError(t, scans.submit(objectPath))
+	id := <-scans.queue
+	record, err := scans.get(id)
+	require.NoError(t, err)
+	assert.Equal(t, UploadScanPending, record.Status)
+	_, err = os.Stat(objectStoragePath(objectPath))
+	assert.ErrorIs(t, err, os.ErrNotExist, "the object should be withheld from the namespace while scanned")
+
+	scans.process(context.Background(), id)
+	record, err = scans.get(id)
+	require.NoError(t, err)
+	return record
+}
+
+func TestUploadScanning(t *testing.T) {
+	storageDir := setupVersioning(t, 10)
+	scans := newFakeUploadScans(t)
+	objectFile := filepath.Join(storageDir, "foo.txt")
+
+	t.Run("clean", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(objectFile, []byte("hello"), 0644))
+		record := scanUpload(t, scans, "/test/foo.txt")
+		assert.Equal(t, UploadScanClean, record.Status)
+		assert.Equal(t, int64(5), record.Size)
+		assert.NotNil(t, record.ScannedAt)
+		contents, err := os.ReadFile(objectFile)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(contents))
+		entries, err := os.ReadDir(filepath.Join(scans.root, uploadScanStagingDir))
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("quarantine-release", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(objectFile, []byte("a virus"), 0644))
+		record := scanUpload(t, scans, "/test/foo.txt")
+		assert.Equal(t, UploadScanQuarantined, record.Status)
+		assert.Equal(t, "virus found", record.Reason)
+		_, err := os.Stat(objectFile)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		quarantined := scans.list(UploadScanQuarantined, "/test")
+		require.Len(t, quarantined, 1)
+		assert.Equal(t, record.Id, quarantined[0].Id)
+		assert.Empty(t, scans.list("", "/other"))
+
+		// Quarantined uploads are remembered across restarts
+		reloaded, err := scans.readRecords(uploadScanQuarantineDir)
+		require.NoError(t, err)
+		require.Len(t, reloaded, 1)
+		assert.Equal(t, UploadScanQuarantined, reloaded[0].Status)
+
+		released, err := scans.release(record.Id, "admin")
+		require.NoError(t, err)
+		assert.Equal(t, UploadScanReleased, released.Status)
+		contents, err := os.ReadFile(objectFile)
+		require.NoError(t, err)
+		assert.Equal(t, "a virus", string(contents))
+
+		_, err = scans.release(record.Id, "admin")
+		assert.ErrorIs(t, err, errUploadScanConflict)
+		_, err = scans.release("missing", "admin")
+		assert.ErrorIs(t, err, errUploadScanNotFound)
+	})
+
+	t.Run("quarantine-discard", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(objectFile, []byte("another virus"), 0644))
+		record := scanUpload(t, scans, "/test/foo.txt")
+		require.Equal(t, UploadScanQuarantined, record.Status)
+
+		require.NoError(t, scans.discard(record.Id, "admin"))
+		_, err := scans.get(record.Id)
+		assert.ErrorIs(t, err, errUploadScanNotFound)
+		entries, err := os.ReadDir(filepath.Join(scans.root, uploadScanQuarantineDir))
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("superseded", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(objectFile, []byte("old"), 0644))
+		require.NoError(t, scans.submit("/test/foo.txt"))
+		id := <-scans.queue
+
+		// A newer write lands before the scan of the older one completes
+		require.NoError(t, os.WriteFile(objectFile, []byte("new"), 0644))
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(objectFile, future, future))
+
+		scans.process(context.Background(), id)
+		record, err := scans.get(id)
+		require.NoError(t, err)
+		assert.Equal(t, UploadScanSuperseded, record.Status)
+		contents, err := os.ReadFile(objectFile)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(contents))
+	})
+
+	t.Run("scan-error", func(t *testing.T) {
+		failing := newFakeUploadScans(t)
+		failing.scanner = fakeUploadScanner(func(string) (string, error) {
+			return "", fmt.Errorf("scanner unavailable")
+		})
+		require.NoError(t, os.WriteFile(objectFile, []byte("hello"), 0644))
+		record := scanUpload(t, failing, "/test/foo.txt")
+		assert.Equal(t, UploadScanFailed, record.Status)
+		assert.Contains(t, record.Reason, "scanner unavailable")
+		_, err := os.Stat(filepath.Join(failing.root, uploadScanQuarantineDir, record.Id))
+		assert.NoError(t, err)
+	})
+}
+
+func TestExecUploadScanner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test scanner is a shell script")
+	}
+	file := filepath.Join(t.TempDir(), "upload")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+
+	scan := func(script string) (string, error) {
+		scanner := &execUploadScanner{command: []string{"sh", "-c", script, "scanner"}}
+		return scanner.scan(context.Background(), file)
+	}
+
+	reason, err := scan(`test -f "$1"`)
+	require.NoError(t, err)
+	assert.Empty(t, reason)
+
+	reason, err = scan(`echo "$1: Eicar-Signature FOUND"; exit 1`)
+	require.NoError(t, err)
+	assert.Equal(t, file+": Eicar-Signature FOUND", reason)
+
+	reason, err = scan(`exit 1`)
+	require.NoError(t, err)
+	assert.Equal(t, "rejected by sh", reason)
+
+	_, err = scan(`echo "database missing"; exit 2`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "database missing")
+
+	scanner := &execUploadScanner{command: []string{"sh", "-c", "exec sleep 10", "scanner"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = scanner.scan(ctx, file)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// Serve ICAP RESPMOD requests, rejecting bodies containing "virus"
+func serveFakeICAP(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := textproto.NewReader(bufio.NewReader(conn))
+				requestLine, err := reader.ReadLine()
+				if err != nil || !strings.HasPrefix(requestLine, "RESPMOD icap://") {
+					return
+				}
+				headers, err := reader.ReadMIMEHeader()
+				if err != nil || !strings.Contains(headers.Get("Encapsulated"), "res-body=") {
+					return
+				}
+				// The encapsulated HTTP response header
+				if _, err = reader.ReadLine(); err != nil {
+					return
+				}
+				if _, err = reader.ReadMIMEHeader(); err != nil {
+					return
+				}
+				body := strings.Builder{}
+				for {
+					sizeLine, err := reader.ReadLine()
+					if err != nil {
+						return
+					}
+					size, err := strconv.ParseInt(sizeLine, 16, 64)
+					if err != nil {
+						return
+					}
+					if size == 0 {
+						_, _ = reader.ReadLine()
+						break
+					}
+					if _, err = io.CopyN(&body, reader.R, size); err != nil {
+						return
+					}
+					_, _ = reader.ReadLine()
+				}
+				if strings.Contains(body.String(), "virus") {
+					fmt.Fprint(conn, "ICAP/1.0 200 OK\r\nX-Infection-Found: Type=0; Resolution=2; Threat=Test-Virus;\r\nEncapsulated: null-body=0\r\n\r\n")
+				} else {
+					fmt.Fprintf(conn, "ICAP/1.0 %d No Content\r\n\r\n", http.StatusNoContent)
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestICAPUploadScanner(t *testing.T) {
+	address := serveFakeICAP(t)
+	serviceUrl, err := url.Parse("icap://" + address + "/avscan")
+	require.NoError(t, err)
+	scanner := &icapUploadScanner{serviceUrl: serviceUrl}
+
+	dir := t.TempDir()
+	clean := filepath.Join(dir, "clean")
+	require.NoError(t, os.WriteFile(clean, []byte(strings.Repeat("hello", 100000)), 0644))
+	infected := filepath.Join(dir, "infected")
+	require.NoError(t, os.WriteFile(infected, []byte("this is a virus"), 0644))
+
+	reason, err := scanner.scan(context.Background(), clean)
+	require.NoError(t, err)
+	assert.Empty(t, reason)
+
+	reason, err = scanner.scan(context.Background(), infected)
+	require.NoError(t, err)
+	assert.Equal(t, "X-Infection-Found: Type=0; Resolution=2; Threat=Test-Virus;", reason)
+}
@@ -0,0 +1,628 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"hash/adler32"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/token"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+type (
+	// An upload staged through the origin's web API, waiting to be published
+	StagedUpload struct {
+		Id   string `json:"id"`
+		Path string `json:"path"`
+		Size int64  `json:"size"`
+		// The checksums of the staged object, keyed by their name in the Digest header
+		Digests   map[string]string `json:"digests"`
+		StagedAt  time.Time         `json:"stagedAt"`
+		ExpiresAt time.Time         `json:"expiresAt"`
+	}
+
+	uploadStaging struct {
+		root     string
+		lifetime time.Duration
+
+		// Serializes publishing objects into the namespace
+		mutex   sync.Mutex
+		uploads map[string]*StagedUpload
+		// The chunked uploads whose chunks are being received, keyed by id
+		chunked map[string]*chunkedUpload
+	}
+)
+
+const (
+	uploadStagingRecordExt  = ".json"
+	uploadStagingPartialExt = ".part"
+	// How often uploads that were never published are looked for
+	uploadStagingExpiryInterval = 10 * time.Minute
+	// How long the keys of an issuer other than the origin itself are kept
+	uploadStagingKeysLifetime = 15 * time.Minute
+	// The audience of tokens valid at any server (WLCG token profile)
+	wlcgAnyAudience = "https://wlcg.cern.ch/jwt/v1/any"
+)
+
+var (
+	errStagedUploadNotFound     = errors.New("staged upload not found")
+	errStagedUploadBadDigest    = errors.New("invalid digest")
+	errStagedUploadMismatch     = errors.New("checksum mismatch")
+	errStagedUploadUnauthorized = errors.New("unauthorized")
+	errStagedUploadForbidden    = errors.New("forbidden")
+	errStagedUploadBadRequest   = errors.New("bad request")
+	errStagedUploadIncomplete   = errors.New("incomplete upload")
+
+	activeStaging atomic.Pointer[uploadStaging]
+
+	// The checksums computed for staged uploads, keyed by their name in the Digest header (RFC 3230)
+	stagedUploadDigests = map[string]func() hash.Hash{
+		"sha-256": sha256.New,
+		"md5":     md5.New,
+		"adler32": func() hash.Hash { return adler32.New() },
+	}
+
+	// Look up the public keys of the issuer of the tokens authorizing staged uploads
+	stagedUploadIssuerKeys = getStagedUploadIssuerKeys
+
+	stagedUploadKeys        jwk.Set
+	stagedUploadKeysFetched time.Time
+	stagedUploadKeysMutex   sync.Mutex
+)
+
+// The upload staging area, or nil if upload staging is disabled
+func activeUploadStaging() *uploadStaging {
+	return activeStaging.Load()
+}
+
+// Encode a checksum the way it appears in a Digest header: adler32 is
+// hex-encoded while the cryptographic digests are base64-encoded
+func encodeStagedUploadDigest(name string, sum []byte) string {
+	if name == "adler32" {
+		return hex.EncodeToString(sum)
+	}
+	return base64.StdEncoding.EncodeToString(sum)
+}
+
+// Compare the value from a Digest header with a checksum.  Clients are inconsistent
+// about the encoding, so both hex and base64 are accepted.
+func stagedUploadDigestMatches(name string, value string, expected string) bool {
+	sum, err := hex.DecodeString(expected)
+	if name != "adler32" {
+		sum, err = base64.StdEncoding.DecodeString(expected)
+	}
+	if err != nil {
+		return false
+	}
+	value = strings.TrimSpace(value)
+	if decoded, err := hex.DecodeString(value); err == nil && len(decoded) == len(sum) {
+		return bytes.Equal(decoded, sum)
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+		return bytes.Equal(decoded, sum)
+	}
+	return false
+}
+
+// Parse a Digest header (RFC 3230) into the values of the checksums the origin computes
+func parseStagedUploadDigests(header string) map[string]string {
+	digests := make(map[string]string)
+	for _, entry := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, known := stagedUploadDigests[name]; known {
+			digests[name] = strings.TrimSpace(value)
+		}
+	}
+	return digests
+}
+
+// The Digest header describing the staged object
+func (upload StagedUpload) digestHeader() string {
+	entries := make([]string, 0, len(upload.Digests))
+	for name, value := range upload.Digests {
+		entries = append(entries, name+"="+value)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+func (staging *uploadStaging) filePath(id string) string {
+	return filepath.Join(staging.root, id)
+}
+
+func (staging *uploadStaging) recordPath(id string) string {
+	return filepath.Join(staging.root, id+uploadStagingRecordExt)
+}
+
+// Delete the files of a staged upload
+func (staging *uploadStaging) remove(id string) {
+	os.Remove(staging.recordPath(id))
+	os.Remove(staging.filePath(id))
+}
+
+// Store an upload for the object in the staging area, replacing any upload of the
+// object that wasn't published yet
+func (staging *uploadStaging) stage(objectPath string, body io.Reader) (StagedUpload, error) {
+	id := uuid.NewString()
+	partialPath := staging.filePath(id) + uploadStagingPartialExt
+	file, err := os.OpenFile(partialPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return StagedUpload{}, err
+	}
+	hashers := make(map[string]hash.Hash, len(stagedUploadDigests))
+	writers := []io.Writer{file}
+	for name, newHash := range stagedUploadDigests {
+		hashers[name] = newHash()
+		writers = append(writers, hashers[name])
+	}
+	size, err := io.Copy(io.MultiWriter(writers...), body)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(partialPath)
+		return StagedUpload{}, errors.Wrapf(err, "failed to stage the upload of %s", objectPath)
+	}
+	return staging.register(id, objectPath, size, hashers)
+}
+
+// Record a complete upload whose data is in the partial file of the id, making it the
+// staged upload of the object
+func (staging *uploadStaging) register(id string, objectPath string, size int64, hashers map[string]hash.Hash) (StagedUpload, error) {
+	partialPath := staging.filePath(id) + uploadStagingPartialExt
+	now := time.Now().UTC()
+	record := StagedUpload{
+		Id:        id,
+		Path:      objectPath,
+		Size:      size,
+		Digests:   make(map[string]string, len(hashers)),
+		StagedAt:  now,
+		ExpiresAt: now.Add(staging.lifetime),
+	}
+	for name, hasher := range hashers {
+		record.Digests[name] = encodeStagedUploadDigest(name, hasher.Sum(nil))
+	}
+	// The record is only written once the upload is complete, so that uploads
+	// interrupted by a restart are never published
+	if err := os.Rename(partialPath, staging.filePath(id)); err != nil {
+		os.Remove(partialPath)
+		return StagedUpload{}, err
+	}
+	data, err := json.Marshal(record)
+	if err == nil {
+		err = os.WriteFile(staging.recordPath(id), data, 0640)
+	}
+	if err != nil {
+		staging.remove(id)
+		return StagedUpload{}, errors.Wrapf(err, "failed to record the staged upload of %s", objectPath)
+	}
+
+	staging.mutex.Lock()
+	previous := staging.uploads[objectPath]
+	staging.uploads[objectPath] = &record
+	staging.mutex.Unlock()
+	if previous != nil {
+		staging.remove(previous.Id)
+	}
+	metrics.PelicanOriginStagedUploads.WithLabelValues("staged").Inc()
+	log.Debugf("Staged an upload of %s (%d bytes) as %s", objectPath, size, id)
+	return record, nil
+}
+
+// Move a staged upload into the namespace if its checksums match the Digest header.
+// An upload failing the verification is discarded.
+func (staging *uploadStaging) publish(objectPath string, digestHeader string) (StagedUpload, error) {
+	expected := parseStagedUploadDigests(digestHeader)
+	if len(expected) == 0 {
+		return StagedUpload{}, errors.Wrap(errStagedUploadBadDigest, "the Digest header must give the sha-256, md5, or adler32 checksum of the object")
+	}
+
+	staging.mutex.Lock()
+	record, ok := staging.uploads[objectPath]
+	if !ok {
+		staging.mutex.Unlock()
+		return StagedUpload{}, errors.Wrapf(errStagedUploadNotFound, "no upload of %s is staged", objectPath)
+	}
+	for name, value := range expected {
+		if !stagedUploadDigestMatches(name, value, record.Digests[name]) {
+			delete(staging.uploads, objectPath)
+			staging.mutex.Unlock()
+			staging.remove(record.Id)
+			metrics.PelicanOriginStagedUploads.WithLabelValues("mismatch").Inc()
+			log.Warningf("Discarded the staged upload of %s: its %s checksum %s does not match the expected %s", objectPath, name, record.Digests[name], value)
+			return StagedUpload{}, errors.Wrapf(errStagedUploadMismatch, "the %s checksum of the staged upload is %s, not %s; the upload was discarded", name, record.Digests[name], value)
+		}
+	}
+
+	storagePath := objectStoragePath(objectPath)
+	err := os.MkdirAll(filepath.Dir(storagePath), 0755)
+	if err == nil {
+		err = moveFile(staging.filePath(record.Id), storagePath)
+	}
+	if err != nil {
+		staging.mutex.Unlock()
+		return StagedUpload{}, errors.Wrapf(err, "failed to publish %s", objectPath)
+	}
+	delete(staging.uploads, objectPath)
+	staging.mutex.Unlock()
+	os.Remove(staging.recordPath(record.Id))
+	metrics.PelicanOriginStagedUploads.WithLabelValues("published").Inc()
+	log.Debugf("Published the staged upload %s of %s", record.Id, objectPath)
+
+	// XRootD doesn't report the write, so act on it as if it had
+	if writeNotificationsEnabled() {
+		handleObjectWritten(objectPath)
+	}
+	return *record, nil
+}
+
+// Delete the staged upload of an object
+func (staging *uploadStaging) discard(objectPath string) error {
+	staging.mutex.Lock()
+	record, ok := staging.uploads[objectPath]
+	delete(staging.uploads, objectPath)
+	staging.mutex.Unlock()
+	if !ok {
+		return errors.Wrapf(errStagedUploadNotFound, "no upload of %s is staged", objectPath)
+	}
+	staging.remove(record.Id)
+	metrics.PelicanOriginStagedUploads.WithLabelValues("abandoned").Inc()
+	return nil
+}
+
+// Delete the uploads that were not published within Origin.UploadStagingLifetime
+func (staging *uploadStaging) expire(now time.Time) {
+	expired := []StagedUpload{}
+	staging.mutex.Lock()
+	for objectPath, record := range staging.uploads {
+		if now.After(record.ExpiresAt) {
+			expired = append(expired, *record)
+			delete(staging.uploads, objectPath)
+		}
+	}
+	staging.mutex.Unlock()
+	for _, record := range expired {
+		staging.remove(record.Id)
+		metrics.PelicanOriginStagedUploads.WithLabelValues("expired").Inc()
+		log.Infof("Deleted the upload of %s staged at %s; it was never published", record.Path, record.StagedAt.Format(time.RFC3339))
+	}
+	staging.expireChunked(now)
+}
+
+// Load the uploads staged before the origin restarted, deleting partial uploads,
+// including the chunked uploads that were never assembled
+func (staging *uploadStaging) load() error {
+	entries, err := os.ReadDir(staging.root)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, uploadStagingPartialExt) {
+			os.Remove(filepath.Join(staging.root, name))
+			continue
+		} else if !strings.HasSuffix(name, uploadStagingRecordExt) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(staging.root, name))
+		if err != nil {
+			return err
+		}
+		record := StagedUpload{}
+		if err = json.Unmarshal(data, &record); err != nil || record.Id+uploadStagingRecordExt != name {
+			log.Warningln("Ignoring invalid staged upload record", name)
+			continue
+		}
+		if _, err = os.Stat(staging.filePath(record.Id)); err != nil {
+			log.Warningln("Ignoring staged upload record", name, "without a matching object:", err)
+			continue
+		}
+		if previous := staging.uploads[record.Path]; previous != nil {
+			if previous.StagedAt.After(record.StagedAt) {
+				staging.remove(record.Id)
+				continue
+			}
+			staging.remove(previous.Id)
+		}
+		staging.uploads[record.Path] = &record
+	}
+	return nil
+}
+
+// Get the public keys of the issuer, caching those of issuers other than the origin itself
+func getStagedUploadIssuerKeys(issuer string) (jwk.Set, error) {
+	if issuer == param.Server_ExternalWebUrl.GetString() {
+		return config.GetIssuerPublicJWKS()
+	}
+	stagedUploadKeysMutex.Lock()
+	defer stagedUploadKeysMutex.Unlock()
+	if stagedUploadKeys != nil && time.Since(stagedUploadKeysFetched) < uploadStagingKeysLifetime {
+		return stagedUploadKeys, nil
+	}
+	keys, err := token.GetJWKSFromIssUrl(issuer)
+	if err != nil {
+		return nil, err
+	}
+	stagedUploadKeys, stagedUploadKeysFetched = *keys, time.Now()
+	return stagedUploadKeys, nil
+}
+
+// Check that the token grants one of the scopes on the object.  Like XRootD, the paths of
+// the scopes are relative to the exports of the origin, and limited to
+// Origin.ScitokensRestrictedPaths if set.
+func stagedUploadScopeAllows(tok jwt.Token, objectPath string, exports []server_utils.OriginExport, scopes []token_scopes.TokenScope) bool {
+	for _, export := range exports {
+		prefix := path.Clean(export.FederationPrefix)
+		if !export.Capabilities.Writes || (objectPath != prefix && !strings.HasPrefix(objectPath, prefix+"/")) {
+			continue
+		}
+		if exportScopeAllows(tok, objectPath, prefix, scopes) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check that the token grants one of the scopes on the object of the export with the prefix
+func exportScopeAllows(tok jwt.Token, objectPath string, prefix string, scopes []token_scopes.TokenScope) bool {
+	restrictedPaths := param.Origin_ScitokensRestrictedPaths.GetStringSlice()
+	for _, scope := range token_scopes.ParseResourceScopeString(tok) {
+		if !slices.Contains(scopes, scope.Authorization) {
+			continue
+		}
+		requested := token_scopes.NewResourceScope(scope.Authorization, objectPath)
+		if !token_scopes.NewResourceScope(scope.Authorization, path.Join(prefix, scope.Resource)).Contains(requested) {
+			continue
+		}
+		if len(restrictedPaths) == 0 || slices.ContainsFunc(restrictedPaths, func(restrictedPath string) bool {
+			return token_scopes.NewResourceScope(scope.Authorization, path.Join(prefix, restrictedPath)).Contains(requested)
+		}) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify that the request carries a token from the origin's issuer granting one of the
+// scopes on the object
+func authorizeStagedUpload(ctx *gin.Context, objectPath string, scopes ...token_scopes.TokenScope) error {
+	tok, err := verifyOriginToken(ctx)
+	if err != nil {
+		return err
+	}
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		return err
+	}
+	if !stagedUploadScopeAllows(tok, objectPath, exports, scopes) {
+		return errors.Wrapf(errStagedUploadForbidden, "the token does not allow writing %s", objectPath)
+	}
+	return nil
+}
+
+// Verify the token of the request, which must be issued by the origin's issuer for the origin
+func verifyOriginToken(ctx *gin.Context) (jwt.Token, error) {
+	strToken := ctx.Query("authz")
+	if authz := ctx.GetHeader("Authorization"); strings.HasPrefix(authz, "Bearer ") {
+		strToken = strings.TrimPrefix(authz, "Bearer ")
+	}
+	if strToken == "" {
+		return nil, errors.Wrap(errStagedUploadUnauthorized, "a token is required")
+	}
+	issuer, err := config.GetServerIssuerURL()
+	if err != nil {
+		return nil, err
+	}
+	unverified, err := jwt.Parse([]byte(strToken), jwt.WithVerify(false))
+	if err != nil {
+		return nil, errors.Wrap(errStagedUploadUnauthorized, "invalid token")
+	}
+	if unverified.Issuer() != issuer {
+		return nil, errors.Wrapf(errStagedUploadForbidden, "tokens must be issued by %s", issuer)
+	}
+	keys, err := stagedUploadIssuerKeys(issuer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the keys of the issuer %s", issuer)
+	}
+	tok, err := jwt.Parse([]byte(strToken), jwt.WithKeySet(keys), jwt.WithValidate(true))
+	if err != nil {
+		return nil, errors.Wrapf(errStagedUploadUnauthorized, "token verification failed: %v", err)
+	}
+	audiences := tok.Audience()
+	if !slices.Contains(audiences, config.GetServerAudience()) && !slices.Contains(audiences, wlcgAnyAudience) {
+		return nil, errors.Wrap(errStagedUploadForbidden, "the token is not intended for this origin")
+	}
+	return tok, nil
+}
+
+// Set up the staging area for uploads through the origin's web API, if enabled.  Uploads
+// staged before a restart can still be published.
+func LaunchUploadStaging(ctx context.Context, egrp *errgroup.Group) error {
+	if !param.Origin_EnableUploadStaging.GetBool() {
+		return nil
+	}
+	if param.Origin_StorageType.GetString() != string(server_structs.OriginStoragePosix) {
+		return errors.Errorf("Origin.EnableUploadStaging is only supported for the %s storage type", server_structs.OriginStoragePosix)
+	}
+	if param.Origin_Multiuser.GetBool() {
+		return errors.New("Origin.EnableUploadStaging is not supported with Origin.Multiuser")
+	}
+	staging := &uploadStaging{
+		root:     param.Origin_UploadStagingLocation.GetString(),
+		lifetime: param.Origin_UploadStagingLifetime.GetDuration(),
+		uploads:  make(map[string]*StagedUpload),
+		chunked:  make(map[string]*chunkedUpload),
+	}
+	if err := os.MkdirAll(staging.root, 0750); err != nil {
+		return errors.Wrap(err, "failed to create the upload staging directory")
+	}
+	if err := staging.load(); err != nil {
+		return errors.Wrap(err, "failed to load the staged uploads")
+	}
+	staging.expire(time.Now())
+
+	egrp.Go(func() error {
+		ticker := time.NewTicker(uploadStagingExpiryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case now := <-ticker.C:
+				staging.expire(now)
+			}
+		}
+	})
+
+	activeStaging.Store(staging)
+	log.Infoln("Upload staging enabled; uploads through the web API are kept in", staging.root, "until published")
+	return nil
+}
+
+func abortStagedUploadError(ctx *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, errStagedUploadNotFound) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, errStagedUploadBadDigest) {
+		status = http.StatusBadRequest
+	} else if errors.Is(err, errStagedUploadBadRequest) {
+		status = http.StatusBadRequest
+	} else if errors.Is(err, errStagedUploadMismatch) || errors.Is(err, errStagedUploadIncomplete) {
+		status = http.StatusConflict
+	} else if errors.Is(err, errStagedUploadUnauthorized) {
+		status = http.StatusUnauthorized
+	} else if errors.Is(err, errStagedUploadForbidden) {
+		status = http.StatusForbidden
+	} else {
+		log.Errorln("Staged upload request failed:", err)
+	}
+	ctx.AbortWithStatusJSON(status, server_structs.SimpleApiResp{
+		Status: server_structs.RespFailed,
+		Msg:    err.Error(),
+	})
+}
+
+// The upload staging area and the object path of the request, aborting the request if
+// staging has not started, the object isn't in a writable export, or the token doesn't
+// grant one of the scopes on the object
+func stagedUploadRequest(ctx *gin.Context, scopes ...token_scopes.TokenScope) (*uploadStaging, string) {
+	staging := activeUploadStaging()
+	if staging == nil {
+		ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Upload staging is not running",
+		})
+		return nil, ""
+	}
+	objectPath, err := cleanVersionedPath(ctx.Param("path"))
+	if err != nil {
+		abortStagedUploadError(ctx, errors.Wrap(errStagedUploadForbidden, err.Error()))
+		return nil, ""
+	}
+	if len(scopes) == 0 {
+		// Overwriting an object requires the modify scope
+		scopes = []token_scopes.TokenScope{token_scopes.Storage_Modify}
+		if _, err := os.Stat(objectStoragePath(objectPath)); errors.Is(err, os.ErrNotExist) {
+			scopes = append(scopes, token_scopes.Storage_Create)
+		}
+	}
+	if err = authorizeStagedUpload(ctx, objectPath, scopes...); err != nil {
+		abortStagedUploadError(ctx, err)
+		return nil, ""
+	}
+	return staging, objectPath
+}
+
+func handleStageUpload(ctx *gin.Context) {
+	staging, objectPath := stagedUploadRequest(ctx, token_scopes.Storage_Create, token_scopes.Storage_Modify)
+	if staging == nil {
+		return
+	}
+	record, err := staging.stage(objectPath, ctx.Request.Body)
+	if err != nil {
+		abortStagedUploadError(ctx, err)
+		return
+	}
+	ctx.Header("Digest", record.digestHeader())
+	ctx.JSON(http.StatusCreated, record)
+}
+
+func handlePublishUpload(ctx *gin.Context) {
+	staging, objectPath := stagedUploadRequest(ctx)
+	if staging == nil {
+		return
+	}
+	record, err := staging.publish(objectPath, ctx.GetHeader("Digest"))
+	if err != nil {
+		abortStagedUploadError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, record)
+}
+
+func handleDiscardUpload(ctx *gin.Context) {
+	staging, objectPath := stagedUploadRequest(ctx, token_scopes.Storage_Create, token_scopes.Storage_Modify)
+	if staging == nil {
+		return
+	}
+	if err := staging.discard(objectPath); err != nil {
+		abortStagedUploadError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK})
+}
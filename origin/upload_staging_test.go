@@ -0,0 +1,212 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	stagingTestIssuer   = "https://issuer.example.org"
+	stagingTestAudience = "https://origin.example.org"
+)
+
+// Set up upload staging for the writable export of /test, returning the staging area,
+// the directory backing the export, and a function creating tokens for the origin
+func setupUploadStaging(t *testing.T) (*uploadStaging, string, func(scope string) string) {
+	storageDir := setupVersioning(t, 3)
+	viper.Set("Server.IssuerUrl", stagingTestIssuer)
+	viper.Set("Origin.AudienceURL", stagingTestAudience)
+
+	raw, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	key, err := jwk.FromRaw(raw)
+	require.NoError(t, err)
+	require.NoError(t, jwk.AssignKeyID(key))
+	pubKey, err := key.PublicKey()
+	require.NoError(t, err)
+	require.NoError(t, pubKey.Set(jwk.AlgorithmKey, jwa.ES256))
+	keys := jwk.NewSet()
+	require.NoError(t, keys.AddKey(pubKey))
+	stagedUploadIssuerKeys = func(issuer string) (jwk.Set, error) { return keys, nil }
+
+	staging := &uploadStaging{
+		root:     t.TempDir(),
+		lifetime: time.Hour,
+		uploads:  make(map[string]*StagedUpload),
+	}
+	activeStaging.Store(staging)
+	t.Cleanup(func() {
+		stagedUploadIssuerKeys = getStagedUploadIssuerKeys
+		activeStaging.Store(nil)
+	})
+
+	newToken := func(scope string) string {
+		tok, err := jwt.NewBuilder().
+			Issuer(stagingTestIssuer).
+			Audience([]string{stagingTestAudience}).
+			Subject("tester").
+			IssuedAt(time.Now()).
+			Expiration(time.Now().Add(time.Minute)).
+			Claim("scope", scope).
+			Build()
+		require.NoError(t, err)
+		signed, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, key))
+		require.NoError(t, err)
+		return string(signed)
+	}
+	return staging, storageDir, newToken
+}
+
+func TestUploadStagingAPI(t *testing.T) {
+	staging, storageDir, newToken := setupUploadStaging(t)
+	router := gin.New()
+	router.PUT("/staging/*path", handleStageUpload)
+	router.DELETE("/staging/*path", handleDiscardUpload)
+	router.POST("/publish/*path", handlePublishUpload)
+	do := func(method, target, tok, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if tok != "" {
+			req.Header.Set("Authorization", "Bearer "+tok)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	sha256Digest := func(contents string) string {
+		sum := sha256.Sum256([]byte(contents))
+		return "sha-256=" + base64.StdEncoding.EncodeToString(sum[:])
+	}
+	objectFile := filepath.Join(storageDir, "dir", "foo.txt")
+	createToken := newToken("storage.create:/dir")
+
+	t.Run("unauthorized", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodPut, "/staging/test/dir/foo.txt", "", "hello", nil).Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/staging/test/dir/foo.txt", newToken("storage.read:/"), "hello", nil).Code)
+		// Scopes are relative to the export
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/staging/test/other.txt", createToken, "hello", nil).Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/staging/elsewhere/foo.txt", newToken("storage.create:/"), "hello", nil).Code)
+	})
+
+	t.Run("checksum-mismatch", func(t *testing.T) {
+		w := do(http.MethodPut, "/staging/test/dir/foo.txt", createToken, "hello", nil)
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Header().Get("Digest"), sha256Digest("hello"))
+		_, err := os.Stat(objectFile)
+		assert.ErrorIs(t, err, os.ErrNotExist, "a staged upload must not be in the namespace")
+
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/publish/test/dir/foo.txt", createToken, "", nil).Code)
+		assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/publish/test/dir/foo.txt", createToken, "", map[string]string{"Digest": sha256Digest("goodbye")}).Code)
+		// The corrupted upload is discarded
+		assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/publish/test/dir/foo.txt", createToken, "", map[string]string{"Digest": sha256Digest("hello")}).Code)
+		_, err = os.Stat(objectFile)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		require.Equal(t, http.StatusCreated, do(http.MethodPut, "/staging/test/dir/foo.txt", createToken, "hello", nil).Code)
+		sum := sha256.Sum256([]byte("hello"))
+		w := do(http.MethodPost, "/publish/test/dir/foo.txt", createToken, "", map[string]string{"Digest": "SHA-256=" + hex.EncodeToString(sum[:])})
+		require.Equal(t, http.StatusOK, w.Code)
+		record := StagedUpload{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &record))
+		assert.Equal(t, "/test/dir/foo.txt", record.Path)
+		assert.Equal(t, int64(5), record.Size)
+		contents, err := os.ReadFile(objectFile)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(contents))
+		assert.Empty(t, staging.uploads)
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		require.Equal(t, http.StatusCreated, do(http.MethodPut, "/staging/test/dir/foo.txt", createToken, "first", nil).Code)
+		// Staging the object again replaces the previous upload
+		require.Equal(t, http.StatusCreated, do(http.MethodPut, "/staging/test/dir/foo.txt", createToken, "second", nil).Code)
+		entries, err := os.ReadDir(staging.root)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2, "only the latest upload and its record should be staged")
+
+		// Replacing an existing object requires the modify scope
+		headers := map[string]string{"Digest": "adler32=00000000, " + sha256Digest("second")}
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/publish/test/dir/foo.txt", createToken, "", headers).Code)
+		modifyToken := newToken("storage.modify:/")
+		assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/publish/test/dir/foo.txt", modifyToken, "", headers).Code)
+		require.Equal(t, http.StatusCreated, do(http.MethodPut, "/staging/test/dir/foo.txt", createToken, "second", nil).Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodPost, "/publish/test/dir/foo.txt", modifyToken, "", map[string]string{"Digest": sha256Digest("second")}).Code)
+		contents, err := os.ReadFile(objectFile)
+		require.NoError(t, err)
+		assert.Equal(t, "second", string(contents))
+	})
+
+	t.Run("discard", func(t *testing.T) {
+		require.Equal(t, http.StatusCreated, do(http.MethodPut, "/staging/test/dir/bar.txt", createToken, "bar", nil).Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/staging/test/dir/bar.txt", createToken, "", nil).Code)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/staging/test/dir/bar.txt", createToken, "", nil).Code)
+		entries, err := os.ReadDir(staging.root)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestUploadStagingRestart(t *testing.T) {
+	staging, _, _ := setupUploadStaging(t)
+	_, err := staging.stage("/test/foo.txt", strings.NewReader("foo"))
+	require.NoError(t, err)
+	old, err := staging.stage("/test/bar.txt", strings.NewReader("bar"))
+	require.NoError(t, err)
+	// An upload interrupted by the restart
+	require.NoError(t, os.WriteFile(filepath.Join(staging.root, "partial"+uploadStagingPartialExt), []byte("ba"), 0644))
+
+	restarted := &uploadStaging{root: staging.root, lifetime: time.Hour, uploads: make(map[string]*StagedUpload)}
+	require.NoError(t, restarted.load())
+	require.Len(t, restarted.uploads, 2)
+	assert.Equal(t, old.Digests, restarted.uploads["/test/bar.txt"].Digests)
+	_, err = os.Stat(filepath.Join(staging.root, "partial"+uploadStagingPartialExt))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	restarted.expire(time.Now().Add(2 * time.Hour))
+	assert.Empty(t, restarted.uploads)
+	entries, err := os.ReadDir(staging.root)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
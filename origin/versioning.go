@@ -0,0 +1,408 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+type (
+	// A retained version of an object in a writable export
+	ObjectVersion struct {
+		VersionId string    `json:"versionId"`
+		Size      int64     `json:"size"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+
+	objectVersionsResp struct {
+		Path     string          `json:"path"`
+		Versions []ObjectVersion `json:"versions"`
+	}
+
+	restoreVersionReq struct {
+		Path      string `json:"path" binding:"required"`
+		VersionId string `json:"versionId" binding:"required"`
+	}
+)
+
+const (
+	// File in each object's version directory recording the object path
+	versionObjectFile = "object"
+	// Layout of version IDs; they sort in creation order
+	versionIdLayout = "20060102T150405.000000000Z"
+	// How often versions exceeding the retention policy are removed
+	versionGCInterval = time.Hour
+)
+
+var (
+	errVersionNotFound = errors.New("object version not found")
+
+	versionIdRegexp = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}\.[0-9]{9}Z$`)
+	// Serializes snapshots, restores and garbage collection of the version store
+	versionsMutex sync.Mutex
+)
+
+// Clean an object path and check that it falls within one of the origin's writable exports
+func cleanVersionedPath(objectPath string) (string, error) {
+	objectPath = path.Clean("/" + objectPath)
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		return "", err
+	}
+	for _, export := range exports {
+		prefix := path.Clean(export.FederationPrefix)
+		if export.Capabilities.Writes && (objectPath == prefix || strings.HasPrefix(objectPath, prefix+"/")) {
+			return objectPath, nil
+		}
+	}
+	return "", errors.Errorf("%s is not in a writable export of this origin", objectPath)
+}
+
+// The directory holding the versions of an object.  Directories are keyed on a hash
+// of the object path so that version files never collide with the object namespace.
+func objectVersionsDir(objectPath string) string {
+	sum := sha256.Sum256([]byte(objectPath))
+	digest := hex.EncodeToString(sum[:])
+	return filepath.Join(param.Origin_VersionsLocation.GetString(), digest[:2], digest)
+}
+
+// The location of the object in the origin's POSIX storage
+func objectStoragePath(objectPath string) string {
+	return filepath.Join(param.Xrootd_Mount.GetString(), filepath.FromSlash(objectPath))
+}
+
+// Copy a file to dest via a temporary file in the same directory, so that
+// dest is replaced atomically
+func copyFileAtomic(src string, dest string, perm fs.FileMode) (err error) {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmpFile.Close()
+			os.Remove(tmpFile.Name())
+		}
+	}()
+	if _, err = io.Copy(tmpFile, srcFile); err != nil {
+		return err
+	}
+	if err = tmpFile.Sync(); err != nil {
+		return err
+	}
+	if err = tmpFile.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), dest)
+}
+
+// Retain the current contents of an object as a new version
+func snapshotObject(objectPath string) (version ObjectVersion, err error) {
+	versionsMutex.Lock()
+	defer versionsMutex.Unlock()
+	return snapshotObjectLocked(objectPath)
+}
+
+func snapshotObjectLocked(objectPath string) (version ObjectVersion, err error) {
+	storagePath := objectStoragePath(objectPath)
+	info, err := os.Stat(storagePath)
+	if err != nil {
+		return
+	}
+	if !info.Mode().IsRegular() {
+		err = errors.Errorf("%s is not a regular file", objectPath)
+		return
+	}
+
+	versionsDir := objectVersionsDir(objectPath)
+	if err = os.MkdirAll(versionsDir, 0750); err != nil {
+		return
+	}
+	objectFile := filepath.Join(versionsDir, versionObjectFile)
+	if _, statErr := os.Stat(objectFile); statErr != nil {
+		if err = os.WriteFile(objectFile, []byte(objectPath), 0640); err != nil {
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	version.VersionId = now.Format(versionIdLayout)
+	if err = copyFileAtomic(storagePath, filepath.Join(versionsDir, version.VersionId), 0640); err != nil {
+		err = errors.Wrapf(err, "failed to retain version of %s", objectPath)
+		return
+	}
+	version.Size = info.Size()
+	version.CreatedAt = now
+	log.Debugf("Retained version %s of %s", version.VersionId, objectPath)
+
+	if pruneErr := pruneVersionsDir(versionsDir, now); pruneErr != nil {
+		log.Warningln("Failed to remove expired versions of", objectPath, ":", pruneErr)
+	}
+	return
+}
+
+// List the versions in a version directory, newest first
+func readVersionsDir(versionsDir string) ([]ObjectVersion, error) {
+	entries, err := os.ReadDir(versionsDir)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]ObjectVersion, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !versionIdRegexp.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		createdAt, err := time.Parse(versionIdLayout, entry.Name())
+		if err != nil {
+			continue
+		}
+		versions = append(versions, ObjectVersion{VersionId: entry.Name(), Size: info.Size(), CreatedAt: createdAt})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].VersionId > versions[j].VersionId })
+	return versions, nil
+}
+
+// List the retained versions of an object, newest first
+func listObjectVersions(objectPath string) ([]ObjectVersion, error) {
+	versions, err := readVersionsDir(objectVersionsDir(objectPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return []ObjectVersion{}, nil
+	}
+	return versions, err
+}
+
+// Replace the contents of an object with a retained version.  The restored contents
+// become the newest version, so the current contents (retained when they were
+// written) and the restored contents are both kept.
+func restoreObjectVersion(objectPath string, versionId string) (version ObjectVersion, err error) {
+	if !versionIdRegexp.MatchString(versionId) {
+		err = errors.Wrapf(errVersionNotFound, "invalid version ID %q", versionId)
+		return
+	}
+	versionsMutex.Lock()
+	defer versionsMutex.Unlock()
+
+	versionFile := filepath.Join(objectVersionsDir(objectPath), versionId)
+	if _, err = os.Stat(versionFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = errors.Wrapf(errVersionNotFound, "version %s of %s does not exist", versionId, objectPath)
+		}
+		return
+	}
+
+	storagePath := objectStoragePath(objectPath)
+	perm := fs.FileMode(0644)
+	existing, statErr := os.Stat(storagePath)
+	if statErr == nil {
+		perm = existing.Mode().Perm()
+	} else if err = os.MkdirAll(filepath.Dir(storagePath), 0755); err != nil {
+		return
+	}
+	if err = copyFileAtomic(versionFile, storagePath, perm); err != nil {
+		err = errors.Wrapf(err, "failed to restore version %s of %s", versionId, objectPath)
+		return
+	}
+	if statErr == nil {
+		if err = preserveOwnership(existing, storagePath); err != nil {
+			return
+		}
+	}
+	log.Infof("Restored version %s of %s", versionId, objectPath)
+	return snapshotObjectLocked(objectPath)
+}
+
+// Remove versions exceeding Origin.MaxVersions or older than Origin.VersionRetention.
+// The newest version is always kept.
+func pruneVersionsDir(versionsDir string, now time.Time) error {
+	versions, err := readVersionsDir(versionsDir)
+	if err != nil {
+		return err
+	}
+	maxVersions := param.Origin_MaxVersions.GetInt()
+	retention := param.Origin_VersionRetention.GetDuration()
+	for idx, version := range versions {
+		if idx == 0 {
+			continue
+		}
+		expired := retention > 0 && now.Sub(version.CreatedAt) > retention
+		if expired || (maxVersions > 0 && idx >= maxVersions) {
+			if err := os.Remove(filepath.Join(versionsDir, version.VersionId)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			log.Debugf("Removed version %s in %s", version.VersionId, versionsDir)
+		}
+	}
+	return nil
+}
+
+// Apply the retention policy to every object in the version store
+func collectVersionGarbage() error {
+	versionsMutex.Lock()
+	defer versionsMutex.Unlock()
+
+	root := param.Origin_VersionsLocation.GetString()
+	buckets, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, bucket := range buckets {
+		if !bucket.IsDir() {
+			continue
+		}
+		objectDirs, err := os.ReadDir(filepath.Join(root, bucket.Name()))
+		if err != nil {
+			return err
+		}
+		for _, objectDir := range objectDirs {
+			if !objectDir.IsDir() {
+				continue
+			}
+			if err := pruneVersionsDir(filepath.Join(root, bucket.Name(), objectDir.Name()), now); err != nil {
+				log.Warningln("Failed to remove expired object versions:", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Set up object versioning for the origin, if enabled.  Versions are retained as
+// the write notifications started by LaunchWriteNotifications arrive.
+func LaunchObjectVersioning(ctx context.Context, egrp *errgroup.Group) error {
+	if !param.Origin_EnableVersioning.GetBool() {
+		return nil
+	}
+	if param.Origin_StorageType.GetString() != string(server_structs.OriginStoragePosix) {
+		return errors.Errorf("Origin.EnableVersioning is only supported for the %s storage type", server_structs.OriginStoragePosix)
+	}
+	if err := os.MkdirAll(param.Origin_VersionsLocation.GetString(), 0750); err != nil {
+		return errors.Wrap(err, "failed to create the origin versions directory")
+	}
+
+	egrp.Go(func() error {
+		ticker := time.NewTicker(versionGCInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				if err := collectVersionGarbage(); err != nil {
+					log.Warningln("Failed to remove expired object versions:", err)
+				}
+			}
+		}
+	})
+	log.Infoln("Object versioning enabled; versions are retained in", param.Origin_VersionsLocation.GetString())
+	return nil
+}
+
+func handleListVersions(ctx *gin.Context) {
+	objectPath, err := cleanVersionedPath(ctx.Query("path"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+		return
+	}
+	versions, err := listObjectVersions(objectPath)
+	if err != nil {
+		log.Errorln("Failed to list versions of", objectPath, ":", err)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to list object versions",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, objectVersionsResp{Path: objectPath, Versions: versions})
+}
+
+func handleRestoreVersion(ctx *gin.Context) {
+	req := restoreVersionReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid request data format: " + err.Error(),
+		})
+		return
+	}
+	objectPath, err := cleanVersionedPath(req.Path)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+		return
+	}
+	version, err := restoreObjectVersion(objectPath, req.VersionId)
+	if errors.Is(err, errVersionNotFound) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+		return
+	} else if err != nil {
+		log.Errorln("Failed to restore version", req.VersionId, "of", objectPath, ":", err)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to restore object version",
+		})
+		return
+	}
+	log.Infof("Version %s of %s restored by %s", req.VersionId, objectPath, ctx.GetString("User"))
+	ctx.JSON(http.StatusOK, version)
+}
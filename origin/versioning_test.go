@@ -0,0 +1,158 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+// Configure a writable POSIX export of /test, returning the directory that
+// backs the export in the origin's storage
+func setupVersioning(t *testing.T, maxVersions int) string {
+	server_utils.ResetTestState()
+	t.Cleanup(server_utils.ResetTestState)
+
+	mount := t.TempDir()
+	storageDir := filepath.Join(mount, "test")
+	require.NoError(t, os.MkdirAll(storageDir, 0755))
+	viper.Set("Origin.StorageType", "posix")
+	viper.Set("Origin.ExportVolumes", []string{storageDir + ":/test"})
+	viper.Set("Origin.EnableWrites", true)
+	viper.Set("Xrootd.Mount", mount)
+	viper.Set("Origin.VersionsLocation", filepath.Join(t.TempDir(), "versions"))
+	viper.Set("Origin.MaxVersions", maxVersions)
+	viper.Set("Origin.VersionRetention", "720h")
+	return storageDir
+}
+
+func TestObjectVersioning(t *testing.T) {
+	storageDir := setupVersioning(t, 3)
+	objectFile := filepath.Join(storageDir, "foo.txt")
+
+	write := func(contents string) ObjectVersion {
+		require.NoError(t, os.WriteFile(objectFile, []byte(contents), 0644))
+		version, err := snapshotObject("/test/foo.txt")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(contents)), version.Size)
+		return version
+	}
+
+	first := write("first")
+	second := write("second contents")
+	versions, err := listObjectVersions("/test/foo.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, second.VersionId, versions[0].VersionId)
+	assert.Equal(t, first.VersionId, versions[1].VersionId)
+
+	t.Run("restore", func(t *testing.T) {
+		restored, err := restoreObjectVersion("/test/foo.txt", first.VersionId)
+		require.NoError(t, err)
+		contents, err := os.ReadFile(objectFile)
+		require.NoError(t, err)
+		assert.Equal(t, "first", string(contents))
+
+		// The restored contents become the newest version; the replaced ones are kept
+		versions, err := listObjectVersions("/test/foo.txt")
+		require.NoError(t, err)
+		require.Len(t, versions, 3)
+		assert.Equal(t, restored.VersionId, versions[0].VersionId)
+		assert.Equal(t, second.VersionId, versions[1].VersionId)
+	})
+
+	t.Run("restore-missing-version", func(t *testing.T) {
+		_, err := restoreObjectVersion("/test/foo.txt", "20000101T000000.000000000Z")
+		assert.ErrorIs(t, err, errVersionNotFound)
+		_, err = restoreObjectVersion("/test/foo.txt", "../../etc/passwd")
+		assert.ErrorIs(t, err, errVersionNotFound)
+	})
+
+	t.Run("max-versions", func(t *testing.T) {
+		write("third")
+		write("fourth")
+		versions, err := listObjectVersions("/test/foo.txt")
+		require.NoError(t, err)
+		require.Len(t, versions, 3)
+		assert.Equal(t, int64(len("fourth")), versions[0].Size)
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		versionsDir := objectVersionsDir("/test/foo.txt")
+		require.NoError(t, pruneVersionsDir(versionsDir, time.Now().Add(time.Hour*24*365)))
+		// Even expired, the newest version is always kept
+		versions, err := listObjectVersions("/test/foo.txt")
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.Equal(t, int64(len("fourth")), versions[0].Size)
+	})
+
+	t.Run("unversioned-object", func(t *testing.T) {
+		versions, err := listObjectVersions("/test/other.txt")
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+}
+
+func TestCleanVersionedPath(t *testing.T) {
+	setupVersioning(t, 10)
+
+	objectPath, err := cleanVersionedPath("test/dir/../foo.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "/test/foo.txt", objectPath)
+
+	_, err = cleanVersionedPath("/testing/foo.txt")
+	assert.Error(t, err)
+	_, err = cleanVersionedPath("/test/../etc/passwd")
+	assert.Error(t, err)
+}
+
+func TestWriteNotifications(t *testing.T) {
+	storageDir := setupVersioning(t, 10)
+
+	objectPath, ok := parseWriteNotification("closew /test/dir/my file.txt")
+	assert.True(t, ok)
+	assert.Equal(t, "/test/dir/my file.txt", objectPath)
+	_, ok = parseWriteNotification("closer /test/foo.txt")
+	assert.False(t, ok)
+	_, ok = parseWriteNotification("closew")
+	assert.False(t, ok)
+
+	require.NoError(t, os.WriteFile(filepath.Join(storageDir, "foo.txt"), []byte("hello"), 0644))
+	notifications := strings.Join([]string{
+		"closew /test/foo.txt",
+		"closew /test/missing.txt",
+		"closew /pelican/monitoring/selfTest/self-test.txt",
+	}, "\n")
+	require.NoError(t, handleWriteNotifications(strings.NewReader(notifications)))
+
+	versions, err := listObjectVersions("/test/foo.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, int64(5), versions[0].Size)
+}
@@ -0,0 +1,69 @@
+//go:build !windows
+
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/config"
+)
+
+// Create the FIFO that XRootD writes completed-write notifications to and open it for reading.
+//
+// The FIFO is opened read-write so that the open does not block waiting for XRootD and reads
+// do not see EOF when XRootD restarts; closing the returned file stops any pending read.
+func openWriteNotifyFifo(fifoPath string) (*os.File, error) {
+	if err := os.Remove(fifoPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := syscall.Mkfifo(fifoPath, 0600); err != nil {
+		return nil, err
+	}
+	uid, err := config.GetDaemonUID()
+	if err != nil {
+		return nil, err
+	}
+	gid, err := config.GetDaemonGID()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chown(fifoPath, uid, gid); err != nil {
+		return nil, errors.Wrapf(err, "unable to change ownership of %s to the daemon user", fifoPath)
+	}
+	return os.OpenFile(fifoPath, os.O_RDWR, os.ModeNamedPipe)
+}
+
+// Give a restored object the same owner as the object it replaced, since
+// the origin's XRootD daemon may run as a different user than this process
+func preserveOwnership(previous fs.FileInfo, restoredPath string) error {
+	stat, ok := previous.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if int(stat.Uid) == os.Getuid() && int(stat.Gid) == os.Getgid() {
+		return nil
+	}
+	return errors.Wrapf(os.Chown(restoredPath, int(stat.Uid), int(stat.Gid)), "unable to change ownership of %s", restoredPath)
+}
@@ -0,0 +1,36 @@
+//go:build windows
+
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"io/fs"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+func openWriteNotifyFifo(fifoPath string) (*os.File, error) {
+	return nil, errors.New("write notifications are not supported on Windows")
+}
+
+func preserveOwnership(previous fs.FileInfo, restoredPath string) error {
+	return nil
+}
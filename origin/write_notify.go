@@ -0,0 +1,115 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+// Name of the FIFO, under Origin.RunLocation, that XRootD writes completed-write notifications to
+const writeNotifyFifoName = "writes.fifo"
+
+// Path of the FIFO that XRootD writes completed-write notifications to
+func WriteNotifyFifoPath() string {
+	return filepath.Join(param.Origin_RunLocation.GetString(), writeNotifyFifoName)
+}
+
+// Report whether any origin feature needs XRootD to report completed writes
+func writeNotificationsEnabled() bool {
+	return param.Origin_EnableVersioning.GetBool() || param.Origin_EnableUploadScan.GetBool()
+}
+
+// Extract the object path from a notification written by XRootD's ofs.notify
+// directive; returns false for notifications other than completed writes
+func parseWriteNotification(line string) (string, bool) {
+	fields := strings.Fields(line)
+	for idx, field := range fields {
+		if field == "closew" && idx+1 < len(fields) {
+			return path.Clean("/" + strings.Join(fields[idx+1:], " ")), true
+		}
+	}
+	return "", false
+}
+
+// Act on an object whose write just completed.  When upload scanning is enabled, the
+// object is withheld until the scan passes and versioned once released; otherwise a
+// version is retained immediately.
+func handleObjectWritten(objectPath string) {
+	if scans := activeUploadScans(); scans != nil {
+		if err := scans.submit(objectPath); err != nil {
+			log.Errorln("Failed to queue", objectPath, "for an upload scan:", err)
+		}
+		return
+	}
+	if _, err := snapshotObject(objectPath); err != nil {
+		log.Errorln("Failed to retain a version of", objectPath, ":", err)
+	}
+}
+
+// Process each completed write that XRootD reports
+func handleWriteNotifications(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		objectPath, ok := parseWriteNotification(scanner.Text())
+		if !ok {
+			continue
+		}
+		if _, err := cleanVersionedPath(objectPath); err != nil {
+			// Internal paths such as the self-test files are neither versioned nor scanned
+			continue
+		}
+		handleObjectWritten(objectPath)
+	}
+	return scanner.Err()
+}
+
+// Start processing the completed-write notifications used by object versioning and
+// upload scanning.  Must be invoked after LaunchObjectVersioning and LaunchUploadScanning
+// and before XRootD is launched, since XRootD writes its notifications to the FIFO created here.
+func LaunchWriteNotifications(ctx context.Context, egrp *errgroup.Group) error {
+	if !writeNotificationsEnabled() {
+		return nil
+	}
+	fifo, err := openWriteNotifyFifo(WriteNotifyFifoPath())
+	if err != nil {
+		return errors.Wrap(err, "failed to set up the write notification FIFO")
+	}
+	egrp.Go(func() error {
+		<-ctx.Done()
+		return fifo.Close()
+	})
+	egrp.Go(func() error {
+		if err := handleWriteNotifications(fifo); err != nil && ctx.Err() == nil {
+			log.Errorln("Stopped processing write notifications:", err)
+		}
+		return nil
+	})
+	return nil
+}
@@ -150,25 +150,41 @@ var (
 	Cache_LocalRoot = StringParam{"Cache.LocalRoot"}
 	Cache_LowWatermark = StringParam{"Cache.LowWatermark"}
 	Cache_NamespaceLocation = StringParam{"Cache.NamespaceLocation"}
+	Cache_Region = StringParam{"Cache.Region"}
 	Cache_RunLocation = StringParam{"Cache.RunLocation"}
 	Cache_SentinelLocation = StringParam{"Cache.SentinelLocation"}
 	Cache_StorageLocation = StringParam{"Cache.StorageLocation"}
 	Cache_Url = StringParam{"Cache.Url"}
 	Cache_XRootDPrefix = StringParam{"Cache.XRootDPrefix"}
+	Client_CompatibilityLevel = StringParam{"Client.CompatibilityLevel"}
+	Client_DaemonSocket = StringParam{"Client.DaemonSocket"}
+	Client_MaxRate = StringParam{"Client.MaxRate"}
+	Client_S3AccessKeyfile = StringParam{"Client.S3AccessKeyfile"}
+	Client_S3Region = StringParam{"Client.S3Region"}
+	Client_S3SecretKeyfile = StringParam{"Client.S3SecretKeyfile"}
+	Client_S3ServiceUrl = StringParam{"Client.S3ServiceUrl"}
+	Client_S3UrlStyle = StringParam{"Client.S3UrlStyle"}
+	ConfigInstance = StringParam{"ConfigInstance"}
+	ConfigSite = StringParam{"ConfigSite"}
 	Director_CacheSortMethod = StringParam{"Director.CacheSortMethod"}
+	Director_DNSHostname = StringParam{"Director.DNSHostname"}
 	Director_DbLocation = StringParam{"Director.DbLocation"}
 	Director_DefaultResponse = StringParam{"Director.DefaultResponse"}
 	Director_GeoIPLocation = StringParam{"Director.GeoIPLocation"}
 	Director_MaxMindKeyFile = StringParam{"Director.MaxMindKeyFile"}
 	Director_SupportContactEmail = StringParam{"Director.SupportContactEmail"}
 	Director_SupportContactUrl = StringParam{"Director.SupportContactUrl"}
+	Director_TopologyIssueWebhook = StringParam{"Director.TopologyIssueWebhook"}
+	Federation_DiscoveryCacheLocation = StringParam{"Federation.DiscoveryCacheLocation"}
 	Federation_DiscoveryUrl = StringParam{"Federation.DiscoveryUrl"}
 	Federation_TopologyDowntimeUrl = StringParam{"Federation.TopologyDowntimeUrl"}
 	Federation_TopologyNamespaceUrl = StringParam{"Federation.TopologyNamespaceUrl"}
 	Federation_TopologyUrl = StringParam{"Federation.TopologyUrl"}
 	IssuerKey = StringParam{"IssuerKey"}
+	IssuerKeyURI = StringParam{"IssuerKeyURI"}
 	Issuer_AuthenticationSource = StringParam{"Issuer.AuthenticationSource"}
 	Issuer_GroupFile = StringParam{"Issuer.GroupFile"}
+	Issuer_GroupScopeMappingFile = StringParam{"Issuer.GroupScopeMappingFile"}
 	Issuer_GroupSource = StringParam{"Issuer.GroupSource"}
 	Issuer_IssuerClaimValue = StringParam{"Issuer.IssuerClaimValue"}
 	Issuer_OIDCAuthenticationUserClaim = StringParam{"Issuer.OIDCAuthenticationUserClaim"}
@@ -187,6 +203,7 @@ var (
 	Logging_Cache_Scitokens = StringParam{"Logging.Cache.Scitokens"}
 	Logging_Cache_Xrd = StringParam{"Logging.Cache.Xrd"}
 	Logging_Cache_Xrootd = StringParam{"Logging.Cache.Xrootd"}
+	Logging_Format = StringParam{"Logging.Format"}
 	Logging_Level = StringParam{"Logging.Level"}
 	Logging_LogLocation = StringParam{"Logging.LogLocation"}
 	Logging_Origin_Cms = StringParam{"Logging.Origin.Cms"}
@@ -210,6 +227,7 @@ var (
 	OIDC_TokenEndpoint = StringParam{"OIDC.TokenEndpoint"}
 	OIDC_UserInfoEndpoint = StringParam{"OIDC.UserInfoEndpoint"}
 	Origin_DbLocation = StringParam{"Origin.DbLocation"}
+	Origin_ExportScanMaxRate = StringParam{"Origin.ExportScanMaxRate"}
 	Origin_ExportVolume = StringParam{"Origin.ExportVolume"}
 	Origin_FederationPrefix = StringParam{"Origin.FederationPrefix"}
 	Origin_GlobusClientIDFile = StringParam{"Origin.GlobusClientIDFile"}
@@ -234,17 +252,30 @@ var (
 	Origin_ScitokensUsernameClaim = StringParam{"Origin.ScitokensUsernameClaim"}
 	Origin_StoragePrefix = StringParam{"Origin.StoragePrefix"}
 	Origin_StorageType = StringParam{"Origin.StorageType"}
+	Origin_UploadScanICAPUrl = StringParam{"Origin.UploadScanICAPUrl"}
+	Origin_UploadScanLocation = StringParam{"Origin.UploadScanLocation"}
+	Origin_UploadStagingLocation = StringParam{"Origin.UploadStagingLocation"}
 	Origin_Url = StringParam{"Origin.Url"}
+	Origin_VersionsLocation = StringParam{"Origin.VersionsLocation"}
 	Origin_XRootDPrefix = StringParam{"Origin.XRootDPrefix"}
 	Origin_XRootServiceUrl = StringParam{"Origin.XRootServiceUrl"}
 	Plugin_Token = StringParam{"Plugin.Token"}
 	Registry_DbLocation = StringParam{"Registry.DbLocation"}
 	Registry_InstitutionsUrl = StringParam{"Registry.InstitutionsUrl"}
+	Registry_RegistrationChallenge = StringParam{"Registry.RegistrationChallenge"}
+	Registry_RegistrationChallengeSecretFile = StringParam{"Registry.RegistrationChallengeSecretFile"}
+	Registry_RegistrationChallengeSiteKey = StringParam{"Registry.RegistrationChallengeSiteKey"}
+	Registry_SMTPFrom = StringParam{"Registry.SMTPFrom"}
+	Registry_SMTPPasswordFile = StringParam{"Registry.SMTPPasswordFile"}
+	Registry_SMTPServer = StringParam{"Registry.SMTPServer"}
+	Registry_SMTPUsername = StringParam{"Registry.SMTPUsername"}
 	Server_ExternalWebUrl = StringParam{"Server.ExternalWebUrl"}
 	Server_Hostname = StringParam{"Server.Hostname"}
 	Server_IssuerHostname = StringParam{"Server.IssuerHostname"}
 	Server_IssuerJwks = StringParam{"Server.IssuerJwks"}
 	Server_IssuerUrl = StringParam{"Server.IssuerUrl"}
+	Server_LivenessProbeStrictness = StringParam{"Server.LivenessProbeStrictness"}
+	Server_ReadinessProbeStrictness = StringParam{"Server.ReadinessProbeStrictness"}
 	Server_SessionSecretFile = StringParam{"Server.SessionSecretFile"}
 	Server_TLSCACertificateDirectory = StringParam{"Server.TLSCACertificateDirectory"}
 	Server_TLSCACertificateFile = StringParam{"Server.TLSCACertificateFile"}
@@ -252,6 +283,7 @@ var (
 	Server_TLSCertificate = StringParam{"Server.TLSCertificate"}
 	Server_TLSCertificateChain = StringParam{"Server.TLSCertificateChain"}
 	Server_TLSKey = StringParam{"Server.TLSKey"}
+	Server_UIAccountDbLocation = StringParam{"Server.UIAccountDbLocation"}
 	Server_UIActivationCodeFile = StringParam{"Server.UIActivationCodeFile"}
 	Server_UIPasswordFile = StringParam{"Server.UIPasswordFile"}
 	Server_WebConfigFile = StringParam{"Server.WebConfigFile"}
@@ -269,13 +301,18 @@ var (
 	StagePlugin_MountPrefix = StringParam{"StagePlugin.MountPrefix"}
 	StagePlugin_OriginPrefix = StringParam{"StagePlugin.OriginPrefix"}
 	StagePlugin_ShadowOriginPrefix = StringParam{"StagePlugin.ShadowOriginPrefix"}
+	Tracing_Endpoint = StringParam{"Tracing.Endpoint"}
+	Tracing_Exporter = StringParam{"Tracing.Exporter"}
 	Xrootd_Authfile = StringParam{"Xrootd.Authfile"}
+	Xrootd_CacheConfigTemplate = StringParam{"Xrootd.CacheConfigTemplate"}
+	Xrootd_ConfigExtras = StringParam{"Xrootd.ConfigExtras"}
 	Xrootd_ConfigFile = StringParam{"Xrootd.ConfigFile"}
 	Xrootd_DetailedMonitoringHost = StringParam{"Xrootd.DetailedMonitoringHost"}
 	Xrootd_LocalMonitoringHost = StringParam{"Xrootd.LocalMonitoringHost"}
 	Xrootd_MacaroonsKeyFile = StringParam{"Xrootd.MacaroonsKeyFile"}
 	Xrootd_ManagerHost = StringParam{"Xrootd.ManagerHost"}
 	Xrootd_Mount = StringParam{"Xrootd.Mount"}
+	Xrootd_OriginConfigTemplate = StringParam{"Xrootd.OriginConfigTemplate"}
 	Xrootd_RobotsTxtFile = StringParam{"Xrootd.RobotsTxtFile"}
 	Xrootd_RunLocation = StringParam{"Xrootd.RunLocation"}
 	Xrootd_ScitokensConfig = StringParam{"Xrootd.ScitokensConfig"}
@@ -285,17 +322,21 @@ var (
 
 var (
 	Cache_DataLocations = StringSliceParam{"Cache.DataLocations"}
+	Cache_LocalHttpNetworks = StringSliceParam{"Cache.LocalHttpNetworks"}
 	Cache_MetaLocations = StringSliceParam{"Cache.MetaLocations"}
 	Cache_PermittedNamespaces = StringSliceParam{"Cache.PermittedNamespaces"}
 	ConfigLocations = StringSliceParam{"ConfigLocations"}
 	Director_CacheResponseHostnames = StringSliceParam{"Director.CacheResponseHostnames"}
 	Director_FilteredServers = StringSliceParam{"Director.FilteredServers"}
 	Director_OriginResponseHostnames = StringSliceParam{"Director.OriginResponseHostnames"}
+	Director_RateLimitAllowlist = StringSliceParam{"Director.RateLimitAllowlist"}
 	Director_X509ClientAuthenticationPrefixes = StringSliceParam{"Director.X509ClientAuthenticationPrefixes"}
 	Issuer_GroupRequirements = StringSliceParam{"Issuer.GroupRequirements"}
 	Monitoring_AggregatePrefixes = StringSliceParam{"Monitoring.AggregatePrefixes"}
+	Origin_ExperimentalCapabilities = StringSliceParam{"Origin.ExperimentalCapabilities"}
 	Origin_ExportVolumes = StringSliceParam{"Origin.ExportVolumes"}
 	Origin_ScitokensRestrictedPaths = StringSliceParam{"Origin.ScitokensRestrictedPaths"}
+	Origin_UploadScanCommand = StringSliceParam{"Origin.UploadScanCommand"}
 	Registry_AdminUsers = StringSliceParam{"Registry.AdminUsers"}
 	Server_Modules = StringSliceParam{"Server.Modules"}
 	Server_UIAdminUsers = StringSliceParam{"Server.UIAdminUsers"}
@@ -305,25 +346,49 @@ var (
 var (
 	Cache_BlocksToPrefetch = IntParam{"Cache.BlocksToPrefetch"}
 	Cache_Concurrency = IntParam{"Cache.Concurrency"}
+	Cache_ContentAdvertisementMaxObjects = IntParam{"Cache.ContentAdvertisementMaxObjects"}
+	Cache_DiskErrorThreshold = IntParam{"Cache.DiskErrorThreshold"}
+	Cache_DiskMinFreePercentage = IntParam{"Cache.DiskMinFreePercentage"}
+	Cache_DiskRefetchCount = IntParam{"Cache.DiskRefetchCount"}
+	Cache_LocalHttpPort = IntParam{"Cache.LocalHttpPort"}
 	Cache_Port = IntParam{"Cache.Port"}
+	Client_ChunkedUploadThreshold = IntParam{"Client.ChunkedUploadThreshold"}
+	Client_DaemonPort = IntParam{"Client.DaemonPort"}
+	Client_MaxDownloadSources = IntParam{"Client.MaxDownloadSources"}
 	Client_MaximumDownloadSpeed = IntParam{"Client.MaximumDownloadSpeed"}
 	Client_MinimumDownloadSpeed = IntParam{"Client.MinimumDownloadSpeed"}
+	Client_Nice = IntParam{"Client.Nice"}
+	Client_StripeSize = IntParam{"Client.StripeSize"}
+	Client_UploadChunkSize = IntParam{"Client.UploadChunkSize"}
+	Client_UploadConcurrency = IntParam{"Client.UploadConcurrency"}
 	Client_WorkerCount = IntParam{"Client.WorkerCount"}
 	Director_CachePresenceCapacity = IntParam{"Director.CachePresenceCapacity"}
+	Director_DNSPort = IntParam{"Director.DNSPort"}
 	Director_MaxStatResponse = IntParam{"Director.MaxStatResponse"}
 	Director_MinStatResponse = IntParam{"Director.MinStatResponse"}
+	Director_RateLimitPerIP = IntParam{"Director.RateLimitPerIP"}
+	Director_RateLimitPerIPBurst = IntParam{"Director.RateLimitPerIPBurst"}
+	Director_RateLimitPerToken = IntParam{"Director.RateLimitPerToken"}
+	Director_RateLimitPerTokenBurst = IntParam{"Director.RateLimitPerTokenBurst"}
 	Director_StatConcurrencyLimit = IntParam{"Director.StatConcurrencyLimit"}
 	LocalCache_HighWaterMarkPercentage = IntParam{"LocalCache.HighWaterMarkPercentage"}
 	LocalCache_LowWaterMarkPercentage = IntParam{"LocalCache.LowWaterMarkPercentage"}
 	MinimumDownloadSpeed = IntParam{"MinimumDownloadSpeed"}
 	Monitoring_PortHigher = IntParam{"Monitoring.PortHigher"}
 	Monitoring_PortLower = IntParam{"Monitoring.PortLower"}
+	Origin_ExportScanMaxFileRate = IntParam{"Origin.ExportScanMaxFileRate"}
+	Origin_ExportScanWorkers = IntParam{"Origin.ExportScanWorkers"}
+	Origin_MaxVersions = IntParam{"Origin.MaxVersions"}
 	Origin_Port = IntParam{"Origin.Port"}
+	Origin_S3GatewayPort = IntParam{"Origin.S3GatewayPort"}
+	Registry_KeyRecoveryApprovals = IntParam{"Registry.KeyRecoveryApprovals"}
+	Registry_RecoveryCodeCount = IntParam{"Registry.RecoveryCodeCount"}
 	Server_IssuerPort = IntParam{"Server.IssuerPort"}
 	Server_UILoginRateLimit = IntParam{"Server.UILoginRateLimit"}
 	Server_WebPort = IntParam{"Server.WebPort"}
 	Shoveler_PortHigher = IntParam{"Shoveler.PortHigher"}
 	Shoveler_PortLower = IntParam{"Shoveler.PortLower"}
+	Tracing_SamplingPercentage = IntParam{"Tracing.SamplingPercentage"}
 	Transport_MaxIdleConns = IntParam{"Transport.MaxIdleConns"}
 	Xrootd_DetailedMonitoringPort = IntParam{"Xrootd.DetailedMonitoringPort"}
 	Xrootd_ManagerPort = IntParam{"Xrootd.ManagerPort"}
@@ -332,20 +397,28 @@ var (
 )
 
 var (
+	Cache_EnableContentAdvertisement = BoolParam{"Cache.EnableContentAdvertisement"}
+	Cache_EnableDiskHealthCheck = BoolParam{"Cache.EnableDiskHealthCheck"}
+	Cache_EnableDiskSmartCheck = BoolParam{"Cache.EnableDiskSmartCheck"}
+	Cache_EnableLocalHttp = BoolParam{"Cache.EnableLocalHttp"}
 	Cache_EnableLotman = BoolParam{"Cache.EnableLotman"}
 	Cache_EnableOIDC = BoolParam{"Cache.EnableOIDC"}
 	Cache_EnableVoms = BoolParam{"Cache.EnableVoms"}
 	Cache_SelfTest = BoolParam{"Cache.SelfTest"}
 	Client_DisableHttpProxy = BoolParam{"Client.DisableHttpProxy"}
 	Client_DisableProxyFallback = BoolParam{"Client.DisableProxyFallback"}
+	Client_UseDaemon = BoolParam{"Client.UseDaemon"}
 	Debug = BoolParam{"Debug"}
 	Director_AssumePresenceAtSingleOrigin = BoolParam{"Director.AssumePresenceAtSingleOrigin"}
 	Director_CachesPullFromCaches = BoolParam{"Director.CachesPullFromCaches"}
 	Director_CheckCachePresence = BoolParam{"Director.CheckCachePresence"}
 	Director_CheckOriginPresence = BoolParam{"Director.CheckOriginPresence"}
 	Director_EnableBroker = BoolParam{"Director.EnableBroker"}
+	Director_EnableDNS = BoolParam{"Director.EnableDNS"}
 	Director_EnableOIDC = BoolParam{"Director.EnableOIDC"}
 	Director_EnableStat = BoolParam{"Director.EnableStat"}
+	Director_EnableTopologyIssueChecks = BoolParam{"Director.EnableTopologyIssueChecks"}
+	Director_RateLimitExemptCaches = BoolParam{"Director.RateLimitExemptCaches"}
 	DisableHttpProxy = BoolParam{"DisableHttpProxy"}
 	DisableProxyFallback = BoolParam{"DisableProxyFallback"}
 	Issuer_UserStripDomain = BoolParam{"Issuer.UserStripDomain"}
@@ -354,10 +427,12 @@ var (
 	Monitoring_MetricAuthorization = BoolParam{"Monitoring.MetricAuthorization"}
 	Monitoring_PromQLAuthorization = BoolParam{"Monitoring.PromQLAuthorization"}
 	Origin_DirectorTest = BoolParam{"Origin.DirectorTest"}
+	Origin_EnableArchiveMembers = BoolParam{"Origin.EnableArchiveMembers"}
 	Origin_EnableBroker = BoolParam{"Origin.EnableBroker"}
 	Origin_EnableCmsd = BoolParam{"Origin.EnableCmsd"}
 	Origin_EnableDirListing = BoolParam{"Origin.EnableDirListing"}
 	Origin_EnableDirectReads = BoolParam{"Origin.EnableDirectReads"}
+	Origin_EnableExportScan = BoolParam{"Origin.EnableExportScan"}
 	Origin_EnableFallbackRead = BoolParam{"Origin.EnableFallbackRead"}
 	Origin_EnableIssuer = BoolParam{"Origin.EnableIssuer"}
 	Origin_EnableListings = BoolParam{"Origin.EnableListings"}
@@ -365,7 +440,11 @@ var (
 	Origin_EnableOIDC = BoolParam{"Origin.EnableOIDC"}
 	Origin_EnablePublicReads = BoolParam{"Origin.EnablePublicReads"}
 	Origin_EnableReads = BoolParam{"Origin.EnableReads"}
+	Origin_EnableS3Gateway = BoolParam{"Origin.EnableS3Gateway"}
 	Origin_EnableUI = BoolParam{"Origin.EnableUI"}
+	Origin_EnableUploadScan = BoolParam{"Origin.EnableUploadScan"}
+	Origin_EnableUploadStaging = BoolParam{"Origin.EnableUploadStaging"}
+	Origin_EnableVersioning = BoolParam{"Origin.EnableVersioning"}
 	Origin_EnableVoms = BoolParam{"Origin.EnableVoms"}
 	Origin_EnableWrite = BoolParam{"Origin.EnableWrite"}
 	Origin_EnableWrites = BoolParam{"Origin.EnableWrites"}
@@ -373,6 +452,7 @@ var (
 	Origin_ScitokensMapSubject = BoolParam{"Origin.ScitokensMapSubject"}
 	Origin_SelfTest = BoolParam{"Origin.SelfTest"}
 	Registry_RequireCacheApproval = BoolParam{"Registry.RequireCacheApproval"}
+	Registry_RequireEmailVerification = BoolParam{"Registry.RequireEmailVerification"}
 	Registry_RequireKeyChaining = BoolParam{"Registry.RequireKeyChaining"}
 	Registry_RequireOriginApproval = BoolParam{"Registry.RequireOriginApproval"}
 	Server_EnablePprof = BoolParam{"Server.EnablePprof"}
@@ -384,23 +464,51 @@ var (
 )
 
 var (
+	Cache_ContentAdvertisementInterval = DurationParam{"Cache.ContentAdvertisementInterval"}
 	Cache_DefaultCacheTimeout = DurationParam{"Cache.DefaultCacheTimeout"}
+	Cache_DiskErrorWindow = DurationParam{"Cache.DiskErrorWindow"}
+	Cache_DiskFullHorizon = DurationParam{"Cache.DiskFullHorizon"}
+	Cache_DiskHealthCheckInterval = DurationParam{"Cache.DiskHealthCheckInterval"}
+	Cache_NamespaceLimitsInterval = DurationParam{"Cache.NamespaceLimitsInterval"}
 	Cache_SelfTestInterval = DurationParam{"Cache.SelfTestInterval"}
+	Client_BusyRetryTimeout = DurationParam{"Client.BusyRetryTimeout"}
+	Client_ConnectTimeout = DurationParam{"Client.ConnectTimeout"}
+	Client_DirectorTimeout = DurationParam{"Client.DirectorTimeout"}
+	Client_DiscoveryTimeout = DurationParam{"Client.DiscoveryTimeout"}
+	Client_FirstByteTimeout = DurationParam{"Client.FirstByteTimeout"}
 	Client_SlowTransferRampupTime = DurationParam{"Client.SlowTransferRampupTime"}
 	Client_SlowTransferWindow = DurationParam{"Client.SlowTransferWindow"}
 	Client_StoppedTransferTimeout = DurationParam{"Client.StoppedTransferTimeout"}
 	Director_AdvertisementTTL = DurationParam{"Director.AdvertisementTTL"}
 	Director_CachePresenceTTL = DurationParam{"Director.CachePresenceTTL"}
+	Director_DNSTTL = DurationParam{"Director.DNSTTL"}
+	Director_DenyRuleDefaultLifetime = DurationParam{"Director.DenyRuleDefaultLifetime"}
+	Director_DenyRuleMaxLifetime = DurationParam{"Director.DenyRuleMaxLifetime"}
+	Director_DiscoveryMaxAge = DurationParam{"Director.DiscoveryMaxAge"}
+	Director_DiscoveryValidity = DurationParam{"Director.DiscoveryValidity"}
+	Director_GeoIPMaxAge = DurationParam{"Director.GeoIPMaxAge"}
+	Director_GeoIPRefreshInterval = DurationParam{"Director.GeoIPRefreshInterval"}
 	Director_OriginCacheHealthTestInterval = DurationParam{"Director.OriginCacheHealthTestInterval"}
 	Director_StatTimeout = DurationParam{"Director.StatTimeout"}
+	Federation_DiscoveryCacheTTL = DurationParam{"Federation.DiscoveryCacheTTL"}
 	Federation_TopologyReloadInterval = DurationParam{"Federation.TopologyReloadInterval"}
+	LocalCache_RevalidateInterval = DurationParam{"LocalCache.RevalidateInterval"}
+	LocalCache_TokenAuthzCacheLifetime = DurationParam{"LocalCache.TokenAuthzCacheLifetime"}
+	LocalCache_TokenRevocationListRefreshInterval = DurationParam{"LocalCache.TokenRevocationListRefreshInterval"}
 	Lotman_DefaultLotDeletionLifetime = DurationParam{"Lotman.DefaultLotDeletionLifetime"}
 	Lotman_DefaultLotExpirationLifetime = DurationParam{"Lotman.DefaultLotExpirationLifetime"}
 	Monitoring_DataRetention = DurationParam{"Monitoring.DataRetention"}
 	Monitoring_TokenExpiresIn = DurationParam{"Monitoring.TokenExpiresIn"}
 	Monitoring_TokenRefreshInterval = DurationParam{"Monitoring.TokenRefreshInterval"}
+	Origin_ExportScanInterval = DurationParam{"Origin.ExportScanInterval"}
 	Origin_SelfTestInterval = DurationParam{"Origin.SelfTestInterval"}
+	Origin_UploadScanTimeout = DurationParam{"Origin.UploadScanTimeout"}
+	Origin_UploadStagingLifetime = DurationParam{"Origin.UploadStagingLifetime"}
+	Origin_VersionRetention = DurationParam{"Origin.VersionRetention"}
+	Registry_EmailVerificationExpiry = DurationParam{"Registry.EmailVerificationExpiry"}
 	Registry_InstitutionsUrlReloadMinutes = DurationParam{"Registry.InstitutionsUrlReloadMinutes"}
+	Registry_IssuerMetadataCacheLifetime = DurationParam{"Registry.IssuerMetadataCacheLifetime"}
+	Server_ConfigWatchInterval = DurationParam{"Server.ConfigWatchInterval"}
 	Server_RegistrationRetryInterval = DurationParam{"Server.RegistrationRetryInterval"}
 	Server_StartupTimeout = DurationParam{"Server.StartupTimeout"}
 	Transport_DialerKeepAlive = DurationParam{"Transport.DialerKeepAlive"}
@@ -411,15 +519,29 @@ var (
 	Transport_TLSHandshakeTimeout = DurationParam{"Transport.TLSHandshakeTimeout"}
 	Xrootd_AuthRefreshInterval = DurationParam{"Xrootd.AuthRefreshInterval"}
 	Xrootd_MaxStartupWait = DurationParam{"Xrootd.MaxStartupWait"}
+	Xrootd_RestartDrainPeriod = DurationParam{"Xrootd.RestartDrainPeriod"}
 )
 
 var (
+	Cache_NamespaceLimits = ObjectParam{"Cache.NamespaceLimits"}
+	Client_ProxyOverrides = ObjectParam{"Client.ProxyOverrides"}
+	Director_DiscoveryExtraFields = ObjectParam{"Director.DiscoveryExtraFields"}
+	Director_NamespaceAliases = ObjectParam{"Director.NamespaceAliases"}
+	Director_NamespaceSLOs = ObjectParam{"Director.NamespaceSLOs"}
+	Director_RedirectStyles = ObjectParam{"Director.RedirectStyles"}
+	Director_SortExperiment = ObjectParam{"Director.SortExperiment"}
+	Director_SubDirectors = ObjectParam{"Director.SubDirectors"}
 	GeoIPOverrides = ObjectParam{"GeoIPOverrides"}
 	Issuer_AuthorizationTemplates = ObjectParam{"Issuer.AuthorizationTemplates"}
 	Issuer_OIDCAuthenticationRequirements = ObjectParam{"Issuer.OIDCAuthenticationRequirements"}
+	LocalCache_TokenRevocationLists = ObjectParam{"LocalCache.TokenRevocationLists"}
+	Logging_Modules = ObjectParam{"Logging.Modules"}
 	Lotman_PolicyDefinitions = ObjectParam{"Lotman.PolicyDefinitions"}
+	OIDC_Providers = ObjectParam{"OIDC.Providers"}
 	Origin_Exports = ObjectParam{"Origin.Exports"}
+	Origin_PathRewrites = ObjectParam{"Origin.PathRewrites"}
 	Registry_CustomRegistrationFields = ObjectParam{"Registry.CustomRegistrationFields"}
+	Registry_IdentityProviders = ObjectParam{"Registry.IdentityProviders"}
 	Registry_Institutions = ObjectParam{"Registry.Institutions"}
 	Shoveler_IPMapping = ObjectParam{"Shoveler.IPMapping"}
 )
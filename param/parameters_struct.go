@@ -27,20 +27,37 @@ type Config struct {
 	Cache struct {
 		BlocksToPrefetch int `mapstructure:"blockstoprefetch" yaml:"BlocksToPrefetch"`
 		Concurrency int `mapstructure:"concurrency" yaml:"Concurrency"`
+		ContentAdvertisementInterval time.Duration `mapstructure:"contentadvertisementinterval" yaml:"ContentAdvertisementInterval"`
+		ContentAdvertisementMaxObjects int `mapstructure:"contentadvertisementmaxobjects" yaml:"ContentAdvertisementMaxObjects"`
 		DataLocation string `mapstructure:"datalocation" yaml:"DataLocation"`
 		DataLocations []string `mapstructure:"datalocations" yaml:"DataLocations"`
 		DefaultCacheTimeout time.Duration `mapstructure:"defaultcachetimeout" yaml:"DefaultCacheTimeout"`
+		DiskErrorThreshold int `mapstructure:"diskerrorthreshold" yaml:"DiskErrorThreshold"`
+		DiskErrorWindow time.Duration `mapstructure:"diskerrorwindow" yaml:"DiskErrorWindow"`
+		DiskFullHorizon time.Duration `mapstructure:"diskfullhorizon" yaml:"DiskFullHorizon"`
+		DiskHealthCheckInterval time.Duration `mapstructure:"diskhealthcheckinterval" yaml:"DiskHealthCheckInterval"`
+		DiskMinFreePercentage int `mapstructure:"diskminfreepercentage" yaml:"DiskMinFreePercentage"`
+		DiskRefetchCount int `mapstructure:"diskrefetchcount" yaml:"DiskRefetchCount"`
+		EnableContentAdvertisement bool `mapstructure:"enablecontentadvertisement" yaml:"EnableContentAdvertisement"`
+		EnableDiskHealthCheck bool `mapstructure:"enablediskhealthcheck" yaml:"EnableDiskHealthCheck"`
+		EnableDiskSmartCheck bool `mapstructure:"enabledisksmartcheck" yaml:"EnableDiskSmartCheck"`
+		EnableLocalHttp bool `mapstructure:"enablelocalhttp" yaml:"EnableLocalHttp"`
 		EnableLotman bool `mapstructure:"enablelotman" yaml:"EnableLotman"`
 		EnableOIDC bool `mapstructure:"enableoidc" yaml:"EnableOIDC"`
 		EnableVoms bool `mapstructure:"enablevoms" yaml:"EnableVoms"`
 		ExportLocation string `mapstructure:"exportlocation" yaml:"ExportLocation"`
 		HighWaterMark string `mapstructure:"highwatermark" yaml:"HighWaterMark"`
+		LocalHttpNetworks []string `mapstructure:"localhttpnetworks" yaml:"LocalHttpNetworks"`
+		LocalHttpPort int `mapstructure:"localhttpport" yaml:"LocalHttpPort"`
 		LocalRoot string `mapstructure:"localroot" yaml:"LocalRoot"`
 		LowWatermark string `mapstructure:"lowwatermark" yaml:"LowWatermark"`
 		MetaLocations []string `mapstructure:"metalocations" yaml:"MetaLocations"`
+		NamespaceLimits interface{} `mapstructure:"namespacelimits" yaml:"NamespaceLimits"`
+		NamespaceLimitsInterval time.Duration `mapstructure:"namespacelimitsinterval" yaml:"NamespaceLimitsInterval"`
 		NamespaceLocation string `mapstructure:"namespacelocation" yaml:"NamespaceLocation"`
 		PermittedNamespaces []string `mapstructure:"permittednamespaces" yaml:"PermittedNamespaces"`
 		Port int `mapstructure:"port" yaml:"Port"`
+		Region string `mapstructure:"region" yaml:"Region"`
 		RunLocation string `mapstructure:"runlocation" yaml:"RunLocation"`
 		SelfTest bool `mapstructure:"selftest" yaml:"SelfTest"`
 		SelfTestInterval time.Duration `mapstructure:"selftestinterval" yaml:"SelfTestInterval"`
@@ -50,17 +67,41 @@ type Config struct {
 		XRootDPrefix string `mapstructure:"xrootdprefix" yaml:"XRootDPrefix"`
 	} `mapstructure:"cache" yaml:"Cache"`
 	Client struct {
+		BusyRetryTimeout time.Duration `mapstructure:"busyretrytimeout" yaml:"BusyRetryTimeout"`
+		ChunkedUploadThreshold int `mapstructure:"chunkeduploadthreshold" yaml:"ChunkedUploadThreshold"`
+		CompatibilityLevel string `mapstructure:"compatibilitylevel" yaml:"CompatibilityLevel"`
+		ConnectTimeout time.Duration `mapstructure:"connecttimeout" yaml:"ConnectTimeout"`
+		DaemonPort int `mapstructure:"daemonport" yaml:"DaemonPort"`
+		DaemonSocket string `mapstructure:"daemonsocket" yaml:"DaemonSocket"`
+		DirectorTimeout time.Duration `mapstructure:"directortimeout" yaml:"DirectorTimeout"`
 		DisableHttpProxy bool `mapstructure:"disablehttpproxy" yaml:"DisableHttpProxy"`
 		DisableProxyFallback bool `mapstructure:"disableproxyfallback" yaml:"DisableProxyFallback"`
+		DiscoveryTimeout time.Duration `mapstructure:"discoverytimeout" yaml:"DiscoveryTimeout"`
+		FirstByteTimeout time.Duration `mapstructure:"firstbytetimeout" yaml:"FirstByteTimeout"`
+		MaxDownloadSources int `mapstructure:"maxdownloadsources" yaml:"MaxDownloadSources"`
+		MaxRate string `mapstructure:"maxrate" yaml:"MaxRate"`
 		MaximumDownloadSpeed int `mapstructure:"maximumdownloadspeed" yaml:"MaximumDownloadSpeed"`
 		MinimumDownloadSpeed int `mapstructure:"minimumdownloadspeed" yaml:"MinimumDownloadSpeed"`
+		Nice int `mapstructure:"nice" yaml:"Nice"`
+		ProxyOverrides interface{} `mapstructure:"proxyoverrides" yaml:"ProxyOverrides"`
+		S3AccessKeyfile string `mapstructure:"s3accesskeyfile" yaml:"S3AccessKeyfile"`
+		S3Region string `mapstructure:"s3region" yaml:"S3Region"`
+		S3SecretKeyfile string `mapstructure:"s3secretkeyfile" yaml:"S3SecretKeyfile"`
+		S3ServiceUrl string `mapstructure:"s3serviceurl" yaml:"S3ServiceUrl"`
+		S3UrlStyle string `mapstructure:"s3urlstyle" yaml:"S3UrlStyle"`
 		SlowTransferRampupTime time.Duration `mapstructure:"slowtransferrampuptime" yaml:"SlowTransferRampupTime"`
 		SlowTransferWindow time.Duration `mapstructure:"slowtransferwindow" yaml:"SlowTransferWindow"`
 		StoppedTransferTimeout time.Duration `mapstructure:"stoppedtransfertimeout" yaml:"StoppedTransferTimeout"`
+		StripeSize int `mapstructure:"stripesize" yaml:"StripeSize"`
+		UploadChunkSize int `mapstructure:"uploadchunksize" yaml:"UploadChunkSize"`
+		UploadConcurrency int `mapstructure:"uploadconcurrency" yaml:"UploadConcurrency"`
+		UseDaemon bool `mapstructure:"usedaemon" yaml:"UseDaemon"`
 		WorkerCount int `mapstructure:"workercount" yaml:"WorkerCount"`
 	} `mapstructure:"client" yaml:"Client"`
 	ConfigDir string `mapstructure:"configdir" yaml:"ConfigDir"`
+	ConfigInstance string `mapstructure:"configinstance" yaml:"ConfigInstance"`
 	ConfigLocations []string `mapstructure:"configlocations" yaml:"ConfigLocations"`
+	ConfigSite string `mapstructure:"configsite" yaml:"ConfigSite"`
 	Debug bool `mapstructure:"debug" yaml:"Debug"`
 	Director struct {
 		AdvertisementTTL time.Duration `mapstructure:"advertisementttl" yaml:"AdvertisementTTL"`
@@ -72,22 +113,46 @@ type Config struct {
 		CachesPullFromCaches bool `mapstructure:"cachespullfromcaches" yaml:"CachesPullFromCaches"`
 		CheckCachePresence bool `mapstructure:"checkcachepresence" yaml:"CheckCachePresence"`
 		CheckOriginPresence bool `mapstructure:"checkoriginpresence" yaml:"CheckOriginPresence"`
+		DNSHostname string `mapstructure:"dnshostname" yaml:"DNSHostname"`
+		DNSPort int `mapstructure:"dnsport" yaml:"DNSPort"`
+		DNSTTL time.Duration `mapstructure:"dnsttl" yaml:"DNSTTL"`
 		DbLocation string `mapstructure:"dblocation" yaml:"DbLocation"`
 		DefaultResponse string `mapstructure:"defaultresponse" yaml:"DefaultResponse"`
+		DenyRuleDefaultLifetime time.Duration `mapstructure:"denyruledefaultlifetime" yaml:"DenyRuleDefaultLifetime"`
+		DenyRuleMaxLifetime time.Duration `mapstructure:"denyrulemaxlifetime" yaml:"DenyRuleMaxLifetime"`
+		DiscoveryExtraFields interface{} `mapstructure:"discoveryextrafields" yaml:"DiscoveryExtraFields"`
+		DiscoveryMaxAge time.Duration `mapstructure:"discoverymaxage" yaml:"DiscoveryMaxAge"`
+		DiscoveryValidity time.Duration `mapstructure:"discoveryvalidity" yaml:"DiscoveryValidity"`
 		EnableBroker bool `mapstructure:"enablebroker" yaml:"EnableBroker"`
+		EnableDNS bool `mapstructure:"enabledns" yaml:"EnableDNS"`
 		EnableOIDC bool `mapstructure:"enableoidc" yaml:"EnableOIDC"`
 		EnableStat bool `mapstructure:"enablestat" yaml:"EnableStat"`
+		EnableTopologyIssueChecks bool `mapstructure:"enabletopologyissuechecks" yaml:"EnableTopologyIssueChecks"`
 		FilteredServers []string `mapstructure:"filteredservers" yaml:"FilteredServers"`
 		GeoIPLocation string `mapstructure:"geoiplocation" yaml:"GeoIPLocation"`
+		GeoIPMaxAge time.Duration `mapstructure:"geoipmaxage" yaml:"GeoIPMaxAge"`
+		GeoIPRefreshInterval time.Duration `mapstructure:"geoiprefreshinterval" yaml:"GeoIPRefreshInterval"`
 		MaxMindKeyFile string `mapstructure:"maxmindkeyfile" yaml:"MaxMindKeyFile"`
 		MaxStatResponse int `mapstructure:"maxstatresponse" yaml:"MaxStatResponse"`
 		MinStatResponse int `mapstructure:"minstatresponse" yaml:"MinStatResponse"`
+		NamespaceAliases interface{} `mapstructure:"namespacealiases" yaml:"NamespaceAliases"`
+		NamespaceSLOs interface{} `mapstructure:"namespaceslos" yaml:"NamespaceSLOs"`
 		OriginCacheHealthTestInterval time.Duration `mapstructure:"origincachehealthtestinterval" yaml:"OriginCacheHealthTestInterval"`
 		OriginResponseHostnames []string `mapstructure:"originresponsehostnames" yaml:"OriginResponseHostnames"`
+		RateLimitAllowlist []string `mapstructure:"ratelimitallowlist" yaml:"RateLimitAllowlist"`
+		RateLimitExemptCaches bool `mapstructure:"ratelimitexemptcaches" yaml:"RateLimitExemptCaches"`
+		RateLimitPerIP int `mapstructure:"ratelimitperip" yaml:"RateLimitPerIP"`
+		RateLimitPerIPBurst int `mapstructure:"ratelimitperipburst" yaml:"RateLimitPerIPBurst"`
+		RateLimitPerToken int `mapstructure:"ratelimitpertoken" yaml:"RateLimitPerToken"`
+		RateLimitPerTokenBurst int `mapstructure:"ratelimitpertokenburst" yaml:"RateLimitPerTokenBurst"`
+		RedirectStyles interface{} `mapstructure:"redirectstyles" yaml:"RedirectStyles"`
+		SortExperiment interface{} `mapstructure:"sortexperiment" yaml:"SortExperiment"`
 		StatConcurrencyLimit int `mapstructure:"statconcurrencylimit" yaml:"StatConcurrencyLimit"`
 		StatTimeout time.Duration `mapstructure:"stattimeout" yaml:"StatTimeout"`
+		SubDirectors interface{} `mapstructure:"subdirectors" yaml:"SubDirectors"`
 		SupportContactEmail string `mapstructure:"supportcontactemail" yaml:"SupportContactEmail"`
 		SupportContactUrl string `mapstructure:"supportcontacturl" yaml:"SupportContactUrl"`
+		TopologyIssueWebhook string `mapstructure:"topologyissuewebhook" yaml:"TopologyIssueWebhook"`
 		X509ClientAuthenticationPrefixes []string `mapstructure:"x509clientauthenticationprefixes" yaml:"X509ClientAuthenticationPrefixes"`
 	} `mapstructure:"director" yaml:"Director"`
 	DisableHttpProxy bool `mapstructure:"disablehttpproxy" yaml:"DisableHttpProxy"`
@@ -95,6 +160,8 @@ type Config struct {
 	Federation struct {
 		BrokerUrl string `mapstructure:"brokerurl" yaml:"BrokerUrl"`
 		DirectorUrl string `mapstructure:"directorurl" yaml:"DirectorUrl"`
+		DiscoveryCacheLocation string `mapstructure:"discoverycachelocation" yaml:"DiscoveryCacheLocation"`
+		DiscoveryCacheTTL time.Duration `mapstructure:"discoverycachettl" yaml:"DiscoveryCacheTTL"`
 		DiscoveryUrl string `mapstructure:"discoveryurl" yaml:"DiscoveryUrl"`
 		JwkUrl string `mapstructure:"jwkurl" yaml:"JwkUrl"`
 		RegistryUrl string `mapstructure:"registryurl" yaml:"RegistryUrl"`
@@ -109,6 +176,7 @@ type Config struct {
 		AuthorizationTemplates interface{} `mapstructure:"authorizationtemplates" yaml:"AuthorizationTemplates"`
 		GroupFile string `mapstructure:"groupfile" yaml:"GroupFile"`
 		GroupRequirements []string `mapstructure:"grouprequirements" yaml:"GroupRequirements"`
+		GroupScopeMappingFile string `mapstructure:"groupscopemappingfile" yaml:"GroupScopeMappingFile"`
 		GroupSource string `mapstructure:"groupsource" yaml:"GroupSource"`
 		IssuerClaimValue string `mapstructure:"issuerclaimvalue" yaml:"IssuerClaimValue"`
 		OIDCAuthenticationRequirements interface{} `mapstructure:"oidcauthenticationrequirements" yaml:"OIDCAuthenticationRequirements"`
@@ -120,13 +188,18 @@ type Config struct {
 		UserStripDomain bool `mapstructure:"userstripdomain" yaml:"UserStripDomain"`
 	} `mapstructure:"issuer" yaml:"Issuer"`
 	IssuerKey string `mapstructure:"issuerkey" yaml:"IssuerKey"`
+	IssuerKeyURI string `mapstructure:"issuerkeyuri" yaml:"IssuerKeyURI"`
 	LocalCache struct {
 		DataLocation string `mapstructure:"datalocation" yaml:"DataLocation"`
 		HighWaterMarkPercentage int `mapstructure:"highwatermarkpercentage" yaml:"HighWaterMarkPercentage"`
 		LowWaterMarkPercentage int `mapstructure:"lowwatermarkpercentage" yaml:"LowWaterMarkPercentage"`
+		RevalidateInterval time.Duration `mapstructure:"revalidateinterval" yaml:"RevalidateInterval"`
 		RunLocation string `mapstructure:"runlocation" yaml:"RunLocation"`
 		Size string `mapstructure:"size" yaml:"Size"`
 		Socket string `mapstructure:"socket" yaml:"Socket"`
+		TokenAuthzCacheLifetime time.Duration `mapstructure:"tokenauthzcachelifetime" yaml:"TokenAuthzCacheLifetime"`
+		TokenRevocationListRefreshInterval time.Duration `mapstructure:"tokenrevocationlistrefreshinterval" yaml:"TokenRevocationListRefreshInterval"`
+		TokenRevocationLists interface{} `mapstructure:"tokenrevocationlists" yaml:"TokenRevocationLists"`
 	} `mapstructure:"localcache" yaml:"LocalCache"`
 	Logging struct {
 		Cache struct {
@@ -139,8 +212,10 @@ type Config struct {
 			Xrootd string `mapstructure:"xrootd" yaml:"Xrootd"`
 		} `mapstructure:"cache" yaml:"Cache"`
 		DisableProgressBars bool `mapstructure:"disableprogressbars" yaml:"DisableProgressBars"`
+		Format string `mapstructure:"format" yaml:"Format"`
 		Level string `mapstructure:"level" yaml:"Level"`
 		LogLocation string `mapstructure:"loglocation" yaml:"LogLocation"`
+		Modules interface{} `mapstructure:"modules" yaml:"Modules"`
 		Origin struct {
 			Cms string `mapstructure:"cms" yaml:"Cms"`
 			Http string `mapstructure:"http" yaml:"Http"`
@@ -180,16 +255,19 @@ type Config struct {
 		ClientSecretFile string `mapstructure:"clientsecretfile" yaml:"ClientSecretFile"`
 		DeviceAuthEndpoint string `mapstructure:"deviceauthendpoint" yaml:"DeviceAuthEndpoint"`
 		Issuer string `mapstructure:"issuer" yaml:"Issuer"`
+		Providers interface{} `mapstructure:"providers" yaml:"Providers"`
 		TokenEndpoint string `mapstructure:"tokenendpoint" yaml:"TokenEndpoint"`
 		UserInfoEndpoint string `mapstructure:"userinfoendpoint" yaml:"UserInfoEndpoint"`
 	} `mapstructure:"oidc" yaml:"OIDC"`
 	Origin struct {
 		DbLocation string `mapstructure:"dblocation" yaml:"DbLocation"`
 		DirectorTest bool `mapstructure:"directortest" yaml:"DirectorTest"`
+		EnableArchiveMembers bool `mapstructure:"enablearchivemembers" yaml:"EnableArchiveMembers"`
 		EnableBroker bool `mapstructure:"enablebroker" yaml:"EnableBroker"`
 		EnableCmsd bool `mapstructure:"enablecmsd" yaml:"EnableCmsd"`
 		EnableDirListing bool `mapstructure:"enabledirlisting" yaml:"EnableDirListing"`
 		EnableDirectReads bool `mapstructure:"enabledirectreads" yaml:"EnableDirectReads"`
+		EnableExportScan bool `mapstructure:"enableexportscan" yaml:"EnableExportScan"`
 		EnableFallbackRead bool `mapstructure:"enablefallbackread" yaml:"EnableFallbackRead"`
 		EnableIssuer bool `mapstructure:"enableissuer" yaml:"EnableIssuer"`
 		EnableListings bool `mapstructure:"enablelistings" yaml:"EnableListings"`
@@ -197,10 +275,19 @@ type Config struct {
 		EnableOIDC bool `mapstructure:"enableoidc" yaml:"EnableOIDC"`
 		EnablePublicReads bool `mapstructure:"enablepublicreads" yaml:"EnablePublicReads"`
 		EnableReads bool `mapstructure:"enablereads" yaml:"EnableReads"`
+		EnableS3Gateway bool `mapstructure:"enables3gateway" yaml:"EnableS3Gateway"`
 		EnableUI bool `mapstructure:"enableui" yaml:"EnableUI"`
+		EnableUploadScan bool `mapstructure:"enableuploadscan" yaml:"EnableUploadScan"`
+		EnableUploadStaging bool `mapstructure:"enableuploadstaging" yaml:"EnableUploadStaging"`
+		EnableVersioning bool `mapstructure:"enableversioning" yaml:"EnableVersioning"`
 		EnableVoms bool `mapstructure:"enablevoms" yaml:"EnableVoms"`
 		EnableWrite bool `mapstructure:"enablewrite" yaml:"EnableWrite"`
 		EnableWrites bool `mapstructure:"enablewrites" yaml:"EnableWrites"`
+		ExperimentalCapabilities []string `mapstructure:"experimentalcapabilities" yaml:"ExperimentalCapabilities"`
+		ExportScanInterval time.Duration `mapstructure:"exportscaninterval" yaml:"ExportScanInterval"`
+		ExportScanMaxFileRate int `mapstructure:"exportscanmaxfilerate" yaml:"ExportScanMaxFileRate"`
+		ExportScanMaxRate string `mapstructure:"exportscanmaxrate" yaml:"ExportScanMaxRate"`
+		ExportScanWorkers int `mapstructure:"exportscanworkers" yaml:"ExportScanWorkers"`
 		ExportVolume string `mapstructure:"exportvolume" yaml:"ExportVolume"`
 		ExportVolumes []string `mapstructure:"exportvolumes" yaml:"ExportVolumes"`
 		Exports interface{} `mapstructure:"exports" yaml:"Exports"`
@@ -212,13 +299,16 @@ type Config struct {
 		GlobusConfigLocation string `mapstructure:"globusconfiglocation" yaml:"GlobusConfigLocation"`
 		HttpAuthTokenFile string `mapstructure:"httpauthtokenfile" yaml:"HttpAuthTokenFile"`
 		HttpServiceUrl string `mapstructure:"httpserviceurl" yaml:"HttpServiceUrl"`
+		MaxVersions int `mapstructure:"maxversions" yaml:"MaxVersions"`
 		Mode string `mapstructure:"mode" yaml:"Mode"`
 		Multiuser bool `mapstructure:"multiuser" yaml:"Multiuser"`
 		NamespacePrefix string `mapstructure:"namespaceprefix" yaml:"NamespacePrefix"`
+		PathRewrites interface{} `mapstructure:"pathrewrites" yaml:"PathRewrites"`
 		Port int `mapstructure:"port" yaml:"Port"`
 		RunLocation string `mapstructure:"runlocation" yaml:"RunLocation"`
 		S3AccessKeyfile string `mapstructure:"s3accesskeyfile" yaml:"S3AccessKeyfile"`
 		S3Bucket string `mapstructure:"s3bucket" yaml:"S3Bucket"`
+		S3GatewayPort int `mapstructure:"s3gatewayport" yaml:"S3GatewayPort"`
 		S3Region string `mapstructure:"s3region" yaml:"S3Region"`
 		S3SecretKeyfile string `mapstructure:"s3secretkeyfile" yaml:"S3SecretKeyfile"`
 		S3ServiceName string `mapstructure:"s3servicename" yaml:"S3ServiceName"`
@@ -233,7 +323,15 @@ type Config struct {
 		SelfTestInterval time.Duration `mapstructure:"selftestinterval" yaml:"SelfTestInterval"`
 		StoragePrefix string `mapstructure:"storageprefix" yaml:"StoragePrefix"`
 		StorageType string `mapstructure:"storagetype" yaml:"StorageType"`
+		UploadScanCommand []string `mapstructure:"uploadscancommand" yaml:"UploadScanCommand"`
+		UploadScanICAPUrl string `mapstructure:"uploadscanicapurl" yaml:"UploadScanICAPUrl"`
+		UploadScanLocation string `mapstructure:"uploadscanlocation" yaml:"UploadScanLocation"`
+		UploadScanTimeout time.Duration `mapstructure:"uploadscantimeout" yaml:"UploadScanTimeout"`
+		UploadStagingLifetime time.Duration `mapstructure:"uploadstaginglifetime" yaml:"UploadStagingLifetime"`
+		UploadStagingLocation string `mapstructure:"uploadstaginglocation" yaml:"UploadStagingLocation"`
 		Url string `mapstructure:"url" yaml:"Url"`
+		VersionRetention time.Duration `mapstructure:"versionretention" yaml:"VersionRetention"`
+		VersionsLocation string `mapstructure:"versionslocation" yaml:"VersionsLocation"`
 		XRootDPrefix string `mapstructure:"xrootdprefix" yaml:"XRootDPrefix"`
 		XRootServiceUrl string `mapstructure:"xrootserviceurl" yaml:"XRootServiceUrl"`
 	} `mapstructure:"origin" yaml:"Origin"`
@@ -244,14 +342,28 @@ type Config struct {
 		AdminUsers []string `mapstructure:"adminusers" yaml:"AdminUsers"`
 		CustomRegistrationFields interface{} `mapstructure:"customregistrationfields" yaml:"CustomRegistrationFields"`
 		DbLocation string `mapstructure:"dblocation" yaml:"DbLocation"`
+		EmailVerificationExpiry time.Duration `mapstructure:"emailverificationexpiry" yaml:"EmailVerificationExpiry"`
+		IdentityProviders interface{} `mapstructure:"identityproviders" yaml:"IdentityProviders"`
 		Institutions interface{} `mapstructure:"institutions" yaml:"Institutions"`
 		InstitutionsUrl string `mapstructure:"institutionsurl" yaml:"InstitutionsUrl"`
 		InstitutionsUrlReloadMinutes time.Duration `mapstructure:"institutionsurlreloadminutes" yaml:"InstitutionsUrlReloadMinutes"`
+		IssuerMetadataCacheLifetime time.Duration `mapstructure:"issuermetadatacachelifetime" yaml:"IssuerMetadataCacheLifetime"`
+		KeyRecoveryApprovals int `mapstructure:"keyrecoveryapprovals" yaml:"KeyRecoveryApprovals"`
+		RecoveryCodeCount int `mapstructure:"recoverycodecount" yaml:"RecoveryCodeCount"`
+		RegistrationChallenge string `mapstructure:"registrationchallenge" yaml:"RegistrationChallenge"`
+		RegistrationChallengeSecretFile string `mapstructure:"registrationchallengesecretfile" yaml:"RegistrationChallengeSecretFile"`
+		RegistrationChallengeSiteKey string `mapstructure:"registrationchallengesitekey" yaml:"RegistrationChallengeSiteKey"`
 		RequireCacheApproval bool `mapstructure:"requirecacheapproval" yaml:"RequireCacheApproval"`
+		RequireEmailVerification bool `mapstructure:"requireemailverification" yaml:"RequireEmailVerification"`
 		RequireKeyChaining bool `mapstructure:"requirekeychaining" yaml:"RequireKeyChaining"`
 		RequireOriginApproval bool `mapstructure:"requireoriginapproval" yaml:"RequireOriginApproval"`
+		SMTPFrom string `mapstructure:"smtpfrom" yaml:"SMTPFrom"`
+		SMTPPasswordFile string `mapstructure:"smtppasswordfile" yaml:"SMTPPasswordFile"`
+		SMTPServer string `mapstructure:"smtpserver" yaml:"SMTPServer"`
+		SMTPUsername string `mapstructure:"smtpusername" yaml:"SMTPUsername"`
 	} `mapstructure:"registry" yaml:"Registry"`
 	Server struct {
+		ConfigWatchInterval time.Duration `mapstructure:"configwatchinterval" yaml:"ConfigWatchInterval"`
 		EnablePprof bool `mapstructure:"enablepprof" yaml:"EnablePprof"`
 		EnableUI bool `mapstructure:"enableui" yaml:"EnableUI"`
 		ExternalWebUrl string `mapstructure:"externalweburl" yaml:"ExternalWebUrl"`
@@ -260,7 +372,9 @@ type Config struct {
 		IssuerJwks string `mapstructure:"issuerjwks" yaml:"IssuerJwks"`
 		IssuerPort int `mapstructure:"issuerport" yaml:"IssuerPort"`
 		IssuerUrl string `mapstructure:"issuerurl" yaml:"IssuerUrl"`
+		LivenessProbeStrictness string `mapstructure:"livenessprobestrictness" yaml:"LivenessProbeStrictness"`
 		Modules []string `mapstructure:"modules" yaml:"Modules"`
+		ReadinessProbeStrictness string `mapstructure:"readinessprobestrictness" yaml:"ReadinessProbeStrictness"`
 		RegistrationRetryInterval time.Duration `mapstructure:"registrationretryinterval" yaml:"RegistrationRetryInterval"`
 		SessionSecretFile string `mapstructure:"sessionsecretfile" yaml:"SessionSecretFile"`
 		StartupTimeout time.Duration `mapstructure:"startuptimeout" yaml:"StartupTimeout"`
@@ -270,6 +384,7 @@ type Config struct {
 		TLSCertificate string `mapstructure:"tlscertificate" yaml:"TLSCertificate"`
 		TLSCertificateChain string `mapstructure:"tlscertificatechain" yaml:"TLSCertificateChain"`
 		TLSKey string `mapstructure:"tlskey" yaml:"TLSKey"`
+		UIAccountDbLocation string `mapstructure:"uiaccountdblocation" yaml:"UIAccountDbLocation"`
 		UIActivationCodeFile string `mapstructure:"uiactivationcodefile" yaml:"UIActivationCodeFile"`
 		UIAdminUsers []string `mapstructure:"uiadminusers" yaml:"UIAdminUsers"`
 		UILoginRateLimit int `mapstructure:"uiloginratelimit" yaml:"UILoginRateLimit"`
@@ -303,6 +418,11 @@ type Config struct {
 		ShadowOriginPrefix string `mapstructure:"shadoworiginprefix" yaml:"ShadowOriginPrefix"`
 	} `mapstructure:"stageplugin" yaml:"StagePlugin"`
 	TLSSkipVerify bool `mapstructure:"tlsskipverify" yaml:"TLSSkipVerify"`
+	Tracing struct {
+		Endpoint string `mapstructure:"endpoint" yaml:"Endpoint"`
+		Exporter string `mapstructure:"exporter" yaml:"Exporter"`
+		SamplingPercentage int `mapstructure:"samplingpercentage" yaml:"SamplingPercentage"`
+	} `mapstructure:"tracing" yaml:"Tracing"`
 	Transport struct {
 		DialerKeepAlive time.Duration `mapstructure:"dialerkeepalive" yaml:"DialerKeepAlive"`
 		DialerTimeout time.Duration `mapstructure:"dialertimeout" yaml:"DialerTimeout"`
@@ -315,6 +435,8 @@ type Config struct {
 	Xrootd struct {
 		AuthRefreshInterval time.Duration `mapstructure:"authrefreshinterval" yaml:"AuthRefreshInterval"`
 		Authfile string `mapstructure:"authfile" yaml:"Authfile"`
+		CacheConfigTemplate string `mapstructure:"cacheconfigtemplate" yaml:"CacheConfigTemplate"`
+		ConfigExtras string `mapstructure:"configextras" yaml:"ConfigExtras"`
 		ConfigFile string `mapstructure:"configfile" yaml:"ConfigFile"`
 		DetailedMonitoringHost string `mapstructure:"detailedmonitoringhost" yaml:"DetailedMonitoringHost"`
 		DetailedMonitoringPort int `mapstructure:"detailedmonitoringport" yaml:"DetailedMonitoringPort"`
@@ -324,7 +446,9 @@ type Config struct {
 		ManagerPort int `mapstructure:"managerport" yaml:"ManagerPort"`
 		MaxStartupWait time.Duration `mapstructure:"maxstartupwait" yaml:"MaxStartupWait"`
 		Mount string `mapstructure:"mount" yaml:"Mount"`
+		OriginConfigTemplate string `mapstructure:"originconfigtemplate" yaml:"OriginConfigTemplate"`
 		Port int `mapstructure:"port" yaml:"Port"`
+		RestartDrainPeriod time.Duration `mapstructure:"restartdrainperiod" yaml:"RestartDrainPeriod"`
 		RobotsTxtFile string `mapstructure:"robotstxtfile" yaml:"RobotsTxtFile"`
 		RunLocation string `mapstructure:"runlocation" yaml:"RunLocation"`
 		ScitokensConfig string `mapstructure:"scitokensconfig" yaml:"ScitokensConfig"`
@@ -339,20 +463,37 @@ type configWithType struct {
 	Cache struct {
 		BlocksToPrefetch struct { Type string; Value int }
 		Concurrency struct { Type string; Value int }
+		ContentAdvertisementInterval struct { Type string; Value time.Duration }
+		ContentAdvertisementMaxObjects struct { Type string; Value int }
 		DataLocation struct { Type string; Value string }
 		DataLocations struct { Type string; Value []string }
 		DefaultCacheTimeout struct { Type string; Value time.Duration }
+		DiskErrorThreshold struct { Type string; Value int }
+		DiskErrorWindow struct { Type string; Value time.Duration }
+		DiskFullHorizon struct { Type string; Value time.Duration }
+		DiskHealthCheckInterval struct { Type string; Value time.Duration }
+		DiskMinFreePercentage struct { Type string; Value int }
+		DiskRefetchCount struct { Type string; Value int }
+		EnableContentAdvertisement struct { Type string; Value bool }
+		EnableDiskHealthCheck struct { Type string; Value bool }
+		EnableDiskSmartCheck struct { Type string; Value bool }
+		EnableLocalHttp struct { Type string; Value bool }
 		EnableLotman struct { Type string; Value bool }
 		EnableOIDC struct { Type string; Value bool }
 		EnableVoms struct { Type string; Value bool }
 		ExportLocation struct { Type string; Value string }
 		HighWaterMark struct { Type string; Value string }
+		LocalHttpNetworks struct { Type string; Value []string }
+		LocalHttpPort struct { Type string; Value int }
 		LocalRoot struct { Type string; Value string }
 		LowWatermark struct { Type string; Value string }
 		MetaLocations struct { Type string; Value []string }
+		NamespaceLimits struct { Type string; Value interface{} }
+		NamespaceLimitsInterval struct { Type string; Value time.Duration }
 		NamespaceLocation struct { Type string; Value string }
 		PermittedNamespaces struct { Type string; Value []string }
 		Port struct { Type string; Value int }
+		Region struct { Type string; Value string }
 		RunLocation struct { Type string; Value string }
 		SelfTest struct { Type string; Value bool }
 		SelfTestInterval struct { Type string; Value time.Duration }
@@ -362,17 +503,41 @@ type configWithType struct {
 		XRootDPrefix struct { Type string; Value string }
 	}
 	Client struct {
+		BusyRetryTimeout struct { Type string; Value time.Duration }
+		ChunkedUploadThreshold struct { Type string; Value int }
+		CompatibilityLevel struct { Type string; Value string }
+		ConnectTimeout struct { Type string; Value time.Duration }
+		DaemonPort struct { Type string; Value int }
+		DaemonSocket struct { Type string; Value string }
+		DirectorTimeout struct { Type string; Value time.Duration }
 		DisableHttpProxy struct { Type string; Value bool }
 		DisableProxyFallback struct { Type string; Value bool }
+		DiscoveryTimeout struct { Type string; Value time.Duration }
+		FirstByteTimeout struct { Type string; Value time.Duration }
+		MaxDownloadSources struct { Type string; Value int }
+		MaxRate struct { Type string; Value string }
 		MaximumDownloadSpeed struct { Type string; Value int }
 		MinimumDownloadSpeed struct { Type string; Value int }
+		Nice struct { Type string; Value int }
+		ProxyOverrides struct { Type string; Value interface{} }
+		S3AccessKeyfile struct { Type string; Value string }
+		S3Region struct { Type string; Value string }
+		S3SecretKeyfile struct { Type string; Value string }
+		S3ServiceUrl struct { Type string; Value string }
+		S3UrlStyle struct { Type string; Value string }
 		SlowTransferRampupTime struct { Type string; Value time.Duration }
 		SlowTransferWindow struct { Type string; Value time.Duration }
 		StoppedTransferTimeout struct { Type string; Value time.Duration }
+		StripeSize struct { Type string; Value int }
+		UploadChunkSize struct { Type string; Value int }
+		UploadConcurrency struct { Type string; Value int }
+		UseDaemon struct { Type string; Value bool }
 		WorkerCount struct { Type string; Value int }
 	}
 	ConfigDir struct { Type string; Value string }
+	ConfigInstance struct { Type string; Value string }
 	ConfigLocations struct { Type string; Value []string }
+	ConfigSite struct { Type string; Value string }
 	Debug struct { Type string; Value bool }
 	Director struct {
 		AdvertisementTTL struct { Type string; Value time.Duration }
@@ -384,22 +549,46 @@ type configWithType struct {
 		CachesPullFromCaches struct { Type string; Value bool }
 		CheckCachePresence struct { Type string; Value bool }
 		CheckOriginPresence struct { Type string; Value bool }
+		DNSHostname struct { Type string; Value string }
+		DNSPort struct { Type string; Value int }
+		DNSTTL struct { Type string; Value time.Duration }
 		DbLocation struct { Type string; Value string }
 		DefaultResponse struct { Type string; Value string }
+		DenyRuleDefaultLifetime struct { Type string; Value time.Duration }
+		DenyRuleMaxLifetime struct { Type string; Value time.Duration }
+		DiscoveryExtraFields struct { Type string; Value interface{} }
+		DiscoveryMaxAge struct { Type string; Value time.Duration }
+		DiscoveryValidity struct { Type string; Value time.Duration }
 		EnableBroker struct { Type string; Value bool }
+		EnableDNS struct { Type string; Value bool }
 		EnableOIDC struct { Type string; Value bool }
 		EnableStat struct { Type string; Value bool }
+		EnableTopologyIssueChecks struct { Type string; Value bool }
 		FilteredServers struct { Type string; Value []string }
 		GeoIPLocation struct { Type string; Value string }
+		GeoIPMaxAge struct { Type string; Value time.Duration }
+		GeoIPRefreshInterval struct { Type string; Value time.Duration }
 		MaxMindKeyFile struct { Type string; Value string }
 		MaxStatResponse struct { Type string; Value int }
 		MinStatResponse struct { Type string; Value int }
+		NamespaceAliases struct { Type string; Value interface{} }
+		NamespaceSLOs struct { Type string; Value interface{} }
 		OriginCacheHealthTestInterval struct { Type string; Value time.Duration }
 		OriginResponseHostnames struct { Type string; Value []string }
+		RateLimitAllowlist struct { Type string; Value []string }
+		RateLimitExemptCaches struct { Type string; Value bool }
+		RateLimitPerIP struct { Type string; Value int }
+		RateLimitPerIPBurst struct { Type string; Value int }
+		RateLimitPerToken struct { Type string; Value int }
+		RateLimitPerTokenBurst struct { Type string; Value int }
+		RedirectStyles struct { Type string; Value interface{} }
+		SortExperiment struct { Type string; Value interface{} }
 		StatConcurrencyLimit struct { Type string; Value int }
 		StatTimeout struct { Type string; Value time.Duration }
+		SubDirectors struct { Type string; Value interface{} }
 		SupportContactEmail struct { Type string; Value string }
 		SupportContactUrl struct { Type string; Value string }
+		TopologyIssueWebhook struct { Type string; Value string }
 		X509ClientAuthenticationPrefixes struct { Type string; Value []string }
 	}
 	DisableHttpProxy struct { Type string; Value bool }
@@ -407,6 +596,8 @@ type configWithType struct {
 	Federation struct {
 		BrokerUrl struct { Type string; Value string }
 		DirectorUrl struct { Type string; Value string }
+		DiscoveryCacheLocation struct { Type string; Value string }
+		DiscoveryCacheTTL struct { Type string; Value time.Duration }
 		DiscoveryUrl struct { Type string; Value string }
 		JwkUrl struct { Type string; Value string }
 		RegistryUrl struct { Type string; Value string }
@@ -421,6 +612,7 @@ type configWithType struct {
 		AuthorizationTemplates struct { Type string; Value interface{} }
 		GroupFile struct { Type string; Value string }
 		GroupRequirements struct { Type string; Value []string }
+		GroupScopeMappingFile struct { Type string; Value string }
 		GroupSource struct { Type string; Value string }
 		IssuerClaimValue struct { Type string; Value string }
 		OIDCAuthenticationRequirements struct { Type string; Value interface{} }
@@ -432,13 +624,18 @@ type configWithType struct {
 		UserStripDomain struct { Type string; Value bool }
 	}
 	IssuerKey struct { Type string; Value string }
+	IssuerKeyURI struct { Type string; Value string }
 	LocalCache struct {
 		DataLocation struct { Type string; Value string }
 		HighWaterMarkPercentage struct { Type string; Value int }
 		LowWaterMarkPercentage struct { Type string; Value int }
+		RevalidateInterval struct { Type string; Value time.Duration }
 		RunLocation struct { Type string; Value string }
 		Size struct { Type string; Value string }
 		Socket struct { Type string; Value string }
+		TokenAuthzCacheLifetime struct { Type string; Value time.Duration }
+		TokenRevocationListRefreshInterval struct { Type string; Value time.Duration }
+		TokenRevocationLists struct { Type string; Value interface{} }
 	}
 	Logging struct {
 		Cache struct {
@@ -451,8 +648,10 @@ type configWithType struct {
 			Xrootd struct { Type string; Value string }
 		}
 		DisableProgressBars struct { Type string; Value bool }
+		Format struct { Type string; Value string }
 		Level struct { Type string; Value string }
 		LogLocation struct { Type string; Value string }
+		Modules struct { Type string; Value interface{} }
 		Origin struct {
 			Cms struct { Type string; Value string }
 			Http struct { Type string; Value string }
@@ -492,16 +691,19 @@ type configWithType struct {
 		ClientSecretFile struct { Type string; Value string }
 		DeviceAuthEndpoint struct { Type string; Value string }
 		Issuer struct { Type string; Value string }
+		Providers struct { Type string; Value interface{} }
 		TokenEndpoint struct { Type string; Value string }
 		UserInfoEndpoint struct { Type string; Value string }
 	}
 	Origin struct {
 		DbLocation struct { Type string; Value string }
 		DirectorTest struct { Type string; Value bool }
+		EnableArchiveMembers struct { Type string; Value bool }
 		EnableBroker struct { Type string; Value bool }
 		EnableCmsd struct { Type string; Value bool }
 		EnableDirListing struct { Type string; Value bool }
 		EnableDirectReads struct { Type string; Value bool }
+		EnableExportScan struct { Type string; Value bool }
 		EnableFallbackRead struct { Type string; Value bool }
 		EnableIssuer struct { Type string; Value bool }
 		EnableListings struct { Type string; Value bool }
@@ -509,10 +711,19 @@ type configWithType struct {
 		EnableOIDC struct { Type string; Value bool }
 		EnablePublicReads struct { Type string; Value bool }
 		EnableReads struct { Type string; Value bool }
+		EnableS3Gateway struct { Type string; Value bool }
 		EnableUI struct { Type string; Value bool }
+		EnableUploadScan struct { Type string; Value bool }
+		EnableUploadStaging struct { Type string; Value bool }
+		EnableVersioning struct { Type string; Value bool }
 		EnableVoms struct { Type string; Value bool }
 		EnableWrite struct { Type string; Value bool }
 		EnableWrites struct { Type string; Value bool }
+		ExperimentalCapabilities struct { Type string; Value []string }
+		ExportScanInterval struct { Type string; Value time.Duration }
+		ExportScanMaxFileRate struct { Type string; Value int }
+		ExportScanMaxRate struct { Type string; Value string }
+		ExportScanWorkers struct { Type string; Value int }
 		ExportVolume struct { Type string; Value string }
 		ExportVolumes struct { Type string; Value []string }
 		Exports struct { Type string; Value interface{} }
@@ -524,13 +735,16 @@ type configWithType struct {
 		GlobusConfigLocation struct { Type string; Value string }
 		HttpAuthTokenFile struct { Type string; Value string }
 		HttpServiceUrl struct { Type string; Value string }
+		MaxVersions struct { Type string; Value int }
 		Mode struct { Type string; Value string }
 		Multiuser struct { Type string; Value bool }
 		NamespacePrefix struct { Type string; Value string }
+		PathRewrites struct { Type string; Value interface{} }
 		Port struct { Type string; Value int }
 		RunLocation struct { Type string; Value string }
 		S3AccessKeyfile struct { Type string; Value string }
 		S3Bucket struct { Type string; Value string }
+		S3GatewayPort struct { Type string; Value int }
 		S3Region struct { Type string; Value string }
 		S3SecretKeyfile struct { Type string; Value string }
 		S3ServiceName struct { Type string; Value string }
@@ -545,7 +759,15 @@ type configWithType struct {
 		SelfTestInterval struct { Type string; Value time.Duration }
 		StoragePrefix struct { Type string; Value string }
 		StorageType struct { Type string; Value string }
+		UploadScanCommand struct { Type string; Value []string }
+		UploadScanICAPUrl struct { Type string; Value string }
+		UploadScanLocation struct { Type string; Value string }
+		UploadScanTimeout struct { Type string; Value time.Duration }
+		UploadStagingLifetime struct { Type string; Value time.Duration }
+		UploadStagingLocation struct { Type string; Value string }
 		Url struct { Type string; Value string }
+		VersionRetention struct { Type string; Value time.Duration }
+		VersionsLocation struct { Type string; Value string }
 		XRootDPrefix struct { Type string; Value string }
 		XRootServiceUrl struct { Type string; Value string }
 	}
@@ -556,14 +778,28 @@ type configWithType struct {
 		AdminUsers struct { Type string; Value []string }
 		CustomRegistrationFields struct { Type string; Value interface{} }
 		DbLocation struct { Type string; Value string }
+		EmailVerificationExpiry struct { Type string; Value time.Duration }
+		IdentityProviders struct { Type string; Value interface{} }
 		Institutions struct { Type string; Value interface{} }
 		InstitutionsUrl struct { Type string; Value string }
 		InstitutionsUrlReloadMinutes struct { Type string; Value time.Duration }
+		IssuerMetadataCacheLifetime struct { Type string; Value time.Duration }
+		KeyRecoveryApprovals struct { Type string; Value int }
+		RecoveryCodeCount struct { Type string; Value int }
+		RegistrationChallenge struct { Type string; Value string }
+		RegistrationChallengeSecretFile struct { Type string; Value string }
+		RegistrationChallengeSiteKey struct { Type string; Value string }
 		RequireCacheApproval struct { Type string; Value bool }
+		RequireEmailVerification struct { Type string; Value bool }
 		RequireKeyChaining struct { Type string; Value bool }
 		RequireOriginApproval struct { Type string; Value bool }
+		SMTPFrom struct { Type string; Value string }
+		SMTPPasswordFile struct { Type string; Value string }
+		SMTPServer struct { Type string; Value string }
+		SMTPUsername struct { Type string; Value string }
 	}
 	Server struct {
+		ConfigWatchInterval struct { Type string; Value time.Duration }
 		EnablePprof struct { Type string; Value bool }
 		EnableUI struct { Type string; Value bool }
 		ExternalWebUrl struct { Type string; Value string }
@@ -572,7 +808,9 @@ type configWithType struct {
 		IssuerJwks struct { Type string; Value string }
 		IssuerPort struct { Type string; Value int }
 		IssuerUrl struct { Type string; Value string }
+		LivenessProbeStrictness struct { Type string; Value string }
 		Modules struct { Type string; Value []string }
+		ReadinessProbeStrictness struct { Type string; Value string }
 		RegistrationRetryInterval struct { Type string; Value time.Duration }
 		SessionSecretFile struct { Type string; Value string }
 		StartupTimeout struct { Type string; Value time.Duration }
@@ -582,6 +820,7 @@ type configWithType struct {
 		TLSCertificate struct { Type string; Value string }
 		TLSCertificateChain struct { Type string; Value string }
 		TLSKey struct { Type string; Value string }
+		UIAccountDbLocation struct { Type string; Value string }
 		UIActivationCodeFile struct { Type string; Value string }
 		UIAdminUsers struct { Type string; Value []string }
 		UILoginRateLimit struct { Type string; Value int }
@@ -615,6 +854,11 @@ type configWithType struct {
 		ShadowOriginPrefix struct { Type string; Value string }
 	}
 	TLSSkipVerify struct { Type string; Value bool }
+	Tracing struct {
+		Endpoint struct { Type string; Value string }
+		Exporter struct { Type string; Value string }
+		SamplingPercentage struct { Type string; Value int }
+	}
 	Transport struct {
 		DialerKeepAlive struct { Type string; Value time.Duration }
 		DialerTimeout struct { Type string; Value time.Duration }
@@ -627,6 +871,8 @@ type configWithType struct {
 	Xrootd struct {
 		AuthRefreshInterval struct { Type string; Value time.Duration }
 		Authfile struct { Type string; Value string }
+		CacheConfigTemplate struct { Type string; Value string }
+		ConfigExtras struct { Type string; Value string }
 		ConfigFile struct { Type string; Value string }
 		DetailedMonitoringHost struct { Type string; Value string }
 		DetailedMonitoringPort struct { Type string; Value int }
@@ -636,7 +882,9 @@ type configWithType struct {
 		ManagerPort struct { Type string; Value int }
 		MaxStartupWait struct { Type string; Value time.Duration }
 		Mount struct { Type string; Value string }
+		OriginConfigTemplate struct { Type string; Value string }
 		Port struct { Type string; Value int }
+		RestartDrainPeriod struct { Type string; Value time.Duration }
 		RobotsTxtFile struct { Type string; Value string }
 		RunLocation struct { Type string; Value string }
 		ScitokensConfig struct { Type string; Value string }
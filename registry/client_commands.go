@@ -21,11 +21,13 @@ package registry
 import (
 	"bufio"
 	"context"
-	"crypto/ecdsa"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/lestrrat-go/jwx/v2/jwk"
@@ -33,6 +35,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_utils"
 	"github.com/pelicanplatform/pelican/token"
 	"github.com/pelicanplatform/pelican/token_scopes"
@@ -49,6 +52,8 @@ type clientResponseData struct {
 	ServerSignature string `json:"server_signature"`
 	Message         string `json:"msg"`
 	Error           string `json:"error"`
+	// Recovery codes for the namespace, only returned when it's first registered
+	RecoveryCodes []string `json:"recovery_codes"`
 }
 
 func NamespaceRegisterWithIdentity(privateKey jwk.Key, namespaceRegistryEndpoint string, prefix string, siteName string) error {
@@ -160,11 +165,12 @@ func NamespaceRegister(privateKey jwk.Key, namespaceRegistryEndpoint string, acc
 	clientPayload := clientNonce + respData.ServerNonce
 
 	// Sign the payload
-	privateKeyRaw := &ecdsa.PrivateKey{}
-	if err = privateKey.Raw(privateKeyRaw); err != nil {
-		return errors.Wrap(err, "failed to get an ECDSA private key")
+	// The key may be held by an external key management service, which signs on our behalf
+	signer, err := config.GetJWKSigner(privateKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to get a signer for the private key")
 	}
-	signature, err := signPayload([]byte(clientPayload), privateKeyRaw)
+	signature, err := signPayload([]byte(clientPayload), signer)
 	if err != nil {
 		return errors.Wrap(err, "failed to sign payload")
 	}
@@ -196,6 +202,16 @@ func NamespaceRegister(privateKey jwk.Key, namespaceRegistryEndpoint string, acc
 		if respData.Message != "" {
 			log.Debugf("Server responded to registration confirmation successfully with message: %s", respData.Message)
 		}
+		if len(respData.RecoveryCodes) > 0 {
+			// The namespace is registered at this point, so failing to save the codes isn't fatal;
+			// new ones can be generated from the registry website
+			if codesFile, err := saveRecoveryCodes(prefix, respData.RecoveryCodes); err != nil {
+				log.Errorf("Failed to save the recovery codes for prefix %s: %v", prefix, err)
+			} else {
+				log.Warningf("Recovery codes for prefix %s were saved to %s. Each code may be used once to replace the "+
+					"registered public key if the private key is lost; store them somewhere safe, they cannot be retrieved again.", prefix, codesFile)
+			}
+		}
 	} else { // Error decoding JSON
 		if err != nil {
 			return errors.Wrapf(err, "Server responded with an error and failed to parse JSON response from the server. Raw response is %s", resp)
@@ -206,6 +222,58 @@ func NamespaceRegister(privateKey jwk.Key, namespaceRegistryEndpoint string, acc
 	return nil
 }
 
+// Save the recovery codes of a newly-registered namespace to a file next to the
+// issuer key, readable only by the current user. Returns the location of the file.
+func saveRecoveryCodes(prefix string, codes []string) (string, error) {
+	fileName := "recovery-codes" + strings.ReplaceAll(path.Clean("/"+prefix), "/", "-") + ".txt"
+	codesFile := filepath.Join(filepath.Dir(param.IssuerKey.GetString()), fileName)
+	contents := fmt.Sprintf("# Recovery codes for the namespace %s, generated %s\n# Each code can be used once, e.g. with `pelican namespace recover --prefix %s --code <code>`\n%s\n",
+		prefix, time.Now().Format(time.RFC3339), prefix, strings.Join(codes, "\n"))
+	if err := os.MkdirAll(filepath.Dir(codesFile), 0700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(codesFile, []byte(contents), 0600); err != nil {
+		return "", err
+	}
+	return codesFile, nil
+}
+
+// Replace the registered public key of a namespace with the public key of privateKey,
+// authorizing the replacement with one of the namespace's recovery codes
+func NamespaceRecover(privateKey jwk.Key, recoveryEndpoint string, prefix string, recoveryCode string) error {
+	publicKey, err := privateKey.PublicKey()
+	if err != nil {
+		return errors.Wrapf(err, "failed to generate public key for namespace key recovery")
+	}
+	if err = jwk.AssignKeyID(publicKey); err != nil {
+		return errors.Wrap(err, "failed to assign key ID to public key")
+	}
+	if err = publicKey.Set("alg", "ES256"); err != nil {
+		return errors.Wrap(err, "failed to assign signature algorithm to public key")
+	}
+	keySet := jwk.NewSet()
+	if err = keySet.AddKey(publicKey); err != nil {
+		return errors.Wrap(err, "failed to add public key to new JWKS")
+	}
+
+	data := map[string]interface{}{
+		"prefix":        prefix,
+		"recovery_code": recoveryCode,
+		"pubkey":        keySet,
+	}
+	tr := config.GetTransport()
+	respData, err := utils.MakeRequest(context.Background(), tr, recoveryEndpoint, "POST", data, nil)
+	var respErr clientResponseData
+	if err != nil {
+		if unmarshalErr := json.Unmarshal(respData, &respErr); unmarshalErr == nil {
+			return errors.Wrapf(err, "Server responded with an error: %s", respErr.Message)
+		}
+		return errors.Wrap(err, "Failed to make request")
+	}
+	fmt.Println(string(respData))
+	return nil
+}
+
 func NamespaceList(endpoint string) error {
 	tr := config.GetTransport()
 	respData, err := utils.MakeRequest(context.Background(), tr, endpoint, "GET", nil, nil)
@@ -20,12 +20,19 @@ package registry
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -241,6 +248,131 @@ func TestRegistryKeyChainingOSDF(t *testing.T) {
 	server_utils.ResetTestState()
 }
 
+// A signer standing in for a key held by an external key management service
+type countingSigner struct {
+	key        *ecdsa.PrivateKey
+	signatures atomic.Int32
+}
+
+func (signer *countingSigner) Public() crypto.PublicKey {
+	return signer.key.Public()
+}
+
+func (signer *countingSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	signer.signatures.Add(1)
+	return signer.key.Sign(rand, digest, opts)
+}
+
+func TestRegisterWithExternalKey(t *testing.T) {
+	ctx, cancel, egrp := test_utils.TestContext(context.Background(), t)
+	defer func() { require.NoError(t, egrp.Wait()) }()
+	defer cancel()
+
+	server_utils.ResetTestState()
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer := &countingSigner{key: privateKey}
+	config.RegisterKeyCustodyProvider("testkms", func(ctx context.Context, keyURI *url.URL) (crypto.Signer, error) {
+		return signer, nil
+	})
+	// Both the registry and the registering origin sign with the external key
+	viper.Set("IssuerKeyURI", "testkms://issuer")
+	serverCredsLoad = sync.Once{}
+	t.Cleanup(func() {
+		serverCredsLoad = sync.Once{}
+		server_utils.ResetTestState()
+	})
+
+	svr := registryMockup(ctx, t, "externalkey")
+	defer func() {
+		err := ShutdownRegistryDB()
+		assert.NoError(t, err)
+		svr.CloseClientConnections()
+		svr.Close()
+	}()
+
+	privKey, err := config.GetIssuerPrivateJWK()
+	require.NoError(t, err)
+	require.True(t, config.IsExternalJWK(privKey))
+
+	err = NamespaceRegister(privKey, svr.URL+"/api/v1.0/registry", "", "/foo/bar", "")
+	require.NoError(t, err)
+	// The registry signed its challenge, and the origin its response
+	assert.Equal(t, int32(2), signer.signatures.Load())
+
+	ns, err := getNamespaceByPrefix("/foo/bar")
+	require.NoError(t, err)
+	assert.Contains(t, ns.Pubkey, privKey.KeyID())
+}
+
+func TestRegisterWithVerification(t *testing.T) {
+	ctx, cancel, egrp := test_utils.TestContext(context.Background(), t)
+	defer func() { require.NoError(t, egrp.Wait()) }()
+	defer cancel()
+
+	server_utils.ResetTestState()
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		activeChallenge = nil
+		sendEmail = sendSMTPEmail
+	})
+
+	svr := registryMockup(ctx, t, "verification")
+	defer func() {
+		err := ShutdownRegistryDB()
+		assert.NoError(t, err)
+		svr.CloseClientConnections()
+		svr.Close()
+	}()
+
+	privKey, err := config.GetIssuerPrivateJWK()
+	require.NoError(t, err)
+
+	t.Run("challenge-refuses-anonymous-registrations", func(t *testing.T) {
+		activeChallenge = &siteVerifyChallenge{}
+		defer func() { activeChallenge = nil }()
+		err := NamespaceRegister(privKey, svr.URL+"/api/v1.0/registry", "", "/foo/bar", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "requires registrations to be verified")
+		exists, err := namespaceExistsByPrefix("/foo/bar")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("email-verification-holds-identified-registrations", func(t *testing.T) {
+		viper.Set("Registry.RequireEmailVerification", true)
+		viper.Set("Registry.EmailVerificationExpiry", "24h")
+		defer viper.Set("Registry.RequireEmailVerification", false)
+		emails := map[string]string{}
+		sendEmail = func(to string, subject string, body string) error {
+			emails[to] = body
+			return nil
+		}
+
+		err := NamespaceRegister(privKey, svr.URL+"/api/v1.0/registry", "", "/foo/baz", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "requires registrations to be verified")
+
+		ns := server_structs.Namespace{Prefix: "/foo/baz", AdminMetadata: server_structs.AdminMetadata{UserID: "owner"}}
+		_, err = holdAPIRegistration(&ns, &registrationData{identityVerified: true}, "")
+		assert.ErrorAs(t, err, &permissionDeniedError{})
+
+		held, err := holdAPIRegistration(&ns, &registrationData{identityVerified: true}, "owner@example.com")
+		require.NoError(t, err)
+		assert.True(t, held)
+		assert.Contains(t, emails["owner@example.com"], "/foo/baz")
+		pending := PendingRegistration{}
+		require.NoError(t, db.First(&pending, "prefix = ?", "/foo/baz").Error)
+		assert.Equal(t, "owner@example.com", pending.Namespace.AdminMetadata.ContactEmail)
+		exists, err := namespaceExistsByPrefix("/foo/baz")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	// Without the checks, anonymous registrations are accepted as before
+	require.NoError(t, NamespaceRegister(privKey, svr.URL+"/api/v1.0/registry", "", "/foo/bar", ""))
+}
+
 func TestRegistryKeyChaining(t *testing.T) {
 	ctx, cancel, egrp := test_utils.TestContext(context.Background(), t)
 	defer func() { require.NoError(t, egrp.Wait()) }()
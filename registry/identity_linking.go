@@ -0,0 +1,593 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/pelicanplatform/pelican/config"
+	pelican_oauth2 "github.com/pelicanplatform/pelican/oauth2"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/web_ui"
+)
+
+type (
+	// An external identity (e.g., an ORCID iD or a GitHub account) that one of the
+	// owners of a namespace proved control of through the provider's OAuth2 flow.
+	// Registry admins use it to validate who actually controls a prefix, and it lets
+	// whoever controls the identity regain ownership of the namespace.
+	NamespaceIdentity struct {
+		ID          int       `json:"id" gorm:"primaryKey;autoIncrement"`
+		NamespaceID int       `json:"namespace_id" gorm:"not null;uniqueIndex:idx_namespace_provider"`
+		Provider    string    `json:"provider" gorm:"not null;uniqueIndex:idx_namespace_provider"`
+		Subject     string    `json:"subject" gorm:"not null"`
+		DisplayName string    `json:"display_name"`
+		ProfileUrl  string    `json:"profile_url"`
+		LinkedBy    string    `json:"linked_by" gorm:"not null"`
+		VerifiedAt  time.Time `json:"verified_at" gorm:"not null"`
+	}
+
+	// An entry of Registry.IdentityProviders
+	identityProviderConfig struct {
+		Name             string `mapstructure:"name"`
+		ClientIDFile     string `mapstructure:"clientIdFile"`
+		ClientSecretFile string `mapstructure:"clientSecretFile"`
+		AuthorizationUrl string `mapstructure:"authorizationUrl"`
+		TokenUrl         string `mapstructure:"tokenUrl"`
+		UserInfoUrl      string `mapstructure:"userInfoUrl"`
+	}
+
+	// The identity established by an OAuth2 flow with an identity provider
+	externalIdentity struct {
+		Subject     string
+		DisplayName string
+		ProfileUrl  string
+	}
+
+	identityProvider struct {
+		name        string
+		oauthConfig *oauth2.Config
+		userInfoUrl string
+		// Derive the identity of the user from the token issued by the provider
+		fetchIdentity func(ctx context.Context, p *identityProvider, token *oauth2.Token) (externalIdentity, error)
+	}
+
+	identityLinkAction string
+)
+
+const (
+	identityProviderOrcid  = "orcid"
+	identityProviderGithub = "github"
+
+	// Link the identity to the namespace
+	identityActionLink identityLinkAction = "link"
+	// Make the logged-in user a co-owner of the namespace after they prove control
+	// of the identity already linked to it
+	identityActionRecover identityLinkAction = "recover"
+
+	identityCallbackPath = "/api/v1.0/registry_ui/identities/callback"
+)
+
+var (
+	identityProviders = map[string]*identityProvider{}
+
+	// Endpoints and identity lookups of the supported providers.  The endpoints may be
+	// overridden in Registry.IdentityProviders, e.g., to use the ORCID sandbox.
+	identityProviderDefaults = map[string]identityProvider{
+		identityProviderOrcid: {
+			oauthConfig: &oauth2.Config{
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  "https://orcid.org/oauth/authorize",
+					TokenURL: "https://orcid.org/oauth/token",
+				},
+				Scopes: []string{"/authenticate"},
+			},
+			fetchIdentity: fetchOrcidIdentity,
+		},
+		identityProviderGithub: {
+			oauthConfig: &oauth2.Config{
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  "https://github.com/login/oauth/authorize",
+					TokenURL: "https://github.com/login/oauth/access_token",
+				},
+			},
+			userInfoUrl:   "https://api.github.com/user",
+			fetchIdentity: fetchGithubIdentity,
+		},
+	}
+)
+
+func (NamespaceIdentity) TableName() string {
+	return "namespace_identity"
+}
+
+// ORCID returns the iD and name of the user alongside the access token
+func fetchOrcidIdentity(_ context.Context, p *identityProvider, token *oauth2.Token) (externalIdentity, error) {
+	orcid, _ := token.Extra("orcid").(string)
+	if orcid == "" {
+		return externalIdentity{}, errors.New("ORCID did not return an iD in the token response")
+	}
+	name, _ := token.Extra("name").(string)
+	profileUrl := "https://orcid.org/" + orcid
+	if authUrl, err := url.Parse(p.oauthConfig.Endpoint.AuthURL); err == nil && authUrl.Host != "" {
+		profileUrl = authUrl.Scheme + "://" + authUrl.Host + "/" + orcid
+	}
+	return externalIdentity{Subject: orcid, DisplayName: name, ProfileUrl: profileUrl}, nil
+}
+
+// GitHub requires a call to its user API.  The numeric user ID is used as the subject
+// because, unlike the login, it never changes.
+func fetchGithubIdentity(ctx context.Context, p *identityProvider, token *oauth2.Token) (externalIdentity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.userInfoUrl, nil)
+	if err != nil {
+		return externalIdentity{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	client := http.Client{Transport: config.GetTransport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return externalIdentity{}, errors.Wrap(err, "failed to query the GitHub user API")
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return externalIdentity{}, errors.Wrap(err, "failed to read the GitHub user API response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return externalIdentity{}, errors.Errorf("GitHub user API returned status code %d: %s", resp.StatusCode, string(body))
+	}
+	user := struct {
+		ID      int64  `json:"id"`
+		Login   string `json:"login"`
+		HtmlUrl string `json:"html_url"`
+	}{}
+	if err = json.Unmarshal(body, &user); err != nil {
+		return externalIdentity{}, errors.Wrap(err, "failed to parse the GitHub user API response")
+	}
+	if user.ID == 0 {
+		return externalIdentity{}, errors.New("GitHub user API did not return a user ID")
+	}
+	return externalIdentity{Subject: strconv.FormatInt(user.ID, 10), DisplayName: user.Login, ProfileUrl: user.HtmlUrl}, nil
+}
+
+func readSecretFile(configName string, location string) (string, error) {
+	if location == "" {
+		return "", errors.Errorf("%s is not set", configName)
+	}
+	contents, err := os.ReadFile(location)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s %s", configName, location)
+	}
+	value := strings.TrimSpace(string(contents))
+	if value == "" {
+		return "", errors.Errorf("%s %s is empty", configName, location)
+	}
+	return value, nil
+}
+
+// Initialize the identity providers namespace owners may link, from Registry.IdentityProviders
+func InitIdentityProviders() error {
+	configs := []identityProviderConfig{}
+	if err := param.Registry_IdentityProviders.Unmarshal(&configs); err != nil {
+		return errors.Wrap(err, "Error reading from config value for Registry.IdentityProviders")
+	}
+	if len(configs) == 0 {
+		identityProviders = map[string]*identityProvider{}
+		return nil
+	}
+	redirectUrl, err := pelican_oauth2.GetRedirectURL(identityCallbackPath)
+	if err != nil {
+		return err
+	}
+
+	providers := make(map[string]*identityProvider, len(configs))
+	for _, conf := range configs {
+		name := strings.ToLower(conf.Name)
+		defaults, ok := identityProviderDefaults[name]
+		if !ok {
+			return errors.Errorf("Bad Registry.IdentityProviders: unsupported identity provider %q; supported providers are %q and %q", conf.Name, identityProviderOrcid, identityProviderGithub)
+		}
+		if _, ok := providers[name]; ok {
+			return errors.Errorf("Bad Registry.IdentityProviders: identity provider %q is configured more than once", name)
+		}
+		clientID, err := readSecretFile(fmt.Sprintf("Registry.IdentityProviders client ID file for %s", name), conf.ClientIDFile)
+		if err != nil {
+			return err
+		}
+		clientSecret, err := readSecretFile(fmt.Sprintf("Registry.IdentityProviders client secret file for %s", name), conf.ClientSecretFile)
+		if err != nil {
+			return err
+		}
+
+		provider := defaults
+		oauthConfig := *defaults.oauthConfig
+		oauthConfig.ClientID = clientID
+		oauthConfig.ClientSecret = clientSecret
+		oauthConfig.RedirectURL = redirectUrl
+		if conf.AuthorizationUrl != "" {
+			oauthConfig.Endpoint.AuthURL = conf.AuthorizationUrl
+		}
+		if conf.TokenUrl != "" {
+			oauthConfig.Endpoint.TokenURL = conf.TokenUrl
+		}
+		if conf.UserInfoUrl != "" {
+			provider.userInfoUrl = conf.UserInfoUrl
+		}
+		provider.name = name
+		provider.oauthConfig = &oauthConfig
+		providers[name] = &provider
+	}
+	identityProviders = providers
+	return nil
+}
+
+// Get the external identities linked to a namespace
+func getNamespaceIdentities(namespaceId int) ([]NamespaceIdentity, error) {
+	identities := []NamespaceIdentity{}
+	err := db.Where("namespace_id = ?", namespaceId).Order("provider ASC").Find(&identities).Error
+	return identities, err
+}
+
+// Get the external identities linked to each of the namespaces, keyed by namespace ID
+func getIdentitiesByNamespace(namespaceIds []int) (map[int][]NamespaceIdentity, error) {
+	identities := []NamespaceIdentity{}
+	if err := db.Where("namespace_id IN ?", namespaceIds).Order("provider ASC").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	byNamespace := make(map[int][]NamespaceIdentity)
+	for _, identity := range identities {
+		byNamespace[identity.NamespaceID] = append(byNamespace[identity.NamespaceID], identity)
+	}
+	return byNamespace, nil
+}
+
+// Link an identity to a namespace, replacing any identity previously linked from the same provider
+func linkNamespaceIdentity(ns *server_structs.Namespace, provider string, identity externalIdentity, user string) (*NamespaceIdentity, error) {
+	record := &NamespaceIdentity{
+		NamespaceID: ns.ID,
+		Provider:    provider,
+		Subject:     identity.Subject,
+		DisplayName: identity.DisplayName,
+		ProfileUrl:  identity.ProfileUrl,
+		LinkedBy:    user,
+		VerifiedAt:  time.Now(),
+	}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "namespace_id"}, {Name: "provider"}},
+		DoUpdates: clause.AssignmentColumns([]string{"subject", "display_name", "profile_url", "linked_by", "verified_at"}),
+	}).Create(record).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to link %s identity to namespace %s", provider, ns.Prefix)
+	}
+	log.Infof("User %s linked the %s identity %s to namespace %s", user, provider, identity.Subject, ns.Prefix)
+	return record, nil
+}
+
+func unlinkNamespaceIdentity(namespaceId int, provider string) error {
+	result := db.Where("namespace_id = ? AND provider = ?", namespaceId, provider).Delete(&NamespaceIdentity{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Make the user a co-owner of the namespace if the identity they proved control of
+// is the one linked to the namespace
+func recoverNamespaceOwnership(ns *server_structs.Namespace, provider string, identity externalIdentity, user string) error {
+	linked := NamespaceIdentity{}
+	err := db.Where("namespace_id = ? AND provider = ?", ns.ID, provider).First(&linked).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && linked.Subject != identity.Subject) {
+		return permissionDeniedError{Message: fmt.Sprintf("the %s identity you logged in with is not linked to the namespace %s", provider, ns.Prefix)}
+	} else if err != nil {
+		return err
+	}
+	if slices.Contains(namespaceOwners(ns), user) {
+		return nil
+	}
+
+	ns.AdminMetadata.CoOwners = append(ns.AdminMetadata.CoOwners, user)
+	ns.AdminMetadata.UpdatedAt = time.Now()
+	adminMetadataByte, err := json.Marshal(ns.AdminMetadata)
+	if err != nil {
+		return errors.Wrap(err, "Error marshaling admin metadata")
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&server_structs.Namespace{}).Where("id = ?", ns.ID).Update("admin_metadata", string(adminMetadataByte)).Error; err != nil {
+			return err
+		}
+		// Re-verifying the identity keeps the record of who last proved control of it
+		return tx.Model(&NamespaceIdentity{}).Where("id = ?", linked.ID).Updates(map[string]interface{}{
+			"linked_by":   user,
+			"verified_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to add co-owner to namespace %s", ns.Prefix)
+	}
+	log.Infof("User %s became a co-owner of namespace %s by proving control of its linked %s identity %s", user, ns.Prefix, provider, identity.Subject)
+	return nil
+}
+
+// Only allow redirects back into the registry web UI after an identity flow
+func safeNextUrl(nextUrl string) string {
+	if !strings.HasPrefix(nextUrl, "/") || strings.HasPrefix(nextUrl, "//") || strings.HasPrefix(nextUrl, "/\\") {
+		return "/view/registry/"
+	}
+	return nextUrl
+}
+
+func identityErrorResp(ctx *gin.Context, err error) {
+	if errors.As(err, &permissionDeniedError{}) {
+		ctx.JSON(http.StatusForbidden, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error()})
+	} else if errors.As(err, &badRequestError{}) {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error()})
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "No identity from this provider is linked to the namespace"})
+	} else {
+		log.Errorln("Failed to process linked identity:", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Server encountered an error processing the linked identity"})
+	}
+}
+
+func getIdentityProvider(ctx *gin.Context, name string) *identityProvider {
+	provider, ok := identityProviders[strings.ToLower(name)]
+	if !ok {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Identity provider %q is not configured in this registry", name)})
+		return nil
+	}
+	return provider
+}
+
+// List the identity providers namespace owners may link
+//
+// GET /identities/providers
+func listIdentityProvidersHandler(ctx *gin.Context) {
+	names := make([]string, 0, len(identityProviders))
+	for name := range identityProviders {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	ctx.JSON(http.StatusOK, names)
+}
+
+// List the external identities linked to a namespace
+//
+// GET /namespaces/:id/identities
+func listNamespaceIdentitiesHandler(ctx *gin.Context) {
+	ns, _ := getOwnedNamespace(ctx, true)
+	if ns == nil {
+		return
+	}
+	identities, err := getNamespaceIdentities(ns.ID)
+	if err != nil {
+		identityErrorResp(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, identities)
+}
+
+// Redirect the user to the identity provider to prove control of an identity.
+// For the "link" action the user must own the namespace; for "recover" any
+// logged-in user may try, and becomes a co-owner if the identity matches the
+// one linked to the namespace.
+//
+// GET /namespaces/:id/identities/:provider/link
+// GET /namespaces/:id/identities/:provider/recover
+func startIdentityFlow(ctx *gin.Context, action identityLinkAction) {
+	provider := getIdentityProvider(ctx, ctx.Param("provider"))
+	if provider == nil {
+		return
+	}
+	var ns *server_structs.Namespace
+	if action == identityActionLink {
+		if ns, _ = getOwnedNamespace(ctx, false); ns == nil {
+			return
+		}
+	} else {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil || id <= 0 {
+			ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Invalid ID format. ID must a positive integer"})
+			return
+		}
+		if ns, err = getNamespaceById(id); err != nil {
+			ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Namespace not found"})
+			return
+		}
+	}
+
+	req := server_structs.OAuthLoginRequest{}
+	if ctx.ShouldBindQuery(&req) != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to bind next url"})
+		return
+	}
+	csrfState, err := web_ui.GenerateCSRFCookie(ctx, map[string]string{
+		"nextUrl":  req.NextUrl,
+		"id":       strconv.Itoa(ns.ID),
+		"provider": provider.name,
+		"action":   string(action),
+	})
+	if err != nil {
+		log.Errorf("Failed to generate CSRF token: %v", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to generate CSRF token"})
+		return
+	}
+	ctx.Redirect(http.StatusTemporaryRedirect, provider.oauthConfig.AuthCodeURL(csrfState))
+}
+
+// Handle the callback of the identity provider, linking the identity to the namespace
+// or recovering ownership of it, depending on the action the flow was started with
+//
+// GET /identities/callback
+func handleIdentityCallback(ctx *gin.Context) {
+	user := ctx.GetString("User")
+	session := sessions.Default(ctx)
+	csrfFromSession := session.Get("oauthstate")
+	if csrfFromSession == nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid OAuth callback: CSRF token from cookie is missing"})
+		return
+	}
+	req := server_structs.OAuthCallbackRequest{}
+	if ctx.ShouldBindQuery(&req) != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid OAuth callback: fail to bind state"})
+		return
+	}
+	stateMap, err := web_ui.ParseOAuthState(req.State)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid OAuth callback: failed to parse state metadata"})
+		return
+	}
+	if pkce, ok := stateMap["pkce"]; !ok || pkce != csrfFromSession {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid OAuth callback: CSRF token doesn't match"})
+		return
+	}
+	// The state is single-use
+	session.Delete("oauthstate")
+	if err := session.Save(); err != nil {
+		log.Warningln("Failed to clear the OAuth state from the session:", err)
+	}
+
+	provider := getIdentityProvider(ctx, stateMap["provider"])
+	if provider == nil {
+		return
+	}
+	action := identityLinkAction(stateMap["action"])
+	if action != identityActionLink && action != identityActionRecover {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Invalid OAuth callback: unknown action %q", action)})
+		return
+	}
+	id, err := strconv.Atoi(stateMap["id"])
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid OAuth callback: namespace ID is missing from the callback state"})
+		return
+	}
+	ns, err := getNamespaceById(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Namespace not found"})
+		return
+	}
+	// Ownership may have changed since the flow started
+	if action == identityActionLink && !slices.Contains(namespaceOwners(ns), user) {
+		ctx.JSON(http.StatusForbidden, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Only the owners of a namespace may link identities to it"})
+		return
+	}
+
+	token, err := provider.oauthConfig.Exchange(ctx.Request.Context(), req.Code)
+	if err != nil {
+		log.Errorf("Error in exchanging code for %s token: %v", provider.name, err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Error in exchanging code for %s token", provider.name)})
+		return
+	}
+	identity, err := provider.fetchIdentity(ctx.Request.Context(), provider, token)
+	if err != nil {
+		log.Errorf("Failed to get the identity of the user from %s: %v", provider.name, err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Failed to get your identity from %s", provider.name)})
+		return
+	}
+
+	if action == identityActionLink {
+		_, err = linkNamespaceIdentity(ns, provider.name, identity, user)
+	} else {
+		err = recoverNamespaceOwnership(ns, provider.name, identity, user)
+	}
+	if err != nil {
+		identityErrorResp(ctx, err)
+		return
+	}
+	ctx.Redirect(http.StatusTemporaryRedirect, safeNextUrl(stateMap["nextUrl"]))
+}
+
+// Remove an identity linked to a namespace
+//
+// DELETE /namespaces/:id/identities/:provider
+func unlinkNamespaceIdentityHandler(ctx *gin.Context) {
+	ns, user := getOwnedNamespace(ctx, true)
+	if ns == nil {
+		return
+	}
+	provider := strings.ToLower(ctx.Param("provider"))
+	if err := unlinkNamespaceIdentity(ns.ID, provider); err != nil {
+		identityErrorResp(ctx, err)
+		return
+	}
+	log.Infof("User %s unlinked the %s identity from namespace %s", user, provider, ns.Prefix)
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{
+		Status: server_structs.RespOK,
+		Msg:    fmt.Sprintf("The %s identity was unlinked from namespace %s", provider, ns.Prefix)})
+}
@@ -0,0 +1,189 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func TestInitIdentityProviders(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		identityProviders = map[string]*identityProvider{}
+	})
+	viper.Set("Server.ExternalWebUrl", "https://registry.example.com")
+	dir := t.TempDir()
+	idFile := filepath.Join(dir, "client-id")
+	secretFile := filepath.Join(dir, "client-secret")
+	require.NoError(t, os.WriteFile(idFile, []byte("my-client\n"), 0600))
+	require.NoError(t, os.WriteFile(secretFile, []byte("my-secret\n"), 0600))
+
+	viper.Set("Registry.IdentityProviders", []map[string]interface{}{
+		{"name": "ORCID", "clientIdFile": idFile, "clientSecretFile": secretFile, "authorizationUrl": "https://sandbox.orcid.org/oauth/authorize"},
+		{"name": "github", "clientIdFile": idFile, "clientSecretFile": secretFile},
+	})
+	require.NoError(t, InitIdentityProviders())
+	require.Len(t, identityProviders, 2)
+	orcid := identityProviders[identityProviderOrcid]
+	require.NotNil(t, orcid)
+	assert.Equal(t, "my-client", orcid.oauthConfig.ClientID)
+	assert.Equal(t, "my-secret", orcid.oauthConfig.ClientSecret)
+	assert.Equal(t, "https://sandbox.orcid.org/oauth/authorize", orcid.oauthConfig.Endpoint.AuthURL)
+	assert.Equal(t, "https://orcid.org/oauth/token", orcid.oauthConfig.Endpoint.TokenURL)
+	assert.Equal(t, "https://registry.example.com"+identityCallbackPath, orcid.oauthConfig.RedirectURL)
+	// The defaults are not modified by the overrides
+	assert.Equal(t, "https://orcid.org/oauth/authorize", identityProviderDefaults[identityProviderOrcid].oauthConfig.Endpoint.AuthURL)
+	assert.Equal(t, "https://api.github.com/user", identityProviders[identityProviderGithub].userInfoUrl)
+
+	viper.Set("Registry.IdentityProviders", []map[string]interface{}{
+		{"name": "myspace", "clientIdFile": idFile, "clientSecretFile": secretFile},
+	})
+	assert.ErrorContains(t, InitIdentityProviders(), "unsupported identity provider")
+
+	viper.Set("Registry.IdentityProviders", []map[string]interface{}{
+		{"name": "github", "clientIdFile": filepath.Join(dir, "missing"), "clientSecretFile": secretFile},
+	})
+	assert.Error(t, InitIdentityProviders())
+}
+
+func TestFetchIdentity(t *testing.T) {
+	t.Run("orcid", func(t *testing.T) {
+		provider := &identityProvider{oauthConfig: &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://sandbox.orcid.org/oauth/authorize"}}}
+		token := (&oauth2.Token{AccessToken: "token"}).WithExtra(map[string]interface{}{"orcid": "0000-0002-1825-0097", "name": "Josiah Carberry"})
+		identity, err := fetchOrcidIdentity(context.Background(), provider, token)
+		require.NoError(t, err)
+		assert.Equal(t, externalIdentity{
+			Subject:     "0000-0002-1825-0097",
+			DisplayName: "Josiah Carberry",
+			ProfileUrl:  "https://sandbox.orcid.org/0000-0002-1825-0097",
+		}, identity)
+
+		_, err = fetchOrcidIdentity(context.Background(), provider, &oauth2.Token{AccessToken: "token"})
+		assert.Error(t, err)
+	})
+
+	t.Run("github", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"id": 583231, "login": "octocat", "html_url": "https://github.com/octocat"}`))
+		}))
+		defer server.Close()
+		provider := &identityProvider{userInfoUrl: server.URL}
+		identity, err := fetchGithubIdentity(context.Background(), provider, &oauth2.Token{AccessToken: "token"})
+		require.NoError(t, err)
+		assert.Equal(t, externalIdentity{Subject: "583231", DisplayName: "octocat", ProfileUrl: "https://github.com/octocat"}, identity)
+
+		_, err = fetchGithubIdentity(context.Background(), provider, &oauth2.Token{AccessToken: "wrong"})
+		assert.ErrorContains(t, err, "401")
+	})
+}
+
+func TestNamespaceIdentities(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(server_utils.ResetTestState)
+	setupMockRegistryDB(t)
+	defer teardownMockNamespaceDB(t)
+
+	require.NoError(t, insertMockDBData([]server_structs.Namespace{
+		mockNamespace("/foo", "pubkey", "", server_structs.AdminMetadata{UserID: "owner"}),
+		mockNamespace("/bar", "pubkey", "", server_structs.AdminMetadata{UserID: "other-owner"}),
+	}))
+	foo, err := getNamespaceByPrefix("/foo")
+	require.NoError(t, err)
+	bar, err := getNamespaceByPrefix("/bar")
+	require.NoError(t, err)
+	orcidId := externalIdentity{Subject: "0000-0002-1825-0097", DisplayName: "Josiah Carberry"}
+
+	t.Run("link-and-relink", func(t *testing.T) {
+		_, err := linkNamespaceIdentity(foo, identityProviderOrcid, externalIdentity{Subject: "0000-0001-5109-3700"}, "owner")
+		require.NoError(t, err)
+		// Linking again from the same provider replaces the identity
+		_, err = linkNamespaceIdentity(foo, identityProviderOrcid, orcidId, "owner")
+		require.NoError(t, err)
+		_, err = linkNamespaceIdentity(foo, identityProviderGithub, externalIdentity{Subject: "583231", DisplayName: "octocat"}, "owner")
+		require.NoError(t, err)
+
+		identities, err := getNamespaceIdentities(foo.ID)
+		require.NoError(t, err)
+		require.Len(t, identities, 2)
+		assert.Equal(t, identityProviderGithub, identities[0].Provider)
+		assert.Equal(t, identityProviderOrcid, identities[1].Provider)
+		assert.Equal(t, orcidId.Subject, identities[1].Subject)
+		assert.Equal(t, "owner", identities[1].LinkedBy)
+
+		byNamespace, err := getIdentitiesByNamespace([]int{foo.ID, bar.ID})
+		require.NoError(t, err)
+		assert.Len(t, byNamespace[foo.ID], 2)
+		assert.Empty(t, byNamespace[bar.ID])
+	})
+
+	t.Run("recover-with-wrong-identity", func(t *testing.T) {
+		err := recoverNamespaceOwnership(foo, identityProviderOrcid, externalIdentity{Subject: "0000-0001-5109-3700"}, "newcomer")
+		assert.ErrorAs(t, err, &permissionDeniedError{})
+		// No identity is linked to /bar at all
+		err = recoverNamespaceOwnership(bar, identityProviderOrcid, orcidId, "newcomer")
+		assert.ErrorAs(t, err, &permissionDeniedError{})
+	})
+
+	t.Run("recover-with-linked-identity", func(t *testing.T) {
+		require.NoError(t, recoverNamespaceOwnership(foo, identityProviderOrcid, orcidId, "newcomer"))
+		ns, err := getNamespaceByPrefix("/foo")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"newcomer"}, ns.AdminMetadata.CoOwners)
+
+		// Recovering again does not add a duplicate co-owner
+		require.NoError(t, recoverNamespaceOwnership(ns, identityProviderOrcid, orcidId, "newcomer"))
+		ns, err = getNamespaceByPrefix("/foo")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"newcomer"}, ns.AdminMetadata.CoOwners)
+	})
+
+	t.Run("unlink", func(t *testing.T) {
+		require.NoError(t, unlinkNamespaceIdentity(foo.ID, identityProviderGithub))
+		assert.ErrorIs(t, unlinkNamespaceIdentity(foo.ID, identityProviderGithub), gorm.ErrRecordNotFound)
+		identities, err := getNamespaceIdentities(foo.ID)
+		require.NoError(t, err)
+		assert.Len(t, identities, 1)
+	})
+}
+
+func TestSafeNextUrl(t *testing.T) {
+	assert.Equal(t, "/view/registry/namespace/edit/?id=1", safeNextUrl("/view/registry/namespace/edit/?id=1"))
+	assert.Equal(t, "/view/registry/", safeNextUrl(""))
+	assert.Equal(t, "/view/registry/", safeNextUrl("https://evil.example.com"))
+	assert.Equal(t, "/view/registry/", safeNextUrl("//evil.example.com"))
+}
@@ -0,0 +1,254 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+const (
+	// The route, under the registry API, of the proxies of the namespace issuers
+	issuerProxyPath = "/issuer"
+
+	// The largest issuer document the registry proxies
+	maxIssuerDocumentSize = 1024 * 1024
+)
+
+var (
+	// Issuer documents fetched by the proxies, keyed by their URL
+	issuerDocumentCache = ttlcache.New[string, []byte]()
+)
+
+// Start the cache of the issuer metadata the registry proxies for the namespaces
+func InitIssuerMetadataCache(ctx context.Context, egrp *errgroup.Group) {
+	go issuerDocumentCache.Start()
+
+	egrp.Go(func() error {
+		<-ctx.Done()
+		issuerDocumentCache.DeleteAll()
+		issuerDocumentCache.Stop()
+		return nil
+	})
+}
+
+// Fetch a JSON document of an issuer, using the cached copy if there's one
+func getIssuerDocument(ctx context.Context, docUrl string) ([]byte, error) {
+	if item := issuerDocumentCache.Get(docUrl); item != nil {
+		return item.Value(), nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, docUrl, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the request for %s", docUrl)
+	}
+	req.Header.Set("Accept", "application/json")
+	client := http.Client{Transport: config.GetTransport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch %s", docUrl)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fetching %s returned status code %d", docUrl, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxIssuerDocumentSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", docUrl)
+	}
+	if len(body) > maxIssuerDocumentSize {
+		return nil, errors.Errorf("%s is larger than %d bytes", docUrl, maxIssuerDocumentSize)
+	}
+	if !json.Valid(body) {
+		return nil, errors.Errorf("%s is not a JSON document", docUrl)
+	}
+	issuerDocumentCache.Set(docUrl, body, param.Registry_IssuerMetadataCacheLifetime.GetDuration())
+	return body, nil
+}
+
+// Fetch the openid-configuration of the issuer, checking that it describes the issuer
+func getIssuerConfiguration(ctx context.Context, issuer string) (map[string]interface{}, error) {
+	body, err := getIssuerDocument(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	issuerConfig := map[string]interface{}{}
+	if err := json.Unmarshal(body, &issuerConfig); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the openid-configuration of issuer %s", issuer)
+	}
+	// Don't serve metadata of another issuer under the namespace
+	if configIssuer, _ := issuerConfig["issuer"].(string); strings.TrimSuffix(configIssuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return nil, errors.Errorf("the openid-configuration of issuer %s is for issuer %q", issuer, configIssuer)
+	}
+	return issuerConfig, nil
+}
+
+// Get the issuer registered for the namespace, writing the error response if the
+// namespace doesn't exist, isn't approved, or has no issuer
+func getNamespaceIssuer(ctx *gin.Context, prefix string) (issuer string, ok bool) {
+	exists, err := namespaceExistsByPrefix(prefix)
+	if err != nil {
+		log.Errorf("Error while checking for existence of prefix %s: %v", prefix, err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Server encountered an error while checking if the prefix exists"})
+		return
+	}
+	if !exists {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("The requested prefix %s does not exist in the registry's database", prefix)})
+		return
+	}
+	ns, err := getNamespaceByPrefix(prefix)
+	if err != nil {
+		log.Errorf("Failed to load namespace for prefix %s: %v", prefix, err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "server encountered an error trying to get the namespace registration for the prefix " + prefix})
+		return
+	}
+	// The issuer is set by the registrant, so the registry only fetches from it once a
+	// federation administrator has approved the namespace, even if approval isn't required
+	if ns.AdminMetadata.Status != server_structs.RegApproved {
+		ctx.JSON(http.StatusForbidden, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "The namespace has not been approved by a federation administrator"})
+		return
+	}
+	if ns.AdminMetadata.Issuer == "" {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("The namespace %s has no registered issuer", prefix)})
+		return
+	}
+	return ns.AdminMetadata.Issuer, true
+}
+
+// Check that the jwks_uri of an issuer is served by the issuer itself, so that the
+// metadata can't point the registry at any other host
+func checkJwksUri(issuer string, jwksUri string) error {
+	issuerUrl, err := url.Parse(issuer)
+	if err != nil {
+		return errors.Wrapf(err, "invalid issuer URL %s", issuer)
+	}
+	jwksUrl, err := url.Parse(jwksUri)
+	if err != nil {
+		return errors.Wrapf(err, "invalid jwks_uri %s", jwksUri)
+	}
+	if jwksUrl.Scheme != issuerUrl.Scheme || jwksUrl.Host != issuerUrl.Host {
+		return errors.Errorf("the jwks_uri %s is not on the host of the issuer", jwksUri)
+	}
+	return nil
+}
+
+// Proxy the metadata of the issuer registered for a namespace, so that clients that can't
+// reach the issuer of every collaboration can discover it through the registry.  The path
+// is relative to issuerProxyPath and is one of
+//
+//   - <prefix>/.well-known/openid-configuration: the issuer metadata, with the jwks_uri
+//     pointing at the proxy of the issuer public keys below
+//   - <prefix>/.well-known/issuer.jwks: the public keys of the issuer
+func issuerProxyHandler(ctx *gin.Context, proxyPath string) {
+	var prefix string
+	var isJwks bool
+	if strings.HasSuffix(proxyPath, "/.well-known/openid-configuration") {
+		prefix = strings.TrimSuffix(proxyPath, "/.well-known/openid-configuration")
+	} else if strings.HasSuffix(proxyPath, "/.well-known/issuer.jwks") {
+		prefix = strings.TrimSuffix(proxyPath, "/.well-known/issuer.jwks")
+		isJwks = true
+	} else {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Only the openid-configuration and issuer.jwks of a namespace issuer are proxied"})
+		return
+	}
+	prefix = path.Clean("/" + prefix)
+	issuer, ok := getNamespaceIssuer(ctx, prefix)
+	if !ok {
+		return
+	}
+
+	issuerConfig, err := getIssuerConfiguration(ctx.Request.Context(), issuer)
+	if err != nil {
+		log.Warningf("Failed to proxy the metadata of issuer %s for namespace %s: %v", issuer, prefix, err)
+		ctx.JSON(http.StatusBadGateway, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Failed to get the metadata of the issuer of namespace %s", prefix)})
+		return
+	}
+
+	if isJwks {
+		jwksUri, _ := issuerConfig["jwks_uri"].(string)
+		if jwksUri == "" {
+			ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    fmt.Sprintf("The issuer of namespace %s does not publish its public keys", prefix)})
+			return
+		}
+		if err := checkJwksUri(issuer, jwksUri); err != nil {
+			log.Warningf("Refusing to proxy the public keys of issuer %s for namespace %s: %v", issuer, prefix, err)
+			ctx.JSON(http.StatusBadGateway, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    fmt.Sprintf("The issuer of namespace %s publishes its public keys on another host", prefix)})
+			return
+		}
+		jwks, err := getIssuerDocument(ctx.Request.Context(), jwksUri)
+		if err != nil {
+			log.Warningf("Failed to proxy the public keys of issuer %s for namespace %s: %v", issuer, prefix, err)
+			ctx.JSON(http.StatusBadGateway, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    fmt.Sprintf("Failed to get the public keys of the issuer of namespace %s", prefix)})
+			return
+		}
+		ctx.Data(http.StatusOK, "application/json", jwks)
+		return
+	}
+
+	// Point the clients at the proxy of the public keys; the other endpoints, e.g. to
+	// obtain tokens, are left as they are
+	if _, ok := issuerConfig["jwks_uri"]; ok {
+		jwksUrl, err := url.Parse(param.Server_ExternalWebUrl.GetString())
+		if err != nil {
+			log.Errorf("Failed to parse configured external web URL while constructing the issuer jwks proxy location: %v", err)
+			ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Server encountered an error while constructing the issuer metadata"})
+			return
+		}
+		jwksUrl.Path, _ = url.JoinPath("/api", "v1.0", "registry", issuerProxyPath, prefix, ".well-known", "issuer.jwks")
+		issuerConfig["jwks_uri"] = jwksUrl.String()
+	}
+	ctx.JSON(http.StatusOK, issuerConfig)
+}
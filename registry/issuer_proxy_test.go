@@ -0,0 +1,135 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func TestIssuerProxy(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		issuerDocumentCache.DeleteAll()
+	})
+	viper.Set("Server.ExternalWebUrl", "https://registry.example.com:8444")
+
+	var fetches atomic.Int32
+	var issuerUrl string
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer": %q, "jwks_uri": %q, "token_endpoint": %q}`, issuerUrl, issuerUrl+"/jwks", issuerUrl+"/token")
+		case "/jwks":
+			fmt.Fprint(w, `{"keys": [{"kty": "EC", "kid": "key1"}]}`)
+		case "/other/.well-known/openid-configuration":
+			fmt.Fprint(w, `{"issuer": "https://other.example.com"}`)
+		case "/elsewhere/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer": %q, "jwks_uri": "http://169.254.169.254/latest/meta-data"}`, issuerUrl+"/elsewhere")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(issuer.Close)
+	issuerUrl = issuer.URL
+
+	setupMockRegistryDB(t)
+	t.Cleanup(func() { teardownMockNamespaceDB(t) })
+	require.NoError(t, insertMockDBData([]server_structs.Namespace{
+		mockNamespace("/collab", "pubkey1", "", server_structs.AdminMetadata{Status: server_structs.RegApproved, Issuer: issuerUrl}),
+		mockNamespace("/no-issuer", "pubkey2", "", server_structs.AdminMetadata{Status: server_structs.RegApproved}),
+		mockNamespace("/spoofed", "pubkey3", "", server_structs.AdminMetadata{Status: server_structs.RegApproved, Issuer: issuerUrl + "/other"}),
+		mockNamespace("/elsewhere", "pubkey5", "", server_structs.AdminMetadata{Status: server_structs.RegApproved, Issuer: issuerUrl + "/elsewhere"}),
+	}))
+
+	r := gin.New()
+	r.GET("/api/v1.0/registry/*wildcard", wildcardHandler)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1.0/registry/issuer"+path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("proxies-openid-configuration", func(t *testing.T) {
+		w := get("/collab/.well-known/openid-configuration")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		issuerConfig := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issuerConfig))
+		assert.Equal(t, issuerUrl, issuerConfig["issuer"])
+		assert.Equal(t, issuerUrl+"/token", issuerConfig["token_endpoint"])
+		assert.Equal(t, "https://registry.example.com:8444/api/v1.0/registry/issuer/collab/.well-known/issuer.jwks", issuerConfig["jwks_uri"])
+	})
+
+	t.Run("proxies-jwks", func(t *testing.T) {
+		w := get("/collab/.well-known/issuer.jwks")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"keys": [{"kty": "EC", "kid": "key1"}]}`, w.Body.String())
+	})
+
+	t.Run("caches-documents", func(t *testing.T) {
+		before := fetches.Load()
+		assert.Equal(t, http.StatusOK, get("/collab/.well-known/openid-configuration").Code)
+		assert.Equal(t, http.StatusOK, get("/collab/.well-known/issuer.jwks").Code)
+		assert.Equal(t, before, fetches.Load())
+	})
+
+	t.Run("rejects-namespace-without-issuer", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/no-issuer/.well-known/openid-configuration").Code)
+		assert.Equal(t, http.StatusNotFound, get("/does-not-exist/.well-known/openid-configuration").Code)
+		assert.Equal(t, http.StatusNotFound, get("/collab/.well-known/other").Code)
+	})
+
+	t.Run("rejects-metadata-of-another-issuer", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadGateway, get("/spoofed/.well-known/openid-configuration").Code)
+	})
+
+	t.Run("rejects-jwks-on-another-host", func(t *testing.T) {
+		before := fetches.Load()
+		assert.Equal(t, http.StatusBadGateway, get("/elsewhere/.well-known/issuer.jwks").Code)
+		// Only the openid-configuration was fetched
+		assert.Equal(t, before+1, fetches.Load())
+	})
+
+	t.Run("requires-approval", func(t *testing.T) {
+		// Even if the federation doesn't require the approval of namespaces
+		require.NoError(t, insertMockDBData([]server_structs.Namespace{
+			mockNamespace("/pending", "pubkey4", "", server_structs.AdminMetadata{Status: server_structs.RegPending, Issuer: issuerUrl}),
+		}))
+		before := fetches.Load()
+		assert.Equal(t, http.StatusForbidden, get("/pending/.well-known/openid-configuration").Code)
+		assert.Equal(t, http.StatusForbidden, get("/pending/.well-known/issuer.jwks").Code)
+		assert.Equal(t, before, fetches.Load())
+	})
+}
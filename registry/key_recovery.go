@@ -0,0 +1,567 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/web_ui"
+)
+
+type (
+	// A single-use code that authorizes replacing the public key of a namespace.
+	// Only a hash of the code is stored in the registry.
+	RecoveryCode struct {
+		ID          int       `gorm:"primaryKey;autoIncrement"`
+		NamespaceID int       `gorm:"not null"`
+		CodeHash    string    `gorm:"not null;unique"`
+		CreatedAt   time.Time `gorm:"not null"`
+		UsedAt      *time.Time
+	}
+
+	keyRecoveryStatus string
+
+	// A request, by one of the owners of a namespace, to replace its public key.
+	// The key is replaced once Registry.KeyRecoveryApprovals owners approve the request.
+	KeyRecoveryRequest struct {
+		ID          int               `json:"id" gorm:"primaryKey;autoIncrement"`
+		NamespaceID int               `json:"namespace_id" gorm:"not null"`
+		Pubkey      string            `json:"pubkey" gorm:"not null"`
+		RequestedBy string            `json:"requested_by" gorm:"not null"`
+		Approvals   []string          `json:"approvals" gorm:"serializer:json;not null"`
+		Status      keyRecoveryStatus `json:"status" gorm:"type:text;not null"`
+		CreatedAt   time.Time         `json:"created_at" gorm:"not null"`
+		CompletedAt *time.Time        `json:"completed_at,omitempty"`
+		// Incremented on each approval, so that concurrent approvals don't overwrite each other
+		Version int `json:"-" gorm:"not null;default:0"`
+	}
+
+	// Request body for replacing the public key of a namespace with a recovery code
+	recoverKeyReq struct {
+		Prefix       string          `json:"prefix" binding:"required"`
+		RecoveryCode string          `json:"recovery_code" binding:"required"`
+		Pubkey       json.RawMessage `json:"pubkey" binding:"required"`
+	}
+
+	// Request body for proposing a new public key for a namespace
+	keyRecoveryProposalReq struct {
+		Pubkey string `json:"pubkey" binding:"required"`
+	}
+
+	recoveryCodesRes struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+)
+
+const (
+	keyRecoveryPending   keyRecoveryStatus = "pending"
+	keyRecoveryCompleted keyRecoveryStatus = "completed"
+	keyRecoveryCancelled keyRecoveryStatus = "cancelled"
+
+	// Number of random bytes in a recovery code; encoded in base32, this gives 16 characters
+	recoveryCodeBytes = 10
+)
+
+var (
+	errKeyRecoveryConflict = errors.New("the key recovery request was modified concurrently")
+
+	// How many times to retry an approval that raced with another one
+	keyRecoveryApproveAttempts = 5
+)
+
+func (RecoveryCode) TableName() string {
+	return "recovery_code"
+}
+
+func (KeyRecoveryRequest) TableName() string {
+	return "key_recovery_request"
+}
+
+// Generate a random recovery code, formatted as four dash-separated groups
+// of four characters for readability (e.g., "abcd-efgh-ijkl-mnop")
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, recoveryCodeBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", errors.Wrap(err, "failed to generate random recovery code")
+	}
+	encoded := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw))
+	groups := make([]string, 0, len(encoded)/4)
+	for idx := 0; idx < len(encoded); idx += 4 {
+		groups = append(groups, encoded[idx:idx+4])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// Hash a recovery code for storage, ignoring case, dashes, and whitespace so that
+// codes copied by hand still match
+func hashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// Generate a new set of Registry.RecoveryCodeCount recovery codes for a namespace,
+// invalidating any existing codes. The returned codes are not stored anywhere and
+// cannot be retrieved again. Returns no codes if recovery codes are disabled.
+func createRecoveryCodes(namespaceId int) ([]string, error) {
+	count := param.Registry_RecoveryCodeCount.GetInt()
+	if count <= 0 {
+		return nil, nil
+	}
+
+	codes := make([]string, 0, count)
+	records := make([]RecoveryCode, 0, count)
+	now := time.Now()
+	for idx := 0; idx < count; idx++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, RecoveryCode{NamespaceID: namespaceId, CodeHash: hashRecoveryCode(code), CreatedAt: now})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("namespace_id = ?", namespaceId).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to store recovery codes for namespace with id %d", namespaceId)
+	}
+	return codes, nil
+}
+
+// Count the recovery codes of a namespace that have not been used yet
+func countUnusedRecoveryCodes(namespaceId int) (int64, error) {
+	var count int64
+	err := db.Model(&RecoveryCode{}).Where("namespace_id = ? AND used_at IS NULL", namespaceId).Count(&count).Error
+	return count, err
+}
+
+// Replace the public key of a namespace within a transaction, cancelling any
+// pending co-owner key recovery requests for it
+func replaceNamespaceKey(tx *gorm.DB, ns *server_structs.Namespace, pubkey string) error {
+	ns.Pubkey = pubkey
+	ns.AdminMetadata.UpdatedAt = time.Now()
+	adminMetadataByte, err := json.Marshal(ns.AdminMetadata)
+	if err != nil {
+		return errors.Wrap(err, "Error marshaling admin metadata")
+	}
+	err = tx.Model(&server_structs.Namespace{}).Where("id = ?", ns.ID).Updates(map[string]interface{}{
+		"pubkey":         pubkey,
+		"admin_metadata": string(adminMetadataByte),
+	}).Error
+	if err != nil {
+		return errors.Wrapf(err, "failed to update the public key of namespace %s", ns.Prefix)
+	}
+	return tx.Model(&KeyRecoveryRequest{}).
+		Where("namespace_id = ? AND status = ?", ns.ID, keyRecoveryPending).
+		Update("status", keyRecoveryCancelled).Error
+}
+
+// Replace the public key of the namespace with the given prefix, consuming one of its
+// recovery codes. Returns the number of unused recovery codes left for the namespace.
+//
+// An unknown prefix and an invalid or already used code both result in a
+// permissionDeniedError so that callers cannot probe for registered namespaces.
+func recoverNamespaceKey(prefix string, code string, pubkey string) (int64, error) {
+	invalidErr := permissionDeniedError{Message: "invalid or already used recovery code for the namespace " + prefix}
+	ns, err := getNamespaceByPrefix(prefix)
+	if err != nil {
+		return 0, invalidErr
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// The used_at condition makes sure concurrent requests cannot use the same code twice
+		result := tx.Model(&RecoveryCode{}).
+			Where("namespace_id = ? AND code_hash = ? AND used_at IS NULL", ns.ID, hashRecoveryCode(code)).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return invalidErr
+		}
+		return replaceNamespaceKey(tx, ns, pubkey)
+	})
+	if err != nil {
+		return 0, err
+	}
+	log.Infof("Public key of namespace %s was replaced using a recovery code", ns.Prefix)
+	return countUnusedRecoveryCodes(ns.ID)
+}
+
+// Get the owners of a namespace: the user who registered it plus its co-owners
+func namespaceOwners(ns *server_structs.Namespace) []string {
+	owners := []string{}
+	if ns.AdminMetadata.UserID != "" {
+		owners = append(owners, ns.AdminMetadata.UserID)
+	}
+	for _, coOwner := range ns.AdminMetadata.CoOwners {
+		if coOwner != "" && !slices.Contains(owners, coOwner) {
+			owners = append(owners, coOwner)
+		}
+	}
+	return owners
+}
+
+// Whether two lists of co-owners name the same users, in any order
+func sameCoOwners(a []string, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// Record the approval of a key recovery request by one of the namespace owners,
+// replacing the namespace key once Registry.KeyRecoveryApprovals owners have approved.
+// On success, request is updated to the stored state of the request.
+func approveKeyRecoveryRequest(ns *server_structs.Namespace, request *KeyRecoveryRequest, user string) error {
+	for attempt := 0; attempt < keyRecoveryApproveAttempts; attempt++ {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return approveKeyRecoveryRequestTx(tx, ns, request, user)
+		})
+		if !errors.Is(err, errKeyRecoveryConflict) {
+			return err
+		}
+	}
+	return errors.Errorf("key recovery request %d kept changing while approving it; try again", request.ID)
+}
+
+// Add the approval of the user to the stored request, as of its stored version.  If the
+// request changed since it was read, e.g. because another owner approved it at the same
+// time, errKeyRecoveryConflict is returned and the transaction must be retried.
+func approveKeyRecoveryRequestTx(tx *gorm.DB, ns *server_structs.Namespace, request *KeyRecoveryRequest, user string) error {
+	current := KeyRecoveryRequest{}
+	if err := tx.Where("id = ? AND status = ?", request.ID, keyRecoveryPending).First(&current).Error; err != nil {
+		return err
+	}
+	if slices.Contains(current.Approvals, user) {
+		return badRequestError{Message: fmt.Sprintf("user %s already approved key recovery request %d", user, current.ID)}
+	}
+	current.Approvals = append(current.Approvals, user)
+	approvalsByte, err := json.Marshal(current.Approvals)
+	if err != nil {
+		return errors.Wrap(err, "Error marshaling key recovery approvals")
+	}
+	updates := map[string]interface{}{
+		"approvals": string(approvalsByte),
+		"version":   current.Version + 1,
+	}
+	complete := len(current.Approvals) >= param.Registry_KeyRecoveryApprovals.GetInt()
+	now := time.Now()
+	if complete {
+		updates["status"] = keyRecoveryCompleted
+		updates["completed_at"] = now
+	}
+	result := tx.Model(&KeyRecoveryRequest{}).Where("id = ? AND version = ?", current.ID, current.Version).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return errKeyRecoveryConflict
+	}
+	current.Version++
+	if complete {
+		if err := replaceNamespaceKey(tx, ns, current.Pubkey); err != nil {
+			return err
+		}
+		current.Status = keyRecoveryCompleted
+		current.CompletedAt = &now
+		log.Infof("Public key of namespace %s was replaced after approval by owners %v", ns.Prefix, current.Approvals)
+	}
+	*request = current
+	return nil
+}
+
+// Create a key recovery request for a namespace on behalf of one of its owners, who
+// implicitly approves it. If a single approval suffices, the key is replaced right away.
+func createKeyRecoveryRequest(ns *server_structs.Namespace, user string, pubkey string) (*KeyRecoveryRequest, error) {
+	required := param.Registry_KeyRecoveryApprovals.GetInt()
+	if required <= 0 {
+		return nil, badRequestError{Message: "co-owner key recovery is disabled in this registry"}
+	}
+	if owners := namespaceOwners(ns); len(owners) < required {
+		return nil, badRequestError{Message: fmt.Sprintf("key recovery requires the approval of %d owners, but the namespace %s only has %d", required, ns.Prefix, len(owners))}
+	}
+
+	request := &KeyRecoveryRequest{
+		NamespaceID: ns.ID,
+		Pubkey:      pubkey,
+		RequestedBy: user,
+		Approvals:   []string{},
+		Status:      keyRecoveryPending,
+		CreatedAt:   time.Now(),
+	}
+	if err := db.Create(request).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to create key recovery request")
+	}
+	if err := approveKeyRecoveryRequest(ns, request, user); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Get a pending key recovery request of a namespace
+func getPendingKeyRecoveryRequest(namespaceId int, requestId int) (*KeyRecoveryRequest, error) {
+	request := KeyRecoveryRequest{}
+	err := db.Where("id = ? AND namespace_id = ? AND status = ?", requestId, namespaceId, keyRecoveryPending).First(&request).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// Get all pending key recovery requests of a namespace
+func getPendingKeyRecoveryRequests(namespaceId int) ([]KeyRecoveryRequest, error) {
+	requests := []KeyRecoveryRequest{}
+	err := db.Where("namespace_id = ? AND status = ?", namespaceId, keyRecoveryPending).Order("id ASC").Find(&requests).Error
+	return requests, err
+}
+
+// Load the namespace given by the "id" path parameter, making sure the logged-in
+// user is one of its owners (or, if allowAdmin is set, a registry admin).
+// Writes the error response and returns nil if the namespace can't be used.
+func getOwnedNamespace(ctx *gin.Context, allowAdmin bool) (*server_structs.Namespace, string) {
+	user := ctx.GetString("User")
+	if user == "" {
+		ctx.JSON(http.StatusUnauthorized, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "You need to login to perform this action"})
+		return nil, ""
+	}
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid ID format. ID must a positive integer"})
+		return nil, ""
+	}
+	exists, err := namespaceExistsById(id)
+	if err != nil {
+		log.Error("Error checking if namespace exists: ", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Error checking if namespace exists"})
+		return nil, ""
+	}
+	if !exists {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Namespace not found"})
+		return nil, ""
+	}
+	ns, err := getNamespaceById(id)
+	if err != nil {
+		log.Error("Error getting namespace: ", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Error getting namespace"})
+		return nil, ""
+	}
+	if isAdmin, _ := web_ui.CheckAdmin(user); allowAdmin && isAdmin {
+		return ns, user
+	}
+	if !slices.Contains(namespaceOwners(ns), user) {
+		log.Errorf("Access denied from user %s for namespace with id=%d", user, id)
+		ctx.JSON(http.StatusForbidden, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "You do not have permissions to manage the keys of this namespace. Check the id or if you own the namespace"})
+		return nil, ""
+	}
+	return ns, user
+}
+
+// Write the error response for a failed key recovery operation
+func keyRecoveryErrorResp(ctx *gin.Context, err error) {
+	if errors.As(err, &permissionDeniedError{}) {
+		ctx.JSON(http.StatusForbidden, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error()})
+	} else if errors.As(err, &badRequestError{}) {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error()})
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Key recovery request not found or no longer pending"})
+	} else {
+		log.Errorln("Failed to process key recovery:", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Server encountered an error processing the key recovery"})
+	}
+}
+
+// Replace the public key of a namespace using one of its recovery codes.
+// The recovery code is the only authorization required.
+//
+// POST /api/v1.0/registry/recover
+func recoverKeyHandler(ctx *gin.Context) {
+	req := recoverKeyReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprint("Invalid key recovery request: ", err)})
+		return
+	}
+	if _, err := validateJwks(string(req.Pubkey)); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Validation for Pubkey failed: %v", err)})
+		return
+	}
+	pubkeyData, err := json.Marshal(req.Pubkey)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Failed to convert public key from json to string format: %v", err)})
+		return
+	}
+
+	remaining, err := recoverNamespaceKey(req.Prefix, req.RecoveryCode, string(pubkeyData))
+	if err != nil {
+		keyRecoveryErrorResp(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{
+		Status: server_structs.RespOK,
+		Msg:    fmt.Sprintf("The public key of namespace %s was replaced; %d recovery codes remain", req.Prefix, remaining)})
+}
+
+// Generate a new set of recovery codes for a namespace, invalidating the old ones.
+// The codes are only ever returned in this response.
+//
+// POST /namespaces/:id/recovery_codes
+func createRecoveryCodesHandler(ctx *gin.Context) {
+	ns, _ := getOwnedNamespace(ctx, true)
+	if ns == nil {
+		return
+	}
+	codes, err := createRecoveryCodes(ns.ID)
+	if err != nil {
+		keyRecoveryErrorResp(ctx, err)
+		return
+	}
+	if len(codes) == 0 {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Recovery codes are disabled in this registry"})
+		return
+	}
+	ctx.JSON(http.StatusOK, recoveryCodesRes{RecoveryCodes: codes})
+}
+
+// List the pending key recovery requests of a namespace
+//
+// GET /namespaces/:id/key_recovery
+func listKeyRecoveryRequestsHandler(ctx *gin.Context) {
+	ns, _ := getOwnedNamespace(ctx, true)
+	if ns == nil {
+		return
+	}
+	requests, err := getPendingKeyRecoveryRequests(ns.ID)
+	if err != nil {
+		keyRecoveryErrorResp(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, requests)
+}
+
+// Propose a new public key for a namespace. The proposal counts as the approval
+// of the requesting owner.
+//
+// POST /namespaces/:id/key_recovery
+func createKeyRecoveryRequestHandler(ctx *gin.Context) {
+	ns, user := getOwnedNamespace(ctx, false)
+	if ns == nil {
+		return
+	}
+	req := keyRecoveryProposalReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprint("Invalid key recovery request: ", err)})
+		return
+	}
+	if _, err := validateJwks(req.Pubkey); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Validation for Pubkey failed: %v", err)})
+		return
+	}
+	request, err := createKeyRecoveryRequest(ns, user, req.Pubkey)
+	if err != nil {
+		keyRecoveryErrorResp(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, request)
+}
+
+// Approve a pending key recovery request of a namespace
+//
+// POST /namespaces/:id/key_recovery/:requestId/approve
+func approveKeyRecoveryRequestHandler(ctx *gin.Context) {
+	ns, user := getOwnedNamespace(ctx, false)
+	if ns == nil {
+		return
+	}
+	requestId, err := strconv.Atoi(ctx.Param("requestId"))
+	if err != nil || requestId <= 0 {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid request ID format. ID must a positive integer"})
+		return
+	}
+	request, err := getPendingKeyRecoveryRequest(ns.ID, requestId)
+	if err != nil {
+		keyRecoveryErrorResp(ctx, err)
+		return
+	}
+	if err = approveKeyRecoveryRequest(ns, request, user); err != nil {
+		keyRecoveryErrorResp(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, request)
+}
@@ -0,0 +1,257 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/test_utils"
+)
+
+func TestRecoveryCodes(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(server_utils.ResetTestState)
+	setupMockRegistryDB(t)
+	defer teardownMockNamespaceDB(t)
+
+	viper.Set("Registry.RecoveryCodeCount", 3)
+	require.NoError(t, insertMockDBData([]server_structs.Namespace{
+		mockNamespace("/foo", "old-pubkey", "", server_structs.AdminMetadata{UserID: "owner"}),
+	}))
+	ns, err := getNamespaceByPrefix("/foo")
+	require.NoError(t, err)
+
+	codes, err := createRecoveryCodes(ns.ID)
+	require.NoError(t, err)
+	require.Len(t, codes, 3)
+	assert.Regexp(t, "^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$", codes[0])
+	assert.NotEqual(t, codes[0], codes[1])
+
+	t.Run("wrong-prefix-or-code", func(t *testing.T) {
+		_, err := recoverNamespaceKey("/bar", codes[0], "new-pubkey")
+		assert.ErrorAs(t, err, &permissionDeniedError{})
+		_, err = recoverNamespaceKey("/foo", "not-a-code", "new-pubkey")
+		assert.ErrorAs(t, err, &permissionDeniedError{})
+	})
+
+	t.Run("recover-with-code", func(t *testing.T) {
+		// Codes are matched regardless of case and dashes
+		remaining, err := recoverNamespaceKey("/foo", strings.ToUpper(strings.ReplaceAll(codes[0], "-", "")), "new-pubkey")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), remaining)
+		ns, err := getNamespaceByPrefix("/foo")
+		require.NoError(t, err)
+		assert.Equal(t, "new-pubkey", ns.Pubkey)
+
+		// Each code can only be used once
+		_, err = recoverNamespaceKey("/foo", codes[0], "another-pubkey")
+		assert.ErrorAs(t, err, &permissionDeniedError{})
+	})
+
+	t.Run("regenerate-invalidates-codes", func(t *testing.T) {
+		newCodes, err := createRecoveryCodes(ns.ID)
+		require.NoError(t, err)
+		require.Len(t, newCodes, 3)
+		_, err = recoverNamespaceKey("/foo", codes[1], "another-pubkey")
+		assert.ErrorAs(t, err, &permissionDeniedError{})
+		_, err = recoverNamespaceKey("/foo", newCodes[1], "another-pubkey")
+		assert.NoError(t, err)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		viper.Set("Registry.RecoveryCodeCount", 0)
+		codes, err := createRecoveryCodes(ns.ID)
+		require.NoError(t, err)
+		assert.Empty(t, codes)
+	})
+}
+
+func TestKeyRecoveryRequests(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(server_utils.ResetTestState)
+	setupMockRegistryDB(t)
+	defer teardownMockNamespaceDB(t)
+
+	require.NoError(t, insertMockDBData([]server_structs.Namespace{
+		mockNamespace("/foo", "old-pubkey", "", server_structs.AdminMetadata{UserID: "owner1", CoOwners: []string{"owner2", "owner3"}}),
+	}))
+	ns, err := getNamespaceByPrefix("/foo")
+	require.NoError(t, err)
+	newPubkey, err := test_utils.GenerateJWKS()
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	withUser := func(handler gin.HandlerFunc) gin.HandlerFunc {
+		return func(ctx *gin.Context) {
+			ctx.Set("User", ctx.GetHeader("X-Test-User"))
+			handler(ctx)
+		}
+	}
+	router.GET("/namespaces/:id/key_recovery", withUser(listKeyRecoveryRequestsHandler))
+	router.POST("/namespaces/:id/key_recovery", withUser(createKeyRecoveryRequestHandler))
+	router.POST("/namespaces/:id/key_recovery/:requestId/approve", withUser(approveKeyRecoveryRequestHandler))
+	router.POST("/namespaces/:id/recovery_codes", withUser(createRecoveryCodesHandler))
+
+	doRequest := func(method, target, user string, body interface{}) *httptest.ResponseRecorder {
+		reqBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, target, bytes.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-User", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	recoveryUrl := fmt.Sprintf("/namespaces/%d/key_recovery", ns.ID)
+	proposal := keyRecoveryProposalReq{Pubkey: newPubkey}
+
+	t.Run("disabled", func(t *testing.T) {
+		viper.Set("Registry.KeyRecoveryApprovals", 0)
+		w := doRequest(http.MethodPost, recoveryUrl, "owner1", proposal)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("too-few-owners", func(t *testing.T) {
+		viper.Set("Registry.KeyRecoveryApprovals", 4)
+		w := doRequest(http.MethodPost, recoveryUrl, "owner1", proposal)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "only has 3")
+	})
+
+	t.Run("non-owner-forbidden", func(t *testing.T) {
+		viper.Set("Registry.KeyRecoveryApprovals", 2)
+		w := doRequest(http.MethodPost, recoveryUrl, "stranger", proposal)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doRequest(http.MethodPost, fmt.Sprintf("/namespaces/%d/recovery_codes", ns.ID), "stranger", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("invalid-pubkey", func(t *testing.T) {
+		viper.Set("Registry.KeyRecoveryApprovals", 2)
+		w := doRequest(http.MethodPost, recoveryUrl, "owner1", keyRecoveryProposalReq{Pubkey: "not-a-key"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("m-of-n-approval", func(t *testing.T) {
+		viper.Set("Registry.KeyRecoveryApprovals", 2)
+		w := doRequest(http.MethodPost, recoveryUrl, "owner1", proposal)
+		require.Equal(t, http.StatusCreated, w.Code)
+		request := KeyRecoveryRequest{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &request))
+		assert.Equal(t, keyRecoveryPending, request.Status)
+		assert.Equal(t, []string{"owner1"}, request.Approvals)
+
+		w = doRequest(http.MethodGet, recoveryUrl, "owner3", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		pending := []KeyRecoveryRequest{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pending))
+		assert.Len(t, pending, 1)
+
+		// The key is unchanged until enough owners approve
+		ns, err := getNamespaceById(ns.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "old-pubkey", ns.Pubkey)
+
+		approveUrl := fmt.Sprintf("%s/%d/approve", recoveryUrl, request.ID)
+		w = doRequest(http.MethodPost, approveUrl, "owner1", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = doRequest(http.MethodPost, approveUrl, "owner2", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &request))
+		assert.Equal(t, keyRecoveryCompleted, request.Status)
+		assert.Equal(t, []string{"owner1", "owner2"}, request.Approvals)
+
+		ns, err = getNamespaceById(ns.ID)
+		require.NoError(t, err)
+		assert.Equal(t, newPubkey, ns.Pubkey)
+
+		// Completed requests can't be approved again
+		w = doRequest(http.MethodPost, approveUrl, "owner3", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("stale-approvals-are-kept", func(t *testing.T) {
+		viper.Set("Registry.KeyRecoveryApprovals", 3)
+		request, err := createKeyRecoveryRequest(ns, "owner1", newPubkey)
+		require.NoError(t, err)
+
+		// Two owners approve copies of the request read before either approval
+		staleA, err := getPendingKeyRecoveryRequest(ns.ID, request.ID)
+		require.NoError(t, err)
+		staleB, err := getPendingKeyRecoveryRequest(ns.ID, request.ID)
+		require.NoError(t, err)
+		require.NoError(t, approveKeyRecoveryRequest(ns, staleA, "owner2"))
+		assert.Equal(t, keyRecoveryPending, staleA.Status)
+		require.NoError(t, approveKeyRecoveryRequest(ns, staleB, "owner3"))
+		assert.Equal(t, keyRecoveryCompleted, staleB.Status)
+		assert.Equal(t, []string{"owner1", "owner2", "owner3"}, staleB.Approvals)
+	})
+
+	t.Run("owner-generates-codes", func(t *testing.T) {
+		viper.Set("Registry.RecoveryCodeCount", 2)
+		w := doRequest(http.MethodPost, fmt.Sprintf("/namespaces/%d/recovery_codes", ns.ID), "owner2", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		res := recoveryCodesRes{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Len(t, res.RecoveryCodes, 2)
+	})
+}
+
+func TestUpdateNamespaceKeepsCoOwners(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(server_utils.ResetTestState)
+	setupMockRegistryDB(t)
+	defer teardownMockNamespaceDB(t)
+
+	require.NoError(t, insertMockDBData([]server_structs.Namespace{
+		mockNamespace("/foo", "pubkey", "", server_structs.AdminMetadata{UserID: "owner1", CoOwners: []string{"owner2"}}),
+	}))
+	ns, err := getNamespaceByPrefix("/foo")
+	require.NoError(t, err)
+
+	// Omitting the co-owners keeps the existing ones
+	ns.AdminMetadata.CoOwners = nil
+	ns.AdminMetadata.Description = "updated"
+	require.NoError(t, updateNamespace(ns))
+	ns, err = getNamespaceByPrefix("/foo")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"owner2"}, ns.AdminMetadata.CoOwners)
+
+	// An explicit, empty list removes them
+	ns.AdminMetadata.CoOwners = []string{}
+	require.NoError(t, updateNamespace(ns))
+	ns, err = getNamespaceByPrefix("/foo")
+	require.NoError(t, err)
+	assert.Empty(t, ns.AdminMetadata.CoOwners)
+}
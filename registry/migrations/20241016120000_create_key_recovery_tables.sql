@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS recovery_code (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  namespace_id INTEGER NOT NULL,
+  code_hash TEXT NOT NULL UNIQUE,
+  created_at DATETIME NOT NULL,
+  used_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS key_recovery_request (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  namespace_id INTEGER NOT NULL,
+  pubkey TEXT NOT NULL,
+  requested_by TEXT NOT NULL,
+  approvals TEXT NOT NULL DEFAULT '[]',
+  status TEXT NOT NULL,
+  created_at DATETIME NOT NULL,
+  completed_at DATETIME
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS key_recovery_request;
+DROP TABLE IF EXISTS recovery_code;
+-- +goose StatementEnd
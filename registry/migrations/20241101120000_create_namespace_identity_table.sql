@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS namespace_identity (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  namespace_id INTEGER NOT NULL,
+  provider TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  display_name TEXT NOT NULL DEFAULT '',
+  profile_url TEXT NOT NULL DEFAULT '',
+  linked_by TEXT NOT NULL,
+  verified_at DATETIME NOT NULL,
+  UNIQUE (namespace_id, provider)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS namespace_identity;
+-- +goose StatementEnd
@@ -0,0 +1,443 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/token"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+type (
+	// A namespace registered through the web UI, held until the user verifies its
+	// contact email address by following the link emailed to them.  Only then is the
+	// namespace added to the registry for the admins to review.
+	PendingRegistration struct {
+		ID          int                      `gorm:"primaryKey;autoIncrement"`
+		Prefix      string                   `gorm:"not null"`
+		Namespace   server_structs.Namespace `gorm:"serializer:json;not null"`
+		Email       string                   `gorm:"not null"`
+		RequestedBy string                   `gorm:"not null"`
+		CreatedAt   time.Time                `gorm:"not null"`
+		ExpiresAt   time.Time                `gorm:"not null"`
+	}
+
+	// A challenge, such as a CAPTCHA, users must pass to register a namespace
+	// through the web UI.  Verify returns false if the response to the challenge
+	// is wrong and an error if it couldn't be checked.
+	registrationChallenge interface {
+		Verify(ctx context.Context, response string, remoteIP string) (bool, error)
+	}
+
+	// A challenge verified through the "siteverify" API shared by hCaptcha,
+	// reCAPTCHA, and Cloudflare Turnstile
+	siteVerifyChallenge struct {
+		verifyUrl string
+		secret    string
+	}
+
+	registrationChallengeRes struct {
+		Provider string `json:"provider"`
+		SiteKey  string `json:"site_key,omitempty"`
+	}
+)
+
+const (
+	// The header of a registration request carrying the response to the challenge
+	challengeResponseHeader = "X-Pelican-Challenge-Response"
+
+	// The claim of an email verification token naming the pending registration
+	pendingRegistrationClaim = "pelican.pending_registration"
+
+	emailVerificationPath = "/api/v1.0/registry_ui/registrations/verify"
+)
+
+var (
+	// The verification APIs of the supported challenges, keyed by the value of Registry.RegistrationChallenge
+	challengeVerifyUrls = map[string]string{
+		"hcaptcha":  "https://api.hcaptcha.com/siteverify",
+		"recaptcha": "https://www.google.com/recaptcha/api/siteverify",
+		"turnstile": "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+	}
+
+	// The challenge of registrations through the web UI; nil if there's none
+	activeChallenge registrationChallenge
+
+	// Send an email through Registry.SMTPServer; replaced in tests
+	sendEmail = sendSMTPEmail
+)
+
+func (PendingRegistration) TableName() string {
+	return "pending_registration"
+}
+
+func (c *siteVerifyChallenge) Verify(ctx context.Context, response string, remoteIP string) (bool, error) {
+	if response == "" {
+		return false, nil
+	}
+	form := url.Values{}
+	form.Set("secret", c.secret)
+	form.Set("response", response)
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.verifyUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := http.Client{Transport: config.GetTransport(), Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to query the challenge verification API")
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read the challenge verification API response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.Errorf("challenge verification API returned status code %d: %s", resp.StatusCode, string(body))
+	}
+	result := struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}{}
+	if err = json.Unmarshal(body, &result); err != nil {
+		return false, errors.Wrap(err, "failed to parse the challenge verification API response")
+	}
+	if !result.Success && len(result.ErrorCodes) > 0 {
+		log.Debugf("Registration challenge failed: %s", strings.Join(result.ErrorCodes, ", "))
+	}
+	return result.Success, nil
+}
+
+// Initialize the challenge and email verification of registrations through the web UI from
+// Registry.RegistrationChallenge and Registry.RequireEmailVerification
+func InitRegistrationVerification() error {
+	name := strings.ToLower(param.Registry_RegistrationChallenge.GetString())
+	switch name {
+	case "", "none":
+		activeChallenge = nil
+	default:
+		verifyUrl, ok := challengeVerifyUrls[name]
+		if !ok {
+			return errors.Errorf("Bad Registry.RegistrationChallenge: unsupported challenge %q; supported challenges are \"hcaptcha\", \"recaptcha\", and \"turnstile\"", param.Registry_RegistrationChallenge.GetString())
+		}
+		if param.Registry_RegistrationChallengeSiteKey.GetString() == "" {
+			return errors.New("Registry.RegistrationChallengeSiteKey must be set to use Registry.RegistrationChallenge")
+		}
+		secret, err := readSecretFile("Registry.RegistrationChallengeSecretFile", param.Registry_RegistrationChallengeSecretFile.GetString())
+		if err != nil {
+			return err
+		}
+		activeChallenge = &siteVerifyChallenge{verifyUrl: verifyUrl, secret: secret}
+	}
+
+	if param.Registry_RequireEmailVerification.GetBool() {
+		if param.Registry_SMTPServer.GetString() == "" || param.Registry_SMTPFrom.GetString() == "" {
+			return errors.New("Registry.SMTPServer and Registry.SMTPFrom must be set to use Registry.RequireEmailVerification")
+		}
+		if param.Registry_EmailVerificationExpiry.GetDuration() <= 0 {
+			return errors.New("Registry.EmailVerificationExpiry must be positive")
+		}
+	}
+	return nil
+}
+
+// Check the response to the challenge of a registration through the web UI, if there's
+// a challenge.  Responds to the request and returns false if the check fails.
+func checkRegistrationChallenge(ctx *gin.Context) bool {
+	if activeChallenge == nil {
+		return true
+	}
+	ok, err := activeChallenge.Verify(ctx.Request.Context(), ctx.GetHeader(challengeResponseHeader), ctx.ClientIP())
+	if err != nil {
+		log.Errorf("Failed to verify the registration challenge: %v", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Server encountered an error verifying the registration challenge"})
+		return false
+	}
+	if !ok {
+		ctx.JSON(http.StatusForbidden, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "The registration challenge was not passed; please complete it and try again"})
+		return false
+	}
+	return true
+}
+
+// Apply the challenge and email verification of the web UI to a registration through the
+// API, which has no one to solve a challenge.  While either is on, only registrations that
+// present an identity verified with the registry's OIDC provider are accepted, and with
+// email verification the namespace is held until the identity's email address is verified.
+// Returns whether the registration was held.
+func holdAPIRegistration(ns *server_structs.Namespace, data *registrationData, email string) (bool, error) {
+	requireEmail := param.Registry_RequireEmailVerification.GetBool()
+	if activeChallenge == nil && !requireEmail {
+		return false, nil
+	}
+	if !data.identityVerified {
+		return false, permissionDeniedError{Message: fmt.Sprintf("this registry requires registrations to be verified; "+
+			"register %s through the registry website at %s, or through Pelican CLI with the flag '--with-identity' enabled",
+			ns.Prefix, param.Server_ExternalWebUrl.GetString())}
+	}
+	if !requireEmail {
+		return false, nil
+	}
+	if email == "" {
+		return false, permissionDeniedError{Message: "your identity has no email address to verify; register the namespace through the registry website instead"}
+	}
+	ns.AdminMetadata.ContactEmail = email
+	if _, err := createPendingRegistration(ns, ns.AdminMetadata.UserID); err != nil {
+		return false, errors.Wrapf(err, "failed to hold the registration of %s for email verification", ns.Prefix)
+	}
+	return true, nil
+}
+
+// Send an email through Registry.SMTPServer, using STARTTLS if the server supports it
+func sendSMTPEmail(to string, subject string, body string) error {
+	server := param.Registry_SMTPServer.GetString()
+	from := param.Registry_SMTPFrom.GetString()
+	var auth smtp.Auth
+	if username := param.Registry_SMTPUsername.GetString(); username != "" {
+		password, err := readSecretFile("Registry.SMTPPasswordFile", param.Registry_SMTPPasswordFile.GetString())
+		if err != nil {
+			return err
+		}
+		host, _, err := net.SplitHostPort(server)
+		if err != nil {
+			return errors.Wrapf(err, "invalid Registry.SMTPServer %q", server)
+		}
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	msg := strings.Join([]string{
+		"From: " + (&mail.Address{Address: from}).String(),
+		"To: " + (&mail.Address{Address: to}).String(),
+		"Subject: " + subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+	if err := smtp.SendMail(server, auth, from, []string{to}, []byte(msg)); err != nil {
+		return errors.Wrapf(err, "failed to send email through %s", server)
+	}
+	return nil
+}
+
+// Create the token of the link verifying the email address of a pending registration
+func createEmailVerificationToken(pending *PendingRegistration) (string, error) {
+	issuerUrl := param.Server_ExternalWebUrl.GetString()
+	tc := token.NewWLCGToken()
+	tc.Issuer = issuerUrl
+	tc.Lifetime = time.Until(pending.ExpiresAt)
+	tc.Subject = pending.Email
+	tc.AddAudiences(issuerUrl)
+	tc.AddScopes(token_scopes.Registry_VerifyEmail)
+	tc.Claims = map[string]string{pendingRegistrationClaim: strconv.Itoa(pending.ID)}
+	return tc.CreateToken()
+}
+
+// Check the signature, expiry, and scope of an email verification token and return the ID
+// of the pending registration it verifies
+func parseEmailVerificationToken(tokenStr string) (int, error) {
+	jwks, err := config.GetIssuerPublicJWKS()
+	if err != nil {
+		return 0, err
+	}
+	tok, err := jwt.Parse([]byte(tokenStr), jwt.WithKeySet(jwks), jwt.WithValidate(true),
+		jwt.WithAudience(param.Server_ExternalWebUrl.GetString()))
+	if err != nil {
+		return 0, badRequestError{Message: fmt.Sprintf("invalid or expired verification link: %v", err)}
+	}
+	scopeValidator := token_scopes.CreateScopeValidator([]token_scopes.TokenScope{token_scopes.Registry_VerifyEmail}, false)
+	if err = jwt.Validate(tok, jwt.WithValidator(scopeValidator)); err != nil {
+		return 0, badRequestError{Message: fmt.Sprintf("invalid verification link: %v", err)}
+	}
+	claim, _ := tok.Get(pendingRegistrationClaim)
+	idStr, _ := claim.(string)
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		return 0, badRequestError{Message: "invalid verification link: the token does not name a registration"}
+	}
+	return id, nil
+}
+
+// Hold a validated registration until its contact email address is verified, and email the
+// verification link to the address
+func createPendingRegistration(ns *server_structs.Namespace, user string) (*PendingRegistration, error) {
+	// Discard the registrations whose links expired
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&PendingRegistration{}).Error; err != nil {
+		log.Warningf("Failed to discard expired pending registrations: %v", err)
+	}
+
+	now := time.Now()
+	pending := &PendingRegistration{
+		Prefix:      ns.Prefix,
+		Namespace:   *ns,
+		Email:       ns.AdminMetadata.ContactEmail,
+		RequestedBy: user,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(param.Registry_EmailVerificationExpiry.GetDuration()),
+	}
+	if err := db.Create(pending).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to save the pending registration of %s", ns.Prefix)
+	}
+	tok, err := createEmailVerificationToken(pending)
+	if err == nil {
+		link := param.Server_ExternalWebUrl.GetString() + emailVerificationPath + "?token=" + url.QueryEscape(tok)
+		body := fmt.Sprintf("The user %s registered the namespace %s at the Pelican registry %s with this contact address.\r\n\r\n"+
+			"To complete the registration, verify your email address by opening the following link before %s:\r\n\r\n%s\r\n\r\n"+
+			"If you did not request this registration, ignore this email.\r\n",
+			user, ns.Prefix, param.Server_ExternalWebUrl.GetString(), pending.ExpiresAt.UTC().Format(time.RFC1123), link)
+		err = sendEmail(pending.Email, "Verify your registration of "+ns.Prefix, body)
+	}
+	if err != nil {
+		if delErr := db.Delete(pending).Error; delErr != nil {
+			log.Warningf("Failed to delete the pending registration of %s: %v", ns.Prefix, delErr)
+		}
+		return nil, err
+	}
+	log.Infof("User %s registered namespace %s pending the verification of %s", user, ns.Prefix, pending.Email)
+	return pending, nil
+}
+
+// Add the namespace of the pending registration verified by the token to the registry
+func completePendingRegistration(tokenStr string) (*server_structs.Namespace, error) {
+	id, err := parseEmailVerificationToken(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	pending := PendingRegistration{}
+	if err = db.First(&pending, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, badRequestError{Message: "the registration was already verified or has expired"}
+	} else if err != nil {
+		return nil, err
+	}
+	if time.Now().After(pending.ExpiresAt) {
+		return nil, badRequestError{Message: "the verification link has expired; please register the namespace again"}
+	}
+
+	// The prefix may have been registered while the email was being verified
+	ns := pending.Namespace
+	exists, err := namespaceExistsByPrefix(ns.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, badRequestError{Message: fmt.Sprintf("the prefix %s was registered while the email address was being verified", ns.Prefix)}
+	}
+	pubkey, err := validateJwks(ns.Pubkey)
+	if err != nil {
+		return nil, badRequestError{Message: fmt.Sprintf("validation for Pubkey failed: %v", err)}
+	}
+	if _, _, valErr, sysErr := validateKeyChaining(ns.Prefix, pubkey); valErr != nil {
+		return nil, badRequestError{Message: valErr.Error()}
+	} else if sysErr != nil {
+		return nil, sysErr
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&pending)
+		if result.Error != nil {
+			return result.Error
+		}
+		// Another request verified the same link first
+		if result.RowsAffected == 0 {
+			return badRequestError{Message: "the registration was already verified"}
+		}
+		ns.ID = 0
+		ns.AdminMetadata.Status = server_structs.RegPending
+		ns.AdminMetadata.CreatedAt = time.Now()
+		ns.AdminMetadata.UpdatedAt = time.Now()
+		return tx.Create(&ns).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Verified the email address %s of the registration of namespace %s by user %s", pending.Email, ns.Prefix, pending.RequestedBy)
+	return &ns, nil
+}
+
+// Describe the challenge the web UI must render for registrations
+//
+// GET /registration_challenge
+func getRegistrationChallengeHandler(ctx *gin.Context) {
+	res := registrationChallengeRes{Provider: "none"}
+	if activeChallenge != nil {
+		res.Provider = strings.ToLower(param.Registry_RegistrationChallenge.GetString())
+		res.SiteKey = param.Registry_RegistrationChallengeSiteKey.GetString()
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// Complete a registration by following the link emailed to its contact address
+//
+// GET /registrations/verify?token=<token>
+func verifyRegistrationEmailHandler(ctx *gin.Context) {
+	tokenStr := ctx.Query("token")
+	if tokenStr == "" {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "The verification link is missing its token"})
+		return
+	}
+	ns, err := completePendingRegistration(tokenStr)
+	if err != nil {
+		if errors.As(err, &badRequestError{}) {
+			ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    err.Error()})
+			return
+		}
+		log.Errorf("Failed to complete a pending registration: %v", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Server encountered an error completing the registration"})
+		return
+	}
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{
+		Status: server_structs.RespOK,
+		Msg:    fmt.Sprintf("Email address verified; the registration of %s is pending approval by the registry admins", ns.Prefix),
+	})
+}
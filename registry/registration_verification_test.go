@@ -0,0 +1,236 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/elliptic"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/test_utils"
+)
+
+func TestRegistrationChallenge(t *testing.T) {
+	server_utils.ResetTestState()
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		activeChallenge = nil
+	})
+
+	verifyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "my-secret", r.PostForm.Get("secret"))
+		assert.Equal(t, "192.0.2.10", r.PostForm.Get("remoteip"))
+		if r.PostForm.Get("response") == "solved" {
+			_, _ = w.Write([]byte(`{"success": true}`))
+		} else {
+			_, _ = w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+		}
+	}))
+	defer verifyServer.Close()
+
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("my-secret\n"), 0600))
+	viper.Set("Registry.RegistrationChallenge", "hCaptcha")
+	viper.Set("Registry.RegistrationChallengeSiteKey", "my-site-key")
+	viper.Set("Registry.RegistrationChallengeSecretFile", secretFile)
+	require.NoError(t, InitRegistrationVerification())
+	require.IsType(t, &siteVerifyChallenge{}, activeChallenge)
+	assert.Equal(t, challengeVerifyUrls["hcaptcha"], activeChallenge.(*siteVerifyChallenge).verifyUrl)
+	activeChallenge.(*siteVerifyChallenge).verifyUrl = verifyServer.URL
+
+	router := gin.Default()
+	router.GET("/registration_challenge", getRegistrationChallengeHandler)
+	router.POST("/namespaces", func(ctx *gin.Context) {
+		ctx.Set("User", "admin")
+		createUpdateNamespace(ctx, false)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/registration_challenge", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"provider": "hcaptcha", "site_key": "my-site-key"}`, w.Body.String())
+
+	// Requests failing the challenge are rejected before they're validated
+	for _, response := range []string{"", "wrong"} {
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodPost, "/namespaces", nil)
+		req.RemoteAddr = "192.0.2.10:12345"
+		req.Header.Set(challengeResponseHeader, response)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/namespaces", nil)
+	req.RemoteAddr = "192.0.2.10:12345"
+	req.Header.Set(challengeResponseHeader, "solved")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid create or update namespace request")
+
+	viper.Set("Registry.RegistrationChallenge", "captcha")
+	assert.ErrorContains(t, InitRegistrationVerification(), "unsupported challenge")
+	viper.Set("Registry.RegistrationChallenge", "none")
+	require.NoError(t, InitRegistrationVerification())
+	assert.Nil(t, activeChallenge)
+
+	viper.Set("Registry.RequireEmailVerification", true)
+	assert.ErrorContains(t, InitRegistrationVerification(), "Registry.SMTPServer")
+}
+
+func TestEmailVerifiedRegistration(t *testing.T) {
+	server_utils.ResetTestState()
+	ctx, cancel, egrp := test_utils.TestContext(context.Background(), t)
+	defer func() { require.NoError(t, egrp.Wait()) }()
+	defer cancel()
+	t.Cleanup(func() {
+		server_utils.ResetTestState()
+		sendEmail = sendSMTPEmail
+	})
+
+	setupMockRegistryDB(t)
+	defer teardownMockNamespaceDB(t)
+
+	viper.Set("Server.WebPort", 0)
+	viper.Set("Server.ExternalWebUrl", "https://registry.example.com")
+	viper.Set("ConfigDir", t.TempDir())
+	viper.Set("Origin.Port", 0)
+	require.NoError(t, config.InitServer(ctx, server_structs.OriginType))
+	require.NoError(t, config.GeneratePrivateKey(param.IssuerKey.GetString(), elliptic.P256(), false))
+	viper.Set("Registry.Institutions", []registrationFieldOption{{ID: "1000"}})
+	viper.Set("Registry.RequireEmailVerification", true)
+	viper.Set("Registry.SMTPServer", "smtp.example.com:587")
+	viper.Set("Registry.SMTPFrom", "registry@example.com")
+	viper.Set("Registry.EmailVerificationExpiry", "24h")
+	require.NoError(t, InitRegistrationVerification())
+
+	emails := map[string]string{}
+	sendEmail = func(to string, subject string, body string) error {
+		emails[to] = body
+		return nil
+	}
+	linkRegexp := regexp.MustCompile(regexp.QuoteMeta("https://registry.example.com"+emailVerificationPath) + `\?token=(\S+)`)
+	getToken := func(email string) string {
+		match := linkRegexp.FindStringSubmatch(emails[email])
+		require.Len(t, match, 2, "the email has no verification link: %s", emails[email])
+		token, err := url.QueryUnescape(match[1])
+		require.NoError(t, err)
+		return token
+	}
+
+	router := gin.Default()
+	router.POST("/namespaces", func(ctx *gin.Context) {
+		ctx.Set("User", "admin")
+		createUpdateNamespace(ctx, false)
+	})
+	router.GET("/registrations/verify", verifyRegistrationEmailHandler)
+	register := func(prefix string, email string) *httptest.ResponseRecorder {
+		pubKeyStr, err := test_utils.GenerateJWKS()
+		require.NoError(t, err)
+		ns := server_structs.Namespace{Prefix: prefix, Pubkey: pubKeyStr, AdminMetadata: server_structs.AdminMetadata{Institution: "1000", ContactEmail: email}}
+		nsBytes, err := json.Marshal(ns)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/namespaces", bytes.NewReader(nsBytes))
+		router.ServeHTTP(w, req)
+		return w
+	}
+	verify := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/registrations/verify?token="+url.QueryEscape(token), nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("email-required", func(t *testing.T) {
+		resetNamespaceDB(t)
+		assert.Equal(t, http.StatusBadRequest, register("/foo", "").Code)
+		assert.Equal(t, http.StatusBadRequest, register("/foo", "not-an-email").Code)
+	})
+
+	t.Run("namespace-added-once-verified", func(t *testing.T) {
+		resetNamespaceDB(t)
+		w := register("/foo", "owner@example.com")
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+		// Nothing is visible to the admins until the email is verified
+		nss, err := getAllNamespaces()
+		require.NoError(t, err)
+		assert.Empty(t, nss)
+
+		token := getToken("owner@example.com")
+		w = verify(token)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		nss, err = getAllNamespaces()
+		require.NoError(t, err)
+		require.Len(t, nss, 1)
+		assert.Equal(t, "/foo", nss[0].Prefix)
+		assert.Equal(t, "admin", nss[0].AdminMetadata.UserID)
+		assert.Equal(t, "owner@example.com", nss[0].AdminMetadata.ContactEmail)
+		assert.Equal(t, server_structs.RegPending, nss[0].AdminMetadata.Status)
+
+		// Links are single-use
+		assert.Equal(t, http.StatusBadRequest, verify(token).Code)
+	})
+
+	t.Run("prefix-registered-meanwhile", func(t *testing.T) {
+		resetNamespaceDB(t)
+		require.Equal(t, http.StatusAccepted, register("/bar", "first@example.com").Code)
+		require.Equal(t, http.StatusAccepted, register("/bar", "second@example.com").Code)
+		require.Equal(t, http.StatusOK, verify(getToken("second@example.com")).Code)
+		w := verify(getToken("first@example.com"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "was registered while the email address was being verified")
+	})
+
+	t.Run("invalid-links-rejected", func(t *testing.T) {
+		resetNamespaceDB(t)
+		require.Equal(t, http.StatusAccepted, register("/baz", "owner@example.com").Code)
+		token := getToken("owner@example.com")
+		assert.Equal(t, http.StatusBadRequest, verify(token[:len(token)-4]+"AAAA").Code)
+		assert.Equal(t, http.StatusBadRequest, verify("").Code)
+
+		// Expired registrations can't be completed
+		require.NoError(t, db.Model(&PendingRegistration{}).Where("prefix = ?", "/baz").Update("expires_at", time.Now().Add(-time.Minute)).Error)
+		assert.Equal(t, http.StatusBadRequest, verify(token).Code)
+		nss, err := getAllNamespaces()
+		require.NoError(t, err)
+		assert.Empty(t, nss)
+	})
+}
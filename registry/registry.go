@@ -41,6 +41,7 @@ import (
 	"path"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -69,7 +70,7 @@ var OIDC struct {
 var (
 	// Loading of public/private keys for signing challenges
 	serverCredsLoad    sync.Once
-	serverCredsPrivKey *ecdsa.PrivateKey
+	serverCredsPrivKey crypto.Signer
 	serverCredsErr     error
 )
 
@@ -107,6 +108,10 @@ type registrationData struct {
 	Identity         string          `json:"identity"`
 	IdentityRequired string          `json:"identity_required"`
 	DeviceCode       string          `json:"device_code"`
+
+	// Whether Identity came from the user info endpoint of the registry's OIDC provider,
+	// rather than from the request
+	identityVerified bool
 }
 type permissionDeniedError struct {
 	Message string
@@ -169,10 +174,22 @@ func generateNonce() (string, error) {
 	return hex.EncodeToString(nonce), nil
 }
 
-func loadServerKeys() (*ecdsa.PrivateKey, error) {
+func loadServerKeys() (crypto.Signer, error) {
 	// Note: go 1.21 introduces `OnceValues` which automates this procedure.
 	// TODO: Reimplement the function once we switch to a minimum of 1.21
 	serverCredsLoad.Do(func() {
+		// Sign the challenges with the key in the external key management service, if any
+		if keyURI := param.IssuerKeyURI.GetString(); keyURI != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			serverCredsPrivKey, serverCredsErr = config.OpenExternalKey(ctx, keyURI)
+			if serverCredsErr == nil {
+				if _, ok := serverCredsPrivKey.Public().(*ecdsa.PublicKey); !ok {
+					serverCredsErr = errors.Errorf("unsupported key type for server issuer key: %T", serverCredsPrivKey.Public())
+				}
+			}
+			return
+		}
 		issuerFileName := param.IssuerKey.GetString()
 		var privateKey crypto.PrivateKey
 		privateKey, serverCredsErr = config.LoadPrivateKey(issuerFileName, false)
@@ -188,9 +205,9 @@ func loadServerKeys() (*ecdsa.PrivateKey, error) {
 	return serverCredsPrivKey, serverCredsErr
 }
 
-func signPayload(payload []byte, privateKey *ecdsa.PrivateKey) ([]byte, error) {
+func signPayload(payload []byte, signer crypto.Signer) ([]byte, error) {
 	hash := sha256.Sum256(payload)
-	signature, err := privateKey.Sign(rand.Reader, hash[:], crypto.SHA256) // Use crypto.SHA256 instead of the hash[:]
+	signature, err := signer.Sign(rand.Reader, hash[:], crypto.SHA256) // Use crypto.SHA256 instead of the hash[:]
 	if err != nil {
 		return nil, err
 	}
@@ -269,8 +286,11 @@ func keySignChallengeCommit(ctx *gin.Context, data *registrationData) (bool, map
 	if err != nil {
 		return false, nil, errors.Wrap(err, "Failed to decode the server's private key")
 	}
-	serverPubkey := serverPrivateKey.PublicKey
-	serverVerified := verifySignature(serverPayload, serverSignature, &serverPubkey)
+	serverPubkey, ok := serverPrivateKey.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return false, nil, errors.Errorf("unsupported key type for server issuer key: %T", serverPrivateKey.Public())
+	}
+	serverVerified := verifySignature(serverPayload, serverSignature, serverPubkey)
 
 	if clientVerified && serverVerified {
 		log.Debug("Registering namespace ", data.Prefix)
@@ -317,6 +337,7 @@ func keySignChallengeCommit(ctx *gin.Context, data *registrationData) (bool, map
 		ns.Pubkey = string(pubkeyData)
 		ns.Identity = data.Identity
 		ns.AdminMetadata.SiteName = data.SiteName
+		identityEmail := ""
 
 		if data.Identity != "" {
 			idMap := map[string]interface{}{}
@@ -347,6 +368,7 @@ func keySignChallengeCommit(ctx *gin.Context, data *registrationData) (bool, map
 			if ok {
 				val, ok := email.(string)
 				if ok {
+					identityEmail = val
 					ns.AdminMetadata.Description += "User email: " + val + " This is a namespace registration from Pelican CLI with OIDC authentication. Certain fields may not be populated"
 				}
 			}
@@ -374,6 +396,16 @@ func keySignChallengeCommit(ctx *gin.Context, data *registrationData) (bool, map
 		// Overwrite status to Pending to filter malicious request
 		ns.AdminMetadata.Status = server_structs.RegPending
 
+		held, err := holdAPIRegistration(&ns, data, identityEmail)
+		if err != nil {
+			return false, nil, err
+		}
+		if held {
+			return false, map[string]interface{}{
+				"message": fmt.Sprintf("A verification link was sent to %s; prefix %s will be registered once you open it", identityEmail, ns.Prefix),
+			}, nil
+		}
+
 		err = AddNamespace(&ns)
 		if err != nil {
 			return false, nil, errors.Wrapf(err, "Failed to add the prefix %q to the database", ns.Prefix)
@@ -382,9 +414,17 @@ func keySignChallengeCommit(ctx *gin.Context, data *registrationData) (bool, map
 			if inTopo {
 				msg = fmt.Sprintf("Prefix %s successfully registered. Note that there is an existing superspace or subspace of the namespace in the OSDF topology: %s. The registry admin will review your request and approve your namespace if this is expected.", ns.Prefix, GetTopoPrefixString(topoNss))
 			}
-			return true, map[string]interface{}{
+			res := map[string]interface{}{
 				"message": msg,
-			}, nil
+			}
+			// The recovery codes are only ever returned here; failing to create them
+			// shouldn't fail the registration as the owner can generate new ones later
+			if codes, err := createRecoveryCodes(ns.ID); err != nil {
+				log.Warningf("Failed to create recovery codes for prefix %s: %v", ns.Prefix, err)
+			} else if len(codes) > 0 {
+				res["recovery_codes"] = codes
+			}
+			return true, res, nil
 		}
 	} else {
 		return false, nil, errors.Errorf("Unable to verify the client's public key, or an encountered an error with its own: "+
@@ -523,6 +563,7 @@ func cliRegisterNamespace(ctx *gin.Context) {
 		}
 
 		reqData.Identity = string(body)
+		reqData.identityVerified = true
 		created, res, err := keySignChallenge(ctx, &reqData)
 		if err != nil {
 			if errors.As(err, &permissionDeniedError{}) {
@@ -802,6 +843,13 @@ func wildcardHandler(ctx *gin.Context) {
 	// new / here!
 	path := ctx.Param("wildcard")
 
+	// Proxy the metadata of the prefix's issuer.  This shadows the routes below for
+	// namespaces under /issuer.
+	if strings.HasPrefix(path, issuerProxyPath+"/") {
+		issuerProxyHandler(ctx, strings.TrimPrefix(path, issuerProxyPath))
+		return
+	}
+
 	// Get the prefix's JWKS
 	// Avoid using filepath.Base for path matching, as filepath format depends on OS
 	// while HTTP path is always slash (/)
@@ -1060,34 +1108,39 @@ func checkApprovalHandler(ctx *gin.Context) {
 			Msg:    fmt.Sprintf("Error getting namespace %s: %s", req.Prefix, err.Error())})
 		return
 	}
+	// Let the director enforce the capabilities the namespace requires of its origins
+	var policy *server_structs.NamespacePolicy
+	if !ns.AdminMetadata.Policy.IsEmpty() {
+		policy = &ns.AdminMetadata.Policy
+	}
 	emptyMetadata := server_structs.AdminMetadata{}
 	// If Registry.RequireCacheApproval or Registry.RequireOriginApproval is false
 	// we return Approved == true
-	if ns.AdminMetadata != emptyMetadata {
+	if !ns.AdminMetadata.Equal(emptyMetadata) {
 		// Caches
 		if server_structs.IsCacheNS(req.Prefix) && param.Registry_RequireCacheApproval.GetBool() {
-			res := server_structs.CheckNamespaceStatusRes{Approved: ns.AdminMetadata.Status == server_structs.RegApproved}
+			res := server_structs.CheckNamespaceStatusRes{Approved: ns.AdminMetadata.Status == server_structs.RegApproved, Policy: policy}
 			ctx.JSON(http.StatusOK, res)
 			return
 		} else if !param.Registry_RequireCacheApproval.GetBool() {
-			res := server_structs.CheckNamespaceStatusRes{Approved: true}
+			res := server_structs.CheckNamespaceStatusRes{Approved: true, Policy: policy}
 			ctx.JSON(http.StatusOK, res)
 			return
 		} else {
 			// Origins
 			if param.Registry_RequireOriginApproval.GetBool() {
-				res := server_structs.CheckNamespaceStatusRes{Approved: ns.AdminMetadata.Status == server_structs.RegApproved}
+				res := server_structs.CheckNamespaceStatusRes{Approved: ns.AdminMetadata.Status == server_structs.RegApproved, Policy: policy}
 				ctx.JSON(http.StatusOK, res)
 				return
 			} else {
-				res := server_structs.CheckNamespaceStatusRes{Approved: true}
+				res := server_structs.CheckNamespaceStatusRes{Approved: true, Policy: policy}
 				ctx.JSON(http.StatusOK, res)
 				return
 			}
 		}
 	} else {
 		// For legacy Pelican (<=7.3.0) registry schema without Admin_Metadata
-		res := server_structs.CheckNamespaceStatusRes{Approved: true}
+		res := server_structs.CheckNamespaceStatusRes{Approved: true, Policy: policy}
 		ctx.JSON(http.StatusOK, res)
 	}
 }
@@ -1168,6 +1221,7 @@ func RegisterRegistryAPI(router *gin.RouterGroup) {
 		registryAPI.GET("/*wildcard", wildcardHandler)
 		registryAPI.POST("/checkNamespaceExists", checkNamespaceExistsHandler)
 		registryAPI.POST("/checkNamespaceStatus", checkApprovalHandler)
+		registryAPI.POST("/recover", recoverKeyHandler)
 
 		registryAPI.DELETE("/*wildcard", deleteNamespaceHandler)
 	}
@@ -29,9 +29,11 @@ import (
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 	"gorm.io/gorm"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/server_utils"
@@ -43,6 +45,8 @@ type NamespaceWOPubkey struct {
 	Pubkey        string                       `json:"-"` // Don't include pubkey in this case
 	Identity      string                       `json:"identity"`
 	AdminMetadata server_structs.AdminMetadata `json:"admin_metadata"`
+	// External identities linked to the namespace; only included for registry admins
+	LinkedIdentities []NamespaceIdentity `json:"linked_identities,omitempty"`
 }
 
 type Topology struct {
@@ -71,6 +75,12 @@ var db *gorm.DB
 //go:embed migrations/*.sql
 var embedMigrations embed.FS
 
+// The schema migrations for the registry database, for tools that manage the
+// database outside of a running server
+func GetMigrations() embed.FS {
+	return embedMigrations
+}
+
 func (st prefixType) String() string {
 	return string(st)
 }
@@ -410,7 +420,14 @@ func updateNamespace(ns *server_structs.Namespace) error {
 	ns.AdminMetadata.Status = existingNsAdmin.Status
 	ns.AdminMetadata.ApprovedAt = existingNsAdmin.ApprovedAt
 	ns.AdminMetadata.ApproverID = existingNsAdmin.ApproverID
+	// Only admins change the policy, through updateNamespacePolicyById
+	ns.AdminMetadata.Policy = existingNsAdmin.Policy
 	ns.AdminMetadata.UpdatedAt = time.Now()
+	// Clients unaware of co-owners (e.g., older web UIs) omit them; only an
+	// explicit list, possibly empty, replaces the existing co-owners
+	if ns.AdminMetadata.CoOwners == nil {
+		ns.AdminMetadata.CoOwners = existingNsAdmin.CoOwners
+	}
 
 	return db.Save(ns).Error
 }
@@ -439,6 +456,25 @@ func updateNamespaceStatusById(id int, status server_structs.RegistrationStatus,
 	return db.Model(ns).Where("id = ?", id).Update("admin_metadata", string(adminMetadataByte)).Error
 }
 
+// Save the policy of the namespace with the given ID in its admin metadata, replacing
+// any previous policy
+func updateNamespacePolicyById(id int, policy server_structs.NamespacePolicy) error {
+	ns, err := getNamespaceById(id)
+	if err != nil {
+		return errors.Wrap(err, "Error getting namespace by id")
+	}
+
+	ns.AdminMetadata.Policy = policy
+	ns.AdminMetadata.UpdatedAt = time.Now()
+
+	adminMetadataByte, err := json.Marshal(ns.AdminMetadata)
+	if err != nil {
+		return errors.Wrap(err, "Error marshaling admin metadata")
+	}
+
+	return db.Model(ns).Where("id = ?", id).Update("admin_metadata", string(adminMetadataByte)).Error
+}
+
 func deleteNamespaceByID(id int) error {
 	return db.Delete(&server_structs.Namespace{}, id).Error
 }
@@ -580,3 +616,8 @@ func PeriodicTopologyReload(ctx context.Context) {
 func ShutdownRegistryDB() error {
 	return server_utils.ShutdownDB(db)
 }
+
+// Periodically check the database works, reporting the result as the registry-database component
+func LaunchDBHealthCheck(ctx context.Context, egrp *errgroup.Group) {
+	server_utils.LaunchDBHealthCheck(ctx, egrp, db, metrics.Registry_Database)
+}
@@ -57,6 +57,12 @@ func setupMockRegistryDB(t *testing.T) {
 	require.NoError(t, err, "Error setting up mock namespace DB")
 	err = db.AutoMigrate(&server_structs.Namespace{})
 	require.NoError(t, err, "Failed to migrate DB for namespace table")
+	err = db.AutoMigrate(&RecoveryCode{}, &KeyRecoveryRequest{})
+	require.NoError(t, err, "Failed to migrate DB for key recovery tables")
+	err = db.AutoMigrate(&NamespaceIdentity{})
+	require.NoError(t, err, "Failed to migrate DB for namespace identity table")
+	err = db.AutoMigrate(&PendingRegistration{})
+	require.NoError(t, err, "Failed to migrate DB for pending registration table")
 	err = migrateTopologyTestTable()
 	require.NoError(t, err, "Error creating topology table")
 }
@@ -64,6 +70,14 @@ func setupMockRegistryDB(t *testing.T) {
 func resetNamespaceDB(t *testing.T) {
 	err := db.Where("1 = 1").Delete(&server_structs.Namespace{}).Error
 	require.NoError(t, err, "Error resetting namespace DB")
+	err = db.Where("1 = 1").Delete(&RecoveryCode{}).Error
+	require.NoError(t, err, "Error resetting recovery code DB")
+	err = db.Where("1 = 1").Delete(&KeyRecoveryRequest{}).Error
+	require.NoError(t, err, "Error resetting key recovery request DB")
+	err = db.Where("1 = 1").Delete(&NamespaceIdentity{}).Error
+	require.NoError(t, err, "Error resetting namespace identity DB")
+	err = db.Where("1 = 1").Delete(&PendingRegistration{}).Error
+	require.NoError(t, err, "Error resetting pending registration DB")
 	err = db.Where("1 = 1").Delete(&Topology{}).Error
 	require.NoError(t, err, "Error resetting topology DB")
 }
@@ -118,7 +132,7 @@ func compareNamespaces(execpted []server_structs.Namespace, returned interface{}
 		if nssEx.Prefix != nssRt.Prefix ||
 			(!woPubkey && nssEx.Pubkey != nssRt.Pubkey) ||
 			nssEx.Identity != nssRt.Identity ||
-			nssEx.AdminMetadata != nssRt.AdminMetadata {
+			!nssEx.AdminMetadata.Equal(nssRt.AdminMetadata) {
 			return false
 		}
 	}
@@ -359,6 +373,7 @@ func TestUpdateNamespace(t *testing.T) {
 		initialNs.AdminMetadata.Status = server_structs.RegApproved
 		initialNs.AdminMetadata.ApproverID = "hacker"
 		initialNs.AdminMetadata.ApprovedAt = time.Now().Add(10 * time.Hour)
+		initialNs.AdminMetadata.Policy = server_structs.NamespacePolicy{WriteOrigins: []string{"hacker-origin"}}
 		err = updateNamespace(initialNs)
 		require.NoError(t, err)
 		finalNss, err := getAllNamespaces()
@@ -371,6 +386,7 @@ func TestUpdateNamespace(t *testing.T) {
 		assert.Equal(t, mockNs.AdminMetadata.Status, finalNs.AdminMetadata.Status)
 		assert.Equal(t, mockNs.AdminMetadata.ApprovedAt.Unix(), finalNs.AdminMetadata.ApprovedAt.Unix())
 		assert.Equal(t, mockNs.AdminMetadata.ApproverID, finalNs.AdminMetadata.ApproverID)
+		assert.True(t, finalNs.AdminMetadata.Policy.IsEmpty())
 		// DB first changes initialNs.AdminMetadata.UpdatedAt then commit
 		assert.Equal(t, initialNs.AdminMetadata.UpdatedAt.Unix(), finalNs.AdminMetadata.UpdatedAt.Unix())
 	})
@@ -444,6 +460,34 @@ func TestUpdateNamespaceStatusById(t *testing.T) {
 	})
 }
 
+func TestUpdateNamespacePolicyById(t *testing.T) {
+	setupMockRegistryDB(t)
+	defer teardownMockNamespaceDB(t)
+
+	t.Run("return-error-if-id-dne", func(t *testing.T) {
+		defer resetNamespaceDB(t)
+		err := updateNamespacePolicyById(100, server_structs.NamespacePolicy{DisallowDirectReads: true})
+		assert.Error(t, err)
+	})
+
+	t.Run("update-policy-preserves-status", func(t *testing.T) {
+		defer resetNamespaceDB(t)
+		mockNs := mockNamespace("/test", "pubkey", "identity", server_structs.AdminMetadata{UserID: "someone", Status: server_structs.RegApproved})
+		require.NoError(t, insertMockDBData([]server_structs.Namespace{mockNs}))
+		got, err := getAllNamespaces()
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+
+		policy := server_structs.NamespacePolicy{DisallowDirectReads: true, WriteOrigins: []string{"origin.example.com"}}
+		require.NoError(t, updateNamespacePolicyById(got[0].ID, policy))
+		got, err = getAllNamespaces()
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, policy, got[0].AdminMetadata.Policy)
+		assert.Equal(t, server_structs.RegApproved, got[0].AdminMetadata.Status)
+	})
+}
+
 func TestGetNamespacesByFilter(t *testing.T) {
 	_, cancel, egrp := test_utils.TestContext(context.Background(), t)
 	defer func() { require.NoError(t, egrp.Wait()) }()
@@ -253,6 +253,24 @@ func listNamespaces(ctx *gin.Context) {
 		return
 	}
 	nssWOPubkey := excludePubKey(namespaces)
+	// Registry admins see the identities linked to each namespace to validate who controls it
+	if isAdmin, _ := web_ui.CheckAdmin(user); isAuthed && isAdmin && len(nssWOPubkey) > 0 {
+		ids := make([]int, 0, len(nssWOPubkey))
+		for _, ns := range nssWOPubkey {
+			ids = append(ids, ns.ID)
+		}
+		identities, err := getIdentitiesByNamespace(ids)
+		if err != nil {
+			log.Error("Failed to get the identities linked to namespaces: ", err)
+			ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Server encountered an error trying to list namespaces"})
+			return
+		}
+		for idx := range nssWOPubkey {
+			nssWOPubkey[idx].LinkedIdentities = identities[nssWOPubkey[idx].ID]
+		}
+	}
 	ctx.JSON(http.StatusOK, nssWOPubkey)
 }
 
@@ -340,6 +358,10 @@ func createUpdateNamespace(ctx *gin.Context, isUpdate bool) {
 			Msg:    "You need to login to perform this action"})
 		return
 	}
+	// Keep bots from spamming the registry before doing any work for the request
+	if !isUpdate && !checkRegistrationChallenge(ctx) {
+		return
+	}
 	if isUpdate {
 		idStr := ctx.Param("id")
 		var err error
@@ -471,6 +493,26 @@ func createUpdateNamespace(ctx *gin.Context, isUpdate bool) {
 			})
 			return
 		}
+		if param.Registry_RequireEmailVerification.GetBool() {
+			if ns.AdminMetadata.ContactEmail == "" {
+				ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    "A contact email address is required to register a namespace"})
+				return
+			}
+			if _, err := createPendingRegistration(&ns, user); err != nil {
+				log.Errorf("Failed to hold the registration of %s for email verification: %v", ns.Prefix, err)
+				ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    "Server encountered an error sending the verification email"})
+				return
+			}
+			ctx.JSON(http.StatusAccepted, server_structs.SimpleApiResp{
+				Status: server_structs.RespOK,
+				Msg:    fmt.Sprintf("A verification link was sent to %s; prefix %s will be registered once you open it", ns.AdminMetadata.ContactEmail, ns.Prefix),
+			})
+			return
+		}
 		if err := AddNamespace(&ns); err != nil {
 			log.Errorf("Failed to insert namespace with id %d. %v", ns.ID, err)
 			ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
@@ -592,6 +634,26 @@ func createUpdateNamespace(ctx *gin.Context, isUpdate bool) {
 			}
 		}
 
+		// Co-owners approve replacements of the namespace key, so an owner can't change
+		// them on their own; the registry admin has to
+		if !isAdmin && ns.AdminMetadata.CoOwners != nil {
+			existingNs, err := getNamespaceById(ns.ID)
+			if err != nil {
+				log.Error("Error getting namespace: ", err)
+				ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    "Error getting namespace"})
+				return
+			}
+			if !sameCoOwners(existingNs.AdminMetadata.CoOwners, ns.AdminMetadata.CoOwners) {
+				log.Errorf("User '%s' is trying to change the co-owners of namespace registration with id=%d", user, ns.ID)
+				ctx.JSON(http.StatusForbidden, server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    "You don't have permission to change the co-owners of a namespace. Please contact your federation administrator"})
+				return
+			}
+		}
+
 		// Basic validation (type, required, etc)
 		errs := config.GetValidate().Struct(ns)
 		if errs != nil {
@@ -776,6 +838,63 @@ func updateNamespaceStatus(ctx *gin.Context, status server_structs.RegistrationS
 		})
 }
 
+// Admin endpoint to set the policy of a namespace, i.e., the capabilities the director
+// requires of the origins exporting it.  Blank write origins are dropped before saving.
+func updateNamespacePolicy(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid ID format. ID must a non-zero integer"})
+		return
+	}
+	policy := server_structs.NamespacePolicy{}
+	if err := ctx.ShouldBindJSON(&policy); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Invalid namespace policy: %v", err)})
+		return
+	}
+	writeOrigins := make([]string, 0, len(policy.WriteOrigins))
+	for _, origin := range policy.WriteOrigins {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			writeOrigins = append(writeOrigins, origin)
+		}
+	}
+	policy.WriteOrigins = nil
+	if len(writeOrigins) > 0 {
+		policy.WriteOrigins = writeOrigins
+	}
+
+	exists, err := namespaceExistsById(id)
+	if err != nil {
+		log.Error("Error checking if namespace exists: ", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Error checking if namespace exists"})
+		return
+	}
+	if !exists {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Namespace not found"})
+		return
+	}
+
+	if err = updateNamespacePolicyById(id, policy); err != nil {
+		log.Errorf("Error updating the policy of the namespace with ID %d: %v", id, err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to update the namespace policy"})
+		return
+	}
+	log.Infof("User %s set the policy of the namespace with ID %d to %+v", ctx.GetString("User"), id, policy)
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{
+		Status: server_structs.RespOK,
+		Msg:    "success",
+	})
+}
+
 func getNamespaceJWKS(ctx *gin.Context) {
 	idStr := ctx.Param("id")
 	id, err := strconv.Atoi(idStr)
@@ -930,6 +1049,9 @@ func RegisterRegistryWebAPI(router *gin.RouterGroup) error {
 			createUpdateNamespace(ctx, false)
 		})
 
+		registryWebAPI.GET("/registration_challenge", getRegistrationChallengeHandler)
+		registryWebAPI.GET("/registrations/verify", verifyRegistrationEmailHandler)
+
 		registryWebAPI.GET("/namespaces/user", web_ui.AuthHandler, listNamespacesForUser)
 
 		registryWebAPI.GET("/namespaces/:id", web_ui.AuthHandler, getNamespace)
@@ -944,6 +1066,33 @@ func RegisterRegistryWebAPI(router *gin.RouterGroup) error {
 		registryWebAPI.PATCH("/namespaces/:id/deny", web_ui.AuthHandler, web_ui.AdminAuthHandler, func(ctx *gin.Context) {
 			updateNamespaceStatus(ctx, server_structs.RegDenied)
 		})
+		registryWebAPI.PUT("/namespaces/:id/policy", web_ui.AuthHandler, web_ui.AdminAuthHandler, updateNamespacePolicy)
+	}
+	{
+		registryWebAPI.POST("/namespaces/:id/recovery_codes", web_ui.AuthHandler, createRecoveryCodesHandler)
+		registryWebAPI.GET("/namespaces/:id/key_recovery", web_ui.AuthHandler, listKeyRecoveryRequestsHandler)
+		registryWebAPI.POST("/namespaces/:id/key_recovery", web_ui.AuthHandler, createKeyRecoveryRequestHandler)
+		registryWebAPI.POST("/namespaces/:id/key_recovery/:requestId/approve", web_ui.AuthHandler, approveKeyRecoveryRequestHandler)
+	}
+	{
+		registryWebAPI.GET("/identities/providers", listIdentityProvidersHandler)
+		registryWebAPI.GET("/namespaces/:id/identities", web_ui.AuthHandler, listNamespaceIdentitiesHandler)
+		registryWebAPI.DELETE("/namespaces/:id/identities/:provider", web_ui.AuthHandler, unlinkNamespaceIdentityHandler)
+	}
+	// The OAuth2 flows with identity providers keep their state in the session
+	if len(identityProviders) > 0 {
+		seHandler, err := web_ui.GetSessionHandler()
+		if err != nil {
+			return err
+		}
+		identityAPI := registryWebAPI.Group("", seHandler)
+		identityAPI.GET("/namespaces/:id/identities/:provider/link", web_ui.AuthHandler, func(ctx *gin.Context) {
+			startIdentityFlow(ctx, identityActionLink)
+		})
+		identityAPI.GET("/namespaces/:id/identities/:provider/recover", web_ui.AuthHandler, func(ctx *gin.Context) {
+			startIdentityFlow(ctx, identityActionRecover)
+		})
+		identityAPI.GET("/identities/callback", web_ui.AuthHandler, handleIdentityCallback)
 	}
 	{
 		registryWebAPI.GET("/topology", listTopologyNamespaces)
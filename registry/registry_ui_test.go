@@ -1337,6 +1337,59 @@ func TestUpdateNamespaceHandler(t *testing.T) {
 		assert.Equal(t, "newDescription", nss[0].AdminMetadata.Description)
 	})
 
+	t.Run("reg-user-cant-change-co-owners", func(t *testing.T) {
+		resetNamespaceDB(t)
+		mockInsts := []registrationFieldOption{{ID: "1000"}}
+		viper.Set("Registry.Institutions", mockInsts)
+
+		pubKeyStr, err := test_utils.GenerateJWKS()
+		require.NoError(t, err)
+
+		mockNs := server_structs.Namespace{
+			Prefix: "/foo",
+			Pubkey: pubKeyStr,
+			AdminMetadata: server_structs.AdminMetadata{
+				Description: "oldDescription",
+				Institution: "1000",
+				UserID:      "mockUser",
+				CoOwners:    []string{"alice", "bob"},
+				Status:      server_structs.RegPending,
+			},
+		}
+
+		err = insertMockDBData([]server_structs.Namespace{mockNs})
+		require.NoError(t, err)
+
+		id, err := getLastNamespaceId()
+		require.NoError(t, err)
+
+		// Adding a co-owner, who could then approve replacing the key, needs an admin
+		updatedNs := mockNs
+		updatedNs.AdminMetadata.CoOwners = []string{"alice", "bob", "mallory"}
+		mockNsBytes, err := json.Marshal(updatedNs)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/namespaces/"+strconv.Itoa(id), bytes.NewReader(mockNsBytes))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+
+		// Sending the same co-owners back, in any order, is fine
+		updatedNs.AdminMetadata.CoOwners = []string{"bob", "alice"}
+		updatedNs.AdminMetadata.Description = "newDescription"
+		mockNsBytes, err = json.Marshal(updatedNs)
+		require.NoError(t, err)
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("PUT", "/namespaces/"+strconv.Itoa(id), bytes.NewReader(mockNsBytes))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+		nss, err := getAllNamespaces()
+		require.NoError(t, err)
+		require.Equal(t, 1, len(nss))
+		assert.Equal(t, "newDescription", nss[0].AdminMetadata.Description)
+		assert.ElementsMatch(t, []string{"alice", "bob"}, nss[0].AdminMetadata.CoOwners)
+	})
+
 	t.Run("admin-can-change-anybody", func(t *testing.T) {
 		resetNamespaceDB(t)
 		mockInsts := []registrationFieldOption{{ID: "1000"}}
@@ -0,0 +1,127 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// The origin/cache advertisement from version 3 of the schema on.  Unlike V1 and
+	// V2, which are told apart by their fields, it carries its version in the ad
+	// itself so a director can reject versions it doesn't understand.  New fields
+	// are added by introducing a new version; servers negotiate the version to send
+	// with each director (see AdVersionsHeader) so older directors keep receiving
+	// ads they can parse.
+	OriginAdvertiseV3 struct {
+		AdVersion int `json:"ad-version"`
+		OriginAdvertiseV2
+		// The server's current load; unset if it's unknown
+		Load *AdvertisedLoad `json:"load,omitempty"`
+		// The checksum algorithms the server can compute for its objects
+		ChecksumAlgorithms []string `json:"checksum-algorithms,omitempty"`
+		// Whether the server is draining before a restart and should only get clients no other server can take
+		Draining bool `json:"draining,omitempty"`
+		// Why the server is degraded, e.g., a cache whose disks are failing or full, and should
+		// only get clients no other server can take; empty if it's healthy
+		Degraded string `json:"degraded,omitempty"`
+		// The fraction of its normal capacity the server has left, e.g., after a cache took
+		// failing disks out of service; unset if it's at full capacity
+		Capacity float64 `json:"capacity,omitempty"`
+		// The objects a cache recently served, letting the director prefer caches likely to have an object
+		ContentFilter *ContentFilter `json:"content-filter,omitempty"`
+		// Whether the origin's web API accepts large uploads in chunks sent in parallel
+		ChunkedUploads bool `json:"chunked-uploads,omitempty"`
+		// Whether the origin's web API serves the members of zip and tar archives
+		ArchiveMembers bool `json:"archive-members,omitempty"`
+	}
+
+	// The load a server reports in its advertisement
+	AdvertisedLoad struct {
+		// The number of ongoing storage operations
+		ActiveIO int64 `json:"active-io"`
+	}
+)
+
+const (
+	AdVersionV1 = 1
+	AdVersionV2 = 2
+	AdVersionV3 = 3
+
+	// The newest advertisement version this build can send and accept
+	LatestAdVersion = AdVersionV3
+
+	// The response header a director uses to list the advertisement versions it
+	// accepts, as a comma-separated list (e.g., "1,2,3")
+	AdVersionsHeader = "X-Pelican-Ad-Versions"
+)
+
+// The advertisement versions accepted by the director, formatted for AdVersionsHeader
+func SupportedAdVersions() string {
+	versions := make([]string, 0, LatestAdVersion)
+	for version := AdVersionV1; version <= LatestAdVersion; version++ {
+		versions = append(versions, strconv.Itoa(version))
+	}
+	return strings.Join(versions, ",")
+}
+
+// Parse the value of AdVersionsHeader, ignoring malformed entries
+func ParseAdVersions(header string) (versions []int) {
+	for _, field := range strings.Split(header, ",") {
+		if version, err := strconv.Atoi(strings.TrimSpace(field)); err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+	return
+}
+
+// Choose the advertisement version to send to a director given the value of the
+// AdVersionsHeader in its response: the newest version both sides support.
+// Directors that predate versioned ads don't send the header; they accept V2.
+func NegotiateAdVersion(header string) int {
+	versions := ParseAdVersions(header)
+	if len(versions) == 0 {
+		return AdVersionV2
+	}
+	for idx := len(versions) - 1; idx >= 0; idx-- {
+		if versions[idx] <= LatestAdVersion {
+			return versions[idx]
+		}
+	}
+	// The director only accepts versions newer than ours; V2 is the best we can do
+	return AdVersionV2
+}
+
+// The advertisement in the form sent to a director accepting the given version
+func (ad *OriginAdvertiseV3) ForVersion(version int) (any, error) {
+	switch version {
+	case AdVersionV3:
+		adV3 := *ad
+		adV3.AdVersion = AdVersionV3
+		return &adV3, nil
+	case AdVersionV2:
+		return &ad.OriginAdvertiseV2, nil
+	}
+	return nil, errors.Errorf("unable to send a version %d advertisement", version)
+}
@@ -0,0 +1,71 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateAdVersion(t *testing.T) {
+	assert.Equal(t, "1,2,3", SupportedAdVersions())
+	assert.Equal(t, []int{1, 2, 3}, ParseAdVersions(" 3, 1,bogus,2,-4"))
+
+	// Directors that predate the header accept V2
+	assert.Equal(t, AdVersionV2, NegotiateAdVersion(""))
+	assert.Equal(t, AdVersionV3, NegotiateAdVersion(SupportedAdVersions()))
+	// A newer director still accepts our latest version
+	assert.Equal(t, LatestAdVersion, NegotiateAdVersion("2,3,4"))
+	assert.Equal(t, AdVersionV2, NegotiateAdVersion("1,2"))
+}
+
+func TestAdForVersion(t *testing.T) {
+	ad := OriginAdvertiseV3{
+		OriginAdvertiseV2:  OriginAdvertiseV2{Name: "test", DataURL: "https://origin.example.com"},
+		Load:               &AdvertisedLoad{ActiveIO: 2},
+		ChecksumAlgorithms: []string{"md5"},
+	}
+
+	versioned, err := ad.ForVersion(AdVersionV3)
+	require.NoError(t, err)
+	data, err := json.Marshal(versioned)
+	require.NoError(t, err)
+	fields := map[string]any{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+	assert.Equal(t, float64(AdVersionV3), fields["ad-version"])
+	assert.Equal(t, "https://origin.example.com", fields["data-url"])
+	assert.Contains(t, fields, "load")
+
+	// Older directors get a V2 ad without the version or the newer fields
+	versioned, err = ad.ForVersion(AdVersionV2)
+	require.NoError(t, err)
+	data, err = json.Marshal(versioned)
+	require.NoError(t, err)
+	fields = map[string]any{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+	assert.NotContains(t, fields, "ad-version")
+	assert.NotContains(t, fields, "checksum-algorithms")
+	assert.Equal(t, "test", fields["name"])
+
+	_, err = ad.ForVersion(AdVersionV1)
+	assert.Error(t, err)
+}
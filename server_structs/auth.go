@@ -21,6 +21,10 @@ package server_structs
 type (
 	OAuthLoginRequest struct {
 		NextUrl string `form:"nextUrl,omitempty"`
+		// The name of the provider in OIDC.Providers to log in with; defaults to the first
+		Provider string `form:"provider,omitempty"`
+		// Link the identity to the logged-in user's account
+		Link bool `form:"link,omitempty"`
 	}
 
 	OAuthCallbackRequest struct {
@@ -0,0 +1,102 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"path"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// A Bloom filter of the objects a cache holds, advertised so that the director can
+	// prefer the caches likely to already have an object.  It may report an object it
+	// wasn't given (a false positive) but never misses one it was.
+	ContentFilter struct {
+		Bits []byte `json:"bits"`
+		// The number of bits set for each object
+		Hashes int `json:"hashes"`
+	}
+)
+
+const (
+	// Bounds on the filters a director accepts, keeping a cache's ad reasonably small
+	maxContentFilterBytes  = 8 << 20
+	maxContentFilterHashes = 32
+)
+
+// Create a filter sized for the number of objects with the given rate of false positives
+func NewContentFilter(objects int, falsePositiveRate float64) *ContentFilter {
+	if objects < 1 {
+		objects = 1
+	}
+	bits := math.Ceil(-float64(objects) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	bytes := min(int(math.Ceil(bits/8)), maxContentFilterBytes)
+	hashes := int(math.Round(float64(bytes*8) / float64(objects) * math.Ln2))
+	hashes = min(max(hashes, 1), maxContentFilterHashes)
+	return &ContentFilter{Bits: make([]byte, bytes), Hashes: hashes}
+}
+
+// The bits of the object, from two halves of its FNV-1a hash (Kirsch and Mitzenmacher)
+func (filter *ContentFilter) positions(objectPath string) []uint64 {
+	hasher := fnv.New128a()
+	hasher.Write([]byte(path.Clean("/" + objectPath)))
+	sum := hasher.Sum(nil)
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:]) | 1
+	size := uint64(len(filter.Bits)) * 8
+	positions := make([]uint64, filter.Hashes)
+	for idx := range positions {
+		positions[idx] = (h1 + uint64(idx)*h2) % size
+	}
+	return positions
+}
+
+func (filter *ContentFilter) Add(objectPath string) {
+	for _, pos := range filter.positions(objectPath) {
+		filter.Bits[pos/8] |= 1 << (pos % 8)
+	}
+}
+
+// Whether the object was likely added to the filter
+func (filter *ContentFilter) MayContain(objectPath string) bool {
+	if len(filter.Bits) == 0 || filter.Hashes < 1 {
+		return false
+	}
+	for _, pos := range filter.positions(objectPath) {
+		if filter.Bits[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Check a filter received from a cache
+func (filter *ContentFilter) Validate() error {
+	if len(filter.Bits) == 0 || len(filter.Bits) > maxContentFilterBytes {
+		return errors.Errorf("the content filter has %d bytes; it must have between 1 and %d", len(filter.Bits), maxContentFilterBytes)
+	}
+	if filter.Hashes < 1 || filter.Hashes > maxContentFilterHashes {
+		return errors.Errorf("the content filter sets %d bits per object; it must set between 1 and %d", filter.Hashes, maxContentFilterHashes)
+	}
+	return nil
+}
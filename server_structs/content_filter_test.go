@@ -0,0 +1,59 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentFilter(t *testing.T) {
+	filter := NewContentFilter(1000, 0.01)
+	require.NoError(t, filter.Validate())
+	for idx := 0; idx < 1000; idx++ {
+		filter.Add(fmt.Sprintf("/data/run%d/events.dat", idx))
+	}
+
+	// The filter survives the advertisement
+	data, err := json.Marshal(filter)
+	require.NoError(t, err)
+	received := ContentFilter{}
+	require.NoError(t, json.Unmarshal(data, &received))
+	require.NoError(t, received.Validate())
+
+	for idx := 0; idx < 1000; idx++ {
+		assert.True(t, received.MayContain(fmt.Sprintf("/data/run%d/events.dat", idx)))
+	}
+	assert.True(t, received.MayContain("data//run7/events.dat"), "paths are compared once cleaned")
+	falsePositives := 0
+	for idx := 0; idx < 10000; idx++ {
+		if received.MayContain(fmt.Sprintf("/other/run%d/events.dat", idx)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 300)
+
+	assert.False(t, (&ContentFilter{}).MayContain("/data/run7/events.dat"))
+	assert.Error(t, (&ContentFilter{Bits: []byte{0}, Hashes: 0}).Validate())
+	assert.Error(t, (&ContentFilter{Hashes: 3}).Validate())
+}
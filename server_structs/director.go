@@ -32,6 +32,21 @@ import (
 )
 
 type (
+	// One of the servers a director lists for the client to fail over to
+	RedirectServer struct {
+		URL      string `json:"url"`
+		Priority int    `json:"priority"` // 1 is the most preferred server
+		Depth    int    `json:"depth"`    // The number of path components of the namespace prefix
+	}
+
+	// The JSON body a director responds with, instead of a redirect, to clients accepting JSON
+	RedirectResponse struct {
+		// The URL the director would have redirected to, including the token of the request
+		Location  string           `json:"location"`
+		Namespace string           `json:"namespace"`
+		Servers   []RedirectServer `json:"servers"`
+	}
+
 	TokenIssuer struct {
 		BasePaths       []string `json:"base-paths"`
 		RestrictedPaths []string `json:"restricted-paths"`
@@ -52,6 +67,11 @@ type (
 		Writes      bool `json:"Write"`
 		Listings    bool `json:"Listing"`
 		DirectReads bool `json:"FallBackRead"`
+		// Experimental capabilities that aren't (yet) fields of their own, keyed by
+		// their lowercase name (e.g., "tpc" or "zip-extraction").  This lets servers
+		// advertise a feature before it's promoted to a first-class field; directors
+		// pass along and match names they don't otherwise understand.
+		Extensions map[string]string `json:"Extensions,omitempty"`
 	}
 
 	NamespaceAdV2 struct {
@@ -60,6 +80,9 @@ type (
 		Generation   []TokenGen    `json:"token-generation"`
 		Issuer       []TokenIssuer `json:"token-issuer"`
 		FromTopology bool          `json:"from-topology"`
+		// The number of cache replicas desired for the namespace in each cache region,
+		// keyed by the (lowercase) region name; "*" counts caches in any region
+		ReplicaTargets map[string]int `json:"replica-targets,omitempty"`
 	}
 
 	NamespaceAdV1 struct {
@@ -78,9 +101,10 @@ type (
 		StorageType         OriginStorageType `json:"storageType"` // Always POSIX for caches
 		DisableDirectorTest bool              `json:"directorTest"`
 		AuthURL             url.URL           `json:"auth_url"`
-		BrokerURL           url.URL           `json:"broker_url"` // The URL of the broker service to use for this host.
-		URL                 url.URL           `json:"url"`        // This is server's XRootD URL for file transfer
-		WebURL              url.URL           `json:"web_url"`    // This is server's Web interface and API
+		BrokerURL           url.URL           `json:"broker_url"`            // The URL of the broker service to use for this host.
+		BrokerOnly          bool              `json:"broker_only,omitempty"` // Whether the server is only reachable through the broker, which only caches can use
+		URL                 url.URL           `json:"url"`                   // This is server's XRootD URL for file transfer
+		WebURL              url.URL           `json:"web_url"`               // This is server's Web interface and API
 		Type                string            `json:"type"`
 		Latitude            float64           `json:"latitude"`
 		Longitude           float64           `json:"longitude"`
@@ -88,6 +112,21 @@ type (
 		FromTopology        bool              `json:"from_topology"`
 		IOLoad              float64           `json:"io_load"`
 		Version             string            `json:"version"`
+		Region              string            `json:"region,omitempty"`              // The region a cache declares itself to be in
+		LocalHttpURL        url.URL           `json:"local_http_url"`                // The cache's plain HTTP endpoint for public reads from local networks
+		LocalHttpNetworks   []string          `json:"local_http_networks,omitempty"` // The CIDRs of the clients allowed to use LocalHttpURL
+		OfflinePartitions   []string          `json:"offline_partitions,omitempty"`  // Cache data partitions taken out of service for IO errors
+		AdVersion           int               `json:"ad_version,omitempty"`          // The version of the advertisement schema the server sent
+		ActiveIO            int64             `json:"active_io,omitempty"`           // The ongoing storage operations the server reported (ad version 3+)
+		ChecksumAlgorithms  []string          `json:"checksum_algorithms,omitempty"` // The checksum algorithms the server supports (ad version 3+)
+		Draining            bool              `json:"draining,omitempty"`            // Whether the server is draining before a restart (ad version 3+)
+		Degraded            string            `json:"degraded,omitempty"`            // Why the server is degraded, if it is (ad version 3+)
+		Capacity            float64           `json:"capacity,omitempty"`            // The fraction of its normal capacity the server has left; 0 if it's at full capacity (ad version 3+)
+		ContentFilter       *ContentFilter    `json:"-"`                             // The objects a cache recently served (ad version 3+)
+		ChunkedUploads      bool              `json:"chunked_uploads,omitempty"`     // Whether the origin's web API accepts chunked uploads (ad version 3+)
+		ArchiveMembers      bool              `json:"archive_members,omitempty"`     // Whether the origin's web API serves the members of archives (ad version 3+)
+		ResourceGroup       string            `json:"resource_group,omitempty"`      // The OSG resource group of a server from topology
+		Contacts            []TopoContact     `json:"contacts,omitempty"`            // The contacts of a server from topology
 	}
 
 	// The struct holding a server's advertisement (including ServerAd and NamespaceAd)
@@ -116,6 +155,10 @@ type (
 		StorageType         OriginStorageType `json:"storageType"`
 		DisableDirectorTest bool              `json:"directorTest"` // Use negative attribute (disable instead of enable) to be BC with legacy servers where they don't have this field
 		Version             string            `json:"version"`
+		Region              string            `json:"region,omitempty"`
+		LocalHttpURL        string            `json:"local-http-url,omitempty"`
+		LocalHttpNetworks   []string          `json:"local-http-networks,omitempty"`
+		OfflinePartitions   []string          `json:"offline-partitions,omitempty"` // Cache data partitions taken out of service for IO errors
 	}
 
 	OriginAdvertiseV1 struct {
@@ -172,12 +215,28 @@ type (
 		VaultServer   *url.URL
 	}
 
+	// The availability of an object at a single cache, as determined by the director
+	ObjectAvailability struct {
+		Name      string `json:"name"`
+		URL       string `json:"url"`
+		Available bool   `json:"available"`
+		Checksum  string `json:"checksum,omitempty"` // Value of the Digest header returned by the cache, if any
+	}
+
+	// Response for the director's object availability endpoint
+	ObjectAvailabilityResp struct {
+		Path   string               `json:"path"`
+		Caches []ObjectAvailability `json:"caches"`
+	}
+
 	DirectorResponse struct {
 		ObjectServers []*url.URL // List of servers provided in Link header
 		Location      *url.URL   // URL content of the location header
 		XPelAuthHdr   XPelAuth
 		XPelNsHdr     XPelNs
 		XPelTokGenHdr XPelTokGen
+		// The web API of the origin an upload goes to, if it accepts chunked uploads
+		ChunkedUploadUrl *url.URL
 	}
 )
 
@@ -273,6 +332,21 @@ func (x *XPelTokGen) ParseRawResponse(resp *http.Response) error {
 	return nil
 }
 
+const (
+	// Response header set by a director when it delegates a client request to a
+	// regional sub-director; the value is the sub-director's URL
+	SubDirectorHeader = "X-Pelican-Sub-Director"
+	// Request header set by a director when it forwards a server advertisement to
+	// a sub-director; the value is the forwarding director's URL
+	ForwardedAdHeader = "X-Pelican-Forwarded-Ad"
+	// Response header set by a director when the requested path is under a renamed
+	// namespace; the value names the deprecated alias and the namespace it now maps to
+	NamespaceAliasHeader = "X-Pelican-Namespace-Alias"
+	// Response header set by a director redirecting an upload to an origin that accepts
+	// chunked uploads; the value is the URL of the origin's web API
+	ChunkedUploadHeader = "X-Pelican-Chunked-Upload"
+)
+
 const (
 	OAuthStrategy StrategyType = "OAuth2"
 	VaultStrategy StrategyType = "Vault"
@@ -295,20 +369,68 @@ func IsValidStrategy(strategy string) bool {
 	}
 }
 
+// Normalize the name of an experimental capability
+func normalizeExtensionName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Set the value of an experimental capability
+func (caps *Capabilities) SetExtension(name, value string) {
+	if caps.Extensions == nil {
+		caps.Extensions = map[string]string{}
+	}
+	caps.Extensions[normalizeExtensionName(name)] = value
+}
+
+// The value of an experimental capability and whether it's advertised at all
+func (caps Capabilities) Extension(name string) (value string, ok bool) {
+	value, ok = caps.Extensions[normalizeExtensionName(name)]
+	return
+}
+
+// Whether an experimental capability is enabled: it's advertised with any value
+// other than a false boolean (e.g., "true" or a feature version such as "v2")
+func (caps Capabilities) HasExtension(name string) bool {
+	value, ok := caps.Extension(name)
+	if !ok {
+		return false
+	}
+	if enabled, err := strconv.ParseBool(value); err == nil {
+		return enabled
+	}
+	return true
+}
+
+// Parse an experimental capability given as "name" or "name=value"; a bare
+// name is enabled with the value "true"
+func ParseExtension(extension string) (name, value string, err error) {
+	name, value, found := strings.Cut(extension, "=")
+	name = normalizeExtensionName(name)
+	if name == "" {
+		return "", "", errors.Errorf("invalid experimental capability %q; expected name or name=value", extension)
+	}
+	if !found {
+		value = "true"
+	}
+	return name, strings.TrimSpace(value), nil
+}
+
 func (ad *ServerAd) MarshalJSON() ([]byte, error) {
 	type Alias ServerAd
 	return json.Marshal(&struct {
-		AuthURL   string `json:"auth_url"`
-		BrokerURL string `json:"broker_url"`
-		URL       string `json:"url"`
-		WebURL    string `json:"web_url"`
+		AuthURL      string `json:"auth_url"`
+		BrokerURL    string `json:"broker_url"`
+		URL          string `json:"url"`
+		WebURL       string `json:"web_url"`
+		LocalHttpURL string `json:"local_http_url"`
 		*Alias
 	}{
-		AuthURL:   ad.AuthURL.String(),
-		BrokerURL: ad.BrokerURL.String(),
-		URL:       ad.URL.String(),
-		WebURL:    ad.WebURL.String(),
-		Alias:     (*Alias)(ad),
+		AuthURL:      ad.AuthURL.String(),
+		BrokerURL:    ad.BrokerURL.String(),
+		URL:          ad.URL.String(),
+		WebURL:       ad.WebURL.String(),
+		LocalHttpURL: ad.LocalHttpURL.String(),
+		Alias:        (*Alias)(ad),
 	})
 }
 
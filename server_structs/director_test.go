@@ -19,6 +19,7 @@
 package server_structs
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -281,3 +282,37 @@ func TestXPelTokGenParsing(t *testing.T) {
 		assert.Len(t, xPelTokGen.BasePaths, 0)
 	})
 }
+
+func TestCapabilityExtensions(t *testing.T) {
+	caps := Capabilities{Reads: true}
+	assert.False(t, caps.HasExtension("tpc"))
+
+	caps.SetExtension(" TPC ", "true")
+	caps.SetExtension("zip-extraction", "v2")
+	caps.SetExtension("checksums", "false")
+	assert.True(t, caps.HasExtension("tpc"))
+	assert.True(t, caps.HasExtension("zip-extraction"))
+	assert.False(t, caps.HasExtension("checksums"))
+	value, ok := caps.Extension("Zip-Extraction")
+	assert.True(t, ok)
+	assert.Equal(t, "v2", value)
+
+	// Servers and directors that predate extensions ignore the field
+	data, err := json.Marshal(caps)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"PublicRead":false,"Read":true,"Write":false,"Listing":false,"FallBackRead":false,"Extensions":{"tpc":"true","zip-extraction":"v2","checksums":"false"}}`, string(data))
+	data, err = json.Marshal(Capabilities{Reads: true})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "Extensions")
+
+	name, value, err := ParseExtension("zip-extraction = v1")
+	require.NoError(t, err)
+	assert.Equal(t, "zip-extraction", name)
+	assert.Equal(t, "v1", value)
+	name, value, err = ParseExtension("TPC")
+	require.NoError(t, err)
+	assert.Equal(t, "tpc", name)
+	assert.Equal(t, "true", value)
+	_, _, err = ParseExtension("=v1")
+	assert.Error(t, err)
+}
@@ -19,6 +19,7 @@
 package server_structs
 
 import (
+	"slices"
 	"strings"
 	"time"
 )
@@ -46,13 +47,30 @@ type AdminMetadata struct {
 	SiteName              string             `json:"site_name"`
 	Institution           string             `json:"institution" validate:"required"`                                                                                // the unique identifier of the institution
 	SecurityContactUserID string             `json:"security_contact_user_id" description:"User Identifier of the user responsible for the security of the service"` // "sub" claim of user who is responsible for taking security concern
+	ContactEmail          string             `json:"contact_email" validate:"omitempty,email" description:"Email address the registry may contact the owners of the namespace at"`
+	CoOwners              []string           `json:"co_owners,omitempty" description:"User Identifiers of additional owners who may approve a replacement of the namespace public key"`
+	Issuer                string             `json:"issuer,omitempty" validate:"omitempty,http_url" description:"URL of the token issuer of the collaboration owning the namespace, whose metadata the registry proxies for clients"`
 	Status                RegistrationStatus `json:"status" post:"exclude"`
+	Policy                NamespacePolicy    `json:"policy" post:"exclude"`      // set by registry admins only
 	ApproverID            string             `json:"approver_id" post:"exclude"` // "sub" claim of user JWT who approved registration
 	ApprovedAt            time.Time          `json:"approved_at" post:"exclude"`
 	CreatedAt             time.Time          `json:"created_at" post:"exclude"`
 	UpdatedAt             time.Time          `json:"updated_at" post:"exclude"`
 }
 
+// The NamespacePolicy holds the capabilities an administrator of the registry
+// requires of the origins exporting a namespace.  The registry passes it to the
+// director with the namespace's approval status, and the director rejects the
+// advertisements of origins violating it.
+type NamespacePolicy struct {
+	// The namespace must never be read directly from an origin, bypassing the caches
+	DisallowDirectReads bool `json:"disallow_direct_reads,omitempty"`
+	// The namespace must never be readable without a token
+	DisallowPublicReads bool `json:"disallow_public_reads,omitempty"`
+	// If set, only the listed origins, by name or hostname, may accept writes to the namespace
+	WriteOrigins []string `json:"write_origins,omitempty"`
+}
+
 type Namespace struct {
 	ID            int                    `json:"id" post:"exclude" gorm:"primaryKey"`
 	Prefix        string                 `json:"prefix" validate:"required"`
@@ -81,6 +99,8 @@ type (
 
 	CheckNamespaceStatusRes struct {
 		Approved bool `json:"approved"`
+		// Older registries do not send a policy
+		Policy *NamespacePolicy `json:"policy,omitempty"`
 	}
 
 	CheckNamespaceCompleteReq struct {
@@ -119,13 +139,28 @@ func (a AdminMetadata) Equal(b AdminMetadata) bool {
 		a.SiteName == b.SiteName &&
 		a.Institution == b.Institution &&
 		a.SecurityContactUserID == b.SecurityContactUserID &&
+		a.ContactEmail == b.ContactEmail &&
+		slices.Equal(a.CoOwners, b.CoOwners) &&
+		a.Issuer == b.Issuer &&
 		a.Status == b.Status &&
+		a.Policy.Equal(b.Policy) &&
 		a.ApproverID == b.ApproverID &&
 		a.ApprovedAt.Equal(b.ApprovedAt) &&
 		a.CreatedAt.Equal(b.CreatedAt) &&
 		a.UpdatedAt.Equal(b.UpdatedAt)
 }
 
+func (p NamespacePolicy) Equal(b NamespacePolicy) bool {
+	return p.DisallowDirectReads == b.DisallowDirectReads &&
+		p.DisallowPublicReads == b.DisallowPublicReads &&
+		slices.Equal(p.WriteOrigins, b.WriteOrigins)
+}
+
+// Whether the policy requires nothing of the origins
+func (p NamespacePolicy) IsEmpty() bool {
+	return p.Equal(NamespacePolicy{})
+}
+
 func (Namespace) TableName() string {
 	return "namespace"
 }
@@ -2,6 +2,7 @@ package server_structs
 
 import (
 	"encoding/xml"
+	"strings"
 )
 
 type (
@@ -9,6 +10,17 @@ type (
 		AuthEndpoint string `json:"auth_endpoint"`
 		Endpoint     string `json:"endpoint"`
 		Resource     string `json:"resource"`
+		// The OSG resource group the server's resource belongs to
+		ResourceGroup string        `json:"resource_group,omitempty"`
+		Contacts      []TopoContact `json:"contacts,omitempty"`
+	}
+
+	// A contact for a topology resource
+	TopoContact struct {
+		Name  string `json:"name"`
+		Email string `json:"email,omitempty"`
+		// The kind of contact, e.g., "Administrative Contact" or "Security Contact"
+		Type string `json:"type,omitempty"`
 	}
 
 	TopoScitokens struct {
@@ -36,6 +48,9 @@ type (
 		Scitokens            []TopoScitokens          `json:"scitokens"`
 		UseTokenOnRead       bool                     `json:"usetokenonread"`
 		WritebackHost        string                   `json:"writebackhost"`
+		// Experimental capabilities of the namespace, keyed by name; values may be
+		// any JSON scalar and are converted to strings
+		ExperimentalCapabilities map[string]interface{} `json:"experimental_capabilities,omitempty"`
 	}
 
 	TopologyNamespacesJSON struct {
@@ -71,14 +86,43 @@ type (
 
 	TopoServerDowntime struct {
 		ID            int               `xml:"ID"`
+		ResourceID    int               `xml:"ResourceID"`
 		ResourceGroup TopoResourceGroup `xml:"ResourceGroup"`
 		ResourceName  string            `xml:"ResourceName"`
 		ResourceFQDN  string            `xml:"ResourceFQDN"`
 		StartTime     string            `xml:"StartTime"`
 		EndTime       string            `xml:"EndTime"`
-		CreatedTime   string            `xml:"CreatedTime"`
-		UpdateTime    string            `xml:"UpdateTime"`
-		Services      TopoServices      `xml:"Services"`
-		Description   string            `xml:"Description"`
+		// SCHEDULED for planned maintenance or UNSCHEDULED for failures
+		Class string `xml:"Class"`
+		// The impact of the downtime, e.g., "Outage" or "Intermittent Outage"
+		Severity    string       `xml:"Severity"`
+		CreatedTime string       `xml:"CreatedTime"`
+		UpdateTime  string       `xml:"UpdateTime"`
+		Services    TopoServices `xml:"Services"`
+		Description string       `xml:"Description"`
 	}
 )
+
+// Whether the downtime is planned maintenance rather than a failure
+func (downtime *TopoServerDowntime) IsScheduled() bool {
+	return strings.EqualFold(downtime.Class, "SCHEDULED")
+}
+
+// Whether the downtime covers any of the resource's data services (an XRootD or
+// Pelican cache or origin).  A downtime listing only other services, such as the
+// resource's Squid, leaves its cache or origin in service.  Downtimes that don't
+// list their services are assumed to cover the whole resource.
+func (downtime *TopoServerDowntime) AffectsDataServices() bool {
+	if len(downtime.Services.Service) == 0 {
+		return true
+	}
+	for _, service := range downtime.Services.Service {
+		name := strings.ToLower(service.Name)
+		for _, keyword := range []string{"xrootd", "pelican", "cache", "origin"} {
+			if strings.Contains(name, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -27,7 +27,7 @@ type (
 		GetServerType() ServerType
 		SetNamespaceAds([]NamespaceAdV2)
 		GetNamespaceAds() []NamespaceAdV2
-		CreateAdvertisement(name string, serverUrl string, serverWebUrl string) (*OriginAdvertiseV2, error)
+		CreateAdvertisement(name string, serverUrl string, serverWebUrl string) (*OriginAdvertiseV3, error)
 		GetNamespaceAdsFromDirector() error
 
 		// Return the PIDs corresponding to the running process(es) for the XRootD
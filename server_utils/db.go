@@ -19,20 +19,28 @@
 package server_utils
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/glebarez/sqlite" // It doesn't require CGO
 	"github.com/pkg/errors"
 	"github.com/pressly/goose/v3"
 	log "github.com/sirupsen/logrus"
 	gormlog "github.com/thomas-tacquet/gormv2-logrus"
+	"golang.org/x/sync/errgroup"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+
+	"github.com/pelicanplatform/pelican/metrics"
 )
 
+// How often LaunchDBHealthCheck queries the database
+var dbHealthCheckInterval = 30 * time.Second
+
 func InitSQLiteDB(dbPath string) (*gorm.DB, error) {
 	if dbPath == "" {
 		return nil, errors.New("SQLite database path is empty")
@@ -83,8 +91,12 @@ func InitSQLiteDB(dbPath string) (*gorm.DB, error) {
 
 // Update database schema with the embedded migration files
 //
-// The embedded migration files need to be under "/migrations" folder
+// The embedded migration files need to be under "/migrations" folder. An existing
+// database is backed up before any pending migrations are applied to it.
 func MigrateDB(sqldb *sql.DB, migrationFS embed.FS) error {
+	if err := backupBeforeMigrating(sqldb, migrationFS); err != nil {
+		return err
+	}
 	goose.SetBaseFS(migrationFS)
 
 	if err := goose.SetDialect("sqlite3"); err != nil {
@@ -109,3 +121,42 @@ func ShutdownDB(db *gorm.DB) error {
 	}
 	return err
 }
+
+// Run a trivial query against the database
+func CheckDB(ctx context.Context, db *gorm.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var result int
+	if err := db.WithContext(ctx).Raw("SELECT 1").Scan(&result).Error; err != nil {
+		return errors.Wrap(err, "failed to query the database")
+	}
+	return nil
+}
+
+// Periodically query the database and report whether it works as the health of the component
+func LaunchDBHealthCheck(ctx context.Context, egrp *errgroup.Group, db *gorm.DB, component metrics.HealthStatusComponent) {
+	check := func() {
+		if err := CheckDB(ctx, db); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorf("The %s health check failed: %v", component.String(), err)
+			metrics.SetComponentHealthStatus(component, metrics.StatusCritical, err.Error())
+		} else {
+			metrics.SetComponentHealthStatus(component, metrics.StatusOK, "")
+		}
+	}
+	check()
+	egrp.Go(func() error {
+		ticker := time.NewTicker(dbHealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				check()
+			}
+		}
+	})
+}
@@ -0,0 +1,210 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_utils
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pressly/goose/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	// A schema migration embedded in the binary and whether it has been
+	// applied to a database
+	MigrationStatus struct {
+		Version int64
+		Name    string
+		Applied bool
+	}
+)
+
+const (
+	migrationsDir = "migrations"
+
+	// The table goose records applied migrations in
+	gooseVersionTable = "goose_db_version"
+
+	// Upgrade to the newest migration
+	LatestMigration int64 = math.MaxInt64
+)
+
+// Parse a migration file name such as 20240212192712_create_db_tables.sql
+func parseMigrationName(fileName string) (int64, string, bool) {
+	if path.Ext(fileName) != ".sql" {
+		return 0, "", false
+	}
+	versionStr, name, found := strings.Cut(strings.TrimSuffix(fileName, ".sql"), "_")
+	if !found {
+		return 0, "", false
+	}
+	version, err := strconv.ParseInt(versionStr, 10, 64)
+	if err != nil || version < 1 {
+		return 0, "", false
+	}
+	return version, name, true
+}
+
+// The versions goose has applied to the database. A database without the
+// goose table has had nothing applied; it is not created here so that
+// checking a database never modifies it.
+func appliedMigrations(sqldb *sql.DB) (map[int64]bool, error) {
+	applied := map[int64]bool{}
+	var count int
+	if err := sqldb.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", gooseVersionTable).Scan(&count); err != nil {
+		return nil, errors.Wrap(err, "failed to look up the migration history")
+	}
+	if count == 0 {
+		return applied, nil
+	}
+	rows, err := sqldb.Query("SELECT version_id, is_applied FROM " + gooseVersionTable + " ORDER BY id ASC")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the migration history")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int64
+		var isApplied bool
+		if err := rows.Scan(&version, &isApplied); err != nil {
+			return nil, errors.Wrap(err, "failed to read the migration history")
+		}
+		// Later rows record rollbacks of earlier ones
+		applied[version] = isApplied
+	}
+	return applied, rows.Err()
+}
+
+// List the embedded migrations in version order along with whether each has
+// been applied to the database
+func GetMigrationStatus(sqldb *sql.DB, migrationFS fs.FS) ([]MigrationStatus, error) {
+	entries, err := fs.ReadDir(migrationFS, migrationsDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the embedded migrations")
+	}
+	applied, err := appliedMigrations(sqldb)
+	if err != nil {
+		return nil, err
+	}
+	statuses := []MigrationStatus{}
+	for _, entry := range entries {
+		version, name, ok := parseMigrationName(entry.Name())
+		if !ok {
+			continue
+		}
+		statuses = append(statuses, MigrationStatus{Version: version, Name: name, Applied: applied[version]})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// The newest applied migration, or 0 if none has been applied
+func CurrentMigrationVersion(statuses []MigrationStatus) int64 {
+	current := int64(0)
+	for _, status := range statuses {
+		if status.Applied && status.Version > current {
+			current = status.Version
+		}
+	}
+	return current
+}
+
+// Work out which migrations bring the database to the target version: the
+// pending migrations up to the target, in the order they'll be applied, and
+// the applied migrations after the target, in the order they'll be rolled back
+func PlanMigrations(statuses []MigrationStatus, target int64) (apply, rollback []MigrationStatus) {
+	for _, status := range statuses {
+		if !status.Applied && status.Version <= target {
+			apply = append(apply, status)
+		}
+	}
+	for idx := len(statuses) - 1; idx >= 0; idx-- {
+		if statuses[idx].Applied && statuses[idx].Version > target {
+			rollback = append(rollback, statuses[idx])
+		}
+	}
+	return
+}
+
+// Copy the database next to itself with a timestamped name so a failed migration
+// can be undone by restoring the copy. Returns the path of the backup.
+func BackupSQLiteDB(sqldb *sql.DB) (string, error) {
+	var dbFile string
+	if err := sqldb.QueryRow("SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&dbFile); err != nil {
+		return "", errors.Wrap(err, "failed to locate the database file")
+	}
+	if dbFile == "" {
+		return "", errors.New("an in-memory database can't be backed up")
+	}
+	backupFile := fmt.Sprintf("%s.%s.bak", dbFile, time.Now().UTC().Format("20060102T150405Z"))
+	if _, err := os.Stat(backupFile); err == nil {
+		return "", errors.Errorf("backup file %s already exists", backupFile)
+	}
+	if _, err := sqldb.Exec("VACUUM INTO ?", backupFile); err != nil {
+		return "", errors.Wrapf(err, "failed to back up the database to %s", backupFile)
+	}
+	return backupFile, nil
+}
+
+// Apply or roll back migrations until the newest applied migration is the target
+// version. Pass LatestMigration to apply every pending migration.
+func MigrateDBTo(sqldb *sql.DB, migrationFS fs.FS, target int64) error {
+	goose.SetBaseFS(migrationFS)
+	defer goose.SetBaseFS(nil)
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		return err
+	}
+
+	statuses, err := GetMigrationStatus(sqldb, migrationFS)
+	if err != nil {
+		return err
+	}
+	if target < CurrentMigrationVersion(statuses) {
+		return goose.DownTo(sqldb, migrationsDir, target)
+	}
+	return goose.UpTo(sqldb, migrationsDir, target)
+}
+
+// Back up the database before applying migrations to a database that already
+// holds data, so that a migration gone wrong never costs the only copy
+func backupBeforeMigrating(sqldb *sql.DB, migrationFS fs.FS) error {
+	statuses, err := GetMigrationStatus(sqldb, migrationFS)
+	if err != nil {
+		return err
+	}
+	apply, _ := PlanMigrations(statuses, LatestMigration)
+	if len(apply) == 0 || CurrentMigrationVersion(statuses) == 0 {
+		return nil
+	}
+	backupFile, err := BackupSQLiteDB(sqldb)
+	if err != nil {
+		return errors.Wrap(err, "refusing to migrate the database without a backup")
+	}
+	log.Infof("Backed up the database to %s before applying %d migrations", backupFile, len(apply))
+	return nil
+}
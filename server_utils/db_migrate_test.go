@@ -0,0 +1,99 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_utils
+
+import (
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMigrations = fstest.MapFS{
+	"migrations/1_create_widget.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE widget (id INTEGER PRIMARY KEY);
+-- +goose Down
+DROP TABLE widget;
+`)},
+	"migrations/2_add_widget_name.sql": {Data: []byte(`-- +goose Up
+ALTER TABLE widget ADD name TEXT NOT NULL DEFAULT '';
+-- +goose Down
+ALTER TABLE widget DROP name;
+`)},
+	"migrations/README.md": {Data: []byte("Not a migration")},
+}
+
+func TestPlanMigrations(t *testing.T) {
+	statuses := []MigrationStatus{
+		{Version: 1, Name: "a", Applied: true},
+		{Version: 2, Name: "b", Applied: true},
+		{Version: 3, Name: "c"},
+		{Version: 4, Name: "d"},
+	}
+	assert.Equal(t, int64(2), CurrentMigrationVersion(statuses))
+
+	apply, rollback := PlanMigrations(statuses, LatestMigration)
+	assert.Equal(t, []MigrationStatus{statuses[2], statuses[3]}, apply)
+	assert.Empty(t, rollback)
+
+	apply, rollback = PlanMigrations(statuses, 3)
+	assert.Equal(t, []MigrationStatus{statuses[2]}, apply)
+	assert.Empty(t, rollback)
+
+	apply, rollback = PlanMigrations(statuses, 0)
+	assert.Empty(t, apply)
+	assert.Equal(t, []MigrationStatus{statuses[1], statuses[0]}, rollback)
+}
+
+func TestMigrateDBTo(t *testing.T) {
+	gormDB, err := InitSQLiteDB(filepath.Join(t.TempDir(), "test.sqlite"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ShutdownDB(gormDB) })
+	sqldb, err := gormDB.DB()
+	require.NoError(t, err)
+
+	// Checking a new database must not create the goose table
+	statuses, err := GetMigrationStatus(sqldb, testMigrations)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, MigrationStatus{Version: 1, Name: "create_widget"}, statuses[0])
+	assert.Equal(t, int64(0), CurrentMigrationVersion(statuses))
+	assert.False(t, gormDB.Migrator().HasTable(gooseVersionTable))
+
+	require.NoError(t, MigrateDBTo(sqldb, testMigrations, 1))
+	statuses, err = GetMigrationStatus(sqldb, testMigrations)
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+
+	require.NoError(t, MigrateDBTo(sqldb, testMigrations, LatestMigration))
+	assert.True(t, gormDB.Migrator().HasColumn("widget", "name"))
+
+	backupFile, err := BackupSQLiteDB(sqldb)
+	require.NoError(t, err)
+	assert.FileExists(t, backupFile)
+
+	require.NoError(t, MigrateDBTo(sqldb, testMigrations, 0))
+	statuses, err = GetMigrationStatus(sqldb, testMigrations)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), CurrentMigrationVersion(statuses))
+	assert.False(t, gormDB.Migrator().HasTable("widget"))
+}
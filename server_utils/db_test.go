@@ -0,0 +1,62 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_utils
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+)
+
+func TestLaunchDBHealthCheck(t *testing.T) {
+	oldInterval := dbHealthCheckInterval
+	dbHealthCheckInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		dbHealthCheckInterval = oldInterval
+		metrics.DeleteComponentHealthStatus(metrics.Origin_Database)
+	})
+
+	db, err := InitSQLiteDB(filepath.Join(t.TempDir(), "test.sqlite"))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	egrp := &errgroup.Group{}
+	defer func() {
+		cancel()
+		require.NoError(t, egrp.Wait())
+	}()
+
+	LaunchDBHealthCheck(ctx, egrp, db, metrics.Origin_Database)
+	status, err := metrics.GetComponentStatus(metrics.Origin_Database)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.StatusOK.String(), status)
+
+	// A closed database fails the next check
+	require.NoError(t, ShutdownDB(db))
+	assert.Eventually(t, func() bool {
+		status, err := metrics.GetComponentStatus(metrics.Origin_Database)
+		return err == nil && status == metrics.StatusCritical.String()
+	}, 5*time.Second, 10*time.Millisecond)
+}
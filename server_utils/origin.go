@@ -25,6 +25,7 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -60,6 +61,12 @@ type (
 		// Capabilities for the export
 		Capabilities     server_structs.Capabilities `json:"capabilities"`
 		SentinelLocation string                      `json:"sentinelLocation"`
+
+		// The number of cache replicas desired for the export in each cache region
+		ReplicaTargets map[string]int `json:"replicaTargets,omitempty"`
+
+		// When set, the export is only public during the embargo's window
+		Embargo *ExportEmbargo `json:"embargo,omitempty"`
 	}
 )
 
@@ -70,6 +77,36 @@ var (
 		"For finer-grained control of each export, please configure them in your pelican.yaml file via Origin.Exports"
 )
 
+// Decode the Origin.Exports block, where capabilities are given as a list of
+// strings and embargo times as RFC 3339 timestamps
+func unmarshalOriginExports(exports *[]OriginExport) error {
+	hooks := mapstructure.ComposeDecodeHookFunc(StringListToCapsHookFunc(), mapstructure.StringToTimeHookFunc(time.RFC3339))
+	if err := viper.UnmarshalKey("Origin.Exports", exports, viper.DecodeHook(hooks)); err != nil {
+		return err
+	}
+	for _, export := range *exports {
+		if export.Embargo == nil {
+			continue
+		}
+		if err := export.Embargo.validate(); err != nil {
+			return errors.Wrapf(err, "invalid embargo for export %s", export.FederationPrefix)
+		}
+	}
+	return nil
+}
+
+// Add the experimental capabilities from Origin.ExperimentalCapabilities to caps
+func SetExperimentalCapabilities(caps *server_structs.Capabilities) error {
+	for _, extension := range param.Origin_ExperimentalCapabilities.GetStringSlice() {
+		name, value, err := server_structs.ParseExtension(extension)
+		if err != nil {
+			return errors.Wrap(err, "invalid value for Origin.ExperimentalCapabilities")
+		}
+		caps.SetExtension(name, value)
+	}
+	return nil
+}
+
 /*
 A decoder hook we can pass to viper.Unmarshal to convert a list of strings to a struct
 with boolean fields. In this case, we're converting a string slice (flow) from yaml:
@@ -128,7 +165,16 @@ func StringListToCapsHookFunc() mapstructure.DecodeHookFuncType {
 			case "Reads":
 				exportCaps.Reads = true
 			default:
-				return nil, errors.Errorf("Unknown capability %v", cap)
+				// Experimental capabilities are given as Experimental.<name>[=<value>]
+				extension, ok := strings.CutPrefix(cap, "Experimental.")
+				if !ok {
+					return nil, errors.Errorf("Unknown capability %v", cap)
+				}
+				name, value, err := server_structs.ParseExtension(extension)
+				if err != nil {
+					return nil, err
+				}
+				exportCaps.SetExtension(name, value)
 			}
 		}
 
@@ -136,6 +182,76 @@ func StringListToCapsHookFunc() mapstructure.DecodeHookFuncType {
 	}
 }
 
+// Convert capabilities to the list of strings Origin.Exports uses for them, the
+// inverse of StringListToCapsHookFunc
+func CapsToStringList(caps server_structs.Capabilities) []string {
+	result := []string{}
+	if caps.PublicReads {
+		result = append(result, "PublicReads")
+	} else if caps.Reads {
+		result = append(result, "Reads")
+	}
+	if caps.Writes {
+		result = append(result, "Writes")
+	}
+	if caps.Listings {
+		result = append(result, "Listings")
+	}
+	if caps.DirectReads {
+		result = append(result, "DirectReads")
+	}
+	names := make([]string, 0, len(caps.Extensions))
+	for name := range caps.Extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result = append(result, "Experimental."+name+"="+caps.Extensions[name])
+	}
+	return result
+}
+
+// Check a list of exports the way GetOriginExports would check an Origin.Exports
+// block for the given storage type
+func ValidateOriginExports(storageType server_structs.OriginStorageType, exports []OriginExport) error {
+	if len(exports) == 0 {
+		return errors.Wrap(ErrInvalidOriginConfig, "at least one export is required")
+	}
+	if storageType == server_structs.OriginStorageHTTPS && len(exports) > 1 {
+		return errors.Wrap(ErrInvalidOriginConfig, "only one export is currently supported for the https backend")
+	}
+	seen := make(map[string]bool, len(exports))
+	for _, export := range exports {
+		switch storageType {
+		case server_structs.OriginStoragePosix, server_structs.OriginStorageHTTPS:
+			if err := validateExportPaths(export.StoragePrefix, export.FederationPrefix); err != nil {
+				return err
+			}
+		case server_structs.OriginStorageS3:
+			if err := validateFederationPrefix(export.FederationPrefix); err != nil {
+				return errors.Wrapf(err, "invalid federation prefix %s", export.FederationPrefix)
+			}
+			if err := validateBucketName(export.S3Bucket); err != nil {
+				return errors.Wrapf(err, "invalid bucket name for export %s", export.FederationPrefix)
+			}
+		default:
+			if err := validateFederationPrefix(export.FederationPrefix); err != nil {
+				return errors.Wrapf(err, "invalid federation prefix %s", export.FederationPrefix)
+			}
+		}
+		if seen[export.FederationPrefix] {
+			return errors.Wrapf(ErrInvalidOriginConfig, "the federation prefix %s is exported more than once", export.FederationPrefix)
+		}
+		seen[export.FederationPrefix] = true
+		if export.Embargo != nil {
+			if err := export.Embargo.validate(); err != nil {
+				return errors.Wrapf(err, "invalid embargo for export %s", export.FederationPrefix)
+			}
+		}
+	}
+	return nil
+}
+
 func validateExportPaths(storagePrefix string, federationPrefix string) error {
 	if storagePrefix == "" || federationPrefix == "" {
 		return errors.Wrap(ErrInvalidOriginConfig, "volume mount/ExportVolume paths cannot be empty")
@@ -271,6 +387,9 @@ func GetOriginExports() ([]OriginExport, error) {
 		Reads:       param.Origin_EnableReads.GetBool() || param.Origin_EnablePublicReads.GetBool(),
 		DirectReads: param.Origin_EnableDirectReads.GetBool(),
 	}
+	if err = SetExperimentalCapabilities(&capabilities); err != nil {
+		return originExports, err
+	}
 
 	var originExport OriginExport
 	switch storageType {
@@ -322,7 +441,7 @@ func GetOriginExports() ([]OriginExport, error) {
 		if param.Origin_Exports.IsSet() {
 			log.Infoln("Configuring multi-exports from Origin.Exports block in config file")
 			var tmpExports []OriginExport
-			if err := viper.UnmarshalKey("Origin.Exports", &tmpExports, viper.DecodeHook(StringListToCapsHookFunc())); err != nil {
+			if err := unmarshalOriginExports(&tmpExports); err != nil {
 				return nil, err
 			}
 			if len(tmpExports) == 0 {
@@ -414,7 +533,7 @@ func GetOriginExports() ([]OriginExport, error) {
 
 		if param.Origin_Exports.IsSet() {
 			var tmpExports []OriginExport
-			if err := viper.UnmarshalKey("Origin.Exports", &tmpExports, viper.DecodeHook(StringListToCapsHookFunc())); err != nil {
+			if err := unmarshalOriginExports(&tmpExports); err != nil {
 				return nil, err
 			}
 			if len(tmpExports) == 0 {
@@ -522,7 +641,7 @@ from S3 service URL. In this configuration, objects can be accessed at /federati
 		if param.Origin_Exports.IsSet() {
 			log.Infoln("Configuring multiple S3 exports from Origin.Exports block in config file")
 			var tmpExports []OriginExport
-			if err := viper.UnmarshalKey("Origin.Exports", &tmpExports, viper.DecodeHook(StringListToCapsHookFunc())); err != nil {
+			if err := unmarshalOriginExports(&tmpExports); err != nil {
 				return nil, errors.Wrap(err, "unable to parse the Origin.Exports configuration")
 			}
 			if len(tmpExports) == 0 {
@@ -582,7 +701,7 @@ from S3 service URL. In this configuration, objects can be accessed at /federati
 		if param.Origin_Exports.IsSet() {
 			log.Infoln("Configuring multiple Globus exports from Origin.Exports block in config file")
 			var tmpExports []OriginExport
-			if err := viper.UnmarshalKey("Origin.Exports", &tmpExports, viper.DecodeHook(StringListToCapsHookFunc())); err != nil {
+			if err := unmarshalOriginExports(&tmpExports); err != nil {
 				return nil, errors.Wrap(err, "unable to parse the Origin.Exports configuration")
 			}
 			if len(tmpExports) == 0 {
@@ -690,7 +809,7 @@ from S3 service URL. In this configuration, objects can be accessed at /federati
 		if param.Origin_Exports.IsSet() {
 			log.Infoln("Configuring multi-exports from Origin.Exports block in config file")
 			var tmpExports []OriginExport
-			if err := viper.UnmarshalKey("Origin.Exports", &tmpExports, viper.DecodeHook(StringListToCapsHookFunc())); err != nil {
+			if err := unmarshalOriginExports(&tmpExports); err != nil {
 				return nil, err
 			}
 			if len(tmpExports) == 0 {
@@ -714,6 +833,10 @@ from S3 service URL. In this configuration, objects can be accessed at /federati
 					return nil, errors.Wrapf(ErrInvalidOriginConfig, "all exports from an xroot backend must have the PublicReads capability, but the export with FederationPrefix "+
 						"'%s' did not", export.FederationPrefix)
 				}
+				if export.Embargo != nil {
+					return nil, errors.Wrapf(ErrInvalidOriginConfig, "exports from an xroot backend are always public and can't be embargoed, but the export with FederationPrefix "+
+						"'%s' was", export.FederationPrefix)
+				}
 				// Paths must be the same for the XRoot backend
 				if export.StoragePrefix != export.FederationPrefix {
 					return nil, errors.Wrapf(ErrInvalidOriginConfig, "federation and storage prefixes must be the same for xroot backends, but you "+
@@ -0,0 +1,85 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_utils
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// An embargo on an export: the export is publicly readable only from PublicAfter
+// (if set) until PublicUntil (if set), and requires authorization otherwise.  This
+// lets an origin release a dataset at a set time, or make it public for a limited
+// window, without anyone flipping its capabilities by hand.
+type ExportEmbargo struct {
+	PublicAfter time.Time `json:"publicAfter,omitempty"`
+	PublicUntil time.Time `json:"publicUntil,omitempty"`
+}
+
+func (embargo *ExportEmbargo) validate() error {
+	if embargo.PublicAfter.IsZero() && embargo.PublicUntil.IsZero() {
+		return errors.New("the embargo must set PublicAfter, PublicUntil, or both")
+	}
+	if !embargo.PublicAfter.IsZero() && !embargo.PublicUntil.IsZero() && !embargo.PublicUntil.After(embargo.PublicAfter) {
+		return errors.Errorf("the embargo's PublicUntil (%s) must be after its PublicAfter (%s)",
+			embargo.PublicUntil.Format(time.RFC3339), embargo.PublicAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Whether the embargo permits public reads at the given time
+func (embargo *ExportEmbargo) publicAt(now time.Time) bool {
+	if !embargo.PublicAfter.IsZero() && now.Before(embargo.PublicAfter) {
+		return false
+	}
+	if !embargo.PublicUntil.IsZero() && !now.Before(embargo.PublicUntil) {
+		return false
+	}
+	return true
+}
+
+// The export's capabilities at the given time.  Without an embargo, these are the
+// configured capabilities; with one, the embargo decides whether the export is public.
+func (export *OriginExport) CapabilitiesAt(now time.Time) server_structs.Capabilities {
+	caps := export.Capabilities
+	if export.Embargo != nil {
+		caps.PublicReads = export.Embargo.publicAt(now)
+	}
+	return caps
+}
+
+// The first time after now at which an embargo makes one of the exports public
+// or stops it from being public; returns false if no such time remains
+func NextEmbargoBoundary(exports []OriginExport, now time.Time) (next time.Time, ok bool) {
+	for _, export := range exports {
+		if export.Embargo == nil {
+			continue
+		}
+		for _, boundary := range []time.Time{export.Embargo.PublicAfter, export.Embargo.PublicUntil} {
+			if boundary.After(now) && (!ok || boundary.Before(next)) {
+				next = boundary
+				ok = true
+			}
+		}
+	}
+	return
+}
@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -450,3 +451,132 @@ func TestFederationPrefixValidation(t *testing.T) {
 	runFedPrefixTest(t, "/caches/example.org", false)
 	runFedPrefixTest(t, "/valid/prefix", true) // Test valid prefix
 }
+
+func TestExperimentalCapabilities(t *testing.T) {
+	ResetTestState()
+
+	t.Run("export-capabilities", func(t *testing.T) {
+		defer ResetTestState()
+		exports := setup(t, `
+Origin:
+  StorageType: "posix"
+  Exports:
+    - StoragePrefix: /test1
+      FederationPrefix: /first/namespace
+      Capabilities: ["Reads", "Experimental.TPC", "Experimental.zip-extraction=v1"]
+`)
+		require.Len(t, exports, 1)
+		assert.True(t, exports[0].Capabilities.Reads)
+		assert.Equal(t, map[string]string{"tpc": "true", "zip-extraction": "v1"}, exports[0].Capabilities.Extensions)
+	})
+
+	t.Run("inherited-by-export-volumes", func(t *testing.T) {
+		defer ResetTestState()
+		exports := setup(t, `
+Origin:
+  StorageType: "posix"
+  ExperimentalCapabilities: ["tpc=false", "zip-extraction"]
+  ExportVolumes:
+    - "/test1:/first/namespace"
+`)
+		require.Len(t, exports, 1)
+		assert.False(t, exports[0].Capabilities.HasExtension("tpc"))
+		assert.True(t, exports[0].Capabilities.HasExtension("zip-extraction"))
+	})
+
+	t.Run("invalid-capability", func(t *testing.T) {
+		defer ResetTestState()
+		viper.SetConfigType("yaml")
+		require.NoError(t, viper.ReadConfig(strings.NewReader(`
+Origin:
+  StorageType: "posix"
+  Exports:
+    - StoragePrefix: /test1
+      FederationPrefix: /first/namespace
+      Capabilities: ["Experimental.=v1"]
+`)))
+		_, err := GetOriginExports()
+		assert.Error(t, err)
+	})
+}
+
+func TestExportEmbargo(t *testing.T) {
+	ResetTestState()
+
+	t.Run("parse-and-apply", func(t *testing.T) {
+		defer ResetTestState()
+		exports := setup(t, `
+Origin:
+  StorageType: "posix"
+  Exports:
+    - StoragePrefix: /test1
+      FederationPrefix: /first/namespace
+      Capabilities: ["Reads"]
+      Embargo:
+        PublicAfter: "2030-01-01T00:00:00Z"
+    - StoragePrefix: /test2
+      FederationPrefix: /second/namespace
+      Capabilities: ["Reads"]
+      Embargo:
+        PublicAfter: 2030-02-01T00:00:00Z
+        PublicUntil: "2030-03-01T00:00:00Z"
+    - StoragePrefix: /test3
+      FederationPrefix: /third/namespace
+      Capabilities: ["PublicReads"]
+`)
+		require.Len(t, exports, 3)
+		releaseTime := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		windowStart := time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC)
+		windowEnd := time.Date(2030, 3, 1, 0, 0, 0, 0, time.UTC)
+		require.NotNil(t, exports[0].Embargo)
+		assert.True(t, releaseTime.Equal(exports[0].Embargo.PublicAfter))
+		require.NotNil(t, exports[1].Embargo)
+		assert.True(t, windowStart.Equal(exports[1].Embargo.PublicAfter))
+		assert.True(t, windowEnd.Equal(exports[1].Embargo.PublicUntil))
+		assert.Nil(t, exports[2].Embargo)
+
+		before := releaseTime.Add(-time.Second)
+		assert.False(t, exports[0].CapabilitiesAt(before).PublicReads)
+		assert.True(t, exports[0].CapabilitiesAt(before).Reads)
+		assert.True(t, exports[0].CapabilitiesAt(releaseTime).PublicReads)
+		assert.False(t, exports[1].CapabilitiesAt(releaseTime).PublicReads)
+		assert.True(t, exports[1].CapabilitiesAt(windowStart.Add(time.Hour)).PublicReads)
+		assert.False(t, exports[1].CapabilitiesAt(windowEnd).PublicReads)
+		assert.True(t, exports[2].CapabilitiesAt(before).PublicReads)
+
+		next, ok := NextEmbargoBoundary(exports, before)
+		assert.True(t, ok)
+		assert.True(t, releaseTime.Equal(next))
+		next, ok = NextEmbargoBoundary(exports, releaseTime)
+		assert.True(t, ok)
+		assert.True(t, windowStart.Equal(next))
+		next, ok = NextEmbargoBoundary(exports, windowStart)
+		assert.True(t, ok)
+		assert.True(t, windowEnd.Equal(next))
+		_, ok = NextEmbargoBoundary(exports, windowEnd)
+		assert.False(t, ok)
+	})
+
+	t.Run("invalid-embargo", func(t *testing.T) {
+		for _, embargo := range []string{
+			`{}`,
+			`{PublicAfter: "2030-03-01T00:00:00Z", PublicUntil: "2030-02-01T00:00:00Z"}`,
+			`{PublicAfter: "next tuesday"}`,
+		} {
+			ResetTestState()
+			viper.SetConfigType("yaml")
+			require.NoError(t, viper.ReadConfig(strings.NewReader(`
+Origin:
+  StorageType: "posix"
+  Exports:
+    - StoragePrefix: /test1
+      FederationPrefix: /first/namespace
+      Capabilities: ["Reads"]
+      Embargo: `+embargo+`
+`)))
+			_, err := GetOriginExports()
+			assert.Error(t, err, embargo)
+		}
+		ResetTestState()
+	})
+}
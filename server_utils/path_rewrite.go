@@ -0,0 +1,164 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_utils
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// A rule rewriting federation paths, as written in Origin.PathRewrites
+	PathRewriteRule struct {
+		Match   string `mapstructure:"Match" yaml:"Match" json:"match"`
+		Replace string `mapstructure:"Replace" yaml:"Replace" json:"replace"`
+		Last    bool   `mapstructure:"Last" yaml:"Last" json:"last,omitempty"`
+	}
+
+	// A rule that changed a path while it was rewritten
+	PathRewriteStep struct {
+		// The position of the rule in the list, starting from 0
+		Rule   int    `json:"rule"`
+		Match  string `json:"match"`
+		Before string `json:"before"`
+		After  string `json:"after"`
+	}
+
+	// The outcome of rewriting a federation path
+	PathRewriteResult struct {
+		FederationPath string            `json:"federationPath"`
+		RewrittenPath  string            `json:"rewrittenPath"`
+		Steps          []PathRewriteStep `json:"steps"`
+		// The export the rewritten path falls in and the object's location within its storage
+		Export      *OriginExport `json:"export,omitempty"`
+		StoragePath string        `json:"storagePath,omitempty"`
+	}
+
+	pathRewriteRule struct {
+		PathRewriteRule
+		re *regexp.Regexp
+	}
+
+	// An ordered list of compiled path rewrite rules
+	PathRewriter struct {
+		rules []pathRewriteRule
+	}
+)
+
+func NewPathRewriter(rules []PathRewriteRule) (*PathRewriter, error) {
+	rewriter := &PathRewriter{rules: make([]pathRewriteRule, 0, len(rules))}
+	for idx, rule := range rules {
+		if rule.Match == "" {
+			return nil, errors.Errorf("path rewrite rule %d has no Match expression", idx)
+		}
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid Match expression in path rewrite rule %d", idx)
+		}
+		rewriter.rules = append(rewriter.rules, pathRewriteRule{PathRewriteRule: rule, re: re})
+	}
+	return rewriter, nil
+}
+
+// Compile the rules in Origin.PathRewrites
+func GetOriginPathRewriter() (*PathRewriter, error) {
+	var rules []PathRewriteRule
+	if err := param.Origin_PathRewrites.Unmarshal(&rules); err != nil {
+		return nil, errors.Wrap(err, "failed to parse Origin.PathRewrites")
+	}
+	rewriter, err := NewPathRewriter(rules)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Origin.PathRewrites")
+	}
+	return rewriter, nil
+}
+
+// Whether there are any rules; a nil rewriter has none
+func (rewriter *PathRewriter) Enabled() bool {
+	return rewriter != nil && len(rewriter.rules) > 0
+}
+
+// Apply the rules in order to a federation path, returning the rewritten
+// path and the rules that changed it
+func (rewriter *PathRewriter) Rewrite(fedPath string) (string, []PathRewriteStep) {
+	current := path.Clean("/" + fedPath)
+	steps := []PathRewriteStep{}
+	if rewriter == nil {
+		return current, steps
+	}
+	for idx, rule := range rewriter.rules {
+		if !rule.re.MatchString(current) {
+			continue
+		}
+		next := path.Clean("/" + rule.re.ReplaceAllString(current, rule.Replace))
+		if next != current {
+			steps = append(steps, PathRewriteStep{Rule: idx, Match: rule.Match, Before: current, After: next})
+			current = next
+		}
+		if rule.Last {
+			break
+		}
+	}
+	return current, steps
+}
+
+// The export with the longest federation prefix containing objPath
+func findExport(objPath string, exports []OriginExport) *OriginExport {
+	var match *OriginExport
+	for idx := range exports {
+		prefix := path.Clean(exports[idx].FederationPrefix)
+		if objPath != prefix && !strings.HasPrefix(objPath, strings.TrimSuffix(prefix, "/")+"/") {
+			continue
+		}
+		if match == nil || len(prefix) > len(path.Clean(match.FederationPrefix)) {
+			match = &exports[idx]
+		}
+	}
+	return match
+}
+
+// Rewrite a federation path and locate the result in the origin's exports.  Paths
+// rewritten out of the exports are an error, as the origin has nothing to serve there.
+func (rewriter *PathRewriter) Resolve(fedPath string, exports []OriginExport) (PathRewriteResult, error) {
+	rewritten, steps := rewriter.Rewrite(fedPath)
+	result := PathRewriteResult{
+		FederationPath: path.Clean("/" + fedPath),
+		RewrittenPath:  rewritten,
+		Steps:          steps,
+	}
+	export := findExport(rewritten, exports)
+	if export == nil {
+		return result, errors.Errorf("%s is rewritten to %s, which is not in any of the origin's exports", result.FederationPath, rewritten)
+	}
+	result.Export = export
+	relPath := strings.TrimPrefix(strings.TrimPrefix(rewritten, path.Clean(export.FederationPrefix)), "/")
+	if param.Origin_StorageType.GetString() == string(server_structs.OriginStoragePosix) {
+		result.StoragePath = filepath.Join(export.StoragePrefix, filepath.FromSlash(relPath))
+	} else {
+		result.StoragePath = path.Join(export.StoragePrefix, relPath)
+	}
+	return result, nil
+}
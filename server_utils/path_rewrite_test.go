@@ -0,0 +1,110 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_utils
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathRewriter(t *testing.T) {
+	rewriter, err := NewPathRewriter([]PathRewriteRule{
+		{Match: `^/foo/data/2023/run-([0-9]+)/`, Replace: "/foo/runs/$1/"},
+		{Match: `^/foo/runs/(?P<run>[0-9]+)/raw/`, Replace: "/foo/raw/${run}/", Last: true},
+		{Match: `^/foo/`, Replace: "/bar/"},
+	})
+	require.NoError(t, err)
+	assert.True(t, rewriter.Enabled())
+
+	t.Run("rules-apply-in-order", func(t *testing.T) {
+		rewritten, steps := rewriter.Rewrite("/foo/data/2023/run-12/raw/a.root")
+		assert.Equal(t, "/foo/raw/12/a.root", rewritten)
+		require.Len(t, steps, 2)
+		assert.Equal(t, 0, steps[0].Rule)
+		assert.Equal(t, "/foo/runs/12/raw/a.root", steps[0].After)
+		// The second rule is the last, so the third is never evaluated
+		assert.Equal(t, 1, steps[1].Rule)
+	})
+
+	t.Run("unmatched-rule-does-not-stop", func(t *testing.T) {
+		rewritten, steps := rewriter.Rewrite("foo/data/2023/run-12/../run-13/b.root")
+		assert.Equal(t, "/bar/runs/13/b.root", rewritten)
+		assert.Len(t, steps, 2)
+	})
+
+	t.Run("no-match", func(t *testing.T) {
+		rewritten, steps := rewriter.Rewrite("/baz/c.root")
+		assert.Equal(t, "/baz/c.root", rewritten)
+		assert.Empty(t, steps)
+	})
+
+	t.Run("nil-rewriter", func(t *testing.T) {
+		var none *PathRewriter
+		assert.False(t, none.Enabled())
+		rewritten, steps := none.Rewrite("/foo/a")
+		assert.Equal(t, "/foo/a", rewritten)
+		assert.Empty(t, steps)
+	})
+
+	t.Run("invalid-rules", func(t *testing.T) {
+		_, err := NewPathRewriter([]PathRewriteRule{{Match: "^/foo/(", Replace: "/bar"}})
+		assert.Error(t, err)
+		_, err = NewPathRewriter([]PathRewriteRule{{Replace: "/bar"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestPathRewriterResolve(t *testing.T) {
+	ResetTestState()
+	t.Cleanup(ResetTestState)
+	viper.Set("Origin.StorageType", "posix")
+
+	exports := []OriginExport{
+		{FederationPrefix: "/foo", StoragePrefix: "/mnt/foo"},
+		{FederationPrefix: "/foo/archive", StoragePrefix: "/mnt/archive"},
+	}
+	rewriter, err := NewPathRewriter([]PathRewriteRule{
+		{Match: `^/foo/old/(.*)$`, Replace: "/foo/archive/$1"},
+		{Match: `^/foo/escape/`, Replace: "/elsewhere/"},
+	})
+	require.NoError(t, err)
+
+	result, err := rewriter.Resolve("/foo/old/a/b.txt", exports)
+	require.NoError(t, err)
+	assert.Equal(t, "/foo/archive/a/b.txt", result.RewrittenPath)
+	require.NotNil(t, result.Export)
+	assert.Equal(t, "/foo/archive", result.Export.FederationPrefix)
+	assert.Equal(t, "/mnt/archive/a/b.txt", result.StoragePath)
+
+	result, err = rewriter.Resolve("/foo/c.txt", exports)
+	require.NoError(t, err)
+	assert.Equal(t, "/mnt/foo/c.txt", result.StoragePath)
+
+	_, err = rewriter.Resolve("/foo/escape/d.txt", exports)
+	assert.Error(t, err)
+
+	viper.Set("Origin.PathRewrites", []map[string]any{{"Match": "^/foo/old/", "Replace": "/foo/new/", "Last": true}})
+	configured, err := GetOriginPathRewriter()
+	require.NoError(t, err)
+	rewritten, _ := configured.Rewrite("/foo/old/e.txt")
+	assert.Equal(t, "/foo/new/e.txt", rewritten)
+}
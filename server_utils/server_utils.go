@@ -279,6 +279,7 @@ func LaunchWatcherMaintenance(ctx context.Context, dirPaths []string, descriptio
 func ResetTestState() {
 	config.ResetConfig()
 	ResetOriginExports()
+	metrics.ResetProbes()
 }
 
 // Given a slice of NamespaceAdV2 objects, return a slice of unique top-level prefixes.
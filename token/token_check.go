@@ -0,0 +1,170 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package token
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+type (
+	// What to check a token against when debugging it
+	TokenCheckOptions struct {
+		// The expected issuer; the token's own issuer is trusted if empty
+		Issuer string
+		// The issuer's public keys; they're looked up from the issuer if nil
+		Keys jwk.Set
+		// The token must be intended for one of these audiences, if any are given
+		Audiences []string
+		// Scopes the token must grant, e.g. storage.read:/foo/bar
+		Scopes []string
+		// The profile whose required claims the token must have; none skips the check
+		Profile TokenProfile
+		// The allowed clock skew when checking the token's lifetime
+		Skew time.Duration
+	}
+
+	// The decoded contents of a token and every problem found with it
+	TokenCheckResult struct {
+		Header   map[string]interface{} `json:"header"`
+		Claims   map[string]interface{} `json:"claims"`
+		Valid    bool                   `json:"valid"`
+		Problems []string               `json:"problems"`
+	}
+)
+
+// Decode a token and check its signature, lifetime, audience, scopes, and profile,
+// collecting every problem rather than stopping at the first.  An error is only
+// returned if the string isn't a JWT at all.
+func CheckToken(ctx context.Context, strToken string, opts TokenCheckOptions) (*TokenCheckResult, error) {
+	strToken = strings.TrimSpace(strToken)
+	msg, err := jws.Parse([]byte(strToken))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode the token")
+	}
+	if len(msg.Signatures()) == 0 {
+		return nil, errors.New("the token is not signed")
+	}
+	tok, err := jwt.ParseInsecure([]byte(strToken))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode the token's claims")
+	}
+
+	result := &TokenCheckResult{Problems: []string{}}
+	if result.Header, err = msg.Signatures()[0].ProtectedHeaders().AsMap(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to decode the token's header")
+	}
+	if result.Claims, err = tok.AsMap(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to decode the token's claims")
+	}
+
+	issuer := tok.Issuer()
+	if opts.Issuer != "" && opts.Issuer != issuer {
+		result.Problems = append(result.Problems, fmt.Sprintf("The token was issued by %q rather than the expected issuer %s", issuer, opts.Issuer))
+	}
+
+	// Check the signature
+	keys := opts.Keys
+	if keys == nil {
+		if issuer == "" {
+			result.Problems = append(result.Problems, "The token has no issuer to look up its signing keys from")
+		} else if fetched, err := GetJWKSFromIssUrl(issuer); err != nil {
+			result.Problems = append(result.Problems, fmt.Sprintf("Failed to get the public keys of issuer %s: %v", issuer, err))
+		} else {
+			keys = *fetched
+		}
+	}
+	if keys != nil {
+		if _, err := jws.Verify([]byte(strToken), jws.WithKeySet(keys, jws.WithInferAlgorithmFromKey(true))); err != nil {
+			result.Problems = append(result.Problems, fmt.Sprintf("The token's signature does not match the issuer's keys: %v", err))
+		}
+	}
+
+	// Check the lifetime
+	if err := jwt.Validate(tok, jwt.WithAcceptableSkew(opts.Skew)); err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("The token is not currently valid: %v", err))
+	}
+
+	if len(opts.Audiences) > 0 {
+		matched := slices.ContainsFunc(tok.Audience(), func(aud string) bool {
+			return aud == wlcgAny || aud == scitokensAny || slices.Contains(opts.Audiences, aud)
+		})
+		if !matched {
+			result.Problems = append(result.Problems, fmt.Sprintf("The token's audience %v does not include %s", tok.Audience(), strings.Join(opts.Audiences, " or ")))
+		}
+	}
+
+	granted := token_scopes.ParseResourceScopeString(tok)
+	for _, scope := range opts.Scopes {
+		authz, resource, _ := strings.Cut(scope, ":")
+		wanted := token_scopes.NewResourceScope(token_scopes.TokenScope(authz), resource)
+		if !slices.ContainsFunc(granted, func(g token_scopes.ResourceScope) bool { return g.Contains(wanted) }) {
+			result.Problems = append(result.Problems, fmt.Sprintf("The token does not grant the scope %s", wanted.String()))
+		}
+	}
+
+	result.Problems = append(result.Problems, checkTokenProfile(tok, opts.Profile)...)
+	result.Valid = len(result.Problems) == 0
+	return result, nil
+}
+
+// Check the token has the claims its profile requires
+func checkTokenProfile(tok jwt.Token, profile TokenProfile) (problems []string) {
+	stringClaim := func(name string) string {
+		value, ok := tok.Get(name)
+		if !ok {
+			return ""
+		}
+		str, _ := value.(string)
+		return str
+	}
+	switch profile {
+	case TokenProfileWLCG:
+		if ver := stringClaim("wlcg.ver"); !wlcgVerPattern.MatchString(ver) {
+			problems = append(problems, fmt.Sprintf("The 'wlcg.ver' claim %q required by the WLCG profile must be of the form '1.x'", ver))
+		}
+		if tok.Subject() == "" {
+			problems = append(problems, "The 'sub' claim is required by the WLCG profile")
+		}
+		if len(tok.Audience()) == 0 {
+			problems = append(problems, "The 'aud' claim is required by the WLCG profile")
+		}
+	case TokenProfileScitokens2:
+		if ver := stringClaim("ver"); !scitokensVerPattern.MatchString(ver) {
+			problems = append(problems, fmt.Sprintf("The 'ver' claim %q required by the scitokens2 profile must be of the form 'scitokens:2.x'", ver))
+		}
+		if len(tok.Audience()) == 0 {
+			problems = append(problems, "The 'aud' claim is required by the scitokens2 profile")
+		}
+		if stringClaim("scope") == "" {
+			problems = append(problems, "The 'scope' claim is required by the scitokens2 profile")
+		}
+	}
+	return
+}
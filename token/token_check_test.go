@@ -0,0 +1,126 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckToken(t *testing.T) {
+	ctx := context.Background()
+	newKey := func(t *testing.T) (jwk.Key, jwk.Set) {
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		key, err := jwk.FromRaw(ecKey)
+		require.NoError(t, err)
+		require.NoError(t, key.Set(jwk.KeyIDKey, "test-key"))
+		require.NoError(t, key.Set(jwk.AlgorithmKey, jwa.ES256))
+		pubKey, err := key.PublicKey()
+		require.NoError(t, err)
+		keys := jwk.NewSet()
+		require.NoError(t, keys.AddKey(pubKey))
+		return key, keys
+	}
+	key, keys := newKey(t)
+	sign := func(t *testing.T, key jwk.Key, lifetime time.Duration, claims map[string]interface{}) string {
+		builder := jwt.NewBuilder().
+			Issuer("https://issuer.example.org").
+			IssuedAt(time.Now()).
+			Expiration(time.Now().Add(lifetime))
+		for name, value := range claims {
+			builder = builder.Claim(name, value)
+		}
+		tok, err := builder.Build()
+		require.NoError(t, err)
+		signed, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, key))
+		require.NoError(t, err)
+		return string(signed)
+	}
+	wlcgClaims := map[string]interface{}{
+		"wlcg.ver": "1.0",
+		"sub":      "alice",
+		"aud":      []string{"https://origin.example.org"},
+		"scope":    "storage.read:/foo storage.modify:/foo/bar",
+	}
+
+	t.Run("valid-token", func(t *testing.T) {
+		result, err := CheckToken(ctx, sign(t, key, time.Minute, wlcgClaims), TokenCheckOptions{
+			Issuer:    "https://issuer.example.org",
+			Keys:      keys,
+			Audiences: []string{"https://origin.example.org"},
+			Scopes:    []string{"storage.read:/foo/baz", "storage.modify:/foo/bar"},
+			Profile:   TokenProfileWLCG,
+		})
+		require.NoError(t, err)
+		assert.True(t, result.Valid, "unexpected problems: %v", result.Problems)
+		assert.Empty(t, result.Problems)
+		assert.Equal(t, "alice", result.Claims["sub"])
+		assert.Equal(t, "test-key", result.Header["kid"])
+	})
+
+	t.Run("any-audience", func(t *testing.T) {
+		claims := map[string]interface{}{"aud": []string{wlcgAny}}
+		result, err := CheckToken(ctx, sign(t, key, time.Minute, claims), TokenCheckOptions{Keys: keys, Audiences: []string{"https://origin.example.org"}})
+		require.NoError(t, err)
+		assert.True(t, result.Valid, "unexpected problems: %v", result.Problems)
+	})
+
+	t.Run("every-problem-is-reported", func(t *testing.T) {
+		_, otherKeys := newKey(t)
+		result, err := CheckToken(ctx, sign(t, key, -time.Hour, wlcgClaims), TokenCheckOptions{
+			Issuer:    "https://other.example.org",
+			Keys:      otherKeys,
+			Audiences: []string{"https://cache.example.org"},
+			Scopes:    []string{"storage.modify:/foo"},
+			Profile:   TokenProfileScitokens2,
+		})
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		// The issuer, signature, lifetime, audience, scope, and the scitokens2 'ver' claim
+		assert.Len(t, result.Problems, 6)
+	})
+
+	t.Run("expired-within-skew", func(t *testing.T) {
+		result, err := CheckToken(ctx, sign(t, key, -10*time.Second, nil), TokenCheckOptions{Keys: keys, Skew: time.Minute})
+		require.NoError(t, err)
+		assert.True(t, result.Valid, "unexpected problems: %v", result.Problems)
+	})
+
+	t.Run("profile-claims", func(t *testing.T) {
+		result, err := CheckToken(ctx, sign(t, key, time.Minute, map[string]interface{}{"wlcg.ver": "2.0"}), TokenCheckOptions{Keys: keys, Profile: TokenProfileWLCG})
+		require.NoError(t, err)
+		assert.Len(t, result.Problems, 3)
+	})
+
+	t.Run("not-a-token", func(t *testing.T) {
+		_, err := CheckToken(ctx, "not-a-token", TokenCheckOptions{Keys: keys})
+		assert.Error(t, err)
+	})
+}
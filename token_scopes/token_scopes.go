@@ -33,6 +33,7 @@ const (
 	Pelican_NamespaceDelete TokenScope = "pelican.namespace_delete"
 	WebUi_Access TokenScope = "web_ui.access"
 	Registry_EditRegistration TokenScope = "registry.edit_registration"
+	Registry_VerifyEmail TokenScope = "registry.verify_email"
 	Monitoring_Scrape TokenScope = "monitoring.scrape"
 	Monitoring_Query TokenScope = "monitoring.query"
 	Broker_Reverse TokenScope = "broker.reverse"
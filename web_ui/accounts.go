@@ -0,0 +1,236 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+import (
+	"embed"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+type (
+	// An identity at one of the providers in OIDC.Providers and the web UI account
+	// it's linked to.  An account may have identities from several providers.
+	LinkedIdentity struct {
+		ID            int    `json:"-" gorm:"primaryKey;autoIncrement"`
+		Provider      string `json:"provider" gorm:"not null;uniqueIndex:idx_provider_subject"`
+		Subject       string `json:"subject" gorm:"not null;uniqueIndex:idx_provider_subject"`
+		Account       string `json:"account" gorm:"not null;index"`
+		Username      string `json:"username"`
+		Email         string `json:"email" gorm:"index"`
+		EmailVerified bool   `json:"emailVerified"`
+		// Whether the identity was in one of the provider's admin groups at its last login
+		Admin       bool      `json:"admin"`
+		CreatedAt   time.Time `json:"createdAt"`
+		LastLoginAt time.Time `json:"lastLoginAt"`
+	}
+
+	// The identity established by logging in to a provider
+	oidcIdentity struct {
+		Subject       string
+		User          string
+		Email         string
+		EmailVerified bool
+		Groups        []string
+	}
+)
+
+var (
+	accountDB *gorm.DB
+
+	//go:embed migrations/*.sql
+	embedMigrations embed.FS
+)
+
+func (LinkedIdentity) TableName() string {
+	return "linked_identities"
+}
+
+func initAccountDB() error {
+	dbPath := param.Server_UIAccountDbLocation.GetString()
+	tdb, err := server_utils.InitSQLiteDB(dbPath)
+	if err != nil {
+		return err
+	}
+	sqldb, err := tdb.DB()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get sql.DB from gorm DB: %s", dbPath)
+	}
+	if err := server_utils.MigrateDB(sqldb, embedMigrations); err != nil {
+		return err
+	}
+	accountDB = tdb
+	return nil
+}
+
+// Find the account an identity from the provider is linked to, linking it to an
+// account first if it's new.
+//
+// A new identity is linked to the account in linkTo, if set, as when a logged-in user
+// links another identity to their account.  Otherwise, it's linked to the account of
+// an identity from another provider with the same verified email address, or to a new
+// account named after the username.
+func resolveAccount(provider *oidcProvider, identity oidcIdentity, linkTo string) (string, error) {
+	if identity.Subject == "" {
+		return "", errors.Errorf("provider %s did not return a subject for the user", provider.name)
+	}
+	now := time.Now()
+	admin := provider.isAdmin(identity.Groups)
+	existing := LinkedIdentity{}
+	err := accountDB.Where("provider = ? AND subject = ?", provider.name, identity.Subject).First(&existing).Error
+	if err == nil {
+		if linkTo != "" && linkTo != existing.Account {
+			return "", errors.Errorf("this %s identity is already linked to another account", provider.displayName)
+		}
+		err = accountDB.Model(&existing).Updates(map[string]interface{}{
+			"username":       identity.User,
+			"email":          identity.Email,
+			"email_verified": identity.EmailVerified,
+			"admin":          admin,
+			"last_login_at":  now,
+		}).Error
+		if err != nil {
+			return "", errors.Wrap(err, "failed to update the linked identity")
+		}
+		return existing.Account, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", errors.Wrap(err, "failed to look up the linked identity")
+	}
+
+	account := linkTo
+	if account == "" && identity.EmailVerified && identity.Email != "" {
+		match := LinkedIdentity{}
+		err = accountDB.Where("email = ? AND email_verified = ? AND provider != ?", identity.Email, true, provider.name).
+			Order("created_at ASC").First(&match).Error
+		if err == nil {
+			account = match.Account
+			log.Infof("Linking the %s identity %s to account %s by the verified email %s", provider.name, identity.Subject, account, identity.Email)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errors.Wrap(err, "failed to look up identities by email")
+		}
+	}
+	if account == "" {
+		if identity.User == "" {
+			return "", errors.Errorf("provider %s did not return a username", provider.name)
+		}
+		// Don't let a user take over the account of another user with the same name,
+		// or the built-in admin account
+		for _, candidate := range []string{identity.User, fmt.Sprintf("%s@%s", identity.User, provider.name)} {
+			var taken int64
+			if err = accountDB.Model(&LinkedIdentity{}).Where("account = ?", candidate).Count(&taken).Error; err != nil {
+				return "", errors.Wrap(err, "failed to look up the account")
+			}
+			if taken == 0 && candidate != "admin" {
+				account = candidate
+				break
+			}
+		}
+		if account == "" {
+			// The provider's subject is unique, unlike the username
+			account = fmt.Sprintf("%s@%s", identity.Subject, provider.name)
+		}
+	}
+
+	record := LinkedIdentity{
+		Provider:      provider.name,
+		Subject:       identity.Subject,
+		Account:       account,
+		Username:      identity.User,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Admin:         admin,
+		CreatedAt:     now,
+		LastLoginAt:   now,
+	}
+	if err = accountDB.Create(&record).Error; err != nil {
+		return "", errors.Wrap(err, "failed to link the identity")
+	}
+	log.Infof("Linked the %s identity %s to account %s", provider.name, identity.Subject, account)
+	return account, nil
+}
+
+// Whether the account is linked to an identity in one of its provider's admin groups
+func isProviderAdmin(account string) bool {
+	if accountDB == nil {
+		return false
+	}
+	var count int64
+	if err := accountDB.Model(&LinkedIdentity{}).Where("account = ? AND admin = ?", account, true).Count(&count).Error; err != nil {
+		log.Errorf("Failed to look up the linked identities of account %s: %v", account, err)
+		return false
+	}
+	return count > 0
+}
+
+// List the identities linked to the logged-in user's account
+func listLinkedIdentitiesHandler(ctx *gin.Context) {
+	identities := []LinkedIdentity{}
+	if accountDB != nil {
+		if err := accountDB.Where("account = ?", ctx.GetString("User")).Order("provider ASC").Find(&identities).Error; err != nil {
+			log.Errorf("Failed to list the linked identities of %s: %v", ctx.GetString("User"), err)
+			ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Failed to list the linked identities",
+			})
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, identities)
+}
+
+// Unlink one of the identities from the logged-in user's account.  The last identity
+// can't be unlinked, so the user can still log in.
+func unlinkIdentityHandler(ctx *gin.Context) {
+	user := ctx.GetString("User")
+	provider := ctx.Param("provider")
+	if accountDB == nil {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: "Account linking is not enabled"})
+		return
+	}
+	var count int64
+	if err := accountDB.Model(&LinkedIdentity{}).Where("account = ?", user).Count(&count).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: "Failed to look up the linked identities"})
+		return
+	}
+	if count <= 1 {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: "The only identity linked to an account can't be unlinked"})
+		return
+	}
+	result := accountDB.Where("account = ? AND provider = ?", user, provider).Delete(&LinkedIdentity{})
+	if result.Error != nil {
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: "Failed to unlink the identity"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: fmt.Sprintf("No %s identity is linked to the account", provider)})
+		return
+	}
+	log.Infof("User %s unlinked their %s identity", user, provider)
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK, Msg: "success"})
+}
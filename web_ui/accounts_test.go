@@ -0,0 +1,156 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func setupTestAccountDB(t *testing.T) {
+	tdb, err := server_utils.InitSQLiteDB(filepath.Join(t.TempDir(), "accounts.sqlite"))
+	require.NoError(t, err)
+	require.NoError(t, tdb.AutoMigrate(&LinkedIdentity{}))
+	accountDB = tdb
+	t.Cleanup(func() {
+		accountDB = nil
+		sqldb, err := tdb.DB()
+		if err == nil {
+			sqldb.Close()
+		}
+	})
+}
+
+func TestResolveAccount(t *testing.T) {
+	cilogon := &oidcProvider{name: "cilogon", displayName: "CILogon", adminGroups: []string{"/pelican-admins"}}
+	campus := &oidcProvider{name: "campus", displayName: "Campus SSO"}
+	github := &oidcProvider{name: "github", displayName: "GitHub"}
+
+	t.Run("new-and-returning-identity", func(t *testing.T) {
+		setupTestAccountDB(t)
+		identity := oidcIdentity{Subject: "http://cilogon.org/serverA/users/1", User: "alice"}
+		account, err := resolveAccount(cilogon, identity, "")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", account)
+
+		// The username may change, but the subject identifies the account
+		identity.User = "alice.smith"
+		account, err = resolveAccount(cilogon, identity, "")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", account)
+	})
+
+	t.Run("link-by-verified-email", func(t *testing.T) {
+		setupTestAccountDB(t)
+		_, err := resolveAccount(cilogon, oidcIdentity{Subject: "1", User: "alice", Email: "alice@example.edu", EmailVerified: true}, "")
+		require.NoError(t, err)
+
+		account, err := resolveAccount(campus, oidcIdentity{Subject: "a1", User: "asmith", Email: "alice@example.edu", EmailVerified: true}, "")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", account)
+
+		// An unverified email isn't enough to link the identity
+		account, err = resolveAccount(github, oidcIdentity{Subject: "42", User: "mallory", Email: "alice@example.edu"}, "")
+		require.NoError(t, err)
+		assert.Equal(t, "mallory", account)
+	})
+
+	t.Run("username-collision", func(t *testing.T) {
+		setupTestAccountDB(t)
+		_, err := resolveAccount(cilogon, oidcIdentity{Subject: "1", User: "bob"}, "")
+		require.NoError(t, err)
+
+		account, err := resolveAccount(github, oidcIdentity{Subject: "2", User: "bob"}, "")
+		require.NoError(t, err)
+		assert.Equal(t, "bob@github", account)
+
+		account, err = resolveAccount(github, oidcIdentity{Subject: "3", User: "bob"}, "")
+		require.NoError(t, err)
+		assert.Equal(t, "3@github", account)
+
+		account, err = resolveAccount(github, oidcIdentity{Subject: "4", User: "admin"}, "")
+		require.NoError(t, err)
+		assert.Equal(t, "admin@github", account)
+	})
+
+	t.Run("explicit-link", func(t *testing.T) {
+		setupTestAccountDB(t)
+		_, err := resolveAccount(cilogon, oidcIdentity{Subject: "1", User: "carol"}, "")
+		require.NoError(t, err)
+		_, err = resolveAccount(cilogon, oidcIdentity{Subject: "2", User: "dave"}, "")
+		require.NoError(t, err)
+
+		account, err := resolveAccount(github, oidcIdentity{Subject: "7", User: "carol-gh"}, "carol")
+		require.NoError(t, err)
+		assert.Equal(t, "carol", account)
+
+		// An identity already linked to one account can't be linked to another
+		_, err = resolveAccount(github, oidcIdentity{Subject: "7", User: "carol-gh"}, "dave")
+		assert.Error(t, err)
+	})
+
+	t.Run("provider-admin", func(t *testing.T) {
+		setupTestAccountDB(t)
+		_, err := resolveAccount(cilogon, oidcIdentity{Subject: "1", User: "erin", Groups: []string{"/pelican-admins"}}, "")
+		require.NoError(t, err)
+		_, err = resolveAccount(campus, oidcIdentity{Subject: "f1", User: "frank", Groups: []string{"/pelican-admins"}}, "")
+		require.NoError(t, err)
+		assert.True(t, isProviderAdmin("erin"))
+		// Admin groups only apply to the provider they're configured for
+		assert.False(t, isProviderAdmin("frank"))
+
+		// Leaving the group revokes admin at the next login
+		_, err = resolveAccount(cilogon, oidcIdentity{Subject: "1", User: "erin"}, "")
+		require.NoError(t, err)
+		assert.False(t, isProviderAdmin("erin"))
+	})
+
+	t.Run("no-subject", func(t *testing.T) {
+		setupTestAccountDB(t)
+		_, err := resolveAccount(cilogon, oidcIdentity{User: "alice"}, "")
+		assert.Error(t, err)
+	})
+}
+
+func TestIdentityFromUserInfo(t *testing.T) {
+	provider := &oidcProvider{name: "github", userClaim: "login", subjectClaim: "id", groupClaim: "teams"}
+	identity, err := provider.identityFromUserInfo(map[string]interface{}{
+		"login":          "octocat",
+		"id":             float64(583231),
+		"email":          "octocat@example.com",
+		"email_verified": "true",
+		"teams":          []interface{}{"admins", "devs"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, oidcIdentity{
+		Subject:       "583231",
+		User:          "octocat",
+		Email:         "octocat@example.com",
+		EmailVerified: true,
+		Groups:        []string{"admins", "devs"},
+	}, identity)
+
+	_, err = provider.identityFromUserInfo(map[string]interface{}{"login": "octocat"})
+	assert.Error(t, err)
+}
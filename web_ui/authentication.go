@@ -75,6 +75,7 @@ var (
 	authDB       atomic.Pointer[htpasswd.File]
 	currentCode  atomic.Pointer[string]
 	previousCode atomic.Pointer[string]
+	loginLimiter atomic.Pointer[gin.HandlerFunc]
 )
 
 const (
@@ -243,11 +244,15 @@ func RequireAuthMiddleware(ctx *gin.Context) {
 // indicating the error message.
 //
 // Note that by default it only checks if user == "admin". If you have a custom list of admin identifiers
-// to check, you should set Server.UIAdminUsers. See parameters.yaml for details.
+// to check, you should set Server.UIAdminUsers. See parameters.yaml for details. Users in the admin
+// groups of one of the providers in OIDC.Providers are also admins.
 func CheckAdmin(user string) (isAdmin bool, message string) {
 	if user == "admin" {
 		return true, ""
 	}
+	if isProviderAdmin(user) {
+		return true, ""
+	}
 	adminList := param.Server_UIAdminUsers.GetStringSlice()
 	if !param.Server_UIAdminUsers.IsSet() {
 		return false, "Server.UIAdminUsers is not set, and user is not root user. Admin check returns false"
@@ -475,20 +480,8 @@ func listOIDCEnabledServersHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, res)
 }
 
-// Configure the authentication endpoints for the server web UI
-func configureAuthEndpoints(ctx context.Context, router *gin.Engine, egrp *errgroup.Group) error {
-	if router == nil {
-		return errors.New("Web engine configuration passed a nil pointer")
-	}
-
-	if err := configureAuthDB(); err != nil {
-		log.Infoln("Authorization not configured (non-fatal):", err)
-	}
-
-	csrfHandler, err := config.GetCSRFHandler()
-	if err != nil {
-		return err
-	}
+// Build the middleware limiting login attempts per client to Server.UILoginRateLimit a second
+func newLoginRateLimiter() *gin.HandlerFunc {
 	limit := param.Server_UILoginRateLimit.GetInt()
 	if limit <= 0 {
 		log.Warning("Invalid Server.UILoginRateLimit. Value is less than 1. Fallback to 1")
@@ -509,6 +502,32 @@ func configureAuthEndpoints(ctx context.Context, router *gin.Engine, egrp *errgr
 		},
 		KeyFunc: func(ctx *gin.Context) string { return ctx.ClientIP() },
 	})
+	return &mw
+}
+
+// Configure the authentication endpoints for the server web UI
+func configureAuthEndpoints(ctx context.Context, router *gin.Engine, egrp *errgroup.Group) error {
+	if router == nil {
+		return errors.New("Web engine configuration passed a nil pointer")
+	}
+
+	if err := configureAuthDB(); err != nil {
+		log.Infoln("Authorization not configured (non-fatal):", err)
+	}
+
+	csrfHandler, err := config.GetCSRFHandler()
+	if err != nil {
+		return err
+	}
+	loginLimiter.Store(newLoginRateLimiter())
+	// The limiter is rebuilt when Server.UILoginRateLimit changes on a configuration reload
+	config.RegisterReloadable(func() error {
+		loginLimiter.Store(newLoginRateLimiter())
+		return nil
+	}, param.Server_UILoginRateLimit.GetName())
+	mw := func(ctx *gin.Context) {
+		(*loginLimiter.Load())(ctx)
+	}
 
 	group := router.Group("/api/v1.0/auth")
 	group.POST("/login", mw, loginHandler)
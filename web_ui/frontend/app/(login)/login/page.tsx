@@ -33,12 +33,13 @@ import LoadingButton from '../components/LoadingButton';
 import PasswordInput from '../components/PasswordInput';
 import useSWR from 'swr';
 import { getUser } from '@/helpers/login';
-import { ServerType } from '@/index';
+import { OIDCProvider, ServerType } from '@/index';
 import {
   alertOnError,
   getEnabledServers,
   getErrorMessage,
   getOauthEnabledServers,
+  getOIDCProviders,
 } from '@/helpers/util';
 import { login } from '@/helpers/api';
 import { AlertDispatchContext } from '@/components/AlertProvider';
@@ -184,6 +185,17 @@ export default function Home() {
     { fallbackData: [] }
   );
 
+  const { data: oidcProviders } = useSWR<OIDCProvider[] | undefined>(
+    'getOIDCProviders',
+    async () =>
+      await alertOnError(
+        getOIDCProviders,
+        'Could not get the login providers',
+        dispatch
+      ),
+    { fallbackData: [] }
+  );
+
   useEffect(() => {
     const url = new URL(window.location.href);
     const returnUrl = url.searchParams.get('returnURL') || '';
@@ -217,15 +229,35 @@ export default function Home() {
               serverIntersect.includes('cache') ||
               serverIntersect.includes('director')) && (
               <>
-                <Box display={'flex'} justifyContent={'center'} mb={1}>
-                  <Button
-                    size={'large'}
-                    href={`/api/v1.0/auth/oauth/login?nextUrl=${returnUrl ? returnUrl : '/'}`}
-                    variant={'contained'}
-                  >
-                    Login with OAuth
-                  </Button>
-                </Box>
+                {oidcProviders && oidcProviders.length > 1 ? (
+                  oidcProviders.map((provider) => (
+                    <Box
+                      key={provider.name}
+                      display={'flex'}
+                      justifyContent={'center'}
+                      mb={1}
+                    >
+                      <Button
+                        size={'large'}
+                        href={`/api/v1.0/auth/oauth/login?provider=${encodeURIComponent(provider.name)}&nextUrl=${returnUrl ? returnUrl : '/'}`}
+                        variant={'contained'}
+                        fullWidth
+                      >
+                        Login with {provider.displayName}
+                      </Button>
+                    </Box>
+                  ))
+                ) : (
+                  <Box display={'flex'} justifyContent={'center'} mb={1}>
+                    <Button
+                      size={'large'}
+                      href={`/api/v1.0/auth/oauth/login?nextUrl=${returnUrl ? returnUrl : '/'}`}
+                      variant={'contained'}
+                    >
+                      Login with OAuth
+                    </Button>
+                  </Box>
+                )}
               </>
             )}
           {serverIntersect && <AdminLogin />}
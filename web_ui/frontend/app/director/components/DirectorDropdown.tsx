@@ -32,6 +32,30 @@ export const DirectorDropdown = ({
               name={'Latitude'}
               value={server.latitude.toString()}
             />
+            {'resourceGroup' in server && server.resourceGroup && (
+              <InformationSpan
+                name={'Resource Group'}
+                value={server.resourceGroup}
+              />
+            )}
+            {'topologyDowntime' in server && server.topologyDowntime && (
+              <InformationSpan
+                name={'Topology Downtime'}
+                value={`${server.topologyDowntime.scheduled ? 'Scheduled' : 'Unscheduled'} until ${new Date(server.topologyDowntime.endTime).toLocaleString()}`}
+              />
+            )}
+            {'contacts' in server &&
+              server.contacts?.map((contact) => (
+                <InformationSpan
+                  key={`${contact.type}-${contact.name}`}
+                  name={contact.type || 'Contact'}
+                  value={
+                    contact.email
+                      ? `${contact.name} <${contact.email}>`
+                      : contact.name
+                  }
+                />
+              ))}
           </Grid>
           <Grid item xs={12} md={5}>
             <Box
@@ -65,20 +89,22 @@ export const CapabilitiesRow = ({
 }) => {
   return (
     <Grid container spacing={1}>
-      {Object.entries(capabilities).map(([key, value]) => {
-        const castKey = key as keyof Capabilities;
-        return (
-          <Grid item md={12 / 5} sm={12 / 4} xs={12 / 2} key={key}>
-            <CapabilitiesChip
-              name={key}
-              value={value}
-              parentValue={
-                parentCapabilities ? parentCapabilities[castKey] : undefined
-              }
-            />
-          </Grid>
-        );
-      })}
+      {Object.entries(capabilities)
+        .filter(([key]) => key !== 'Extensions')
+        .map(([key, value]) => {
+          const castKey = key as Exclude<keyof Capabilities, 'Extensions'>;
+          return (
+            <Grid item md={12 / 5} sm={12 / 4} xs={12 / 2} key={key}>
+              <CapabilitiesChip
+                name={key}
+                value={value as boolean}
+                parentValue={
+                  parentCapabilities ? parentCapabilities[castKey] : undefined
+                }
+              />
+            </Grid>
+          );
+        })}
     </Grid>
   );
 };
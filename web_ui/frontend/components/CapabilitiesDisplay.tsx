@@ -11,13 +11,15 @@ export const CapabilitiesDisplay = ({
 }) => {
   return (
     <>
-      {Object.entries(capabilities).map(([key, value]) => {
-        return (
-          <Tooltip title={key} key={key}>
-            <CapabilitiesChip key={key} name={key} value={value as boolean} />
-          </Tooltip>
-        );
-      })}
+      {Object.entries(capabilities)
+        .filter(([key]) => key !== 'Extensions')
+        .map(([key, value]) => {
+          return (
+            <Tooltip title={key} key={key}>
+              <CapabilitiesChip key={key} name={key} value={value as boolean} />
+            </Tooltip>
+          );
+        })}
     </>
   );
 };
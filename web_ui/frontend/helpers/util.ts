@@ -1,4 +1,4 @@
-import { ServerType } from '@/index';
+import { OIDCProvider, ServerType } from '@/index';
 import { Dispatch } from 'react';
 import { AlertReducerAction } from '@/components/AlertProvider';
 
@@ -38,6 +38,14 @@ export const getOauthEnabledServers = async () => {
   }
 };
 
+export const getOIDCProviders = async (): Promise<OIDCProvider[]> => {
+  const response = await fetch('/api/v1.0/auth/oauth/providers');
+  if (response.ok) {
+    return await response.json();
+  }
+  return [];
+};
+
 /**
  * Extract the value from a object via a list of keys
  * @param obj
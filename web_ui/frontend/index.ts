@@ -10,10 +10,16 @@ export interface User {
 
 export type ServerType = 'registry' | 'director' | 'origin' | 'cache';
 
+export interface OIDCProvider {
+  name: string;
+  displayName: string;
+}
+
 export interface Server {
   name: string;
   authUrl: string;
   brokerUrl: string;
+  brokerOnly: boolean;
   url: string;
   webUrl: string;
   type: 'Origin' | 'Cache';
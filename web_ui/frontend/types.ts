@@ -4,6 +4,7 @@ export interface Capabilities {
   Write: boolean;
   Listing: boolean;
   FallBackRead: boolean;
+  Extensions?: Record<string, string>;
 }
 
 export interface TokenGeneration {
@@ -35,6 +36,7 @@ interface ServerBase {
   disableDirectorTest: boolean;
   authUrl: string;
   brokerUrl: string;
+  brokerOnly: boolean;
   url: string;
   webUrl: string;
   type: 'Origin' | 'Cache';
@@ -48,8 +50,27 @@ interface ServerBase {
   ioLoad: number;
 }
 
+export interface TopologyContact {
+  name: string;
+  email?: string;
+  type?: string;
+}
+
+export interface TopologyDowntime {
+  scheduled: boolean;
+  severity?: string;
+  description?: string;
+  resourceGroup?: string;
+  services?: string[];
+  startTime: string;
+  endTime: string;
+}
+
 export interface ServerDetailed extends ServerBase {
   namespaces: DirectorNamespace[];
+  resourceGroup?: string;
+  contacts?: TopologyContact[];
+  topologyDowntime?: TopologyDowntime;
 }
 
 export interface ServerGeneral extends ServerBase {
@@ -0,0 +1,125 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	logLevelsResp struct {
+		Default string            `json:"default"`
+		Modules map[string]string `json:"modules"`
+	}
+
+	logLevelUpdate struct {
+		Component string `json:"component" binding:"required"`
+		// An empty level removes the override for the component
+		Level string `json:"level"`
+	}
+)
+
+// The header carrying the ID of a request, which is echoed back in the response
+// and attached to the request's log entries as the request_id field
+const requestIdHeader = "X-Request-Id"
+
+func newRequestId() string {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(idBytes)
+}
+
+// Middleware assigning each request an ID, honoring one provided by an upstream proxy
+func requestIdMiddleware(ctx *gin.Context) {
+	requestId := ctx.GetHeader(requestIdHeader)
+	if requestId == "" || len(requestId) > 128 {
+		requestId = newRequestId()
+	}
+	ctx.Set(config.LogFieldRequestId, requestId)
+	ctx.Header(requestIdHeader, requestId)
+	ctx.Next()
+}
+
+// Return a logger for the component tagged with the ID of the request being handled
+func RequestLogger(ctx *gin.Context, component string) *log.Entry {
+	return config.ModuleLogger(component).WithField(config.LogFieldRequestId, ctx.GetString(config.LogFieldRequestId))
+}
+
+func currentLogLevels() logLevelsResp {
+	defaultLevel, overrides := config.GetModuleLogLevels()
+	resp := logLevelsResp{Default: defaultLevel.String(), Modules: make(map[string]string, len(overrides))}
+	for component, level := range overrides {
+		resp.Modules[component] = level.String()
+	}
+	return resp
+}
+
+func getLogLevels(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, currentLogLevels())
+}
+
+// Change the log level of a single module at runtime.  The change is not persisted;
+// use Logging.Modules to configure levels across restarts.
+func updateLogLevel(ctx *gin.Context) {
+	update := logLevelUpdate{}
+	if err := ctx.ShouldBindJSON(&update); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid request data format: " + err.Error(),
+		})
+		return
+	}
+	component := strings.TrimSpace(update.Component)
+	if update.Level == "" {
+		config.ResetModuleLogLevel(component)
+		log.Infof("Log level override for module %s removed by %s", component, ctx.GetString("User"))
+		ctx.JSON(http.StatusOK, currentLogLevels())
+		return
+	}
+	level, err := log.ParseLevel(update.Level)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid log level: " + err.Error(),
+		})
+		return
+	}
+	config.SetModuleLogLevel(component, level)
+	log.Infof("Log level for module %s set to %s by %s", component, level.String(), ctx.GetString("User"))
+	ctx.JSON(http.StatusOK, currentLogLevels())
+}
+
+func configureLoggingEndpoints(engine *gin.Engine) {
+	loggingRoutes := engine.Group("/api/v1.0/logging", AuthHandler, AdminAuthHandler)
+	{
+		loggingRoutes.GET("/levels", getLogLevels)
+		loggingRoutes.PUT("/levels", updateLogLevel)
+	}
+}
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE linked_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    account TEXT NOT NULL,
+    username TEXT NOT NULL DEFAULT '',
+    email TEXT NOT NULL DEFAULT '',
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    admin BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL,
+    last_login_at DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX idx_provider_subject ON linked_identities(provider, subject);
+CREATE INDEX idx_linked_identities_account ON linked_identities(account);
+CREATE INDEX idx_linked_identities_email ON linked_identities(email);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS linked_identities;
+-- +goose StatementEnd
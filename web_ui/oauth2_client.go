@@ -28,6 +28,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -48,11 +49,6 @@ const (
 	oauthCallbackPath = "/api/v1.0/auth/oauth/callback"
 )
 
-var (
-	oauthConfig      *oauth2.Config
-	oauthUserInfoUrl = "" // Value will be set at ConfigOAuthClientAPIs
-)
-
 // Parse the OAuth2 callback state into a key-val map. Error if keys are duplicated
 // state is the url-decoded value of the query parameter "state" in the the OAuth2 callback request
 func ParseOAuthState(state string) (metadata map[string]string, err error) {
@@ -125,7 +121,11 @@ func GenerateCSRFCookie(ctx *gin.Context, metadata map[string]string) (string, e
 // Handler to redirect user to the login page of OAuth2 provider
 // You can pass an optional next_url as query param if you want the user
 // to be redirected back to where they were before hitting the login when
-// the user is successfully authenticated against the OAuth2 provider
+// the user is successfully authenticated against the OAuth2 provider.
+//
+// When several providers are configured, the provider query param chooses one,
+// and a logged-in user may pass link=true to link the identity they log in with
+// to their current account.
 func handleOAuthLogin(ctx *gin.Context) {
 	req := server_structs.OAuthLoginRequest{}
 	if ctx.ShouldBindQuery(&req) != nil {
@@ -134,7 +134,44 @@ func handleOAuthLogin(ctx *gin.Context) {
 				Status: server_structs.RespFailed,
 				Msg:    "Failed to bind next url",
 			})
+		return
+	}
+
+	provider, ok := getOIDCProvider(req.Provider)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest,
+			server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    fmt.Sprintf("Unknown login provider %q", req.Provider),
+			})
+		return
 	}
+	linkTo := ""
+	if req.Link {
+		if provider.legacy {
+			ctx.JSON(http.StatusBadRequest,
+				server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    "Account linking requires OIDC.Providers to be configured",
+				})
+			return
+		}
+		user, _, err := GetUserGroups(ctx)
+		if err != nil || user == "" {
+			ctx.JSON(http.StatusUnauthorized,
+				server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    "Login required to link another identity to your account",
+				})
+			return
+		}
+		linkTo = user
+	}
+
+	// Remember which provider the user logs in with, and the account to link the identity to, for the callback
+	session := sessions.Default(ctx)
+	session.Set("oauthprovider", provider.name)
+	session.Set("oauthlink", linkTo)
 
 	// CSRF token is required, embed next URL to the state
 	csrfState, err := GenerateCSRFCookie(ctx, map[string]string{"nextUrl": req.NextUrl})
@@ -149,7 +186,7 @@ func handleOAuthLogin(ctx *gin.Context) {
 		return
 	}
 
-	redirectUrl := oauthConfig.AuthCodeURL(csrfState)
+	redirectUrl := provider.oauthConfig.AuthCodeURL(csrfState)
 	ctx.Redirect(http.StatusTemporaryRedirect, redirectUrl)
 }
 
@@ -177,13 +214,8 @@ func generateGroupInfo(user string) (groups []string, err error) {
 	return
 }
 
-// Given a map from a JSON object, generate user/group information according to
-// the current policy.
-func generateUserGroupInfo(userInfo map[string]interface{}) (user string, groups []string, err error) {
-	userClaim := param.Issuer_OIDCAuthenticationUserClaim.GetString()
-	if userClaim == "" {
-		userClaim = "sub"
-	}
+// Get the username from the claim of a user info response
+func userFromClaim(userInfo map[string]interface{}, userClaim string) (user string, err error) {
 	userIdentifierIface, ok := userInfo[userClaim]
 	if !ok {
 		log.Errorln("User info endpoint did not return a value for the user claim", userClaim)
@@ -208,28 +240,47 @@ func generateUserGroupInfo(userInfo map[string]interface{}) (user string, groups
 		return
 	}
 	user = userIdentifier
+	return
+}
+
+// Parse the groups in a claim of a user info response, which is either a
+// comma-separated string or a list of strings
+func groupsFromClaim(groupList interface{}) (groups []string) {
+	if groupsStr, ok := groupList.(string); ok {
+		groupsInfo := strings.Split(groupsStr, ",")
+		groups = make([]string, 0, len(groupsInfo))
+		for _, groupRaw := range groupsInfo {
+			group := strings.TrimSpace(groupRaw)
+			if group != "" {
+				groups = append(groups, group)
+			}
+		}
+	} else if groupsTmp, ok := groupList.([]interface{}); ok {
+		groups = make([]string, 0, len(groupsTmp))
+		for _, groupObj := range groupsTmp {
+			if groupStr, ok := groupObj.(string); ok {
+				groups = append(groups, groupStr)
+			}
+		}
+	}
+	return
+}
+
+// Given a map from a JSON object, generate user/group information according to
+// the current policy.
+func generateUserGroupInfo(userInfo map[string]interface{}) (user string, groups []string, err error) {
+	userClaim := param.Issuer_OIDCAuthenticationUserClaim.GetString()
+	if userClaim == "" {
+		userClaim = "sub"
+	}
+	if user, err = userFromClaim(userInfo, userClaim); err != nil {
+		return
+	}
 
 	if param.Issuer_GroupSource.GetString() == "oidc" {
 		groupClaim := param.Issuer_OIDCGroupClaim.GetString()
-		groupList, ok := userInfo[groupClaim]
-		if ok {
-			if groupsStr, ok := groupList.(string); ok {
-				groupsInfo := strings.Split(groupsStr, ",")
-				groups = make([]string, 0, len(groupsInfo))
-				for _, groupRaw := range groupsInfo {
-					group := strings.TrimSpace(groupRaw)
-					if group != "" {
-						groups = append(groups, group)
-					}
-				}
-			} else if groupsTmp, ok := groupList.([]interface{}); ok {
-				groups = make([]string, 0, len(groupsTmp))
-				for _, groupObj := range groupsTmp {
-					if groupStr, ok := groupObj.(string); ok {
-						groups = append(groups, groupStr)
-					}
-				}
-			}
+		if groupList, ok := userInfo[groupClaim]; ok {
+			groups = groupsFromClaim(groupList)
 		}
 	} else {
 		groups, err = generateGroupInfo(user)
@@ -237,6 +288,93 @@ func generateUserGroupInfo(userInfo map[string]interface{}) (user string, groups
 	return
 }
 
+// Get the identity of a user from the user info response of one of the providers in OIDC.Providers
+func (p *oidcProvider) identityFromUserInfo(userInfo map[string]interface{}) (identity oidcIdentity, err error) {
+	if identity.User, err = userFromClaim(userInfo, p.userClaim); err != nil {
+		return
+	}
+	// Subjects may be numeric, as GitHub's are
+	switch subject := userInfo[p.subjectClaim].(type) {
+	case string:
+		identity.Subject = subject
+	case float64:
+		identity.Subject = strconv.FormatFloat(subject, 'f', -1, 64)
+	}
+	if identity.Subject == "" {
+		err = errors.Errorf("identity provider did not return the %s claim identifying the user", p.subjectClaim)
+		return
+	}
+	identity.Email, _ = userInfo["email"].(string)
+	switch verified := userInfo["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	if p.groupClaim != "" {
+		identity.Groups = groupsFromClaim(userInfo[p.groupClaim])
+	} else if param.Issuer_GroupSource.GetString() == "oidc" {
+		identity.Groups = groupsFromClaim(userInfo[param.Issuer_OIDCGroupClaim.GetString()])
+	} else {
+		identity.Groups, err = generateGroupInfo(identity.User)
+	}
+	return
+}
+
+// Query the provider's user info endpoint with the access token
+func (p *oidcProvider) fetchUserInfo(c context.Context, token *oauth2.Token) (userInfo map[string]interface{}, err error) {
+	client := p.oauthConfig.Client(c, token)
+	client.Transport = config.GetTransport()
+
+	var userInfoReq *http.Request
+	if p.postUserInfo {
+		// CILogon requires token to be set as part of post form
+		data := url.Values{}
+		data.Add("access_token", token.AccessToken)
+		userInfoReq, err = http.NewRequest(http.MethodPost, p.userInfoUrl, strings.NewReader(data.Encode()))
+		if err == nil {
+			userInfoReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		userInfoReq, err = http.NewRequest(http.MethodGet, p.userInfoUrl, nil)
+	}
+	if err != nil {
+		log.Errorf("Error creating a new request for user info from auth provider at %s. %v", p.userInfoUrl, err)
+		return nil, errors.Wrap(err, "Error requesting user info from auth provider")
+	}
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	userInfoReq.Header.Add("Authorization", tokenType+" "+token.AccessToken)
+	userInfoReq.Header.Add("Accept", "application/json")
+
+	resp, err := client.Do(userInfoReq)
+	if err != nil {
+		log.Errorf("Error requesting user info from auth provider at %s. %v", p.userInfoUrl, err)
+		return nil, errors.Wrap(err, "Error requesting user info from auth provider")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Errorf("Error getting user info response from auth provider at %s. %v", p.userInfoUrl, err)
+		return nil, errors.Wrap(err, "Failed to get OAuth2 user info response")
+	}
+
+	if resp.StatusCode != 200 {
+		log.Errorf("Error requesting user info from auth provider at %s with status code %d and body %s", p.userInfoUrl, resp.StatusCode, string(body))
+		return nil, errors.Errorf("Error requesting user info from auth provider with status code %d", resp.StatusCode)
+	}
+
+	if err = json.Unmarshal(body, &userInfo); err != nil {
+		log.Errorf("Error parsing user info from auth provider at %s. %v", p.userInfoUrl, err)
+		return nil, errors.Wrap(err, "Error parsing user info from auth provider")
+	}
+	return userInfo, nil
+}
+
 // Handle the callback request from CILogon when user is successfully authenticated
 // Get user info from CILogon and issue our token for user to access web UI
 func handleOAuthCallback(ctx *gin.Context) {
@@ -292,85 +430,58 @@ func handleOAuthCallback(ctx *gin.Context) {
 		return
 	}
 
-	// We only need this token to grab user id from cilogon
-	// and we won't store it anywhere. We will later issue our own token
-	// for user access
-	token, err := oauthConfig.Exchange(c, req.Code)
-	if err != nil {
-		log.Errorf("Error in exchanging code for token:  %v", err)
-		ctx.JSON(http.StatusInternalServerError,
-			server_structs.SimpleApiResp{
-				Status: server_structs.RespFailed,
-				Msg:    fmt.Sprint("Error in exchanging code for token: ", ctx.Request.URL),
-			})
-		return
+	providerName, _ := session.Get("oauthprovider").(string)
+	linkTo, _ := session.Get("oauthlink").(string)
+	session.Delete("oauthprovider")
+	session.Delete("oauthlink")
+	if err := session.Save(); err != nil {
+		log.Warningf("Failed to clear the OAuth login provider from the session: %v", err)
 	}
-
-	client := oauthConfig.Client(c, token)
-	client.Transport = config.GetTransport()
-	// CILogon requires token to be set as part of post form
-	data := url.Values{}
-	data.Add("access_token", token.AccessToken)
-
-	// Use access_token to get user info from CILogon
-	userInfoReq, err := http.NewRequest(http.MethodPost, oauthUserInfoUrl, strings.NewReader(data.Encode()))
-	if err != nil {
-		log.Errorf("Error creating a new request for user info from auth provider at %s. %v", oauthUserInfoUrl, err)
-		ctx.JSON(http.StatusInternalServerError,
+	provider, ok := getOIDCProvider(providerName)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest,
 			server_structs.SimpleApiResp{
 				Status: server_structs.RespFailed,
-				Msg:    fmt.Sprint("Error requesting user info from CILogon: ", err),
+				Msg:    fmt.Sprintf("Invalid OAuth callback: unknown login provider %q", providerName),
 			})
 		return
 	}
-	userInfoReq.Header.Add("Authorization", token.TokenType+" "+token.AccessToken)
-	userInfoReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := client.Do(userInfoReq)
+	// We only need this token to grab user id from the provider
+	// and we won't store it anywhere. We will later issue our own token
+	// for user access
+	token, err := provider.oauthConfig.Exchange(c, req.Code)
 	if err != nil {
-		log.Errorf("Error requesting user info from auth provider at %s. %v", oauthUserInfoUrl, err)
+		log.Errorf("Error in exchanging code for token:  %v", err)
 		ctx.JSON(http.StatusInternalServerError,
 			server_structs.SimpleApiResp{
 				Status: server_structs.RespFailed,
-				Msg:    fmt.Sprint("Error requesting user info from auth provider: ", err),
+				Msg:    fmt.Sprint("Error in exchanging code for token: ", ctx.Request.URL),
 			})
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	userInfo, err := provider.fetchUserInfo(c, token)
 	if err != nil {
-		log.Errorf("Error getting user info response from auth provider at %s. %v", oauthUserInfoUrl, err)
-		ctx.JSON(http.StatusInternalServerError,
-			server_structs.SimpleApiResp{
-				Status: server_structs.RespFailed,
-				Msg:    fmt.Sprint("Failed to get OAuth2 user info response: ", err),
-			})
-		return
-	}
-
-	if resp.StatusCode != 200 {
-		log.Errorf("Error requesting user info from auth provider at %s with status code %d and body %s", oauthUserInfoUrl, resp.StatusCode, string(body))
 		ctx.JSON(http.StatusInternalServerError,
 			server_structs.SimpleApiResp{
 				Status: server_structs.RespFailed,
-				Msg:    fmt.Sprint("Error requesting user info from auth provider with status code ", resp.StatusCode),
+				Msg:    err.Error(),
 			})
 		return
 	}
 
-	var userInfo map[string]interface{}
-	if err := json.Unmarshal(body, &userInfo); err != nil {
-		log.Errorf("Error parsing user info from auth provider at %s. %v", oauthUserInfoUrl, err)
-		ctx.JSON(http.StatusInternalServerError,
-			server_structs.SimpleApiResp{
-				Status: server_structs.RespFailed,
-				Msg:    fmt.Sprint("Error parsing user info from CILogon: ", err),
-			})
-		return
+	var user string
+	var groups []string
+	if provider.legacy {
+		user, groups, err = generateUserGroupInfo(userInfo)
+	} else {
+		var identity oidcIdentity
+		if identity, err = provider.identityFromUserInfo(userInfo); err == nil {
+			groups = identity.Groups
+			user, err = resolveAccount(provider, identity, linkTo)
+		}
 	}
-
-	user, groups, err := generateUserGroupInfo(userInfo)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError,
 			server_structs.SimpleApiResp{
@@ -394,23 +505,44 @@ func handleOAuthCallback(ctx *gin.Context) {
 
 // Configure OAuth2 client and register related authentication endpoints for Web UI
 func ConfigOAuthClientAPIs(engine *gin.Engine) error {
-	oauthCommonConfig, provider, err := pelican_oauth2.ServerOIDCClient()
-	if err != nil {
-		return errors.Wrap(err, "failed to load server OIDC client config")
-	}
-	// Pelican registry relies on OAuth2 device flow for CLI-based registration
-	// and Globus does not support such flow. So users should not use Globus for the registry
-	if config.IsServerEnabled(server_structs.RegistryType) && provider == config.Globus {
-		return errors.New("you are using Globus as the OIDC auth server. However, Pelican registry server does not support Globus. Please use CILogon as the auth server instead.")
-	}
-
-	oauthUserInfoUrl = oauthCommonConfig.Endpoint.UserInfoURL
+	if param.OIDC_Providers.IsSet() {
+		if err := initOIDCProviders(); err != nil {
+			return err
+		}
+		if len(oidcProviders) == 0 {
+			return errors.New("OIDC.Providers is set but has no providers")
+		}
+		if err := initAccountDB(); err != nil {
+			return errors.Wrap(err, "failed to initialize the web UI account database")
+		}
+	} else {
+		oauthCommonConfig, provider, err := pelican_oauth2.ServerOIDCClient()
+		if err != nil {
+			return errors.Wrap(err, "failed to load server OIDC client config")
+		}
+		// Pelican registry relies on OAuth2 device flow for CLI-based registration
+		// and Globus does not support such flow. So users should not use Globus for the registry
+		if config.IsServerEnabled(server_structs.RegistryType) && provider == config.Globus {
+			return errors.New("you are using Globus as the OIDC auth server. However, Pelican registry server does not support Globus. Please use CILogon as the auth server instead.")
+		}
 
-	ocfg, err := pelican_oauth2.ParsePelicanOAuth(oauthCommonConfig, oauthCallbackPath)
-	if err != nil {
-		return err
+		ocfg, err := pelican_oauth2.ParsePelicanOAuth(oauthCommonConfig, oauthCallbackPath)
+		if err != nil {
+			return err
+		}
+		displayName := string(provider)
+		if provider == config.UnknownProvider {
+			displayName = "OAuth"
+		}
+		oidcProviders = []*oidcProvider{{
+			name:         strings.ToLower(string(provider)),
+			displayName:  displayName,
+			oauthConfig:  &ocfg,
+			userInfoUrl:  oauthCommonConfig.Endpoint.UserInfoURL,
+			postUserInfo: true,
+			legacy:       true,
+		}}
 	}
-	oauthConfig = &ocfg
 
 	seHandler, err := GetSessionHandler()
 	if err != nil {
@@ -421,6 +553,9 @@ func ConfigOAuthClientAPIs(engine *gin.Engine) error {
 	{
 		oauthGroup.GET("/login", handleOAuthLogin)
 		oauthGroup.GET("/callback", handleOAuthCallback)
+		oauthGroup.GET("/providers", listOIDCProvidersHandler)
+		oauthGroup.GET("/identities", AuthHandler, listLinkedIdentitiesHandler)
+		oauthGroup.DELETE("/identities/:provider", AuthHandler, unlinkIdentityHandler)
 	}
 	return nil
 }
@@ -0,0 +1,256 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+
+	"github.com/pelicanplatform/pelican/config"
+	pelican_oauth2 "github.com/pelicanplatform/pelican/oauth2"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// An entry of OIDC.Providers
+	oidcProviderConfig struct {
+		Name             string   `mapstructure:"name"`
+		DisplayName      string   `mapstructure:"displayName"`
+		Issuer           string   `mapstructure:"issuer"`
+		ClientIDFile     string   `mapstructure:"clientIdFile"`
+		ClientSecretFile string   `mapstructure:"clientSecretFile"`
+		AuthorizationUrl string   `mapstructure:"authorizationUrl"`
+		TokenUrl         string   `mapstructure:"tokenUrl"`
+		UserInfoUrl      string   `mapstructure:"userInfoUrl"`
+		Scopes           []string `mapstructure:"scopes"`
+		UserClaim        string   `mapstructure:"userClaim"`
+		SubjectClaim     string   `mapstructure:"subjectClaim"`
+		GroupClaim       string   `mapstructure:"groupClaim"`
+		AdminGroups      []string `mapstructure:"adminGroups"`
+	}
+
+	// An identity provider users may log in to the web UI with
+	oidcProvider struct {
+		name        string
+		displayName string
+		oauthConfig *oauth2.Config
+		userInfoUrl string
+		// CILogon requires the access token to be posted to its user info endpoint
+		postUserInfo bool
+		userClaim    string
+		subjectClaim string
+		groupClaim   string
+		adminGroups  []string
+		// The provider from the single-provider OIDC configuration, which keeps the
+		// original login behavior and doesn't link accounts
+		legacy bool
+	}
+
+	OIDCProviderRes struct {
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	}
+)
+
+const (
+	oidcProviderCILogon = "cilogon"
+	oidcProviderGithub  = "github"
+)
+
+var (
+	// The login providers in the configured order; the first is the default
+	oidcProviders = []*oidcProvider{}
+
+	oidcProviderNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+	// The endpoints and claims of the providers with built-in support, used unless
+	// they're overridden in OIDC.Providers
+	oidcProviderDefaults = map[string]oidcProviderConfig{
+		oidcProviderCILogon: {
+			DisplayName: "CILogon",
+			Issuer:      "https://cilogon.org",
+			Scopes:      []string{"openid", "profile", "email", "org.cilogon.userinfo"},
+		},
+		oidcProviderGithub: {
+			DisplayName:      "GitHub",
+			AuthorizationUrl: "https://github.com/login/oauth/authorize",
+			TokenUrl:         "https://github.com/login/oauth/access_token",
+			UserInfoUrl:      "https://api.github.com/user",
+			Scopes:           []string{"read:user"},
+			UserClaim:        "login",
+			// The numeric ID, unlike the login, never changes
+			SubjectClaim: "id",
+		},
+	}
+)
+
+func readProviderSecretFile(configName string, location string) (string, error) {
+	if location == "" {
+		return "", errors.Errorf("%s is not set", configName)
+	}
+	contents, err := os.ReadFile(location)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s %s", configName, location)
+	}
+	value := strings.TrimSpace(string(contents))
+	if value == "" {
+		return "", errors.Errorf("%s %s is empty", configName, location)
+	}
+	return value, nil
+}
+
+// Build the login provider described by an entry of OIDC.Providers
+func newOIDCProvider(conf oidcProviderConfig, redirectUrl string) (*oidcProvider, error) {
+	conf.Name = strings.ToLower(conf.Name)
+	if !oidcProviderNamePattern.MatchString(conf.Name) {
+		return nil, errors.Errorf("invalid provider name %q; it must be lowercase letters, digits, '-', or '_'", conf.Name)
+	}
+	defaults := oidcProviderDefaults[conf.Name]
+	orDefault := func(value, defaultValue string) string {
+		if value != "" {
+			return value
+		}
+		return defaultValue
+	}
+	conf.DisplayName = orDefault(conf.DisplayName, orDefault(defaults.DisplayName, conf.Name))
+	conf.Issuer = orDefault(conf.Issuer, defaults.Issuer)
+	conf.AuthorizationUrl = orDefault(conf.AuthorizationUrl, defaults.AuthorizationUrl)
+	conf.TokenUrl = orDefault(conf.TokenUrl, defaults.TokenUrl)
+	conf.UserInfoUrl = orDefault(conf.UserInfoUrl, defaults.UserInfoUrl)
+	conf.UserClaim = orDefault(conf.UserClaim, orDefault(defaults.UserClaim, param.Issuer_OIDCAuthenticationUserClaim.GetString()))
+	conf.UserClaim = orDefault(conf.UserClaim, "sub")
+	conf.SubjectClaim = orDefault(conf.SubjectClaim, orDefault(defaults.SubjectClaim, "sub"))
+	if len(conf.Scopes) == 0 {
+		conf.Scopes = defaults.Scopes
+	}
+	if len(conf.Scopes) == 0 {
+		conf.Scopes = []string{"openid", "profile", "email"}
+	}
+
+	// Discover whichever endpoints aren't given
+	if conf.Issuer != "" && (conf.AuthorizationUrl == "" || conf.TokenUrl == "" || conf.UserInfoUrl == "") {
+		metadata, err := config.GetIssuerMetadata(conf.Issuer)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to discover the endpoints of provider %s from its issuer %s", conf.Name, conf.Issuer)
+		}
+		conf.AuthorizationUrl = orDefault(conf.AuthorizationUrl, metadata.AuthURL)
+		conf.TokenUrl = orDefault(conf.TokenUrl, metadata.TokenURL)
+		conf.UserInfoUrl = orDefault(conf.UserInfoUrl, metadata.UserInfoURL)
+	}
+	if conf.AuthorizationUrl == "" || conf.TokenUrl == "" || conf.UserInfoUrl == "" {
+		return nil, errors.Errorf("provider %s needs either an issuer or its authorization, token, and user info URLs", conf.Name)
+	}
+
+	clientID, err := readProviderSecretFile(fmt.Sprintf("OIDC.Providers client ID file for %s", conf.Name), conf.ClientIDFile)
+	if err != nil {
+		return nil, err
+	}
+	clientSecret, err := readProviderSecretFile(fmt.Sprintf("OIDC.Providers client secret file for %s", conf.Name), conf.ClientSecretFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oidcProvider{
+		name:        conf.Name,
+		displayName: conf.DisplayName,
+		oauthConfig: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectUrl,
+			Scopes:       conf.Scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  conf.AuthorizationUrl,
+				TokenURL: conf.TokenUrl,
+			},
+		},
+		userInfoUrl:  conf.UserInfoUrl,
+		userClaim:    conf.UserClaim,
+		subjectClaim: conf.SubjectClaim,
+		groupClaim:   conf.GroupClaim,
+		adminGroups:  conf.AdminGroups,
+	}, nil
+}
+
+// Set up the login providers from OIDC.Providers
+func initOIDCProviders() error {
+	configs := []oidcProviderConfig{}
+	if err := param.OIDC_Providers.Unmarshal(&configs); err != nil {
+		return errors.Wrap(err, "Error reading from config value for OIDC.Providers")
+	}
+	redirectUrl, err := pelican_oauth2.GetRedirectURL(oauthCallbackPath)
+	if err != nil {
+		return err
+	}
+	providers := make([]*oidcProvider, 0, len(configs))
+	for _, conf := range configs {
+		provider, err := newOIDCProvider(conf, redirectUrl)
+		if err != nil {
+			return errors.Wrap(err, "Bad OIDC.Providers")
+		}
+		if slices.ContainsFunc(providers, func(p *oidcProvider) bool { return p.name == provider.name }) {
+			return errors.Errorf("Bad OIDC.Providers: provider %q is configured more than once", provider.name)
+		}
+		providers = append(providers, provider)
+	}
+	oidcProviders = providers
+	return nil
+}
+
+// Get a login provider by name, or the default provider if the name is empty
+func getOIDCProvider(name string) (*oidcProvider, bool) {
+	if len(oidcProviders) == 0 {
+		return nil, false
+	}
+	if name == "" {
+		return oidcProviders[0], true
+	}
+	for _, provider := range oidcProviders {
+		if provider.name == name {
+			return provider, true
+		}
+	}
+	return nil, false
+}
+
+// Whether the user's groups at the provider make them a web UI admin
+func (p *oidcProvider) isAdmin(groups []string) bool {
+	for _, group := range groups {
+		if slices.Contains(p.adminGroups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// List the providers for the login page to let the user choose from
+func listOIDCProvidersHandler(ctx *gin.Context) {
+	res := make([]OIDCProviderRes, 0, len(oidcProviders))
+	for _, provider := range oidcProviders {
+		res = append(res, OIDCProviderRes{Name: provider.name, DisplayName: provider.displayName})
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -105,52 +105,76 @@ func updateConfigValues(ctx *gin.Context) {
 		return
 	}
 
-	webConfigPath := param.Server_WebConfigFile.GetString()
-	if webConfigPath == "" {
+	if err := WriteWebConfig(updatedConfigMap); err != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
 			Status: server_structs.RespFailed,
-			Msg:    "Bad server configuration: Server.WebConfigFile value is empty",
+			Msg:    err.Error(),
 		})
 		return
 	}
 
+	ctx.JSON(http.StatusOK,
+		server_structs.SimpleApiResp{
+			Status: server_structs.RespOK,
+			Msg:    "success",
+		})
+	config.RestartFlag <- true
+}
+
+// Merge the updates into the web-based config file, Server.WebConfigFile, whose values
+// take precedence over the other configuration files when the server starts
+func WriteWebConfig(updates map[string]interface{}) error {
+	webConfigPath := param.Server_WebConfigFile.GetString()
+	if webConfigPath == "" {
+		return errors.New("Bad server configuration: Server.WebConfigFile value is empty")
+	}
+
 	// Create a new viper instance to handle config validation and merging
 	webCfgViper := viper.New()
 	webCfgViper.SetConfigFile(webConfigPath)
 
 	if err := webCfgViper.ReadInConfig(); err != nil {
 		log.Error("Failed to read existing web-based config into internal config struct: ", err.Error())
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
-			Status: server_structs.RespFailed,
-			Msg:    "Failed to read existing web-based config into internal config struct",
-		})
-		return
+		return errors.New("Failed to read existing web-based config into internal config struct")
 	}
 
-	if err := webCfgViper.MergeConfigMap(updatedConfigMap); err != nil {
+	if err := webCfgViper.MergeConfigMap(updates); err != nil {
 		log.Error("Failed to update web-based config with requested changes: ", err.Error())
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
-			Status: server_structs.RespFailed,
-			Msg:    "Failed to update web-based config with requested changes",
-		})
-		return
+		return errors.New("Failed to update web-based config with requested changes")
 	}
 
 	if err := webCfgViper.WriteConfig(); err != nil {
 		log.Error("Failed to write back the updated config: ", err.Error())
+		return errors.New("Failed to write back the updated config")
+	}
+	return nil
+}
+
+// Re-read the configuration files, applying the parameters that are safe to change at runtime
+func triggerConfigReload(ctx *gin.Context) {
+	result, err := config.ReloadConfig()
+	if err != nil {
+		log.Errorln("Failed to reload the configuration:", err)
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
 			Status: server_structs.RespFailed,
-			Msg:    "Failed to write back the updated config",
+			Msg:    err.Error(),
 		})
 		return
 	}
+	log.Infof("Configuration reloaded by %s", ctx.GetString("User"))
+	ctx.JSON(http.StatusOK, result)
+}
 
-	ctx.JSON(http.StatusOK,
-		server_structs.SimpleApiResp{
-			Status: server_structs.RespOK,
-			Msg:    "success",
+func getConfigReload(ctx *gin.Context) {
+	result := config.GetLastConfigReload()
+	if result == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "The configuration has not been reloaded since the server started",
 		})
-	config.RestartFlag <- true
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
 }
 
 func getEnabledServers(ctx *gin.Context) {
@@ -392,14 +416,33 @@ func configureWebResource(engine *gin.Engine) {
 func configureCommonEndpoints(engine *gin.Engine) error {
 	engine.GET("/api/v1.0/config", AuthHandler, AdminAuthHandler, getConfigValues)
 	engine.PATCH("/api/v1.0/config", AuthHandler, AdminAuthHandler, updateConfigValues)
+	engine.GET("/api/v1.0/config/reload", AuthHandler, AdminAuthHandler, getConfigReload)
+	engine.POST("/api/v1.0/config/reload", AuthHandler, AdminAuthHandler, triggerConfigReload)
 	engine.GET("/api/v1.0/servers", getEnabledServers)
+	configureLoggingEndpoints(engine)
 	// Health check endpoint for web engine
 	engine.GET("/api/v1.0/health", func(ctx *gin.Context) {
 		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Web Engine Running. Time: %s", time.Now().String())})
 	})
+	// Probes for orchestrators such as Kubernetes
+	engine.GET("/api/v1.0/health/startup", probeHandler(metrics.ProbeStartup))
+	engine.GET("/api/v1.0/health/live", probeHandler(metrics.ProbeLiveness))
+	engine.GET("/api/v1.0/health/ready", probeHandler(metrics.ProbeReadiness))
 	return nil
 }
 
+// Respond with the result of the probe, with a status code of 503 if it fails
+func probeHandler(probe metrics.ProbeType) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		status := metrics.GetProbe(probe)
+		if status.Pass {
+			ctx.JSON(http.StatusOK, status)
+		} else {
+			ctx.JSON(http.StatusServiceUnavailable, status)
+		}
+	}
+}
+
 // Map gin routes for Prometheus metrics to reduce metric cardinality
 func mapPrometheusPath(c *gin.Context) string {
 	url := c.Request.URL.Path
@@ -563,14 +606,15 @@ func GetEngine() (*gin.Engine, error) {
 	gin.SetMode(gin.ReleaseMode)
 	engine := gin.New()
 	engine.Use(gin.Recovery())
-	webLogger := log.WithFields(log.Fields{"daemon": "gin"})
+	engine.Use(requestIdMiddleware)
+	webLogger := config.ModuleLogger("web").WithFields(log.Fields{"daemon": "gin"})
 	engine.Use(func(ctx *gin.Context) {
 		startTime := time.Now()
 
 		ctx.Next()
 
 		latency := time.Since(startTime)
-		webLogger.WithFields(log.Fields{"method": ctx.Request.Method,
+		webLogger.WithField(config.LogFieldRequestId, ctx.GetString(config.LogFieldRequestId)).WithFields(log.Fields{"method": ctx.Request.Method,
 			"status":   ctx.Writer.Status(),
 			"time":     latency.String(),
 			"client":   ctx.ClientIP(),
@@ -37,6 +37,7 @@ import (
 	"path/filepath"
 	"strings"
 	"text/template"
+	"time"
 	"unicode"
 
 	"github.com/go-ini/ini"
@@ -298,8 +299,9 @@ func EmitAuthfile(server server_structs.XRootDServer) error {
 					return errors.Wrapf(err, "Failed to get origin exports")
 				}
 
+				now := time.Now()
 				for _, export := range originExports {
-					if export.Capabilities.PublicReads {
+					if export.CapabilitiesAt(now).PublicReads {
 						outStr += export.FederationPrefix + " lr "
 					}
 				}
@@ -324,8 +326,9 @@ func EmitAuthfile(server server_structs.XRootDServer) error {
 			return errors.Wrapf(err, "Failed to get origin exports")
 		}
 
+		now := time.Now()
 		for _, export := range originExports {
-			if export.Capabilities.PublicReads {
+			if export.CapabilitiesAt(now).PublicReads {
 				outStr += " " + export.FederationPrefix + " lr"
 			}
 		}
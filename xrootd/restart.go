@@ -0,0 +1,300 @@
+//go:build !windows
+
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package xrootd
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/daemon"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// Restarts the XRootD daemons of one server when its configuration changes.
+	//
+	// XRootD can't hand its listening sockets over to a new process, so a restart
+	// drains the server instead: it's advertised as draining so the director sends
+	// new clients elsewhere, and the daemons are only replaced once the ongoing
+	// transfers finish or Xrootd.RestartDrainPeriod passes.
+	xrootdRestarter struct {
+		mutex             sync.Mutex
+		ctx               context.Context
+		egrp              *errgroup.Group
+		server            server_structs.XRootDServer
+		launchers         []daemon.Launcher
+		portStartCallback func(int)
+		// Stops the daemons of the current generation
+		cancel context.CancelFunc
+		pids   []int
+		// Whether a restart is in progress, and why another one was requested meanwhile
+		restarting bool
+		draining   bool
+		pending    string
+		restarts   int
+	}
+)
+
+var (
+	restarters      = map[server_structs.ServerType]*xrootdRestarter{}
+	restartersMutex sync.Mutex
+
+	restartAdvertiser      func(ctx context.Context) error
+	restartAdvertiserMutex sync.Mutex
+
+	// How long to wait for the old daemons to exit before killing them
+	restartStopTimeout = 10 * time.Second
+)
+
+// Launch the XRootD daemons of the server such that RestartXrootd can replace them later
+func LaunchRestartableDaemons(ctx context.Context, egrp *errgroup.Group, server server_structs.XRootDServer, launchers []daemon.Launcher, portStartCallback func(int)) (pids []int, err error) {
+	genCtx, cancel := context.WithCancel(ctx)
+	pids, err = LaunchDaemons(genCtx, launchers, egrp, portStartCallback)
+	if err != nil {
+		cancel()
+		return
+	}
+	restartersMutex.Lock()
+	defer restartersMutex.Unlock()
+	restarters[server.GetServerType()] = &xrootdRestarter{
+		ctx:               ctx,
+		egrp:              egrp,
+		server:            server,
+		launchers:         launchers,
+		portStartCallback: portStartCallback,
+		cancel:            cancel,
+		pids:              pids,
+	}
+	return
+}
+
+// Set the function that re-advertises the servers to the director, which lets the
+// director know when a server starts and stops draining for a restart
+func SetRestartAdvertiser(advertise func(ctx context.Context) error) {
+	restartAdvertiserMutex.Lock()
+	defer restartAdvertiserMutex.Unlock()
+	restartAdvertiser = advertise
+}
+
+// Gracefully restart the XRootD daemons of the server with a freshly generated configuration.
+// The restart happens in the background; the XRootD component of the health API reports
+// its progress.  Requests made while a restart is in progress are combined into one more
+// restart after it.
+func RestartXrootd(serverType server_structs.ServerType, reason string) error {
+	restartersMutex.Lock()
+	r := restarters[serverType]
+	restartersMutex.Unlock()
+	if r == nil {
+		return errors.Errorf("the %s XRootD daemons were not launched to be restartable", serverType.String())
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.restarting {
+		log.Infof("Queuing another %s XRootD restart: %s", serverType.String(), reason)
+		r.pending = reason
+		return nil
+	}
+	r.restarting = true
+	r.egrp.Go(func() error {
+		r.run(reason)
+		return nil
+	})
+	return nil
+}
+
+// Whether the XRootD daemons of the server are draining or being restarted
+func IsXrootdDraining(serverType server_structs.ServerType) bool {
+	restartersMutex.Lock()
+	r := restarters[serverType]
+	restartersMutex.Unlock()
+	if r == nil {
+		return false
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.draining
+}
+
+// Restart until no more restarts are pending
+func (r *xrootdRestarter) run(reason string) {
+	for {
+		if err := r.restart(reason); err != nil {
+			log.Errorf("Failed to restart the %s XRootD daemons: %v", r.server.GetServerType().String(), err)
+		}
+		r.mutex.Lock()
+		reason = r.pending
+		r.pending = ""
+		if reason == "" || r.ctx.Err() != nil {
+			r.restarting = false
+			r.mutex.Unlock()
+			return
+		}
+		r.mutex.Unlock()
+	}
+}
+
+func (r *xrootdRestarter) setDraining(draining bool) {
+	r.mutex.Lock()
+	r.draining = draining
+	r.mutex.Unlock()
+	restartAdvertiserMutex.Lock()
+	advertise := restartAdvertiser
+	restartAdvertiserMutex.Unlock()
+	if advertise == nil {
+		return
+	}
+	if err := advertise(r.ctx); err != nil {
+		log.Warningln("Failed to advertise the XRootD restart to the director:", err)
+	}
+}
+
+// Wait for the ongoing transfers to finish, up to Xrootd.RestartDrainPeriod
+func (r *xrootdRestarter) drain() {
+	deadline := time.Now().Add(param.Xrootd_RestartDrainPeriod.GetDuration())
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		activeIO, ok := metrics.GetActiveIO()
+		if !ok || activeIO == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			log.Warningf("Restarting XRootD with %d transfers still in progress after draining for %s", activeIO, param.Xrootd_RestartDrainPeriod.GetDuration().String())
+			return
+		}
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *xrootdRestarter) restart(reason string) error {
+	serverType := r.server.GetServerType()
+	isOrigin := serverType.IsEnabled(server_structs.OriginType)
+	log.Infof("Restarting the %s XRootD daemons: %s", serverType.String(), reason)
+	metrics.SetComponentHealthStatus(metrics.OriginCache_XRootD, metrics.StatusWarning, "XRootD is draining for a restart: "+reason)
+	r.setDraining(true)
+	defer r.setDraining(false)
+	r.drain()
+	if r.ctx.Err() != nil {
+		return nil
+	}
+
+	// Requests made up to now are served by this restart
+	r.mutex.Lock()
+	r.pending = ""
+	r.mutex.Unlock()
+
+	// Generate the new configuration while the old daemons still run, so that they keep
+	// serving if it's invalid
+	genCtx, cancel := context.WithCancel(r.ctx)
+	if _, err := ConfigXrootd(genCtx, isOrigin); err != nil {
+		cancel()
+		metrics.SetComponentHealthStatus(metrics.OriginCache_XRootD, metrics.StatusWarning,
+			"XRootD is running with its previous configuration; the restart failed: "+err.Error())
+		return err
+	}
+
+	metrics.SetComponentHealthStatus(metrics.OriginCache_XRootD, metrics.StatusWarning, "XRootD is restarting: "+reason)
+	r.mutex.Lock()
+	oldCancel, oldPids := r.cancel, r.pids
+	r.mutex.Unlock()
+	oldCancel()
+	stopDaemons(oldPids, restartStopTimeout)
+
+	err := CheckXrootdEnv(r.server)
+	if err == nil {
+		err = EmitScitokensConfig(r.server)
+	}
+	var pids []int
+	if err == nil {
+		pids, err = LaunchDaemons(genCtx, r.launchers, r.egrp, r.portStartCallback)
+	}
+	if err != nil {
+		cancel()
+		metrics.SetComponentHealthStatus(metrics.OriginCache_XRootD, metrics.StatusCritical, "XRootD failed to restart: "+err.Error())
+		return err
+	}
+
+	r.mutex.Lock()
+	r.cancel = cancel
+	r.pids = pids
+	r.restarts++
+	restarts := r.restarts
+	r.mutex.Unlock()
+	r.server.SetPids(replacePids(r.server.GetPids(), oldPids, pids))
+	log.Infof("Restarted the %s XRootD daemons (%d restarts since startup)", serverType.String(), restarts)
+	return nil
+}
+
+// Replace the PIDs of the old daemons by those of the new ones, keeping the PIDs of other daemons
+func replacePids(pids []int, oldPids []int, newPids []int) []int {
+	result := make([]int, 0, len(pids))
+	for _, pid := range pids {
+		if idx := slices.Index(oldPids, pid); idx >= 0 && idx < len(newPids) {
+			result = append(result, newPids[idx])
+		} else {
+			result = append(result, pid)
+		}
+	}
+	return result
+}
+
+// Ask the processes to exit and wait for them to do so, killing them after the timeout
+func stopDaemons(pids []int, timeout time.Duration) {
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+			log.Warningf("Failed to stop the daemon with pid %d: %v", pid, err)
+		}
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		running := []int{}
+		for _, pid := range pids {
+			if syscall.Kill(pid, 0) == nil {
+				running = append(running, pid)
+			}
+		}
+		if len(running) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			for _, pid := range running {
+				log.Warningf("The daemon with pid %d did not exit after %s; killing it", pid, timeout.String())
+				_ = syscall.Kill(pid, syscall.SIGKILL)
+			}
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,70 @@
+//go:build !windows
+
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package xrootd
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestReplacePids(t *testing.T) {
+	// The OA4MP daemon keeps running when XRootD and CMSD restart
+	assert.Equal(t, []int{20, 21, 12}, replacePids([]int{10, 11, 12}, []int{10, 11}, []int{20, 21}))
+	assert.Equal(t, []int{12}, replacePids([]int{12}, []int{10, 11}, []int{20, 21}))
+}
+
+func TestStopDaemons(t *testing.T) {
+	start := func(t *testing.T, script string) *exec.Cmd {
+		cmd := exec.Command("sh", "-c", script)
+		require.NoError(t, cmd.Start())
+		// Reap the process once it exits, as the daemon launchers do
+		go func() { _ = cmd.Wait() }()
+		return cmd
+	}
+
+	t.Run("graceful", func(t *testing.T) {
+		cmd := start(t, "sleep 60")
+		begin := time.Now()
+		stopDaemons([]int{cmd.Process.Pid}, 10*time.Second)
+		assert.Less(t, time.Since(begin), 5*time.Second)
+		assert.Error(t, syscall.Kill(cmd.Process.Pid, 0))
+	})
+
+	t.Run("killed-after-timeout", func(t *testing.T) {
+		cmd := start(t, "trap '' TERM; while true; do sleep 0.1; done")
+		// Give the shell time to install the trap
+		time.Sleep(200 * time.Millisecond)
+		stopDaemons([]int{cmd.Process.Pid}, 500*time.Millisecond)
+		assert.Eventually(t, func() bool { return syscall.Kill(cmd.Process.Pid, 0) != nil }, 5*time.Second, 50*time.Millisecond)
+	})
+}
+
+func TestRestartXrootdNotLaunched(t *testing.T) {
+	assert.Error(t, RestartXrootd(server_structs.CacheType, "test"))
+	assert.False(t, IsXrootdDraining(server_structs.CacheType))
+}
@@ -19,7 +19,6 @@
 package xrootd
 
 import (
-	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/tls"
@@ -36,7 +35,6 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
@@ -89,6 +87,8 @@ type (
 		EnablePublicReads bool
 		EnableListings    bool
 		SelfTest          bool
+		EnableVersioning  bool
+		EnableUploadScan  bool
 		CalculatedPort    string
 		FederationPrefix  string
 		HttpServiceUrl    string
@@ -719,6 +719,14 @@ func ConfigXrootd(ctx context.Context, isOrigin bool) (string, error) {
 				xrdConfig.Cache.LowWatermark = strconv.FormatFloat(float64(num)/100, 'f', 2, 64)
 			}
 		}
+
+		// Leave out the partitions taken out of service for IO errors so
+		// XRootD doesn't place new objects on them
+		dataLocations, err := cache.FilterOfflineDataLocations(xrdConfig.Cache.DataLocations)
+		if err != nil {
+			return "", err
+		}
+		xrdConfig.Cache.DataLocations = dataLocations
 	}
 
 	// To make sure we get the correct exports, we overwrite the exports in the xrdConfig struct with the exports
@@ -854,14 +862,19 @@ func ConfigXrootd(ctx context.Context, isOrigin bool) (string, error) {
 		}
 	}
 
-	var xrootdCfg string
-	if isOrigin {
-		xrootdCfg = xrootdOriginCfg
-	} else {
-		xrootdCfg = xrootdCacheCfg
+	templName, templ, err := getXrootdConfigTemplate(isOrigin)
+	if err != nil {
+		return "", err
 	}
-
-	templ := template.Must(template.New("xrootd.cfg").Parse(xrootdCfg))
+	xrootdCfg, err := renderXrootdConfig(templName, templ, xrdConfig, true)
+	if err != nil {
+		return "", err
+	}
+	extras, err := getXrootdConfigExtras(isOrigin, xrdConfig)
+	if err != nil {
+		return "", err
+	}
+	xrootdCfg = mergeXrootdConfigExtras(xrootdCfg, extras)
 
 	configPath := filepath.Join(param.Origin_RunLocation.GetString(), "xrootd.cfg")
 	if !isOrigin {
@@ -878,18 +891,12 @@ func ConfigXrootd(ctx context.Context, isOrigin bool) (string, error) {
 
 	defer file.Close()
 
-	err = templ.Execute(file, xrdConfig)
-	if err != nil {
+	if _, err = file.WriteString(xrootdCfg); err != nil {
 		return "", err
 	}
 
 	if log.IsLevelEnabled(log.DebugLevel) {
-		buffer := new(bytes.Buffer)
-		err = templ.Execute(buffer, xrdConfig)
-		if err != nil {
-			return "", err
-		}
-		log.Debugln("XRootD configuration file contents:\n", buffer.String())
+		log.Debugln("XRootD configuration file contents:\n", xrootdCfg)
 	}
 
 	return configPath, nil
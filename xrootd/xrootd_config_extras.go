@@ -0,0 +1,184 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package xrootd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+var (
+	// XRootD directives are prefixed by the component they configure, e.g. `xrd.port`
+	xrootdDirectivePattern = regexp.MustCompile(`^[a-z][a-z0-9]*\.[a-z][a-z0-9_.]*$`)
+
+	xrootdControlStatements = map[string]bool{
+		"if":     true,
+		"else":   true,
+		"fi":     true,
+		"set":    true,
+		"setenv": true,
+	}
+)
+
+// Get the template for the XRootD configuration, which is the built-in one unless
+// Xrootd.OriginConfigTemplate or Xrootd.CacheConfigTemplate overrides it
+func getXrootdConfigTemplate(isOrigin bool) (name string, templ string, err error) {
+	templateParam := param.Xrootd_CacheConfigTemplate
+	name, templ = "xrootd-cache.cfg", xrootdCacheCfg
+	if isOrigin {
+		templateParam = param.Xrootd_OriginConfigTemplate
+		name, templ = "xrootd-origin.cfg", xrootdOriginCfg
+	}
+	if location := templateParam.GetString(); location != "" {
+		contents, err := os.ReadFile(location)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "failed to read the XRootD configuration template %s given by %s", location, templateParam.GetName())
+		}
+		log.Infof("Using the XRootD configuration template %s instead of the built-in template", location)
+		return location, string(contents), nil
+	}
+	return
+}
+
+// Render a template for the XRootD configuration and check the result is XRootD configuration
+func renderXrootdConfig(name string, templ string, xrdConfig XrootdConfig, allowContinue bool) (string, error) {
+	parsed, err := template.New(filepath.Base(name)).Option("missingkey=error").Parse(templ)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse the XRootD configuration template %s", name)
+	}
+	buffer := new(bytes.Buffer)
+	if err = parsed.Execute(buffer, xrdConfig); err != nil {
+		return "", errors.Wrapf(err, "failed to render the XRootD configuration template %s", name)
+	}
+	if err = validateXrootdConfig(buffer.String(), allowContinue); err != nil {
+		return "", errors.Wrapf(err, "invalid XRootD configuration in %s", name)
+	}
+	return buffer.String(), nil
+}
+
+// Check that each line of the configuration is a comment, a directive, or a control statement.
+//
+// This won't catch a misspelled directive or a bad value, which XRootD reports at startup, but
+// it does catch content that isn't XRootD configuration at all.
+func validateXrootdConfig(cfg string, allowContinue bool) error {
+	continued := false
+	for idx, line := range strings.Split(cfg, "\n") {
+		line = strings.TrimSpace(line)
+		// A line ending in a backslash continues on the next line
+		wasContinued := continued
+		continued = strings.HasSuffix(line, "\\")
+		if wasContinued || line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		directive := strings.Fields(line)[0]
+		if directive == "continue" {
+			if !allowContinue {
+				return errors.Errorf("line %d: the continue directive isn't allowed in configuration snippets; use Xrootd.ConfigFile instead", idx+1)
+			}
+			continue
+		}
+		if !xrootdControlStatements[directive] && !xrootdDirectivePattern.MatchString(directive) {
+			return errors.Errorf("line %d: %q is not an XRootD directive", idx+1, directive)
+		}
+	}
+	return nil
+}
+
+// List the snippet files in a directory, in lexical order
+func listXrootdConfigSnippets(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the XRootD configuration snippet directory %s", dir)
+	}
+	snippets := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".cfg" {
+			continue
+		}
+		snippets = append(snippets, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(snippets)
+	return snippets, nil
+}
+
+// Render the snippets in Xrootd.ConfigExtras that apply to the server
+func getXrootdConfigExtras(isOrigin bool, xrdConfig XrootdConfig) (string, error) {
+	dir := param.Xrootd_ConfigExtras.GetString()
+	if dir == "" {
+		return "", nil
+	}
+	serverDir := filepath.Join(dir, "cache")
+	if isOrigin {
+		serverDir = filepath.Join(dir, "origin")
+	}
+
+	extras := strings.Builder{}
+	for _, snippetDir := range []string{dir, serverDir} {
+		snippets, err := listXrootdConfigSnippets(snippetDir)
+		if err != nil {
+			return "", err
+		}
+		for _, snippet := range snippets {
+			contents, err := os.ReadFile(snippet)
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to read the XRootD configuration snippet %s", snippet)
+			}
+			rendered, err := renderXrootdConfig(snippet, string(contents), xrdConfig, false)
+			if err != nil {
+				return "", err
+			}
+			log.Debugln("Merging the XRootD configuration snippet", snippet)
+			extras.WriteString("\n# Begin configuration snippet " + snippet + "\n")
+			extras.WriteString(strings.TrimRight(rendered, "\n"))
+			extras.WriteString("\n# End configuration snippet " + snippet + "\n")
+		}
+	}
+	return extras.String(), nil
+}
+
+// Merge the configuration snippets into the generated configuration.  They're added at the
+// end so they take precedence, but before the continuation to Xrootd.ConfigFile, which XRootD
+// processes last.
+func mergeXrootdConfigExtras(cfg string, extras string) string {
+	if extras == "" {
+		return cfg
+	}
+	lines := strings.SplitAfter(cfg, "\n")
+	for idx, line := range lines {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "continue" {
+			return strings.Join(lines[:idx], "") + extras + strings.Join(lines[idx:], "")
+		}
+	}
+	if !strings.HasSuffix(cfg, "\n") {
+		cfg += "\n"
+	}
+	return cfg + extras
+}
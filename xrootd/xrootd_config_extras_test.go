@@ -0,0 +1,144 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package xrootd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/test_utils"
+)
+
+func TestValidateXrootdConfig(t *testing.T) {
+	assert.NoError(t, validateXrootdConfig(`
+# A comment
+xrd.timeout idle 60m
+if exec xrootd
+  pfc.ram 4g \
+      with continuation
+fi
+continue /etc/xrootd/extra.cfg
+`, true))
+
+	err := validateXrootdConfig("xrd.timeout idle 60m\nTimeout: 60m\n", false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2")
+
+	assert.Error(t, validateXrootdConfig("continue /etc/xrootd/extra.cfg\n", false))
+}
+
+func TestMergeXrootdConfigExtras(t *testing.T) {
+	extras := "# Begin snippet\nxrd.timeout idle 60m\n"
+	assert.Equal(t, "xrd.port 8443\n"+extras, mergeXrootdConfigExtras("xrd.port 8443", extras))
+	assert.Equal(t, "xrd.port 8443\n"+extras+"continue /etc/extra.cfg\n",
+		mergeXrootdConfigExtras("xrd.port 8443\ncontinue /etc/extra.cfg\n", extras))
+	assert.Equal(t, "xrd.port 8443\n", mergeXrootdConfigExtras("xrd.port 8443\n", ""))
+}
+
+func TestXrootdConfigExtras(t *testing.T) {
+	ctx, cancel, egrp := test_utils.TestContext(context.Background(), t)
+	defer func() { require.NoError(t, egrp.Wait()) }()
+	defer cancel()
+
+	setup := func(t *testing.T) (extrasDir string) {
+		server_utils.ResetTestState()
+		t.Cleanup(server_utils.ResetTestState)
+		dirname := t.TempDir()
+		extrasDir = filepath.Join(dirname, "config.d")
+		require.NoError(t, os.MkdirAll(filepath.Join(extrasDir, "origin"), 0755))
+		require.NoError(t, os.MkdirAll(filepath.Join(extrasDir, "cache"), 0755))
+		viper.Set("ConfigDir", dirname)
+		viper.Set("Origin.RunLocation", dirname)
+		viper.Set("Xrootd.RunLocation", dirname)
+		viper.Set("Origin.StoragePrefix", "/")
+		viper.Set("Origin.FederationPrefix", "/")
+		viper.Set("Xrootd.ConfigExtras", extrasDir)
+		config.InitConfig()
+		return
+	}
+
+	t.Run("snippets", func(t *testing.T) {
+		extrasDir := setup(t)
+		require.NoError(t, os.WriteFile(filepath.Join(extrasDir, "20-timeout.cfg"), []byte("xrd.timeout idle 60m\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(extrasDir, "10-sched.cfg"), []byte("xrd.sched mint 8 maxt 512\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(extrasDir, "origin", "10-site.cfg"), []byte("all.sitename {{.Xrootd.Sitename}}-extra\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(extrasDir, "cache", "10-pfc.cfg"), []byte("pfc.ram 4g\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(extrasDir, "README"), []byte("Not a snippet"), 0644))
+		viper.Set("Xrootd.Sitename", "my-origin")
+
+		configPath, err := ConfigXrootd(ctx, true)
+		require.NoError(t, err)
+		content, err := os.ReadFile(configPath)
+		require.NoError(t, err)
+		cfg := string(content)
+
+		sched := strings.Index(cfg, "xrd.sched mint 8 maxt 512")
+		timeout := strings.Index(cfg, "xrd.timeout idle 60m")
+		site := strings.Index(cfg, "all.sitename my-origin-extra")
+		require.True(t, sched > 0 && timeout > 0 && site > 0, "snippets missing from the config:\n%s", cfg)
+		// Snippets for both servers come first, each in lexical order, after the generated directives
+		assert.Less(t, strings.Index(cfg, "all.sitename my-origin\n"), sched)
+		assert.Less(t, sched, timeout)
+		assert.Less(t, timeout, site)
+		assert.NotContains(t, cfg, "pfc.ram 4g")
+		assert.NotContains(t, cfg, "Not a snippet")
+	})
+
+	t.Run("invalid-snippet", func(t *testing.T) {
+		extrasDir := setup(t)
+		require.NoError(t, os.WriteFile(filepath.Join(extrasDir, "bad.cfg"), []byte("timeout = 60\n"), 0644))
+		_, err := ConfigXrootd(ctx, true)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "bad.cfg")
+
+		require.NoError(t, os.WriteFile(filepath.Join(extrasDir, "bad.cfg"), []byte("xrd.timeout {{.Xrootd.NoSuchValue}}\n"), 0644))
+		_, err = ConfigXrootd(ctx, true)
+		assert.Error(t, err)
+	})
+
+	t.Run("template-override", func(t *testing.T) {
+		extrasDir := setup(t)
+		templ := filepath.Join(t.TempDir(), "xrootd-origin.cfg")
+		require.NoError(t, os.WriteFile(templ, []byte("all.role server\nxrd.port {{.Xrootd.Port}}\ncontinue /etc/extra.cfg\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(extrasDir, "10-timeout.cfg"), []byte("xrd.timeout idle 60m\n"), 0644))
+		viper.Set("Xrootd.OriginConfigTemplate", templ)
+		viper.Set("Origin.Port", 8443)
+
+		configPath, err := ConfigXrootd(ctx, true)
+		require.NoError(t, err)
+		content, err := os.ReadFile(configPath)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(content), "all.role server\nxrd.port "))
+		assert.True(t, strings.HasSuffix(string(content), "xrd.timeout idle 60m\n# End configuration snippet "+
+			filepath.Join(extrasDir, "10-timeout.cfg")+"\ncontinue /etc/extra.cfg\n"))
+
+		require.NoError(t, os.WriteFile(templ, []byte("xrd.port {{.Xrootd.Port\n"), 0644))
+		_, err = ConfigXrootd(ctx, true)
+		assert.Error(t, err)
+	})
+}